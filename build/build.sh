@@ -13,16 +13,13 @@ function build() {
     OUTPUT_BIN=$OUTPUT/bin
     mkdir -p $OUTPUT_BIN
 
-    # add otectl to output directory
-    cp $WORKROOT/deployments/otectl $OUTPUT_BIN
-    if [ $? -ne 0 ]; then
-        exit 1
-    fi
-    # build clustercontroller and cluster shim
+    # build clustercontroller, cluster shim, otectl and loadgen
     go build -o $OUTPUT_BIN/clustercontroller ./cmd/clustercontroller && \
         go build -o $OUTPUT_BIN/k8s_cluster_shim ./cmd/k8s_cluster_shim && \
         go build -o $OUTPUT_BIN/k3s_cluster_shim ./cmd/k3s_cluster_shim && \
         go build -o $OUTPUT_BIN/ote_controller_manager ./cmd/ote_controller_manager && \
+        go build -o $OUTPUT_BIN/otectl ./cmd/otectl && \
+        go build -o $OUTPUT_BIN/loadgen ./cmd/loadgen && \
         echo "build done"
 }
 
@@ -22,6 +22,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 	"k8s.io/klog"
@@ -29,12 +30,21 @@ import (
 	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
 	"github.com/baidu/ote-stack/pkg/clustershim"
 	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+	"github.com/baidu/ote-stack/pkg/config"
+	"github.com/baidu/ote-stack/pkg/envelope"
 	"github.com/baidu/ote-stack/pkg/k8sclient"
 )
 
 var (
-	shimSock   string
-	kubeConfig string
+	shimSock     string
+	kubeConfig   string
+	readCacheTTL time.Duration
+
+	latencyBudget             time.Duration
+	destinationLatencyBudgets map[string]string
+
+	kmsURL      string
+	envelopeKey string
 )
 
 // NewK3sClusterShimCommand creates a *cobra.Command object with default parameters.
@@ -62,8 +72,18 @@ func NewK3sClusterShimCommand() *cobra.Command {
 
 	cmd.AddCommand(versionCmd)
 	cmd.PersistentFlags().StringVarP(&shimSock, "listen", "l",
-		":8262", "Websocket address of ClusterShim")
+		":8262", "Websocket address of ClusterShim, e.g., :8262, or unix socket like unix:///var/run/ote/shim.sock")
 	cmd.PersistentFlags().StringVarP(&kubeConfig, "kube-config", "k", "/root/.kube/config", "KubeConfig file path")
+	cmd.PersistentFlags().DurationVar(&readCacheTTL, "read-cache-ttl", 0,
+		"time to cache responses of GET requests to apiserver, e.g., 5s, 0 means no cache")
+	cmd.PersistentFlags().DurationVar(&latencyBudget, "latency-budget", 0,
+		"time a request to a destination is expected to be done in, slower ones are logged and counted, 0 means no budget")
+	cmd.PersistentFlags().StringToStringVar(&destinationLatencyBudgets, "destination-latency-budgets", nil,
+		"latency budgets of destinations overriding --latency-budget, e.g., api=1s,exec=30s")
+	cmd.PersistentFlags().StringVar(&kmsURL, "kms-url", "",
+		"key manager to open sealed bodies of requests, file:///DIR of key files or url of vault transit engine like https://vault:8200/v1/transit with token in env VAULT_TOKEN")
+	cmd.PersistentFlags().StringVar(&envelopeKey, "envelope-key", "",
+		"key of this cluster in --kms-url to open sealed bodies of requests, e.g., ote-c1, sealed bodies are not opened if empty")
 	fs := cmd.Flags()
 	fs.AddGoFlagSet(flag.CommandLine)
 
@@ -72,6 +92,9 @@ func NewK3sClusterShimCommand() *cobra.Command {
 
 // Run runs the k3s cluster shim.
 func Run() error {
+	if err := config.ValidateAddress(shimSock); err != nil {
+		return err
+	}
 	// make client to k3s apiserver.
 	k3sClient, err := k8sclient.NewK8sClient(k8sclient.K8sOption{KubeConfig: kubeConfig})
 	if err != nil {
@@ -82,8 +105,46 @@ func Run() error {
 	signals := make(chan os.Signal, 0)
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 
+	budgets, err := handler.ParseLatencyBudgets(destinationLatencyBudgets)
+	if err != nil {
+		return err
+	}
+	handler.SetLatencyBudgets(latencyBudget, budgets)
+
 	s := clustershim.NewShimServer()
-	s.RegisterHandler(otev1.ClusterControllerDestAPI, handler.NewK8sHandler(k3sClient))
+	apiHandler := handler.NewK8sHandler(k3sClient)
+	if readCacheTTL > 0 {
+		apiHandler = handler.NewReadCacheHandler(apiHandler, readCacheTTL)
+	}
+	// quotas are checked on bodies opened by envelope handlers.
+	quotaGuard := handler.NewQuotaGuard(k3sClient)
+	apiHandler = handler.NewQuotaHandler(apiHandler, quotaGuard)
+	applyHandler := handler.NewQuotaHandler(handler.NewApplyHandler(k3sClient), quotaGuard)
+	if envelopeKey != "" {
+		km, err := envelope.NewKeyManager(kmsURL)
+		if err != nil {
+			return err
+		}
+		apiHandler = handler.NewEnvelopeHandler(apiHandler, km, envelopeKey)
+		applyHandler = handler.NewEnvelopeHandler(applyHandler, km, envelopeKey)
+	}
+	s.RegisterHandler(otev1.ClusterControllerDestAPI, apiHandler)
+	s.RegisterHandler(otev1.ClusterControllerDestLog, handler.NewLogHandler(k3sClient))
+	s.RegisterHandler(otev1.ClusterControllerDestPrePull, handler.NewPrePullHandler(k3sClient))
+	s.RegisterHandler(otev1.ClusterControllerDestJob, handler.NewJobHandler(k3sClient))
+	s.RegisterHandler(otev1.ClusterControllerDestProxy, handler.NewProxyHandler(k3sClient))
+	s.RegisterHandler(otev1.ClusterControllerDestApply, applyHandler)
+	s.RegisterHandler(otev1.ClusterControllerDestPrune, handler.NewPruneHandler(k3sClient))
+	s.RegisterHandler(otev1.ClusterControllerDestQuota, quotaGuard)
+	restConfig, err := k8sclient.GetRestConfig(kubeConfig)
+	if err != nil {
+		return err
+	}
+	execHandler, err := handler.NewExecHandler(restConfig)
+	if err != nil {
+		return err
+	}
+	s.RegisterHandler(otev1.ClusterControllerDestExec, execHandler)
 
 	go func() {
 		<-signals
@@ -32,6 +32,8 @@ import (
 	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
 	"github.com/baidu/ote-stack/pkg/clustershim"
 	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+	"github.com/baidu/ote-stack/pkg/config"
+	"github.com/baidu/ote-stack/pkg/envelope"
 	"github.com/baidu/ote-stack/pkg/k8sclient"
 	"github.com/baidu/ote-stack/pkg/reporter"
 )
@@ -40,6 +42,18 @@ var (
 	shimSock   string
 	kubeConfig string
 	helmConfig string
+
+	nodeNotReadyGracePeriod time.Duration
+	readCacheTTL            time.Duration
+	parentEndpoint          string
+	dnsNames                []string
+	certExpiryThreshold     time.Duration
+
+	latencyBudget             time.Duration
+	destinationLatencyBudgets map[string]string
+
+	kmsURL      string
+	envelopeKey string
 )
 
 const (
@@ -71,9 +85,27 @@ func NewK8sClusterShimCommand() *cobra.Command {
 
 	cmd.AddCommand(versionCmd)
 	cmd.PersistentFlags().StringVarP(&shimSock, "listen", "l",
-		":8262", "Websocket address of ClusterShim")
+		":8262", "Websocket address of ClusterShim, e.g., :8262, or unix socket like unix:///var/run/ote/shim.sock")
 	cmd.PersistentFlags().StringVarP(&kubeConfig, "kube-config", "k", "/root/.kube/config", "KubeConfig file path")
 	cmd.PersistentFlags().StringVarP(&helmConfig, "helm-addr", "", "", "Helm proxy address")
+	cmd.PersistentFlags().DurationVar(&nodeNotReadyGracePeriod, "node-notready-grace-period", 0,
+		"time a ready node turning not ready is still reported as ready, 0 reports NotReady at once")
+	cmd.PersistentFlags().DurationVar(&readCacheTTL, "read-cache-ttl", 0,
+		"time to cache responses of GET requests to apiserver, e.g., 5s, 0 means no cache")
+	cmd.PersistentFlags().StringVar(&parentEndpoint, "connectivity-parent", "",
+		"address of the parent cluster to check reachability in condition Connectivity, e.g., 192.168.0.3:8287, not checked if empty")
+	cmd.PersistentFlags().StringSliceVar(&dnsNames, "connectivity-dns-names", nil,
+		"names to resolve by local dns in condition Connectivity, e.g., kubernetes.default.svc.cluster.local, not checked if empty")
+	cmd.PersistentFlags().DurationVar(&certExpiryThreshold, "cert-expiry-threshold", 30*24*time.Hour,
+		"time before expiry of certificates of apiserver and kubeconfig to raise condition CertificateExpiring, 0 means not checked")
+	cmd.PersistentFlags().DurationVar(&latencyBudget, "latency-budget", 0,
+		"time a request to a destination is expected to be done in, slower ones are logged and counted, 0 means no budget")
+	cmd.PersistentFlags().StringToStringVar(&destinationLatencyBudgets, "destination-latency-budgets", nil,
+		"latency budgets of destinations overriding --latency-budget, e.g., api=1s,exec=30s")
+	cmd.PersistentFlags().StringVar(&kmsURL, "kms-url", "",
+		"key manager to open sealed bodies of requests, file:///DIR of key files or url of vault transit engine like https://vault:8200/v1/transit with token in env VAULT_TOKEN")
+	cmd.PersistentFlags().StringVar(&envelopeKey, "envelope-key", "",
+		"key of this cluster in --kms-url to open sealed bodies of requests, e.g., ote-c1, sealed bodies are not opened if empty")
 	fs := cmd.Flags()
 	fs.AddGoFlagSet(flag.CommandLine)
 
@@ -82,6 +114,9 @@ func NewK8sClusterShimCommand() *cobra.Command {
 
 // Run runs the k8s cluster shim.
 func Run() error {
+	if err := config.ValidateAddresses(shimSock, parentEndpoint); err != nil {
+		return err
+	}
 	// make client to k8s apiserver.
 	k8sClient, err := k8sclient.NewK8sClient(k8sclient.K8sOption{KubeConfig: kubeConfig})
 	if err != nil {
@@ -92,8 +127,46 @@ func Run() error {
 	signals := make(chan os.Signal, 0)
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 
+	budgets, err := handler.ParseLatencyBudgets(destinationLatencyBudgets)
+	if err != nil {
+		return err
+	}
+	handler.SetLatencyBudgets(latencyBudget, budgets)
+
 	s := clustershim.NewShimServer()
-	s.RegisterHandler(otev1.ClusterControllerDestAPI, handler.NewK8sHandler(k8sClient))
+	apiHandler := handler.NewK8sHandler(k8sClient)
+	if readCacheTTL > 0 {
+		apiHandler = handler.NewReadCacheHandler(apiHandler, readCacheTTL)
+	}
+	// quotas are checked on bodies opened by envelope handlers.
+	quotaGuard := handler.NewQuotaGuard(k8sClient)
+	apiHandler = handler.NewQuotaHandler(apiHandler, quotaGuard)
+	applyHandler := handler.NewQuotaHandler(handler.NewApplyHandler(k8sClient), quotaGuard)
+	if envelopeKey != "" {
+		km, err := envelope.NewKeyManager(kmsURL)
+		if err != nil {
+			return err
+		}
+		apiHandler = handler.NewEnvelopeHandler(apiHandler, km, envelopeKey)
+		applyHandler = handler.NewEnvelopeHandler(applyHandler, km, envelopeKey)
+	}
+	s.RegisterHandler(otev1.ClusterControllerDestAPI, apiHandler)
+	s.RegisterHandler(otev1.ClusterControllerDestLog, handler.NewLogHandler(k8sClient))
+	s.RegisterHandler(otev1.ClusterControllerDestPrePull, handler.NewPrePullHandler(k8sClient))
+	s.RegisterHandler(otev1.ClusterControllerDestJob, handler.NewJobHandler(k8sClient))
+	s.RegisterHandler(otev1.ClusterControllerDestProxy, handler.NewProxyHandler(k8sClient))
+	s.RegisterHandler(otev1.ClusterControllerDestApply, applyHandler)
+	s.RegisterHandler(otev1.ClusterControllerDestPrune, handler.NewPruneHandler(k8sClient))
+	s.RegisterHandler(otev1.ClusterControllerDestQuota, quotaGuard)
+	restConfig, err := k8sclient.GetRestConfig(kubeConfig)
+	if err != nil {
+		return err
+	}
+	execHandler, err := handler.NewExecHandler(restConfig)
+	if err != nil {
+		return err
+	}
+	s.RegisterHandler(otev1.ClusterControllerDestExec, execHandler)
 	// TODO directly connect helm tiller.
 	s.RegisterHandler(otev1.ClusterControllerDestHelm, handler.NewHTTPProxyHandler(helmConfig))
 
@@ -106,8 +179,16 @@ func Run() error {
 		SyncChan:        s.SendChan(),
 		StopChan:        ctx.Done(),
 		KubeClient:      k8sClient,
+
+		NodeNotReadyGracePeriod: nodeNotReadyGracePeriod,
+		ParentEndpoint:          parentEndpoint,
+		DNSNames:                dnsNames,
+		RestConfig:              restConfig,
+		CertExpiryThreshold:     certExpiryThreshold,
 	}
 
+	s.RegisterHandler(otev1.ClusterControllerDestResync, handler.NewResyncHandler(reporterContext.Resync))
+
 	err = startReporters(reporterContext)
 	if err != nil {
 		klog.Fatalf("start reporters failed: %v", err)
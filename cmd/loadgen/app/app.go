@@ -0,0 +1,91 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package app set flags and command to loadgen, and run the benchmark.
+package app
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/baidu/ote-stack/pkg/benchmark"
+)
+
+var (
+	conf          = benchmark.Default()
+	minThroughput float64
+	maxP99        time.Duration
+)
+
+// NewLoadgenCommand creates a *cobra.Command object with default parameters.
+func NewLoadgenCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "loadgen",
+		Short: "loadgen benchmarks the message pipeline of ote-stack",
+		Long: `loadgen simulates edge clusters sending reports to an in-process
+		root clustercontroller and ote controller manager, and reports throughput and latency`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return Run()
+		},
+	}
+
+	cmd.PersistentFlags().IntVarP(&conf.Clusters, "clusters", "n", conf.Clusters,
+		"Number of simulated edge clusters")
+	cmd.PersistentFlags().Float64VarP(&conf.Rate, "rate", "r", conf.Rate,
+		"Reports sent per second by each edge cluster")
+	cmd.PersistentFlags().DurationVarP(&conf.Duration, "duration", "d", conf.Duration,
+		"Time to send reports")
+	cmd.PersistentFlags().IntVarP(&conf.PodsPerReport, "pods", "p", conf.PodsPerReport,
+		"Number of pods in each report")
+	cmd.PersistentFlags().DurationVar(&conf.DrainTimeout, "drain-timeout", conf.DrainTimeout,
+		"Time to wait for reports in flight after sending stopped")
+	cmd.PersistentFlags().Float64Var(&minThroughput, "min-throughput", 0,
+		"Fail if throughput(reports/s) is lower than this, 0 means no check")
+	cmd.PersistentFlags().DurationVar(&maxP99, "max-p99", 0,
+		"Fail if p99 latency is higher than this, 0 means no check")
+	fs := cmd.Flags()
+	fs.AddGoFlagSet(flag.CommandLine)
+
+	return cmd
+}
+
+// Run runs the benchmark and checks the result against thresholds.
+func Run() error {
+	fmt.Printf("running %d clusters at %v reports/s each for %v\n",
+		conf.Clusters, conf.Rate, conf.Duration)
+	result, err := benchmark.Run(conf)
+	if err != nil {
+		return err
+	}
+	result.Print(os.Stdout)
+
+	if result.Failed != 0 || result.Errors != 0 || result.Lost() != 0 {
+		return fmt.Errorf("%d reports failed, %d errors, %d lost",
+			result.Failed, result.Errors, result.Lost())
+	}
+	if minThroughput > 0 && result.Throughput < minThroughput {
+		return fmt.Errorf("throughput %.1f reports/s is lower than %.1f", result.Throughput, minThroughput)
+	}
+	if maxP99 > 0 && result.LatencyP99 > maxP99 {
+		return fmt.Errorf("p99 latency %v is higher than %v", result.LatencyP99, maxP99)
+	}
+	return nil
+}
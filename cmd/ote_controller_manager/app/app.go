@@ -22,6 +22,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -33,13 +34,32 @@ import (
 	"k8s.io/client-go/tools/leaderelection/resourcelock"
 	"k8s.io/klog"
 
+	"github.com/baidu/ote-stack/pkg/admin"
+	"github.com/baidu/ote-stack/pkg/config"
+	"github.com/baidu/ote-stack/pkg/controller"
 	"github.com/baidu/ote-stack/pkg/controller/clustercrd"
+	"github.com/baidu/ote-stack/pkg/controller/clusterproxy"
+	"github.com/baidu/ote-stack/pkg/controller/crontask"
+	"github.com/baidu/ote-stack/pkg/controller/decommission"
+	"github.com/baidu/ote-stack/pkg/controller/drift"
+	"github.com/baidu/ote-stack/pkg/controller/epoch"
+	"github.com/baidu/ote-stack/pkg/controller/eventbus"
+	"github.com/baidu/ote-stack/pkg/controller/gitops"
+	"github.com/baidu/ote-stack/pkg/controller/health"
+	"github.com/baidu/ote-stack/pkg/controller/inventory"
 	"github.com/baidu/ote-stack/pkg/controller/namespace"
+	"github.com/baidu/ote-stack/pkg/controller/quota"
+	"github.com/baidu/ote-stack/pkg/controller/scaffold"
+	"github.com/baidu/ote-stack/pkg/controller/serviceimport"
+	"github.com/baidu/ote-stack/pkg/controller/transform"
+	"github.com/baidu/ote-stack/pkg/controller/upgrade"
+	"github.com/baidu/ote-stack/pkg/controller/webhook"
 	"github.com/baidu/ote-stack/pkg/controllermanager"
 	"github.com/baidu/ote-stack/pkg/eventrecorder"
 	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned"
 	oteinformer "github.com/baidu/ote-stack/pkg/generated/informers/externalversions"
 	"github.com/baidu/ote-stack/pkg/k8sclient"
+	"github.com/baidu/ote-stack/pkg/reporter"
 	"github.com/baidu/ote-stack/pkg/tunnel"
 )
 
@@ -57,9 +77,30 @@ var (
 	kubeBurst                 int
 	kubeQps                   float32
 	rootClusterControllerAddr string
+	gitopsConf                gitops.Config
+	webhookConfig             string
+	eventBusConfig            string
+	scaffoldConfig            string
+	upgradeConf               upgrade.Config
+	proxyConf                 clusterproxy.Config
+	epochConf                 epoch.Config
+	driftInterval             time.Duration
+	nodeUnreachableToleration time.Duration
+	adjustClockSkew           bool
+	reportEncodings           []string
+	tunnelTLS                 tunnel.TLSConfig
+	tlsReload                 time.Duration
+	adminListenAddr           string
 	Controllers               = map[string]controllermanager.InitFunc{
-		"clustercrd": clustercrd.InitClusterCrdController,
-		"namespace":  namespace.InitNamespaceController,
+		"clustercrd":    clustercrd.InitClusterCrdController,
+		"crontask":      crontask.InitCronTaskController,
+		"decommission":  decommission.InitDecommissionController,
+		"health":        health.InitHealthController,
+		"inventory":     inventory.InitInventoryController,
+		"namespace":     namespace.InitNamespaceController,
+		"quota":         quota.InitQuotaController,
+		"serviceimport": serviceimport.InitServiceImportController,
+		"transform":     transform.InitTransformController,
 	}
 )
 
@@ -92,11 +133,70 @@ func NewOTEControllerManagerCommand() *cobra.Command {
 		"/root/.kube/config", "KubeConfig file path")
 	cmd.PersistentFlags().StringVarP(&rootClusterControllerAddr, "root-cluster-controller", "r",
 		":8272",
-		"root clustercontroller address, could be a front load balancer, e.g., 192.168.0.4:8272")
+		"root clustercontroller address, could be a front load balancer, e.g., 192.168.0.4:8272, "+
+			"or addresses of all root frontends sharing childs separated by comma")
 	cmd.PersistentFlags().IntVarP(&kubeBurst, "kube-api-burst", "b", 0,
 		"Burst to use while talking with kubernetes apiserver")
 	cmd.PersistentFlags().Float32VarP(&kubeQps, "kube-api-qps", "q", 0.0,
 		"qps to use while talking with kubernetes apiserver")
+	cmd.PersistentFlags().StringVar(&gitopsConf.Repo, "gitops-repo", "",
+		"git repository to sync clustercontrollers from, gitops controller is disabled if empty")
+	cmd.PersistentFlags().StringVar(&gitopsConf.Branch, "gitops-branch", gitops.DefaultBranch,
+		"branch of git repository to sync")
+	cmd.PersistentFlags().StringVar(&gitopsConf.Path, "gitops-path", "",
+		"directory in git repository containing clustercontroller manifests")
+	cmd.PersistentFlags().StringVar(&gitopsConf.WorkDir, "gitops-workdir", "",
+		"local directory to check out git repository, a temp directory is used if empty")
+	cmd.PersistentFlags().DurationVar(&gitopsConf.Interval, "gitops-interval", gitops.DefaultInterval,
+		"interval to sync from git repository")
+	cmd.PersistentFlags().BoolVar(&gitopsConf.Prune, "gitops-prune", false,
+		"delete clustercontrollers applied by gitops but removed from git repository")
+	cmd.PersistentFlags().StringVar(&webhookConfig, "webhook-config", "",
+		"file of webhooks notified with cluster and command events, in yaml or json")
+	cmd.PersistentFlags().StringVar(&eventBusConfig, "event-bus-config", "",
+		"file of kafka or nats brokers published with cluster events and task results, in yaml or json")
+	cmd.PersistentFlags().StringVar(&scaffoldConfig, "scaffold-config", "",
+		"file of scaffolds of namespaces, roles and rolebindings ensured on clusters, in yaml or json")
+	cmd.PersistentFlags().DurationVar(&nodeUnreachableToleration, "node-unreachable-toleration", 0,
+		"time pods reported from edge tolerate their nodes not ready or unreachable in center, 0 keeps tolerations reported")
+	cmd.PersistentFlags().BoolVar(&adjustClockSkew, "adjust-clock-skew", false,
+		"convert timestamps of cluster status reported from edge to the clock of root by clock skew of the cluster")
+	cmd.PersistentFlags().StringSliceVar(&reportEncodings, "report-encodings", []string{reporter.EncodingJSON},
+		"encodings of edge reports offered to root clustercontroller in preference order, json, protobuf or cbor")
+	cmd.PersistentFlags().StringVar(&tunnelTLS.CAFile, "tunnel-tls-ca", "",
+		"ca file to verify the certificate of root clustercontroller, connected in plain text if empty")
+	cmd.PersistentFlags().StringVar(&tunnelTLS.CertFile, "tunnel-tls-cert", "",
+		"cert file presented to root clustercontroller, whose common name must be "+tunnel.ControllerManagerCertName)
+	cmd.PersistentFlags().StringVar(&tunnelTLS.KeyFile, "tunnel-tls-key", "",
+		"key file of tunnel tls cert")
+	cmd.PersistentFlags().DurationVar(&tlsReload, "tunnel-tls-reload-interval", time.Minute,
+		"interval to check tunnel tls files and reload them if changed, 0 means not reloaded")
+	cmd.PersistentFlags().StringVar(&upgradeConf.Image, "upgrade-image", "",
+		"clustercontroller image to upgrade edge clusters to, upgrade controller is disabled if empty")
+	cmd.PersistentFlags().StringVar(&upgradeConf.Version, "upgrade-version", "",
+		"version reported by clustercontroller of the upgrade image")
+	cmd.PersistentFlags().StringVar(&upgradeConf.Order, "upgrade-order", upgrade.OrderTopDown,
+		"order to upgrade clusters wave by wave, top-down(parents first) or bottom-up(children first)")
+	cmd.PersistentFlags().StringVar(&upgradeConf.Selector, "upgrade-selector", "",
+		"selector of clusters to upgrade, all clusters if empty")
+	cmd.PersistentFlags().DurationVar(&upgradeConf.WaveTimeout, "upgrade-wave-timeout", upgrade.DefaultWaveTimeout,
+		"time to wait for clusters of a wave upgraded, the upgrade halts after timeout")
+	cmd.PersistentFlags().StringVar(&proxyConf.ListenAddr, "proxy-listen", "",
+		"address to serve k8s api of edge clusters at /proxy/clusters/{name}/, e.g., :8273, cluster proxy is disabled if empty")
+	cmd.PersistentFlags().DurationVar(&proxyConf.Timeout, "proxy-timeout", clusterproxy.DefaultTimeout,
+		"time to wait for the response of a request proxied to edge cluster")
+	cmd.PersistentFlags().StringVar(&proxyConf.AggregateSource, "aggregate-source", clusterproxy.AggregateSourceEdge,
+		"source of lists across clusters at /aggregate/ of cluster proxy, edge or mirror")
+	cmd.PersistentFlags().StringVar(&epochConf.ConfigMap, "state-epoch-configmap", "",
+		"namespace/name of the configmap holding the state epoch announced to clusters, epoch controller is disabled if empty")
+	cmd.PersistentFlags().DurationVar(&epochConf.Interval, "state-epoch-interval", epoch.DefaultInterval,
+		"interval to announce the state epoch to clusters")
+	cmd.PersistentFlags().DurationVar(&driftInterval, "drift-interval", 0,
+		"interval to compute drift of clusters from typed tasks of clustercontrollers into cluster status, 0 disables drift reports")
+	cmd.PersistentFlags().DurationVar(&controller.PruneInterval, "prune-interval", 0,
+		"interval to send pruning manifests to clusters, which delete objects distributed but removed in center, 0 disables pruning")
+	cmd.PersistentFlags().StringVar(&adminListenAddr, "admin-listen", "",
+		"admin server listen address serving health of clusters, e.g., 127.0.0.1:8290, disabled if empty")
 	fs := cmd.Flags()
 	fs.AddGoFlagSet(flag.CommandLine)
 
@@ -128,8 +228,69 @@ func Run() error {
 		return err
 	}
 
-	// connect to root clustercontroller
-	controllerTunnel := tunnel.NewControllerTunnel(rootClusterControllerAddr)
+	controllermanager.NodeUnreachableTolerationSeconds = int64(nodeUnreachableToleration.Seconds())
+	controllermanager.AdjustClockSkew = adjustClockSkew
+	if err := reporter.SetEncodings(reportEncodings); err != nil {
+		return err
+	}
+	if err := tunnel.SetupTLS(tunnelTLS); err != nil {
+		return err
+	}
+	if tlsReload > 0 && tunnelTLS.CertFile != "" {
+		go tunnel.WatchTLS(tlsReload, make(chan struct{}))
+	}
+	if gitopsConf.Repo != "" {
+		Controllers["gitops"] = gitops.NewInitFunc(&gitopsConf)
+	}
+	if webhookConfig != "" {
+		conf, err := webhook.LoadConfig(webhookConfig)
+		if err != nil {
+			return err
+		}
+		Controllers["webhook"] = webhook.NewInitFunc(conf)
+	}
+	if eventBusConfig != "" {
+		conf, err := eventbus.LoadConfig(eventBusConfig)
+		if err != nil {
+			return err
+		}
+		Controllers["eventbus"] = eventbus.NewInitFunc(conf)
+	}
+	if scaffoldConfig != "" {
+		conf, err := scaffold.LoadConfig(scaffoldConfig)
+		if err != nil {
+			return err
+		}
+		Controllers["scaffold"] = scaffold.NewInitFunc(conf)
+	}
+	if upgradeConf.Image != "" {
+		Controllers["upgrade"] = upgrade.NewInitFunc(&upgradeConf)
+	}
+	if proxyConf.ListenAddr != "" {
+		Controllers["clusterproxy"] = clusterproxy.NewInitFunc(&proxyConf)
+	}
+	if epochConf.ConfigMap != "" {
+		Controllers["epoch"] = epoch.NewInitFunc(&epochConf)
+	}
+	if driftInterval > 0 {
+		Controllers["drift"] = drift.NewInitFunc(driftInterval)
+	}
+
+	if err := startAdminServer(); err != nil {
+		return err
+	}
+
+	// connect to root clustercontroller, or all root frontends
+	var controllerTunnel tunnel.ControllerTunnel
+	addrs := strings.Split(rootClusterControllerAddr, ",")
+	if err := config.ValidateAddresses(addrs...); err != nil {
+		return err
+	}
+	if len(addrs) > 1 {
+		controllerTunnel = tunnel.NewMultiControllerTunnel(addrs)
+	} else {
+		controllerTunnel = tunnel.NewControllerTunnel(rootClusterControllerAddr)
+	}
 	ctx := createControllerContext(oteClient, k8sClient)
 	upstreamProcessor := controllermanager.NewUpstreamProcessor(&ctx.K8sContext)
 	controllerTunnel.RegistReceiveMessageHandler(upstreamProcessor.HandleReceivedMessage)
@@ -192,6 +353,16 @@ func Run() error {
 	return nil
 }
 
+// startAdminServer starts admin server if admin listen address is set.
+func startAdminServer() error {
+	if adminListenAddr == "" {
+		return nil
+	}
+	server := admin.NewServer(adminListenAddr)
+	server.HandleFunc("/cluster-health", health.Handler)
+	return server.Start()
+}
+
 func createControllerContext(oteClient oteclient.Interface,
 	k8sClient kubernetes.Interface) *controllermanager.ControllerContext {
 	oteSharedInformers := oteinformer.NewSharedInformerFactory(oteClient, informerDuration)
@@ -0,0 +1,778 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package app set flags and commands to otectl, and run the command.
+package app
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+	"github.com/baidu/ote-stack/pkg/envelope"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned"
+	"github.com/baidu/ote-stack/pkg/k8sclient"
+	"github.com/baidu/ote-stack/pkg/objectref"
+	"github.com/baidu/ote-stack/pkg/otectl"
+	"github.com/baidu/ote-stack/pkg/reporter"
+)
+
+var (
+	kubeConfig string
+)
+
+// NewOtectlCommand creates a *cobra.Command object with default parameters.
+func NewOtectlCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "otectl",
+		Short: "otectl controls the ote clusters",
+		Long: `otectl is the command line tool of ote-stack,
+		which shows and operates clusters managed by root clustercontroller`,
+	}
+
+	versionCmd := &cobra.Command{
+		Use:   "version",
+		Short: "Show version",
+		Long:  "",
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Println("OTE otectl 1.0")
+		},
+	}
+
+	cmd.AddCommand(versionCmd)
+	cmd.AddCommand(newTreeCommand())
+	cmd.AddCommand(newGetCommand())
+	cmd.AddCommand(newDescribeCommand())
+	cmd.AddCommand(newRunCommand())
+	cmd.AddCommand(newSelectorCommand())
+	cmd.AddCommand(newDryRunCommand())
+	cmd.AddCommand(newExecCommand())
+	cmd.AddCommand(newLogsCommand())
+	cmd.AddCommand(newDiagnoseCommand())
+	cmd.AddCommand(newCaptureCommand())
+	cmd.AddCommand(newPrePullCommand())
+	cmd.AddCommand(newJobCommand())
+	cmd.AddCommand(newJoinManifestCommand())
+	cmd.AddCommand(newDecommissionCommand())
+	cmd.AddCommand(newKubectlCommand())
+	cmd.PersistentFlags().StringVarP(&kubeConfig, "kube-config", "k", "/root/.kube/config",
+		"KubeConfig file path of root cluster")
+	fs := cmd.Flags()
+	fs.AddGoFlagSet(flag.CommandLine)
+
+	return cmd
+}
+
+func newTreeCommand() *cobra.Command {
+	watch := false
+	cmd := &cobra.Command{
+		Use:   "tree",
+		Short: "Show the tree of all clusters",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newOteClient()
+			if err != nil {
+				return err
+			}
+			if watch {
+				stop := make(chan struct{})
+				signals := make(chan os.Signal, 1)
+				signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+				go func() {
+					<-signals
+					close(stop)
+				}()
+				return otectl.WatchClusterTree(client, os.Stdout, stop)
+			}
+			clusters, err := otectl.ListClusters(client)
+			if err != nil {
+				return err
+			}
+			otectl.PrintClusterTree(os.Stdout, otectl.BuildClusterTree(clusters))
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&watch, "watch", "w", false, "Watch changes of clusters and update the tree")
+	return cmd
+}
+
+func newGetCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "get",
+		Short: "Display one or many resources",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:     "clusters",
+		Aliases: []string{"cluster", "cs"},
+		Short:   "List all registered clusters",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newOteClient()
+			if err != nil {
+				return err
+			}
+			clusters, err := otectl.ListClusters(client)
+			if err != nil {
+				return err
+			}
+			return otectl.PrintClusters(os.Stdout, clusters)
+		},
+	})
+	return cmd
+}
+
+func newDescribeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "describe",
+		Short: "Show details of a specific resource",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:     "cluster NAME",
+		Aliases: []string{"clusters", "cs"},
+		Short:   "Show details of a cluster",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newOteClient()
+			if err != nil {
+				return err
+			}
+			cluster, err := otectl.GetCluster(client, args[0])
+			if err != nil {
+				return err
+			}
+			clusters, err := otectl.ListClusters(client)
+			if err != nil {
+				return err
+			}
+			return otectl.DescribeCluster(os.Stdout, cluster, clusters)
+		},
+	})
+	return cmd
+}
+
+func newRunCommand() *cobra.Command {
+	task := &otectl.Task{}
+	bodyFile := ""
+	bodyURL := ""
+	bodyRef := false
+	sealKMS := ""
+	sealKeyTemplate := envelope.DefaultKeyTemplate
+	dryRun := false
+	timeout := otectl.DefaultTaskTimeout
+	cmd := &cobra.Command{
+		Use:   "run URI -s SELECTOR",
+		Short: "Send a request to clusters matched by selector",
+		Long: `Send a request to clusters matched by selector, wait for the responses
+		and print the result of each cluster. otectl exits with 1 if any cluster failed.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			task.URI = args[0]
+			task.Method = strings.ToUpper(task.Method)
+			if sealKMS != "" && (bodyRef || bodyURL != "") {
+				return fmt.Errorf("sealed body cannot be sent by reference")
+			}
+			if bodyFile != "" {
+				body, err := ioutil.ReadFile(bodyFile)
+				if err != nil {
+					return fmt.Errorf("read body file %s failed: %v", bodyFile, err)
+				}
+				task.Body = string(body)
+				if bodyRef || bodyURL != "" {
+					task.Body = string(objectref.NewRef(body, bodyURL).Marshal())
+				}
+			}
+
+			client, err := newOteClient()
+			if err != nil {
+				return err
+			}
+			clusters, err := otectl.ListClusters(client)
+			if err != nil {
+				return err
+			}
+			if sealKMS != "" {
+				km, err := envelope.NewKeyManager(sealKMS)
+				if err != nil {
+					return err
+				}
+				err = otectl.SealTask(task, otectl.SelectClusters(task.Selector, clusters, true), km, sealKeyTemplate)
+				if err != nil {
+					return err
+				}
+			}
+			if dryRun {
+				return otectl.PrintDryRun(os.Stdout, task, clusters)
+			}
+			expect := otectl.SelectClusters(task.Selector, clusters, true)
+			if len(expect) == 0 {
+				return fmt.Errorf("no online cluster is matched by selector %s", task.Selector)
+			}
+
+			results, err := otectl.RunTask(client, task, expect, timeout)
+			if err != nil {
+				return err
+			}
+			if !otectl.PrintTaskResults(os.Stdout, results, expect) {
+				os.Exit(1)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&task.Selector, "selector", "s", "", "Cluster selector, regular expressions separated by comma")
+	cmd.Flags().StringVarP(&task.Destination, "destination", "d", otev1.ClusterControllerDestAPI,
+		"Destination of the request in cluster shim")
+	cmd.Flags().StringVarP(&task.Method, "method", "X", http.MethodGet, "Method of the request")
+	cmd.Flags().StringVarP(&bodyFile, "body-file", "f", "", "File contains the body of the request")
+	cmd.Flags().StringVar(&bodyURL, "body-url", "", "Url of body file uploaded to object storage, clusters fetch the body by the url instead of receiving it")
+	cmd.Flags().BoolVar(&bodyRef, "body-ref", false, "Send the sha256 of body file only, clusters fetch the body from their object store url")
+	cmd.Flags().StringVar(&sealKMS, "seal-kms", "",
+		"Key manager to seal the body for each cluster matched, file:///DIR of key files or url of vault transit engine with token in env VAULT_TOKEN, e.g., https://vault:8200/v1/transit")
+	cmd.Flags().StringVar(&sealKeyTemplate, "seal-key-template", sealKeyTemplate,
+		"Key of each cluster in --seal-kms, "+envelope.ClusterPlaceholder+" is replaced by the cluster name")
+	cmd.Flags().StringVar(&task.IdempotencyKey, "idempotency-key", "",
+		"Key to deduplicate the request, clusters which have done a request of the same key do not do it again")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only print the request and the clusters it would be sent to")
+	cmd.Flags().DurationVar(&timeout, "timeout", timeout, "Time to wait for the responses")
+	cmd.MarkFlagRequired("selector")
+	return cmd
+}
+
+func newSelectorCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "selector SELECTOR",
+		Short: "Show the clusters matched by selector right now",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newOteClient()
+			if err != nil {
+				return err
+			}
+			clusters, err := otectl.ListClusters(client)
+			if err != nil {
+				return err
+			}
+			otectl.PrintResolution(os.Stdout, otectl.ResolveSelector(args[0], clusters))
+			return nil
+		},
+	}
+}
+
+func newDryRunCommand() *cobra.Command {
+	file := ""
+	cmd := &cobra.Command{
+		Use:   "dry-run -f FILE",
+		Short: "Show what a ClusterController crd would do before applying it",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			cc, err := otectl.LoadClusterController(f)
+			if err != nil {
+				return err
+			}
+
+			client, err := newOteClient()
+			if err != nil {
+				return err
+			}
+			clusters, err := otectl.ListClusters(client)
+			if err != nil {
+				return err
+			}
+			task, err := otectl.TaskFromClusterController(cc)
+			if err != nil {
+				return err
+			}
+			return otectl.PrintDryRun(os.Stdout, task, clusters)
+		},
+	}
+	cmd.Flags().StringVarP(&file, "filename", "f", "", "File of the ClusterController crd, in yaml or json")
+	cmd.MarkFlagRequired("filename")
+	return cmd
+}
+
+func newExecCommand() *cobra.Command {
+	pod := &otectl.PodOption{}
+	timeout := otectl.DefaultTaskTimeout
+	cmd := &cobra.Command{
+		Use:   "exec CLUSTER POD -- COMMAND [args...]",
+		Short: "Execute a command in a pod of an edge cluster",
+		Long: `Execute a command in a pod of an edge cluster through the tunnel.
+		stdin and tty are not supported, the output is shown after the command exits.`,
+		Args: cobra.MinimumNArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newOteClient()
+			if err != nil {
+				return err
+			}
+			pod.Cluster, pod.Pod = args[0], args[1]
+			code, err := otectl.Exec(client, pod, args[2:], os.Stdout, os.Stderr, timeout)
+			if err != nil {
+				return err
+			}
+			if code != 0 {
+				os.Exit(code)
+			}
+			return nil
+		},
+	}
+	addPodFlags(cmd, pod)
+	cmd.Flags().DurationVar(&timeout, "timeout", timeout, "Time to wait for the response")
+	return cmd
+}
+
+func newDecommissionCommand() *cobra.Command {
+	var migrateTo string
+	var wait bool
+	timeout := 10 * time.Minute
+	cmd := &cobra.Command{
+		Use:   "decommission CLUSTER",
+		Short: "Decommission an edge cluster before its site is shut down",
+		Long: `Decommission an edge cluster before its site is shut down.
+		ote-controller-manager requests a final full report of the cluster, writes migration hints
+		of its workloads if --migrate-to is set, then removes its mirrored resources and unregisters it.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newOteClient()
+			if err != nil {
+				return err
+			}
+			if err := otectl.Decommission(client, args[0], migrateTo, time.Now()); err != nil {
+				return err
+			}
+			if !wait {
+				return nil
+			}
+			return otectl.WaitDecommissioned(client, args[0], timeout, 2*time.Second, os.Stdout)
+		},
+	}
+	cmd.Flags().StringVar(&migrateTo, "migrate-to", "",
+		"Selector of clusters to hint the workloads of the cluster to migrate to")
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait until the cluster is unregistered")
+	cmd.Flags().DurationVar(&timeout, "timeout", timeout, "Time to wait for the cluster to be unregistered")
+	return cmd
+}
+
+func newLogsCommand() *cobra.Command {
+	pod := &otectl.PodOption{}
+	option := &otectl.LogOption{}
+	timeout := otectl.DefaultTaskTimeout
+	cmd := &cobra.Command{
+		Use:   "logs CLUSTER POD",
+		Short: "Print the logs of a pod in an edge cluster",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newOteClient()
+			if err != nil {
+				return err
+			}
+			pod.Cluster, pod.Pod = args[0], args[1]
+			return otectl.Logs(client, pod, option, os.Stdout, timeout)
+		},
+	}
+	addPodFlags(cmd, pod)
+	cmd.Flags().Int64Var(&option.TailLines, "tail", 0, "Lines of recent log to display")
+	cmd.Flags().BoolVarP(&option.Previous, "previous", "p", false,
+		"Print the logs of the previous terminated container")
+	cmd.Flags().DurationVar(&timeout, "timeout", timeout, "Time to wait for the response")
+	return cmd
+}
+
+func newDiagnoseCommand() *cobra.Command {
+	output := ""
+	timeout := otectl.DefaultTaskTimeout
+	cmd := &cobra.Command{
+		Use:   "diagnose CLUSTER",
+		Short: "Collect a support bundle from an edge cluster",
+		Long: `Collect a support bundle from an edge cluster, including logs, route table,
+		queue stats and versions of clustercontroller, and save it as a tar.gz archive.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newOteClient()
+			if err != nil {
+				return err
+			}
+			if output == "" {
+				output = otectl.DiagnoseBundleName(args[0], time.Now())
+			}
+			f, err := os.Create(output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if err := otectl.Diagnose(client, args[0], f, timeout); err != nil {
+				os.Remove(output)
+				return err
+			}
+			fmt.Printf("support bundle of %s is saved to %s\n", args[0], output)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "", "File to save the support bundle")
+	cmd.Flags().DurationVar(&timeout, "timeout", timeout, "Time to wait for the response")
+	return cmd
+}
+
+func newCaptureCommand() *cobra.Command {
+	rate := 0.1
+	duration := 10 * time.Minute
+	stop := false
+	timeout := otectl.DefaultTaskTimeout
+	cmd := &cobra.Command{
+		Use:   "capture CLUSTER",
+		Short: "Capture sampled messages of an edge cluster",
+		Long: `Capture whole heads and bodies of messages sampled at rate, between an edge cluster
+		and its parent and childs, to the message archive of the cluster for a while.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newOteClient()
+			if err != nil {
+				return err
+			}
+			if stop {
+				duration = 0
+			} else if duration <= 0 {
+				return fmt.Errorf("duration must be positive")
+			}
+			ret, err := otectl.Capture(client, args[0], rate, duration, timeout)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s: %s\n", args[0], ret)
+			return nil
+		},
+	}
+	cmd.Flags().Float64Var(&rate, "rate", rate, "Fraction of messages captured in (0, 1]")
+	cmd.Flags().DurationVar(&duration, "duration", duration, "Time to capture, at most 1h")
+	cmd.Flags().BoolVar(&stop, "stop", stop, "Stop capturing")
+	cmd.Flags().DurationVar(&timeout, "timeout", timeout, "Time to wait for the response")
+	return cmd
+}
+
+func newPrePullCommand() *cobra.Command {
+	selector := ""
+	timeout := otectl.DefaultTaskTimeout
+	cmd := &cobra.Command{
+		Use:   "prepull",
+		Short: "Pre-pull images on nodes of clusters matched by selector",
+		Long: `Pre-pull images on nodes of clusters matched by selector before a large rollout,
+		so that images are not pulled over slow links during the rollout.`,
+	}
+	cmd.PersistentFlags().StringVarP(&selector, "selector", "s", "", "Cluster selector, regular expressions separated by comma")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", timeout, "Time to wait for the responses")
+	cmd.MarkPersistentFlagRequired("selector")
+
+	spec := &handler.PrePullSpec{}
+	startCmd := &cobra.Command{
+		Use:   "start NAME --image IMAGE [--image IMAGE]",
+		Short: "Start pre-pulling images, or update images of a pre-pull",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			spec.Name = args[0]
+			task, err := otectl.PrePullTask(selector, spec)
+			if err != nil {
+				return err
+			}
+			results, expect, err := runSelectedTask(task, timeout)
+			if err != nil {
+				return err
+			}
+			otectl.PrintPrePullProgress(os.Stdout, otectl.ParsePrePullResults(results, expect), false)
+			return nil
+		},
+	}
+	startCmd.Flags().StringArrayVar(&spec.Images, "image", nil, "Image to pre-pull, can be repeated")
+	startCmd.Flags().StringToStringVar(&spec.NodeSelector, "node-selector", nil,
+		"Labels of nodes to pre-pull images, e.g., zone=a, all nodes if empty")
+	startCmd.Flags().StringVar(&spec.HelperImage, "helper-image", handler.DefaultPrePullHelperImage,
+		"Image containing a static busybox at /bin/busybox")
+	startCmd.MarkFlagRequired("image")
+
+	wait := false
+	interval := 10 * time.Second
+	statusCmd := &cobra.Command{
+		Use:   "status NAME",
+		Short: "Show the progress of a pre-pull on each cluster",
+		Long: `Show the progress of a pre-pull on each cluster and the images failed to pull on nodes.
+		otectl exits with 1 if images are not pulled on all nodes.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for {
+				results, expect, err := runSelectedTask(otectl.PrePullStatusTask(selector, args[0]), timeout)
+				if err != nil {
+					return err
+				}
+				if otectl.PrintPrePullProgress(os.Stdout, otectl.ParsePrePullResults(results, expect), true) {
+					return nil
+				}
+				if !wait {
+					os.Exit(1)
+				}
+				time.Sleep(interval)
+				fmt.Println()
+			}
+		},
+	}
+	statusCmd.Flags().BoolVarP(&wait, "wait", "w", false, "Wait until images are pulled on all nodes")
+	statusCmd.Flags().DurationVar(&interval, "interval", interval, "Interval to get the progress when waiting")
+
+	deleteCmd := &cobra.Command{
+		Use:   "delete NAME",
+		Short: "Stop a pre-pull and delete its daemonsets, images pulled are kept",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			results, expect, err := runSelectedTask(otectl.PrePullDeleteTask(selector, args[0]), timeout)
+			if err != nil {
+				return err
+			}
+			if !otectl.PrintTaskResults(os.Stdout, results, expect) {
+				os.Exit(1)
+			}
+			return nil
+		},
+	}
+
+	cmd.AddCommand(startCmd, statusCmd, deleteCmd)
+	return cmd
+}
+
+func newJobCommand() *cobra.Command {
+	selector := ""
+	timeout := otectl.DefaultTaskTimeout
+	cmd := &cobra.Command{
+		Use:   "job",
+		Short: "Run jobs on clusters matched by selector and collect their results",
+		Long: `Run jobs on clusters matched by selector and collect their results.
+		The result of a pod is the termination message of its containers, which is written to
+		/dev/termination-log by default, and is truncated to 4KB.`,
+	}
+	cmd.PersistentFlags().StringVarP(&selector, "selector", "s", "", "Cluster selector, regular expressions separated by comma")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", timeout, "Time to wait for the responses")
+	cmd.MarkPersistentFlagRequired("selector")
+
+	wait := false
+	interval := 10 * time.Second
+	detail := false
+	outputDir := ""
+	// showJob shows the status of job on clusters until it finishes on all clusters if wait is set.
+	// otectl exits with 1 if the job does not succeed on all clusters.
+	showJob := func(namespace, name string) error {
+		for {
+			results, expect, err := runSelectedTask(otectl.JobStatusTask(selector, namespace, name), timeout)
+			if err != nil {
+				return err
+			}
+			jobs := otectl.ParseJobResults(results, expect)
+			finished, succeeded := otectl.PrintJobResults(os.Stdout, jobs, detail)
+			if wait && finished < len(jobs) {
+				time.Sleep(interval)
+				fmt.Println()
+				continue
+			}
+			if outputDir != "" {
+				if err := otectl.SaveJobResults(outputDir, jobs); err != nil {
+					return err
+				}
+			}
+			if succeeded < len(jobs) {
+				os.Exit(1)
+			}
+			return nil
+		}
+	}
+	addShowFlags := func(c *cobra.Command) {
+		c.Flags().BoolVarP(&wait, "wait", "w", false, "Wait until the job finishes on all clusters")
+		c.Flags().DurationVar(&interval, "interval", interval, "Interval to get the status when waiting")
+		c.Flags().BoolVar(&detail, "results", false, "Show the results of pods finished")
+		c.Flags().StringVarP(&outputDir, "output-dir", "o", "", "Save the result of each pod to <dir>/<cluster>/<pod>")
+	}
+
+	file := ""
+	runCmd := &cobra.Command{
+		Use:   "run -f FILE",
+		Short: "Create a job on clusters, the job is kept if it exists",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			job, err := otectl.LoadJob(f)
+			if err != nil {
+				return err
+			}
+			task, err := otectl.JobTask(selector, job)
+			if err != nil {
+				return err
+			}
+			results, expect, err := runSelectedTask(task, timeout)
+			if err != nil {
+				return err
+			}
+			if !wait {
+				otectl.PrintJobResults(os.Stdout, otectl.ParseJobResults(results, expect), detail)
+				return nil
+			}
+			return showJob(job.Namespace, job.Name)
+		},
+	}
+	runCmd.Flags().StringVarP(&file, "filename", "f", "", "File of the Job, in yaml or json")
+	runCmd.MarkFlagRequired("filename")
+	addShowFlags(runCmd)
+
+	namespace := ""
+	statusCmd := &cobra.Command{
+		Use:   "status NAME",
+		Short: "Show the status and results of a job on each cluster",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return showJob(namespace, args[0])
+		},
+	}
+	statusCmd.Flags().StringVarP(&namespace, "namespace", "n", "default", "Namespace of the job")
+	addShowFlags(statusCmd)
+
+	deleteCmd := &cobra.Command{
+		Use:   "delete NAME",
+		Short: "Delete a job with its pods on clusters",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			results, expect, err := runSelectedTask(otectl.JobDeleteTask(selector, namespace, args[0]), timeout)
+			if err != nil {
+				return err
+			}
+			if !otectl.PrintTaskResults(os.Stdout, results, expect) {
+				os.Exit(1)
+			}
+			return nil
+		},
+	}
+	deleteCmd.Flags().StringVarP(&namespace, "namespace", "n", "default", "Namespace of the job")
+
+	cmd.AddCommand(runCmd, statusCmd, deleteCmd)
+	return cmd
+}
+
+func newJoinManifestCommand() *cobra.Command {
+	option := &otectl.JoinOption{}
+	cmd := &cobra.Command{
+		Use:   "join-manifest --name CLUSTER --token TOKEN",
+		Short: "Print manifests to deploy clustercontroller in a new edge cluster",
+		Long: `Print manifests to deploy clustercontroller in a new edge cluster,
+		which can be applied by kubectl apply -f in the edge cluster.
+		The parent is the cloud tunnel of root by default.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if option.Parent == "" {
+				restConfig, err := k8sclient.GetRestConfig(kubeConfig)
+				if err != nil {
+					return err
+				}
+				if option.Parent, err = otectl.DefaultParent(restConfig.Host); err != nil {
+					return err
+				}
+			}
+			client, err := newOteClient()
+			if err != nil {
+				return err
+			}
+			clusters, err := otectl.ListClusters(client)
+			if err != nil {
+				return err
+			}
+			if err := otectl.CheckJoinOption(option, clusters); err != nil {
+				return err
+			}
+			return otectl.JoinManifest(os.Stdout, option)
+		},
+	}
+	cmd.Flags().StringVar(&option.ClusterName, "name", "", "Name of the new edge cluster, must be unique")
+	cmd.Flags().StringVar(&option.Parent, "parent", "", "Cloud tunnel of parent cluster, e.g., 192.168.0.2:8287")
+	cmd.Flags().StringVar(&option.Token, "token", "", "Token of the new edge cluster, saved in a secret")
+	cmd.Flags().StringVar(&option.Image, "image", otectl.DefaultClusterControllerImage, "Image of clustercontroller")
+	cmd.Flags().StringVar(&option.TunnelListen, "tunnel-listen", ":"+otectl.DefaultTunnelPort,
+		"Cloud tunnel listen address of the new edge cluster")
+	cmd.Flags().StringVar(&option.RemoteShimAddr, "remote-shim-endpoint", "", "Remote cluster shim address")
+	cmd.Flags().StringVar(&option.HelmTillerAddr, "helm-tiller-addr", "", "Helm tiller http proxy addr")
+	cmd.MarkFlagRequired("name")
+	cmd.MarkFlagRequired("token")
+	return cmd
+}
+
+func addPodFlags(cmd *cobra.Command, pod *otectl.PodOption) {
+	cmd.Flags().StringVarP(&pod.Namespace, "namespace", "n", "default", "Namespace of the pod")
+	cmd.Flags().StringVarP(&pod.Container, "container", "c", "", "Container name of the pod")
+}
+
+// newKubectlCommand runs kubectl with the resources reported by a certain cluster.
+func newKubectlCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:                "kubectl CLUSTER [kubectl args...]",
+		Short:              "Run kubectl with resources of a cluster",
+		Args:               cobra.MinimumNArgs(1),
+		DisableFlagParsing: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kubectlArgs := append([]string{"-l", reporter.ClusterLabel + "=" + args[0]}, args[1:]...)
+			c := exec.Command("kubectl", kubectlArgs...)
+			c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+			return c.Run()
+		},
+	}
+}
+
+func newOteClient() (oteclient.Interface, error) {
+	client, err := k8sclient.NewClient(kubeConfig)
+	if err != nil {
+		klog.Errorf("connect to root cluster failed: %v", err)
+		return nil, err
+	}
+	return client, nil
+}
+
+// runSelectedTask runs the task on online clusters matched by its selector.
+// It returns the responses and the clusters expected to respond.
+func runSelectedTask(task *otectl.Task, timeout time.Duration) (map[string]otev1.ClusterControllerStatus, []string, error) {
+	client, err := newOteClient()
+	if err != nil {
+		return nil, nil, err
+	}
+	clusters, err := otectl.ListClusters(client)
+	if err != nil {
+		return nil, nil, err
+	}
+	expect := otectl.SelectClusters(task.Selector, clusters, true)
+	if len(expect) == 0 {
+		return nil, nil, fmt.Errorf("no online cluster is matched by selector %s", task.Selector)
+	}
+	results, err := otectl.RunTask(client, task, expect, timeout)
+	return results, expect, err
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package admin implements the admin http server of ote components,
+// which serves endpoints for debugging and operating the running process.
+package admin
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"k8s.io/klog"
+)
+
+const (
+	readTimeout  = 15 * time.Second
+	writeTimeout = 15 * time.Second
+	stopTimeout  = 15 * time.Second
+)
+
+// Server is an admin http server.
+type Server struct {
+	address string
+	router  *mux.Router
+	server  *http.Server
+}
+
+// NewServer returns an admin server listening on address.
+func NewServer(address string) *Server {
+	return &Server{
+		address: address,
+		router:  mux.NewRouter(),
+	}
+}
+
+// HandleFunc registers the handler for path.
+func (s *Server) HandleFunc(path string, fn http.HandlerFunc) {
+	s.router.HandleFunc(path, fn)
+}
+
+// Addr returns the address listened, which is available after Start.
+func (s *Server) Addr() string {
+	if s.server == nil {
+		return s.address
+	}
+	return s.server.Addr
+}
+
+// Start listens and serves in background.
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.address)
+	if err != nil {
+		return err
+	}
+	s.server = &http.Server{
+		Addr:         ln.Addr().String(),
+		Handler:      s.router,
+		ReadTimeout:  readTimeout,
+		WriteTimeout: writeTimeout,
+	}
+	klog.Infof("start admin server on %s", s.server.Addr)
+
+	go func() {
+		if err := s.server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			klog.Errorf("admin server stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Stop gracefully stops the server.
+func (s *Server) Stop() error {
+	if s.server == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
@@ -47,7 +47,17 @@ var (
 func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
 		&Cluster{},
+		&ClusterList{},
 		&ClusterController{},
+		&ClusterControllerList{},
+		&ClusterInventory{},
+		&ClusterInventoryList{},
+		&ClusterQuota{},
+		&ClusterQuotaList{},
+		&CronClusterTask{},
+		&CronClusterTaskList{},
+		&ResourceTransform{},
+		&ResourceTransformList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
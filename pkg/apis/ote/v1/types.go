@@ -40,11 +40,30 @@ const (
 	ClusterControllerDestUnregistCluster = "unregist" // cluster unregist
 	ClusterControllerDestClusterRoute    = "route"    // cluster route
 	ClusterControllerDestClusterSubtree  = "subtree"  // cluster subtree
+	ClusterControllerDestExec            = "exec"     // exec command in pod
+	ClusterControllerDestLog             = "log"      // get logs of pod
+	ClusterControllerDestDiagnose        = "diagnose" // collect support bundle of clustercontroller
+	ClusterControllerDestCapture         = "capture"  // capture sampled messages of clustercontroller
+	ClusterControllerDestPrePull         = "prepull"  // pre-pull images on nodes
+	ClusterControllerDestJob             = "job"      // run jobs and collect results
+	ClusterControllerDestProxy           = "proxy"    // k8s api requests proxied from cloud
+	ClusterControllerDestApply           = "apply"    // create or update an object
+	ClusterControllerDestResync          = "resync"   // state epoch announced by center
+	ClusterControllerDestPrune           = "prune"    // garbage collect objects distributed by cloud
+	ClusterControllerDestQuota           = "quota"    // quota of the cluster enforced by the shim
 
 	ClusterStatusOnline  = "online"
 	ClusterStatusOffline = "offline"
 )
 
+// ClusterProxyMessagePrefix starts ids of messages of k8s api requests proxied from cloud,
+// whose responses are not merged to ClusterController crds.
+const ClusterProxyMessagePrefix = "proxy-"
+
+// ClusterScaffoldMessagePrefix starts ids of messages of scaffolds ensured on clusters,
+// whose responses are not merged to ClusterController crds.
+const ClusterScaffoldMessagePrefix = "scaffold-"
+
 // ClusterNamespace defines the namespace of k8s crd must be in.
 // CRD out of the namespace won't be watched.
 const (
@@ -56,7 +75,7 @@ const (
 
 // ClusterController is the k8s crd to manipulate all clusters in ote.
 type ClusterController struct {
-	metav1.TypeMeta   `json:", inline"`
+	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
 	Spec   ClusterControllerSpec              `json:"spec"`
@@ -73,6 +92,66 @@ type ClusterControllerSpec struct {
 	URL             string `json:"url"`
 
 	Body string `json:"body"`
+
+	// Task is a typed task, which sets Destination, Method, URL and Body when dispatched.
+	Task *ClusterControllerTask `json:"task,omitempty"`
+
+	// IdempotencyKey deduplicates tasks, the task is not executed again on clusters
+	// which have done a task of the same key, e.g., the same crd applied again.
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+
+	// WaveSize dispatches the task to clusters selected in waves of the size by order of names,
+	// the next wave after all clusters of the previous one responded. All clusters at once if 0.
+	WaveSize int `json:"waveSize,omitempty"`
+	// FailurePolicy is what to do when more than MaxFailures clusters failed after a wave,
+	// ClusterControllerFailureContinue if empty.
+	FailurePolicy string `json:"failurePolicy,omitempty"`
+	// MaxFailures is the number of clusters failed tolerated by FailurePolicy.
+	MaxFailures int `json:"maxFailures,omitempty"`
+	// Rollback is the typed task sent to clusters succeeded, required by ClusterControllerFailureRollback.
+	Rollback *ClusterControllerTask `json:"rollback,omitempty"`
+	// MinHealthScore skips clusters selected whose health score is below it, none if 0.
+	MinHealthScore int `json:"minHealthScore,omitempty"`
+}
+
+// ClusterControllerFailure* are failure policies of ClusterControllers,
+// should be set to ClusterController.Spec.FailurePolicy.
+const (
+	// continue with remaining waves, and report clusters failed in status
+	ClusterControllerFailureContinue = "Continue"
+	// stop remaining waves, clusters not dispatched are Skipped
+	ClusterControllerFailureFailFast = "FailFast"
+	// stop remaining waves, and send the rollback task to clusters succeeded
+	ClusterControllerFailureRollback = "Rollback"
+)
+
+// ClusterControllerRollbackLabel labels the ClusterController created by root to roll back
+// the ClusterController of its value.
+const ClusterControllerRollbackLabel = "ote-rollback-of"
+
+// ClusterControllerTask* are types of typed tasks,
+// should be set to ClusterController.Spec.Task.Type.
+const (
+	ClusterControllerTaskApply  = "apply"  // create or update an object by its manifest
+	ClusterControllerTaskDelete = "delete" // delete an object
+	ClusterControllerTaskJob    = "job"    // run a job and collect its results
+)
+
+// ClusterControllerTask is a typed task of a ClusterController.
+type ClusterControllerTask struct {
+	Type string `json:"type"`
+	// Manifest is the object to apply, or the Job to run, in json or yaml.
+	Manifest string `json:"manifest,omitempty"`
+	// Object is the object to delete.
+	Object *ObjectReference `json:"object,omitempty"`
+}
+
+// ObjectReference refers to an object in clusters.
+type ObjectReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
 }
 
 // ClusterControllerStatus is status of a ClusterController.
@@ -80,13 +159,46 @@ type ClusterControllerStatus struct {
 	Timestamp  int64  `json:"timestamp"`
 	StatusCode int    `json:"code"`
 	Body       string `json:"body"`
+	// Reason is set if the status is not responded by the cluster, e.g., TimedOut.
+	Reason string `json:"reason,omitempty"`
+}
+
+// ClusterControllerStatusTimedOut is the reason of status of clusters not responded
+// in task timeout, which is replaced if the cluster responds later.
+const ClusterControllerStatusTimedOut = "TimedOut"
+
+// ClusterControllerStatusDeduplicated is the reason of status copied from a ClusterController
+// of the same idempotency key, the task is not sent to the cluster again.
+const ClusterControllerStatusDeduplicated = "Deduplicated"
+
+// ClusterControllerStatusSkipped is the reason of status of clusters in waves not dispatched,
+// since the task is stopped by its failure policy.
+const ClusterControllerStatusSkipped = "Skipped"
+
+// ClusterControllerStatusOverrideFailed is the reason of status of clusters whose task
+// is failed to override, the task is not sent to the cluster.
+const ClusterControllerStatusOverrideFailed = "OverrideFailed"
+
+// ClusterControllerStatusQuotaExceeded is the reason of status of clusters whose task
+// exceeds a ClusterQuota of the cluster, the task is not sent to the cluster.
+const ClusterControllerStatusQuotaExceeded = "QuotaExceeded"
+
+// SentToCluster returns false for reasons of status of tasks not sent to the cluster,
+// including those deduplicated, whose status is copied from another ClusterController.
+func SentToCluster(reason string) bool {
+	switch reason {
+	case ClusterControllerStatusSkipped, ClusterControllerStatusOverrideFailed,
+		ClusterControllerStatusQuotaExceeded, ClusterControllerStatusDeduplicated:
+		return false
+	}
+	return true
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // ClusterControllerList is a list of ClusterController.
 type ClusterControllerList struct {
-	metav1.TypeMeta `json:", inline"`
+	metav1.TypeMeta `json:",inline"`
 	metav1.ListMeta `json:"metadata"`
 	Items           []ClusterController `json:"items,omitempty"`
 }
@@ -96,7 +208,7 @@ type ClusterControllerList struct {
 
 // Cluster is the k8s crd to store cluster info.
 type Cluster struct {
-	metav1.TypeMeta   `json:", inline"`
+	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
 	Spec   ClusterSpec   `json:"spec"`
@@ -114,9 +226,168 @@ type ClusterStatus struct {
 	ParentName string `json:"parentName,omitempty"`
 	Status     string `json:"status,omitempty"`
 	Timestamp  int64  `json:"timestamp"`
+	// Version is the clustercontroller version of the cluster.
+	Version string `json:"version,omitempty"`
+	// Protocol is the protocol version negotiated with its parent.
+	Protocol int `json:"protocol,omitempty"`
+	// ClockSkew is the milliseconds the clock of the cluster is ahead of root.
+	ClockSkew  int64              `json:"clockSkew,omitempty"`
+	Conditions []ClusterCondition `json:"conditions,omitempty"`
+	// Capabilities are set on regist, nil for clusters of versions not reporting them.
+	Capabilities *ClusterCapabilities `json:"capabilities,omitempty"`
+	// HLC is the hybrid logical clock of the status reported, which orders statuses of the cluster
+	// rather than Timestamp. nil for clusters of versions not reporting it.
+	HLC *HybridTime `json:"hlc,omitempty"`
+	// Certificates are expiries of certificates of the apiserver and the credentials of the cluster shim,
+	// nil if not checked.
+	Certificates []CertificateExpiry `json:"certificates,omitempty"`
+	// Health is computed by ote controller manager, nil if not computed yet.
+	Health *ClusterHealth `json:"health,omitempty"`
+	// Drift is computed by ote controller manager, nil if not computed yet.
+	Drift *ClusterDrift `json:"drift,omitempty"`
 	ClusterResource
 }
 
+// Factors of health scores of clusters.
+const (
+	// ClusterHealthHeartbeat is the freshness of the last status of the cluster.
+	ClusterHealthHeartbeat = "heartbeat"
+	// ClusterHealthReportLag is the delay of the last status of the cluster reaching center.
+	ClusterHealthReportLag = "reportLag"
+	// ClusterHealthCommandSuccess is the rate of tasks succeeded by the cluster recently.
+	ClusterHealthCommandSuccess = "commandSuccess"
+	// ClusterHealthNodeReadiness is the rate of nodes of the cluster ready.
+	ClusterHealthNodeReadiness = "nodeReadiness"
+)
+
+// ClusterHealth is the composite health score of a cluster.
+type ClusterHealth struct {
+	// Score is the weighted average of scores of factors known, from 0 to 100 of the healthiest.
+	Score int `json:"score"`
+	// Factors are scores of factors from 0 to 100 by ClusterHealth*, factors unknown are omitted,
+	// e.g., commandSuccess of a cluster without tasks recently.
+	Factors map[string]int `json:"factors,omitempty"`
+	// Timestamp is the unix time the score last changed.
+	Timestamp int64 `json:"timestamp"`
+}
+
+// ClusterDrift is the drift of objects of a cluster from those declared by typed tasks
+// of ClusterControllers, compared with objects mirrored from the cluster to center.
+type ClusterDrift struct {
+	// Missing are objects applied which are not mirrored.
+	Missing []DriftedObject `json:"missing,omitempty"`
+	// Extra are objects mirrored which are deleted by tasks, or labeled applied by tasks
+	// but not declared any more, e.g., their ClusterControllers are removed.
+	Extra []DriftedObject `json:"extra,omitempty"`
+	// Modified are objects mirrored whose fields differ from the manifests applied.
+	Modified []DriftedObject `json:"modified,omitempty"`
+	// Timestamp is the unix time the drift last changed.
+	Timestamp int64 `json:"timestamp"`
+}
+
+// DriftedObject is an object drifted in a cluster.
+type DriftedObject struct {
+	ObjectReference `json:",inline"`
+	// ClusterController is the one of the latest task of the object, empty if none.
+	ClusterController string `json:"clusterController,omitempty"`
+	// Fields are the paths of fields modified, e.g., spec.replicas.
+	Fields []string `json:"fields,omitempty"`
+}
+
+// CertificateExpiry is the expiry of a certificate of a cluster.
+type CertificateExpiry struct {
+	// Name is what the certificate is of, e.g., apiserver, kubeconfig-client or kubeconfig-ca.
+	Name    string `json:"name"`
+	Subject string `json:"subject,omitempty"`
+	// NotAfter is the unix time the certificate expires.
+	NotAfter int64 `json:"notAfter"`
+}
+
+// HybridTime is a timestamp of hybrid logical clocks, which follows the wall clock
+// but never goes backwards, and is ahead of all timestamps received by the cluster,
+// so that events are ordered regardless of skew of clocks of clusters.
+type HybridTime struct {
+	// WallTime is the unix time in milliseconds.
+	WallTime int64 `json:"wallTime"`
+	// Logical orders events of the same WallTime.
+	Logical int32 `json:"logical"`
+}
+
+// Before returns true if t is before u.
+func (t HybridTime) Before(u HybridTime) bool {
+	return t.WallTime < u.WallTime || (t.WallTime == u.WallTime && t.Logical < u.Logical)
+}
+
+// String returns t as "WallTime.Logical", it is parsed by ParseHybridTime.
+func (t HybridTime) String() string {
+	return fmt.Sprintf("%d.%d", t.WallTime, t.Logical)
+}
+
+// ParseHybridTime parses s returned by HybridTime.String.
+func ParseHybridTime(s string) (HybridTime, error) {
+	var t HybridTime
+	if n, err := fmt.Sscanf(s, "%d.%d", &t.WallTime, &t.Logical); err != nil || n != 2 {
+		return HybridTime{}, fmt.Errorf("hybrid time %s is invalid", s)
+	}
+	return t, nil
+}
+
+// ClusterCapabilities are what the cluster controller of a cluster supports.
+type ClusterCapabilities struct {
+	// Commands are the commands handled from the parent.
+	Commands []string `json:"commands,omitempty"`
+	// Destinations are the destinations of the cluster shim.
+	Destinations []string `json:"destinations,omitempty"`
+	// Protocol and MinProtocol are the range of protocol versions supported.
+	Protocol    int `json:"protocol,omitempty"`
+	MinProtocol int `json:"minProtocol,omitempty"`
+	// ReportEncodings are the report encodings accepted.
+	ReportEncodings []string `json:"reportEncodings,omitempty"`
+	// FeatureGates are the feature gates and whether they are enabled.
+	FeatureGates map[string]bool `json:"featureGates,omitempty"`
+}
+
+// Annotations of Cluster crds to decommission clusters.
+const (
+	// ClusterDecommissionAnnotation requests to decommission the cluster, its value is the time requested in RFC3339.
+	ClusterDecommissionAnnotation = "ote.baidu.com/decommission"
+	// ClusterMigrateToAnnotation is the cluster selector of clusters suggested to take over workloads.
+	ClusterMigrateToAnnotation = "ote.baidu.com/migrate-to"
+	// ClusterDecommissionPhaseAnnotation is the phase of decommission set by ote controller manager.
+	ClusterDecommissionPhaseAnnotation = "ote.baidu.com/decommission-phase"
+	// ClusterDecommissionMessageAnnotation is the message of decommission phase, e.g., why it is blocked.
+	ClusterDecommissionMessageAnnotation = "ote.baidu.com/decommission-message"
+)
+
+// Annotations of Cluster crds to override tasks dispatched to clusters by root.
+const (
+	// ClusterImageRegistryAnnotation is the registry images of containers in tasks are rewritten to.
+	ClusterImageRegistryAnnotation = "ote.baidu.com/image-registry"
+	// ClusterResourceScaleAnnotation is the factor cpu and memory requests and limits of containers
+	// in tasks are scaled by, e.g., 0.5.
+	ClusterResourceScaleAnnotation = "ote.baidu.com/resource-scale"
+)
+
+const (
+	// ClusterConditionClockSkewed is true if the clock of a cluster is skewed from root over threshold.
+	ClusterConditionClockSkewed = "ClockSkewed"
+	// ClusterConditionConnectivity is true if local dns, apiserver and parent are reachable from a cluster.
+	ClusterConditionConnectivity = "Connectivity"
+	// ClusterConditionCertificateExpiring is true if a certificate of the apiserver or of the credentials
+	// of the cluster shim expires soon, or has expired.
+	ClusterConditionCertificateExpiring = "CertificateExpiring"
+)
+
+// ClusterCondition is a condition of a Cluster.
+type ClusterCondition struct {
+	Type   string                 `json:"type"`
+	Status corev1.ConditionStatus `json:"status"`
+	// LastTransitionTime is the unix time the condition changed status.
+	LastTransitionTime int64  `json:"lastTransitionTime,omitempty"`
+	Reason             string `json:"reason,omitempty"`
+	Message            string `json:"message,omitempty"`
+}
+
 // ClusterResource represents the resources of a cluster.
 type ClusterResource struct {
 	// Capacity represents the total resources of a cluster.
@@ -129,11 +400,196 @@ type ClusterResource struct {
 
 // ClusterList is a list of Cluster.
 type ClusterList struct {
-	metav1.TypeMeta `json:", inline"`
+	metav1.TypeMeta `json:",inline"`
 	metav1.ListMeta `json:"metadata,omitempty"`
 	Items           []Cluster `json:"items,omitempty"`
 }
 
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterInventory is the k8s crd of capacity and inventory of a cluster,
+// aggregated from nodes reported by the cluster. It is named by the cluster.
+type ClusterInventory struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status ClusterInventoryStatus `json:"status"`
+}
+
+// ClusterInventoryStatus is status of a ClusterInventory.
+type ClusterInventoryStatus struct {
+	// Nodes is the number of nodes of the cluster.
+	Nodes int `json:"nodes"`
+	// ReadyNodes is the number of nodes ready.
+	ReadyNodes int `json:"readyNodes"`
+	// GPUs is the number of gpus in capacity of nodes by resource name, e.g., nvidia.com/gpu.
+	GPUs map[corev1.ResourceName]int64 `json:"gpus,omitempty"`
+	// KubeletVersions is the number of nodes by kubelet version.
+	KubeletVersions map[string]int `json:"kubeletVersions,omitempty"`
+	// ContainerRuntimeVersions is the number of nodes by container runtime version.
+	ContainerRuntimeVersions map[string]int `json:"containerRuntimeVersions,omitempty"`
+	// KernelVersions is the number of nodes by kernel version.
+	KernelVersions map[string]int `json:"kernelVersions,omitempty"`
+	// Problems are problems of nodes, ordered by node and type.
+	Problems []NodeProblem `json:"problems,omitempty"`
+	// Timestamp is the unix time the inventory is recomputed.
+	Timestamp int64 `json:"timestamp"`
+	ClusterResource
+}
+
+// NodeProblem is a problem of a node, which is a condition true other than Ready,
+// set by kubelet or node-problem-detector, e.g., DiskPressure or KernelDeadlock.
+type NodeProblem struct {
+	// Node is the name of the node in its cluster.
+	Node    string                   `json:"node"`
+	Type    corev1.NodeConditionType `json:"type"`
+	Reason  string                   `json:"reason,omitempty"`
+	Message string                   `json:"message,omitempty"`
+	// Since is the time the condition turned true.
+	Since metav1.Time `json:"since,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterInventoryList is a list of ClusterInventory.
+type ClusterInventoryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterInventory `json:"items,omitempty"`
+}
+
+// CronClusterTask* are concurrency policies of CronClusterTask,
+// should be set to CronClusterTask.Spec.ConcurrencyPolicy.
+const (
+	CronClusterTaskAllowConcurrent   = "Allow"   // runs may be active at the same time
+	CronClusterTaskForbidConcurrent  = "Forbid"  // a run is skipped if the previous one is active
+	CronClusterTaskReplaceConcurrent = "Replace" // the active run is deleted for the new one
+)
+
+// CronClusterTaskLabel is the label of ClusterControllers created by a CronClusterTask, valued by its name.
+const CronClusterTaskLabel = "ote-cron-task"
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CronClusterTask is the k8s crd to create ClusterControllers from a template on a schedule.
+type CronClusterTask struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CronClusterTaskSpec   `json:"spec"`
+	Status CronClusterTaskStatus `json:"status"`
+}
+
+// CronClusterTaskSpec is specification of a CronClusterTask.
+type CronClusterTaskSpec struct {
+	// Schedule is in cron format of minute, hour, day of month, month and day of week,
+	// or one of @yearly, @monthly, @weekly, @daily and @hourly.
+	Schedule string `json:"schedule"`
+	// ConcurrencyPolicy is Allow, Forbid or Replace, default Allow.
+	ConcurrencyPolicy string `json:"concurrencyPolicy,omitempty"`
+	// StartingDeadlineSeconds is the time a run missed can be started after its schedule, 0 means no deadline.
+	StartingDeadlineSeconds int64 `json:"startingDeadlineSeconds,omitempty"`
+	// Suspend stops creating runs, active runs are not affected.
+	Suspend bool `json:"suspend,omitempty"`
+	// SuccessfulRunsHistoryLimit is the number of runs succeeded to keep, default 3.
+	SuccessfulRunsHistoryLimit *int32 `json:"successfulRunsHistoryLimit,omitempty"`
+	// FailedRunsHistoryLimit is the number of runs failed to keep, default 1.
+	FailedRunsHistoryLimit *int32 `json:"failedRunsHistoryLimit,omitempty"`
+	// Template is the spec of ClusterControllers created for runs.
+	Template ClusterControllerSpec `json:"template"`
+}
+
+// CronClusterTaskStatus is status of a CronClusterTask.
+type CronClusterTaskStatus struct {
+	// LastScheduleTime is the unix time of schedule of the latest run.
+	LastScheduleTime int64 `json:"lastScheduleTime,omitempty"`
+	// Active are the runs not done.
+	Active []CronClusterTaskRun `json:"active,omitempty"`
+	// History are the runs done, latest first.
+	History []CronClusterTaskRun `json:"history,omitempty"`
+}
+
+// CronClusterTaskRun is a run of a CronClusterTask.
+type CronClusterTaskRun struct {
+	// Name is the name of the ClusterController of the run.
+	Name string `json:"name"`
+	// ScheduleTime is the unix time the run is scheduled at.
+	ScheduleTime int64 `json:"scheduleTime"`
+	// Clusters are the online clusters selected when the run is created,
+	// the run is done when all of them responded.
+	Clusters []string `json:"clusters,omitempty"`
+	// CompletionTime is the unix time the run is done.
+	CompletionTime int64 `json:"completionTime,omitempty"`
+	// Succeeded and Failed are the number of clusters responded in 2xx and others.
+	Succeeded int `json:"succeeded,omitempty"`
+	Failed    int `json:"failed,omitempty"`
+	// Reason is set if the run is not done by clusters, e.g., Replaced.
+	Reason string `json:"reason,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CronClusterTaskList is a list of CronClusterTask.
+type CronClusterTaskList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CronClusterTask `json:"items,omitempty"`
+}
+
+// ResourceTransform* are variables in values set by ResourceTransform, replaced by
+// the cluster reporting the resource, and the value of the field before set.
+const (
+	ResourceTransformClusterVar = "${cluster}"
+	ResourceTransformValueVar   = "${value}"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ResourceTransform is the k8s crd to transform resources of a kind reported by edge clusters
+// before they are written to the center, e.g., strips fields, rewrites nodeName or adds labels.
+type ResourceTransform struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ResourceTransformSpec   `json:"spec"`
+	Status ResourceTransformStatus `json:"status"`
+}
+
+// ResourceTransformSpec is specification of a ResourceTransform.
+// Fields are stripped, then set, then labels and annotations are added.
+type ResourceTransformSpec struct {
+	// APIVersion and Kind are the kind of resources transformed, e.g., v1 and Pod.
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	// ClusterSelector selects clusters whose resources are transformed, all if empty.
+	ClusterSelector string `json:"clusterSelector,omitempty"`
+	// Strip are the dotted paths of fields removed, e.g., spec.hostname.
+	Strip []string `json:"strip,omitempty"`
+	// Set are the values of fields by dotted paths, e.g., spec.nodeName: ${cluster}-${value}.
+	Set map[string]string `json:"set,omitempty"`
+	// Labels and Annotations are added to resources, their values may refer to ${cluster}.
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ResourceTransformStatus is status of a ResourceTransform.
+type ResourceTransformStatus struct {
+	// Error is the reason the transform is invalid and not applied, empty if it is applied.
+	Error string `json:"error,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ResourceTransformList is a list of ResourceTransform.
+type ResourceTransformList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ResourceTransform `json:"items,omitempty"`
+}
+
 // Serialize serialize ClusterController using json.
 func (cc *ClusterController) Serialize() ([]byte, error) {
 	b, err := json.Marshal(cc)
@@ -191,6 +647,30 @@ func ClusterStatusDeserialize(b []byte) (*ClusterStatus, error) {
 	return &c, nil
 }
 
+// GetCondition returns the condition of type t, nil if not found.
+func (c *ClusterStatus) GetCondition(t string) *ClusterCondition {
+	for i := range c.Conditions {
+		if c.Conditions[i].Type == t {
+			return &c.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetCondition adds or updates the condition of the same type,
+// LastTransitionTime is kept if status of the condition is not changed.
+func (c *ClusterStatus) SetCondition(cond ClusterCondition) {
+	old := c.GetCondition(cond.Type)
+	if old == nil {
+		c.Conditions = append(c.Conditions, cond)
+		return
+	}
+	if old.Status == cond.Status {
+		cond.LastTransitionTime = old.LastTransitionTime
+	}
+	*old = cond
+}
+
 // WrapperToClusterController wrapper a Cluster to a ClusterController using json.
 func (c *Cluster) WrapperToClusterController(dst string) (*ClusterController, error) {
 	cbyte, err := c.Serialize()
@@ -205,3 +685,41 @@ func (c *Cluster) WrapperToClusterController(dst string) (*ClusterController, er
 	}
 	return &cc, nil
 }
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterQuota is the k8s crd to limit what the cloud may deploy to each of the clusters selected,
+// e.g., pods, cpu and memory, which protects constrained edge sites from over-provisioning.
+type ClusterQuota struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterQuotaSpec   `json:"spec"`
+	Status ClusterQuotaStatus `json:"status"`
+}
+
+// ClusterQuotaSpec is specification of a ClusterQuota.
+type ClusterQuotaSpec struct {
+	// ClusterSelector selects clusters limited, all if empty. Each cluster is limited by Hard on its own.
+	ClusterSelector string `json:"clusterSelector,omitempty"`
+	// Hard is the max of resources requested by pods of each cluster, i.e., pods, cpu and memory.
+	Hard corev1.ResourceList `json:"hard"`
+}
+
+// ClusterQuotaStatus is status of a ClusterQuota.
+type ClusterQuotaStatus struct {
+	// Used are the resources requested by pods of clusters selected, by cluster.
+	Used map[string]corev1.ResourceList `json:"used,omitempty"`
+	// Error is the reason the quota is invalid and not enforced, empty if it is enforced.
+	Error string `json:"error,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterQuotaList is a list of ClusterQuota.
+type ClusterQuotaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterQuota `json:"items,omitempty"`
+}
@@ -0,0 +1,527 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package archive keeps heads and truncated bodies of messages sent to and received from
+// the parent and children of clustercontroller in a local directory, and queries them.
+/*
+Messages are archived by peer in files of an hour, e.g., <dir>/c1/2019103123.jsonl,
+with an entry in json per line. Files older than the retention are removed.
+Archiving is disabled unless a directory is set.
+
+A capture can be started for a while to archive whole heads and bodies of messages
+sampled at a rate, regardless of commands and body size configured.
+*/
+package archive
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+// Direction is the direction of a message.
+type Direction string
+
+const (
+	Sent     Direction = "sent"
+	Received Direction = "received"
+)
+
+const (
+	// DefaultRetention is the time to keep messages archived by default.
+	DefaultRetention = 24 * time.Hour
+	// DefaultBodySize is the max bytes of body archived by default.
+	DefaultBodySize = 256
+	// DefaultQueryLimit is the max number of entries returned by a query by default.
+	DefaultQueryLimit = 1000
+	// MaxCaptureDuration is the max time of a capture.
+	MaxCaptureDuration = time.Hour
+
+	hourFormat = "2006010215"
+	fileSuffix = ".jsonl"
+)
+
+// Config is the config of message archive.
+type Config struct {
+	// Dir is the directory to archive messages, archiving is disabled if empty.
+	Dir string
+	// Retention is the time to keep messages archived, DefaultRetention if 0.
+	Retention time.Duration
+	// BodySize is the max bytes of body archived, bodies are not archived if 0.
+	BodySize int
+	// Commands are the commands of messages archived, all commands if empty.
+	Commands []string
+}
+
+// Entry is a message archived.
+type Entry struct {
+	Time              time.Time `json:"time"`
+	Peer              string    `json:"peer"`
+	Direction         Direction `json:"direction"`
+	MessageID         string    `json:"messageID,omitempty"`
+	Command           string    `json:"command"`
+	ClusterSelector   string    `json:"clusterSelector,omitempty"`
+	ClusterName       string    `json:"clusterName,omitempty"`
+	ParentClusterName string    `json:"parentClusterName,omitempty"`
+	// BodySize is the bytes of the whole body.
+	BodySize int `json:"bodySize"`
+	// Body is the body truncated to BodySize of config, or the whole body if captured.
+	Body []byte `json:"body,omitempty"`
+	// Captured is true if the message is sampled by a capture.
+	Captured bool `json:"captured,omitempty"`
+	// Head is the whole head of a message captured.
+	Head *clustermessage.MessageHead `json:"head,omitempty"`
+}
+
+// Capture is a capture of messages.
+type Capture struct {
+	// Rate is the fraction of messages captured in (0, 1].
+	Rate float64 `json:"rate"`
+	// Duration is the time to capture, capped to MaxCaptureDuration, capture is stopped if 0.
+	Duration time.Duration `json:"duration"`
+}
+
+// Query selects entries archived, empty fields match all.
+type Query struct {
+	Peer      string
+	Since     time.Time
+	Until     time.Time
+	Command   string
+	MessageID string
+	// Captured selects entries captured only.
+	Captured bool
+	// Limit is the max number of the latest entries returned, DefaultQueryLimit if 0.
+	Limit int
+}
+
+func (q *Query) match(e *Entry) bool {
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && e.Time.After(q.Until) {
+		return false
+	}
+	if q.Command != "" && q.Command != e.Command {
+		return false
+	}
+	if q.Captured && !e.Captured {
+		return false
+	}
+	return q.MessageID == "" || q.MessageID == e.MessageID
+}
+
+// segment is the file of a peer archiving messages in an hour.
+type segment struct {
+	hour string
+	file *os.File
+}
+
+// Archive archives messages by peer.
+type Archive struct {
+	sync.Mutex
+	conf     Config
+	commands map[string]bool
+	segments map[string]*segment
+	// pruned is the hour files are pruned last time.
+	pruned string
+	now    func() time.Time
+	// captureRate is the rate of the capture until captureUntil.
+	captureRate  float64
+	captureUntil time.Time
+	random       func() float64
+}
+
+var defaultArchive *Archive
+
+// New returns an Archive with conf.
+func New(conf Config) (*Archive, error) {
+	if conf.Dir == "" {
+		return nil, fmt.Errorf("archive directory is empty")
+	}
+	if conf.Retention < 0 {
+		return nil, fmt.Errorf("archive retention cannot be negative")
+	}
+	if conf.Retention == 0 {
+		conf.Retention = DefaultRetention
+	}
+	if conf.BodySize < 0 {
+		return nil, fmt.Errorf("archive body size cannot be negative")
+	}
+	commands := make(map[string]bool, len(conf.Commands))
+	for _, c := range conf.Commands {
+		if _, ok := clustermessage.CommandType_value[c]; !ok {
+			return nil, fmt.Errorf("command %s to archive is unknown", c)
+		}
+		commands[c] = true
+	}
+	if err := os.MkdirAll(conf.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("create archive directory %s failed: %v", conf.Dir, err)
+	}
+	return &Archive{
+		conf:     conf,
+		commands: commands,
+		segments: make(map[string]*segment),
+		now:      time.Now,
+		random:   rand.Float64,
+	}, nil
+}
+
+// Setup replaces the default archive with conf, disables archiving if directory is empty.
+func Setup(conf Config) error {
+	if conf.Dir == "" {
+		defaultArchive = nil
+		return nil
+	}
+	a, err := New(conf)
+	if err != nil {
+		return err
+	}
+	defaultArchive = a
+	klog.Infof("archive messages in %s for %v", conf.Dir, a.conf.Retention)
+	return nil
+}
+
+// RecordMessage archives msg sent to or received from peer by the default archive.
+func RecordMessage(peer string, dir Direction, msg *clustermessage.ClusterMessage) {
+	if defaultArchive == nil {
+		return
+	}
+	defaultArchive.RecordMessage(peer, dir, msg)
+}
+
+// SetCapture starts or stops the capture of the default archive,
+// and returns the time the capture ends.
+func SetCapture(c Capture) (time.Time, error) {
+	if defaultArchive == nil {
+		return time.Time{}, fmt.Errorf("message archive is disabled")
+	}
+	return defaultArchive.SetCapture(c)
+}
+
+// QueryHandler is the http handler to query the default archive, by query parameters of
+// peer, since and until in RFC3339, command, messageID, captured and limit, and responds entries in json.
+func QueryHandler(w http.ResponseWriter, r *http.Request) {
+	if defaultArchive == nil {
+		http.Error(w, "message archive is disabled", http.StatusNotFound)
+		return
+	}
+	q, err := parseQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries, err := defaultArchive.Query(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data, _ := json.Marshal(entries)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func parseQuery(values url.Values) (Query, error) {
+	q := Query{
+		Peer:      values.Get("peer"),
+		Command:   values.Get("command"),
+		MessageID: values.Get("messageID"),
+	}
+	var err error
+	if s := values.Get("since"); s != "" {
+		if q.Since, err = time.Parse(time.RFC3339, s); err != nil {
+			return q, fmt.Errorf("since %s is not in RFC3339: %v", s, err)
+		}
+	}
+	if s := values.Get("until"); s != "" {
+		if q.Until, err = time.Parse(time.RFC3339, s); err != nil {
+			return q, fmt.Errorf("until %s is not in RFC3339: %v", s, err)
+		}
+	}
+	if s := values.Get("captured"); s != "" {
+		if q.Captured, err = strconv.ParseBool(s); err != nil {
+			return q, fmt.Errorf("captured %s is invalid", s)
+		}
+	}
+	if s := values.Get("limit"); s != "" {
+		if q.Limit, err = strconv.Atoi(s); err != nil || q.Limit < 0 {
+			return q, fmt.Errorf("limit %s is invalid", s)
+		}
+	}
+	return q, nil
+}
+
+// SetCapture starts the capture of messages sampled at rate for duration,
+// or stops it if duration is 0, and returns the time the capture ends.
+func (a *Archive) SetCapture(c Capture) (time.Time, error) {
+	if c.Duration < 0 {
+		return time.Time{}, fmt.Errorf("capture duration cannot be negative")
+	}
+	if c.Duration > 0 && (c.Rate <= 0 || c.Rate > 1) {
+		return time.Time{}, fmt.Errorf("capture rate %v is not in (0, 1]", c.Rate)
+	}
+	if c.Duration > MaxCaptureDuration {
+		c.Duration = MaxCaptureDuration
+	}
+	a.Lock()
+	defer a.Unlock()
+	a.captureRate = c.Rate
+	a.captureUntil = a.now().Add(c.Duration)
+	if c.Duration == 0 {
+		klog.Infof("message capture is stopped")
+		return a.captureUntil, nil
+	}
+	klog.Infof("capture messages at rate %v until %s", c.Rate, a.captureUntil.Format(time.RFC3339))
+	return a.captureUntil, nil
+}
+
+// sampled returns true if a message is sampled by the capture.
+func (a *Archive) sampled() bool {
+	a.Lock()
+	defer a.Unlock()
+	if !a.now().Before(a.captureUntil) {
+		return false
+	}
+	return a.random() < a.captureRate
+}
+
+// RecordMessage archives msg sent to or received from peer.
+func (a *Archive) RecordMessage(peer string, dir Direction, msg *clustermessage.ClusterMessage) {
+	if msg == nil || msg.Head == nil || !validPeer(peer) {
+		return
+	}
+	command := msg.Head.Command.String()
+	captured := a.sampled()
+	if !captured && len(a.commands) != 0 && !a.commands[command] {
+		return
+	}
+	e := &Entry{
+		Peer:              peer,
+		Direction:         dir,
+		MessageID:         msg.Head.MessageID,
+		Command:           command,
+		ClusterSelector:   msg.Head.ClusterSelector,
+		ClusterName:       msg.Head.ClusterName,
+		ParentClusterName: msg.Head.ParentClusterName,
+		BodySize:          len(msg.Body),
+	}
+	if captured {
+		e.Captured = true
+		e.Head = msg.Head
+		e.Body = msg.Body
+	} else if a.conf.BodySize > 0 {
+		size := len(msg.Body)
+		if size > a.conf.BodySize {
+			size = a.conf.BodySize
+		}
+		e.Body = msg.Body[:size]
+	}
+
+	a.Lock()
+	defer a.Unlock()
+	e.Time = a.now()
+	data, err := json.Marshal(e)
+	if err != nil {
+		klog.Errorf("marshal archive entry of %s failed: %v", msg.Head.MessageID, err)
+		return
+	}
+	f, err := a.segment(peer, e.Time)
+	if err != nil {
+		klog.Errorf("open archive of %s failed: %v", peer, err)
+		return
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		klog.Errorf("archive message %s of %s failed: %v", msg.Head.MessageID, peer, err)
+	}
+}
+
+// segment returns the file of peer to archive messages at now, call with lock held.
+func (a *Archive) segment(peer string, now time.Time) (*os.File, error) {
+	hour := now.UTC().Format(hourFormat)
+	if a.pruned != hour {
+		a.pruned = hour
+		a.prune(now)
+	}
+	s, ok := a.segments[peer]
+	if ok && s.hour == hour {
+		return s.file, nil
+	}
+	if ok {
+		s.file.Close()
+		delete(a.segments, peer)
+	}
+	dir := a.peerDir(peer)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, hour+fileSuffix), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	a.segments[peer] = &segment{hour: hour, file: f}
+	return f, nil
+}
+
+// prune removes files of hours out of retention at now, call with lock held.
+func (a *Archive) prune(now time.Time) {
+	oldest := now.Add(-a.conf.Retention).UTC().Format(hourFormat)
+	for _, peer := range a.peers() {
+		hours, err := a.hours(peer)
+		if err != nil {
+			continue
+		}
+		for _, hour := range hours {
+			if hour >= oldest {
+				break
+			}
+			if s, ok := a.segments[peer]; ok && s.hour == hour {
+				s.file.Close()
+				delete(a.segments, peer)
+			}
+			if err := os.Remove(filepath.Join(a.peerDir(peer), hour+fileSuffix)); err != nil {
+				klog.Errorf("remove archive of %s at %s failed: %v", peer, hour, err)
+			}
+		}
+	}
+}
+
+// Query returns the latest entries matched by q in time order.
+func (a *Archive) Query(q Query) ([]Entry, error) {
+	if q.Limit == 0 {
+		q.Limit = DefaultQueryLimit
+	}
+	a.Lock()
+	defer a.Unlock()
+	peers := []string{q.Peer}
+	if q.Peer == "" {
+		peers = a.peers()
+	} else if !validPeer(q.Peer) {
+		return nil, fmt.Errorf("peer %s is invalid", q.Peer)
+	}
+	entries := []Entry{}
+	for _, peer := range peers {
+		hours, err := a.hours(peer)
+		if err != nil {
+			return nil, err
+		}
+		for _, hour := range hours {
+			if !q.Since.IsZero() && hour < q.Since.UTC().Format(hourFormat) {
+				continue
+			}
+			if !q.Until.IsZero() && hour > q.Until.UTC().Format(hourFormat) {
+				continue
+			}
+			matched, err := a.read(peer, hour, &q)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, matched...)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	if len(entries) > q.Limit {
+		entries = entries[len(entries)-q.Limit:]
+	}
+	return entries, nil
+}
+
+// read returns entries matched by q in file of peer at hour.
+func (a *Archive) read(peer, hour string, q *Query) ([]Entry, error) {
+	f, err := os.Open(filepath.Join(a.peerDir(peer), hour+fileSuffix))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		e := Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// skip entry written partially.
+			continue
+		}
+		if q.match(&e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// peers returns the peers archived.
+func (a *Archive) peers() []string {
+	files, err := ioutil.ReadDir(a.conf.Dir)
+	if err != nil {
+		return nil
+	}
+	var peers []string
+	for _, f := range files {
+		if !f.IsDir() {
+			continue
+		}
+		if peer, err := url.PathUnescape(f.Name()); err == nil {
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
+
+// hours returns the hours of files of peer in order.
+func (a *Archive) hours(peer string) ([]string, error) {
+	files, err := ioutil.ReadDir(a.peerDir(peer))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var hours []string
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), fileSuffix) {
+			hours = append(hours, strings.TrimSuffix(f.Name(), fileSuffix))
+		}
+	}
+	sort.Strings(hours)
+	return hours, nil
+}
+
+// validPeer returns true if peer can be a directory in the archive.
+func validPeer(peer string) bool {
+	return peer != "" && peer != "." && peer != ".."
+}
+
+func (a *Archive) peerDir(peer string) string {
+	return filepath.Join(a.conf.Dir, url.PathEscape(peer))
+}
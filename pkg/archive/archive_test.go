@@ -0,0 +1,228 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func newTestArchive(t *testing.T, conf Config) (*Archive, *fakeClock) {
+	dir, err := ioutil.TempDir("", "archive")
+	assert.Nil(t, err)
+	conf.Dir = dir
+	a, err := New(conf)
+	assert.Nil(t, err)
+	clock := &fakeClock{t: time.Date(2019, 10, 31, 23, 30, 0, 0, time.UTC)}
+	a.now = clock.now
+	return a, clock
+}
+
+func newMessage(id string, command clustermessage.CommandType, body []byte) *clustermessage.ClusterMessage {
+	return &clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{MessageID: id, Command: command},
+		Body: body,
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{})
+	assert.NotNil(t, err)
+	_, err = New(Config{Dir: "/tmp", Retention: -1})
+	assert.NotNil(t, err)
+	_, err = New(Config{Dir: "/tmp", BodySize: -1})
+	assert.NotNil(t, err)
+	_, err = New(Config{Dir: "/tmp", Commands: []string{"Unknown"}})
+	assert.NotNil(t, err)
+}
+
+func TestRecordAndQuery(t *testing.T) {
+	a, clock := newTestArchive(t, Config{BodySize: 4})
+	defer os.RemoveAll(a.conf.Dir)
+
+	start := clock.t
+	a.RecordMessage("c1", Sent, newMessage("m1", clustermessage.CommandType_ControlReq, []byte("task body")))
+	clock.t = clock.t.Add(time.Hour)
+	a.RecordMessage("c1", Received, newMessage("m1", clustermessage.CommandType_ControlResp, []byte("ok")))
+	a.RecordMessage("c2", Sent, newMessage("m2", clustermessage.CommandType_ControlReq, nil))
+	a.RecordMessage("..", Sent, newMessage("m3", clustermessage.CommandType_ControlReq, nil))
+
+	entries, err := a.Query(Query{Peer: "c1"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, Entry{
+		Time:      start,
+		Peer:      "c1",
+		Direction: Sent,
+		MessageID: "m1",
+		Command:   "ControlReq",
+		BodySize:  9,
+		Body:      []byte("task"),
+	}, entries[0])
+	assert.Equal(t, "ControlResp", entries[1].Command)
+
+	// by time
+	entries, err = a.Query(Query{Since: start.Add(time.Minute)})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+	entries, err = a.Query(Query{Until: start})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+
+	// by command and message id
+	entries, err = a.Query(Query{Command: "ControlReq"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+	entries, err = a.Query(Query{MessageID: "m1", Command: "ControlResp"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+
+	// the latest ones in limit
+	entries, err = a.Query(Query{Limit: 1, Peer: "c1"})
+	assert.Nil(t, err)
+	assert.Equal(t, "ControlResp", entries[0].Command)
+
+	_, err = a.Query(Query{Peer: ".."})
+	assert.NotNil(t, err)
+}
+
+func TestCommands(t *testing.T) {
+	a, _ := newTestArchive(t, Config{Commands: []string{"ControlReq"}})
+	defer os.RemoveAll(a.conf.Dir)
+
+	a.RecordMessage("c1", Sent, newMessage("m1", clustermessage.CommandType_ControlReq, []byte("task")))
+	a.RecordMessage("c1", Received, newMessage("", clustermessage.CommandType_SubTreeRoute, nil))
+	entries, err := a.Query(Query{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Nil(t, entries[0].Body)
+}
+
+func TestPrune(t *testing.T) {
+	a, clock := newTestArchive(t, Config{Retention: 2 * time.Hour})
+	defer os.RemoveAll(a.conf.Dir)
+
+	a.RecordMessage("c1", Sent, newMessage("m1", clustermessage.CommandType_ControlReq, nil))
+	clock.t = clock.t.Add(time.Hour)
+	a.RecordMessage("c1", Sent, newMessage("m2", clustermessage.CommandType_ControlReq, nil))
+	clock.t = clock.t.Add(2 * time.Hour)
+	a.RecordMessage("c2", Sent, newMessage("m3", clustermessage.CommandType_ControlReq, nil))
+
+	hours, err := a.hours("c1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"2019110100"}, hours)
+	_, err = os.Stat(filepath.Join(a.conf.Dir, "c1", "2019103123"+fileSuffix))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestQueryHandler(t *testing.T) {
+	defaultArchive = nil
+	w := httptest.NewRecorder()
+	QueryHandler(w, httptest.NewRequest(http.MethodGet, "/archive", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	a, _ := newTestArchive(t, Config{})
+	defer os.RemoveAll(a.conf.Dir)
+	defaultArchive = a
+	defer func() { defaultArchive = nil }()
+	RecordMessage("c1", Sent, newMessage("m1", clustermessage.CommandType_ControlReq, nil))
+
+	w = httptest.NewRecorder()
+	QueryHandler(w, httptest.NewRequest(http.MethodGet, "/archive?since=yesterday", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	QueryHandler(w, httptest.NewRequest(http.MethodGet,
+		"/archive?peer=c1&since=2019-10-31T00:00:00Z&messageID=m1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var entries []Entry
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &entries))
+	assert.Equal(t, 1, len(entries))
+}
+
+func TestCapture(t *testing.T) {
+	a, clock := newTestArchive(t, Config{BodySize: 2, Commands: []string{"ControlReq"}})
+	defer os.RemoveAll(a.conf.Dir)
+	samples := []float64{0.1, 0.9, 0.6}
+	a.random = func() float64 {
+		r := samples[0]
+		samples = samples[1:]
+		return r
+	}
+
+	_, err := a.SetCapture(Capture{Rate: 0, Duration: time.Minute})
+	assert.NotNil(t, err)
+	_, err = a.SetCapture(Capture{Rate: 1.5, Duration: time.Minute})
+	assert.NotNil(t, err)
+	_, err = a.SetCapture(Capture{Rate: 0.5, Duration: -time.Minute})
+	assert.NotNil(t, err)
+	until, err := a.SetCapture(Capture{Rate: 0.5, Duration: 2 * time.Hour})
+	assert.Nil(t, err)
+	assert.Equal(t, clock.t.Add(MaxCaptureDuration), until)
+
+	// sampled regardless of commands and body size.
+	a.RecordMessage("c1", Received, newMessage("", clustermessage.CommandType_SubTreeRoute, []byte("route")))
+	// not sampled, archived as configured.
+	a.RecordMessage("c1", Sent, newMessage("m1", clustermessage.CommandType_ControlReq, []byte("task")))
+	a.RecordMessage("c1", Received, newMessage("", clustermessage.CommandType_SubTreeRoute, []byte("route")))
+	// capture ended.
+	clock.t = until
+	a.RecordMessage("c1", Received, newMessage("", clustermessage.CommandType_SubTreeRoute, []byte("route")))
+
+	entries, err := a.Query(Query{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.True(t, entries[0].Captured)
+	assert.Equal(t, []byte("route"), entries[0].Body)
+	assert.Equal(t, clustermessage.CommandType_SubTreeRoute, entries[0].Head.Command)
+	assert.False(t, entries[1].Captured)
+	assert.Equal(t, []byte("ta"), entries[1].Body)
+
+	entries, err = a.Query(Query{Captured: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+
+	// stopped
+	clock.t = clock.t.Add(-time.Minute)
+	_, err = a.SetCapture(Capture{Rate: 1, Duration: time.Minute})
+	assert.Nil(t, err)
+	_, err = a.SetCapture(Capture{})
+	assert.Nil(t, err)
+	assert.False(t, a.sampled())
+
+	defaultArchive = nil
+	_, err = SetCapture(Capture{Rate: 1, Duration: time.Minute})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package audit records messages received from and sent to the parent by edgehandler,
+with what is done to them, for debugging after incidents on remote sites.
+
+Entries are written in json per line to a file, and sent to a channel if any,
+e.g., {"time":"...","peer":"parent","direction":"received","messageID":"m1",
+"command":"ControlReq","clusterSelector":"c1","disposition":"handled"}.
+Auditing is disabled unless a file or channel is set.
+*/
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+// Direction is the direction of a message.
+type Direction string
+
+const (
+	Sent     Direction = "sent"
+	Received Direction = "received"
+)
+
+// Disposition is what is done to a message.
+type Disposition string
+
+const (
+	// Handled messages are handled by this cluster if received, or from this cluster if sent.
+	Handled Disposition = "handled"
+	// Forwarded messages are sent to the subtree if received, or from the subtree if sent.
+	Forwarded Disposition = "forwarded"
+	// Dropped messages are ignored, e.g., duplicated or shed.
+	Dropped Disposition = "dropped"
+	// Error messages failed to be handled or sent.
+	Error Disposition = "error"
+)
+
+// Config is the config of audit log.
+type Config struct {
+	// File is the file to append entries to, not written if empty.
+	File string
+	// Entries is the channel to send entries to, not sent if nil.
+	// Entries are dropped if the channel is full.
+	Entries chan<- Entry
+	// Commands are the commands of messages audited, all commands if empty.
+	Commands []string
+}
+
+// Entry is a message audited.
+type Entry struct {
+	Time            time.Time   `json:"time"`
+	Peer            string      `json:"peer"`
+	Direction       Direction   `json:"direction"`
+	MessageID       string      `json:"messageID,omitempty"`
+	Command         string      `json:"command,omitempty"`
+	ClusterSelector string      `json:"clusterSelector,omitempty"`
+	ClusterName     string      `json:"clusterName,omitempty"`
+	Disposition     Disposition `json:"disposition"`
+	Reason          string      `json:"reason,omitempty"`
+}
+
+// Log records entries of messages by conf.
+type Log struct {
+	entries  chan<- Entry
+	commands map[string]bool
+	now      func() time.Time
+
+	lock    sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+var (
+	defaultLog     *Log
+	defaultLogLock sync.RWMutex
+)
+
+// New returns a Log with conf, whose file is opened to append.
+func New(conf Config) (*Log, error) {
+	if conf.File == "" && conf.Entries == nil {
+		return nil, fmt.Errorf("audit file and channel are both empty")
+	}
+	commands := make(map[string]bool, len(conf.Commands))
+	for _, c := range conf.Commands {
+		if _, ok := clustermessage.CommandType_value[c]; !ok {
+			return nil, fmt.Errorf("command %s to audit is unknown", c)
+		}
+		commands[c] = true
+	}
+	l := &Log{
+		entries:  conf.Entries,
+		commands: commands,
+		now:      time.Now,
+	}
+	if conf.File != "" {
+		f, err := os.OpenFile(conf.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("open audit file %s failed: %v", conf.File, err)
+		}
+		l.file = f
+		l.encoder = json.NewEncoder(f)
+	}
+	return l, nil
+}
+
+// Close closes the file of l.
+func (l *Log) Close() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.file.Close()
+}
+
+/*
+RecordMessage records msg sent to or received from peer with its disposition and the reason, if any.
+Messages without a head, e.g., undecodable ones, are recorded regardless of commands configured.
+*/
+func (l *Log) RecordMessage(peer string, dir Direction, msg *clustermessage.ClusterMessage,
+	disposition Disposition, reason string) {
+	if l == nil {
+		return
+	}
+	entry := Entry{
+		Time:        l.now(),
+		Peer:        peer,
+		Direction:   dir,
+		Disposition: disposition,
+		Reason:      reason,
+	}
+	if head := msg.GetHead(); head != nil {
+		if len(l.commands) != 0 && !l.commands[head.Command.String()] {
+			return
+		}
+		entry.MessageID = head.MessageID
+		entry.Command = head.Command.String()
+		entry.ClusterSelector = head.ClusterSelector
+		entry.ClusterName = head.ClusterName
+	}
+	l.write(entry)
+}
+
+func (l *Log) write(entry Entry) {
+	if l.encoder != nil {
+		l.lock.Lock()
+		err := l.encoder.Encode(&entry)
+		l.lock.Unlock()
+		if err != nil {
+			klog.Errorf("write audit entry of message %s failed: %v", entry.MessageID, err)
+		}
+	}
+	if l.entries != nil {
+		select {
+		case l.entries <- entry:
+		default:
+			klog.V(3).Infof("audit channel is full, drop entry of message %s", entry.MessageID)
+		}
+	}
+}
+
+// Setup replaces the default log with conf, disables auditing if file and channel are empty.
+func Setup(conf Config) error {
+	var l *Log
+	if conf.File != "" || conf.Entries != nil {
+		var err error
+		if l, err = New(conf); err != nil {
+			return err
+		}
+		klog.Infof("audit messages with parent in %q", conf.File)
+	}
+	defaultLogLock.Lock()
+	old := defaultLog
+	defaultLog = l
+	defaultLogLock.Unlock()
+	return old.Close()
+}
+
+// Enabled returns if messages are audited by the default log.
+func Enabled() bool {
+	defaultLogLock.RLock()
+	defer defaultLogLock.RUnlock()
+	return defaultLog != nil
+}
+
+// RecordMessage records msg sent to or received from peer by the default log.
+func RecordMessage(peer string, dir Direction, msg *clustermessage.ClusterMessage,
+	disposition Disposition, reason string) {
+	defaultLogLock.RLock()
+	defer defaultLogLock.RUnlock()
+	defaultLog.RecordMessage(peer, dir, msg, disposition, reason)
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+func auditMessage(id string, cmd clustermessage.CommandType) *clustermessage.ClusterMessage {
+	return &clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{
+			MessageID:       id,
+			Command:         cmd,
+			ClusterSelector: "c1,c2",
+			ClusterName:     "c1",
+		},
+	}
+}
+
+func readEntries(t *testing.T, file string) []Entry {
+	f, err := os.Open(file)
+	assert.Nil(t, err)
+	defer f.Close()
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Entry
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), &e))
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{})
+	assert.NotNil(t, err)
+	_, err = New(Config{Entries: make(chan Entry), Commands: []string{"Unknown"}})
+	assert.NotNil(t, err)
+	_, err = New(Config{File: "/nonexistent/audit.jsonl"})
+	assert.NotNil(t, err)
+}
+
+func TestRecordMessage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "audit.jsonl")
+	entries := make(chan Entry, 1)
+	l, err := New(Config{File: file, Entries: entries, Commands: []string{"ControlReq"}})
+	assert.Nil(t, err)
+	now := time.Date(2019, 10, 31, 23, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+
+	l.RecordMessage("parent", Received, auditMessage("m1", clustermessage.CommandType_ControlReq), Error, "failed")
+	// commands not configured are not recorded.
+	l.RecordMessage("parent", Sent, auditMessage("m2", clustermessage.CommandType_SubTreeRoute), Handled, "")
+	// messages without a head are recorded regardless of commands.
+	l.RecordMessage("parent", Received, nil, Error, "undecodable")
+	assert.Nil(t, l.Close())
+
+	expected := Entry{
+		Time:            now,
+		Peer:            "parent",
+		Direction:       Received,
+		MessageID:       "m1",
+		Command:         "ControlReq",
+		ClusterSelector: "c1,c2",
+		ClusterName:     "c1",
+		Disposition:     Error,
+		Reason:          "failed",
+	}
+	written := readEntries(t, file)
+	assert.Len(t, written, 2)
+	assert.Equal(t, expected, written[0])
+	assert.Equal(t, "undecodable", written[1].Reason)
+	assert.Empty(t, written[1].MessageID)
+
+	// entries are dropped once the channel is full.
+	assert.Len(t, entries, 1)
+	assert.Equal(t, expected, <-entries)
+
+	// the file is appended to.
+	l, err = New(Config{File: file})
+	assert.Nil(t, err)
+	l.RecordMessage("parent", Sent, auditMessage("m3", clustermessage.CommandType_ControlResp), Handled, "")
+	assert.Nil(t, l.Close())
+	assert.Len(t, readEntries(t, file), 3)
+
+	var nilLog *Log
+	nilLog.RecordMessage("parent", Sent, auditMessage("m4", clustermessage.CommandType_ControlResp), Handled, "")
+	assert.Nil(t, nilLog.Close())
+}
+
+func TestSetup(t *testing.T) {
+	assert.Nil(t, Setup(Config{}))
+	assert.False(t, Enabled())
+	RecordMessage("parent", Sent, auditMessage("m1", clustermessage.CommandType_ControlResp), Handled, "")
+
+	entries := make(chan Entry, 1)
+	assert.Nil(t, Setup(Config{Entries: entries}))
+	defer Setup(Config{})
+	assert.True(t, Enabled())
+	RecordMessage("parent", Sent, auditMessage("m2", clustermessage.CommandType_ControlResp), Handled, "")
+	assert.Equal(t, "m2", (<-entries).MessageID)
+
+	assert.NotNil(t, Setup(Config{Entries: entries, Commands: []string{"Unknown"}}))
+}
@@ -0,0 +1,341 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package bandwidth accounts bytes sent to and received from the parent and children of
+// clustercontroller, by peer and message kind, since many edges run on metered links.
+/*
+Bytes are accounted in windows of the last minute, 5 minutes and hour, in the current month
+and in total. Kind of a message is its command, e.g., EdgeReport or ControlReq, and the body
+of edge reports is accounted by resource type too, e.g., EdgeReport/pod.
+
+If a monthly quota is set, a warning is logged once each time bytes sent and received with
+a peer in the current month reach an alert percent of the quota.
+*/
+package bandwidth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/reporter"
+)
+
+// Direction is the direction of bytes.
+type Direction string
+
+const (
+	Sent     Direction = "sent"
+	Received Direction = "received"
+)
+
+const (
+	// ParentPeer is the peer name of the parent cluster.
+	ParentPeer = "parent"
+	// ShadowParentPeer is the peer name of the shadow parent cluster.
+	ShadowParentPeer = "shadow-parent"
+
+	monthFormat = "2006-01"
+	// windowMinutes is the longest window accounted.
+	windowMinutes = 60
+)
+
+// DefaultAlertPercents are the percents of monthly quota to alert by default.
+var DefaultAlertPercents = []int{80, 100}
+
+// resourceNames are the names of resource types in kinds of edge reports.
+var resourceNames = map[int]string{
+	reporter.ResourceTypeNode:          "node",
+	reporter.ResourceTypePod:           "pod",
+	reporter.ResourceTypeDeployment:    "deployment",
+	reporter.ResourceTypeDaemonset:     "daemonset",
+	reporter.ResourceTypeService:       "service",
+	reporter.ResourceTypeStatefulset:   "statefulset",
+	reporter.ResourceTypeClusterStatus: "clusterstatus",
+	reporter.ResourceTypeEvent:         "event",
+	reporter.ResourceTypeEndpoints:     "endpoints",
+}
+
+// Config is the config of bandwidth accounting.
+type Config struct {
+	// MonthlyQuota is the bytes sent and received with each peer per month, 0 means no quota.
+	MonthlyQuota uint64
+	// AlertPercents are the percents of monthly quota to alert, DefaultAlertPercents if empty.
+	AlertPercents []int
+}
+
+// Usage is the bytes of a direction or a kind in windows.
+type Usage struct {
+	LastMinute   uint64 `json:"lastMinute"`
+	Last5Minutes uint64 `json:"last5Minutes"`
+	LastHour     uint64 `json:"lastHour"`
+	Month        uint64 `json:"month"`
+	Total        uint64 `json:"total"`
+}
+
+// PeerStats is the bandwidth usage with a peer.
+type PeerStats struct {
+	Sent     Usage `json:"sent"`
+	Received Usage `json:"received"`
+	// Kinds is the usage by direction/kind, e.g., sent/EdgeReport.
+	Kinds map[string]Usage `json:"kinds,omitempty"`
+	// QuotaPercent is the percent of monthly quota used in the current month.
+	QuotaPercent float64 `json:"quotaPercent,omitempty"`
+}
+
+// Stats is the bandwidth usage of all peers.
+type Stats struct {
+	Month        string                `json:"month"`
+	MonthlyQuota uint64                `json:"monthlyQuota,omitempty"`
+	Peers        map[string]*PeerStats `json:"peers"`
+}
+
+// counter counts bytes by minute in the last hour, in the current month and in total.
+type counter struct {
+	total   uint64
+	month   string
+	inMonth uint64
+	minutes [windowMinutes]uint64
+	// starts are the unix minutes of buckets in minutes.
+	starts [windowMinutes]int64
+}
+
+func (c *counter) add(now time.Time, n uint64) {
+	c.total += n
+	month := now.Format(monthFormat)
+	if month != c.month {
+		c.month = month
+		c.inMonth = 0
+	}
+	c.inMonth += n
+	minute := now.Unix() / 60
+	i := minute % windowMinutes
+	if c.starts[i] != minute {
+		c.starts[i] = minute
+		c.minutes[i] = 0
+	}
+	c.minutes[i] += n
+}
+
+func (c *counter) usage(now time.Time) Usage {
+	u := Usage{Total: c.total}
+	if c.month == now.Format(monthFormat) {
+		u.Month = c.inMonth
+	}
+	minute := now.Unix() / 60
+	for i := range c.minutes {
+		age := minute - c.starts[i]
+		if age < 0 || age >= windowMinutes {
+			continue
+		}
+		if age < 1 {
+			u.LastMinute += c.minutes[i]
+		}
+		if age < 5 {
+			u.Last5Minutes += c.minutes[i]
+		}
+		u.LastHour += c.minutes[i]
+	}
+	return u
+}
+
+type peer struct {
+	sent     counter
+	received counter
+	kinds    map[string]*counter
+	// alerted is the highest alert percent of quota logged in month.
+	alerted      int
+	alertedMonth string
+}
+
+// Accountant accounts bytes with peers.
+type Accountant struct {
+	sync.Mutex
+	conf  Config
+	peers map[string]*peer
+	now   func() time.Time
+}
+
+var defaultAccountant = NewAccountant(Config{})
+
+// NewAccountant returns an Accountant with conf.
+func NewAccountant(conf Config) *Accountant {
+	if len(conf.AlertPercents) == 0 {
+		conf.AlertPercents = DefaultAlertPercents
+	}
+	percents := make([]int, len(conf.AlertPercents))
+	copy(percents, conf.AlertPercents)
+	sort.Ints(percents)
+	conf.AlertPercents = percents
+	return &Accountant{
+		conf:  conf,
+		peers: make(map[string]*peer),
+		now:   time.Now,
+	}
+}
+
+// Setup replaces the default accountant with conf, bytes accounted before are dropped.
+func Setup(conf Config) error {
+	for _, p := range conf.AlertPercents {
+		if p <= 0 {
+			return fmt.Errorf("alert percent %d must be positive", p)
+		}
+	}
+	defaultAccountant = NewAccountant(conf)
+	if conf.MonthlyQuota > 0 {
+		klog.Infof("bandwidth monthly quota %d bytes of each peer, alert at %v percent",
+			conf.MonthlyQuota, defaultAccountant.conf.AlertPercents)
+	}
+	return nil
+}
+
+// RecordMessage accounts data of msg sent to or received from peer by the default accountant.
+func RecordMessage(peer string, dir Direction, msg *clustermessage.ClusterMessage, size int) {
+	defaultAccountant.RecordMessage(peer, dir, msg, size)
+}
+
+// GetStats returns the stats of the default accountant.
+func GetStats() Stats {
+	return defaultAccountant.Stats()
+}
+
+// StatsHandler is the http handler to get stats of the default accountant in json.
+func StatsHandler(w http.ResponseWriter, r *http.Request) {
+	data, _ := json.Marshal(GetStats())
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// RecordMessage accounts size bytes of msg sent to or received from peer.
+func (a *Accountant) RecordMessage(peer string, dir Direction, msg *clustermessage.ClusterMessage, size int) {
+	if msg == nil || msg.Head == nil || size <= 0 {
+		return
+	}
+	kind := msg.Head.Command.String()
+	var reports map[string]uint64
+	if msg.Head.Command == clustermessage.CommandType_EdgeReport {
+		reports = reportSizes(msg.Body)
+	}
+
+	a.Lock()
+	defer a.Unlock()
+	now := a.now()
+	p := a.peer(peer)
+	if dir == Sent {
+		p.sent.add(now, uint64(size))
+	} else {
+		p.received.add(now, uint64(size))
+	}
+	p.kind(string(dir)+"/"+kind).add(now, uint64(size))
+	for resource, n := range reports {
+		p.kind(string(dir)+"/"+kind+"/"+resource).add(now, n)
+	}
+	a.checkQuota(peer, p, now)
+}
+
+func (a *Accountant) peer(name string) *peer {
+	p, ok := a.peers[name]
+	if !ok {
+		p = &peer{kinds: make(map[string]*counter)}
+		a.peers[name] = p
+	}
+	return p
+}
+
+func (p *peer) kind(name string) *counter {
+	c, ok := p.kinds[name]
+	if !ok {
+		c = &counter{}
+		p.kinds[name] = c
+	}
+	return c
+}
+
+// checkQuota logs a warning if bytes in month reach a new alert percent of quota.
+func (a *Accountant) checkQuota(name string, p *peer, now time.Time) {
+	if a.conf.MonthlyQuota == 0 {
+		return
+	}
+	month := now.Format(monthFormat)
+	if p.alertedMonth != month {
+		p.alertedMonth = month
+		p.alerted = 0
+	}
+	used := p.sent.usage(now).Month + p.received.usage(now).Month
+	percent := float64(used) * 100 / float64(a.conf.MonthlyQuota)
+	for i := len(a.conf.AlertPercents) - 1; i >= 0; i-- {
+		alert := a.conf.AlertPercents[i]
+		if percent < float64(alert) {
+			continue
+		}
+		if alert > p.alerted {
+			p.alerted = alert
+			klog.Warningf("bandwidth with %s reaches %d%% of monthly quota: %d of %d bytes in %s",
+				name, alert, used, a.conf.MonthlyQuota, month)
+		}
+		return
+	}
+}
+
+// Stats returns the bandwidth usage of all peers.
+func (a *Accountant) Stats() Stats {
+	a.Lock()
+	defer a.Unlock()
+	now := a.now()
+	stats := Stats{
+		Month:        now.Format(monthFormat),
+		MonthlyQuota: a.conf.MonthlyQuota,
+		Peers:        make(map[string]*PeerStats, len(a.peers)),
+	}
+	for name, p := range a.peers {
+		ps := &PeerStats{
+			Sent:     p.sent.usage(now),
+			Received: p.received.usage(now),
+			Kinds:    make(map[string]Usage, len(p.kinds)),
+		}
+		for kind, c := range p.kinds {
+			ps.Kinds[kind] = c.usage(now)
+		}
+		if a.conf.MonthlyQuota > 0 {
+			ps.QuotaPercent = float64(ps.Sent.Month+ps.Received.Month) * 100 / float64(a.conf.MonthlyQuota)
+		}
+		stats.Peers[name] = ps
+	}
+	return stats
+}
+
+// reportSizes returns the bytes of report bodies by resource type, in any report encoding.
+func reportSizes(body []byte) map[string]uint64 {
+	reports, err := reporter.DecodeReports(body)
+	if err != nil {
+		return nil
+	}
+	sizes := make(map[string]uint64, len(reports))
+	for _, r := range reports {
+		name, ok := resourceNames[r.ResourceType]
+		if !ok {
+			name = fmt.Sprintf("type-%d", r.ResourceType)
+		}
+		sizes[name] += uint64(len(r.Body))
+	}
+	return sizes
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bandwidth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/reporter"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func newTestAccountant(conf Config) (*Accountant, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2019, 10, 31, 23, 0, 0, 0, time.UTC)}
+	a := NewAccountant(conf)
+	a.now = clock.now
+	return a, clock
+}
+
+func newMessage(command clustermessage.CommandType, body []byte) *clustermessage.ClusterMessage {
+	return &clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{Command: command},
+		Body: body,
+	}
+}
+
+func TestCounter(t *testing.T) {
+	now := time.Date(2019, 10, 31, 23, 0, 0, 0, time.UTC)
+	c := &counter{}
+	c.add(now, 1)
+	c.add(now.Add(2*time.Minute), 10)
+	c.add(now.Add(30*time.Minute), 100)
+
+	u := c.usage(now.Add(30 * time.Minute))
+	assert.Equal(t, Usage{LastMinute: 100, Last5Minutes: 100, LastHour: 111, Month: 111, Total: 111}, u)
+
+	u = c.usage(now.Add(62 * time.Minute))
+	assert.Equal(t, Usage{LastHour: 100, Total: 111}, u)
+
+	// buckets of an hour ago are reused.
+	c.add(now.Add(62*time.Minute), 1000)
+	u = c.usage(now.Add(62 * time.Minute))
+	assert.Equal(t, Usage{LastMinute: 1000, Last5Minutes: 1000, LastHour: 1100, Month: 1000, Total: 1111}, u)
+}
+
+func TestRecordMessage(t *testing.T) {
+	a, _ := newTestAccountant(Config{})
+	reports := reporter.Reports{
+		{ResourceType: reporter.ResourceTypePod, Body: []byte("pods")},
+		{ResourceType: reporter.ResourceTypeEvent, Body: []byte("event")},
+		{ResourceType: reporter.ResourceTypePod, Body: []byte("pod")},
+	}
+	body, err := json.Marshal(reports)
+	assert.Nil(t, err)
+
+	a.RecordMessage("c1", Received, newMessage(clustermessage.CommandType_EdgeReport, body), 200)
+	a.RecordMessage("c1", Sent, newMessage(clustermessage.CommandType_ControlReq, nil), 50)
+	a.RecordMessage("c1", Sent, nil, 50)
+	a.RecordMessage("c2", Sent, newMessage(clustermessage.CommandType_ControlReq, nil), 0)
+
+	stats := a.Stats()
+	assert.Equal(t, "2019-10", stats.Month)
+	assert.Len(t, stats.Peers, 1)
+	p := stats.Peers["c1"]
+	assert.Equal(t, uint64(200), p.Received.Total)
+	assert.Equal(t, uint64(50), p.Sent.Total)
+	assert.Equal(t, uint64(200), p.Kinds["received/EdgeReport"].Month)
+	assert.Equal(t, uint64(50), p.Kinds["sent/ControlReq"].LastMinute)
+	assert.Equal(t, uint64(7), p.Kinds["received/EdgeReport/pod"].Total)
+	assert.Equal(t, uint64(5), p.Kinds["received/EdgeReport/event"].Total)
+	assert.Zero(t, p.QuotaPercent)
+}
+
+func TestReportSizes(t *testing.T) {
+	assert.Nil(t, reportSizes([]byte("invalid")))
+	sizes := reportSizes([]byte(`[{"resourceType":99,"body":"YWJj"}]`))
+	assert.Equal(t, map[string]uint64{"type-99": 3}, sizes)
+
+	// bodies in binary encodings are accounted the same.
+	body, err := reporter.EncodeReports(reporter.Reports{{ResourceType: reporter.ResourceTypePod, Body: []byte("abc")}},
+		reporter.EncodingCBOR)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]uint64{"pod": 3}, reportSizes(body))
+}
+
+func TestCheckQuota(t *testing.T) {
+	a, clock := newTestAccountant(Config{MonthlyQuota: 1000, AlertPercents: []int{100, 50}})
+	assert.Equal(t, []int{50, 100}, a.conf.AlertPercents)
+	msg := newMessage(clustermessage.CommandType_EdgeReport, nil)
+
+	a.RecordMessage("c1", Received, msg, 400)
+	assert.Equal(t, 0, a.peers["c1"].alerted)
+	a.RecordMessage("c1", Sent, msg, 200)
+	assert.Equal(t, 50, a.peers["c1"].alerted)
+	assert.Equal(t, 60.0, a.Stats().Peers["c1"].QuotaPercent)
+	a.RecordMessage("c1", Sent, msg, 500)
+	assert.Equal(t, 100, a.peers["c1"].alerted)
+	a.RecordMessage("c1", Sent, msg, 500)
+	assert.Equal(t, 100, a.peers["c1"].alerted)
+
+	// usage and alerts are reset in a new month.
+	clock.t = clock.t.Add(2 * time.Hour)
+	a.RecordMessage("c1", Sent, msg, 100)
+	assert.Equal(t, 0, a.peers["c1"].alerted)
+	stats := a.Stats()
+	assert.Equal(t, "2019-11", stats.Month)
+	assert.Equal(t, uint64(100), stats.Peers["c1"].Sent.Month)
+	assert.Equal(t, uint64(1300), stats.Peers["c1"].Sent.Total)
+	assert.Equal(t, 10.0, stats.Peers["c1"].QuotaPercent)
+}
+
+func TestSetup(t *testing.T) {
+	defer Setup(Config{})
+
+	assert.NotNil(t, Setup(Config{AlertPercents: []int{80, 0}}))
+	assert.Nil(t, Setup(Config{MonthlyQuota: 1000}))
+	assert.Equal(t, DefaultAlertPercents, defaultAccountant.conf.AlertPercents)
+
+	RecordMessage(ParentPeer, Sent, newMessage(clustermessage.CommandType_EdgeReport, nil), 100)
+	w := httptest.NewRecorder()
+	StatsHandler(w, httptest.NewRequest(http.MethodGet, "/bandwidth", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	stats := Stats{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, uint64(1000), stats.MonthlyQuota)
+	assert.Equal(t, uint64(100), stats.Peers[ParentPeer].Sent.Total)
+	assert.Equal(t, 10.0, stats.Peers[ParentPeer].QuotaPercent)
+}
@@ -0,0 +1,270 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package benchmark loads the message pipeline from edge clusters to ote controller manager.
+// It starts a real root clusterhandler and a real upstream processor of controller manager
+// in process, simulates edge clusters by edge tunnels sending synthetic reports,
+// and measures the throughput and latency of reports processed by controller manager.
+package benchmark
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog"
+
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/baidu/ote-stack/pkg/clusterhandler"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/config"
+	"github.com/baidu/ote-stack/pkg/controllermanager"
+	otefake "github.com/baidu/ote-stack/pkg/k8sclient/fake"
+	"github.com/baidu/ote-stack/pkg/tunnel"
+)
+
+const (
+	clusterNamePrefix = "bench-"
+	// connectTimeout is the time to wait for tunnels connected.
+	connectTimeout = 10 * time.Second
+)
+
+// Config is the config of a benchmark.
+type Config struct {
+	// Clusters is the number of simulated edge clusters.
+	Clusters int
+	// Rate is the number of reports sent by each cluster per second.
+	Rate float64
+	// Duration is the time to send reports.
+	Duration time.Duration
+	// PodsPerReport is the number of pods in each report.
+	PodsPerReport int
+	// DrainTimeout is the time to wait for reports in flight after sending stopped.
+	DrainTimeout time.Duration
+}
+
+// Default returns a config with default values.
+func Default() *Config {
+	return &Config{
+		Clusters:      10,
+		Rate:          10,
+		Duration:      10 * time.Second,
+		PodsPerReport: 10,
+		DrainTimeout:  5 * time.Second,
+	}
+}
+
+func (c *Config) valid() error {
+	if c.Clusters <= 0 {
+		return fmt.Errorf("number of clusters must be positive")
+	}
+	if c.Rate <= 0 {
+		return fmt.Errorf("rate must be positive")
+	}
+	if c.Duration <= 0 {
+		return fmt.Errorf("duration must be positive")
+	}
+	if c.PodsPerReport < 0 {
+		return fmt.Errorf("pods per report cannot be negative")
+	}
+	return nil
+}
+
+// benchmark holds the state of a running benchmark.
+type benchmark struct {
+	conf      *Config
+	rootAddr  string
+	sendTimes sync.Map // message id -> time sent
+	stats     *stats
+	sent      int64
+	failed    int64
+}
+
+// Run runs a benchmark by config, and returns the result after all reports
+// are processed or drain timeout.
+func Run(c *Config) (*Result, error) {
+	if err := c.valid(); err != nil {
+		return nil, err
+	}
+	addr, err := freeAddr()
+	if err != nil {
+		return nil, err
+	}
+	b := &benchmark{
+		conf:     c,
+		rootAddr: addr,
+		stats:    newStats(),
+	}
+
+	if err := b.startRoot(); err != nil {
+		return nil, err
+	}
+	if err := b.startControllerManager(); err != nil {
+		return nil, err
+	}
+	edges, err := b.startEdges()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	b.load(edges)
+	b.drain()
+	return b.stats.result(atomic.LoadInt64(&b.sent), atomic.LoadInt64(&b.failed), time.Since(start)), nil
+}
+
+// startRoot starts a root clusterhandler with a fake k8s client.
+func (b *benchmark) startRoot() error {
+	handler, err := clusterhandler.NewClusterHandler(&config.ClusterControllerConfig{
+		TunnelListenAddr:      b.rootAddr,
+		ClusterName:           config.RootClusterName,
+		ClusterUserDefineName: config.RootClusterName,
+		K8sClient:             otefake.NewSimpleClientset(),
+		EdgeToClusterChan:     make(chan clustermessage.ClusterMessage),
+		ClusterToEdgeChan:     make(chan clustermessage.ClusterMessage),
+	})
+	if err != nil {
+		return fmt.Errorf("create root clusterhandler failed: %v", err)
+	}
+	return handler.Start()
+}
+
+// startControllerManager connects an upstream processor with fake k8s clients to root,
+// the latency of a report is measured after it is processed.
+func (b *benchmark) startControllerManager() error {
+	processor := controllermanager.NewUpstreamProcessor(&controllermanager.K8sContext{
+		OteClient: otefake.NewSimpleClientset(),
+		K8sClient: k8sfake.NewSimpleClientset(),
+	})
+
+	connected := make(chan struct{})
+	controllerTunnel := tunnel.NewControllerTunnel(b.rootAddr)
+	controllerTunnel.RegistAfterConnectToHook(func() {
+		close(connected)
+	})
+	controllerTunnel.RegistReceiveMessageHandler(func(client string, data []byte) error {
+		msg := &clustermessage.ClusterMessage{}
+		if err := msg.Deserialize(data); err != nil || msg.Head == nil {
+			return err
+		}
+		if msg.Head.Command != clustermessage.CommandType_EdgeReport {
+			return nil
+		}
+		err := processor.HandleReceivedMessage(client, data)
+		if sent, ok := b.sendTimes.Load(msg.Head.MessageID); ok {
+			b.sendTimes.Delete(msg.Head.MessageID)
+			b.stats.add(time.Since(sent.(time.Time)), err)
+		}
+		return err
+	})
+	if err := controllerTunnel.Start(); err != nil {
+		return fmt.Errorf("connect controller tunnel to root failed: %v", err)
+	}
+
+	select {
+	case <-connected:
+	case <-time.After(connectTimeout):
+		return fmt.Errorf("controller tunnel is not connected in %v", connectTimeout)
+	}
+	// wait for root to record the controller manager.
+	time.Sleep(100 * time.Millisecond)
+	return nil
+}
+
+// startEdges connects all simulated edge clusters to root.
+func (b *benchmark) startEdges() ([]*edge, error) {
+	edges := make([]*edge, 0, b.conf.Clusters)
+	for i := 0; i < b.conf.Clusters; i++ {
+		e := newEdge(fmt.Sprintf("%s%d", clusterNamePrefix, i), b.rootAddr)
+		if err := e.start(); err != nil {
+			return nil, err
+		}
+		edges = append(edges, e)
+	}
+	for _, e := range edges {
+		if err := e.waitConnected(connectTimeout); err != nil {
+			return nil, err
+		}
+	}
+	klog.Infof("%d edge clusters connected to %s", len(edges), b.rootAddr)
+	return edges, nil
+}
+
+// load sends reports from all edges at the rate until duration.
+func (b *benchmark) load(edges []*edge) {
+	interval := time.Duration(float64(time.Second) / b.conf.Rate)
+	stop := time.After(b.conf.Duration)
+	done := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for _, e := range edges {
+		wg.Add(1)
+		go func(e *edge) {
+			defer wg.Done()
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					b.send(e)
+				}
+			}
+		}(e)
+	}
+	<-stop
+	close(done)
+	wg.Wait()
+}
+
+func (b *benchmark) send(e *edge) {
+	id, data, err := e.nextReport(b.conf.PodsPerReport)
+	if err != nil {
+		klog.Errorf("make report of %s failed: %v", e.name, err)
+		atomic.AddInt64(&b.failed, 1)
+		return
+	}
+	b.sendTimes.Store(id, time.Now())
+	atomic.AddInt64(&b.sent, 1)
+	if err := e.tunnel.Send(data); err != nil {
+		b.sendTimes.Delete(id)
+		atomic.AddInt64(&b.failed, 1)
+	}
+}
+
+// drain waits until all reports sent are processed or drain timeout.
+func (b *benchmark) drain() {
+	deadline := time.Now().Add(b.conf.DrainTimeout)
+	for time.Now().Before(deadline) {
+		if b.stats.count() >= atomic.LoadInt64(&b.sent)-atomic.LoadInt64(&b.failed) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// freeAddr returns a free local address for root cloud tunnel.
+func freeAddr() (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("find free address failed: %v", err)
+	}
+	defer ln.Close()
+	return ln.Addr().String(), nil
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package benchmark
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/controllermanager"
+)
+
+func TestConfigValid(t *testing.T) {
+	assert.Nil(t, Default().valid())
+
+	c := Default()
+	c.Clusters = 0
+	assert.NotNil(t, c.valid())
+	c = Default()
+	c.Rate = 0
+	assert.NotNil(t, c.valid())
+	c = Default()
+	c.Duration = 0
+	assert.NotNil(t, c.valid())
+	c = Default()
+	c.PodsPerReport = -1
+	assert.NotNil(t, c.valid())
+}
+
+func TestNextReport(t *testing.T) {
+	e := newEdge("bench-0", "127.0.0.1:8287")
+	id, data, err := e.nextReport(3)
+	assert.Nil(t, err)
+	assert.Equal(t, "bench-0-1", id)
+
+	msg := &clustermessage.ClusterMessage{}
+	assert.Nil(t, msg.Deserialize(data))
+	assert.Equal(t, clustermessage.CommandType_EdgeReport, msg.Head.Command)
+	assert.Equal(t, "bench-0", msg.Head.ClusterName)
+	assert.Equal(t, id, msg.Head.MessageID)
+
+	reports, err := controllermanager.ReportDeserialize(msg.Body)
+	assert.Nil(t, err)
+	assert.Len(t, reports, 1)
+	prs, err := controllermanager.PodReportStatusDeserialize(reports[0].Body)
+	assert.Nil(t, err)
+	assert.Len(t, prs.UpdateMap, 3)
+
+	id, _, err = e.nextReport(0)
+	assert.Nil(t, err)
+	assert.Equal(t, "bench-0-2", id)
+}
+
+func TestStatsResult(t *testing.T) {
+	s := newStats()
+	r := s.result(2, 2, time.Second)
+	assert.Equal(t, int64(0), r.Received)
+	assert.Equal(t, int64(0), r.Lost())
+
+	for i := 1; i <= 100; i++ {
+		s.add(time.Duration(i)*time.Millisecond, nil)
+	}
+	s.add(time.Second, assert.AnError)
+	r = s.result(110, 2, 2*time.Second)
+	assert.Equal(t, int64(101), r.Received)
+	assert.Equal(t, int64(1), r.Errors)
+	assert.Equal(t, int64(7), r.Lost())
+	assert.Equal(t, 50.5, r.Throughput)
+	assert.Equal(t, 51*time.Millisecond, r.LatencyP50)
+	assert.Equal(t, 91*time.Millisecond, r.LatencyP90)
+	assert.Equal(t, 100*time.Millisecond, r.LatencyP99)
+	assert.Equal(t, time.Second, r.LatencyMax)
+
+	buf := &bytes.Buffer{}
+	r.Print(buf)
+	assert.Contains(t, buf.String(), "110 sent, 101 received, 2 failed, 1 errors, 7 lost")
+	assert.Contains(t, buf.String(), "throughput: 50.5 reports/s")
+}
+
+func TestRun(t *testing.T) {
+	_, err := Run(&Config{})
+	assert.NotNil(t, err)
+
+	r, err := Run(&Config{
+		Clusters:      3,
+		Rate:          20,
+		Duration:      500 * time.Millisecond,
+		PodsPerReport: 2,
+		DrainTimeout:  5 * time.Second,
+	})
+	assert.Nil(t, err)
+	assert.True(t, r.Sent > 0)
+	assert.Equal(t, r.Sent, r.Received)
+	assert.Equal(t, int64(0), r.Failed)
+	assert.Equal(t, int64(0), r.Errors)
+	assert.True(t, r.LatencyMax > 0)
+}
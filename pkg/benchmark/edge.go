@@ -0,0 +1,138 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package benchmark
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/baidu/ote-stack/pkg/config"
+	"github.com/baidu/ote-stack/pkg/reporter"
+	"github.com/baidu/ote-stack/pkg/tunnel"
+)
+
+// edge is a simulated edge cluster connected to root by an edge tunnel.
+type edge struct {
+	name      string
+	tunnel    tunnel.EdgeTunnel
+	connected chan struct{}
+	seq       int64
+	version   int64
+}
+
+func newEdge(name, rootAddr string) *edge {
+	e := &edge{
+		name:      name,
+		connected: make(chan struct{}),
+	}
+	e.tunnel = tunnel.NewEdgeTunnel(&config.ClusterControllerConfig{
+		ClusterName:           name,
+		ClusterUserDefineName: name,
+		ParentCluster:         []string{rootAddr},
+		// simulated edges are not listened, only used to register.
+		TunnelListenAddr: "127.0.0.1:0",
+	})
+	e.tunnel.RegistAfterConnectToHook(func() {
+		select {
+		case <-e.connected:
+		default:
+			close(e.connected)
+		}
+	})
+	// messages from root are dropped.
+	e.tunnel.RegistReceiveMessageHandler(func(string, []byte) error {
+		return nil
+	})
+	return e
+}
+
+func (e *edge) start() error {
+	if err := e.tunnel.Start(); err != nil {
+		return fmt.Errorf("start edge tunnel of %s failed: %v", e.name, err)
+	}
+	return nil
+}
+
+func (e *edge) waitConnected(timeout time.Duration) error {
+	select {
+	case <-e.connected:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("edge %s is not connected in %v", e.name, timeout)
+	}
+}
+
+// nextReport returns the message id and the serialized edge report with pods
+// updated, as the pod reporter of an edge cluster does.
+// It is called by only one goroutine for each edge.
+func (e *edge) nextReport(pods int) (string, []byte, error) {
+	e.seq++
+	e.version++
+	id := e.name + "-" + strconv.FormatInt(e.seq, 10)
+
+	prs := &reporter.PodResourceStatus{
+		UpdateMap: make(map[string]*corev1.Pod, pods),
+	}
+	for i := 0; i < pods; i++ {
+		pod := e.newPod(i)
+		prs.UpdateMap[pod.Namespace+"/"+pod.Name] = pod
+	}
+	body, err := json.Marshal(prs)
+	if err != nil {
+		return "", nil, err
+	}
+	msg, err := reporter.Reports{
+		{ResourceType: reporter.ResourceTypePod, Body: body},
+	}.ToClusterMessage(e.name)
+	if err != nil {
+		return "", nil, err
+	}
+	msg.Head.MessageID = id
+	data, err := msg.Serialize()
+	if err != nil {
+		return "", nil, err
+	}
+	return id, data, nil
+}
+
+func (e *edge) newPod(i int) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            fmt.Sprintf("pod-%d", i),
+			Namespace:       "default",
+			ResourceVersion: strconv.FormatInt(e.version, 10),
+			Labels: map[string]string{
+				reporter.ClusterLabel:     e.name,
+				reporter.EdgeVersionLabel: strconv.FormatInt(e.version, 10),
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: e.name + "-node",
+			Containers: []corev1.Container{
+				{Name: "app", Image: "nginx"},
+			},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+		},
+	}
+}
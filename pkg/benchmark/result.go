@@ -0,0 +1,135 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package benchmark
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Result is the result of a benchmark.
+type Result struct {
+	// Sent is the number of reports sent by edges.
+	Sent int64
+	// Received is the number of reports processed by controller manager.
+	Received int64
+	// Failed is the number of reports failed to send.
+	Failed int64
+	// Errors is the number of reports received but failed to process.
+	Errors int64
+	// Duration is the time from the first report sent to the last processed.
+	Duration time.Duration
+	// Throughput is the number of reports processed per second.
+	Throughput float64
+
+	LatencyMean time.Duration
+	LatencyP50  time.Duration
+	LatencyP90  time.Duration
+	LatencyP99  time.Duration
+	LatencyMax  time.Duration
+}
+
+// Print writes the result to w in a human readable format.
+func (r *Result) Print(w io.Writer) {
+	fmt.Fprintf(w, "reports:    %d sent, %d received, %d failed, %d errors, %d lost\n",
+		r.Sent, r.Received, r.Failed, r.Errors, r.Lost())
+	fmt.Fprintf(w, "duration:   %v\n", r.Duration.Round(time.Millisecond))
+	fmt.Fprintf(w, "throughput: %.1f reports/s\n", r.Throughput)
+	fmt.Fprintf(w, "latency:    mean %v, p50 %v, p90 %v, p99 %v, max %v\n",
+		r.LatencyMean, r.LatencyP50, r.LatencyP90, r.LatencyP99, r.LatencyMax)
+}
+
+// Lost returns the number of reports sent but not received.
+func (r *Result) Lost() int64 {
+	lost := r.Sent - r.Failed - r.Received
+	if lost < 0 {
+		return 0
+	}
+	return lost
+}
+
+// stats collects latencies of reports processed.
+type stats struct {
+	sync.Mutex
+	latencies []time.Duration
+	errors    int64
+}
+
+func newStats() *stats {
+	return &stats{}
+}
+
+func (s *stats) add(latency time.Duration, err error) {
+	s.Lock()
+	defer s.Unlock()
+	s.latencies = append(s.latencies, latency)
+	if err != nil {
+		s.errors++
+	}
+}
+
+func (s *stats) count() int64 {
+	s.Lock()
+	defer s.Unlock()
+	return int64(len(s.latencies))
+}
+
+// result computes the result by the reports sent and failed to send.
+func (s *stats) result(sent, sendFailed int64, duration time.Duration) *Result {
+	s.Lock()
+	defer s.Unlock()
+
+	r := &Result{
+		Sent:     sent,
+		Received: int64(len(s.latencies)),
+		Failed:   sendFailed,
+		Errors:   s.errors,
+		Duration: duration,
+	}
+	if duration > 0 {
+		r.Throughput = float64(r.Received) / duration.Seconds()
+	}
+	if len(s.latencies) == 0 {
+		return r
+	}
+
+	sorted := make([]time.Duration, len(s.latencies))
+	copy(sorted, s.latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var sum time.Duration
+	for _, l := range sorted {
+		sum += l
+	}
+	r.LatencyMean = sum / time.Duration(len(sorted))
+	r.LatencyP50 = percentile(sorted, 50)
+	r.LatencyP90 = percentile(sorted, 90)
+	r.LatencyP99 = percentile(sorted, 99)
+	r.LatencyMax = sorted[len(sorted)-1]
+	return r
+}
+
+// percentile returns the p-th percentile of sorted latencies by nearest rank.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (len(sorted)*p + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package capability describes the commands, shim destinations, protocol versions and
+// feature gates supported by a cluster controller, which are sent to its parent on connect
+// and saved in the Cluster crd by root, so that root adapts fan-outs to clusters of a
+// heterogeneous fleet.
+package capability
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/config"
+	"github.com/baidu/ote-stack/pkg/reporter"
+)
+
+var (
+	lock         sync.RWMutex
+	commands     []string
+	destinations []string
+)
+
+// Set sets the commands handled from the parent and the destinations of the cluster shim.
+func Set(cmds []clustermessage.CommandType, dests []string) {
+	names := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		names[i] = cmd.String()
+	}
+	sorted := append([]string(nil), dests...)
+	sort.Strings(sorted)
+
+	lock.Lock()
+	defer lock.Unlock()
+	commands = names
+	destinations = sorted
+}
+
+// Local returns the capabilities of this cluster controller.
+func Local() *otev1.ClusterCapabilities {
+	lock.RLock()
+	defer lock.RUnlock()
+	return &otev1.ClusterCapabilities{
+		Commands:        append([]string(nil), commands...),
+		Destinations:    append([]string(nil), destinations...),
+		Protocol:        config.ProtocolVersion,
+		MinProtocol:     config.MinProtocolVersion,
+		ReportEncodings: strings.Split(reporter.Encodings(), ","),
+		FeatureGates:    config.FeatureGates(),
+	}
+}
+
+// Header returns the local capabilities in json for header ClusterConnectHeaderCapabilities,
+// empty if feature gate CapabilityReport is disabled.
+func Header() string {
+	if !config.FeatureEnabled(config.FeatureCapabilityReport) {
+		return ""
+	}
+	data, err := json.Marshal(Local())
+	if err != nil {
+		klog.Errorf("marshal capabilities failed: %v", err)
+		return ""
+	}
+	return string(data)
+}
+
+// Parse returns the capabilities in header, nil if the header is empty or invalid.
+func Parse(header string) *otev1.ClusterCapabilities {
+	if header == "" {
+		return nil
+	}
+	c := &otev1.ClusterCapabilities{}
+	if err := json.Unmarshal([]byte(header), c); err != nil {
+		klog.Warningf("invalid capabilities %s: %v", header, err)
+		return nil
+	}
+	return c
+}
+
+// Handler is the http handler to get the local capabilities in json.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	data, _ := json.Marshal(Local())
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
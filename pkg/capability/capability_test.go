@@ -0,0 +1,62 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package capability
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/config"
+)
+
+func TestLocal(t *testing.T) {
+	Set([]clustermessage.CommandType{clustermessage.CommandType_ControlReq}, []string{"exec", "api"})
+	c := Local()
+	assert.Equal(t, []string{"ControlReq"}, c.Commands)
+	assert.Equal(t, []string{"api", "exec"}, c.Destinations)
+	assert.Equal(t, config.ProtocolVersion, c.Protocol)
+	assert.Equal(t, config.MinProtocolVersion, c.MinProtocol)
+	assert.Contains(t, c.ReportEncodings, "json")
+	assert.True(t, c.FeatureGates[config.FeatureCapabilityReport])
+
+	// copies are returned.
+	c.Destinations[0] = "helm"
+	assert.Equal(t, []string{"api", "exec"}, Local().Destinations)
+
+	w := httptest.NewRecorder()
+	Handler(w, httptest.NewRequest(http.MethodGet, "/capabilities", nil))
+	got := &otev1.ClusterCapabilities{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), got))
+	assert.Equal(t, Local(), got)
+}
+
+func TestHeader(t *testing.T) {
+	Set(nil, []string{"api"})
+	assert.Equal(t, Local(), Parse(Header()))
+	assert.Nil(t, Parse(""))
+	assert.Nil(t, Parse("{"))
+
+	assert.Nil(t, config.SetFeatureGates(map[string]string{config.FeatureCapabilityReport: "false"}))
+	defer config.SetFeatureGates(map[string]string{config.FeatureCapabilityReport: "true"})
+	assert.Equal(t, "", Header())
+}
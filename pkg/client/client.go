@@ -0,0 +1,284 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package client is the client of ote-stack for go programs, which submits tasks to clusters
+// selected through root, streams results of the tasks by cluster, and subscribes to events of clusters.
+// Tasks are ClusterController crds in the k8s apiserver of root, which are dispatched by
+// root clustercontroller, so that callers do not depend on the messages of the tunnels.
+package client
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clusterselector"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned"
+	"github.com/baidu/ote-stack/pkg/k8sclient"
+)
+
+const (
+	// DefaultPollInterval is the interval to check results of tasks by default.
+	DefaultPollInterval = time.Second
+
+	taskNamePrefix = "client-"
+)
+
+// Task is an operation sent to clusters selected by Selector.
+type Task struct {
+	// Name is the name of the task, generated if empty.
+	Name string
+	// Selector selects clusters in rules of cluster selector, e.g., "c1,beijing-.*".
+	Selector string
+	// Destination is where the task is done in cluster, e.g., api or helm.
+	Destination string
+	Method      string
+	URI         string
+	Body        string
+	// IdempotencyKey is set to skip clusters which have done a task of the same key.
+	IdempotencyKey string
+}
+
+// TaskRef refers to a task submitted.
+type TaskRef struct {
+	Name string
+	// Clusters are online clusters selected when the task is submitted, sorted by name.
+	// Results of the task are done when all of them responded.
+	Clusters []string
+}
+
+// Result is the result of a task in a cluster.
+type Result struct {
+	Cluster    string
+	StatusCode int
+	Body       string
+	// Reason is set if the result is not responded by the cluster, e.g., TimedOut.
+	Reason    string
+	Timestamp time.Time
+}
+
+// Succeeded returns true if the task succeeded in the cluster.
+func (r *Result) Succeeded() bool {
+	return r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// Client submits tasks to clusters and gets their results.
+type Client struct {
+	oteClient oteclient.Interface
+	// PollInterval is the interval to check results of tasks.
+	PollInterval time.Duration
+}
+
+// New returns a client connecting to the k8s apiserver of root by kubeConfig.
+func New(kubeConfig string) (*Client, error) {
+	oteClient, err := k8sclient.NewClient(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	return NewForClientset(oteClient), nil
+}
+
+// NewForClientset returns a client using oteClient.
+func NewForClientset(oteClient oteclient.Interface) *Client {
+	return &Client{
+		oteClient:    oteClient,
+		PollInterval: DefaultPollInterval,
+	}
+}
+
+// Submit submits task to the clusters selected.
+func (c *Client) Submit(task *Task) (*TaskRef, error) {
+	if task.Selector == "" {
+		return nil, fmt.Errorf("selector of task is required")
+	}
+	if task.Destination == "" {
+		return nil, fmt.Errorf("destination of task is required")
+	}
+	clusters, err := c.selectClusters(task.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	name := task.Name
+	if name == "" {
+		name = fmt.Sprintf("%s%d", taskNamePrefix, time.Now().UnixNano())
+	}
+	cc := &otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: otev1.ClusterNamespace,
+		},
+		Spec: otev1.ClusterControllerSpec{
+			ClusterSelector: task.Selector,
+			Destination:     task.Destination,
+			Method:          task.Method,
+			URL:             task.URI,
+			Body:            task.Body,
+			IdempotencyKey:  task.IdempotencyKey,
+		},
+	}
+	if _, err := c.oteClient.OteV1().ClusterControllers(otev1.ClusterNamespace).Create(cc); err != nil {
+		return nil, fmt.Errorf("create task %s failed: %v", name, err)
+	}
+	return &TaskRef{Name: name, Clusters: clusters}, nil
+}
+
+// selectClusters returns names of online clusters matched by selector, sorted by name.
+func (c *Client) selectClusters(selector string) ([]string, error) {
+	list, err := c.oteClient.OteV1().Clusters(otev1.ClusterNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list clusters failed: %v", err)
+	}
+	s := clusterselector.NewSelector(selector)
+	var ret []string
+	for _, cluster := range list.Items {
+		if cluster.Status.Status == otev1.ClusterStatusOnline && s.Has(cluster.Name) {
+			ret = append(ret, cluster.Name)
+		}
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+/*
+Results streams results of task by cluster as they arrive.
+A result of a cluster is sent again if it is replaced, e.g., the cluster responds after TimedOut.
+The channel is closed once all clusters of task have results, timeout passed,
+stop is closed or the task is deleted.
+*/
+func (c *Client) Results(task *TaskRef, timeout time.Duration, stop <-chan struct{}) <-chan Result {
+	ch := make(chan Result)
+	go func() {
+		defer close(ch)
+		ccClient := c.oteClient.OteV1().ClusterControllers(otev1.ClusterNamespace)
+		sent := make(map[string]otev1.ClusterControllerStatus)
+		interval := c.PollInterval
+		if interval <= 0 {
+			interval = DefaultPollInterval
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		deadline := time.After(timeout)
+		for {
+			cc, err := ccClient.Get(task.Name, metav1.GetOptions{})
+			if errors.IsNotFound(err) {
+				klog.Errorf("task %s is deleted", task.Name)
+				return
+			}
+			if err != nil {
+				klog.Errorf("get task %s failed: %v", task.Name, err)
+			} else {
+				for _, r := range newResults(cc.Status, sent) {
+					select {
+					case ch <- r:
+					case <-stop:
+						return
+					}
+				}
+				if done(sent, task.Clusters) {
+					return
+				}
+			}
+
+			select {
+			case <-ticker.C:
+			case <-deadline:
+				return
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// newResults returns results in status not in sent by cluster name, and records them to sent.
+func newResults(status, sent map[string]otev1.ClusterControllerStatus) []Result {
+	var ret []Result
+	for cluster, s := range status {
+		if old, ok := sent[cluster]; ok && old == s {
+			continue
+		}
+		sent[cluster] = s
+		ret = append(ret, Result{
+			Cluster:    cluster,
+			StatusCode: s.StatusCode,
+			Body:       s.Body,
+			Reason:     s.Reason,
+			Timestamp:  time.Unix(s.Timestamp, 0),
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Cluster < ret[j].Cluster
+	})
+	return ret
+}
+
+func done(sent map[string]otev1.ClusterControllerStatus, clusters []string) bool {
+	for _, cluster := range clusters {
+		if _, ok := sent[cluster]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Wait waits for results of task until all clusters of task have results or timeout,
+// and returns the results by cluster name. An error is returned if any cluster has no result.
+func (c *Client) Wait(task *TaskRef, timeout time.Duration) (map[string]Result, error) {
+	ret := make(map[string]Result)
+	for r := range c.Results(task, timeout, nil) {
+		ret[r.Cluster] = r
+	}
+	var missing []string
+	for _, cluster := range task.Clusters {
+		if _, ok := ret[cluster]; !ok {
+			missing = append(missing, cluster)
+		}
+	}
+	if len(missing) != 0 {
+		return ret, fmt.Errorf("no result of task %s from clusters %v in %v", task.Name, missing, timeout)
+	}
+	return ret, nil
+}
+
+// Delete deletes task, results of it are deleted too.
+func (c *Client) Delete(task *TaskRef) error {
+	err := c.oteClient.OteV1().ClusterControllers(otev1.ClusterNamespace).Delete(task.Name, &metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("delete task %s failed: %v", task.Name, err)
+	}
+	return nil
+}
+
+// Run submits task, waits for its results and deletes it.
+func (c *Client) Run(task *Task, timeout time.Duration) (map[string]Result, error) {
+	ref, err := c.Submit(task)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := c.Delete(ref); err != nil {
+			klog.Error(err)
+		}
+	}()
+	return c.Wait(ref, timeout)
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned"
+	otefake "github.com/baidu/ote-stack/pkg/k8sclient/fake"
+)
+
+func newCluster(name, status string) *otev1.Cluster {
+	return &otev1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: otev1.ClusterNamespace},
+		Status:     otev1.ClusterStatus{Status: status, ParentName: "root"},
+	}
+}
+
+func newFakeClient(objs ...runtime.Object) (*Client, oteclient.Interface) {
+	oteClient := otefake.NewSimpleClientset(objs...)
+	c := NewForClientset(oteClient)
+	c.PollInterval = 10 * time.Millisecond
+	return c, oteClient
+}
+
+// respond acts as the root clustercontroller, it sets status of task.
+func respond(t *testing.T, oteClient oteclient.Interface, name string, status map[string]otev1.ClusterControllerStatus) {
+	ccClient := oteClient.OteV1().ClusterControllers(otev1.ClusterNamespace)
+	cc, err := ccClient.Get(name, metav1.GetOptions{})
+	assert.Nil(t, err)
+	cc.Status = status
+	_, err = ccClient.Update(cc)
+	assert.Nil(t, err)
+}
+
+func TestSubmit(t *testing.T) {
+	c, oteClient := newFakeClient(newCluster("c1", otev1.ClusterStatusOnline),
+		newCluster("c2", otev1.ClusterStatusOffline), newCluster("c3", otev1.ClusterStatusOnline))
+
+	_, err := c.Submit(&Task{Destination: otev1.ClusterControllerDestAPI})
+	assert.Error(t, err)
+	_, err = c.Submit(&Task{Selector: "c1"})
+	assert.Error(t, err)
+
+	ref, err := c.Submit(&Task{
+		Name:        "t1",
+		Selector:    "c.*",
+		Destination: otev1.ClusterControllerDestAPI,
+		Method:      http.MethodGet,
+		URI:         "/api/v1/pods",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "t1", ref.Name)
+	assert.Equal(t, []string{"c1", "c3"}, ref.Clusters)
+	cc, err := oteClient.OteV1().ClusterControllers(otev1.ClusterNamespace).Get("t1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "c.*", cc.Spec.ClusterSelector)
+	assert.Equal(t, "/api/v1/pods", cc.Spec.URL)
+
+	// name is generated if empty.
+	ref, err = c.Submit(&Task{Selector: "c1", Destination: otev1.ClusterControllerDestAPI})
+	assert.Nil(t, err)
+	assert.Contains(t, ref.Name, taskNamePrefix)
+
+	assert.Nil(t, c.Delete(ref))
+	assert.Nil(t, c.Delete(ref))
+}
+
+func TestResults(t *testing.T) {
+	c, oteClient := newFakeClient(newCluster("c1", otev1.ClusterStatusOnline),
+		newCluster("c2", otev1.ClusterStatusOnline))
+	ref, err := c.Submit(&Task{Name: "t1", Selector: "c1,c2", Destination: otev1.ClusterControllerDestAPI})
+	assert.Nil(t, err)
+
+	results := c.Results(ref, time.Second, nil)
+	respond(t, oteClient, "t1", map[string]otev1.ClusterControllerStatus{
+		"c1": {StatusCode: http.StatusOK, Body: "ok", Timestamp: 1},
+	})
+	r := <-results
+	assert.Equal(t, "c1", r.Cluster)
+	assert.True(t, r.Succeeded())
+	assert.Equal(t, "ok", r.Body)
+
+	respond(t, oteClient, "t1", map[string]otev1.ClusterControllerStatus{
+		"c1": {StatusCode: http.StatusOK, Body: "ok", Timestamp: 1},
+		"c2": {StatusCode: http.StatusNotFound, Body: "not found", Timestamp: 2},
+	})
+	r = <-results
+	assert.Equal(t, "c2", r.Cluster)
+	assert.False(t, r.Succeeded())
+	// closed after all clusters have results.
+	_, ok := <-results
+	assert.False(t, ok)
+}
+
+func TestResultsStop(t *testing.T) {
+	c, _ := newFakeClient(newCluster("c1", otev1.ClusterStatusOnline))
+	ref, err := c.Submit(&Task{Name: "t1", Selector: "c1", Destination: otev1.ClusterControllerDestAPI})
+	assert.Nil(t, err)
+
+	stop := make(chan struct{})
+	results := c.Results(ref, time.Minute, stop)
+	close(stop)
+	_, ok := <-results
+	assert.False(t, ok)
+
+	// closed if the task is deleted.
+	results = c.Results(ref, time.Minute, nil)
+	assert.Nil(t, c.Delete(ref))
+	_, ok = <-results
+	assert.False(t, ok)
+}
+
+func TestRun(t *testing.T) {
+	c, oteClient := newFakeClient(newCluster("c1", otev1.ClusterStatusOnline),
+		newCluster("c2", otev1.ClusterStatusOnline))
+	go func() {
+		for i := 0; i < 100; i++ {
+			list, err := oteClient.OteV1().ClusterControllers(otev1.ClusterNamespace).List(metav1.ListOptions{})
+			assert.Nil(t, err)
+			if len(list.Items) != 0 {
+				respond(t, oteClient, list.Items[0].Name, map[string]otev1.ClusterControllerStatus{
+					"c1": {StatusCode: http.StatusOK, Body: "ok"},
+				})
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	ret, err := c.Run(&Task{Selector: "c1,c2", Destination: otev1.ClusterControllerDestAPI},
+		200*time.Millisecond)
+	assert.Error(t, err)
+	assert.Len(t, ret, 1)
+	assert.Equal(t, http.StatusOK, ret["c1"].StatusCode)
+
+	// task is deleted after run.
+	list, err := oteClient.OteV1().ClusterControllers(otev1.ClusterNamespace).List(metav1.ListOptions{})
+	assert.Nil(t, err)
+	assert.Empty(t, list.Items)
+}
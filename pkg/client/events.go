@@ -0,0 +1,145 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	oteinformer "github.com/baidu/ote-stack/pkg/generated/informers/externalversions"
+)
+
+// EventType is the type of an event of a cluster.
+type EventType string
+
+const (
+	// EventAdded is sent when a cluster is registered, or is listed when subscribed.
+	EventAdded EventType = "Added"
+	// EventOnline is sent when a cluster turns online.
+	EventOnline EventType = "Online"
+	// EventOffline is sent when a cluster turns offline.
+	EventOffline EventType = "Offline"
+	// EventUpdated is sent when status of a cluster changes without turning online or offline.
+	EventUpdated EventType = "Updated"
+	// EventDeleted is sent when a cluster is unregistered.
+	EventDeleted EventType = "Deleted"
+)
+
+// ClusterEventBufferSize is the number of events buffered for a subscriber.
+var ClusterEventBufferSize = 100
+
+// Cluster is a cluster of ote-stack.
+type Cluster struct {
+	Name   string
+	Parent string
+	// Status is online or offline.
+	Status string
+	// Version is the clustercontroller version of the cluster.
+	Version string
+	// Timestamp is the time the status of cluster is reported.
+	Timestamp time.Time
+}
+
+// ClusterEvent is an event of a cluster.
+type ClusterEvent struct {
+	Type    EventType
+	Cluster Cluster
+}
+
+func toCluster(c *otev1.Cluster) Cluster {
+	return Cluster{
+		Name:      c.Name,
+		Parent:    c.Status.ParentName,
+		Status:    c.Status.Status,
+		Version:   c.Status.Version,
+		Timestamp: time.Unix(c.Status.Timestamp, 0),
+	}
+}
+
+// Clusters returns all clusters.
+func (c *Client) Clusters() ([]Cluster, error) {
+	list, err := c.oteClient.OteV1().Clusters(otev1.ClusterNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]Cluster, len(list.Items))
+	for i := range list.Items {
+		ret[i] = toCluster(&list.Items[i])
+	}
+	return ret, nil
+}
+
+/*
+Subscribe returns the channel of events of clusters until stop is closed.
+Existing clusters are sent as EventAdded first.
+Events are held until they are received, the channel is not closed after stop.
+*/
+func (c *Client) Subscribe(stop <-chan struct{}) <-chan ClusterEvent {
+	ch := make(chan ClusterEvent, ClusterEventBufferSize)
+	send := func(t EventType, obj interface{}) {
+		cluster, ok := obj.(*otev1.Cluster)
+		if !ok {
+			tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+			if !ok {
+				return
+			}
+			if cluster, ok = tombstone.Obj.(*otev1.Cluster); !ok {
+				return
+			}
+		}
+		select {
+		case ch <- ClusterEvent{Type: t, Cluster: toCluster(cluster)}:
+		case <-stop:
+		}
+	}
+
+	factory := oteinformer.NewSharedInformerFactoryWithOptions(c.oteClient, 0,
+		oteinformer.WithNamespace(otev1.ClusterNamespace))
+	factory.Ote().V1().Clusters().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			send(EventAdded, obj)
+		},
+		UpdateFunc: func(old, new interface{}) {
+			oldCluster, newCluster := old.(*otev1.Cluster), new.(*otev1.Cluster)
+			if oldCluster.ResourceVersion == newCluster.ResourceVersion {
+				return
+			}
+			send(updateEventType(oldCluster, newCluster), new)
+		},
+		DeleteFunc: func(obj interface{}) {
+			send(EventDeleted, obj)
+		},
+	})
+	factory.Start(stop)
+	return ch
+}
+
+func updateEventType(old, new *otev1.Cluster) EventType {
+	if old.Status.Status == new.Status.Status {
+		return EventUpdated
+	}
+	switch new.Status.Status {
+	case otev1.ClusterStatusOnline:
+		return EventOnline
+	case otev1.ClusterStatusOffline:
+		return EventOffline
+	}
+	return EventUpdated
+}
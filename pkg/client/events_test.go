@@ -0,0 +1,82 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+)
+
+func TestClusters(t *testing.T) {
+	c, _ := newFakeClient(newCluster("c1", otev1.ClusterStatusOnline))
+	clusters, err := c.Clusters()
+	assert.Nil(t, err)
+	assert.Equal(t, []Cluster{{Name: "c1", Parent: "root", Status: otev1.ClusterStatusOnline, Timestamp: time.Unix(0, 0)}},
+		clusters)
+}
+
+func TestSubscribe(t *testing.T) {
+	c, oteClient := newFakeClient(newCluster("c1", otev1.ClusterStatusOnline))
+	stop := make(chan struct{})
+	defer close(stop)
+	events := c.Subscribe(stop)
+	next := func() ClusterEvent {
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(time.Second):
+			t.Fatalf("no event received")
+		}
+		return ClusterEvent{}
+	}
+
+	e := next()
+	assert.Equal(t, EventAdded, e.Type)
+	assert.Equal(t, "c1", e.Cluster.Name)
+
+	clusterClient := oteClient.OteV1().Clusters(otev1.ClusterNamespace)
+	cluster, err := clusterClient.Get("c1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	cluster.Status.Status = otev1.ClusterStatusOffline
+	cluster.ResourceVersion = "2"
+	_, err = clusterClient.Update(cluster)
+	assert.Nil(t, err)
+	e = next()
+	assert.Equal(t, EventOffline, e.Type)
+
+	cluster.Status.Status = otev1.ClusterStatusOnline
+	cluster.ResourceVersion = "3"
+	_, err = clusterClient.Update(cluster)
+	assert.Nil(t, err)
+	assert.Equal(t, EventOnline, next().Type)
+
+	cluster.Status.Timestamp = 10
+	cluster.ResourceVersion = "4"
+	_, err = clusterClient.Update(cluster)
+	assert.Nil(t, err)
+	e = next()
+	assert.Equal(t, EventUpdated, e.Type)
+	assert.Equal(t, time.Unix(10, 0), e.Cluster.Timestamp)
+
+	assert.Nil(t, clusterClient.Delete("c1", &metav1.DeleteOptions{}))
+	assert.Equal(t, EventDeleted, next().Type)
+}
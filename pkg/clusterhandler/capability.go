@@ -0,0 +1,113 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterhandler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/config"
+)
+
+/*
+incapableClusters returns clusters of which the shim does not support destination,
+by capabilities reported in Cluster crds.
+Clusters with capabilities unknown, e.g., of old versions or not reported yet,
+are taken as capable, so that tasks to them are dispatched as before.
+*/
+func (c *clusterHandler) incapableClusters(destination string, clusters []string) []string {
+	if destination == "" || c.clusterLister == nil || !config.FeatureEnabled(config.FeatureCapabilityGatedDispatch) {
+		return nil
+	}
+	var incapable []string
+	for _, name := range clusters {
+		cluster, err := c.clusterLister.Clusters(otev1.ClusterNamespace).Get(name)
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				klog.Errorf("get cluster %s failed: %v", name, err)
+			}
+			continue
+		}
+		if !supportsDestination(cluster.Status.Capabilities, destination) {
+			incapable = append(incapable, name)
+		}
+	}
+	return incapable
+}
+
+// supportsDestination returns false only if capabilities known lack destination.
+func supportsDestination(capabilities *otev1.ClusterCapabilities, destination string) bool {
+	if capabilities == nil || len(capabilities.Destinations) == 0 {
+		return true
+	}
+	for _, d := range capabilities.Destinations {
+		if d == destination {
+			return true
+		}
+	}
+	return false
+}
+
+// skipIncapable records status Skipped of clusters not capable of cc, and returns the capable ones.
+func (c *clusterHandler) skipIncapable(cc *otev1.ClusterController, clusters []string) []string {
+	incapable := c.incapableClusters(cc.Spec.Destination, clusters)
+	if len(incapable) == 0 {
+		return clusters
+	}
+	klog.Infof("clustercontroller %s skips clusters not supporting destination %s: %v",
+		cc.ObjectMeta.Name, cc.Spec.Destination, incapable)
+	c.mergeStatusToApiserver(incapableClusterController(cc.ObjectMeta.Name, incapable, cc.Spec.Destination))
+	skipped := make(map[string]bool, len(incapable))
+	for _, cluster := range incapable {
+		skipped[cluster] = true
+	}
+	var capable []string
+	for _, cluster := range clusters {
+		if !skipped[cluster] {
+			capable = append(capable, cluster)
+		}
+	}
+	return capable
+}
+
+// incapableClusterController returns the ClusterController with status Skipped of clusters
+// not supporting destination.
+func incapableClusterController(name string, clusters []string, destination string) *otev1.ClusterController {
+	cc := &otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: otev1.ClusterNamespace,
+		},
+		Status: make(map[string]otev1.ClusterControllerStatus, len(clusters)),
+	}
+	now := time.Now().Unix()
+	for _, cluster := range clusters {
+		cc.Status[cluster] = otev1.ClusterControllerStatus{
+			Timestamp:  now,
+			StatusCode: http.StatusPreconditionFailed,
+			Body:       fmt.Sprintf("destination %s is not supported by the cluster shim", destination),
+			Reason:     otev1.ClusterControllerStatusSkipped,
+		}
+	}
+	return cc
+}
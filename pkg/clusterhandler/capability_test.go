@@ -0,0 +1,77 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterhandler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/config"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned/fake"
+	otelisters "github.com/baidu/ote-stack/pkg/generated/listers/ote/v1"
+	"github.com/baidu/ote-stack/pkg/k8sclient"
+)
+
+func newCapabilityCluster(name string, capabilities *otev1.ClusterCapabilities) *otev1.Cluster {
+	return &otev1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: otev1.ClusterNamespace},
+		Status:     otev1.ClusterStatus{Capabilities: capabilities},
+	}
+}
+
+func TestSkipIncapable(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	indexer.Add(newCapabilityCluster("c1", &otev1.ClusterCapabilities{Destinations: []string{"api", "exec"}}))
+	indexer.Add(newCapabilityCluster("c2", &otev1.ClusterCapabilities{Destinations: []string{"api"}}))
+	// capabilities of c3 are unknown, c4 is not registed.
+	indexer.Add(newCapabilityCluster("c3", nil))
+	cc := &otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{Name: "cc1", Namespace: otev1.ClusterNamespace},
+		Spec:       otev1.ClusterControllerSpec{Destination: "exec"},
+	}
+	client := oteclient.NewSimpleClientset(cc)
+	c := &clusterHandler{
+		clusterControllerCRD: k8sclient.NewClusterControllerCRD(client),
+		clusterLister:        otelisters.NewClusterLister(indexer),
+	}
+
+	capable := c.skipIncapable(cc, []string{"c1", "c2", "c3", "c4"})
+	assert.Equal(t, []string{"c1", "c3", "c4"}, capable)
+	cc, err := client.OteV1().ClusterControllers(otev1.ClusterNamespace).Get("cc1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, cc.Status, 1)
+	assert.Equal(t, http.StatusPreconditionFailed, cc.Status["c2"].StatusCode)
+	assert.Equal(t, otev1.ClusterControllerStatusSkipped, cc.Status["c2"].Reason)
+	assert.Contains(t, cc.Status["c2"].Body, "exec")
+
+	// no cluster is skipped if the feature gate is disabled.
+	assert.Nil(t, config.SetFeatureGates(map[string]string{config.FeatureCapabilityGatedDispatch: "false"}))
+	defer config.SetFeatureGates(map[string]string{config.FeatureCapabilityGatedDispatch: "true"})
+	assert.Equal(t, []string{"c1", "c2"}, c.skipIncapable(cc, []string{"c1", "c2"}))
+}
+
+func TestSupportsDestination(t *testing.T) {
+	assert.True(t, supportsDestination(nil, "api"))
+	assert.True(t, supportsDestination(&otev1.ClusterCapabilities{}, "api"))
+	assert.True(t, supportsDestination(&otev1.ClusterCapabilities{Destinations: []string{"api"}}, "api"))
+	assert.False(t, supportsDestination(&otev1.ClusterCapabilities{Destinations: []string{"api"}}, "exec"))
+}
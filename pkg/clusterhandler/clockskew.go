@@ -0,0 +1,97 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterhandler
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/config"
+)
+
+// DefaultClockSkewThreshold is the clock skew of clusters from root to set condition ClockSkewed by default.
+const DefaultClockSkewThreshold = 30 * time.Second
+
+// setClockSkewCondition sets condition ClockSkewed of status by its clock skew,
+// nothing is set if threshold is 0.
+func setClockSkewCondition(name string, status *otev1.ClusterStatus, threshold time.Duration) {
+	if threshold <= 0 {
+		return
+	}
+	skew := time.Duration(status.ClockSkew) * time.Millisecond
+	cond := otev1.ClusterCondition{
+		Type:               otev1.ClusterConditionClockSkewed,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: time.Now().Unix(),
+		Reason:             "ClockSynced",
+	}
+	if skew >= threshold || -skew >= threshold {
+		cond.Status = corev1.ConditionTrue
+		cond.Reason = "ClockSkewed"
+		if skew > 0 {
+			cond.Message = fmt.Sprintf("clock is %v ahead of root", skew)
+		} else {
+			cond.Message = fmt.Sprintf("clock is %v behind root", -skew)
+		}
+		klog.Warningf("cluster %s %s", name, cond.Message)
+	}
+	status.SetCondition(cond)
+}
+
+// registryToParent returns msg of cr from a child, with the clock skew and time of cr
+// relative to the parent of this cluster, so they are relative to root when transmitted to root.
+func registryToParent(cr *config.ClusterRegistry, msg *clustermessage.ClusterMessage) *clustermessage.ClusterMessage {
+	skew := config.ParentClockSkew()
+	if cr == nil || skew == 0 {
+		return msg
+	}
+	parentCr := *cr
+	parentCr.ClockSkew += skew
+	parentCr.Time -= skew / 1000
+	body, err := parentCr.Serialize()
+	if err != nil {
+		klog.Errorf("serialize clusterregistry(%v) failed: %v", parentCr, err)
+		return msg
+	}
+	m := *msg
+	m.Body = body
+	return &m
+}
+
+/*
+unregistHybridTime returns the hybrid time of unregist of a cluster, which is after both
+the status stored and the unregist, since the parent may not see the latest reports of the cluster.
+nil if both are nil, so that clusters not reporting hybrid times are ordered by timestamps.
+*/
+func unregistHybridTime(stored, unregist *otev1.HybridTime) *otev1.HybridTime {
+	if stored == nil && unregist == nil {
+		return nil
+	}
+	var ht otev1.HybridTime
+	if stored != nil {
+		ht = config.HybridUpdate(*stored)
+	}
+	if unregist != nil {
+		ht = config.HybridUpdate(*unregist)
+	}
+	return &ht
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterhandler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/config"
+)
+
+func TestSetClockSkewCondition(t *testing.T) {
+	status := &otev1.ClusterStatus{ClockSkew: 1000}
+	setClockSkewCondition("c1", status, 0)
+	assert.Empty(t, status.Conditions)
+
+	setClockSkewCondition("c1", status, time.Minute)
+	assert.Len(t, status.Conditions, 1)
+	cond := status.GetCondition(otev1.ClusterConditionClockSkewed)
+	assert.Equal(t, corev1.ConditionFalse, cond.Status)
+	assert.Equal(t, "ClockSynced", cond.Reason)
+
+	// transition time is kept if status is not changed.
+	cond.LastTransitionTime = 1
+	setClockSkewCondition("c1", status, time.Minute)
+	assert.Equal(t, int64(1), status.GetCondition(otev1.ClusterConditionClockSkewed).LastTransitionTime)
+
+	status.ClockSkew = 90000
+	setClockSkewCondition("c1", status, time.Minute)
+	assert.Len(t, status.Conditions, 1)
+	cond = status.GetCondition(otev1.ClusterConditionClockSkewed)
+	assert.Equal(t, corev1.ConditionTrue, cond.Status)
+	assert.Equal(t, "ClockSkewed", cond.Reason)
+	assert.Equal(t, "clock is 1m30s ahead of root", cond.Message)
+	assert.NotEqual(t, int64(1), cond.LastTransitionTime)
+}
+
+func TestRegistryToParent(t *testing.T) {
+	cr := &config.ClusterRegistry{Name: "c2", Time: 1000, ClockSkew: 3000}
+	body, err := cr.Serialize()
+	assert.Nil(t, err)
+	msg := &clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{ClusterName: "c2"},
+		Body: body,
+	}
+
+	// message is not changed if this cluster is synced with parent.
+	assert.Equal(t, msg, registryToParent(cr, msg))
+
+	config.SetParentClockSkew(-5000)
+	defer config.SetParentClockSkew(0)
+	m := registryToParent(cr, msg)
+	assert.Equal(t, msg.Head, m.Head)
+	parentCr, err := config.ClusterRegistryDeserialize(m.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(-2000), parentCr.ClockSkew)
+	assert.Equal(t, int64(1005), parentCr.Time)
+	// origin message is not changed.
+	assert.Equal(t, body, msg.Body)
+}
+
+func TestUnregistHybridTime(t *testing.T) {
+	assert.Nil(t, unregistHybridTime(nil, nil))
+
+	stored := &otev1.HybridTime{WallTime: config.UnixMilli(time.Now()) + 60000}
+	unregist := &otev1.HybridTime{WallTime: 1000}
+	ht := unregistHybridTime(stored, unregist)
+	assert.True(t, stored.Before(*ht))
+	assert.True(t, unregist.Before(*ht))
+
+	ht = unregistHybridTime(nil, unregist)
+	assert.True(t, unregist.Before(*ht))
+}
@@ -21,21 +21,30 @@ package clusterhandler
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/golang/protobuf/proto"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appslisters "k8s.io/client-go/listers/apps/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog"
 
 	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/archive"
+	"github.com/baidu/ote-stack/pkg/bandwidth"
 	"github.com/baidu/ote-stack/pkg/clustermessage"
 	"github.com/baidu/ote-stack/pkg/clusterrouter"
 	"github.com/baidu/ote-stack/pkg/clusterselector"
 	"github.com/baidu/ote-stack/pkg/config"
+	"github.com/baidu/ote-stack/pkg/deadletter"
 	oteinformer "github.com/baidu/ote-stack/pkg/generated/informers/externalversions"
+	otelisters "github.com/baidu/ote-stack/pkg/generated/listers/ote/v1"
 	"github.com/baidu/ote-stack/pkg/k8sclient"
+	"github.com/baidu/ote-stack/pkg/reporter"
+	"github.com/baidu/ote-stack/pkg/resultstore"
+	"github.com/baidu/ote-stack/pkg/taskbuilder"
 	"github.com/baidu/ote-stack/pkg/tunnel"
 )
 
@@ -51,6 +60,10 @@ var (
 // Get one by NewClusterHandler and Start it.
 type ClusterHandler interface {
 	Start() error // nonblock
+	// Redirect asks cluster in subtree to reconnect to the parent at address.
+	Redirect(cluster, address string) error
+	// Drain moves childs to the alternate parent and stops listening in timeout.
+	Drain(alternate string, timeout time.Duration) error
 }
 
 type clusterHandler struct {
@@ -58,17 +71,43 @@ type clusterHandler struct {
 	tunn                 tunnel.CloudTunnel
 	clusterCRD           *k8sclient.ClusterCRD
 	clusterControllerCRD *k8sclient.ClusterControllerCRD
-	k8sEnable            bool
+	// clusterControllerIndexer indexes ClusterController crds watched by idempotency key
+	clusterControllerIndexer cache.Indexer
+	// clusterLister lists Cluster crds watched, to skip clusters not capable of tasks
+	clusterLister otelisters.ClusterLister
+	// clusterQuotaLister lists ClusterQuota crds watched, to skip clusters over quota
+	clusterQuotaLister otelisters.ClusterQuotaLister
+	// deploymentLister and daemonSetLister list workloads mirrored to center,
+	// to charge tasks updating them only the increase against ClusterQuotas
+	deploymentLister appslisters.DeploymentLister
+	daemonSetLister  appslisters.DaemonSetLister
+	k8sEnable        bool
 	// msg from clusters back to controller manager
 	backToControllerManagerChan chan clustermessage.ClusterMessage
 	// msg from controller manager to publish to clusters
 	controllerManagerPublishChan chan clustermessage.ClusterMessage
+	// tracker tracks tasks dispatched by root, nil if task timeout is disabled
+	tracker *taskTracker
+	// frontends assigns childs to root frontends, nil if there is only one frontend
+	frontends *tunnel.HashRing
+	// rollouts are tasks dispatched by root in waves, nil if this is not root
+	rollouts *rollouts
+	// router is the route of subtree and neighbor, the default router if nil
+	router *clusterrouter.ClusterRouter
 }
 
 // NewClusterHandler news a ClusterHandler by ClusterControllerConfig.
 func NewClusterHandler(c *config.ClusterControllerConfig) (ClusterHandler, error) {
+	return NewClusterHandlerWithRouter(c, clusterrouter.Router())
+}
+
+// NewClusterHandlerWithRouter news a ClusterHandler by ClusterControllerConfig,
+// which keeps the route of its subtree in router instead of the default one.
+func NewClusterHandlerWithRouter(c *config.ClusterControllerConfig,
+	router *clusterrouter.ClusterRouter) (ClusterHandler, error) {
 	ch := &clusterHandler{
 		conf:      c,
+		router:    router,
 		k8sEnable: false,
 		backToControllerManagerChan: make(chan clustermessage.ClusterMessage,
 			controllerManagerChanBufferSize),
@@ -78,6 +117,16 @@ func NewClusterHandler(c *config.ClusterControllerConfig) (ClusterHandler, error
 	if err := ch.valid(); err != nil {
 		return nil, err
 	}
+	if ch.isRoot() && c.TaskTimeout > 0 {
+		ch.tracker = newTaskTracker(c.TaskTimeout)
+	}
+	if ch.isRoot() {
+		ch.rollouts = newRollouts()
+	}
+	err := tunnel.SetRateLimit(tunnel.RateLimitConfig{Limit: c.ChildRateLimit, Burst: c.ChildRateBurst})
+	if err != nil {
+		return nil, err
+	}
 	tunn := tunnel.NewCloudTunnel(c.TunnelListenAddr)
 	if tunn == nil {
 		return nil, fmt.Errorf("tunnel is nil with no error, listen addr is " + c.TunnelListenAddr)
@@ -85,8 +134,13 @@ func NewClusterHandler(c *config.ClusterControllerConfig) (ClusterHandler, error
 	tunn.RegistRedirectFunc(func() string {
 		return c.LeaderListenAddr
 	})
+	if ch.isRoot() && len(c.TunnelFrontends) > 1 {
+		ch.frontends = tunnel.NewHashRing(c.TunnelFrontends, 0)
+		tunn.RegistAssignFunc(ch.assignFrontend)
+	}
 	tunn.RegistCheckNameValidFunc(ch.checkClusterName)
 	tunn.RegistReturnMessageFunc(ch.handleMessageFromChild)
+	deadletter.RegistRequeueFunc(deadletter.SourceChild, ch.handleMessageFromChild)
 	tunn.RegistClientCloseHandler(ch.closeChild)
 	tunn.RegistAfterConnectHook(ch.afterClusterConnect)
 	tunn.RegistControllerManagerMsgHandler(ch.controllerMsgHandler)
@@ -94,21 +148,32 @@ func NewClusterHandler(c *config.ClusterControllerConfig) (ClusterHandler, error
 	return ch, nil
 }
 
+// route returns the router of cluster handler.
+func (c *clusterHandler) route() *clusterrouter.ClusterRouter {
+	if c.router == nil {
+		return clusterrouter.Router()
+	}
+	return c.router
+}
+
 // valid check if config of cluster handler is valid, return error if it is invalid.
 // call before Start.
 func (c *clusterHandler) valid() error {
 	if c.conf.ClusterUserDefineName == "" {
 		return fmt.Errorf("cluster name of cluster controller cannot be empty, set by --cluster-name")
 	}
-	if c.conf.ParentCluster == "" && !c.isRoot() {
+	if len(c.conf.ParentCluster) == 0 && !c.isRoot() {
 		return fmt.Errorf("root cluster(no parent-cluster set) should not set cluster name")
 	}
-	if c.conf.ParentCluster != "" && c.isRoot() {
+	if len(c.conf.ParentCluster) != 0 && c.isRoot() {
 		return fmt.Errorf("no-root cluster must set cluster name(cannot be same as root cluster)")
 	}
 	if c.conf.TunnelListenAddr == "" {
 		return fmt.Errorf("listen tunn is empty, listen addr is " + c.conf.TunnelListenAddr)
 	}
+	if err := c.validFrontends(); err != nil {
+		return err
+	}
 	// if it is root, must connect to k8s
 	if c.isRoot() {
 		if c.conf.K8sClient == nil {
@@ -164,7 +229,21 @@ func (c *clusterHandler) Start() error {
 				c.addClusterController(ca)
 			},
 		})
+		informer.AddIndexers(cache.Indexers{idempotencyKeyIndex: idempotencyKeyIndexFunc})
+		c.clusterControllerIndexer = informer.GetIndexer()
+		clusterInformer := factory.Ote().V1().Clusters().Informer()
+		c.clusterLister = factory.Ote().V1().Clusters().Lister()
+		go clusterInformer.Run(stopper)
+		clusterQuotaInformer := factory.Ote().V1().ClusterQuotas().Informer()
+		c.clusterQuotaLister = factory.Ote().V1().ClusterQuotas().Lister()
+		go clusterQuotaInformer.Run(stopper)
+		if c.isRoot() && c.conf.KubeClient != nil {
+			c.runMirroredWorkloadInformers(stopper)
+		}
 		go informer.Run(stopper)
+		if c.tracker != nil {
+			go c.runTaskTracker(stopper)
+		}
 	}
 
 	// TODO if this is root, regist self to etcd
@@ -181,41 +260,116 @@ addClusterController is k8s cluster controller crd watch AddFunc.
 */
 func (c *clusterHandler) addClusterController(cc *otev1.ClusterController) {
 	// check if crd is valid to process, drop it if invalid
-	if !hasToProcessClusterController(cc) {
+	if !hasToProcessClusterController(c.ownedStatus(cc)) {
 		return
 	}
 	// add parentClusterName
 	cc.Spec.ParentClusterName = c.conf.ClusterName
+	// expand typed task to destination, method, url and body
+	if err := taskbuilder.Expand(&cc.Spec); err != nil {
+		klog.Errorf("invalid task of clustercontroller %s: %v", cc.ObjectMeta.Name, err)
+		return
+	}
+	if err := taskbuilder.ValidateFailurePolicy(&cc.Spec); err != nil {
+		klog.Errorf("invalid failure policy of clustercontroller %s: %v", cc.ObjectMeta.Name, err)
+		return
+	}
 	// transfer crd to cluster message
 	msg := clusterControllerCRDToClusterMessage(cc, clustermessage.CommandType_ControlReq)
 	if msg == nil {
 		klog.Errorf("cluster msg is nil when add a crd %v", cc)
 		return
 	}
-	// send to child
-	// directed broadcast by cluster selector
-	selectedChild := selectChild(msg)
-	for port, portMsg := range selectedChild {
-		klog.V(3).Infof("send %v to %s with selector %s", portMsg, port, portMsg.Head.ClusterSelector)
-		c.sendToChild(portMsg, port)
+	clusters := c.selectedClusters(msg.Head.ClusterSelector)
+	// do not send to clusters of which the shim does not support the destination
+	if capable := c.skipIncapable(cc, clusters); len(capable) != len(clusters) {
+		if len(capable) == 0 {
+			return
+		}
+		msg.Head.ClusterSelector = exactSelector(capable)
+		clusters = capable
+	}
+	// do not send to clusters of health scores below the min health score
+	if healthy := c.skipUnhealthy(cc, clusters); len(healthy) != len(clusters) {
+		if len(healthy) == 0 {
+			return
+		}
+		msg.Head.ClusterSelector = exactSelector(healthy)
+		clusters = healthy
 	}
+	// do not send to clusters which have done the task of the same idempotency key
+	if notDone := c.deduplicate(cc, clusters); len(notDone) != len(clusters) {
+		if len(notDone) == 0 {
+			return
+		}
+		msg.Head.ClusterSelector = exactSelector(notDone)
+		clusters = notDone
+	}
+	// dispatch the first wave only, the next is dispatched after it is done
+	if c.rollouts != nil && needRollout(&cc.Spec) {
+		wave := c.rollouts.start(cc.ObjectMeta.Name, &cc.Spec, msg, clusters)
+		if len(wave) != len(clusters) {
+			klog.Infof("dispatch clustercontroller %s in waves of %d clusters", cc.ObjectMeta.Name, cc.Spec.WaveSize)
+			msg = waveMessage(msg, wave)
+			clusters = wave
+		}
+	}
+	c.dispatch(cc.ObjectMeta.Name, msg, clusters)
 
 	// broadcast to all childs if do not use selector
 	// c.sendToChild(msg)
 }
 
-func selectChild(msg *clustermessage.ClusterMessage) map[string]*clustermessage.ClusterMessage {
-	selector := clusterselector.NewSelector(msg.Head.ClusterSelector)
-	subtreeClusters := clusterrouter.Router().SubTreeClusters()
+// dispatch sends task msg of ClusterController name to childs routing to clusters selected,
+// after overridden for each cluster, except clusters exceeding their quotas.
+func (c *clusterHandler) dispatch(name string, msg *clustermessage.ClusterMessage, clusters []string) {
+	tasks := c.admitQuota(name, c.overrideTasks(name, msg, clusters))
+	if c.tracker != nil {
+		var dispatched []string
+		for _, task := range tasks {
+			dispatched = append(dispatched, task.clusters...)
+		}
+		c.tracker.track(name, dispatched, time.Now())
+	}
+	for _, task := range tasks {
+		// send to child
+		// directed broadcast by cluster selector
+		selectedChild := c.selectChild(task.msg)
+		for port, portMsg := range selectedChild {
+			klog.V(3).Infof("send %v to %s with selector %s", portMsg, port, portMsg.Head.ClusterSelector)
+			c.sendToChild(portMsg, port)
+		}
+	}
+}
+
+// selectedClusters returns clusters in subtree matched by selector,
+// and clusters renamed from names tombstoned matched by selector.
+func (c *clusterHandler) selectedClusters(s string) []string {
+	selector := clusterselector.NewSelector(s)
+	subtreeClusters := c.route().SubTreeClusters()
 	var selectedSubTreeClusters []string
-	ret := make(map[string]*clustermessage.ClusterMessage)
+	selected := make(map[string]bool)
 	for _, subtreeCluster := range subtreeClusters {
 		if selector.Has(subtreeCluster) {
 			selectedSubTreeClusters = append(selectedSubTreeClusters, subtreeCluster)
+			selected[subtreeCluster] = true
+		}
+	}
+	// reroute messages to previous names of clusters renamed.
+	for _, renamed := range c.route().ResolveTombstones(selector.Has) {
+		if !selected[renamed] {
+			klog.V(3).Infof("reroute message of selector %s to renamed cluster %s", s, renamed)
+			selectedSubTreeClusters = append(selectedSubTreeClusters, renamed)
 		}
 	}
+	return selectedSubTreeClusters
+}
+
+func (c *clusterHandler) selectChild(msg *clustermessage.ClusterMessage) map[string]*clustermessage.ClusterMessage {
+	selectedSubTreeClusters := c.selectedClusters(msg.Head.ClusterSelector)
+	ret := make(map[string]*clustermessage.ClusterMessage)
 	// get out ports of selected subtree clusters
-	portsToSubtreeClusters := clusterrouter.Router().PortsToSubtreeClusters(&selectedSubTreeClusters)
+	portsToSubtreeClusters := c.route().PortsToSubtreeClusters(&selectedSubTreeClusters)
 	for port, subtree := range portsToSubtreeClusters {
 		portMsg := proto.Clone(msg).(*clustermessage.ClusterMessage)
 		portMsg.Head.ClusterSelector = clusterselector.ClustersToSelector(&subtree)
@@ -261,6 +415,8 @@ func (c *clusterHandler) sendToChild(msg *clustermessage.ClusterMessage, tos ...
 		go c.tunn.Broadcast(data)
 	} else {
 		for _, to := range tos {
+			bandwidth.RecordMessage(to, bandwidth.Sent, msg, len(data))
+			archive.RecordMessage(to, archive.Sent, msg)
 			go c.tunn.Send(to, data)
 		}
 	}
@@ -272,28 +428,30 @@ handleMessageFromParent handler message from parent(edge handler of this process
 func (c *clusterHandler) handleMessageFromParent() {
 	for {
 		msg := <-c.conf.EdgeToClusterChan
-		// if it is a route message from parent, update route
-		// otherwise, send to child
-		if msg.Head.Command == clustermessage.CommandType_NeighborRoute {
-			clusterrouter.UpdateRouter(&msg, c.sendToChild)
-		} else {
-			// directed broadcast by cluster selector
-			selectedChild := selectChild(&msg)
-			for port, portMsg := range selectedChild {
-				klog.V(3).Infof("send %v to %s with selector %s", portMsg, port, portMsg.Head.ClusterSelector)
-				c.sendToChild(portMsg, port)
-			}
+		c.dispatchFromParent(&msg)
+	}
+}
 
-			// broadcast to all childs if do not use selector
-			// c.sendToChild(msg)
-		}
+// dispatchFromParent updates route by msg from parent if it is a route message,
+// otherwise, sends it to childs selected.
+func (c *clusterHandler) dispatchFromParent(msg *clustermessage.ClusterMessage) {
+	defer deadletter.RecoverMessage(deadletter.SourceParent, bandwidth.ParentPeer, msg)
+	if msg.Head.Command == clustermessage.CommandType_NeighborRoute {
+		c.route().UpdateRouter(msg, c.sendToChild)
+		return
+	}
+	// directed broadcast by cluster selector
+	selectedChild := c.selectChild(msg)
+	for port, portMsg := range selectedChild {
+		klog.V(3).Infof("send %v to %s with selector %s", portMsg, port, portMsg.Head.ClusterSelector)
+		c.sendToChild(portMsg, port)
 	}
 }
 
 // isInParentPool check if the connecting client is in the parent pool,
 // because cluster does not allow its candidate parent to be it's child.
-func isInParentPool(clientName string) bool {
-	for name := range clusterrouter.Router().ParentNeighbors() {
+func (c *clusterHandler) isInParentPool(clientName string) bool {
+	for name := range c.route().ParentNeighbors() {
 		// if client is found in ParentNeighbors router, it is a candidate parent cluster.
 		if clientName == name {
 			return true
@@ -312,7 +470,7 @@ func (c *clusterHandler) checkClusterName(cr *config.ClusterRegistry) bool {
 		return false
 	}
 
-	if isInParentPool(cr.Name) {
+	if c.isInParentPool(cr.Name) {
 		return false
 	}
 
@@ -340,7 +498,7 @@ afterClusterConnect runs after connect to a child.
 */
 func (c *clusterHandler) afterClusterConnect(cr *config.ClusterRegistry) {
 	// add cluster to route
-	clusterrouter.Router().AddChild(cr.Name, cr.Listen, c.sendToChild)
+	c.route().AddChild(cr.Name, cr.Listen, c.sendToChild)
 }
 
 /*
@@ -352,33 +510,41 @@ There are things to do with message from child.
 */
 func (c *clusterHandler) handleMessageFromChild(client string, data []byte) (ret error) {
 	ret = nil
-	msg := &clustermessage.ClusterMessage{}
+	defer deadletter.Recover(deadletter.SourceChild, client, data, &ret)
+	msg := clustermessage.AcquireClusterMessage()
+	defer clustermessage.ReleaseClusterMessage(msg)
 	err := proto.Unmarshal(data, msg)
 	if err != nil {
 		ret = fmt.Errorf("deserialize cluster message(%s) failed: %v", string(data), err)
 		klog.Error(ret)
+		deadletter.Record(deadletter.SourceChild, client, deadletter.ReasonUndecodable, data, ret)
 		return
 	}
 	if msg.Head == nil {
 		ret = fmt.Errorf("deserialize cluster message(%s) failed: message head is nil", string(data))
 		klog.Error(ret)
+		deadletter.Record(deadletter.SourceChild, client, deadletter.ReasonUndecodable, data, ret)
 		return
 	}
+	bandwidth.RecordMessage(client, bandwidth.Received, msg, len(data))
+	archive.RecordMessage(client, archive.Received, msg)
 	// if the msg has no parentClusterName, set it to self
 	if msg.Head.ParentClusterName == "" {
 		msg.Head.ParentClusterName = c.conf.ClusterName
+		// data is out of date, msg will be serialized again if forwarded.
+		data = nil
 	}
 
 	switch msg.Head.Command {
 	case clustermessage.CommandType_ClusterRegist:
 		if c.isRoot() {
-			ret = c.sendToControllerManager(msg)
+			ret = c.sendToControllerManager(msg, data)
 		}
 		// TODO do not access k8s in cluster controller
 		ret = c.handleRegistClusterMessage(client, msg)
 	case clustermessage.CommandType_ClusterUnregist:
 		if c.isRoot() {
-			ret = c.sendToControllerManager(msg)
+			ret = c.sendToControllerManager(msg, data)
 		}
 		// TODO do not access k8s in cluster controller
 		ret = c.handleUnregistClusterMessage(client, msg)
@@ -387,10 +553,17 @@ func (c *clusterHandler) handleMessageFromChild(client string, data []byte) (ret
 	default:
 		if c.isRoot() {
 			// send to controller manager
-			ret = c.sendToControllerManager(msg)
-			// TODO return error if failed
+			ret = c.sendToControllerManager(msg, data)
+			if ret != nil {
+				c.retrySendToControllerManager(client, msg, ret)
+			}
 			// TODO do not merge to apiserver
-			if msg.Head.Command == clustermessage.CommandType_ControlResp {
+			if msg.Head.Command == clustermessage.CommandType_ControlResp &&
+				!strings.HasPrefix(msg.Head.MessageID, otev1.ClusterProxyMessagePrefix) &&
+				!strings.HasPrefix(msg.Head.MessageID, otev1.ClusterScaffoldMessagePrefix) {
+				if c.tracker != nil {
+					c.tracker.responded(msg.Head.MessageID, msg.Head.ClusterName)
+				}
 				ret = c.mergeToApiserver(msg)
 			}
 		} else {
@@ -400,26 +573,46 @@ func (c *clusterHandler) handleMessageFromChild(client string, data []byte) (ret
 	return
 }
 
-func (c *clusterHandler) sendToControllerManager(msg *clustermessage.ClusterMessage) error {
-	var ret error
-	data, err := proto.Marshal(msg)
+// retrySendToControllerManager sends msg from client failed by err to controller manager again
+// in background, which is kept as a dead letter if all attempts failed.
+func (c *clusterHandler) retrySendToControllerManager(client string, msg *clustermessage.ClusterMessage, err error) {
+	if !deadletter.Enabled() {
+		return
+	}
+	// msg is released after handled, and is transcoded already.
+	data, marshalErr := proto.Marshal(msg)
+	if marshalErr != nil {
+		klog.Errorf("serialize cluster message %s failed: %v", msg.Head.MessageID, marshalErr)
+		return
+	}
+	deadletter.Retry(deadletter.SourceChild, client, data, err, func() error {
+		return c.tunn.SendToControllerManager(data)
+	})
+}
+
+// sendToControllerManager sends msg to controller manager.
+// data is the serialized msg received, which is forwarded without serializing again if not nil.
+func (c *clusterHandler) sendToControllerManager(msg *clustermessage.ClusterMessage, data []byte) error {
+	// edge reports are encoded by the encoding negotiated with controller managers.
+	changed, err := reporter.Transcode(msg, reporter.UpstreamEncoding())
 	if err != nil {
-		ret = fmt.Errorf("serialize cluster message(%v) failed: %v", msg, err)
-		klog.Error(ret)
-		return ret
+		klog.Errorf("transcode reports from %s failed: %v", msg.Head.ClusterName, err)
+	} else if changed {
+		data = nil
+	}
+	if data != nil {
+		err = c.tunn.SendToControllerManager(data)
+	} else {
+		err = msg.SerializeTo(c.tunn.SendToControllerManager)
 	}
-	err = c.tunn.SendToControllerManager(data)
 	if err != nil {
-		ret = fmt.Errorf("send to controller manager failed: %v", err)
+		ret := fmt.Errorf("send to controller manager failed: %v", err)
 		klog.Error(ret)
 		return ret
 	}
 	return nil
 }
 
-/*
-isRoot checks whether a cluster is root.
-*/
 func (c *clusterHandler) isRoot() bool {
 	return config.IsRoot(c.conf.ClusterUserDefineName)
 }
@@ -444,7 +637,7 @@ func (c *clusterHandler) handleRegistClusterMessage(
 
 	// add the cluster to router
 	// and if failed to add, do not transmit to parent or save to k8s
-	err := clusterrouter.Router().AddRoute(cr.Name, client)
+	err := c.route().AddRoute(cr.Name, client)
 	if err != nil {
 		// handle rename situation
 		// TODO make the new one reconnect
@@ -452,12 +645,17 @@ func (c *clusterHandler) handleRegistClusterMessage(
 		klog.Error(ret)
 		return
 	}
+	// the cluster re-registers by a new name, routes to its previous name are tombstoned.
+	if err := c.route().TombstoneRoute(cr.PreviousName, cr.Name, client); err != nil {
+		klog.Errorf("tombstone %s renamed to %s failed: %v", cr.PreviousName, cr.Name, err)
+	}
 
 	if c.isRoot() {
 		old := c.clusterCRD.Get(cluster.ObjectMeta.Namespace, cluster.ObjectMeta.Name)
 		if old == nil {
 			cluster.Status.Status = otev1.ClusterStatusOnline
 			cluster.Status.Timestamp = time.Now().Unix()
+			setClockSkewCondition(cluster.ObjectMeta.Name, &cluster.Status, c.conf.ClockSkewThreshold)
 			c.clusterCRD.Create(cluster)
 		} else {
 			// update cluster status to online
@@ -465,6 +663,12 @@ func (c *clusterHandler) handleRegistClusterMessage(
 			old.Status.Timestamp = cluster.Status.Timestamp
 			old.Status.Listen = cluster.Status.Listen
 			old.Status.ParentName = cluster.Status.ParentName
+			old.Status.Version = cluster.Status.Version
+			old.Status.Protocol = cluster.Status.Protocol
+			old.Status.ClockSkew = cluster.Status.ClockSkew
+			old.Status.Capabilities = cluster.Status.Capabilities
+			old.Status.HLC = cluster.Status.HLC
+			setClockSkewCondition(old.ObjectMeta.Name, &old.Status, c.conf.ClockSkewThreshold)
 			err = c.clusterCRD.UpdateStatus(old)
 			if err != nil {
 				ret = fmt.Errorf("update cluster status failed: %v", err)
@@ -473,7 +677,7 @@ func (c *clusterHandler) handleRegistClusterMessage(
 			}
 		}
 	} else {
-		c.transmitToParent(msg)
+		c.transmitToParent(registryToParent(cr, msg))
 	}
 
 	return
@@ -492,7 +696,7 @@ func (c *clusterHandler) closeChild(cr *config.ClusterRegistry) {
 
 	cr.ParentName = c.conf.ClusterName
 	// delete child from route
-	clusterrouter.Router().DelChild(cr.Name, c.sendToChild)
+	c.route().DelChild(cr.Name, c.sendToChild)
 
 	// if this is root, delete cluster from etcd
 	// otherwise, report unregist to root
@@ -528,7 +732,7 @@ func (c *clusterHandler) handleUnregistClusterMessage(
 		return
 	}
 
-	clusterrouter.Router().DelRoute(cluster.ObjectMeta.Name, client)
+	c.route().DelRoute(cluster.ObjectMeta.Name, client)
 
 	if c.isRoot() {
 		old := c.clusterCRD.Get(cluster.ObjectMeta.Namespace, cluster.ObjectMeta.Name)
@@ -536,6 +740,7 @@ func (c *clusterHandler) handleUnregistClusterMessage(
 			// update to offline status
 			old.Status.Status = otev1.ClusterStatusOffline
 			old.Status.Timestamp = cr.Time
+			old.Status.HLC = unregistHybridTime(old.Status.HLC, cr.HLC)
 			err := c.clusterCRD.UpdateStatus(old)
 			if err != nil {
 				ret = fmt.Errorf("update cluster status failed: %v", err)
@@ -544,7 +749,7 @@ func (c *clusterHandler) handleUnregistClusterMessage(
 			}
 		}
 	} else {
-		c.transmitToParent(msg)
+		c.transmitToParent(registryToParent(cr, msg))
 	}
 
 	return
@@ -555,13 +760,26 @@ mergeToApiserver merge response to etcd with mutex lock.
 cc is part of response to a cluster controller crd reqeust.
 */
 func (c *clusterHandler) mergeToApiserver(msg *clustermessage.ClusterMessage) error {
-	mergeToApiserverMutex.Lock()
-	defer mergeToApiserverMutex.Unlock()
 	// transfer cluster message to crd
 	cc := clusterMessageToClusterControllerCRD(msg)
 	if cc == nil {
 		return fmt.Errorf("transfer cluster message to crd failed")
 	}
+	c.mergeStatusToApiserver(cc)
+	return nil
+}
+
+// mergeStatusToApiserver merges status of clusters in cc to the ClusterController crd,
+// and dispatches the next wave of cc if the current one is done.
+func (c *clusterHandler) mergeStatusToApiserver(cc *otev1.ClusterController) {
+	c.advanceRollout(cc, c.mergeStatus(cc))
+}
+
+// mergeStatus merges status of clusters in cc to the ClusterController crd, and returns status merged.
+func (c *clusterHandler) mergeStatus(cc *otev1.ClusterController) map[string]otev1.ClusterControllerStatus {
+	mergeToApiserverMutex.Lock()
+	defer mergeToApiserverMutex.Unlock()
+	merged := make(map[string]otev1.ClusterControllerStatus)
 	// get clustercontroller crd by name
 	if origin := c.clusterControllerCRD.Get(cc.ObjectMeta.Namespace, cc.ObjectMeta.Name); origin != nil {
 		// merge status and update timestamp
@@ -570,28 +788,36 @@ func (c *clusterHandler) mergeToApiserver(msg *clustermessage.ClusterMessage) er
 			new.Status = make(map[string]otev1.ClusterControllerStatus)
 		}
 		for cn, s := range cc.Status {
-			if originStatus, ok := origin.Status[cn]; !ok {
-				new.Status[cn] = s
-			} else {
-				// update cluster status if timestamp is new
-				if originStatus.Timestamp < s.Timestamp {
-					new.Status[cn] = s
-				}
+			originStatus, ok := origin.Status[cn]
+			switch {
+			case !ok:
+			case s.Reason == otev1.ClusterControllerStatusTimedOut:
+				// keep status responded before timed out
+				continue
+			case originStatus.Reason == otev1.ClusterControllerStatusTimedOut ||
+				originStatus.Timestamp < s.Timestamp:
+				// update cluster status if timestamp is new, or responded after timed out
+			default:
+				continue
 			}
+			new.Status[cn] = resultstore.Record(origin, cn, s)
+			merged[cn] = s
 		}
 		// update new to apiserver
 		klog.Infof("crd response update %s-%s", new.ObjectMeta.Namespace, new.ObjectMeta.Name)
 		c.clusterControllerCRD.Update(new)
 	}
-	return nil
+	return merged
 }
 
 /*
 transmitToParent transmit message to parent asynchronously.
 */
 func (c *clusterHandler) transmitToParent(msg *clustermessage.ClusterMessage) {
+	// copy msg before returning, since msg may be released to pool by caller.
+	m := *msg
 	go func() {
-		c.conf.ClusterToEdgeChan <- *msg
+		c.conf.ClusterToEdgeChan <- m
 	}()
 }
 
@@ -628,9 +854,14 @@ func getClusterFromClusterRegistry(cr *config.ClusterRegistry) *otev1.Cluster {
 			Name: cr.UserDefineName,
 		},
 		Status: otev1.ClusterStatus{
-			Listen:     cr.Listen,
-			ParentName: cr.ParentName,
-			Timestamp:  cr.Time,
+			Listen:       cr.Listen,
+			ParentName:   cr.ParentName,
+			Timestamp:    cr.Time,
+			Version:      cr.Version,
+			Protocol:     cr.Protocol,
+			ClockSkew:    cr.ClockSkew,
+			Capabilities: cr.Capabilities,
+			HLC:          cr.HLC,
 		},
 	}
 }
@@ -643,16 +874,16 @@ func (c *clusterHandler) updateRouteToSubtree(msg *clustermessage.ClusterMessage
 	}
 	var err error
 	for to := range subtrees {
-		err = clusterrouter.Router().AddRoute(to, msg.Head.ClusterName)
+		err = c.route().AddRoute(to, msg.Head.ClusterName)
 		if err != nil {
 			klog.Errorf("add subtree router %s-%s failed: %v", to, msg.Head.ClusterName, err)
 		}
 	}
 	// delete route from child but not in subtrees
-	childsOfChild := clusterrouter.Router().SubTreeOfPort(msg.Head.ClusterName)
-	for _, c := range childsOfChild {
-		if _, ok := subtrees[c]; !ok {
-			clusterrouter.Router().DelRoute(c, msg.Head.ClusterName)
+	childsOfChild := c.route().SubTreeOfPort(msg.Head.ClusterName)
+	for _, child := range childsOfChild {
+		if _, ok := subtrees[child]; !ok {
+			c.route().DelRoute(child, msg.Head.ClusterName)
 		}
 	}
 
@@ -720,6 +951,8 @@ func clusterControllerCRDToSerializedControllerTask(
 		Method:      cc.Spec.Method,
 		URI:         cc.Spec.URL,
 		Body:        []byte(cc.Spec.Body),
+
+		IdempotencyKey: cc.Spec.IdempotencyKey,
 	}
 	data, err := proto.Marshal(ret)
 	if err != nil {
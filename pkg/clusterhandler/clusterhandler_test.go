@@ -23,12 +23,14 @@ import (
 
 	"github.com/golang/protobuf/proto"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
 	"github.com/baidu/ote-stack/pkg/clustermessage"
 	"github.com/baidu/ote-stack/pkg/clusterrouter"
 	"github.com/baidu/ote-stack/pkg/config"
+	"github.com/baidu/ote-stack/pkg/deadletter"
 	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned/fake"
 	"github.com/baidu/ote-stack/pkg/tunnel"
 )
@@ -57,14 +59,14 @@ func TestInit(t *testing.T) {
 	assert.False(t, h.k8sEnable)
 	assert.NoError(t, h.valid())
 	assert.True(t, h.k8sEnable)
-	h.conf.ParentCluster = "parent"
+	h.conf.ParentCluster = []string{"parent"}
 	assert.Error(t, h.valid())
 
 	// test no-root cluster config
 	h.conf.ClusterUserDefineName = "c1"
-	h.conf.ParentCluster = ""
+	h.conf.ParentCluster = nil
 	assert.Error(t, h.valid())
-	h.conf.ParentCluster = "parent"
+	h.conf.ParentCluster = []string{"parent"}
 	assert.NoError(t, h.valid())
 
 	h2, err := NewClusterHandler(h.conf)
@@ -94,7 +96,7 @@ func TestSelectChild(t *testing.T) {
 			ClusterSelector: "c3,c5",
 		},
 	}
-	selected := selectChild(msg)
+	selected := (&clusterHandler{}).selectChild(msg)
 	assert.Equal(t, 2, len(selected))
 	assert.Equal(t, "c3", selected["c1"].Head.ClusterSelector)
 	assert.Equal(t, "c5", selected["c4"].Head.ClusterSelector)
@@ -296,11 +298,65 @@ func TestHandleRegistClusterMessage(t *testing.T) {
 	assert.NotNil(err)
 	// add a renamed cluster from the same port with large timestamp
 	cr.Time++
+	cr.Version = "2.0"
+	cr.Protocol = 1
+	cr.ClockSkew = -120000
+	c.conf.ClockSkewThreshold = time.Minute
+	cr.Capabilities = &otev1.ClusterCapabilities{Destinations: []string{"api"}}
 	ccbytes, err = json.Marshal(cr)
 	assert.Nil(err)
 	msg.Body = ccbytes
 	err = c.handleRegistClusterMessage("c1", msg)
 	assert.Nil(err)
+	cluster := c.clusterCRD.Get(otev1.ClusterNamespace, "c1")
+	assert.NotNil(cluster)
+	assert.Equal("2.0", cluster.Status.Version)
+	assert.Equal(1, cluster.Status.Protocol)
+	assert.Equal(int64(-120000), cluster.Status.ClockSkew)
+	assert.Equal([]string{"api"}, cluster.Status.Capabilities.Destinations)
+	cond := cluster.Status.GetCondition(otev1.ClusterConditionClockSkewed)
+	assert.NotNil(cond)
+	assert.Equal(corev1.ConditionTrue, cond.Status)
+	assert.Equal("clock is 2m0s behind root", cond.Message)
+}
+
+func TestHandleRenamedClusterRegist(t *testing.T) {
+	assert := assert.New(t)
+	c := newFakeRootClusterHandler(t)
+	c.router = clusterrouter.NewClusterRouter()
+	assert.Nil(c.router.AddRoute("c1", "c1"))
+	assert.Nil(c.router.AddRoute("old", "c1"))
+	assert.Nil(c.router.AddRoute("c2", "c2"))
+
+	cr := &config.ClusterRegistry{
+		Name:         "new",
+		Time:         time.Now().Unix(),
+		PreviousName: "old",
+	}
+	msg, err := cr.WrapperToClusterMessage(clustermessage.CommandType_ClusterRegist)
+	assert.Nil(err)
+	assert.Nil(c.handleRegistClusterMessage("c1", msg))
+	assert.True(c.router.HasRoute("new", "c1"))
+	assert.False(c.router.HasRoute("old", "c1"))
+	assert.NotNil(c.clusterCRD.Get(otev1.ClusterNamespace, "new"))
+
+	// messages to the previous name are rerouted to the new name
+	selected := c.selectChild(&clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{ClusterSelector: "old,c2"},
+	})
+	assert.Equal(2, len(selected))
+	assert.Equal("new", selected["c1"].Head.ClusterSelector)
+	assert.Equal("c2", selected["c2"].Head.ClusterSelector)
+	assert.ElementsMatch([]string{"new"}, c.selectedClusters("old,new"))
+
+	// the previous name used by another cluster is not tombstoned
+	cr.Name = "c3"
+	cr.PreviousName = "c2"
+	msg, err = cr.WrapperToClusterMessage(clustermessage.CommandType_ClusterRegist)
+	assert.Nil(err)
+	assert.Nil(c.handleRegistClusterMessage("c1", msg))
+	assert.True(c.router.HasRoute("c2", "c2"))
+	assert.Equal([]string{"c2"}, c.selectedClusters("c2"))
 }
 
 func TestHandleUnregistClusterMessage(t *testing.T) {
@@ -383,6 +439,14 @@ func (f *fakeCloudTunnel) Stop() error {
 	return nil
 }
 
+func (f *fakeCloudTunnel) Drain(alternate string, timeout time.Duration) error {
+	return nil
+}
+
+func (f *fakeCloudTunnel) Stats() []tunnel.ConnectionStats {
+	return nil
+}
+
 func (f *fakeCloudTunnel) Send(clusterName string, msg []byte) error {
 	f.sendCalled = true
 	return nil
@@ -398,6 +462,8 @@ func (f *fakeCloudTunnel) SendToControllerManager(msg []byte) error {
 
 func (f *fakeCloudTunnel) RegistRedirectFunc(fn tunnel.RedirectFunc) {}
 
+func (f *fakeCloudTunnel) RegistAssignFunc(fn tunnel.AssignFunc) {}
+
 func (f *fakeCloudTunnel) RegistCheckNameValidFunc(fn tunnel.ClusterNameChecker) {}
 
 func (f *fakeCloudTunnel) RegistAfterConnectHook(fn tunnel.AfterConnectHook) {}
@@ -433,7 +499,7 @@ func newFakeNoRootClusterHandler(t *testing.T) *clusterHandler {
 			ClusterUserDefineName: "c1",
 			TunnelListenAddr:      "8273",
 			K8sClient:             oteclient.NewSimpleClientset(),
-			ParentCluster:         "8272",
+			ParentCluster:         []string{"8272"},
 		},
 		tunn:      fakeTunn,
 		k8sEnable: false,
@@ -445,13 +511,13 @@ func newFakeNoRootClusterHandler(t *testing.T) *clusterHandler {
 }
 
 func TestIsCandidateParent(t *testing.T) {
-	ret := isInParentPool("c1")
+	ret := (&clusterHandler{}).isInParentPool("c1")
 	assert.Equal(t, false, ret)
 
 	clusterrouter.Router().ParentNeighbor = map[string]string{
 		"c1": "12345",
 	}
-	ret = isInParentPool("c1")
+	ret = (&clusterHandler{}).isInParentPool("c1")
 	assert.Equal(t, true, ret)
 }
 
@@ -469,3 +535,15 @@ func TestCheckName(t *testing.T) {
 	ret := c.checkClusterName(registry)
 	assert.Equal(t, false, ret)
 }
+
+func TestDispatchFromParentRecoverPanic(t *testing.T) {
+	assert.Nil(t, deadletter.Setup(deadletter.Config{Limit: 10}))
+	defer deadletter.Setup(deadletter.Config{})
+	c := &clusterHandler{conf: &config.ClusterControllerConfig{ClusterName: "c1"}}
+
+	// a message without head panics, which is kept instead of crashing.
+	c.dispatchFromParent(&clustermessage.ClusterMessage{Body: []byte("no head")})
+	letters := deadletter.List(deadletter.Query{Reason: deadletter.ReasonPanicked})
+	assert.Len(t, letters, 1)
+	assert.Equal(t, deadletter.SourceParent, letters[0].Source)
+}
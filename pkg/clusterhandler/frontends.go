@@ -0,0 +1,70 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterhandler
+
+import (
+	"fmt"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+)
+
+// validFrontends checks this root frontend is one of the frontends if there are more than one.
+func (c *clusterHandler) validFrontends() error {
+	if len(c.conf.TunnelFrontends) <= 1 {
+		return nil
+	}
+	if !c.isRoot() {
+		return fmt.Errorf("tunnel frontends are only supported by root")
+	}
+	for _, addr := range c.conf.TunnelFrontends {
+		if addr == c.conf.TunnelAdvertiseAddr {
+			return nil
+		}
+	}
+	return fmt.Errorf("advertise address %s is not one of tunnel frontends %v",
+		c.conf.TunnelAdvertiseAddr, c.conf.TunnelFrontends)
+}
+
+// assignFrontend returns the address of root frontend cluster is assigned to,
+// empty if it is assigned to this one.
+func (c *clusterHandler) assignFrontend(cluster string) string {
+	addr := c.frontends.Get(cluster)
+	if addr == c.conf.TunnelAdvertiseAddr {
+		return ""
+	}
+	return addr
+}
+
+/*
+ownedStatus returns cc with status of clusters in subtree of this root frontend only,
+since a ClusterController is dispatched by each frontend to its childs, and responses
+of childs of other frontends do not mean it is processed by this one.
+cc is returned as it is if there is only one frontend.
+*/
+func (c *clusterHandler) ownedStatus(cc *otev1.ClusterController) *otev1.ClusterController {
+	if c.frontends == nil || len(cc.Status) == 0 {
+		return cc
+	}
+	owned := cc.DeepCopy()
+	owned.Status = make(map[string]otev1.ClusterControllerStatus)
+	for _, cluster := range c.route().SubTreeClusters() {
+		if s, ok := cc.Status[cluster]; ok {
+			owned.Status[cluster] = s
+		}
+	}
+	return owned
+}
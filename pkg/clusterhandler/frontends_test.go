@@ -0,0 +1,68 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterhandler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/config"
+	"github.com/baidu/ote-stack/pkg/tunnel"
+)
+
+func TestAssignFrontend(t *testing.T) {
+	frontends := []string{"f1:8287", "f2:8287"}
+	c := &clusterHandler{
+		conf: &config.ClusterControllerConfig{
+			ClusterUserDefineName: config.RootClusterName,
+			TunnelFrontends:       frontends,
+			TunnelAdvertiseAddr:   "f3:8287",
+		},
+	}
+	assert.NotNil(t, c.validFrontends())
+	c.conf.TunnelAdvertiseAddr = "f1:8287"
+	assert.Nil(t, c.validFrontends())
+
+	c.frontends = tunnel.NewHashRing(frontends, 0)
+	for _, cluster := range []string{"c1", "c2", "c3", "c4"} {
+		addr := c.assignFrontend(cluster)
+		if c.frontends.Get(cluster) == "f1:8287" {
+			assert.Equal(t, "", addr)
+		} else {
+			assert.Equal(t, "f2:8287", addr)
+		}
+	}
+}
+
+func TestOwnedStatus(t *testing.T) {
+	cc := &otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{Name: "cc1", Namespace: otev1.ClusterNamespace},
+		Status: map[string]otev1.ClusterControllerStatus{
+			"c1": {StatusCode: 200},
+		},
+	}
+	c := &clusterHandler{}
+	assert.Equal(t, cc, c.ownedStatus(cc))
+
+	// c1 is not in subtree of this frontend
+	c.frontends = tunnel.NewHashRing([]string{"f1:8287", "f2:8287"}, 0)
+	assert.Empty(t, c.ownedStatus(cc).Status)
+	assert.Len(t, cc.Status, 1)
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterhandler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+)
+
+/*
+skipUnhealthy records status Skipped of clusters whose health score is below
+the min health score of cc, and returns the others.
+Clusters with health not computed, e.g., ote controller manager not running the health controller,
+are taken as healthy, so that tasks to them are dispatched as before.
+*/
+func (c *clusterHandler) skipUnhealthy(cc *otev1.ClusterController, clusters []string) []string {
+	if cc.Spec.MinHealthScore <= 0 || c.clusterLister == nil {
+		return clusters
+	}
+	var healthy []string
+	unhealthy := make(map[string]int)
+	for _, name := range clusters {
+		cluster, err := c.clusterLister.Clusters(otev1.ClusterNamespace).Get(name)
+		if err != nil && !errors.IsNotFound(err) {
+			klog.Errorf("get cluster %s failed: %v", name, err)
+		}
+		if err == nil && cluster.Status.Health != nil && cluster.Status.Health.Score < cc.Spec.MinHealthScore {
+			unhealthy[name] = cluster.Status.Health.Score
+			continue
+		}
+		healthy = append(healthy, name)
+	}
+	if len(unhealthy) == 0 {
+		return clusters
+	}
+	klog.Infof("clustercontroller %s skips clusters of health score below %d: %v",
+		cc.ObjectMeta.Name, cc.Spec.MinHealthScore, unhealthy)
+	c.mergeStatusToApiserver(unhealthyClusterController(cc.ObjectMeta.Name, unhealthy, cc.Spec.MinHealthScore))
+	return healthy
+}
+
+// unhealthyClusterController returns the ClusterController with status Skipped of clusters
+// of health scores below min.
+func unhealthyClusterController(name string, scores map[string]int, min int) *otev1.ClusterController {
+	cc := &otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: otev1.ClusterNamespace,
+		},
+		Status: make(map[string]otev1.ClusterControllerStatus, len(scores)),
+	}
+	now := time.Now().Unix()
+	for cluster, score := range scores {
+		cc.Status[cluster] = otev1.ClusterControllerStatus{
+			Timestamp:  now,
+			StatusCode: http.StatusPreconditionFailed,
+			Body:       fmt.Sprintf("health score %d of the cluster is below %d", score, min),
+			Reason:     otev1.ClusterControllerStatusSkipped,
+		}
+	}
+	return cc
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterhandler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned/fake"
+	otelisters "github.com/baidu/ote-stack/pkg/generated/listers/ote/v1"
+	"github.com/baidu/ote-stack/pkg/k8sclient"
+)
+
+func newHealthCluster(name string, health *otev1.ClusterHealth) *otev1.Cluster {
+	return &otev1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: otev1.ClusterNamespace},
+		Status:     otev1.ClusterStatus{Health: health},
+	}
+}
+
+func TestSkipUnhealthy(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	indexer.Add(newHealthCluster("c1", &otev1.ClusterHealth{Score: 90}))
+	indexer.Add(newHealthCluster("c2", &otev1.ClusterHealth{Score: 40}))
+	// health of c3 is not computed, c4 is not registed.
+	indexer.Add(newHealthCluster("c3", nil))
+	cc := &otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{Name: "cc1", Namespace: otev1.ClusterNamespace},
+		Spec:       otev1.ClusterControllerSpec{Destination: "api", MinHealthScore: 50},
+	}
+	client := oteclient.NewSimpleClientset(cc)
+	c := &clusterHandler{
+		clusterControllerCRD: k8sclient.NewClusterControllerCRD(client),
+		clusterLister:        otelisters.NewClusterLister(indexer),
+	}
+
+	healthy := c.skipUnhealthy(cc, []string{"c1", "c2", "c3", "c4"})
+	assert.Equal(t, []string{"c1", "c3", "c4"}, healthy)
+	cc, err := client.OteV1().ClusterControllers(otev1.ClusterNamespace).Get("cc1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, cc.Status, 1)
+	assert.Equal(t, http.StatusPreconditionFailed, cc.Status["c2"].StatusCode)
+	assert.Equal(t, otev1.ClusterControllerStatusSkipped, cc.Status["c2"].Reason)
+	assert.Contains(t, cc.Status["c2"].Body, "40")
+
+	// no cluster is skipped without min health score.
+	cc.Spec.MinHealthScore = 0
+	assert.Equal(t, []string{"c1", "c2"}, c.skipUnhealthy(cc, []string{"c1", "c2"}))
+}
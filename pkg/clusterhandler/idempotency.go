@@ -0,0 +1,110 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterhandler
+
+import (
+	"net/http"
+	"regexp"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clusterselector"
+)
+
+// idempotencyKeyIndex is the index of ClusterControllers by idempotency key.
+const idempotencyKeyIndex = "idempotencyKey"
+
+func idempotencyKeyIndexFunc(obj interface{}) ([]string, error) {
+	cc, ok := obj.(*otev1.ClusterController)
+	if !ok || cc.Spec.IdempotencyKey == "" {
+		return nil, nil
+	}
+	return []string{cc.Spec.IdempotencyKey}, nil
+}
+
+/*
+doneByIdempotencyKey returns status of clusters selected by cc, which have done the task
+in other ClusterControllers of the same idempotency key.
+The status returned is the latest succeeded one, with reason Deduplicated.
+*/
+func (c *clusterHandler) doneByIdempotencyKey(cc *otev1.ClusterController) map[string]otev1.ClusterControllerStatus {
+	if cc.Spec.IdempotencyKey == "" || c.clusterControllerIndexer == nil {
+		return nil
+	}
+	objs, err := c.clusterControllerIndexer.ByIndex(idempotencyKeyIndex, cc.Spec.IdempotencyKey)
+	if err != nil {
+		klog.Errorf("get clustercontrollers of idempotency key %s failed: %v", cc.Spec.IdempotencyKey, err)
+		return nil
+	}
+	selector := clusterselector.NewSelector(cc.Spec.ClusterSelector)
+	done := make(map[string]otev1.ClusterControllerStatus)
+	for _, obj := range objs {
+		other := obj.(*otev1.ClusterController)
+		if other.Namespace == cc.Namespace && other.Name == cc.Name {
+			continue
+		}
+		for cluster, s := range other.Status {
+			if s.StatusCode < http.StatusOK || s.StatusCode >= http.StatusMultipleChoices {
+				continue
+			}
+			if !selector.Has(cluster) {
+				continue
+			}
+			if d, ok := done[cluster]; ok && d.Timestamp >= s.Timestamp {
+				continue
+			}
+			s.Reason = otev1.ClusterControllerStatusDeduplicated
+			done[cluster] = s
+		}
+	}
+	return done
+}
+
+// deduplicate records status of clusters done in cc, and returns clusters not done.
+func (c *clusterHandler) deduplicate(cc *otev1.ClusterController, clusters []string) []string {
+	done := c.doneByIdempotencyKey(cc)
+	if len(done) == 0 {
+		return clusters
+	}
+	klog.Infof("clustercontroller %s is done by %d clusters with idempotency key %s",
+		cc.ObjectMeta.Name, len(done), cc.Spec.IdempotencyKey)
+	c.mergeStatusToApiserver(&otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cc.ObjectMeta.Name,
+			Namespace: cc.ObjectMeta.Namespace,
+		},
+		Status: done,
+	})
+	var notDone []string
+	for _, cluster := range clusters {
+		if _, ok := done[cluster]; !ok {
+			notDone = append(notDone, cluster)
+		}
+	}
+	return notDone
+}
+
+// exactSelector returns the selector only matching clusters.
+func exactSelector(clusters []string) string {
+	rules := make([]string, len(clusters))
+	for i, cluster := range clusters {
+		rules[i] = "^" + regexp.QuoteMeta(cluster) + "$"
+	}
+	return clusterselector.ClustersToSelector(&rules)
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterhandler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clusterselector"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned/fake"
+	"github.com/baidu/ote-stack/pkg/k8sclient"
+)
+
+func TestDeduplicate(t *testing.T) {
+	done := &otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{Name: "cc1", Namespace: otev1.ClusterNamespace},
+		Spec:       otev1.ClusterControllerSpec{IdempotencyKey: "key1"},
+		Status: map[string]otev1.ClusterControllerStatus{
+			"c1":  {Timestamp: 100, StatusCode: http.StatusCreated, Body: "created"},
+			"c2":  {Timestamp: 100, StatusCode: http.StatusInternalServerError},
+			"c10": {Timestamp: 100, StatusCode: http.StatusOK},
+		},
+	}
+	otherKey := &otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{Name: "cc2", Namespace: otev1.ClusterNamespace},
+		Spec:       otev1.ClusterControllerSpec{IdempotencyKey: "key2"},
+		Status: map[string]otev1.ClusterControllerStatus{
+			"c3": {Timestamp: 100, StatusCode: http.StatusOK},
+		},
+	}
+	cc := &otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{Name: "cc3", Namespace: otev1.ClusterNamespace},
+		Spec: otev1.ClusterControllerSpec{
+			ClusterSelector: "^c\\d$",
+			IdempotencyKey:  "key1",
+		},
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+		cache.Indexers{idempotencyKeyIndex: idempotencyKeyIndexFunc})
+	indexer.Add(done)
+	indexer.Add(otherKey)
+	indexer.Add(cc)
+	client := oteclient.NewSimpleClientset(cc)
+	c := &clusterHandler{
+		clusterControllerCRD:     k8sclient.NewClusterControllerCRD(client),
+		clusterControllerIndexer: indexer,
+	}
+
+	// c1 is done, c2 failed and c3 did a task of another key.
+	notDone := c.deduplicate(cc, []string{"c1", "c2", "c3"})
+	assert.Equal(t, []string{"c2", "c3"}, notDone)
+	cc, err := client.OteV1().ClusterControllers(otev1.ClusterNamespace).Get("cc3", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, cc.Status, 1)
+	assert.Equal(t, http.StatusCreated, cc.Status["c1"].StatusCode)
+	assert.Equal(t, "created", cc.Status["c1"].Body)
+	assert.Equal(t, otev1.ClusterControllerStatusDeduplicated, cc.Status["c1"].Reason)
+
+	// tasks without idempotency key are not deduplicated.
+	cc.Spec.IdempotencyKey = ""
+	assert.Equal(t, []string{"c1"}, c.deduplicate(cc, []string{"c1"}))
+}
+
+func TestExactSelector(t *testing.T) {
+	selector := clusterselector.NewSelector(exactSelector([]string{"c1", "c.2"}))
+	assert.True(t, selector.Has("c1"))
+	assert.True(t, selector.Has("c.2"))
+	assert.False(t, selector.Has("c10"))
+	assert.False(t, selector.Has("cx2"))
+}
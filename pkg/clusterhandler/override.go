@@ -0,0 +1,297 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package clusterhandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+// TaskOverride mutates task of a ClusterController before it is dispatched to cluster by root,
+// e.g., rewrites images or scales resources by annotations of the Cluster crd.
+// The task is not sent to the cluster if an error is returned.
+type TaskOverride func(cluster *otev1.Cluster, task *clustermessage.ControllerTask) error
+
+var (
+	overridesLock sync.RWMutex
+	overrides     = []TaskOverride{overrideImageRegistry, overrideResourceScale}
+)
+
+// RegistTaskOverride registers o to override tasks dispatched to clusters by root.
+// Overrides are applied in order of registration, after the built-in ones driven by
+// ClusterImageRegistryAnnotation and ClusterResourceScaleAnnotation.
+func RegistTaskOverride(o TaskOverride) {
+	overridesLock.Lock()
+	defer overridesLock.Unlock()
+	overrides = append(overrides, o)
+}
+
+// overriddenTask is the task msg overridden for clusters.
+type overriddenTask struct {
+	msg      *clustermessage.ClusterMessage
+	clusters []string
+}
+
+/*
+overrideTasks applies overrides to task msg of ClusterController name for each of clusters,
+and returns the tasks to dispatch, clusters of the same task overridden share a message.
+Clusters failed to override are recorded status OverrideFailed, and are not returned.
+Tasks are overridden by root only, clusters without Cluster crds are not overridden.
+*/
+func (c *clusterHandler) overrideTasks(name string, msg *clustermessage.ClusterMessage,
+	clusters []string) []overriddenTask {
+	overridesLock.RLock()
+	fns := append([]TaskOverride(nil), overrides...)
+	overridesLock.RUnlock()
+	asIs := []overriddenTask{{msg: msg, clusters: clusters}}
+	if len(fns) == 0 || len(clusters) == 0 || c.clusterLister == nil || msg.Head.Command != clustermessage.CommandType_ControlReq {
+		return asIs
+	}
+	task := &clustermessage.ControllerTask{}
+	if err := proto.Unmarshal(msg.Body, task); err != nil {
+		return asIs
+	}
+
+	var ret []overriddenTask
+	groups := make(map[string]int)
+	failed := make(map[string]error)
+	for _, cn := range clusters {
+		body := msg.Body
+		cluster, err := c.clusterLister.Clusters(otev1.ClusterNamespace).Get(cn)
+		if err != nil && !errors.IsNotFound(err) {
+			klog.Errorf("get cluster %s failed: %v", cn, err)
+		}
+		if err == nil {
+			body, err = overrideTask(cluster, task, fns)
+			if err != nil {
+				failed[cn] = err
+				continue
+			}
+		}
+		if i, ok := groups[string(body)]; ok {
+			ret[i].clusters = append(ret[i].clusters, cn)
+			continue
+		}
+		groups[string(body)] = len(ret)
+		groupMsg := proto.Clone(msg).(*clustermessage.ClusterMessage)
+		groupMsg.Body = body
+		ret = append(ret, overriddenTask{msg: groupMsg, clusters: []string{cn}})
+	}
+	if len(failed) != 0 {
+		klog.Errorf("clustercontroller %s is not sent to clusters failed to override: %v", name, failed)
+		c.mergeStatusToApiserver(overrideFailedClusterController(name, failed))
+	}
+	if len(failed) == 0 && len(ret) == 1 && string(ret[0].msg.Body) == string(msg.Body) {
+		// the task is not overridden for any cluster, keep the selector.
+		return asIs
+	}
+	for i := range ret {
+		ret[i].msg.Head.ClusterSelector = exactSelector(ret[i].clusters)
+	}
+	return ret
+}
+
+// overrideTask returns task serialized after overridden by fns for cluster.
+func overrideTask(cluster *otev1.Cluster, task *clustermessage.ControllerTask, fns []TaskOverride) ([]byte, error) {
+	overridden := proto.Clone(task).(*clustermessage.ControllerTask)
+	for _, fn := range fns {
+		if err := fn(cluster, overridden); err != nil {
+			return nil, err
+		}
+	}
+	return proto.Marshal(overridden)
+}
+
+// overrideFailedClusterController returns the ClusterController with status OverrideFailed
+// of clusters failed to override by errs.
+func overrideFailedClusterController(name string, errs map[string]error) *otev1.ClusterController {
+	cc := &otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: otev1.ClusterNamespace,
+		},
+		Status: make(map[string]otev1.ClusterControllerStatus, len(errs)),
+	}
+	now := time.Now().Unix()
+	for cluster, err := range errs {
+		cc.Status[cluster] = otev1.ClusterControllerStatus{
+			Timestamp:  now,
+			StatusCode: http.StatusUnprocessableEntity,
+			Body:       fmt.Sprintf("override task failed: %v", err),
+			Reason:     otev1.ClusterControllerStatusOverrideFailed,
+		}
+	}
+	return cc
+}
+
+// overrideContainers calls fn with each container of pod templates in json body of task,
+// and updates the body if any is changed. Bodies not of json objects, e.g., sealed ones, are kept.
+func overrideContainers(task *clustermessage.ControllerTask, fn func(container map[string]interface{}) (bool, error)) error {
+	obj := make(map[string]interface{})
+	if err := json.Unmarshal(task.Body, &obj); err != nil {
+		return nil
+	}
+	changed, err := walkContainers(obj, fn)
+	if err != nil || !changed {
+		return err
+	}
+	body, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	task.Body = body
+	return nil
+}
+
+// walkContainers calls fn with containers and init containers in v recursively,
+// e.g., of a Pod, the template of a Deployment, or items of a List.
+func walkContainers(v interface{}, fn func(container map[string]interface{}) (bool, error)) (bool, error) {
+	changed := false
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if key == "containers" || key == "initContainers" {
+				if containers, ok := value.([]interface{}); ok {
+					for _, container := range containers {
+						m, ok := container.(map[string]interface{})
+						if !ok {
+							continue
+						}
+						c, err := fn(m)
+						if err != nil {
+							return false, err
+						}
+						changed = changed || c
+					}
+					continue
+				}
+			}
+			c, err := walkContainers(value, fn)
+			if err != nil {
+				return false, err
+			}
+			changed = changed || c
+		}
+	case []interface{}:
+		for _, value := range v {
+			c, err := walkContainers(value, fn)
+			if err != nil {
+				return false, err
+			}
+			changed = changed || c
+		}
+	}
+	return changed, nil
+}
+
+// overrideImageRegistry rewrites registry of images of containers to ClusterImageRegistryAnnotation.
+func overrideImageRegistry(cluster *otev1.Cluster, task *clustermessage.ControllerTask) error {
+	registry := strings.TrimSuffix(cluster.Annotations[otev1.ClusterImageRegistryAnnotation], "/")
+	if registry == "" {
+		return nil
+	}
+	return overrideContainers(task, func(container map[string]interface{}) (bool, error) {
+		image, ok := container["image"].(string)
+		if !ok || image == "" {
+			return false, nil
+		}
+		rewritten := rewriteImageRegistry(image, registry)
+		container["image"] = rewritten
+		return rewritten != image, nil
+	})
+}
+
+/*
+rewriteImageRegistry returns image in registry. The first part of image is its registry
+if it has a dot or a port, or is localhost, otherwise image is of docker hub,
+whose official images are in library, e.g., nginx is rewritten to registry/library/nginx.
+*/
+func rewriteImageRegistry(image, registry string) string {
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		return registry + "/" + parts[1]
+	}
+	if len(parts) == 1 {
+		return registry + "/library/" + image
+	}
+	return registry + "/" + image
+}
+
+// overrideResourceScale scales cpu and memory requests and limits of containers
+// by ClusterResourceScaleAnnotation.
+func overrideResourceScale(cluster *otev1.Cluster, task *clustermessage.ControllerTask) error {
+	value, ok := cluster.Annotations[otev1.ClusterResourceScaleAnnotation]
+	if !ok {
+		return nil
+	}
+	scale, err := strconv.ParseFloat(value, 64)
+	if err != nil || scale <= 0 || math.IsInf(scale, 0) {
+		return fmt.Errorf("annotation %s=%s is not a positive number", otev1.ClusterResourceScaleAnnotation, value)
+	}
+	if scale == 1 {
+		return nil
+	}
+	return overrideContainers(task, func(container map[string]interface{}) (bool, error) {
+		resources, ok := container["resources"].(map[string]interface{})
+		if !ok {
+			return false, nil
+		}
+		changed := false
+		for _, field := range []string{"requests", "limits"} {
+			quantities, ok := resources[field].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, name := range []string{"cpu", "memory"} {
+				raw, ok := quantities[name]
+				if !ok {
+					continue
+				}
+				q, err := resource.ParseQuantity(fmt.Sprint(raw))
+				if err != nil {
+					return false, fmt.Errorf("%s %s of container is invalid: %v", field, name, err)
+				}
+				quantities[name] = scaleQuantity(name, q, scale).String()
+				changed = true
+			}
+		}
+		return changed, nil
+	})
+}
+
+// scaleQuantity returns q of resource name scaled by scale, cpu is rounded up to millicores,
+// and memory is rounded up to bytes.
+func scaleQuantity(name string, q resource.Quantity, scale float64) *resource.Quantity {
+	if name == "cpu" {
+		return resource.NewMilliQuantity(int64(math.Ceil(float64(q.MilliValue())*scale)), resource.DecimalSI)
+	}
+	return resource.NewQuantity(int64(math.Ceil(float64(q.Value())*scale)), q.Format)
+}
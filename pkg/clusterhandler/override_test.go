@@ -0,0 +1,168 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package clusterhandler
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned/fake"
+	otelisters "github.com/baidu/ote-stack/pkg/generated/listers/ote/v1"
+	"github.com/baidu/ote-stack/pkg/k8sclient"
+)
+
+const overrideTestBody = `{"kind":"Deployment","spec":{"template":{"spec":{` +
+	`"initContainers":[{"name":"init","image":"busybox"}],` +
+	`"containers":[{"name":"app","image":"docker.io/org/app:1.0",` +
+	`"resources":{"requests":{"cpu":"500m","memory":"128Mi"},"limits":{"cpu":1,"memory":"256Mi","nvidia.com/gpu":"1"}}}]}}}}`
+
+func newOverrideCluster(name string, annotations map[string]string) *otev1.Cluster {
+	return &otev1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: otev1.ClusterNamespace, Annotations: annotations},
+	}
+}
+
+func overriddenBody(t *testing.T, msg *clustermessage.ClusterMessage) string {
+	task := &clustermessage.ControllerTask{}
+	assert.Nil(t, proto.Unmarshal(msg.Body, task))
+	return string(task.Body)
+}
+
+func TestOverrideTasks(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	indexer.Add(newOverrideCluster("c1", map[string]string{
+		otev1.ClusterImageRegistryAnnotation: "registry.example.com/",
+	}))
+	indexer.Add(newOverrideCluster("c2", map[string]string{
+		otev1.ClusterImageRegistryAnnotation: "registry.example.com",
+		otev1.ClusterResourceScaleAnnotation: "0.5",
+	}))
+	indexer.Add(newOverrideCluster("c3", map[string]string{
+		otev1.ClusterImageRegistryAnnotation: "registry.example.com",
+	}))
+	indexer.Add(newOverrideCluster("c4", map[string]string{
+		otev1.ClusterResourceScaleAnnotation: "half",
+	}))
+	// c5 is not overridden, c6 is not registed.
+	indexer.Add(newOverrideCluster("c5", nil))
+	cc := &otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{Name: "cc1", Namespace: otev1.ClusterNamespace},
+		Spec:       otev1.ClusterControllerSpec{Method: http.MethodPost, URL: "/apis/apps/v1", Body: overrideTestBody},
+	}
+	client := oteclient.NewSimpleClientset(cc)
+	c := &clusterHandler{
+		clusterControllerCRD: k8sclient.NewClusterControllerCRD(client),
+		clusterLister:        otelisters.NewClusterLister(indexer),
+	}
+	msg := clusterControllerCRDToClusterMessage(cc, clustermessage.CommandType_ControlReq)
+	msg.Head.ClusterSelector = "c.*"
+
+	// tasks to clusters not overridden are as is.
+	tasks := c.overrideTasks("cc1", msg, []string{"c5", "c6"})
+	assert.Len(t, tasks, 1)
+	assert.Equal(t, msg, tasks[0].msg)
+	assert.Equal(t, []string{"c5", "c6"}, tasks[0].clusters)
+
+	tasks = c.overrideTasks("cc1", msg, []string{"c1", "c2", "c3", "c4", "c5", "c6"})
+	assert.Len(t, tasks, 3)
+	assert.Equal(t, []string{"c1", "c3"}, tasks[0].clusters)
+	assert.Equal(t, exactSelector([]string{"c1", "c3"}), tasks[0].msg.Head.ClusterSelector)
+	assert.Equal(t, "cc1", tasks[0].msg.Head.MessageID)
+	body := overriddenBody(t, tasks[0].msg)
+	assert.Contains(t, body, `"image":"registry.example.com/library/busybox"`)
+	assert.Contains(t, body, `"image":"registry.example.com/org/app:1.0"`)
+	assert.Contains(t, body, `"cpu":"500m"`)
+
+	assert.Equal(t, []string{"c2"}, tasks[1].clusters)
+	body = overriddenBody(t, tasks[1].msg)
+	assert.Contains(t, body, `"image":"registry.example.com/org/app:1.0"`)
+	assert.Contains(t, body, `"requests":{"cpu":"250m","memory":"64Mi"}`)
+	assert.Contains(t, body, `"limits":{"cpu":"500m","memory":"128Mi","nvidia.com/gpu":"1"}`)
+
+	assert.Equal(t, []string{"c5", "c6"}, tasks[2].clusters)
+	assert.Equal(t, overrideTestBody, overriddenBody(t, tasks[2].msg))
+	// the message of the task is not changed
+	assert.Equal(t, "c.*", msg.Head.ClusterSelector)
+	assert.Equal(t, overrideTestBody, overriddenBody(t, msg))
+
+	// c4 failed to override is not sent to
+	cc, err := client.OteV1().ClusterControllers(otev1.ClusterNamespace).Get("cc1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, cc.Status, 1)
+	assert.Equal(t, http.StatusUnprocessableEntity, cc.Status["c4"].StatusCode)
+	assert.Equal(t, otev1.ClusterControllerStatusOverrideFailed, cc.Status["c4"].Reason)
+	assert.Contains(t, cc.Status["c4"].Body, otev1.ClusterResourceScaleAnnotation)
+}
+
+func TestRegistTaskOverride(t *testing.T) {
+	origin := overrides
+	defer func() { overrides = origin }()
+	RegistTaskOverride(func(cluster *otev1.Cluster, task *clustermessage.ControllerTask) error {
+		if cluster.Labels["env"] == "" {
+			return fmt.Errorf("env is not labeled")
+		}
+		task.URI = task.URI + "?env=" + cluster.Labels["env"]
+		return nil
+	})
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	cluster := newOverrideCluster("c1", nil)
+	cluster.Labels = map[string]string{"env": "prod"}
+	indexer.Add(cluster)
+	c := &clusterHandler{clusterLister: otelisters.NewClusterLister(indexer)}
+	cc := &otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{Name: "cc1", Namespace: otev1.ClusterNamespace},
+		Spec:       otev1.ClusterControllerSpec{Method: http.MethodGet, URL: "/api/v1/pods"},
+	}
+	msg := clusterControllerCRDToClusterMessage(cc, clustermessage.CommandType_ControlReq)
+	tasks := c.overrideTasks("cc1", msg, []string{"c1"})
+	assert.Len(t, tasks, 1)
+	task := &clustermessage.ControllerTask{}
+	assert.Nil(t, proto.Unmarshal(tasks[0].msg.Body, task))
+	assert.Equal(t, "/api/v1/pods?env=prod", task.URI)
+
+	// tasks are overridden by root only.
+	c.clusterLister = nil
+	tasks = c.overrideTasks("cc1", msg, []string{"c1"})
+	assert.Equal(t, msg, tasks[0].msg)
+}
+
+func TestRewriteImageRegistry(t *testing.T) {
+	for image, expected := range map[string]string{
+		"nginx":                        "r.io/library/nginx",
+		"nginx:1.19":                   "r.io/library/nginx:1.19",
+		"org/app":                      "r.io/org/app",
+		"docker.io/org/app@sha256:abc": "r.io/org/app@sha256:abc",
+		"localhost/app":                "r.io/app",
+		"10.0.0.1:5000/org/app":        "r.io/org/app",
+	} {
+		assert.Equal(t, expected, rewriteImageRegistry(image, "r.io"), image)
+	}
+}
+
+func TestOverrideSealedBody(t *testing.T) {
+	cluster := newOverrideCluster("c1", map[string]string{otev1.ClusterImageRegistryAnnotation: "r.io"})
+	task := &clustermessage.ControllerTask{Body: []byte("ote-envelope:{}")}
+	assert.Nil(t, overrideImageRegistry(cluster, task))
+	assert.Equal(t, "ote-envelope:{}", string(task.Body))
+}
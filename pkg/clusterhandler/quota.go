@@ -0,0 +1,255 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterhandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/config"
+	"github.com/baidu/ote-stack/pkg/controllermanager"
+	"github.com/baidu/ote-stack/pkg/quota"
+	"github.com/baidu/ote-stack/pkg/reporter"
+)
+
+/*
+admitQuota removes clusters from tasks of ClusterController name whose ClusterQuotas
+are exceeded by pods the task creates, and records status QuotaExceeded of them.
+Only tasks posting, applying or putting workloads to apiserver are checked, after overridden
+so that tasks scaled for clusters are charged as sent. Tasks updating workloads are charged
+only the increase over those mirrored to center, and left to shims if the workloads are not
+mirrored. Usage is of pods mirrored to center, which lags behind the clusters, so that shims
+of clusters enforce quotas as well.
+*/
+func (c *clusterHandler) admitQuota(name string, tasks []overriddenTask) []overriddenTask {
+	if c.clusterQuotaLister == nil || len(tasks) == 0 ||
+		tasks[0].msg.Head.Command != clustermessage.CommandType_ControlReq {
+		return tasks
+	}
+	quotas, err := c.clusterQuotaLister.ClusterQuotas(otev1.ClusterNamespace).List(labels.Everything())
+	if err != nil {
+		klog.Errorf("list clusterquotas failed: %v", err)
+		return tasks
+	}
+	if len(quotas) == 0 {
+		return tasks
+	}
+
+	var ret []overriddenTask
+	exceeded := make(map[string]string)
+	for _, t := range tasks {
+		task, requested := c.taskRequests(t.msg)
+		if requested == nil {
+			ret = append(ret, t)
+			continue
+		}
+		var admitted []string
+		for _, cluster := range t.clusters {
+			r := requested
+			if existing := c.mirroredRequests(task, cluster); existing != nil {
+				r = quota.Subtract(requested, existing)
+			}
+			if reasons := quotaExceeded(quotas, cluster, r); len(reasons) != 0 {
+				exceeded[cluster] = strings.Join(reasons, "; ")
+				continue
+			}
+			admitted = append(admitted, cluster)
+		}
+		if len(admitted) == 0 {
+			continue
+		}
+		if len(admitted) != len(t.clusters) {
+			msg := proto.Clone(t.msg).(*clustermessage.ClusterMessage)
+			msg.Head.ClusterSelector = exactSelector(admitted)
+			t = overriddenTask{msg: msg, clusters: admitted}
+		}
+		ret = append(ret, t)
+	}
+	if len(exceeded) != 0 {
+		klog.Errorf("clustercontroller %s is not sent to clusters exceeding quotas: %v", name, exceeded)
+		c.mergeStatusToApiserver(quotaExceededClusterController(name, exceeded))
+	}
+	return ret
+}
+
+/*
+taskRequests returns the task of msg and resources requested by workloads it creates or updates,
+nil if it is not checked. Bodies not of json, e.g., sealed ones, and updates of workloads
+not mirrored to center are left to shims, since objects they update are unknown in center.
+*/
+func (c *clusterHandler) taskRequests(msg *clustermessage.ClusterMessage) (*clustermessage.ControllerTask,
+	corev1.ResourceList) {
+	task := &clustermessage.ControllerTask{}
+	if err := proto.Unmarshal(msg.Body, task); err != nil {
+		return nil, nil
+	}
+	switch {
+	case task.Destination == otev1.ClusterControllerDestAPI && task.Method == http.MethodPost:
+	case task.Destination == otev1.ClusterControllerDestApply && task.Method == http.MethodPost,
+		task.Destination == otev1.ClusterControllerDestAPI && task.Method == http.MethodPut:
+		if !c.mirrored(task.Body) {
+			return nil, nil
+		}
+	default:
+		return nil, nil
+	}
+	requested, ok, err := quota.Requests(task.Body)
+	if err != nil || !ok {
+		return nil, nil
+	}
+	return task, requested
+}
+
+// mirrored returns true if the workload of body is mirrored to center.
+func (c *clusterHandler) mirrored(body []byte) bool {
+	obj := &metav1.TypeMeta{}
+	if err := json.Unmarshal(body, obj); err != nil {
+		return false
+	}
+	switch obj.Kind {
+	case "Deployment":
+		return c.deploymentLister != nil
+	case "DaemonSet":
+		return c.daemonSetLister != nil
+	}
+	return false
+}
+
+/*
+mirroredRequests returns requests of the workload in cluster task updates, by the one mirrored
+to center, nil if the task creates the workload or it does not exist. Applied workloads
+are updated if they exist.
+*/
+func (c *clusterHandler) mirroredRequests(task *clustermessage.ControllerTask, cluster string) corev1.ResourceList {
+	if task.Destination == otev1.ClusterControllerDestAPI && task.Method == http.MethodPost {
+		return nil
+	}
+	obj := &struct {
+		metav1.TypeMeta `json:",inline"`
+		Metadata        metav1.ObjectMeta `json:"metadata"`
+	}{}
+	if err := json.Unmarshal(task.Body, obj); err != nil || obj.Metadata.Name == "" {
+		return nil
+	}
+	namespace := obj.Metadata.Namespace
+	if namespace == "" {
+		namespace = uriNamespace(task.URI)
+	}
+	name := obj.Metadata.Name + controllermanager.UniqueResourceNameSeparator + cluster
+
+	// objects of listers have no kind, which is set for requests of them.
+	existing := &struct {
+		Kind string      `json:"kind"`
+		Spec interface{} `json:"spec"`
+	}{Kind: obj.Kind}
+	switch obj.Kind {
+	case "Deployment":
+		deployment, err := c.deploymentLister.Deployments(namespace).Get(name)
+		if err != nil {
+			return nil
+		}
+		existing.Spec = &deployment.Spec
+	case "DaemonSet":
+		daemonSet, err := c.daemonSetLister.DaemonSets(namespace).Get(name)
+		if err != nil {
+			return nil
+		}
+		existing.Spec = &daemonSet.Spec
+	default:
+		return nil
+	}
+	raw, err := json.Marshal(existing)
+	if err != nil {
+		return nil
+	}
+	requests, ok, err := quota.Requests(raw)
+	if err != nil || !ok {
+		return nil
+	}
+	return requests
+}
+
+// uriNamespace returns the namespace of objects at uri, default if it is not namespaced.
+func uriNamespace(uri string) string {
+	parts := strings.Split(strings.Trim(uri, "/"), "/")
+	for i := 0; i+1 < len(parts); i++ {
+		if parts[i] == "namespaces" {
+			return parts[i+1]
+		}
+	}
+	return metav1.NamespaceDefault
+}
+
+// runMirroredWorkloadInformers watches workloads mirrored to center for mirroredRequests.
+func (c *clusterHandler) runMirroredWorkloadInformers(stopper chan struct{}) {
+	factory := kubeinformers.NewSharedInformerFactoryWithOptions(c.conf.KubeClient,
+		config.K8sInformerSyncDuration*time.Second,
+		kubeinformers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = reporter.ClusterLabel
+		}))
+	c.deploymentLister = factory.Apps().V1().Deployments().Lister()
+	c.daemonSetLister = factory.Apps().V1().DaemonSets().Lister()
+	factory.Start(stopper)
+}
+
+// quotaExceeded returns reasons of quotas of cluster exceeded if requested is added to pods of it.
+func quotaExceeded(quotas []*otev1.ClusterQuota, cluster string, requested corev1.ResourceList) []string {
+	var reasons []string
+	for _, q := range quotas {
+		if !quota.Selects(q, cluster) {
+			continue
+		}
+		for _, reason := range quota.Exceeded(q.Spec.Hard, q.Status.Used[cluster], requested) {
+			reasons = append(reasons, fmt.Sprintf("clusterquota %s %s", q.Name, reason))
+		}
+	}
+	return reasons
+}
+
+// quotaExceededClusterController returns the ClusterController with status QuotaExceeded
+// of clusters by reasons.
+func quotaExceededClusterController(name string, reasons map[string]string) *otev1.ClusterController {
+	cc := &otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: otev1.ClusterNamespace,
+		},
+		Status: make(map[string]otev1.ClusterControllerStatus, len(reasons)),
+	}
+	now := time.Now().Unix()
+	for cluster, reason := range reasons {
+		cc.Status[cluster] = otev1.ClusterControllerStatus{
+			Timestamp:  now,
+			StatusCode: http.StatusForbidden,
+			Body:       fmt.Sprintf("quota exceeded: %s", reason),
+			Reason:     otev1.ClusterControllerStatusQuotaExceeded,
+		}
+	}
+	return cc
+}
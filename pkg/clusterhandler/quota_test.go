@@ -0,0 +1,193 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterhandler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	"k8s.io/client-go/tools/cache"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned/fake"
+	otelisters "github.com/baidu/ote-stack/pkg/generated/listers/ote/v1"
+	"github.com/baidu/ote-stack/pkg/k8sclient"
+)
+
+func TestAdmitQuota(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	indexer.Add(&otev1.ClusterQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "edge", Namespace: otev1.ClusterNamespace},
+		Spec: otev1.ClusterQuotaSpec{
+			ClusterSelector: "^edge-",
+			Hard: corev1.ResourceList{
+				corev1.ResourcePods: resource.MustParse("4"),
+				corev1.ResourceCPU:  resource.MustParse("2"),
+			},
+		},
+		Status: otev1.ClusterQuotaStatus{Used: map[string]corev1.ResourceList{
+			"edge-1": {corev1.ResourcePods: resource.MustParse("1"), corev1.ResourceCPU: resource.MustParse("500m")},
+			"edge-2": {corev1.ResourcePods: resource.MustParse("3"), corev1.ResourceCPU: resource.MustParse("500m")},
+		}},
+	})
+	cc := &otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{Name: "cc1", Namespace: otev1.ClusterNamespace},
+		Spec: otev1.ClusterControllerSpec{
+			Destination: otev1.ClusterControllerDestAPI,
+			Method:      http.MethodPost,
+			URL:         "/apis/apps/v1/namespaces/default/deployments",
+			Body:        `{"kind":"Deployment","spec":{"replicas":2}}`,
+		},
+	}
+	client := oteclient.NewSimpleClientset(cc)
+	c := &clusterHandler{
+		clusterControllerCRD: k8sclient.NewClusterControllerCRD(client),
+		clusterQuotaLister:   otelisters.NewClusterQuotaLister(indexer),
+	}
+	msg := clusterControllerCRDToClusterMessage(cc, clustermessage.CommandType_ControlReq)
+	msg.Head.ClusterSelector = ".*"
+
+	tasks := c.admitQuota("cc1", []overriddenTask{{msg: msg, clusters: []string{"edge-1", "edge-2", "c1"}}})
+	assert.Len(t, tasks, 1)
+	assert.Equal(t, []string{"edge-1", "c1"}, tasks[0].clusters)
+	assert.Equal(t, exactSelector([]string{"edge-1", "c1"}), tasks[0].msg.Head.ClusterSelector)
+	// the message of the task is not changed
+	assert.Equal(t, ".*", msg.Head.ClusterSelector)
+
+	cc, err := client.OteV1().ClusterControllers(otev1.ClusterNamespace).Get("cc1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, cc.Status, 1)
+	assert.Equal(t, http.StatusForbidden, cc.Status["edge-2"].StatusCode)
+	assert.Equal(t, otev1.ClusterControllerStatusQuotaExceeded, cc.Status["edge-2"].Reason)
+	assert.Contains(t, cc.Status["edge-2"].Body, "clusterquota edge pods: 5 > 4")
+
+	// tasks not creating workloads are not checked.
+	cc.Spec.Body = `{"kind":"ConfigMap"}`
+	msg = clusterControllerCRDToClusterMessage(cc, clustermessage.CommandType_ControlReq)
+	tasks = c.admitQuota("cc1", []overriddenTask{{msg: msg, clusters: []string{"edge-2"}}})
+	assert.Equal(t, []string{"edge-2"}, tasks[0].clusters)
+	assert.Equal(t, msg, tasks[0].msg)
+
+	// tasks to clusters all over quota are dropped.
+	cc.Spec.Body = `{"kind":"Deployment","spec":{"replicas":4}}`
+	msg = clusterControllerCRDToClusterMessage(cc, clustermessage.CommandType_ControlReq)
+	tasks = c.admitQuota("cc1", []overriddenTask{{msg: msg, clusters: []string{"edge-1", "edge-2"}}})
+	assert.Empty(t, tasks)
+
+	// quotas are not checked if not watched.
+	c.clusterQuotaLister = nil
+	tasks = c.admitQuota("cc1", []overriddenTask{{msg: msg, clusters: []string{"edge-1"}}})
+	assert.Equal(t, []string{"edge-1"}, tasks[0].clusters)
+}
+
+func TestAdmitQuotaUpdates(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	indexer.Add(&otev1.ClusterQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "edge", Namespace: otev1.ClusterNamespace},
+		Spec: otev1.ClusterQuotaSpec{
+			ClusterSelector: "^edge-",
+			Hard:            corev1.ResourceList{corev1.ResourcePods: resource.MustParse("4")},
+		},
+		Status: otev1.ClusterQuotaStatus{Used: map[string]corev1.ResourceList{
+			"edge-1": {corev1.ResourcePods: resource.MustParse("1")},
+			"edge-2": {corev1.ResourcePods: resource.MustParse("3")},
+		}},
+	})
+	// deployment web mirrored from clusters.
+	replicas := []int32{1, 2}
+	deploymentIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for i, cluster := range []string{"edge-1", "edge-2"} {
+		deploymentIndexer.Add(&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-" + cluster, Namespace: "default"},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas[i]},
+		})
+	}
+	cc := &otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{Name: "cc1", Namespace: otev1.ClusterNamespace},
+		Spec: otev1.ClusterControllerSpec{
+			Destination: otev1.ClusterControllerDestApply,
+			Method:      http.MethodPost,
+			URL:         "/apis/apps/v1/namespaces/default/deployments",
+			Body:        `{"kind":"Deployment","metadata":{"name":"web"},"spec":{"replicas":2}}`,
+		},
+	}
+	client := oteclient.NewSimpleClientset(cc)
+	c := &clusterHandler{
+		clusterControllerCRD: k8sclient.NewClusterControllerCRD(client),
+		clusterQuotaLister:   otelisters.NewClusterQuotaLister(indexer),
+		deploymentLister:     appslisters.NewDeploymentLister(deploymentIndexer),
+		daemonSetLister:      appslisters.NewDaemonSetLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+	}
+	clusters := []string{"edge-1", "edge-2"}
+
+	// applying an unchanged workload is charged nothing.
+	msg := clusterControllerCRDToClusterMessage(cc, clustermessage.CommandType_ControlReq)
+	tasks := c.admitQuota("cc1", []overriddenTask{{msg: msg, clusters: clusters}})
+	assert.Len(t, tasks, 1)
+	assert.Equal(t, clusters, tasks[0].clusters)
+
+	// putting a workload is charged the increase of its pods.
+	cc.Spec.Destination = otev1.ClusterControllerDestAPI
+	cc.Spec.Method = http.MethodPut
+	cc.Spec.URL = "/apis/apps/v1/namespaces/default/deployments/web"
+	cc.Spec.Body = `{"kind":"Deployment","metadata":{"name":"web"},"spec":{"replicas":4}}`
+	msg = clusterControllerCRDToClusterMessage(cc, clustermessage.CommandType_ControlReq)
+	tasks = c.admitQuota("cc1", []overriddenTask{{msg: msg, clusters: clusters}})
+	assert.Len(t, tasks, 1)
+	assert.Equal(t, []string{"edge-1"}, tasks[0].clusters)
+	cc, err := client.OteV1().ClusterControllers(otev1.ClusterNamespace).Get("cc1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Contains(t, cc.Status["edge-2"].Body, "clusterquota edge pods: 5 > 4")
+
+	// applying a workload not existing is charged all its pods.
+	cc.Spec.Destination = otev1.ClusterControllerDestApply
+	cc.Spec.Method = http.MethodPost
+	cc.Spec.URL = "/apis/apps/v1/namespaces/default/deployments"
+	cc.Spec.Body = `{"kind":"Deployment","metadata":{"name":"api"},"spec":{"replicas":2}}`
+	msg = clusterControllerCRDToClusterMessage(cc, clustermessage.CommandType_ControlReq)
+	tasks = c.admitQuota("cc1", []overriddenTask{{msg: msg, clusters: clusters}})
+	assert.Len(t, tasks, 1)
+	assert.Equal(t, []string{"edge-1"}, tasks[0].clusters)
+
+	// updates of workloads not mirrored are left to shims.
+	cc.Spec.URL = "/apis/apps/v1/namespaces/default/statefulsets"
+	cc.Spec.Body = `{"kind":"StatefulSet","metadata":{"name":"db"},"spec":{"replicas":4}}`
+	msg = clusterControllerCRDToClusterMessage(cc, clustermessage.CommandType_ControlReq)
+	tasks = c.admitQuota("cc1", []overriddenTask{{msg: msg, clusters: clusters}})
+	assert.Len(t, tasks, 1)
+	assert.Equal(t, clusters, tasks[0].clusters)
+
+	// updates are left to shims if workloads mirrored are not watched.
+	c.deploymentLister = nil
+	cc.Spec.URL = "/apis/apps/v1/namespaces/default/deployments"
+	cc.Spec.Body = `{"kind":"Deployment","metadata":{"name":"api"},"spec":{"replicas":4}}`
+	msg = clusterControllerCRDToClusterMessage(cc, clustermessage.CommandType_ControlReq)
+	tasks = c.admitQuota("cc1", []overriddenTask{{msg: msg, clusters: clusters}})
+	assert.Equal(t, clusters, tasks[0].clusters)
+}
+
+func TestURINamespace(t *testing.T) {
+	assert.Equal(t, "kube-system", uriNamespace("/apis/apps/v1/namespaces/kube-system/deployments/web"))
+	assert.Equal(t, "default", uriNamespace("/apis/apps/v1/deployments"))
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterhandler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+// RedirectMessagePrefix is the prefix of id of Redirect messages.
+const RedirectMessagePrefix = "redirect-"
+
+/*
+Redirect sends a Redirect message to cluster in subtree, which asks it to close the connection
+to its parent and reconnect to the parent at address, e.g. to move a child to another root frontend.
+Responses of tasks in flight are held by the child while moving, and sent to the new parent.
+*/
+func (c *clusterHandler) Redirect(cluster, address string) error {
+	if cluster == "" || address == "" {
+		return fmt.Errorf("cluster and address are required to redirect")
+	}
+	task := &clustermessage.RedirectTask{Address: address}
+	msg, err := task.ToClusterMessage(&clustermessage.MessageHead{
+		MessageID:         RedirectMessagePrefix + string(uuid.NewUUID()),
+		Command:           clustermessage.CommandType_Redirect,
+		ClusterSelector:   exactSelector([]string{cluster}),
+		ClusterName:       c.conf.ClusterName,
+		ParentClusterName: c.conf.ClusterName,
+	})
+	if err != nil {
+		return err
+	}
+	selectedChild := c.selectChild(msg)
+	if len(selectedChild) == 0 {
+		return fmt.Errorf("cluster %s is not in subtree of %s", cluster, c.conf.ClusterName)
+	}
+	klog.Infof("redirect cluster %s to %s", cluster, address)
+	for port, portMsg := range selectedChild {
+		c.sendToChild(portMsg, port)
+	}
+	return nil
+}
+
+// Drain moves childs to the parent at alternate, or parent neighbors if empty, and stops the tunnel
+// to childs once they are moved or timeout passed.
+func (c *clusterHandler) Drain(alternate string, timeout time.Duration) error {
+	return c.tunn.Drain(alternate, timeout)
+}
+
+// RedirectHandler returns the admin handler redirecting cluster in query to address in query by ch.
+func RedirectHandler(ch ClusterHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		cluster := r.URL.Query().Get("cluster")
+		address := r.URL.Query().Get("address")
+		if err := ch.Redirect(cluster, address); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, "cluster %s is redirected to %s\n", cluster, address)
+	}
+}
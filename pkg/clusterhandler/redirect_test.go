@@ -0,0 +1,62 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterhandler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/clusterrouter"
+)
+
+func TestRedirect(t *testing.T) {
+	c := newFakeRootClusterHandler(t)
+	assert.NotNil(t, c.Redirect("redirected", ""))
+	// cluster not in subtree
+	assert.NotNil(t, c.Redirect("redirected", "f2:8287"))
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, fakeTunn.sendCalled)
+
+	clusterrouter.Router().AddRoute("redirected", "redirected")
+	defer clusterrouter.Router().DelRoute("redirected", "redirected")
+	assert.Nil(t, c.Redirect("redirected", "f2:8287"))
+	time.Sleep(100 * time.Millisecond)
+	assert.True(t, fakeTunn.sendCalled)
+}
+
+func TestRedirectHandler(t *testing.T) {
+	c := newFakeRootClusterHandler(t)
+	handler := RedirectHandler(c)
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/redirect?cluster=redirected&address=f2:8287", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, "/redirect?cluster=redirected", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	clusterrouter.Router().AddRoute("redirected", "redirected")
+	defer clusterrouter.Router().DelRoute("redirected", "redirected")
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, "/redirect?cluster=redirected&address=f2:8287", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,237 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterhandler
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+// rollout is a task dispatched by root in waves, applying its failure policy after each wave.
+type rollout struct {
+	spec otev1.ClusterControllerSpec
+	msg  *clustermessage.ClusterMessage
+	// waves are clusters of waves not dispatched.
+	waves [][]string
+	// waiting are the clusters of the current wave not responded.
+	waiting   map[string]bool
+	succeeded []string
+	failed    int
+}
+
+// rolloutStep is what to do after a wave of a rollout is done.
+type rolloutStep struct {
+	// wave is the next wave to dispatch by msg.
+	wave []string
+	msg  *clustermessage.ClusterMessage
+	// skipped are clusters not dispatched since the rollout stopped.
+	skipped []string
+	// rollback are clusters to roll back by task, failed is the number of clusters failed.
+	rollback []string
+	task     *otev1.ClusterControllerTask
+	policy   string
+	failed   int
+}
+
+// rollouts are rollouts of root by name of ClusterController.
+type rollouts struct {
+	lock sync.Mutex
+	m    map[string]*rollout
+}
+
+func newRollouts() *rollouts {
+	return &rollouts{m: make(map[string]*rollout)}
+}
+
+// needRollout returns true if spec is dispatched in waves or rolled back on failure.
+func needRollout(spec *otev1.ClusterControllerSpec) bool {
+	return spec.WaveSize > 0 || spec.FailurePolicy == otev1.ClusterControllerFailureRollback
+}
+
+// planRolloutWaves splits clusters sorted by name into waves of size, one wave if size is 0.
+func planRolloutWaves(clusters []string, size int) [][]string {
+	sorted := append([]string(nil), clusters...)
+	sort.Strings(sorted)
+	if size <= 0 || size > len(sorted) {
+		size = len(sorted)
+	}
+	var waves [][]string
+	for len(sorted) > 0 {
+		waves = append(waves, sorted[:size:size])
+		sorted = sorted[size:]
+		if size > len(sorted) {
+			size = len(sorted)
+		}
+	}
+	return waves
+}
+
+// start starts the rollout name of spec and task message msg to clusters, and returns the first wave.
+func (r *rollouts) start(name string, spec *otev1.ClusterControllerSpec,
+	msg *clustermessage.ClusterMessage, clusters []string) []string {
+	ro := &rollout{
+		spec:  *spec.DeepCopy(),
+		msg:   msg,
+		waves: planRolloutWaves(clusters, spec.WaveSize),
+	}
+	if len(ro.waves) == 0 {
+		return nil
+	}
+	wave := ro.nextWave()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.m[name] = ro
+	return wave
+}
+
+// nextWave pops the next wave and waits for its clusters.
+func (ro *rollout) nextWave() []string {
+	wave := ro.waves[0]
+	ro.waves = ro.waves[1:]
+	ro.waiting = make(map[string]bool, len(wave))
+	for _, cluster := range wave {
+		ro.waiting[cluster] = true
+	}
+	return wave
+}
+
+// responded records status of cluster of rollout name, and returns the step after the wave is done,
+// nil if the wave is not done or there is no such rollout.
+func (r *rollouts) responded(name, cluster string, status otev1.ClusterControllerStatus) *rolloutStep {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	ro, ok := r.m[name]
+	if !ok || !ro.waiting[cluster] {
+		return nil
+	}
+	delete(ro.waiting, cluster)
+	if status.StatusCode >= http.StatusOK && status.StatusCode < http.StatusMultipleChoices {
+		ro.succeeded = append(ro.succeeded, cluster)
+	} else {
+		ro.failed++
+	}
+	if len(ro.waiting) != 0 {
+		return nil
+	}
+
+	step := &rolloutStep{policy: ro.spec.FailurePolicy, failed: ro.failed}
+	if ro.failed > ro.spec.MaxFailures && ro.spec.FailurePolicy != "" &&
+		ro.spec.FailurePolicy != otev1.ClusterControllerFailureContinue {
+		for _, wave := range ro.waves {
+			step.skipped = append(step.skipped, wave...)
+		}
+		if ro.spec.FailurePolicy == otev1.ClusterControllerFailureRollback {
+			step.rollback = append(step.rollback, ro.succeeded...)
+			step.task = ro.spec.Rollback
+		}
+		delete(r.m, name)
+		return step
+	}
+	if len(ro.waves) == 0 {
+		delete(r.m, name)
+		return nil
+	}
+	step.wave = ro.nextWave()
+	step.msg = waveMessage(ro.msg, step.wave)
+	return step
+}
+
+// waveMessage returns the copy of task message msg to dispatch to clusters of wave.
+func waveMessage(msg *clustermessage.ClusterMessage, wave []string) *clustermessage.ClusterMessage {
+	ret := proto.Clone(msg).(*clustermessage.ClusterMessage)
+	ret.Head.ClusterSelector = exactSelector(wave)
+	return ret
+}
+
+// advanceRollout applies the step of rollout of cc after status of clusters merged.
+func (c *clusterHandler) advanceRollout(cc *otev1.ClusterController, merged map[string]otev1.ClusterControllerStatus) {
+	if c.rollouts == nil {
+		return
+	}
+	name := cc.ObjectMeta.Name
+	for cluster, status := range merged {
+		step := c.rollouts.responded(name, cluster, status)
+		if step == nil {
+			continue
+		}
+		if len(step.wave) != 0 {
+			klog.Infof("dispatch next wave of clustercontroller %s to %d clusters, %d failed",
+				name, len(step.wave), step.failed)
+			c.dispatch(name, step.msg, step.wave)
+			continue
+		}
+		klog.Warningf("clustercontroller %s stopped by failure policy %s, %d clusters failed, %d skipped",
+			name, step.policy, step.failed, len(step.skipped))
+		if len(step.skipped) != 0 {
+			c.mergeStatusToApiserver(skippedClusterController(name, step.skipped, step.policy, step.failed))
+		}
+		if len(step.rollback) != 0 {
+			c.rollback(name, step.task, step.rollback)
+		}
+	}
+}
+
+// rollback creates the ClusterController to send task to clusters succeeded in ClusterController name.
+func (c *clusterHandler) rollback(name string, task *otev1.ClusterControllerTask, clusters []string) {
+	sort.Strings(clusters)
+	cc := &otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name + "-rollback",
+			Namespace: otev1.ClusterNamespace,
+			Labels:    map[string]string{otev1.ClusterControllerRollbackLabel: name},
+		},
+		Spec: otev1.ClusterControllerSpec{
+			ClusterSelector: exactSelector(clusters),
+			Task:            task.DeepCopy(),
+		},
+	}
+	klog.Infof("roll back clustercontroller %s on %d clusters by %s", name, len(clusters), cc.ObjectMeta.Name)
+	if err := c.clusterControllerCRD.Create(cc); err != nil {
+		klog.Errorf("roll back clustercontroller %s failed: %v", name, err)
+	}
+}
+
+// skippedClusterController returns the ClusterController with status Skipped of clusters.
+func skippedClusterController(name string, clusters []string, policy string, failed int) *otev1.ClusterController {
+	cc := &otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: otev1.ClusterNamespace,
+		},
+		Status: make(map[string]otev1.ClusterControllerStatus, len(clusters)),
+	}
+	now := time.Now().Unix()
+	for _, cluster := range clusters {
+		cc.Status[cluster] = otev1.ClusterControllerStatus{
+			Timestamp:  now,
+			StatusCode: http.StatusPreconditionFailed,
+			Body:       fmt.Sprintf("stopped by failure policy %s after %d clusters failed", policy, failed),
+			Reason:     otev1.ClusterControllerStatusSkipped,
+		}
+	}
+	return cc
+}
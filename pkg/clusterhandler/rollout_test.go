@@ -0,0 +1,159 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterhandler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/clusterselector"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned/fake"
+	"github.com/baidu/ote-stack/pkg/k8sclient"
+)
+
+var (
+	succeededStatus = otev1.ClusterControllerStatus{StatusCode: http.StatusOK}
+	failedStatus    = otev1.ClusterControllerStatus{StatusCode: http.StatusInternalServerError}
+	testRollback    = &otev1.ClusterControllerTask{
+		Type:   otev1.ClusterControllerTaskDelete,
+		Object: &otev1.ObjectReference{APIVersion: "v1", Kind: "ConfigMap", Name: "cm1"},
+	}
+)
+
+func newRolloutMessage() *clustermessage.ClusterMessage {
+	return &clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{
+			MessageID:       "cc1",
+			Command:         clustermessage.CommandType_ControlReq,
+			ClusterSelector: "c1,c2,c3,c4,c5",
+		},
+	}
+}
+
+func TestNeedRollout(t *testing.T) {
+	assert.False(t, needRollout(&otev1.ClusterControllerSpec{}))
+	assert.False(t, needRollout(&otev1.ClusterControllerSpec{FailurePolicy: otev1.ClusterControllerFailureFailFast}))
+	assert.True(t, needRollout(&otev1.ClusterControllerSpec{WaveSize: 1}))
+	assert.True(t, needRollout(&otev1.ClusterControllerSpec{FailurePolicy: otev1.ClusterControllerFailureRollback}))
+}
+
+func TestPlanRolloutWaves(t *testing.T) {
+	clusters := []string{"c5", "c1", "c3", "c2", "c4"}
+	assert.Equal(t, [][]string{{"c1", "c2"}, {"c3", "c4"}, {"c5"}}, planRolloutWaves(clusters, 2))
+	assert.Equal(t, [][]string{{"c1", "c2", "c3", "c4", "c5"}}, planRolloutWaves(clusters, 0))
+	assert.Equal(t, [][]string{{"c1", "c2", "c3", "c4", "c5"}}, planRolloutWaves(clusters, 10))
+	assert.Empty(t, planRolloutWaves(nil, 2))
+	assert.Equal(t, []string{"c5", "c1", "c3", "c2", "c4"}, clusters)
+}
+
+func TestRolloutsContinue(t *testing.T) {
+	r := newRollouts()
+	spec := &otev1.ClusterControllerSpec{WaveSize: 2}
+	clusters := []string{"c1", "c2", "c3", "c4", "c5"}
+	assert.Equal(t, []string{"c1", "c2"}, r.start("cc1", spec, newRolloutMessage(), clusters))
+
+	assert.Nil(t, r.responded("cc1", "c1", failedStatus))
+	assert.Nil(t, r.responded("cc1", "c3", succeededStatus))
+	assert.Nil(t, r.responded("cc2", "c2", succeededStatus))
+	step := r.responded("cc1", "c2", succeededStatus)
+	assert.Equal(t, []string{"c3", "c4"}, step.wave)
+	assert.Equal(t, 1, step.failed)
+	selector := clusterselector.NewSelector(step.msg.Head.ClusterSelector)
+	assert.True(t, selector.Has("c3"))
+	assert.False(t, selector.Has("c1"))
+	assert.Equal(t, "c1,c2,c3,c4,c5", newRolloutMessage().Head.ClusterSelector)
+
+	r.responded("cc1", "c3", failedStatus)
+	step = r.responded("cc1", "c4", failedStatus)
+	assert.Equal(t, []string{"c5"}, step.wave)
+	assert.Nil(t, r.responded("cc1", "c5", succeededStatus))
+	assert.Empty(t, r.m)
+}
+
+func TestRolloutsStop(t *testing.T) {
+	clusters := []string{"c1", "c2", "c3", "c4", "c5"}
+	for _, policy := range []string{otev1.ClusterControllerFailureFailFast, otev1.ClusterControllerFailureRollback} {
+		r := newRollouts()
+		spec := &otev1.ClusterControllerSpec{WaveSize: 2, FailurePolicy: policy, MaxFailures: 1, Rollback: testRollback}
+		r.start("cc1", spec, newRolloutMessage(), clusters)
+
+		// failures tolerated.
+		r.responded("cc1", "c1", failedStatus)
+		step := r.responded("cc1", "c2", succeededStatus)
+		assert.Equal(t, []string{"c3", "c4"}, step.wave)
+
+		r.responded("cc1", "c3", succeededStatus)
+		step = r.responded("cc1", "c4", otev1.ClusterControllerStatus{
+			StatusCode: http.StatusGatewayTimeout, Reason: otev1.ClusterControllerStatusTimedOut})
+		assert.Empty(t, step.wave)
+		assert.Equal(t, policy, step.policy)
+		assert.Equal(t, 2, step.failed)
+		assert.Equal(t, []string{"c5"}, step.skipped)
+		if policy == otev1.ClusterControllerFailureRollback {
+			assert.Equal(t, []string{"c2", "c3"}, step.rollback)
+			assert.Equal(t, testRollback, step.task)
+		} else {
+			assert.Empty(t, step.rollback)
+		}
+		assert.Empty(t, r.m)
+	}
+}
+
+func TestAdvanceRollout(t *testing.T) {
+	client := oteclient.NewSimpleClientset(&otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{Name: "cc1", Namespace: otev1.ClusterNamespace},
+	})
+	c := &clusterHandler{
+		clusterControllerCRD: k8sclient.NewClusterControllerCRD(client),
+		rollouts:             newRollouts(),
+	}
+	spec := &otev1.ClusterControllerSpec{
+		WaveSize:      3,
+		FailurePolicy: otev1.ClusterControllerFailureRollback,
+		Rollback:      testRollback,
+	}
+	c.rollouts.start("cc1", spec, newRolloutMessage(), []string{"c1", "c2", "c3", "c4", "c5"})
+
+	c.mergeStatusToApiserver(&otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{Name: "cc1", Namespace: otev1.ClusterNamespace},
+		Status: map[string]otev1.ClusterControllerStatus{
+			"c1": succeededStatus,
+			"c2": succeededStatus,
+			"c3": failedStatus,
+		},
+	})
+
+	cc, err := client.OteV1().ClusterControllers(otev1.ClusterNamespace).Get("cc1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, cc.Status, 5)
+	assert.Equal(t, otev1.ClusterControllerStatusSkipped, cc.Status["c4"].Reason)
+	assert.Equal(t, http.StatusPreconditionFailed, cc.Status["c5"].StatusCode)
+
+	rollback, err := client.OteV1().ClusterControllers(otev1.ClusterNamespace).Get("cc1-rollback", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "cc1", rollback.Labels[otev1.ClusterControllerRollbackLabel])
+	assert.Equal(t, testRollback, rollback.Spec.Task)
+	selector := clusterselector.NewSelector(rollback.Spec.ClusterSelector)
+	assert.True(t, selector.Has("c1"))
+	assert.True(t, selector.Has("c2"))
+	assert.False(t, selector.Has("c3"))
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterhandler
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+)
+
+// DefaultTaskTimeout is the time root waits for responses of a task from clusters by default.
+const DefaultTaskTimeout = 10 * time.Minute
+
+// taskTrackInterval is the interval to check tasks timed out.
+var taskTrackInterval = 5 * time.Second
+
+// outstandingTask is a task dispatched by root and waiting for responses of clusters.
+type outstandingTask struct {
+	deadline time.Time
+	// waiting are the clusters not responded.
+	waiting map[string]bool
+}
+
+// taskTracker tracks tasks dispatched by root until all clusters dispatched to responded,
+// or the task is timed out.
+type taskTracker struct {
+	timeout time.Duration
+	lock    sync.Mutex
+	// tasks are outstanding tasks by name of ClusterController.
+	tasks map[string]*outstandingTask
+}
+
+func newTaskTracker(timeout time.Duration) *taskTracker {
+	return &taskTracker{
+		timeout: timeout,
+		tasks:   make(map[string]*outstandingTask),
+	}
+}
+
+// track starts tracking task name dispatched to clusters at now.
+func (t *taskTracker) track(name string, clusters []string, now time.Time) {
+	if len(clusters) == 0 {
+		return
+	}
+	task := &outstandingTask{
+		deadline: now.Add(t.timeout),
+		waiting:  make(map[string]bool, len(clusters)),
+	}
+	for _, cluster := range clusters {
+		task.waiting[cluster] = true
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.tasks[name] = task
+}
+
+// responded records the response of task name from cluster, the task is done if all clusters responded.
+func (t *taskTracker) responded(name, cluster string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	task, ok := t.tasks[name]
+	if !ok {
+		return
+	}
+	delete(task.waiting, cluster)
+	if len(task.waiting) == 0 {
+		delete(t.tasks, name)
+	}
+}
+
+// expire removes tasks timed out at now, and returns the clusters not responded by task name.
+func (t *taskTracker) expire(now time.Time) map[string][]string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	ret := make(map[string][]string)
+	for name, task := range t.tasks {
+		if now.Before(task.deadline) {
+			continue
+		}
+		clusters := make([]string, 0, len(task.waiting))
+		for cluster := range task.waiting {
+			clusters = append(clusters, cluster)
+		}
+		sort.Strings(clusters)
+		ret[name] = clusters
+		delete(t.tasks, name)
+	}
+	return ret
+}
+
+// runTaskTracker marks clusters not responded as TimedOut in ClusterControllers timed out.
+func (c *clusterHandler) runTaskTracker(stop <-chan struct{}) {
+	ticker := time.NewTicker(taskTrackInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		for name, clusters := range c.tracker.expire(time.Now()) {
+			klog.Warningf("clustercontroller %s timed out, clusters not responded: %v", name, clusters)
+			c.mergeStatusToApiserver(timedOutClusterController(name, clusters, c.tracker.timeout, time.Now()))
+		}
+	}
+}
+
+// timedOutClusterController returns the ClusterController with status TimedOut of clusters.
+func timedOutClusterController(name string, clusters []string,
+	timeout time.Duration, now time.Time) *otev1.ClusterController {
+	cc := &otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: otev1.ClusterNamespace,
+		},
+		Status: make(map[string]otev1.ClusterControllerStatus, len(clusters)),
+	}
+	for _, cluster := range clusters {
+		cc.Status[cluster] = otev1.ClusterControllerStatus{
+			Timestamp:  now.Unix(),
+			StatusCode: http.StatusGatewayTimeout,
+			Body:       fmt.Sprintf("no response in %v", timeout),
+			Reason:     otev1.ClusterControllerStatusTimedOut,
+		}
+	}
+	return cc
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterhandler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned/fake"
+	"github.com/baidu/ote-stack/pkg/k8sclient"
+)
+
+func TestTaskTracker(t *testing.T) {
+	tracker := newTaskTracker(time.Minute)
+	now := time.Now()
+	tracker.track("cc1", []string{"c1", "c2", "c3"}, now)
+	tracker.track("cc2", []string{"c1"}, now)
+	tracker.track("cc3", nil, now)
+	assert.Len(t, tracker.tasks, 2)
+
+	// task is done if all clusters responded.
+	tracker.responded("cc2", "c1")
+	tracker.responded("cc1", "c2")
+	tracker.responded("unknown", "c1")
+	assert.Len(t, tracker.tasks, 1)
+
+	assert.Empty(t, tracker.expire(now.Add(time.Second)))
+	expired := tracker.expire(now.Add(time.Minute))
+	assert.Equal(t, map[string][]string{"cc1": {"c1", "c3"}}, expired)
+	assert.Empty(t, tracker.tasks)
+}
+
+func TestMergeTimedOutStatus(t *testing.T) {
+	client := oteclient.NewSimpleClientset(&otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{Name: "cc1", Namespace: otev1.ClusterNamespace},
+		Status: map[string]otev1.ClusterControllerStatus{
+			"c1": {Timestamp: 100, StatusCode: http.StatusOK},
+		},
+	})
+	c := &clusterHandler{clusterControllerCRD: k8sclient.NewClusterControllerCRD(client)}
+	getStatus := func() map[string]otev1.ClusterControllerStatus {
+		cc, err := client.OteV1().ClusterControllers(otev1.ClusterNamespace).Get("cc1", metav1.GetOptions{})
+		assert.Nil(t, err)
+		return cc.Status
+	}
+
+	// status responded is kept.
+	c.mergeStatusToApiserver(timedOutClusterController("cc1", []string{"c1", "c2"}, time.Minute, time.Unix(200, 0)))
+	status := getStatus()
+	assert.Equal(t, http.StatusOK, status["c1"].StatusCode)
+	assert.Equal(t, http.StatusGatewayTimeout, status["c2"].StatusCode)
+	assert.Equal(t, otev1.ClusterControllerStatusTimedOut, status["c2"].Reason)
+
+	// status timed out is replaced by response later, even if the cluster clock is behind.
+	c.mergeStatusToApiserver(&otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{Name: "cc1", Namespace: otev1.ClusterNamespace},
+		Status: map[string]otev1.ClusterControllerStatus{
+			"c2": {Timestamp: 150, StatusCode: http.StatusCreated},
+		},
+	})
+	status = getStatus()
+	assert.Equal(t, http.StatusCreated, status["c2"].StatusCode)
+	assert.Empty(t, status["c2"].Reason)
+}
@@ -23,17 +23,19 @@ const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 type CommandType int32
 
 const (
-	CommandType_Reserved        CommandType = 0
-	CommandType_ClusterRegist   CommandType = 1
-	CommandType_ClusterUnregist CommandType = 2
-	CommandType_NeighborRoute   CommandType = 3
-	CommandType_SubTreeRoute    CommandType = 4
-	CommandType_DeployReq       CommandType = 5
-	CommandType_DeployResp      CommandType = 6
-	CommandType_ControlReq      CommandType = 7
-	CommandType_ControlResp     CommandType = 8
-	CommandType_EdgeReport      CommandType = 9
-	CommandType_ControlMultiReq CommandType = 10
+	CommandType_Reserved         CommandType = 0
+	CommandType_ClusterRegist    CommandType = 1
+	CommandType_ClusterUnregist  CommandType = 2
+	CommandType_NeighborRoute    CommandType = 3
+	CommandType_SubTreeRoute     CommandType = 4
+	CommandType_DeployReq        CommandType = 5
+	CommandType_DeployResp       CommandType = 6
+	CommandType_ControlReq       CommandType = 7
+	CommandType_ControlResp      CommandType = 8
+	CommandType_EdgeReport       CommandType = 9
+	CommandType_ControlMultiReq  CommandType = 10
+	CommandType_Redirect         CommandType = 11
+	CommandType_ControlMultiResp CommandType = 12
 )
 
 var CommandType_name = map[int32]string{
@@ -48,20 +50,24 @@ var CommandType_name = map[int32]string{
 	8:  "ControlResp",
 	9:  "EdgeReport",
 	10: "ControlMultiReq",
+	11: "Redirect",
+	12: "ControlMultiResp",
 }
 
 var CommandType_value = map[string]int32{
-	"Reserved":        0,
-	"ClusterRegist":   1,
-	"ClusterUnregist": 2,
-	"NeighborRoute":   3,
-	"SubTreeRoute":    4,
-	"DeployReq":       5,
-	"DeployResp":      6,
-	"ControlReq":      7,
-	"ControlResp":     8,
-	"EdgeReport":      9,
-	"ControlMultiReq": 10,
+	"Reserved":         0,
+	"ClusterRegist":    1,
+	"ClusterUnregist":  2,
+	"NeighborRoute":    3,
+	"SubTreeRoute":     4,
+	"DeployReq":        5,
+	"DeployResp":       6,
+	"ControlReq":       7,
+	"ControlResp":      8,
+	"EdgeReport":       9,
+	"ControlMultiReq":  10,
+	"Redirect":         11,
+	"ControlMultiResp": 12,
 }
 
 func (x CommandType) String() string {
@@ -198,6 +204,7 @@ type ControllerTask struct {
 	Method               string   `protobuf:"bytes,2,opt,name=Method,proto3" json:"Method,omitempty"`
 	URI                  string   `protobuf:"bytes,3,opt,name=URI,proto3" json:"URI,omitempty"`
 	Body                 []byte   `protobuf:"bytes,4,opt,name=Body,proto3" json:"Body,omitempty"`
+	IdempotencyKey       string   `protobuf:"bytes,5,opt,name=IdempotencyKey,proto3" json:"IdempotencyKey,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -256,6 +263,13 @@ func (m *ControllerTask) GetBody() []byte {
 	return nil
 }
 
+func (m *ControllerTask) GetIdempotencyKey() string {
+	if m != nil {
+		return m.IdempotencyKey
+	}
+	return ""
+}
+
 type ControllerTaskResponse struct {
 	Timestamp            int64    `protobuf:"varint,1,opt,name=Timestamp,proto3" json:"Timestamp,omitempty"`
 	StatusCode           int32    `protobuf:"varint,2,opt,name=StatusCode,proto3" json:"StatusCode,omitempty"`
@@ -311,6 +325,46 @@ func (m *ControllerTaskResponse) GetBody() []byte {
 	return nil
 }
 
+type RedirectTask struct {
+	// Address is the address of the parent to reconnect to.
+	Address              string   `protobuf:"bytes,1,opt,name=Address,proto3" json:"Address,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RedirectTask) Reset()         { *m = RedirectTask{} }
+func (m *RedirectTask) String() string { return proto.CompactTextString(m) }
+func (*RedirectTask) ProtoMessage()    {}
+func (*RedirectTask) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cb5c8b0b58767cdb, []int{4}
+}
+
+func (m *RedirectTask) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RedirectTask.Unmarshal(m, b)
+}
+func (m *RedirectTask) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RedirectTask.Marshal(b, m, deterministic)
+}
+func (m *RedirectTask) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RedirectTask.Merge(m, src)
+}
+func (m *RedirectTask) XXX_Size() int {
+	return xxx_messageInfo_RedirectTask.Size(m)
+}
+func (m *RedirectTask) XXX_DiscardUnknown() {
+	xxx_messageInfo_RedirectTask.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RedirectTask proto.InternalMessageInfo
+
+func (m *RedirectTask) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
+
 type DeployTask struct {
 	Replicas             int32             `protobuf:"varint,1,opt,name=Replicas,proto3" json:"Replicas,omitempty"`
 	PodParams            map[string]string `protobuf:"bytes,2,rep,name=PodParams,proto3" json:"PodParams,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
@@ -324,7 +378,7 @@ func (m *DeployTask) Reset()         { *m = DeployTask{} }
 func (m *DeployTask) String() string { return proto.CompactTextString(m) }
 func (*DeployTask) ProtoMessage()    {}
 func (*DeployTask) Descriptor() ([]byte, []int) {
-	return fileDescriptor_cb5c8b0b58767cdb, []int{4}
+	return fileDescriptor_cb5c8b0b58767cdb, []int{5}
 }
 
 func (m *DeployTask) XXX_Unmarshal(b []byte) error {
@@ -380,7 +434,7 @@ func (m *ControlMultiTask) Reset()         { *m = ControlMultiTask{} }
 func (m *ControlMultiTask) String() string { return proto.CompactTextString(m) }
 func (*ControlMultiTask) ProtoMessage()    {}
 func (*ControlMultiTask) Descriptor() ([]byte, []int) {
-	return fileDescriptor_cb5c8b0b58767cdb, []int{5}
+	return fileDescriptor_cb5c8b0b58767cdb, []int{6}
 }
 
 func (m *ControlMultiTask) XXX_Unmarshal(b []byte) error {
@@ -429,53 +483,107 @@ func (m *ControlMultiTask) GetBody() [][]byte {
 	return nil
 }
 
+type ControlMultiTaskResponse struct {
+	Timestamp int64 `protobuf:"varint,1,opt,name=Timestamp,proto3" json:"Timestamp,omitempty"`
+	// Items are the responses of bodies of the task in order.
+	Items                []*ControllerTaskResponse `protobuf:"bytes,2,rep,name=Items,proto3" json:"Items,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                  `json:"-"`
+	XXX_unrecognized     []byte                    `json:"-"`
+	XXX_sizecache        int32                     `json:"-"`
+}
+
+func (m *ControlMultiTaskResponse) Reset()         { *m = ControlMultiTaskResponse{} }
+func (m *ControlMultiTaskResponse) String() string { return proto.CompactTextString(m) }
+func (*ControlMultiTaskResponse) ProtoMessage()    {}
+func (*ControlMultiTaskResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cb5c8b0b58767cdb, []int{7}
+}
+
+func (m *ControlMultiTaskResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ControlMultiTaskResponse.Unmarshal(m, b)
+}
+func (m *ControlMultiTaskResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ControlMultiTaskResponse.Marshal(b, m, deterministic)
+}
+func (m *ControlMultiTaskResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ControlMultiTaskResponse.Merge(m, src)
+}
+func (m *ControlMultiTaskResponse) XXX_Size() int {
+	return xxx_messageInfo_ControlMultiTaskResponse.Size(m)
+}
+func (m *ControlMultiTaskResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ControlMultiTaskResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ControlMultiTaskResponse proto.InternalMessageInfo
+
+func (m *ControlMultiTaskResponse) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+func (m *ControlMultiTaskResponse) GetItems() []*ControllerTaskResponse {
+	if m != nil {
+		return m.Items
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterEnum("clustermessage.CommandType", CommandType_name, CommandType_value)
 	proto.RegisterType((*ClusterMessage)(nil), "clustermessage.ClusterMessage")
 	proto.RegisterType((*MessageHead)(nil), "clustermessage.MessageHead")
 	proto.RegisterType((*ControllerTask)(nil), "clustermessage.ControllerTask")
 	proto.RegisterType((*ControllerTaskResponse)(nil), "clustermessage.ControllerTaskResponse")
+	proto.RegisterType((*RedirectTask)(nil), "clustermessage.RedirectTask")
 	proto.RegisterType((*DeployTask)(nil), "clustermessage.DeployTask")
 	proto.RegisterMapType((map[string]string)(nil), "clustermessage.DeployTask.PodParamsEntry")
 	proto.RegisterType((*ControlMultiTask)(nil), "clustermessage.ControlMultiTask")
+	proto.RegisterType((*ControlMultiTaskResponse)(nil), "clustermessage.ControlMultiTaskResponse")
 }
 
 func init() { proto.RegisterFile("clustermessage.proto", fileDescriptor_cb5c8b0b58767cdb) }
 
 var fileDescriptor_cb5c8b0b58767cdb = []byte{
-	// 530 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x54, 0xc1, 0x6e, 0xda, 0x40,
-	0x10, 0xad, 0x31, 0x24, 0xf1, 0x90, 0x38, 0xce, 0x34, 0x8a, 0x50, 0x5a, 0x55, 0x88, 0x13, 0xad,
-	0x2a, 0x2a, 0xa5, 0xaa, 0x54, 0x55, 0x3d, 0x15, 0xa2, 0x36, 0x07, 0x22, 0xb4, 0xc0, 0x07, 0x2c,
-	0x78, 0x44, 0xdc, 0xd8, 0x5e, 0x77, 0x77, 0x1d, 0x89, 0x2f, 0xec, 0xa9, 0x7f, 0xd0, 0x8f, 0xa9,
-	0x76, 0xbd, 0x80, 0x21, 0xe7, 0xde, 0xfc, 0xde, 0xbe, 0x9d, 0x37, 0xf3, 0x76, 0x64, 0xb8, 0x5c,
-	0xa6, 0xa5, 0xd2, 0x24, 0x33, 0x52, 0x8a, 0xaf, 0x68, 0x50, 0x48, 0xa1, 0x05, 0x86, 0xfb, 0x6c,
-	0x6f, 0x0e, 0xe1, 0xb0, 0x62, 0xc6, 0x15, 0x83, 0x1f, 0xa0, 0xf9, 0x83, 0x78, 0xdc, 0xf1, 0xba,
-	0x5e, 0xbf, 0x7d, 0xf3, 0x6a, 0x70, 0x50, 0xc6, 0xc9, 0x8c, 0x84, 0x59, 0x21, 0x22, 0x34, 0xbf,
-	0x89, 0x78, 0xdd, 0x69, 0x74, 0xbd, 0xfe, 0x29, 0xb3, 0xdf, 0xbd, 0xbf, 0x1e, 0xb4, 0x6b, 0x4a,
-	0x7c, 0x0d, 0x81, 0x83, 0x77, 0x23, 0x5b, 0x39, 0x60, 0x3b, 0x02, 0x3f, 0xc1, 0xf1, 0x50, 0x64,
-	0x19, 0xcf, 0x63, 0x5b, 0x24, 0x7c, 0xee, 0xea, 0x8e, 0x67, 0xeb, 0x82, 0xd8, 0x46, 0x8b, 0x7d,
-	0x38, 0x77, 0xbd, 0x4f, 0x29, 0xa5, 0xa5, 0x16, 0xb2, 0xe3, 0xdb, 0xd2, 0x87, 0x34, 0x76, 0xa1,
-	0xed, 0xa8, 0x7b, 0x9e, 0x51, 0xa7, 0x69, 0x55, 0x75, 0x0a, 0xdf, 0xc3, 0xc5, 0x84, 0x4b, 0xca,
-	0x75, 0x5d, 0xd7, 0xb2, 0xba, 0xe7, 0x07, 0xbd, 0x02, 0xc2, 0xa1, 0xc8, 0xb5, 0x14, 0x69, 0x4a,
-	0x72, 0xc6, 0xd5, 0xa3, 0x71, 0x18, 0x91, 0xd2, 0x49, 0xce, 0x75, 0x22, 0x72, 0x37, 0x62, 0x9d,
-	0xc2, 0x2b, 0x38, 0x1a, 0x93, 0x7e, 0x10, 0xd5, 0x8c, 0x01, 0x73, 0x08, 0x23, 0xf0, 0xe7, 0xec,
-	0xce, 0x75, 0x6e, 0x3e, 0xb7, 0x81, 0x36, 0x6b, 0x81, 0xfe, 0x84, 0xab, 0x7d, 0x47, 0x46, 0xaa,
-	0x10, 0xb9, 0x22, 0x13, 0xed, 0x2c, 0xc9, 0x48, 0x69, 0x9e, 0x15, 0xd6, 0xd7, 0x67, 0x3b, 0x02,
-	0xdf, 0x00, 0x4c, 0x35, 0xd7, 0xa5, 0x1a, 0x8a, 0x98, 0xac, 0x73, 0x8b, 0xd5, 0x98, 0xad, 0x97,
-	0x5f, 0xf3, 0xfa, 0xed, 0x01, 0x8c, 0xa8, 0x48, 0xc5, 0xda, 0x8e, 0x76, 0x0d, 0x27, 0x8c, 0x8a,
-	0x34, 0x59, 0x72, 0x65, 0xeb, 0xb7, 0xd8, 0x16, 0xe3, 0x77, 0x08, 0x26, 0x22, 0x9e, 0x70, 0xc9,
-	0x33, 0xd5, 0x69, 0x74, 0xfd, 0x7e, 0xfb, 0xe6, 0xed, 0xe1, 0xdb, 0xed, 0x4a, 0x0d, 0xb6, 0xda,
-	0xdb, 0x5c, 0xcb, 0x35, 0xdb, 0xdd, 0x35, 0xe9, 0x54, 0x5d, 0xb9, 0x20, 0x1c, 0xba, 0xfe, 0x0a,
-	0xe1, 0xfe, 0x25, 0x93, 0xd7, 0x23, 0xad, 0x5d, 0xc2, 0xe6, 0x13, 0x2f, 0xa1, 0xf5, 0xc4, 0xd3,
-	0x92, 0x5c, 0xb0, 0x15, 0xf8, 0xd2, 0xf8, 0xec, 0xf5, 0x24, 0x44, 0x2e, 0xb5, 0x71, 0x99, 0xea,
-	0xe4, 0x3f, 0xbe, 0x94, 0xbf, 0x49, 0xef, 0xdd, 0x1f, 0x0f, 0xda, 0xb5, 0x75, 0xc5, 0x53, 0x13,
-	0x9f, 0x22, 0xf9, 0x44, 0x71, 0xf4, 0x02, 0x2f, 0xe0, 0xcc, 0x2d, 0x12, 0xa3, 0x55, 0xa2, 0x74,
-	0xe4, 0xe1, 0xcb, 0xed, 0x1a, 0xcf, 0x73, 0x59, 0x91, 0x0d, 0xa3, 0xbb, 0xa7, 0x64, 0xf5, 0xb0,
-	0x10, 0x92, 0x89, 0x52, 0x53, 0xe4, 0x63, 0x04, 0xa7, 0xd3, 0x72, 0x31, 0x93, 0x44, 0x15, 0xd3,
-	0xc4, 0x33, 0x08, 0xaa, 0x70, 0x19, 0xfd, 0x8a, 0x5a, 0x18, 0x6e, 0x9e, 0xcd, 0xec, 0x46, 0x74,
-	0x64, 0xb0, 0x9b, 0xde, 0x9c, 0x1f, 0xe3, 0xb9, 0x69, 0xcc, 0x61, 0x55, 0x44, 0x27, 0x46, 0x70,
-	0x1b, 0xaf, 0x88, 0x51, 0x21, 0xa4, 0x8e, 0x02, 0xdb, 0x49, 0x2d, 0x2e, 0x73, 0x0b, 0x16, 0x47,
-	0xf6, 0xc7, 0xf1, 0xf1, 0x5f, 0x00, 0x00, 0x00, 0xff, 0xff, 0xc2, 0xdd, 0x04, 0x31, 0x50, 0x04,
-	0x00, 0x00,
+	// 607 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x54, 0xdd, 0x6e, 0xd3, 0x30,
+	0x14, 0x26, 0x4d, 0xbb, 0xad, 0x27, 0x5d, 0x96, 0x99, 0x69, 0x8a, 0x06, 0x42, 0x55, 0x2f, 0xa6,
+	0x82, 0xd0, 0x90, 0x86, 0x90, 0x10, 0xda, 0x0d, 0x74, 0x13, 0x54, 0x68, 0xd3, 0xe4, 0x75, 0x0f,
+	0xe0, 0x35, 0x47, 0x5d, 0x58, 0x12, 0x07, 0xdb, 0x99, 0x94, 0x37, 0xe1, 0x8d, 0x78, 0x09, 0x5e,
+	0x82, 0x37, 0x40, 0xfe, 0x59, 0x9a, 0x76, 0x5c, 0x70, 0xc3, 0x5d, 0xce, 0xe7, 0xcf, 0xc7, 0x9f,
+	0xbf, 0xcf, 0x27, 0xb0, 0x37, 0xcf, 0x2a, 0xa9, 0x50, 0xe4, 0x28, 0x25, 0x5b, 0xe0, 0x51, 0x29,
+	0xb8, 0xe2, 0x24, 0x5c, 0x45, 0x47, 0xd7, 0x10, 0x4e, 0x2c, 0x72, 0x6e, 0x11, 0xf2, 0x06, 0xba,
+	0x5f, 0x90, 0x25, 0xb1, 0x37, 0xf4, 0xc6, 0xc1, 0xf1, 0xb3, 0xa3, 0xb5, 0x36, 0x8e, 0xa6, 0x29,
+	0xd4, 0x10, 0x09, 0x81, 0xee, 0x27, 0x9e, 0xd4, 0x71, 0x67, 0xe8, 0x8d, 0x07, 0xd4, 0x7c, 0x8f,
+	0x7e, 0x79, 0x10, 0xb4, 0x98, 0xe4, 0x39, 0xf4, 0x5d, 0x39, 0x3d, 0x35, 0x9d, 0xfb, 0x74, 0x09,
+	0x90, 0x77, 0xb0, 0x39, 0xe1, 0x79, 0xce, 0x8a, 0xc4, 0x34, 0x09, 0x1f, 0x9f, 0xea, 0x96, 0x67,
+	0x75, 0x89, 0xf4, 0x81, 0x4b, 0xc6, 0xb0, 0xe3, 0xb4, 0x5f, 0x61, 0x86, 0x73, 0xc5, 0x45, 0xec,
+	0x9b, 0xd6, 0xeb, 0x30, 0x19, 0x42, 0xe0, 0xa0, 0x0b, 0x96, 0x63, 0xdc, 0x35, 0xac, 0x36, 0x44,
+	0x5e, 0xc3, 0xee, 0x25, 0x13, 0x58, 0xa8, 0x36, 0xaf, 0x67, 0x78, 0x8f, 0x17, 0x46, 0x3f, 0x3c,
+	0x08, 0x27, 0xbc, 0x50, 0x82, 0x67, 0x19, 0x8a, 0x19, 0x93, 0x77, 0xfa, 0x88, 0x53, 0x94, 0x2a,
+	0x2d, 0x98, 0x4a, 0x79, 0xe1, 0xee, 0xd8, 0x86, 0xc8, 0x3e, 0x6c, 0x9c, 0xa3, 0xba, 0xe5, 0xf6,
+	0x92, 0x7d, 0xea, 0x2a, 0x12, 0x81, 0x7f, 0x4d, 0xa7, 0x4e, 0xba, 0xfe, 0x6c, 0x1c, 0xed, 0x2e,
+	0x1d, 0x25, 0x87, 0x10, 0x4e, 0x13, 0xcc, 0x4b, 0xae, 0xb0, 0x98, 0xd7, 0x5f, 0xb1, 0x76, 0xea,
+	0xd6, 0xd0, 0xd1, 0x37, 0xd8, 0x5f, 0x55, 0x46, 0x51, 0x96, 0xbc, 0x90, 0xa8, 0x33, 0x98, 0xa5,
+	0x39, 0x4a, 0xc5, 0xf2, 0xd2, 0xe8, 0xf3, 0xe9, 0x12, 0x20, 0x2f, 0x00, 0xae, 0x14, 0x53, 0x95,
+	0x9c, 0xf0, 0x04, 0x8d, 0xc2, 0x1e, 0x6d, 0x21, 0x8d, 0x26, 0xbf, 0x95, 0xf2, 0x18, 0x06, 0x14,
+	0x93, 0x54, 0xe0, 0x5c, 0x19, 0x0f, 0x62, 0xd8, 0xfc, 0x98, 0x24, 0x02, 0xa5, 0x74, 0xf7, 0x7f,
+	0x28, 0x47, 0x3f, 0x3d, 0x80, 0x53, 0x2c, 0x33, 0x5e, 0x1b, 0xe2, 0x01, 0x6c, 0x51, 0x2c, 0xb3,
+	0x74, 0xce, 0x2c, 0xb3, 0x47, 0x9b, 0x9a, 0x7c, 0x86, 0xfe, 0x25, 0x4f, 0x2e, 0x99, 0x60, 0xb9,
+	0x8c, 0x3b, 0x43, 0x7f, 0x1c, 0x1c, 0xbf, 0x5c, 0x7f, 0x0e, 0xcb, 0x56, 0x47, 0x0d, 0xf7, 0xac,
+	0x50, 0xa2, 0xa6, 0xcb, 0xbd, 0xda, 0x6f, 0xab, 0xdf, 0x59, 0xeb, 0xaa, 0x83, 0x13, 0x08, 0x57,
+	0x37, 0xe9, 0x04, 0xee, 0xb0, 0x76, 0x9a, 0xf5, 0x27, 0xd9, 0x83, 0xde, 0x3d, 0xcb, 0x2a, 0x74,
+	0x51, 0xd9, 0xe2, 0x43, 0xe7, 0xbd, 0x37, 0x12, 0x10, 0x39, 0x7f, 0xcf, 0xab, 0x4c, 0xa5, 0xff,
+	0x31, 0x7b, 0xbf, 0xf1, 0xf9, 0x1e, 0xe2, 0xf5, 0x33, 0xff, 0x31, 0xd5, 0x13, 0xe8, 0x4d, 0x15,
+	0x36, 0x46, 0x1e, 0x3e, 0x9e, 0xab, 0xbf, 0x3d, 0x15, 0x6a, 0x37, 0xbd, 0xfa, 0xed, 0x41, 0xd0,
+	0x9a, 0x3c, 0x32, 0xd0, 0xb1, 0x49, 0x14, 0xf7, 0x98, 0x44, 0x4f, 0xc8, 0x2e, 0x6c, 0xbb, 0x99,
+	0xa0, 0xb8, 0x48, 0xa5, 0x8a, 0x3c, 0xf2, 0xb4, 0x99, 0xc8, 0xeb, 0x42, 0x58, 0xb0, 0xa3, 0x79,
+	0x17, 0x98, 0x2e, 0x6e, 0x6f, 0xb8, 0xa0, 0xbc, 0x52, 0x18, 0xf9, 0x24, 0x82, 0xc1, 0x55, 0x75,
+	0x33, 0x13, 0x88, 0x16, 0xe9, 0x92, 0x6d, 0xe8, 0xdb, 0x50, 0x29, 0x7e, 0x8f, 0x7a, 0x24, 0x7c,
+	0x78, 0x2e, 0x5a, 0x52, 0xb4, 0xa1, 0x6b, 0x27, 0x55, 0xaf, 0x6f, 0x92, 0x1d, 0x08, 0x9a, 0x5a,
+	0x96, 0xd1, 0x96, 0x26, 0x9c, 0x25, 0x0b, 0xa4, 0x58, 0x72, 0xa1, 0xa2, 0xbe, 0x51, 0xd2, 0xb2,
+	0x4c, 0xef, 0x02, 0xab, 0xdf, 0xbe, 0xd7, 0x28, 0x20, 0x7b, 0xab, 0x49, 0x9a, 0x46, 0x83, 0x9b,
+	0x0d, 0xf3, 0x9f, 0x7c, 0xfb, 0x67, 0x00, 0xca, 0xe3, 0x76, 0xc0, 0x3f, 0x05, 0x00, 0x00,
 }
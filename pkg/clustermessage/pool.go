@@ -0,0 +1,79 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clustermessage
+
+import (
+	"fmt"
+	"sync"
+
+	proto "github.com/golang/protobuf/proto"
+)
+
+// maxPooledBufferSize is the max capacity of buffers put back to pool,
+// larger ones are dropped so that a burst of large messages does not pin memory.
+const maxPooledBufferSize = 1 << 20
+
+var (
+	bufferPool = sync.Pool{
+		New: func() interface{} {
+			return proto.NewBuffer(nil)
+		},
+	}
+	messagePool = sync.Pool{
+		New: func() interface{} {
+			return &ClusterMessage{}
+		},
+	}
+)
+
+// SerializeTo serializes a ClusterMessage into a pooled buffer and calls fn with the data.
+// The data is only valid until fn returns, fn must copy it if it is retained.
+func (c *ClusterMessage) SerializeTo(fn func(data []byte) error) error {
+	buf := bufferPool.Get().(*proto.Buffer)
+	defer putBuffer(buf)
+
+	// Marshal grows the buffer by the size of message at once.
+	buf.Reset()
+	if err := buf.Marshal(c); err != nil {
+		return fmt.Errorf("serialize cluster message(%v) failed: %v", c, err)
+	}
+	return fn(buf.Bytes())
+}
+
+func putBuffer(buf *proto.Buffer) {
+	if cap(buf.Bytes()) > maxPooledBufferSize {
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+// AcquireClusterMessage returns an empty ClusterMessage from pool.
+// It should be released by ReleaseClusterMessage when it is no longer used.
+func AcquireClusterMessage() *ClusterMessage {
+	return messagePool.Get().(*ClusterMessage)
+}
+
+// ReleaseClusterMessage resets a ClusterMessage and puts it back to pool.
+// Head and Body of the message are not reused, so they are still valid
+// for those holding them, but the message itself must not be used any more.
+func ReleaseClusterMessage(c *ClusterMessage) {
+	if c == nil {
+		return
+	}
+	c.Reset()
+	messagePool.Put(c)
+}
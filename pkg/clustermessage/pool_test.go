@@ -0,0 +1,100 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clustermessage
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestMessage(bodySize int) *ClusterMessage {
+	return &ClusterMessage{
+		Head: &MessageHead{
+			MessageID:   "1",
+			Command:     CommandType_EdgeReport,
+			ClusterName: "c1",
+		},
+		Body: bytes.Repeat([]byte{'a'}, bodySize),
+	}
+}
+
+func TestSerializeTo(t *testing.T) {
+	msg := newTestMessage(100)
+	expect, err := msg.Serialize()
+	assert.Nil(t, err)
+
+	for i := 0; i < 3; i++ {
+		err = msg.SerializeTo(func(data []byte) error {
+			assert.Equal(t, expect, data)
+			return nil
+		})
+		assert.Nil(t, err)
+	}
+
+	err = msg.SerializeTo(func(data []byte) error {
+		return fmt.Errorf("send failed")
+	})
+	assert.NotNil(t, err)
+
+	// large buffer is not put back to pool
+	large := newTestMessage(maxPooledBufferSize + 1)
+	assert.Nil(t, large.SerializeTo(func(data []byte) error { return nil }))
+}
+
+func TestAcquireReleaseClusterMessage(t *testing.T) {
+	data, err := newTestMessage(10).Serialize()
+	assert.Nil(t, err)
+
+	msg := AcquireClusterMessage()
+	assert.Nil(t, msg.Deserialize(data))
+	head := msg.Head
+	body := msg.Body
+	ReleaseClusterMessage(msg)
+	ReleaseClusterMessage(nil)
+
+	// head and body are still valid after release
+	assert.Equal(t, "c1", head.ClusterName)
+	assert.Len(t, body, 10)
+
+	msg = AcquireClusterMessage()
+	assert.Nil(t, msg.Head)
+	assert.Nil(t, msg.Body)
+	ReleaseClusterMessage(msg)
+}
+
+func BenchmarkSerialize(b *testing.B) {
+	msg := newTestMessage(16 << 10)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := msg.Serialize(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSerializeTo(b *testing.B) {
+	msg := newTestMessage(16 << 10)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := msg.SerializeTo(func([]byte) error { return nil }); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
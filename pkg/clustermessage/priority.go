@@ -0,0 +1,88 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clustermessage
+
+import (
+	proto "github.com/golang/protobuf/proto"
+)
+
+// Priority is the priority of messages sent by tunnels, the smaller the more urgent.
+type Priority int
+
+const (
+	// PriorityControl is of tasks and their responses.
+	PriorityControl Priority = iota
+	// PriorityRoute is of registries and routes of clusters.
+	PriorityRoute
+	// PriorityReport is of edge reports and messages unknown.
+	PriorityReport
+
+	// Priorities is the number of priorities.
+	Priorities = int(PriorityReport) + 1
+)
+
+var priorityNames = []string{"control", "route", "report"}
+
+func (p Priority) String() string {
+	if p < 0 || int(p) >= Priorities {
+		return "unknown"
+	}
+	return priorityNames[p]
+}
+
+// Priority returns the priority of messages of command c.
+func (c CommandType) Priority() Priority {
+	switch c {
+	case CommandType_ControlReq, CommandType_ControlResp, CommandType_ControlMultiReq, CommandType_ControlMultiResp,
+		CommandType_DeployReq, CommandType_DeployResp, CommandType_Redirect:
+		return PriorityControl
+	case CommandType_ClusterRegist, CommandType_ClusterUnregist,
+		CommandType_NeighborRoute, CommandType_SubTreeRoute:
+		return PriorityRoute
+	default:
+		return PriorityReport
+	}
+}
+
+// messageHead is a ClusterMessage only decoded with its head, skipping its body.
+type messageHead struct {
+	Head *MessageHead `protobuf:"bytes,1,opt,name=Head,proto3"`
+}
+
+func (m *messageHead) Reset()         { *m = messageHead{} }
+func (m *messageHead) String() string { return proto.CompactTextString(m) }
+func (*messageHead) ProtoMessage()    {}
+
+// HeadOf returns the head of ClusterMessage serialized in data without decoding its body,
+// nil if data is not a ClusterMessage.
+func HeadOf(data []byte) *MessageHead {
+	m := &messageHead{}
+	if err := proto.Unmarshal(data, m); err != nil {
+		return nil
+	}
+	return m.Head
+}
+
+// PriorityOf returns the priority of ClusterMessage serialized in data,
+// PriorityReport if data is not a ClusterMessage.
+func PriorityOf(data []byte) Priority {
+	head := HeadOf(data)
+	if head == nil {
+		return PriorityReport
+	}
+	return head.Command.Priority()
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clustermessage
+
+import (
+	"testing"
+
+	proto "github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriorityOf(t *testing.T) {
+	casetest := []struct {
+		command CommandType
+		expect  Priority
+	}{
+		{CommandType_ControlReq, PriorityControl},
+		{CommandType_ControlResp, PriorityControl},
+		{CommandType_Redirect, PriorityControl},
+		{CommandType_NeighborRoute, PriorityRoute},
+		{CommandType_SubTreeRoute, PriorityRoute},
+		{CommandType_ClusterRegist, PriorityRoute},
+		{CommandType_EdgeReport, PriorityReport},
+	}
+	for _, ct := range casetest {
+		data, err := proto.Marshal(&ClusterMessage{
+			Head: &MessageHead{Command: ct.command, ClusterName: "c1"},
+			Body: []byte("body"),
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, ct.expect, PriorityOf(data), ct.command.String())
+	}
+	assert.Equal(t, PriorityReport, PriorityOf([]byte("invalid")))
+	assert.Equal(t, PriorityReport, PriorityOf(nil))
+
+	assert.Equal(t, "control", PriorityControl.String())
+	assert.Equal(t, "report", PriorityReport.String())
+	assert.Equal(t, "unknown", Priority(Priorities).String())
+}
+
+func TestHeadOf(t *testing.T) {
+	data, err := proto.Marshal(&ClusterMessage{
+		Head: &MessageHead{MessageID: "m1", Command: CommandType_ControlReq},
+		Body: []byte("body"),
+	})
+	assert.Nil(t, err)
+	head := HeadOf(data)
+	assert.NotNil(t, head)
+	assert.Equal(t, "m1", head.MessageID)
+	assert.Equal(t, CommandType_ControlReq, head.Command)
+	assert.Nil(t, HeadOf([]byte("invalid")))
+}
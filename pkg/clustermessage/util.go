@@ -78,3 +78,21 @@ func (c *ControlMultiTask) ToClusterMessage(head *MessageHead) (*ClusterMessage,
 	}
 	return ret, nil
 }
+
+//ToClusterMessage makes RedirectTask to ClusterMessage.
+func (c *RedirectTask) ToClusterMessage(head *MessageHead) (*ClusterMessage, error) {
+	if head.Command != CommandType_Redirect {
+		return nil, fmt.Errorf("make RedirectTask to ClusterMessage failed: wrong command")
+	}
+
+	data, err := proto.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("make RedirectTask to ClusterMessage failed: %v", err)
+	}
+
+	ret := &ClusterMessage{
+		Head: head,
+		Body: data,
+	}
+	return ret, nil
+}
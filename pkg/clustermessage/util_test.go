@@ -61,4 +61,13 @@ func TestUtil(t *testing.T) {
 	m, err = controlMultiTask.ToClusterMessage(head2)
 	assert.NotNil(t, m)
 	assert.Nil(t, err)
+
+	redirectTask := &RedirectTask{Address: "127.0.0.1:8287"}
+	m, err = redirectTask.ToClusterMessage(head1)
+	assert.Nil(t, m)
+	assert.NotNil(t, err)
+
+	m, err = redirectTask.ToClusterMessage(&MessageHead{Command: CommandType_Redirect})
+	assert.NotNil(t, m)
+	assert.Nil(t, err)
 }
@@ -45,11 +45,7 @@ import (
 )
 
 var (
-	defaultClusterRouter = ClusterRouter{
-		Childs:        make(map[string]string),
-		subtreeRouter: make(map[string]string),
-		rwMutex:       &sync.RWMutex{},
-	}
+	defaultClusterRouter = NewClusterRouter()
 )
 
 // SubTreeRouter is a router which represents from a certain port to a certain node in subtree.
@@ -76,6 +72,8 @@ type ClusterRouter struct {
 	// subtreeRouter should not serialized to json string to send to childs or parent
 	// value should be string if cluster name is universally unique
 	subtreeRouter SubTreeRouter
+	// tombstones keeps previous names of clusters renamed, see TombstoneRoute
+	tombstones map[string]tombstone
 
 	rwMutex *sync.RWMutex
 }
@@ -102,7 +100,17 @@ type RouterNotifier func(*clustermessage.ClusterMessage, ...string)
 
 // Router returns the default cluster router.
 func Router() *ClusterRouter {
-	return &defaultClusterRouter
+	return defaultClusterRouter
+}
+
+// NewClusterRouter returns a new empty cluster router, which is used instead of the default one
+// if more than one cluster controller runs in a process.
+func NewClusterRouter() *ClusterRouter {
+	return &ClusterRouter{
+		Childs:        make(map[string]string),
+		subtreeRouter: make(map[string]string),
+		rwMutex:       &sync.RWMutex{},
+	}
 }
 
 // AddChild add a child named clusterName with listen addr,
@@ -118,14 +126,14 @@ func (cr *ClusterRouter) AddChild(clusterName, listen string, notifier RouterNot
 		}
 		cr.Childs[clusterName] = listen
 		klog.V(3).Infof("add child(%s-%s) to route", clusterName, listen)
-		klog.Infof("cluster neighbor router updated: %#v", defaultClusterRouter)
+		klog.Infof("cluster neighbor router updated: %#v", cr)
 		return nil
 	}()
 	if err != nil {
 		return err
 	}
 
-	notifier(defaultClusterRouter.NeighborRouterMessage())
+	notifier(cr.NeighborRouterMessage())
 
 	return nil
 }
@@ -143,10 +151,10 @@ func (cr *ClusterRouter) DelChild(clusterName string, notifier RouterNotifier) {
 		}
 		delete(cr.Childs, clusterName)
 		klog.V(3).Infof("del child(%s) from route", clusterName)
-		klog.Infof("cluster neighbor router updated: %#v", defaultClusterRouter)
+		klog.Infof("cluster neighbor router updated: %#v", cr)
 	}()
 
-	notifier(defaultClusterRouter.NeighborRouterMessage())
+	notifier(cr.NeighborRouterMessage())
 }
 
 // HasChild returns if the current node has a child named clusterName.
@@ -169,6 +177,8 @@ func (cr *ClusterRouter) AddRoute(to, port string) error {
 
 	if oldPort, ok := cr.subtreeRouter[to]; !ok {
 		cr.subtreeRouter[to] = port
+		// the name is used again by a cluster
+		delete(cr.tombstones, to)
 	} else if port != oldPort {
 		// there is a same name child to a diffrent port, refuse to add
 		klog.Errorf(
@@ -356,16 +366,21 @@ func neighborRouterFromClusterMessage(msg *clustermessage.ClusterMessage) *Clust
 	return &ret
 }
 
-// UpdateRouter updates router of current cluster and notify child.
+// UpdateRouter updates the default router of current cluster and notify child.
 func UpdateRouter(msg *clustermessage.ClusterMessage, notifier RouterNotifier) {
+	defaultClusterRouter.UpdateRouter(msg, notifier)
+}
+
+// UpdateRouter updates router of current cluster and notify child.
+func (cr *ClusterRouter) UpdateRouter(msg *clustermessage.ClusterMessage, notifier RouterNotifier) {
 	r := neighborRouterFromClusterMessage(msg)
 	if r == nil {
 		return
 	}
 	// r is route of parent
-	if defaultClusterRouter.updateNeighbor(r) {
-		notifier(defaultClusterRouter.NeighborRouterMessage())
+	if cr.updateNeighbor(r) {
+		notifier(cr.NeighborRouterMessage())
 	}
-	defaultClusterRouter.updateParentNeighbor(r)
-	klog.Infof("cluster router updated: %#v", defaultClusterRouter)
+	cr.updateParentNeighbor(r)
+	klog.Infof("cluster router updated: %#v", cr)
 }
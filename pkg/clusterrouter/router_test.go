@@ -144,3 +144,26 @@ func TestRoute(t *testing.T) {
 func testRouterNotifier(msg *clustermessage.ClusterMessage, tos ...string) {
 	calledNotifier = true
 }
+
+func TestNewClusterRouter(t *testing.T) {
+	r := NewClusterRouter()
+	assert.NotEqual(t, Router(), r)
+
+	var notified *clustermessage.ClusterMessage
+	assert.NoError(t, r.AddChild("new-c1", "192.168.0.3:1234", func(msg *clustermessage.ClusterMessage, tos ...string) {
+		notified = msg
+	}))
+	assert.True(t, r.HasChild("new-c1"))
+	assert.False(t, Router().HasChild("new-c1"))
+	neighbor := neighborRouterFromClusterMessage(notified)
+	assert.NotNil(t, neighbor)
+	assert.Contains(t, neighbor.Childs, "new-c1")
+
+	parentR := ClusterRouter{
+		Childs:  map[string]string{"new-c2": ""},
+		rwMutex: &sync.RWMutex{},
+	}
+	r.UpdateRouter(parentR.NeighborRouterMessage(), testRouterNotifier)
+	assert.Contains(t, r.Neighbor, "new-c2")
+	assert.NotContains(t, Router().Neighbor, "new-c2")
+}
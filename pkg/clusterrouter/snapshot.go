@@ -0,0 +1,91 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterrouter
+
+import (
+	"k8s.io/klog"
+)
+
+// Snapshot is the route info of a node saved for restoring after restart.
+// Childs are not included, since they are added again when childs reconnect.
+type Snapshot struct {
+	Neighbor       map[string]string `json:"neighbor,omitempty"`
+	ParentNeighbor map[string]string `json:"parentNeighbor,omitempty"`
+	Subtree        SubTreeRouter     `json:"subtree,omitempty"`
+}
+
+func copyMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	ret := make(map[string]string, len(m))
+	for k, v := range m {
+		ret[k] = v
+	}
+	return ret
+}
+
+// Snapshot returns a copy of route info of current node.
+func (cr *ClusterRouter) Snapshot() *Snapshot {
+	cr.rwMutex.RLock()
+	defer cr.rwMutex.RUnlock()
+
+	return &Snapshot{
+		Neighbor:       copyMap(cr.Neighbor),
+		ParentNeighbor: copyMap(cr.ParentNeighbor),
+		Subtree:        copyMap(cr.subtreeRouter),
+	}
+}
+
+// Restore restores route info from a snapshot, routes already exist are kept.
+// Restored routes are reachable once their ports reconnect,
+// and those of ports never reconnected are removed by ExpireRestoredRoutes.
+func (cr *ClusterRouter) Restore(s *Snapshot) {
+	if s == nil {
+		return
+	}
+	cr.rwMutex.Lock()
+	defer cr.rwMutex.Unlock()
+
+	if len(cr.Neighbor) == 0 {
+		cr.Neighbor = copyMap(s.Neighbor)
+	}
+	if len(cr.ParentNeighbor) == 0 {
+		cr.ParentNeighbor = copyMap(s.ParentNeighbor)
+	}
+	for to, port := range s.Subtree {
+		if _, ok := cr.subtreeRouter[to]; !ok {
+			cr.subtreeRouter[to] = port
+		}
+	}
+	klog.Infof("cluster router restored: %d neighbors, %d parent neighbors, %d routes",
+		len(s.Neighbor), len(s.ParentNeighbor), len(s.Subtree))
+}
+
+// ExpireRestoredRoutes removes routes whose ports are not childs of current node,
+// which are restored from snapshot but their ports never reconnected.
+func (cr *ClusterRouter) ExpireRestoredRoutes() {
+	cr.rwMutex.Lock()
+	defer cr.rwMutex.Unlock()
+
+	for to, port := range cr.subtreeRouter {
+		if _, ok := cr.Childs[port]; !ok {
+			delete(cr.subtreeRouter, to)
+			klog.Infof("route to %s from %s expired, %s is not reconnected", to, port, port)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterrouter
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRouter() *ClusterRouter {
+	return &ClusterRouter{
+		Childs:        map[string]string{},
+		subtreeRouter: SubTreeRouter{},
+		rwMutex:       &sync.RWMutex{},
+	}
+}
+
+func TestSnapshotAndRestore(t *testing.T) {
+	r := newTestRouter()
+	r.Childs["c1"] = "192.168.0.2:8287"
+	r.Neighbor = map[string]string{"n1": "192.168.0.3:8287"}
+	r.ParentNeighbor = map[string]string{"p1": "192.168.0.4:8287"}
+	r.subtreeRouter["c1"] = "c1"
+	r.subtreeRouter["c11"] = "c1"
+	r.subtreeRouter["c2"] = "c2"
+
+	s := r.Snapshot()
+	// snapshot is a copy
+	r.subtreeRouter["c3"] = "c3"
+	assert.Equal(t, SubTreeRouter{"c1": "c1", "c11": "c1", "c2": "c2"}, s.Subtree)
+
+	restored := newTestRouter()
+	restored.subtreeRouter["c2"] = "c1"
+	restored.Restore(s)
+	restored.Restore(nil)
+	assert.Empty(t, restored.Childs)
+	assert.Equal(t, s.Neighbor, restored.Neighbor)
+	assert.Equal(t, s.ParentNeighbor, restored.ParentNeighbor)
+	// existing route is kept
+	assert.Equal(t, SubTreeRouter{"c1": "c1", "c11": "c1", "c2": "c1"}, restored.subtreeRouter)
+
+	// c1 reconnected, routes from c2 expired
+	restored.Childs["c1"] = "192.168.0.2:8287"
+	restored.subtreeRouter["c2"] = "c2"
+	restored.ExpireRestoredRoutes()
+	assert.Equal(t, SubTreeRouter{"c1": "c1", "c11": "c1"}, restored.subtreeRouter)
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package clusterrouter
+
+import (
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/config"
+)
+
+var (
+	// TombstoneTTL is the time a renamed cluster is reachable by its previous name.
+	TombstoneTTL = 10 * time.Minute
+	// maxTombstoneHops is the max renames followed to resolve a previous name.
+	maxTombstoneHops = 8
+)
+
+// tombstone is the previous name of a cluster renamed.
+type tombstone struct {
+	name    string
+	expires time.Time
+}
+
+/*
+TombstoneRoute tombstones the route to previous, which is renamed to name reached from port.
+The route to previous is deleted, and messages to previous are routed to name
+by ResolveTombstones in TombstoneTTL.
+It fails if previous is reached from another port, which is used by another cluster.
+*/
+func (cr *ClusterRouter) TombstoneRoute(previous, name, port string) error {
+	if previous == "" || previous == name {
+		return nil
+	}
+	cr.rwMutex.Lock()
+	defer cr.rwMutex.Unlock()
+
+	if oldPort, ok := cr.subtreeRouter[previous]; ok && oldPort != port {
+		klog.Errorf("route to %s exists from port %s, tombstone it from port %s failed",
+			previous, oldPort, port)
+		return config.ErrDuplicatedName
+	}
+
+	if cr.tombstones == nil {
+		cr.tombstones = make(map[string]tombstone)
+	}
+	// routes to the subtree of previous are updated by subtree routes of name.
+	delete(cr.subtreeRouter, previous)
+	cr.tombstones[previous] = tombstone{name: name, expires: time.Now().Add(TombstoneTTL)}
+	// previous is not a tombstone of name any more if renamed back.
+	delete(cr.tombstones, name)
+	klog.Infof("route to %s is tombstoned, renamed to %s", previous, name)
+	return nil
+}
+
+/*
+ResolveTombstones returns the current names of clusters tombstoned and matched by has,
+with renames followed. Clusters with no route by their current names are not returned.
+*/
+func (cr *ClusterRouter) ResolveTombstones(has func(string) bool) []string {
+	cr.rwMutex.Lock()
+	defer cr.rwMutex.Unlock()
+
+	now := time.Now()
+	for previous, t := range cr.tombstones {
+		if !now.Before(t.expires) {
+			delete(cr.tombstones, previous)
+		}
+	}
+	var ret []string
+	resolved := make(map[string]bool)
+	for previous, t := range cr.tombstones {
+		if !has(previous) {
+			continue
+		}
+		name := t.name
+		for i := 0; i < maxTombstoneHops; i++ {
+			next, ok := cr.tombstones[name]
+			if !ok {
+				break
+			}
+			name = next.name
+		}
+		if _, ok := cr.subtreeRouter[name]; !ok || resolved[name] {
+			continue
+		}
+		resolved[name] = true
+		ret = append(ret, name)
+	}
+	return ret
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package clusterrouter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/config"
+)
+
+func TestTombstoneRoute(t *testing.T) {
+	r := NewClusterRouter()
+	has := func(names ...string) func(string) bool {
+		return func(name string) bool {
+			for _, n := range names {
+				if n == name {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	assert.NoError(t, r.AddRoute("c1", "c1"))
+	assert.NoError(t, r.AddRoute("old", "c1"))
+	assert.NoError(t, r.AddRoute("other", "c2"))
+
+	// nothing to tombstone
+	assert.NoError(t, r.TombstoneRoute("", "new", "c1"))
+	assert.NoError(t, r.TombstoneRoute("new", "new", "c1"))
+	assert.Empty(t, r.tombstones)
+	// previous is used by another cluster
+	assert.Equal(t, config.ErrDuplicatedName, r.TombstoneRoute("other", "new", "c1"))
+	assert.True(t, r.HasRoute("other", "c2"))
+
+	// the renamed cluster is not routed yet
+	assert.NoError(t, r.AddRoute("new", "c1"))
+	assert.NoError(t, r.TombstoneRoute("old", "new", "c1"))
+	assert.False(t, r.HasRoute("old", "c1"))
+	assert.NotContains(t, r.SubTreeClusters(), "old")
+	assert.Equal(t, []string{"new"}, r.ResolveTombstones(has("old")))
+	assert.Empty(t, r.ResolveTombstones(has("c1")))
+	// selected by both names
+	assert.Equal(t, []string{"new"}, r.ResolveTombstones(has("old", "new")))
+
+	// renamed again
+	assert.NoError(t, r.AddRoute("newer", "c1"))
+	assert.NoError(t, r.TombstoneRoute("new", "newer", "c1"))
+	assert.Equal(t, []string{"newer"}, r.ResolveTombstones(has("old")))
+	assert.ElementsMatch(t, []string{"newer"}, r.ResolveTombstones(has("old", "new")))
+	// renamed back
+	assert.NoError(t, r.AddRoute("old", "c1"))
+	assert.NotContains(t, r.tombstones, "old")
+	assert.Empty(t, r.ResolveTombstones(has("old")))
+
+	// the renamed cluster is unreachable
+	r.DelRoute("newer", "c1")
+	assert.Empty(t, r.ResolveTombstones(has("new")))
+}
+
+func TestTombstoneExpired(t *testing.T) {
+	ttl := TombstoneTTL
+	defer func() { TombstoneTTL = ttl }()
+	TombstoneTTL = 0
+
+	r := NewClusterRouter()
+	assert.NoError(t, r.AddRoute("new", "new"))
+	assert.NoError(t, r.TombstoneRoute("old", "new", "new"))
+	assert.Empty(t, r.ResolveTombstones(func(string) bool { return true }))
+	assert.Empty(t, r.tombstones)
+
+	TombstoneTTL = time.Minute
+	assert.NoError(t, r.TombstoneRoute("old", "new", "new"))
+	assert.Equal(t, []string{"new"}, r.ResolveTombstones(func(string) bool { return true }))
+}
@@ -0,0 +1,374 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package conformance tests a shim against the contract between cluster controller and shims,
+// so that shims built by third parties are verified to be compatible, e.g.,
+//
+//	func TestConformance(t *testing.T) {
+//		conformance.Run(t, clustershim.NewRemoteShimClient("conformance", addr), &conformance.Config{
+//			ListURI: "/api/v1/namespaces",
+//		})
+//	}
+//
+// The shim is used only by the tests while they run, responses of other messages are dropped.
+package conformance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/clustershim"
+)
+
+const (
+	// DefaultTimeout is the time to wait for a response of a task by default.
+	DefaultTimeout = 5 * time.Second
+	// silencePeriod is the time to wait for no response to a message not responded.
+	silencePeriod = 500 * time.Millisecond
+	// unknownDestination is a destination not supported by any shim.
+	unknownDestination = "conformance-unknown"
+	messageIDPrefix    = "conformance-"
+)
+
+// Config is the config of conformance tests.
+type Config struct {
+	// Timeout is the time to wait for a response of a task, DefaultTimeout if 0.
+	Timeout time.Duration
+	// Tasks are tasks of destinations to be responded with a 2xx status code.
+	// Destinations without a task are tested by GET /, and responses of any status code are accepted.
+	Tasks map[string]*clustermessage.ControllerTask
+	// ListURI is the uri listing objects of more than one item by destination api,
+	// e.g., /api/v1/namespaces, to test pagination. Pagination is not tested if empty.
+	ListURI string
+}
+
+func (c *Config) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return DefaultTimeout
+}
+
+// suite runs conformance tests of a shim.
+type suite struct {
+	conf *Config
+	shim clustershim.ShimServiceClient
+	seq  int64
+}
+
+// Run runs conformance tests of shim as subtests of t by config.
+func Run(t *testing.T, shim clustershim.ShimServiceClient, c *Config) {
+	if shim == nil {
+		t.Fatal("shim is nil")
+	}
+	if c == nil {
+		c = &Config{}
+	}
+	s := &suite{conf: c, shim: shim}
+	t.Run("Destinations", s.testDestinations)
+	t.Run("UnknownDestination", s.testUnknownDestination)
+	t.Run("TaskNotFound", s.testTaskNotFound)
+	t.Run("UnsupportedCommand", s.testUnsupportedCommand)
+	t.Run("Timeout", s.testTimeout)
+	t.Run("Canceled", s.testCanceled)
+	t.Run("Pagination", s.testPagination)
+}
+
+// testDestinations tests that tasks of all destinations of the shim are responded.
+func (s *suite) testDestinations(t *testing.T) {
+	dests := s.shim.Destinations()
+	if dests == nil {
+		t.Fatal("destinations are unknown, Destinations must return those supported by the shim")
+	}
+	for _, dest := range dests {
+		task, configured := s.conf.Tasks[dest]
+		if !configured {
+			task = &clustermessage.ControllerTask{Destination: dest, Method: http.MethodGet, URI: "/"}
+		}
+		resp, _, err := s.do(context.Background(), s.controlRequest(task))
+		if err != nil {
+			t.Errorf("task of destination %s is not responded: %v", dest, err)
+			continue
+		}
+		if resp.StatusCode < 100 || resp.StatusCode > 599 {
+			t.Errorf("task of destination %s is responded with invalid status code %d", dest, resp.StatusCode)
+			continue
+		}
+		if configured && !succeeded(resp) {
+			t.Errorf("task of destination %s is responded with status code %d: %s",
+				dest, resp.StatusCode, string(resp.Body))
+		}
+	}
+}
+
+// testUnknownDestination tests that a task of a destination not supported is responded with 404 or failed.
+func (s *suite) testUnknownDestination(t *testing.T) {
+	task := &clustermessage.ControllerTask{Destination: unknownDestination, Method: http.MethodGet, URI: "/"}
+	resp, doErr, err := s.do(context.Background(), s.controlRequest(task))
+	if err != nil {
+		if doErr {
+			return
+		}
+		t.Fatalf("task of an unknown destination is neither responded nor failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("task of an unknown destination is responded with status code %d, expected %d",
+			resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+// testTaskNotFound tests that a request without a valid task is responded with 404 or failed.
+func (s *suite) testTaskNotFound(t *testing.T) {
+	in := s.message(clustermessage.CommandType_ControlReq, []byte{0xff})
+	resp, doErr, err := s.do(context.Background(), in)
+	if err != nil {
+		if doErr {
+			return
+		}
+		t.Fatalf("request without a valid task is neither responded nor failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("request without a valid task is responded with status code %d, expected %d",
+			resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+// testUnsupportedCommand tests that a message of a command not done by shims is failed or not responded.
+func (s *suite) testUnsupportedCommand(t *testing.T) {
+	task := &clustermessage.ControllerTask{Destination: s.destination(), Method: http.MethodGet, URI: "/"}
+	body, err := proto.Marshal(task)
+	if err != nil {
+		t.Fatalf("marshal task failed: %v", err)
+	}
+	in := s.message(clustermessage.CommandType_NeighborRoute, body)
+	resp, err := s.shim.Do(context.Background(), in)
+	if err != nil {
+		return
+	}
+	if resp == nil {
+		resp = s.wait(in.Head.MessageID, silencePeriod)
+	}
+	if resp != nil {
+		t.Errorf("message of command %s is responded, expected to be failed or not responded",
+			clustermessage.CommandType_NeighborRoute.String())
+	}
+}
+
+// testTimeout tests that a task past its deadline is responded with 504.
+func (s *suite) testTimeout(t *testing.T) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	s.testContextDone(t, ctx, http.StatusGatewayTimeout)
+}
+
+// testCanceled tests that a task canceled is responded with 503.
+func (s *suite) testCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.testContextDone(t, ctx, http.StatusServiceUnavailable)
+}
+
+func (s *suite) testContextDone(t *testing.T, ctx context.Context, status int) {
+	task := &clustermessage.ControllerTask{Destination: s.destination(), Method: http.MethodGet, URI: "/"}
+	resp, _, err := s.do(ctx, s.controlRequest(task))
+	if err != nil {
+		t.Fatalf("task of context done is not responded: %v", err)
+	}
+	if int(resp.StatusCode) != status {
+		t.Errorf("task of context done by %v is responded with status code %d, expected %d",
+			ctx.Err(), resp.StatusCode, status)
+	}
+}
+
+// destination returns a destination supported by the shim, api if supported.
+func (s *suite) destination() string {
+	dests := s.shim.Destinations()
+	for _, dest := range dests {
+		if dest == otev1.ClusterControllerDestAPI {
+			return dest
+		}
+	}
+	if len(dests) == 0 {
+		return otev1.ClusterControllerDestAPI
+	}
+	return dests[0]
+}
+
+// list is a page of objects listed.
+type list struct {
+	Metadata struct {
+		Continue string `json:"continue"`
+	} `json:"metadata"`
+	Items []object `json:"items"`
+}
+
+type object struct {
+	Metadata objectMeta `json:"metadata"`
+}
+
+type objectMeta struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// testPagination tests that the limit and continue of lists are passed to apiserver.
+func (s *suite) testPagination(t *testing.T) {
+	if s.conf.ListURI == "" {
+		t.Skip("list uri is not set")
+	}
+	first, err := s.list(s.conf.ListURI, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Items) != 1 {
+		t.Fatalf("list of limit 1 returns %d items", len(first.Items))
+	}
+	if first.Metadata.Continue == "" {
+		t.Fatalf("list of limit 1 returns no continue token, %s must list more than one item", s.conf.ListURI)
+	}
+	next, err := s.list(s.conf.ListURI, first.Metadata.Continue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(next.Items) != 1 {
+		t.Fatalf("list continued returns %d items", len(next.Items))
+	}
+	if next.Items[0].Metadata == first.Items[0].Metadata {
+		t.Errorf("list continued returns the item of the first page %s", first.Items[0].Metadata.Name)
+	}
+}
+
+// list lists a page of one item at uri from continue token.
+func (s *suite) list(uri, token string) (*list, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid list uri %s: %v", uri, err)
+	}
+	query := u.Query()
+	query.Set("limit", "1")
+	if token != "" {
+		query.Set("continue", token)
+	}
+	u.RawQuery = query.Encode()
+	task := &clustermessage.ControllerTask{
+		Destination: otev1.ClusterControllerDestAPI,
+		Method:      http.MethodGet,
+		URI:         u.String(),
+	}
+	resp, _, err := s.do(context.Background(), s.controlRequest(task))
+	if err != nil {
+		return nil, fmt.Errorf("list %s is not responded: %v", u.String(), err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("list %s is responded with status code %d: %s",
+			u.String(), resp.StatusCode, string(resp.Body))
+	}
+	l := &list{}
+	if err := json.Unmarshal(resp.Body, l); err != nil {
+		return nil, fmt.Errorf("list %s is responded with an invalid list: %v", u.String(), err)
+	}
+	return l, nil
+}
+
+// message returns a message of command with body and a new message id.
+func (s *suite) message(command clustermessage.CommandType, body []byte) *clustermessage.ClusterMessage {
+	return &clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{
+			MessageID:         fmt.Sprintf("%s%d", messageIDPrefix, atomic.AddInt64(&s.seq, 1)),
+			Command:           command,
+			ClusterName:       "conformance",
+			ParentClusterName: "conformance-parent",
+		},
+		Body: body,
+	}
+}
+
+func (s *suite) controlRequest(task *clustermessage.ControllerTask) *clustermessage.ClusterMessage {
+	body, err := proto.Marshal(task)
+	if err != nil {
+		// a task of string fields is always marshaled.
+		panic(err)
+	}
+	return s.message(clustermessage.CommandType_ControlReq, body)
+}
+
+/*
+do does in by the shim, and returns the response of task, which is returned by Do,
+or sent to the return channel later.
+doErr is true if Do failed, err is not nil if in is not responded with a valid response.
+*/
+func (s *suite) do(ctx context.Context,
+	in *clustermessage.ClusterMessage) (resp *clustermessage.ControllerTaskResponse, doErr bool, err error) {
+	msg, err := s.shim.Do(ctx, in)
+	if err != nil && msg == nil {
+		return nil, true, fmt.Errorf("do failed: %v", err)
+	}
+	doErr = err != nil
+	if msg == nil {
+		msg = s.wait(in.Head.MessageID, s.conf.timeout())
+		if msg == nil {
+			return nil, doErr, fmt.Errorf("no response in %v", s.conf.timeout())
+		}
+	}
+	if msg.Head == nil {
+		return nil, doErr, fmt.Errorf("response has no head")
+	}
+	if msg.Head.MessageID != in.Head.MessageID {
+		return nil, doErr, fmt.Errorf("response is of message id %s, expected %s",
+			msg.Head.MessageID, in.Head.MessageID)
+	}
+	if msg.Head.Command != clustermessage.CommandType_ControlResp {
+		return nil, doErr, fmt.Errorf("response is of command %s, expected %s",
+			msg.Head.Command.String(), clustermessage.CommandType_ControlResp.String())
+	}
+	resp = &clustermessage.ControllerTaskResponse{}
+	if err := proto.Unmarshal(msg.Body, resp); err != nil {
+		return nil, doErr, fmt.Errorf("response is not a ControllerTaskResponse: %v", err)
+	}
+	return resp, doErr, nil
+}
+
+// wait waits for the response of message id from the return channel in timeout, nil if not responded.
+func (s *suite) wait(id string, timeout time.Duration) *clustermessage.ClusterMessage {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case msg, ok := <-s.shim.ReturnChan():
+			if !ok {
+				return nil
+			}
+			if msg != nil && msg.Head != nil && msg.Head.MessageID == id {
+				return msg
+			}
+		case <-deadline:
+			return nil
+		}
+	}
+}
+
+func succeeded(resp *clustermessage.ControllerTaskResponse) bool {
+	return resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conformance
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/clustershim"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+)
+
+// fakeAPIHandler lists namespaces by pages, and responds other tasks with an empty object.
+type fakeAPIHandler struct {
+	namespaces []string
+}
+
+func (f *fakeAPIHandler) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	task := handler.GetControllerTaskFromClusterMessage(in)
+	if task == nil {
+		return handler.Response(handler.ControlTaskResponse(http.StatusNotFound, ""), in.Head), handler.ErrTaskNotFound
+	}
+	u, err := url.Parse(task.URI)
+	if err != nil || u.Path != "/api/v1/namespaces" {
+		return handler.Response(handler.ControlTaskResponse(http.StatusOK, "{}"), in.Head), nil
+	}
+	start, _ := strconv.Atoi(u.Query().Get("continue"))
+	end := len(f.namespaces)
+	if limit, err := strconv.Atoi(u.Query().Get("limit")); err == nil && start+limit < end {
+		end = start + limit
+	}
+	l := &list{}
+	if end < len(f.namespaces) {
+		l.Metadata.Continue = strconv.Itoa(end)
+	}
+	for _, ns := range f.namespaces[start:end] {
+		l.Items = append(l.Items, object{Metadata: objectMeta{Name: ns}})
+	}
+	body, _ := json.Marshal(l)
+	return handler.Response(handler.ControlTaskResponse(http.StatusOK, string(body)), in.Head), nil
+}
+
+func fakeHandlers() clustershim.ShimHandler {
+	return clustershim.ShimHandler{
+		otev1.ClusterControllerDestAPI:  &fakeAPIHandler{namespaces: []string{"default", "kube-system"}},
+		otev1.ClusterControllerDestHelm: handler.NewHTTPProxyHandler("127.0.0.1:1"),
+	}
+}
+
+func TestRunLocalShim(t *testing.T) {
+	Run(t, clustershim.NewlocalShimClientWithHandler(fakeHandlers()), &Config{
+		Tasks: map[string]*clustermessage.ControllerTask{
+			otev1.ClusterControllerDestAPI: {
+				Destination: otev1.ClusterControllerDestAPI,
+				Method:      http.MethodGet,
+				URI:         "/api/v1/namespaces/default",
+			},
+		},
+		ListURI: "/api/v1/namespaces",
+	})
+}
+
+func TestRunRemoteShim(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conformance")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	addr := "unix://" + filepath.Join(dir, "shim.sock")
+	server := clustershim.NewShimServer()
+	for dest, h := range fakeHandlers() {
+		server.RegisterHandler(dest, h)
+	}
+	go server.Serve(addr)
+	defer server.Close()
+	time.Sleep(500 * time.Millisecond)
+
+	shim := clustershim.NewRemoteShimClient("conformance", addr)
+	require.NotNil(t, shim)
+	Run(t, shim, &Config{ListURI: "/api/v1/namespaces"})
+}
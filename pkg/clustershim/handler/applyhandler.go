@@ -0,0 +1,122 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+// applyHandler creates an object, or updates it if existed.
+type applyHandler struct {
+	restclient rest.Interface
+}
+
+// NewApplyHandler returns a new applyHandler, which creates the object in body of a task
+// by posting it to the uri of task, and updates the object if it already exists.
+func NewApplyHandler(cl kubernetes.Interface) Handler {
+	return &applyHandler{restclient: cl.Discovery().RESTClient()}
+}
+
+func (a *applyHandler) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	return a.DoContext(context.Background(), in)
+}
+
+func (a *applyHandler) DoContext(ctx context.Context,
+	in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	switch in.Head.Command {
+	case clustermessage.CommandType_ControlReq:
+		resp, err := a.DoControlRequest(ctx, in)
+		return Response(resp, in.Head), err
+	default:
+		return nil, Errorf(ErrUnsupportedCommand, "command %s is not supported by applyHandler", in.Head.Command.String())
+	}
+}
+
+func (a *applyHandler) DoControlRequest(ctx context.Context, in *clustermessage.ClusterMessage) ([]byte, error) {
+	controllerTask := GetControllerTaskFromClusterMessage(in)
+	if controllerTask == nil {
+		return ControlTaskResponse(http.StatusNotFound, ""), ErrTaskNotFound
+	}
+	if controllerTask.Method != http.MethodPost {
+		return ControlTaskResponse(http.StatusMethodNotAllowed, ""), ErrMethodNotAllowed
+	}
+	obj := make(map[string]interface{})
+	if err := json.Unmarshal(controllerTask.Body, &obj); err != nil {
+		return ControlTaskResponse(http.StatusBadRequest, err.Error()), err
+	}
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	if name == "" {
+		return ControlTaskResponse(http.StatusBadRequest, "name is required"), Errorf(ErrBadRequest, "name is required")
+	}
+
+	code, raw := a.request(ctx, http.MethodPost, controllerTask.URI, controllerTask.Body)
+	if ctx.Err() != nil {
+		return contextTaskResponse(ctx)
+	}
+	if code != http.StatusConflict {
+		return ControlTaskResponse(code, string(raw)), nil
+	}
+
+	// the object exists, update it with the resource version of the existing one.
+	uri := controllerTask.URI + "/" + name
+	code, raw = a.request(ctx, http.MethodGet, uri, nil)
+	if ctx.Err() != nil {
+		return contextTaskResponse(ctx)
+	}
+	if code != http.StatusOK {
+		return ControlTaskResponse(code, string(raw)), nil
+	}
+	existing := make(map[string]interface{})
+	if err := json.Unmarshal(raw, &existing); err != nil {
+		return ControlTaskResponse(http.StatusInternalServerError, err.Error()), err
+	}
+	existingMetadata, _ := existing["metadata"].(map[string]interface{})
+	metadata["resourceVersion"] = existingMetadata["resourceVersion"]
+	body, err := json.Marshal(obj)
+	if err != nil {
+		return ControlTaskResponse(http.StatusInternalServerError, err.Error()), err
+	}
+	klog.V(3).Infof("object %s exists, update it", uri)
+	code, raw = a.request(ctx, http.MethodPut, uri, body)
+	return ControlTaskResponse(code, string(raw)), nil
+}
+
+func (a *applyHandler) request(ctx context.Context, method, uri string, body []byte) (int, []byte) {
+	req := a.restclient.Verb(method).RequestURI(uri).Context(ctx)
+	if body != nil {
+		req.Body(body)
+	}
+	result := req.Do()
+	var code int
+	result.StatusCode(&code)
+	raw, err := result.Raw()
+	if code == 0 {
+		// apiserver is not reached.
+		return http.StatusBadGateway, []byte(fmt.Sprintf("%v", err))
+	}
+	return code, raw
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/kubernetes/scheme"
+	fakerest "k8s.io/client-go/rest/fake"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+func TestApplyHandler(t *testing.T) {
+	var methods []string
+	var lastBody []byte
+	fakeRestClient := &fakerest.RESTClient{
+		Client: fakerest.CreateHTTPClient(
+			func(req *http.Request) (*http.Response, error) {
+				methods = append(methods, req.Method+" "+req.URL.Path)
+				if req.Body != nil {
+					lastBody, _ = ioutil.ReadAll(req.Body)
+				}
+				code, body := http.StatusCreated, `{"kind":"ConfigMap"}`
+				switch {
+				case req.Method == http.MethodPost && req.URL.Path == "/api/v1/namespaces/default/configmaps":
+					code = http.StatusConflict
+				case req.Method == http.MethodGet:
+					code, body = http.StatusOK, `{"metadata":{"name":"cm1","resourceVersion":"5"}}`
+				case req.Method == http.MethodPut:
+					code = http.StatusOK
+				}
+				return &http.Response{
+					StatusCode: code,
+					Body:       ioutil.NopCloser(bytes.NewReader([]byte(body))),
+				}, nil
+			},
+		),
+		GroupVersion:         v1.SchemeGroupVersion,
+		NegotiatedSerializer: serializer.NewCodecFactory(scheme.Scheme),
+		VersionedAPIPath:     "/",
+	}
+	h := &applyHandler{restclient: fakeRestClient}
+	applyTask := func(uri, body string) *clustermessage.ClusterMessage {
+		task := &clustermessage.ControllerTask{Method: http.MethodPost, URI: uri, Body: []byte(body)}
+		msg, err := task.ToClusterMessage(&clustermessage.MessageHead{Command: clustermessage.CommandType_ControlReq})
+		assert.Nil(t, err)
+		return msg
+	}
+	getResp := func(msg *clustermessage.ClusterMessage) *clustermessage.ControllerTaskResponse {
+		resp := &clustermessage.ControllerTaskResponse{}
+		assert.Nil(t, proto.Unmarshal(msg.Body, resp))
+		return resp
+	}
+
+	// created.
+	msg, err := h.Do(applyTask("/api/v1/namespaces/ns1/configmaps", `{"metadata":{"name":"cm1"}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, int32(http.StatusCreated), getResp(msg).StatusCode)
+	assert.Equal(t, []string{"POST /api/v1/namespaces/ns1/configmaps"}, methods)
+
+	// updated with resource version of the existing one.
+	methods = nil
+	msg, err = h.Do(applyTask("/api/v1/namespaces/default/configmaps", `{"metadata":{"name":"cm1"}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, int32(http.StatusOK), getResp(msg).StatusCode)
+	assert.Equal(t, []string{
+		"POST /api/v1/namespaces/default/configmaps",
+		"GET /api/v1/namespaces/default/configmaps/cm1",
+		"PUT /api/v1/namespaces/default/configmaps/cm1",
+	}, methods)
+	assert.Contains(t, string(lastBody), `"resourceVersion":"5"`)
+
+	msg, err = h.Do(applyTask("/api/v1/namespaces/default/configmaps", `{"metadata":{}}`))
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(http.StatusBadRequest), getResp(msg).StatusCode)
+	msg, err = h.Do(applyTask("/api/v1/namespaces/default/configmaps", `{`))
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(http.StatusBadRequest), getResp(msg).StatusCode)
+
+	task := &clustermessage.ControllerTask{Method: http.MethodGet, URI: "/"}
+	msg, err = task.ToClusterMessage(&clustermessage.MessageHead{Command: clustermessage.CommandType_ControlReq})
+	assert.Nil(t, err)
+	msg, err = h.Do(msg)
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(http.StatusMethodNotAllowed), getResp(msg).StatusCode)
+
+	msg.Head.Command = clustermessage.CommandType_EdgeReport
+	_, err = h.Do(msg)
+	assert.NotNil(t, err)
+}
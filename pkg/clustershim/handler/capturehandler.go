@@ -0,0 +1,80 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/baidu/ote-stack/pkg/archive"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+// CaptureFunc starts or stops a capture, and returns the time the capture ends.
+type CaptureFunc func(archive.Capture) (time.Time, error)
+
+type captureHandler struct {
+	capture CaptureFunc
+}
+
+// NewCaptureHandler returns a new captureHandler, which starts a capture by capture
+// with the archive.Capture in json in body of a POST task, and stops it by a DELETE task.
+func NewCaptureHandler(capture CaptureFunc) Handler {
+	return &captureHandler{capture: capture}
+}
+
+func (c *captureHandler) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	switch in.Head.Command {
+	case clustermessage.CommandType_ControlReq:
+		resp, err := c.DoControlRequest(in)
+		return Response(resp, in.Head), err
+	default:
+		return nil, Errorf(ErrUnsupportedCommand, "command %s is not supported by captureHandler", in.Head.Command.String())
+	}
+}
+
+func (c *captureHandler) DoControlRequest(in *clustermessage.ClusterMessage) ([]byte, error) {
+	controllerTask := GetControllerTaskFromClusterMessage(in)
+	if controllerTask == nil {
+		return ControlTaskResponse(http.StatusNotFound, ""), ErrTaskNotFound
+	}
+
+	capture := archive.Capture{}
+	switch controllerTask.Method {
+	case http.MethodPost:
+		if err := json.Unmarshal(controllerTask.Body, &capture); err != nil {
+			return ControlTaskResponse(http.StatusBadRequest, err.Error()), err
+		}
+		if capture.Duration == 0 {
+			return ControlTaskResponse(http.StatusBadRequest, "duration is required"), Errorf(ErrBadRequest, "duration is required")
+		}
+	case http.MethodDelete:
+	default:
+		return ControlTaskResponse(http.StatusMethodNotAllowed, ""), ErrMethodNotAllowed
+	}
+
+	until, err := c.capture(capture)
+	if err != nil {
+		return ControlTaskResponse(http.StatusBadRequest, err.Error()), err
+	}
+	if capture.Duration == 0 {
+		return ControlTaskResponse(http.StatusOK, "capture stopped"), nil
+	}
+	return ControlTaskResponse(http.StatusOK,
+		fmt.Sprintf("capture messages at rate %v until %s", capture.Rate, until.Format(time.RFC3339))), nil
+}
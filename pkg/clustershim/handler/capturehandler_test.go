@@ -0,0 +1,91 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/archive"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+func makeCaptureMessage(method, body string, t *testing.T) *clustermessage.ClusterMessage {
+	data, err := proto.Marshal(&clustermessage.ControllerTask{
+		Method: method,
+		Body:   []byte(body),
+	})
+	assert.Nil(t, err)
+	return &clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{
+			Command: clustermessage.CommandType_ControlReq,
+		},
+		Body: data,
+	}
+}
+
+func captureResponse(t *testing.T, resp *clustermessage.ClusterMessage) *clustermessage.ControllerTaskResponse {
+	taskResp := &clustermessage.ControllerTaskResponse{}
+	assert.Nil(t, proto.Unmarshal(resp.Body, taskResp))
+	return taskResp
+}
+
+func TestCaptureHandlerDo(t *testing.T) {
+	until := time.Date(2019, 10, 31, 23, 30, 0, 0, time.UTC)
+	var captured *archive.Capture
+	h := NewCaptureHandler(func(c archive.Capture) (time.Time, error) {
+		if c.Rate > 1 {
+			return time.Time{}, fmt.Errorf("rate invalid")
+		}
+		captured = &c
+		return until, nil
+	})
+
+	// unsupportable command
+	msg := makeCaptureMessage(http.MethodPost, "", t)
+	msg.Head.Command = clustermessage.CommandType_NeighborRoute
+	resp, err := h.Do(msg)
+	assert.Nil(t, resp)
+	assert.NotNil(t, err)
+
+	_, err = h.Do(makeCaptureMessage(http.MethodGet, "", t))
+	assert.NotNil(t, err)
+	_, err = h.Do(makeCaptureMessage(http.MethodPost, "{", t))
+	assert.NotNil(t, err)
+	_, err = h.Do(makeCaptureMessage(http.MethodPost, `{"rate":0.5}`, t))
+	assert.NotNil(t, err)
+
+	resp, err = h.Do(makeCaptureMessage(http.MethodPost, `{"rate":2,"duration":60000000000}`, t))
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(http.StatusBadRequest), captureResponse(t, resp).StatusCode)
+
+	resp, err = h.Do(makeCaptureMessage(http.MethodPost, `{"rate":0.5,"duration":60000000000}`, t))
+	assert.Nil(t, err)
+	assert.Equal(t, &archive.Capture{Rate: 0.5, Duration: time.Minute}, captured)
+	taskResp := captureResponse(t, resp)
+	assert.Equal(t, int32(http.StatusOK), taskResp.StatusCode)
+	assert.Contains(t, string(taskResp.Body), "2019-10-31T23:30:00Z")
+
+	resp, err = h.Do(makeCaptureMessage(http.MethodDelete, "", t))
+	assert.Nil(t, err)
+	assert.Equal(t, &archive.Capture{}, captured)
+	assert.Equal(t, int32(http.StatusOK), captureResponse(t, resp).StatusCode)
+}
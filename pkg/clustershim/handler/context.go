@@ -0,0 +1,82 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+// ContextHandler is a Handler which also does a message with a context,
+// and stops calls to apiserver once the context is done, e.g., timed out or canceled on shutdown.
+type ContextHandler interface {
+	Handler
+	DoContext(ctx context.Context, in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error)
+}
+
+// DoContext does in by h with ctx if h is a ContextHandler, or by Do of h otherwise.
+// in is not done if ctx is already done.
+func DoContext(ctx context.Context, h Handler, in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	if ctx.Err() != nil {
+		return ContextResponse(ctx, in.Head)
+	}
+	if c, ok := h.(ContextHandler); ok {
+		return c.DoContext(ctx, in)
+	}
+	return h.Do(in)
+}
+
+// ContextResponse returns the response to the request of head, which is not done since ctx is done,
+// and the error of ctx.
+func ContextResponse(ctx context.Context,
+	head *clustermessage.MessageHead) (*clustermessage.ClusterMessage, error) {
+	body, err := contextTaskResponse(ctx)
+	head = proto.Clone(head).(*clustermessage.MessageHead)
+	head.Command = clustermessage.CommandType_ControlResp
+	return Response(body, head), err
+}
+
+// contextTaskResponse returns the response of a task stopped since ctx is done, and the error of ctx.
+func contextTaskResponse(ctx context.Context) ([]byte, error) {
+	status, err := contextStatus(ctx)
+	return ControlTaskResponse(status, err.Error()), err
+}
+
+// contextStatus returns the status code of a task stopped since ctx is done, and the error of ctx.
+func contextStatus(ctx context.Context) (int, error) {
+	if ctx.Err() == context.DeadlineExceeded {
+		return http.StatusGatewayTimeout, Errorf(ErrTimedOut, "task %s timed out", TraceID(ctx))
+	}
+	return http.StatusServiceUnavailable, Errorf(ErrCanceled, "task %s canceled", TraceID(ctx))
+}
+
+type traceIDKey struct{}
+
+// WithTraceID returns a copy of ctx carrying id, e.g., the id of the message done with ctx,
+// which is logged by handlers to correlate calls of the message.
+func WithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, id)
+}
+
+// TraceID returns the trace id carried by ctx, empty if not set.
+func TraceID(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/errorkind"
+)
+
+// traceHandler records the trace id of the context of tasks.
+type traceHandler struct {
+	countHandler
+	traceID string
+}
+
+func (h *traceHandler) DoContext(ctx context.Context,
+	in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	h.traceID = TraceID(ctx)
+	return h.Do(in)
+}
+
+func statusOf(t *testing.T, resp *clustermessage.ClusterMessage) int32 {
+	taskResp := &clustermessage.ControllerTaskResponse{}
+	assert.Nil(t, proto.Unmarshal(resp.Body, taskResp))
+	return taskResp.StatusCode
+}
+
+func TestDoContext(t *testing.T) {
+	h := &traceHandler{countHandler: countHandler{code: http.StatusOK}}
+	ctx := WithTraceID(context.Background(), "1")
+	resp, err := DoContext(ctx, h, makeTaskMessage(t, "1", http.MethodGet, "/api/v1/pods"))
+	assert.Nil(t, err)
+	assert.Equal(t, int32(http.StatusOK), statusOf(t, resp))
+	assert.Equal(t, "1", h.traceID)
+
+	// handlers without context are done by Do.
+	c := &countHandler{code: http.StatusOK}
+	_, err = DoContext(ctx, c, makeTaskMessage(t, "2", http.MethodGet, "/api/v1/pods"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, c.count)
+
+	// tasks are not done if the context is done.
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	resp, err = DoContext(canceled, c, makeTaskMessage(t, "3", http.MethodGet, "/api/v1/pods"))
+	assert.True(t, errorkind.Is(err, ErrCanceled))
+	assert.Equal(t, int32(http.StatusServiceUnavailable), statusOf(t, resp))
+	assert.Equal(t, clustermessage.CommandType_ControlResp, resp.Head.Command)
+	assert.Equal(t, "3", resp.Head.MessageID)
+
+	timedOut, cancel := context.WithTimeout(ctx, -time.Second)
+	defer cancel()
+	resp, err = DoContext(timedOut, c, makeTaskMessage(t, "4", http.MethodGet, "/api/v1/pods"))
+	assert.True(t, errorkind.Is(err, ErrTimedOut))
+	assert.Equal(t, ErrTimedOut, KindOf(err))
+	assert.Equal(t, int32(http.StatusGatewayTimeout), statusOf(t, resp))
+	assert.Equal(t, 1, c.count)
+	assert.Nil(t, KindOf(fmt.Errorf("task timed out")))
+}
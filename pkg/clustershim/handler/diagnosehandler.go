@@ -0,0 +1,113 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"net/http"
+	"sort"
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+// DiagnoseCollector collects the content of a file in the support bundle.
+type DiagnoseCollector func() ([]byte, error)
+
+type diagnoseHandler struct {
+	collectors map[string]DiagnoseCollector
+}
+
+// NewDiagnoseHandler returns a new diagnoseHandler, which packs the output
+// of collectors to a tar.gz support bundle, keyed by file name in the bundle.
+// The bundle is base64 encoded in the response body.
+func NewDiagnoseHandler(collectors map[string]DiagnoseCollector) Handler {
+	return &diagnoseHandler{collectors: collectors}
+}
+
+func (d *diagnoseHandler) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	switch in.Head.Command {
+	case clustermessage.CommandType_ControlReq:
+		resp, err := d.DoControlRequest(in)
+		return Response(resp, in.Head), err
+	default:
+		return nil, Errorf(ErrUnsupportedCommand, "command %s is not supported by diagnoseHandler", in.Head.Command.String())
+	}
+}
+
+func (d *diagnoseHandler) DoControlRequest(in *clustermessage.ClusterMessage) ([]byte, error) {
+	controllerTask := GetControllerTaskFromClusterMessage(in)
+	if controllerTask == nil {
+		return ControlTaskResponse(http.StatusNotFound, ""), ErrTaskNotFound
+	}
+
+	if controllerTask.Method != http.MethodGet {
+		return ControlTaskResponse(http.StatusMethodNotAllowed, ""), ErrMethodNotAllowed
+	}
+
+	bundle, err := d.collect()
+	if err != nil {
+		return ControlTaskResponse(http.StatusInternalServerError, err.Error()), err
+	}
+	return ControlTaskResponse(http.StatusOK, base64.StdEncoding.EncodeToString(bundle)), nil
+}
+
+// collect runs all collectors and packs the output to a tar.gz bundle.
+// The error of a collector is written to file <name>.error instead of failing the bundle.
+func (d *diagnoseHandler) collect() ([]byte, error) {
+	names := make([]string, 0, len(d.collectors))
+	for name := range d.collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	now := time.Now()
+	for _, name := range names {
+		data, err := d.collectors[name]()
+		if err != nil {
+			klog.Errorf("diagnose collector %s failed: %v", name, err)
+			name, data = name+".error", []byte(err.Error())
+		}
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, Errorf(ErrInternal, "write bundle header %s failed: %v", name, err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, Errorf(ErrInternal, "write bundle file %s failed: %v", name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
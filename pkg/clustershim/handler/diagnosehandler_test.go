@@ -0,0 +1,86 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+func readBundle(t *testing.T, data []byte) map[string]string {
+	raw, err := base64.StdEncoding.DecodeString(string(data))
+	assert.Nil(t, err)
+	gr, err := gzip.NewReader(bytes.NewReader(raw))
+	assert.Nil(t, err)
+	tr := tar.NewReader(gr)
+	files := map[string]string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		assert.Nil(t, err)
+		content, err := ioutil.ReadAll(tr)
+		assert.Nil(t, err)
+		files[hdr.Name] = string(content)
+	}
+}
+
+func TestDiagnoseHandlerDo(t *testing.T) {
+	h := NewDiagnoseHandler(map[string]DiagnoseCollector{
+		"route.json": func() ([]byte, error) {
+			return []byte(`{"c1":"c1"}`), nil
+		},
+		"logs": func() ([]byte, error) {
+			return nil, fmt.Errorf("log dir not set")
+		},
+	})
+
+	// unsupportable command
+	msg := makeExecMessage(http.MethodGet, "", t)
+	msg.Head.Command = clustermessage.CommandType_NeighborRoute
+	resp, err := h.Do(msg)
+	assert.Nil(t, resp)
+	assert.NotNil(t, err)
+
+	_, err = h.Do(makeExecMessage(http.MethodPost, "", t))
+	assert.NotNil(t, err)
+
+	resp, err = h.Do(makeExecMessage(http.MethodGet, "", t))
+	assert.Nil(t, err)
+	taskResp := &clustermessage.ControllerTaskResponse{}
+	assert.Nil(t, proto.Unmarshal(resp.Body, taskResp))
+	assert.Equal(t, int32(http.StatusOK), taskResp.StatusCode)
+
+	files := readBundle(t, taskResp.Body)
+	assert.Equal(t, map[string]string{
+		"route.json": `{"c1":"c1"}`,
+		"logs.error": "log dir not set",
+	}, files)
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/golang/protobuf/proto"
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/envelope"
+)
+
+// envelopeHandler opens sealed bodies of tasks before done by a handler.
+type envelopeHandler struct {
+	handler Handler
+	km      envelope.KeyManager
+	key     string
+}
+
+/*
+NewEnvelopeHandler returns a handler opening sealed bodies of tasks by key of km,
+and passing the tasks with the plaintext to h, so that secrets are only decrypted in this shim.
+Tasks not sealed are passed to h as is, and tasks not sealed for key are refused.
+*/
+func NewEnvelopeHandler(h Handler, km envelope.KeyManager, key string) Handler {
+	return &envelopeHandler{handler: h, km: km, key: key}
+}
+
+func (e *envelopeHandler) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	return e.DoContext(context.Background(), in)
+}
+
+func (e *envelopeHandler) DoContext(ctx context.Context,
+	in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	if in.Head.Command != clustermessage.CommandType_ControlReq {
+		return DoContext(ctx, e.handler, in)
+	}
+	task := GetControllerTaskFromClusterMessage(in)
+	if task == nil || !envelope.IsSealed(task.Body) {
+		return DoContext(ctx, e.handler, in)
+	}
+
+	plaintext, err := envelope.Open(task.Body, e.key, e.km)
+	if err != nil {
+		code := http.StatusBadRequest
+		if _, ok := err.(envelope.ErrNotSealedFor); ok {
+			code = http.StatusForbidden
+		}
+		klog.Errorf("open envelope of %s failed: %v", task.URI, err)
+		return Response(ControlTaskResponse(code, err.Error()), in.Head), err
+	}
+	task.Body = plaintext
+	body, err := proto.Marshal(task)
+	if err != nil {
+		err = Errorf(ErrInternal, "marshal controller task failed: %v", err)
+		return Response(ControlTaskResponse(http.StatusInternalServerError, err.Error()), in.Head), err
+	}
+	klog.V(3).Infof("envelope of %s is opened by key %s", task.URI, e.key)
+	msg := *in
+	msg.Body = body
+	return DoContext(ctx, e.handler, &msg)
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/envelope"
+)
+
+// fakeKeyManager encrypts by prefixing the key name.
+type fakeKeyManager struct{}
+
+func (fakeKeyManager) Encrypt(key string, plaintext []byte) ([]byte, error) {
+	return append([]byte(key+":"), plaintext...), nil
+}
+
+func (fakeKeyManager) Decrypt(key string, ciphertext []byte) ([]byte, error) {
+	if !bytes.HasPrefix(ciphertext, []byte(key+":")) {
+		return nil, fmt.Errorf("not encrypted by %s", key)
+	}
+	return ciphertext[len(key)+1:], nil
+}
+
+// bodyHandler responds the body of tasks.
+type bodyHandler struct{}
+
+func (bodyHandler) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	task := GetControllerTaskFromClusterMessage(in)
+	return Response(ControlTaskResponse(http.StatusOK, string(task.Body)), in.Head), nil
+}
+
+func makeSealedTaskMessage(t *testing.T, body []byte) *clustermessage.ClusterMessage {
+	task := &clustermessage.ControllerTask{Destination: "api", Method: http.MethodPost,
+		URI: "/api/v1/namespaces/default/secrets", Body: body}
+	msg, err := task.ToClusterMessage(&clustermessage.MessageHead{
+		MessageID: "1",
+		Command:   clustermessage.CommandType_ControlReq,
+	})
+	assert.Nil(t, err)
+	return msg
+}
+
+func responseOf(t *testing.T, msg *clustermessage.ClusterMessage) *clustermessage.ControllerTaskResponse {
+	resp := &clustermessage.ControllerTaskResponse{}
+	assert.Nil(t, proto.Unmarshal(msg.Body, resp))
+	return resp
+}
+
+func TestEnvelopeHandler(t *testing.T) {
+	h := NewEnvelopeHandler(bodyHandler{}, fakeKeyManager{}, "ote-c1")
+	secret := []byte(`{"kind":"Secret","data":{"password":"cGFzcw=="}}`)
+
+	// bodies not sealed are passed as is.
+	resp, err := h.Do(makeSealedTaskMessage(t, secret))
+	assert.Nil(t, err)
+	assert.Equal(t, secret, responseOf(t, resp).Body)
+
+	sealed, err := envelope.Seal(secret, []string{"ote-c1", "ote-c2"}, fakeKeyManager{})
+	assert.Nil(t, err)
+	in := makeSealedTaskMessage(t, sealed)
+	origin := in.Body
+	resp, err = h.Do(in)
+	assert.Nil(t, err)
+	assert.Equal(t, secret, responseOf(t, resp).Body)
+	assert.Equal(t, origin, in.Body)
+
+	// envelopes not sealed for this shim are refused.
+	sealed, err = envelope.Seal(secret, []string{"ote-c2"}, fakeKeyManager{})
+	assert.Nil(t, err)
+	resp, err = h.Do(makeSealedTaskMessage(t, sealed))
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(http.StatusForbidden), responseOf(t, resp).StatusCode)
+
+	// envelopes broken are refused.
+	resp, err = h.Do(makeSealedTaskMessage(t, []byte(envelope.Prefix+"{")))
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(http.StatusBadRequest), responseOf(t, resp).StatusCode)
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"errors"
+
+	"github.com/baidu/ote-stack/pkg/errorkind"
+)
+
+// Kinds of errors returned by handlers and shims, which callers can tell by errorkind.Is.
+var (
+	// ErrUnsupportedCommand is returned doing a message of command not supported.
+	ErrUnsupportedCommand = errors.New("command not supported")
+	// ErrTaskNotFound is returned doing a message without a task in its body.
+	ErrTaskNotFound = errors.New("controller task not found")
+	// ErrMethodNotAllowed is returned doing a task of method not supported by the handler.
+	ErrMethodNotAllowed = errors.New("method not allowed")
+	// ErrBadRequest is returned doing a task invalid, e.g., a required field is missing.
+	ErrBadRequest = errors.New("bad request")
+	// ErrNoHandler is returned doing a task of destination not handled by the shim.
+	ErrNoHandler = errors.New("no handler")
+	// ErrPanicked is returned doing a task which makes the handler panic.
+	ErrPanicked = errors.New("handler panicked")
+	// ErrTimedOut is returned doing a task whose context is past its deadline.
+	ErrTimedOut = errors.New("task timed out")
+	// ErrCanceled is returned doing a task whose context is canceled, e.g., on shutdown.
+	ErrCanceled = errors.New("task canceled")
+	// ErrQuotaExceeded is returned doing a task whose pods exceed the quota of the cluster.
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrAPIServerUnreachable is returned doing a task whose request does not reach the apiserver.
+	ErrAPIServerUnreachable = errors.New("apiserver unreachable")
+	// ErrInvalidConfig is returned creating a handler of an invalid config, e.g., a bad apiserver host.
+	ErrInvalidConfig = errors.New("invalid config")
+	// ErrInvalidStream is returned decoding a stream of frames which is malformed.
+	ErrInvalidStream = errors.New("invalid stream")
+	// ErrInternal is returned doing a task failed by the handler itself, e.g., a response fails to encode.
+	ErrInternal = errors.New("internal error")
+)
+
+// Errorf returns an error of kind, with message formatted by format and a.
+func Errorf(kind error, format string, a ...interface{}) error {
+	return errorkind.Errorf(kind, format, a...)
+}
+
+// KindOf returns the kind of err returned by Errorf, nil if it is not of a kind.
+func KindOf(err error) error {
+	return errorkind.Of(err)
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+const (
+	execTimeout = 60
+	// execProtocol is the websocket subprotocol of kube-apiserver exec.
+	execProtocol = "v4.channel.k8s.io"
+	// maxStreamSize limits the output of a command carried by a message.
+	maxStreamSize = 4 << 20
+)
+
+var podExecURI = regexp.MustCompile(`^/api/v1/namespaces/[^/]+/pods/[^/]+/exec$`)
+
+type execHandler struct {
+	config *rest.Config
+	dialer *websocket.Dialer
+}
+
+// NewExecHandler returns a new execHandler.
+// Commands are executed without stdin and tty, since a control request
+// has only one response, and the output is returned as a multiplexed stream.
+func NewExecHandler(config *rest.Config) (Handler, error) {
+	tlsConfig, err := rest.TLSConfigFor(config)
+	if err != nil {
+		return nil, Errorf(ErrInvalidConfig, "get tls config for exec failed: %v", err)
+	}
+	return &execHandler{
+		config: config,
+		dialer: &websocket.Dialer{
+			TLSClientConfig:  tlsConfig,
+			HandshakeTimeout: time.Second * execTimeout,
+			Subprotocols:     []string{execProtocol},
+		},
+	}, nil
+}
+
+func (e *execHandler) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	switch in.Head.Command {
+	case clustermessage.CommandType_ControlReq:
+		resp, err := e.DoControlRequest(in)
+		return Response(resp, in.Head), err
+	default:
+		return nil, Errorf(ErrUnsupportedCommand, "command %s is not supported by execHandler", in.Head.Command.String())
+	}
+}
+
+func (e *execHandler) DoControlRequest(in *clustermessage.ClusterMessage) ([]byte, error) {
+	controllerTask := GetControllerTaskFromClusterMessage(in)
+	if controllerTask == nil {
+		return ControlTaskResponse(http.StatusNotFound, ""), ErrTaskNotFound
+	}
+
+	if controllerTask.Method != http.MethodPost && controllerTask.Method != http.MethodGet {
+		return ControlTaskResponse(http.StatusMethodNotAllowed, ""), ErrMethodNotAllowed
+	}
+
+	u, err := e.execURL(controllerTask.URI)
+	if err != nil {
+		return ControlTaskResponse(http.StatusBadRequest, err.Error()), err
+	}
+
+	conn, resp, err := e.dialer.Dial(u.String(), e.header())
+	if err != nil {
+		if resp == nil {
+			return ControlTaskResponse(http.StatusInternalServerError, err.Error()), err
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return ControlTaskResponse(resp.StatusCode, string(body)), nil
+	}
+	defer conn.Close()
+
+	frames := readStream(conn)
+	data, err := EncodeStream(frames)
+	if err != nil {
+		return ControlTaskResponse(http.StatusInternalServerError, ""), err
+	}
+	return ControlTaskResponse(http.StatusOK, string(data)), nil
+}
+
+// execURL checks the exec uri and makes the websocket url to kube-apiserver.
+func (e *execHandler) execURL(uri string) (*url.URL, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, Errorf(ErrBadRequest, "parse exec uri %s failed: %v", uri, err)
+	}
+	if !podExecURI.MatchString(u.Path) {
+		return nil, Errorf(ErrBadRequest, "%s is not a pod exec uri", u.Path)
+	}
+	query := u.Query()
+	if query.Get("stdin") == "true" || query.Get("tty") == "true" {
+		return nil, Errorf(ErrBadRequest, "stdin and tty are not supported")
+	}
+
+	host, err := url.Parse(e.config.Host)
+	if err != nil {
+		return nil, Errorf(ErrInvalidConfig, "parse apiserver host %s failed: %v", e.config.Host, err)
+	}
+	if host.Scheme == "https" {
+		host.Scheme = "wss"
+	} else {
+		host.Scheme = "ws"
+	}
+	host.Path = strings.TrimSuffix(host.Path, "/") + u.Path
+	host.RawQuery = u.RawQuery
+	return host, nil
+}
+
+func (e *execHandler) header() http.Header {
+	header := http.Header{}
+	if e.config.BearerToken != "" {
+		header.Set("Authorization", "Bearer "+e.config.BearerToken)
+	} else if e.config.Username != "" {
+		req := &http.Request{Header: header}
+		req.SetBasicAuth(e.config.Username, e.config.Password)
+	}
+	return header
+}
+
+// readStream reads all frames of the exec stream until it is closed,
+// timeout or its size is out of limit.
+func readStream(conn *websocket.Conn) []StreamFrame {
+	var frames []StreamFrame
+	size := 0
+	conn.SetReadDeadline(time.Now().Add(time.Second * execTimeout))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				klog.V(3).Infof("exec stream closed: %v", err)
+			}
+			return frames
+		}
+		// the first byte of message is the channel, drop empty message
+		if len(data) <= 1 {
+			continue
+		}
+		size += len(data) - 1
+		if size > maxStreamSize {
+			frames = append(frames, StreamFrame{
+				Channel: StreamStderr,
+				Data:    []byte("output is truncated\n"),
+			})
+			return frames
+		}
+		frames = append(frames, StreamFrame{
+			Channel: int(data[0]),
+			Data:    data[1:],
+		})
+	}
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/rest"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+func newExecTestServer(t *testing.T) *httptest.Server {
+	upgrader := websocket.Upgrader{Subprotocols: []string{execProtocol}}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/namespaces/default/pods/p1/exec", r.URL.Path)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade failed: %v", err)
+			return
+		}
+		defer conn.Close()
+		conn.WriteMessage(websocket.BinaryMessage, []byte{StreamStdout})
+		conn.WriteMessage(websocket.BinaryMessage, append([]byte{StreamStdout}, "hello\n"...))
+		conn.WriteMessage(websocket.BinaryMessage, append([]byte{StreamStderr}, "oops\n"...))
+		conn.WriteMessage(websocket.BinaryMessage, append([]byte{StreamError}, `{"status":"Success"}`...))
+		conn.WriteMessage(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}))
+}
+
+func makeExecMessage(method, uri string, t *testing.T) *clustermessage.ClusterMessage {
+	data, err := proto.Marshal(&clustermessage.ControllerTask{
+		Method: method,
+		URI:    uri,
+	})
+	if err != nil {
+		t.Errorf("to controller task request failed: %v", err)
+	}
+	return &clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{
+			Command: clustermessage.CommandType_ControlReq,
+		},
+		Body: data,
+	}
+}
+
+func TestExecHandlerDo(t *testing.T) {
+	server := newExecTestServer(t)
+	defer server.Close()
+
+	h, err := NewExecHandler(&rest.Config{Host: server.URL, BearerToken: "token"})
+	assert.Nil(t, err)
+
+	// unsupportable command
+	msg := makeExecMessage(http.MethodPost, "/", t)
+	msg.Head.Command = clustermessage.CommandType_NeighborRoute
+	resp, err := h.Do(msg)
+	assert.Nil(t, resp)
+	assert.NotNil(t, err)
+
+	resp, err = h.Do(makeExecMessage(http.MethodPost,
+		"/api/v1/namespaces/default/pods/p1/exec?command=ls&stdout=true&stderr=true", t))
+	assert.Nil(t, err)
+
+	taskResp := &clustermessage.ControllerTaskResponse{}
+	assert.Nil(t, proto.Unmarshal(resp.Body, taskResp))
+	assert.Equal(t, int32(http.StatusOK), taskResp.StatusCode)
+
+	frames, err := DecodeStream(taskResp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, []StreamFrame{
+		{Channel: StreamStdout, Data: []byte("hello\n")},
+		{Channel: StreamStderr, Data: []byte("oops\n")},
+		{Channel: StreamError, Data: []byte(`{"status":"Success"}`)},
+	}, frames)
+}
+
+func TestExecHandlerDoControlRequestFailed(t *testing.T) {
+	h, err := NewExecHandler(&rest.Config{Host: "http://127.0.0.1:1"})
+	assert.Nil(t, err)
+
+	errorcase := []struct {
+		Name   string
+		Method string
+		URI    string
+		Code   int32
+	}{
+		{
+			Name:   "method not allowed",
+			Method: http.MethodDelete,
+			URI:    "/api/v1/namespaces/default/pods/p1/exec",
+			Code:   http.StatusMethodNotAllowed,
+		},
+		{
+			Name:   "not exec uri",
+			Method: http.MethodPost,
+			URI:    "/api/v1/namespaces/default/pods/p1",
+			Code:   http.StatusBadRequest,
+		},
+		{
+			Name:   "stdin is not supported",
+			Method: http.MethodPost,
+			URI:    "/api/v1/namespaces/default/pods/p1/exec?command=sh&stdin=true",
+			Code:   http.StatusBadRequest,
+		},
+		{
+			Name:   "apiserver unreachable",
+			Method: http.MethodPost,
+			URI:    "/api/v1/namespaces/default/pods/p1/exec?command=ls",
+			Code:   http.StatusInternalServerError,
+		},
+	}
+	for _, ec := range errorcase {
+		resp, err := h.Do(makeExecMessage(ec.Method, ec.URI, t))
+		assert.NotNil(t, err, ec.Name)
+		taskResp := &clustermessage.ControllerTaskResponse{}
+		assert.Nil(t, proto.Unmarshal(resp.Body, taskResp))
+		assert.Equal(t, ec.Code, taskResp.StatusCode, ec.Name)
+	}
+}
@@ -18,6 +18,9 @@ limitations under the License.
 package handler
 
 import (
+	"context"
+	"net/http"
+	"runtime/debug"
 	"time"
 
 	"github.com/golang/protobuf/proto"
@@ -33,6 +36,35 @@ type Handler interface {
 	Do(*clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error)
 }
 
+// Responder sends a response asynchronously.
+type Responder func(resp *clustermessage.ClusterMessage)
+
+// AsyncHandler is a Handler which also sends responses asynchronously after Do returns,
+// e.g., parts of a watch. The responder is set by the shim before any request.
+type AsyncHandler interface {
+	Handler
+	SetResponder(Responder)
+}
+
+// doRecovered does in by h with ctx, and responds 500 to a control request if h panics,
+// so that a malformed message does not take down the shim.
+func doRecovered(ctx context.Context, h Handler,
+	in *clustermessage.ClusterMessage) (resp *clustermessage.ClusterMessage, err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		klog.Errorf("handler panicked on message %s: %v\n%s", in.Head.GetMessageID(), r, debug.Stack())
+		err = Errorf(ErrPanicked, "handler panicked: %v", r)
+		resp = nil
+		if in.Head.GetCommand() == clustermessage.CommandType_ControlReq {
+			resp = Response(ControlTaskResponse(http.StatusInternalServerError, err.Error()), in.Head)
+		}
+	}()
+	return DoContext(ctx, h, in)
+}
+
 // Response packages the body message to clustermessage.ClusterMessage.
 func Response(body []byte, head *clustermessage.MessageHead) *clustermessage.ClusterMessage {
 	msg := &clustermessage.ClusterMessage{
@@ -45,18 +77,47 @@ func Response(body []byte, head *clustermessage.MessageHead) *clustermessage.Clu
 //ControlTaskResponse packages the body message to clustermessage.ControllerTaskResponse
 //and serialize it.
 func ControlTaskResponse(status int, body string) []byte {
-	data := &clustermessage.ControllerTaskResponse{
+	data := controllerTaskResponse(status, body)
+
+	resp, err := proto.Marshal(data)
+	if err != nil {
+		klog.Errorf("marshal ControllerTaskResponse failed: %v", err)
+		return nil
+	}
+	return resp 
+}
+
+func controllerTaskResponse(status int, body string) *clustermessage.ControllerTaskResponse {
+	return &clustermessage.ControllerTaskResponse{
 		Timestamp:  time.Now().Unix(),
 		StatusCode: int32(status),
 		Body:       []byte(body),
 	}
+}
 
+// ControlMultiTaskResponse packages responses of bodies of a ControlMultiTask in order
+// to clustermessage.ControlMultiTaskResponse and serialize it.
+func ControlMultiTaskResponse(items []*clustermessage.ControllerTaskResponse) []byte {
+	data := &clustermessage.ControlMultiTaskResponse{
+		Timestamp: time.Now().Unix(),
+		Items:     items,
+	}
 	resp, err := proto.Marshal(data)
 	if err != nil {
-		klog.Errorf("marshal ControllerTaskResponse failed: %v", err)
+		klog.Errorf("marshal ControlMultiTaskResponse failed: %v", err)
 		return nil
 	}
-	return resp 
+	return resp
+}
+
+// ControlMultiTaskStatus returns the serialized response of a ControlMultiTask
+// whose bodies are all responded with status and body, e.g., of an unknown destination.
+func ControlMultiTaskStatus(task *clustermessage.ControlMultiTask, status int, body string) []byte {
+	items := make([]*clustermessage.ControllerTaskResponse, len(task.GetBody()))
+	for i := range items {
+		items[i] = controllerTaskResponse(status, body)
+	}
+	return ControlMultiTaskResponse(items)
 }
 
 func GetControllerTaskFromClusterMessage(
@@ -85,4 +146,19 @@ func GetControlMultiTaskFromClusterMessage(
 		return nil
 	}
 	return task
+}
+
+// GetControlMultiTaskResponseFromClusterMessage returns the response in msg of command ControlMultiResp,
+// nil if it is invalid.
+func GetControlMultiTaskResponseFromClusterMessage(
+	msg *clustermessage.ClusterMessage) *clustermessage.ControlMultiTaskResponse {
+	if msg == nil {
+		return nil
+	}
+	resp := &clustermessage.ControlMultiTaskResponse{}
+	if err := proto.Unmarshal(msg.Body, resp); err != nil {
+		klog.Errorf("unmarshal ControlMultiTaskResponse failed: %v", err)
+		return nil
+	}
+	return resp
 }
\ No newline at end of file
@@ -19,7 +19,6 @@ package handler
 import (
 	"bytes"
 	"crypto/tls"
-	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strings"
@@ -65,7 +64,7 @@ func (h *httpProxyHandler) Do(in *clustermessage.ClusterMessage) (*clustermessag
 		resp, err := h.DoControlRequest(in)
 		return Response(resp, in.Head), err
 	default:
-		return nil, fmt.Errorf("command %s is not supported by httpProxyHandler", in.Head.Command.String())
+		return nil, Errorf(ErrUnsupportedCommand, "command %s is not supported by httpProxyHandler", in.Head.Command.String())
 	}
 }
 
@@ -75,7 +74,7 @@ func (h *httpProxyHandler) DoControlRequest(in *clustermessage.ClusterMessage) (
 
 	controllerTask := GetControllerTaskFromClusterMessage(in)
 	if controllerTask == nil {
-		return ControlTaskResponse(http.StatusNotFound, ""), fmt.Errorf("Controllertask Not Found")
+		return ControlTaskResponse(http.StatusNotFound, ""), ErrTaskNotFound
 	}
 
 	url := h.addr + controllerTask.URI
@@ -91,7 +90,7 @@ func (h *httpProxyHandler) DoControlRequest(in *clustermessage.ClusterMessage) (
 	case http.MethodPut:
 		req, err = http.NewRequest(http.MethodPut, url, buf)
 	default:
-		return ControlTaskResponse(http.StatusMethodNotAllowed, ""), fmt.Errorf("method not allowed")
+		return ControlTaskResponse(http.StatusMethodNotAllowed, ""), ErrMethodNotAllowed
 	}
 
 	if err != nil {
@@ -0,0 +1,265 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+const (
+	// JobLabel is the label of jobs and their pods created by jobHandler, the value is the job name.
+	JobLabel = "ote-job"
+	// MaxJobResultSize is the max size of the result of a pod sent back.
+	MaxJobResultSize = 4096
+	// MaxJobResults is the max number of pods whose results are sent back for a job.
+	MaxJobResults = 100
+)
+
+// JobPodResult is the result of a pod of job.
+type JobPodResult struct {
+	Node  string `json:"node,omitempty"`
+	Phase string `json:"phase"`
+	// ExitCode is the exit code of the first container terminated with error, or 0.
+	ExitCode int32 `json:"exitCode"`
+	// Result is the termination message of containers of the pod, truncated to MaxJobResultSize.
+	Result string `json:"result,omitempty"`
+}
+
+// JobStatus is the status and results of a job in cluster.
+type JobStatus struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// Completions is the number of pods to succeed for the job.
+	Completions int32 `json:"completions"`
+	Active      int32 `json:"active"`
+	Succeeded   int32 `json:"succeeded"`
+	Failed      int32 `json:"failed"`
+	// Complete or JobFailed is set when the job is finished.
+	Complete  bool   `json:"complete,omitempty"`
+	JobFailed bool   `json:"jobFailed,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	// Pods is the results of finished pods by pod name.
+	Pods map[string]*JobPodResult `json:"pods,omitempty"`
+}
+
+// Finished returns true if the job is complete or failed.
+func (s *JobStatus) Finished() bool {
+	return s.Complete || s.JobFailed
+}
+
+type jobHandler struct {
+	client kubernetes.Interface
+}
+
+// NewJobHandler returns a new jobHandler, which runs jobs and collects their results.
+// POST creates the job in body, GET and DELETE with namespace/name as URI returns
+// the JobStatus and deletes the job with its pods respectively.
+// The result of a pod is its termination message, which is written to
+// /dev/termination-log by default, or the tail of its log if
+// terminationMessagePolicy of container is FallbackToLogsOnError.
+func NewJobHandler(cl kubernetes.Interface) Handler {
+	return &jobHandler{client: cl}
+}
+
+func (j *jobHandler) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	switch in.Head.Command {
+	case clustermessage.CommandType_ControlReq:
+		resp, err := j.DoControlRequest(in)
+		return Response(resp, in.Head), err
+	default:
+		return nil, Errorf(ErrUnsupportedCommand, "command %s is not supported by jobHandler", in.Head.Command.String())
+	}
+}
+
+func (j *jobHandler) DoControlRequest(in *clustermessage.ClusterMessage) ([]byte, error) {
+	controllerTask := GetControllerTaskFromClusterMessage(in)
+	if controllerTask == nil {
+		return ControlTaskResponse(http.StatusNotFound, ""), ErrTaskNotFound
+	}
+
+	var namespace, name string
+	switch controllerTask.Method {
+	case http.MethodPost:
+		job := &batchv1.Job{}
+		if err := json.Unmarshal(controllerTask.Body, job); err != nil {
+			return ControlTaskResponse(http.StatusBadRequest, err.Error()), err
+		}
+		if err := j.create(job); err != nil {
+			return ControlTaskResponse(statusCodeOf(err), err.Error()), err
+		}
+		namespace, name = job.Namespace, job.Name
+	case http.MethodGet, http.MethodDelete:
+		var err error
+		namespace, name, err = parseJobURI(controllerTask.URI)
+		if err != nil {
+			return ControlTaskResponse(http.StatusBadRequest, err.Error()), err
+		}
+		if controllerTask.Method == http.MethodDelete {
+			propagation := metav1.DeletePropagationBackground
+			err := j.client.BatchV1().Jobs(namespace).Delete(name, &metav1.DeleteOptions{PropagationPolicy: &propagation})
+			if err != nil {
+				return ControlTaskResponse(statusCodeOf(err), err.Error()), err
+			}
+			klog.Infof("job %s/%s deleted", namespace, name)
+			return ControlTaskResponse(http.StatusOK, ""), nil
+		}
+	default:
+		return ControlTaskResponse(http.StatusMethodNotAllowed, ""), ErrMethodNotAllowed
+	}
+
+	status, err := j.status(namespace, name)
+	if err != nil {
+		return ControlTaskResponse(statusCodeOf(err), err.Error()), err
+	}
+	body, err := json.Marshal(status)
+	if err != nil {
+		return ControlTaskResponse(http.StatusInternalServerError, err.Error()), err
+	}
+	return ControlTaskResponse(http.StatusOK, string(body)), nil
+}
+
+// parseJobURI returns namespace and name of job from uri namespace/name.
+func parseJobURI(uri string) (string, string, error) {
+	parts := strings.Split(strings.Trim(uri, "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", Errorf(ErrBadRequest, "uri %s is not namespace/name of job", uri)
+	}
+	return parts[0], parts[1], nil
+}
+
+// create creates the job with JobLabel added to the job and its pods.
+// The job is left as is if it exists, so the request can be resent safely.
+func (j *jobHandler) create(job *batchv1.Job) error {
+	if job.Name == "" {
+		return errors.NewBadRequest("name of job is required")
+	}
+	if job.Namespace == "" {
+		job.Namespace = metav1.NamespaceDefault
+	}
+	// fields copied from a job of another cluster are not valid in this cluster.
+	job.ResourceVersion = ""
+	job.UID = ""
+	job.Spec.Selector = nil
+	delete(job.Spec.Template.Labels, "controller-uid")
+	delete(job.Spec.Template.Labels, "job-name")
+
+	if job.Labels == nil {
+		job.Labels = make(map[string]string)
+	}
+	job.Labels[JobLabel] = job.Name
+	if job.Spec.Template.Labels == nil {
+		job.Spec.Template.Labels = make(map[string]string)
+	}
+	job.Spec.Template.Labels[JobLabel] = job.Name
+
+	_, err := j.client.BatchV1().Jobs(job.Namespace).Create(job)
+	if errors.IsAlreadyExists(err) {
+		klog.Infof("job %s/%s exists", job.Namespace, job.Name)
+		return nil
+	}
+	if err == nil {
+		klog.Infof("job %s/%s created", job.Namespace, job.Name)
+	}
+	return err
+}
+
+// status collects the status of job and results of its finished pods.
+func (j *jobHandler) status(namespace, name string) (*JobStatus, error) {
+	job, err := j.client.BatchV1().Jobs(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	status := &JobStatus{
+		Name:        name,
+		Namespace:   namespace,
+		Completions: 1,
+		Active:      job.Status.Active,
+		Succeeded:   job.Status.Succeeded,
+		Failed:      job.Status.Failed,
+		Pods:        make(map[string]*JobPodResult),
+	}
+	if job.Spec.Completions != nil {
+		status.Completions = *job.Spec.Completions
+	}
+	for _, c := range job.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			status.Complete = true
+		case batchv1.JobFailed:
+			status.JobFailed = true
+			status.Reason = c.Reason + ": " + c.Message
+		}
+	}
+
+	selector := labels.SelectorFromSet(labels.Set{JobLabel: name}).String()
+	pods, err := j.client.CoreV1().Pods(namespace).List(metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+			continue
+		}
+		if len(status.Pods) >= MaxJobResults {
+			klog.Warningf("results of job %s/%s exceed %d pods, the rest are dropped", namespace, name, MaxJobResults)
+			break
+		}
+		status.Pods[pod.Name] = jobPodResult(pod)
+	}
+	return status, nil
+}
+
+func jobPodResult(pod *corev1.Pod) *JobPodResult {
+	result := &JobPodResult{
+		Node:  pod.Spec.NodeName,
+		Phase: string(pod.Status.Phase),
+	}
+	var messages []string
+	for _, cs := range pod.Status.ContainerStatuses {
+		terminated := cs.State.Terminated
+		if terminated == nil {
+			continue
+		}
+		if terminated.ExitCode != 0 && result.ExitCode == 0 {
+			result.ExitCode = terminated.ExitCode
+		}
+		if terminated.Message != "" {
+			messages = append(messages, terminated.Message)
+		}
+	}
+	result.Result = strings.Join(messages, "\n")
+	if len(result.Result) > MaxJobResultSize {
+		result.Result = result.Result[:MaxJobResultSize]
+	}
+	return result
+}
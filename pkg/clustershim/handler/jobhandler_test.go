@@ -0,0 +1,165 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+func makeJobMessage(t *testing.T, method, uri string, body []byte) *clustermessage.ClusterMessage {
+	data, err := proto.Marshal(&clustermessage.ControllerTask{
+		Method: method,
+		URI:    uri,
+		Body:   body,
+	})
+	assert.Nil(t, err)
+	return &clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{Command: clustermessage.CommandType_ControlReq},
+		Body: data,
+	}
+}
+
+func doJob(t *testing.T, h Handler, msg *clustermessage.ClusterMessage) (*clustermessage.ControllerTaskResponse, error) {
+	resp, err := h.Do(msg)
+	taskResp := &clustermessage.ControllerTaskResponse{}
+	assert.Nil(t, proto.Unmarshal(resp.Body, taskResp))
+	return taskResp, err
+}
+
+func newJobPod(name string, phase corev1.PodPhase, statuses ...corev1.ContainerStatus) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{JobLabel: "infer"},
+		},
+		Spec:   corev1.PodSpec{NodeName: "n1"},
+		Status: corev1.PodStatus{Phase: phase, ContainerStatuses: statuses},
+	}
+}
+
+func terminated(code int32, message string) corev1.ContainerStatus {
+	return corev1.ContainerStatus{State: corev1.ContainerState{
+		Terminated: &corev1.ContainerStateTerminated{ExitCode: code, Message: message}}}
+}
+
+func TestParseJobURI(t *testing.T) {
+	ns, name, err := parseJobURI("/default/infer")
+	assert.Nil(t, err)
+	assert.Equal(t, "default", ns)
+	assert.Equal(t, "infer", name)
+
+	for _, uri := range []string{"", "/infer", "/a/b/c", "/a/"} {
+		_, _, err = parseJobURI(uri)
+		assert.Equal(t, ErrBadRequest, KindOf(err), uri)
+	}
+}
+
+func TestJobPodResult(t *testing.T) {
+	r := jobPodResult(newJobPod("p1", corev1.PodFailed, terminated(0, "a"), terminated(2, "b"), terminated(3, "")))
+	assert.Equal(t, "n1", r.Node)
+	assert.Equal(t, "Failed", r.Phase)
+	assert.Equal(t, int32(2), r.ExitCode)
+	assert.Equal(t, "a\nb", r.Result)
+
+	r = jobPodResult(newJobPod("p1", corev1.PodSucceeded, terminated(0, strings.Repeat("x", MaxJobResultSize+1))))
+	assert.Len(t, r.Result, MaxJobResultSize)
+}
+
+func TestJobHandler(t *testing.T) {
+	client := k8sfake.NewSimpleClientset(
+		newJobPod("p1", corev1.PodSucceeded, terminated(0, "score=0.9")),
+		newJobPod("p2", corev1.PodRunning),
+	)
+	h := NewJobHandler(client)
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "infer", ResourceVersion: "10"},
+		Spec: batchv1.JobSpec{
+			Selector: &metav1.LabelSelector{},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"job-name": "infer"}},
+			},
+		},
+	}
+	body, _ := json.Marshal(job)
+
+	// invalid job
+	resp, err := doJob(t, h, makeJobMessage(t, http.MethodPost, "", []byte("{")))
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(http.StatusBadRequest), resp.StatusCode)
+	resp, err = doJob(t, h, makeJobMessage(t, http.MethodPost, "", []byte("{}")))
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(http.StatusBadRequest), resp.StatusCode)
+
+	// not found
+	resp, err = doJob(t, h, makeJobMessage(t, http.MethodGet, "/default/infer", nil))
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(http.StatusNotFound), resp.StatusCode)
+
+	// create, and create again
+	for i := 0; i < 2; i++ {
+		resp, err = doJob(t, h, makeJobMessage(t, http.MethodPost, "", body))
+		assert.Nil(t, err)
+		assert.Equal(t, int32(http.StatusOK), resp.StatusCode)
+	}
+	created, err := client.BatchV1().Jobs("default").Get("infer", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "infer", created.Labels[JobLabel])
+	assert.Equal(t, map[string]string{JobLabel: "infer"}, created.Spec.Template.Labels)
+	assert.Nil(t, created.Spec.Selector)
+
+	created.Status.Succeeded = 1
+	created.Status.Active = 1
+	created.Status.Conditions = []batchv1.JobCondition{
+		{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: "BackoffLimitExceeded", Message: "too many"},
+	}
+	client.BatchV1().Jobs("default").UpdateStatus(created)
+
+	// status
+	resp, err = doJob(t, h, makeJobMessage(t, http.MethodGet, "/default/infer", nil))
+	assert.Nil(t, err)
+	status := &JobStatus{}
+	assert.Nil(t, json.Unmarshal(resp.Body, status))
+	assert.Equal(t, int32(1), status.Completions)
+	assert.Equal(t, int32(1), status.Succeeded)
+	assert.True(t, status.Finished())
+	assert.Equal(t, "BackoffLimitExceeded: too many", status.Reason)
+	assert.Len(t, status.Pods, 1)
+	assert.Equal(t, "score=0.9", status.Pods["p1"].Result)
+
+	// delete
+	resp, err = doJob(t, h, makeJobMessage(t, http.MethodDelete, "/default/infer", nil))
+	assert.Nil(t, err)
+	assert.Equal(t, int32(http.StatusOK), resp.StatusCode)
+	_, err = client.BatchV1().Jobs("default").Get("infer", metav1.GetOptions{})
+	assert.NotNil(t, err)
+
+	_, err = doJob(t, h, makeJobMessage(t, http.MethodPut, "/default/infer", nil))
+	assert.NotNil(t, err)
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package handler
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
@@ -38,24 +39,32 @@ func NewK8sHandler(cl kubernetes.Interface) Handler {
 }
 
 func (k *k8sHandler) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	return k.DoContext(context.Background(), in)
+}
+
+func (k *k8sHandler) DoContext(ctx context.Context,
+	in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
 	switch in.Head.Command {
 	case clustermessage.CommandType_ControlReq:
-		resp, err := k.DoControlRequest(in)
+		resp, err := k.DoControlRequest(ctx, in)
 		return Response(resp, in.Head), err
 	case clustermessage.CommandType_ControlMultiReq:
-		err := k.DoControlMultiRequest(in)
-		return nil, err
+		resp, err := k.DoControlMultiRequest(ctx, in)
+		if resp == nil {
+			return nil, err
+		}
+		return Response(resp, in.Head), err
 	default:
-		return nil, fmt.Errorf("command %s is not supported by k8sHandler", in.Head.Command.String())
+		return nil, Errorf(ErrUnsupportedCommand, "command %s is not supported by k8sHandler", in.Head.Command.String())
 	}
 }
 
-func (k *k8sHandler) DoControlRequest(in *clustermessage.ClusterMessage) ([]byte, error) {
+func (k *k8sHandler) DoControlRequest(ctx context.Context, in *clustermessage.ClusterMessage) ([]byte, error) {
 	var req *rest.Request
 
 	controllerTask := GetControllerTaskFromClusterMessage(in)
 	if controllerTask == nil {
-		return ControlTaskResponse(http.StatusNotFound, ""), fmt.Errorf("Controllertask Not Found")
+		return ControlTaskResponse(http.StatusNotFound, ""), ErrTaskNotFound
 	}
 
 	switch controllerTask.Method {
@@ -64,29 +73,39 @@ func (k *k8sHandler) DoControlRequest(in *clustermessage.ClusterMessage) ([]byte
 	case http.MethodPatch:
 		req = k.restclient.Patch(types.JSONPatchType)
 	default:
-		return ControlTaskResponse(http.StatusMethodNotAllowed, ""), fmt.Errorf("method not allowed")
+		return ControlTaskResponse(http.StatusMethodNotAllowed, ""), ErrMethodNotAllowed
 	}
 
 	req.Body([]byte(controllerTask.Body))
 	req.RequestURI(controllerTask.URI)
+	req.Context(ctx)
 
 	result := req.Do()
 
 	var code int
 	result.StatusCode(&code)
+	if code == 0 && ctx.Err() != nil {
+		return contextTaskResponse(ctx)
+	}
 
 	raw, _ := result.Raw()
 
 	return ControlTaskResponse(code, string(raw)), nil
 }
 
-func (k *k8sHandler) DoControlMultiRequest(in *clustermessage.ClusterMessage) error {
+/*
+DoControlMultiRequest does the request of each body of the task in order, and returns
+the serialized ControlMultiTaskResponse with the status code of each body, and the error
+returned by the apiserver of bodies failed. Bodies failed do not fail the task. Bodies not done once ctx is done
+are responded by the status of ctx, with the error of ctx returned.
+*/
+func (k *k8sHandler) DoControlMultiRequest(ctx context.Context, in *clustermessage.ClusterMessage) ([]byte, error) {
 	var request *rest.Request
 	var result rest.Result
 
 	controlMultiTask := GetControlMultiTaskFromClusterMessage(in)
 	if controlMultiTask == nil {
-		return fmt.Errorf("ControlMultiTask Not Found")
+		return nil, ErrTaskNotFound
 	}
 
 	switch controlMultiTask.Method {
@@ -95,26 +114,41 @@ func (k *k8sHandler) DoControlMultiRequest(in *clustermessage.ClusterMessage) er
 	case http.MethodPatch:
 		request = k.restclient.Patch(types.JSONPatchType)
 	default:
-		return fmt.Errorf("method not allowed")
+		return ControlMultiTaskStatus(controlMultiTask, http.StatusMethodNotAllowed, ""), ErrMethodNotAllowed
 	}
 
 	request.RequestURI(controlMultiTask.URI)
-
-	for _, item := range controlMultiTask.Body {
+	request.Context(ctx)
+
+	items := make([]*clustermessage.ControllerTaskResponse, 0, len(controlMultiTask.Body))
+	for i, item := range controlMultiTask.Body {
+		if ctx.Err() != nil {
+			status, err := contextStatus(ctx)
+			for range controlMultiTask.Body[i:] {
+				items = append(items, controllerTaskResponse(status, err.Error()))
+			}
+			return ControlMultiTaskResponse(items), err
+		}
 		req := *request
 		req.Body([]byte(item))
 
 		result = req.Do()
+		var code int
+		result.StatusCode(&code)
 		raw, err := result.Raw()
-
-		if err != nil {
-			klog.Errorf("Do k8s request failed: %v", err)
-			//TODO should do request again
+		if code == 0 {
+			// apiserver is not reached.
+			code, raw = http.StatusBadGateway, []byte(fmt.Sprintf("%v", err))
 		}
-		if raw != nil {
+		if err != nil {
+			klog.Errorf("Do k8s request of body %d failed: %v", i, err)
+		} else {
 			klog.V(3).Infof("the response of k8s request is: %v", string(raw))
+			// objects of bodies succeeded are not responded to keep the response small.
+			raw = nil
 		}
+		items = append(items, controllerTaskResponse(code, string(raw)))
 	}
 
-	return nil
+	return ControlMultiTaskResponse(items), nil
 }
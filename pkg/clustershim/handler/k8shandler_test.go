@@ -18,6 +18,8 @@ package handler
 
 import (
 	"bufio"
+	"context"
+	"io/ioutil"
 	"net/http"
 	"strings"
 	"testing"
@@ -155,7 +157,7 @@ func TestK8sHandlerDoControlRequest(t *testing.T) {
 	}
 
 	for _, sc := range successcase {
-		resp, err := h.DoControlRequest(sc.Request)
+		resp, err := h.DoControlRequest(context.Background(), sc.Request)
 		assert.Nil(t, err)
 
 		task := &clustermessage.ControllerTaskResponse{}
@@ -182,7 +184,7 @@ func TestK8sHandlerDoControlRequest(t *testing.T) {
 		},
 	}
 	for _, ec := range errorcase {
-		_, err := h.DoControlRequest(ec.Request)
+		_, err := h.DoControlRequest(context.Background(), ec.Request)
 		assert.NotNil(t, err)
 	}
 }
@@ -260,8 +262,11 @@ func TestDoControlMultiRequest(t *testing.T) {
 	}
 
 	for _, sc := range successcase {
-		err := h.DoControlMultiRequest(sc.Request)
+		resp, err := h.DoControlMultiRequest(context.Background(), sc.Request)
 		assert.Nil(t, err)
+		multiResp := &clustermessage.ControlMultiTaskResponse{}
+		assert.Nil(t, proto.Unmarshal(resp, multiResp))
+		assert.Len(t, multiResp.Items, 2)
 	}
 
 	data6 := makeControlMultiTask("", t)
@@ -280,7 +285,60 @@ func TestDoControlMultiRequest(t *testing.T) {
 		},
 	}
 	for _, ec := range errorcase {
-		err := h.DoControlMultiRequest(ec.Request)
+		_, err := h.DoControlMultiRequest(context.Background(), ec.Request)
 		assert.NotNil(t, err)
 	}
 }
+
+func TestDoControlMultiRequestPartialFailure(t *testing.T) {
+	fakeRestClient := &fakerest.RESTClient{
+		Client: fakerest.CreateHTTPClient(
+			func(req *http.Request) (*http.Response, error) {
+				body, _ := ioutil.ReadAll(req.Body)
+				resp := "HTTP/1.0 201 Created\r\nConnection: close\r\n\r\ncreated\n"
+				if string(body) == "ns2" {
+					resp = "HTTP/1.0 409 Conflict\r\nConnection: close\r\n\r\nalready exists\n"
+				}
+				return http.ReadResponse(bufio.NewReader(strings.NewReader(resp)), req)
+			},
+		),
+		GroupVersion:         v1.SchemeGroupVersion,
+		NegotiatedSerializer: serializer.NewCodecFactory(scheme.Scheme),
+		VersionedAPIPath:     "/",
+	}
+	h := &k8sHandler{restclient: fakeRestClient}
+	task := &clustermessage.ControlMultiTask{
+		Method: http.MethodPost,
+		URI:    "/api/v1/namespaces",
+		Body:   [][]byte{[]byte("ns1"), []byte("ns2"), []byte("ns3")},
+	}
+	in, err := task.ToClusterMessage(&clustermessage.MessageHead{
+		MessageID: "multi",
+		Command:   clustermessage.CommandType_ControlMultiReq,
+	})
+	assert.Nil(t, err)
+
+	// bodies failed do not fail the task, and are responded with their status codes.
+	resp, err := h.DoContext(context.Background(), in)
+	assert.Nil(t, err)
+	assert.Equal(t, "multi", resp.Head.MessageID)
+	multiResp := GetControlMultiTaskResponseFromClusterMessage(resp)
+	assert.NotNil(t, multiResp)
+	assert.Len(t, multiResp.Items, 3)
+	assert.Equal(t, int32(http.StatusCreated), multiResp.Items[0].StatusCode)
+	assert.Empty(t, multiResp.Items[0].Body)
+	assert.Equal(t, int32(http.StatusConflict), multiResp.Items[1].StatusCode)
+	assert.Contains(t, string(multiResp.Items[1].Body), "already exists")
+	assert.Equal(t, int32(http.StatusCreated), multiResp.Items[2].StatusCode)
+
+	// bodies not done once canceled are responded with 503.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	resp, err = h.DoContext(ctx, in)
+	assert.Equal(t, ErrCanceled, KindOf(err))
+	multiResp = GetControlMultiTaskResponseFromClusterMessage(resp)
+	assert.Len(t, multiResp.Items, 3)
+	for _, item := range multiResp.Items {
+		assert.Equal(t, int32(http.StatusServiceUnavailable), item.StatusCode)
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+var podLogURI = regexp.MustCompile(`^/api/v1/namespaces/[^/]+/pods/[^/]+/log$`)
+
+type logHandler struct {
+	restclient rest.Interface
+}
+
+// NewLogHandler returns a new logHandler.
+// Logs are read once, follow is not supported,
+// and the size of logs is limited by limitBytes.
+func NewLogHandler(cl kubernetes.Interface) Handler {
+	return &logHandler{restclient: cl.Discovery().RESTClient()}
+}
+
+func (l *logHandler) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	switch in.Head.Command {
+	case clustermessage.CommandType_ControlReq:
+		resp, err := l.DoControlRequest(in)
+		return Response(resp, in.Head), err
+	default:
+		return nil, Errorf(ErrUnsupportedCommand, "command %s is not supported by logHandler", in.Head.Command.String())
+	}
+}
+
+func (l *logHandler) DoControlRequest(in *clustermessage.ClusterMessage) ([]byte, error) {
+	controllerTask := GetControllerTaskFromClusterMessage(in)
+	if controllerTask == nil {
+		return ControlTaskResponse(http.StatusNotFound, ""), ErrTaskNotFound
+	}
+
+	if controllerTask.Method != http.MethodGet {
+		return ControlTaskResponse(http.StatusMethodNotAllowed, ""), ErrMethodNotAllowed
+	}
+
+	uri, err := logURI(controllerTask.URI)
+	if err != nil {
+		return ControlTaskResponse(http.StatusBadRequest, err.Error()), err
+	}
+
+	result := l.restclient.Get().RequestURI(uri).Do()
+
+	var code int
+	result.StatusCode(&code)
+
+	raw, _ := result.Raw()
+
+	return ControlTaskResponse(code, string(raw)), nil
+}
+
+// logURI checks the log uri, disables follow and limits the size of logs.
+func logURI(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", Errorf(ErrBadRequest, "parse log uri %s failed: %v", uri, err)
+	}
+	if !podLogURI.MatchString(u.Path) {
+		return "", Errorf(ErrBadRequest, "%s is not a pod log uri", u.Path)
+	}
+	query := u.Query()
+	query.Del("follow")
+	limit, err := strconv.Atoi(query.Get("limitBytes"))
+	if err != nil || limit <= 0 || limit > maxStreamSize {
+		query.Set("limitBytes", strconv.Itoa(maxStreamSize))
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bufio"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/kubernetes/scheme"
+	fakerest "k8s.io/client-go/rest/fake"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+func TestLogHandlerDoControlRequest(t *testing.T) {
+	var requestURL string
+	fakeRestClient := &fakerest.RESTClient{
+		Client: fakerest.CreateHTTPClient(
+			func(req *http.Request) (*http.Response, error) {
+				requestURL = req.URL.String()
+				body := "HTTP/1.0 200 OK\r\nConnection: close\r\n\r\nlog line\n"
+				resp, _ := http.ReadResponse(bufio.NewReader(strings.NewReader(body)), req)
+				return resp, nil
+			},
+		),
+		GroupVersion:         v1.SchemeGroupVersion,
+		NegotiatedSerializer: serializer.NewCodecFactory(scheme.Scheme),
+		VersionedAPIPath:     "/",
+	}
+	h := &logHandler{restclient: fakeRestClient}
+
+	resp, err := h.Do(makeExecMessage(http.MethodGet,
+		"/api/v1/namespaces/default/pods/p1/log?container=c1&follow=true", t))
+	assert.Nil(t, err)
+	assert.Contains(t, requestURL, "container=c1")
+	assert.Contains(t, requestURL, "limitBytes=")
+	assert.NotContains(t, requestURL, "follow")
+
+	taskResp := &clustermessage.ControllerTaskResponse{}
+	assert.Nil(t, proto.Unmarshal(resp.Body, taskResp))
+	assert.Equal(t, int32(http.StatusOK), taskResp.StatusCode)
+	assert.Equal(t, "log line\n", string(taskResp.Body))
+
+	_, err = h.Do(makeExecMessage(http.MethodPost, "/api/v1/namespaces/default/pods/p1/log", t))
+	assert.NotNil(t, err)
+
+	_, err = h.Do(makeExecMessage(http.MethodGet, "/api/v1/namespaces/default/pods", t))
+	assert.NotNil(t, err)
+}
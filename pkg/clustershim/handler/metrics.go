@@ -0,0 +1,276 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+// latencyBuckets are the upper bounds of latency histograms of destinations.
+var latencyBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+}
+
+// LatencyBucket is the number of requests done in LE seconds.
+type LatencyBucket struct {
+	LE    string `json:"le"`
+	Count uint64 `json:"count"`
+}
+
+// SlowRequest is a request exceeding the latency budget of its destination.
+type SlowRequest struct {
+	Method  string    `json:"method"`
+	URI     string    `json:"uri"`
+	Seconds float64   `json:"seconds"`
+	Time    time.Time `json:"time"`
+}
+
+// DestinationStats are the stats of requests done by the handler of a destination.
+type DestinationStats struct {
+	Requests uint64 `json:"requests"`
+	// Errors are requests failed by the handler, or responded with status code of 4xx or 5xx.
+	Errors      uint64            `json:"errors"`
+	Slow        uint64            `json:"slow"`
+	StatusCodes map[string]uint64 `json:"statusCodes,omitempty"`
+	// Buckets are cumulative, the last one is +Inf.
+	Buckets    []LatencyBucket `json:"buckets"`
+	SumSeconds float64         `json:"sumSeconds"`
+	MaxSeconds float64         `json:"maxSeconds"`
+	Budget     string          `json:"budget,omitempty"`
+	LastSlow   *SlowRequest    `json:"lastSlow,omitempty"`
+}
+
+type destinationMetrics struct {
+	requests    uint64
+	errors      uint64
+	slow        uint64
+	statusCodes map[int32]uint64
+	// buckets are counts by latencyBuckets, not cumulative, the last one is +Inf.
+	buckets  []uint64
+	sum      time.Duration
+	max      time.Duration
+	lastSlow *SlowRequest
+}
+
+// Metrics collects latency and errors of shim handlers by destination,
+// and logs requests exceeding latency budgets of their destinations.
+type Metrics struct {
+	now func() time.Time
+
+	lock          sync.Mutex
+	defaultBudget time.Duration
+	budgets       map[string]time.Duration
+	destinations  map[string]*destinationMetrics
+}
+
+// NewMetrics returns a new Metrics without latency budgets.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		now:          time.Now,
+		destinations: make(map[string]*destinationMetrics),
+	}
+}
+
+var defaultMetrics = NewMetrics()
+
+// SetLatencyBudgets sets the latency budget of destinations, and the default one of
+// destinations not in budgets. 0 means no budget.
+func (m *Metrics) SetLatencyBudgets(defaultBudget time.Duration, budgets map[string]time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.defaultBudget = defaultBudget
+	m.budgets = budgets
+}
+
+func (m *Metrics) budget(destination string) time.Duration {
+	if b, ok := m.budgets[destination]; ok {
+		return b
+	}
+	return m.defaultBudget
+}
+
+// Do does request in by h, the handler of destination, with ctx, and records its latency and result.
+func (m *Metrics) Do(ctx context.Context, destination string, h Handler,
+	in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	start := m.now()
+	resp, err := doRecovered(ctx, h, in)
+	m.record(destination, in, resp, err, m.now().Sub(start))
+	return resp, err
+}
+
+func (m *Metrics) record(destination string, in, resp *clustermessage.ClusterMessage, err error, latency time.Duration) {
+	code := int32(0)
+	if resp != nil && in.Head.Command == clustermessage.CommandType_ControlReq {
+		taskResp := &clustermessage.ControllerTaskResponse{}
+		if proto.Unmarshal(resp.Body, taskResp) == nil {
+			code = taskResp.StatusCode
+		}
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	d, ok := m.destinations[destination]
+	if !ok {
+		d = &destinationMetrics{
+			statusCodes: make(map[int32]uint64),
+			buckets:     make([]uint64, len(latencyBuckets)+1),
+		}
+		m.destinations[destination] = d
+	}
+	d.requests++
+	if err != nil || code >= http.StatusBadRequest {
+		d.errors++
+	}
+	if code != 0 {
+		d.statusCodes[code]++
+	}
+	i := 0
+	for i < len(latencyBuckets) && latency > latencyBuckets[i] {
+		i++
+	}
+	d.buckets[i]++
+	d.sum += latency
+	if latency > d.max {
+		d.max = latency
+	}
+
+	budget := m.budget(destination)
+	if budget <= 0 || latency <= budget {
+		return
+	}
+	method, uri := requestOf(in)
+	d.slow++
+	d.lastSlow = &SlowRequest{
+		Method:  method,
+		URI:     uri,
+		Seconds: latency.Seconds(),
+		Time:    m.now(),
+	}
+	klog.Warningf("handler %s is slow: %s %s took %v, budget %v", destination, method, uri, latency, budget)
+}
+
+// requestOf returns the method and uri of request in.
+func requestOf(in *clustermessage.ClusterMessage) (string, string) {
+	switch in.Head.Command {
+	case clustermessage.CommandType_ControlReq:
+		if task := GetControllerTaskFromClusterMessage(in); task != nil {
+			return task.Method, task.URI
+		}
+	case clustermessage.CommandType_ControlMultiReq:
+		if task := GetControlMultiTaskFromClusterMessage(in); task != nil {
+			return task.Method, task.URI
+		}
+	}
+	return "", ""
+}
+
+// Stats returns stats of destinations.
+func (m *Metrics) Stats() map[string]*DestinationStats {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	stats := make(map[string]*DestinationStats, len(m.destinations))
+	for destination, d := range m.destinations {
+		s := &DestinationStats{
+			Requests:    d.requests,
+			Errors:      d.errors,
+			Slow:        d.slow,
+			StatusCodes: make(map[string]uint64, len(d.statusCodes)),
+			Buckets:     make([]LatencyBucket, len(d.buckets)),
+			SumSeconds:  d.sum.Seconds(),
+			MaxSeconds:  d.max.Seconds(),
+		}
+		for code, n := range d.statusCodes {
+			s.StatusCodes[strconv.Itoa(int(code))] = n
+		}
+		count := uint64(0)
+		for i, n := range d.buckets {
+			count += n
+			le := "+Inf"
+			if i < len(latencyBuckets) {
+				le = strconv.FormatFloat(latencyBuckets[i].Seconds(), 'f', -1, 64)
+			}
+			s.Buckets[i] = LatencyBucket{LE: le, Count: count}
+		}
+		if budget := m.budget(destination); budget > 0 {
+			s.Budget = budget.String()
+		}
+		if d.lastSlow != nil {
+			slow := *d.lastSlow
+			s.LastSlow = &slow
+		}
+		stats[destination] = s
+	}
+	return stats
+}
+
+// StatsHandler is the http handler to get stats of destinations in json.
+func (m *Metrics) StatsHandler(w http.ResponseWriter, r *http.Request) {
+	data, _ := json.Marshal(m.Stats())
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// SetLatencyBudgets sets latency budgets of the default metrics.
+func SetLatencyBudgets(defaultBudget time.Duration, budgets map[string]time.Duration) {
+	defaultMetrics.SetLatencyBudgets(defaultBudget, budgets)
+}
+
+// DoWithMetrics does request in by h, the handler of destination, with ctx, recorded by the default metrics.
+func DoWithMetrics(ctx context.Context, destination string, h Handler,
+	in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	return defaultMetrics.Do(ctx, destination, h, in)
+}
+
+// MetricsHandler is the http handler to get stats of the default metrics in json.
+func MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	defaultMetrics.StatsHandler(w, r)
+}
+
+// ParseLatencyBudgets parses latency budgets of destinations, e.g., {"api": "1s"}.
+func ParseLatencyBudgets(budgets map[string]string) (map[string]time.Duration, error) {
+	ret := make(map[string]time.Duration, len(budgets))
+	for destination, s := range budgets {
+		d, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil {
+			return nil, Errorf(ErrInvalidConfig, "invalid latency budget of %s: %v", destination, err)
+		}
+		ret[destination] = d
+	}
+	return ret, nil
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/errorkind"
+)
+
+// failHandler fails all tasks.
+type failHandler struct{}
+
+func (f *failHandler) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	return nil, fmt.Errorf("failed")
+}
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics()
+	m.SetLatencyBudgets(time.Second, map[string]time.Duration{"exec": 0})
+	now := time.Now()
+	latency := 20 * time.Millisecond
+	m.now = func() time.Time {
+		now = now.Add(latency)
+		return now
+	}
+
+	_, err := m.Do(context.Background(), "api", &countHandler{code: http.StatusOK}, makeTaskMessage(t, "1", http.MethodGet, "/api/v1/pods"))
+	assert.Nil(t, err)
+	_, err = m.Do(context.Background(), "api", &countHandler{code: http.StatusNotFound}, makeTaskMessage(t, "2", http.MethodGet, "/api/v1/nodes"))
+	assert.Nil(t, err)
+	latency = 2 * time.Second
+	_, err = m.Do(context.Background(), "api", &countHandler{code: http.StatusOK}, makeTaskMessage(t, "3", http.MethodPut, "/api/v1/nodes/n1"))
+	assert.Nil(t, err)
+	// no budget of exec.
+	_, err = m.Do(context.Background(), "exec", &failHandler{}, makeTaskMessage(t, "4", http.MethodPost, "/exec"))
+	assert.NotNil(t, err)
+
+	stats := m.Stats()
+	api := stats["api"]
+	assert.Equal(t, uint64(3), api.Requests)
+	assert.Equal(t, uint64(1), api.Errors)
+	assert.Equal(t, uint64(1), api.Slow)
+	assert.Equal(t, map[string]uint64{"200": 2, "404": 1}, api.StatusCodes)
+	assert.Equal(t, "1s", api.Budget)
+	assert.Equal(t, 2.0, api.MaxSeconds)
+	assert.InDelta(t, 2.04, api.SumSeconds, 1e-9)
+	assert.Equal(t, http.MethodPut, api.LastSlow.Method)
+	assert.Equal(t, "/api/v1/nodes/n1", api.LastSlow.URI)
+	assert.Len(t, api.Buckets, len(latencyBuckets)+1)
+	assert.Equal(t, LatencyBucket{LE: "0.01", Count: 0}, api.Buckets[1])
+	assert.Equal(t, LatencyBucket{LE: "0.025", Count: 2}, api.Buckets[2])
+	assert.Equal(t, LatencyBucket{LE: "2.5", Count: 3}, api.Buckets[8])
+	assert.Equal(t, LatencyBucket{LE: "+Inf", Count: 3}, api.Buckets[len(latencyBuckets)])
+
+	exec := stats["exec"]
+	assert.Equal(t, uint64(1), exec.Requests)
+	assert.Equal(t, uint64(1), exec.Errors)
+	assert.Equal(t, uint64(0), exec.Slow)
+	assert.Empty(t, exec.Budget)
+	assert.Nil(t, exec.LastSlow)
+
+	w := httptest.NewRecorder()
+	m.StatsHandler(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	got := make(map[string]*DestinationStats)
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, uint64(3), got["api"].Requests)
+}
+
+func TestParseLatencyBudgets(t *testing.T) {
+	budgets, err := ParseLatencyBudgets(map[string]string{"api": "1s", "exec": " 30s"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]time.Duration{"api": time.Second, "exec": 30 * time.Second}, budgets)
+
+	_, err = ParseLatencyBudgets(map[string]string{"api": "fast"})
+	assert.NotNil(t, err)
+}
+
+// panicHandler panics on all tasks.
+type panicHandler struct{}
+
+func (p *panicHandler) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	var task *clustermessage.ControllerTask
+	return nil, fmt.Errorf("%s", task.URI)
+}
+
+func TestMetricsRecoverPanic(t *testing.T) {
+	m := NewMetrics()
+	resp, err := m.Do(context.Background(), "api", &panicHandler{}, makeTaskMessage(t, "1", http.MethodGet, "/api/v1/pods"))
+	assert.NotNil(t, err)
+	assert.True(t, errorkind.Is(err, ErrPanicked))
+	assert.Contains(t, err.Error(), "panicked")
+	taskResp := &clustermessage.ControllerTaskResponse{}
+	assert.Nil(t, proto.Unmarshal(resp.Body, taskResp))
+	assert.Equal(t, int32(http.StatusInternalServerError), taskResp.StatusCode)
+	assert.Equal(t, uint64(1), m.Stats()["api"].Errors)
+}
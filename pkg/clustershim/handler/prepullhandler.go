@@ -0,0 +1,320 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+const (
+	// PrePullNamespace is the namespace of pre-pull daemonsets.
+	PrePullNamespace = "kube-system"
+	// DefaultPrePullHelperImage is the image of the static busybox used to run
+	// in images pre-pulled, so that images without shell can be pre-pulled too.
+	DefaultPrePullHelperImage = "busybox:1.31"
+
+	prePullLabel      = "ote-prepull"
+	prePullNamePrefix = "ote-prepull-"
+	prePullToolsDir   = "/ote-prepull"
+)
+
+// PrePullSpec is the body of request to start pre-pulling images.
+type PrePullSpec struct {
+	// Name identifies the pre-pull in cluster.
+	Name   string   `json:"name"`
+	Images []string `json:"images"`
+	// NodeSelector selects nodes to pre-pull images, all nodes if empty.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// HelperImage is the image containing a static busybox, DefaultPrePullHelperImage if empty.
+	HelperImage string `json:"helperImage,omitempty"`
+}
+
+// PrePullNodeStatus is the progress of pre-pull on a node.
+type PrePullNodeStatus struct {
+	Pulled  []string `json:"pulled,omitempty"`
+	Pending []string `json:"pending,omitempty"`
+	// Failed is the reason of images failed to pull, by image.
+	Failed map[string]string `json:"failed,omitempty"`
+}
+
+// PrePullStatus is the progress of pre-pull in cluster.
+type PrePullStatus struct {
+	Name   string   `json:"name"`
+	Images []string `json:"images"`
+	// DesiredNodes is the number of nodes to pre-pull images.
+	DesiredNodes int `json:"desiredNodes"`
+	// CompletedNodes is the number of nodes pulled all images.
+	CompletedNodes int `json:"completedNodes"`
+	// Nodes is the progress by node name.
+	Nodes map[string]*PrePullNodeStatus `json:"nodes,omitempty"`
+}
+
+// Completed returns true if all images are pulled on all desired nodes.
+func (s *PrePullStatus) Completed() bool {
+	return s.CompletedNodes >= s.DesiredNodes
+}
+
+type prePullHandler struct {
+	client kubernetes.Interface
+}
+
+// NewPrePullHandler returns a new prePullHandler, which pre-pulls images on nodes by daemonsets.
+// POST starts pre-pulling by PrePullSpec in body, GET and DELETE with the name as URI
+// returns the PrePullStatus and stops pre-pulling respectively.
+func NewPrePullHandler(cl kubernetes.Interface) Handler {
+	return &prePullHandler{client: cl}
+}
+
+func (p *prePullHandler) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	switch in.Head.Command {
+	case clustermessage.CommandType_ControlReq:
+		resp, err := p.DoControlRequest(in)
+		return Response(resp, in.Head), err
+	default:
+		return nil, Errorf(ErrUnsupportedCommand, "command %s is not supported by prePullHandler", in.Head.Command.String())
+	}
+}
+
+func (p *prePullHandler) DoControlRequest(in *clustermessage.ClusterMessage) ([]byte, error) {
+	controllerTask := GetControllerTaskFromClusterMessage(in)
+	if controllerTask == nil {
+		return ControlTaskResponse(http.StatusNotFound, ""), ErrTaskNotFound
+	}
+
+	var name string
+	switch controllerTask.Method {
+	case http.MethodPost:
+		spec := &PrePullSpec{}
+		if err := json.Unmarshal(controllerTask.Body, spec); err != nil {
+			return ControlTaskResponse(http.StatusBadRequest, err.Error()), err
+		}
+		if err := p.apply(spec); err != nil {
+			return ControlTaskResponse(statusCodeOf(err), err.Error()), err
+		}
+		name = spec.Name
+	case http.MethodGet:
+		name = strings.Trim(controllerTask.URI, "/")
+	case http.MethodDelete:
+		name = strings.Trim(controllerTask.URI, "/")
+		err := p.client.AppsV1().DaemonSets(PrePullNamespace).Delete(prePullNamePrefix+name, &metav1.DeleteOptions{})
+		if err != nil {
+			return ControlTaskResponse(statusCodeOf(err), err.Error()), err
+		}
+		klog.Infof("pre-pull %s deleted", name)
+		return ControlTaskResponse(http.StatusOK, ""), nil
+	default:
+		return ControlTaskResponse(http.StatusMethodNotAllowed, ""), ErrMethodNotAllowed
+	}
+
+	status, err := p.status(name)
+	if err != nil {
+		return ControlTaskResponse(statusCodeOf(err), err.Error()), err
+	}
+	body, err := json.Marshal(status)
+	if err != nil {
+		return ControlTaskResponse(http.StatusInternalServerError, err.Error()), err
+	}
+	return ControlTaskResponse(http.StatusOK, string(body)), nil
+}
+
+// apply creates the pre-pull daemonset, or updates it if exists.
+func (p *prePullHandler) apply(spec *PrePullSpec) error {
+	if spec.Name == "" || len(spec.Images) == 0 {
+		return errors.NewBadRequest("name and images of pre-pull are required")
+	}
+	ds := prePullDaemonSet(spec)
+	client := p.client.AppsV1().DaemonSets(PrePullNamespace)
+	old, err := client.Get(ds.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = client.Create(ds)
+		if err == nil {
+			klog.Infof("pre-pull %s created with %d images", spec.Name, len(spec.Images))
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	old.Spec.Template = ds.Spec.Template
+	_, err = client.Update(old)
+	if err == nil {
+		klog.Infof("pre-pull %s updated with %d images", spec.Name, len(spec.Images))
+	}
+	return err
+}
+
+// status collects the progress of pre-pull from pods of the daemonset.
+func (p *prePullHandler) status(name string) (*PrePullStatus, error) {
+	ds, err := p.client.AppsV1().DaemonSets(PrePullNamespace).Get(prePullNamePrefix+name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	images := prePullImages(ds)
+	status := &PrePullStatus{
+		Name:         name,
+		Images:       images,
+		DesiredNodes: int(ds.Status.DesiredNumberScheduled),
+		Nodes:        make(map[string]*PrePullNodeStatus),
+	}
+
+	selector := labels.SelectorFromSet(labels.Set{prePullLabel: name}).String()
+	pods, err := p.client.CoreV1().Pods(PrePullNamespace).List(metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Spec.NodeName == "" {
+			continue
+		}
+		node := prePullNodeStatus(pod, images)
+		status.Nodes[pod.Spec.NodeName] = node
+		if len(node.Pulled) == len(images) {
+			status.CompletedNodes++
+		}
+	}
+	return status, nil
+}
+
+func prePullNodeStatus(pod *corev1.Pod, images []string) *PrePullNodeStatus {
+	node := &PrePullNodeStatus{}
+	byName := make(map[string]*corev1.ContainerStatus, len(pod.Status.InitContainerStatuses))
+	for i := range pod.Status.InitContainerStatuses {
+		byName[pod.Status.InitContainerStatuses[i].Name] = &pod.Status.InitContainerStatuses[i]
+	}
+	for i, image := range images {
+		cs, ok := byName[prePullContainerName(i)]
+		switch {
+		case ok && cs.ImageID != "":
+			node.Pulled = append(node.Pulled, image)
+		case ok && cs.State.Waiting != nil && isPullFailure(cs.State.Waiting.Reason):
+			if node.Failed == nil {
+				node.Failed = make(map[string]string)
+			}
+			node.Failed[image] = cs.State.Waiting.Reason + ": " + cs.State.Waiting.Message
+		default:
+			node.Pending = append(node.Pending, image)
+		}
+	}
+	return node
+}
+
+func isPullFailure(reason string) bool {
+	switch reason {
+	case "ErrImagePull", "ImagePullBackOff", "InvalidImageName", "ErrImageNeverPull":
+		return true
+	}
+	return false
+}
+
+func prePullContainerName(i int) string {
+	return fmt.Sprintf("prepull-%d", i)
+}
+
+// prePullImages returns the images pre-pulled by the daemonset in order.
+func prePullImages(ds *appsv1.DaemonSet) []string {
+	var images []string
+	for _, c := range ds.Spec.Template.Spec.InitContainers {
+		if strings.HasPrefix(c.Name, "prepull-") {
+			images = append(images, c.Image)
+		}
+	}
+	return images
+}
+
+// prePullDaemonSet makes the daemonset to pre-pull images.
+// The static busybox of helper image is copied to a shared volume first, then each image runs
+// it to exit at once, so the image is pulled on the node. The pod sleeps after all pulled.
+func prePullDaemonSet(spec *PrePullSpec) *appsv1.DaemonSet {
+	helper := spec.HelperImage
+	if helper == "" {
+		helper = DefaultPrePullHelperImage
+	}
+	volumeMounts := []corev1.VolumeMount{{Name: "tools", MountPath: prePullToolsDir}}
+	busybox := prePullToolsDir + "/busybox"
+	initContainers := []corev1.Container{
+		{
+			Name:         "tools",
+			Image:        helper,
+			Command:      []string{"cp", "/bin/busybox", busybox},
+			VolumeMounts: volumeMounts,
+		},
+	}
+	for i, image := range spec.Images {
+		initContainers = append(initContainers, corev1.Container{
+			Name:         prePullContainerName(i),
+			Image:        image,
+			Command:      []string{busybox, "true"},
+			VolumeMounts: volumeMounts,
+		})
+	}
+
+	podLabels := map[string]string{prePullLabel: spec.Name}
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      prePullNamePrefix + spec.Name,
+			Namespace: PrePullNamespace,
+			Labels:    podLabels,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: podLabels},
+			UpdateStrategy: appsv1.DaemonSetUpdateStrategy{
+				Type: appsv1.RollingUpdateDaemonSetStrategyType,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: podLabels},
+				Spec: corev1.PodSpec{
+					NodeSelector:   spec.NodeSelector,
+					InitContainers: initContainers,
+					Containers: []corev1.Container{
+						{
+							Name:    "sleep",
+							Image:   helper,
+							Command: []string{"sleep", "2147483647"},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{Name: "tools", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+					},
+					// pre-pull on all nodes including masters and tainted nodes.
+					Tolerations: []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+				},
+			},
+		},
+	}
+}
+
+// statusCodeOf returns the http status code of a k8s api error.
+func statusCodeOf(err error) int {
+	if status, ok := err.(errors.APIStatus); ok {
+		return int(status.Status().Code)
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+func makePrePullMessage(t *testing.T, method, uri string, spec *PrePullSpec) *clustermessage.ClusterMessage {
+	var body []byte
+	if spec != nil {
+		body, _ = json.Marshal(spec)
+	}
+	data, err := proto.Marshal(&clustermessage.ControllerTask{
+		Method: method,
+		URI:    uri,
+		Body:   body,
+	})
+	assert.Nil(t, err)
+	return &clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{Command: clustermessage.CommandType_ControlReq},
+		Body: data,
+	}
+}
+
+func doPrePull(t *testing.T, h Handler, msg *clustermessage.ClusterMessage) (*clustermessage.ControllerTaskResponse, error) {
+	resp, err := h.Do(msg)
+	taskResp := &clustermessage.ControllerTaskResponse{}
+	assert.Nil(t, proto.Unmarshal(resp.Body, taskResp))
+	return taskResp, err
+}
+
+func newPrePullPod(name, node string, statuses ...corev1.ContainerStatus) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: PrePullNamespace,
+			Labels:    map[string]string{prePullLabel: "web"},
+		},
+		Spec:   corev1.PodSpec{NodeName: node},
+		Status: corev1.PodStatus{InitContainerStatuses: statuses},
+	}
+}
+
+func TestPrePullDaemonSet(t *testing.T) {
+	ds := prePullDaemonSet(&PrePullSpec{
+		Name:         "web",
+		Images:       []string{"nginx:1.17", "redis:5"},
+		NodeSelector: map[string]string{"zone": "a"},
+	})
+	assert.Equal(t, "ote-prepull-web", ds.Name)
+	assert.Equal(t, PrePullNamespace, ds.Namespace)
+	podSpec := ds.Spec.Template.Spec
+	assert.Equal(t, "a", podSpec.NodeSelector["zone"])
+	assert.Len(t, podSpec.InitContainers, 3)
+	assert.Equal(t, DefaultPrePullHelperImage, podSpec.InitContainers[0].Image)
+	assert.Equal(t, "nginx:1.17", podSpec.InitContainers[1].Image)
+	assert.Equal(t, []string{"/ote-prepull/busybox", "true"}, podSpec.InitContainers[2].Command)
+	assert.Equal(t, []string{"nginx:1.17", "redis:5"}, prePullImages(ds))
+
+	ds = prePullDaemonSet(&PrePullSpec{Name: "web", Images: []string{"a"}, HelperImage: "mirror/busybox"})
+	assert.Equal(t, "mirror/busybox", ds.Spec.Template.Spec.Containers[0].Image)
+}
+
+func TestPrePullHandler(t *testing.T) {
+	client := k8sfake.NewSimpleClientset(
+		newPrePullPod("p1", "n1",
+			corev1.ContainerStatus{Name: "tools", ImageID: "busybox@sha256:1"},
+			corev1.ContainerStatus{Name: "prepull-0", ImageID: "nginx@sha256:1"},
+			corev1.ContainerStatus{Name: "prepull-1", ImageID: "redis@sha256:1"}),
+		newPrePullPod("p2", "n2",
+			corev1.ContainerStatus{Name: "prepull-0", ImageID: "nginx@sha256:1"},
+			corev1.ContainerStatus{Name: "prepull-1", State: corev1.ContainerState{
+				Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "timeout"}}}),
+		newPrePullPod("p3", ""),
+	)
+	h := NewPrePullHandler(client)
+	spec := &PrePullSpec{Name: "web", Images: []string{"nginx:1.17", "redis:5"}}
+
+	// invalid spec
+	resp, err := doPrePull(t, h, makePrePullMessage(t, http.MethodPost, "", &PrePullSpec{Name: "web"}))
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(http.StatusBadRequest), resp.StatusCode)
+
+	// not found
+	resp, err = doPrePull(t, h, makePrePullMessage(t, http.MethodGet, "/web", nil))
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(http.StatusNotFound), resp.StatusCode)
+
+	// create
+	resp, err = doPrePull(t, h, makePrePullMessage(t, http.MethodPost, "", spec))
+	assert.Nil(t, err)
+	assert.Equal(t, int32(http.StatusOK), resp.StatusCode)
+	ds, err := client.AppsV1().DaemonSets(PrePullNamespace).Get("ote-prepull-web", metav1.GetOptions{})
+	assert.Nil(t, err)
+	ds.Status.DesiredNumberScheduled = 2
+	client.AppsV1().DaemonSets(PrePullNamespace).UpdateStatus(ds)
+
+	// status
+	resp, err = doPrePull(t, h, makePrePullMessage(t, http.MethodGet, "/web", nil))
+	assert.Nil(t, err)
+	status := &PrePullStatus{}
+	assert.Nil(t, json.Unmarshal(resp.Body, status))
+	assert.Equal(t, 2, status.DesiredNodes)
+	assert.Equal(t, 1, status.CompletedNodes)
+	assert.False(t, status.Completed())
+	assert.Len(t, status.Nodes, 2)
+	assert.Len(t, status.Nodes["n1"].Pulled, 2)
+	assert.Equal(t, []string{"nginx:1.17"}, status.Nodes["n2"].Pulled)
+	assert.Equal(t, "ImagePullBackOff: timeout", status.Nodes["n2"].Failed["redis:5"])
+
+	// update
+	spec.Images = append(spec.Images, "mysql:8")
+	resp, err = doPrePull(t, h, makePrePullMessage(t, http.MethodPost, "", spec))
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal(resp.Body, status))
+	assert.Len(t, status.Images, 3)
+	assert.Equal(t, 0, status.CompletedNodes)
+	assert.Equal(t, []string{"mysql:8"}, status.Nodes["n1"].Pending)
+
+	// delete
+	resp, err = doPrePull(t, h, makePrePullMessage(t, http.MethodDelete, "/web", nil))
+	assert.Nil(t, err)
+	assert.Equal(t, int32(http.StatusOK), resp.StatusCode)
+	_, err = client.AppsV1().DaemonSets(PrePullNamespace).Get("ote-prepull-web", metav1.GetOptions{})
+	assert.NotNil(t, err)
+
+	_, err = doPrePull(t, h, makePrePullMessage(t, http.MethodPut, "/web", nil))
+	assert.NotNil(t, err)
+}
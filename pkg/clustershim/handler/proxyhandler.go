@@ -0,0 +1,284 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+// ProxyContentType is the content type of requests to and responses from apiserver by proxyHandler.
+const ProxyContentType = "application/json"
+
+// ProxyWatch* are the parameters of watches proxied, the events of a watch are sent in parts
+// when ProxyWatchMaxEvents events are read, or ProxyWatchFlushInterval after the first event
+// of a part, and an empty part is sent each ProxyWatchHeartbeat if no event.
+var (
+	ProxyWatchFlushInterval = 100 * time.Millisecond
+	ProxyWatchHeartbeat     = 10 * time.Second
+	ProxyWatchMaxEvents     = 100
+	// ProxyWatchMaxDuration is the max duration of a watch, in case it is not canceled.
+	ProxyWatchMaxDuration = time.Hour
+)
+
+// ProxyRequest is the body of a task to proxyHandler, method and uri are those of the task.
+type ProxyRequest struct {
+	// ContentType is the content type of body, e.g., application/merge-patch+json,
+	// ProxyContentType if empty.
+	ContentType string `json:"contentType,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+	// Watch is set if the request is a watch, whose events are responded in ProxyWatchParts
+	// asynchronously with the message id of the request.
+	Watch bool `json:"watch,omitempty"`
+	// CancelWatch is the message id of a watch to cancel.
+	CancelWatch string `json:"cancelWatch,omitempty"`
+}
+
+// ProxyWatchPart is a part of the events of a watch proxied, the body of a ControllerTaskResponse.
+type ProxyWatchPart struct {
+	// Seq is the sequence of the part in the watch starting from 1, parts may arrive out of order.
+	Seq int64 `json:"seq"`
+	// Events are the watch events of apiserver in json.
+	Events []json.RawMessage `json:"events,omitempty"`
+	// Done is set in the last part, after the watch is closed by apiserver or canceled.
+	Done  bool   `json:"done,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type proxyHandler struct {
+	restclient rest.Interface
+	responder  Responder
+
+	lock sync.Mutex
+	// watches are the stop channels of watches by message id.
+	watches map[string]chan struct{}
+}
+
+// NewProxyHandler returns a new proxyHandler, which sends k8s api requests proxied
+// from cloud to apiserver as they are, and responds the status code and body of apiserver.
+// Unlike k8sHandler, the content type of body, e.g., patch type, is given by the request.
+// The events of watches are responded in parts asynchronously.
+func NewProxyHandler(cl kubernetes.Interface) Handler {
+	return &proxyHandler{
+		restclient: cl.Discovery().RESTClient(),
+		watches:    make(map[string]chan struct{}),
+	}
+}
+
+func (p *proxyHandler) SetResponder(r Responder) {
+	p.responder = r
+}
+
+func (p *proxyHandler) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	return p.DoContext(context.Background(), in)
+}
+
+func (p *proxyHandler) DoContext(ctx context.Context,
+	in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	switch in.Head.Command {
+	case clustermessage.CommandType_ControlReq:
+		resp, err := p.DoControlRequest(ctx, in)
+		if resp == nil && err == nil {
+			// watch is responded asynchronously.
+			return nil, nil
+		}
+		return Response(resp, in.Head), err
+	default:
+		return nil, Errorf(ErrUnsupportedCommand, "command %s is not supported by proxyHandler", in.Head.Command.String())
+	}
+}
+
+func (p *proxyHandler) DoControlRequest(ctx context.Context, in *clustermessage.ClusterMessage) ([]byte, error) {
+	controllerTask := GetControllerTaskFromClusterMessage(in)
+	if controllerTask == nil {
+		return ControlTaskResponse(http.StatusNotFound, ""), ErrTaskNotFound
+	}
+
+	switch controllerTask.Method {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return ControlTaskResponse(http.StatusMethodNotAllowed, ""), ErrMethodNotAllowed
+	}
+	proxyReq := &ProxyRequest{}
+	if len(controllerTask.Body) > 0 {
+		if err := json.Unmarshal(controllerTask.Body, proxyReq); err != nil {
+			return ControlTaskResponse(http.StatusBadRequest, err.Error()), err
+		}
+	}
+	if proxyReq.ContentType == "" {
+		proxyReq.ContentType = ProxyContentType
+	}
+	if proxyReq.CancelWatch != "" {
+		p.cancelWatch(proxyReq.CancelWatch)
+		return ControlTaskResponse(http.StatusOK, ""), nil
+	}
+	if proxyReq.Watch {
+		if p.responder == nil || controllerTask.Method != http.MethodGet {
+			return ControlTaskResponse(http.StatusBadRequest, "watch is not supported"), Errorf(ErrBadRequest, "watch is not supported")
+		}
+		go p.watch(in.Head, controllerTask.URI)
+		return nil, nil
+	}
+
+	req := p.restclient.Verb(controllerTask.Method).
+		RequestURI(controllerTask.URI).
+		SetHeader("Accept", ProxyContentType).
+		SetHeader("Content-Type", proxyReq.ContentType).
+		Context(ctx)
+	if len(proxyReq.Body) > 0 {
+		req.Body(proxyReq.Body)
+	}
+	result := req.Do()
+
+	var code int
+	result.StatusCode(&code)
+	raw, err := result.Raw()
+	if code == 0 {
+		if ctx.Err() != nil {
+			return contextTaskResponse(ctx)
+		}
+		// apiserver is not reached.
+		if err == nil {
+			err = Errorf(ErrAPIServerUnreachable, "no response from apiserver")
+		}
+		return ControlTaskResponse(http.StatusBadGateway, err.Error()), err
+	}
+	return ControlTaskResponse(code, string(raw)), nil
+}
+
+func (p *proxyHandler) cancelWatch(id string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if stop, ok := p.watches[id]; ok {
+		close(stop)
+		delete(p.watches, id)
+		klog.V(3).Infof("watch %s canceled", id)
+	}
+}
+
+// watch watches uri and sends its events in parts until the watch is closed or canceled.
+func (p *proxyHandler) watch(head *clustermessage.MessageHead, uri string) {
+	id := head.MessageID
+	stop := make(chan struct{})
+	p.lock.Lock()
+	p.watches[id] = stop
+	p.lock.Unlock()
+	defer p.cancelWatch(id)
+
+	var seq int64
+	send := func(code int, part *ProxyWatchPart) {
+		seq++
+		part.Seq = seq
+		data, err := json.Marshal(part)
+		if err != nil {
+			klog.Errorf("marshal part of watch %s failed: %v", id, err)
+			return
+		}
+		respHead := proto.Clone(head).(*clustermessage.MessageHead)
+		respHead.Command = clustermessage.CommandType_ControlResp
+		p.responder(Response(ControlTaskResponse(code, string(data)), respHead))
+	}
+
+	stream, err := p.restclient.Get().
+		RequestURI(uri).
+		SetHeader("Accept", ProxyContentType).
+		Stream()
+	if err != nil {
+		send(statusCodeOf(err), &ProxyWatchPart{Done: true, Error: err.Error()})
+		return
+	}
+	defer stream.Close()
+	klog.V(3).Infof("watch %s started: %s", id, uri)
+	// the first part shows the watch is started.
+	send(http.StatusOK, &ProxyWatchPart{})
+
+	events := make(chan json.RawMessage)
+	readErr := make(chan error, 1)
+	go func() {
+		decoder := json.NewDecoder(stream)
+		for {
+			var event json.RawMessage
+			if err := decoder.Decode(&event); err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case events <- event:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	part := &ProxyWatchPart{}
+	flush := time.NewTimer(ProxyWatchFlushInterval)
+	flush.Stop()
+	heartbeat := time.NewTicker(ProxyWatchHeartbeat)
+	defer heartbeat.Stop()
+	maxDuration := time.NewTimer(ProxyWatchMaxDuration)
+	defer maxDuration.Stop()
+	for {
+		select {
+		case event := <-events:
+			if len(part.Events) == 0 {
+				flush.Reset(ProxyWatchFlushInterval)
+			}
+			part.Events = append(part.Events, event)
+			if len(part.Events) < ProxyWatchMaxEvents {
+				continue
+			}
+			flush.Stop()
+			send(http.StatusOK, part)
+			part = &ProxyWatchPart{}
+		case <-flush.C:
+			send(http.StatusOK, part)
+			part = &ProxyWatchPart{}
+		case <-heartbeat.C:
+			if len(part.Events) == 0 {
+				send(http.StatusOK, part)
+			}
+		case err := <-readErr:
+			part.Done = true
+			if err != io.EOF {
+				part.Error = err.Error()
+			}
+			send(http.StatusOK, part)
+			klog.V(3).Infof("watch %s closed: %v", id, err)
+			return
+		case <-stop:
+			part.Done = true
+			part.Error = "canceled"
+			send(http.StatusOK, part)
+			return
+		case <-maxDuration.C:
+			part.Done = true
+			send(http.StatusOK, part)
+			return
+		}
+	}
+}
@@ -0,0 +1,229 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/kubernetes/scheme"
+	fakerest "k8s.io/client-go/rest/fake"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+func newProxyTask(t *testing.T, method, uri string, req *ProxyRequest) *clustermessage.ClusterMessage {
+	task := &clustermessage.ControllerTask{Method: method, URI: uri}
+	if req != nil {
+		body, err := json.Marshal(req)
+		assert.Nil(t, err)
+		task.Body = body
+	}
+	msg, err := task.ToClusterMessage(&clustermessage.MessageHead{Command: clustermessage.CommandType_ControlReq})
+	assert.Nil(t, err)
+	return msg
+}
+
+func TestProxyHandler(t *testing.T) {
+	var last *http.Request
+	var lastBody []byte
+	fakeRestClient := &fakerest.RESTClient{
+		Client: fakerest.CreateHTTPClient(
+			func(req *http.Request) (*http.Response, error) {
+				last = req
+				lastBody = nil
+				if req.Body != nil {
+					lastBody, _ = ioutil.ReadAll(req.Body)
+				}
+				if req.URL.Path == "/api/v1/namespaces/default/pods/missing" {
+					return &http.Response{
+						StatusCode: http.StatusNotFound,
+						Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"kind":"Status","code":404}`))),
+					}, nil
+				}
+				if req.URL.Path == "/unreachable" {
+					return nil, fmt.Errorf("connection refused")
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"kind":"Pod"}`))),
+				}, nil
+			},
+		),
+		GroupVersion:         v1.SchemeGroupVersion,
+		NegotiatedSerializer: serializer.NewCodecFactory(scheme.Scheme),
+		VersionedAPIPath:     "/",
+	}
+	h := &proxyHandler{restclient: fakeRestClient}
+
+	getResp := func(msg *clustermessage.ClusterMessage) *clustermessage.ControllerTaskResponse {
+		resp := &clustermessage.ControllerTaskResponse{}
+		assert.Nil(t, proto.Unmarshal(msg.Body, resp))
+		return resp
+	}
+
+	msg, err := h.Do(newProxyTask(t, http.MethodGet, "/api/v1/namespaces/default/pods/p1?resourceVersion=0", nil))
+	assert.Nil(t, err)
+	resp := getResp(msg)
+	assert.Equal(t, int32(http.StatusOK), resp.StatusCode)
+	assert.Equal(t, `{"kind":"Pod"}`, string(resp.Body))
+	assert.Equal(t, "/api/v1/namespaces/default/pods/p1", last.URL.Path)
+	assert.Equal(t, "0", last.URL.Query().Get("resourceVersion"))
+	assert.Equal(t, ProxyContentType, last.Header.Get("Accept"))
+
+	// content type of patch is given by request.
+	msg, err = h.Do(newProxyTask(t, http.MethodPatch, "/api/v1/namespaces/default/pods/p1", &ProxyRequest{
+		ContentType: "application/merge-patch+json",
+		Body:        []byte(`{"metadata":{"labels":{"a":"b"}}}`),
+	}))
+	assert.Nil(t, err)
+	assert.Equal(t, http.MethodPatch, last.Method)
+	assert.Equal(t, "application/merge-patch+json", last.Header.Get("Content-Type"))
+	assert.Equal(t, `{"metadata":{"labels":{"a":"b"}}}`, string(lastBody))
+
+	// error status of apiserver is responded as is.
+	msg, err = h.Do(newProxyTask(t, http.MethodGet, "/api/v1/namespaces/default/pods/missing", nil))
+	assert.Nil(t, err)
+	resp = getResp(msg)
+	assert.Equal(t, int32(http.StatusNotFound), resp.StatusCode)
+	assert.Equal(t, `{"kind":"Status","code":404}`, string(resp.Body))
+
+	msg, err = h.Do(newProxyTask(t, http.MethodGet, "/unreachable", nil))
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(http.StatusBadGateway), getResp(msg).StatusCode)
+
+	_, err = h.Do(newProxyTask(t, "CONNECT", "/", nil))
+	assert.NotNil(t, err)
+	task := &clustermessage.ControllerTask{Method: http.MethodPost, URI: "/", Body: []byte("{")}
+	msg, err = task.ToClusterMessage(&clustermessage.MessageHead{Command: clustermessage.CommandType_ControlReq})
+	assert.Nil(t, err)
+	msg, err = h.Do(msg)
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(http.StatusBadRequest), getResp(msg).StatusCode)
+
+	msg.Head.Command = clustermessage.CommandType_EdgeReport
+	msg, err = h.Do(msg)
+	assert.Nil(t, msg)
+	assert.NotNil(t, err)
+}
+
+func TestProxyHandlerWatch(t *testing.T) {
+	defer func(d time.Duration) { ProxyWatchFlushInterval = d }(ProxyWatchFlushInterval)
+	ProxyWatchFlushInterval = 10 * time.Millisecond
+	events := `{"type":"ADDED","object":{"metadata":{"resourceVersion":"1"}}}
+{"type":"MODIFIED","object":{"metadata":{"resourceVersion":"2"}}}
+`
+	blocked := make(chan struct{})
+	fakeRestClient := &fakerest.RESTClient{
+		Client: fakerest.CreateHTTPClient(
+			func(req *http.Request) (*http.Response, error) {
+				switch req.URL.Path {
+				case "/forbidden":
+					return &http.Response{
+						StatusCode: http.StatusForbidden,
+						Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"kind":"Status","code":403}`))),
+					}, nil
+				case "/blocked":
+					r, w := io.Pipe()
+					go func() {
+						<-blocked
+						w.Close()
+					}()
+					return &http.Response{StatusCode: http.StatusOK, Body: r}, nil
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(bytes.NewReader([]byte(events))),
+				}, nil
+			},
+		),
+		GroupVersion:         v1.SchemeGroupVersion,
+		NegotiatedSerializer: serializer.NewCodecFactory(scheme.Scheme),
+		VersionedAPIPath:     "/",
+	}
+	h := &proxyHandler{restclient: fakeRestClient, watches: make(map[string]chan struct{})}
+	watchTask := func(id, uri string) *clustermessage.ClusterMessage {
+		msg := newProxyTask(t, http.MethodGet, uri, &ProxyRequest{Watch: true})
+		msg.Head.MessageID = id
+		return msg
+	}
+
+	// watch is not supported without responder.
+	_, err := h.Do(watchTask("w0", "/api/v1/pods?watch=true"))
+	assert.NotNil(t, err)
+
+	resps := make(chan *clustermessage.ClusterMessage, 10)
+	h.SetResponder(func(resp *clustermessage.ClusterMessage) {
+		resps <- resp
+	})
+	nextPart := func() (int32, *ProxyWatchPart) {
+		resp := <-resps
+		assert.Equal(t, clustermessage.CommandType_ControlResp, resp.Head.Command)
+		taskResp := &clustermessage.ControllerTaskResponse{}
+		assert.Nil(t, proto.Unmarshal(resp.Body, taskResp))
+		part := &ProxyWatchPart{}
+		assert.Nil(t, json.Unmarshal(taskResp.Body, part))
+		return taskResp.StatusCode, part
+	}
+
+	msg, err := h.Do(watchTask("w1", "/api/v1/pods?watch=true"))
+	assert.Nil(t, msg)
+	assert.Nil(t, err)
+	code, part := nextPart()
+	assert.Equal(t, int32(http.StatusOK), code)
+	assert.Equal(t, int64(1), part.Seq)
+	assert.Empty(t, part.Events)
+	// events are sent in parts until done.
+	count := 0
+	for seq := int64(2); !part.Done; seq++ {
+		_, part = nextPart()
+		assert.Equal(t, seq, part.Seq)
+		count += len(part.Events)
+	}
+	assert.Equal(t, 2, count)
+	assert.True(t, part.Done)
+	assert.Empty(t, part.Error)
+
+	// watch refused by apiserver.
+	h.Do(watchTask("w2", "/forbidden"))
+	code, part = nextPart()
+	assert.Equal(t, int32(http.StatusForbidden), code)
+	assert.True(t, part.Done)
+
+	// watch canceled.
+	defer close(blocked)
+	h.Do(watchTask("w3", "/blocked"))
+	_, part = nextPart()
+	assert.False(t, part.Done)
+	msg, err = h.Do(newProxyTask(t, http.MethodDelete, "/", &ProxyRequest{CancelWatch: "w3"}))
+	assert.Nil(t, err)
+	assert.NotNil(t, msg)
+	_, part = nextPart()
+	assert.True(t, part.Done)
+	assert.Equal(t, "canceled", part.Error)
+	assert.Empty(t, h.watches)
+}
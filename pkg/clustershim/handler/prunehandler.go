@@ -0,0 +1,194 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+// OwnerLabel is the label of objects distributed to clusters by cloud controllers,
+// the value is the name of the controller owning the object.
+const OwnerLabel = "ote-owner"
+
+// PruneResource declares all objects of a resource an owner keeps in cluster.
+type PruneResource struct {
+	// URI lists objects of the resource in all namespaces, e.g., /api/v1/services.
+	URI string `json:"uri"`
+	// Names are the objects declared, namespace/name of namespaced ones or name of others.
+	Names []string `json:"names,omitempty"`
+}
+
+// PruneManifest declares objects an owner keeps in cluster, other objects of its resources
+// labeled with the owner are garbage and deleted.
+type PruneManifest struct {
+	Owner     string          `json:"owner"`
+	Resources []PruneResource `json:"resources"`
+}
+
+// PruneResult is the result of pruning a manifest.
+type PruneResult struct {
+	// Deleted are the uris of objects deleted.
+	Deleted []string `json:"deleted,omitempty"`
+	// Errors are errors of listing or deleting objects.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// pruneHandler deletes objects distributed to cluster but not declared by their owner any more.
+type pruneHandler struct {
+	restclient rest.Interface
+}
+
+/*
+NewPruneHandler returns a handler of pruning manifests sent by cloud controllers in body of tasks.
+Objects of resources in a manifest labeled with OwnerLabel of its owner are deleted if not declared,
+so that objects removed in cloud do not stay in cluster. Objects without the label, e.g.,
+created by users of cluster, are never deleted.
+*/
+func NewPruneHandler(cl kubernetes.Interface) Handler {
+	return &pruneHandler{restclient: cl.Discovery().RESTClient()}
+}
+
+func (p *pruneHandler) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	switch in.Head.Command {
+	case clustermessage.CommandType_ControlReq:
+		resp, err := p.DoControlRequest(in)
+		return Response(resp, in.Head), err
+	default:
+		return nil, Errorf(ErrUnsupportedCommand, "command %s is not supported by pruneHandler", in.Head.Command.String())
+	}
+}
+
+func (p *pruneHandler) DoControlRequest(in *clustermessage.ClusterMessage) ([]byte, error) {
+	controllerTask := GetControllerTaskFromClusterMessage(in)
+	if controllerTask == nil {
+		return ControlTaskResponse(http.StatusNotFound, ""), ErrTaskNotFound
+	}
+	if controllerTask.Method != http.MethodPost {
+		return ControlTaskResponse(http.StatusMethodNotAllowed, ""), ErrMethodNotAllowed
+	}
+	manifest := &PruneManifest{}
+	if err := json.Unmarshal(controllerTask.Body, manifest); err != nil {
+		return ControlTaskResponse(http.StatusBadRequest, err.Error()), err
+	}
+	if err := manifest.validate(); err != nil {
+		return ControlTaskResponse(http.StatusBadRequest, err.Error()), err
+	}
+
+	result := &PruneResult{}
+	for _, res := range manifest.Resources {
+		p.prune(manifest.Owner, &res, result)
+	}
+	body, err := json.Marshal(result)
+	if err != nil {
+		return ControlTaskResponse(http.StatusInternalServerError, err.Error()), err
+	}
+	code := http.StatusOK
+	if len(result.Errors) != 0 {
+		code = http.StatusInternalServerError
+	}
+	return ControlTaskResponse(code, string(body)), nil
+}
+
+func (m *PruneManifest) validate() error {
+	if m.Owner == "" {
+		return Errorf(ErrBadRequest, "owner is required")
+	}
+	for _, res := range m.Resources {
+		if !strings.HasPrefix(res.URI, "/api/") && !strings.HasPrefix(res.URI, "/apis/") {
+			return Errorf(ErrBadRequest, "uri %q is not a resource", res.URI)
+		}
+	}
+	return nil
+}
+
+// prune deletes objects of res labeled with owner but not declared.
+func (p *pruneHandler) prune(owner string, res *PruneResource, result *PruneResult) {
+	declared := make(map[string]bool, len(res.Names))
+	for _, name := range res.Names {
+		declared[name] = true
+	}
+	selector := url.Values{"labelSelector": []string{OwnerLabel + "=" + owner}}
+	raw, err := p.request(http.MethodGet, res.URI+"?"+selector.Encode())
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("list %s failed: %v", res.URI, err))
+		return
+	}
+	list := struct {
+		Items []struct {
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+		} `json:"items"`
+	}{}
+	if err := json.Unmarshal(raw, &list); err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("decode %s failed: %v", res.URI, err))
+		return
+	}
+
+	var garbage []string
+	for _, item := range list.Items {
+		name := item.Metadata.Name
+		if item.Metadata.Namespace != "" {
+			name = item.Metadata.Namespace + "/" + name
+		}
+		if !declared[name] {
+			garbage = append(garbage, objectURI(res.URI, item.Metadata.Namespace, item.Metadata.Name))
+		}
+	}
+	sort.Strings(garbage)
+	for _, uri := range garbage {
+		klog.Infof("%s is not declared by %s, delete it", uri, owner)
+		if _, err := p.request(http.MethodDelete, uri); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("delete %s failed: %v", uri, err))
+			continue
+		}
+		result.Deleted = append(result.Deleted, uri)
+	}
+}
+
+// objectURI returns the uri of object name in namespace listed by uri.
+func objectURI(uri, namespace, name string) string {
+	if namespace == "" || strings.Contains(uri, "/namespaces/") {
+		return uri + "/" + name
+	}
+	i := strings.LastIndex(uri, "/")
+	return uri[:i] + "/namespaces/" + namespace + uri[i:] + "/" + name
+}
+
+func (p *pruneHandler) request(method, uri string) ([]byte, error) {
+	result := p.restclient.Verb(method).RequestURI(uri).Do()
+	var code int
+	result.StatusCode(&code)
+	raw, err := result.Raw()
+	if code == http.StatusNotFound && method == http.MethodDelete {
+		// deleted already.
+		return raw, nil
+	}
+	return raw, err
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/kubernetes/scheme"
+	fakerest "k8s.io/client-go/rest/fake"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+func TestObjectURI(t *testing.T) {
+	assert.Equal(t, "/api/v1/namespaces/ns1", objectURI("/api/v1/namespaces", "", "ns1"))
+	assert.Equal(t, "/api/v1/namespaces/ns1/services/svc1", objectURI("/api/v1/services", "ns1", "svc1"))
+	assert.Equal(t, "/apis/apps/v1/namespaces/ns1/deployments/d1",
+		objectURI("/apis/apps/v1/deployments", "ns1", "d1"))
+	assert.Equal(t, "/api/v1/namespaces/ns1/services/svc1",
+		objectURI("/api/v1/namespaces/ns1/services", "ns1", "svc1"))
+}
+
+func TestPruneHandler(t *testing.T) {
+	var requests []string
+	fakeRestClient := &fakerest.RESTClient{
+		Client: fakerest.CreateHTTPClient(
+			func(req *http.Request) (*http.Response, error) {
+				requests = append(requests, req.Method+" "+req.URL.Path)
+				code, body := http.StatusOK, `{}`
+				switch {
+				case req.Method == http.MethodGet && req.URL.Path == "/api/v1/services":
+					assert.Equal(t, OwnerLabel+"=serviceimport", req.URL.Query().Get("labelSelector"))
+					body = `{"items":[{"metadata":{"name":"svc1","namespace":"ns1"}},` +
+						`{"metadata":{"name":"svc2","namespace":"ns1"}},{"metadata":{"name":"svc3","namespace":"ns2"}}]}`
+				case req.Method == http.MethodGet:
+					code, body = http.StatusForbidden, `{}`
+				case req.URL.Path == "/api/v1/namespaces/ns2/services/svc3":
+					code = http.StatusNotFound
+				}
+				return &http.Response{
+					StatusCode: code,
+					Body:       ioutil.NopCloser(bytes.NewReader([]byte(body))),
+				}, nil
+			},
+		),
+		GroupVersion:         v1.SchemeGroupVersion,
+		NegotiatedSerializer: serializer.NewCodecFactory(scheme.Scheme),
+		VersionedAPIPath:     "/",
+	}
+	h := &pruneHandler{restclient: fakeRestClient}
+	pruneTask := func(method string, manifest *PruneManifest) *clustermessage.ClusterMessage {
+		body, _ := json.Marshal(manifest)
+		task := &clustermessage.ControllerTask{Method: method, Body: body}
+		msg, err := task.ToClusterMessage(&clustermessage.MessageHead{Command: clustermessage.CommandType_ControlReq})
+		assert.Nil(t, err)
+		return msg
+	}
+	getResp := func(msg *clustermessage.ClusterMessage) (int32, *PruneResult) {
+		resp := &clustermessage.ControllerTaskResponse{}
+		assert.Nil(t, proto.Unmarshal(msg.Body, resp))
+		result := &PruneResult{}
+		json.Unmarshal(resp.Body, result)
+		return resp.StatusCode, result
+	}
+
+	// invalid manifests.
+	_, err := h.Do(pruneTask(http.MethodGet, &PruneManifest{Owner: "serviceimport"}))
+	assert.NotNil(t, err)
+	_, err = h.Do(pruneTask(http.MethodPost, &PruneManifest{}))
+	assert.NotNil(t, err)
+	_, err = h.Do(pruneTask(http.MethodPost, &PruneManifest{
+		Owner: "serviceimport", Resources: []PruneResource{{URI: "/healthz"}}}))
+	assert.NotNil(t, err)
+	assert.Empty(t, requests)
+
+	// objects not declared are deleted, deleted ones are ignored.
+	msg, err := h.Do(pruneTask(http.MethodPost, &PruneManifest{
+		Owner:     "serviceimport",
+		Resources: []PruneResource{{URI: "/api/v1/services", Names: []string{"ns1/svc1"}}},
+	}))
+	assert.Nil(t, err)
+	code, result := getResp(msg)
+	assert.Equal(t, int32(http.StatusOK), code)
+	assert.Equal(t, []string{"/api/v1/namespaces/ns1/services/svc2", "/api/v1/namespaces/ns2/services/svc3"},
+		result.Deleted)
+	assert.Equal(t, []string{
+		"GET /api/v1/services",
+		"DELETE /api/v1/namespaces/ns1/services/svc2",
+		"DELETE /api/v1/namespaces/ns2/services/svc3",
+	}, requests)
+
+	// objects of a resource failed listing are kept.
+	requests = nil
+	msg, err = h.Do(pruneTask(http.MethodPost, &PruneManifest{
+		Owner:     "serviceimport",
+		Resources: []PruneResource{{URI: "/api/v1/endpoints"}},
+	}))
+	assert.Nil(t, err)
+	code, result = getResp(msg)
+	assert.Equal(t, int32(http.StatusInternalServerError), code)
+	assert.Len(t, result.Errors, 1)
+	assert.Empty(t, result.Deleted)
+	assert.Equal(t, []string{"GET /api/v1/endpoints"}, requests)
+}
@@ -0,0 +1,269 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/quota"
+)
+
+// QuotaGuard holds the limits of the cluster sent by center, and checks tasks
+// creating pods against pods of the cluster, as a backstop of quotas checked by center.
+type QuotaGuard struct {
+	client     kubernetes.Interface
+	restclient rest.Interface
+
+	lock sync.RWMutex
+	// hard are the limits of the cluster, nil if it is not limited.
+	hard corev1.ResourceList
+}
+
+/*
+NewQuotaGuard returns a QuotaGuard of the cluster of cl, which is also the handler
+of limits sent by center in tasks of json ResourceList: PUT sets the limits,
+DELETE clears them and GET returns them. The cluster is not limited until the limits are set.
+*/
+func NewQuotaGuard(cl kubernetes.Interface) *QuotaGuard {
+	return &QuotaGuard{client: cl, restclient: cl.Discovery().RESTClient()}
+}
+
+func (g *QuotaGuard) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	switch in.Head.Command {
+	case clustermessage.CommandType_ControlReq:
+		resp, err := g.DoControlRequest(in)
+		return Response(resp, in.Head), err
+	default:
+		return nil, Errorf(ErrUnsupportedCommand, "command %s is not supported by QuotaGuard", in.Head.Command.String())
+	}
+}
+
+func (g *QuotaGuard) DoControlRequest(in *clustermessage.ClusterMessage) ([]byte, error) {
+	controllerTask := GetControllerTaskFromClusterMessage(in)
+	if controllerTask == nil {
+		return ControlTaskResponse(http.StatusNotFound, ""), ErrTaskNotFound
+	}
+	switch controllerTask.Method {
+	case http.MethodGet:
+		body, err := json.Marshal(g.Hard())
+		if err != nil {
+			return ControlTaskResponse(http.StatusInternalServerError, err.Error()), err
+		}
+		return ControlTaskResponse(http.StatusOK, string(body)), nil
+	case http.MethodPut:
+		hard := corev1.ResourceList{}
+		if err := json.Unmarshal(controllerTask.Body, &hard); err != nil {
+			return ControlTaskResponse(http.StatusBadRequest, err.Error()), Errorf(ErrBadRequest, "invalid quota: %v", err)
+		}
+		klog.V(3).Infof("quota of cluster is set to %v", hard)
+		g.setHard(hard)
+		return ControlTaskResponse(http.StatusOK, "quota set"), nil
+	case http.MethodDelete:
+		klog.V(3).Infof("quota of cluster is cleared")
+		g.setHard(nil)
+		return ControlTaskResponse(http.StatusOK, "quota cleared"), nil
+	}
+	return ControlTaskResponse(http.StatusMethodNotAllowed, ""), ErrMethodNotAllowed
+}
+
+// Hard returns the limits of the cluster, nil if it is not limited.
+func (g *QuotaGuard) Hard() corev1.ResourceList {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	return g.hard
+}
+
+func (g *QuotaGuard) setHard(hard corev1.ResourceList) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.hard = hard
+}
+
+/*
+exceeded returns reasons of limits exceeded if pods of bodies posted, put or patched to uri
+are added to pods of the cluster. Objects updated are charged only the increase
+of their pods, and bodies not of workloads are not charged. Patches, which are json patches
+as sent by k8sHandler, are applied to the workload at uri to charge the result, and rejected
+if they cannot be applied to check.
+*/
+func (g *QuotaGuard) exceeded(ctx context.Context, destination, method, uri string, bodies [][]byte) ([]string, error) {
+	hard := g.Hard()
+	if hard == nil || (method != http.MethodPost && method != http.MethodPut && method != http.MethodPatch) {
+		return nil, nil
+	}
+	requested := corev1.ResourceList{}
+	charged := false
+	for _, body := range bodies {
+		if method == http.MethodPatch {
+			r, ok, err := g.patchedRequests(ctx, uri, body)
+			if err != nil {
+				return []string{fmt.Sprintf("patch of %s cannot be checked: %v", uri, err)}, nil
+			}
+			if ok {
+				requested = quota.Add(requested, r)
+				charged = true
+			}
+			continue
+		}
+		r, ok, err := quota.Requests(body)
+		if err != nil || !ok {
+			continue
+		}
+		if existing := g.existingRequests(ctx, destination, method, uri, body); existing != nil {
+			r = quota.Subtract(r, existing)
+		}
+		requested = quota.Add(requested, r)
+		charged = true
+	}
+	if !charged {
+		return nil, nil
+	}
+	pods, err := g.client.CoreV1().Pods("").List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	running := make([]*corev1.Pod, len(pods.Items))
+	for i := range pods.Items {
+		running[i] = &pods.Items[i]
+	}
+	return quota.Exceeded(hard, quota.Usage(running), requested), nil
+}
+
+// existingRequests returns requests of the object body updates, nil if it does not exist.
+// The object is at uri if put, or at uri of its name if applied.
+func (g *QuotaGuard) existingRequests(ctx context.Context, destination, method, uri string, body []byte) corev1.ResourceList {
+	switch {
+	case method == http.MethodPut:
+	case destination == otev1.ClusterControllerDestApply:
+		obj := &struct {
+			Metadata metav1.ObjectMeta `json:"metadata"`
+		}{}
+		if err := json.Unmarshal(body, obj); err != nil || obj.Metadata.Name == "" {
+			return nil
+		}
+		uri = strings.TrimSuffix(uri, "/") + "/" + obj.Metadata.Name
+	default:
+		return nil
+	}
+	raw, err := g.restclient.Get().RequestURI(uri).Context(ctx).Do().Raw()
+	if err != nil {
+		return nil
+	}
+	existing, ok, err := quota.Requests(raw)
+	if err != nil || !ok {
+		return nil
+	}
+	return existing
+}
+
+/*
+patchedRequests returns the increase of requests of the workload at uri if json patch is applied,
+false if it is not a workload or does not exist, whose patch fails in apiserver as well.
+Errors are returned if the workload is not got or patch is not applied to it.
+*/
+func (g *QuotaGuard) patchedRequests(ctx context.Context, uri string, patch []byte) (corev1.ResourceList, bool, error) {
+	raw, err := g.restclient.Get().RequestURI(uri).Context(ctx).Do().Raw()
+	if apierrors.IsNotFound(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	existing, ok, err := quota.Requests(raw)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	p, err := jsonpatch.DecodePatch(patch)
+	if err != nil {
+		return nil, false, err
+	}
+	patched, err := p.Apply(raw)
+	if err != nil {
+		return nil, false, err
+	}
+	requested, ok, err := quota.Requests(patched)
+	if err != nil {
+		return nil, false, err
+	}
+	if !ok {
+		return nil, false, nil
+	}
+	return quota.Subtract(requested, existing), true, nil
+}
+
+// quotaHandler checks tasks of a handler against the quota of the cluster.
+type quotaHandler struct {
+	handler Handler
+	guard   *QuotaGuard
+}
+
+// NewQuotaHandler returns a handler rejecting tasks creating pods exceeding limits of g
+// with status 403, and doing other tasks by h.
+func NewQuotaHandler(h Handler, g *QuotaGuard) Handler {
+	return &quotaHandler{handler: h, guard: g}
+}
+
+func (q *quotaHandler) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	return q.DoContext(context.Background(), in)
+}
+
+func (q *quotaHandler) DoContext(ctx context.Context,
+	in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	switch in.Head.Command {
+	case clustermessage.CommandType_ControlReq:
+		if task := GetControllerTaskFromClusterMessage(in); task != nil {
+			reasons, err := q.guard.exceeded(ctx, task.Destination, task.Method, task.URI, [][]byte{task.Body})
+			// tasks are done if pods are not listed, as they were before quotas.
+			if err != nil {
+				klog.Errorf("check quota of %s failed: %v", task.URI, err)
+			}
+			if len(reasons) != 0 {
+				reason := "quota exceeded: " + strings.Join(reasons, "; ")
+				return Response(ControlTaskResponse(http.StatusForbidden, reason), in.Head),
+					Errorf(ErrQuotaExceeded, "%s %s %s", task.Method, task.URI, reason)
+			}
+		}
+	case clustermessage.CommandType_ControlMultiReq:
+		if task := GetControlMultiTaskFromClusterMessage(in); task != nil {
+			reasons, err := q.guard.exceeded(ctx, task.Destination, task.Method, task.URI, task.Body)
+			if err != nil {
+				klog.Errorf("check quota of %s failed: %v", task.URI, err)
+			}
+			if len(reasons) != 0 {
+				reason := "quota exceeded: " + strings.Join(reasons, "; ")
+				return Response(ControlMultiTaskStatus(task, http.StatusForbidden, reason), in.Head),
+					Errorf(ErrQuotaExceeded, "%s %s %s", task.Method, task.URI, reason)
+			}
+		}
+	}
+	return DoContext(ctx, q.handler, in)
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	fakerest "k8s.io/client-go/rest/fake"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/errorkind"
+)
+
+func newQuotaTestGuard(existing map[string]string, pods ...*v1.Pod) *QuotaGuard {
+	fakeRestClient := &fakerest.RESTClient{
+		Client: fakerest.CreateHTTPClient(
+			func(req *http.Request) (*http.Response, error) {
+				code, body := http.StatusNotFound, `{}`
+				if obj, ok := existing[req.URL.Path]; ok {
+					code, body = http.StatusOK, obj
+				}
+				return &http.Response{
+					StatusCode: code,
+					Body:       ioutil.NopCloser(bytes.NewReader([]byte(body))),
+				}, nil
+			},
+		),
+		GroupVersion:         v1.SchemeGroupVersion,
+		NegotiatedSerializer: serializer.NewCodecFactory(scheme.Scheme),
+		VersionedAPIPath:     "/",
+	}
+	client := k8sfake.NewSimpleClientset()
+	for _, pod := range pods {
+		client.CoreV1().Pods(pod.Namespace).Create(pod)
+	}
+	return &QuotaGuard{client: client, restclient: fakeRestClient}
+}
+
+func newQuotaTestPod(name string, phase v1.PodPhase) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{}}},
+		Status:     v1.PodStatus{Phase: phase},
+	}
+}
+
+func makeQuotaTask(t *testing.T, dest, method, uri, body string) *clustermessage.ClusterMessage {
+	task := &clustermessage.ControllerTask{Destination: dest, Method: method, URI: uri, Body: []byte(body)}
+	msg, err := task.ToClusterMessage(&clustermessage.MessageHead{Command: clustermessage.CommandType_ControlReq})
+	assert.Nil(t, err)
+	return msg
+}
+
+func quotaTaskStatus(t *testing.T, msg *clustermessage.ClusterMessage) (int32, string) {
+	resp := &clustermessage.ControllerTaskResponse{}
+	assert.Nil(t, proto.Unmarshal(msg.Body, resp))
+	return resp.StatusCode, string(resp.Body)
+}
+
+func TestQuotaGuard(t *testing.T) {
+	g := newQuotaTestGuard(nil)
+	dest := otev1.ClusterControllerDestQuota
+
+	resp, err := g.Do(makeQuotaTask(t, dest, http.MethodPut, "", `{"pods":"3","cpu":"2"}`))
+	assert.Nil(t, err)
+	code, _ := quotaTaskStatus(t, resp)
+	assert.Equal(t, int32(http.StatusOK), code)
+	hard := g.Hard()
+	assert.Equal(t, int64(3), hard.Pods().Value())
+
+	resp, err = g.Do(makeQuotaTask(t, dest, http.MethodGet, "", ""))
+	assert.Nil(t, err)
+	code, body := quotaTaskStatus(t, resp)
+	assert.Equal(t, int32(http.StatusOK), code)
+	got := v1.ResourceList{}
+	assert.Nil(t, json.Unmarshal([]byte(body), &got))
+	assert.Equal(t, int64(2), got.Cpu().Value())
+
+	_, err = g.Do(makeQuotaTask(t, dest, http.MethodPut, "", "{"))
+	assert.True(t, errorkind.Is(err, ErrBadRequest))
+
+	_, err = g.Do(makeQuotaTask(t, dest, http.MethodDelete, "", ""))
+	assert.Nil(t, err)
+	assert.Nil(t, g.Hard())
+
+	_, err = g.Do(makeQuotaTask(t, dest, http.MethodPost, "", ""))
+	assert.Equal(t, ErrMethodNotAllowed, err)
+}
+
+func TestQuotaHandler(t *testing.T) {
+	existing := map[string]string{
+		"/apis/apps/v1/namespaces/default/deployments/d1": `{"kind":"Deployment","spec":{"replicas":2}}`,
+	}
+	g := newQuotaTestGuard(existing,
+		newQuotaTestPod("p1", v1.PodRunning),
+		newQuotaTestPod("p2", v1.PodPending),
+		newQuotaTestPod("p3", v1.PodSucceeded))
+	inner := &countHandler{code: http.StatusCreated}
+	h := NewQuotaHandler(inner, g)
+	api := otev1.ClusterControllerDestAPI
+	uri := "/apis/apps/v1/namespaces/default/deployments"
+
+	// the cluster is not limited before limits are set.
+	_, err := h.Do(makeQuotaTask(t, api, http.MethodPost, uri, `{"kind":"Deployment","spec":{"replicas":10}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, inner.count)
+
+	g.setHard(v1.ResourceList{v1.ResourcePods: resource.MustParse("4")})
+	resp, err := h.Do(makeQuotaTask(t, api, http.MethodPost, uri, `{"kind":"Deployment","spec":{"replicas":3}}`))
+	assert.True(t, errorkind.Is(err, ErrQuotaExceeded))
+	code, body := quotaTaskStatus(t, resp)
+	assert.Equal(t, int32(http.StatusForbidden), code)
+	assert.Contains(t, body, "pods: 5 > 4")
+	assert.Equal(t, 1, inner.count)
+
+	_, err = h.Do(makeQuotaTask(t, api, http.MethodPost, uri, `{"kind":"Deployment","spec":{"replicas":2}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, inner.count)
+
+	// updates are charged the increase of pods only.
+	_, err = h.Do(makeQuotaTask(t, api, http.MethodPut, uri+"/d1", `{"kind":"Deployment","spec":{"replicas":4}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, inner.count)
+	_, err = h.Do(makeQuotaTask(t, otev1.ClusterControllerDestApply, http.MethodPost, uri,
+		`{"kind":"Deployment","metadata":{"name":"d1"},"spec":{"replicas":5}}`))
+	assert.True(t, errorkind.Is(err, ErrQuotaExceeded))
+
+	// tasks not creating pods are done.
+	_, err = h.Do(makeQuotaTask(t, api, http.MethodPost, "/api/v1/namespaces/default/configmaps", `{"kind":"ConfigMap"}`))
+	assert.Nil(t, err)
+	_, err = h.Do(makeQuotaTask(t, api, http.MethodDelete, uri+"/d1", ""))
+	assert.Nil(t, err)
+	assert.Equal(t, 5, inner.count)
+
+	// bodies of multi tasks are charged in total.
+	multi := &clustermessage.ControlMultiTask{Destination: api, Method: http.MethodPost, URI: uri, Body: [][]byte{
+		[]byte(`{"kind":"Pod"}`), []byte(`{"kind":"Pod"}`), []byte(`{"kind":"Pod"}`),
+	}}
+	msg, err := multi.ToClusterMessage(&clustermessage.MessageHead{Command: clustermessage.CommandType_ControlMultiReq})
+	assert.Nil(t, err)
+	resp, err = h.Do(msg)
+	assert.True(t, errorkind.Is(err, ErrQuotaExceeded))
+	items := GetControlMultiTaskResponseFromClusterMessage(resp).GetItems()
+	assert.Len(t, items, 3)
+	assert.Equal(t, int32(http.StatusForbidden), items[2].StatusCode)
+	assert.Equal(t, 5, inner.count)
+}
+
+func TestQuotaHandlerPatch(t *testing.T) {
+	existing := map[string]string{
+		"/apis/apps/v1/namespaces/default/deployments/d1": `{"kind":"Deployment","spec":{"replicas":2}}`,
+		"/api/v1/namespaces/default/configmaps/c1":        `{"kind":"ConfigMap","data":{}}`,
+	}
+	g := newQuotaTestGuard(existing, newQuotaTestPod("p1", v1.PodRunning), newQuotaTestPod("p2", v1.PodRunning))
+	inner := &countHandler{code: http.StatusOK}
+	h := NewQuotaHandler(inner, g)
+	api := otev1.ClusterControllerDestAPI
+	uri := "/apis/apps/v1/namespaces/default/deployments/d1"
+	scale := func(replicas string) string {
+		return `[{"op":"replace","path":"/spec/replicas","value":` + replicas + `}]`
+	}
+
+	// the cluster is not limited before limits are set.
+	_, err := h.Do(makeQuotaTask(t, api, http.MethodPatch, uri, scale("10")))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, inner.count)
+
+	// patches are charged the increase of pods of the workload patched.
+	g.setHard(v1.ResourceList{v1.ResourcePods: resource.MustParse("4")})
+	_, err = h.Do(makeQuotaTask(t, api, http.MethodPatch, uri, scale("4")))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, inner.count)
+	resp, err := h.Do(makeQuotaTask(t, api, http.MethodPatch, uri, scale("5")))
+	assert.True(t, errorkind.Is(err, ErrQuotaExceeded))
+	code, body := quotaTaskStatus(t, resp)
+	assert.Equal(t, int32(http.StatusForbidden), code)
+	assert.Contains(t, body, "pods: 5 > 4")
+	assert.Equal(t, 2, inner.count)
+
+	// patches of objects not workloads or not existing are done.
+	_, err = h.Do(makeQuotaTask(t, api, http.MethodPatch, "/api/v1/namespaces/default/configmaps/c1",
+		`[{"op":"add","path":"/data/k","value":"v"}]`))
+	assert.Nil(t, err)
+	_, err = h.Do(makeQuotaTask(t, api, http.MethodPatch, uri+"x", scale("5")))
+	assert.Nil(t, err)
+	assert.Equal(t, 4, inner.count)
+
+	// patches not applied to check are rejected.
+	resp, err = h.Do(makeQuotaTask(t, api, http.MethodPatch, uri, `{"spec":{"replicas":5}}`))
+	assert.True(t, errorkind.Is(err, ErrQuotaExceeded))
+	code, body = quotaTaskStatus(t, resp)
+	assert.Equal(t, int32(http.StatusForbidden), code)
+	assert.Contains(t, body, "cannot be checked")
+	_, err = h.Do(makeQuotaTask(t, api, http.MethodPatch, uri, `[{"op":"replace","path":"/spec/template/x","value":1}]`))
+	assert.True(t, errorkind.Is(err, ErrQuotaExceeded))
+	assert.Equal(t, 4, inner.count)
+}
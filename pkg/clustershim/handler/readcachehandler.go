@@ -0,0 +1,156 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+// maxReadCacheEntries is the max number of responses cached, the oldest is dropped if exceeded.
+var maxReadCacheEntries = 256
+
+// cachedResponse is a response of a read-only task.
+type cachedResponse struct {
+	body    []byte
+	expires time.Time
+}
+
+// readCacheHandler caches responses of GET tasks of a handler.
+type readCacheHandler struct {
+	handler Handler
+	ttl     time.Duration
+	now     func() time.Time
+
+	lock      sync.Mutex
+	responses map[[sha256.Size]byte]*cachedResponse
+}
+
+/*
+NewReadCacheHandler returns a handler caching succeeded responses of GET tasks done by h
+for ttl, so that GET tasks repeated by cloud components in ttl do not reach the apiserver.
+Tasks are keyed by signature of their destination, uri and body.
+All responses cached are dropped once h does a task other than GET,
+since it may change the objects read.
+*/
+func NewReadCacheHandler(h Handler, ttl time.Duration) Handler {
+	return &readCacheHandler{
+		handler:   h,
+		ttl:       ttl,
+		now:       time.Now,
+		responses: make(map[[sha256.Size]byte]*cachedResponse),
+	}
+}
+
+func (r *readCacheHandler) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	return r.DoContext(context.Background(), in)
+}
+
+func (r *readCacheHandler) DoContext(ctx context.Context,
+	in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	var task *clustermessage.ControllerTask
+	if in.Head.Command == clustermessage.CommandType_ControlReq {
+		task = GetControllerTaskFromClusterMessage(in)
+	}
+	if task == nil || task.Method != http.MethodGet {
+		r.invalidate()
+		return DoContext(ctx, r.handler, in)
+	}
+
+	key := taskSignature(task)
+	if body := r.get(key); body != nil {
+		klog.V(3).Infof("respond %s from read cache", task.URI)
+		return Response(body, in.Head), nil
+	}
+	resp, err := DoContext(ctx, r.handler, in)
+	if err == nil && resp != nil {
+		r.put(key, resp.Body)
+	}
+	return resp, err
+}
+
+// taskSignature returns the signature of task.
+func taskSignature(task *clustermessage.ControllerTask) [sha256.Size]byte {
+	h := sha256.New()
+	for _, s := range []string{task.Destination, task.Method, task.URI} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	h.Write(task.Body)
+	var key [sha256.Size]byte
+	copy(key[:], h.Sum(nil))
+	return key
+}
+
+func (r *readCacheHandler) get(key [sha256.Size]byte) []byte {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	resp, ok := r.responses[key]
+	if !ok {
+		return nil
+	}
+	if !r.now().Before(resp.expires) {
+		delete(r.responses, key)
+		return nil
+	}
+	return resp.body
+}
+
+// put caches body if it is a succeeded response.
+func (r *readCacheHandler) put(key [sha256.Size]byte, body []byte) {
+	resp := &clustermessage.ControllerTaskResponse{}
+	if err := proto.Unmarshal(body, resp); err != nil || resp.StatusCode != http.StatusOK {
+		return
+	}
+	now := r.now()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.responses[key] = &cachedResponse{body: body, expires: now.Add(r.ttl)}
+	if len(r.responses) <= maxReadCacheEntries {
+		return
+	}
+	var oldest *[sha256.Size]byte
+	for k, c := range r.responses {
+		if !now.Before(c.expires) {
+			delete(r.responses, k)
+			continue
+		}
+		if oldest == nil || c.expires.Before(r.responses[*oldest].expires) {
+			k := k
+			oldest = &k
+		}
+	}
+	if len(r.responses) > maxReadCacheEntries {
+		delete(r.responses, *oldest)
+	}
+}
+
+func (r *readCacheHandler) invalidate() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if len(r.responses) != 0 {
+		r.responses = make(map[[sha256.Size]byte]*cachedResponse)
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+// countHandler responds tasks with code and counts them.
+type countHandler struct {
+	code  int
+	count int
+}
+
+func (c *countHandler) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	c.count++
+	return Response(ControlTaskResponse(c.code, in.Head.MessageID), in.Head), nil
+}
+
+func makeTaskMessage(t *testing.T, id, method, uri string) *clustermessage.ClusterMessage {
+	task := &clustermessage.ControllerTask{Destination: "api", Method: method, URI: uri}
+	msg, err := task.ToClusterMessage(&clustermessage.MessageHead{
+		MessageID: id,
+		Command:   clustermessage.CommandType_ControlReq,
+	})
+	assert.Nil(t, err)
+	return msg
+}
+
+func TestReadCacheHandler(t *testing.T) {
+	h := &countHandler{code: http.StatusOK}
+	r := NewReadCacheHandler(h, time.Minute).(*readCacheHandler)
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	resp, err := r.Do(makeTaskMessage(t, "1", http.MethodGet, "/api/v1/pods"))
+	assert.Nil(t, err)
+	cached, err := r.Do(makeTaskMessage(t, "2", http.MethodGet, "/api/v1/pods"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, h.count)
+	assert.Equal(t, resp.Body, cached.Body)
+	assert.Equal(t, "2", cached.Head.MessageID)
+
+	// tasks of other uri are not cached.
+	r.Do(makeTaskMessage(t, "3", http.MethodGet, "/api/v1/nodes"))
+	assert.Equal(t, 2, h.count)
+
+	// responses are expired after ttl.
+	now = now.Add(time.Minute)
+	r.Do(makeTaskMessage(t, "4", http.MethodGet, "/api/v1/pods"))
+	assert.Equal(t, 3, h.count)
+
+	// tasks other than GET are not cached and clear the cache.
+	r.Do(makeTaskMessage(t, "5", http.MethodDelete, "/api/v1/namespaces/default/pods/a"))
+	r.Do(makeTaskMessage(t, "6", http.MethodDelete, "/api/v1/namespaces/default/pods/a"))
+	assert.Equal(t, 5, h.count)
+	assert.Empty(t, r.responses)
+	r.Do(makeTaskMessage(t, "7", http.MethodGet, "/api/v1/pods"))
+	assert.Equal(t, 6, h.count)
+}
+
+func TestReadCacheHandlerFailed(t *testing.T) {
+	h := &countHandler{code: http.StatusNotFound}
+	r := NewReadCacheHandler(h, time.Minute)
+	r.Do(makeTaskMessage(t, "1", http.MethodGet, "/api/v1/pods"))
+	r.Do(makeTaskMessage(t, "2", http.MethodGet, "/api/v1/pods"))
+	assert.Equal(t, 2, h.count)
+}
+
+func TestReadCacheHandlerLimit(t *testing.T) {
+	max := maxReadCacheEntries
+	maxReadCacheEntries = 2
+	defer func() { maxReadCacheEntries = max }()
+
+	h := &countHandler{code: http.StatusOK}
+	r := NewReadCacheHandler(h, time.Minute).(*readCacheHandler)
+	now := time.Now()
+	r.now = func() time.Time { return now }
+	for _, uri := range []string{"/a", "/b", "/c"} {
+		r.Do(makeTaskMessage(t, uri, http.MethodGet, uri))
+		now = now.Add(time.Second)
+	}
+	assert.Len(t, r.responses, 2)
+	r.Do(makeTaskMessage(t, "a", http.MethodGet, "/a"))
+	assert.Equal(t, 4, h.count)
+}
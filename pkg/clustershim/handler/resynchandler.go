@@ -0,0 +1,92 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"net/http"
+	"sync"
+
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+// resyncHandler resyncs resources reported to center when the state epoch of center changes.
+type resyncHandler struct {
+	resync func()
+
+	lock  sync.Mutex
+	epoch string
+}
+
+/*
+NewResyncHandler returns a handler of state epochs announced by center in body of tasks.
+The first epoch is only recorded, since all resources are reported once the reporters start.
+resync is called once the epoch changes, which means center lost the resources mirrored,
+e.g., etcd of center is restored from a backup.
+A task of method PUT calls resync regardless of the epoch, e.g., to report all resources
+for the last time before the cluster is decommissioned.
+*/
+func NewResyncHandler(resync func()) Handler {
+	return &resyncHandler{resync: resync}
+}
+
+func (r *resyncHandler) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	switch in.Head.Command {
+	case clustermessage.CommandType_ControlReq:
+		resp, err := r.DoControlRequest(in)
+		return Response(resp, in.Head), err
+	default:
+		return nil, Errorf(ErrUnsupportedCommand, "command %s is not supported by resyncHandler", in.Head.Command.String())
+	}
+}
+
+func (r *resyncHandler) DoControlRequest(in *clustermessage.ClusterMessage) ([]byte, error) {
+	controllerTask := GetControllerTaskFromClusterMessage(in)
+	if controllerTask == nil {
+		return ControlTaskResponse(http.StatusNotFound, ""), ErrTaskNotFound
+	}
+	if controllerTask.Method == http.MethodPut {
+		klog.Infof("resync all resources requested")
+		go r.resync()
+		return ControlTaskResponse(http.StatusOK, "resync started"), nil
+	}
+	if controllerTask.Method != http.MethodPost {
+		return ControlTaskResponse(http.StatusMethodNotAllowed, ""), ErrMethodNotAllowed
+	}
+	epoch := string(controllerTask.Body)
+	if epoch == "" {
+		return ControlTaskResponse(http.StatusBadRequest, "epoch is required"), Errorf(ErrBadRequest, "epoch is required")
+	}
+
+	r.lock.Lock()
+	last := r.epoch
+	r.epoch = epoch
+	r.lock.Unlock()
+
+	switch last {
+	case epoch:
+		return ControlTaskResponse(http.StatusOK, "epoch unchanged"), nil
+	case "":
+		klog.Infof("state epoch of center is %s", epoch)
+		return ControlTaskResponse(http.StatusOK, "epoch recorded"), nil
+	}
+	klog.Infof("state epoch of center changed from %s to %s, resync all resources", last, epoch)
+	// resources are reported to the same channel responses are sent to.
+	go r.resync()
+	return ControlTaskResponse(http.StatusOK, "resync started"), nil
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+func TestResyncHandler(t *testing.T) {
+	resynced := make(chan struct{}, 1)
+	h := NewResyncHandler(func() { resynced <- struct{}{} })
+	epochTask := func(method, epoch string) *clustermessage.ClusterMessage {
+		task := &clustermessage.ControllerTask{Method: method, Body: []byte(epoch)}
+		msg, err := task.ToClusterMessage(&clustermessage.MessageHead{Command: clustermessage.CommandType_ControlReq})
+		assert.Nil(t, err)
+		return msg
+	}
+	getResp := func(msg *clustermessage.ClusterMessage) *clustermessage.ControllerTaskResponse {
+		resp := &clustermessage.ControllerTaskResponse{}
+		assert.Nil(t, proto.Unmarshal(msg.Body, resp))
+		return resp
+	}
+	noResync := func() {
+		select {
+		case <-resynced:
+			t.Errorf("unexpected resync")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	// the first epoch is recorded.
+	msg, err := h.Do(epochTask(http.MethodPost, "1"))
+	assert.Nil(t, err)
+	assert.Equal(t, "epoch recorded", string(getResp(msg).Body))
+	noResync()
+
+	// the same epoch.
+	msg, err = h.Do(epochTask(http.MethodPost, "1"))
+	assert.Nil(t, err)
+	assert.Equal(t, "epoch unchanged", string(getResp(msg).Body))
+	noResync()
+
+	// epoch changed.
+	msg, err = h.Do(epochTask(http.MethodPost, "2"))
+	assert.Nil(t, err)
+	assert.Equal(t, int32(http.StatusOK), getResp(msg).StatusCode)
+	select {
+	case <-resynced:
+	case <-time.After(time.Second):
+		t.Errorf("resync is not called")
+	}
+
+	// resync regardless of epoch.
+	msg, err = h.Do(epochTask(http.MethodPut, ""))
+	assert.Nil(t, err)
+	assert.Equal(t, "resync started", string(getResp(msg).Body))
+	select {
+	case <-resynced:
+	case <-time.After(time.Second):
+		t.Errorf("resync is not called")
+	}
+
+	// invalid tasks.
+	msg, err = h.Do(epochTask(http.MethodGet, "3"))
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(http.StatusMethodNotAllowed), getResp(msg).StatusCode)
+	msg, err = h.Do(epochTask(http.MethodPost, ""))
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(http.StatusBadRequest), getResp(msg).StatusCode)
+	noResync()
+
+	_, err = h.Do(&clustermessage.ClusterMessage{Head: &clustermessage.MessageHead{Command: clustermessage.CommandType_EdgeReport}})
+	assert.NotNil(t, err)
+}
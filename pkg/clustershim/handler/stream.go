@@ -0,0 +1,54 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"encoding/json"
+)
+
+// Stream* are the channels of a multiplexed stream,
+// same as the channels used by kube-apiserver for exec.
+const (
+	StreamStdin  = 0
+	StreamStdout = 1
+	StreamStderr = 2
+	StreamError  = 3
+)
+
+// StreamFrame is a piece of data read from a channel of a multiplexed stream.
+type StreamFrame struct {
+	Channel int    `json:"channel"`
+	Data    []byte `json:"data"`
+}
+
+// EncodeStream serializes frames in order, so that the stream can be
+// carried by the body of ControllerTaskResponse.
+func EncodeStream(frames []StreamFrame) ([]byte, error) {
+	if frames == nil {
+		frames = []StreamFrame{}
+	}
+	return json.Marshal(frames)
+}
+
+// DecodeStream deserializes frames encoded by EncodeStream.
+func DecodeStream(data []byte) ([]StreamFrame, error) {
+	frames := []StreamFrame{}
+	if err := json.Unmarshal(data, &frames); err != nil {
+		return nil, Errorf(ErrInvalidStream, "decode stream failed: %v", err)
+	}
+	return frames, nil
+}
@@ -17,9 +17,13 @@ limitations under the License.
 package clustershim
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"sort"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/gorilla/websocket"
@@ -29,27 +33,37 @@ import (
 	"github.com/baidu/ote-stack/pkg/clustermessage"
 	"github.com/baidu/ote-stack/pkg/clustershim/handler"
 	"github.com/baidu/ote-stack/pkg/config"
+	"github.com/baidu/ote-stack/pkg/envelope"
 	"github.com/baidu/ote-stack/pkg/k8sclient"
 	"github.com/baidu/ote-stack/pkg/tunnel"
 )
 
 const (
 	shimRespChanLen = 100
+	// shimServerPathForDestinations is the path of shim server to get its destinations.
+	shimServerPathForDestinations = "/destinations"
+	destinationsTimeout           = 5 * time.Second
 )
 
 // ShimServiceClient is the client interface to a cluster shim.
 type ShimServiceClient interface {
-	Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error)
+	// Do does in by the shim, which stops calls to apiserver once ctx is done.
+	Do(ctx context.Context, in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error)
 	ReturnChan() <-chan *clustermessage.ClusterMessage
+	// Destinations returns the destinations supported by the shim, nil if unknown.
+	Destinations() []string
 }
 
 type localShimClient struct {
 	handlers map[string]handler.Handler
+	// respChan is the responses sent asynchronously by handlers.
+	respChan chan *clustermessage.ClusterMessage
 }
 
 type remoteShimClient struct {
-	client   *tunnel.WSClient
-	respChan chan *clustermessage.ClusterMessage
+	client       *tunnel.WSClient
+	respChan     chan *clustermessage.ClusterMessage
+	destinations []string
 }
 
 // ShimHandler is a handler map of a shim server.
@@ -67,44 +81,96 @@ func NewlocalShimClient(c *config.ClusterControllerConfig) ShimServiceClient {
 
 	local := &localShimClient{
 		handlers: make(map[string]handler.Handler),
+		respChan: make(chan *clustermessage.ClusterMessage, shimRespChanLen),
 	}
+	defer local.setResponders()
 
 	local.handlers[otev1.ClusterControllerDestAPI] = handler.NewK8sHandler(k8sClient)
+	if c.ReadCacheTTL > 0 {
+		local.handlers[otev1.ClusterControllerDestAPI] = handler.NewReadCacheHandler(
+			local.handlers[otev1.ClusterControllerDestAPI], c.ReadCacheTTL)
+	}
+	quotaGuard := handler.NewQuotaGuard(k8sClient)
+	local.handlers[otev1.ClusterControllerDestAPI] = handler.NewQuotaHandler(
+		local.handlers[otev1.ClusterControllerDestAPI], quotaGuard)
 	local.handlers[otev1.ClusterControllerDestHelm] = handler.NewHTTPProxyHandler(c.HelmTillerAddr)
+	local.handlers[otev1.ClusterControllerDestLog] = handler.NewLogHandler(k8sClient)
+	local.handlers[otev1.ClusterControllerDestProxy] = handler.NewProxyHandler(k8sClient)
+	local.handlers[otev1.ClusterControllerDestApply] = handler.NewQuotaHandler(handler.NewApplyHandler(k8sClient), quotaGuard)
+	local.handlers[otev1.ClusterControllerDestPrune] = handler.NewPruneHandler(k8sClient)
+	local.handlers[otev1.ClusterControllerDestQuota] = quotaGuard
+	if c.EnvelopeKey != "" {
+		km, err := envelope.NewKeyManager(c.KMSURL)
+		if err != nil {
+			klog.Errorf("failed to create key manager: %v", err)
+			return nil
+		}
+		for _, dest := range []string{otev1.ClusterControllerDestAPI, otev1.ClusterControllerDestApply} {
+			local.handlers[dest] = handler.NewEnvelopeHandler(local.handlers[dest], km, c.EnvelopeKey)
+		}
+	}
+
+	restConfig, err := k8sclient.GetRestConfig(c.KubeConfig)
+	if err != nil {
+		klog.Errorf("failed to get k8s rest config: %v", err)
+		return local
+	}
+	execHandler, err := handler.NewExecHandler(restConfig)
+	if err != nil {
+		klog.Errorf("failed to create exec handler: %v", err)
+		return local
+	}
+	local.handlers[otev1.ClusterControllerDestExec] = execHandler
 	return local
 }
 
 // NewlocalShimClientWithHandler returns a local shim client with given handlers.
 func NewlocalShimClientWithHandler(handlers ShimHandler) ShimServiceClient {
-	return &localShimClient{
+	local := &localShimClient{
 		handlers: handlers,
+		respChan: make(chan *clustermessage.ClusterMessage, shimRespChanLen),
 	}
+	local.setResponders()
+	return local
 }
 
-func (s *localShimClient) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+// setResponders sets responders of async handlers to send responses to respChan.
+func (s *localShimClient) setResponders() {
+	for _, h := range s.handlers {
+		if async, ok := h.(handler.AsyncHandler); ok {
+			async.SetResponder(func(resp *clustermessage.ClusterMessage) {
+				s.respChan <- resp
+			})
+		}
+	}
+}
+
+func (s *localShimClient) Do(ctx context.Context,
+	in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
 	switch in.Head.Command {
 	case clustermessage.CommandType_ControlReq:
-		return s.DoControlRequest(in)
+		return s.DoControlRequest(ctx, in)
 	case clustermessage.CommandType_ControlMultiReq:
-		return nil, s.DoControlMultiRequest(in)
+		return s.DoControlMultiRequest(ctx, in)
 	default:
-		return nil, fmt.Errorf("command %s is not supported by ShimClient", in.Head.Command.String())
+		return nil, handler.Errorf(handler.ErrUnsupportedCommand, "command %s is not supported by ShimClient", in.Head.Command.String())
 	}
 }
 
-func (s *localShimClient) DoControlRequest(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+func (s *localShimClient) DoControlRequest(ctx context.Context,
+	in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
 	head := proto.Clone(in.Head).(*clustermessage.MessageHead)
 	head.Command = clustermessage.CommandType_ControlResp
 
 	controllerTask := handler.GetControllerTaskFromClusterMessage(in)
 	if controllerTask == nil {
 		resp := handler.ControlTaskResponse(http.StatusNotFound, "")
-		return handler.Response(resp, head), fmt.Errorf("ControllerTask Not Found")
+		return handler.Response(resp, head), handler.ErrTaskNotFound
 	}
 
 	h, exist := s.handlers[controllerTask.Destination]
 	if exist {
-		resp, err := h.Do(in)
+		resp, err := handler.DoWithMetrics(ctx, controllerTask.Destination, h, in)
 		if resp != nil {
 			resp.Head.Command = clustermessage.CommandType_ControlResp
 		}
@@ -112,37 +178,53 @@ func (s *localShimClient) DoControlRequest(in *clustermessage.ClusterMessage) (*
 	}
 
 	resp := handler.ControlTaskResponse(http.StatusNotFound, "")
-	return handler.Response(resp, head), fmt.Errorf("no handler for %s", controllerTask.Destination)
+	return handler.Response(resp, head), handler.Errorf(handler.ErrNoHandler, "no handler for %s", controllerTask.Destination)
 }
 
-func (s *localShimClient) DoControlMultiRequest(in *clustermessage.ClusterMessage) error {
+func (s *localShimClient) DoControlMultiRequest(ctx context.Context,
+	in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
 	controlMultiTask := handler.GetControlMultiTaskFromClusterMessage(in)
 	if controlMultiTask == nil {
-		return fmt.Errorf("ControlMultiTask Not Found")
+		return nil, handler.ErrTaskNotFound
 	}
 
 	h, exist := s.handlers[controlMultiTask.Destination]
 	if exist {
-		_, err := h.Do(in)
-		return err
+		resp, err := handler.DoWithMetrics(ctx, controlMultiTask.Destination, h, in)
+		if resp != nil {
+			resp.Head.Command = clustermessage.CommandType_ControlMultiResp
+		}
+		return resp, err
 	}
 
-	return fmt.Errorf("no handler for %s", controlMultiTask.Destination)
+	head := proto.Clone(in.Head).(*clustermessage.MessageHead)
+	head.Command = clustermessage.CommandType_ControlMultiResp
+	resp := handler.ControlMultiTaskStatus(controlMultiTask, http.StatusNotFound, "")
+	return handler.Response(resp, head), handler.Errorf(handler.ErrNoHandler, "no handler for %s", controlMultiTask.Destination)
+}
+
+func (s *localShimClient) Destinations() []string {
+	return handlerNames(s.handlers)
 }
 
 func (s *localShimClient) ReturnChan() <-chan *clustermessage.ClusterMessage {
-	return nil
+	return s.respChan
 }
 
 // NewRemoteShimClient returns a remote shim client which is connecting to addr.
 func NewRemoteShimClient(shimClientName, addr string) ShimServiceClient {
+	host, dial := tunnel.UnixDial(addr)
 	u := url.URL{
 		Scheme: "ws",
-		Host:   addr,
+		Host:   host,
 		Path:   fmt.Sprintf("/%s/%s", shimServerPathForClusterController, shimClientName),
 	}
+	dialer := *websocket.DefaultDialer
+	if dial != nil {
+		dialer.NetDial = dial
+	}
 	header := http.Header{}
-	conn, resp, err := websocket.DefaultDialer.Dial(u.String(), header)
+	conn, resp, err := dialer.Dial(u.String(), header)
 	if err != nil {
 		if resp != nil {
 			klog.Errorf("failed to connect to remote shim, code=%v", resp.StatusCode)
@@ -151,25 +233,61 @@ func NewRemoteShimClient(shimClientName, addr string) ShimServiceClient {
 		return nil
 	}
 	ret := &remoteShimClient{
-		client:   tunnel.NewWSClient(shimClientName, conn),
-		respChan: make(chan *clustermessage.ClusterMessage, shimRespChanLen),
+		client:       tunnel.NewWSClient(shimClientName, conn),
+		respChan:     make(chan *clustermessage.ClusterMessage, shimRespChanLen),
+		destinations: getRemoteDestinations(addr),
 	}
 	go ret.handleReceiveMessage()
 	return ret
 }
 
-func (s *remoteShimClient) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+// Do sends in to the remote shim, which does it without ctx, unless ctx is already done.
+func (s *remoteShimClient) Do(ctx context.Context,
+	in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	if ctx.Err() != nil {
+		return handler.ContextResponse(ctx, in.Head)
+	}
 	// serialized req and send to server
 	reqMsg, err := proto.Marshal(in)
 	if err != nil {
-		msg := fmt.Sprintf("marshal shim request failed: %v", err)
-		klog.Error(msg)
-		return nil, fmt.Errorf(msg)
+		err = handler.Errorf(handler.ErrInternal, "marshal shim request failed: %v", err)
+		klog.Error(err)
+		return nil, err
 	}
 	go s.client.WriteMessage(reqMsg)
 	return nil, nil
 }
 
+func (s *remoteShimClient) Destinations() []string {
+	return s.destinations
+}
+
+// getRemoteDestinations gets destinations of the shim server at addr, nil if it fails,
+// e.g., the shim is of an old version.
+func getRemoteDestinations(addr string) []string {
+	host, dial := tunnel.UnixDial(addr)
+	client := &http.Client{Timeout: destinationsTimeout}
+	if dial != nil {
+		client.Transport = &http.Transport{Dial: dial}
+	}
+	resp, err := client.Get("http://" + host + shimServerPathForDestinations)
+	if err != nil {
+		klog.Warningf("get destinations of remote shim failed: %v", err)
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		klog.Warningf("get destinations of remote shim failed, code=%d", resp.StatusCode)
+		return nil
+	}
+	var destinations []string
+	if err := json.NewDecoder(resp.Body).Decode(&destinations); err != nil {
+		klog.Warningf("decode destinations of remote shim failed: %v", err)
+		return nil
+	}
+	return destinations
+}
+
 func (s *remoteShimClient) ReturnChan() <-chan *clustermessage.ClusterMessage {
 	return s.respChan
 }
@@ -193,3 +311,13 @@ func (s *remoteShimClient) handleReceiveMessage() {
 		s.respChan <- resp
 	}
 }
+
+// handlerNames returns names of handlers sorted.
+func handlerNames(handlers map[string]handler.Handler) []string {
+	names := make([]string, 0, len(handlers))
+	for name := range handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -17,7 +17,11 @@ limitations under the License.
 package clustershim
 
 import (
+	"context"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -30,6 +34,7 @@ import (
 	"github.com/baidu/ote-stack/pkg/clustermessage"
 	"github.com/baidu/ote-stack/pkg/clustershim/handler"
 	"github.com/baidu/ote-stack/pkg/config"
+	"github.com/baidu/ote-stack/pkg/errorkind"
 	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned/fake"
 )
 
@@ -98,7 +103,7 @@ func TestShimClientDoControlRequest(t *testing.T) {
 		},
 		Body: data1,
 	}
-	resp, err := localClient.DoControlRequest(&msg1)
+	resp, err := localClient.DoControlRequest(context.Background(), &msg1)
 	assert.Equal(t, clustermessage.CommandType_ControlResp, resp.Head.Command) // local shim client return not nil resp
 
 	// unsupportable handler
@@ -111,9 +116,9 @@ func TestShimClientDoControlRequest(t *testing.T) {
 		},
 		Body: data2,
 	}
-	resp, err = localClient.DoControlRequest(&msg2)
+	resp, err = localClient.DoControlRequest(context.Background(), &msg2)
 	assert.NotNil(t, resp) // local shim client return not nil resp
-	assert.NotNil(t, err)
+	assert.True(t, errorkind.Is(err, handler.ErrNoHandler))
 
 	//unsupportable command
 	data3 := getControllerTask(otev1.ClusterControllerDestAPI, method, uri, t)
@@ -125,7 +130,7 @@ func TestShimClientDoControlRequest(t *testing.T) {
 		},
 		Body: data3,
 	}
-	resp, err = localClient.DoControlRequest(&msg3)
+	resp, err = localClient.DoControlRequest(context.Background(), &msg3)
 	assert.Nil(t, resp) // local shim client return nil resp
 	assert.NotNil(t, err)
 }
@@ -152,7 +157,7 @@ func TestShimClientDoControlMultiRequest(t *testing.T) {
 		},
 		Body: data1,
 	}
-	err := localClient.DoControlMultiRequest(&msg1)
+	_, err := localClient.DoControlMultiRequest(context.Background(), &msg1)
 	assert.Nil(t, err)
 
 	//unsupportable handler
@@ -163,8 +168,8 @@ func TestShimClientDoControlMultiRequest(t *testing.T) {
 		},
 		Body: data2,
 	}
-	err = localClient.DoControlMultiRequest(&msg2)
-	assert.NotNil(t, err)
+	_, err = localClient.DoControlMultiRequest(context.Background(), &msg2)
+	assert.True(t, errorkind.Is(err, handler.ErrNoHandler))
 
 	//unsupportable command
 	msg3 := clustermessage.ClusterMessage{
@@ -173,7 +178,7 @@ func TestShimClientDoControlMultiRequest(t *testing.T) {
 		},
 		Body: data1,
 	}
-	err = localClient.DoControlMultiRequest(&msg3)
+	_, err = localClient.DoControlMultiRequest(context.Background(), &msg3)
 	assert.NotNil(t, err)
 }
 
@@ -189,7 +194,7 @@ func TestShimClientDo(t *testing.T) {
 		HelmTillerAddr: "",
 	}
 	localClient := NewlocalShimClient(c)
-	assert.Nil(t, localClient.ReturnChan())
+	assert.NotNil(t, localClient.ReturnChan())
 
 	msg := clustermessage.ClusterMessage{
 		Head: &clustermessage.MessageHead{
@@ -197,7 +202,7 @@ func TestShimClientDo(t *testing.T) {
 		},
 	}
 
-	resp, err := localClient.Do(&msg)
+	resp, err := localClient.Do(context.Background(), &msg)
 	assert.Nil(t, resp)
 	assert.NotNil(t, err)
 }
@@ -210,6 +215,8 @@ func TestRemoteShimClient(t *testing.T) {
 	require.NotNil(t, shimclient)
 	c, ok := shimclient.(*remoteShimClient)
 	require.True(t, ok)
+	// the test shim server has no handler.
+	assert.Equal(t, []string{}, c.Destinations())
 
 	// test receive msg
 	expect := clustermessage.ClusterMessage{
@@ -225,7 +232,7 @@ func TestRemoteShimClient(t *testing.T) {
 	assert.Equal(t, expect.Body, resp.Body)
 
 	// test do
-	resp, err := shimclient.Do(&expect)
+	resp, err := shimclient.Do(context.Background(), &expect)
 	assert.Nil(t, err)
 	assert.Nil(t, resp)
 	resp = <-shimclient.ReturnChan()
@@ -236,3 +243,38 @@ func TestRemoteShimClient(t *testing.T) {
 	c.client.Close()
 	time.Sleep(1 * time.Second)
 }
+
+func TestShimClientDestinations(t *testing.T) {
+	shimclient := NewlocalShimClientWithHandler(ShimHandler{
+		otev1.ClusterControllerDestExec: &fakeShimHandler{},
+		otev1.ClusterControllerDestAPI:  &fakeShimHandler{},
+	})
+	assert.Equal(t, []string{otev1.ClusterControllerDestAPI, otev1.ClusterControllerDestExec},
+		shimclient.Destinations())
+
+	assert.Nil(t, getRemoteDestinations("127.0.0.1:1"))
+}
+
+func TestRemoteShimClientOverUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shim")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	addr := "unix://" + filepath.Join(dir, "shim.sock")
+	s := NewShimServer()
+	go s.Serve(addr)
+	defer s.Close()
+	time.Sleep(500 * time.Millisecond)
+
+	shimclient := NewRemoteShimClient("testshim", addr)
+	require.NotNil(t, shimclient)
+	c := shimclient.(*remoteShimClient)
+	defer c.client.Close()
+	assert.Equal(t, []string{}, c.Destinations())
+
+	s.SendChan() <- clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{Command: clustermessage.CommandType_ControlResp},
+		Body: []byte("over unix socket"),
+	}
+	resp := <-shimclient.ReturnChan()
+	assert.Equal(t, []byte("over unix socket"), resp.Body)
+}
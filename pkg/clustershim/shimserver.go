@@ -19,8 +19,8 @@ package clustershim
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"net"
 	"net/http"
 	"sync"
 
@@ -64,36 +64,44 @@ func NewShimServer() *ShimServer {
 }
 
 // RegisterHandler registers shim handler.
+// Responses of async handlers are sent to cluster controller as reports.
 func (s *ShimServer) RegisterHandler(name string, h handler.Handler) {
 	s.handlers[name] = h
+	if async, ok := h.(handler.AsyncHandler); ok {
+		async.SetResponder(func(resp *clustermessage.ClusterMessage) {
+			s.sendChan <- *resp
+		})
+	}
 }
 
 // Do handles the requests and transmits to corresponding server.
-func (s *ShimServer) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+func (s *ShimServer) Do(ctx context.Context,
+	in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
 	switch in.Head.Command {
 	case clustermessage.CommandType_ControlReq:
-		return s.DoControlRequest(in)
+		return s.DoControlRequest(ctx, in)
 	case clustermessage.CommandType_ControlMultiReq:
-		return nil, s.DoControlMultiRequest(in)
+		return s.DoControlMultiRequest(ctx, in)
 	default:
-		return nil, fmt.Errorf("command %s is not supported by ShimServer", in.Head.Command.String())
+		return nil, handler.Errorf(handler.ErrUnsupportedCommand, "command %s is not supported by ShimServer", in.Head.Command.String())
 	}
 }
 
-func (s *ShimServer) DoControlRequest(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+func (s *ShimServer) DoControlRequest(ctx context.Context,
+	in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
 	head := proto.Clone(in.Head).(*clustermessage.MessageHead)
 	head.Command = clustermessage.CommandType_ControlResp
 
 	controllerTask := handler.GetControllerTaskFromClusterMessage(in)
 	if controllerTask == nil {
 		resp := handler.ControlTaskResponse(http.StatusNotFound, "")
-		return handler.Response(resp, head), fmt.Errorf("Controllertask Not Found")
+		return handler.Response(resp, head), handler.ErrTaskNotFound
 	}
 	klog.V(1).Infof("Received request for %v", controllerTask.Destination)
 
 	h, exist := s.handlers[controllerTask.Destination]
 	if exist {
-		resp, err := h.Do(in)
+		resp, err := handler.DoWithMetrics(ctx, controllerTask.Destination, h, in)
 
 		if err != nil {
 			klog.Errorf("handle request error: %v", err)
@@ -106,26 +114,33 @@ func (s *ShimServer) DoControlRequest(in *clustermessage.ClusterMessage) (*clust
 
 	klog.Infof("no handler for %v", controllerTask.Destination)
 	resp := handler.ControlTaskResponse(http.StatusNotFound, "")
-	return handler.Response(resp, head), fmt.Errorf("Not Found")
+	return handler.Response(resp, head), handler.Errorf(handler.ErrNoHandler, "no handler for %s", controllerTask.Destination)
 }
 
-func (s *ShimServer) DoControlMultiRequest(in *clustermessage.ClusterMessage) error {
+func (s *ShimServer) DoControlMultiRequest(ctx context.Context,
+	in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
 	controlMultiTask := handler.GetControlMultiTaskFromClusterMessage(in)
 	if controlMultiTask == nil {
-		return fmt.Errorf("ControlMultiTask Not Found")
+		return nil, handler.ErrTaskNotFound
 	}
 
 	h, exist := s.handlers[controlMultiTask.Destination]
 	if exist {
-		_, err := h.Do(in)
+		resp, err := handler.DoWithMetrics(ctx, controlMultiTask.Destination, h, in)
 		if err != nil {
 			klog.Errorf("handle request error: %v", err)
 		}
-		return err
+		if resp != nil {
+			resp.Head.Command = clustermessage.CommandType_ControlMultiResp
+		}
+		return resp, err
 	}
 
 	klog.Infof("no handler for %v", controlMultiTask.Destination)
-	return fmt.Errorf("no handler for %s", controlMultiTask.Destination)
+	head := proto.Clone(in.Head).(*clustermessage.MessageHead)
+	head.Command = clustermessage.CommandType_ControlMultiResp
+	resp := handler.ControlMultiTaskStatus(controlMultiTask, http.StatusNotFound, "")
+	return handler.Response(resp, head), handler.Errorf(handler.ErrNoHandler, "no handler for %s", controlMultiTask.Destination)
 }
 
 func (s *ShimServer) do(w http.ResponseWriter, r *http.Request) {
@@ -184,7 +199,8 @@ func (s *ShimServer) handleReadMessage(msg []byte) {
 		return
 	}
 
-	resp, err := s.Do(&in)
+	// deadlines of messages are not sent by cluster controller, only the trace id is set.
+	resp, err := s.Do(handler.WithTraceID(context.Background(), in.Head.GetMessageID()), &in)
 	if err != nil {
 		klog.Errorf("execute shim request failed: %v", err)
 	}
@@ -208,6 +224,8 @@ func (s *ShimServer) Serve(addr string) error {
 	router := mux.NewRouter()
 	router.HandleFunc(fmt.Sprintf("/%s/{%s}",
 		shimServerPathForClusterController, clusterNameParam), s.do)
+	router.HandleFunc("/metrics", handler.MetricsHandler)
+	router.HandleFunc(shimServerPathForDestinations, s.destinationsHandler)
 
 	s.server = &http.Server{
 		Addr:         addr,
@@ -218,7 +236,7 @@ func (s *ShimServer) Serve(addr string) error {
 	}
 
 	klog.Infof("listen on %s", addr)
-	ln, err := net.Listen("tcp", addr)
+	ln, err := tunnel.Listen(addr)
 	if err != nil {
 		return err
 	}
@@ -234,6 +252,13 @@ func (s *ShimServer) Serve(addr string) error {
 	return nil
 }
 
+// destinationsHandler responds the destinations of handlers registered in json.
+func (s *ShimServer) destinationsHandler(w http.ResponseWriter, r *http.Request) {
+	data, _ := json.Marshal(handlerNames(s.handlers))
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
 // Close gracefully stops shim server.
 func (s *ShimServer) Close() {
 	ctx, cancel := context.WithTimeout(context.Background(), tunnel.StopTimeout)
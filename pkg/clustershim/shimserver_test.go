@@ -17,6 +17,7 @@ limitations under the License.
 package clustershim
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -81,7 +82,7 @@ func TestDo(t *testing.T) {
 		Body: data1,
 	}
 
-	resp, err := server.Do(msg)
+	resp, err := server.Do(context.Background(), msg)
 	assert.Nil(t, resp)
 	assert.NotNil(t, err)
 }
@@ -110,7 +111,7 @@ func TestDoControlRequest(t *testing.T) {
 	}
 
 	for _, sc := range successcase {
-		resp, err := server.DoControlRequest(sc.Request)
+		resp, err := server.DoControlRequest(context.Background(), sc.Request)
 		assert.Nil(t, err)
 
 		task := &clustermessage.ControllerTaskResponse{}
@@ -148,7 +149,7 @@ func TestDoControlRequest(t *testing.T) {
 	}
 
 	for _, ec := range errorcase {
-		resp, err := server.DoControlRequest(ec.Request)
+		resp, err := server.DoControlRequest(context.Background(), ec.Request)
 		assert.NotNil(t, err)
 		if ec.Name == "no command" {
 			assert.Nil(t, resp)
@@ -285,7 +286,7 @@ func TestDoControlMultiRequest(t *testing.T) {
 		},
 		Body: data1,
 	}
-	err := server.DoControlMultiRequest(&msg1)
+	_, err := server.DoControlMultiRequest(context.Background(), &msg1)
 	assert.Nil(t, err)
 
 	//unsupportable handler
@@ -296,7 +297,7 @@ func TestDoControlMultiRequest(t *testing.T) {
 		},
 		Body: data2,
 	}
-	err = server.DoControlMultiRequest(&msg2)
+	_, err = server.DoControlMultiRequest(context.Background(), &msg2)
 	assert.NotNil(t, err)
 
 	//unsupportable command
@@ -306,6 +307,6 @@ func TestDoControlMultiRequest(t *testing.T) {
 		},
 		Body: data1,
 	}
-	err = server.DoControlMultiRequest(&msg3)
+	_, err = server.DoControlMultiRequest(context.Background(), &msg3)
 	assert.NotNil(t, err)
 }
@@ -0,0 +1,99 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// UnixAddressPrefix is the prefix of addresses of unix domain sockets, e.g., unix:///var/run/ote/shim.sock.
+const UnixAddressPrefix = "unix://"
+
+// UnixSocketPath returns the path of the unix domain socket of addr, false if addr is not of one.
+func UnixSocketPath(addr string) (string, bool) {
+	if !strings.HasPrefix(addr, UnixAddressPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(addr, UnixAddressPrefix), true
+}
+
+/*
+ValidateAddress returns error if addr is not an address of host and port, e.g.,
+192.168.0.2:8287, [fd00::2]:8287, ote.example.com:8287, or :8287 of all addresses,
+nor of a unix domain socket in absolute path, e.g., unix:///var/run/ote/shim.sock.
+IPv6 literals must be in brackets, since the port cannot be told from the address otherwise.
+*/
+func ValidateAddress(addr string) error {
+	if path, ok := UnixSocketPath(addr); ok {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("path of unix socket address %s must be absolute", addr)
+		}
+		return nil
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		if strings.Count(addr, ":") > 1 && !strings.HasPrefix(addr, "[") {
+			return fmt.Errorf("address %s is invalid, ipv6 address must be in brackets, e.g., [%s]:port",
+				addr, addr)
+		}
+		return fmt.Errorf("address %s is invalid: %v", addr, err)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("port of address %s is invalid", addr)
+	}
+	if strings.Contains(host, ":") && net.ParseIP(host) == nil {
+		return fmt.Errorf("host of address %s is not a valid ipv6 address", addr)
+	}
+	return nil
+}
+
+// ValidateAddresses returns error if any of addrs is invalid, empty ones are skipped.
+func ValidateAddresses(addrs ...string) error {
+	for _, addr := range addrs {
+		if addr == "" {
+			continue
+		}
+		if err := ValidateAddress(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+AdvertiseAddr returns the address to reach listen of the peer connected from remote.
+The host of listen is replaced by the host of remote if it is empty or unspecified,
+e.g., :8287, 0.0.0.0:8287 or [::]:8287, which listens on all addresses of the peer.
+listen is returned as it is if either address is invalid.
+*/
+func AdvertiseAddr(listen, remote string) string {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return listen
+	}
+	if ip := net.ParseIP(host); host != "" && (ip == nil || !ip.IsUnspecified()) {
+		return listen
+	}
+	remoteHost, _, err := net.SplitHostPort(remote)
+	if err != nil {
+		return listen
+	}
+	return net.JoinHostPort(remoteHost, port)
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateAddress(t *testing.T) {
+	for _, addr := range []string{
+		"192.168.0.2:8287", ":8287", "[fd00::2]:8287", "[::]:8287", "ote.example.com:8287",
+		"unix:///var/run/ote/shim.sock",
+	} {
+		assert.Nil(t, ValidateAddress(addr), addr)
+	}
+	for _, addr := range []string{
+		"192.168.0.2", "fd00::2:8287", "[fd00::2]", "[fd00::zz]:8287", "127.0.0.1:port", "127.0.0.1:70000",
+		"unix://", "unix://shim.sock",
+	} {
+		assert.NotNil(t, ValidateAddress(addr), addr)
+	}
+	assert.Contains(t, ValidateAddress("fd00::2:8287").Error(), "brackets")
+
+	assert.Nil(t, ValidateAddresses("", "[::1]:8262"))
+	assert.NotNil(t, ValidateAddresses("127.0.0.1:8287", "::1"))
+}
+
+func TestAdvertiseAddr(t *testing.T) {
+	casetest := []struct {
+		listen string
+		remote string
+		expect string
+	}{
+		{":8287", "192.168.0.2:34567", "192.168.0.2:8287"},
+		{"0.0.0.0:8287", "192.168.0.2:34567", "192.168.0.2:8287"},
+		{"[::]:8287", "[fd00::2]:34567", "[fd00::2]:8287"},
+		{":8287", "[fd00::2]:34567", "[fd00::2]:8287"},
+		{"192.168.0.3:8287", "192.168.0.2:34567", "192.168.0.3:8287"},
+		{"[fd00::3]:8287", "[fd00::2]:34567", "[fd00::3]:8287"},
+		{"edge.example.com:8287", "192.168.0.2:34567", "edge.example.com:8287"},
+		{"fake", "192.168.0.2:34567", "fake"},
+		{":8287", "", ":8287"},
+	}
+	for _, ct := range casetest {
+		assert.Equal(t, ct.expect, AdvertiseAddr(ct.listen, ct.remote), ct.listen)
+	}
+}
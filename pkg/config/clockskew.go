@@ -0,0 +1,58 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// parentClockSkew is the milliseconds the clock of this cluster is ahead of its parent.
+var parentClockSkew int64
+
+// UnixMilli returns t as unix time in milliseconds.
+func UnixMilli(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// ClockSkew returns the milliseconds a remote clock is ahead of the local one,
+// with remote time in header ClusterConnectHeaderTime, and the local time the request
+// is sent and the response is received, the remote time is taken as the middle of them.
+// The skew is 0 if the remote does not set the header.
+func ClockSkew(remote string, sent, received time.Time) (int64, error) {
+	if remote == "" {
+		return 0, nil
+	}
+	ms, err := strconv.ParseInt(remote, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("time %s is invalid", remote)
+	}
+	return ms - (UnixMilli(sent)+UnixMilli(received))/2, nil
+}
+
+// SetParentClockSkew sets the milliseconds the clock of this cluster is ahead of its parent,
+// which is measured when connecting to the parent.
+func SetParentClockSkew(skew int64) {
+	atomic.StoreInt64(&parentClockSkew, skew)
+}
+
+// ParentClockSkew returns the milliseconds the clock of this cluster is ahead of its parent.
+func ParentClockSkew() int64 {
+	return atomic.LoadInt64(&parentClockSkew)
+}
@@ -21,12 +21,26 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"time"
 
+	"k8s.io/client-go/kubernetes"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
 	"github.com/baidu/ote-stack/pkg/clustermessage"
 	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned"
 )
 
 const (
+	// Version is the version of ote-stack components.
+	Version = "1.0"
+
+	// ProtocolVersion is the version of messages between clusters,
+	// which is increased when messages are changed incompatibly.
+	ProtocolVersion = 1
+	// MinProtocolVersion is the lowest protocol version of children accepted by a parent.
+	MinProtocolVersion = 1
+
 	// RootClusterName defines the cluster name of root cluster.
 	RootClusterName = "Root"
 
@@ -36,6 +50,41 @@ const (
 	ClusterConnectHeaderListenAddr = "listen-addr"
 	// ClusterConnectHeaderUserDefineName is the user-define name of the child
 	ClusterConnectHeaderUserDefineName = "name"
+	// ClusterConnectHeaderVersion is the clustercontroller version of the child.
+	ClusterConnectHeaderVersion = "version"
+	// ClusterConnectHeaderProtocol is the protocol version of the child in request,
+	// and the protocol version negotiated by parent in response.
+	ClusterConnectHeaderProtocol = "protocol"
+	// ClusterConnectHeaderTime is the unix time in milliseconds of the child in request,
+	// and of the parent in response, to measure clock skew between them.
+	ClusterConnectHeaderTime = "time"
+	// ClusterConnectHeaderHLC is the hybrid logical clock of the child in request,
+	// and of the parent in response, so that both clocks are ahead of each other.
+	ClusterConnectHeaderHLC = "hlc"
+	// ClusterConnectHeaderEncodings is the report encodings accepted by the child or
+	// controller manager in preference order, separated by comma.
+	ClusterConnectHeaderEncodings = "encodings"
+	// ClusterConnectHeaderEncoding is the report encoding negotiated by parent in response.
+	ClusterConnectHeaderEncoding = "encoding"
+	// ClusterConnectHeaderCompressions is the tunnel compressions accepted by the child
+	// in preference order, separated by comma.
+	ClusterConnectHeaderCompressions = "compressions"
+	// ClusterConnectHeaderCompression is the tunnel compression negotiated by parent in response.
+	ClusterConnectHeaderCompression = "compression"
+	// ClusterConnectHeaderChannelWindow is the window of tunnel channels in bytes offered by the child,
+	// and the one negotiated by parent in response, channels are not used if either has none.
+	ClusterConnectHeaderChannelWindow = "channel-window"
+	// ClusterConnectHeaderChecksum is the checksum of tunnel frames offered by the child,
+	// and the one accepted by parent in response, frames are not checksummed if it is not accepted.
+	ClusterConnectHeaderChecksum = "checksum"
+	// ClusterConnectHeaderCapabilities is the capabilities of the child in json.
+	ClusterConnectHeaderCapabilities = "capabilities"
+	// ClusterConnectHeaderRedirected is set by the child reconnecting to the parent it is redirected to
+	// by a Redirect message, which is accepted by the parent without assigning to another.
+	ClusterConnectHeaderRedirected = "redirected"
+	// ClusterConnectHeaderPreviousName is the user-define name the child registed by before renamed,
+	// which is tombstoned by parents.
+	ClusterConnectHeaderPreviousName = "previous-name"
 
 	// K8sInformerSyncDuration defines k8s informer sync seconds.
 	K8sInformerSyncDuration = 10
@@ -48,17 +97,70 @@ var (
 
 // ClusterControllerConfig contains config needed by cluster controller.
 type ClusterControllerConfig struct {
-	TunnelListenAddr      string
-	LeaderListenAddr      string
-	ParentCluster         string
-	ClusterName           string
-	ClusterUserDefineName string
-	KubeConfig            string
-	HelmTillerAddr        string
-	RemoteShimAddr        string
-	K8sClient             oteclient.Interface
-	EdgeToClusterChan     chan clustermessage.ClusterMessage
-	ClusterToEdgeChan     chan clustermessage.ClusterMessage
+	TunnelListenAddr string
+	LeaderListenAddr string
+	// ParentCluster are the cloud tunnels of parents, the first reachable one is connected,
+	// and the next one is failed over to once disconnected for ParentFailoverThreshold.
+	ParentCluster []string
+	// ParentFailoverThreshold is the time to retry the parent disconnected before failing over
+	// to the next one of ParentCluster, 0 fails over once a retry failed.
+	ParentFailoverThreshold time.Duration
+	ClusterName             string
+	ClusterUserDefineName   string
+	// PreviousClusterName is the name the cluster registed by before renamed to ClusterUserDefineName,
+	// with which the cluster re-registers to the parent, no rename if empty.
+	PreviousClusterName string
+	KubeConfig          string
+	HelmTillerAddr      string
+	RemoteShimAddr      string
+	K8sClient           oteclient.Interface
+	EdgeToClusterChan   chan clustermessage.ClusterMessage
+	ClusterToEdgeChan   chan clustermessage.ClusterMessage
+	// ClockSkewThreshold is the clock skew of clusters from root to set condition ClockSkewed,
+	// which is only used by root, 0 means no condition.
+	ClockSkewThreshold time.Duration
+	// TaskTimeout is the time to wait for responses of a task from clusters, after which
+	// clusters not responded are marked TimedOut. It is only used by root, 0 means no timeout.
+	TaskTimeout time.Duration
+	// ReadCacheTTL is the time to cache responses of GET tasks to k8s apiserver
+	// by the local shim, 0 means no cache.
+	ReadCacheTTL time.Duration
+	// ShimWorkers is the max number of tasks from the parent done by the shim concurrently,
+	// tasks are done one by one if 1 or less.
+	// ShimTimeout is the time a task from the parent is canceled after, and responded with
+	// status code 504 by edgehandler if the shim does not respond in it. 0 means no timeout.
+	ShimWorkers int
+	ShimTimeout time.Duration
+	// ChildRateLimit is the bytes per second received from each child, 0 means no limit.
+	// ChildRateBurst is the max bytes received from a child at once, ChildRateLimit if 0.
+	ChildRateLimit int64
+	ChildRateBurst int64
+	// TunnelProxy is the socks5 or http proxy to connect to parent by,
+	// parent is connected directly or by http proxies of environment if empty.
+	TunnelProxy string
+	// KMSURL is the key manager to open sealed bodies of tasks by the local shim with EnvelopeKey,
+	// sealed bodies are not opened if EnvelopeKey is empty.
+	KMSURL      string
+	EnvelopeKey string
+	// TunnelFrontends are the advertise addresses of all root frontends sharing childs,
+	// each child is assigned to one of them by consistent hashing.
+	TunnelFrontends []string
+	// TunnelAdvertiseAddr is the address of this root frontend in TunnelFrontends.
+	TunnelAdvertiseAddr string
+	// TunnelProtocol is the protocol to connect to parent by, websocket if empty.
+	// The cloud tunnel accepts childs of all protocols.
+	TunnelProtocol string
+	// HeartbeatInterval is the interval to ping the parent, the connection is closed if
+	// nothing is received from the parent in HeartbeatTimeout after that. 0 means no ping.
+	HeartbeatInterval time.Duration
+	HeartbeatTimeout  time.Duration
+	// ShadowParent is the cloud tunnel of a shadow parent, which receives the same reports
+	// as the parent, and whose tasks are ignored. No shadow parent if empty.
+	ShadowParent string
+	// KubeClient is the client of k8s apiserver of root to look up workloads mirrored to center,
+	// which tasks updating them are charged only the increase against ClusterQuotas by.
+	// Updates are left to shims of clusters if nil.
+	KubeClient kubernetes.Interface
 }
 
 // ClusterRegistry defines a data structure to use when a cluster regists.
@@ -68,6 +170,19 @@ type ClusterRegistry struct {
 	Listen         string
 	Time           int64
 	ParentName     string
+	// Version is the clustercontroller version of the cluster.
+	Version string `json:",omitempty"`
+	// Protocol is the protocol version negotiated with its parent.
+	Protocol int `json:",omitempty"`
+	// ClockSkew is the milliseconds the clock of the cluster is ahead of its parent,
+	// and ahead of root after transmitted to root.
+	ClockSkew int64 `json:",omitempty"`
+	// Capabilities are what the cluster controller of the cluster supports.
+	Capabilities *otev1.ClusterCapabilities `json:",omitempty"`
+	// HLC is the hybrid logical clock of the parent when the cluster regists or unregists.
+	HLC *otev1.HybridTime `json:",omitempty"`
+	// PreviousName is the name the cluster registed by before renamed, which is set by regist only.
+	PreviousName string `json:",omitempty"`
 }
 
 // Serialize is for the ClusterRegistry serialization method.
@@ -109,3 +224,23 @@ func (cr *ClusterRegistry) WrapperToClusterMessage(
 func IsRoot(clusterName string) bool {
 	return RootClusterName == clusterName
 }
+
+// NegotiateProtocol returns the protocol version used between a parent and a child
+// with protocol version in connect header, which is the lower one of them.
+// Children without the header are taken as MinProtocolVersion.
+func NegotiateProtocol(child string) (int, error) {
+	if child == "" {
+		return MinProtocolVersion, nil
+	}
+	version, err := strconv.Atoi(child)
+	if err != nil {
+		return 0, fmt.Errorf("protocol version %s is invalid", child)
+	}
+	if version < MinProtocolVersion {
+		return 0, fmt.Errorf("protocol version %d is lower than %d", version, MinProtocolVersion)
+	}
+	if version > ProtocolVersion {
+		return ProtocolVersion, nil
+	}
+	return version, nil
+}
@@ -18,6 +18,7 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -26,3 +27,45 @@ func TestIsRoot(t *testing.T) {
 	assert.True(t, IsRoot("Root"))
 	assert.False(t, IsRoot("a"))
 }
+
+func TestNegotiateProtocol(t *testing.T) {
+	version, err := NegotiateProtocol("")
+	assert.Nil(t, err)
+	assert.Equal(t, MinProtocolVersion, version)
+
+	version, err = NegotiateProtocol("1")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, version)
+
+	version, err = NegotiateProtocol("100")
+	assert.Nil(t, err)
+	assert.Equal(t, ProtocolVersion, version)
+
+	_, err = NegotiateProtocol("0")
+	assert.NotNil(t, err)
+	_, err = NegotiateProtocol("v1")
+	assert.NotNil(t, err)
+}
+
+func TestClockSkew(t *testing.T) {
+	now := time.Unix(1000, 0)
+	skew, err := ClockSkew("", now, now)
+	assert.Nil(t, err)
+	assert.Zero(t, skew)
+
+	skew, err = ClockSkew("1005000", now, now)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5000), skew)
+
+	// remote time is taken as the middle of request and response.
+	skew, err = ClockSkew("999000", now, now.Add(2*time.Second))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(-2000), skew)
+
+	_, err = ClockSkew("now", now, now)
+	assert.NotNil(t, err)
+
+	SetParentClockSkew(-300)
+	assert.Equal(t, int64(-300), ParentClockSkew())
+	SetParentClockSkew(0)
+}
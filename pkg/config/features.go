@@ -0,0 +1,97 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Feature gates of cluster controller.
+const (
+	// FeatureCapabilityReport sends capabilities of the cluster controller to its parent on connect.
+	FeatureCapabilityReport = "CapabilityReport"
+	// FeatureCapabilityGatedDispatch skips clusters of which the shim does not support the destination of tasks.
+	FeatureCapabilityGatedDispatch = "CapabilityGatedDispatch"
+)
+
+var (
+	featureGatesLock sync.RWMutex
+	// featureGates are the known feature gates and whether they are enabled.
+	featureGates = map[string]bool{
+		FeatureCapabilityReport:        true,
+		FeatureCapabilityGatedDispatch: true,
+	}
+)
+
+// SetFeatureGates enables or disables known feature gates by gates, e.g., {"CapabilityReport": "false"}.
+func SetFeatureGates(gates map[string]string) error {
+	parsed := make(map[string]bool, len(gates))
+	featureGatesLock.RLock()
+	for name, value := range gates {
+		if _, ok := featureGates[name]; !ok {
+			featureGatesLock.RUnlock()
+			return fmt.Errorf("unknown feature gate %s", name)
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			featureGatesLock.RUnlock()
+			return fmt.Errorf("invalid value %s of feature gate %s", value, name)
+		}
+		parsed[name] = enabled
+	}
+	featureGatesLock.RUnlock()
+
+	featureGatesLock.Lock()
+	defer featureGatesLock.Unlock()
+	for name, enabled := range parsed {
+		featureGates[name] = enabled
+	}
+	return nil
+}
+
+// FeatureEnabled returns true if feature gate name is enabled.
+func FeatureEnabled(name string) bool {
+	featureGatesLock.RLock()
+	defer featureGatesLock.RUnlock()
+	return featureGates[name]
+}
+
+// FeatureGates returns a copy of the known feature gates.
+func FeatureGates() map[string]bool {
+	featureGatesLock.RLock()
+	defer featureGatesLock.RUnlock()
+	gates := make(map[string]bool, len(featureGates))
+	for name, enabled := range featureGates {
+		gates[name] = enabled
+	}
+	return gates
+}
+
+// FeatureGateNames returns names of the known feature gates, sorted.
+func FeatureGateNames() []string {
+	featureGatesLock.RLock()
+	defer featureGatesLock.RUnlock()
+	names := make([]string, 0, len(featureGates))
+	for name := range featureGates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
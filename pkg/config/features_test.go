@@ -0,0 +1,41 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureGates(t *testing.T) {
+	defer SetFeatureGates(map[string]string{FeatureCapabilityReport: "true"})
+	assert.True(t, FeatureEnabled(FeatureCapabilityReport))
+	assert.False(t, FeatureEnabled("Unknown"))
+	assert.Contains(t, FeatureGateNames(), FeatureCapabilityReport)
+
+	assert.NotNil(t, SetFeatureGates(map[string]string{"Unknown": "true"}))
+	assert.NotNil(t, SetFeatureGates(map[string]string{FeatureCapabilityReport: "maybe"}))
+	assert.True(t, FeatureEnabled(FeatureCapabilityReport))
+
+	assert.Nil(t, SetFeatureGates(map[string]string{FeatureCapabilityReport: "false"}))
+	assert.False(t, FeatureEnabled(FeatureCapabilityReport))
+	gates := FeatureGates()
+	assert.Equal(t, false, gates[FeatureCapabilityReport])
+	gates[FeatureCapabilityReport] = true
+	assert.False(t, FeatureEnabled(FeatureCapabilityReport))
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"sync"
+	"time"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+)
+
+// hybridClock is the hybrid logical clock of this cluster.
+var hybridClock = struct {
+	sync.Mutex
+	last otev1.HybridTime
+	now  func() time.Time
+}{now: time.Now}
+
+// HybridNow returns the hybrid time of an event happening in this cluster,
+// which is after all hybrid times returned before.
+func HybridNow() otev1.HybridTime {
+	hybridClock.Lock()
+	defer hybridClock.Unlock()
+	wall := UnixMilli(hybridClock.now())
+	if wall > hybridClock.last.WallTime {
+		hybridClock.last = otev1.HybridTime{WallTime: wall}
+	} else {
+		hybridClock.last.Logical++
+	}
+	return hybridClock.last
+}
+
+// HybridUpdate merges the hybrid time remote received from another cluster to the clock,
+// and returns the hybrid time of receiving it, which is after both remote and
+// all hybrid times returned before.
+func HybridUpdate(remote otev1.HybridTime) otev1.HybridTime {
+	hybridClock.Lock()
+	defer hybridClock.Unlock()
+	last := hybridClock.last
+	wall := UnixMilli(hybridClock.now())
+	switch {
+	case wall > last.WallTime && wall > remote.WallTime:
+		hybridClock.last = otev1.HybridTime{WallTime: wall}
+	case last.WallTime == remote.WallTime:
+		hybridClock.last.Logical = maxLogical(last.Logical, remote.Logical) + 1
+	case last.WallTime > remote.WallTime:
+		hybridClock.last.Logical++
+	default:
+		hybridClock.last = otev1.HybridTime{WallTime: remote.WallTime, Logical: remote.Logical + 1}
+	}
+	return hybridClock.last
+}
+
+// HybridUpdateHeader merges the hybrid time in header value of ClusterConnectHeaderHLC,
+// and returns the hybrid time of receiving it. The header is ignored if it is empty or invalid.
+func HybridUpdateHeader(value string) otev1.HybridTime {
+	if value == "" {
+		return HybridNow()
+	}
+	remote, err := otev1.ParseHybridTime(value)
+	if err != nil {
+		return HybridNow()
+	}
+	return HybridUpdate(remote)
+}
+
+func maxLogical(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
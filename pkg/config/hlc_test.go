@@ -0,0 +1,74 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+)
+
+func setHybridClock(wall int64, now func() time.Time) {
+	hybridClock.Lock()
+	defer hybridClock.Unlock()
+	hybridClock.last = otev1.HybridTime{WallTime: wall}
+	hybridClock.now = now
+}
+
+func TestHybridClock(t *testing.T) {
+	defer setHybridClock(0, time.Now)
+	wall := time.Unix(1000, 0)
+	setHybridClock(0, func() time.Time { return wall })
+
+	t1 := HybridNow()
+	assert.Equal(t, otev1.HybridTime{WallTime: 1000000}, t1)
+	// the wall clock does not move, or moves backwards.
+	t2 := HybridNow()
+	assert.Equal(t, otev1.HybridTime{WallTime: 1000000, Logical: 1}, t2)
+	wall = time.Unix(900, 0)
+	t3 := HybridNow()
+	assert.True(t, t2.Before(t3))
+
+	// remote ahead.
+	t4 := HybridUpdate(otev1.HybridTime{WallTime: 2000000, Logical: 5})
+	assert.Equal(t, otev1.HybridTime{WallTime: 2000000, Logical: 6}, t4)
+	// remote of the same wall time.
+	t5 := HybridUpdate(otev1.HybridTime{WallTime: 2000000, Logical: 10})
+	assert.Equal(t, otev1.HybridTime{WallTime: 2000000, Logical: 11}, t5)
+	// remote behind.
+	t6 := HybridUpdate(otev1.HybridTime{WallTime: 1000})
+	assert.Equal(t, otev1.HybridTime{WallTime: 2000000, Logical: 12}, t6)
+	// the wall clock catches up.
+	wall = time.Unix(3000, 0)
+	assert.Equal(t, otev1.HybridTime{WallTime: 3000000}, HybridUpdate(t6))
+}
+
+func TestHybridUpdateHeader(t *testing.T) {
+	defer setHybridClock(0, time.Now)
+	setHybridClock(0, func() time.Time { return time.Unix(1, 0) })
+
+	assert.Equal(t, otev1.HybridTime{WallTime: 1000}, HybridUpdateHeader(""))
+	assert.Equal(t, otev1.HybridTime{WallTime: 1000, Logical: 1}, HybridUpdateHeader("invalid"))
+	assert.Equal(t, otev1.HybridTime{WallTime: 5000, Logical: 3}, HybridUpdateHeader("5000.2"))
+
+	ht, err := otev1.ParseHybridTime(otev1.HybridTime{WallTime: 5000, Logical: 3}.String())
+	assert.Nil(t, err)
+	assert.Equal(t, otev1.HybridTime{WallTime: 5000, Logical: 3}, ht)
+}
@@ -0,0 +1,359 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterproxy
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clusterselector"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+	"github.com/baidu/ote-stack/pkg/reporter"
+)
+
+const (
+	// AggregatePathPrefix is the path prefix of lists aggregated across clusters, followed by the api path.
+	AggregatePathPrefix = "/aggregate/"
+	// AggregateSourceEdge lists from apiservers of edge clusters through the tunnel.
+	AggregateSourceEdge = "edge"
+	// AggregateSourceMirror lists resources mirrored to the apiserver of root by controller manager.
+	AggregateSourceMirror = "mirror"
+	// ClusterAnnotation is the annotation of items aggregated, recording the cluster they are listed from.
+	ClusterAnnotation = reporter.ClusterLabel
+
+	// clusterSelectorParam is the query of clusters to list from, in rules of cluster selector.
+	clusterSelectorParam = "clusterSelector"
+	// sourceParam is the query of source to list from, overriding Config.AggregateSource.
+	sourceParam = "source"
+)
+
+// aggregateList is a list aggregated across clusters.
+type aggregateList struct {
+	Kind       string `json:"kind"`
+	APIVersion string `json:"apiVersion"`
+	Metadata   struct {
+		Continue string `json:"continue,omitempty"`
+	} `json:"metadata"`
+	Items []map[string]interface{} `json:"items"`
+	// FailedClusters are errors of clusters failed to list by cluster name.
+	FailedClusters map[string]string `json:"failedClusters,omitempty"`
+}
+
+// aggregateContinue is the position of a page of list across clusters.
+type aggregateContinue struct {
+	// Cluster is the cluster the next page starts from.
+	Cluster string `json:"cluster"`
+	// Continue is the continue token of the list of Cluster.
+	Continue string `json:"continue,omitempty"`
+}
+
+func encodeContinue(c *aggregateContinue) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeContinue(token string) (*aggregateContinue, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid continue token: %v", err)
+	}
+	c := &aggregateContinue{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("invalid continue token: %v", err)
+	}
+	return c, nil
+}
+
+// annotateCluster sets the annotation of cluster name to items.
+func annotateCluster(items []map[string]interface{}, name string) {
+	for _, item := range items {
+		metadata, ok := item["metadata"].(map[string]interface{})
+		if !ok {
+			metadata = make(map[string]interface{})
+			item["metadata"] = metadata
+		}
+		annotations, ok := metadata["annotations"].(map[string]interface{})
+		if !ok {
+			annotations = make(map[string]interface{})
+			metadata["annotations"] = annotations
+		}
+		annotations[ClusterAnnotation] = name
+	}
+}
+
+// selectedClusters returns names of clusters matched by selector in order, all clusters if selector is empty.
+func (p *ClusterProxy) selectedClusters(selector string, onlineOnly bool) ([]string, error) {
+	clusters, err := p.clusterLister.Clusters(otev1.ClusterNamespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	var s clusterselector.Selector
+	if selector != "" {
+		s = clusterselector.NewSelector(selector)
+	}
+	names := []string{}
+	for _, c := range clusters {
+		if onlineOnly && c.Status.Status != otev1.ClusterStatusOnline {
+			continue
+		}
+		if s != nil && !s.Has(c.Name) {
+			continue
+		}
+		names = append(names, c.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+/*
+serveAggregate lists a resource type across clusters selected by query clusterSelector, and
+responds the items merged in a list, each annotated with the cluster it is listed from.
+Items are listed from edge clusters through the tunnel, or from resources mirrored to root.
+*/
+func (p *ClusterProxy) serveAggregate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeStatus(w, http.StatusMethodNotAllowed, "only list is supported across clusters")
+		return
+	}
+	apiPath := "/" + strings.TrimPrefix(r.URL.Path, AggregatePathPrefix)
+	if isWatch(r, apiPath) {
+		writeStatus(w, http.StatusNotImplemented, "watch is not supported across clusters")
+		return
+	}
+	query := r.URL.Query()
+	selector := query.Get(clusterSelectorParam)
+	source := query.Get(sourceParam)
+	if source == "" {
+		source = p.conf.AggregateSource
+	}
+	query.Del(clusterSelectorParam)
+	query.Del(sourceParam)
+
+	var list *aggregateList
+	var code int
+	var err error
+	switch source {
+	case AggregateSourceMirror:
+		list, code, err = p.listMirror(apiPath, query, selector)
+	case AggregateSourceEdge, "":
+		list, code, err = p.listEdge(r, apiPath, query, selector)
+	default:
+		code, err = http.StatusBadRequest, fmt.Errorf("unknown source %s", source)
+	}
+	if err != nil {
+		writeStatus(w, code, err.Error())
+		return
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		writeStatus(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	klog.V(3).Infof("aggregate %s from %s of clusters %q: %d items, %d failed",
+		apiPath, source, selector, len(list.Items), len(list.FailedClusters))
+	w.Header().Set("Content-Type", handler.ProxyContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// listEdge lists apiPath from edge clusters. Clusters are listed at the same time without limit,
+// otherwise one after another until the limit of the page is reached.
+func (p *ClusterProxy) listEdge(r *http.Request, apiPath string, query url.Values,
+	selector string) (*aggregateList, int, error) {
+	names, err := p.selectedClusters(selector, true)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	list := &aggregateList{Kind: "List", APIVersion: "v1", Items: []map[string]interface{}{}}
+	failed := make(map[string]string)
+
+	limit, _ := strconv.ParseInt(query.Get("limit"), 10, 64)
+	if limit <= 0 {
+		lists := make([]*aggregateList, len(names))
+		errs := make([]error, len(names))
+		wg := sync.WaitGroup{}
+		for i := range names {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				lists[i], errs[i] = p.listCluster(r, names[i], apiPath, query)
+			}(i)
+		}
+		wg.Wait()
+		for i, name := range names {
+			if errs[i] != nil {
+				failed[name] = errs[i].Error()
+				continue
+			}
+			list.merge(lists[i])
+		}
+	} else {
+		pos := &aggregateContinue{}
+		if token := query.Get("continue"); token != "" {
+			if pos, err = decodeContinue(token); err != nil {
+				return nil, http.StatusBadRequest, err
+			}
+		}
+		start := sort.SearchStrings(names, pos.Cluster)
+		for i := start; i < len(names); i++ {
+			remaining := limit - int64(len(list.Items))
+			if remaining <= 0 {
+				list.Metadata.Continue = encodeContinue(&aggregateContinue{Cluster: names[i]})
+				break
+			}
+			q := url.Values{}
+			for k, v := range query {
+				q[k] = v
+			}
+			q.Set("limit", strconv.FormatInt(remaining, 10))
+			q.Del("continue")
+			if names[i] == pos.Cluster && pos.Continue != "" {
+				q.Set("continue", pos.Continue)
+			}
+			l, err := p.listCluster(r, names[i], apiPath, q)
+			if err != nil {
+				failed[names[i]] = err.Error()
+				continue
+			}
+			list.merge(l)
+			if l.Metadata.Continue != "" {
+				list.Metadata.Continue = encodeContinue(&aggregateContinue{
+					Cluster:  names[i],
+					Continue: l.Metadata.Continue,
+				})
+				break
+			}
+		}
+	}
+	if len(failed) > 0 {
+		list.FailedClusters = failed
+	}
+	return list, http.StatusOK, nil
+}
+
+// listCluster lists apiPath from cluster name, with items annotated by the cluster.
+func (p *ClusterProxy) listCluster(r *http.Request, name, apiPath string, query url.Values) (*aggregateList, error) {
+	uri := apiPath
+	if len(query) > 0 {
+		uri += "?" + query.Encode()
+	}
+	msg, err := newProxyMessage(name, http.MethodGet, uri, &handler.ProxyRequest{})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.roundTrip(msg, r.Context().Done())
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, string(resp.Body))
+	}
+	list := &aggregateList{}
+	if err := json.Unmarshal(resp.Body, list); err != nil {
+		return nil, fmt.Errorf("unmarshal list failed: %v", err)
+	}
+	annotateCluster(list.Items, name)
+	return list, nil
+}
+
+// listMirror lists apiPath from resources mirrored to the apiserver of root, which are labeled by
+// the cluster they are reported from. Pages are given by the apiserver of root.
+func (p *ClusterProxy) listMirror(apiPath string, query url.Values, selector string) (*aggregateList, int, error) {
+	if p.restclient == nil {
+		return nil, http.StatusNotImplemented, fmt.Errorf("mirrored resources are not available")
+	}
+	clusterRequirement, err := p.mirrorClusterRequirement(selector)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if clusterRequirement == "" {
+		return &aggregateList{Kind: "List", APIVersion: "v1", Items: []map[string]interface{}{}}, http.StatusOK, nil
+	}
+	if l := query.Get("labelSelector"); l != "" {
+		clusterRequirement = l + "," + clusterRequirement
+	}
+	query.Set("labelSelector", clusterRequirement)
+
+	raw, code, err := p.getMirror(apiPath + "?" + query.Encode())
+	if err != nil {
+		return nil, code, err
+	}
+	list := &aggregateList{}
+	if err := json.Unmarshal(raw, list); err != nil {
+		return nil, http.StatusBadGateway, fmt.Errorf("unmarshal list failed: %v", err)
+	}
+	for _, item := range list.Items {
+		metadata, _ := item["metadata"].(map[string]interface{})
+		itemLabels, _ := metadata["labels"].(map[string]interface{})
+		name, _ := itemLabels[reporter.ClusterLabel].(string)
+		annotateCluster([]map[string]interface{}{item}, name)
+	}
+	if list.Items == nil {
+		list.Items = []map[string]interface{}{}
+	}
+	return list, http.StatusOK, nil
+}
+
+// mirrorClusterRequirement returns the label requirement of resources mirrored from clusters
+// matched by selector, all clusters if selector is empty, or empty if no cluster is matched.
+func (p *ClusterProxy) mirrorClusterRequirement(selector string) (string, error) {
+	if selector == "" {
+		return reporter.ClusterLabel, nil
+	}
+	names, err := p.selectedClusters(selector, false)
+	if err != nil || len(names) == 0 {
+		return "", err
+	}
+	return fmt.Sprintf("%s in (%s)", reporter.ClusterLabel, strings.Join(names, ",")), nil
+}
+
+// getMirror gets uri from the apiserver of root, and returns the body, or the status code and error.
+func (p *ClusterProxy) getMirror(uri string) ([]byte, int, error) {
+	result := p.restclient.Get().
+		RequestURI(uri).
+		SetHeader("Accept", handler.ProxyContentType).
+		Do()
+	var code int
+	result.StatusCode(&code)
+	raw, err := result.Raw()
+	if err != nil {
+		if code == 0 {
+			code = http.StatusBadGateway
+		}
+		return nil, code, fmt.Errorf("list mirrored resources failed: %v", err)
+	}
+	return raw, http.StatusOK, nil
+}
+
+// merge appends items of l, the kind of list is taken from l.
+func (list *aggregateList) merge(l *aggregateList) {
+	if l.Kind != "" {
+		list.Kind, list.APIVersion = l.Kind, l.APIVersion
+	}
+	list.Items = append(list.Items, l.Items...)
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterproxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/kubernetes/scheme"
+	fakerest "k8s.io/client-go/rest/fake"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/reporter"
+)
+
+func podList(cont string, names ...string) string {
+	items := []string{}
+	for _, n := range names {
+		items = append(items, `{"metadata":{"name":"`+n+`"}}`)
+	}
+	return `{"kind":"PodList","apiVersion":"v1","metadata":{"continue":"` + cont + `"},"items":[` +
+		strings.Join(items, ",") + `]}`
+}
+
+// serveShims responds requests sent by p as shims of clusters by f until stop.
+func serveShims(t *testing.T, p *ClusterProxy, stop chan struct{},
+	f func(cluster string, uri *url.URL) (int32, string)) {
+	for {
+		select {
+		case msg := <-p.sendChan:
+			task := &clustermessage.ControllerTask{}
+			assert.Nil(t, proto.Unmarshal(msg.Body, task))
+			uri, err := url.Parse(task.URI)
+			assert.Nil(t, err)
+			cluster := strings.Trim(msg.Head.ClusterSelector, "^$")
+			code, body := f(cluster, uri)
+			data, err := proto.Marshal(&clustermessage.ControllerTaskResponse{StatusCode: code, Body: []byte(body)})
+			assert.Nil(t, err)
+			p.handleResponse(&clustermessage.ClusterMessage{
+				Head: &clustermessage.MessageHead{MessageID: msg.Head.MessageID, Command: clustermessage.CommandType_ControlResp},
+				Body: data,
+			})
+		case <-stop:
+			return
+		}
+	}
+}
+
+func getAggregate(t *testing.T, p *ClusterProxy, path string) (int, *aggregateList) {
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	list := &aggregateList{}
+	if w.Code == http.StatusOK {
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), list))
+	}
+	return w.Code, list
+}
+
+func itemNames(list *aggregateList) []string {
+	names := []string{}
+	for _, item := range list.Items {
+		metadata := item["metadata"].(map[string]interface{})
+		annotations := metadata["annotations"].(map[string]interface{})
+		names = append(names, annotations[ClusterAnnotation].(string)+"/"+metadata["name"].(string))
+	}
+	return names
+}
+
+func TestAggregateEdge(t *testing.T) {
+	p := newFakeClusterProxy(time.Second, newCluster("c1", otev1.ClusterStatusOnline),
+		newCluster("c2", otev1.ClusterStatusOnline), newCluster("c3", otev1.ClusterStatusOnline),
+		newCluster("c4", otev1.ClusterStatusOffline))
+	stop := make(chan struct{})
+	defer close(stop)
+	go serveShims(t, p, stop, func(cluster string, uri *url.URL) (int32, string) {
+		assert.Equal(t, "/api/v1/pods", uri.Path)
+		assert.Equal(t, "app=x", uri.Query().Get("labelSelector"))
+		assert.Empty(t, uri.Query().Get(clusterSelectorParam))
+		switch cluster {
+		case "c1":
+			return http.StatusOK, podList("", "a", "b")
+		case "c2":
+			if uri.Query().Get("continue") == "" {
+				return http.StatusOK, podList("next", "c")
+			}
+			return http.StatusOK, podList("", "d")
+		}
+		return http.StatusForbidden, "forbidden"
+	})
+
+	// all online clusters are listed.
+	code, list := getAggregate(t, p, "/aggregate/api/v1/pods?labelSelector=app%3Dx")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "PodList", list.Kind)
+	assert.Equal(t, []string{"c1/a", "c1/b", "c2/c"}, itemNames(list))
+	assert.Equal(t, map[string]string{"c3": "status 403: forbidden"}, list.FailedClusters)
+
+	code, list = getAggregate(t, p, "/aggregate/api/v1/pods?labelSelector=app%3Dx&clusterSelector=c1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"c1/a", "c1/b"}, itemNames(list))
+	assert.Empty(t, list.FailedClusters)
+
+	// pages of list continue across clusters.
+	code, list = getAggregate(t, p, "/aggregate/api/v1/pods?labelSelector=app%3Dx&clusterSelector=c1,c2&limit=3")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"c1/a", "c1/b", "c2/c"}, itemNames(list))
+	assert.NotEmpty(t, list.Metadata.Continue)
+	code, list = getAggregate(t, p, "/aggregate/api/v1/pods?labelSelector=app%3Dx&clusterSelector=c1,c2&limit=3&continue="+
+		list.Metadata.Continue)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"c2/d"}, itemNames(list))
+	assert.Empty(t, list.Metadata.Continue)
+
+	code, list = getAggregate(t, p, "/aggregate/api/v1/pods?labelSelector=app%3Dx&clusterSelector=c1&limit=2")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"c1/a", "c1/b"}, itemNames(list))
+	assert.Empty(t, list.Metadata.Continue)
+
+	for path, code := range map[string]int{
+		"/aggregate/api/v1/pods?limit=1&continue=invalid": http.StatusBadRequest,
+		"/aggregate/api/v1/pods?source=unknown":           http.StatusBadRequest,
+		"/aggregate/api/v1/pods?source=mirror":            http.StatusNotImplemented,
+		"/aggregate/api/v1/pods?watch=true":               http.StatusNotImplemented,
+	} {
+		c, _ := getAggregate(t, p, path)
+		assert.Equal(t, code, c, path)
+	}
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/aggregate/api/v1/pods", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestAggregateMirror(t *testing.T) {
+	var last *http.Request
+	p := newFakeClusterProxy(time.Second, newCluster("c1", otev1.ClusterStatusOnline),
+		newCluster("c2", otev1.ClusterStatusOffline), newCluster("d1", otev1.ClusterStatusOnline))
+	p.conf.AggregateSource = AggregateSourceMirror
+	p.restclient = &fakerest.RESTClient{
+		Client: fakerest.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			last = req
+			body := `{"kind":"PodList","apiVersion":"v1","metadata":{},"items":[` +
+				`{"metadata":{"name":"a-c1","labels":{"` + reporter.ClusterLabel + `":"c1"}}}]}`
+			return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader([]byte(body)))}, nil
+		}),
+		GroupVersion:         v1.SchemeGroupVersion,
+		NegotiatedSerializer: serializer.NewCodecFactory(scheme.Scheme),
+		VersionedAPIPath:     "/",
+	}
+
+	code, list := getAggregate(t, p, "/aggregate/api/v1/pods?labelSelector=app%3Dx&clusterSelector=c.*&limit=10")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"c1/a-c1"}, itemNames(list))
+	assert.Equal(t, "/api/v1/pods", last.URL.Path)
+	assert.Equal(t, "app=x,"+reporter.ClusterLabel+" in (c1,c2)", last.URL.Query().Get("labelSelector"))
+	assert.Equal(t, "10", last.URL.Query().Get("limit"))
+
+	code, _ = getAggregate(t, p, "/aggregate/api/v1/pods")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, reporter.ClusterLabel, last.URL.Query().Get("labelSelector"))
+
+	// no cluster selected.
+	last = nil
+	code, list = getAggregate(t, p, "/aggregate/api/v1/pods?clusterSelector=x")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, list.Items)
+	assert.Nil(t, last)
+}
@@ -0,0 +1,290 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//Package clusterproxy serves k8s api of edge clusters in center, so that existing tools
+//like kubectl work against edge clusters. A request to /proxy/clusters/{name}/{path} is sent
+//through the tunnel to the shim of cluster name, which sends it to its apiserver as {path}.
+package clusterproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+	"github.com/baidu/ote-stack/pkg/controllermanager"
+	otelisters "github.com/baidu/ote-stack/pkg/generated/listers/ote/v1"
+)
+
+const (
+	// DefaultTimeout is the time to wait for the response of a request by default.
+	DefaultTimeout = 30 * time.Second
+	// PathPrefix is the path prefix of requests proxied, followed by the cluster name.
+	PathPrefix = "/proxy/clusters/"
+)
+
+// Config is the config of cluster proxy.
+type Config struct {
+	// ListenAddr is the address to serve the proxy, e.g., :8273.
+	ListenAddr string
+	// Timeout is the time to wait for the response of a request, DefaultTimeout if 0.
+	Timeout time.Duration
+	// AggregateSource is the source of lists aggregated across clusters by default,
+	// AggregateSourceEdge if empty.
+	AggregateSource string
+}
+
+//ClusterProxy proxies k8s api requests to edge clusters through the tunnel.
+type ClusterProxy struct {
+	conf          *Config
+	sendChan      chan clustermessage.ClusterMessage
+	clusterLister otelisters.ClusterLister
+	// restclient is the client of apiserver of root, to list resources mirrored.
+	restclient rest.Interface
+
+	lock sync.Mutex
+	// pending are the channels waiting for responses by message id.
+	pending map[string]chan *clustermessage.ControllerTaskResponse
+}
+
+//NewInitFunc returns the InitFunc of cluster proxy by config.
+func NewInitFunc(conf *Config) controllermanager.InitFunc {
+	return func(ctx *controllermanager.ControllerContext) error {
+		p := newClusterProxy(conf, ctx.PublishChan, ctx.OteInformerFactory.Ote().V1().Clusters().Lister(),
+			ctx.K8sClient.Discovery().RESTClient())
+		controllermanager.RegistResponseHandler(p.handleResponse)
+
+		l, err := net.Listen("tcp", conf.ListenAddr)
+		if err != nil {
+			return fmt.Errorf("cluster proxy listen on %s failed: %v", conf.ListenAddr, err)
+		}
+		server := &http.Server{Handler: p}
+		go func() {
+			if err := server.Serve(l); err != nil && err != http.ErrServerClosed {
+				klog.Errorf("cluster proxy stopped: %v", err)
+			}
+		}()
+		go func() {
+			<-ctx.StopChan
+			server.Close()
+		}()
+		klog.Infof("cluster proxy serves on %s", conf.ListenAddr)
+		return nil
+	}
+}
+
+func newClusterProxy(conf *Config, sendChan chan clustermessage.ClusterMessage,
+	clusterLister otelisters.ClusterLister, restclient rest.Interface) *ClusterProxy {
+	if conf.Timeout <= 0 {
+		conf.Timeout = DefaultTimeout
+	}
+	return &ClusterProxy{
+		conf:          conf,
+		sendChan:      sendChan,
+		clusterLister: clusterLister,
+		restclient:    restclient,
+		pending:       make(map[string]chan *clustermessage.ControllerTaskResponse),
+	}
+}
+
+// parsePath returns the cluster name and the path in cluster of a request proxied.
+func parsePath(path string) (string, string, error) {
+	if !strings.HasPrefix(path, PathPrefix) {
+		return "", "", fmt.Errorf("path %s is not under %s", path, PathPrefix)
+	}
+	rest := strings.TrimPrefix(path, PathPrefix)
+	name := rest
+	apiPath := "/"
+	if i := strings.Index(rest, "/"); i >= 0 {
+		name, apiPath = rest[:i], rest[i:]
+	}
+	if name == "" {
+		return "", "", fmt.Errorf("cluster name is required in path %s", path)
+	}
+	return name, apiPath, nil
+}
+
+// isWatch returns true if the request is a watch.
+func isWatch(r *http.Request, apiPath string) bool {
+	watch := r.URL.Query().Get("watch")
+	return watch == "true" || watch == "1" || strings.Contains(apiPath, "/watch/")
+}
+
+func (p *ClusterProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, AggregatePathPrefix) {
+		p.serveAggregate(w, r)
+		return
+	}
+	if r.URL.Path == TriagePath {
+		p.serveTriage(w, r)
+		return
+	}
+	name, apiPath, err := parsePath(r.URL.Path)
+	if err != nil {
+		writeStatus(w, http.StatusNotFound, err.Error())
+		return
+	}
+	cluster, err := p.clusterLister.Clusters(otev1.ClusterNamespace).Get(name)
+	if err != nil {
+		writeStatus(w, http.StatusNotFound, fmt.Sprintf("cluster %s not found", name))
+		return
+	}
+	if cluster.Status.Status != otev1.ClusterStatusOnline {
+		writeStatus(w, http.StatusServiceUnavailable, fmt.Sprintf("cluster %s is %s", name, cluster.Status.Status))
+		return
+	}
+
+	if isWatch(r, apiPath) {
+		p.serveWatch(w, r, name, apiPath)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeStatus(w, http.StatusBadRequest, fmt.Sprintf("read body failed: %v", err))
+		return
+	}
+	uri := apiPath
+	if r.URL.RawQuery != "" {
+		uri += "?" + r.URL.RawQuery
+	}
+	msg, err := newProxyMessage(name, r.Method, uri, &handler.ProxyRequest{
+		ContentType: r.Header.Get("Content-Type"),
+		Body:        body,
+	})
+	if err != nil {
+		writeStatus(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	resp, err := p.roundTrip(msg, r.Context().Done())
+	if err != nil {
+		writeStatus(w, http.StatusGatewayTimeout, fmt.Sprintf("request to cluster %s failed: %v", name, err))
+		return
+	}
+	klog.V(3).Infof("proxy %s %s to cluster %s: %d", r.Method, uri, name, resp.StatusCode)
+	w.Header().Set("Content-Type", handler.ProxyContentType)
+	w.WriteHeader(int(resp.StatusCode))
+	w.Write(resp.Body)
+}
+
+// newProxyMessage returns the message of a request sent to shim of cluster name.
+func newProxyMessage(name, method, uri string, req *handler.ProxyRequest) (*clustermessage.ClusterMessage, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	task := &clustermessage.ControllerTask{
+		Destination: otev1.ClusterControllerDestProxy,
+		Method:      method,
+		URI:         uri,
+		Body:        data,
+	}
+	head := &clustermessage.MessageHead{
+		MessageID:       otev1.ClusterProxyMessagePrefix + string(uuid.NewUUID()),
+		ClusterSelector: "^" + regexp.QuoteMeta(name) + "$",
+		Command:         clustermessage.CommandType_ControlReq,
+	}
+	return task.ToClusterMessage(head)
+}
+
+// roundTrip sends msg and waits for its response until timeout or done.
+func (p *ClusterProxy) roundTrip(msg *clustermessage.ClusterMessage,
+	done <-chan struct{}) (*clustermessage.ControllerTaskResponse, error) {
+	id := msg.Head.MessageID
+	ch := make(chan *clustermessage.ControllerTaskResponse, 1)
+	p.lock.Lock()
+	p.pending[id] = ch
+	p.lock.Unlock()
+	defer func() {
+		p.lock.Lock()
+		delete(p.pending, id)
+		p.lock.Unlock()
+	}()
+
+	timer := time.NewTimer(p.conf.Timeout)
+	defer timer.Stop()
+	select {
+	case p.sendChan <- *msg:
+	case <-timer.C:
+		return nil, fmt.Errorf("send timeout")
+	case <-done:
+		return nil, fmt.Errorf("canceled")
+	}
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("no response in %v", p.conf.Timeout)
+	case <-done:
+		return nil, fmt.Errorf("canceled")
+	}
+}
+
+// handleResponse passes responses of requests proxied to the waiting requests.
+func (p *ClusterProxy) handleResponse(msg *clustermessage.ClusterMessage) bool {
+	if !strings.HasPrefix(msg.Head.MessageID, otev1.ClusterProxyMessagePrefix) {
+		return false
+	}
+	p.lock.Lock()
+	ch, ok := p.pending[msg.Head.MessageID]
+	p.lock.Unlock()
+	if !ok {
+		klog.V(3).Infof("drop response %s of request finished", msg.Head.MessageID)
+		return true
+	}
+	resp := &clustermessage.ControllerTaskResponse{}
+	if err := proto.Unmarshal(msg.Body, resp); err != nil {
+		klog.Errorf("unmarshal response %s failed: %v", msg.Head.MessageID, err)
+		resp = &clustermessage.ControllerTaskResponse{
+			StatusCode: http.StatusBadGateway,
+			Body:       []byte(err.Error()),
+		}
+	}
+	select {
+	case ch <- resp:
+	default:
+	}
+	return true
+}
+
+// writeStatus writes a k8s status of failure, which is understood by k8s clients.
+func writeStatus(w http.ResponseWriter, code int, message string) {
+	status := &metav1.Status{
+		TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+		Status:   metav1.StatusFailure,
+		Message:  message,
+		Code:     int32(code),
+	}
+	data, _ := json.Marshal(status)
+	w.Header().Set("Content-Type", handler.ProxyContentType)
+	w.WriteHeader(code)
+	w.Write(data)
+}
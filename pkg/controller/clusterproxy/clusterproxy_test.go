@@ -0,0 +1,255 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterproxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+	fakeote "github.com/baidu/ote-stack/pkg/generated/clientset/versioned/fake"
+	oteinformer "github.com/baidu/ote-stack/pkg/generated/informers/externalversions"
+)
+
+func newFakeClusterProxy(timeout time.Duration, clusters ...*otev1.Cluster) *ClusterProxy {
+	factory := oteinformer.NewSharedInformerFactory(fakeote.NewSimpleClientset(), 0)
+	for _, c := range clusters {
+		factory.Ote().V1().Clusters().Informer().GetIndexer().Add(c)
+	}
+	return newClusterProxy(&Config{Timeout: timeout}, make(chan clustermessage.ClusterMessage, 10),
+		factory.Ote().V1().Clusters().Lister(), nil)
+}
+
+func newCluster(name, status string) *otev1.Cluster {
+	return &otev1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: otev1.ClusterNamespace},
+		Status:     otev1.ClusterStatus{Status: status},
+	}
+}
+
+// respond responds the request sent by p with code and body as the shim of cluster.
+func respond(t *testing.T, p *ClusterProxy, code int32, body string) *clustermessage.ControllerTask {
+	msg := <-p.sendChan
+	task := &clustermessage.ControllerTask{}
+	assert.Nil(t, proto.Unmarshal(msg.Body, task))
+	data, err := proto.Marshal(&clustermessage.ControllerTaskResponse{StatusCode: code, Body: []byte(body)})
+	assert.Nil(t, err)
+	resp := &clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{
+			MessageID:   msg.Head.MessageID,
+			Command:     clustermessage.CommandType_ControlResp,
+			ClusterName: "c1",
+		},
+		Body: data,
+	}
+	assert.True(t, p.handleResponse(resp))
+	// response of request finished is dropped.
+	assert.True(t, p.handleResponse(resp))
+	return task
+}
+
+func TestParsePath(t *testing.T) {
+	cases := []struct {
+		Path    string
+		Name    string
+		APIPath string
+		Err     bool
+	}{
+		{Path: "/proxy/clusters/c1/api/v1/pods", Name: "c1", APIPath: "/api/v1/pods"},
+		{Path: "/proxy/clusters/c1", Name: "c1", APIPath: "/"},
+		{Path: "/proxy/clusters/c1/", Name: "c1", APIPath: "/"},
+		{Path: "/proxy/clusters/", Err: true},
+		{Path: "/api/v1/pods", Err: true},
+	}
+	for _, c := range cases {
+		name, apiPath, err := parsePath(c.Path)
+		assert.Equal(t, c.Err, err != nil, c.Path)
+		assert.Equal(t, c.Name, name, c.Path)
+		assert.Equal(t, c.APIPath, apiPath, c.Path)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	p := newFakeClusterProxy(time.Second, newCluster("c1", otev1.ClusterStatusOnline),
+		newCluster("c2", otev1.ClusterStatusOffline))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPatch, "/proxy/clusters/c1/api/v1/namespaces/default/pods/p1?dryRun=All",
+		strings.NewReader(`{"metadata":{}}`))
+	r.Header.Set("Content-Type", "application/merge-patch+json")
+	done := make(chan *clustermessage.ControllerTask)
+	go func() {
+		done <- respond(t, p, http.StatusOK, `{"kind":"Pod"}`)
+	}()
+	p.ServeHTTP(w, r)
+	task := <-done
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"kind":"Pod"}`, w.Body.String())
+	assert.Equal(t, handler.ProxyContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, otev1.ClusterControllerDestProxy, task.Destination)
+	assert.Equal(t, http.MethodPatch, task.Method)
+	assert.Equal(t, "/api/v1/namespaces/default/pods/p1?dryRun=All", task.URI)
+	req := &handler.ProxyRequest{}
+	assert.Nil(t, json.Unmarshal(task.Body, req))
+	assert.Equal(t, "application/merge-patch+json", req.ContentType)
+	assert.Equal(t, `{"metadata":{}}`, string(req.Body))
+	assert.Empty(t, p.pending)
+
+	// failures are responded in k8s status.
+	for path, code := range map[string]int{
+		"/proxy/clusters/c3/api":            http.StatusNotFound,
+		"/proxy/clusters/c2/api":            http.StatusServiceUnavailable,
+		"/proxy/clusters/c2/api?watch=true": http.StatusServiceUnavailable,
+		"/api":                              http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, code, w.Code, path)
+		status := &metav1.Status{}
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), status))
+		assert.Equal(t, int32(code), status.Code)
+		assert.Equal(t, "Status", status.Kind)
+	}
+
+	// no response.
+	p.conf.Timeout = 10 * time.Millisecond
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/proxy/clusters/c1/api", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Empty(t, p.pending)
+}
+
+func TestHandleResponse(t *testing.T) {
+	p := newFakeClusterProxy(time.Second)
+	msg := &clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{MessageID: "cc1", Command: clustermessage.CommandType_ControlResp},
+	}
+	assert.False(t, p.handleResponse(msg))
+
+	ch := make(chan *clustermessage.ControllerTaskResponse, 1)
+	p.pending["proxy-1"] = ch
+	msg.Head.MessageID = "proxy-1"
+	msg.Body = []byte("invalid")
+	assert.True(t, p.handleResponse(msg))
+	resp := <-ch
+	assert.Equal(t, int32(http.StatusBadGateway), resp.StatusCode)
+}
+
+// sendPart sends a part of watch id to p as the shim of cluster.
+func sendPart(t *testing.T, p *ClusterProxy, id string, code int32, part *handler.ProxyWatchPart) {
+	body, err := json.Marshal(part)
+	assert.Nil(t, err)
+	data, err := proto.Marshal(&clustermessage.ControllerTaskResponse{StatusCode: code, Body: body})
+	assert.Nil(t, err)
+	for {
+		p.lock.Lock()
+		_, ok := p.pending[id]
+		p.lock.Unlock()
+		if ok {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	p.handleResponse(&clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{MessageID: id, Command: clustermessage.CommandType_ControlResp},
+		Body: data,
+	})
+}
+
+// nextWatch returns the id and uri of the next watch sent by p, skipping cancels of watches.
+func nextWatch(t *testing.T, p *ClusterProxy) (string, string) {
+	for {
+		msg := <-p.sendChan
+		task := &clustermessage.ControllerTask{}
+		assert.Nil(t, proto.Unmarshal(msg.Body, task))
+		req := &handler.ProxyRequest{}
+		assert.Nil(t, json.Unmarshal(task.Body, req))
+		if req.Watch {
+			return msg.Head.MessageID, task.URI
+		}
+		assert.NotEmpty(t, req.CancelWatch)
+	}
+}
+
+func TestServeWatch(t *testing.T) {
+	defer func(d time.Duration) { watchLostAfter = d }(watchLostAfter)
+	watchLostAfter = 50 * time.Millisecond
+	p := newFakeClusterProxy(time.Second, newCluster("c1", otev1.ClusterStatusOnline))
+	event := func(rv string) json.RawMessage {
+		return json.RawMessage(`{"type":"ADDED","object":{"metadata":{"resourceVersion":"` + rv + `"}}}`)
+	}
+
+	// parts are written in order of sequence.
+	go func() {
+		id, uri := nextWatch(t, p)
+		assert.Contains(t, uri, "/api/v1/pods?")
+		assert.Contains(t, uri, "timeoutSeconds=")
+		sendPart(t, p, id, http.StatusOK, &handler.ProxyWatchPart{Seq: 2, Events: []json.RawMessage{event("2")}})
+		sendPart(t, p, id, http.StatusOK, &handler.ProxyWatchPart{Seq: 3, Events: []json.RawMessage{event("3")}, Done: true})
+		sendPart(t, p, id, http.StatusOK, &handler.ProxyWatchPart{Seq: 1})
+	}()
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/proxy/clusters/c1/api/v1/pods?watch=true", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, string(event("2"))+"\n"+string(event("3"))+"\n", w.Body.String())
+	assert.Empty(t, p.pending)
+
+	// watch is resumed from the last resource version if parts are lost.
+	go func() {
+		id, _ := nextWatch(t, p)
+		sendPart(t, p, id, http.StatusOK, &handler.ProxyWatchPart{Seq: 1, Events: []json.RawMessage{event("7")}})
+		id, uri := nextWatch(t, p)
+		assert.Contains(t, uri, "resourceVersion=7")
+		sendPart(t, p, id, http.StatusOK, &handler.ProxyWatchPart{Seq: 1})
+		sendPart(t, p, id, http.StatusOK, &handler.ProxyWatchPart{Seq: 2, Events: []json.RawMessage{event("8")}, Done: true})
+	}()
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/proxy/clusters/c1/api/v1/watch/pods", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, string(event("7"))+"\n"+string(event("8"))+"\n", w.Body.String())
+
+	// watch refused by apiserver of edge.
+	go func() {
+		id, _ := nextWatch(t, p)
+		sendPart(t, p, id, http.StatusForbidden, &handler.ProxyWatchPart{Seq: 1, Done: true, Error: "forbidden"})
+	}()
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/proxy/clusters/c1/api/v1/pods?watch=1", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// no response of watch.
+	p.conf.Timeout = 10 * time.Millisecond
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/proxy/clusters/c1/api/v1/pods?watch=1", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+}
+
+func TestWatchDeadline(t *testing.T) {
+	now := time.Now()
+	assert.Equal(t, now.Add(time.Minute), watchDeadline(map[string][]string{"timeoutSeconds": {"60"}}, now))
+	assert.Equal(t, now.Add(DefaultWatchTimeout), watchDeadline(map[string][]string{}, now))
+}
@@ -0,0 +1,330 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package clusterproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+	"github.com/baidu/ote-stack/pkg/controllermanager"
+	"github.com/baidu/ote-stack/pkg/reporter"
+)
+
+const (
+	// TriagePath is the path of triage of pods across clusters from resources mirrored to root.
+	TriagePath = "/triage/pods"
+	// DefaultTriageSince is the time before now terminations and events are collected in by default.
+	DefaultTriageSince = time.Hour
+
+	// namespaceParam is the query of namespace of pods to triage, all namespaces if empty.
+	namespaceParam = "namespace"
+	// sinceParam is the query of time before now to collect terminations and events in, e.g., 30m.
+	sinceParam = "since"
+	// maxTriageEvents is the max number of the latest events collected of a pod.
+	maxTriageEvents = 10
+)
+
+// triageReport is the triage of pods selected across clusters.
+type triageReport struct {
+	Kind  string `json:"kind"`
+	Since string `json:"since"`
+	// Clusters are summaries of pods by cluster name.
+	Clusters map[string]*triageSummary `json:"clusters"`
+	// Pods are sorted by restarts descending, then cluster and name.
+	Pods []*podTriage `json:"pods"`
+}
+
+// triageSummary is the summary of pods of a cluster.
+type triageSummary struct {
+	Pods       int   `json:"pods"`
+	NotReady   int   `json:"notReady"`
+	Restarts   int32 `json:"restarts"`
+	Terminated int   `json:"terminated"`
+	Warnings   int   `json:"warnings"`
+}
+
+// podTriage is the state of a pod mirrored, with its recent terminations and events.
+type podTriage struct {
+	Cluster    string             `json:"cluster"`
+	Namespace  string             `json:"namespace"`
+	Name       string             `json:"name"`
+	Phase      corev1.PodPhase    `json:"phase,omitempty"`
+	Reason     string             `json:"reason,omitempty"`
+	Message    string             `json:"message,omitempty"`
+	NodeName   string             `json:"nodeName,omitempty"`
+	Ready      bool               `json:"ready"`
+	Restarts   int32              `json:"restarts"`
+	Containers []*containerTriage `json:"containers,omitempty"`
+	Events     []*eventTriage     `json:"events,omitempty"`
+	key        string
+}
+
+// containerTriage is the state of a container, with its termination in the time collected.
+type containerTriage struct {
+	Name         string `json:"name"`
+	Init         bool   `json:"init,omitempty"`
+	Ready        bool   `json:"ready"`
+	RestartCount int32  `json:"restartCount"`
+	// State is waiting, running or terminated, with the reason and message of waiting or terminated.
+	State       string                           `json:"state,omitempty"`
+	Reason      string                           `json:"reason,omitempty"`
+	Message     string                           `json:"message,omitempty"`
+	Termination *corev1.ContainerStateTerminated `json:"termination,omitempty"`
+}
+
+// eventTriage is an event of a pod.
+type eventTriage struct {
+	Type          string      `json:"type,omitempty"`
+	Reason        string      `json:"reason,omitempty"`
+	Message       string      `json:"message,omitempty"`
+	Count         int32       `json:"count,omitempty"`
+	LastTimestamp metav1.Time `json:"lastTimestamp"`
+}
+
+/*
+serveTriage collects pods of an app selected by query labelSelector across clusters selected by
+query clusterSelector, with restart counts, termination messages and events of them in query since,
+from pods and events mirrored to root, so that pods failing are triaged without listing edge clusters.
+*/
+func (p *ClusterProxy) serveTriage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeStatus(w, http.StatusMethodNotAllowed, "only get is supported by triage")
+		return
+	}
+	if p.restclient == nil {
+		writeStatus(w, http.StatusNotImplemented, "mirrored resources are not available")
+		return
+	}
+	query := r.URL.Query()
+	labelSelector := query.Get("labelSelector")
+	if labelSelector == "" {
+		writeStatus(w, http.StatusBadRequest, "labelSelector of the app is required")
+		return
+	}
+	since := DefaultTriageSince
+	if s := query.Get(sinceParam); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			writeStatus(w, http.StatusBadRequest, fmt.Sprintf("since %s is invalid", s))
+			return
+		}
+		since = d
+	}
+
+	report, code, err := p.triage(query.Get(namespaceParam), labelSelector,
+		query.Get(clusterSelectorParam), time.Now().Add(-since))
+	if err != nil {
+		writeStatus(w, code, err.Error())
+		return
+	}
+	report.Since = since.String()
+	data, err := json.Marshal(report)
+	if err != nil {
+		writeStatus(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	klog.V(3).Infof("triage pods of %q in clusters %q: %d pods", labelSelector, query.Get(clusterSelectorParam), len(report.Pods))
+	w.Header().Set("Content-Type", handler.ProxyContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// triage returns the triage of pods in namespace selected by labelSelector of clusters selected,
+// with terminations and events after from.
+func (p *ClusterProxy) triage(namespace, labelSelector, clusterSelector string,
+	from time.Time) (*triageReport, int, error) {
+	report := &triageReport{Kind: "PodTriage", Clusters: map[string]*triageSummary{}, Pods: []*podTriage{}}
+	clusterRequirement, err := p.mirrorClusterRequirement(clusterSelector)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if clusterRequirement == "" {
+		return report, http.StatusOK, nil
+	}
+	apiPath := "/api/v1"
+	if namespace != "" {
+		apiPath += "/namespaces/" + url.PathEscape(namespace)
+	}
+
+	raw, code, err := p.getMirror(apiPath + "/pods?" + url.Values{
+		"labelSelector": {labelSelector + "," + clusterRequirement},
+	}.Encode())
+	if err != nil {
+		return nil, code, err
+	}
+	pods := &corev1.PodList{}
+	if err := json.Unmarshal(raw, pods); err != nil {
+		return nil, http.StatusBadGateway, fmt.Errorf("unmarshal pods failed: %v", err)
+	}
+	if len(pods.Items) == 0 {
+		return report, http.StatusOK, nil
+	}
+	byKey := make(map[string]*podTriage, len(pods.Items))
+	for i := range pods.Items {
+		t := newPodTriage(&pods.Items[i], from)
+		byKey[t.key] = t
+		report.Pods = append(report.Pods, t)
+	}
+
+	// events of pods are mirrored in the namespace of pods, involving the pods mirrored.
+	raw, code, err = p.getMirror(apiPath + "/events?" + url.Values{
+		"labelSelector": {clusterRequirement},
+		"fieldSelector": {"involvedObject.kind=Pod"},
+	}.Encode())
+	if err != nil {
+		return nil, code, err
+	}
+	events := &corev1.EventList{}
+	if err := json.Unmarshal(raw, events); err != nil {
+		return nil, http.StatusBadGateway, fmt.Errorf("unmarshal events failed: %v", err)
+	}
+	for i := range events.Items {
+		e := &events.Items[i]
+		t, ok := byKey[e.InvolvedObject.Namespace+"/"+e.InvolvedObject.Name]
+		if !ok {
+			continue
+		}
+		last := eventTime(e)
+		if last.Time.Before(from) {
+			continue
+		}
+		t.Events = append(t.Events, &eventTriage{
+			Type:          e.Type,
+			Reason:        e.Reason,
+			Message:       e.Message,
+			Count:         e.Count,
+			LastTimestamp: last,
+		})
+	}
+
+	for _, t := range report.Pods {
+		sort.SliceStable(t.Events, func(i, j int) bool {
+			return t.Events[j].LastTimestamp.Before(&t.Events[i].LastTimestamp)
+		})
+		if len(t.Events) > maxTriageEvents {
+			t.Events = t.Events[:maxTriageEvents]
+		}
+		summary, ok := report.Clusters[t.Cluster]
+		if !ok {
+			summary = &triageSummary{}
+			report.Clusters[t.Cluster] = summary
+		}
+		summary.Pods++
+		if !t.Ready {
+			summary.NotReady++
+		}
+		summary.Restarts += t.Restarts
+		for _, c := range t.Containers {
+			if c.Termination != nil {
+				summary.Terminated++
+			}
+		}
+		for _, e := range t.Events {
+			if e.Type == corev1.EventTypeWarning {
+				summary.Warnings++
+			}
+		}
+	}
+	sort.SliceStable(report.Pods, func(i, j int) bool {
+		a, b := report.Pods[i], report.Pods[j]
+		if a.Restarts != b.Restarts {
+			return a.Restarts > b.Restarts
+		}
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		return a.Namespace+"/"+a.Name < b.Namespace+"/"+b.Name
+	})
+	return report, http.StatusOK, nil
+}
+
+// newPodTriage returns the triage of pod mirrored, with terminations after from.
+// The name of pod is the one in its cluster, without the suffix of cluster name.
+func newPodTriage(pod *corev1.Pod, from time.Time) *podTriage {
+	cluster := pod.Labels[reporter.ClusterLabel]
+	t := &podTriage{
+		Cluster:   cluster,
+		Namespace: pod.Namespace,
+		Name:      strings.TrimSuffix(pod.Name, controllermanager.UniqueResourceNameSeparator+cluster),
+		Phase:     pod.Status.Phase,
+		Reason:    pod.Status.Reason,
+		Message:   pod.Status.Message,
+		NodeName:  pod.Spec.NodeName,
+		key:       pod.Namespace + "/" + pod.Name,
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			t.Ready = cond.Status == corev1.ConditionTrue
+		}
+	}
+	for i, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, s := range statuses {
+			c := newContainerTriage(&s, from)
+			c.Init = i == 0
+			t.Restarts += s.RestartCount
+			t.Containers = append(t.Containers, c)
+		}
+	}
+	return t
+}
+
+// newContainerTriage returns the triage of container status s, with the termination after from,
+// which is the current state if terminated, otherwise the last one.
+func newContainerTriage(s *corev1.ContainerStatus, from time.Time) *containerTriage {
+	c := &containerTriage{
+		Name:         s.Name,
+		Ready:        s.Ready,
+		RestartCount: s.RestartCount,
+	}
+	switch {
+	case s.State.Waiting != nil:
+		c.State, c.Reason, c.Message = "waiting", s.State.Waiting.Reason, s.State.Waiting.Message
+	case s.State.Running != nil:
+		c.State = "running"
+	case s.State.Terminated != nil:
+		c.State, c.Reason, c.Message = "terminated", s.State.Terminated.Reason, s.State.Terminated.Message
+	}
+	termination := s.State.Terminated
+	if termination == nil {
+		termination = s.LastTerminationState.Terminated
+	}
+	if termination != nil && !termination.FinishedAt.Time.Before(from) {
+		c.Termination = termination.DeepCopy()
+	}
+	return c
+}
+
+// eventTime returns the time an event last happened.
+func eventTime(e *corev1.Event) metav1.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp
+	case !e.EventTime.IsZero():
+		return metav1.Time{Time: e.EventTime.Time}
+	default:
+		return e.CreationTimestamp
+	}
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package clusterproxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/kubernetes/scheme"
+	fakerest "k8s.io/client-go/rest/fake"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/reporter"
+)
+
+func newTriagePod(name, cluster string, restarts int32, ready bool, terminated *corev1.ContainerStateTerminated) corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name + "-" + cluster,
+			Namespace: "default",
+			Labels:    map[string]string{"app": "x", reporter.ClusterLabel: cluster},
+		},
+		Spec: corev1.PodSpec{NodeName: "n1"},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:                 "app",
+				Ready:                ready,
+				RestartCount:         restarts,
+				State:                corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+				LastTerminationState: corev1.ContainerState{Terminated: terminated},
+			}},
+		},
+	}
+}
+
+func newTriageEvent(pod, cluster, eventType, reason string, last time.Time) corev1.Event {
+	return corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pod + "." + reason + "-" + cluster,
+			Namespace: "default",
+			Labels:    map[string]string{reporter.ClusterLabel: cluster},
+		},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: "default", Name: pod + "-" + cluster},
+		Type:           eventType,
+		Reason:         reason,
+		Message:        reason + " of " + pod,
+		Count:          2,
+		LastTimestamp:  metav1.NewTime(last),
+	}
+}
+
+func TestTriage(t *testing.T) {
+	now := time.Now()
+	requests := []*http.Request{}
+	pods := &corev1.PodList{Items: []corev1.Pod{
+		newTriagePod("a", "c1", 0, true, nil),
+		newTriagePod("b", "c1", 3, false, &corev1.ContainerStateTerminated{
+			ExitCode: 1, Reason: "Error", Message: "panic: config not found",
+			FinishedAt: metav1.NewTime(now.Add(-time.Minute)),
+		}),
+		newTriagePod("a", "c2", 1, true, &corev1.ContainerStateTerminated{
+			ExitCode: 137, Reason: "OOMKilled", FinishedAt: metav1.NewTime(now.Add(-2 * time.Hour)),
+		}),
+	}}
+	events := &corev1.EventList{Items: []corev1.Event{
+		newTriageEvent("b", "c1", corev1.EventTypeNormal, "Pulled", now.Add(-2*time.Minute)),
+		newTriageEvent("b", "c1", corev1.EventTypeWarning, "BackOff", now.Add(-time.Minute)),
+		newTriageEvent("a", "c2", corev1.EventTypeWarning, "OOMKilling", now.Add(-2*time.Hour)),
+		newTriageEvent("other", "c1", corev1.EventTypeWarning, "Failed", now),
+	}}
+	p := newFakeClusterProxy(time.Second, newCluster("c1", otev1.ClusterStatusOnline),
+		newCluster("c2", otev1.ClusterStatusOffline), newCluster("d1", otev1.ClusterStatusOnline))
+	p.restclient = &fakerest.RESTClient{
+		Client: fakerest.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			requests = append(requests, req)
+			var body []byte
+			switch req.URL.Path {
+			case "/api/v1/namespaces/default/pods":
+				body, _ = json.Marshal(pods)
+			case "/api/v1/namespaces/default/events":
+				body, _ = json.Marshal(events)
+			default:
+				return &http.Response{StatusCode: http.StatusNotFound, Body: ioutil.NopCloser(bytes.NewReader(nil))}, nil
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(body))}, nil
+		}),
+		GroupVersion:         corev1.SchemeGroupVersion,
+		NegotiatedSerializer: serializer.NewCodecFactory(scheme.Scheme),
+		VersionedAPIPath:     "/",
+	}
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet,
+		TriagePath+"?namespace=default&labelSelector=app%3Dx&clusterSelector=c.*&since=30m", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, requests, 2)
+	assert.Equal(t, "app=x,"+reporter.ClusterLabel+" in (c1,c2)", requests[0].URL.Query().Get("labelSelector"))
+	assert.Equal(t, reporter.ClusterLabel+" in (c1,c2)", requests[1].URL.Query().Get("labelSelector"))
+	assert.Equal(t, "involvedObject.kind=Pod", requests[1].URL.Query().Get("fieldSelector"))
+
+	report := &triageReport{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), report))
+	assert.Equal(t, "30m0s", report.Since)
+	assert.Len(t, report.Pods, 3)
+	// pods restarted most come first, with names in their clusters.
+	b := report.Pods[0]
+	assert.Equal(t, "c1", b.Cluster)
+	assert.Equal(t, "b", b.Name)
+	assert.Equal(t, int32(3), b.Restarts)
+	assert.False(t, b.Ready)
+	assert.Equal(t, "waiting", b.Containers[0].State)
+	assert.Equal(t, "CrashLoopBackOff", b.Containers[0].Reason)
+	assert.Equal(t, "panic: config not found", b.Containers[0].Termination.Message)
+	assert.Equal(t, int32(1), b.Containers[0].Termination.ExitCode)
+	assert.Len(t, b.Events, 2)
+	assert.Equal(t, "BackOff", b.Events[0].Reason)
+	assert.Equal(t, "Pulled", b.Events[1].Reason)
+	// terminations and events before since are not collected.
+	assert.Equal(t, "c2", report.Pods[1].Cluster)
+	assert.Nil(t, report.Pods[1].Containers[0].Termination)
+	assert.Empty(t, report.Pods[1].Events)
+	assert.Equal(t, "a", report.Pods[2].Name)
+
+	assert.Equal(t, &triageSummary{Pods: 2, NotReady: 1, Restarts: 3, Terminated: 1, Warnings: 1}, report.Clusters["c1"])
+	assert.Equal(t, &triageSummary{Pods: 1, Restarts: 1}, report.Clusters["c2"])
+}
+
+func TestTriageInvalid(t *testing.T) {
+	p := newFakeClusterProxy(time.Second, newCluster("c1", otev1.ClusterStatusOnline))
+	get := func(method, path string) int {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w.Code
+	}
+	// mirrored resources are not available without the client of root.
+	assert.Equal(t, http.StatusNotImplemented, get(http.MethodGet, TriagePath+"?labelSelector=app%3Dx"))
+
+	p.restclient = &fakerest.RESTClient{
+		Client: fakerest.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			t.Errorf("unexpected request %s", req.URL)
+			return nil, nil
+		}),
+		NegotiatedSerializer: serializer.NewCodecFactory(scheme.Scheme),
+	}
+	assert.Equal(t, http.StatusMethodNotAllowed, get(http.MethodPost, TriagePath+"?labelSelector=app%3Dx"))
+	assert.Equal(t, http.StatusBadRequest, get(http.MethodGet, TriagePath))
+	assert.Equal(t, http.StatusBadRequest, get(http.MethodGet, TriagePath+"?labelSelector=app%3Dx&since=x"))
+
+	// no cluster selected.
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, TriagePath+"?labelSelector=app%3Dx&clusterSelector=d.*", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	report := &triageReport{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), report))
+	assert.Empty(t, report.Pods)
+}
@@ -0,0 +1,266 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterproxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+)
+
+const (
+	// DefaultWatchTimeout is the duration of a watch without timeoutSeconds.
+	DefaultWatchTimeout = 30 * time.Minute
+	// watchPartsBuffer is the parts of a watch buffered, parts are lost if exceeded.
+	watchPartsBuffer = 64
+)
+
+// watchLostAfter is the time without parts after which a watch is taken as lost,
+// e.g., the tunnel is reconnected, and resumed.
+var watchLostAfter = 3 * handler.ProxyWatchHeartbeat
+
+// watchEvent is the part of a watch event to resume the watch.
+type watchEvent struct {
+	Type   string `json:"type"`
+	Object struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+	} `json:"object"`
+}
+
+// watchResult is the result of following a watch of edge.
+type watchResult int
+
+const (
+	// watchClosed means the watch is closed by apiserver of edge, or by an error event.
+	watchClosed watchResult = iota
+	// watchLost means parts of the watch are lost, and the watch should be resumed.
+	watchLost
+	// watchCanceled means the client is gone.
+	watchCanceled
+)
+
+// watchStream writes events of a watch to the client as a chunked watch response.
+type watchStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	started bool
+	// resourceVersion is the resource version of the last event written.
+	resourceVersion string
+}
+
+// start writes the header of watch response if not written.
+func (s *watchStream) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+	s.w.Header().Set("Content-Type", handler.ProxyContentType)
+	s.w.Header().Set("Transfer-Encoding", "chunked")
+	s.w.WriteHeader(http.StatusOK)
+	s.flusher.Flush()
+}
+
+// write writes events, and returns true if an error event is written, which ends the watch.
+func (s *watchStream) write(events []json.RawMessage) bool {
+	if len(events) == 0 {
+		return false
+	}
+	s.start()
+	failed := false
+	for _, event := range events {
+		s.w.Write(event)
+		s.w.Write([]byte("\n"))
+		e := &watchEvent{}
+		if err := json.Unmarshal(event, e); err != nil {
+			continue
+		}
+		if e.Type == "ERROR" {
+			failed = true
+			break
+		}
+		if e.Object.Metadata.ResourceVersion != "" {
+			s.resourceVersion = e.Object.Metadata.ResourceVersion
+		}
+	}
+	s.flusher.Flush()
+	return failed
+}
+
+// watchDeadline returns the end time of a watch by its timeoutSeconds.
+func watchDeadline(query url.Values, now time.Time) time.Time {
+	if seconds, err := strconv.ParseInt(query.Get("timeoutSeconds"), 10, 64); err == nil && seconds > 0 {
+		return now.Add(time.Duration(seconds) * time.Second)
+	}
+	return now.Add(DefaultWatchTimeout)
+}
+
+/*
+serveWatch proxies a watch to cluster name. The watch of edge sends its events in parts, which
+are written to the client in order of their sequence as a chunked watch response.
+If parts are lost, or no part is received in watchLostAfter, e.g., the tunnel is reconnected,
+the watch is canceled and resumed from the resource version of the last event written.
+*/
+func (p *ClusterProxy) serveWatch(w http.ResponseWriter, r *http.Request, name, apiPath string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeStatus(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	stream := &watchStream{w: w, flusher: flusher}
+	query := r.URL.Query()
+	stream.resourceVersion = query.Get("resourceVersion")
+	deadline := watchDeadline(query, time.Now())
+
+	for {
+		remaining := deadline.Sub(time.Now())
+		if remaining < time.Second {
+			return
+		}
+		if stream.resourceVersion != "" {
+			query.Set("resourceVersion", stream.resourceVersion)
+		}
+		query.Set("timeoutSeconds", strconv.FormatInt(int64(remaining/time.Second), 10))
+		msg, err := newProxyMessage(name, http.MethodGet, apiPath+"?"+query.Encode(),
+			&handler.ProxyRequest{Watch: true})
+		if err != nil {
+			writeStatus(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		result := p.followWatch(msg, stream, r.Context().Done())
+		if result != watchLost {
+			if result == watchCanceled {
+				p.cancelWatch(name, msg.Head.MessageID)
+			}
+			return
+		}
+		p.cancelWatch(name, msg.Head.MessageID)
+		klog.Infof("watch %s of cluster %s is lost, resume from resource version %s",
+			apiPath, name, stream.resourceVersion)
+	}
+}
+
+// followWatch sends the watch in msg, and writes events in parts received to stream
+// until the watch is closed, lost or canceled.
+func (p *ClusterProxy) followWatch(msg *clustermessage.ClusterMessage, stream *watchStream,
+	done <-chan struct{}) watchResult {
+	id := msg.Head.MessageID
+	ch := make(chan *clustermessage.ControllerTaskResponse, watchPartsBuffer)
+	p.lock.Lock()
+	p.pending[id] = ch
+	p.lock.Unlock()
+	defer func() {
+		p.lock.Lock()
+		delete(p.pending, id)
+		p.lock.Unlock()
+	}()
+
+	select {
+	case p.sendChan <- *msg:
+	case <-time.After(p.conf.Timeout):
+		if !stream.started {
+			writeStatus(stream.w, http.StatusGatewayTimeout, "send watch timeout")
+			return watchClosed
+		}
+		return watchLost
+	case <-done:
+		return watchCanceled
+	}
+
+	var next int64 = 1
+	// parts arrived before the parts preceding them.
+	parts := make(map[int64]*handler.ProxyWatchPart)
+	lost := time.NewTimer(p.conf.Timeout)
+	defer lost.Stop()
+	for {
+		select {
+		case resp := <-ch:
+			part := &handler.ProxyWatchPart{}
+			if err := json.Unmarshal(resp.Body, part); err != nil {
+				klog.Errorf("unmarshal part of watch %s failed: %v", id, err)
+				return watchLost
+			}
+			if part.Seq == 1 && resp.StatusCode != http.StatusOK {
+				// watch is refused by apiserver.
+				if !stream.started {
+					writeStatus(stream.w, int(resp.StatusCode), part.Error)
+				}
+				return watchClosed
+			}
+			if part.Seq < next {
+				continue
+			}
+			parts[part.Seq] = part
+			if len(parts) >= watchPartsBuffer {
+				klog.Warningf("parts of watch %s before %d are lost", id, part.Seq)
+				return watchLost
+			}
+			for {
+				part, ok := parts[next]
+				if !ok {
+					break
+				}
+				delete(parts, next)
+				next++
+				if stream.write(part.Events) {
+					return watchClosed
+				}
+				if part.Done {
+					if part.Error != "" {
+						klog.V(3).Infof("watch %s closed: %s", id, part.Error)
+						return watchLost
+					}
+					return watchClosed
+				}
+			}
+			// the first part shows the watch is started.
+			stream.start()
+			lost.Reset(watchLostAfter)
+		case <-lost.C:
+			if !stream.started {
+				writeStatus(stream.w, http.StatusGatewayTimeout, "no response of watch")
+				return watchClosed
+			}
+			return watchLost
+		case <-done:
+			return watchCanceled
+		}
+	}
+}
+
+// cancelWatch cancels the watch of id in cluster name, the response is dropped.
+func (p *ClusterProxy) cancelWatch(name, id string) {
+	msg, err := newProxyMessage(name, http.MethodDelete, "/", &handler.ProxyRequest{CancelWatch: id})
+	if err != nil {
+		klog.Errorf("make cancel of watch %s failed: %v", id, err)
+		return
+	}
+	select {
+	case p.sendChan <- *msg:
+	default:
+		klog.Warningf("cancel of watch %s is dropped", id)
+	}
+}
@@ -0,0 +1,326 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package crontask creates ClusterControllers of CronClusterTasks on their schedules,
+// and keeps history of runs by results of clusters, as CronJob does for Jobs.
+package crontask
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clusterselector"
+	"github.com/baidu/ote-stack/pkg/controllermanager"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned"
+	otelisters "github.com/baidu/ote-stack/pkg/generated/listers/ote/v1"
+)
+
+const (
+	defaultSuccessfulRunsHistoryLimit = 3
+	defaultFailedRunsHistoryLimit     = 1
+
+	// RunReasonReplaced is the reason of runs deleted by concurrency policy Replace.
+	RunReasonReplaced = "Replaced"
+	// RunReasonDeleted is the reason of runs whose ClusterControllers are deleted before done.
+	RunReasonDeleted = "Deleted"
+)
+
+// syncInterval is the interval to check schedules and runs of CronClusterTasks.
+var syncInterval = 10 * time.Second
+
+// CronTaskController runs CronClusterTasks. A run is a ClusterController created from the template,
+// named by the CronClusterTask and the unix time of its schedule, and done when all online clusters
+// selected on creation responded, including TimedOut by root on task timeout.
+type CronTaskController struct {
+	oteClient     oteclient.Interface
+	cronLister    otelisters.CronClusterTaskLister
+	ccLister      otelisters.ClusterControllerLister
+	clusterLister otelisters.ClusterLister
+	now           func() time.Time
+}
+
+// InitCronTaskController inits crontask controller.
+func InitCronTaskController(ctx *controllermanager.ControllerContext) error {
+	informers := ctx.OteInformerFactory.Ote().V1()
+	c := &CronTaskController{
+		oteClient:     ctx.OteClient,
+		cronLister:    informers.CronClusterTasks().Lister(),
+		ccLister:      informers.ClusterControllers().Lister(),
+		clusterLister: informers.Clusters().Lister(),
+		now:           time.Now,
+	}
+	synced := []cache.InformerSynced{
+		informers.CronClusterTasks().Informer().HasSynced,
+		informers.ClusterControllers().Informer().HasSynced,
+		informers.Clusters().Informer().HasSynced,
+	}
+	go func() {
+		// runs are done by mistake if ClusterControllers are not listed.
+		if !cache.WaitForCacheSync(ctx.StopChan, synced...) {
+			return
+		}
+		wait.Until(c.syncAll, syncInterval, ctx.StopChan)
+	}()
+	return nil
+}
+
+func (c *CronTaskController) syncAll() {
+	crons, err := c.cronLister.CronClusterTasks(otev1.ClusterNamespace).List(labels.Everything())
+	if err != nil {
+		klog.Errorf("list cronclustertasks failed: %v", err)
+		return
+	}
+	for _, cron := range crons {
+		if err := c.sync(cron); err != nil {
+			klog.Errorf("sync cronclustertask %s failed: %v", cron.Name, err)
+		}
+	}
+}
+
+// sync completes runs done, creates the run scheduled and prunes history of cron.
+func (c *CronTaskController) sync(cron *otev1.CronClusterTask) error {
+	now := c.now()
+	status := cron.Status.DeepCopy()
+	history := status.History
+	done := func(run otev1.CronClusterTaskRun) {
+		run.CompletionTime = now.Unix()
+		history = append([]otev1.CronClusterTaskRun{run}, history...)
+	}
+
+	var active []otev1.CronClusterTaskRun
+	for _, run := range status.Active {
+		cc, err := c.getRun(run.Name)
+		if errors.IsNotFound(err) {
+			countResults(&run, nil)
+			run.Reason = RunReasonDeleted
+			done(run)
+			continue
+		}
+		if err != nil || !countResults(&run, cc) {
+			active = append(active, run)
+			continue
+		}
+		klog.Infof("run %s of cronclustertask %s is done, %d succeeded, %d failed",
+			run.Name, cron.Name, run.Succeeded, run.Failed)
+		done(run)
+	}
+
+	var err error
+	if scheduled := c.scheduled(cron, now); !scheduled.IsZero() {
+		switch {
+		case cron.Spec.ConcurrencyPolicy == otev1.CronClusterTaskForbidConcurrent && len(active) != 0:
+			klog.V(3).Infof("cronclustertask %s forbids concurrent runs, skip run of %v", cron.Name, scheduled)
+		default:
+			if cron.Spec.ConcurrencyPolicy == otev1.CronClusterTaskReplaceConcurrent {
+				for _, run := range active {
+					klog.Infof("replace run %s of cronclustertask %s", run.Name, cron.Name)
+					c.deleteRun(run.Name)
+					run.Reason = RunReasonReplaced
+					done(run)
+				}
+				active = nil
+			}
+			var run *otev1.CronClusterTaskRun
+			if run, err = c.createRun(cron, scheduled); err == nil {
+				active = append(active, *run)
+				status.LastScheduleTime = scheduled.Unix()
+			}
+		}
+	}
+
+	status.Active = active
+	var pruned []otev1.CronClusterTaskRun
+	status.History, pruned = pruneHistory(history,
+		limit(cron.Spec.SuccessfulRunsHistoryLimit, defaultSuccessfulRunsHistoryLimit),
+		limit(cron.Spec.FailedRunsHistoryLimit, defaultFailedRunsHistoryLimit))
+	for _, run := range pruned {
+		c.deleteRun(run.Name)
+	}
+	if !equality.Semantic.DeepEqual(&cron.Status, status) {
+		updated := cron.DeepCopy()
+		updated.Status = *status
+		if _, err := c.oteClient.OteV1().CronClusterTasks(otev1.ClusterNamespace).Update(updated); err != nil {
+			return fmt.Errorf("update status failed: %v", err)
+		}
+	}
+	return err
+}
+
+// scheduled returns the latest time scheduled after the last run until now,
+// zero time if none or it is missed over starting deadline. Runs missed before it are skipped.
+func (c *CronTaskController) scheduled(cron *otev1.CronClusterTask, now time.Time) time.Time {
+	if cron.Spec.Suspend {
+		return time.Time{}
+	}
+	s, err := parseSchedule(cron.Spec.Schedule)
+	if err != nil {
+		klog.Errorf("cronclustertask %s: %v", cron.Name, err)
+		return time.Time{}
+	}
+	from := cron.CreationTimestamp.Time
+	if cron.Status.LastScheduleTime != 0 {
+		from = time.Unix(cron.Status.LastScheduleTime, 0)
+	}
+	deadline := time.Duration(cron.Spec.StartingDeadlineSeconds) * time.Second
+	if deadline > 0 && from.Before(now.Add(-deadline)) {
+		from = now.Add(-deadline - time.Minute)
+	}
+	if from.IsZero() {
+		from = now.Add(-time.Minute)
+	}
+	from = from.In(now.Location())
+	var latest time.Time
+	for t := s.next(from); !t.IsZero() && !t.After(now); t = s.next(t) {
+		latest = t
+	}
+	if deadline > 0 && now.Sub(latest) > deadline {
+		return time.Time{}
+	}
+	return latest
+}
+
+// createRun creates the ClusterController of cron scheduled at t, and returns the run.
+func (c *CronTaskController) createRun(cron *otev1.CronClusterTask, t time.Time) (*otev1.CronClusterTaskRun, error) {
+	clusters, err := c.selectClusters(cron.Spec.Template.ClusterSelector)
+	if err != nil {
+		return nil, err
+	}
+	run := &otev1.CronClusterTaskRun{
+		Name:         fmt.Sprintf("%s-%d", cron.Name, t.Unix()),
+		ScheduleTime: t.Unix(),
+		Clusters:     clusters,
+	}
+	cc := &otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      run.Name,
+			Namespace: otev1.ClusterNamespace,
+			Labels:    map[string]string{otev1.CronClusterTaskLabel: cron.Name},
+		},
+		Spec: *cron.Spec.Template.DeepCopy(),
+	}
+	_, err = c.oteClient.OteV1().ClusterControllers(otev1.ClusterNamespace).Create(cc)
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return nil, fmt.Errorf("create run %s failed: %v", run.Name, err)
+	}
+	klog.Infof("create run %s of cronclustertask %s for %d clusters", run.Name, cron.Name, len(clusters))
+	return run, nil
+}
+
+// getRun gets the ClusterController of run name, from apiserver if not in cache yet.
+func (c *CronTaskController) getRun(name string) (*otev1.ClusterController, error) {
+	cc, err := c.ccLister.ClusterControllers(otev1.ClusterNamespace).Get(name)
+	if errors.IsNotFound(err) {
+		return c.oteClient.OteV1().ClusterControllers(otev1.ClusterNamespace).Get(name, metav1.GetOptions{})
+	}
+	return cc, err
+}
+
+func (c *CronTaskController) deleteRun(name string) {
+	err := c.oteClient.OteV1().ClusterControllers(otev1.ClusterNamespace).Delete(name, &metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		klog.Errorf("delete run %s failed: %v", name, err)
+	}
+}
+
+// selectClusters returns names of online clusters matched by selector, sorted by name.
+func (c *CronTaskController) selectClusters(selector string) ([]string, error) {
+	list, err := c.clusterLister.Clusters(otev1.ClusterNamespace).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("list clusters failed: %v", err)
+	}
+	s := clusterselector.NewSelector(selector)
+	var ret []string
+	for _, cluster := range list {
+		if cluster.Status.Status == otev1.ClusterStatusOnline && s.Has(cluster.Name) {
+			ret = append(ret, cluster.Name)
+		}
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// countResults counts results of clusters of run in cc, and returns true if all clusters responded.
+// Clusters not responded are failed if cc is nil.
+func countResults(run *otev1.CronClusterTaskRun, cc *otev1.ClusterController) bool {
+	run.Succeeded, run.Failed = 0, 0
+	responded := true
+	for _, cluster := range run.Clusters {
+		var status otev1.ClusterControllerStatus
+		var ok bool
+		if cc != nil {
+			status, ok = cc.Status[cluster]
+		}
+		switch {
+		case !ok:
+			responded = false
+			if cc == nil {
+				run.Failed++
+			}
+		case status.StatusCode >= http.StatusOK && status.StatusCode < http.StatusMultipleChoices:
+			run.Succeeded++
+		default:
+			run.Failed++
+		}
+	}
+	return responded
+}
+
+func failed(run *otev1.CronClusterTaskRun) bool {
+	return run.Failed != 0 || run.Reason != ""
+}
+
+// pruneHistory keeps the latest runs in history of succeeded and failed in limits,
+// and returns the runs kept and pruned.
+func pruneHistory(history []otev1.CronClusterTaskRun,
+	succeededLimit, failedLimit int) ([]otev1.CronClusterTaskRun, []otev1.CronClusterTaskRun) {
+	var kept, pruned []otev1.CronClusterTaskRun
+	succeeded, failures := 0, 0
+	for _, run := range history {
+		if failed(&run) {
+			failures++
+			if failures > failedLimit {
+				pruned = append(pruned, run)
+				continue
+			}
+		} else {
+			succeeded++
+			if succeeded > succeededLimit {
+				pruned = append(pruned, run)
+				continue
+			}
+		}
+		kept = append(kept, run)
+	}
+	return kept, pruned
+}
+
+func limit(l *int32, defaultLimit int) int {
+	if l == nil {
+		return defaultLimit
+	}
+	return int(*l)
+}
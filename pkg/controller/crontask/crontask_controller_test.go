@@ -0,0 +1,207 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crontask
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	oteinformer "github.com/baidu/ote-stack/pkg/generated/informers/externalversions"
+	otefake "github.com/baidu/ote-stack/pkg/k8sclient/fake"
+)
+
+var testNow = time.Date(2019, 7, 1, 10, 0, 30, 0, time.UTC)
+
+// newFakeCronTaskController returns a controller with indexers of clustercontrollers and clusters.
+func newFakeCronTaskController() (*CronTaskController, cache.Indexer, cache.Indexer) {
+	oteClient := otefake.NewSimpleClientset()
+	factory := oteinformer.NewSharedInformerFactory(oteClient, 0)
+	informers := factory.Ote().V1()
+	c := &CronTaskController{
+		oteClient:     oteClient,
+		cronLister:    informers.CronClusterTasks().Lister(),
+		ccLister:      informers.ClusterControllers().Lister(),
+		clusterLister: informers.Clusters().Lister(),
+		now:           func() time.Time { return testNow },
+	}
+	for _, name := range []string{"c1", "c2", "c3"} {
+		status := otev1.ClusterStatusOnline
+		if name == "c3" {
+			status = otev1.ClusterStatusOffline
+		}
+		informers.Clusters().Informer().GetIndexer().Add(&otev1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: otev1.ClusterNamespace},
+			Status:     otev1.ClusterStatus{Status: status},
+		})
+	}
+	return c, informers.ClusterControllers().Informer().GetIndexer(),
+		informers.CronClusterTasks().Informer().GetIndexer()
+}
+
+func newFakeCron(policy string) *otev1.CronClusterTask {
+	return &otev1.CronClusterTask{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "backup",
+			Namespace:         otev1.ClusterNamespace,
+			CreationTimestamp: metav1.NewTime(testNow.Add(-time.Hour)),
+		},
+		Spec: otev1.CronClusterTaskSpec{
+			Schedule:          "0 * * * *",
+			ConcurrencyPolicy: policy,
+			Template: otev1.ClusterControllerSpec{
+				ClusterSelector: "c1,c2,c3",
+				Destination:     "api",
+				Method:          http.MethodGet,
+				URL:             "/api/v1/pods",
+			},
+		},
+	}
+}
+
+// syncCron syncs cron and returns it updated.
+func syncCron(t *testing.T, c *CronTaskController, crons cache.Indexer, cron *otev1.CronClusterTask) *otev1.CronClusterTask {
+	client := c.oteClient.OteV1().CronClusterTasks(otev1.ClusterNamespace)
+	if _, err := client.Get(cron.Name, metav1.GetOptions{}); err != nil {
+		client.Create(cron)
+	} else {
+		client.Update(cron)
+	}
+	assert.Nil(t, c.sync(cron))
+	updated, err := client.Get(cron.Name, metav1.GetOptions{})
+	assert.Nil(t, err)
+	crons.Update(updated)
+	return updated
+}
+
+func TestSyncCreateRun(t *testing.T) {
+	c, ccs, crons := newFakeCronTaskController()
+	cron := syncCron(t, c, crons, newFakeCron(otev1.CronClusterTaskAllowConcurrent))
+
+	assert.Equal(t, time.Date(2019, 7, 1, 10, 0, 0, 0, time.UTC).Unix(), cron.Status.LastScheduleTime)
+	assert.Len(t, cron.Status.Active, 1)
+	run := cron.Status.Active[0]
+	assert.Equal(t, "backup-1561975200", run.Name)
+	assert.Equal(t, []string{"c1", "c2"}, run.Clusters)
+	cc, err := c.oteClient.OteV1().ClusterControllers(otev1.ClusterNamespace).Get(run.Name, metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "backup", cc.Labels[otev1.CronClusterTaskLabel])
+	assert.Equal(t, "/api/v1/pods", cc.Spec.URL)
+
+	// not scheduled again until next hour.
+	cron = syncCron(t, c, crons, cron)
+	assert.Len(t, cron.Status.Active, 1)
+
+	// run is done when all clusters responded.
+	cc.Status = map[string]otev1.ClusterControllerStatus{"c1": {StatusCode: http.StatusOK}}
+	ccs.Add(cc)
+	cron = syncCron(t, c, crons, cron)
+	assert.Len(t, cron.Status.Active, 1)
+	assert.Equal(t, 1, cron.Status.Active[0].Succeeded)
+
+	cc.Status["c2"] = otev1.ClusterControllerStatus{StatusCode: http.StatusGatewayTimeout,
+		Reason: otev1.ClusterControllerStatusTimedOut}
+	ccs.Update(cc)
+	cron = syncCron(t, c, crons, cron)
+	assert.Empty(t, cron.Status.Active)
+	assert.Len(t, cron.Status.History, 1)
+	assert.Equal(t, 1, cron.Status.History[0].Succeeded)
+	assert.Equal(t, 1, cron.Status.History[0].Failed)
+	assert.Equal(t, testNow.Unix(), cron.Status.History[0].CompletionTime)
+}
+
+func TestSyncConcurrencyPolicy(t *testing.T) {
+	for _, policy := range []string{otev1.CronClusterTaskAllowConcurrent,
+		otev1.CronClusterTaskForbidConcurrent, otev1.CronClusterTaskReplaceConcurrent} {
+		c, ccs, crons := newFakeCronTaskController()
+		cron := syncCron(t, c, crons, newFakeCron(policy))
+		cc, err := c.oteClient.OteV1().ClusterControllers(otev1.ClusterNamespace).Get(
+			cron.Status.Active[0].Name, metav1.GetOptions{})
+		assert.Nil(t, err)
+		ccs.Add(cc)
+
+		c.now = func() time.Time { return testNow.Add(time.Hour) }
+		cron = syncCron(t, c, crons, cron)
+		switch policy {
+		case otev1.CronClusterTaskAllowConcurrent:
+			assert.Len(t, cron.Status.Active, 2)
+		case otev1.CronClusterTaskForbidConcurrent:
+			assert.Len(t, cron.Status.Active, 1)
+			assert.Equal(t, "backup-1561975200", cron.Status.Active[0].Name)
+		case otev1.CronClusterTaskReplaceConcurrent:
+			assert.Len(t, cron.Status.Active, 1)
+			assert.Equal(t, "backup-1561978800", cron.Status.Active[0].Name)
+			assert.Equal(t, RunReasonReplaced, cron.Status.History[0].Reason)
+			_, err := c.oteClient.OteV1().ClusterControllers(otev1.ClusterNamespace).Get(
+				"backup-1561975200", metav1.GetOptions{})
+			assert.NotNil(t, err)
+		}
+	}
+}
+
+func TestSyncSuspendAndDeadline(t *testing.T) {
+	c, _, crons := newFakeCronTaskController()
+	cron := newFakeCron(otev1.CronClusterTaskAllowConcurrent)
+	cron.Spec.Suspend = true
+	cron = syncCron(t, c, crons, cron)
+	assert.Empty(t, cron.Status.Active)
+
+	// scheduled 30s before, missed over deadline.
+	cron.Spec.Suspend = false
+	cron.Spec.StartingDeadlineSeconds = 10
+	cron = syncCron(t, c, crons, cron)
+	assert.Empty(t, cron.Status.Active)
+	assert.Equal(t, int64(0), cron.Status.LastScheduleTime)
+
+	cron.Spec.StartingDeadlineSeconds = 60
+	cron = syncCron(t, c, crons, cron)
+	assert.Len(t, cron.Status.Active, 1)
+}
+
+func TestSyncDeletedRun(t *testing.T) {
+	c, _, crons := newFakeCronTaskController()
+	cron := newFakeCron(otev1.CronClusterTaskAllowConcurrent)
+	cron.Status.LastScheduleTime = testNow.Unix()
+	cron.Status.Active = []otev1.CronClusterTaskRun{{Name: "backup-1", Clusters: []string{"c1"}}}
+	cron = syncCron(t, c, crons, cron)
+	assert.Empty(t, cron.Status.Active)
+	assert.Equal(t, RunReasonDeleted, cron.Status.History[0].Reason)
+	assert.Equal(t, 1, cron.Status.History[0].Failed)
+}
+
+func TestPruneHistory(t *testing.T) {
+	history := []otev1.CronClusterTaskRun{
+		{Name: "r6", Succeeded: 1},
+		{Name: "r5", Failed: 1},
+		{Name: "r4", Succeeded: 1},
+		{Name: "r3", Reason: RunReasonReplaced},
+		{Name: "r2", Succeeded: 1},
+		{Name: "r1", Succeeded: 1},
+	}
+	kept, pruned := pruneHistory(history, 2, 1)
+	assert.Equal(t, []otev1.CronClusterTaskRun{history[0], history[1], history[2]}, kept)
+	assert.Equal(t, []otev1.CronClusterTaskRun{history[3], history[4], history[5]}, pruned)
+
+	kept, pruned = pruneHistory(history, 0, 0)
+	assert.Empty(t, kept)
+	assert.Len(t, pruned, 6)
+}
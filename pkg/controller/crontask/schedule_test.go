@@ -0,0 +1,61 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crontask
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSchedule(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *",
+		"* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@every 1m"} {
+		_, err := parseSchedule(spec)
+		assert.NotNil(t, err, spec)
+	}
+	for _, spec := range []string{"* * * * *", "0,30 1-5/2 ? * 7", "@daily", "@hourly", "*/15 * 1 1 *"} {
+		_, err := parseSchedule(spec)
+		assert.Nil(t, err, spec)
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	base := time.Date(2019, 7, 1, 10, 20, 30, 0, time.UTC) // Monday
+	casesTest := []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2019, 7, 1, 10, 21, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2019, 7, 1, 10, 30, 0, 0, time.UTC)},
+		{"0 9 * * *", time.Date(2019, 7, 2, 9, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2019, 7, 1, 11, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2019, 7, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2019, 7, 7, 0, 0, 0, 0, time.UTC)},
+		// day of month or day of week.
+		{"0 0 15 * 3", time.Date(2019, 7, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	}
+	for _, c := range casesTest {
+		s, err := parseSchedule(c.spec)
+		assert.Nil(t, err, c.spec)
+		assert.Equal(t, c.next, s.next(base), c.spec)
+	}
+}
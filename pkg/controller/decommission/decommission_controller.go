@@ -0,0 +1,392 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package decommission decommissions clusters annotated by otectl decommission before their sites shut down,
+// by a final full report, migration hints of workloads, and a clean unregister from center.
+package decommission
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/clusterselector"
+	"github.com/baidu/ote-stack/pkg/controllermanager"
+	"github.com/baidu/ote-stack/pkg/errorkind"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned"
+	otelisters "github.com/baidu/ote-stack/pkg/generated/listers/ote/v1"
+	"github.com/baidu/ote-stack/pkg/reporter"
+)
+
+// Phases of decommission, set to otev1.ClusterDecommissionPhaseAnnotation.
+const (
+	// PhaseBlocked waits for clusters routed through the cluster to move to other parents.
+	PhaseBlocked = "Blocked"
+	// PhaseReporting waits for the final full report of the cluster.
+	PhaseReporting = "Reporting"
+	// PhaseUnregistering removes resources mirrored from the cluster, and the cluster crd at last.
+	PhaseUnregistering = "Unregistering"
+
+	// HintsConfigMapPrefix starts names of configmaps of migration hints in otev1.ClusterNamespace.
+	HintsConfigMapPrefix = "ote-decommission-"
+	// HintsKey is the key of migration hints in json in the configmap.
+	HintsKey = "hints.json"
+
+	// phaseTimeAnnotation is the time the current phase started in RFC3339.
+	phaseTimeAnnotation = "ote.baidu.com/decommission-phase-time"
+)
+
+var (
+	// syncInterval is the interval to check clusters to decommission.
+	syncInterval = 5 * time.Second
+	// reportGracePeriod is the time to wait for the final full report of an online cluster.
+	reportGracePeriod = 30 * time.Second
+)
+
+// MigrationHint suggests clusters to take over a workload mirrored from a cluster decommissioned.
+type MigrationHint struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	// Name is the name of the workload in the cluster decommissioned.
+	Name     string `json:"name"`
+	Replicas int32  `json:"replicas,omitempty"`
+	// Candidates are online clusters matched by the selector of migration.
+	Candidates []string `json:"candidates"`
+}
+
+// mirroredResource lists and deletes a kind of resources mirrored from edge clusters.
+type mirroredResource struct {
+	kind   string
+	list   func(opts metav1.ListOptions) (runtime.Object, error)
+	delete func(namespace, name string) error
+}
+
+// DecommissionController decommissions clusters annotated with otev1.ClusterDecommissionAnnotation.
+type DecommissionController struct {
+	oteClient     oteclient.Interface
+	k8sClient     kubernetes.Interface
+	clusterLister otelisters.ClusterLister
+	sendChan      chan clustermessage.ClusterMessage
+	resources     []mirroredResource
+	now           func() time.Time
+}
+
+// InitDecommissionController inits decommission controller.
+func InitDecommissionController(ctx *controllermanager.ControllerContext) error {
+	c := &DecommissionController{
+		oteClient:     ctx.OteClient,
+		k8sClient:     ctx.K8sClient,
+		clusterLister: ctx.OteInformerFactory.Ote().V1().Clusters().Lister(),
+		sendChan:      ctx.PublishChan,
+		resources:     mirroredResources(ctx.K8sClient),
+		now:           time.Now,
+	}
+	go wait.Until(c.syncAll, syncInterval, ctx.StopChan)
+	return nil
+}
+
+// mirroredResources returns the kinds of resources mirrored by upstream processor.
+func mirroredResources(cl kubernetes.Interface) []mirroredResource {
+	opts := &metav1.DeleteOptions{}
+	return []mirroredResource{
+		{
+			kind: "pod",
+			list: func(o metav1.ListOptions) (runtime.Object, error) { return cl.CoreV1().Pods("").List(o) },
+			delete: func(namespace, name string) error {
+				return cl.CoreV1().Pods(namespace).Delete(name, opts)
+			},
+		},
+		{
+			kind: "deployment",
+			list: func(o metav1.ListOptions) (runtime.Object, error) { return cl.AppsV1().Deployments("").List(o) },
+			delete: func(namespace, name string) error {
+				return cl.AppsV1().Deployments(namespace).Delete(name, opts)
+			},
+		},
+		{
+			kind: "daemonset",
+			list: func(o metav1.ListOptions) (runtime.Object, error) { return cl.AppsV1().DaemonSets("").List(o) },
+			delete: func(namespace, name string) error {
+				return cl.AppsV1().DaemonSets(namespace).Delete(name, opts)
+			},
+		},
+		{
+			kind: "service",
+			list: func(o metav1.ListOptions) (runtime.Object, error) { return cl.CoreV1().Services("").List(o) },
+			delete: func(namespace, name string) error {
+				return cl.CoreV1().Services(namespace).Delete(name, opts)
+			},
+		},
+		{
+			kind: "endpoints",
+			list: func(o metav1.ListOptions) (runtime.Object, error) { return cl.CoreV1().Endpoints("").List(o) },
+			delete: func(namespace, name string) error {
+				return cl.CoreV1().Endpoints(namespace).Delete(name, opts)
+			},
+		},
+		{
+			kind: "event",
+			list: func(o metav1.ListOptions) (runtime.Object, error) { return cl.CoreV1().Events("").List(o) },
+			delete: func(namespace, name string) error {
+				return cl.CoreV1().Events(namespace).Delete(name, opts)
+			},
+		},
+		{
+			// nodes are the last, so that pods are not evicted from nodes gone.
+			kind: "node",
+			list: func(o metav1.ListOptions) (runtime.Object, error) { return cl.CoreV1().Nodes().List(o) },
+			delete: func(namespace, name string) error {
+				return cl.CoreV1().Nodes().Delete(name, opts)
+			},
+		},
+	}
+}
+
+func (c *DecommissionController) syncAll() {
+	clusters, err := c.clusterLister.Clusters(otev1.ClusterNamespace).List(labels.Everything())
+	if err != nil {
+		klog.Errorf("list clusters failed: %v", err)
+		return
+	}
+	for _, cluster := range clusters {
+		if cluster.Annotations[otev1.ClusterDecommissionAnnotation] == "" {
+			continue
+		}
+		if err := c.sync(cluster.DeepCopy(), clusters); err != nil {
+			klog.Errorf("decommission cluster %s failed: %v", cluster.Name, err)
+		}
+	}
+}
+
+// sync moves decommission of cluster to the next phase if the current one is done.
+func (c *DecommissionController) sync(cluster *otev1.Cluster, clusters []*otev1.Cluster) error {
+	now := c.now()
+	switch phase := cluster.Annotations[otev1.ClusterDecommissionPhaseAnnotation]; phase {
+	case "", PhaseBlocked:
+		if err := checkLeaf(cluster.Name, clusters); err != nil {
+			return c.setPhase(cluster, PhaseBlocked, err.Error(), now)
+		}
+		message := "cluster is offline, no final report"
+		if cluster.Status.Status == otev1.ClusterStatusOnline {
+			if err := c.requestReport(cluster.Name); err != nil {
+				return err
+			}
+			message = "final full report requested"
+		}
+		klog.Infof("decommission cluster %s: %s", cluster.Name, message)
+		return c.setPhase(cluster, PhaseReporting, message, now)
+	case PhaseReporting:
+		started, _ := time.Parse(time.RFC3339, cluster.Annotations[phaseTimeAnnotation])
+		if cluster.Status.Status == otev1.ClusterStatusOnline && now.Sub(started) < reportGracePeriod {
+			return nil
+		}
+		if selector := cluster.Annotations[otev1.ClusterMigrateToAnnotation]; selector != "" {
+			if err := c.writeHints(cluster.Name, selector, clusters); err != nil {
+				return err
+			}
+		}
+		return c.setPhase(cluster, PhaseUnregistering, "remove resources mirrored", now)
+	case PhaseUnregistering:
+		return c.unregister(cluster.Name)
+	default:
+		return fmt.Errorf("unknown phase %s", phase)
+	}
+}
+
+// setPhase records phase of decommission of cluster with message.
+func (c *DecommissionController) setPhase(cluster *otev1.Cluster, phase, message string, now time.Time) error {
+	if cluster.Annotations[otev1.ClusterDecommissionPhaseAnnotation] == phase &&
+		cluster.Annotations[otev1.ClusterDecommissionMessageAnnotation] == message {
+		return nil
+	}
+	cluster.Annotations[otev1.ClusterDecommissionPhaseAnnotation] = phase
+	cluster.Annotations[otev1.ClusterDecommissionMessageAnnotation] = message
+	cluster.Annotations[phaseTimeAnnotation] = now.UTC().Format(time.RFC3339)
+	if _, err := c.oteClient.OteV1().Clusters(otev1.ClusterNamespace).Update(cluster); err != nil {
+		return fmt.Errorf("update phase %s failed: %v", phase, err)
+	}
+	return nil
+}
+
+// requestReport asks cluster to report all resources again.
+func (c *DecommissionController) requestReport(name string) error {
+	data := &clustermessage.ControllerTask{
+		Destination: otev1.ClusterControllerDestResync,
+		Method:      http.MethodPut,
+	}
+	head := &clustermessage.MessageHead{
+		ClusterSelector: exactSelector(name),
+		Command:         clustermessage.CommandType_ControlReq,
+	}
+	msg, err := data.ToClusterMessage(head)
+	if err != nil {
+		return err
+	}
+	c.sendChan <- *msg
+	return nil
+}
+
+// writeHints writes migration hints of workloads mirrored from cluster to clusters selected.
+func (c *DecommissionController) writeHints(name, selector string, clusters []*otev1.Cluster) error {
+	s := clusterselector.NewSelector(selector)
+	candidates := []string{}
+	for _, cluster := range clusters {
+		if cluster.Name == name || cluster.Status.Status != otev1.ClusterStatusOnline ||
+			cluster.Annotations[otev1.ClusterDecommissionAnnotation] != "" || !s.Has(cluster.Name) {
+			continue
+		}
+		candidates = append(candidates, cluster.Name)
+	}
+	sort.Strings(candidates)
+
+	opts := metav1.ListOptions{LabelSelector: reporter.ClusterLabel + "=" + name}
+	hints := []MigrationHint{}
+	deployments, err := c.k8sClient.AppsV1().Deployments("").List(opts)
+	if err != nil {
+		return fmt.Errorf("list deployments failed: %v", err)
+	}
+	for _, d := range deployments.Items {
+		hint := MigrationHint{Kind: "Deployment", Namespace: d.Namespace,
+			Name: unmirroredName(d.Name, name), Candidates: candidates}
+		if d.Spec.Replicas != nil {
+			hint.Replicas = *d.Spec.Replicas
+		}
+		hints = append(hints, hint)
+	}
+	daemonsets, err := c.k8sClient.AppsV1().DaemonSets("").List(opts)
+	if err != nil {
+		return fmt.Errorf("list daemonsets failed: %v", err)
+	}
+	for _, d := range daemonsets.Items {
+		hints = append(hints, MigrationHint{Kind: "DaemonSet", Namespace: d.Namespace,
+			Name: unmirroredName(d.Name, name), Candidates: candidates})
+	}
+	data, err := json.MarshalIndent(hints, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	klog.Infof("decommission cluster %s: %d workloads to migrate to %d clusters", name, len(hints), len(candidates))
+	configMaps := c.k8sClient.CoreV1().ConfigMaps(otev1.ClusterNamespace)
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      HintsConfigMapPrefix + name,
+			Namespace: otev1.ClusterNamespace,
+		},
+		Data: map[string]string{HintsKey: string(data)},
+	}
+	if _, err := configMaps.Create(cm); err != nil {
+		if !errors.IsAlreadyExists(err) {
+			return fmt.Errorf("create configmap of hints failed: %v", err)
+		}
+		if _, err := configMaps.Update(cm); err != nil {
+			return fmt.Errorf("update configmap of hints failed: %v", err)
+		}
+	}
+	return nil
+}
+
+// unregister removes resources mirrored from cluster name and its inventory, and the cluster crd at last.
+func (c *DecommissionController) unregister(name string) error {
+	opts := metav1.ListOptions{LabelSelector: reporter.ClusterLabel + "=" + name}
+	var failed []string
+	for _, r := range c.resources {
+		list, err := r.list(opts)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("list %s: %v", r.kind, err))
+			continue
+		}
+		objs, err := meta.ExtractList(list)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("list %s: %v", r.kind, err))
+			continue
+		}
+		for _, obj := range objs {
+			accessor, err := meta.Accessor(obj)
+			if err != nil {
+				continue
+			}
+			err = r.delete(accessor.GetNamespace(), accessor.GetName())
+			if err != nil && !errors.IsNotFound(err) {
+				failed = append(failed, fmt.Sprintf("delete %s %s/%s: %v",
+					r.kind, accessor.GetNamespace(), accessor.GetName(), err))
+			}
+		}
+		if len(objs) != 0 {
+			klog.Infof("decommission cluster %s: %d %s removed", name, len(objs), r.kind)
+		}
+	}
+	if len(failed) != 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	err := c.oteClient.OteV1().ClusterInventories(otev1.ClusterNamespace).Delete(name, &metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("delete inventory failed: %v", err)
+	}
+	err = c.oteClient.OteV1().Clusters(otev1.ClusterNamespace).Delete(name, &metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("delete cluster failed: %v", err)
+	}
+	klog.Infof("cluster %s is decommissioned", name)
+	return nil
+}
+
+// checkLeaf returns an error of kind controllermanager.ErrNotLeaf if clusters are connected through name.
+func checkLeaf(name string, clusters []*otev1.Cluster) error {
+	if childs := childClusters(name, clusters); len(childs) != 0 {
+		return errorkind.Errorf(controllermanager.ErrNotLeaf,
+			"clusters %s are connected through it", strings.Join(childs, ","))
+	}
+	return nil
+}
+
+// childClusters returns names of clusters whose parent is name, sorted.
+func childClusters(name string, clusters []*otev1.Cluster) []string {
+	var childs []string
+	for _, cluster := range clusters {
+		if cluster.Status.ParentName == name && cluster.Status.Status == otev1.ClusterStatusOnline {
+			childs = append(childs, cluster.Name)
+		}
+	}
+	sort.Strings(childs)
+	return childs
+}
+
+// unmirroredName returns the name of a resource in cluster by its name mirrored in center.
+func unmirroredName(mirrored, cluster string) string {
+	return strings.TrimSuffix(mirrored, controllermanager.UniqueResourceNameSeparator+cluster)
+}
+
+// exactSelector returns the selector only matching cluster name.
+func exactSelector(name string) string {
+	return "^" + regexp.QuoteMeta(name) + "$"
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package decommission
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/clusterselector"
+	"github.com/baidu/ote-stack/pkg/controllermanager"
+	"github.com/baidu/ote-stack/pkg/errorkind"
+	otefake "github.com/baidu/ote-stack/pkg/k8sclient/fake"
+	"github.com/baidu/ote-stack/pkg/reporter"
+)
+
+var testNow = time.Date(2019, 11, 1, 10, 0, 0, 0, time.UTC)
+
+func newCluster(name, parent, status string) *otev1.Cluster {
+	return &otev1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: otev1.ClusterNamespace},
+		Status:     otev1.ClusterStatus{ParentName: parent, Status: status},
+	}
+}
+
+func mirrored(name, cluster string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      name + "-" + cluster,
+		Namespace: "default",
+		Labels:    map[string]string{reporter.ClusterLabel: cluster},
+	}
+}
+
+func newFakeDecommissionController(objs ...*otev1.Cluster) *DecommissionController {
+	replicas := int32(3)
+	k8sClient := k8sfake.NewSimpleClientset(
+		&appsv1.Deployment{ObjectMeta: mirrored("web", "c1"), Spec: appsv1.DeploymentSpec{Replicas: &replicas}},
+		&appsv1.DaemonSet{ObjectMeta: mirrored("agent", "c1")},
+		&appsv1.Deployment{ObjectMeta: mirrored("web", "c2")},
+		&corev1.Pod{ObjectMeta: mirrored("web-0", "c1")},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "n1-c1", Labels: map[string]string{reporter.ClusterLabel: "c1"}}},
+	)
+	oteClient := otefake.NewSimpleClientset()
+	for _, obj := range objs {
+		oteClient.OteV1().Clusters(otev1.ClusterNamespace).Create(obj)
+	}
+	return &DecommissionController{
+		oteClient: oteClient,
+		k8sClient: k8sClient,
+		sendChan:  make(chan clustermessage.ClusterMessage, 1),
+		resources: mirroredResources(k8sClient),
+		now:       func() time.Time { return testNow },
+	}
+}
+
+// syncCluster syncs cluster name and returns it updated, nil if deleted.
+func syncCluster(t *testing.T, c *DecommissionController, name string, clusters []*otev1.Cluster) *otev1.Cluster {
+	cluster, err := c.oteClient.OteV1().Clusters(otev1.ClusterNamespace).Get(name, metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Nil(t, c.sync(cluster, clusters))
+	cluster, err = c.oteClient.OteV1().Clusters(otev1.ClusterNamespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil
+	}
+	return cluster
+}
+
+func TestDecommission(t *testing.T) {
+	c1 := newCluster("c1", "root", otev1.ClusterStatusOnline)
+	c1.Annotations = map[string]string{
+		otev1.ClusterDecommissionAnnotation: testNow.Format(time.RFC3339),
+		otev1.ClusterMigrateToAnnotation:    "c.*",
+	}
+	c2 := newCluster("c2", "c1", otev1.ClusterStatusOnline)
+	c3 := newCluster("c3", "root", otev1.ClusterStatusOffline)
+	c := newFakeDecommissionController(c1, c2, c3)
+
+	// blocked by child.
+	cluster := syncCluster(t, c, "c1", []*otev1.Cluster{c1, c2, c3})
+	assert.Equal(t, PhaseBlocked, cluster.Annotations[otev1.ClusterDecommissionPhaseAnnotation])
+	assert.Contains(t, cluster.Annotations[otev1.ClusterDecommissionMessageAnnotation], "c2")
+
+	// final report requested.
+	c2.Status.ParentName = "root"
+	cluster = syncCluster(t, c, "c1", []*otev1.Cluster{c1, c2, c3})
+	assert.Equal(t, PhaseReporting, cluster.Annotations[otev1.ClusterDecommissionPhaseAnnotation])
+	msg := <-c.sendChan
+	assert.True(t, clusterselector.NewSelector(msg.Head.ClusterSelector).Has("c1"))
+	assert.False(t, clusterselector.NewSelector(msg.Head.ClusterSelector).Has("c10"))
+	task := &clustermessage.ControllerTask{}
+	assert.Nil(t, proto.Unmarshal(msg.Body, task))
+	assert.Equal(t, otev1.ClusterControllerDestResync, task.Destination)
+	assert.Equal(t, http.MethodPut, task.Method)
+
+	// wait for the report.
+	cluster = syncCluster(t, c, "c1", []*otev1.Cluster{c1, c2, c3})
+	assert.Equal(t, PhaseReporting, cluster.Annotations[otev1.ClusterDecommissionPhaseAnnotation])
+
+	c.now = func() time.Time { return testNow.Add(reportGracePeriod) }
+	cluster = syncCluster(t, c, "c1", []*otev1.Cluster{c1, c2, c3})
+	assert.Equal(t, PhaseUnregistering, cluster.Annotations[otev1.ClusterDecommissionPhaseAnnotation])
+	cm, err := c.k8sClient.CoreV1().ConfigMaps(otev1.ClusterNamespace).Get(HintsConfigMapPrefix+"c1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	var hints []MigrationHint
+	assert.Nil(t, json.Unmarshal([]byte(cm.Data[HintsKey]), &hints))
+	assert.ElementsMatch(t, []MigrationHint{
+		{Kind: "Deployment", Namespace: "default", Name: "web", Replicas: 3, Candidates: []string{"c2"}},
+		{Kind: "DaemonSet", Namespace: "default", Name: "agent", Candidates: []string{"c2"}},
+	}, hints)
+
+	// mirrored resources of c1 and the cluster are removed.
+	assert.Nil(t, syncCluster(t, c, "c1", []*otev1.Cluster{c1, c2, c3}))
+	list, err := c.k8sClient.AppsV1().Deployments("").List(metav1.ListOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, list.Items, 1)
+	assert.Equal(t, "web-c2", list.Items[0].Name)
+	pods, err := c.k8sClient.CoreV1().Pods("").List(metav1.ListOptions{})
+	assert.Nil(t, err)
+	assert.Empty(t, pods.Items)
+	nodes, err := c.k8sClient.CoreV1().Nodes().List(metav1.ListOptions{})
+	assert.Nil(t, err)
+	assert.Empty(t, nodes.Items)
+}
+
+func TestDecommissionOffline(t *testing.T) {
+	c3 := newCluster("c3", "root", otev1.ClusterStatusOffline)
+	c3.Annotations = map[string]string{otev1.ClusterDecommissionAnnotation: testNow.Format(time.RFC3339)}
+	c := newFakeDecommissionController(c3)
+
+	cluster := syncCluster(t, c, "c3", []*otev1.Cluster{c3})
+	assert.Equal(t, PhaseReporting, cluster.Annotations[otev1.ClusterDecommissionPhaseAnnotation])
+	assert.Empty(t, c.sendChan)
+
+	// no wait for offline cluster, and no hints without migration.
+	cluster = syncCluster(t, c, "c3", []*otev1.Cluster{c3})
+	assert.Equal(t, PhaseUnregistering, cluster.Annotations[otev1.ClusterDecommissionPhaseAnnotation])
+	_, err := c.k8sClient.CoreV1().ConfigMaps(otev1.ClusterNamespace).Get(HintsConfigMapPrefix+"c3", metav1.GetOptions{})
+	assert.NotNil(t, err)
+
+	cluster.Annotations[otev1.ClusterDecommissionPhaseAnnotation] = "Unknown"
+	assert.NotNil(t, c.sync(cluster, nil))
+}
+
+func TestCheckLeaf(t *testing.T) {
+	clusters := []*otev1.Cluster{
+		newCluster("c1", "root", otev1.ClusterStatusOnline),
+		newCluster("c2", "c1", otev1.ClusterStatusOnline),
+		newCluster("c3", "c1", otev1.ClusterStatusOnline),
+		// offline childs are not connected through c2.
+		newCluster("c4", "c2", otev1.ClusterStatusOffline),
+	}
+	err := checkLeaf("c1", clusters)
+	assert.True(t, errorkind.Is(err, controllermanager.ErrNotLeaf))
+	assert.Equal(t, "clusters c2,c3 are connected through it", err.Error())
+	assert.Nil(t, checkLeaf("c2", clusters))
+}
+
+func TestUnmirroredName(t *testing.T) {
+	assert.Equal(t, "web", unmirroredName("web-c1", "c1"))
+	assert.Equal(t, "web-c2", unmirroredName("web-c2", "c1"))
+}
@@ -0,0 +1,512 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package drift reports drift of objects in clusters from typed tasks of ClusterControllers
+// into Cluster status, comparing objects applied or deleted by tasks with those mirrored to center.
+package drift
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+	"github.com/baidu/ote-stack/pkg/controllermanager"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned"
+	otelisters "github.com/baidu/ote-stack/pkg/generated/listers/ote/v1"
+	"github.com/baidu/ote-stack/pkg/reporter"
+	"github.com/baidu/ote-stack/pkg/taskbuilder"
+)
+
+// mirror gets and lists a kind of objects mirrored to center.
+type mirror struct {
+	apiVersion string
+	get        func(namespace, name string) (metav1.Object, error)
+	list       func(selector labels.Selector) ([]metav1.Object, error)
+	synced     cache.InformerSynced
+}
+
+// mirrors returns the kinds of objects mirrored to center by kind, whose drift is computed.
+// Tasks of other kinds are not checked, since their objects in clusters are unknown in center.
+func mirrors(factory informers.SharedInformerFactory) map[string]mirror {
+	deployments := factory.Apps().V1().Deployments()
+	daemonSets := factory.Apps().V1().DaemonSets()
+	pods := factory.Core().V1().Pods()
+	services := factory.Core().V1().Services()
+	endpoints := factory.Core().V1().Endpoints()
+	return map[string]mirror{
+		"Deployment": {
+			apiVersion: "apps/v1",
+			get: func(namespace, name string) (metav1.Object, error) {
+				return deployments.Lister().Deployments(namespace).Get(name)
+			},
+			list: func(selector labels.Selector) ([]metav1.Object, error) {
+				list, err := deployments.Lister().List(selector)
+				objs := make([]metav1.Object, len(list))
+				for i := range list {
+					objs[i] = list[i]
+				}
+				return objs, err
+			},
+			synced: deployments.Informer().HasSynced,
+		},
+		"DaemonSet": {
+			apiVersion: "apps/v1",
+			get: func(namespace, name string) (metav1.Object, error) {
+				return daemonSets.Lister().DaemonSets(namespace).Get(name)
+			},
+			list: func(selector labels.Selector) ([]metav1.Object, error) {
+				list, err := daemonSets.Lister().List(selector)
+				objs := make([]metav1.Object, len(list))
+				for i := range list {
+					objs[i] = list[i]
+				}
+				return objs, err
+			},
+			synced: daemonSets.Informer().HasSynced,
+		},
+		"Pod": {
+			apiVersion: "v1",
+			get: func(namespace, name string) (metav1.Object, error) {
+				return pods.Lister().Pods(namespace).Get(name)
+			},
+			list: func(selector labels.Selector) ([]metav1.Object, error) {
+				list, err := pods.Lister().List(selector)
+				objs := make([]metav1.Object, len(list))
+				for i := range list {
+					objs[i] = list[i]
+				}
+				return objs, err
+			},
+			synced: pods.Informer().HasSynced,
+		},
+		"Service": {
+			apiVersion: "v1",
+			get: func(namespace, name string) (metav1.Object, error) {
+				return services.Lister().Services(namespace).Get(name)
+			},
+			list: func(selector labels.Selector) ([]metav1.Object, error) {
+				list, err := services.Lister().List(selector)
+				objs := make([]metav1.Object, len(list))
+				for i := range list {
+					objs[i] = list[i]
+				}
+				return objs, err
+			},
+			synced: services.Informer().HasSynced,
+		},
+		"Endpoints": {
+			apiVersion: "v1",
+			get: func(namespace, name string) (metav1.Object, error) {
+				return endpoints.Lister().Endpoints(namespace).Get(name)
+			},
+			list: func(selector labels.Selector) ([]metav1.Object, error) {
+				list, err := endpoints.Lister().List(selector)
+				objs := make([]metav1.Object, len(list))
+				for i := range list {
+					objs[i] = list[i]
+				}
+				return objs, err
+			},
+			synced: endpoints.Informer().HasSynced,
+		},
+	}
+}
+
+// declaration is the latest typed task of an object in a cluster.
+type declaration struct {
+	ref otev1.ObjectReference
+	// cc is the name of the ClusterController of the task.
+	cc string
+	// manifest is the object applied, nil if it is deleted.
+	manifest map[string]interface{}
+}
+
+// DriftController computes drift of clusters periodically, from the latest typed tasks
+// of objects in each cluster and objects mirrored from the cluster.
+// Drift is patched to Cluster status once it changes.
+type DriftController struct {
+	oteClient     oteclient.Interface
+	clusterLister otelisters.ClusterLister
+	ccLister      otelisters.ClusterControllerLister
+	mirrors       map[string]mirror
+	now           func() time.Time
+}
+
+// NewInitFunc returns the InitFunc of drift controller computing drift every interval.
+func NewInitFunc(interval time.Duration) controllermanager.InitFunc {
+	return func(ctx *controllermanager.ControllerContext) error {
+		informers := ctx.OteInformerFactory.Ote().V1()
+		c := &DriftController{
+			oteClient:     ctx.OteClient,
+			clusterLister: informers.Clusters().Lister(),
+			ccLister:      informers.ClusterControllers().Lister(),
+			mirrors:       mirrors(ctx.InformerFactory),
+			now:           time.Now,
+		}
+		synced := []cache.InformerSynced{
+			informers.Clusters().Informer().HasSynced,
+			informers.ClusterControllers().Informer().HasSynced,
+		}
+		for _, m := range c.mirrors {
+			synced = append(synced, m.synced)
+		}
+		go func() {
+			// all objects are missing if those mirrored are not listed.
+			if !cache.WaitForCacheSync(ctx.StopChan, synced...) {
+				return
+			}
+			wait.Until(func() {
+				if err := c.sync(); err != nil {
+					klog.Errorf("sync drift of clusters failed: %v", err)
+				}
+			}, interval, ctx.StopChan)
+		}()
+		return nil
+	}
+}
+
+// sync computes drift of all clusters, and patches those changed.
+func (c *DriftController) sync() error {
+	clusters, err := c.clusterLister.Clusters(otev1.ClusterNamespace).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	ccs, err := c.ccLister.ClusterControllers(otev1.ClusterNamespace).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	declared := c.declarations(ccs)
+	labeled, err := c.labeled()
+	if err != nil {
+		return err
+	}
+	for _, cluster := range clusters {
+		drift := c.compute(cluster, declared[cluster.Name], labeled[cluster.Name])
+		if old := cluster.Status.Drift; old != nil && reflect.DeepEqual(old.Missing, drift.Missing) &&
+			reflect.DeepEqual(old.Extra, drift.Extra) && reflect.DeepEqual(old.Modified, drift.Modified) {
+			continue
+		}
+		drift.Timestamp = c.now().Unix()
+		if err := c.patch(cluster, drift); err != nil {
+			klog.Errorf("patch drift of cluster %s failed: %v", cluster.Name, err)
+		}
+	}
+	return nil
+}
+
+/*
+declarations returns the latest typed tasks of objects by kind/namespace/name in each cluster,
+in which a later ClusterController of an object replaces earlier ones.
+Clusters a task is not applied to, e.g., skipped or over quota, are not declared the object.
+*/
+func (c *DriftController) declarations(ccs []*otev1.ClusterController) map[string]map[string]*declaration {
+	sorted := append([]*otev1.ClusterController(nil), ccs...)
+	sort.Slice(sorted, func(i, j int) bool {
+		ti, tj := sorted[i].CreationTimestamp, sorted[j].CreationTimestamp
+		if !ti.Equal(&tj) {
+			return ti.Before(&tj)
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	declared := make(map[string]map[string]*declaration)
+	for _, cc := range sorted {
+		d := c.declare(cc)
+		if d == nil {
+			continue
+		}
+		for cluster, status := range cc.Status {
+			if !appliedToCluster(status.Reason) {
+				continue
+			}
+			if declared[cluster] == nil {
+				declared[cluster] = make(map[string]*declaration)
+			}
+			declared[cluster][objectKey(d.ref)] = d
+		}
+	}
+	return declared
+}
+
+// declare returns the declaration of the typed task of cc, nil if it has none or the kind is not mirrored.
+func (c *DriftController) declare(cc *otev1.ClusterController) *declaration {
+	task := cc.Spec.Task
+	if task == nil {
+		return nil
+	}
+	d := &declaration{cc: cc.Name}
+	switch task.Type {
+	case otev1.ClusterControllerTaskApply:
+		obj, _, err := taskbuilder.DecodeManifest(task.Manifest)
+		if err != nil {
+			klog.Errorf("decode manifest of clustercontroller %s failed: %v", cc.Name, err)
+			return nil
+		}
+		metadata := obj["metadata"].(map[string]interface{})
+		d.ref.APIVersion, _ = obj["apiVersion"].(string)
+		d.ref.Kind, _ = obj["kind"].(string)
+		d.ref.Namespace, _ = metadata["namespace"].(string)
+		d.ref.Name, _ = metadata["name"].(string)
+		d.manifest = obj
+	case otev1.ClusterControllerTaskDelete:
+		if task.Object == nil {
+			return nil
+		}
+		d.ref = *task.Object
+		if d.ref.Namespace == "" {
+			// kinds mirrored are all namespaced.
+			d.ref.Namespace = taskbuilder.DefaultNamespace
+		}
+	default:
+		return nil
+	}
+	if _, ok := c.mirrors[d.ref.Kind]; !ok {
+		return nil
+	}
+	return d
+}
+
+/*
+appliedToCluster returns false for reasons of status of tasks whose object is not applied to the cluster.
+Unlike otev1.SentToCluster, a deduplicated task is applied, since the ClusterController its status
+is copied from has sent the same task to the cluster, and it still declares the object.
+*/
+func appliedToCluster(reason string) bool {
+	return reason == otev1.ClusterControllerStatusDeduplicated || otev1.SentToCluster(reason)
+}
+
+// labeled returns objects mirrored from each cluster which are labeled applied by typed tasks.
+func (c *DriftController) labeled() (map[string][]otev1.ObjectReference, error) {
+	selector := labels.SelectorFromSet(labels.Set{handler.OwnerLabel: taskbuilder.Owner})
+	labeled := make(map[string][]otev1.ObjectReference)
+	for kind, m := range c.mirrors {
+		objs, err := m.list(selector)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range objs {
+			cluster := obj.GetLabels()[reporter.ClusterLabel]
+			if cluster == "" {
+				continue
+			}
+			labeled[cluster] = append(labeled[cluster], otev1.ObjectReference{
+				APIVersion: m.apiVersion,
+				Kind:       kind,
+				Namespace:  obj.GetNamespace(),
+				Name:       unmirroredName(obj.GetName(), cluster),
+			})
+		}
+	}
+	return labeled, nil
+}
+
+// compute returns drift of cluster from objects declared in it, and those labeled applied by tasks.
+func (c *DriftController) compute(cluster *otev1.Cluster, declared map[string]*declaration,
+	labeled []otev1.ObjectReference) *otev1.ClusterDrift {
+	drift := &otev1.ClusterDrift{}
+	ignored := ignoredFields(cluster)
+	for _, d := range declared {
+		mirrored, err := c.mirrors[d.ref.Kind].get(d.ref.Namespace, mirroredName(d.ref.Name, cluster.Name))
+		if errors.IsNotFound(err) {
+			if d.manifest != nil {
+				drift.Missing = append(drift.Missing, otev1.DriftedObject{ObjectReference: d.ref, ClusterController: d.cc})
+			}
+			continue
+		}
+		if err != nil {
+			klog.Errorf("get %s %s/%s of cluster %s failed: %v", d.ref.Kind, d.ref.Namespace, d.ref.Name, cluster.Name, err)
+			continue
+		}
+		if d.manifest == nil {
+			drift.Extra = append(drift.Extra, otev1.DriftedObject{ObjectReference: d.ref, ClusterController: d.cc})
+			continue
+		}
+		fields, err := modifiedFields(d.manifest, mirrored, ignored)
+		if err != nil {
+			klog.Errorf("compare %s %s/%s of cluster %s failed: %v", d.ref.Kind, d.ref.Namespace, d.ref.Name, cluster.Name, err)
+			continue
+		}
+		if len(fields) != 0 {
+			drift.Modified = append(drift.Modified, otev1.DriftedObject{
+				ObjectReference:   d.ref,
+				ClusterController: d.cc,
+				Fields:            fields,
+			})
+		}
+	}
+	for _, ref := range labeled {
+		if _, ok := declared[objectKey(ref)]; !ok {
+			drift.Extra = append(drift.Extra, otev1.DriftedObject{ObjectReference: ref})
+		}
+	}
+	for _, objs := range [][]otev1.DriftedObject{drift.Missing, drift.Extra, drift.Modified} {
+		sort.Slice(objs, func(i, j int) bool { return objectKey(objs[i].ObjectReference) < objectKey(objs[j].ObjectReference) })
+	}
+	return drift
+}
+
+// ignoredFields returns fields of containers overridden for cluster by root, which are not compared.
+func ignoredFields(cluster *otev1.Cluster) map[string]bool {
+	ignored := make(map[string]bool)
+	if cluster.Annotations[otev1.ClusterImageRegistryAnnotation] != "" {
+		ignored["image"] = true
+	}
+	if cluster.Annotations[otev1.ClusterResourceScaleAnnotation] != "" {
+		ignored["resources"] = true
+	}
+	return ignored
+}
+
+/*
+modifiedFields returns paths of fields of manifest which differ in mirrored, sorted.
+Fields not set in manifest, e.g., defaulted by apiserver, and status are not compared,
+and quantities of the same value in different formats are equal.
+*/
+func modifiedFields(manifest map[string]interface{}, mirrored metav1.Object, ignored map[string]bool) ([]string, error) {
+	data, err := json.Marshal(mirrored)
+	if err != nil {
+		return nil, err
+	}
+	got := make(map[string]interface{})
+	if err := json.Unmarshal(data, &got); err != nil {
+		return nil, err
+	}
+	var fields []string
+	for key, want := range manifest {
+		switch key {
+		case "apiVersion", "kind", "status":
+		case "metadata":
+			metadata, _ := want.(map[string]interface{})
+			gotMetadata, _ := got["metadata"].(map[string]interface{})
+			for _, key := range []string{"labels", "annotations"} {
+				if value, ok := metadata[key]; ok {
+					compare("metadata."+key, value, gotMetadata[key], ignored, &fields)
+				}
+			}
+		default:
+			compare(key, want, got[key], ignored, &fields)
+		}
+	}
+	sort.Strings(fields)
+	return fields, nil
+}
+
+// compare appends path of want to fields if got differs, or paths of fields of want differing in got.
+func compare(path string, want, got interface{}, ignored map[string]bool, fields *[]string) {
+	switch want := want.(type) {
+	case nil:
+	case map[string]interface{}:
+		gotMap, _ := got.(map[string]interface{})
+		for key, value := range want {
+			if !ignored[key] {
+				compare(path+"."+key, value, gotMap[key], ignored, fields)
+			}
+		}
+	case []interface{}:
+		gotList, ok := got.([]interface{})
+		if !ok || len(gotList) != len(want) {
+			*fields = append(*fields, path)
+			return
+		}
+		for i := range want {
+			compare(path+"["+strconv.Itoa(i)+"]", want[i], gotList[i], ignored, fields)
+		}
+	default:
+		if !equalValue(want, got) {
+			*fields = append(*fields, path)
+		}
+	}
+}
+
+// equalValue returns true if scalars want and got are equal, where a zero value equals
+// a field omitted, and strings of quantities equal those of the same value.
+func equalValue(want, got interface{}) bool {
+	if reflect.DeepEqual(want, got) {
+		return true
+	}
+	if got == nil {
+		switch want := want.(type) {
+		case bool:
+			return !want
+		case float64:
+			return want == 0
+		case string:
+			return want == ""
+		}
+		return false
+	}
+	wantString, ok1 := want.(string)
+	gotString, ok2 := got.(string)
+	if !ok1 || !ok2 {
+		return false
+	}
+	wantQuantity, err1 := resource.ParseQuantity(wantString)
+	gotQuantity, err2 := resource.ParseQuantity(gotString)
+	return err1 == nil && err2 == nil && wantQuantity.Cmp(gotQuantity) == 0
+}
+
+// patch patches drift of cluster, which is not reported by clusters
+// and kept by status patched from reports.
+func (c *DriftController) patch(cluster *otev1.Cluster, drift *otev1.ClusterDrift) error {
+	oldData, err := json.Marshal(cluster)
+	if err != nil {
+		return err
+	}
+	update := cluster.DeepCopy()
+	update.Status.Drift = drift
+	newData, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+	data, err := jsonpatch.CreateMergePatch(oldData, newData)
+	if err != nil {
+		return err
+	}
+	klog.V(3).Infof("update drift of cluster %s: %d missing, %d extra, %d modified",
+		cluster.Name, len(drift.Missing), len(drift.Extra), len(drift.Modified))
+	_, err = c.oteClient.OteV1().Clusters(cluster.Namespace).Patch(cluster.Name, types.MergePatchType, data)
+	return err
+}
+
+// objectKey returns kind/namespace/name of ref.
+func objectKey(ref otev1.ObjectReference) string {
+	return ref.Kind + "/" + ref.Namespace + "/" + ref.Name
+}
+
+// mirroredName returns the name of an object of cluster mirrored in center.
+func mirroredName(name, cluster string) string {
+	return name + controllermanager.UniqueResourceNameSeparator + cluster
+}
+
+// unmirroredName returns the name of an object in cluster by its name mirrored in center.
+func unmirroredName(mirrored, cluster string) string {
+	return strings.TrimSuffix(mirrored, controllermanager.UniqueResourceNameSeparator+cluster)
+}
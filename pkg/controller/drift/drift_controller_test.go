@@ -0,0 +1,281 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package drift
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+	oteinformer "github.com/baidu/ote-stack/pkg/generated/informers/externalversions"
+	otefake "github.com/baidu/ote-stack/pkg/k8sclient/fake"
+	"github.com/baidu/ote-stack/pkg/reporter"
+	"github.com/baidu/ote-stack/pkg/taskbuilder"
+)
+
+func newFakeCC(name string, created int64, task *otev1.ClusterControllerTask, clusters ...string) *otev1.ClusterController {
+	cc := &otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         otev1.ClusterNamespace,
+			CreationTimestamp: metav1.Unix(created, 0),
+		},
+		Spec:   otev1.ClusterControllerSpec{Task: task},
+		Status: make(map[string]otev1.ClusterControllerStatus),
+	}
+	for _, cluster := range clusters {
+		cc.Status[cluster] = otev1.ClusterControllerStatus{StatusCode: 200}
+	}
+	return cc
+}
+
+func applyTask(manifest string) *otev1.ClusterControllerTask {
+	return &otev1.ClusterControllerTask{Type: otev1.ClusterControllerTaskApply, Manifest: manifest}
+}
+
+func newMirroredDeployment(name, cluster string, replicas int32, cpu string, owned bool) *appsv1.Deployment {
+	labels := map[string]string{reporter.ClusterLabel: cluster, "app": name}
+	if owned {
+		labels[handler.OwnerLabel] = taskbuilder.Owner
+	}
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: mirroredName(name, cluster), Namespace: "default", Labels: labels},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Strategy: appsv1.DeploymentStrategy{Type: appsv1.RollingUpdateDeploymentStrategyType},
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name:  "web",
+				Image: "nginx:1.0",
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu)},
+				},
+			}}}},
+		},
+	}
+}
+
+const webManifest = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+spec:
+  replicas: %d
+  paused: false
+  template:
+    spec:
+      containers:
+      - name: web
+        image: nginx:1.0
+        resources:
+          requests:
+            cpu: "0.5"
+`
+
+func webTask(replicas int) *otev1.ClusterControllerTask {
+	return applyTask(fmt.Sprintf(webManifest, replicas))
+}
+
+// newFakeDriftController returns a controller of clusters, clustercontrollers, and deployments mirrored.
+func newFakeDriftController(clusters []*otev1.Cluster, ccs []*otev1.ClusterController,
+	deployments ...*appsv1.Deployment) *DriftController {
+	var objs []runtime.Object
+	for _, cluster := range clusters {
+		objs = append(objs, cluster)
+	}
+	oteClient := otefake.NewSimpleClientset(objs...)
+	oteFactory := oteinformer.NewSharedInformerFactory(oteClient, 0)
+	for _, cluster := range clusters {
+		oteFactory.Ote().V1().Clusters().Informer().GetIndexer().Add(cluster)
+	}
+	for _, cc := range ccs {
+		oteFactory.Ote().V1().ClusterControllers().Informer().GetIndexer().Add(cc)
+	}
+	factory := informers.NewSharedInformerFactory(k8sfake.NewSimpleClientset(), 0)
+	for _, deployment := range deployments {
+		factory.Apps().V1().Deployments().Informer().GetIndexer().Add(deployment)
+	}
+	return &DriftController{
+		oteClient:     oteClient,
+		clusterLister: oteFactory.Ote().V1().Clusters().Lister(),
+		ccLister:      oteFactory.Ote().V1().ClusterControllers().Lister(),
+		mirrors:       mirrors(factory),
+		now:           func() time.Time { return time.Unix(10000, 0) },
+	}
+}
+
+func TestSync(t *testing.T) {
+	clusters := []*otev1.Cluster{
+		{ObjectMeta: metav1.ObjectMeta{Name: "c1", Namespace: otev1.ClusterNamespace}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "c2", Namespace: otev1.ClusterNamespace}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "c3", Namespace: otev1.ClusterNamespace}},
+	}
+	skipped := newFakeCC("web-v1", 1, webTask(2), "c1", "c2")
+	skipped.Status["c3"] = otev1.ClusterControllerStatus{Reason: otev1.ClusterControllerStatusSkipped}
+	ccs := []*otev1.ClusterController{
+		skipped,
+		// the later task of web replaces the earlier one in c1.
+		newFakeCC("web-v2", 2, webTask(3), "c1"),
+		newFakeCC("delete-api", 3, &otev1.ClusterControllerTask{
+			Type:   otev1.ClusterControllerTaskDelete,
+			Object: &otev1.ObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "api"},
+		}, "c1"),
+		newFakeCC("svc", 4, applyTask(`{"apiVersion":"v1","kind":"Service","metadata":{"name":"svc"}}`), "c2"),
+		// objects not mirrored are not checked.
+		newFakeCC("cm", 5, applyTask(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cm"}}`), "c1"),
+	}
+	c := newFakeDriftController(clusters, ccs,
+		newMirroredDeployment("web", "c1", 3, "500m", true),
+		newMirroredDeployment("web", "c2", 1, "500m", true),
+		newMirroredDeployment("api", "c1", 1, "1", true),
+		newMirroredDeployment("old", "c2", 1, "1", true),
+		// objects not applied by tasks are not extra.
+		newMirroredDeployment("user", "c2", 1, "1", false))
+	assert.Nil(t, c.sync())
+
+	get := func(name string) *otev1.ClusterDrift {
+		cluster, err := c.oteClient.OteV1().Clusters(otev1.ClusterNamespace).Get(name, metav1.GetOptions{})
+		assert.Nil(t, err)
+		return cluster.Status.Drift
+	}
+	deployment := func(name string) otev1.ObjectReference {
+		return otev1.ObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: name}
+	}
+	drift := get("c1")
+	assert.Empty(t, drift.Missing)
+	assert.Empty(t, drift.Modified)
+	assert.Equal(t, []otev1.DriftedObject{{ObjectReference: deployment("api"), ClusterController: "delete-api"}}, drift.Extra)
+	assert.Equal(t, int64(10000), drift.Timestamp)
+
+	drift = get("c2")
+	assert.Equal(t, []otev1.DriftedObject{{
+		ObjectReference:   otev1.ObjectReference{APIVersion: "v1", Kind: "Service", Namespace: "default", Name: "svc"},
+		ClusterController: "svc",
+	}}, drift.Missing)
+	assert.Equal(t, []otev1.DriftedObject{{ObjectReference: deployment("old")}}, drift.Extra)
+	assert.Equal(t, []otev1.DriftedObject{{
+		ObjectReference:   deployment("web"),
+		ClusterController: "web-v1",
+		Fields:            []string{"spec.replicas"},
+	}}, drift.Modified)
+
+	// clusters tasks are not sent to have no drift.
+	drift = get("c3")
+	assert.NotNil(t, drift)
+	assert.Empty(t, drift.Missing)
+	assert.Empty(t, drift.Extra)
+	assert.Empty(t, drift.Modified)
+}
+
+func TestSyncUnchanged(t *testing.T) {
+	cluster := &otev1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "c1", Namespace: otev1.ClusterNamespace},
+		Status:     otev1.ClusterStatus{Drift: &otev1.ClusterDrift{Timestamp: 100}},
+	}
+	c := newFakeDriftController([]*otev1.Cluster{cluster},
+		[]*otev1.ClusterController{newFakeCC("web", 1, webTask(3), "c1")},
+		newMirroredDeployment("web", "c1", 3, "0.5", true))
+	assert.Nil(t, c.sync())
+	got, err := c.oteClient.OteV1().Clusters(otev1.ClusterNamespace).Get("c1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(100), got.Status.Drift.Timestamp)
+}
+
+func TestModifiedFields(t *testing.T) {
+	manifest := map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":   "web",
+			"labels": map[string]interface{}{"app": "web", "tier": "front"},
+		},
+		"spec": map[string]interface{}{
+			"replicas": float64(2),
+			"template": map[string]interface{}{"spec": map[string]interface{}{
+				"containers": []interface{}{map[string]interface{}{
+					"name":      "web",
+					"image":     "registry.local/nginx:1.0",
+					"resources": map[string]interface{}{"requests": map[string]interface{}{"cpu": "1"}},
+				}},
+			}},
+		},
+		"status": map[string]interface{}{"replicas": float64(5)},
+	}
+	mirrored := newMirroredDeployment("web", "c1", 2, "500m", true)
+	fields, err := modifiedFields(manifest, mirrored, map[string]bool{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"metadata.labels.tier",
+		"spec.template.spec.containers[0].image",
+		"spec.template.spec.containers[0].resources.requests.cpu",
+	}, fields)
+
+	// fields overridden by root are not compared.
+	cluster := &otev1.Cluster{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+		otev1.ClusterImageRegistryAnnotation: "registry.local",
+		otev1.ClusterResourceScaleAnnotation: "0.5",
+	}}}
+	fields, err = modifiedFields(manifest, mirrored, ignoredFields(cluster))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"metadata.labels.tier"}, fields)
+
+	// lists of different lengths are modified as a whole.
+	manifest["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})["containers"] =
+		[]interface{}{map[string]interface{}{"name": "web"}, map[string]interface{}{"name": "sidecar"}}
+	fields, err = modifiedFields(manifest, mirrored, map[string]bool{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"metadata.labels.tier", "spec.template.spec.containers"}, fields)
+}
+
+func TestEqualValue(t *testing.T) {
+	assert.True(t, equalValue("a", "a"))
+	assert.False(t, equalValue("a", "b"))
+	assert.True(t, equalValue(float64(1), float64(1)))
+	assert.False(t, equalValue(float64(1), float64(2)))
+	// zero values equal fields omitted.
+	assert.True(t, equalValue(false, nil))
+	assert.True(t, equalValue(float64(0), nil))
+	assert.True(t, equalValue("", nil))
+	assert.False(t, equalValue(true, nil))
+	// quantities of the same value.
+	assert.True(t, equalValue("0.5", "500m"))
+	assert.True(t, equalValue("1Gi", "1024Mi"))
+	assert.False(t, equalValue("1", "500m"))
+}
+
+func TestAppliedToCluster(t *testing.T) {
+	assert.True(t, appliedToCluster(""))
+	assert.True(t, appliedToCluster(otev1.ClusterControllerStatusTimedOut))
+	// deduplicated tasks are applied by the ClusterController of the same key.
+	assert.True(t, appliedToCluster(otev1.ClusterControllerStatusDeduplicated))
+	assert.False(t, appliedToCluster(otev1.ClusterControllerStatusSkipped))
+	assert.False(t, appliedToCluster(otev1.ClusterControllerStatusOverrideFailed))
+	assert.False(t, appliedToCluster(otev1.ClusterControllerStatusQuotaExceeded))
+}
@@ -0,0 +1,267 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package epoch announces the state epoch of center to all clusters,
+// and quarantines resources mirrored before the epoch until they are reported again.
+// The epoch is bumped after center lost resources mirrored, e.g., etcd is restored from a backup.
+package epoch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/controllermanager"
+	"github.com/baidu/ote-stack/pkg/reporter"
+)
+
+const (
+	// DefaultInterval is the interval to announce the epoch by default.
+	DefaultInterval = time.Minute
+
+	// EpochKey is the key of the epoch in the configmap.
+	EpochKey = "epoch"
+	// QuarantinedKey is the key of the last epoch resources are quarantined for in the configmap.
+	QuarantinedKey = "quarantined"
+)
+
+// Config is the config of epoch controller.
+type Config struct {
+	// ConfigMap is the namespace/name of the configmap holding the epoch.
+	ConfigMap string
+	// Interval is the interval to announce the epoch, so that clusters offline
+	// when the epoch changed get it once they are online again.
+	Interval time.Duration
+}
+
+// mirroredResource lists and patches a kind of resources mirrored from edge clusters.
+type mirroredResource struct {
+	kind  string
+	list  func(opts metav1.ListOptions) (runtime.Object, error)
+	patch func(namespace, name string, data []byte) error
+}
+
+// EpochController announces the state epoch and quarantines resources mirrored before it.
+type EpochController struct {
+	namespace string
+	name      string
+	interval  time.Duration
+	k8sClient kubernetes.Interface
+	sendChan  chan clustermessage.ClusterMessage
+	resources []mirroredResource
+}
+
+// NewInitFunc returns the InitFunc of epoch controller by config.
+func NewInitFunc(conf *Config) controllermanager.InitFunc {
+	return func(ctx *controllermanager.ControllerContext) error {
+		c, err := newEpochController(conf, ctx.K8sClient, ctx.PublishChan)
+		if err != nil {
+			return err
+		}
+		go wait.Until(c.sync, c.interval, ctx.StopChan)
+		return nil
+	}
+}
+
+func newEpochController(conf *Config, k8sClient kubernetes.Interface,
+	sendChan chan clustermessage.ClusterMessage) (*EpochController, error) {
+	parts := strings.Split(conf.ConfigMap, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("configmap of epoch should be namespace/name, got %q", conf.ConfigMap)
+	}
+	interval := conf.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &EpochController{
+		namespace: parts[0],
+		name:      parts[1],
+		interval:  interval,
+		k8sClient: k8sClient,
+		sendChan:  sendChan,
+		resources: mirroredResources(k8sClient),
+	}, nil
+}
+
+// mirroredResources returns the kinds of resources mirrored by upstream processor.
+func mirroredResources(cl kubernetes.Interface) []mirroredResource {
+	return []mirroredResource{
+		{
+			kind: "node",
+			list: func(opts metav1.ListOptions) (runtime.Object, error) { return cl.CoreV1().Nodes().List(opts) },
+			patch: func(namespace, name string, data []byte) error {
+				_, err := cl.CoreV1().Nodes().Patch(name, types.StrategicMergePatchType, data)
+				return err
+			},
+		},
+		{
+			kind: "pod",
+			list: func(opts metav1.ListOptions) (runtime.Object, error) { return cl.CoreV1().Pods("").List(opts) },
+			patch: func(namespace, name string, data []byte) error {
+				_, err := cl.CoreV1().Pods(namespace).Patch(name, types.StrategicMergePatchType, data)
+				return err
+			},
+		},
+		{
+			kind: "deployment",
+			list: func(opts metav1.ListOptions) (runtime.Object, error) { return cl.AppsV1().Deployments("").List(opts) },
+			patch: func(namespace, name string, data []byte) error {
+				_, err := cl.AppsV1().Deployments(namespace).Patch(name, types.StrategicMergePatchType, data)
+				return err
+			},
+		},
+		{
+			kind: "daemonset",
+			list: func(opts metav1.ListOptions) (runtime.Object, error) { return cl.AppsV1().DaemonSets("").List(opts) },
+			patch: func(namespace, name string, data []byte) error {
+				_, err := cl.AppsV1().DaemonSets(namespace).Patch(name, types.StrategicMergePatchType, data)
+				return err
+			},
+		},
+		{
+			kind: "service",
+			list: func(opts metav1.ListOptions) (runtime.Object, error) { return cl.CoreV1().Services("").List(opts) },
+			patch: func(namespace, name string, data []byte) error {
+				_, err := cl.CoreV1().Services(namespace).Patch(name, types.StrategicMergePatchType, data)
+				return err
+			},
+		},
+		{
+			kind: "endpoints",
+			list: func(opts metav1.ListOptions) (runtime.Object, error) { return cl.CoreV1().Endpoints("").List(opts) },
+			patch: func(namespace, name string, data []byte) error {
+				_, err := cl.CoreV1().Endpoints(namespace).Patch(name, types.StrategicMergePatchType, data)
+				return err
+			},
+		},
+	}
+}
+
+// sync quarantines resources mirrored if the epoch is not quarantined for, and announces the epoch.
+func (c *EpochController) sync() {
+	cm, err := c.k8sClient.CoreV1().ConfigMaps(c.namespace).Get(c.name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		klog.V(3).Infof("configmap %s/%s of epoch not found", c.namespace, c.name)
+		return
+	}
+	if err != nil {
+		klog.Errorf("get configmap %s/%s of epoch failed: %v", c.namespace, c.name, err)
+		return
+	}
+	epoch := cm.Data[EpochKey]
+	if epoch == "" {
+		klog.V(3).Infof("epoch is not set in configmap %s/%s", c.namespace, c.name)
+		return
+	}
+
+	if cm.Data[QuarantinedKey] != epoch {
+		klog.Infof("state epoch changed to %s, quarantine resources mirrored", epoch)
+		if err := c.quarantine(epoch); err != nil {
+			klog.Errorf("quarantine resources for epoch %s failed: %v", epoch, err)
+			return
+		}
+		cm.Data[QuarantinedKey] = epoch
+		if _, err := c.k8sClient.CoreV1().ConfigMaps(c.namespace).Update(cm); err != nil {
+			klog.Errorf("record epoch %s quarantined failed: %v", epoch, err)
+			return
+		}
+	}
+
+	if err := c.announce(epoch); err != nil {
+		klog.Errorf("announce epoch %s failed: %v", epoch, err)
+	}
+}
+
+// quarantine sets QuarantineLabel to epoch on all resources mirrored from edge clusters.
+func (c *EpochController) quarantine(epoch string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]string{controllermanager.QuarantineLabel: epoch},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	opts := metav1.ListOptions{LabelSelector: reporter.ClusterLabel}
+
+	var failed []string
+	for _, r := range c.resources {
+		list, err := r.list(opts)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("list %s: %v", r.kind, err))
+			continue
+		}
+		objs, err := meta.ExtractList(list)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("list %s: %v", r.kind, err))
+			continue
+		}
+		quarantined := 0
+		for _, obj := range objs {
+			accessor, err := meta.Accessor(obj)
+			if err != nil {
+				continue
+			}
+			if accessor.GetLabels()[controllermanager.QuarantineLabel] == epoch {
+				continue
+			}
+			if err := r.patch(accessor.GetNamespace(), accessor.GetName(), patch); err != nil {
+				failed = append(failed, fmt.Sprintf("patch %s %s/%s: %v",
+					r.kind, accessor.GetNamespace(), accessor.GetName(), err))
+				continue
+			}
+			quarantined++
+		}
+		klog.Infof("%d %s quarantined for epoch %s", quarantined, r.kind, epoch)
+	}
+	if len(failed) != 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// announce sends epoch to all clusters.
+func (c *EpochController) announce(epoch string) error {
+	data := &clustermessage.ControllerTask{
+		Destination: otev1.ClusterControllerDestResync,
+		Method:      http.MethodPost,
+		Body:        []byte(epoch),
+	}
+	head := &clustermessage.MessageHead{
+		ClusterSelector: "",
+		Command:         clustermessage.CommandType_ControlReq,
+	}
+	msg, err := data.ToClusterMessage(head)
+	if err != nil {
+		return err
+	}
+
+	c.sendChan <- *msg
+	return nil
+}
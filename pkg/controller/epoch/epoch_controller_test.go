@@ -0,0 +1,128 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package epoch
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+	"github.com/baidu/ote-stack/pkg/controllermanager"
+	"github.com/baidu/ote-stack/pkg/reporter"
+)
+
+func TestNewEpochController(t *testing.T) {
+	for _, cm := range []string{"", "kube-system", "/ote-state-epoch", "kube-system/", "a/b/c"} {
+		_, err := newEpochController(&Config{ConfigMap: cm}, nil, nil)
+		assert.Error(t, err, cm)
+	}
+	c, err := newEpochController(&Config{ConfigMap: "kube-system/ote-state-epoch"}, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "kube-system", c.namespace)
+	assert.Equal(t, "ote-state-epoch", c.name)
+	assert.Equal(t, DefaultInterval, c.interval)
+}
+
+func TestSync(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "ote-state-epoch"},
+		Data:       map[string]string{EpochKey: "2", QuarantinedKey: "1"},
+	}
+	mirrored := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "pod1-c1",
+			Labels:    map[string]string{reporter.ClusterLabel: "c1"},
+		},
+	}
+	local := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pod2"},
+	}
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "node1-c1",
+			Labels: map[string]string{reporter.ClusterLabel: "c1"},
+		},
+	}
+	client := k8sfake.NewSimpleClientset(cm, mirrored, local, node)
+	sendChan := make(chan clustermessage.ClusterMessage, 2)
+	c, err := newEpochController(&Config{ConfigMap: "kube-system/ote-state-epoch"}, client, sendChan)
+	assert.Nil(t, err)
+
+	getPod := func(name string) *corev1.Pod {
+		pod, err := client.CoreV1().Pods("default").Get(name, metav1.GetOptions{})
+		assert.Nil(t, err)
+		return pod
+	}
+	assertAnnounced := func() {
+		msg := <-sendChan
+		assert.Equal(t, clustermessage.CommandType_ControlReq, msg.Head.Command)
+		task := handler.GetControllerTaskFromClusterMessage(&msg)
+		assert.NotNil(t, task)
+		assert.Equal(t, otev1.ClusterControllerDestResync, task.Destination)
+		assert.Equal(t, http.MethodPost, task.Method)
+		assert.Equal(t, "2", string(task.Body))
+	}
+
+	// epoch changed, mirrored resources are quarantined.
+	c.sync()
+	assertAnnounced()
+	pod := getPod("pod1-c1")
+	assert.Equal(t, "2", pod.Labels[controllermanager.QuarantineLabel])
+	assert.Equal(t, "c1", pod.Labels[reporter.ClusterLabel])
+	assert.NotContains(t, getPod("pod2").Labels, controllermanager.QuarantineLabel)
+	n, err := client.CoreV1().Nodes().Get("node1-c1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "2", n.Labels[controllermanager.QuarantineLabel])
+	stored, err := client.CoreV1().ConfigMaps("kube-system").Get("ote-state-epoch", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "2", stored.Data[QuarantinedKey])
+
+	// refreshed resources are not quarantined again for the same epoch.
+	delete(pod.Labels, controllermanager.QuarantineLabel)
+	_, err = client.CoreV1().Pods("default").Update(pod)
+	assert.Nil(t, err)
+	c.sync()
+	assertAnnounced()
+	assert.NotContains(t, getPod("pod1-c1").Labels, controllermanager.QuarantineLabel)
+}
+
+func TestSyncWithoutEpoch(t *testing.T) {
+	sendChan := make(chan clustermessage.ClusterMessage, 1)
+	client := k8sfake.NewSimpleClientset()
+	c, err := newEpochController(&Config{ConfigMap: "kube-system/ote-state-epoch"}, client, sendChan)
+	assert.Nil(t, err)
+
+	// configmap not found.
+	c.sync()
+	assert.Len(t, sendChan, 0)
+
+	// epoch not set.
+	_, err = client.CoreV1().ConfigMaps("kube-system").Create(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "ote-state-epoch"},
+	})
+	assert.Nil(t, err)
+	c.sync()
+	assert.Len(t, sendChan, 0)
+}
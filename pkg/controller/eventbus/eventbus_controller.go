@@ -0,0 +1,151 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//Package eventbus publishes lifecycle events of clusters and results of tasks to message buses
+//like kafka and nats, so that data platforms consume state of clusters without polling k8s apiserver.
+package eventbus
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/controllermanager"
+)
+
+//EventBusController watches clusters and clustercontrollers, and publishes events to brokers.
+type EventBusController struct {
+	sinks []*sink
+	now   func() time.Time
+}
+
+//NewInitFunc returns the InitFunc of event bus controller by config.
+func NewInitFunc(conf *Config) controllermanager.InitFunc {
+	return func(ctx *controllermanager.ControllerContext) error {
+		c, err := newEventBusController(conf)
+		if err != nil {
+			return err
+		}
+		for _, s := range c.sinks {
+			go s.run(ctx.StopChan)
+		}
+
+		ctx.OteInformerFactory.Ote().V1().Clusters().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    c.handleClusterAdded,
+			UpdateFunc: c.handleClusterUpdated,
+			DeleteFunc: c.handleClusterDeleted,
+		})
+		ctx.OteInformerFactory.Ote().V1().ClusterControllers().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			UpdateFunc: c.handleClusterControllerUpdated,
+		})
+		return nil
+	}
+}
+
+func newEventBusController(conf *Config) (*EventBusController, error) {
+	if len(conf.Brokers) == 0 {
+		return nil, fmt.Errorf("no broker is registered")
+	}
+	c := &EventBusController{now: time.Now}
+	for _, b := range conf.Brokers {
+		s, err := newSink(b)
+		if err != nil {
+			return nil, err
+		}
+		c.sinks = append(c.sinks, s)
+	}
+	return c, nil
+}
+
+func (c *EventBusController) publish(e *Event) {
+	klog.V(3).Infof("publish event %s of %s", e.ID, e.Cluster)
+	e.SchemaVersion = SchemaVersion
+	e.Time = c.now()
+	for _, s := range c.sinks {
+		s.publish(e)
+	}
+}
+
+func clusterEvent(t EventType, cluster *otev1.Cluster) *Event {
+	return &Event{
+		ID:      fmt.Sprintf("%s/%s/%s", t, cluster.Name, cluster.ResourceVersion),
+		Type:    t,
+		Cluster: cluster.Name,
+		Parent:  cluster.Status.ParentName,
+		Status:  cluster.Status.Status,
+	}
+}
+
+//handleClusterAdded publishes clusters created and those listed on start,
+//so that consumers have the state of all clusters. Events of clusters listed again
+//on restart have the same id.
+func (c *EventBusController) handleClusterAdded(obj interface{}) {
+	c.publish(clusterEvent(EventClusterAdded, obj.(*otev1.Cluster)))
+}
+
+func (c *EventBusController) handleClusterUpdated(oldObj, newObj interface{}) {
+	old := oldObj.(*otev1.Cluster)
+	cluster := newObj.(*otev1.Cluster)
+	if old.Status.Status == cluster.Status.Status && old.Status.ParentName == cluster.Status.ParentName {
+		return
+	}
+	switch {
+	case old.Status.Status != cluster.Status.Status && cluster.Status.Status == otev1.ClusterStatusOnline:
+		c.publish(clusterEvent(EventClusterOnline, cluster))
+	case old.Status.Status != cluster.Status.Status && cluster.Status.Status == otev1.ClusterStatusOffline:
+		c.publish(clusterEvent(EventClusterOffline, cluster))
+	default:
+		c.publish(clusterEvent(EventClusterUpdated, cluster))
+	}
+}
+
+func (c *EventBusController) handleClusterDeleted(obj interface{}) {
+	cluster, ok := obj.(*otev1.Cluster)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			return
+		}
+		if cluster, ok = tombstone.Obj.(*otev1.Cluster); !ok {
+			return
+		}
+	}
+	c.publish(clusterEvent(EventClusterDeleted, cluster))
+}
+
+//handleClusterControllerUpdated publishes results of clusters newly written.
+func (c *EventBusController) handleClusterControllerUpdated(oldObj, newObj interface{}) {
+	old := oldObj.(*otev1.ClusterController)
+	cc := newObj.(*otev1.ClusterController)
+	for cluster, status := range cc.Status {
+		if oldStatus, ok := old.Status[cluster]; ok && oldStatus == status {
+			continue
+		}
+		c.publish(&Event{
+			ID:         fmt.Sprintf("%s/%s/%s/%s", EventTaskResult, cc.Name, cluster, cc.ResourceVersion),
+			Type:       EventTaskResult,
+			Cluster:    cluster,
+			Task:       cc.Name,
+			StatusCode: status.StatusCode,
+			Body:       status.Body,
+			Reason:     status.Reason,
+			Timestamp:  status.Timestamp,
+		})
+	}
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventbus
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+)
+
+func newFakeEventBusController(t *testing.T) (*EventBusController, *sink) {
+	c, err := newEventBusController(&Config{Brokers: []Broker{{Type: BrokerKafka, URL: "http://127.0.0.1:8082"}}})
+	assert.Nil(t, err)
+	return c, c.sinks[0]
+}
+
+func newFakeCluster(name, parent, status, resourceVersion string) *otev1.Cluster {
+	return &otev1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       otev1.ClusterNamespace,
+			ResourceVersion: resourceVersion,
+		},
+		Status: otev1.ClusterStatus{Status: status, ParentName: parent},
+	}
+}
+
+func assertEvent(t *testing.T, s *sink, eventType EventType, cluster string) *Event {
+	select {
+	case e := <-s.queue:
+		assert.Equal(t, eventType, e.Type)
+		assert.Equal(t, cluster, e.Cluster)
+		assert.Equal(t, SchemaVersion, e.SchemaVersion)
+		assert.False(t, e.Time.IsZero())
+		return e
+	default:
+		t.Fatalf("no event %s of %s", eventType, cluster)
+	}
+	return nil
+}
+
+func assertNoEvent(t *testing.T, s *sink) {
+	select {
+	case e := <-s.queue:
+		t.Fatalf("unexpected event %v", e)
+	default:
+	}
+}
+
+func TestNewEventBusController(t *testing.T) {
+	_, err := newEventBusController(&Config{})
+	assert.NotNil(t, err)
+	_, err = newEventBusController(&Config{Brokers: []Broker{{Type: "amqp", URL: "amqp://127.0.0.1"}}})
+	assert.NotNil(t, err)
+	_, err = newEventBusController(&Config{Brokers: []Broker{{Type: BrokerNATS, URL: "http://127.0.0.1"}}})
+	assert.NotNil(t, err)
+}
+
+func TestHandleClusterEvents(t *testing.T) {
+	c, s := newFakeEventBusController(t)
+
+	c.handleClusterAdded(newFakeCluster("c1", "root", otev1.ClusterStatusOnline, "1"))
+	e := assertEvent(t, s, EventClusterAdded, "c1")
+	assert.Equal(t, "ClusterAdded/c1/1", e.ID)
+	assert.Equal(t, "root", e.Parent)
+	assert.Equal(t, otev1.ClusterStatusOnline, e.Status)
+
+	online := newFakeCluster("c2", "root", otev1.ClusterStatusOnline, "2")
+	offline := newFakeCluster("c2", "root", otev1.ClusterStatusOffline, "3")
+	moved := newFakeCluster("c2", "c1", otev1.ClusterStatusOnline, "4")
+	c.handleClusterUpdated(online, online)
+	assertNoEvent(t, s)
+	c.handleClusterUpdated(online, offline)
+	assertEvent(t, s, EventClusterOffline, "c2")
+	c.handleClusterUpdated(offline, online)
+	assertEvent(t, s, EventClusterOnline, "c2")
+	c.handleClusterUpdated(online, moved)
+	e = assertEvent(t, s, EventClusterUpdated, "c2")
+	assert.Equal(t, "c1", e.Parent)
+
+	c.handleClusterDeleted(moved)
+	assertEvent(t, s, EventClusterDeleted, "c2")
+	c.handleClusterDeleted(cache.DeletedFinalStateUnknown{Obj: moved})
+	assertEvent(t, s, EventClusterDeleted, "c2")
+	c.handleClusterDeleted(cache.DeletedFinalStateUnknown{})
+	assertNoEvent(t, s)
+}
+
+func TestHandleClusterControllerUpdated(t *testing.T) {
+	c, s := newFakeEventBusController(t)
+
+	old := &otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{Name: "cc", ResourceVersion: "1"},
+		Status: map[string]otev1.ClusterControllerStatus{
+			"c1": {StatusCode: 500, Body: "failed"},
+		},
+	}
+	cc := old.DeepCopy()
+	cc.ResourceVersion = "2"
+	cc.Status["c2"] = otev1.ClusterControllerStatus{StatusCode: 404, Body: "not found", Timestamp: 10}
+	c.handleClusterControllerUpdated(old, cc)
+	e := assertEvent(t, s, EventTaskResult, "c2")
+	assert.Equal(t, "TaskResult/cc/c2/2", e.ID)
+	assert.Equal(t, "cc", e.Task)
+	assert.Equal(t, 404, e.StatusCode)
+	assert.Equal(t, "not found", e.Body)
+	assert.Equal(t, int64(10), e.Timestamp)
+	assertNoEvent(t, s)
+}
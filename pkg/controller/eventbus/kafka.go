@@ -0,0 +1,102 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventbus
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const kafkaContentType = "application/vnd.kafka.json.v2+json"
+
+// kafkaRecords is the request of producing records by kafka rest proxy v2.
+type kafkaRecords struct {
+	Records []kafkaRecord `json:"records"`
+}
+
+type kafkaRecord struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// kafkaOffsets is the response of producing records by kafka rest proxy v2.
+type kafkaOffsets struct {
+	Offsets []struct {
+		ErrorCode *int   `json:"error_code"`
+		Error     string `json:"error"`
+	} `json:"offsets"`
+}
+
+// kafkaPublisher produces records to topics by kafka rest proxy, so that no kafka client is required.
+type kafkaPublisher struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newKafkaPublisher(proxy string, headers map[string]string, timeout time.Duration) *kafkaPublisher {
+	return &kafkaPublisher{
+		url:     strings.TrimSuffix(proxy, "/"),
+		headers: headers,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// publish produces value keyed by key to topic, records of the same key are in the same partition.
+func (k *kafkaPublisher) publish(topic, key string, value []byte) error {
+	data, err := json.Marshal(&kafkaRecords{Records: []kafkaRecord{{Key: key, Value: value}}})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, k.url+"/topics/"+url.PathEscape(topic), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", kafkaContentType)
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	for h, v := range k.headers {
+		req.Header.Set(h, v)
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("kafka rest proxy responds %d: %s", resp.StatusCode, string(body))
+	}
+	offsets := &kafkaOffsets{}
+	if err := json.Unmarshal(body, offsets); err != nil {
+		return fmt.Errorf("unmarshal response of kafka rest proxy failed: %v", err)
+	}
+	for _, o := range offsets.Offsets {
+		if o.ErrorCode != nil || o.Error != "" {
+			return fmt.Errorf("produce to %s failed: %s", topic, o.Error)
+		}
+	}
+	return nil
+}
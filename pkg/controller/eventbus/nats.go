@@ -0,0 +1,188 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventbus
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultNATSPort = "4222"
+
+// natsConnect is the options of CONNECT of nats protocol.
+type natsConnect struct {
+	Verbose   bool   `json:"verbose"`
+	Pedantic  bool   `json:"pedantic"`
+	Name      string `json:"name"`
+	Lang      string `json:"lang"`
+	Version   string `json:"version"`
+	User      string `json:"user,omitempty"`
+	Pass      string `json:"pass,omitempty"`
+	AuthToken string `json:"auth_token,omitempty"`
+}
+
+/*
+natsPublisher publishes messages to subjects of a nats server by the text protocol of nats,
+so that no nats client is required. Each publish is followed by a PING and waits for the PONG,
+so that a message is known to be received by server when publish returns.
+The connection is closed on error and dialed again on next publish.
+*/
+type natsPublisher struct {
+	addr    string
+	tls     *tls.Config
+	connect natsConnect
+	timeout time.Duration
+
+	lock   sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func newNATSPublisher(server string, timeout time.Duration) (*natsPublisher, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, fmt.Errorf("parse nats url %s failed: %v", server, err)
+	}
+	n := &natsPublisher{
+		addr:    u.Host,
+		timeout: timeout,
+		connect: natsConnect{Name: "ote-controller-manager", Lang: "go", Version: "1.0"},
+	}
+	switch u.Scheme {
+	case "nats":
+	case "tls":
+		n.tls = &tls.Config{ServerName: u.Hostname()}
+	default:
+		return nil, fmt.Errorf("scheme of nats url %s must be nats or tls", server)
+	}
+	if u.Port() == "" {
+		n.addr = net.JoinHostPort(u.Hostname(), defaultNATSPort)
+	}
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			n.connect.User = u.User.Username()
+			n.connect.Pass = pass
+		} else {
+			n.connect.AuthToken = u.User.Username()
+		}
+	}
+	return n, nil
+}
+
+// publish publishes value to subject topic, key is not used since subjects of nats are not partitioned.
+func (n *natsPublisher) publish(topic, key string, value []byte) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if n.conn == nil {
+		if err := n.dial(); err != nil {
+			return err
+		}
+	}
+	n.conn.SetDeadline(time.Now().Add(n.timeout))
+	msg := fmt.Sprintf("PUB %s %d\r\n%s\r\nPING\r\n", topic, len(value), value)
+	err := n.write(msg)
+	if err == nil {
+		err = n.waitPong()
+	}
+	if err != nil {
+		n.close()
+	}
+	return err
+}
+
+func (n *natsPublisher) dial() error {
+	conn, err := net.DialTimeout("tcp", n.addr, n.timeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(n.timeout))
+	n.conn = conn
+	n.reader = bufio.NewReader(conn)
+	line, err := n.reader.ReadString('\n')
+	if err != nil {
+		n.close()
+		return fmt.Errorf("read info of nats server failed: %v", err)
+	}
+	if !strings.HasPrefix(line, "INFO") {
+		n.close()
+		return fmt.Errorf("unexpected greeting of nats server: %s", strings.TrimSpace(line))
+	}
+	if n.tls != nil {
+		tlsConn := tls.Client(conn, n.tls)
+		if err := tlsConn.Handshake(); err != nil {
+			n.close()
+			return fmt.Errorf("tls handshake with nats server failed: %v", err)
+		}
+		n.conn = tlsConn
+		n.reader = bufio.NewReader(tlsConn)
+	}
+	connect, err := json.Marshal(&n.connect)
+	if err != nil {
+		n.close()
+		return err
+	}
+	if err := n.write(fmt.Sprintf("CONNECT %s\r\nPING\r\n", connect)); err != nil {
+		n.close()
+		return err
+	}
+	if err := n.waitPong(); err != nil {
+		n.close()
+		return fmt.Errorf("connect nats server failed: %v", err)
+	}
+	return nil
+}
+
+func (n *natsPublisher) write(s string) error {
+	_, err := n.conn.Write([]byte(s))
+	return err
+}
+
+// waitPong reads until PONG, and answers PING of server.
+func (n *natsPublisher) waitPong() error {
+	for {
+		line, err := n.reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if err := n.write("PONG\r\n"); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats server responds %s", line)
+		}
+	}
+}
+
+func (n *natsPublisher) close() {
+	if n.conn != nil {
+		n.conn.Close()
+		n.conn = nil
+		n.reader = nil
+	}
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventbus
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfig(t *testing.T) {
+	f, err := ioutil.TempFile("", "eventbus")
+	assert.Nil(t, err)
+	defer os.Remove(f.Name())
+
+	f.WriteString(`brokers:
+- type: kafka
+  url: http://127.0.0.1:8082
+  events: [TaskResult]
+  headers:
+    Authorization: Bearer token
+- type: nats
+  url: nats://127.0.0.1:4222
+  clusterTopic: fleet.clusters
+`)
+	f.Close()
+	conf, err := LoadConfig(f.Name())
+	assert.Nil(t, err)
+	assert.Len(t, conf.Brokers, 2)
+	assert.Equal(t, []EventType{EventTaskResult}, conf.Brokers[0].Events)
+	assert.Equal(t, "fleet.clusters", conf.Brokers[1].ClusterTopic)
+
+	for _, content := range []string{
+		"brokers:\n- type: kafka\n",
+		"brokers:\n- type: amqp\n  url: amqp://127.0.0.1\n",
+	} {
+		ioutil.WriteFile(f.Name(), []byte(content), 0644)
+		_, err = LoadConfig(f.Name())
+		assert.NotNil(t, err, content)
+	}
+
+	_, err = LoadConfig("/notexist")
+	assert.NotNil(t, err)
+}
+
+func TestSubscribedAndTopic(t *testing.T) {
+	s, err := newSink(Broker{Type: BrokerKafka, URL: "http://127.0.0.1:8082"})
+	assert.Nil(t, err)
+	assert.True(t, s.broker.subscribed(EventClusterAdded))
+	assert.Equal(t, DefaultClusterTopic, s.broker.topic(EventClusterOffline))
+	assert.Equal(t, DefaultResultTopic, s.broker.topic(EventTaskResult))
+	s.broker.Events = []EventType{EventTaskResult}
+	assert.True(t, s.broker.subscribed(EventTaskResult))
+	assert.False(t, s.broker.subscribed(EventClusterAdded))
+}
+
+func TestKafkaSink(t *testing.T) {
+	retryInterval = 10 * time.Millisecond
+	var calls int32
+	received := make(chan *Event, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// fail the first request to test retry
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Write([]byte(`{"offsets":[{"error_code":50001,"error":"leader not available"}]}`))
+			return
+		}
+		assert.Equal(t, "/topics/ote.results", r.URL.Path)
+		assert.Equal(t, kafkaContentType, r.Header.Get("Content-Type"))
+		assert.Equal(t, "token", r.Header.Get("Authorization"))
+		records := &kafkaRecords{}
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Nil(t, json.Unmarshal(body, records))
+		assert.Len(t, records.Records, 1)
+		assert.Equal(t, "c2", records.Records[0].Key)
+		e := &Event{}
+		assert.Nil(t, json.Unmarshal(records.Records[0].Value, e))
+		received <- e
+		w.Write([]byte(`{"offsets":[{"partition":0,"offset":1,"error_code":null,"error":null}]}`))
+	}))
+	defer server.Close()
+
+	s, err := newSink(Broker{
+		Type:    BrokerKafka,
+		URL:     server.URL,
+		Events:  []EventType{EventTaskResult},
+		Headers: map[string]string{"Authorization": "token"},
+	})
+	assert.Nil(t, err)
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.run(stop)
+
+	s.publish(&Event{ID: "1", Type: EventClusterAdded, Cluster: "c1"})
+	s.publish(&Event{ID: "2", Type: EventTaskResult, Cluster: "c2", Task: "cc"})
+	select {
+	case e := <-received:
+		assert.Equal(t, "2", e.ID)
+		assert.Equal(t, "cc", e.Task)
+	case <-time.After(5 * time.Second):
+		t.Fatal("event is not received")
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+	p := newKafkaPublisher(notFound.URL, nil, time.Second)
+	assert.NotNil(t, p.publish("t", "k", []byte("{}")))
+}
+
+// fakeNATSServer accepts a connection, and sends messages published to received.
+func fakeNATSServer(t *testing.T, received chan<- string) net.Listener {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				fmt.Fprintf(conn, "INFO {\"server_id\":\"fake\"}\r\n")
+				reader := bufio.NewReader(conn)
+				// ping the client once to test answer of PONG.
+				fmt.Fprintf(conn, "PING\r\n")
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					line = strings.TrimSpace(line)
+					switch {
+					case strings.HasPrefix(line, "CONNECT"):
+						if !strings.Contains(line, `"auth_token":"secret"`) {
+							fmt.Fprintf(conn, "-ERR 'Authorization Violation'\r\n")
+							return
+						}
+					case line == "PING":
+						fmt.Fprintf(conn, "PONG\r\n")
+					case strings.HasPrefix(line, "PUB"):
+						var subject string
+						var size int
+						fmt.Sscanf(line, "PUB %s %d", &subject, &size)
+						payload := make([]byte, size+2)
+						if _, err := io.ReadFull(reader, payload); err != nil {
+							return
+						}
+						received <- subject + " " + string(payload[:size])
+					}
+				}
+			}()
+		}
+	}()
+	return lis
+}
+
+func TestNATSPublisher(t *testing.T) {
+	received := make(chan string, 10)
+	lis := fakeNATSServer(t, received)
+	defer lis.Close()
+
+	p, err := newNATSPublisher("nats://secret@"+lis.Addr().String(), time.Second)
+	assert.Nil(t, err)
+	assert.Nil(t, p.publish("ote.clusters", "c1", []byte(`{"id":"1"}`)))
+	assert.Equal(t, `ote.clusters {"id":"1"}`, <-received)
+
+	// reconnect after the connection is broken.
+	p.conn.Close()
+	assert.NotNil(t, p.publish("ote.clusters", "c1", []byte(`{"id":"2"}`)))
+	assert.Nil(t, p.publish("ote.clusters", "c1", []byte(`{"id":"3"}`)))
+	assert.Equal(t, `ote.clusters {"id":"3"}`, <-received)
+
+	p, err = newNATSPublisher("nats://user:wrong@"+lis.Addr().String(), time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, "user", p.connect.User)
+	assert.NotNil(t, p.publish("ote.clusters", "c1", []byte("{}")))
+
+	p, err = newNATSPublisher("tls://nats.example.com", time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, "nats.example.com:4222", p.addr)
+	assert.NotNil(t, p.tls)
+	_, err = newNATSPublisher("http://127.0.0.1", time.Second)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitops
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// repository is a local checkout of a branch of a git repository,
+// operated by the git command.
+type repository struct {
+	url    string
+	branch string
+	dir    string
+}
+
+// sync checks out the latest commit of the branch, and returns its sha.
+func (r *repository) sync() (string, error) {
+	if _, err := os.Stat(filepath.Join(r.dir, ".git")); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(r.dir), 0755); err != nil {
+			return "", fmt.Errorf("create work dir failed: %v", err)
+		}
+		if _, err := r.git("", "clone", "--quiet", "--single-branch", "--branch", r.branch,
+			r.url, r.dir); err != nil {
+			return "", err
+		}
+	} else {
+		if _, err := r.git(r.dir, "fetch", "--quiet", r.url, r.branch); err != nil {
+			return "", err
+		}
+		if _, err := r.git(r.dir, "reset", "--quiet", "--hard", "FETCH_HEAD"); err != nil {
+			return "", err
+		}
+		if _, err := r.git(r.dir, "clean", "--quiet", "-fdx"); err != nil {
+			return "", err
+		}
+	}
+	return r.git(r.dir, "rev-parse", "HEAD")
+}
+
+// git runs a git command in dir, and returns its output trimmed.
+func (r *repository) git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	// never prompt for credentials, they should be set by git config or in url.
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %v: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
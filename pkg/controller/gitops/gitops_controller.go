@@ -0,0 +1,248 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//Package gitops syncs ClusterControllers from a git repository to root cluster,
+//so that tasks to clusters are declared in git.
+package gitops
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/controllermanager"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned"
+)
+
+const (
+	// ManagedLabel labels ClusterControllers applied by gitops controller.
+	ManagedLabel = "ote-gitops-managed"
+	// CommitAnnotation records the commit sha a ClusterController is applied from.
+	CommitAnnotation = "ote-gitops-commit"
+	// FileAnnotation records the file in repository a ClusterController is defined in.
+	FileAnnotation = "ote-gitops-file"
+
+	// DefaultBranch is the branch synced by default.
+	DefaultBranch = "master"
+	// DefaultInterval is the interval to sync by default.
+	DefaultInterval = time.Minute
+)
+
+// Config is the config of gitops controller.
+type Config struct {
+	// Repo is the url of git repository.
+	Repo string
+	// Branch is the branch to sync.
+	Branch string
+	// Path is the directory in repository containing manifests, empty means the whole repository.
+	Path string
+	// WorkDir is the local directory to check out repository, a temp directory is used if empty.
+	WorkDir string
+	// Interval is the interval to sync from repository.
+	Interval time.Duration
+	// Prune deletes ClusterControllers applied before but removed from repository.
+	Prune bool
+}
+
+//GitOpsController applies ClusterControllers in a git repository to root cluster.
+type GitOpsController struct {
+	conf       *Config
+	repo       *repository
+	oteClient  oteclient.Interface
+	lastCommit string
+}
+
+//NewInitFunc returns the InitFunc of gitops controller by config.
+func NewInitFunc(conf *Config) controllermanager.InitFunc {
+	return func(ctx *controllermanager.ControllerContext) error {
+		c, err := newGitOpsController(conf, ctx.OteClient)
+		if err != nil {
+			return err
+		}
+		go c.run(ctx.StopChan)
+		return nil
+	}
+}
+
+func newGitOpsController(conf *Config, oteClient oteclient.Interface) (*GitOpsController, error) {
+	if conf.Repo == "" {
+		return nil, fmt.Errorf("git repository is not set")
+	}
+	if conf.Branch == "" {
+		conf.Branch = DefaultBranch
+	}
+	if conf.Interval <= 0 {
+		conf.Interval = DefaultInterval
+	}
+	if conf.WorkDir == "" {
+		dir, err := ioutil.TempDir("", "ote-gitops")
+		if err != nil {
+			return nil, fmt.Errorf("create work dir failed: %v", err)
+		}
+		conf.WorkDir = dir
+	}
+	return &GitOpsController{
+		conf: conf,
+		repo: &repository{
+			url:    conf.Repo,
+			branch: conf.Branch,
+			dir:    filepath.Join(conf.WorkDir, "repo"),
+		},
+		oteClient: oteClient,
+	}, nil
+}
+
+func (c *GitOpsController) run(stop <-chan struct{}) {
+	klog.Infof("sync clustercontrollers from %s(%s) every %v", c.conf.Repo, c.conf.Branch, c.conf.Interval)
+	ticker := time.NewTicker(c.conf.Interval)
+	defer ticker.Stop()
+	for {
+		if err := c.sync(); err != nil {
+			klog.Errorf("gitops sync failed: %v", err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync checks out the latest commit, and applies manifests if the commit is not applied.
+func (c *GitOpsController) sync() error {
+	commit, err := c.repo.sync()
+	if err != nil {
+		return err
+	}
+	if commit == c.lastCommit {
+		klog.V(3).Infof("commit %s is already applied", commit)
+		return nil
+	}
+
+	manifests, err := loadManifests(c.repo.dir, filepath.Join(c.repo.dir, c.conf.Path))
+	if err != nil {
+		return fmt.Errorf("load manifests of commit %s failed: %v", commit, err)
+	}
+	if err := c.apply(commit, manifests); err != nil {
+		return fmt.Errorf("apply commit %s failed: %v", commit, err)
+	}
+	c.lastCommit = commit
+	klog.Infof("commit %s applied, %d clustercontrollers", commit, len(manifests))
+	return nil
+}
+
+// apply makes ClusterControllers in root cluster the same as manifests,
+// and returns the last error if any manifest failed.
+func (c *GitOpsController) apply(commit string, manifests []*manifest) error {
+	var lastErr error
+	applied := make(map[string]bool, len(manifests))
+	for _, m := range manifests {
+		applied[m.cc.Name] = true
+		if err := c.applyOne(commit, m); err != nil {
+			klog.Errorf("apply clustercontroller %s in %s failed: %v", m.cc.Name, m.file, err)
+			lastErr = err
+		}
+	}
+	if c.conf.Prune {
+		if err := c.prune(applied); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func (c *GitOpsController) applyOne(commit string, m *manifest) error {
+	desired := m.cc.DeepCopy()
+	if desired.Labels == nil {
+		desired.Labels = make(map[string]string)
+	}
+	desired.Labels[ManagedLabel] = "true"
+	if desired.Annotations == nil {
+		desired.Annotations = make(map[string]string)
+	}
+	desired.Annotations[CommitAnnotation] = commit
+	desired.Annotations[FileAnnotation] = m.file
+	desired.Status = nil
+
+	client := c.oteClient.OteV1().ClusterControllers(otev1.ClusterNamespace)
+	existing, err := client.Get(desired.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = client.Create(desired)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if existing.Labels[ManagedLabel] != "true" {
+		return fmt.Errorf("clustercontroller %s exists but not managed by gitops", desired.Name)
+	}
+
+	if !specEqual(&existing.Spec, &desired.Spec) {
+		// clustercontroller is only dispatched to clusters when added,
+		// so recreate it to dispatch the new spec.
+		klog.Infof("clustercontroller %s changed in commit %s, recreate it", desired.Name, commit)
+		if err := client.Delete(desired.Name, &metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		_, err = client.Create(desired)
+		return err
+	}
+	if existing.Annotations[CommitAnnotation] == commit {
+		return nil
+	}
+	// spec not changed, only record the commit and keep status of clusters.
+	updated := existing.DeepCopy()
+	updated.Labels = desired.Labels
+	updated.Annotations = desired.Annotations
+	_, err = client.Update(updated)
+	return err
+}
+
+// prune deletes ClusterControllers managed by gitops but not applied.
+func (c *GitOpsController) prune(applied map[string]bool) error {
+	client := c.oteClient.OteV1().ClusterControllers(otev1.ClusterNamespace)
+	list, err := client.List(metav1.ListOptions{LabelSelector: ManagedLabel + "=true"})
+	if err != nil {
+		return fmt.Errorf("list clustercontrollers managed by gitops failed: %v", err)
+	}
+	var lastErr error
+	for _, cc := range list.Items {
+		if applied[cc.Name] {
+			continue
+		}
+		klog.Infof("clustercontroller %s is removed from repository, delete it", cc.Name)
+		if err := client.Delete(cc.Name, &metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			klog.Errorf("delete clustercontroller %s failed: %v", cc.Name, err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// specEqual compares specs ignoring parent cluster name, which is set by root clustercontroller.
+func specEqual(a, b *otev1.ClusterControllerSpec) bool {
+	x, y := *a, *b
+	x.ParentClusterName = ""
+	y.ParentClusterName = ""
+	return reflect.DeepEqual(x, y)
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitops
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	otefake "github.com/baidu/ote-stack/pkg/k8sclient/fake"
+)
+
+// upstream is a git repository to sync from in test.
+type upstream struct {
+	t   *testing.T
+	dir string
+}
+
+func newUpstream(t *testing.T) *upstream {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "gitops-upstream")
+	assert.Nil(t, err)
+	u := &upstream{t: t, dir: dir}
+	u.git("init", "--quiet")
+	u.git("checkout", "--quiet", "-b", "master")
+	return u
+}
+
+func (u *upstream) git(args ...string) {
+	args = append([]string{"-c", "user.name=test", "-c", "user.email=test@ote",
+		"-c", "commit.gpgsign=false"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = u.dir
+	out, err := cmd.CombinedOutput()
+	assert.Nil(u.t, err, string(out))
+}
+
+func (u *upstream) commit(files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(u.dir, name)
+		if content == "" {
+			assert.Nil(u.t, os.Remove(path))
+			continue
+		}
+		assert.Nil(u.t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(u.t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+	u.git("add", "-A")
+	u.git("commit", "--quiet", "-m", "update")
+}
+
+func TestNewGitOpsController(t *testing.T) {
+	_, err := newGitOpsController(&Config{}, otefake.NewSimpleClientset())
+	assert.NotNil(t, err)
+
+	c, err := newGitOpsController(&Config{Repo: "repo"}, otefake.NewSimpleClientset())
+	assert.Nil(t, err)
+	defer os.RemoveAll(c.conf.WorkDir)
+	assert.Equal(t, DefaultBranch, c.repo.branch)
+	assert.Equal(t, DefaultInterval, c.conf.Interval)
+}
+
+func TestSync(t *testing.T) {
+	u := newUpstream(t)
+	defer os.RemoveAll(u.dir)
+	u.commit(map[string]string{
+		"apps/cc.yaml": ccYaml,
+		"other/cc.json": `{"kind":"ClusterController","metadata":{"name":"other"},` +
+			`"spec":{"destination":"api"}}`,
+	})
+
+	client := otefake.NewSimpleClientset(&otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "manual",
+			Namespace: otev1.ClusterNamespace,
+		},
+	})
+	c, err := newGitOpsController(&Config{Repo: u.dir, Path: "apps", Prune: true}, client)
+	assert.Nil(t, err)
+	defer os.RemoveAll(c.conf.WorkDir)
+	ccClient := client.OteV1().ClusterControllers(otev1.ClusterNamespace)
+
+	// first sync creates all clustercontrollers under path
+	assert.Nil(t, c.sync())
+	firstCommit := c.lastCommit
+	assert.NotEmpty(t, firstCommit)
+	cc1, err := ccClient.Get("cc1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "true", cc1.Labels[ManagedLabel])
+	assert.Equal(t, firstCommit, cc1.Annotations[CommitAnnotation])
+	assert.Equal(t, filepath.Join("apps", "cc.yaml"), cc1.Annotations[FileAnnotation])
+	_, err = ccClient.Get("cc2", metav1.GetOptions{})
+	assert.Nil(t, err)
+	_, err = ccClient.Get("other", metav1.GetOptions{})
+	assert.NotNil(t, err)
+
+	// record status of clusters
+	cc1.Status = map[string]otev1.ClusterControllerStatus{"c1": {StatusCode: 200}}
+	_, err = ccClient.Update(cc1)
+	assert.Nil(t, err)
+
+	// cc1 not changed keeps status, cc2 changed is recreated, cc3 is added
+	u.commit(map[string]string{
+		"apps/cc.yaml": ccYaml[:len(ccYaml)-len("/api/v1/nodes\n")] + "/api/v1/pods\n",
+		"apps/cc.json": ccJSON,
+	})
+	assert.Nil(t, c.sync())
+	assert.NotEqual(t, firstCommit, c.lastCommit)
+	cc1, err = ccClient.Get("cc1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, c.lastCommit, cc1.Annotations[CommitAnnotation])
+	assert.Len(t, cc1.Status, 1)
+	cc2, err := ccClient.Get("cc2", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "/api/v1/pods", cc2.Spec.URL)
+	_, err = ccClient.Get("cc3", metav1.GetOptions{})
+	assert.Nil(t, err)
+
+	// removed clustercontrollers are pruned, the one not managed is kept
+	u.commit(map[string]string{"apps/cc.yaml": ""})
+	assert.Nil(t, c.sync())
+	list, err := ccClient.List(metav1.ListOptions{})
+	assert.Nil(t, err)
+	var names []string
+	for _, cc := range list.Items {
+		names = append(names, cc.Name)
+	}
+	assert.ElementsMatch(t, []string{"cc3", "manual"}, names)
+
+	// the clustercontroller not managed by gitops is not taken over
+	u.commit(map[string]string{"apps/manual.json": `{"kind":"ClusterController",` +
+		`"metadata":{"name":"manual"},"spec":{"destination":"api"}}`})
+	assert.NotNil(t, c.sync())
+	manual, err := ccClient.Get("manual", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Empty(t, manual.Labels[ManagedLabel])
+}
+
+func TestSyncFailed(t *testing.T) {
+	c, err := newGitOpsController(&Config{Repo: "/notexist"}, otefake.NewSimpleClientset())
+	assert.Nil(t, err)
+	defer os.RemoveAll(c.conf.WorkDir)
+	assert.NotNil(t, c.sync())
+	assert.Empty(t, c.lastCommit)
+}
+
+func TestSpecEqual(t *testing.T) {
+	a := &otev1.ClusterControllerSpec{ParentClusterName: "root", URL: "/a"}
+	b := &otev1.ClusterControllerSpec{URL: "/a"}
+	assert.True(t, specEqual(a, b))
+	b.URL = "/b"
+	assert.False(t, specEqual(a, b))
+}
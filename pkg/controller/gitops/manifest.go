@@ -0,0 +1,131 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitops
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/klog"
+	k8syaml "sigs.k8s.io/yaml"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+)
+
+const clusterControllerKind = "ClusterController"
+
+// manifest is a ClusterController read from a file of repository.
+type manifest struct {
+	file string
+	cc   *otev1.ClusterController
+}
+
+// loadManifests reads all ClusterControllers from yaml or json files under dir recursively,
+// the file of a manifest is relative to root.
+// Documents of other kinds are skipped.
+func loadManifests(root, dir string) ([]*manifest, error) {
+	var manifests []*manifest
+	names := make(map[string]string)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+
+		file, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		ccs, err := decodeClusterControllers(data)
+		if err != nil {
+			return fmt.Errorf("decode %s failed: %v", file, err)
+		}
+		for _, cc := range ccs {
+			if other, ok := names[cc.Name]; ok {
+				return fmt.Errorf("clustercontroller %s is defined in both %s and %s", cc.Name, other, file)
+			}
+			names[cc.Name] = file
+			manifests = append(manifests, &manifest{file: file, cc: cc})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifests, nil
+}
+
+// decodeClusterControllers decodes all ClusterControllers in a multi-document yaml or a json.
+func decodeClusterControllers(data []byte) ([]*otev1.ClusterController, error) {
+	var ccs []*otev1.ClusterController
+	reader := yaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return ccs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+
+		typeMeta := metav1.TypeMeta{}
+		if err := k8syaml.Unmarshal(doc, &typeMeta); err != nil {
+			return nil, err
+		}
+		if typeMeta.Kind != clusterControllerKind {
+			klog.V(3).Infof("skip manifest of kind %q", typeMeta.Kind)
+			continue
+		}
+		cc := &otev1.ClusterController{}
+		if err := k8syaml.Unmarshal(doc, cc); err != nil {
+			return nil, err
+		}
+		if cc.Name == "" {
+			return nil, fmt.Errorf("clustercontroller has no name")
+		}
+		if cc.Namespace != "" && cc.Namespace != otev1.ClusterNamespace {
+			return nil, fmt.Errorf("clustercontroller %s must be in namespace %s", cc.Name, otev1.ClusterNamespace)
+		}
+		cc.Namespace = otev1.ClusterNamespace
+		ccs = append(ccs, cc)
+	}
+}
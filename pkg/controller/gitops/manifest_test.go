@@ -0,0 +1,108 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitops
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+)
+
+const (
+	ccYaml = `apiVersion: ote.baidu.com/v1
+kind: ClusterController
+metadata:
+  name: cc1
+spec:
+  clusterSelector: c1
+  destination: api
+  method: GET
+  url: /api/v1/namespaces
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+---
+apiVersion: ote.baidu.com/v1
+kind: ClusterController
+metadata:
+  name: cc2
+  namespace: kube-system
+spec:
+  clusterSelector: c2
+  destination: api
+  method: GET
+  url: /api/v1/nodes
+`
+	ccJSON = `{"apiVersion":"ote.baidu.com/v1","kind":"ClusterController",` +
+		`"metadata":{"name":"cc3"},"spec":{"destination":"api","method":"GET","url":"/version"}}`
+)
+
+func TestDecodeClusterControllers(t *testing.T) {
+	ccs, err := decodeClusterControllers([]byte(ccYaml))
+	assert.Nil(t, err)
+	assert.Len(t, ccs, 2)
+	assert.Equal(t, "cc1", ccs[0].Name)
+	assert.Equal(t, otev1.ClusterNamespace, ccs[0].Namespace)
+	assert.Equal(t, "c1", ccs[0].Spec.ClusterSelector)
+	assert.Equal(t, "cc2", ccs[1].Name)
+
+	ccs, err = decodeClusterControllers([]byte(ccJSON))
+	assert.Nil(t, err)
+	assert.Len(t, ccs, 1)
+	assert.Equal(t, "/version", ccs[0].Spec.URL)
+
+	ccs, err = decodeClusterControllers([]byte("---\n"))
+	assert.Nil(t, err)
+	assert.Len(t, ccs, 0)
+
+	_, err = decodeClusterControllers([]byte("kind: ClusterController\nspec: {}\n"))
+	assert.NotNil(t, err)
+	_, err = decodeClusterControllers([]byte("kind: ClusterController\nmetadata:\n  name: a\n  namespace: default\n"))
+	assert.NotNil(t, err)
+}
+
+func TestLoadManifests(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitops-test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "apps", "sub"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "apps", "cc.yaml"), []byte(ccYaml), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "apps", "sub", "cc.json"), []byte(ccJSON), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "apps", "README.md"), []byte("# doc"), 0644))
+
+	manifests, err := loadManifests(dir, filepath.Join(dir, "apps"))
+	assert.Nil(t, err)
+	assert.Len(t, manifests, 3)
+	assert.Equal(t, filepath.Join("apps", "cc.yaml"), manifests[0].file)
+	assert.Equal(t, filepath.Join("apps", "sub", "cc.json"), manifests[2].file)
+
+	// duplicated name
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "apps", "dup.yml"), []byte(ccJSON), 0644))
+	_, err = loadManifests(dir, filepath.Join(dir, "apps"))
+	assert.NotNil(t, err)
+
+	_, err = loadManifests(dir, filepath.Join(dir, "notexist"))
+	assert.NotNil(t, err)
+}
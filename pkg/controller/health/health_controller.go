@@ -0,0 +1,270 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package health computes a composite health score of each cluster into Cluster status,
+// which is served as metrics and skips unhealthy clusters in dispatching by minHealthScore.
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/controllermanager"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned"
+	otelisters "github.com/baidu/ote-stack/pkg/generated/listers/ote/v1"
+)
+
+var (
+	// syncInterval is the interval to recompute health of all clusters.
+	syncInterval = 30 * time.Second
+	// commandWindow is the time of tasks recently responded counted in command success rate.
+	commandWindow = time.Hour
+
+	// heartbeat scores 100 within heartbeatGood since the last status, and decreases to 0 at heartbeatBad.
+	// Status is reported every minute.
+	heartbeatGood = 2 * time.Minute
+	heartbeatBad  = 10 * time.Minute
+	// report lag scores 100 within reportLagGood, and decreases to 0 at reportLagBad.
+	reportLagGood = 5 * time.Second
+	reportLagBad  = 5 * time.Minute
+
+	// weights are weights of factors in the score.
+	weights = map[string]int{
+		otev1.ClusterHealthHeartbeat:      40,
+		otev1.ClusterHealthReportLag:      20,
+		otev1.ClusterHealthCommandSuccess: 20,
+		otev1.ClusterHealthNodeReadiness:  20,
+	}
+)
+
+var (
+	healthsLock sync.RWMutex
+	// healths are the latest health of clusters computed, by cluster name.
+	healths = make(map[string]otev1.ClusterHealth)
+)
+
+// HealthController recomputes health of clusters periodically, from the last status
+// reported, the lag of the report, tasks responded recently and nodes ready by ClusterInventory.
+// Health is patched to Cluster status once the score or factors change.
+type HealthController struct {
+	oteClient       oteclient.Interface
+	clusterLister   otelisters.ClusterLister
+	inventoryLister otelisters.ClusterInventoryLister
+	ccLister        otelisters.ClusterControllerLister
+	reportLag       func(cluster string) (time.Duration, bool)
+	now             func() time.Time
+}
+
+// InitHealthController inits health controller.
+func InitHealthController(ctx *controllermanager.ControllerContext) error {
+	c := &HealthController{
+		oteClient:       ctx.OteClient,
+		clusterLister:   ctx.OteInformerFactory.Ote().V1().Clusters().Lister(),
+		inventoryLister: ctx.OteInformerFactory.Ote().V1().ClusterInventories().Lister(),
+		ccLister:        ctx.OteInformerFactory.Ote().V1().ClusterControllers().Lister(),
+		reportLag:       controllermanager.ReportLag,
+		now:             time.Now,
+	}
+	go c.run(ctx.StopChan)
+	return nil
+}
+
+func (c *HealthController) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if err := c.sync(); err != nil {
+			klog.Errorf("sync health of clusters failed: %v", err)
+		}
+	}
+}
+
+// sync recomputes health of all clusters, and patches those changed.
+func (c *HealthController) sync() error {
+	clusters, err := c.clusterLister.Clusters(otev1.ClusterNamespace).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	now := c.now()
+	commands, err := c.commandCounts(now)
+	if err != nil {
+		return err
+	}
+	current := make(map[string]otev1.ClusterHealth, len(clusters))
+	for _, cluster := range clusters {
+		health := c.compute(cluster, commands[cluster.Name], now)
+		if old := cluster.Status.Health; old != nil &&
+			old.Score == health.Score && reflect.DeepEqual(old.Factors, health.Factors) {
+			current[cluster.Name] = *old
+			continue
+		}
+		current[cluster.Name] = *health
+		if err := c.patch(cluster, health); err != nil {
+			klog.Errorf("patch health of cluster %s failed: %v", cluster.Name, err)
+		}
+	}
+	healthsLock.Lock()
+	defer healthsLock.Unlock()
+	healths = current
+	return nil
+}
+
+// commandCount is the number of tasks responded by a cluster.
+type commandCount struct {
+	succeeded int
+	total     int
+}
+
+// commandCounts returns tasks responded by each cluster since commandWindow before now.
+// Tasks not sent to clusters, e.g., skipped or deduplicated, are not counted,
+// while those timed out are counted as failed.
+func (c *HealthController) commandCounts(now time.Time) (map[string]*commandCount, error) {
+	ccs, err := c.ccLister.ClusterControllers(otev1.ClusterNamespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	since := now.Add(-commandWindow).Unix()
+	counts := make(map[string]*commandCount)
+	for _, cc := range ccs {
+		for cluster, status := range cc.Status {
+			if status.Timestamp < since || !otev1.SentToCluster(status.Reason) {
+				continue
+			}
+			count, ok := counts[cluster]
+			if !ok {
+				count = &commandCount{}
+				counts[cluster] = count
+			}
+			count.total++
+			if status.StatusCode >= http.StatusOK && status.StatusCode < http.StatusMultipleChoices {
+				count.succeeded++
+			}
+		}
+	}
+	return counts, nil
+}
+
+// compute returns health of cluster at now, with factors unknown omitted.
+func (c *HealthController) compute(cluster *otev1.Cluster, commands *commandCount, now time.Time) *otev1.ClusterHealth {
+	factors := make(map[string]int)
+	if cluster.Status.Status == otev1.ClusterStatusOffline {
+		factors[otev1.ClusterHealthHeartbeat] = 0
+	} else if cluster.Status.Timestamp != 0 {
+		age := now.Sub(time.Unix(cluster.Status.Timestamp, 0))
+		factors[otev1.ClusterHealthHeartbeat] = linearScore(age, heartbeatGood, heartbeatBad)
+	}
+	if lag, ok := c.reportLag(cluster.Name); ok {
+		factors[otev1.ClusterHealthReportLag] = linearScore(lag, reportLagGood, reportLagBad)
+	}
+	if commands != nil && commands.total != 0 {
+		factors[otev1.ClusterHealthCommandSuccess] = 100 * commands.succeeded / commands.total
+	}
+	inventory, err := c.inventoryLister.ClusterInventories(otev1.ClusterNamespace).Get(cluster.Name)
+	if err != nil && !errors.IsNotFound(err) {
+		klog.Errorf("get inventory of cluster %s failed: %v", cluster.Name, err)
+	}
+	if err == nil && inventory.Status.Nodes != 0 {
+		factors[otev1.ClusterHealthNodeReadiness] = 100 * inventory.Status.ReadyNodes / inventory.Status.Nodes
+	}
+	return &otev1.ClusterHealth{
+		Score:     score(factors),
+		Factors:   factors,
+		Timestamp: now.Unix(),
+	}
+}
+
+// linearScore returns 100 if value is within good, 0 if beyond bad, and linear in between.
+func linearScore(value, good, bad time.Duration) int {
+	switch {
+	case value <= good:
+		return 100
+	case value >= bad:
+		return 0
+	}
+	return int(100 * (bad - value) / (bad - good))
+}
+
+// score returns the weighted average of factors, 0 if none is known.
+func score(factors map[string]int) int {
+	sum, total := 0, 0
+	for factor, value := range factors {
+		sum += value * weights[factor]
+		total += weights[factor]
+	}
+	if total == 0 {
+		return 0
+	}
+	return sum / total
+}
+
+// patch patches health of cluster, which is not reported by clusters
+// and kept by status patched from reports.
+func (c *HealthController) patch(cluster *otev1.Cluster, health *otev1.ClusterHealth) error {
+	oldData, err := json.Marshal(cluster)
+	if err != nil {
+		return err
+	}
+	update := cluster.DeepCopy()
+	update.Status.Health = health
+	newData, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+	// factors unknown now are removed by the patch.
+	data, err := jsonpatch.CreateMergePatch(oldData, newData)
+	if err != nil {
+		return err
+	}
+	klog.V(3).Infof("update health of cluster %s to %d", cluster.Name, health.Score)
+	_, err = c.oteClient.OteV1().Clusters(cluster.Namespace).Patch(cluster.Name, types.MergePatchType, data)
+	return err
+}
+
+// Handler serves the latest health of clusters by cluster name in json,
+// or that of the cluster of query parameter cluster.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	healthsLock.RLock()
+	var data []byte
+	if name := r.URL.Query().Get("cluster"); name != "" {
+		health, ok := healths[name]
+		healthsLock.RUnlock()
+		if !ok {
+			http.Error(w, "health of cluster "+name+" is not computed", http.StatusNotFound)
+			return
+		}
+		data, _ = json.Marshal(health)
+	} else {
+		data, _ = json.Marshal(healths)
+		healthsLock.RUnlock()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	oteinformer "github.com/baidu/ote-stack/pkg/generated/informers/externalversions"
+	otefake "github.com/baidu/ote-stack/pkg/k8sclient/fake"
+)
+
+func newFakeCluster(name, status string, timestamp int64) *otev1.Cluster {
+	return &otev1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: otev1.ClusterNamespace},
+		Spec:       otev1.ClusterSpec{Name: name},
+		Status:     otev1.ClusterStatus{Status: status, Timestamp: timestamp},
+	}
+}
+
+// newFakeHealthController returns a controller with clusters added to client and indexers,
+// and indexers of inventories and clustercontrollers.
+func newFakeHealthController(clusters ...*otev1.Cluster) (*HealthController, cache.Indexer, cache.Indexer) {
+	var objs []runtime.Object
+	for _, cluster := range clusters {
+		objs = append(objs, cluster)
+	}
+	oteClient := otefake.NewSimpleClientset(objs...)
+	factory := oteinformer.NewSharedInformerFactory(oteClient, 0)
+	for _, cluster := range clusters {
+		factory.Ote().V1().Clusters().Informer().GetIndexer().Add(cluster)
+	}
+	c := &HealthController{
+		oteClient:       oteClient,
+		clusterLister:   factory.Ote().V1().Clusters().Lister(),
+		inventoryLister: factory.Ote().V1().ClusterInventories().Lister(),
+		ccLister:        factory.Ote().V1().ClusterControllers().Lister(),
+		reportLag: func(cluster string) (time.Duration, bool) {
+			if cluster == "c1" {
+				return time.Second, true
+			}
+			return 0, false
+		},
+		now: func() time.Time { return time.Unix(10000, 0) },
+	}
+	return c, factory.Ote().V1().ClusterInventories().Informer().GetIndexer(),
+		factory.Ote().V1().ClusterControllers().Informer().GetIndexer()
+}
+
+func TestLinearScore(t *testing.T) {
+	assert.Equal(t, 100, linearScore(time.Minute, 2*time.Minute, 10*time.Minute))
+	assert.Equal(t, 100, linearScore(2*time.Minute, 2*time.Minute, 10*time.Minute))
+	assert.Equal(t, 50, linearScore(6*time.Minute, 2*time.Minute, 10*time.Minute))
+	assert.Equal(t, 0, linearScore(10*time.Minute, 2*time.Minute, 10*time.Minute))
+	assert.Equal(t, 0, linearScore(time.Hour, 2*time.Minute, 10*time.Minute))
+}
+
+func TestScore(t *testing.T) {
+	assert.Equal(t, 0, score(nil))
+	assert.Equal(t, 50, score(map[string]int{otev1.ClusterHealthHeartbeat: 50}))
+	// (100*40 + 0*20) / 60
+	assert.Equal(t, 66, score(map[string]int{
+		otev1.ClusterHealthHeartbeat:      100,
+		otev1.ClusterHealthCommandSuccess: 0,
+	}))
+	assert.Equal(t, 80, score(map[string]int{
+		otev1.ClusterHealthHeartbeat:      100,
+		otev1.ClusterHealthReportLag:      100,
+		otev1.ClusterHealthCommandSuccess: 50,
+		otev1.ClusterHealthNodeReadiness:  50,
+	}))
+}
+
+func TestCompute(t *testing.T) {
+	c, inventories, ccs := newFakeHealthController()
+	inventories.Add(&otev1.ClusterInventory{
+		ObjectMeta: metav1.ObjectMeta{Name: "c1", Namespace: otev1.ClusterNamespace},
+		Status:     otev1.ClusterInventoryStatus{Nodes: 4, ReadyNodes: 3},
+	})
+	ccs.Add(&otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{Name: "cc1", Namespace: otev1.ClusterNamespace},
+		Status: map[string]otev1.ClusterControllerStatus{
+			"c1": {Timestamp: 9990, StatusCode: http.StatusOK},
+			"c2": {Timestamp: 9990, StatusCode: http.StatusPreconditionFailed, Reason: otev1.ClusterControllerStatusSkipped},
+		},
+	})
+	ccs.Add(&otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{Name: "cc2", Namespace: otev1.ClusterNamespace},
+		Status: map[string]otev1.ClusterControllerStatus{
+			"c1": {Timestamp: 9990, StatusCode: http.StatusGatewayTimeout, Reason: otev1.ClusterControllerStatusTimedOut},
+		},
+	})
+	ccs.Add(&otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{Name: "cc3", Namespace: otev1.ClusterNamespace},
+		Status: map[string]otev1.ClusterControllerStatus{
+			// out of window.
+			"c1": {Timestamp: 1000, StatusCode: http.StatusInternalServerError},
+		},
+	})
+	now := c.now()
+	commands, err := c.commandCounts(now)
+	assert.Nil(t, err)
+	assert.Equal(t, &commandCount{succeeded: 1, total: 2}, commands["c1"])
+	assert.Nil(t, commands["c2"])
+
+	health := c.compute(newFakeCluster("c1", otev1.ClusterStatusOnline, 9960), commands["c1"], now)
+	assert.Equal(t, map[string]int{
+		otev1.ClusterHealthHeartbeat:      100,
+		otev1.ClusterHealthReportLag:      100,
+		otev1.ClusterHealthCommandSuccess: 50,
+		otev1.ClusterHealthNodeReadiness:  75,
+	}, health.Factors)
+	assert.Equal(t, 85, health.Score)
+	assert.Equal(t, int64(10000), health.Timestamp)
+
+	// factors unknown are omitted.
+	health = c.compute(newFakeCluster("c2", otev1.ClusterStatusOnline, 9640), commands["c2"], now)
+	assert.Equal(t, map[string]int{otev1.ClusterHealthHeartbeat: 50}, health.Factors)
+	assert.Equal(t, 50, health.Score)
+
+	health = c.compute(newFakeCluster("c2", otev1.ClusterStatusOffline, 9960), nil, now)
+	assert.Equal(t, 0, health.Score)
+}
+
+func TestSync(t *testing.T) {
+	unchanged := newFakeCluster("c2", otev1.ClusterStatusOnline, 9960)
+	unchanged.Status.Health = &otev1.ClusterHealth{
+		Score:     100,
+		Factors:   map[string]int{otev1.ClusterHealthHeartbeat: 100},
+		Timestamp: 100,
+	}
+	stale := newFakeCluster("c1", otev1.ClusterStatusOffline, 9960)
+	stale.Status.Health = &otev1.ClusterHealth{
+		Score:     100,
+		Factors:   map[string]int{otev1.ClusterHealthHeartbeat: 100, otev1.ClusterHealthNodeReadiness: 100},
+		Timestamp: 100,
+	}
+	c, _, _ := newFakeHealthController(stale, unchanged)
+	defer func() {
+		healths = make(map[string]otev1.ClusterHealth)
+	}()
+	assert.Nil(t, c.sync())
+
+	clusters := c.oteClient.OteV1().Clusters(otev1.ClusterNamespace)
+	cluster, err := clusters.Get("c1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	// readiness unknown without inventory is removed.
+	assert.Equal(t, &otev1.ClusterHealth{
+		Score: 33,
+		Factors: map[string]int{
+			otev1.ClusterHealthHeartbeat: 0,
+			otev1.ClusterHealthReportLag: 100,
+		},
+		Timestamp: 10000,
+	}, cluster.Status.Health)
+	cluster, err = clusters.Get("c2", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(100), cluster.Status.Health.Timestamp)
+
+	w := httptest.NewRecorder()
+	Handler(w, httptest.NewRequest(http.MethodGet, "/cluster-health", nil))
+	all := make(map[string]otev1.ClusterHealth)
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &all))
+	assert.Equal(t, 33, all["c1"].Score)
+	assert.Equal(t, int64(100), all["c2"].Timestamp)
+
+	w = httptest.NewRecorder()
+	Handler(w, httptest.NewRequest(http.MethodGet, "/cluster-health?cluster=c3", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,353 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//Package inventory aggregates nodes reported by edge clusters into a ClusterInventory of each cluster,
+//which feeds schedulers and capacity dashboards without listing nodes of all clusters.
+package inventory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/controllermanager"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned"
+	otelisters "github.com/baidu/ote-stack/pkg/generated/listers/ote/v1"
+	"github.com/baidu/ote-stack/pkg/reporter"
+)
+
+// gpuResourceSuffix is the suffix of extended resources of gpus, e.g., nvidia.com/gpu.
+const gpuResourceSuffix = "/gpu"
+
+// syncInterval is the interval to recompute inventories of clusters whose nodes changed,
+// so that frequent node reports are batched.
+var syncInterval = 5 * time.Second
+
+//InventoryController recomputes the ClusterInventory of a cluster when nodes of the cluster
+//mirrored to center change. The inventory is deleted when the cluster has no node.
+type InventoryController struct {
+	oteClient       oteclient.Interface
+	k8sClient       kubernetes.Interface
+	nodeLister      corelisters.NodeLister
+	inventoryLister otelisters.ClusterInventoryLister
+	now             func() time.Time
+
+	lock sync.Mutex
+	// dirty are the clusters whose inventories are to recompute.
+	dirty map[string]bool
+}
+
+//InitInventoryController inits inventory controller.
+func InitInventoryController(ctx *controllermanager.ControllerContext) error {
+	c := &InventoryController{
+		oteClient:       ctx.OteClient,
+		k8sClient:       ctx.K8sClient,
+		nodeLister:      ctx.InformerFactory.Core().V1().Nodes().Lister(),
+		inventoryLister: ctx.OteInformerFactory.Ote().V1().ClusterInventories().Lister(),
+		now:             time.Now,
+		dirty:           make(map[string]bool),
+	}
+	ctx.InformerFactory.Core().V1().Nodes().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.handleNode,
+		UpdateFunc: func(old, new interface{}) {
+			if old.(*corev1.Node).ResourceVersion == new.(*corev1.Node).ResourceVersion {
+				return
+			}
+			c.handleNode(new)
+		},
+		DeleteFunc: c.handleNode,
+	})
+	// inventories listed on start are recomputed, in case nodes are deleted when stopped.
+	ctx.OteInformerFactory.Ote().V1().ClusterInventories().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			c.markDirty(obj.(*otev1.ClusterInventory).Name)
+		},
+	})
+	go c.run(ctx.StopChan)
+	return nil
+}
+
+func (c *InventoryController) handleNode(obj interface{}) {
+	node, ok := obj.(*corev1.Node)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			return
+		}
+		if node, ok = tombstone.Obj.(*corev1.Node); !ok {
+			return
+		}
+	}
+	if cluster := node.Labels[reporter.ClusterLabel]; cluster != "" {
+		c.markDirty(cluster)
+	}
+}
+
+func (c *InventoryController) markDirty(cluster string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.dirty[cluster] = true
+}
+
+// popDirty returns clusters marked dirty and clears them.
+func (c *InventoryController) popDirty() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	clusters := make([]string, 0, len(c.dirty))
+	for cluster := range c.dirty {
+		clusters = append(clusters, cluster)
+	}
+	c.dirty = make(map[string]bool)
+	return clusters
+}
+
+func (c *InventoryController) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		for _, cluster := range c.popDirty() {
+			if err := c.sync(cluster); err != nil {
+				klog.Errorf("sync inventory of cluster %s failed: %v", cluster, err)
+				c.markDirty(cluster)
+			}
+		}
+	}
+}
+
+// sync writes the inventory of cluster computed from its nodes.
+func (c *InventoryController) sync(cluster string) error {
+	nodes, err := c.nodeLister.List(labels.SelectorFromSet(labels.Set{reporter.ClusterLabel: cluster}))
+	if err != nil {
+		return err
+	}
+	inventories := c.oteClient.OteV1().ClusterInventories(otev1.ClusterNamespace)
+	existing, err := c.inventoryLister.ClusterInventories(otev1.ClusterNamespace).Get(cluster)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if len(nodes) == 0 {
+		if existing == nil {
+			return nil
+		}
+		klog.Infof("delete inventory of cluster %s without nodes", cluster)
+		err := inventories.Delete(cluster, &metav1.DeleteOptions{})
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	status := aggregate(cluster, nodes)
+	if existing == nil {
+		status.Timestamp = c.now().Unix()
+		created, err := inventories.Create(&otev1.ClusterInventory{
+			ObjectMeta: metav1.ObjectMeta{Name: cluster, Namespace: otev1.ClusterNamespace},
+			Status:     *status,
+		})
+		if err == nil {
+			c.recordProblems(created, nil, status.Problems)
+		}
+		return err
+	}
+	// quantities are compared semantically since those read from apiserver are formatted.
+	status.Timestamp = existing.Status.Timestamp
+	if equality.Semantic.DeepEqual(&existing.Status, status) {
+		return nil
+	}
+	klog.V(3).Infof("update inventory of cluster %s with %d nodes", cluster, status.Nodes)
+	inventory := existing.DeepCopy()
+	inventory.Status = *status
+	inventory.Status.Timestamp = c.now().Unix()
+	updated, err := inventories.Update(inventory)
+	if err == nil {
+		c.recordProblems(updated, existing.Status.Problems, status.Problems)
+	}
+	return err
+}
+
+// aggregate returns the inventory of nodes of cluster. Capacity is of all nodes,
+// and allocatable is of nodes ready, as that of cluster status.
+func aggregate(cluster string, nodes []*corev1.Node) *otev1.ClusterInventoryStatus {
+	status := &otev1.ClusterInventoryStatus{
+		Nodes:                    len(nodes),
+		KubeletVersions:          make(map[string]int),
+		ContainerRuntimeVersions: make(map[string]int),
+		KernelVersions:           make(map[string]int),
+		ClusterResource: otev1.ClusterResource{
+			Capacity:    make(map[corev1.ResourceName]*resource.Quantity),
+			Allocatable: make(map[corev1.ResourceName]*resource.Quantity),
+		},
+	}
+	for _, node := range nodes {
+		ready := isNodeReady(node)
+		if ready {
+			status.ReadyNodes++
+			addResources(status.Allocatable, node.Status.Allocatable)
+		}
+		addResources(status.Capacity, node.Status.Capacity)
+		for name, value := range node.Status.Capacity {
+			if !strings.HasSuffix(string(name), gpuResourceSuffix) {
+				continue
+			}
+			if status.GPUs == nil {
+				status.GPUs = make(map[corev1.ResourceName]int64)
+			}
+			status.GPUs[name] += value.Value()
+		}
+		info := node.Status.NodeInfo
+		countVersion(status.KubeletVersions, info.KubeletVersion)
+		countVersion(status.ContainerRuntimeVersions, info.ContainerRuntimeVersion)
+		countVersion(status.KernelVersions, info.KernelVersion)
+		status.Problems = append(status.Problems, nodeProblems(cluster, node)...)
+	}
+	sort.Slice(status.Problems, func(i, j int) bool {
+		a, b := status.Problems[i], status.Problems[j]
+		if a.Node != b.Node {
+			return a.Node < b.Node
+		}
+		return a.Type < b.Type
+	})
+	return status
+}
+
+// nodeProblems returns conditions true of node other than Ready, named by the node in cluster.
+// Conditions of kubelet and node-problem-detector are both false if the node is healthy.
+func nodeProblems(cluster string, node *corev1.Node) []otev1.NodeProblem {
+	var problems []otev1.NodeProblem
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady || cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		problems = append(problems, otev1.NodeProblem{
+			Node:    strings.TrimSuffix(node.Name, controllermanager.UniqueResourceNameSeparator+cluster),
+			Type:    cond.Type,
+			Reason:  cond.Reason,
+			Message: cond.Message,
+			Since:   cond.LastTransitionTime,
+		})
+	}
+	return problems
+}
+
+/*
+recordProblems creates events of inventory for node problems occurred or resolved
+from old to new, so that hardware problems of the fleet are seen by events of
+kube-system in center, besides events of nodes mirrored from clusters.
+*/
+func (c *InventoryController) recordProblems(inventory *otev1.ClusterInventory, old, new []otev1.NodeProblem) {
+	if c.k8sClient == nil {
+		return
+	}
+	key := func(p *otev1.NodeProblem) string {
+		return p.Node + "/" + string(p.Type)
+	}
+	// seq makes names of events recorded at the same time unique.
+	seq := 0
+	remaining := make(map[string]*otev1.NodeProblem, len(old))
+	for i := range old {
+		remaining[key(&old[i])] = &old[i]
+	}
+	for i := range new {
+		p := &new[i]
+		if _, ok := remaining[key(p)]; ok {
+			delete(remaining, key(p))
+			continue
+		}
+		seq++
+		c.recordEvent(inventory, seq, corev1.EventTypeWarning, "NodeProblem",
+			fmt.Sprintf("node %s has problem %s: %s %s", p.Node, p.Type, p.Reason, p.Message))
+	}
+	for i := range old {
+		p := &old[i]
+		if _, ok := remaining[key(p)]; !ok {
+			continue
+		}
+		seq++
+		c.recordEvent(inventory, seq, corev1.EventTypeNormal, "NodeProblemResolved",
+			fmt.Sprintf("node %s has no problem %s", p.Node, p.Type))
+	}
+}
+
+func (c *InventoryController) recordEvent(inventory *otev1.ClusterInventory, seq int,
+	eventType, reason, message string) {
+	now := metav1.NewTime(c.now())
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s.%x.%d", inventory.Name, now.UnixNano(), seq),
+			Namespace: inventory.Namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: otev1.SchemeGroupVersion.String(),
+			Kind:       "ClusterInventory",
+			Namespace:  inventory.Namespace,
+			Name:       inventory.Name,
+			UID:        inventory.UID,
+		},
+		Reason:         reason,
+		Message:        strings.TrimSpace(message),
+		Type:           eventType,
+		Count:          1,
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Source:         corev1.EventSource{Component: "ote-controller-manager"},
+	}
+	if _, err := c.k8sClient.CoreV1().Events(event.Namespace).Create(event); err != nil {
+		klog.Errorf("record event %s of cluster %s failed: %v", reason, inventory.Name, err)
+	}
+}
+
+func addResources(total map[corev1.ResourceName]*resource.Quantity, list corev1.ResourceList) {
+	for name, value := range list {
+		if _, exist := total[name]; !exist {
+			total[name] = &resource.Quantity{}
+		}
+		total[name].Add(value)
+	}
+}
+
+func countVersion(versions map[string]int, version string) {
+	if version != "" {
+		versions[version]++
+	}
+}
+
+func isNodeReady(node *corev1.Node) bool {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
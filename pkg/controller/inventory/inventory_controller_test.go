@@ -0,0 +1,219 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	oteinformer "github.com/baidu/ote-stack/pkg/generated/informers/externalversions"
+	otefake "github.com/baidu/ote-stack/pkg/k8sclient/fake"
+	"github.com/baidu/ote-stack/pkg/reporter"
+)
+
+func newFakeNode(name, cluster string, ready bool, cpu, gpu string) *corev1.Node {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	resources := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse(cpu),
+		corev1.ResourceMemory: resource.MustParse("4Gi"),
+	}
+	if gpu != "" {
+		resources["nvidia.com/gpu"] = resource.MustParse(gpu)
+	}
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name + "-" + cluster,
+			Labels: map[string]string{reporter.ClusterLabel: cluster},
+		},
+		Status: corev1.NodeStatus{
+			Capacity:    resources,
+			Allocatable: resources,
+			Conditions:  []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}},
+			NodeInfo: corev1.NodeSystemInfo{
+				KubeletVersion:          "v1.15.0",
+				ContainerRuntimeVersion: "docker://18.9.7",
+				KernelVersion:           "4.15.0",
+			},
+		},
+	}
+}
+
+// newFakeInventoryController returns a controller with indexers of nodes and inventories.
+func newFakeInventoryController() (*InventoryController, cache.Indexer, cache.Indexer) {
+	oteClient := otefake.NewSimpleClientset()
+	k8sClient := k8sfake.NewSimpleClientset()
+	factory := informers.NewSharedInformerFactory(k8sClient, 0)
+	oteFactory := oteinformer.NewSharedInformerFactory(oteClient, 0)
+	c := &InventoryController{
+		oteClient:       oteClient,
+		k8sClient:       k8sClient,
+		nodeLister:      factory.Core().V1().Nodes().Lister(),
+		inventoryLister: oteFactory.Ote().V1().ClusterInventories().Lister(),
+		now:             func() time.Time { return time.Unix(100, 0) },
+		dirty:           make(map[string]bool),
+	}
+	return c, factory.Core().V1().Nodes().Informer().GetIndexer(),
+		oteFactory.Ote().V1().ClusterInventories().Informer().GetIndexer()
+}
+
+func TestAggregate(t *testing.T) {
+	status := aggregate("c1", []*corev1.Node{
+		newFakeNode("n1", "c1", true, "2", "1"),
+		newFakeNode("n2", "c1", true, "4", "2"),
+		newFakeNode("n3", "c1", false, "8", ""),
+	})
+	assert.Equal(t, 3, status.Nodes)
+	assert.Equal(t, 2, status.ReadyNodes)
+	assert.Equal(t, int64(14), status.Capacity[corev1.ResourceCPU].Value())
+	assert.Equal(t, int64(6), status.Allocatable[corev1.ResourceCPU].Value())
+	assert.Equal(t, "12Gi", status.Capacity[corev1.ResourceMemory].String())
+	assert.Equal(t, map[corev1.ResourceName]int64{"nvidia.com/gpu": 3}, status.GPUs)
+	assert.Equal(t, map[string]int{"v1.15.0": 3}, status.KubeletVersions)
+	assert.Equal(t, map[string]int{"docker://18.9.7": 3}, status.ContainerRuntimeVersions)
+	assert.Equal(t, map[string]int{"4.15.0": 3}, status.KernelVersions)
+}
+
+func TestHandleNode(t *testing.T) {
+	c, _, _ := newFakeInventoryController()
+	c.handleNode(newFakeNode("n1", "c1", true, "2", ""))
+	c.handleNode(cache.DeletedFinalStateUnknown{Obj: newFakeNode("n1", "c2", true, "2", "")})
+	c.handleNode(&corev1.Node{})
+	c.handleNode(cache.DeletedFinalStateUnknown{})
+	assert.ElementsMatch(t, []string{"c1", "c2"}, c.popDirty())
+	assert.Empty(t, c.popDirty())
+}
+
+func TestSync(t *testing.T) {
+	c, nodes, inventories := newFakeInventoryController()
+	client := c.oteClient.OteV1().ClusterInventories(otev1.ClusterNamespace)
+
+	// no node and no inventory.
+	assert.Nil(t, c.sync("c1"))
+
+	nodes.Add(newFakeNode("n1", "c1", true, "2", ""))
+	nodes.Add(newFakeNode("n1", "c2", true, "2", ""))
+	assert.Nil(t, c.sync("c1"))
+	inventory, err := client.Get("c1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, inventory.Status.Nodes)
+	assert.Equal(t, int64(100), inventory.Status.Timestamp)
+
+	// unchanged inventory is not updated.
+	inventories.Add(inventory)
+	c.now = func() time.Time { return time.Unix(200, 0) }
+	assert.Nil(t, c.sync("c1"))
+	inventory, err = client.Get("c1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(100), inventory.Status.Timestamp)
+
+	nodes.Add(newFakeNode("n2", "c1", false, "4", ""))
+	assert.Nil(t, c.sync("c1"))
+	inventory, err = client.Get("c1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, inventory.Status.Nodes)
+	assert.Equal(t, 1, inventory.Status.ReadyNodes)
+	assert.Equal(t, int64(6), inventory.Status.Capacity[corev1.ResourceCPU].Value())
+	assert.Equal(t, int64(200), inventory.Status.Timestamp)
+
+	// inventory is deleted without nodes.
+	inventories.Update(inventory)
+	nodes.Delete(newFakeNode("n1", "c1", true, "2", ""))
+	nodes.Delete(newFakeNode("n2", "c1", false, "4", ""))
+	assert.Nil(t, c.sync("c1"))
+	_, err = client.Get("c1", metav1.GetOptions{})
+	assert.NotNil(t, err)
+}
+
+func TestNodeProblems(t *testing.T) {
+	node := newFakeNode("n1", "c1", false, "2", "")
+	assert.Empty(t, nodeProblems("c1", node))
+
+	since := metav1.Unix(50, 0)
+	node.Status.Conditions = append(node.Status.Conditions,
+		corev1.NodeCondition{Type: corev1.NodeDiskPressure, Status: corev1.ConditionFalse},
+		corev1.NodeCondition{Type: "KernelDeadlock", Status: corev1.ConditionTrue,
+			Reason: "DockerHung", Message: "task docker blocked", LastTransitionTime: since})
+	assert.Equal(t, []otev1.NodeProblem{{
+		Node:    "n1",
+		Type:    "KernelDeadlock",
+		Reason:  "DockerHung",
+		Message: "task docker blocked",
+		Since:   since,
+	}}, nodeProblems("c1", node))
+
+	n2 := newFakeNode("n2", "c1", true, "2", "")
+	n2.Status.Conditions = append(n2.Status.Conditions,
+		corev1.NodeCondition{Type: corev1.NodeMemoryPressure, Status: corev1.ConditionTrue})
+	status := aggregate("c1", []*corev1.Node{n2, node})
+	assert.Equal(t, 2, len(status.Problems))
+	assert.Equal(t, "n1", status.Problems[0].Node)
+	assert.Equal(t, corev1.NodeMemoryPressure, status.Problems[1].Type)
+}
+
+func TestRecordProblems(t *testing.T) {
+	c, nodes, inventories := newFakeInventoryController()
+	events := c.k8sClient.CoreV1().Events(otev1.ClusterNamespace)
+
+	node := newFakeNode("n1", "c1", true, "2", "")
+	node.Status.Conditions = append(node.Status.Conditions,
+		corev1.NodeCondition{Type: "KernelDeadlock", Status: corev1.ConditionTrue, Reason: "DockerHung"},
+		corev1.NodeCondition{Type: "ReadonlyFilesystem", Status: corev1.ConditionTrue})
+	nodes.Add(node)
+	assert.Nil(t, c.sync("c1"))
+	list, err := events.List(metav1.ListOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(list.Items))
+	for _, e := range list.Items {
+		assert.Equal(t, corev1.EventTypeWarning, e.Type)
+		assert.Equal(t, "NodeProblem", e.Reason)
+		assert.Equal(t, "ClusterInventory", e.InvolvedObject.Kind)
+		assert.Equal(t, "c1", e.InvolvedObject.Name)
+	}
+
+	// problems unchanged are not recorded again, and resolved ones are recorded.
+	inventory, _ := c.oteClient.OteV1().ClusterInventories(otev1.ClusterNamespace).Get("c1", metav1.GetOptions{})
+	inventories.Add(inventory)
+	node = node.DeepCopy()
+	node.Status.Conditions[2].Status = corev1.ConditionFalse
+	nodes.Update(node)
+	c.now = func() time.Time { return time.Unix(200, 0) }
+	assert.Nil(t, c.sync("c1"))
+	list, err = events.List(metav1.ListOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(list.Items))
+	resolved := 0
+	for _, e := range list.Items {
+		if e.Reason == "NodeProblemResolved" {
+			resolved++
+			assert.Equal(t, corev1.EventTypeNormal, e.Type)
+			assert.Contains(t, e.Message, "ReadonlyFilesystem")
+		}
+	}
+	assert.Equal(t, 1, resolved)
+}
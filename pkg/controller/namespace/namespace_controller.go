@@ -23,32 +23,53 @@ import (
 	"net/http"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog"
 
 	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
 	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
 	"github.com/baidu/ote-stack/pkg/controller"
 	"github.com/baidu/ote-stack/pkg/controllermanager"
 )
 
 //NamespaceController is responsible for performing actions dependent upon a namespace phase.
 type NamespaceController struct {
-	sendChan chan clustermessage.ClusterMessage
+	sendChan        chan clustermessage.ClusterMessage
+	namespaceLister corelisters.NamespaceLister
 }
 
 //InitNamespaceController inits namespace controller.
 func InitNamespaceController(ctx *controllermanager.ControllerContext) error {
+	informer := ctx.InformerFactory.Core().V1().Namespaces()
 	namespaceController := &NamespaceController{
-		sendChan: ctx.PublishChan,
+		sendChan:        ctx.PublishChan,
+		namespaceLister: informer.Lister(),
 	}
-	ctx.InformerFactory.Core().V1().Namespaces().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: namespaceController.handleAddedEvent,
 	})
+	go controller.RunPruner(controller.NamespaceOwner, namespaceController.declare,
+		ctx.PublishChan, ctx.StopChan, informer.Informer().HasSynced)
 
 	return nil
 }
 
+//declare declares all namespaces of center to be kept in clusters.
+func (c *NamespaceController) declare() ([]handler.PruneResource, error) {
+	namespaces, err := c.namespaceLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(namespaces))
+	for _, ns := range namespaces {
+		names = append(names, ns.Name)
+	}
+	return []handler.PruneResource{{URI: controller.OteNamespaceURI, Names: names}}, nil
+}
+
 //handleAddedEvent handles Added Event when new namespace was created,
 //and send the new namespace to all clusters.
 func (c *NamespaceController) handleAddedEvent(obj interface{}) {
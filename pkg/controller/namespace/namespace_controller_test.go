@@ -22,11 +22,13 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/informers"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
 
 	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/controller"
 	"github.com/baidu/ote-stack/pkg/controllermanager"
 )
 
@@ -82,3 +84,18 @@ func TestSendNamespaceToCluster(t *testing.T) {
 	err := fakeController.sendNamespaceToCluster(namespace)
 	assert.Nil(t, err)
 }
+
+func TestDeclare(t *testing.T) {
+	factory := informers.NewSharedInformerFactory(k8sfake.NewSimpleClientset(), noResyncPeriodFunc())
+	for _, name := range []string{"default", "ns1"} {
+		factory.Core().V1().Namespaces().Informer().GetIndexer().Add(
+			&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	c := newFakeNamespaceController()
+	c.namespaceLister = factory.Core().V1().Namespaces().Lister()
+	resources, err := c.declare()
+	assert.Nil(t, err)
+	assert.Len(t, resources, 1)
+	assert.Equal(t, controller.OteNamespaceURI, resources[0].URI)
+	assert.ElementsMatch(t, []string{"default", "ns1"}, resources[0].Names)
+}
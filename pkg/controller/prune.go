@@ -0,0 +1,90 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+)
+
+// PruneInterval is the interval to send pruning manifests of owners to all clusters,
+// 0 means objects distributed are never pruned.
+var PruneInterval time.Duration
+
+// DeclareFunc returns the resources an owner keeps in clusters.
+type DeclareFunc func() ([]handler.PruneResource, error)
+
+/*
+RunPruner sends the pruning manifest of owner declared by declare to all clusters every PruneInterval,
+so that objects labeled with owner but not declared are deleted by clusters.
+Manifests are only sent after synced, since objects not in informers yet are not declared.
+*/
+func RunPruner(owner string, declare DeclareFunc, sendChan chan clustermessage.ClusterMessage,
+	stop <-chan struct{}, synced ...cache.InformerSynced) {
+	if PruneInterval <= 0 {
+		return
+	}
+	if !cache.WaitForCacheSync(stop, synced...) {
+		return
+	}
+	klog.Infof("prune objects distributed by %s every %v", owner, PruneInterval)
+	ticker := time.NewTicker(PruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		msg, err := PruneManifestMessage(owner, declare)
+		if err != nil {
+			klog.Errorf("build pruning manifest of %s failed: %v", owner, err)
+			continue
+		}
+		sendChan <- *msg
+	}
+}
+
+// PruneManifestMessage returns the message of pruning manifest of owner to all clusters.
+func PruneManifestMessage(owner string, declare DeclareFunc) (*clustermessage.ClusterMessage, error) {
+	resources, err := declare()
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(&handler.PruneManifest{Owner: owner, Resources: resources})
+	if err != nil {
+		return nil, err
+	}
+	data := &clustermessage.ControllerTask{
+		Destination: otev1.ClusterControllerDestPrune,
+		Method:      http.MethodPost,
+		Body:        body,
+	}
+	head := &clustermessage.MessageHead{
+		ClusterSelector: "",
+		Command:         clustermessage.CommandType_ControlReq,
+	}
+	return data.ToClusterMessage(head)
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+)
+
+func TestPruneManifestMessage(t *testing.T) {
+	declared := []handler.PruneResource{{URI: OteNamespaceURI, Names: []string{"ns1"}}}
+	msg, err := PruneManifestMessage(NamespaceOwner, func() ([]handler.PruneResource, error) {
+		return declared, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, clustermessage.CommandType_ControlReq, msg.Head.Command)
+	task := &clustermessage.ControllerTask{}
+	assert.Nil(t, proto.Unmarshal(msg.Body, task))
+	assert.Equal(t, otev1.ClusterControllerDestPrune, task.Destination)
+	manifest := &handler.PruneManifest{}
+	assert.Nil(t, json.Unmarshal(task.Body, manifest))
+	assert.Equal(t, NamespaceOwner, manifest.Owner)
+	assert.Equal(t, declared, manifest.Resources)
+
+	_, err = PruneManifestMessage(NamespaceOwner, func() ([]handler.PruneResource, error) {
+		return nil, fmt.Errorf("not synced")
+	})
+	assert.NotNil(t, err)
+}
+
+func TestRunPruner(t *testing.T) {
+	declare := func() ([]handler.PruneResource, error) { return nil, nil }
+	sendChan := make(chan clustermessage.ClusterMessage, 1)
+	stop := make(chan struct{})
+
+	// disabled.
+	RunPruner(NamespaceOwner, declare, sendChan, stop)
+
+	PruneInterval = 10 * time.Millisecond
+	defer func() { PruneInterval = 0 }()
+	synced := make(chan struct{})
+	hasSynced := func() bool {
+		select {
+		case <-synced:
+			return true
+		default:
+			return false
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		RunPruner(NamespaceOwner, declare, sendChan, stop, hasSynced)
+		close(done)
+	}()
+	select {
+	case <-sendChan:
+		t.Errorf("manifest sent before synced")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(synced)
+	select {
+	case <-sendChan:
+	case <-time.After(time.Second):
+		t.Errorf("manifest not sent")
+	}
+	close(stop)
+	<-done
+}
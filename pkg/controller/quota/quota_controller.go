@@ -0,0 +1,336 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package quota records resources used by pods of clusters in ClusterQuotas,
+// and sends limits of ClusterQuotas to shims of clusters, which enforce them as a backstop.
+package quota
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/labels"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/controllermanager"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned"
+	otelisters "github.com/baidu/ote-stack/pkg/generated/listers/ote/v1"
+	"github.com/baidu/ote-stack/pkg/quota"
+	"github.com/baidu/ote-stack/pkg/reporter"
+)
+
+var (
+	// syncInterval is the interval to recompute usage of clusters whose pods changed,
+	// so that frequent pod reports are batched.
+	syncInterval = 5 * time.Second
+	// resendInterval is the interval to send limits to all clusters again,
+	// in case shims restarted and lost them.
+	resendInterval = 5 * time.Minute
+)
+
+// QuotaController recomputes resources used by pods mirrored to center of clusters
+// selected by ClusterQuotas into their status, which distribution checks tasks against,
+// and sends the limits of each cluster to its shim once they change.
+type QuotaController struct {
+	oteClient     oteclient.Interface
+	podLister     corelisters.PodLister
+	quotaLister   otelisters.ClusterQuotaLister
+	clusterLister otelisters.ClusterLister
+	sendChan      chan clustermessage.ClusterMessage
+
+	lock sync.Mutex
+	// dirty are the clusters whose usage is to recompute.
+	dirty map[string]bool
+	// all is true if all clusters are to recompute, e.g., quotas changed.
+	all bool
+	// sent are bodies of limits last sent by cluster.
+	sent map[string]string
+}
+
+// InitQuotaController inits quota controller.
+func InitQuotaController(ctx *controllermanager.ControllerContext) error {
+	c := &QuotaController{
+		oteClient:     ctx.OteClient,
+		podLister:     ctx.InformerFactory.Core().V1().Pods().Lister(),
+		quotaLister:   ctx.OteInformerFactory.Ote().V1().ClusterQuotas().Lister(),
+		clusterLister: ctx.OteInformerFactory.Ote().V1().Clusters().Lister(),
+		sendChan:      ctx.PublishChan,
+		dirty:         make(map[string]bool),
+		sent:          make(map[string]string),
+	}
+	ctx.InformerFactory.Core().V1().Pods().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.handlePod,
+		UpdateFunc: func(old, new interface{}) {
+			if old.(*corev1.Pod).ResourceVersion == new.(*corev1.Pod).ResourceVersion {
+				return
+			}
+			c.handlePod(new)
+		},
+		DeleteFunc: c.handlePod,
+	})
+	ctx.OteInformerFactory.Ote().V1().ClusterQuotas().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			c.markAll()
+		},
+		UpdateFunc: func(old, new interface{}) {
+			// status updated by this controller does not change usage or limits.
+			if !equality.Semantic.DeepEqual(old.(*otev1.ClusterQuota).Spec, new.(*otev1.ClusterQuota).Spec) {
+				c.markAll()
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			c.markAll()
+		},
+	})
+	// limits are sent to clusters once they are registered.
+	ctx.OteInformerFactory.Ote().V1().Clusters().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			c.markDirty(obj.(*otev1.Cluster).Spec.Name)
+		},
+	})
+	go c.run(ctx.StopChan)
+	return nil
+}
+
+func (c *QuotaController) handlePod(obj interface{}) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			return
+		}
+		if pod, ok = tombstone.Obj.(*corev1.Pod); !ok {
+			return
+		}
+	}
+	if cluster := pod.Labels[reporter.ClusterLabel]; cluster != "" {
+		c.markDirty(cluster)
+	}
+}
+
+func (c *QuotaController) markDirty(cluster string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if cluster != "" {
+		c.dirty[cluster] = true
+	}
+}
+
+func (c *QuotaController) markAll() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.all = true
+}
+
+// popDirty returns clusters marked dirty and clears them, and true if all clusters are marked.
+func (c *QuotaController) popDirty() ([]string, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	clusters := make([]string, 0, len(c.dirty))
+	for cluster := range c.dirty {
+		clusters = append(clusters, cluster)
+	}
+	all := c.all
+	c.dirty = make(map[string]bool)
+	c.all = false
+	return clusters, all
+}
+
+func (c *QuotaController) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+	lastSent := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		clusters, all := c.popDirty()
+		resend := time.Since(lastSent) >= resendInterval
+		if resend {
+			lastSent = time.Now()
+		}
+		if !all && !resend && len(clusters) == 0 {
+			continue
+		}
+		if err := c.sync(clusters, all || resend, resend); err != nil {
+			klog.Errorf("sync cluster quotas failed: %v", err)
+			for _, cluster := range clusters {
+				c.markDirty(cluster)
+			}
+			if all {
+				c.markAll()
+			}
+		}
+	}
+}
+
+/*
+sync validates ClusterQuotas, recomputes usage of clusters in their status,
+and sends limits of clusters changed to their shims. All known clusters are synced
+if all is true, and limits are sent even though unchanged if resend is true.
+*/
+func (c *QuotaController) sync(clusters []string, all, resend bool) error {
+	quotas, err := c.quotaLister.ClusterQuotas(otev1.ClusterNamespace).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	sort.Slice(quotas, func(i, j int) bool {
+		return quotas[i].Name < quotas[j].Name
+	})
+	// quotas changed are copied before modified.
+	changed := make(map[string]bool)
+	mutable := func(i int) *otev1.ClusterQuota {
+		if !changed[quotas[i].Name] {
+			quotas[i] = quotas[i].DeepCopy()
+			changed[quotas[i].Name] = true
+		}
+		return quotas[i]
+	}
+	for i, q := range quotas {
+		reason := ""
+		if err := quota.Validate(q); err != nil {
+			reason = err.Error()
+			klog.Errorf("clusterquota %s is invalid: %v", q.Name, err)
+		}
+		if q.Status.Error != reason {
+			mutable(i).Status.Error = reason
+		}
+	}
+
+	if all {
+		if clusters, err = c.allClusters(quotas, clusters); err != nil {
+			return err
+		}
+	}
+	for _, cluster := range clusters {
+		pods, err := c.podLister.List(labels.SelectorFromSet(labels.Set{reporter.ClusterLabel: cluster}))
+		if err != nil {
+			return err
+		}
+		used := quota.Usage(pods)
+		for i, q := range quotas {
+			existing, ok := q.Status.Used[cluster]
+			if !quota.Selects(q, cluster) {
+				if ok {
+					delete(mutable(i).Status.Used, cluster)
+				}
+				continue
+			}
+			if ok && equality.Semantic.DeepEqual(existing, used) {
+				continue
+			}
+			q = mutable(i)
+			if q.Status.Used == nil {
+				q.Status.Used = make(map[string]corev1.ResourceList)
+			}
+			q.Status.Used[cluster] = used
+		}
+		c.send(cluster, quota.Hard(quotas, cluster), resend)
+	}
+
+	for _, q := range quotas {
+		if !changed[q.Name] {
+			continue
+		}
+		klog.V(3).Infof("update status of clusterquota %s", q.Name)
+		if _, err := c.oteClient.OteV1().ClusterQuotas(q.Namespace).UpdateStatus(q); err != nil {
+			klog.Errorf("update status of clusterquota %s failed: %v", q.Name, err)
+		}
+	}
+	return nil
+}
+
+// allClusters returns clusters registered, those used in status of quotas, and clusters.
+func (c *QuotaController) allClusters(quotas []*otev1.ClusterQuota, clusters []string) ([]string, error) {
+	registered, err := c.clusterLister.Clusters(otev1.ClusterNamespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool)
+	for _, cluster := range clusters {
+		names[cluster] = true
+	}
+	for _, cluster := range registered {
+		names[cluster.Spec.Name] = true
+	}
+	for _, q := range quotas {
+		for cluster := range q.Status.Used {
+			names[cluster] = true
+		}
+	}
+	all := make([]string, 0, len(names))
+	for name := range names {
+		if name != "" {
+			all = append(all, name)
+		}
+	}
+	sort.Strings(all)
+	return all, nil
+}
+
+// send publishes hard of cluster to its shim if it changed since last sent, or resend is true.
+// Limits are cleared by a DELETE task if the cluster is not limited.
+func (c *QuotaController) send(cluster string, hard corev1.ResourceList, resend bool) {
+	task := &clustermessage.ControllerTask{
+		Destination: otev1.ClusterControllerDestQuota,
+		Method:      http.MethodDelete,
+	}
+	if hard != nil {
+		body, err := json.Marshal(hard)
+		if err != nil {
+			klog.Errorf("marshal quota of cluster %s failed: %v", cluster, err)
+			return
+		}
+		task.Method = http.MethodPut
+		task.Body = body
+	}
+
+	c.lock.Lock()
+	last, ok := c.sent[cluster]
+	if !resend && ((hard == nil && !ok) || (ok && last == string(task.Body))) {
+		c.lock.Unlock()
+		return
+	}
+	if hard == nil {
+		delete(c.sent, cluster)
+	} else {
+		c.sent[cluster] = string(task.Body)
+	}
+	c.lock.Unlock()
+
+	msg, err := task.ToClusterMessage(&clustermessage.MessageHead{
+		ClusterSelector: "^" + regexp.QuoteMeta(cluster) + "$",
+		Command:         clustermessage.CommandType_ControlReq,
+	})
+	if err != nil {
+		klog.Errorf("make quota task of cluster %s failed: %v", cluster, err)
+		return
+	}
+	klog.V(3).Infof("send quota %s of cluster %s", task.Method, cluster)
+	c.sendChan <- *msg
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	oteinformer "github.com/baidu/ote-stack/pkg/generated/informers/externalversions"
+	otefake "github.com/baidu/ote-stack/pkg/k8sclient/fake"
+	"github.com/baidu/ote-stack/pkg/reporter"
+)
+
+func newPod(name, cluster, cpu string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{reporter.ClusterLabel: cluster},
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu)},
+			},
+		}}},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+func newClusterQuota(name, selector string, hard corev1.ResourceList) *otev1.ClusterQuota {
+	return &otev1.ClusterQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: otev1.ClusterNamespace},
+		Spec:       otev1.ClusterQuotaSpec{ClusterSelector: selector, Hard: hard},
+	}
+}
+
+func newTestController(quotas []*otev1.ClusterQuota, pods []*corev1.Pod, clusters ...string) *QuotaController {
+	var objs []runtime.Object
+	oteFactory := oteinformer.NewSharedInformerFactory(otefake.NewSimpleClientset(), 0)
+	for _, q := range quotas {
+		objs = append(objs, q)
+		oteFactory.Ote().V1().ClusterQuotas().Informer().GetIndexer().Add(q)
+	}
+	for _, name := range clusters {
+		oteFactory.Ote().V1().Clusters().Informer().GetIndexer().Add(&otev1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: otev1.ClusterNamespace},
+			Spec:       otev1.ClusterSpec{Name: name},
+		})
+	}
+	factory := informers.NewSharedInformerFactory(k8sfake.NewSimpleClientset(), 0)
+	for _, pod := range pods {
+		factory.Core().V1().Pods().Informer().GetIndexer().Add(pod)
+	}
+	return &QuotaController{
+		oteClient:     otefake.NewSimpleClientset(objs...),
+		podLister:     factory.Core().V1().Pods().Lister(),
+		quotaLister:   oteFactory.Ote().V1().ClusterQuotas().Lister(),
+		clusterLister: oteFactory.Ote().V1().Clusters().Lister(),
+		sendChan:      make(chan clustermessage.ClusterMessage, 10),
+		dirty:         make(map[string]bool),
+		sent:          make(map[string]string),
+	}
+}
+
+func (c *QuotaController) getQuota(t *testing.T, name string) *otev1.ClusterQuota {
+	q, err := c.oteClient.OteV1().ClusterQuotas(otev1.ClusterNamespace).Get(name, metav1.GetOptions{})
+	assert.Nil(t, err)
+	return q
+}
+
+// receive returns tasks sent by cluster selector.
+func (c *QuotaController) receive(t *testing.T) map[string]*clustermessage.ControllerTask {
+	tasks := make(map[string]*clustermessage.ControllerTask)
+	for {
+		select {
+		case msg := <-c.sendChan:
+			task := &clustermessage.ControllerTask{}
+			assert.Nil(t, proto.Unmarshal(msg.Body, task))
+			tasks[msg.Head.ClusterSelector] = task
+		default:
+			return tasks
+		}
+	}
+}
+
+func TestSync(t *testing.T) {
+	hard := corev1.ResourceList{
+		corev1.ResourcePods: resource.MustParse("10"),
+		corev1.ResourceCPU:  resource.MustParse("4"),
+	}
+	invalid := newClusterQuota("invalid", "", corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")})
+	c := newTestController(
+		[]*otev1.ClusterQuota{newClusterQuota("edge", "^edge-", hard), invalid},
+		[]*corev1.Pod{
+			newPod("p1", "edge-1", "1"),
+			newPod("p2", "edge-1", "500m"),
+			newPod("p3", "c1", "1"),
+		},
+		"edge-1", "c1")
+
+	assert.Nil(t, c.sync(nil, true, false))
+
+	edge := c.getQuota(t, "edge")
+	assert.Equal(t, []string{"edge-1"}, keys(edge.Status.Used))
+	used := edge.Status.Used["edge-1"]
+	assert.Equal(t, int64(2), used.Pods().Value())
+	assert.Equal(t, int64(1500), used.Cpu().MilliValue())
+	assert.Contains(t, c.getQuota(t, "invalid").Status.Error, "not supported")
+
+	tasks := c.receive(t)
+	assert.Equal(t, 1, len(tasks))
+	task := tasks["^edge-1$"]
+	if assert.NotNil(t, task) {
+		assert.Equal(t, otev1.ClusterControllerDestQuota, task.Destination)
+		assert.Equal(t, http.MethodPut, task.Method)
+		sent := corev1.ResourceList{}
+		assert.Nil(t, json.Unmarshal(task.Body, &sent))
+		assert.Zero(t, hard.Pods().Cmp(*sent.Pods()))
+	}
+
+	// limits unchanged are not sent again unless resent.
+	assert.Nil(t, c.sync([]string{"edge-1"}, false, false))
+	assert.Empty(t, c.receive(t))
+	assert.Nil(t, c.sync([]string{"edge-1", "c1"}, false, true))
+	tasks = c.receive(t)
+	assert.Equal(t, http.MethodPut, tasks["^edge-1$"].Method)
+	assert.Equal(t, http.MethodDelete, tasks["^c1$"].Method)
+}
+
+func TestSyncClusterUnselected(t *testing.T) {
+	q := newClusterQuota("edge", "^edge-", corev1.ResourceList{corev1.ResourcePods: resource.MustParse("1")})
+	q.Status.Used = map[string]corev1.ResourceList{"c1": {corev1.ResourcePods: resource.MustParse("3")}}
+	c := newTestController([]*otev1.ClusterQuota{q}, nil)
+	c.sent["c1"] = "{}"
+
+	assert.Nil(t, c.sync(nil, true, false))
+	assert.Empty(t, c.getQuota(t, "edge").Status.Used)
+	// limits sent before are cleared.
+	tasks := c.receive(t)
+	assert.Equal(t, http.MethodDelete, tasks["^c1$"].Method)
+	assert.Empty(t, c.sent)
+}
+
+func TestPopDirty(t *testing.T) {
+	c := newTestController(nil, nil)
+	c.handlePod(newPod("p1", "c1", "1"))
+	c.handlePod(newPod("p2", "", "1"))
+	c.markAll()
+
+	clusters, all := c.popDirty()
+	assert.Equal(t, []string{"c1"}, clusters)
+	assert.True(t, all)
+	clusters, all = c.popDirty()
+	assert.Empty(t, clusters)
+	assert.False(t, all)
+}
+
+func keys(used map[string]corev1.ResourceList) []string {
+	var names []string
+	for name := range used {
+		names = append(names, name)
+	}
+	return names
+}
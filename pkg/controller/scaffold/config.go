@@ -0,0 +1,212 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scaffold
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"text/template"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// ClusterNameVariable is the variable of the cluster name, set for every cluster.
+const ClusterNameVariable = "clusterName"
+
+// Config is the scaffolds ensured on clusters.
+type Config struct {
+	// IntervalSeconds is the interval to ensure scaffolds, DefaultInterval if 0.
+	IntervalSeconds int        `json:"intervalSeconds,omitempty"`
+	Scaffolds       []Scaffold `json:"scaffolds"`
+}
+
+/*
+Scaffold is a set of Namespaces, Roles and RoleBindings ensured on clusters selected.
+Objects are templates of text/template in their json form, rendered for each cluster by
+Variables, overridden by ClusterVariables of the cluster, e.g., "tenant-{{.tenant}}".
+*/
+type Scaffold struct {
+	Name string `json:"name"`
+	// ClusterSelector is the clusters to ensure the scaffold on, in rules of cluster selector.
+	ClusterSelector string `json:"clusterSelector"`
+	// Variables are the variables of all clusters.
+	Variables map[string]string `json:"variables,omitempty"`
+	// ClusterVariables are the variables of each cluster by cluster name.
+	ClusterVariables map[string]map[string]string `json:"clusterVariables,omitempty"`
+
+	Namespaces   []corev1.Namespace   `json:"namespaces,omitempty"`
+	Roles        []rbacv1.Role        `json:"roles,omitempty"`
+	RoleBindings []rbacv1.RoleBinding `json:"roleBindings,omitempty"`
+}
+
+// LoadConfig reads scaffold config in yaml or json from file.
+func LoadConfig(file string) (*Config, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read scaffold config failed: %v", err)
+	}
+	conf := &Config{}
+	if err := yaml.Unmarshal(data, conf); err != nil {
+		return nil, fmt.Errorf("unmarshal scaffold config failed: %v", err)
+	}
+	names := make(map[string]bool)
+	for _, s := range conf.Scaffolds {
+		if s.Name == "" {
+			return nil, fmt.Errorf("scaffold has no name")
+		}
+		if names[s.Name] {
+			return nil, fmt.Errorf("scaffold %s is duplicated", s.Name)
+		}
+		names[s.Name] = true
+		if s.ClusterSelector == "" {
+			return nil, fmt.Errorf("scaffold %s has no cluster selector", s.Name)
+		}
+	}
+	return conf, nil
+}
+
+// object is an object of scaffold rendered for a cluster.
+type object struct {
+	kind      string
+	namespace string
+	name      string
+	body      []byte
+}
+
+// key returns the key of object in a cluster.
+func (o *object) key() string {
+	if o.namespace == "" {
+		return o.kind + "/" + o.name
+	}
+	return o.kind + "/" + o.namespace + "/" + o.name
+}
+
+// collectionURI returns the uri to create the object.
+func (o *object) collectionURI() string {
+	switch o.kind {
+	case "Namespace":
+		return "/api/v1/namespaces"
+	case "Role":
+		return "/apis/rbac.authorization.k8s.io/v1/namespaces/" + o.namespace + "/roles"
+	default:
+		return "/apis/rbac.authorization.k8s.io/v1/namespaces/" + o.namespace + "/rolebindings"
+	}
+}
+
+// uri returns the uri to update the object.
+func (o *object) uri() string {
+	return o.collectionURI() + "/" + o.name
+}
+
+// variables returns the variables of cluster.
+func (s *Scaffold) variables(cluster string) map[string]string {
+	vars := make(map[string]string, len(s.Variables)+1)
+	for k, v := range s.Variables {
+		vars[k] = v
+	}
+	for k, v := range s.ClusterVariables[cluster] {
+		vars[k] = v
+	}
+	vars[ClusterNameVariable] = cluster
+	return vars
+}
+
+// render returns objects of scaffold for cluster, Namespaces first, then Roles and RoleBindings.
+func (s *Scaffold) render(cluster string) ([]*object, error) {
+	vars := s.variables(cluster)
+	// values are escaped since they are rendered in json strings.
+	for k, v := range vars {
+		data, _ := json.Marshal(v)
+		vars[k] = string(data[1 : len(data)-1])
+	}
+
+	var objects []*object
+	for i := range s.Namespaces {
+		ns := &corev1.Namespace{}
+		if err := renderObject(&s.Namespaces[i], ns, vars); err != nil {
+			return nil, err
+		}
+		ns.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"}
+		ns.Namespace = ""
+		o, err := newObject(ns.Kind, "", ns.Name, ns)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, o)
+	}
+	for i := range s.Roles {
+		role := &rbacv1.Role{}
+		if err := renderObject(&s.Roles[i], role, vars); err != nil {
+			return nil, err
+		}
+		role.TypeMeta = metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "Role"}
+		o, err := newObject(role.Kind, role.Namespace, role.Name, role)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, o)
+	}
+	for i := range s.RoleBindings {
+		binding := &rbacv1.RoleBinding{}
+		if err := renderObject(&s.RoleBindings[i], binding, vars); err != nil {
+			return nil, err
+		}
+		binding.TypeMeta = metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "RoleBinding"}
+		o, err := newObject(binding.Kind, binding.Namespace, binding.Name, binding)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, o)
+	}
+	return objects, nil
+}
+
+// renderObject renders the json of in as a template with vars into out.
+func renderObject(in, out interface{}, vars map[string]string) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	t, err := template.New("").Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return fmt.Errorf("parse template failed: %v", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := t.Execute(buf, vars); err != nil {
+		return fmt.Errorf("render template failed: %v", err)
+	}
+	return json.Unmarshal(buf.Bytes(), out)
+}
+
+func newObject(kind, namespace, name string, obj interface{}) (*object, error) {
+	if name == "" {
+		return nil, fmt.Errorf("%s has no name", kind)
+	}
+	if kind != "Namespace" && namespace == "" {
+		return nil, fmt.Errorf("%s %s has no namespace", kind, name)
+	}
+	body, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	return &object{kind: kind, namespace: namespace, name: name, body: body}, nil
+}
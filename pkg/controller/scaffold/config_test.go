@@ -0,0 +1,133 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scaffold
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+const testConfig = `
+intervalSeconds: 60
+scaffolds:
+- name: tenant-a
+  clusterSelector: "^c[0-9]$"
+  variables:
+    tenant: a
+    admin: admin-a
+  clusterVariables:
+    c2:
+      admin: 'admin "c2"'
+  namespaces:
+  - metadata:
+      name: "tenant-{{.tenant}}"
+      labels:
+        cluster: "{{.clusterName}}"
+  roles:
+  - metadata:
+      name: developer
+      namespace: "tenant-{{.tenant}}"
+    rules:
+    - apiGroups: [""]
+      resources: ["pods"]
+      verbs: ["get", "list"]
+  roleBindings:
+  - metadata:
+      name: developer
+      namespace: "tenant-{{.tenant}}"
+    roleRef:
+      apiGroup: rbac.authorization.k8s.io
+      kind: Role
+      name: developer
+    subjects:
+    - kind: User
+      name: "{{.admin}}"
+`
+
+func writeConfig(t *testing.T, content string) (string, func()) {
+	dir, err := ioutil.TempDir("", "scaffold")
+	assert.Nil(t, err)
+	file := filepath.Join(dir, "scaffold.yaml")
+	assert.Nil(t, ioutil.WriteFile(file, []byte(content), 0644))
+	return file, func() { os.RemoveAll(dir) }
+}
+
+func TestLoadConfig(t *testing.T) {
+	file, clean := writeConfig(t, testConfig)
+	defer clean()
+	conf, err := LoadConfig(file)
+	assert.Nil(t, err)
+	assert.Equal(t, 60, conf.IntervalSeconds)
+	assert.Len(t, conf.Scaffolds, 1)
+	assert.Len(t, conf.Scaffolds[0].RoleBindings, 1)
+
+	for _, content := range []string{
+		"scaffolds:\n- clusterSelector: a\n",
+		"scaffolds:\n- name: a\n",
+		"scaffolds:\n- name: a\n  clusterSelector: a\n- name: a\n  clusterSelector: b\n",
+		"scaffolds: {",
+	} {
+		file, clean := writeConfig(t, content)
+		_, err := LoadConfig(file)
+		assert.NotNil(t, err, content)
+		clean()
+	}
+	_, err = LoadConfig("/not/exist")
+	assert.NotNil(t, err)
+}
+
+func TestRender(t *testing.T) {
+	file, clean := writeConfig(t, testConfig)
+	defer clean()
+	conf, err := LoadConfig(file)
+	assert.Nil(t, err)
+	s := &conf.Scaffolds[0]
+
+	objects, err := s.render("c1")
+	assert.Nil(t, err)
+	assert.Len(t, objects, 3)
+	assert.Equal(t, "Namespace/tenant-a", objects[0].key())
+	assert.Equal(t, "/api/v1/namespaces", objects[0].collectionURI())
+	assert.Contains(t, string(objects[0].body), `"cluster":"c1"`)
+	assert.Equal(t, "Role/tenant-a/developer", objects[1].key())
+	assert.Equal(t, "/apis/rbac.authorization.k8s.io/v1/namespaces/tenant-a/roles/developer", objects[1].uri())
+	assert.Equal(t, "/apis/rbac.authorization.k8s.io/v1/namespaces/tenant-a/rolebindings", objects[2].collectionURI())
+	binding := &rbacv1.RoleBinding{}
+	assert.Nil(t, json.Unmarshal(objects[2].body, binding))
+	assert.Equal(t, "RoleBinding", binding.Kind)
+	assert.Equal(t, "admin-a", binding.Subjects[0].Name)
+
+	// variables of cluster override, and are escaped in json.
+	objects, err = s.render("c2")
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal(objects[2].body, binding))
+	assert.Equal(t, `admin "c2"`, binding.Subjects[0].Name)
+
+	// variables missing.
+	s.Roles[0].Namespace = "{{.missing}}"
+	_, err = s.render("c1")
+	assert.NotNil(t, err)
+	s.Roles[0].Namespace = ""
+	_, err = s.render("c1")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,239 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//Package scaffold ensures declared Namespaces, Roles and RoleBindings exist on
+//selected edge clusters, so that tenants are onboarded consistently across clusters.
+package scaffold
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/clusterselector"
+	"github.com/baidu/ote-stack/pkg/controllermanager"
+	otelisters "github.com/baidu/ote-stack/pkg/generated/listers/ote/v1"
+)
+
+// DefaultInterval is the interval to ensure scaffolds by default.
+const DefaultInterval = 5 * time.Minute
+
+// request is a request of an object sent to a cluster, waiting for response.
+type request struct {
+	scaffold string
+	cluster  string
+	obj      *object
+	method   string
+	sent     time.Time
+	// followUp is true if the request follows a failed one, which is not followed again.
+	followUp bool
+}
+
+//ScaffoldController ensures scaffolds on clusters.
+type ScaffoldController struct {
+	conf          *Config
+	interval      time.Duration
+	selectors     []clusterselector.Selector
+	sendChan      chan clustermessage.ClusterMessage
+	clusterLister otelisters.ClusterLister
+
+	lock sync.Mutex
+	// pending are requests waiting for responses by message id.
+	pending map[string]*request
+}
+
+//NewInitFunc returns the InitFunc of scaffold controller by config.
+func NewInitFunc(conf *Config) controllermanager.InitFunc {
+	return func(ctx *controllermanager.ControllerContext) error {
+		informer := ctx.OteInformerFactory.Ote().V1().Clusters()
+		c := newScaffoldController(conf, ctx.PublishChan, informer.Lister())
+		controllermanager.RegistResponseHandler(c.handleResponse)
+		informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    c.handleClusterAdded,
+			UpdateFunc: c.handleClusterUpdated,
+		})
+		go c.run(ctx.StopChan)
+		return nil
+	}
+}
+
+func newScaffoldController(conf *Config, sendChan chan clustermessage.ClusterMessage,
+	clusterLister otelisters.ClusterLister) *ScaffoldController {
+	interval := time.Duration(conf.IntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	selectors := make([]clusterselector.Selector, len(conf.Scaffolds))
+	for i, s := range conf.Scaffolds {
+		selectors[i] = clusterselector.NewSelector(s.ClusterSelector)
+	}
+	return &ScaffoldController{
+		conf:          conf,
+		interval:      interval,
+		selectors:     selectors,
+		sendChan:      sendChan,
+		clusterLister: clusterLister,
+		pending:       make(map[string]*request),
+	}
+}
+
+func (c *ScaffoldController) run(stop <-chan struct{}) {
+	klog.Infof("ensure %d scaffolds on clusters every %v", len(c.conf.Scaffolds), c.interval)
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		c.expirePending(time.Now())
+		c.ensureAll()
+	}
+}
+
+// handleClusterAdded ensures scaffolds on clusters added online.
+func (c *ScaffoldController) handleClusterAdded(obj interface{}) {
+	cluster := obj.(*otev1.Cluster)
+	if cluster.Status.Status == otev1.ClusterStatusOnline {
+		go c.ensureCluster(cluster.Name)
+	}
+}
+
+// handleClusterUpdated ensures scaffolds on clusters getting online, e.g., clusters onboarded.
+func (c *ScaffoldController) handleClusterUpdated(old, new interface{}) {
+	oldCluster := old.(*otev1.Cluster)
+	newCluster := new.(*otev1.Cluster)
+	if oldCluster.Status.Status != otev1.ClusterStatusOnline &&
+		newCluster.Status.Status == otev1.ClusterStatusOnline {
+		go c.ensureCluster(newCluster.Name)
+	}
+}
+
+// ensureAll ensures scaffolds on all online clusters.
+func (c *ScaffoldController) ensureAll() {
+	clusters, err := c.clusterLister.Clusters(otev1.ClusterNamespace).List(labels.Everything())
+	if err != nil {
+		klog.Errorf("list clusters failed: %v", err)
+		return
+	}
+	for _, cluster := range clusters {
+		if cluster.Status.Status == otev1.ClusterStatusOnline {
+			c.ensureCluster(cluster.Name)
+		}
+	}
+}
+
+// ensureCluster creates objects of scaffolds selecting cluster name, objects existed are updated
+// when responded with conflict.
+func (c *ScaffoldController) ensureCluster(name string) {
+	for i := range c.conf.Scaffolds {
+		s := &c.conf.Scaffolds[i]
+		if !c.selectors[i].Has(name) {
+			continue
+		}
+		objects, err := s.render(name)
+		if err != nil {
+			klog.Errorf("render scaffold %s for cluster %s failed: %v", s.Name, name, err)
+			continue
+		}
+		for _, obj := range objects {
+			c.send(&request{scaffold: s.Name, cluster: name, obj: obj, method: http.MethodPost})
+		}
+	}
+}
+
+// send sends the request of an object to its cluster.
+func (c *ScaffoldController) send(req *request) {
+	uri := req.obj.collectionURI()
+	if req.method == http.MethodPut {
+		uri = req.obj.uri()
+	}
+	task := &clustermessage.ControllerTask{
+		Destination: otev1.ClusterControllerDestAPI,
+		Method:      req.method,
+		URI:         uri,
+		Body:        req.obj.body,
+	}
+	head := &clustermessage.MessageHead{
+		MessageID:       otev1.ClusterScaffoldMessagePrefix + string(uuid.NewUUID()),
+		ClusterSelector: "^" + regexp.QuoteMeta(req.cluster) + "$",
+		Command:         clustermessage.CommandType_ControlReq,
+	}
+	msg, err := task.ToClusterMessage(head)
+	if err != nil {
+		klog.Errorf("make request of %s to cluster %s failed: %v", req.obj.key(), req.cluster, err)
+		return
+	}
+	req.sent = time.Now()
+	c.lock.Lock()
+	c.pending[head.MessageID] = req
+	c.lock.Unlock()
+	c.sendChan <- *msg
+}
+
+// expirePending drops requests not responded in an interval, e.g., clusters got offline.
+func (c *ScaffoldController) expirePending(now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for id, req := range c.pending {
+		if now.Sub(req.sent) > c.interval {
+			klog.V(3).Infof("request of %s to cluster %s is not responded", req.obj.key(), req.cluster)
+			delete(c.pending, id)
+		}
+	}
+}
+
+// handleResponse handles responses of requests of scaffolds, objects existed are updated.
+func (c *ScaffoldController) handleResponse(msg *clustermessage.ClusterMessage) bool {
+	if !strings.HasPrefix(msg.Head.MessageID, otev1.ClusterScaffoldMessagePrefix) {
+		return false
+	}
+	c.lock.Lock()
+	req, ok := c.pending[msg.Head.MessageID]
+	delete(c.pending, msg.Head.MessageID)
+	c.lock.Unlock()
+	if !ok {
+		return true
+	}
+	resp := &clustermessage.ControllerTaskResponse{}
+	if err := proto.Unmarshal(msg.Body, resp); err != nil {
+		klog.Errorf("unmarshal response of %s from cluster %s failed: %v", req.obj.key(), req.cluster, err)
+		return true
+	}
+
+	switch {
+	case resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices:
+		klog.V(3).Infof("%s of scaffold %s is ensured on cluster %s", req.obj.key(), req.scaffold, req.cluster)
+	case resp.StatusCode == http.StatusConflict && req.method == http.MethodPost && !req.followUp:
+		go c.send(&request{scaffold: req.scaffold, cluster: req.cluster, obj: req.obj,
+			method: http.MethodPut, followUp: true})
+	default:
+		klog.Errorf("%s %s of scaffold %s on cluster %s failed: %d %s", req.method, req.obj.key(),
+			req.scaffold, req.cluster, resp.StatusCode, string(resp.Body))
+	}
+	return true
+}
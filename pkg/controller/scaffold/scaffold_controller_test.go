@@ -0,0 +1,131 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scaffold
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	fakeote "github.com/baidu/ote-stack/pkg/generated/clientset/versioned/fake"
+	oteinformer "github.com/baidu/ote-stack/pkg/generated/informers/externalversions"
+)
+
+func newCluster(name, status string) *otev1.Cluster {
+	return &otev1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: otev1.ClusterNamespace},
+		Status:     otev1.ClusterStatus{Status: status},
+	}
+}
+
+func newFakeScaffoldController(clusters ...*otev1.Cluster) *ScaffoldController {
+	factory := oteinformer.NewSharedInformerFactory(fakeote.NewSimpleClientset(), 0)
+	for _, c := range clusters {
+		factory.Ote().V1().Clusters().Informer().GetIndexer().Add(c)
+	}
+	conf := &Config{Scaffolds: []Scaffold{{
+		Name:            "tenant",
+		ClusterSelector: "^c",
+		Namespaces:      []corev1.Namespace{{ObjectMeta: metav1.ObjectMeta{Name: "ns-{{.clusterName}}"}}},
+	}}}
+	return newScaffoldController(conf, make(chan clustermessage.ClusterMessage, 10),
+		factory.Ote().V1().Clusters().Lister())
+}
+
+func receiveTask(t *testing.T, c *ScaffoldController) (*clustermessage.ClusterMessage, *clustermessage.ControllerTask) {
+	msg := <-c.sendChan
+	task := &clustermessage.ControllerTask{}
+	assert.Nil(t, proto.Unmarshal(msg.Body, task))
+	return &msg, task
+}
+
+func responseOf(t *testing.T, msg *clustermessage.ClusterMessage, code int32) *clustermessage.ClusterMessage {
+	data, err := proto.Marshal(&clustermessage.ControllerTaskResponse{StatusCode: code})
+	assert.Nil(t, err)
+	return &clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{MessageID: msg.Head.MessageID, Command: clustermessage.CommandType_ControlResp},
+		Body: data,
+	}
+}
+
+func TestEnsureAll(t *testing.T) {
+	c := newFakeScaffoldController(newCluster("c1", otev1.ClusterStatusOnline),
+		newCluster("c2", otev1.ClusterStatusOffline), newCluster("d1", otev1.ClusterStatusOnline))
+	assert.Equal(t, DefaultInterval, c.interval)
+	c.ensureAll()
+	assert.Len(t, c.sendChan, 1)
+	msg, task := receiveTask(t, c)
+	assert.Equal(t, "^c1$", msg.Head.ClusterSelector)
+	assert.Equal(t, otev1.ClusterControllerDestAPI, task.Destination)
+	assert.Equal(t, http.MethodPost, task.Method)
+	assert.Equal(t, "/api/v1/namespaces", task.URI)
+	assert.Contains(t, string(task.Body), `"name":"ns-c1"`)
+	assert.Len(t, c.pending, 1)
+
+	// requests not responded are dropped.
+	c.expirePending(time.Now())
+	assert.Len(t, c.pending, 1)
+	c.expirePending(time.Now().Add(2 * DefaultInterval))
+	assert.Empty(t, c.pending)
+}
+
+func TestClusterOnline(t *testing.T) {
+	c := newFakeScaffoldController()
+	c.handleClusterAdded(newCluster("c1", otev1.ClusterStatusOffline))
+	c.handleClusterUpdated(newCluster("c1", otev1.ClusterStatusOnline), newCluster("c1", otev1.ClusterStatusOnline))
+	c.handleClusterUpdated(newCluster("c1", otev1.ClusterStatusOffline), newCluster("c1", otev1.ClusterStatusOnline))
+	msg, _ := receiveTask(t, c)
+	assert.Equal(t, "^c1$", msg.Head.ClusterSelector)
+	c.handleClusterAdded(newCluster("c2", otev1.ClusterStatusOnline))
+	msg, _ = receiveTask(t, c)
+	assert.Equal(t, "^c2$", msg.Head.ClusterSelector)
+	assert.Empty(t, c.sendChan)
+}
+
+func TestScaffoldHandleResponse(t *testing.T) {
+	c := newFakeScaffoldController()
+	assert.False(t, c.handleResponse(&clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{MessageID: "proxy-1"},
+	}))
+
+	c.ensureCluster("c1")
+	msg, _ := receiveTask(t, c)
+	assert.True(t, c.handleResponse(responseOf(t, msg, http.StatusCreated)))
+	assert.Empty(t, c.pending)
+	assert.Empty(t, c.sendChan)
+	// response of request expired.
+	assert.True(t, c.handleResponse(responseOf(t, msg, http.StatusCreated)))
+
+	// object existed is updated, only once.
+	c.ensureCluster("c1")
+	msg, _ = receiveTask(t, c)
+	assert.True(t, c.handleResponse(responseOf(t, msg, http.StatusConflict)))
+	msg, task := receiveTask(t, c)
+	assert.Equal(t, http.MethodPut, task.Method)
+	assert.Equal(t, "/api/v1/namespaces/ns-c1", task.URI)
+	assert.True(t, c.handleResponse(responseOf(t, msg, http.StatusConflict)))
+	assert.Empty(t, c.pending)
+	time.Sleep(10 * time.Millisecond)
+	assert.Empty(t, c.sendChan)
+}
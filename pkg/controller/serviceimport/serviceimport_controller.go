@@ -0,0 +1,261 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//Package serviceimport imports services exported by edge clusters to all clusters,
+//so that an exported service can be discovered by its name and endpoints on other clusters.
+package serviceimport
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+	"github.com/baidu/ote-stack/pkg/controller"
+	"github.com/baidu/ote-stack/pkg/controllermanager"
+	"github.com/baidu/ote-stack/pkg/reporter"
+)
+
+const (
+	// Owner owns services and endpoints imported to clusters.
+	Owner = "serviceimport"
+
+	servicesURIFormat  = "/api/v1/namespaces/%s/services"
+	endpointsURIFormat = "/api/v1/namespaces/%s/endpoints"
+	servicesURI        = "/api/v1/services"
+	endpointsURI       = "/api/v1/endpoints"
+)
+
+//ServiceImportController sends services exported by edge clusters and their endpoints
+//mirrored in center to all clusters.
+//An exported service svc of cluster c1 is imported as a service without selector
+//named svc-c1 in the same namespace, with endpoints reported by c1.
+type ServiceImportController struct {
+	sendChan      chan clustermessage.ClusterMessage
+	serviceLister corelisters.ServiceLister
+}
+
+//InitServiceImportController inits serviceimport controller.
+func InitServiceImportController(ctx *controllermanager.ControllerContext) error {
+	c := &ServiceImportController{
+		sendChan:      ctx.PublishChan,
+		serviceLister: ctx.InformerFactory.Core().V1().Services().Lister(),
+	}
+	ctx.InformerFactory.Core().V1().Services().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.handleServiceAdded,
+		UpdateFunc: c.handleServiceUpdated,
+		DeleteFunc: c.handleServiceDeleted,
+	})
+	ctx.InformerFactory.Core().V1().Endpoints().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.handleEndpoints,
+		UpdateFunc: func(old, new interface{}) {
+			if old.(*corev1.Endpoints).ResourceVersion == new.(*corev1.Endpoints).ResourceVersion {
+				return
+			}
+			c.handleEndpoints(new)
+		},
+	})
+	go controller.RunPruner(Owner, c.declare, ctx.PublishChan, ctx.StopChan,
+		ctx.InformerFactory.Core().V1().Services().Informer().HasSynced)
+	return nil
+}
+
+//declare declares services and endpoints imported for all exported services to be kept in clusters.
+func (c *ServiceImportController) declare() ([]handler.PruneResource, error) {
+	services, err := c.serviceLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, service := range services {
+		if isImportable(service) {
+			names = append(names, service.Namespace+"/"+service.Name)
+		}
+	}
+	return []handler.PruneResource{
+		{URI: servicesURI, Names: names},
+		{URI: endpointsURI, Names: names},
+	}, nil
+}
+
+// isImportable returns true if the service is an exported service mirrored from edge cluster.
+func isImportable(service *corev1.Service) bool {
+	return reporter.IsServiceExported(service) && service.Labels[reporter.ClusterLabel] != ""
+}
+
+func (c *ServiceImportController) handleServiceAdded(obj interface{}) {
+	service := obj.(*corev1.Service)
+	if !isImportable(service) {
+		return
+	}
+	klog.V(3).Infof("import service %s/%s to all clusters", service.Namespace, service.Name)
+	if err := c.createService(service); err != nil {
+		klog.Errorf("import service %s failed: %v", service.Name, err)
+	}
+}
+
+func (c *ServiceImportController) handleServiceUpdated(oldObj, newObj interface{}) {
+	old := oldObj.(*corev1.Service)
+	service := newObj.(*corev1.Service)
+	importable := isImportable(service)
+	if isImportable(old) && !importable {
+		c.handleServiceDeleted(old)
+		return
+	}
+	if !importable {
+		return
+	}
+	if !isImportable(old) {
+		c.handleServiceAdded(service)
+		return
+	}
+	if reflect.DeepEqual(old.Spec.Ports, service.Spec.Ports) {
+		return
+	}
+	klog.V(3).Infof("ports of imported service %s/%s changed", service.Namespace, service.Name)
+	if err := c.patchServicePorts(service); err != nil {
+		klog.Errorf("update imported service %s failed: %v", service.Name, err)
+	}
+}
+
+func (c *ServiceImportController) handleServiceDeleted(obj interface{}) {
+	service, ok := obj.(*corev1.Service)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			return
+		}
+		if service, ok = tombstone.Obj.(*corev1.Service); !ok {
+			return
+		}
+	}
+	if !isImportable(service) {
+		return
+	}
+	klog.V(3).Infof("delete imported service %s/%s from all clusters", service.Namespace, service.Name)
+	for _, format := range []string{servicesURIFormat, endpointsURIFormat} {
+		uri := fmt.Sprintf(format, service.Namespace) + "/" + service.Name
+		if err := c.send(http.MethodDelete, uri, nil); err != nil {
+			klog.Errorf("delete imported service %s failed: %v", service.Name, err)
+		}
+	}
+}
+
+//handleEndpoints sends endpoints of imported service to all clusters,
+//endpoints are created if not exist on update.
+func (c *ServiceImportController) handleEndpoints(obj interface{}) {
+	endpoints := obj.(*corev1.Endpoints)
+	service, err := c.serviceLister.Services(endpoints.Namespace).Get(endpoints.Name)
+	if err != nil || !isImportable(service) {
+		return
+	}
+	imported := &corev1.Endpoints{
+		TypeMeta: metav1.TypeMeta{Kind: "Endpoints", APIVersion: "v1"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      endpoints.Name,
+			Namespace: endpoints.Namespace,
+			Labels:    importLabels(service),
+		},
+		Subsets: endpoints.Subsets,
+	}
+	body, err := json.Marshal(imported)
+	if err != nil {
+		klog.Errorf("marshal imported endpoints %s failed: %v", endpoints.Name, err)
+		return
+	}
+	uri := fmt.Sprintf(endpointsURIFormat, endpoints.Namespace) + "/" + endpoints.Name
+	if err := c.send(http.MethodPut, uri, body); err != nil {
+		klog.Errorf("import endpoints %s failed: %v", endpoints.Name, err)
+	}
+}
+
+func (c *ServiceImportController) createService(service *corev1.Service) error {
+	body, err := json.Marshal(importedService(service))
+	if err != nil {
+		return err
+	}
+	return c.send(http.MethodPost, fmt.Sprintf(servicesURIFormat, service.Namespace), body)
+}
+
+func (c *ServiceImportController) patchServicePorts(service *corev1.Service) error {
+	patch := []map[string]interface{}{
+		{"op": "replace", "path": "/spec/ports", "value": importedService(service).Spec.Ports},
+	}
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	uri := fmt.Sprintf(servicesURIFormat, service.Namespace) + "/" + service.Name
+	return c.send(http.MethodPatch, uri, body)
+}
+
+func (c *ServiceImportController) send(method, uri string, body []byte) error {
+	data := &clustermessage.ControllerTask{
+		Destination: otev1.ClusterControllerDestAPI,
+		Method:      method,
+		URI:         uri,
+		Body:        body,
+	}
+	head := &clustermessage.MessageHead{
+		ClusterSelector: "",
+		Command:         clustermessage.CommandType_ControlReq,
+	}
+	msg, err := data.ToClusterMessage(head)
+	if err != nil {
+		return err
+	}
+
+	c.sendChan <- *msg
+	return nil
+}
+
+// importedService returns the service to create in other clusters for an exported service.
+func importedService(service *corev1.Service) *corev1.Service {
+	ports := make([]corev1.ServicePort, 0, len(service.Spec.Ports))
+	for _, p := range service.Spec.Ports {
+		p.NodePort = 0
+		ports = append(ports, p)
+	}
+	return &corev1.Service{
+		TypeMeta: metav1.TypeMeta{Kind: "Service", APIVersion: "v1"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      service.Name,
+			Namespace: service.Namespace,
+			Labels:    importLabels(service),
+		},
+		Spec: corev1.ServiceSpec{
+			Type:  corev1.ServiceTypeClusterIP,
+			Ports: ports,
+		},
+	}
+}
+
+func importLabels(service *corev1.Service) map[string]string {
+	return map[string]string{
+		reporter.ServiceImportLabel: service.Labels[reporter.ClusterLabel],
+		handler.OwnerLabel:          Owner,
+	}
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serviceimport
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+	"github.com/baidu/ote-stack/pkg/controllermanager"
+	"github.com/baidu/ote-stack/pkg/reporter"
+)
+
+func newFakeService(exported bool) *corev1.Service {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "svc-c1",
+			Namespace: "default",
+			Labels:    map[string]string{reporter.ClusterLabel: "c1"},
+		},
+		Spec: corev1.ServiceSpec{
+			Type:  corev1.ServiceTypeNodePort,
+			Ports: []corev1.ServicePort{{Name: "http", Port: 80, NodePort: 30080}},
+		},
+	}
+	if exported {
+		service.Labels[reporter.ServiceExportLabel] = "true"
+	}
+	return service
+}
+
+func newFakeServiceImportController(services ...*corev1.Service) *ServiceImportController {
+	factory := informers.NewSharedInformerFactory(k8sfake.NewSimpleClientset(), 0)
+	for _, s := range services {
+		factory.Core().V1().Services().Informer().GetIndexer().Add(s)
+	}
+	return &ServiceImportController{
+		sendChan:      make(chan clustermessage.ClusterMessage, 10),
+		serviceLister: factory.Core().V1().Services().Lister(),
+	}
+}
+
+func receiveTask(t *testing.T, c *ServiceImportController) *clustermessage.ControllerTask {
+	select {
+	case msg := <-c.sendChan:
+		assert.Equal(t, clustermessage.CommandType_ControlReq, msg.Head.Command)
+		assert.Equal(t, "", msg.Head.ClusterSelector)
+		task := &clustermessage.ControllerTask{}
+		assert.Nil(t, proto.Unmarshal(msg.Body, task))
+		return task
+	default:
+		return nil
+	}
+}
+
+func TestInitServiceImportController(t *testing.T) {
+	ctx := &controllermanager.ControllerContext{
+		K8sContext: controllermanager.K8sContext{
+			InformerFactory: informers.NewSharedInformerFactory(k8sfake.NewSimpleClientset(), 0),
+		},
+	}
+	assert.Nil(t, InitServiceImportController(ctx))
+}
+
+func TestHandleServiceAdded(t *testing.T) {
+	c := newFakeServiceImportController()
+	c.handleServiceAdded(newFakeService(false))
+	assert.Nil(t, receiveTask(t, c))
+
+	c.handleServiceAdded(newFakeService(true))
+	task := receiveTask(t, c)
+	assert.NotNil(t, task)
+	assert.Equal(t, http.MethodPost, task.Method)
+	assert.Equal(t, "/api/v1/namespaces/default/services", task.URI)
+	service := &corev1.Service{}
+	assert.Nil(t, json.Unmarshal(task.Body, service))
+	assert.Equal(t, "svc-c1", service.Name)
+	assert.Equal(t, "c1", service.Labels[reporter.ServiceImportLabel])
+	assert.Empty(t, service.Labels[reporter.ServiceExportLabel])
+	assert.Equal(t, corev1.ServiceTypeClusterIP, service.Spec.Type)
+	assert.Nil(t, service.Spec.Selector)
+	assert.Equal(t, int32(0), service.Spec.Ports[0].NodePort)
+}
+
+func TestHandleServiceUpdated(t *testing.T) {
+	c := newFakeServiceImportController()
+	exported := newFakeService(true)
+	c.handleServiceUpdated(exported, exported)
+	assert.Nil(t, receiveTask(t, c))
+
+	// ports changed
+	changed := exported.DeepCopy()
+	changed.Spec.Ports[0].Port = 8080
+	c.handleServiceUpdated(exported, changed)
+	task := receiveTask(t, c)
+	assert.Equal(t, http.MethodPatch, task.Method)
+	assert.Equal(t, "/api/v1/namespaces/default/services/svc-c1", task.URI)
+	assert.Contains(t, string(task.Body), `"path":"/spec/ports"`)
+
+	// exported
+	c.handleServiceUpdated(newFakeService(false), exported)
+	assert.Equal(t, http.MethodPost, receiveTask(t, c).Method)
+
+	// unexported
+	c.handleServiceUpdated(exported, newFakeService(false))
+	assert.Equal(t, http.MethodDelete, receiveTask(t, c).Method)
+	assert.Equal(t, http.MethodDelete, receiveTask(t, c).Method)
+	assert.Nil(t, receiveTask(t, c))
+}
+
+func TestHandleServiceDeleted(t *testing.T) {
+	c := newFakeServiceImportController()
+	c.handleServiceDeleted(newFakeService(false))
+	assert.Nil(t, receiveTask(t, c))
+
+	c.handleServiceDeleted(newFakeService(true))
+	task := receiveTask(t, c)
+	assert.Equal(t, http.MethodDelete, task.Method)
+	assert.Equal(t, "/api/v1/namespaces/default/services/svc-c1", task.URI)
+	task = receiveTask(t, c)
+	assert.Equal(t, "/api/v1/namespaces/default/endpoints/svc-c1", task.URI)
+}
+
+func TestHandleEndpoints(t *testing.T) {
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "svc-c1", Namespace: "default", ResourceVersion: "3"},
+		Subsets: []corev1.EndpointSubset{
+			{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}}},
+		},
+	}
+
+	c := newFakeServiceImportController()
+	c.handleEndpoints(endpoints)
+	assert.Nil(t, receiveTask(t, c))
+	c = newFakeServiceImportController(newFakeService(false))
+	c.handleEndpoints(endpoints)
+	assert.Nil(t, receiveTask(t, c))
+
+	c = newFakeServiceImportController(newFakeService(true))
+	c.handleEndpoints(endpoints)
+	task := receiveTask(t, c)
+	assert.Equal(t, http.MethodPut, task.Method)
+	assert.Equal(t, "/api/v1/namespaces/default/endpoints/svc-c1", task.URI)
+	imported := &corev1.Endpoints{}
+	assert.Nil(t, json.Unmarshal(task.Body, imported))
+	assert.Equal(t, "", imported.ResourceVersion)
+	assert.Equal(t, "c1", imported.Labels[reporter.ServiceImportLabel])
+	assert.Equal(t, "10.0.0.1", imported.Subsets[0].Addresses[0].IP)
+}
+
+func TestDeclare(t *testing.T) {
+	other := newFakeService(false)
+	other.Name = "svc-other"
+	c := newFakeServiceImportController(newFakeService(true), other)
+	resources, err := c.declare()
+	assert.Nil(t, err)
+	assert.Equal(t, []handler.PruneResource{
+		{URI: servicesURI, Names: []string{"default/svc-c1"}},
+		{URI: endpointsURI, Names: []string{"default/svc-c1"}},
+	}, resources)
+	assert.Equal(t, Owner, importedService(newFakeService(true)).Labels[handler.OwnerLabel])
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package transform applies ResourceTransforms to resources reported by edge clusters
+// before upstream processor writes them to the center.
+package transform
+
+import (
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/controllermanager"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned"
+	otelisters "github.com/baidu/ote-stack/pkg/generated/listers/ote/v1"
+)
+
+// TransformController sets transforms of upstream processor by ResourceTransforms whenever
+// they change, and records errors of invalid ones in their status.
+type TransformController struct {
+	oteClient oteclient.Interface
+	rtLister  otelisters.ResourceTransformLister
+}
+
+// InitTransformController inits transform controller.
+func InitTransformController(ctx *controllermanager.ControllerContext) error {
+	informer := ctx.OteInformerFactory.Ote().V1().ResourceTransforms()
+	c := &TransformController{
+		oteClient: ctx.OteClient,
+		rtLister:  informer.Lister(),
+	}
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			c.sync()
+		},
+		UpdateFunc: func(old, new interface{}) {
+			if old.(*otev1.ResourceTransform).ResourceVersion != new.(*otev1.ResourceTransform).ResourceVersion {
+				c.sync()
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			c.sync()
+		},
+	})
+	return nil
+}
+
+// sync replaces transforms of upstream processor by all ResourceTransforms.
+func (c *TransformController) sync() {
+	rts, err := c.rtLister.ResourceTransforms(otev1.ClusterNamespace).List(labels.Everything())
+	if err != nil {
+		klog.Errorf("list resourcetransforms failed: %v", err)
+		return
+	}
+	errs := controllermanager.SetResourceTransforms(rts)
+	klog.V(3).Infof("%d resourcetransforms are set, %d invalid", len(rts)-len(errs), len(errs))
+
+	for _, rt := range rts {
+		reason := ""
+		if err := errs[rt.Name]; err != nil {
+			reason = err.Error()
+			klog.Errorf("resourcetransform %s is invalid: %v", rt.Name, err)
+		}
+		if rt.Status.Error == reason {
+			continue
+		}
+		rt = rt.DeepCopy()
+		rt.Status.Error = reason
+		if _, err := c.oteClient.OteV1().ResourceTransforms(rt.Namespace).UpdateStatus(rt); err != nil {
+			klog.Errorf("update status of resourcetransform %s failed: %v", rt.Name, err)
+		}
+	}
+}
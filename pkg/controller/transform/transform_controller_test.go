@@ -0,0 +1,66 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package transform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/controllermanager"
+	oteinformer "github.com/baidu/ote-stack/pkg/generated/informers/externalversions"
+	otefake "github.com/baidu/ote-stack/pkg/k8sclient/fake"
+)
+
+func newResourceTransform(name, apiVersion, errorReason string) *otev1.ResourceTransform {
+	return &otev1.ResourceTransform{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: otev1.ClusterNamespace},
+		Spec: otev1.ResourceTransformSpec{
+			APIVersion: apiVersion,
+			Kind:       "Pod",
+			Labels:     map[string]string{"region": "bj"},
+		},
+		Status: otev1.ResourceTransformStatus{Error: errorReason},
+	}
+}
+
+func TestSync(t *testing.T) {
+	defer controllermanager.SetResourceTransforms(nil)
+
+	rts := []*otev1.ResourceTransform{
+		newResourceTransform("valid", "v1", ""),
+		newResourceTransform("invalid", "", ""),
+		newResourceTransform("fixed", "v1", "apiVersion and kind are required"),
+	}
+	oteClient := otefake.NewSimpleClientset(rts[0], rts[1], rts[2])
+	informer := oteinformer.NewSharedInformerFactory(oteClient, 0).Ote().V1().ResourceTransforms()
+	for _, rt := range rts {
+		informer.Informer().GetIndexer().Add(rt)
+	}
+	c := &TransformController{oteClient: oteClient, rtLister: informer.Lister()}
+	c.sync()
+
+	get := func(name string) *otev1.ResourceTransform {
+		rt, err := oteClient.OteV1().ResourceTransforms(otev1.ClusterNamespace).Get(name, metav1.GetOptions{})
+		assert.Nil(t, err)
+		return rt
+	}
+	assert.Empty(t, get("valid").Status.Error)
+	assert.Contains(t, get("invalid").Status.Error, "apiVersion and kind are required")
+	assert.Empty(t, get("fixed").Status.Error)
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade
+
+import (
+	"fmt"
+	"sort"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clusterselector"
+	"github.com/baidu/ote-stack/pkg/config"
+)
+
+const (
+	// OrderTopDown upgrades parents before children.
+	OrderTopDown = "top-down"
+	// OrderBottomUp upgrades children before parents.
+	OrderBottomUp = "bottom-up"
+)
+
+func validOrder(order string) error {
+	if order != OrderTopDown && order != OrderBottomUp {
+		return fmt.Errorf("order %s is not %s or %s", order, OrderTopDown, OrderBottomUp)
+	}
+	return nil
+}
+
+// planWaves groups clusters matched by selector into waves by their depth in the tree,
+// clusters in a wave are upgraded together. Root is not included.
+func planWaves(clusters []otev1.Cluster, order, selector string) [][]string {
+	parents := make(map[string]string, len(clusters))
+	for i := range clusters {
+		parents[clusters[i].ObjectMeta.Name] = clusters[i].Status.ParentName
+	}
+
+	var s clusterselector.Selector
+	if selector != "" {
+		s = clusterselector.NewSelector(selector)
+	}
+	byDepth := make(map[int][]string)
+	maxDepth := 0
+	for i := range clusters {
+		name := clusters[i].ObjectMeta.Name
+		if config.IsRoot(name) || (s != nil && !s.Has(clusters[i].Spec.Name)) {
+			continue
+		}
+		depth := depthOf(name, parents)
+		byDepth[depth] = append(byDepth[depth], name)
+		if depth > maxDepth {
+			maxDepth = depth
+		}
+	}
+
+	var waves [][]string
+	for depth := 1; depth <= maxDepth; depth++ {
+		wave, ok := byDepth[depth]
+		if !ok {
+			continue
+		}
+		sort.Strings(wave)
+		waves = append(waves, wave)
+	}
+	if order == OrderBottomUp {
+		for i, j := 0, len(waves)-1; i < j; i, j = i+1, j-1 {
+			waves[i], waves[j] = waves[j], waves[i]
+		}
+	}
+	return waves
+}
+
+// depthOf returns the depth of cluster in the tree, children of root are 1.
+// A cluster whose parent is not registered is taken as a child of root.
+func depthOf(name string, parents map[string]string) int {
+	depth := 1
+	visited := map[string]bool{name: true}
+	for {
+		parent, ok := parents[name]
+		if !ok || config.IsRoot(parent) || visited[parent] {
+			return depth
+		}
+		if _, ok := parents[parent]; !ok {
+			return depth
+		}
+		visited[parent] = true
+		name = parent
+		depth++
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package upgrade
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+)
+
+func newCluster(name, parent, version string, online bool) otev1.Cluster {
+	status := otev1.ClusterStatusOffline
+	if online {
+		status = otev1.ClusterStatusOnline
+	}
+	protocol := 0
+	if version != "" {
+		protocol = 1
+	}
+	return otev1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: otev1.ClusterNamespace},
+		Spec:       otev1.ClusterSpec{Name: name},
+		Status: otev1.ClusterStatus{
+			ParentName: parent,
+			Status:     status,
+			Version:    version,
+			Protocol:   protocol,
+		},
+	}
+}
+
+func TestValidOrder(t *testing.T) {
+	assert.Nil(t, validOrder(OrderTopDown))
+	assert.Nil(t, validOrder(OrderBottomUp))
+	assert.NotNil(t, validOrder("random"))
+}
+
+func TestPlanWaves(t *testing.T) {
+	clusters := []otev1.Cluster{
+		newCluster("c2", "Root", "1.0", true),
+		newCluster("c1", "Root", "1.0", true),
+		newCluster("c11", "c1", "1.0", true),
+		newCluster("c111", "c11", "1.0", true),
+		newCluster("c21", "c2", "1.0", true),
+		// parent is not registered
+		newCluster("c3", "unknown", "1.0", true),
+		// parents in a loop
+		newCluster("x", "y", "1.0", true),
+		newCluster("y", "x", "1.0", true),
+	}
+
+	waves := planWaves(clusters, OrderTopDown, "")
+	assert.Equal(t, [][]string{{"c1", "c2", "c3"}, {"c11", "c21", "x", "y"}, {"c111"}}, waves)
+
+	waves = planWaves(clusters, OrderBottomUp, "")
+	assert.Equal(t, [][]string{{"c111"}, {"c11", "c21", "x", "y"}, {"c1", "c2", "c3"}}, waves)
+
+	waves = planWaves(clusters, OrderTopDown, "^c1,^c2$")
+	assert.Equal(t, [][]string{{"c1", "c2"}, {"c11"}, {"c111"}}, waves)
+
+	waves = planWaves(clusters, OrderTopDown, "c111")
+	assert.Equal(t, [][]string{{"c111"}}, waves)
+
+	assert.Nil(t, planWaves(nil, OrderTopDown, ""))
+}
@@ -0,0 +1,286 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//Package upgrade rolls clustercontroller of edge clusters to a target version
+//wave by wave down or up the cluster tree.
+package upgrade
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/config"
+	"github.com/baidu/ote-stack/pkg/controllermanager"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned"
+)
+
+const (
+	// DefaultWaveTimeout is the time to wait for clusters of a wave upgraded by default.
+	DefaultWaveTimeout = 10 * time.Minute
+	// DefaultCheckInterval is the interval to check clusters upgraded by default.
+	DefaultCheckInterval = 10 * time.Second
+
+	// deploymentURI is the clustercontroller deployment in edge clusters,
+	// as generated by otectl join-manifest.
+	deploymentURI = "/apis/apps/v1/namespaces/kube-system/deployments/ote-clustercontroller"
+	containerName = "clustercontroller"
+)
+
+// Config is the config of upgrade controller.
+type Config struct {
+	// Image is the clustercontroller image to upgrade to.
+	Image string
+	// Version is the version reported by clustercontroller of Image.
+	Version string
+	// Order is OrderTopDown or OrderBottomUp.
+	Order string
+	// Selector selects clusters to upgrade, all clusters if empty.
+	Selector string
+	// WaveTimeout is the time to wait for clusters of a wave upgraded, the upgrade halts after timeout.
+	WaveTimeout time.Duration
+	// CheckInterval is the interval to check clusters upgraded.
+	CheckInterval time.Duration
+}
+
+//UpgradeController upgrades clustercontroller of edge clusters wave by wave.
+type UpgradeController struct {
+	conf      *Config
+	oteClient oteclient.Interface
+	sendChan  chan clustermessage.ClusterMessage
+}
+
+//NewInitFunc returns the InitFunc of upgrade controller by config.
+func NewInitFunc(conf *Config) controllermanager.InitFunc {
+	return func(ctx *controllermanager.ControllerContext) error {
+		c, err := newUpgradeController(conf, ctx.OteClient, ctx.PublishChan)
+		if err != nil {
+			return err
+		}
+		go c.run(ctx.StopChan)
+		return nil
+	}
+}
+
+func newUpgradeController(conf *Config, oteClient oteclient.Interface,
+	sendChan chan clustermessage.ClusterMessage) (*UpgradeController, error) {
+	if conf.Image == "" || conf.Version == "" {
+		return nil, fmt.Errorf("image and version to upgrade are required")
+	}
+	if conf.Order == "" {
+		conf.Order = OrderTopDown
+	}
+	if err := validOrder(conf.Order); err != nil {
+		return nil, err
+	}
+	if conf.WaveTimeout <= 0 {
+		conf.WaveTimeout = DefaultWaveTimeout
+	}
+	if conf.CheckInterval <= 0 {
+		conf.CheckInterval = DefaultCheckInterval
+	}
+	return &UpgradeController{
+		conf:      conf,
+		oteClient: oteClient,
+		sendChan:  sendChan,
+	}, nil
+}
+
+func (c *UpgradeController) run(stop <-chan struct{}) {
+	var clusters []otev1.Cluster
+	for {
+		var err error
+		if clusters, err = c.listClusters(); err == nil {
+			break
+		}
+		klog.Errorf("list clusters to upgrade failed: %v", err)
+		if !sleep(c.conf.CheckInterval, stop) {
+			return
+		}
+	}
+
+	waves := planWaves(clusters, c.conf.Order, c.conf.Selector)
+	klog.Infof("upgrade clustercontroller of %s to %s(%s) in %d waves %s",
+		c.selectorString(), c.conf.Version, c.conf.Image, len(waves), c.conf.Order)
+	for i, wave := range waves {
+		klog.Infof("upgrade wave %d/%d: %s", i+1, len(waves), strings.Join(wave, ","))
+		if err := c.upgradeWave(wave, stop); err != nil {
+			klog.Errorf("upgrade halted at wave %d/%d: %v", i+1, len(waves), err)
+			return
+		}
+	}
+	klog.Infof("upgrade clustercontroller to %s completed", c.conf.Version)
+}
+
+func (c *UpgradeController) selectorString() string {
+	if c.conf.Selector == "" {
+		return "all clusters"
+	}
+	return "clusters " + c.conf.Selector
+}
+
+// upgradeWave upgrades clusters in a wave, and waits until they are reconnected with the
+// target version and a protocol negotiated, and their children are online.
+func (c *UpgradeController) upgradeWave(wave []string, stop <-chan struct{}) error {
+	clusters, err := c.listClusters()
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]*otev1.Cluster, len(clusters))
+	for i := range clusters {
+		byName[clusters[i].ObjectMeta.Name] = &clusters[i]
+	}
+
+	upgrading := make(map[string]bool)
+	for _, name := range wave {
+		cluster, ok := byName[name]
+		switch {
+		case !ok:
+			klog.Warningf("cluster %s is unregistered, skip upgrading", name)
+		case c.upgraded(cluster):
+			klog.V(3).Infof("cluster %s is already %s", name, c.conf.Version)
+		case cluster.Status.Status != otev1.ClusterStatusOnline:
+			klog.Warningf("cluster %s is offline, skip upgrading", name)
+		default:
+			if err := c.sendUpgrade(cluster); err != nil {
+				return fmt.Errorf("send upgrade to cluster %s failed: %v", name, err)
+			}
+			upgrading[name] = true
+		}
+	}
+	if len(upgrading) == 0 {
+		return nil
+	}
+	// children online of clusters upgrading should be online again after their parents restarted.
+	children := make(map[string]bool)
+	for i := range clusters {
+		if upgrading[clusters[i].Status.ParentName] && clusters[i].Status.Status == otev1.ClusterStatusOnline {
+			children[clusters[i].ObjectMeta.Name] = true
+		}
+	}
+
+	deadline := time.Now().Add(c.conf.WaveTimeout)
+	for {
+		if !sleep(c.conf.CheckInterval, stop) {
+			return fmt.Errorf("stopped")
+		}
+		pending, err := c.pending(upgrading, children)
+		if err != nil {
+			klog.Errorf("check clusters upgraded failed: %v", err)
+		} else if len(pending) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("clusters not upgraded or online in %v: %s",
+				c.conf.WaveTimeout, strings.Join(pending, ","))
+		}
+	}
+}
+
+// pending returns the clusters not upgraded and the children not online.
+func (c *UpgradeController) pending(upgrading, children map[string]bool) ([]string, error) {
+	clusters, err := c.listClusters()
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[string]bool)
+	for i := range clusters {
+		cluster := &clusters[i]
+		name := cluster.ObjectMeta.Name
+		switch {
+		case upgrading[name]:
+			done[name] = c.upgraded(cluster) && cluster.Status.Status == otev1.ClusterStatusOnline
+		case children[name]:
+			done[name] = cluster.Status.Status == otev1.ClusterStatusOnline
+		}
+	}
+	var pending []string
+	for name := range upgrading {
+		if !done[name] {
+			pending = append(pending, name)
+		}
+	}
+	for name := range children {
+		if !done[name] {
+			pending = append(pending, name)
+		}
+	}
+	sort.Strings(pending)
+	return pending, nil
+}
+
+// upgraded returns true if clustercontroller of cluster is the target version,
+// and a protocol is negotiated with its parent.
+func (c *UpgradeController) upgraded(cluster *otev1.Cluster) bool {
+	return cluster.Status.Version == c.conf.Version && cluster.Status.Protocol >= config.MinProtocolVersion
+}
+
+// sendUpgrade patches the image of clustercontroller deployment in the cluster.
+func (c *UpgradeController) sendUpgrade(cluster *otev1.Cluster) error {
+	patch := []map[string]string{
+		{"op": "test", "path": "/spec/template/spec/containers/0/name", "value": containerName},
+		{"op": "replace", "path": "/spec/template/spec/containers/0/image", "value": c.conf.Image},
+	}
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	data := &clustermessage.ControllerTask{
+		Destination: otev1.ClusterControllerDestAPI,
+		Method:      http.MethodPatch,
+		URI:         deploymentURI,
+		Body:        body,
+	}
+	head := &clustermessage.MessageHead{
+		ClusterSelector: "^" + regexp.QuoteMeta(cluster.Spec.Name) + "$",
+		Command:         clustermessage.CommandType_ControlReq,
+	}
+	msg, err := data.ToClusterMessage(head)
+	if err != nil {
+		return err
+	}
+
+	c.sendChan <- *msg
+	klog.Infof("upgrade cluster %s from %s to %s", cluster.ObjectMeta.Name, cluster.Status.Version, c.conf.Version)
+	return nil
+}
+
+func (c *UpgradeController) listClusters() ([]otev1.Cluster, error) {
+	list, err := c.oteClient.OteV1().Clusters(otev1.ClusterNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// sleep returns false if stopped.
+func sleep(d time.Duration, stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
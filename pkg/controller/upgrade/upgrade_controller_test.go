@@ -0,0 +1,166 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package upgrade
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned"
+	otefake "github.com/baidu/ote-stack/pkg/k8sclient/fake"
+)
+
+func newTestController(t *testing.T, client oteclient.Interface) *UpgradeController {
+	c, err := newUpgradeController(&Config{
+		Image:         "ote-stack/clustercontroller:2.0",
+		Version:       "2.0",
+		WaveTimeout:   time.Second,
+		CheckInterval: 10 * time.Millisecond,
+	}, client, make(chan clustermessage.ClusterMessage, 10))
+	assert.Nil(t, err)
+	return c
+}
+
+func setVersion(t *testing.T, client oteclient.Interface, name, version string) {
+	cluster, err := client.OteV1().Clusters(otev1.ClusterNamespace).Get(name, metav1.GetOptions{})
+	assert.Nil(t, err)
+	cluster.Status.Version = version
+	cluster.Status.Protocol = 1
+	_, err = client.OteV1().Clusters(otev1.ClusterNamespace).Update(cluster)
+	assert.Nil(t, err)
+}
+
+func TestNewUpgradeController(t *testing.T) {
+	_, err := newUpgradeController(&Config{Image: "a"}, nil, nil)
+	assert.NotNil(t, err)
+	_, err = newUpgradeController(&Config{Image: "a", Version: "2.0", Order: "random"}, nil, nil)
+	assert.NotNil(t, err)
+
+	c, err := newUpgradeController(&Config{Image: "a", Version: "2.0"}, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, OrderTopDown, c.conf.Order)
+	assert.Equal(t, DefaultWaveTimeout, c.conf.WaveTimeout)
+	assert.Equal(t, DefaultCheckInterval, c.conf.CheckInterval)
+}
+
+func TestSendUpgrade(t *testing.T) {
+	c := newTestController(t, nil)
+	cluster := newCluster("c1.a", "Root", "1.0", true)
+	assert.Nil(t, c.sendUpgrade(&cluster))
+
+	msg := <-c.sendChan
+	assert.Equal(t, `^c1\.a$`, msg.Head.ClusterSelector)
+	assert.Equal(t, clustermessage.CommandType_ControlReq, msg.Head.Command)
+	task := &clustermessage.ControllerTask{}
+	assert.Nil(t, proto.Unmarshal(msg.Body, task))
+	assert.Equal(t, otev1.ClusterControllerDestAPI, task.Destination)
+	assert.Equal(t, http.MethodPatch, task.Method)
+	assert.Equal(t, deploymentURI, task.URI)
+	patch := []map[string]string{}
+	assert.Nil(t, json.Unmarshal(task.Body, &patch))
+	assert.Equal(t, "clustercontroller", patch[0]["value"])
+	assert.Equal(t, "ote-stack/clustercontroller:2.0", patch[1]["value"])
+}
+
+func TestUpgradeWave(t *testing.T) {
+	c1 := newCluster("c1", "Root", "1.0", true)
+	c2 := newCluster("c2", "Root", "2.0", true)
+	c3 := newCluster("c3", "Root", "1.0", false)
+	c11 := newCluster("c11", "c1", "1.0", true)
+	client := otefake.NewSimpleClientset(&c1, &c2, &c3, &c11)
+	c := newTestController(t, client)
+
+	done := make(chan error)
+	go func() {
+		done <- c.upgradeWave([]string{"c1", "c2", "c3", "c4"}, make(chan struct{}))
+	}()
+
+	// only c1 is upgraded, c2 is already upgraded, c3 is offline and c4 is unregistered.
+	msg := <-c.sendChan
+	assert.Equal(t, "^c1$", msg.Head.ClusterSelector)
+	select {
+	case msg = <-c.sendChan:
+		t.Errorf("unexpected upgrade to %s", msg.Head.ClusterSelector)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	setVersion(t, client, "c1", "2.0")
+	assert.Nil(t, <-done)
+	assert.Len(t, c.sendChan, 0)
+
+	// wave timeout if not upgraded
+	setVersion(t, client, "c11", "1.0")
+	err := c.upgradeWave([]string{"c11"}, make(chan struct{}))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "c11")
+	<-c.sendChan
+
+	// stopped
+	stop := make(chan struct{})
+	close(stop)
+	assert.NotNil(t, c.upgradeWave([]string{"c11"}, stop))
+}
+
+func TestUpgradeWaveChildren(t *testing.T) {
+	c1 := newCluster("c1", "Root", "1.0", true)
+	c11 := newCluster("c11", "c1", "1.0", true)
+	client := otefake.NewSimpleClientset(&c1, &c11)
+	c := newTestController(t, client)
+
+	done := make(chan error)
+	go func() {
+		done <- c.upgradeWave([]string{"c1"}, make(chan struct{}))
+	}()
+	<-c.sendChan
+	// c1 is upgraded but c11 is not online again
+	c11.Status.Status = otev1.ClusterStatusOffline
+	_, err := client.OteV1().Clusters(otev1.ClusterNamespace).Update(&c11)
+	assert.Nil(t, err)
+	setVersion(t, client, "c1", "2.0")
+	err = <-done
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "c11")
+}
+
+func TestRun(t *testing.T) {
+	c1 := newCluster("c1", "Root", "1.0", true)
+	c11 := newCluster("c11", "c1", "1.0", true)
+	client := otefake.NewSimpleClientset(&c1, &c11)
+	c := newTestController(t, client)
+	c.conf.Order = OrderBottomUp
+
+	done := make(chan struct{})
+	go func() {
+		c.run(make(chan struct{}))
+		close(done)
+	}()
+
+	msg := <-c.sendChan
+	assert.Equal(t, "^c11$", msg.Head.ClusterSelector)
+	setVersion(t, client, "c11", "2.0")
+	msg = <-c.sendChan
+	assert.Equal(t, "^c1$", msg.Head.ClusterSelector)
+	setVersion(t, client, "c1", "2.0")
+	<-done
+}
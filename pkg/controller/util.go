@@ -20,26 +20,32 @@ package controller
 import (
 	"encoding/json"
 	"fmt"
+
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
 )
 
 const (
 	OteNamespaceKind = "Namespace"
 	OteNamespaceURI  = "/api/v1/namespaces"
 	OteApiVersionV1  = "v1"
+
+	// NamespaceOwner owns Namespaces distributed to clusters.
+	NamespaceOwner = "namespace"
 )
 
 //oteNamespace is responsible for constructing namespace object.
 type oteNamespace struct {
-	Kind       string            `json:"kind"`
-	ApiVersion string            `json:"apiVersion"`
-	MetaData   map[string]string `json:"metadata"`
+	Kind       string                 `json:"kind"`
+	ApiVersion string                 `json:"apiVersion"`
+	MetaData   map[string]interface{} `json:"metadata"`
 }
 
 //SerializeNamespaceObject serializes an oteNamespace to be the body of
-//k8s rest request with specific name.
+//k8s rest request with specific name, labeled as owned by NamespaceOwner.
 func SerializeNamespaceObject(name string) ([]byte, error) {
-	data := make(map[string]string)
+	data := make(map[string]interface{})
 	data["name"] = name
+	data["labels"] = map[string]string{handler.OwnerLabel: NamespaceOwner}
 	msg := oteNamespace{
 		Kind:       OteNamespaceKind,
 		ApiVersion: OteApiVersionV1,
@@ -17,13 +17,26 @@ limitations under the License.
 package controller
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
 )
 
 func TestSerializeNamespaceObject(t *testing.T) {
 	data, err := SerializeNamespaceObject("wangpan")
 	assert.Nil(t, err)
 	assert.NotNil(t, data)
+
+	ns := struct {
+		Metadata struct {
+			Name   string            `json:"name"`
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+	}{}
+	assert.Nil(t, json.Unmarshal(data, &ns))
+	assert.Equal(t, "wangpan", ns.Metadata.Name)
+	assert.Equal(t, NamespaceOwner, ns.Metadata.Labels[handler.OwnerLabel])
 }
@@ -0,0 +1,204 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
+)
+
+// EventType is the type of events notified to webhooks.
+type EventType string
+
+const (
+	// EventClusterJoin is notified when a cluster is online.
+	EventClusterJoin EventType = "ClusterJoin"
+	// EventClusterLeave is notified when a cluster is offline or deleted.
+	EventClusterLeave EventType = "ClusterLeave"
+	// EventCommandFailed is notified when a cluster responds a ClusterController with error.
+	EventCommandFailed EventType = "CommandFailed"
+)
+
+const (
+	defaultTimeout    = 5 * time.Second
+	defaultRetries    = 3
+	defaultQueueSize  = 1000
+	eventContentType  = "application/json"
+	eventTypeHeader   = "X-Ote-Event"
+	eventSourceHeader = "ote-controller-manager"
+)
+
+// retryInterval is the interval before the first retry, doubled for each retry.
+var retryInterval = time.Second
+
+// Event is the json notification posted to webhooks.
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	// Cluster is the name of cluster of the event.
+	Cluster string `json:"cluster"`
+	// ClusterController is the name of ClusterController for command events.
+	ClusterController string `json:"clusterController,omitempty"`
+	// StatusCode is the status code responded by cluster for command events.
+	StatusCode int    `json:"statusCode,omitempty"`
+	Message    string `json:"message,omitempty"`
+}
+
+// Webhook is a url receiving events.
+type Webhook struct {
+	URL string `json:"url"`
+	// Events are the event types sent to the url, empty means all.
+	Events []EventType `json:"events,omitempty"`
+	// Headers are added to each request, e.g. for authorization.
+	Headers map[string]string `json:"headers,omitempty"`
+	// TimeoutSeconds is the timeout of each request.
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+	// Retries is the times to retry a failed request.
+	Retries int `json:"retries,omitempty"`
+}
+
+// Config is the webhooks registered.
+type Config struct {
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+// LoadConfig reads webhooks config in yaml or json from file.
+func LoadConfig(file string) (*Config, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read webhook config failed: %v", err)
+	}
+	conf := &Config{}
+	if err := yaml.Unmarshal(data, conf); err != nil {
+		return nil, fmt.Errorf("unmarshal webhook config failed: %v", err)
+	}
+	for _, w := range conf.Webhooks {
+		if w.URL == "" {
+			return nil, fmt.Errorf("webhook has no url")
+		}
+	}
+	return conf, nil
+}
+
+func (w *Webhook) subscribed(t EventType) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+// sink sends events to a webhook in order, events are dropped if the queue is full.
+type sink struct {
+	webhook Webhook
+	client  *http.Client
+	queue   chan *Event
+}
+
+func newSink(w Webhook) *sink {
+	timeout := defaultTimeout
+	if w.TimeoutSeconds > 0 {
+		timeout = time.Duration(w.TimeoutSeconds) * time.Second
+	}
+	if w.Retries <= 0 {
+		w.Retries = defaultRetries
+	}
+	return &sink{
+		webhook: w,
+		client:  &http.Client{Timeout: timeout},
+		queue:   make(chan *Event, defaultQueueSize),
+	}
+}
+
+func (s *sink) notify(e *Event) {
+	if !s.webhook.subscribed(e.Type) {
+		return
+	}
+	select {
+	case s.queue <- e:
+	default:
+		klog.Errorf("webhook %s queue is full, drop event %s of %s", s.webhook.URL, e.Type, e.Cluster)
+	}
+}
+
+func (s *sink) run(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case e := <-s.queue:
+			s.sendWithRetry(e, stop)
+		}
+	}
+}
+
+func (s *sink) sendWithRetry(e *Event, stop <-chan struct{}) {
+	for i := 0; ; i++ {
+		err := s.send(e)
+		if err == nil {
+			return
+		}
+		if i >= s.webhook.Retries {
+			klog.Errorf("send event %s of %s to webhook %s failed: %v", e.Type, e.Cluster, s.webhook.URL, err)
+			return
+		}
+		klog.V(3).Infof("send event to webhook %s failed, retry: %v", s.webhook.URL, err)
+		select {
+		case <-stop:
+			return
+		case <-time.After(retryInterval << uint(i)):
+		}
+	}
+}
+
+func (s *sink) send(e *Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.webhook.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", eventContentType)
+	req.Header.Set("User-Agent", eventSourceHeader)
+	req.Header.Set(eventTypeHeader, string(e.Type))
+	for k, v := range s.webhook.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responds %d", resp.StatusCode)
+	}
+	return nil
+}
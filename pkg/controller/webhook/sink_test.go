@@ -0,0 +1,119 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfig(t *testing.T) {
+	f, err := ioutil.TempFile("", "webhook")
+	assert.Nil(t, err)
+	defer os.Remove(f.Name())
+
+	f.WriteString(`webhooks:
+- url: http://127.0.0.1/hook
+  events: [ClusterJoin, ClusterLeave]
+  headers:
+    Authorization: Bearer token
+- url: http://127.0.0.1/all
+`)
+	f.Close()
+	conf, err := LoadConfig(f.Name())
+	assert.Nil(t, err)
+	assert.Len(t, conf.Webhooks, 2)
+	assert.Equal(t, []EventType{EventClusterJoin, EventClusterLeave}, conf.Webhooks[0].Events)
+	assert.Equal(t, "Bearer token", conf.Webhooks[0].Headers["Authorization"])
+
+	ioutil.WriteFile(f.Name(), []byte("webhooks:\n- events: [ClusterJoin]\n"), 0644)
+	_, err = LoadConfig(f.Name())
+	assert.NotNil(t, err)
+
+	_, err = LoadConfig("/notexist")
+	assert.NotNil(t, err)
+}
+
+func TestSubscribed(t *testing.T) {
+	w := &Webhook{}
+	assert.True(t, w.subscribed(EventCommandFailed))
+	w.Events = []EventType{EventClusterJoin}
+	assert.True(t, w.subscribed(EventClusterJoin))
+	assert.False(t, w.subscribed(EventCommandFailed))
+}
+
+func TestSink(t *testing.T) {
+	retryInterval = 10 * time.Millisecond
+	var calls int32
+	received := make(chan *Event, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// fail the first request to test retry
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "token", r.Header.Get("Authorization"))
+		e := &Event{}
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Nil(t, json.Unmarshal(body, e))
+		assert.Equal(t, string(e.Type), r.Header.Get(eventTypeHeader))
+		received <- e
+	}))
+	defer server.Close()
+
+	s := newSink(Webhook{
+		URL:     server.URL,
+		Events:  []EventType{EventClusterJoin},
+		Headers: map[string]string{"Authorization": "token"},
+	})
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.run(stop)
+
+	s.notify(&Event{Type: EventCommandFailed, Cluster: "c1"})
+	s.notify(&Event{Type: EventClusterJoin, Cluster: "c2"})
+	select {
+	case e := <-received:
+		assert.Equal(t, EventClusterJoin, e.Type)
+		assert.Equal(t, "c2", e.Cluster)
+	case <-time.After(5 * time.Second):
+		t.Fatal("event is not received")
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestSinkSendFailed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	s := newSink(Webhook{URL: server.URL})
+	assert.Equal(t, defaultRetries, s.webhook.Retries)
+	assert.NotNil(t, s.send(&Event{Type: EventClusterJoin}))
+	s.webhook.URL = "http://127.0.0.1:0/notexist"
+	assert.NotNil(t, s.send(&Event{Type: EventClusterJoin}))
+}
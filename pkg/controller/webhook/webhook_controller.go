@@ -0,0 +1,147 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//Package webhook notifies lifecycle events of clusters and failures of commands
+//to webhooks, so that external systems needn't poll k8s apiserver.
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/controllermanager"
+)
+
+//WebhookController watches clusters and clustercontrollers, and notifies events to webhooks.
+type WebhookController struct {
+	sinks []*sink
+	// startTime is used to tell clusters newly created from those listed on start.
+	startTime time.Time
+}
+
+//NewInitFunc returns the InitFunc of webhook controller by config.
+func NewInitFunc(conf *Config) controllermanager.InitFunc {
+	return func(ctx *controllermanager.ControllerContext) error {
+		c, err := newWebhookController(conf)
+		if err != nil {
+			return err
+		}
+		for _, s := range c.sinks {
+			go s.run(ctx.StopChan)
+		}
+
+		ctx.OteInformerFactory.Ote().V1().Clusters().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    c.handleClusterAdded,
+			UpdateFunc: c.handleClusterUpdated,
+			DeleteFunc: c.handleClusterDeleted,
+		})
+		ctx.OteInformerFactory.Ote().V1().ClusterControllers().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			UpdateFunc: c.handleClusterControllerUpdated,
+		})
+		return nil
+	}
+}
+
+func newWebhookController(conf *Config) (*WebhookController, error) {
+	if len(conf.Webhooks) == 0 {
+		return nil, fmt.Errorf("no webhook is registered")
+	}
+	c := &WebhookController{
+		startTime: time.Now(),
+	}
+	for _, w := range conf.Webhooks {
+		c.sinks = append(c.sinks, newSink(w))
+	}
+	return c, nil
+}
+
+func (c *WebhookController) notify(e *Event) {
+	klog.V(3).Infof("notify event %s of %s", e.Type, e.Cluster)
+	e.Time = time.Now()
+	for _, s := range c.sinks {
+		s.notify(e)
+	}
+}
+
+//handleClusterAdded notifies join of clusters created after start,
+//clusters listed on start are not notified again.
+func (c *WebhookController) handleClusterAdded(obj interface{}) {
+	cluster := obj.(*otev1.Cluster)
+	if cluster.CreationTimestamp.Time.Before(c.startTime) {
+		return
+	}
+	if cluster.Status.Status == otev1.ClusterStatusOnline {
+		c.notify(&Event{Type: EventClusterJoin, Cluster: cluster.Name, Message: cluster.Status.Listen})
+	}
+}
+
+func (c *WebhookController) handleClusterUpdated(oldObj, newObj interface{}) {
+	old := oldObj.(*otev1.Cluster)
+	cluster := newObj.(*otev1.Cluster)
+	if old.Status.Status == cluster.Status.Status {
+		return
+	}
+	switch cluster.Status.Status {
+	case otev1.ClusterStatusOnline:
+		c.notify(&Event{Type: EventClusterJoin, Cluster: cluster.Name, Message: cluster.Status.Listen})
+	case otev1.ClusterStatusOffline:
+		c.notify(&Event{Type: EventClusterLeave, Cluster: cluster.Name, Message: "cluster is offline"})
+	}
+}
+
+func (c *WebhookController) handleClusterDeleted(obj interface{}) {
+	cluster, ok := obj.(*otev1.Cluster)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			return
+		}
+		if cluster, ok = tombstone.Obj.(*otev1.Cluster); !ok {
+			return
+		}
+	}
+	if cluster.Status.Status == otev1.ClusterStatusOffline {
+		// leave is notified when it was offline.
+		return
+	}
+	c.notify(&Event{Type: EventClusterLeave, Cluster: cluster.Name, Message: "cluster is deleted"})
+}
+
+//handleClusterControllerUpdated notifies responses of clusters newly written with error code.
+func (c *WebhookController) handleClusterControllerUpdated(oldObj, newObj interface{}) {
+	old := oldObj.(*otev1.ClusterController)
+	cc := newObj.(*otev1.ClusterController)
+	for cluster, status := range cc.Status {
+		if oldStatus, ok := old.Status[cluster]; ok && oldStatus == status {
+			continue
+		}
+		if status.StatusCode >= http.StatusOK && status.StatusCode < http.StatusBadRequest {
+			continue
+		}
+		c.notify(&Event{
+			Type:              EventCommandFailed,
+			Cluster:           cluster,
+			ClusterController: cc.Name,
+			StatusCode:        status.StatusCode,
+			Message:           status.Body,
+		})
+	}
+}
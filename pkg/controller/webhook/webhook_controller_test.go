@@ -0,0 +1,117 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+)
+
+func newFakeWebhookController(t *testing.T) (*WebhookController, *sink) {
+	c, err := newWebhookController(&Config{Webhooks: []Webhook{{URL: "http://127.0.0.1/hook"}}})
+	assert.Nil(t, err)
+	return c, c.sinks[0]
+}
+
+func newFakeCluster(name, status string, created time.Time) *otev1.Cluster {
+	return &otev1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         otev1.ClusterNamespace,
+			CreationTimestamp: metav1.NewTime(created),
+		},
+		Status: otev1.ClusterStatus{Status: status},
+	}
+}
+
+func assertEvent(t *testing.T, s *sink, eventType EventType, cluster string) *Event {
+	select {
+	case e := <-s.queue:
+		assert.Equal(t, eventType, e.Type)
+		assert.Equal(t, cluster, e.Cluster)
+		assert.False(t, e.Time.IsZero())
+		return e
+	default:
+		t.Fatalf("no event %s of %s", eventType, cluster)
+	}
+	return nil
+}
+
+func assertNoEvent(t *testing.T, s *sink) {
+	select {
+	case e := <-s.queue:
+		t.Fatalf("unexpected event %v", e)
+	default:
+	}
+}
+
+func TestNewWebhookController(t *testing.T) {
+	_, err := newWebhookController(&Config{})
+	assert.NotNil(t, err)
+}
+
+func TestHandleClusterEvents(t *testing.T) {
+	c, s := newFakeWebhookController(t)
+
+	// clusters listed on start is not notified
+	c.handleClusterAdded(newFakeCluster("c1", otev1.ClusterStatusOnline, c.startTime.Add(-time.Hour)))
+	assertNoEvent(t, s)
+	c.handleClusterAdded(newFakeCluster("c1", otev1.ClusterStatusOnline, c.startTime.Add(time.Second)))
+	assertEvent(t, s, EventClusterJoin, "c1")
+
+	online := newFakeCluster("c2", otev1.ClusterStatusOnline, c.startTime)
+	offline := newFakeCluster("c2", otev1.ClusterStatusOffline, c.startTime)
+	c.handleClusterUpdated(online, online)
+	assertNoEvent(t, s)
+	c.handleClusterUpdated(online, offline)
+	assertEvent(t, s, EventClusterLeave, "c2")
+	c.handleClusterUpdated(offline, online)
+	assertEvent(t, s, EventClusterJoin, "c2")
+
+	c.handleClusterDeleted(offline)
+	assertNoEvent(t, s)
+	c.handleClusterDeleted(online)
+	assertEvent(t, s, EventClusterLeave, "c2")
+	c.handleClusterDeleted(cache.DeletedFinalStateUnknown{Obj: online})
+	assertEvent(t, s, EventClusterLeave, "c2")
+}
+
+func TestHandleClusterControllerUpdated(t *testing.T) {
+	c, s := newFakeWebhookController(t)
+
+	old := &otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{Name: "cc"},
+		Status: map[string]otev1.ClusterControllerStatus{
+			"c1": {StatusCode: 500, Body: "failed"},
+		},
+	}
+	cc := old.DeepCopy()
+	cc.Status["c2"] = otev1.ClusterControllerStatus{StatusCode: 200}
+	cc.Status["c3"] = otev1.ClusterControllerStatus{StatusCode: 404, Body: "not found"}
+	c.handleClusterControllerUpdated(old, cc)
+	e := assertEvent(t, s, EventCommandFailed, "c3")
+	assert.Equal(t, "cc", e.ClusterController)
+	assert.Equal(t, 404, e.StatusCode)
+	assert.Equal(t, "not found", e.Message)
+	assertNoEvent(t, s)
+}
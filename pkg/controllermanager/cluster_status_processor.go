@@ -17,7 +17,7 @@ limitations under the License.
 package controllermanager
 
 import (
-	"fmt"
+	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/klog"
@@ -25,17 +25,22 @@ import (
 	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
 )
 
+// AdjustClockSkew converts timestamps of cluster status reported from edge
+// to the clock of root by clock skew of the cluster measured on regist.
+var AdjustClockSkew bool
+
 func (u *UpstreamProcessor) handleClusterStatusReport(clustername string, statusbody []byte) error {
 	status, err := otev1.ClusterStatusDeserialize(statusbody)
 	if err != nil {
-		return fmt.Errorf("status body of cluster %s deserialize failed : %v", clustername, err)
+		return errorOf(ErrInvalidReport, "status body of cluster %s deserialize failed : %v", clustername, err)
 	}
 
 	klog.V(3).Infof("update cluster status: name=%s, status=%v", clustername, status)
+	recordReportLag(clustername, status, time.Now())
 
 	err = u.UpdateClusterStatus(clustername, status)
 	if err != nil {
-		return fmt.Errorf("update cluster % status failed : %v", clustername, err)
+		return errorOf(ErrUpdateFailed, "update status of cluster %s failed: %v", clustername, err)
 	}
 
 	return nil
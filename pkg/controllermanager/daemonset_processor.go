@@ -18,7 +18,6 @@ package controllermanager
 
 import (
 	"encoding/json"
-	"fmt"
 
 	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -33,7 +32,7 @@ import (
 func (u *UpstreamProcessor) handleDaemonsetReport(b []byte) error {
 	drs, err := DaemonsetReportStatusDeserialize(b)
 	if err != nil {
-		return fmt.Errorf("DaemonsetReportStatusDeserialize failed: %v", err)
+		return errorOf(ErrInvalidReport, "DaemonsetReportStatusDeserialize failed: %v", err)
 	}
 
 	//handle FullList
@@ -63,6 +62,11 @@ func (u *UpstreamProcessor) handleDaemonsetUpdateMap(updateMap map[string]*appsv
 			continue
 		}
 
+		if err = transformResource(DaemonSetGVK, daemonset); err != nil {
+			klog.Errorf("handleDaemonsetUpdateMap's transformResource failed: %v", err)
+			continue
+		}
+
 		err = u.CreateOrUpdateDaemonset(daemonset)
 		if err != nil {
 			klog.Errorf("daemonset: %s created or updated failed: %v", daemonset.ObjectMeta.Name, err)
@@ -144,7 +148,7 @@ func (u *UpstreamProcessor) UpdateDaemonset(daemonset *appsv1.DaemonSet) error {
 		}
 
 		if !checkEdgeVersion(&daemonset.ObjectMeta, &storedDaemonset.ObjectMeta) {
-			return fmt.Errorf("check daemonset edge version failed")
+			return errorOf(ErrVersionConflict, "check daemonset edge version failed")
 		}
 
 		adaptToCentralResource(&daemonset.ObjectMeta, &storedDaemonset.ObjectMeta)
@@ -164,7 +168,7 @@ func (u *UpstreamProcessor) UpdateDaemonset(daemonset *appsv1.DaemonSet) error {
 		}
 
 		if !checkEdgeVersion(&daemonset.ObjectMeta, &storedDaemonset.ObjectMeta) {
-			return fmt.Errorf("check daemonset edge version failed")
+			return errorOf(ErrVersionConflict, "check daemonset edge version failed")
 		}
 
 		adaptToCentralResource(&daemonset.ObjectMeta, &storedDaemonset.ObjectMeta)
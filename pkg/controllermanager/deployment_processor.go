@@ -18,7 +18,6 @@ package controllermanager
 
 import (
 	"encoding/json"
-	"fmt"
 
 	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -33,7 +32,7 @@ import (
 func (u *UpstreamProcessor) handleDeploymentReport(b []byte) error {
 	drs, err := DeploymentReportStatusDeserialize(b)
 	if err != nil {
-		return fmt.Errorf("DeploymentReportStatusDeserialize failed: %v", err)
+		return errorOf(ErrInvalidReport, "DeploymentReportStatusDeserialize failed: %v", err)
 	}
 
 	//handle FullList
@@ -63,6 +62,11 @@ func (u *UpstreamProcessor) handleDeploymentUpdateMap(updateMap map[string]*apps
 			continue
 		}
 
+		if err = transformResource(DeploymentGVK, deployment); err != nil {
+			klog.Errorf("handleDeploymentUpdateMap's transformResource failed: %v", err)
+			continue
+		}
+
 		err = u.CreateOrUpdateDeployment(deployment)
 		if err != nil {
 			klog.Errorf("deployment: %s created or updated failed: %v", deployment.ObjectMeta.Name, err)
@@ -145,7 +149,7 @@ func (u *UpstreamProcessor) UpdateDeployment(deployment *appsv1.Deployment) erro
 		}
 
 		if !checkEdgeVersion(&deployment.ObjectMeta, &storedDeployment.ObjectMeta) {
-			return fmt.Errorf("check deployment edge version failed")
+			return errorOf(ErrVersionConflict, "check deployment edge version failed")
 		}
 
 		adaptToCentralResource(&deployment.ObjectMeta, &storedDeployment.ObjectMeta)
@@ -165,7 +169,7 @@ func (u *UpstreamProcessor) UpdateDeployment(deployment *appsv1.Deployment) erro
 		}
 
 		if !checkEdgeVersion(&deployment.ObjectMeta, &storedDeployment.ObjectMeta) {
-			return fmt.Errorf("check deployment edge version failed")
+			return errorOf(ErrVersionConflict, "check deployment edge version failed")
 		}
 
 		adaptToCentralResource(&deployment.ObjectMeta, &storedDeployment.ObjectMeta)
@@ -0,0 +1,168 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllermanager
+
+import (
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/reporter"
+)
+
+// handleEndpointsReport handles EndpointsReport of exported services from edge clusters.
+func (u *UpstreamProcessor) handleEndpointsReport(b []byte) error {
+	ers, err := EndpointsReportStatusDeserialize(b)
+	if err != nil {
+		return errorOf(ErrInvalidReport, "EndpointsReportStatusDeserialize failed: %v", err)
+	}
+
+	//handle UpdateMap
+	if ers.UpdateMap != nil {
+		u.handleEndpointsUpdateMap(ers.UpdateMap)
+	}
+
+	//handle DelMap
+	if ers.DelMap != nil {
+		u.handleEndpointsDelMap(ers.DelMap)
+	}
+
+	return nil
+}
+
+// handleEndpointsUpdateMap handles endpoints resource created or updated event from edge clusters.
+func (u *UpstreamProcessor) handleEndpointsUpdateMap(updateMap map[string]*corev1.Endpoints) {
+	for _, endpoints := range updateMap {
+		err := UniqueResourceName(&endpoints.ObjectMeta)
+		if err != nil {
+			klog.Errorf("handleEndpointsUpdateMap's UniqueResourceName method failed: %v", err)
+			continue
+		}
+
+		if err = transformResource(EndpointsGVK, endpoints); err != nil {
+			klog.Errorf("handleEndpointsUpdateMap's transformResource failed: %v", err)
+			continue
+		}
+
+		err = u.CreateOrUpdateEndpoints(endpoints)
+		if err != nil {
+			klog.Errorf("endpoints: %s created or updated failed: %v", endpoints.ObjectMeta.Name, err)
+			continue
+		}
+	}
+}
+
+// handleEndpointsDelMap handles endpoints resource deleted event from edge clusters.
+func (u *UpstreamProcessor) handleEndpointsDelMap(delMap map[string]*corev1.Endpoints) {
+	for _, endpoints := range delMap {
+		err := UniqueResourceName(&endpoints.ObjectMeta)
+		if err != nil {
+			klog.Errorf("handleEndpointsDelMap's UniqueResourceName method failed: %v", err)
+			continue
+		}
+
+		err = u.DeleteEndpoints(endpoints)
+		if err != nil && !errors.IsNotFound(err) {
+			klog.Errorf("endpoints: %s deleted failed: %v", endpoints.ObjectMeta.Name, err)
+			continue
+		}
+
+		klog.V(3).Infof("Reported endpoints resource: %s deleted success.", endpoints.Name)
+	}
+}
+
+// CreateOrUpdateEndpoints checks whether endpoints exists,then creates or updates it to center etcd.
+func (u *UpstreamProcessor) CreateOrUpdateEndpoints(endpoints *corev1.Endpoints) error {
+	_, err := u.GetEndpoints(endpoints)
+	// If not found resource, creates it.
+	if err != nil && errors.IsNotFound(err) {
+		return u.CreateEndpoints(endpoints)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// If endpoints exists, updates it.
+	return u.UpdateEndpoints(endpoints)
+}
+
+// DeleteEndpoints deletes endpoints resource reported from edge cluster.
+func (u *UpstreamProcessor) DeleteEndpoints(endpoints *corev1.Endpoints) error {
+	return u.ctx.K8sClient.CoreV1().Endpoints(endpoints.Namespace).Delete(endpoints.Name, metav1.NewDeleteOptions(0))
+}
+
+// GetEndpoints get endpoints resource stored in center etcd.
+func (u *UpstreamProcessor) GetEndpoints(endpoints *corev1.Endpoints) (*corev1.Endpoints, error) {
+	return u.ctx.K8sClient.CoreV1().Endpoints(endpoints.Namespace).Get(endpoints.Name, metav1.GetOptions{})
+}
+
+// CreateEndpoints creates endpoints resource from edge cluster to center etcd.
+func (u *UpstreamProcessor) CreateEndpoints(endpoints *corev1.Endpoints) error {
+	// ResourceVersion should not be set when resource is to be created.
+	endpoints.ResourceVersion = ""
+
+	_, err := u.ctx.K8sClient.CoreV1().Endpoints(endpoints.Namespace).Create(endpoints)
+	if err != nil {
+		return err
+	}
+
+	klog.V(3).Infof("Reported endpoints resource: %s created success.", endpoints.Name)
+	return nil
+}
+
+// UpdateEndpoints updates endpoints resource from edge cluster to center etcd.
+func (u *UpstreamProcessor) UpdateEndpoints(endpoints *corev1.Endpoints) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		storedEndpoints, err := u.GetEndpoints(endpoints)
+		if err != nil {
+			return err
+		}
+
+		if !checkEdgeVersion(&endpoints.ObjectMeta, &storedEndpoints.ObjectMeta) {
+			return errorOf(ErrVersionConflict, "check endpoints edge version failed")
+		}
+
+		adaptToCentralResource(&endpoints.ObjectMeta, &storedEndpoints.ObjectMeta)
+
+		_, err = u.ctx.K8sClient.CoreV1().Endpoints(endpoints.Namespace).Update(endpoints)
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	klog.V(3).Infof("Reported endpoints resource: %s updated success.", endpoints.Name)
+
+	return nil
+}
+
+// EndpointsReportStatusDeserialize deserialize byte data to EndpointsResourceStatus.
+func EndpointsReportStatusDeserialize(b []byte) (*reporter.EndpointsResourceStatus, error) {
+	endpointsReportStatus := reporter.EndpointsResourceStatus{}
+
+	err := json.Unmarshal(b, &endpointsReportStatus)
+	if err != nil {
+		return nil, err
+	}
+	return &endpointsReportStatus, nil
+}
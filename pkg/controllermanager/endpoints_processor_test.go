@@ -0,0 +1,117 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllermanager
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubernetes "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/baidu/ote-stack/pkg/reporter"
+)
+
+func newEndpoints(name, clusterLabel, edgeVersion, ip string) *corev1.Endpoints {
+	return &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{reporter.ClusterLabel: clusterLabel, reporter.EdgeVersionLabel: edgeVersion},
+		},
+		Subsets: []corev1.EndpointSubset{
+			{Addresses: []corev1.EndpointAddress{{IP: ip}}},
+		},
+	}
+}
+
+func newEndpointsReport(t *testing.T, update, del *corev1.Endpoints) []byte {
+	ers := &reporter.EndpointsResourceStatus{
+		UpdateMap: make(map[string]*corev1.Endpoints),
+		DelMap:    make(map[string]*corev1.Endpoints),
+	}
+	if update != nil {
+		ers.UpdateMap[update.Namespace+"/"+update.Name] = update
+	}
+	if del != nil {
+		ers.DelMap[del.Namespace+"/"+del.Name] = del
+	}
+	b, err := json.Marshal(ers)
+	assert.Nil(t, err)
+	return b
+}
+
+func TestHandleEndpointsReport(t *testing.T) {
+	client := kubernetes.NewSimpleClientset()
+	u := NewUpstreamProcessor(&K8sContext{K8sClient: client})
+
+	assert.NotNil(t, u.handleEndpointsReport([]byte("{")))
+
+	// create
+	err := u.handleEndpointsReport(newEndpointsReport(t, newEndpoints("svc", "c1", "1", "10.0.0.1"), nil))
+	assert.Nil(t, err)
+	stored, err := client.CoreV1().Endpoints("default").Get("svc-c1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.0.1", stored.Subsets[0].Addresses[0].IP)
+
+	// update with newer version
+	err = u.handleEndpointsReport(newEndpointsReport(t, newEndpoints("svc", "c1", "2", "10.0.0.2"), nil))
+	assert.Nil(t, err)
+	stored, _ = client.CoreV1().Endpoints("default").Get("svc-c1", metav1.GetOptions{})
+	assert.Equal(t, "10.0.0.2", stored.Subsets[0].Addresses[0].IP)
+
+	// older version is ignored
+	err = u.handleEndpointsReport(newEndpointsReport(t, newEndpoints("svc", "c1", "1", "10.0.0.1"), nil))
+	assert.Nil(t, err)
+	stored, _ = client.CoreV1().Endpoints("default").Get("svc-c1", metav1.GetOptions{})
+	assert.Equal(t, "10.0.0.2", stored.Subsets[0].Addresses[0].IP)
+
+	// delete, not found is ignored
+	err = u.handleEndpointsReport(newEndpointsReport(t, nil, newEndpoints("svc", "c1", "3", "")))
+	assert.Nil(t, err)
+	_, err = client.CoreV1().Endpoints("default").Get("svc-c1", metav1.GetOptions{})
+	assert.NotNil(t, err)
+	err = u.handleEndpointsReport(newEndpointsReport(t, nil, newEndpoints("svc", "c1", "3", "")))
+	assert.Nil(t, err)
+
+	// no cluster label
+	err = u.handleEndpointsReport(newEndpointsReport(t, newEndpoints("svc", "", "1", "10.0.0.1"), nil))
+	assert.Nil(t, err)
+	_, err = client.CoreV1().Endpoints("default").Get("svc-", metav1.GetOptions{})
+	assert.NotNil(t, err)
+}
+
+func TestExportedServiceSelectorCleared(t *testing.T) {
+	client := kubernetes.NewSimpleClientset()
+	u := NewUpstreamProcessor(&K8sContext{K8sClient: client})
+
+	exported := NewService("exported", "c1", "1", "")
+	exported.Labels[reporter.ServiceExportLabel] = "true"
+	exported.Spec.Selector = map[string]string{"app": "a"}
+	normal := NewService("normal", "c1", "1", "")
+	normal.Spec.Selector = map[string]string{"app": "a"}
+	u.handleServiceUpdateMap(map[string]*corev1.Service{"exported": exported, "normal": normal})
+
+	stored, err := client.CoreV1().Services("").Get("exported-c1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Nil(t, stored.Spec.Selector)
+	stored, err = client.CoreV1().Services("").Get("normal-c1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.NotNil(t, stored.Spec.Selector)
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllermanager
+
+import (
+	"errors"
+
+	"github.com/baidu/ote-stack/pkg/errorkind"
+)
+
+// Kinds of errors returned by UpstreamProcessor and controllers, which callers can tell by errorkind.Is.
+var (
+	// ErrInvalidMessage is returned handling a message which can not be decoded, or without a head.
+	ErrInvalidMessage = errors.New("invalid message")
+	// ErrUnsupportedCommand is returned handling a message of command not supported.
+	ErrUnsupportedCommand = errors.New("command not supported")
+	// ErrInvalidReport is returned handling a report whose body can not be decoded.
+	ErrInvalidReport = errors.New("invalid report")
+	// ErrClusterLabelEmpty is returned handling a resource reported without the cluster label.
+	ErrClusterLabelEmpty = errors.New("ClusterLabel is empty")
+	// ErrVersionConflict is returned updating a resource reported, whose edge version is older
+	// than the stored one, or missing.
+	ErrVersionConflict = errors.New("edge version conflict")
+	// ErrTransformFailed is returned transforming a resource reported by transforms of its kind.
+	ErrTransformFailed = errors.New("transform failed")
+	// ErrInvalidTransform is returned creating a transform of an invalid ResourceTransform.
+	ErrInvalidTransform = errors.New("invalid transform")
+	// ErrUnsupportedKind is returned handling an event of an object whose kind is not mirrored.
+	ErrUnsupportedKind = errors.New("kind not supported")
+	// ErrNotLeaf is returned by controllers acting on leaf clusters only, e.g., decommissioning
+	// a cluster which other clusters are still connected through.
+	ErrNotLeaf = errors.New("cluster is not a leaf")
+	// ErrUpdateFailed is returned writing status reported to center failed, e.g., the apiserver is unavailable.
+	ErrUpdateFailed = errors.New("update failed")
+)
+
+// errorOf returns an error of kind, with message formatted by format and a.
+func errorOf(kind error, format string, a ...interface{}) error {
+	return errorkind.Errorf(kind, format, a...)
+}
@@ -18,7 +18,6 @@ package controllermanager
 
 import (
 	"encoding/json"
-	"fmt"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -34,7 +33,7 @@ import (
 func (u *UpstreamProcessor) handleEventReport(b []byte) error {
 	ers, err := EventReportStatusDeserialize(b)
 	if err != nil {
-		return fmt.Errorf("EventReportStatusDeserialize failed: %v", err)
+		return errorOf(ErrInvalidReport, "EventReportStatusDeserialize failed: %v", err)
 	}
 
 	//handle FullList
@@ -70,6 +69,11 @@ func (u *UpstreamProcessor) CenterCreateEvent(updateMap map[string]*corev1.Event
 			continue
 		}
 
+		if err = transformResource(EventGVK, event); err != nil {
+			klog.Errorf("CenterCreateEvent's transformResource failed: %v", err)
+			continue
+		}
+
 		err = u.CreateEvent(event)
 		if err != nil {
 			klog.Errorf("event: %s created failed: %v", event.ObjectMeta.Name, err)
@@ -131,7 +135,7 @@ func EventReportStatusDeserialize(b []byte) (*reporter.EventResourceStatus, erro
 // so that the event can be bound to the related reported object.
 func (u *UpstreamProcessor) relateToPodOrNode(event *corev1.Event) error {
 	if event.Labels == nil || event.Labels[reporter.ClusterLabel] == "" {
-		return fmt.Errorf("event's label is null")
+		return errorOf(ErrClusterLabelEmpty, "event's label is null")
 	}
 
 	resourceName := event.InvolvedObject.Name + UniqueResourceNameSeparator + event.Labels[reporter.ClusterLabel]
@@ -170,7 +174,7 @@ func (u *UpstreamProcessor) relateToPodOrNode(event *corev1.Event) error {
 
 		event.InvolvedObject = *obj
 	default:
-		return fmt.Errorf("resource type of the event-involved object is not supported")
+		return errorOf(ErrUnsupportedKind, "resource type %s of the event-involved object is not supported", event.InvolvedObject.Kind)
 	}
 
 	return nil
@@ -18,7 +18,6 @@ package controllermanager
 
 import (
 	"encoding/json"
-	"fmt"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -33,7 +32,7 @@ func (u *UpstreamProcessor) handleNodeReport(b []byte) error {
 	// Deserialize byte data to NodeReportStatus
 	nrs, err := NodeReportStatusDeserialize(b)
 	if err != nil {
-		return fmt.Errorf("NodeReportStatusDeserialize failed : %v", err)
+		return errorOf(ErrInvalidReport, "NodeReportStatusDeserialize failed : %v", err)
 	}
 	// handle FullList
 	if nrs.FullList != nil {
@@ -77,6 +76,11 @@ func (u *UpstreamProcessor) handleNodeUpdateMap(updateMap map[string]*corev1.Nod
 			continue
 		}
 
+		if err = transformResource(NodeGVK, node); err != nil {
+			klog.Errorf("handleNodeUpdateMap's transformResource failed: %v", err)
+			continue
+		}
+
 		err = u.CreateOrUpdateNode(node)
 		if err != nil {
 			klog.Errorf("Report node create or update event failed : %v", err)
@@ -128,7 +132,7 @@ func (u *UpstreamProcessor) UpdateNode(node *corev1.Node) error {
 		}
 
 		if !checkEdgeVersion(&node.ObjectMeta, &storedNode.ObjectMeta) {
-			return fmt.Errorf("check node edge version failed")
+			return errorOf(ErrVersionConflict, "check node edge version failed")
 		}
 
 		adaptToCentralResource(&node.ObjectMeta, &storedNode.ObjectMeta)
@@ -148,7 +152,7 @@ func (u *UpstreamProcessor) UpdateNode(node *corev1.Node) error {
 		}
 
 		if !checkEdgeVersion(&node.ObjectMeta, &storedNode.ObjectMeta) {
-			return fmt.Errorf("check node edge version failed")
+			return errorOf(ErrVersionConflict, "check node edge version failed")
 		}
 
 		adaptToCentralResource(&node.ObjectMeta, &storedNode.ObjectMeta)
@@ -18,7 +18,6 @@ package controllermanager
 
 import (
 	"encoding/json"
-	"fmt"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -33,7 +32,7 @@ func (u *UpstreamProcessor) handlePodReport(b []byte) error {
 	// Deserialize byte data to PodReportStatus
 	prs, err := PodReportStatusDeserialize(b)
 	if err != nil {
-		return fmt.Errorf("PodReportStatusDeserialize failed : %v", err)
+		return errorOf(ErrInvalidReport, "PodReportStatusDeserialize failed : %v", err)
 	}
 	// handle FullList
 	if prs.FullList != nil {
@@ -77,6 +76,11 @@ func (u *UpstreamProcessor) handlePodUpdateMap(updateMap map[string]*corev1.Pod)
 			continue
 		}
 
+		if err = transformResource(PodGVK, pod); err != nil {
+			klog.Errorf("handlePodUpdateMap's transformResource failed: %v", err)
+			continue
+		}
+
 		err = u.CreateOrUpdatePod(pod)
 		if err != nil {
 			klog.Errorf("Report pod create or update event failed : %v", err)
@@ -95,6 +99,47 @@ func PodReportStatusDeserialize(b []byte) (*reporter.PodResourceStatus, error) {
 	return &podReportStatus, nil
 }
 
+const (
+	taintNodeNotReady    = "node.kubernetes.io/not-ready"
+	taintNodeUnreachable = "node.kubernetes.io/unreachable"
+)
+
+// NodeUnreachableTolerationSeconds is the time pods reported from edge tolerate
+// their nodes not ready or unreachable in center, so that they are not evicted in
+// center during a brief disconnection of edge cluster. 0 keeps tolerations reported.
+var NodeUnreachableTolerationSeconds int64
+
+// tolerateNodeUnreachable sets the NoExecute tolerations of not ready and unreachable
+// taints of pod to NodeUnreachableTolerationSeconds.
+func tolerateNodeUnreachable(spec *corev1.PodSpec) {
+	if NodeUnreachableTolerationSeconds <= 0 {
+		return
+	}
+	for _, key := range []string{taintNodeNotReady, taintNodeUnreachable} {
+		seconds := NodeUnreachableTolerationSeconds
+		found := false
+		for i := range spec.Tolerations {
+			t := &spec.Tolerations[i]
+			if t.Key != key || t.Effect != corev1.TaintEffectNoExecute {
+				continue
+			}
+			found = true
+			// a toleration without seconds tolerates forever, keep it.
+			if t.TolerationSeconds != nil {
+				t.TolerationSeconds = &seconds
+			}
+		}
+		if !found {
+			spec.Tolerations = append(spec.Tolerations, corev1.Toleration{
+				Key:               key,
+				Operator:          corev1.TolerationOpExists,
+				Effect:            corev1.TaintEffectNoExecute,
+				TolerationSeconds: &seconds,
+			})
+		}
+	}
+}
+
 // GetPod will retrieve the requested pod based on namespace and name.
 func (u *UpstreamProcessor) GetPod(pod *corev1.Pod) (*corev1.Pod, error) {
 	storedPod, err := u.ctx.K8sClient.CoreV1().Pods(pod.Namespace).Get(pod.Name, metav1.GetOptions{})
@@ -110,6 +155,7 @@ func (u *UpstreamProcessor) CreatePod(pod *corev1.Pod) error {
 	pod.ResourceVersion = ""
 	// service account must be empty for create
 	pod.Spec.ServiceAccountName = ""
+	tolerateNodeUnreachable(&pod.Spec)
 
 	_, err := u.ctx.K8sClient.CoreV1().Pods(pod.Namespace).Create(pod)
 
@@ -132,7 +178,7 @@ func (u *UpstreamProcessor) UpdatePod(pod *corev1.Pod) error {
 		}
 
 		if !checkEdgeVersion(&pod.ObjectMeta, &storedPod.ObjectMeta) {
-			return fmt.Errorf("check pod edge version failed")
+			return errorOf(ErrVersionConflict, "check pod edge version failed")
 		}
 
 		adaptToCentralResource(&pod.ObjectMeta, &storedPod.ObjectMeta)
@@ -142,6 +188,7 @@ func (u *UpstreamProcessor) UpdatePod(pod *corev1.Pod) error {
 		// only the following fields can be modified
 		pod.Spec.ActiveDeadlineSeconds = copyPod.Spec.ActiveDeadlineSeconds
 		pod.Spec.Tolerations = copyPod.Spec.Tolerations
+		tolerateNodeUnreachable(&pod.Spec)
 		// TODO spec.containers[*].image and spec.initContainers[*].image setting is not clear,
 		// and no need to sync those fields currently
 
@@ -161,7 +208,7 @@ func (u *UpstreamProcessor) UpdatePod(pod *corev1.Pod) error {
 		}
 
 		if !checkEdgeVersion(&pod.ObjectMeta, &storedPod.ObjectMeta) {
-			return fmt.Errorf("check pod edge version failed")
+			return errorOf(ErrVersionConflict, "check pod edge version failed")
 		}
 
 		adaptToCentralResource(&pod.ObjectMeta, &storedPod.ObjectMeta)
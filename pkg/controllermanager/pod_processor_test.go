@@ -365,3 +365,36 @@ func TestDeletePod(t *testing.T) {
 		})
 	}
 }
+
+func TestTolerateNodeUnreachable(t *testing.T) {
+	spec := &corev1.PodSpec{}
+	tolerateNodeUnreachable(spec)
+	assert.Len(t, spec.Tolerations, 0)
+
+	NodeUnreachableTolerationSeconds = 3600
+	defer func() { NodeUnreachableTolerationSeconds = 0 }()
+
+	// default tolerations added by edge cluster are extended
+	defaultSeconds := int64(300)
+	spec.Tolerations = []corev1.Toleration{
+		{Key: taintNodeNotReady, Operator: corev1.TolerationOpExists,
+			Effect: corev1.TaintEffectNoExecute, TolerationSeconds: &defaultSeconds},
+		{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "edge",
+			Effect: corev1.TaintEffectNoSchedule},
+	}
+	tolerateNodeUnreachable(spec)
+	assert.Len(t, spec.Tolerations, 3)
+	assert.Equal(t, int64(3600), *spec.Tolerations[0].TolerationSeconds)
+	assert.Nil(t, spec.Tolerations[1].TolerationSeconds)
+	assert.Equal(t, taintNodeUnreachable, spec.Tolerations[2].Key)
+	assert.Equal(t, int64(3600), *spec.Tolerations[2].TolerationSeconds)
+	assert.Equal(t, int64(300), defaultSeconds)
+
+	// tolerating forever is kept
+	spec.Tolerations = []corev1.Toleration{
+		{Key: taintNodeUnreachable, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute},
+	}
+	tolerateNodeUnreachable(spec)
+	assert.Len(t, spec.Tolerations, 2)
+	assert.Nil(t, spec.Tolerations[0].TolerationSeconds)
+}
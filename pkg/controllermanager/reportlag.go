@@ -0,0 +1,62 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllermanager
+
+import (
+	"sync"
+	"time"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+)
+
+var (
+	reportLagsLock sync.RWMutex
+	// reportLags are lags of the last status reported by cluster.
+	reportLags = make(map[string]time.Duration)
+)
+
+// recordReportLag records the lag of status reported by cluster from the time it is reported to now.
+// The time reported is the hybrid time of status, which is ahead of messages the cluster received
+// from root, or timestamp of status of clusters not reporting hybrid times. Lags below zero,
+// e.g., of clocks of clusters ahead of root, are recorded as zero.
+func recordReportLag(cluster string, status *otev1.ClusterStatus, now time.Time) {
+	var reported time.Time
+	switch {
+	case status.HLC != nil:
+		reported = time.Unix(0, status.HLC.WallTime*int64(time.Millisecond))
+	case status.Timestamp != 0:
+		reported = time.Unix(status.Timestamp, 0)
+	default:
+		return
+	}
+	lag := now.Sub(reported)
+	if lag < 0 {
+		lag = 0
+	}
+	reportLagsLock.Lock()
+	defer reportLagsLock.Unlock()
+	reportLags[cluster] = lag
+}
+
+// ReportLag returns the lag of the last status reported by cluster reaching ote controller manager,
+// false if no status of the cluster is received since started.
+func ReportLag(cluster string) (time.Duration, bool) {
+	reportLagsLock.RLock()
+	defer reportLagsLock.RUnlock()
+	lag, ok := reportLags[cluster]
+	return lag, ok
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllermanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+)
+
+func TestRecordReportLag(t *testing.T) {
+	now := time.Unix(1571360000, 0)
+	defer func() {
+		reportLags = make(map[string]time.Duration)
+	}()
+
+	recordReportLag("c1", &otev1.ClusterStatus{
+		Timestamp: 1571350000,
+		HLC:       &otev1.HybridTime{WallTime: 1571359998500},
+	}, now)
+	lag, ok := ReportLag("c1")
+	assert.True(t, ok)
+	assert.Equal(t, 1500*time.Millisecond, lag)
+
+	// status without hybrid time is taken by timestamp.
+	recordReportLag("c2", &otev1.ClusterStatus{Timestamp: 1571359990}, now)
+	lag, _ = ReportLag("c2")
+	assert.Equal(t, 10*time.Second, lag)
+
+	recordReportLag("c3", &otev1.ClusterStatus{Timestamp: 1571360010}, now)
+	lag, ok = ReportLag("c3")
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), lag)
+
+	recordReportLag("c4", &otev1.ClusterStatus{}, now)
+	_, ok = ReportLag("c4")
+	assert.False(t, ok)
+}
@@ -18,7 +18,6 @@ package controllermanager
 
 import (
 	"encoding/json"
-	"fmt"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -33,7 +32,7 @@ import (
 func (u *UpstreamProcessor) handleServiceReport(b []byte) error {
 	srs, err := ServiceReportStatusDeserialize(b)
 	if err != nil {
-		return fmt.Errorf("ServiceReportStatusDeserialize failed: %v", err)
+		return errorOf(ErrInvalidReport, "ServiceReportStatusDeserialize failed: %v", err)
 	}
 
 	//handle FullList
@@ -63,6 +62,17 @@ func (u *UpstreamProcessor) handleServiceUpdateMap(updateMap map[string]*corev1.
 			continue
 		}
 
+		if err = transformResource(ServiceGVK, service); err != nil {
+			klog.Errorf("handleServiceUpdateMap's transformResource failed: %v", err)
+			continue
+		}
+
+		if reporter.IsServiceExported(service) {
+			// endpoints of exported service are reported by edge cluster,
+			// should not be managed by endpoints controller of center.
+			service.Spec.Selector = nil
+		}
+
 		err = u.CreateOrUpdateService(service)
 		if err != nil {
 			klog.Errorf("service: %s created or updated failed: %v", service.ObjectMeta.Name, err)
@@ -144,7 +154,7 @@ func (u *UpstreamProcessor) UpdateService(service *corev1.Service) error {
 		}
 
 		if !checkEdgeVersion(&service.ObjectMeta, &storedService.ObjectMeta) {
-			return fmt.Errorf("check service edge version failed")
+			return errorOf(ErrVersionConflict, "check service edge version failed")
 		}
 
 		adaptToCentralResource(&service.ObjectMeta, &storedService.ObjectMeta)
@@ -164,7 +174,7 @@ func (u *UpstreamProcessor) UpdateService(service *corev1.Service) error {
 		}
 
 		if !checkEdgeVersion(&service.ObjectMeta, &storedService.ObjectMeta) {
-			return fmt.Errorf("check service edge version failed")
+			return errorOf(ErrVersionConflict, "check service edge version failed")
 		}
 
 		adaptToCentralResource(&service.ObjectMeta, &storedService.ObjectMeta)
@@ -0,0 +1,216 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllermanager
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clusterselector"
+	"github.com/baidu/ote-stack/pkg/reporter"
+)
+
+// Kinds of resources reported by edge clusters, which are transformed before written to the center.
+var (
+	PodGVK        = corev1.SchemeGroupVersion.WithKind("Pod")
+	NodeGVK       = corev1.SchemeGroupVersion.WithKind("Node")
+	ServiceGVK    = corev1.SchemeGroupVersion.WithKind("Service")
+	EndpointsGVK  = corev1.SchemeGroupVersion.WithKind("Endpoints")
+	EventGVK      = corev1.SchemeGroupVersion.WithKind("Event")
+	DeploymentGVK = appsv1.SchemeGroupVersion.WithKind("Deployment")
+	DaemonSetGVK  = appsv1.SchemeGroupVersion.WithKind("DaemonSet")
+)
+
+// Transform transforms obj of a kind reported by cluster before it is written to the center,
+// e.g., strips fields, rewrites nodeName or adds labels. obj is typed, e.g., *corev1.Pod.
+// obj is not written if an error is returned.
+type Transform func(cluster string, obj runtime.Object) error
+
+var (
+	transformsLock sync.RWMutex
+	// transforms are registered by code, and ruleTransforms are of ResourceTransform crds.
+	transforms     = make(map[schema.GroupVersionKind][]Transform)
+	ruleTransforms = make(map[schema.GroupVersionKind][]Transform)
+)
+
+// RegistTransform registers t to transform resources of gvk reported by edge clusters.
+// Transforms are applied in order of registration, before ones of ResourceTransform crds.
+func RegistTransform(gvk schema.GroupVersionKind, t Transform) {
+	transformsLock.Lock()
+	defer transformsLock.Unlock()
+	transforms[gvk] = append(transforms[gvk], t)
+}
+
+// SetResourceTransforms replaces transforms of ResourceTransform crds by rts, which are applied
+// in order of their names. It returns errors of invalid ones by name, which are not applied.
+func SetResourceTransforms(rts []*otev1.ResourceTransform) map[string]error {
+	sorted := append([]*otev1.ResourceTransform(nil), rts...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Namespace+"/"+sorted[i].Name < sorted[j].Namespace+"/"+sorted[j].Name
+	})
+	errs := make(map[string]error)
+	rules := make(map[schema.GroupVersionKind][]Transform)
+	for _, rt := range sorted {
+		t, err := NewResourceTransform(&rt.Spec)
+		if err != nil {
+			errs[rt.Name] = err
+			continue
+		}
+		gvk := schema.FromAPIVersionAndKind(rt.Spec.APIVersion, rt.Spec.Kind)
+		rules[gvk] = append(rules[gvk], t)
+	}
+
+	transformsLock.Lock()
+	defer transformsLock.Unlock()
+	ruleTransforms = rules
+	return errs
+}
+
+// transformResource applies transforms of gvk to obj reported by the cluster of its ClusterLabel.
+// Transforms must not change the name, namespace, cluster or edge version of obj.
+func transformResource(gvk schema.GroupVersionKind, obj runtime.Object) error {
+	transformsLock.RLock()
+	ts := append(append([]Transform(nil), transforms[gvk]...), ruleTransforms[gvk]...)
+	transformsLock.RUnlock()
+	if len(ts) == 0 {
+		return nil
+	}
+
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return errorOf(ErrTransformFailed, "transform %s failed: %v", gvk.Kind, err)
+	}
+	cluster := accessor.GetLabels()[reporter.ClusterLabel]
+	identity := func() string {
+		labels := accessor.GetLabels()
+		return strings.Join([]string{accessor.GetNamespace(), accessor.GetName(),
+			labels[reporter.ClusterLabel], labels[reporter.EdgeVersionLabel]}, "/")
+	}
+	before := identity()
+	for _, t := range ts {
+		if err := t(cluster, obj); err != nil {
+			return errorOf(ErrTransformFailed, "transform %s %s of cluster %s failed: %v",
+				gvk.Kind, accessor.GetName(), cluster, err)
+		}
+		if identity() != before {
+			return errorOf(ErrTransformFailed, "transform %s %s of cluster %s changed its identity",
+				gvk.Kind, before, cluster)
+		}
+	}
+	return nil
+}
+
+// NewResourceTransform returns the Transform of spec of a ResourceTransform.
+func NewResourceTransform(spec *otev1.ResourceTransformSpec) (Transform, error) {
+	if spec.APIVersion == "" || spec.Kind == "" {
+		return nil, errorOf(ErrInvalidTransform, "apiVersion and kind are required")
+	}
+	var selector clusterselector.Selector
+	if spec.ClusterSelector != "" {
+		selector = clusterselector.NewSelector(spec.ClusterSelector)
+	}
+	fields := func(p string) ([]string, error) {
+		fs := strings.Split(p, ".")
+		for _, f := range fs {
+			if f == "" {
+				return nil, errorOf(ErrInvalidTransform, "field %q is invalid", p)
+			}
+		}
+		return fs, nil
+	}
+	var strip [][]string
+	for _, p := range spec.Strip {
+		fs, err := fields(p)
+		if err != nil {
+			return nil, err
+		}
+		strip = append(strip, fs)
+	}
+	var setPaths []string
+	for p := range spec.Set {
+		setPaths = append(setPaths, p)
+	}
+	sort.Strings(setPaths)
+	var set [][]string
+	for _, p := range setPaths {
+		fs, err := fields(p)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, fs)
+	}
+	for _, key := range []string{reporter.ClusterLabel, reporter.EdgeVersionLabel} {
+		if _, ok := spec.Labels[key]; ok {
+			return nil, errorOf(ErrInvalidTransform, "label %s is reserved", key)
+		}
+	}
+
+	return func(cluster string, obj runtime.Object) error {
+		if selector != nil && !selector.Has(cluster) {
+			return nil
+		}
+		u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return err
+		}
+		for _, fs := range strip {
+			unstructured.RemoveNestedField(u, fs...)
+		}
+		for i, fs := range set {
+			value := ""
+			if v, found, _ := unstructured.NestedFieldNoCopy(u, fs...); found && v != nil {
+				value = fmt.Sprint(v)
+			}
+			value = strings.NewReplacer(otev1.ResourceTransformClusterVar, cluster,
+				otev1.ResourceTransformValueVar, value).Replace(spec.Set[setPaths[i]])
+			if err := unstructured.SetNestedField(u, value, fs...); err != nil {
+				return err
+			}
+		}
+		for field, values := range map[string]map[string]string{
+			"labels":      spec.Labels,
+			"annotations": spec.Annotations,
+		} {
+			if len(values) == 0 {
+				continue
+			}
+			m, _, err := unstructured.NestedStringMap(u, "metadata", field)
+			if err != nil {
+				return err
+			}
+			if m == nil {
+				m = make(map[string]string)
+			}
+			for k, v := range values {
+				m[k] = strings.Replace(v, otev1.ResourceTransformClusterVar, cluster, -1)
+			}
+			if err := unstructured.SetNestedStringMap(u, m, "metadata", field); err != nil {
+				return err
+			}
+		}
+		return runtime.DefaultUnstructuredConverter.FromUnstructured(u, obj)
+	}, nil
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllermanager
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/errorkind"
+	"github.com/baidu/ote-stack/pkg/reporter"
+)
+
+func resetTransforms() {
+	transformsLock.Lock()
+	defer transformsLock.Unlock()
+	transforms = make(map[schema.GroupVersionKind][]Transform)
+	ruleTransforms = make(map[schema.GroupVersionKind][]Transform)
+}
+
+func newReportedPod(name, cluster string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "default",
+			Labels:      map[string]string{reporter.ClusterLabel: cluster, reporter.EdgeVersionLabel: "1"},
+			Annotations: map[string]string{"secret": "s"},
+		},
+		Spec: corev1.PodSpec{NodeName: "n1", Hostname: "h1"},
+	}
+}
+
+func TestResourceTransform(t *testing.T) {
+	transform, err := NewResourceTransform(&otev1.ResourceTransformSpec{
+		APIVersion:      "v1",
+		Kind:            "Pod",
+		ClusterSelector: "bj-.*",
+		Strip:           []string{"spec.hostname", "metadata.annotations.secret"},
+		Set:             map[string]string{"spec.nodeName": "${cluster}-${value}", "spec.subdomain": "${cluster}"},
+		Labels:          map[string]string{"region": "bj", "edge-cluster": "${cluster}"},
+	})
+	assert.Nil(t, err)
+
+	pod := newReportedPod("p1", "bj-1")
+	assert.Nil(t, transform("bj-1", pod))
+	assert.Equal(t, "bj-1-n1", pod.Spec.NodeName)
+	assert.Equal(t, "bj-1", pod.Spec.Subdomain)
+	assert.Empty(t, pod.Spec.Hostname)
+	assert.Empty(t, pod.Annotations)
+	assert.Equal(t, "bj", pod.Labels["region"])
+	assert.Equal(t, "bj-1", pod.Labels["edge-cluster"])
+	assert.Equal(t, "bj-1", pod.Labels[reporter.ClusterLabel])
+
+	// clusters not selected are not transformed.
+	pod = newReportedPod("p1", "sh-1")
+	assert.Nil(t, transform("sh-1", pod))
+	assert.Equal(t, newReportedPod("p1", "sh-1"), pod)
+
+	// a value can not be set to a field not of string.
+	transform, err = NewResourceTransform(&otev1.ResourceTransformSpec{
+		APIVersion: "v1",
+		Kind:       "Pod",
+		Set:        map[string]string{"spec.priority": "1"},
+	})
+	assert.Nil(t, err)
+	assert.Error(t, transform("bj-1", newReportedPod("p1", "bj-1")))
+
+	for _, spec := range []otev1.ResourceTransformSpec{
+		{Kind: "Pod"},
+		{APIVersion: "v1", Kind: "Pod", Strip: []string{"spec..hostname"}},
+		{APIVersion: "v1", Kind: "Pod", Set: map[string]string{"": "x"}},
+		{APIVersion: "v1", Kind: "Pod", Labels: map[string]string{reporter.ClusterLabel: "x"}},
+	} {
+		_, err := NewResourceTransform(&spec)
+		assert.Equal(t, ErrInvalidTransform, errorkind.Of(err), "%v", spec)
+	}
+}
+
+func TestTransformResource(t *testing.T) {
+	defer resetTransforms()
+
+	assert.Nil(t, transformResource(PodGVK, newReportedPod("p1", "c1")))
+
+	var clusters []string
+	RegistTransform(PodGVK, func(cluster string, obj runtime.Object) error {
+		clusters = append(clusters, cluster)
+		obj.(*corev1.Pod).Labels["by"] = "code"
+		return nil
+	})
+	errs := SetResourceTransforms([]*otev1.ResourceTransform{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "b"},
+			Spec:       otev1.ResourceTransformSpec{APIVersion: "v1", Kind: "Pod", Labels: map[string]string{"by": "b"}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "a"},
+			Spec:       otev1.ResourceTransformSpec{APIVersion: "v1", Kind: "Pod", Labels: map[string]string{"by": "a"}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "invalid"},
+			Spec:       otev1.ResourceTransformSpec{Kind: "Pod"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "node"},
+			Spec:       otev1.ResourceTransformSpec{APIVersion: "v1", Kind: "Node", Labels: map[string]string{"by": "node"}},
+		},
+	})
+	assert.Len(t, errs, 1)
+	assert.Error(t, errs["invalid"])
+
+	// transforms of code first, then ones of crds in order of names.
+	pod := newReportedPod("p1", "c1")
+	assert.Nil(t, transformResource(PodGVK, pod))
+	assert.Equal(t, []string{"c1"}, clusters)
+	assert.Equal(t, "b", pod.Labels["by"])
+
+	// transforms are replaced.
+	SetResourceTransforms(nil)
+	pod = newReportedPod("p1", "c1")
+	assert.Nil(t, transformResource(PodGVK, pod))
+	assert.Equal(t, "code", pod.Labels["by"])
+
+	// identity of resources can not be changed.
+	RegistTransform(PodGVK, func(cluster string, obj runtime.Object) error {
+		obj.(*corev1.Pod).Name = "renamed"
+		return nil
+	})
+	err := transformResource(PodGVK, newReportedPod("p1", "c1"))
+	assert.Equal(t, ErrTransformFailed, errorkind.Of(err))
+
+	resetTransforms()
+	RegistTransform(PodGVK, func(cluster string, obj runtime.Object) error {
+		return fmt.Errorf("dropped")
+	})
+	err = transformResource(PodGVK, newReportedPod("p1", "c1"))
+	assert.Equal(t, ErrTransformFailed, errorkind.Of(err))
+}
+
+func TestTransformReportedPod(t *testing.T) {
+	defer resetTransforms()
+	SetResourceTransforms([]*otev1.ResourceTransform{{
+		ObjectMeta: metav1.ObjectMeta{Name: "nodename"},
+		Spec: otev1.ResourceTransformSpec{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Set:        map[string]string{"spec.nodeName": "${value}-${cluster}"},
+		},
+	}})
+	RegistTransform(PodGVK, func(cluster string, obj runtime.Object) error {
+		if obj.(*corev1.Pod).Name == "p2-c1" {
+			return fmt.Errorf("dropped")
+		}
+		return nil
+	})
+
+	cs, tracker := newSimpleClientset()
+	u := NewUpstreamProcessor(&K8sContext{K8sClient: cs})
+	u.handlePodUpdateMap(map[string]*corev1.Pod{
+		"default/p1": newReportedPod("p1", "c1"),
+		"default/p2": newReportedPod("p2", "c1"),
+	})
+
+	obj, err := tracker.Get(podGroup, "default", "p1-c1")
+	assert.Nil(t, err)
+	assert.Equal(t, "n1-c1", obj.(*corev1.Pod).Spec.NodeName)
+	_, err = tracker.Get(podGroup, "default", "p2-c1")
+	assert.Error(t, err)
+}
@@ -17,10 +17,11 @@ limitations under the License.
 package controllermanager
 
 import (
-	"encoding/json"
-	"fmt"
+	"net/http"
 	"strconv"
+	"sync"
 
+	"github.com/golang/protobuf/proto"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/klog"
 
@@ -31,10 +32,42 @@ import (
 
 const (
 	UniqueResourceNameSeparator = "-"
+	// QuarantineLabel is set to the state epoch on mirrored resources reported before the epoch,
+	// it is removed once the resources are reported again by edge clusters.
+	QuarantineLabel = "ote-quarantined"
 )
 
 var noGracePeriodSeconds int64
 
+// ResponseHandler handles a ControlResp message from root cluster controller,
+// and returns true if msg is handled. msg is reused after it returns.
+type ResponseHandler func(msg *clustermessage.ClusterMessage) bool
+
+var (
+	responseHandlersLock sync.RWMutex
+	responseHandlers     []ResponseHandler
+)
+
+// RegistResponseHandler registers h to handle ControlResp messages, e.g., responses of
+// requests sent by a controller and waited for. Messages are passed to handlers in order
+// of registration until one handles it.
+func RegistResponseHandler(h ResponseHandler) {
+	responseHandlersLock.Lock()
+	defer responseHandlersLock.Unlock()
+	responseHandlers = append(responseHandlers, h)
+}
+
+func handleResponse(msg *clustermessage.ClusterMessage) bool {
+	responseHandlersLock.RLock()
+	defer responseHandlersLock.RUnlock()
+	for _, h := range responseHandlers {
+		if h(msg) {
+			return true
+		}
+	}
+	return false
+}
+
 // UpstreamProcessor processes msg from root cluster controller.
 type UpstreamProcessor struct {
 	ctx        *K8sContext
@@ -43,9 +76,11 @@ type UpstreamProcessor struct {
 
 // NewUpstreamProcessor new a UpstreamProcessor with k8s context.
 func NewUpstreamProcessor(ctx *K8sContext) *UpstreamProcessor {
+	clusterCRD := k8sclient.NewClusterCRD(ctx.OteClient)
+	clusterCRD.AdjustClockSkew = AdjustClockSkew
 	return &UpstreamProcessor{
 		ctx:        ctx,
-		clusterCRD: k8sclient.NewClusterCRD(ctx.OteClient),
+		clusterCRD: clusterCRD,
 	}
 }
 
@@ -53,16 +88,17 @@ func NewUpstreamProcessor(ctx *K8sContext) *UpstreamProcessor {
 // This function should be registed to controller tunnel.
 func (u *UpstreamProcessor) HandleReceivedMessage(client string, data []byte) (ret error) {
 	// get ClusterMessage from data
-	msg := &clustermessage.ClusterMessage{}
+	msg := clustermessage.AcquireClusterMessage()
+	defer clustermessage.ReleaseClusterMessage(msg)
 	err := msg.Deserialize(data)
 	if err != nil {
-		ret = fmt.Errorf("handleReceivedMessage failed %v", err)
+		ret = errorOf(ErrInvalidMessage, "handleReceivedMessage failed %v", err)
 		klog.Errorf("%v", ret)
 		return
 	}
 
 	if msg.Head == nil {
-		ret = fmt.Errorf("handleReceivedMessage failed: message head is nil")
+		ret = errorOf(ErrInvalidMessage, "handleReceivedMessage failed: message head is nil")
 		klog.Error(ret)
 		return
 	}
@@ -74,13 +110,43 @@ func (u *UpstreamProcessor) HandleReceivedMessage(client string, data []byte) (r
 		if ret != nil {
 			klog.Errorf("processEdgeReport failed: %v", ret)
 		}
+	case clustermessage.CommandType_ControlResp:
+		// responses of ClusterController crds are merged to crds by root cluster controller.
+		if !handleResponse(msg) {
+			klog.V(3).Infof("response %s from %s is not handled", msg.Head.MessageID, msg.Head.ClusterName)
+		}
+	case clustermessage.CommandType_ControlMultiResp:
+		ret = processControlMultiResp(msg)
+		if ret != nil {
+			klog.Errorf("processControlMultiResp failed: %v", ret)
+		}
 	default:
-		ret = fmt.Errorf("handleReceivedMessage failed: %s command not supported", msg.Head.Command.String())
+		ret = errorOf(ErrUnsupportedCommand, "handleReceivedMessage failed: %s command not supported", msg.Head.Command.String())
 		klog.Error(ret)
 	}
 	return
 }
 
+// processControlMultiResp logs bodies failed in a ControlMultiTask, which is sent without waiting for responses.
+func processControlMultiResp(msg *clustermessage.ClusterMessage) error {
+	resp := &clustermessage.ControlMultiTaskResponse{}
+	if err := proto.Unmarshal(msg.Body, resp); err != nil {
+		return errorOf(ErrInvalidMessage, "unmarshal ControlMultiTaskResponse from %s failed: %v", msg.Head.ClusterName, err)
+	}
+	failed := 0
+	for i, item := range resp.Items {
+		if item.StatusCode >= http.StatusOK && item.StatusCode < http.StatusMultipleChoices {
+			continue
+		}
+		failed++
+		klog.Warningf("body %d of task %s failed in cluster %s with status code %d: %s",
+			i, msg.Head.MessageID, msg.Head.ClusterName, item.StatusCode, item.Body)
+	}
+	klog.V(3).Infof("%d of %d bodies of task %s failed in cluster %s",
+		failed, len(resp.Items), msg.Head.MessageID, msg.Head.ClusterName)
+	return nil
+}
+
 func (u *UpstreamProcessor) processEdgeReport(msg *clustermessage.ClusterMessage) (err error) {
 	klog.V(3).Info("start processEdgeReport")
 
@@ -121,6 +187,10 @@ func (u *UpstreamProcessor) processEdgeReport(msg *clustermessage.ClusterMessage
 			if err = u.handleEventReport(report.Body); err != nil {
 				klog.Errorf("handleEventReport failed: %v", err)
 			}
+		case reporter.ResourceTypeEndpoints:
+			if err = u.handleEndpointsReport(report.Body); err != nil {
+				klog.Errorf("handleEndpointsReport failed: %v", err)
+			}
 		default:
 			klog.Errorf("processEdgeReport failed, reource type(%d) not support", report.ResourceType)
 		}
@@ -128,10 +198,9 @@ func (u *UpstreamProcessor) processEdgeReport(msg *clustermessage.ClusterMessage
 	return
 }
 
-// ReportDeserialize deserialize byte data to report slice.
+// ReportDeserialize deserialize byte data in any report encoding to report slice.
 func ReportDeserialize(b []byte) ([]reporter.Report, error) {
-	reports := []reporter.Report{}
-	err := json.Unmarshal(b, &reports)
+	reports, err := reporter.DecodeReports(b)
 	if err != nil {
 		return nil, err
 	}
@@ -141,7 +210,7 @@ func ReportDeserialize(b []byte) ([]reporter.Report, error) {
 // UniqueResourceName returns unique resource name.
 func UniqueResourceName(obj *metav1.ObjectMeta) error {
 	if obj.Labels[reporter.ClusterLabel] == "" {
-		return fmt.Errorf("ClusterLabel is empty")
+		return ErrClusterLabelEmpty
 	}
 	obj.Name = obj.Name + UniqueResourceNameSeparator + obj.Labels[reporter.ClusterLabel]
 
@@ -189,4 +258,7 @@ func adaptToCentralResource(reportResource *metav1.ObjectMeta, storedResource *m
 
 	// The resource from edge cluster should have the same uid of the one stored in etcd.
 	reportResource.UID = storedResource.UID
+
+	// The resource reported is refreshed and not quarantined any more.
+	delete(reportResource.Labels, QuarantineLabel)
 }
@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"testing"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/stretchr/testify/assert"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -34,6 +35,7 @@ import (
 	kubetesting "k8s.io/client-go/testing"
 
 	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/errorkind"
 	"github.com/baidu/ote-stack/pkg/reporter"
 )
 
@@ -69,6 +71,38 @@ func TestHandleReceivedMessage(t *testing.T) {
 	err = u.HandleReceivedMessage("", data)
 	assert.NotNil(t, err)
 
+	// get msg with command ControlResp
+	var handled []string
+	RegistResponseHandler(func(msg *clustermessage.ClusterMessage) bool {
+		handled = append(handled, msg.Head.MessageID)
+		return msg.Head.MessageID == "resp1"
+	})
+	defer func() { responseHandlers = nil }()
+	msg.Head = &clustermessage.MessageHead{Command: clustermessage.CommandType_ControlResp}
+	for _, id := range []string{"resp1", "resp2"} {
+		msg.Head.MessageID = id
+		data, err = msg.Serialize()
+		assert.Nil(t, err)
+		assert.Nil(t, u.HandleReceivedMessage("", data))
+	}
+	assert.Equal(t, []string{"resp1", "resp2"}, handled)
+
+	// get msg with command ControlMultiResp
+	multiResp, err := proto.Marshal(&clustermessage.ControlMultiTaskResponse{
+		Items: []*clustermessage.ControllerTaskResponse{{StatusCode: 201}, {StatusCode: 409}},
+	})
+	assert.Nil(t, err)
+	msg.Head = &clustermessage.MessageHead{Command: clustermessage.CommandType_ControlMultiResp}
+	msg.Body = multiResp
+	data, err = msg.Serialize()
+	assert.Nil(t, err)
+	assert.Nil(t, u.HandleReceivedMessage("", data))
+	msg.Body = []byte("invalid")
+	data, err = msg.Serialize()
+	assert.Nil(t, err)
+	assert.NotNil(t, u.HandleReceivedMessage("", data))
+	msg.Body = nil
+
 	// get msg with command EdgeReport
 	// TODO detail assert
 	podUpdatesMap := &reporter.PodResourceStatus{
@@ -167,7 +201,7 @@ func TestUniqueResourceName(t *testing.T) {
 
 	pod.Labels = make(map[string]string)
 	err = UniqueResourceName(&pod.ObjectMeta)
-	assert.Error(t, err)
+	assert.True(t, errorkind.Is(err, ErrClusterLabelEmpty))
 }
 
 func TestAdaptToCentralResource(t *testing.T) {
@@ -176,6 +210,7 @@ func TestAdaptToCentralResource(t *testing.T) {
 			Name:            "test-name",
 			UID:             "1234",
 			ResourceVersion: "1",
+			Labels:          map[string]string{QuarantineLabel: "2"},
 		},
 	}
 
@@ -190,4 +225,5 @@ func TestAdaptToCentralResource(t *testing.T) {
 	adaptToCentralResource(&pod.ObjectMeta, &storedPod.ObjectMeta)
 	assert.Equal(t, "5678", string(pod.UID))
 	assert.Equal(t, "2", pod.ResourceVersion)
+	assert.NotContains(t, pod.Labels, QuarantineLabel)
 }
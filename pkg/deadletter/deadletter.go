@@ -0,0 +1,445 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package deadletter keeps messages clustercontroller failed to handle, with reasons,
+// so that they are inspected and requeued instead of dropped silently.
+/*
+A message failed to be handled is retried at an interval up to the attempts configured,
+and kept as a dead letter once all attempts failed. Messages which cannot be decoded are
+kept at once, so are messages panicking their handlers, which are recovered to keep
+clustercontroller alive. Dead letters are kept in memory up to a limit, the oldest is dropped if exceeded.
+Dead letters are disabled unless the limit is set.
+*/
+package deadletter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+const (
+	// SourceParent is the source of messages received from the parent.
+	SourceParent = "parent"
+	// SourceChild is the source of messages received from childs.
+	SourceChild = "child"
+)
+
+const (
+	// ReasonUndecodable is the reason of a message which cannot be decoded.
+	ReasonUndecodable = "Undecodable"
+	// ReasonHandleFailed is the reason of a message failed to be handled after all attempts.
+	ReasonHandleFailed = "HandleFailed"
+	// ReasonUndeliverable is the reason of a message which cannot be sent on, e.g., to controller managers.
+	ReasonUndeliverable = "Undeliverable"
+	// ReasonPanicked is the reason of a message panicking its handler.
+	ReasonPanicked = "Panicked"
+)
+
+const (
+	// DefaultAttempts is the times a message is handled before kept as a dead letter by default.
+	DefaultAttempts = 3
+	// DefaultRetryInterval is the interval to handle a message failed again by default.
+	DefaultRetryInterval = 10 * time.Second
+	// DefaultQueryLimit is the max number of dead letters returned by a query by default.
+	DefaultQueryLimit = 1000
+)
+
+// Config is the config of dead letters.
+type Config struct {
+	// Limit is the max number of dead letters kept, dead letters are disabled if 0.
+	Limit int
+	// Attempts is the times a message is handled before kept, DefaultAttempts if 0.
+	Attempts int
+	// RetryInterval is the interval to handle a message failed again, DefaultRetryInterval if 0.
+	RetryInterval time.Duration
+}
+
+// Letter is a message failed to be handled.
+type Letter struct {
+	ID     uint64    `json:"id"`
+	Time   time.Time `json:"time"`
+	Source string    `json:"source"`
+	Peer   string    `json:"peer"`
+	Reason string    `json:"reason"`
+	// Error is the error of the last attempt.
+	Error    string `json:"error"`
+	Attempts int    `json:"attempts"`
+	// MessageID, Command and ClusterName are of head of the message, empty if undecodable.
+	MessageID   string `json:"messageID,omitempty"`
+	Command     string `json:"command,omitempty"`
+	ClusterName string `json:"clusterName,omitempty"`
+	// Data is the message serialized, which is handled again by requeuing.
+	Data []byte `json:"data"`
+}
+
+// Query selects dead letters, empty fields match all.
+type Query struct {
+	Source string
+	Peer   string
+	Reason string
+	// Limit is the max number of the latest dead letters returned, DefaultQueryLimit if 0.
+	Limit int
+}
+
+func (q *Query) match(l *Letter) bool {
+	if q.Source != "" && q.Source != l.Source {
+		return false
+	}
+	if q.Peer != "" && q.Peer != l.Peer {
+		return false
+	}
+	return q.Reason == "" || q.Reason == l.Reason
+}
+
+// RequeueFunc handles the message data from peer again.
+type RequeueFunc func(peer string, data []byte) error
+
+// Queue keeps dead letters in order of time.
+type Queue struct {
+	sync.Mutex
+	conf    Config
+	letters []*Letter
+	nextID  uint64
+	now     func() time.Time
+}
+
+var (
+	defaultQueue *Queue
+	// requeueFuncs are RequeueFuncs by source.
+	requeueFuncs sync.Map
+)
+
+// New returns a Queue with conf.
+func New(conf Config) (*Queue, error) {
+	if conf.Limit <= 0 {
+		return nil, fmt.Errorf("dead letter limit must be positive")
+	}
+	if conf.Attempts < 0 {
+		return nil, fmt.Errorf("dead letter attempts cannot be negative")
+	}
+	if conf.Attempts == 0 {
+		conf.Attempts = DefaultAttempts
+	}
+	if conf.RetryInterval < 0 {
+		return nil, fmt.Errorf("dead letter retry interval cannot be negative")
+	}
+	if conf.RetryInterval == 0 {
+		conf.RetryInterval = DefaultRetryInterval
+	}
+	return &Queue{
+		conf:   conf,
+		nextID: 1,
+		now:    time.Now,
+	}, nil
+}
+
+// Setup replaces the default queue with conf, disables dead letters if limit is 0.
+func Setup(conf Config) error {
+	if conf.Limit == 0 {
+		defaultQueue = nil
+		return nil
+	}
+	q, err := New(conf)
+	if err != nil {
+		return err
+	}
+	defaultQueue = q
+	klog.Infof("keep %d dead letters after %d attempts", q.conf.Limit, q.conf.Attempts)
+	return nil
+}
+
+// Enabled returns true if dead letters are enabled.
+func Enabled() bool {
+	return defaultQueue != nil
+}
+
+// RegistRequeueFunc registers fn to handle messages of source requeued.
+func RegistRequeueFunc(source string, fn RequeueFunc) {
+	requeueFuncs.Store(source, fn)
+}
+
+// Record keeps the message data from peer of source as a dead letter of reason by the default queue.
+// Messages are dropped as before if dead letters are disabled.
+func Record(source, peer, reason string, data []byte, err error) {
+	if defaultQueue == nil {
+		return
+	}
+	defaultQueue.Record(source, peer, reason, data, 1, err)
+}
+
+/*
+Retry handles the message data from peer of source again by handle, which failed by err,
+at the retry interval in background, and keeps it as a dead letter if all attempts failed.
+Messages are dropped as before if dead letters are disabled.
+*/
+func Retry(source, peer string, data []byte, err error, handle func() error) {
+	if defaultQueue == nil {
+		return
+	}
+	defaultQueue.Retry(source, peer, data, err, handle)
+}
+
+/*
+Recover recovers the panic of handling the message data from peer of source, if any,
+logs it with the message id and stack, and keeps the message as a dead letter of ReasonPanicked.
+err is set to the panic if not nil. It must be deferred by the handler directly.
+*/
+func Recover(source, peer string, data []byte, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	panicked(source, peer, data, r, err)
+}
+
+// RecoverMessage recovers the panic of handling msg from peer of source, see Recover.
+// msg is serialized only if panicked.
+func RecoverMessage(source, peer string, msg *clustermessage.ClusterMessage) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	data, _ := proto.Marshal(msg)
+	panicked(source, peer, data, r, nil)
+}
+
+func panicked(source, peer string, data []byte, r interface{}, err *error) {
+	panicErr := fmt.Errorf("handler panicked: %v", r)
+	if err != nil {
+		*err = panicErr
+	}
+	msg := &clustermessage.ClusterMessage{}
+	proto.Unmarshal(data, msg)
+	klog.Errorf("message %s from %s panicked: %v\n%s", msg.Head.GetMessageID(), peer, r, debug.Stack())
+	Record(source, peer, ReasonPanicked, data, panicErr)
+}
+
+// handleRecovered returns the error of handle, or the panic of it.
+func handleRecovered(handle func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+	}()
+	return handle()
+}
+
+// List returns the latest dead letters matched by query of the default queue.
+func List(query Query) []Letter {
+	if defaultQueue == nil {
+		return nil
+	}
+	return defaultQueue.List(query)
+}
+
+// Retry handles the message data failed by err again, see Retry.
+func (q *Queue) Retry(source, peer string, data []byte, err error, handle func() error) {
+	go func() {
+		for attempt := 2; attempt <= q.conf.Attempts; attempt++ {
+			time.Sleep(q.conf.RetryInterval)
+			if err = handleRecovered(handle); err == nil {
+				return
+			}
+		}
+		q.Record(source, peer, ReasonHandleFailed, data, q.conf.Attempts, err)
+	}()
+}
+
+// Record keeps the message data from peer of source as a dead letter of reason after attempts failed by err.
+func (q *Queue) Record(source, peer, reason string, data []byte, attempts int, err error) {
+	l := &Letter{
+		Source:   source,
+		Peer:     peer,
+		Reason:   reason,
+		Attempts: attempts,
+		Data:     data,
+	}
+	if err != nil {
+		l.Error = err.Error()
+	}
+	msg := &clustermessage.ClusterMessage{}
+	if proto.Unmarshal(data, msg) == nil && msg.Head != nil {
+		l.MessageID = msg.Head.MessageID
+		l.Command = msg.Head.Command.String()
+		l.ClusterName = msg.Head.ClusterName
+	}
+
+	q.Lock()
+	defer q.Unlock()
+	l.ID = q.nextID
+	q.nextID++
+	l.Time = q.now()
+	q.letters = append(q.letters, l)
+	if len(q.letters) > q.conf.Limit {
+		q.letters[0] = nil
+		q.letters = q.letters[1:]
+	}
+	klog.Warningf("message %s from %s is kept as dead letter %d: %s, %s", l.MessageID, peer, l.ID, reason, l.Error)
+}
+
+// List returns the latest dead letters matched by query in time order.
+func (q *Queue) List(query Query) []Letter {
+	if query.Limit == 0 {
+		query.Limit = DefaultQueryLimit
+	}
+	q.Lock()
+	defer q.Unlock()
+	letters := []Letter{}
+	for _, l := range q.letters {
+		if query.match(l) {
+			letters = append(letters, *l)
+		}
+	}
+	if len(letters) > query.Limit {
+		letters = letters[len(letters)-query.Limit:]
+	}
+	return letters
+}
+
+// Remove removes dead letters of ids, or all dead letters if ids is empty, and returns the ones removed.
+func (q *Queue) Remove(ids ...uint64) []Letter {
+	selected := make(map[uint64]bool, len(ids))
+	for _, id := range ids {
+		selected[id] = true
+	}
+	q.Lock()
+	defer q.Unlock()
+	var removed []Letter
+	kept := q.letters[:0]
+	for _, l := range q.letters {
+		if len(ids) == 0 || selected[l.ID] {
+			removed = append(removed, *l)
+			continue
+		}
+		kept = append(kept, l)
+	}
+	for i := len(kept); i < len(q.letters); i++ {
+		q.letters[i] = nil
+	}
+	q.letters = kept
+	return removed
+}
+
+/*
+Requeue removes dead letters of ids, or all dead letters if ids is empty, and handles them again
+by RequeueFuncs of their sources in order. It returns the number of dead letters requeued,
+and the error of the first one failed. Messages failed again are kept as new dead letters by handlers.
+*/
+func (q *Queue) Requeue(ids ...uint64) (int, error) {
+	var firstErr error
+	requeued := 0
+	for _, l := range q.Remove(ids...) {
+		fn, ok := requeueFuncs.Load(l.Source)
+		if !ok {
+			err := fmt.Errorf("dead letter %d of source %s cannot be requeued", l.ID, l.Source)
+			if firstErr == nil {
+				firstErr = err
+			}
+			q.Record(l.Source, l.Peer, l.Reason, l.Data, l.Attempts, err)
+			continue
+		}
+		klog.Infof("requeue dead letter %d of message %s from %s", l.ID, l.MessageID, l.Peer)
+		if err := fn.(RequeueFunc)(l.Peer, l.Data); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("requeue dead letter %d failed: %v", l.ID, err)
+		}
+		requeued++
+	}
+	return requeued, firstErr
+}
+
+/*
+Handler is the http handler of dead letters of the default queue.
+GET lists dead letters by query parameters of source, peer, reason and limit in json,
+POST requeues dead letters of ids in query parameter id, which can be repeated, or all if not set,
+and DELETE removes dead letters of ids, or all if not set.
+*/
+func Handler(w http.ResponseWriter, r *http.Request) {
+	q := defaultQueue
+	if q == nil {
+		http.Error(w, "dead letters are disabled", http.StatusNotFound)
+		return
+	}
+	values := r.URL.Query()
+	switch r.Method {
+	case http.MethodGet:
+		query, err := parseQuery(values)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := json.Marshal(q.List(query))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	case http.MethodPost:
+		ids, err := parseIDs(values)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		n, err := q.Requeue(ids...)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "%d dead letters are requeued\n", n)
+	case http.MethodDelete:
+		ids, err := parseIDs(values)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, "%d dead letters are removed\n", len(q.Remove(ids...)))
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func parseQuery(values url.Values) (Query, error) {
+	q := Query{
+		Source: values.Get("source"),
+		Peer:   values.Get("peer"),
+		Reason: values.Get("reason"),
+	}
+	if s := values.Get("limit"); s != "" {
+		var err error
+		if q.Limit, err = strconv.Atoi(s); err != nil || q.Limit < 0 {
+			return q, fmt.Errorf("limit %s is invalid", s)
+		}
+	}
+	return q, nil
+}
+
+func parseIDs(values url.Values) ([]uint64, error) {
+	var ids []uint64
+	for _, s := range values["id"] {
+		id, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("id %s is invalid", s)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,267 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package deadletter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+func newTestQueue(t *testing.T, conf Config) *Queue {
+	q, err := New(conf)
+	assert.Nil(t, err)
+	return q
+}
+
+func newMessageData(t *testing.T, id string) []byte {
+	data, err := proto.Marshal(&clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{
+			MessageID:   id,
+			Command:     clustermessage.CommandType_ControlReq,
+			ClusterName: "c1",
+		},
+	})
+	assert.Nil(t, err)
+	return data
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{})
+	assert.NotNil(t, err)
+	_, err = New(Config{Limit: 1, Attempts: -1})
+	assert.NotNil(t, err)
+	_, err = New(Config{Limit: 1, RetryInterval: -time.Second})
+	assert.NotNil(t, err)
+
+	q := newTestQueue(t, Config{Limit: 1})
+	assert.Equal(t, DefaultAttempts, q.conf.Attempts)
+	assert.Equal(t, DefaultRetryInterval, q.conf.RetryInterval)
+
+	assert.Nil(t, Setup(Config{}))
+	assert.False(t, Enabled())
+	assert.Nil(t, Setup(Config{Limit: 1}))
+	assert.True(t, Enabled())
+	assert.Nil(t, Setup(Config{}))
+}
+
+func TestRecord(t *testing.T) {
+	q := newTestQueue(t, Config{Limit: 2})
+	q.Record(SourceParent, "parent", ReasonUndecodable, []byte("bad"), 1, fmt.Errorf("bad proto"))
+	q.Record(SourceChild, "c1", ReasonHandleFailed, newMessageData(t, "m1"), 3, fmt.Errorf("failed"))
+
+	letters := q.List(Query{})
+	assert.Len(t, letters, 2)
+	assert.Equal(t, uint64(1), letters[0].ID)
+	assert.Equal(t, "bad proto", letters[0].Error)
+	assert.Equal(t, "", letters[0].MessageID)
+	assert.Equal(t, "m1", letters[1].MessageID)
+	assert.Equal(t, "ControlReq", letters[1].Command)
+	assert.Equal(t, "c1", letters[1].ClusterName)
+	assert.Equal(t, 3, letters[1].Attempts)
+
+	// letters are selected by query.
+	assert.Len(t, q.List(Query{Source: SourceChild}), 1)
+	assert.Len(t, q.List(Query{Peer: "parent"}), 1)
+	assert.Len(t, q.List(Query{Reason: ReasonHandleFailed}), 1)
+	assert.Len(t, q.List(Query{Reason: ReasonUndeliverable}), 0)
+	letters = q.List(Query{Limit: 1})
+	assert.Len(t, letters, 1)
+	assert.Equal(t, "m1", letters[0].MessageID)
+
+	// the oldest is dropped if exceeded.
+	q.Record(SourceChild, "c1", ReasonHandleFailed, newMessageData(t, "m2"), 3, nil)
+	letters = q.List(Query{})
+	assert.Len(t, letters, 2)
+	assert.Equal(t, "m1", letters[0].MessageID)
+	assert.Equal(t, "m2", letters[1].MessageID)
+}
+
+func TestRetry(t *testing.T) {
+	q := newTestQueue(t, Config{Limit: 10, Attempts: 3, RetryInterval: time.Millisecond})
+
+	// succeeded in attempts.
+	attempts := make(chan int, 10)
+	n := 1
+	q.Retry(SourceParent, "parent", newMessageData(t, "m1"), fmt.Errorf("failed"), func() error {
+		n++
+		attempts <- n
+		if n < 3 {
+			return fmt.Errorf("failed %d", n)
+		}
+		return nil
+	})
+	assert.Equal(t, 2, <-attempts)
+	assert.Equal(t, 3, <-attempts)
+	time.Sleep(10 * time.Millisecond)
+	assert.Len(t, q.List(Query{}), 0)
+
+	// kept after all attempts failed.
+	done := make(chan struct{}, 10)
+	q.Retry(SourceParent, "parent", newMessageData(t, "m2"), fmt.Errorf("failed"), func() error {
+		done <- struct{}{}
+		return fmt.Errorf("still failed")
+	})
+	<-done
+	<-done
+	for i := 0; i < 100 && len(q.List(Query{})) == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	letters := q.List(Query{})
+	assert.Len(t, letters, 1)
+	assert.Equal(t, ReasonHandleFailed, letters[0].Reason)
+	assert.Equal(t, "still failed", letters[0].Error)
+	assert.Equal(t, 3, letters[0].Attempts)
+}
+
+func TestRemoveAndRequeue(t *testing.T) {
+	q := newTestQueue(t, Config{Limit: 10})
+	for i := 1; i <= 4; i++ {
+		q.Record(SourceChild, "c1", ReasonUndeliverable, newMessageData(t, fmt.Sprintf("m%d", i)), 1, nil)
+	}
+	q.Record("unknown", "c1", ReasonUndeliverable, newMessageData(t, "m5"), 1, nil)
+
+	removed := q.Remove(1)
+	assert.Len(t, removed, 1)
+	assert.Equal(t, "m1", removed[0].MessageID)
+	assert.Len(t, q.List(Query{}), 4)
+
+	var requeued []string
+	RegistRequeueFunc(SourceChild, func(peer string, data []byte) error {
+		msg := &clustermessage.ClusterMessage{}
+		assert.Nil(t, proto.Unmarshal(data, msg))
+		requeued = append(requeued, peer+"/"+msg.Head.MessageID)
+		return nil
+	})
+	n, err := q.Requeue(3, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"c1/m2", "c1/m3"}, requeued)
+
+	// letters of sources without requeue funcs are kept.
+	n, err = q.Requeue()
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"c1/m2", "c1/m3", "c1/m4"}, requeued)
+	letters := q.List(Query{})
+	assert.Len(t, letters, 1)
+	assert.Equal(t, "m5", letters[0].MessageID)
+
+	assert.Len(t, q.Remove(), 1)
+	assert.Len(t, q.List(Query{}), 0)
+}
+
+func TestHandler(t *testing.T) {
+	assert.Nil(t, Setup(Config{}))
+	w := httptest.NewRecorder()
+	Handler(w, httptest.NewRequest(http.MethodGet, "/dead-letters", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	assert.Nil(t, Setup(Config{Limit: 10}))
+	defer Setup(Config{})
+	Record(SourceParent, "parent", ReasonUndecodable, []byte("bad"), fmt.Errorf("bad proto"))
+	Record(SourceParent, "parent", ReasonUndecodable, []byte("bad"), fmt.Errorf("bad proto"))
+
+	w = httptest.NewRecorder()
+	Handler(w, httptest.NewRequest(http.MethodGet, "/dead-letters?reason=Undecodable", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var letters []Letter
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &letters))
+	assert.Len(t, letters, 2)
+	assert.Equal(t, []byte("bad"), letters[0].Data)
+
+	w = httptest.NewRecorder()
+	Handler(w, httptest.NewRequest(http.MethodGet, "/dead-letters?limit=x", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	Handler(w, httptest.NewRequest(http.MethodDelete, "/dead-letters?id=x", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	requeued := 0
+	RegistRequeueFunc(SourceParent, func(peer string, data []byte) error {
+		requeued++
+		return nil
+	})
+	w = httptest.NewRecorder()
+	Handler(w, httptest.NewRequest(http.MethodPost, "/dead-letters?id=1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, requeued)
+
+	w = httptest.NewRecorder()
+	Handler(w, httptest.NewRequest(http.MethodDelete, "/dead-letters", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "1 dead letters are removed")
+
+	w = httptest.NewRecorder()
+	Handler(w, httptest.NewRequest(http.MethodPut, "/dead-letters", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestRecover(t *testing.T) {
+	assert.Nil(t, Setup(Config{Limit: 10, RetryInterval: time.Millisecond}))
+	defer Setup(Config{})
+
+	handle := func(data []byte) (err error) {
+		defer Recover(SourceParent, "parent", data, &err)
+		if len(data) != 0 {
+			var route map[string]string
+			route["c1"] = "c1"
+		}
+		return nil
+	}
+	assert.Nil(t, handle(nil))
+	err := handle(newMessageData(t, "m1"))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "panicked")
+	letters := List(Query{Reason: ReasonPanicked})
+	assert.Len(t, letters, 1)
+	assert.Equal(t, "m1", letters[0].MessageID)
+
+	func() {
+		defer RecoverMessage(SourceChild, "c1", &clustermessage.ClusterMessage{
+			Head: &clustermessage.MessageHead{MessageID: "m2"},
+		})
+		panic("bad message")
+	}()
+	letters = List(Query{Source: SourceChild})
+	assert.Len(t, letters, 1)
+	assert.Equal(t, "m2", letters[0].MessageID)
+	assert.Equal(t, "handler panicked: bad message", letters[0].Error)
+
+	// panics in retries are failures.
+	done := make(chan struct{}, 10)
+	Retry(SourceChild, "c1", newMessageData(t, "m3"), fmt.Errorf("failed"), func() error {
+		done <- struct{}{}
+		panic("still bad")
+	})
+	<-done
+	<-done
+	for i := 0; i < 100 && len(List(Query{Reason: ReasonHandleFailed})) == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	letters = List(Query{Reason: ReasonHandleFailed})
+	assert.Len(t, letters, 1)
+	assert.Equal(t, "handler panicked: still bad", letters[0].Error)
+}
@@ -0,0 +1,316 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package desiredstate persists the latest objects created or updated in an edge cluster by
+// tasks from the parent in a local directory, so that they are applied again if the cluster
+// restarts while disconnected from the parent, keeping workloads running autonomously.
+/*
+An object is persisted by its uri in a file, e.g., <dir>/<sha256 of uri>.json, when a task of
+destination api or apply posts or puts it, and removed when a task deletes it. Patches and
+subresources are not persisted. Persisting is disabled unless a directory is set.
+*/
+package desiredstate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+const (
+	// DefaultReplayAfter is the time to wait for the parent after started before replaying by default.
+	DefaultReplayAfter = time.Minute
+	// MessagePrefix is the prefix of ids of messages replaying the desired state.
+	MessagePrefix = "desired-state-"
+
+	fileSuffix = ".json"
+)
+
+// Config is the config of desired state.
+type Config struct {
+	// Dir is the directory to persist objects, persisting is disabled if empty.
+	Dir string
+	// ReplayAfter is the time to wait for the parent after started, the desired state is replayed
+	// if not connected in it, DefaultReplayAfter if 0.
+	ReplayAfter time.Duration
+}
+
+// Object is an object persisted.
+type Object struct {
+	// URI is the uri of the object, e.g., /api/v1/namespaces/default/pods/nginx.
+	URI string `json:"uri"`
+	// MessageID is the id of the message of the task creating or updating the object last.
+	MessageID string    `json:"messageID"`
+	Time      time.Time `json:"time"`
+	// Body is the object in json, without resource version and uid.
+	Body json.RawMessage `json:"body"`
+}
+
+// Message returns the message applying the object to the shim.
+func (o *Object) Message() (*clustermessage.ClusterMessage, error) {
+	task := &clustermessage.ControllerTask{
+		Destination: otev1.ClusterControllerDestApply,
+		Method:      http.MethodPost,
+		URI:         path.Dir(o.URI),
+		Body:        o.Body,
+	}
+	body, err := proto.Marshal(task)
+	if err != nil {
+		return nil, err
+	}
+	return &clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{
+			MessageID: MessagePrefix + string(uuid.NewUUID()),
+			Command:   clustermessage.CommandType_ControlReq,
+		},
+		Body: body,
+	}, nil
+}
+
+// Store persists objects of tasks by uri.
+type Store struct {
+	sync.Mutex
+	conf    Config
+	objects map[string]*Object
+	now     func() time.Time
+}
+
+var defaultStore *Store
+
+// New returns a Store with conf, and loads the objects persisted in the directory.
+func New(conf Config) (*Store, error) {
+	if conf.Dir == "" {
+		return nil, fmt.Errorf("desired state directory is empty")
+	}
+	if conf.ReplayAfter < 0 {
+		return nil, fmt.Errorf("desired state replay time cannot be negative")
+	}
+	if conf.ReplayAfter == 0 {
+		conf.ReplayAfter = DefaultReplayAfter
+	}
+	if err := os.MkdirAll(conf.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("create desired state directory %s failed: %v", conf.Dir, err)
+	}
+	s := &Store{
+		conf:    conf,
+		objects: make(map[string]*Object),
+		now:     time.Now,
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Setup replaces the default store with conf, disables persisting if directory is empty.
+func Setup(conf Config) error {
+	if conf.Dir == "" {
+		defaultStore = nil
+		return nil
+	}
+	s, err := New(conf)
+	if err != nil {
+		return err
+	}
+	defaultStore = s
+	klog.Infof("persist desired state in %s, %d objects loaded", conf.Dir, len(s.objects))
+	return nil
+}
+
+// Record persists the object of task of message id by the default store.
+func Record(id string, task *clustermessage.ControllerTask) {
+	if defaultStore == nil {
+		return
+	}
+	defaultStore.Record(id, task)
+}
+
+// Objects returns the objects persisted by the default store, nil if disabled.
+func Objects() []Object {
+	if defaultStore == nil {
+		return nil
+	}
+	return defaultStore.Objects()
+}
+
+// ReplayAfter returns the time to wait for the parent before replaying of the default store.
+func ReplayAfter() time.Duration {
+	if defaultStore == nil {
+		return DefaultReplayAfter
+	}
+	return defaultStore.conf.ReplayAfter
+}
+
+// Handler is the http handler to list objects of the default store in json.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	if defaultStore == nil {
+		http.Error(w, "desired state is disabled", http.StatusNotFound)
+		return
+	}
+	data, _ := json.Marshal(defaultStore.Objects())
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// Record persists the object created or updated by task of message id, or removes the object deleted.
+func (s *Store) Record(id string, task *clustermessage.ControllerTask) {
+	if task == nil {
+		return
+	}
+	if task.Destination != otev1.ClusterControllerDestAPI && task.Destination != otev1.ClusterControllerDestApply {
+		return
+	}
+	uri := strings.SplitN(task.URI, "?", 2)[0]
+	switch task.Method {
+	case http.MethodPost, http.MethodPut:
+		obj, err := objectOf(task.Method, uri, task.Body)
+		if err != nil {
+			klog.V(3).Infof("object of task %s is not persisted: %v", id, err)
+			return
+		}
+		obj.MessageID = id
+		s.put(obj)
+	case http.MethodDelete:
+		s.remove(uri)
+	}
+}
+
+/*
+objectOf returns the object posted to the collection uri or put to the object uri in body.
+The resource version and uid are dropped, so that the object is created again if lost,
+or updated regardless of versions.
+*/
+func objectOf(method, uri string, body []byte) (*Object, error) {
+	obj := make(map[string]interface{})
+	if err := json.Unmarshal(body, &obj); err != nil {
+		return nil, fmt.Errorf("body is not an object: %v", err)
+	}
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if method == http.MethodPost {
+		uri = strings.TrimSuffix(uri, "/") + "/" + name
+	} else if path.Base(uri) != name {
+		return nil, fmt.Errorf("uri %s is not of object %s", uri, name)
+	}
+	delete(metadata, "resourceVersion")
+	delete(metadata, "uid")
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	return &Object{URI: uri, Body: data}, nil
+}
+
+func (s *Store) put(obj *Object) {
+	s.Lock()
+	defer s.Unlock()
+	obj.Time = s.now()
+	data, err := json.Marshal(obj)
+	if err != nil {
+		klog.Errorf("marshal desired state of %s failed: %v", obj.URI, err)
+		return
+	}
+	file := s.file(obj.URI)
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		klog.Errorf("persist desired state of %s failed: %v", obj.URI, err)
+		return
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		klog.Errorf("persist desired state of %s failed: %v", obj.URI, err)
+		return
+	}
+	s.objects[obj.URI] = obj
+}
+
+func (s *Store) remove(uri string) {
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.objects[uri]; !ok {
+		return
+	}
+	if err := os.Remove(s.file(uri)); err != nil && !os.IsNotExist(err) {
+		klog.Errorf("remove desired state of %s failed: %v", uri, err)
+		return
+	}
+	delete(s.objects, uri)
+}
+
+// Objects returns the objects persisted in order of time.
+func (s *Store) Objects() []Object {
+	s.Lock()
+	defer s.Unlock()
+	objects := make([]Object, 0, len(s.objects))
+	for _, obj := range s.objects {
+		objects = append(objects, *obj)
+	}
+	sort.SliceStable(objects, func(i, j int) bool {
+		if objects[i].Time.Equal(objects[j].Time) {
+			return objects[i].URI < objects[j].URI
+		}
+		return objects[i].Time.Before(objects[j].Time)
+	})
+	return objects
+}
+
+// load loads the objects persisted, files broken are skipped.
+func (s *Store) load() error {
+	files, err := ioutil.ReadDir(s.conf.Dir)
+	if err != nil {
+		return fmt.Errorf("read desired state directory %s failed: %v", s.conf.Dir, err)
+	}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), fileSuffix) {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(s.conf.Dir, f.Name()))
+		if err != nil {
+			klog.Errorf("read desired state %s failed: %v", f.Name(), err)
+			continue
+		}
+		obj := &Object{}
+		if err := json.Unmarshal(data, obj); err != nil || obj.URI == "" {
+			klog.Errorf("desired state %s is broken, skip it", f.Name())
+			continue
+		}
+		s.objects[obj.URI] = obj
+	}
+	return nil
+}
+
+func (s *Store) file(uri string) string {
+	sum := sha256.Sum256([]byte(uri))
+	return filepath.Join(s.conf.Dir, hex.EncodeToString(sum[:])+fileSuffix)
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package desiredstate
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+func newTestStore(t *testing.T) *Store {
+	dir, err := ioutil.TempDir("", "desiredstate")
+	assert.Nil(t, err)
+	s, err := New(Config{Dir: dir})
+	assert.Nil(t, err)
+	return s
+}
+
+func newTask(destination, method, uri, body string) *clustermessage.ControllerTask {
+	return &clustermessage.ControllerTask{
+		Destination: destination,
+		Method:      method,
+		URI:         uri,
+		Body:        []byte(body),
+	}
+}
+
+const (
+	pods = "/api/v1/namespaces/default/pods"
+	pod  = `{"metadata":{"name":"nginx","resourceVersion":"3","uid":"u1"},"spec":{}}`
+)
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{})
+	assert.NotNil(t, err)
+	_, err = New(Config{Dir: "/tmp", ReplayAfter: -time.Second})
+	assert.NotNil(t, err)
+
+	s := newTestStore(t)
+	defer os.RemoveAll(s.conf.Dir)
+	assert.Equal(t, DefaultReplayAfter, s.conf.ReplayAfter)
+}
+
+func TestRecord(t *testing.T) {
+	s := newTestStore(t)
+	defer os.RemoveAll(s.conf.Dir)
+
+	s.Record("m1", newTask(otev1.ClusterControllerDestAPI, http.MethodPost, pods, pod))
+	s.Record("m2", newTask(otev1.ClusterControllerDestApply, http.MethodPut, pods+"/redis?dryRun=All",
+		`{"metadata":{"name":"redis"}}`))
+	// not persisted.
+	s.Record("m3", newTask(otev1.ClusterControllerDestAPI, http.MethodGet, pods, ""))
+	s.Record("m4", newTask(otev1.ClusterControllerDestAPI, http.MethodPatch, pods+"/nginx", `{"spec":{}}`))
+	s.Record("m5", newTask(otev1.ClusterControllerDestAPI, http.MethodPut, pods+"/nginx/status", pod))
+	s.Record("m6", newTask(otev1.ClusterControllerDestExec, http.MethodPost, pods, pod))
+	s.Record("m7", newTask(otev1.ClusterControllerDestAPI, http.MethodPost, pods, "sealed"))
+	s.Record("m8", nil)
+
+	objects := s.Objects()
+	assert.Len(t, objects, 2)
+	assert.Equal(t, pods+"/nginx", objects[0].URI)
+	assert.Equal(t, "m1", objects[0].MessageID)
+	assert.JSONEq(t, `{"metadata":{"name":"nginx"},"spec":{}}`, string(objects[0].Body))
+	assert.Equal(t, pods+"/redis", objects[1].URI)
+
+	// objects are loaded after restarted.
+	loaded, err := New(s.conf)
+	assert.Nil(t, err)
+	assert.Len(t, loaded.Objects(), 2)
+
+	// objects deleted are removed.
+	s.Record("m9", newTask(otev1.ClusterControllerDestAPI, http.MethodDelete, pods+"/nginx", ""))
+	objects = s.Objects()
+	assert.Len(t, objects, 1)
+	assert.Equal(t, pods+"/redis", objects[0].URI)
+	files, err := filepath.Glob(filepath.Join(s.conf.Dir, "*"+fileSuffix))
+	assert.Nil(t, err)
+	assert.Len(t, files, 1)
+
+	// broken files are skipped.
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(s.conf.Dir, "broken"+fileSuffix), []byte("{"), 0644))
+	loaded, err = New(s.conf)
+	assert.Nil(t, err)
+	assert.Len(t, loaded.Objects(), 1)
+}
+
+func TestObjectMessage(t *testing.T) {
+	obj := &Object{URI: pods + "/nginx", Body: json.RawMessage(`{"metadata":{"name":"nginx"}}`)}
+	msg, err := obj.Message()
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(msg.Head.MessageID, MessagePrefix))
+	assert.Equal(t, clustermessage.CommandType_ControlReq, msg.Head.Command)
+	task := &clustermessage.ControllerTask{}
+	assert.Nil(t, proto.Unmarshal(msg.Body, task))
+	assert.Equal(t, otev1.ClusterControllerDestApply, task.Destination)
+	assert.Equal(t, http.MethodPost, task.Method)
+	assert.Equal(t, pods, task.URI)
+	assert.Equal(t, []byte(obj.Body), task.Body)
+}
+
+func TestHandler(t *testing.T) {
+	assert.Nil(t, Setup(Config{}))
+	assert.Nil(t, Objects())
+	w := httptest.NewRecorder()
+	Handler(w, httptest.NewRequest(http.MethodGet, "/desired-state", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	dir, err := ioutil.TempDir("", "desiredstate")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, Setup(Config{Dir: dir, ReplayAfter: time.Second}))
+	defer Setup(Config{})
+	assert.Equal(t, time.Second, ReplayAfter())
+	Record("m1", newTask(otev1.ClusterControllerDestAPI, http.MethodPost, pods, pod))
+
+	w = httptest.NewRecorder()
+	Handler(w, httptest.NewRequest(http.MethodGet, "/desired-state", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var objects []Object
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &objects))
+	assert.Len(t, objects, 1)
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgehandler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+)
+
+// maxExpiredTasks is the max number of tasks timed out remembered to drop their late responses,
+// the oldest is dropped if exceeded.
+var maxExpiredTasks = 1024
+
+/*
+pendingTasks are the tasks dispatched to a shim responding asynchronously, e.g., a remote shim,
+and not responded yet, by message id. A task not responded before its deadline is responded
+with timeout by edgehandler, and its late response from the shim is dropped.
+*/
+type pendingTasks struct {
+	lock   sync.Mutex
+	timers map[string]*time.Timer
+	// expired are the tasks responded with timeout by the time they expired.
+	expired map[string]time.Time
+}
+
+func newPendingTasks() *pendingTasks {
+	return &pendingTasks{
+		timers:  make(map[string]*time.Timer),
+		expired: make(map[string]time.Time),
+	}
+}
+
+// add waits for the response of the task of id in timeout, after which expire is called.
+func (p *pendingTasks) add(id string, timeout time.Duration, expire func()) {
+	if p == nil || id == "" {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if timer, ok := p.timers[id]; ok {
+		timer.Stop()
+	}
+	delete(p.expired, id)
+	var timer *time.Timer
+	timer = time.AfterFunc(timeout, func() {
+		p.lock.Lock()
+		if p.timers[id] != timer {
+			// responded or dispatched again.
+			p.lock.Unlock()
+			return
+		}
+		delete(p.timers, id)
+		p.expire(id, time.Now())
+		p.lock.Unlock()
+		expire()
+	})
+	p.timers[id] = timer
+}
+
+func (p *pendingTasks) expire(id string, now time.Time) {
+	p.expired[id] = now
+	if len(p.expired) <= maxExpiredTasks {
+		return
+	}
+	oldest := ""
+	for k, t := range p.expired {
+		if oldest == "" || t.Before(p.expired[oldest]) {
+			oldest = k
+		}
+	}
+	delete(p.expired, oldest)
+}
+
+// done stops waiting for the task of id responded, and returns false if it has been responded with timeout.
+func (p *pendingTasks) done(id string) bool {
+	if p == nil {
+		return true
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if timer, ok := p.timers[id]; ok {
+		timer.Stop()
+		delete(p.timers, id)
+		return true
+	}
+	if _, ok := p.expired[id]; ok {
+		delete(p.expired, id)
+		return false
+	}
+	return true
+}
+
+// len returns the number of tasks waiting for responses.
+func (p *pendingTasks) len() int {
+	if p == nil {
+		return 0
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return len(p.timers)
+}
+
+// timeoutResponse returns the response of the task of msg not responded by the shim in timeout.
+func (e *edgeHandler) timeoutResponse(msg *clustermessage.ClusterMessage,
+	timeout time.Duration) *clustermessage.ClusterMessage {
+	head := proto.Clone(msg.Head).(*clustermessage.MessageHead)
+	head.Command = clustermessage.CommandType_ControlResp
+	head.ClusterName = e.conf.ClusterName
+	body := fmt.Sprintf("task %s is not responded by shim of cluster %s in %s",
+		msg.Head.MessageID, e.conf.ClusterName, timeout)
+	return handler.Response(handler.ControlTaskResponse(http.StatusGatewayTimeout, body), head)
+}
+
+/*
+doWithDeadline dispatches the control request msg to the shim, and responds timeout to the parent
+if the shim does not respond in the shim timeout, e.g., hangs ignoring ctx, or a shim responding
+asynchronously never responds. nil is returned once responded with timeout,
+and the call to the shim hung is left behind.
+*/
+func (e *edgeHandler) doWithDeadline(ctx context.Context,
+	msg *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	timeout := e.conf.ShimTimeout
+	id := msg.Head.MessageID
+	if timeout <= 0 || id == "" {
+		return e.doControlRequest(ctx, msg)
+	}
+	type result struct {
+		resp *clustermessage.ClusterMessage
+		err  error
+	}
+	expired := make(chan struct{})
+	// the timeout response is built before dispatched, since the head of msg may be
+	// reused by the response of the shim while the deadline expires.
+	timeoutResp := e.timeoutResponse(msg, timeout)
+	// the deadline starts before dispatched, so that an asynchronous response is not missed.
+	e.pending.add(id, timeout, func() {
+		klog.Warningf("task %s is not responded by shim in %s, respond timeout", id, timeout)
+		e.sendToParent(timeoutResp)
+		close(expired)
+	})
+	done := make(chan result, 1)
+	go func() {
+		resp, err := e.doControlRequest(ctx, msg)
+		done <- result{resp, err}
+	}()
+	select {
+	case r := <-done:
+		if r.resp == nil && r.err == nil {
+			// responded asynchronously, which is waited for by pending tasks.
+			return nil, nil
+		}
+		if !e.pending.done(id) {
+			klog.Warningf("drop response of task %s done by shim after timeout", id)
+			return nil, nil
+		}
+		return r.resp, r.err
+	case <-expired:
+		return nil, nil
+	}
+}
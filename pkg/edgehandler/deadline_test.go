@@ -0,0 +1,190 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgehandler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/clustershim"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+	"github.com/baidu/ote-stack/pkg/config"
+	"github.com/baidu/ote-stack/pkg/rungroup"
+)
+
+func TestPendingTasks(t *testing.T) {
+	p := newPendingTasks()
+	expired := make(chan string, 2)
+	p.add("t1", time.Hour, func() { expired <- "t1" })
+	p.add("t2", 10*time.Millisecond, func() { expired <- "t2" })
+	assert.Equal(t, 2, p.len())
+	assert.Equal(t, "t2", <-expired)
+	assert.Equal(t, 1, p.len())
+
+	// the response of a task expired is dropped once.
+	assert.False(t, p.done("t2"))
+	assert.True(t, p.done("t2"))
+	assert.True(t, p.done("t1"))
+	assert.Equal(t, 0, p.len())
+	assert.True(t, p.done("unknown"))
+
+	// a task dispatched again waits for its deadline again.
+	p.add("t3", 10*time.Millisecond, func() { expired <- "t3" })
+	p.add("t3", time.Hour, func() { expired <- "t3 again" })
+	select {
+	case id := <-expired:
+		t.Errorf("task %s expired", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.True(t, p.done("t3"))
+
+	max := maxExpiredTasks
+	defer func() { maxExpiredTasks = max }()
+	maxExpiredTasks = 1
+	now := time.Now()
+	p.expire("t4", now)
+	p.expire("t5", now.Add(time.Second))
+	assert.True(t, p.done("t4"))
+	assert.False(t, p.done("t5"))
+
+	var nilTasks *pendingTasks
+	nilTasks.add("t1", time.Millisecond, func() { t.Errorf("nil pending tasks expired") })
+	assert.True(t, nilTasks.done("t1"))
+	assert.Equal(t, 0, nilTasks.len())
+}
+
+// hangingShimHandler blocks tasks until released, ignoring their contexts.
+type hangingShimHandler struct {
+	release chan struct{}
+}
+
+func (h *hangingShimHandler) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	<-h.release
+	return handler.Response(handler.ControlTaskResponse(http.StatusOK, ""), in.Head), nil
+}
+
+// asyncShim responds tasks by its return chan.
+type asyncShim struct {
+	returnChan chan *clustermessage.ClusterMessage
+}
+
+func (a *asyncShim) Do(ctx context.Context, in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	return nil, nil
+}
+
+func (a *asyncShim) ReturnChan() <-chan *clustermessage.ClusterMessage {
+	return a.returnChan
+}
+
+func (a *asyncShim) Destinations() []string {
+	return []string{otev1.ClusterControllerDestAPI}
+}
+
+func assertNotSent(t *testing.T, tun *recordingEdgeTunnel) {
+	select {
+	case msg := <-tun.sent:
+		t.Errorf("message %s of %s is sent", msg.Head.MessageID, msg.Head.Command.String())
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestTimeoutOfHangingShim(t *testing.T) {
+	shim := &hangingShimHandler{release: make(chan struct{})}
+	tun := &recordingEdgeTunnel{sent: make(chan *clustermessage.ClusterMessage, 10)}
+	conf := &config.ClusterControllerConfig{
+		ClusterName:       "child",
+		EdgeToClusterChan: make(chan clustermessage.ClusterMessage, 10),
+		ShimTimeout:       100 * time.Millisecond,
+	}
+	edge := NewEdgeHandlerWithShim(conf,
+		clustershim.NewlocalShimClientWithHandler(clustershim.ShimHandler{otev1.ClusterControllerDestAPI: shim}),
+		nil).(*edgeHandler)
+	edge.edgeTunnel = tun
+
+	assert.Nil(t, edge.receiveMessageFromTunnel("parent", workerTask(t, "hang", "default")))
+	resp := <-tun.sent
+	assert.Equal(t, "hang", resp.Head.MessageID)
+	assert.Equal(t, clustermessage.CommandType_ControlResp, resp.Head.Command)
+	assert.Equal(t, "child", resp.Head.ClusterName)
+	assert.Equal(t, int32(http.StatusGatewayTimeout), responseStatus(t, resp))
+
+	// the response of the shim after timeout is dropped.
+	close(shim.release)
+	assertNotSent(t, tun)
+}
+
+func TestTimeoutOfAsyncShim(t *testing.T) {
+	shim := &asyncShim{returnChan: make(chan *clustermessage.ClusterMessage, 10)}
+	tun := &recordingEdgeTunnel{sent: make(chan *clustermessage.ClusterMessage, 10)}
+	conf := &config.ClusterControllerConfig{
+		ClusterName:       "child",
+		EdgeToClusterChan: make(chan clustermessage.ClusterMessage, 10),
+		ShimTimeout:       100 * time.Millisecond,
+	}
+	edge := NewEdgeHandlerWithShim(conf, shim, nil).(*edgeHandler)
+	edge.edgeTunnel = tun
+	edge.group = rungroup.New(context.Background())
+	defer edge.Stop()
+	edge.group.Go("shim response handler", edge.handleRespFromShimClient)
+
+	response := func(id string) *clustermessage.ClusterMessage {
+		return handler.Response(handler.ControlTaskResponse(http.StatusOK, ""), &clustermessage.MessageHead{
+			MessageID: id,
+			Command:   clustermessage.CommandType_ControlResp,
+		})
+	}
+
+	// a task responded in time is not responded with timeout.
+	assert.Nil(t, edge.receiveMessageFromTunnel("parent", workerTask(t, "t1", "default")))
+	shim.returnChan <- response("t1")
+	resp := <-tun.sent
+	assert.Equal(t, "t1", resp.Head.MessageID)
+	assert.Equal(t, int32(http.StatusOK), responseStatus(t, resp))
+	assertNotSent(t, tun)
+
+	// a task not responded is responded with timeout, and its late response is dropped.
+	assert.Nil(t, edge.receiveMessageFromTunnel("parent", workerTask(t, "t2", "default")))
+	resp = <-tun.sent
+	assert.Equal(t, "t2", resp.Head.MessageID)
+	assert.Equal(t, int32(http.StatusGatewayTimeout), responseStatus(t, resp))
+	shim.returnChan <- response("t2")
+	assertNotSent(t, tun)
+	assert.Equal(t, 0, edge.pending.len())
+}
+
+func TestTimeoutResponseOfShim(t *testing.T) {
+	// the status of a task timed out by the shim is responded instead of an internal error.
+	tun := &recordingEdgeTunnel{sent: make(chan *clustermessage.ClusterMessage, 10)}
+	conf := &config.ClusterControllerConfig{
+		ClusterName:       "child",
+		EdgeToClusterChan: make(chan clustermessage.ClusterMessage, 10),
+	}
+	edge := NewEdgeHandlerWithShim(conf, newFakeShim(), nil).(*edgeHandler)
+	edge.edgeTunnel = tun
+	ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	msg := decodeWorkerTask(t, workerTask(t, "t1", "default"))
+	assert.Nil(t, edge.handleMessage(ctx, msg))
+	resp := <-tun.sent
+	assert.Equal(t, int32(http.StatusGatewayTimeout), responseStatus(t, resp))
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgehandler
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+var (
+	// dedupWindow is the time a message from the parent is remembered to drop its duplicates.
+	dedupWindow = 10 * time.Minute
+	// maxDedupMessages is the max number of messages remembered, the least recently seen is dropped if exceeded.
+	maxDedupMessages = 4096
+)
+
+/*
+dedupKey identifies a message from the parent by its message id, and the digest of its command,
+cluster selector and body, since messages of the same id may be different, e.g., waves of
+a rollout to different clusters and its rollback.
+*/
+type dedupKey struct {
+	id     string
+	digest [sha256.Size]byte
+}
+
+type dedupEntry struct {
+	key  dedupKey
+	seen time.Time
+}
+
+// dedupCache remembers messages from the parent by LRU, so that a message re-broadcast
+// through more than one route or retried by the parent is handled once.
+type dedupCache struct {
+	lock sync.Mutex
+	// order keeps entries by the time seen, the latest at front.
+	order   *list.List
+	entries map[dedupKey]*list.Element
+}
+
+func newDedupCache() *dedupCache {
+	return &dedupCache{
+		order:   list.New(),
+		entries: make(map[dedupKey]*list.Element),
+	}
+}
+
+func dedupKeyOf(msg *clustermessage.ClusterMessage) dedupKey {
+	h := sha256.New()
+	h.Write([]byte(msg.Head.Command.String()))
+	h.Write([]byte{0})
+	h.Write([]byte(msg.Head.ClusterSelector))
+	h.Write([]byte{0})
+	h.Write(msg.Body)
+	key := dedupKey{id: msg.Head.MessageID}
+	copy(key.digest[:], h.Sum(nil))
+	return key
+}
+
+// seen records msg seen at now, and returns true if it is seen in dedup window before.
+// Messages without message id, e.g., routes, are never seen.
+func (d *dedupCache) seen(msg *clustermessage.ClusterMessage, now time.Time) bool {
+	if d == nil || msg.Head == nil || msg.Head.MessageID == "" {
+		return false
+	}
+	key := dedupKeyOf(msg)
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if elem, ok := d.entries[key]; ok {
+		entry := elem.Value.(*dedupEntry)
+		duplicated := now.Sub(entry.seen) <= dedupWindow
+		entry.seen = now
+		d.order.MoveToFront(elem)
+		return duplicated
+	}
+	d.entries[key] = d.order.PushFront(&dedupEntry{key: key, seen: now})
+	for d.order.Len() > maxDedupMessages {
+		oldest := d.order.Back()
+		d.order.Remove(oldest)
+		delete(d.entries, oldest.Value.(*dedupEntry).key)
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgehandler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/config"
+)
+
+func dedupMessage(id, selector string) *clustermessage.ClusterMessage {
+	return &clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{
+			MessageID:       id,
+			Command:         clustermessage.CommandType_ControlReq,
+			ClusterSelector: selector,
+		},
+		Body: []byte("task"),
+	}
+}
+
+func TestDedupCache(t *testing.T) {
+	cache := newDedupCache()
+	now := time.Now()
+	assert.False(t, cache.seen(dedupMessage("m1", "c1"), now))
+	assert.True(t, cache.seen(dedupMessage("m1", "c1"), now.Add(time.Second)))
+	// messages of the same id to other clusters, e.g., the next wave, are not duplicates.
+	assert.False(t, cache.seen(dedupMessage("m1", "c2"), now))
+	rollback := dedupMessage("m1", "c1")
+	rollback.Body = []byte("rollback")
+	assert.False(t, cache.seen(rollback, now))
+	// messages without id are never seen.
+	assert.False(t, cache.seen(dedupMessage("", "c1"), now))
+	assert.False(t, cache.seen(dedupMessage("", "c1"), now))
+	assert.False(t, cache.seen(&clustermessage.ClusterMessage{}, now))
+	// messages are forgotten after dedup window.
+	assert.False(t, cache.seen(dedupMessage("m1", "c1"), now.Add(time.Second+dedupWindow+time.Second)))
+
+	var nilCache *dedupCache
+	assert.False(t, nilCache.seen(dedupMessage("m1", "c1"), now))
+	assert.False(t, nilCache.seen(dedupMessage("m1", "c1"), now))
+}
+
+func TestDedupCacheLimit(t *testing.T) {
+	max := maxDedupMessages
+	maxDedupMessages = 2
+	defer func() { maxDedupMessages = max }()
+
+	cache := newDedupCache()
+	now := time.Now()
+	cache.seen(dedupMessage("m1", "c1"), now)
+	cache.seen(dedupMessage("m2", "c1"), now)
+	// m1 is seen again, so m2 is the least recently seen.
+	assert.True(t, cache.seen(dedupMessage("m1", "c1"), now))
+	cache.seen(dedupMessage("m3", "c1"), now)
+	assert.Len(t, cache.entries, 2)
+	assert.Equal(t, 2, cache.order.Len())
+	assert.True(t, cache.seen(dedupMessage("m1", "c1"), now))
+	assert.False(t, cache.seen(dedupMessage("m2", "c1"), now))
+}
+
+func TestReceiveDuplicatedMessage(t *testing.T) {
+	edge := &edgeHandler{
+		conf: &config.ClusterControllerConfig{
+			ClusterName:       "child",
+			EdgeToClusterChan: make(chan clustermessage.ClusterMessage, 10),
+		},
+		edgeTunnel: &fakeEdgeTunnel{},
+		shimClient: newFakeShim(),
+		dedup:      newDedupCache(),
+	}
+	data, err := proto.Marshal(dedupMessage("m1", "other"))
+	assert.Nil(t, err)
+	assert.Nil(t, edge.receiveMessageFromTunnel("parent", data))
+	assert.Nil(t, edge.receiveMessageFromTunnel("parent", data))
+	// the duplicate is not sent to subtree again.
+	assert.Len(t, edge.conf.EdgeToClusterChan, 1)
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package edgehandler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+	"github.com/baidu/ote-stack/pkg/desiredstate"
+)
+
+/*
+replayDesiredState applies the objects persisted to the shim again if the cluster is not
+connected to the parent in wait after started, so that workloads lost by the cluster restarted
+are recreated without the parent. Objects are not replayed once connected, since the parent
+sends tasks again as it does.
+*/
+func (e *edgeHandler) replayDesiredState(ctx context.Context, wait time.Duration) {
+	objects := desiredstate.Objects()
+	if len(objects) == 0 {
+		return
+	}
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(wait):
+	}
+	if atomic.LoadInt32(&e.connected) == 1 {
+		klog.Infof("connected to parent, desired state is not replayed")
+		return
+	}
+	klog.Infof("not connected to parent in %v, replay desired state of %d objects", wait, len(objects))
+	for i := range objects {
+		msg, err := objects[i].Message()
+		if err != nil {
+			klog.Errorf("build message to replay %s failed: %v", objects[i].URI, err)
+			continue
+		}
+		klog.V(3).Infof("replay %s by message %s", objects[i].URI, msg.Head.MessageID)
+		resp, err := e.shimClient.Do(handler.WithTraceID(ctx, msg.Head.MessageID), msg)
+		if err != nil {
+			klog.Errorf("replay %s failed: %v", objects[i].URI, err)
+			continue
+		}
+		// responses of remote shims are logged when returned.
+		if resp != nil {
+			logReplayResponse(resp)
+		}
+	}
+}
+
+// isReplayResponse returns true if resp is of a message replaying the desired state,
+// which is logged instead of sent to the parent.
+func isReplayResponse(resp *clustermessage.ClusterMessage) bool {
+	if resp.Head == nil || !strings.HasPrefix(resp.Head.MessageID, desiredstate.MessagePrefix) {
+		return false
+	}
+	logReplayResponse(resp)
+	return true
+}
+
+func logReplayResponse(resp *clustermessage.ClusterMessage) {
+	taskResp := &clustermessage.ControllerTaskResponse{}
+	if err := proto.Unmarshal(resp.Body, taskResp); err != nil {
+		klog.Errorf("decode response of replay %s failed: %v", resp.Head.MessageID, err)
+		return
+	}
+	if taskResp.StatusCode < http.StatusOK || taskResp.StatusCode >= http.StatusMultipleChoices {
+		klog.Errorf("replay %s responses %d: %s", resp.Head.MessageID, taskResp.StatusCode, taskResp.Body)
+		return
+	}
+	klog.V(3).Infof("replay %s succeeded", resp.Head.MessageID)
+}
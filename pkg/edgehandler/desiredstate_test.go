@@ -0,0 +1,150 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package edgehandler
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/clustershim"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+	"github.com/baidu/ote-stack/pkg/config"
+	"github.com/baidu/ote-stack/pkg/desiredstate"
+)
+
+// applyRecorder records tasks applied.
+type applyRecorder struct {
+	fakeShimHandler
+	tasks []*clustermessage.ControllerTask
+}
+
+func (a *applyRecorder) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	a.tasks = append(a.tasks, handler.GetControllerTaskFromClusterMessage(in))
+	return a.fakeShimHandler.Do(in)
+}
+
+func TestDesiredState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "desiredstate")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, desiredstate.Setup(desiredstate.Config{Dir: dir}))
+	defer desiredstate.Setup(desiredstate.Config{})
+
+	applied := &applyRecorder{}
+	f := &fakeEdgeTunnel{
+		fakeEdgeTunnelSendChan: make(chan struct{}, 10),
+	}
+	edge := &edgeHandler{
+		conf: &config.ClusterControllerConfig{
+			ClusterName:       "child",
+			EdgeToClusterChan: make(chan clustermessage.ClusterMessage, 10),
+		},
+		edgeTunnel: f,
+		shimClient: clustershim.NewlocalShimClientWithHandler(clustershim.ShimHandler{
+			otev1.ClusterControllerDestAPI:   &fakeShimHandler{},
+			otev1.ClusterControllerDestApply: applied,
+		}),
+	}
+
+	// objects created by tasks from parent are persisted.
+	task, err := proto.Marshal(&clustermessage.ControllerTask{
+		Destination: otev1.ClusterControllerDestAPI,
+		Method:      http.MethodPost,
+		URI:         "/api/v1/namespaces/default/configmaps",
+		Body:        []byte(`{"metadata":{"name":"cm1","resourceVersion":"5"}}`),
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, edge.handleMessage(context.Background(), &clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{
+			MessageID: "m1",
+			Command:   clustermessage.CommandType_ControlReq,
+		},
+		Body: task,
+	}))
+	<-f.fakeEdgeTunnelSendChan
+	objects := desiredstate.Objects()
+	assert.Len(t, objects, 1)
+	assert.Equal(t, "/api/v1/namespaces/default/configmaps/cm1", objects[0].URI)
+
+	// not replayed once connected.
+	edge.connected = 1
+	edge.replayDesiredState(context.Background(), 0)
+	assert.Len(t, applied.tasks, 0)
+	edge.connected = 0
+
+	// replayed if not connected, responses are not sent to parent.
+	edge.replayDesiredState(context.Background(), 0)
+	assert.Len(t, applied.tasks, 1)
+	assert.Equal(t, "/api/v1/namespaces/default/configmaps", applied.tasks[0].URI)
+	assert.Equal(t, `{"metadata":{"name":"cm1"}}`, string(applied.tasks[0].Body))
+	assert.Len(t, f.fakeEdgeTunnelSendChan, 0)
+}
+
+// applyNotifier sends tasks applied by tasks.
+type applyNotifier struct {
+	fakeShimHandler
+	tasks chan *clustermessage.ControllerTask
+}
+
+func (a *applyNotifier) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	a.tasks <- handler.GetControllerTaskFromClusterMessage(in)
+	return a.fakeShimHandler.Do(in)
+}
+
+func TestDesiredStateReplayedOnStartDisconnected(t *testing.T) {
+	dir, err := ioutil.TempDir("", "desiredstate")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, desiredstate.Setup(desiredstate.Config{Dir: dir, ReplayAfter: 10 * time.Millisecond}))
+	defer desiredstate.Setup(desiredstate.Config{})
+	desiredstate.Record("m1", &clustermessage.ControllerTask{
+		Destination: otev1.ClusterControllerDestAPI,
+		Method:      http.MethodPost,
+		URI:         "/api/v1/namespaces/default/configmaps",
+		Body:        []byte(`{"metadata":{"name":"cm1"}}`),
+	})
+
+	applied := &applyNotifier{tasks: make(chan *clustermessage.ControllerTask, 1)}
+	conf := &config.ClusterControllerConfig{
+		ClusterName:           "child",
+		ClusterUserDefineName: "child",
+		ParentCluster:         []string{"127.0.0.1:1"},
+		ClusterToEdgeChan:     make(chan clustermessage.ClusterMessage, 10),
+		EdgeToClusterChan:     make(chan clustermessage.ClusterMessage, 10),
+	}
+	edge := NewEdgeHandlerWithShim(conf, clustershim.NewlocalShimClientWithHandler(clustershim.ShimHandler{
+		otev1.ClusterControllerDestApply: applied,
+	}), nil)
+	defer edge.Stop()
+
+	// the parent unreachable does not fail starting, and the desired state is replayed.
+	assert.Nil(t, edge.Start())
+	select {
+	case task := <-applied.tasks:
+		assert.Equal(t, "/api/v1/namespaces/default/configmaps", task.URI)
+	case <-time.After(5 * time.Second):
+		t.Errorf("desired state is not replayed")
+	}
+}
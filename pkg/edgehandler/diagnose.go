@@ -0,0 +1,155 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgehandler
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/baidu/ote-stack/pkg/bandwidth"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+	"github.com/baidu/ote-stack/pkg/config"
+	"github.com/baidu/ote-stack/pkg/errorkind"
+	"github.com/baidu/ote-stack/pkg/watchdog"
+)
+
+var (
+	// maxDiagnoseLogSize is the max size of each log file in the support bundle,
+	// the tail of the log file is collected.
+	maxDiagnoseLogSize int64 = 1 << 20
+	logSeverities            = []string{"INFO", "WARNING", "ERROR"}
+)
+
+type queueStats struct {
+	Len int `json:"len"`
+	Cap int `json:"cap"`
+}
+
+// diagnoseCollectors returns the collectors of the support bundle of clustercontroller.
+func (e *edgeHandler) diagnoseCollectors() map[string]handler.DiagnoseCollector {
+	collectors := map[string]handler.DiagnoseCollector{
+		"version":        collectVersion,
+		"config.json":    e.collectConfig,
+		"route.json":     e.route().Serialize,
+		"queue.json":     e.collectQueueStats,
+		"memory.json":    collectMemoryStats,
+		"bandwidth.json": collectBandwidthStats,
+	}
+	files := logFiles()
+	if len(files) == 0 {
+		collectors["logs"] = func() ([]byte, error) {
+			return nil, errorkind.Errorf(ErrInvalidConfig, "neither log_file nor log_dir is set, logs are written to stderr")
+		}
+	}
+	for name, path := range files {
+		path := path
+		collectors["logs/"+name] = func() ([]byte, error) {
+			return tailFile(path, maxDiagnoseLogSize)
+		}
+	}
+	return collectors
+}
+
+func collectVersion() ([]byte, error) {
+	return []byte(fmt.Sprintf("clustercontroller: %s\ngo: %s\nplatform: %s/%s\n",
+		config.Version, runtime.Version(), runtime.GOOS, runtime.GOARCH)), nil
+}
+
+func (e *edgeHandler) collectConfig() ([]byte, error) {
+	return json.MarshalIndent(map[string]string{
+		"clusterName":           e.conf.ClusterName,
+		"clusterUserDefineName": e.conf.ClusterUserDefineName,
+		"parentCluster":         strings.Join(e.conf.ParentCluster, ","),
+		"tunnelListenAddr":      e.conf.TunnelListenAddr,
+		"remoteShimAddr":        e.conf.RemoteShimAddr,
+		"helmTillerAddr":        e.conf.HelmTillerAddr,
+	}, "", "  ")
+}
+
+func (e *edgeHandler) collectQueueStats() ([]byte, error) {
+	running, pending := e.workers.stats()
+	return json.MarshalIndent(map[string]interface{}{
+		"edgeToCluster": queueStats{len(e.conf.EdgeToClusterChan), cap(e.conf.EdgeToClusterChan)},
+		"clusterToEdge": queueStats{len(e.conf.ClusterToEdgeChan), cap(e.conf.ClusterToEdgeChan)},
+		"goroutines":    runtime.NumGoroutine(),
+		"runningTasks":  running,
+		"pendingTasks":  pending,
+		"awaitingShim":  e.pending.len(),
+	}, "", "  ")
+}
+
+func collectMemoryStats() ([]byte, error) {
+	return json.MarshalIndent(watchdog.GetStats(), "", "  ")
+}
+
+func collectBandwidthStats() ([]byte, error) {
+	return json.MarshalIndent(bandwidth.GetStats(), "", "  ")
+}
+
+// logFiles returns the klog files of clustercontroller by file name.
+func logFiles() map[string]string {
+	ret := map[string]string{}
+	if f := flag.Lookup("log_file"); f != nil && f.Value.String() != "" {
+		ret[filepath.Base(f.Value.String())] = f.Value.String()
+		return ret
+	}
+	if f := flag.Lookup("log_dir"); f != nil && f.Value.String() != "" {
+		// klog links <program>.<severity> to the latest log file.
+		program := filepath.Base(os.Args[0])
+		for _, severity := range logSeverities {
+			name := program + "." + severity
+			path := filepath.Join(f.Value.String(), name)
+			if _, err := os.Stat(path); err == nil {
+				ret[name] = path
+			}
+		}
+	}
+	return ret
+}
+
+// tailFile reads at most size bytes from the end of file.
+func tailFile(path string, size int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > size {
+		if _, err := f.Seek(-size, io.SeekEnd); err != nil {
+			return nil, err
+		}
+	} else {
+		size = info.Size()
+	}
+	buf := make([]byte, size)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return buf[:n], nil
+}
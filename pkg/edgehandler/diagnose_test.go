@@ -0,0 +1,108 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgehandler
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/config"
+)
+
+func TestDiagnose(t *testing.T) {
+	f := &fakeEdgeTunnel{
+		fakeEdgeTunnelSendChan: make(chan struct{}, 1),
+	}
+	edge := &edgeHandler{
+		conf: &config.ClusterControllerConfig{
+			ClusterName:       "child",
+			ParentCluster:     []string{"127.0.0.1:8287"},
+			EdgeToClusterChan: make(chan clustermessage.ClusterMessage, 10),
+		},
+		edgeTunnel: f,
+		shimClient: newFakeShim(),
+	}
+
+	task, err := proto.Marshal(&clustermessage.ControllerTask{
+		Destination: otev1.ClusterControllerDestDiagnose,
+		Method:      http.MethodGet,
+	})
+	assert.Nil(t, err)
+	err = edge.handleMessage(context.Background(), &clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{
+			MessageID: "diagnose",
+			Command:   clustermessage.CommandType_ControlReq,
+		},
+		Body: task,
+	})
+	assert.Nil(t, err)
+	<-f.fakeEdgeTunnelSendChan
+
+	assert.Equal(t, "child", LastSend.Head.ClusterName)
+	resp := &clustermessage.ControllerTaskResponse{}
+	assert.Nil(t, proto.Unmarshal(LastSend.Body, resp))
+	assert.Equal(t, int32(http.StatusOK), resp.StatusCode)
+
+	raw, err := base64.StdEncoding.DecodeString(string(resp.Body))
+	assert.Nil(t, err)
+	gr, err := gzip.NewReader(bytes.NewReader(raw))
+	assert.Nil(t, err)
+	tr := tar.NewReader(gr)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		names = append(names, hdr.Name)
+	}
+	assert.Equal(t, []string{"bandwidth.json", "config.json", "logs.error", "memory.json", "queue.json", "route.json", "version"}, names)
+}
+
+func TestTailFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnose")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "clustercontroller.INFO")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("0123456789"), 0644))
+
+	data, err := tailFile(path, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, "6789", string(data))
+
+	data, err = tailFile(path, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, "0123456789", string(data))
+
+	_, err = tailFile(filepath.Join(dir, "none"), 4)
+	assert.NotNil(t, err)
+}
@@ -19,56 +19,158 @@ limitations under the License.
 package edgehandler
 
 import (
-	"fmt"
+	"context"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/protobuf/proto"
 	"k8s.io/klog"
 
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/archive"
+	"github.com/baidu/ote-stack/pkg/audit"
+	"github.com/baidu/ote-stack/pkg/bandwidth"
+	"github.com/baidu/ote-stack/pkg/capability"
 	"github.com/baidu/ote-stack/pkg/clustermessage"
 	clusterrouter "github.com/baidu/ote-stack/pkg/clusterrouter"
 	"github.com/baidu/ote-stack/pkg/clusterselector"
 	"github.com/baidu/ote-stack/pkg/clustershim"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
 	"github.com/baidu/ote-stack/pkg/config"
+	"github.com/baidu/ote-stack/pkg/deadletter"
+	"github.com/baidu/ote-stack/pkg/desiredstate"
+	"github.com/baidu/ote-stack/pkg/errorkind"
+	"github.com/baidu/ote-stack/pkg/objectref"
+	"github.com/baidu/ote-stack/pkg/outbox"
+	"github.com/baidu/ote-stack/pkg/reporter"
+	"github.com/baidu/ote-stack/pkg/rungroup"
 	"github.com/baidu/ote-stack/pkg/tunnel"
+	"github.com/baidu/ote-stack/pkg/watchdog"
 )
 
 var (
 	subtreeReportDuration = 1 * time.Second
+	// forwardRetryInterval is the interval to forward the outbox again if failed while connected.
+	forwardRetryInterval = 1 * time.Second
+
+	// handledCommands are the commands from the parent handled by handleMessage, besides the ones registered.
+	handledCommands = []clustermessage.CommandType{
+		clustermessage.CommandType_ControlReq,
+		clustermessage.CommandType_ControlMultiReq,
+		clustermessage.CommandType_Redirect,
+	}
 )
 
 // EdgeHandler is edgehandler interface that process messages from tunnel and transmit to shim.
 type EdgeHandler interface {
 	// Start will start edgehandler.
 	Start() error
+	// Stop cancels messages being handled, e.g., calls to apiserver by the shim,
+	// and stops goroutines of edgehandler.
+	Stop()
+	// Wait waits for goroutines of edgehandler to return once stopped, or one of them failed,
+	// and returns the error of the one failed.
+	Wait() error
 }
 
 // edgeHandler processes message from tunnel and transmit to shim.
 type edgeHandler struct {
-	conf              *config.ClusterControllerConfig
-	edgeTunnel        tunnel.EdgeTunnel
-	shimClient        clustershim.ShimServiceClient
-	stopReportSubtree chan struct{}
+	conf       *config.ClusterControllerConfig
+	edgeTunnel tunnel.EdgeTunnel
+	// shadowTunnel is the tunnel to the shadow parent, nil if no shadow parent.
+	shadowTunnel tunnel.EdgeTunnel
+	shimClient   clustershim.ShimServiceClient
+	// idempotency keeps responses of tasks with idempotency keys
+	idempotency *idempotencyCache
+	// dedup remembers messages from the parent to drop duplicates
+	dedup *dedupCache
+	// connected is 1 if connected to the parent.
+	connected int32
+	// outboxLock guards queuing messages to the outbox against finishing forwarding it.
+	outboxLock sync.Mutex
+	// forwarding is true while the outbox is forwarded to the parent,
+	// during which messages to queue are queued after the ones forwarded instead of sent.
+	forwarding bool
+	// group runs goroutines of edgehandler, whose context is the parent of messages handled,
+	// stopped by Stop.
+	group *rungroup.Group
+	// reporting runs the subtree report while connected to the parent.
+	reporting     *rungroup.Group
+	reportingLock sync.Mutex
+	// router is the route of subtree and neighbor, the default router if nil.
+	router *clusterrouter.ClusterRouter
+	// workers run tasks from the parent concurrently, tasks are run one by one if nil.
+	workers *workerPool
+	// pending are the tasks waiting for asynchronous responses of the shim to time out.
+	pending *pendingTasks
 }
 
 // NewEdgeHandler returns a edgeHandler object.
 func NewEdgeHandler(c *config.ClusterControllerConfig) EdgeHandler {
+	return NewEdgeHandlerWithShim(c, nil, clusterrouter.Router())
+}
+
+// NewEdgeHandlerWithShim returns a edgeHandler object, which does tasks by shim instead of
+// the one configured if shim is not nil, and reports the subtree in router instead of the default one.
+func NewEdgeHandlerWithShim(c *config.ClusterControllerConfig,
+	shim clustershim.ShimServiceClient, router *clusterrouter.ClusterRouter) EdgeHandler {
 	return &edgeHandler{
-		conf:              c,
-		stopReportSubtree: make(chan struct{}, 1),
+		conf:        c,
+		shimClient:  shim,
+		idempotency: newIdempotencyCache(),
+		dedup:       newDedupCache(),
+		group:       rungroup.New(context.Background()),
+		router:      router,
+		workers:     newWorkerPool(c.ShimWorkers),
+		pending:     newPendingTasks(),
+	}
+}
+
+func (e *edgeHandler) Stop() {
+	if e.group != nil {
+		e.group.Stop()
+	}
+}
+
+func (e *edgeHandler) Wait() error {
+	if e.group == nil {
+		return nil
 	}
+	return e.group.Wait()
+}
+
+// route returns the router of edgehandler.
+func (e *edgeHandler) route() *clusterrouter.ClusterRouter {
+	if e.router == nil {
+		return clusterrouter.Router()
+	}
+	return e.router
+}
+
+// context returns the context of the goroutines of edgehandler, done once stopped.
+func (e *edgeHandler) context() context.Context {
+	if e.group == nil {
+		return context.Background()
+	}
+	return e.group.Context()
+}
+
+// messageContext returns the context to handle msg, carrying its message id as the trace id.
+func (e *edgeHandler) messageContext(msg *clustermessage.ClusterMessage) context.Context {
+	return handler.WithTraceID(e.context(), msg.Head.GetMessageID())
 }
 
 func (e *edgeHandler) valid() error {
 	if e.conf.ClusterUserDefineName == "" {
-		return fmt.Errorf("cluster name is empty")
+		return errorkind.Errorf(ErrInvalidConfig, "cluster name is empty")
 	}
-	if e.conf.K8sClient == nil && !e.isRemoteShim() {
-		return fmt.Errorf("k8s client is unavailable or remoteshim not set")
+	if e.conf.K8sClient == nil && !e.isRemoteShim() && e.shimClient == nil {
+		return errorkind.Errorf(ErrInvalidConfig, "k8s client is unavailable or remoteshim not set")
 	}
-	if e.conf.ParentCluster == "" {
-		return fmt.Errorf("parent cluster is empty")
+	if len(e.conf.ParentCluster) == 0 {
+		return errorkind.Errorf(ErrInvalidConfig, "parent cluster is empty")
 	}
 	return nil
 }
@@ -90,8 +192,13 @@ func (e *edgeHandler) Start() error {
 	if err := e.valid(); err != nil {
 		return err
 	}
+	if err := tunnel.SetProxy(e.conf.TunnelProxy); err != nil {
+		return err
+	}
 
-	if e.isRemoteShim() {
+	if e.shimClient != nil {
+		klog.Infof("use shim client given")
+	} else if e.isRemoteShim() {
 		klog.Infof("init remote shim client")
 		e.shimClient = clustershim.NewRemoteShimClient(e.conf.ClusterName, e.conf.RemoteShimAddr)
 	} else {
@@ -100,51 +207,196 @@ func (e *edgeHandler) Start() error {
 	}
 
 	if e.shimClient == nil {
-		return fmt.Errorf("fail to init shim client")
+		return errorkind.Errorf(ErrShimUnavailable, "fail to init shim client")
 	}
+	capability.Set(commands(), e.shimClient.Destinations())
 
-	go e.handleRespFromShimClient()
-	e.edgeTunnel = tunnel.NewEdgeTunnel(e.conf)
+	e.group.Go("shim response handler", e.handleRespFromShimClient)
+	e.edgeTunnel = tunnel.NewEdgeTunnelWithRouter(e.conf, e.route())
 	e.edgeTunnel.RegistReceiveMessageHandler(e.receiveMessageFromTunnel)
+	deadletter.RegistRequeueFunc(deadletter.SourceParent, e.requeueMessage)
 	e.edgeTunnel.RegistAfterConnectToHook(e.afterConnect)
 	e.edgeTunnel.RegistAfterDisconnectHook(e.afterDisconnect)
-	if err := e.edgeTunnel.Start(); err != nil {
+	e.edgeTunnel.RegistMaxRetriesHook(e.afterMaxRetries)
+	e.group.Go("desired state replay", func(ctx context.Context) error {
+		e.replayDesiredState(ctx, desiredstate.ReplayAfter())
+		return nil
+	})
+	if len(desiredstate.Objects()) != 0 {
+		// the desired state is replayed while the parent is unreachable.
+		e.edgeTunnel.StartConnecting()
+	} else if err := e.edgeTunnel.Start(); err != nil {
 		return err
 	}
 
-	go e.sendMessageToTunnel()
+	if e.conf.ShadowParent != "" {
+		klog.Infof("connect to shadow parent %s", e.conf.ShadowParent)
+		e.shadowTunnel = tunnel.NewShadowEdgeTunnel(e.conf, e.conf.ShadowParent)
+		e.shadowTunnel.RegistReceiveMessageHandler(e.receiveMessageFromShadow)
+		e.shadowTunnel.Start()
+	}
+
+	e.group.Go("parent sender", e.sendMessageToTunnel)
 	return nil
 }
 
-func (e *edgeHandler) sendMessageToTunnel() {
+func (e *edgeHandler) sendMessageToTunnel(ctx context.Context) error {
 	for {
-		msg := <-e.conf.ClusterToEdgeChan
+		var msg clustermessage.ClusterMessage
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg = <-e.conf.ClusterToEdgeChan:
+		}
+		if watchdog.ShouldShed(&msg) {
+			klog.V(3).Infof("shed message %s from %s under memory pressure", msg.Head.MessageID, msg.Head.ClusterName)
+			audit.RecordMessage(bandwidth.ParentPeer, audit.Sent, &msg, audit.Dropped, "shed under memory pressure")
+			continue
+		}
+		e.sendToShadow(&msg)
+		transcodeToParent(&msg)
 		data, err := proto.Marshal(&msg)
 		if err != nil {
+			audit.RecordMessage(bandwidth.ParentPeer, audit.Sent, &msg, audit.Error, err.Error())
 			continue
 		}
-		go e.edgeTunnel.Send(data)
+		bandwidth.RecordMessage(bandwidth.ParentPeer, bandwidth.Sent, &msg, len(data))
+		archive.RecordMessage(bandwidth.ParentPeer, archive.Sent, &msg)
+		audit.RecordMessage(bandwidth.ParentPeer, audit.Sent, &msg, audit.Forwarded, "")
+		e.send(msg.Head.GetCommand(), data)
 	}
 }
 
 func (e *edgeHandler) receiveMessageFromTunnel(client string, data []byte) (ret error) {
 	ret = nil
+	defer deadletter.Recover(deadletter.SourceParent, client, data, &ret)
 	msg := &clustermessage.ClusterMessage{}
 	err := proto.Unmarshal(data, msg)
 	if err != nil {
-		ret = fmt.Errorf("can not deserialize message, error: %s", err.Error())
+		ret = errorkind.Errorf(ErrInvalidMessage, "can not deserialize message, error: %s", err.Error())
 		klog.Error(ret)
+		deadletter.Record(deadletter.SourceParent, client, deadletter.ReasonUndecodable, data, ret)
+		audit.RecordMessage(bandwidth.ParentPeer, audit.Received, nil, audit.Error, ret.Error())
+		return
+	}
+	bandwidth.RecordMessage(bandwidth.ParentPeer, bandwidth.Received, msg, len(data))
+	archive.RecordMessage(bandwidth.ParentPeer, archive.Received, msg)
+	if e.dedup.seen(msg, time.Now()) {
+		klog.V(3).Infof("drop duplicated message %s of command %s from parent",
+			msg.Head.MessageID, msg.Head.Command.String())
+		audit.RecordMessage(bandwidth.ParentPeer, audit.Received, msg, audit.Dropped, "duplicated")
+		return
+	}
+
+	// msg is changed to the response once handled if this cluster is selected,
+	// so a copy is sent to subtree.
+	e.conf.EdgeToClusterChan <- *proto.Clone(msg).(*clustermessage.ClusterMessage)
+	if audit.Enabled() && e.selectsSubtree(msg) {
+		audit.RecordMessage(bandwidth.ParentPeer, audit.Received, msg, audit.Forwarded, "")
+	}
+
+	e.handleSelected(client, msg, data)
+	return
+}
+
+/*
+handleSelected handles msg serialized in data from client if this cluster is selected,
+which is retried and kept as a dead letter if failed.
+Tasks are handled by the worker pool if any, in the order of their ordering key.
+*/
+func (e *edgeHandler) handleSelected(client string, msg *clustermessage.ClusterMessage, data []byte) {
+	selector := clusterselector.NewSelector(msg.Head.ClusterSelector)
+	if !selector.Has(e.conf.ClusterName) {
 		return
 	}
+	key := orderingKey(msg)
+	if e.workers == nil || key == "" {
+		// messages without a task, e.g., redirect, are handled at once.
+		e.handleWithRetry(client, msg, data)
+		return
+	}
+	e.workers.submit(key, func() {
+		defer deadletter.Recover(deadletter.SourceParent, client, data, nil)
+		e.handleWithRetry(client, msg, data)
+	})
+}
+
+func (e *edgeHandler) handleWithRetry(client string, msg *clustermessage.ClusterMessage, data []byte) {
+	if err := e.handleTimed(msg); err != nil {
+		deadletter.Retry(deadletter.SourceParent, client, data, err, func() error {
+			return e.handleTimed(msg)
+		})
+	}
+}
+
+// handleTimed handles msg in the shim timeout, if any.
+func (e *edgeHandler) handleTimed(msg *clustermessage.ClusterMessage) error {
+	ctx := e.messageContext(msg)
+	if e.conf.ShimTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.conf.ShimTimeout)
+		defer cancel()
+	}
+	if !audit.Enabled() {
+		return e.handleMessage(ctx, msg)
+	}
+	// msg is changed to the response once handled, so its head is audited as received.
+	received := &clustermessage.ClusterMessage{Head: proto.Clone(msg.Head).(*clustermessage.MessageHead)}
+	err := e.handleMessage(ctx, msg)
+	auditHandled(received, err)
+	return err
+}
 
-	e.conf.EdgeToClusterChan <- *msg
+// auditHandled records msg received from the parent as handled, dropped if not supported, or err if failed.
+func auditHandled(msg *clustermessage.ClusterMessage, err error) {
+	switch {
+	case err != nil:
+		audit.RecordMessage(bandwidth.ParentPeer, audit.Received, msg, audit.Error, err.Error())
+	case !supported(msg.Head.Command):
+		audit.RecordMessage(bandwidth.ParentPeer, audit.Received, msg, audit.Dropped, "command not supported")
+	default:
+		audit.RecordMessage(bandwidth.ParentPeer, audit.Received, msg, audit.Handled, "")
+	}
+}
 
+// selectsSubtree returns if msg selects any cluster in the subtree other than this one.
+func (e *edgeHandler) selectsSubtree(msg *clustermessage.ClusterMessage) bool {
 	selector := clusterselector.NewSelector(msg.Head.ClusterSelector)
-	if selector.Has(e.conf.ClusterName) {
-		e.handleMessage(msg)
+	for _, name := range e.route().SubTreeClusters() {
+		if name != e.conf.ClusterName && selector.Has(name) {
+			return true
+		}
 	}
+	return false
+}
 
-	return
+// requeueMessage handles a dead letter from the parent again, which is not sent to subtree again.
+func (e *edgeHandler) requeueMessage(client string, data []byte) (err error) {
+	defer deadletter.Recover(deadletter.SourceParent, client, data, &err)
+	msg := &clustermessage.ClusterMessage{}
+	if err := proto.Unmarshal(data, msg); err != nil {
+		err = errorkind.Errorf(ErrInvalidMessage, "can not deserialize message, error: %s", err.Error())
+		deadletter.Record(deadletter.SourceParent, client, deadletter.ReasonUndecodable, data, err)
+		return err
+	}
+	if msg.Head == nil {
+		return errorkind.Errorf(ErrInvalidMessage, "message head is nil")
+	}
+	e.handleSelected(client, msg, data)
+	return nil
+}
+
+// receiveMessageFromShadow ignores messages from the shadow parent, which do not reach the cluster.
+func (e *edgeHandler) receiveMessageFromShadow(client string, data []byte) error {
+	msg := &clustermessage.ClusterMessage{}
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return errorkind.Errorf(ErrInvalidMessage, "can not deserialize message, error: %s", err.Error())
+	}
+	bandwidth.RecordMessage(bandwidth.ShadowParentPeer, bandwidth.Received, msg, len(data))
+	klog.V(3).Infof("ignore message %s of command %s from shadow parent",
+		msg.Head.GetMessageID(), msg.Head.GetCommand().String())
+	audit.RecordMessage(bandwidth.ShadowParentPeer, audit.Received, msg, audit.Dropped, "from shadow parent")
+	return nil
 }
 
 func responseErrorStatus(err error) []byte {
@@ -161,16 +413,32 @@ func responseErrorStatus(err error) []byte {
 	return data
 }
 
-func (e *edgeHandler) handleMessage(msg *clustermessage.ClusterMessage) error {
+func (e *edgeHandler) handleMessage(ctx context.Context, msg *clustermessage.ClusterMessage) error {
+	if h := commandHandler(msg.Head.Command); h != nil {
+		return e.handleByRegistered(ctx, h, msg)
+	}
 	switch msg.Head.Command {
 	case clustermessage.CommandType_ControlReq:
+		key := handler.GetControllerTaskFromClusterMessage(msg).GetIdempotencyKey()
+		if body := e.idempotency.get(key, time.Now()); body != nil {
+			klog.V(1).Infof("message %v of idempotency key %s is done, respond it again", msg.Head.MessageID, key)
+			head := proto.Clone(msg.Head).(*clustermessage.MessageHead)
+			head.Command = clustermessage.CommandType_ControlResp
+			head.ClusterName = e.conf.ClusterName
+			return e.sendToParent(handler.Response(body, head))
+		}
 		klog.V(1).Infof("dispatch message %v to shim", msg.Head.MessageID)
-		resp, err := e.shimClient.Do(msg)
+		resp, err := e.doWithDeadline(ctx, msg)
 		if resp != nil {
 			// sync return
 			if err != nil {
-				resp.Body = responseErrorStatus(err)
+				// responses of tasks timed out or canceled tell so by their status codes.
+				if kind := errorkind.Of(err); kind != handler.ErrTimedOut && kind != handler.ErrCanceled {
+					resp.Body = responseErrorStatus(err)
+				}
 				klog.Errorf("handleTask error: %s", err.Error())
+			} else if key != "" {
+				e.idempotency.record(key, resp.Body, time.Now())
 			}
 
 			resp.Head.ClusterName = e.conf.ClusterName
@@ -184,84 +452,269 @@ func (e *edgeHandler) handleMessage(msg *clustermessage.ClusterMessage) error {
 		return err
 	case clustermessage.CommandType_ControlMultiReq:
 		klog.V(3).Infof("dispatch ControlMultiReq message to shim")
-		_, err := e.shimClient.Do(msg)
+		resp, err := e.shimClient.Do(ctx, msg)
 		if err != nil {
 			klog.Errorf("handleTask error: %s", err.Error())
 		}
+		if resp == nil {
+			// async return, or failed before any body is done.
+			return err
+		}
+		// responses tell status codes of bodies, failed or not.
+		resp.Head.ClusterName = e.conf.ClusterName
+		if sendErr := e.sendToParent(resp); err == nil {
+			err = sendErr
+		}
 		return err
+	case clustermessage.CommandType_Redirect:
+		task := &clustermessage.RedirectTask{}
+		if err := proto.Unmarshal(msg.Body, task); err != nil {
+			klog.Errorf("unmarshal redirect task failed: %v", err)
+			return err
+		}
+		klog.Infof("redirected to %s by message %v", task.Address, msg.Head.MessageID)
+		return e.edgeTunnel.Redirect(task.Address)
 	default:
 		klog.Errorf("command %s is not supported by edge handler", msg.Head.Command.String())
 		return nil
 	}
 }
 
-func (e *edgeHandler) handleRespFromShimClient() {
+/*
+doControlRequest dispatches control request to shim,
+except diagnose and capture requests, which are about clustercontroller itself
+and handled locally even if the shim is remote.
+Body of the task referencing an object is replaced by the object before dispatched.
+*/
+func (e *edgeHandler) doControlRequest(ctx context.Context,
+	msg *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	task := handler.GetControllerTaskFromClusterMessage(msg)
+	if task != nil && task.Destination == otev1.ClusterControllerDestDiagnose {
+		return handler.NewDiagnoseHandler(e.diagnoseCollectors()).Do(msg)
+	}
+	if task != nil && task.Destination == otev1.ClusterControllerDestCapture {
+		return handler.NewCaptureHandler(archive.SetCapture).Do(msg)
+	}
+	if task != nil && objectref.IsRef(task.Body) {
+		resolved, err := resolveObjectRef(msg, task)
+		if err != nil {
+			head := proto.Clone(msg.Head).(*clustermessage.MessageHead)
+			head.Command = clustermessage.CommandType_ControlResp
+			return handler.Response(nil, head), err
+		}
+		msg = resolved
+		task = handler.GetControllerTaskFromClusterMessage(msg)
+	}
+	desiredstate.Record(msg.Head.MessageID, task)
+	return e.shimClient.Do(ctx, msg)
+}
+
+// resolveObjectRef returns msg with the body of task replaced by the object it references.
+func resolveObjectRef(msg *clustermessage.ClusterMessage,
+	task *clustermessage.ControllerTask) (*clustermessage.ClusterMessage, error) {
+	body, err := objectref.Resolve(task.Body)
+	if err != nil {
+		return nil, errorkind.Errorf(ErrUnresolvedReference,
+			"resolve object reference of task %s failed: %v", msg.Head.MessageID, err)
+	}
+	resolved := proto.Clone(task).(*clustermessage.ControllerTask)
+	resolved.Body = body
+	return resolved.ToClusterMessage(msg.Head)
+}
+
+func (e *edgeHandler) handleRespFromShimClient(ctx context.Context) error {
 	// async return
 	if e.shimClient == nil || e.shimClient.ReturnChan() == nil {
 		klog.Warningf("shim client or return chan is nil, cannot handle resp")
-		return
+		return nil
 	}
 	respChan := e.shimClient.ReturnChan()
-	if respChan == nil {
-		klog.Warningf("async return channel from shim client is nil")
-		return
-	}
-
 	for {
-		resp := <-respChan
-
+		var resp *clustermessage.ClusterMessage
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case resp, ok = <-respChan:
+		}
+		if !ok {
+			klog.Warningf("async return channel from shim client closed")
+			return nil
+		}
+		if isReplayResponse(resp) {
+			continue
+		}
+		if resp.Head.Command == clustermessage.CommandType_ControlResp && !e.pending.done(resp.Head.MessageID) {
+			klog.Warningf("drop response of task %s from shim after timeout", resp.Head.MessageID)
+			audit.RecordMessage(bandwidth.ParentPeer, audit.Sent, resp, audit.Dropped, "response after timeout")
+			continue
+		}
 		resp.Head.ClusterName = e.conf.ClusterName
 		// send to cloudtunnel.
 		e.sendToParent(resp)
 	}
-	klog.Warningf("async return channel from shim client closed")
 }
 
 func (e *edgeHandler) afterConnect() {
-	// start subtree report goroutine
-	go e.reportSubTreeTimer()
+	e.outboxLock.Lock()
+	atomic.StoreInt32(&e.connected, 1)
+	e.startForwarding()
+	e.outboxLock.Unlock()
+	// start subtree report until disconnected.
+	e.reportingLock.Lock()
+	defer e.reportingLock.Unlock()
+	if e.reporting != nil {
+		e.reporting.Stop()
+	}
+	klog.Info("start reporting subtree")
+	e.reporting = rungroup.New(e.context())
+	e.reporting.Every("subtree report", subtreeReportDuration, e.reportSubTree)
 }
 
 func (e *edgeHandler) afterDisconnect() {
-	// stop subtree report goroutine
-	e.stopReportSubtree <- struct{}{}
+	atomic.StoreInt32(&e.connected, 0)
+	e.reportingLock.Lock()
+	defer e.reportingLock.Unlock()
+	if e.reporting != nil {
+		klog.Info("stop reporting subtree")
+		e.reporting.Stop()
+		e.reporting = nil
+	}
 }
 
-func (e *edgeHandler) reportSubTreeTimer() {
-	klog.Info("start reporting subtree")
-
-	// call report once and start timer
-	e.reportSubTree()
-
-	ticker := time.NewTicker(subtreeReportDuration)
-	for {
-		select {
-		case <-e.stopReportSubtree:
-			klog.Info("stop reporting subtree")
-			return
-		case <-ticker.C:
-			e.reportSubTree()
-		}
-	}
+func (e *edgeHandler) afterMaxRetries(retries int) {
+	klog.Errorf("cluster %s cannot connect to a parent after %d retries, go back to parent %s",
+		e.conf.ClusterName, retries, e.conf.ParentCluster[0])
 }
 
-func (e *edgeHandler) reportSubTree() {
-	msg := clusterrouter.Router().SubTreeMessage()
+func (e *edgeHandler) reportSubTree(ctx context.Context) error {
+	msg := e.route().SubTreeMessage()
 	if msg == nil {
-		return
+		return nil
 	}
 	msg.Head.ClusterName = e.conf.ClusterName
 	e.sendToParent(msg)
+	return nil
 }
 
 func (e *edgeHandler) sendToParent(msg *clustermessage.ClusterMessage) error {
+	if watchdog.ShouldShed(msg) {
+		klog.V(3).Infof("shed message %s under memory pressure", msg.Head.MessageID)
+		audit.RecordMessage(bandwidth.ParentPeer, audit.Sent, msg, audit.Dropped, "shed under memory pressure")
+		return nil
+	}
+	e.sendToShadow(msg)
+	transcodeToParent(msg)
 	data, err := proto.Marshal(msg)
 	if err != nil {
 		klog.Errorf("marshal cluster message error: %s", err.Error())
+		audit.RecordMessage(bandwidth.ParentPeer, audit.Sent, msg, audit.Error, err.Error())
 		return err
 	}
+	bandwidth.RecordMessage(bandwidth.ParentPeer, bandwidth.Sent, msg, len(data))
+	archive.RecordMessage(bandwidth.ParentPeer, archive.Sent, msg)
+	audit.RecordMessage(bandwidth.ParentPeer, audit.Sent, msg, audit.Handled, "")
 
-	go e.edgeTunnel.Send(data)
+	e.send(msg.Head.GetCommand(), data)
 
 	return nil
 }
+
+/*
+send sends data of a message of command to the parent.
+Messages of commands queued are queued in the outbox while disconnected or the outbox is forwarded,
+so that they are not sent before the ones queued, and queued if failed to send.
+*/
+func (e *edgeHandler) send(command clustermessage.CommandType, data []byte) {
+	if !outbox.Queued(command) {
+		go e.edgeTunnel.Send(data)
+		return
+	}
+	e.outboxLock.Lock()
+	defer e.outboxLock.Unlock()
+	if atomic.LoadInt32(&e.connected) == 0 || e.forwarding {
+		outbox.Put(data)
+		return
+	}
+	go func() {
+		if err := e.edgeTunnel.Send(data); err != nil {
+			e.outboxLock.Lock()
+			defer e.outboxLock.Unlock()
+			outbox.Put(data)
+			e.startForwarding()
+		}
+	}()
+}
+
+// startForwarding forwards the outbox if connected and not being forwarded, with outboxLock held.
+func (e *edgeHandler) startForwarding() {
+	if atomic.LoadInt32(&e.connected) == 0 || e.forwarding {
+		return
+	}
+	e.forwarding = true
+	go e.forwardOutbox()
+}
+
+// forwardOutbox forwards the outbox until it is drained or disconnected, and again in
+// forwardRetryInterval if failed while connected.
+func (e *edgeHandler) forwardOutbox() {
+	for !e.forwarded() {
+		select {
+		case <-e.context().Done():
+			e.outboxLock.Lock()
+			e.forwarding = false
+			e.outboxLock.Unlock()
+			return
+		case <-time.After(forwardRetryInterval):
+		}
+	}
+}
+
+// forwarded forwards the outbox, and stops forwarding and returns true if it is drained or disconnected,
+// after which messages are sent directly once connected.
+func (e *edgeHandler) forwarded() bool {
+	outbox.Forward(e.edgeTunnel.Send)
+	e.outboxLock.Lock()
+	defer e.outboxLock.Unlock()
+	if atomic.LoadInt32(&e.connected) == 1 && outbox.Len() != 0 {
+		return false
+	}
+	e.forwarding = false
+	return true
+}
+
+/*
+sendToShadow sends a copy of reports and routes in msg to the shadow parent if any,
+reports are transcoded to json. Responses are not sent, since tasks of the shadow parent
+are ignored, and ones of the parent are unknown to it.
+*/
+func (e *edgeHandler) sendToShadow(msg *clustermessage.ClusterMessage) {
+	if e.shadowTunnel == nil || msg.Head == nil {
+		return
+	}
+	switch msg.Head.Command {
+	case clustermessage.CommandType_EdgeReport, clustermessage.CommandType_SubTreeRoute,
+		clustermessage.CommandType_ClusterRegist, clustermessage.CommandType_ClusterUnregist:
+	default:
+		return
+	}
+	shadow := proto.Clone(msg).(*clustermessage.ClusterMessage)
+	if _, err := reporter.Transcode(shadow, reporter.EncodingJSON); err != nil {
+		klog.Errorf("transcode reports from %s to shadow parent failed: %v", msg.Head.ClusterName, err)
+	}
+	data, err := proto.Marshal(shadow)
+	if err != nil {
+		klog.Errorf("marshal cluster message to shadow parent error: %s", err.Error())
+		return
+	}
+	bandwidth.RecordMessage(bandwidth.ShadowParentPeer, bandwidth.Sent, shadow, len(data))
+	go e.shadowTunnel.Send(data)
+}
+
+// transcodeToParent encodes edge reports by the encoding negotiated with parent,
+// msg is sent as it is if failed.
+func transcodeToParent(msg *clustermessage.ClusterMessage) {
+	if _, err := reporter.Transcode(msg, reporter.UpstreamEncoding()); err != nil {
+		klog.Errorf("transcode reports from %s failed: %v", msg.Head.ClusterName, err)
+	}
+}
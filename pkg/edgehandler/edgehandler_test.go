@@ -17,7 +17,14 @@ limitations under the License.
 package edgehandler
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -26,11 +33,17 @@ import (
 	"k8s.io/klog"
 
 	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/audit"
 	"github.com/baidu/ote-stack/pkg/clustermessage"
 	"github.com/baidu/ote-stack/pkg/clusterrouter"
 	"github.com/baidu/ote-stack/pkg/clustershim"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
 	"github.com/baidu/ote-stack/pkg/config"
+	"github.com/baidu/ote-stack/pkg/deadletter"
+	"github.com/baidu/ote-stack/pkg/errorkind"
 	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned"
+	"github.com/baidu/ote-stack/pkg/objectref"
+	"github.com/baidu/ote-stack/pkg/outbox"
 	"github.com/baidu/ote-stack/pkg/tunnel"
 )
 
@@ -42,6 +55,7 @@ var (
 
 type fakeEdgeTunnel struct {
 	fakeEdgeTunnelSendChan chan struct{}
+	redirectAddr           string
 }
 
 type fakeShimHandler struct {
@@ -73,14 +87,30 @@ func (f *fakeEdgeTunnel) RegistAfterDisconnectHook(tunnel.AfterDisconnectHook) {
 	return
 }
 
+func (f *fakeEdgeTunnel) RegistMaxRetriesHook(tunnel.MaxRetriesHook) {
+	return
+}
+
+func (f *fakeEdgeTunnel) Redirect(addr string) error {
+	f.redirectAddr = addr
+	return nil
+}
+
 func (f *fakeEdgeTunnel) Start() error {
 	return nil
 }
 
+func (f *fakeEdgeTunnel) StartConnecting() {
+}
+
 func (f *fakeEdgeTunnel) Stop() error {
 	return nil
 }
 
+func (f *fakeEdgeTunnel) Stats() []tunnel.ConnectionStats {
+	return nil
+}
+
 func (f *fakeShimHandler) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
 	head := &clustermessage.MessageHead{
 		MessageID:         in.Head.MessageID,
@@ -126,7 +156,7 @@ func TestValid(t *testing.T) {
 				ClusterUserDefineName: "child",
 				K8sClient:             &oteclient.Clientset{},
 				RemoteShimAddr:        "",
-				ParentCluster:         "127.0.0.1:8287",
+				ParentCluster:         []string{"127.0.0.1:8287"},
 			},
 		},
 		{
@@ -136,7 +166,7 @@ func TestValid(t *testing.T) {
 				ClusterUserDefineName: "child",
 				K8sClient:             nil,
 				RemoteShimAddr:        ":8262",
-				ParentCluster:         "127.0.0.1:8287",
+				ParentCluster:         []string{"127.0.0.1:8287"},
 			},
 		},
 	}
@@ -158,7 +188,7 @@ func TestValid(t *testing.T) {
 				ClusterName:    "",
 				K8sClient:      nil,
 				RemoteShimAddr: ":8262",
-				ParentCluster:  "127.0.0.1:8287",
+				ParentCluster:  []string{"127.0.0.1:8287"},
 			},
 		},
 		{
@@ -167,7 +197,7 @@ func TestValid(t *testing.T) {
 				ClusterName:    "child1",
 				K8sClient:      nil,
 				RemoteShimAddr: "",
-				ParentCluster:  "127.0.0.1:8287",
+				ParentCluster:  []string{"127.0.0.1:8287"},
 			},
 		},
 		{
@@ -176,15 +206,15 @@ func TestValid(t *testing.T) {
 				ClusterName:    "child1",
 				K8sClient:      nil,
 				RemoteShimAddr: ":8262",
-				ParentCluster:  "",
+				ParentCluster:  nil,
 			},
 		},
 	}
 
 	for _, ec := range errorcase {
 		edge := &edgeHandler{conf: ec.Conf}
-		if err := edge.valid(); err == nil {
-			t.Errorf("[%q] expected error", ec.Name)
+		if err := edge.valid(); !errorkind.Is(err, ErrInvalidConfig) {
+			t.Errorf("[%q] expected invalid config error, got %v", ec.Name, err)
 		}
 	}
 }
@@ -230,7 +260,7 @@ func TestSendMessageToTunnel(t *testing.T) {
 		ClusterName:       "child",
 		K8sClient:         nil,
 		RemoteShimAddr:    ":8262",
-		ParentCluster:     "127.0.0.1:8287",
+		ParentCluster:     []string{"127.0.0.1:8287"},
 		ClusterToEdgeChan: make(chan clustermessage.ClusterMessage),
 	}
 
@@ -263,10 +293,12 @@ func TestSendMessageToTunnel(t *testing.T) {
 			conf:       conf,
 			edgeTunnel: &fakeEdgeTunnel{},
 		}
-		go edge.sendMessageToTunnel()
+		ctx, cancel := context.WithCancel(context.Background())
+		go edge.sendMessageToTunnel(ctx)
 		edge.conf.ClusterToEdgeChan <- ct.SendData
 		time.Sleep(1 * time.Second)
 		assert.True(t, proto.Equal(&ct.SendData, &LastSend))
+		cancel()
 	}
 }
 
@@ -275,7 +307,7 @@ func TestReceiveMessageFromTunnel(t *testing.T) {
 		ClusterName:       "child",
 		K8sClient:         nil,
 		RemoteShimAddr:    ":8262",
-		ParentCluster:     "127.0.0.1:8287",
+		ParentCluster:     []string{"127.0.0.1:8287"},
 		EdgeToClusterChan: make(chan clustermessage.ClusterMessage, 10),
 	}
 
@@ -347,7 +379,7 @@ func TestHandleMessage(t *testing.T) {
 		ClusterName:       "child",
 		K8sClient:         nil,
 		RemoteShimAddr:    ":8262",
-		ParentCluster:     "127.0.0.1:8287",
+		ParentCluster:     []string{"127.0.0.1:8287"},
 		EdgeToClusterChan: make(chan clustermessage.ClusterMessage, 10),
 	}
 	edge := &edgeHandler{
@@ -395,7 +427,7 @@ func TestHandleMessage(t *testing.T) {
 
 	for _, ct := range casetest {
 		LastSend.Head.Command = clustermessage.CommandType_Reserved
-		if err := edge.handleMessage(&ct.Data); err != nil {
+		if err := edge.handleMessage(context.Background(), &ct.Data); err != nil {
 			t.Errorf("[%q] unexpected error %v", ct.Name, err)
 		}
 
@@ -418,8 +450,89 @@ func TestHandleMessage(t *testing.T) {
 		},
 		Body: controllerAPITaskData,
 	}
-	err = edge.handleMessage(msg)
+	err = edge.handleMessage(context.Background(), msg)
+	assert.Nil(t, err)
+
+	redirectTask := &clustermessage.RedirectTask{Address: "127.0.0.1:8288"}
+	msg, err = redirectTask.ToClusterMessage(&clustermessage.MessageHead{
+		ParentClusterName: "root",
+		Command:           clustermessage.CommandType_Redirect,
+	})
 	assert.Nil(t, err)
+	err = edge.handleMessage(context.Background(), msg)
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1:8288", edge.edgeTunnel.(*fakeEdgeTunnel).redirectAddr)
+}
+
+func TestHandleControlMultiReq(t *testing.T) {
+	sent := make(chan struct{}, 1)
+	edge := &edgeHandler{
+		conf:       &config.ClusterControllerConfig{ClusterName: "child"},
+		edgeTunnel: &fakeEdgeTunnel{fakeEdgeTunnelSendChan: sent},
+		shimClient: newFakeShim(),
+	}
+	task := &clustermessage.ControlMultiTask{
+		Destination: "unknown",
+		Body:        [][]byte{[]byte("ns1"), []byte("ns2")},
+	}
+	msg, err := task.ToClusterMessage(&clustermessage.MessageHead{
+		MessageID: "multi",
+		Command:   clustermessage.CommandType_ControlMultiReq,
+	})
+	assert.Nil(t, err)
+
+	// bodies of an unknown destination are responded with 404 each.
+	err = edge.handleMessage(context.Background(), msg)
+	assert.Equal(t, handler.ErrNoHandler, handler.KindOf(err))
+	<-sent
+	assert.Equal(t, clustermessage.CommandType_ControlMultiResp, LastSend.Head.Command)
+	assert.Equal(t, "multi", LastSend.Head.MessageID)
+	assert.Equal(t, "child", LastSend.Head.ClusterName)
+	resp := handler.GetControlMultiTaskResponseFromClusterMessage(&LastSend)
+	assert.NotNil(t, resp)
+	assert.Len(t, resp.Items, 2)
+	for _, item := range resp.Items {
+		assert.Equal(t, int32(http.StatusNotFound), item.StatusCode)
+	}
+}
+
+func TestResolveObjectRef(t *testing.T) {
+	object := []byte(`{"kind":"ConfigMap"}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(object)
+	}))
+	defer server.Close()
+
+	head := &clustermessage.MessageHead{
+		MessageID: "task1",
+		Command:   clustermessage.CommandType_ControlReq,
+	}
+	task := &clustermessage.ControllerTask{
+		Destination: otev1.ClusterControllerDestAPI,
+		Method:      http.MethodPost,
+		URI:         "/api/v1/namespaces/default/configmaps",
+		Body:        objectref.NewRef(object, server.URL+"/cm").Marshal(),
+	}
+	msg, err := task.ToClusterMessage(head)
+	assert.Nil(t, err)
+
+	resolved, err := resolveObjectRef(msg, task)
+	assert.Nil(t, err)
+	got := handler.GetControllerTaskFromClusterMessage(resolved)
+	assert.Equal(t, object, got.Body)
+	assert.Equal(t, task.URI, got.URI)
+	// task in msg is not changed.
+	assert.True(t, objectref.IsRef(handler.GetControllerTaskFromClusterMessage(msg).Body))
+
+	// response with error if the object can not be fetched.
+	task.Body = objectref.NewRef([]byte("another"), server.URL+"/cm").Marshal()
+	msg, err = task.ToClusterMessage(head)
+	assert.Nil(t, err)
+	edge := &edgeHandler{shimClient: newFakeShim()}
+	resp, err := edge.doControlRequest(context.Background(), msg)
+	assert.NotNil(t, err)
+	assert.Equal(t, clustermessage.CommandType_ControlResp, resp.Head.Command)
+	assert.Equal(t, "task1", resp.Head.MessageID)
 }
 
 func TestReportSubTree(t *testing.T) {
@@ -434,25 +547,18 @@ func TestReportSubTree(t *testing.T) {
 	e.edgeTunnel = f
 	// add route
 	clusterrouter.Router().AddRoute("c1", "c2")
-	go func() {
-		// get a subtree msg
-		msg := <-f.fakeEdgeTunnelSendChan
-		assert.Equal(t, struct{}{}, msg)
-		//fmt.Printf("lastsendptr: %v\n", LastSendPtr)
-		assert.Equal(t, e.conf.ClusterName, LastSendPtr.Head.ClusterName)
-		// stop the timer
-		e.stopReportSubtree <- struct{}{}
-	}()
-	timer := time.NewTimer(5 * time.Second)
-	startReport := make(chan struct{}, 1)
-	startReport <- struct{}{}
+	// subtree is reported once connected, until disconnected.
+	e.afterConnect()
+	reporting := e.reporting
 	select {
-	case <-timer.C:
-		assert.Error(t, fmt.Errorf("%v timeout", t))
-	case <-startReport:
-		// this function will blocked until stop it or timeout
-		e.reportSubTreeTimer()
+	case <-f.fakeEdgeTunnelSendChan:
+	case <-time.After(5 * time.Second):
+		t.Fatal("subtree is not reported")
 	}
+	assert.Equal(t, e.conf.ClusterName, LastSendPtr.Head.ClusterName)
+	e.afterDisconnect()
+	assert.Nil(t, reporting.Wait())
+	assert.Nil(t, e.reporting)
 }
 
 func TestStart(t *testing.T) {
@@ -485,7 +591,7 @@ func TestStart(t *testing.T) {
 				ClusterUserDefineName: "child",
 				K8sClient:             nil,
 				RemoteShimAddr:        ":8080",
-				ParentCluster:         "127.0.0.1:8287",
+				ParentCluster:         []string{"127.0.0.1:8287"},
 			},
 			ExpectErr: true,
 		},
@@ -504,3 +610,263 @@ func TestStart(t *testing.T) {
 		})
 	}
 }
+
+func TestDeadLetterFromParent(t *testing.T) {
+	assert.Nil(t, deadletter.Setup(deadletter.Config{Limit: 10, Attempts: 2, RetryInterval: time.Millisecond}))
+	defer deadletter.Setup(deadletter.Config{})
+	edge := &edgeHandler{
+		conf: &config.ClusterControllerConfig{
+			ClusterName:       "child",
+			EdgeToClusterChan: make(chan clustermessage.ClusterMessage, 10),
+		},
+		edgeTunnel: &recordingEdgeTunnel{sent: make(chan *clustermessage.ClusterMessage, 10)},
+		shimClient: newFakeShim(),
+	}
+
+	// undecodable messages are kept at once.
+	assert.NotNil(t, edge.receiveMessageFromTunnel("parent", []byte("bad")))
+
+	// messages failed to be handled are kept after all attempts.
+	task := &clustermessage.ControlMultiTask{Destination: "unknown"}
+	body, err := proto.Marshal(task)
+	assert.Nil(t, err)
+	data, err := proto.Marshal(&clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{
+			MessageID:       "m1",
+			ClusterSelector: "child",
+			Command:         clustermessage.CommandType_ControlMultiReq,
+		},
+		Body: body,
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, edge.receiveMessageFromTunnel("parent", data))
+	q := deadletter.Query{Reason: deadletter.ReasonHandleFailed}
+	for i := 0; i < 100 && len(deadletter.List(q)) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	letters := deadletter.List(q)
+	assert.Len(t, letters, 1)
+	assert.Equal(t, "m1", letters[0].MessageID)
+	assert.Equal(t, deadletter.SourceParent, letters[0].Source)
+	assert.Contains(t, letters[0].Error, "no handler for unknown")
+	assert.Len(t, deadletter.List(deadletter.Query{Reason: deadletter.ReasonUndecodable}), 1)
+
+	// requeued messages are not sent to subtree again.
+	<-edge.conf.EdgeToClusterChan
+	assert.Nil(t, edge.requeueMessage("parent", data))
+	assert.Len(t, edge.conf.EdgeToClusterChan, 0)
+	// wait for retries of the message requeued, which is kept again.
+	for i := 0; i < 100 && len(deadletter.List(q)) < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Len(t, deadletter.List(q), 2)
+}
+
+func TestPanickedMessageFromParent(t *testing.T) {
+	assert.Nil(t, deadletter.Setup(deadletter.Config{Limit: 10}))
+	defer deadletter.Setup(deadletter.Config{})
+	edge := &edgeHandler{
+		conf: &config.ClusterControllerConfig{
+			ClusterName:       "child",
+			EdgeToClusterChan: make(chan clustermessage.ClusterMessage, 10),
+		},
+		edgeTunnel: &fakeEdgeTunnel{},
+		shimClient: newFakeShim(),
+	}
+
+	// a message without head panics, which is kept instead of crashing.
+	data, err := proto.Marshal(&clustermessage.ClusterMessage{Body: []byte("no head")})
+	assert.Nil(t, err)
+	err = edge.receiveMessageFromTunnel("parent", data)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "panicked")
+	letters := deadletter.List(deadletter.Query{Reason: deadletter.ReasonPanicked})
+	assert.Len(t, letters, 1)
+	assert.Equal(t, deadletter.SourceParent, letters[0].Source)
+}
+
+func TestSendToParentWhileDisconnected(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outbox")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, outbox.Setup(outbox.Config{Dir: dir}))
+	defer outbox.Setup(outbox.Config{})
+	sent := make(chan struct{}, 1)
+	edge := &edgeHandler{
+		conf:       &config.ClusterControllerConfig{ClusterName: "child"},
+		edgeTunnel: &fakeEdgeTunnel{fakeEdgeTunnelSendChan: sent},
+	}
+
+	// responses are queued while disconnected, and forwarded once connected.
+	resp := &clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{
+			MessageID: "queued",
+			Command:   clustermessage.CommandType_ControlResp,
+		},
+	}
+	assert.Nil(t, edge.sendToParent(resp))
+	assert.Len(t, sent, 0)
+	atomic.StoreInt32(&edge.connected, 1)
+	outbox.Forward(edge.edgeTunnel.Send)
+	<-sent
+	assert.Equal(t, "queued", LastSend.Head.MessageID)
+}
+
+// orderedEdgeTunnel sends ids of responses to parent by sent, holds the first response until
+// release is closed, and fails responses of ids in fail once.
+type orderedEdgeTunnel struct {
+	fakeEdgeTunnel
+	sent    chan string
+	holding chan struct{}
+
+	lock    sync.Mutex
+	release chan struct{}
+	fail    map[string]bool
+}
+
+func (o *orderedEdgeTunnel) Send(data []byte) error {
+	msg := &clustermessage.ClusterMessage{}
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return err
+	}
+	if msg.Head.Command != clustermessage.CommandType_ControlResp {
+		return nil
+	}
+	o.lock.Lock()
+	failed := o.fail[msg.Head.MessageID]
+	delete(o.fail, msg.Head.MessageID)
+	release := o.release
+	o.release = nil
+	o.lock.Unlock()
+	if failed {
+		return fmt.Errorf("broken pipe")
+	}
+	if release != nil {
+		o.holding <- struct{}{}
+		<-release
+	}
+	o.sent <- msg.Head.MessageID
+	return nil
+}
+
+func TestSendToParentInOrderAcrossReconnect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outbox")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, outbox.Setup(outbox.Config{Dir: dir}))
+	defer outbox.Setup(outbox.Config{})
+	release := make(chan struct{})
+	edgeTunnel := &orderedEdgeTunnel{
+		sent:    make(chan string, 10),
+		holding: make(chan struct{}),
+		release: release,
+		fail:    map[string]bool{},
+	}
+	edge := &edgeHandler{
+		conf:       &config.ClusterControllerConfig{ClusterName: "child"},
+		edgeTunnel: edgeTunnel,
+	}
+	respond := func(id string) {
+		assert.Nil(t, edge.sendToParent(&clustermessage.ClusterMessage{
+			Head: &clustermessage.MessageHead{
+				MessageID: id,
+				Command:   clustermessage.CommandType_ControlResp,
+			},
+		}))
+	}
+	expectSent := func(ids ...string) {
+		for _, id := range ids {
+			select {
+			case sent := <-edgeTunnel.sent:
+				assert.Equal(t, id, sent)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("%s is not sent", id)
+			}
+		}
+	}
+	forwarding := func() bool {
+		edge.outboxLock.Lock()
+		defer edge.outboxLock.Unlock()
+		return edge.forwarding
+	}
+
+	respond("r1")
+	respond("r2")
+	edge.afterConnect()
+	defer edge.afterDisconnect()
+	// responses during forwarding are sent after the ones queued.
+	<-edgeTunnel.holding
+	respond("r3")
+	close(release)
+	expectSent("r1", "r2", "r3")
+	for i := 0; i < 100 && forwarding(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.False(t, forwarding())
+	respond("r4")
+	expectSent("r4")
+
+	// responses failed while connected are forwarded again without reconnecting.
+	edgeTunnel.lock.Lock()
+	edgeTunnel.fail["r5"] = true
+	edgeTunnel.lock.Unlock()
+	respond("r5")
+	expectSent("r5")
+	assert.Equal(t, 0, outbox.Len())
+}
+
+func TestAuditMessages(t *testing.T) {
+	entries := make(chan audit.Entry, 10)
+	assert.Nil(t, audit.Setup(audit.Config{Entries: entries}))
+	defer audit.Setup(audit.Config{})
+	sent := make(chan struct{}, 1)
+	edge := &edgeHandler{
+		conf: &config.ClusterControllerConfig{
+			ClusterName:       "child",
+			EdgeToClusterChan: make(chan clustermessage.ClusterMessage, 10),
+		},
+		edgeTunnel: &fakeEdgeTunnel{fakeEdgeTunnelSendChan: sent},
+		shimClient: newFakeShim(),
+		dedup:      newDedupCache(),
+	}
+
+	// commands not supported are dropped, so are duplicates.
+	msg := dedupMessage("m1", "child")
+	msg.Head.Command = clustermessage.CommandType_EdgeReport
+	data, err := proto.Marshal(msg)
+	assert.Nil(t, err)
+	assert.Nil(t, edge.receiveMessageFromTunnel("parent", data))
+	assert.Nil(t, edge.receiveMessageFromTunnel("parent", data))
+	entry := <-entries
+	assert.Equal(t, audit.Received, entry.Direction)
+	assert.Equal(t, "m1", entry.MessageID)
+	assert.Equal(t, "EdgeReport", entry.Command)
+	assert.Equal(t, "child", entry.ClusterSelector)
+	assert.Equal(t, audit.Dropped, entry.Disposition)
+	assert.Equal(t, "command not supported", entry.Reason)
+	entry = <-entries
+	assert.Equal(t, audit.Dropped, entry.Disposition)
+	assert.Equal(t, "duplicated", entry.Reason)
+
+	// undecodable messages are errors.
+	assert.NotNil(t, edge.receiveMessageFromTunnel("parent", []byte("bad")))
+	entry = <-entries
+	assert.Equal(t, audit.Error, entry.Disposition)
+	assert.Empty(t, entry.MessageID)
+	assert.NotEmpty(t, entry.Reason)
+
+	// messages of this cluster are handled once sent.
+	resp := &clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{
+			MessageID: "m2",
+			Command:   clustermessage.CommandType_ControlResp,
+		},
+	}
+	assert.Nil(t, edge.sendToParent(resp))
+	entry = <-entries
+	assert.Equal(t, audit.Sent, entry.Direction)
+	assert.Equal(t, "m2", entry.MessageID)
+	assert.Equal(t, audit.Handled, entry.Disposition)
+	<-sent
+	assert.Len(t, entries, 0)
+}
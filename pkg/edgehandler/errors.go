@@ -0,0 +1,34 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgehandler
+
+import (
+	"errors"
+)
+
+// Kinds of errors returned by edgehandler, which callers can tell by errorkind.Is.
+var (
+	// ErrInvalidConfig is returned starting edgehandler with a config missing required fields.
+	ErrInvalidConfig = errors.New("invalid config")
+	// ErrShimUnavailable is returned starting edgehandler if the shim client can not be created,
+	// e.g., the remote shim is not reachable.
+	ErrShimUnavailable = errors.New("shim unavailable")
+	// ErrInvalidMessage is returned handling a message from parent which can not be decoded, or without a head.
+	ErrInvalidMessage = errors.New("invalid message")
+	// ErrUnresolvedReference is returned dispatching a task whose object reference can not be resolved.
+	ErrUnresolvedReference = errors.New("object reference unresolved")
+)
@@ -0,0 +1,105 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgehandler
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+var (
+	// idempotencyKeyTTL is the time to keep responses of tasks with idempotency keys.
+	idempotencyKeyTTL = 24 * time.Hour
+	// maxIdempotencyKeys is the max number of responses kept, the oldest is dropped if exceeded.
+	maxIdempotencyKeys = 1024
+)
+
+// idempotentResponse is the response of a task done with an idempotency key.
+type idempotentResponse struct {
+	body []byte
+	done time.Time
+}
+
+// idempotencyCache keeps responses of tasks succeeded by idempotency key,
+// so that a task of the same key is responded without executed again.
+// Failed tasks are not kept and can be retried.
+type idempotencyCache struct {
+	lock      sync.Mutex
+	responses map[string]*idempotentResponse
+}
+
+func newIdempotencyCache() *idempotencyCache {
+	return &idempotencyCache{
+		responses: make(map[string]*idempotentResponse),
+	}
+}
+
+// get returns the body of response of key if it is done in ttl before now.
+func (i *idempotencyCache) get(key string, now time.Time) []byte {
+	if i == nil {
+		return nil
+	}
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	resp, ok := i.responses[key]
+	if !ok {
+		return nil
+	}
+	if now.Sub(resp.done) > idempotencyKeyTTL {
+		delete(i.responses, key)
+		return nil
+	}
+	return resp.body
+}
+
+// record keeps the body of response of key if the task succeeded.
+func (i *idempotencyCache) record(key string, body []byte, now time.Time) {
+	if i == nil {
+		return
+	}
+	resp := &clustermessage.ControllerTaskResponse{}
+	if err := proto.Unmarshal(body, resp); err != nil {
+		return
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return
+	}
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.responses[key] = &idempotentResponse{body: body, done: now}
+	if len(i.responses) <= maxIdempotencyKeys {
+		return
+	}
+	oldest := ""
+	for k, r := range i.responses {
+		if now.Sub(r.done) > idempotencyKeyTTL {
+			delete(i.responses, k)
+			continue
+		}
+		if oldest == "" || r.done.Before(i.responses[oldest].done) {
+			oldest = k
+		}
+	}
+	if len(i.responses) > maxIdempotencyKeys {
+		delete(i.responses, oldest)
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgehandler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+)
+
+func TestIdempotencyCache(t *testing.T) {
+	cache := newIdempotencyCache()
+	now := time.Now()
+	succeeded := handler.ControlTaskResponse(http.StatusCreated, "created")
+	cache.record("key1", succeeded, now)
+	cache.record("key2", handler.ControlTaskResponse(http.StatusConflict, "conflict"), now)
+	cache.record("key3", []byte("invalid"), now)
+
+	assert.Equal(t, succeeded, cache.get("key1", now.Add(time.Minute)))
+	// failed tasks can be retried.
+	assert.Nil(t, cache.get("key2", now))
+	assert.Nil(t, cache.get("key3", now))
+	assert.Nil(t, cache.get("", now))
+	// responses are dropped after ttl.
+	assert.Nil(t, cache.get("key1", now.Add(idempotencyKeyTTL+time.Second)))
+	assert.Empty(t, cache.responses)
+
+	var nilCache *idempotencyCache
+	nilCache.record("key1", succeeded, now)
+	assert.Nil(t, nilCache.get("key1", now))
+}
+
+func TestIdempotencyCacheLimit(t *testing.T) {
+	max := maxIdempotencyKeys
+	maxIdempotencyKeys = 2
+	defer func() { maxIdempotencyKeys = max }()
+
+	cache := newIdempotencyCache()
+	now := time.Now()
+	body := handler.ControlTaskResponse(http.StatusOK, "")
+	cache.record("key1", body, now)
+	cache.record("key2", body, now.Add(time.Second))
+	cache.record("key3", body, now.Add(2*time.Second))
+	assert.Len(t, cache.responses, 2)
+	assert.Nil(t, cache.get("key1", now))
+	assert.NotNil(t, cache.get("key3", now))
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package edgehandler
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+// HandlerFunc handles a message of a command from the parent with the context of the message,
+// and returns the response sent to the parent, nil if nothing to respond.
+type HandlerFunc func(ctx context.Context, msg *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error)
+
+// commandHandlers are handlers registered by command.
+var commandHandlers sync.Map
+
+/*
+RegisterCommandHandler registers h to handle messages of cmd from the parent selecting this cluster,
+which replaces the built-in handling of cmd if any, so that custom commands can be handled
+without changing handleMessage. Commands registered are reported in capabilities of this cluster,
+so it should be called before edgehandler starts.
+*/
+func RegisterCommandHandler(cmd clustermessage.CommandType, h HandlerFunc) {
+	commandHandlers.Store(cmd, h)
+}
+
+// commandHandler returns the handler registered for cmd, nil if not registered.
+func commandHandler(cmd clustermessage.CommandType) HandlerFunc {
+	h, ok := commandHandlers.Load(cmd)
+	if !ok {
+		return nil
+	}
+	return h.(HandlerFunc)
+}
+
+// commands returns the built-in commands and the commands registered.
+func commands() []clustermessage.CommandType {
+	cmds := append([]clustermessage.CommandType(nil), handledCommands...)
+	commandHandlers.Range(func(key, value interface{}) bool {
+		cmd := key.(clustermessage.CommandType)
+		for _, c := range handledCommands {
+			if c == cmd {
+				return true
+			}
+		}
+		cmds = append(cmds, cmd)
+		return true
+	})
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i] < cmds[j] })
+	return cmds
+}
+
+// supported returns if cmd is a built-in command or registered.
+func supported(cmd clustermessage.CommandType) bool {
+	if commandHandler(cmd) != nil {
+		return true
+	}
+	for _, c := range handledCommands {
+		if c == cmd {
+			return true
+		}
+	}
+	return false
+}
+
+// handleByRegistered handles msg by h registered, and sends the response to the parent.
+func (e *edgeHandler) handleByRegistered(ctx context.Context, h HandlerFunc, msg *clustermessage.ClusterMessage) error {
+	klog.V(3).Infof("dispatch message %v of command %s to handler registered", msg.Head.MessageID, msg.Head.Command.String())
+	resp, err := h(ctx, msg)
+	if err != nil {
+		klog.Errorf("handle message %v of command %s failed: %v", msg.Head.MessageID, msg.Head.Command.String(), err)
+	}
+	if resp == nil {
+		return err
+	}
+	if resp.Head == nil {
+		resp.Head = &clustermessage.MessageHead{MessageID: msg.Head.MessageID}
+	}
+	resp.Head.ClusterName = e.conf.ClusterName
+	if sendErr := e.sendToParent(resp); sendErr != nil {
+		return sendErr
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package edgehandler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/config"
+)
+
+func TestRegisterCommandHandler(t *testing.T) {
+	custom := clustermessage.CommandType(100)
+	var handled *clustermessage.ClusterMessage
+	RegisterCommandHandler(custom, func(ctx context.Context,
+		msg *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+		handled = msg
+		return &clustermessage.ClusterMessage{
+			Head: &clustermessage.MessageHead{
+				MessageID: msg.Head.MessageID,
+				Command:   clustermessage.CommandType_ControlResp,
+			},
+		}, nil
+	})
+	defer commandHandlers.Delete(custom)
+	assert.Equal(t, append(append([]clustermessage.CommandType(nil), handledCommands...), custom), commands())
+
+	sent := make(chan struct{}, 1)
+	edge := &edgeHandler{
+		conf:       &config.ClusterControllerConfig{ClusterName: "child"},
+		edgeTunnel: &fakeEdgeTunnel{fakeEdgeTunnelSendChan: sent},
+	}
+	msg := &clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{
+			MessageID: "custom",
+			Command:   custom,
+		},
+	}
+	assert.Nil(t, edge.handleMessage(context.Background(), msg))
+	assert.Equal(t, msg, handled)
+	<-sent
+	assert.Equal(t, "custom", LastSend.Head.MessageID)
+	assert.Equal(t, "child", LastSend.Head.ClusterName)
+
+	// built-in commands are replaced by the handlers registered.
+	RegisterCommandHandler(clustermessage.CommandType_Redirect, func(ctx context.Context,
+		msg *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+		handled = msg
+		return nil, nil
+	})
+	defer commandHandlers.Delete(clustermessage.CommandType_Redirect)
+	assert.Len(t, commands(), len(handledCommands)+1)
+	redirect := &clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{Command: clustermessage.CommandType_Redirect},
+	}
+	assert.Nil(t, edge.handleMessage(context.Background(), redirect))
+	assert.Equal(t, redirect, handled)
+	assert.Empty(t, edge.edgeTunnel.(*fakeEdgeTunnel).redirectAddr)
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgehandler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/config"
+	"github.com/baidu/ote-stack/pkg/reporter"
+)
+
+// fakeShadowTunnel is a shadow tunnel passing messages sent to a channel.
+type fakeShadowTunnel struct {
+	fakeEdgeTunnel
+	sent chan *clustermessage.ClusterMessage
+}
+
+func (f *fakeShadowTunnel) Send(data []byte) error {
+	msg := &clustermessage.ClusterMessage{}
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return err
+	}
+	f.sent <- msg
+	return nil
+}
+
+func TestSendToShadow(t *testing.T) {
+	reports := reporter.Reports{{ResourceType: reporter.ResourceTypeNode, Body: []byte("node")}}
+	body, err := reporter.EncodeReports(reports, reporter.EncodingProtobuf)
+	assert.Nil(t, err)
+
+	shadow := &fakeShadowTunnel{sent: make(chan *clustermessage.ClusterMessage, 2)}
+	e := &edgeHandler{
+		conf:         &config.ClusterControllerConfig{ClusterName: "child"},
+		edgeTunnel:   &fakeEdgeTunnel{},
+		shadowTunnel: shadow,
+	}
+
+	// reports are sent to the shadow parent in json.
+	report := &clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{
+			ClusterName: "child",
+			Command:     clustermessage.CommandType_EdgeReport,
+		},
+		Body: body,
+	}
+	assert.Nil(t, e.sendToParent(report))
+	select {
+	case msg := <-shadow.sent:
+		assert.Equal(t, clustermessage.CommandType_EdgeReport, msg.Head.Command)
+		assert.Equal(t, reporter.EncodingJSON, reporter.ReportsEncoding(msg.Body))
+		r, err := reporter.DecodeReports(msg.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, reports, r)
+	case <-time.After(time.Second):
+		assert.Fail(t, "report is not sent to shadow parent")
+	}
+
+	// responses are not sent to the shadow parent.
+	resp := &clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{
+			ClusterName: "child",
+			Command:     clustermessage.CommandType_ControlResp,
+		},
+	}
+	assert.Nil(t, e.sendToParent(resp))
+	select {
+	case msg := <-shadow.sent:
+		assert.Fail(t, "response is sent to shadow parent", "%v", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// messages from the shadow parent are ignored.
+	data, err := proto.Marshal(&clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{
+			ClusterSelector: "child",
+			Command:         clustermessage.CommandType_ControlReq,
+		},
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, e.receiveMessageFromShadow("shadow", data))
+	assert.NotNil(t, e.receiveMessageFromShadow("shadow", []byte("invalid")))
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgehandler
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+)
+
+// maxPendingPerWorker is the number of tasks accepted per worker before submitting blocks.
+var maxPendingPerWorker = 64
+
+/*
+workerPool runs tasks from the parent concurrently by at most workers goroutines.
+Tasks of the same ordering key run one by one in the order submitted,
+so that e.g. a create and the following delete of an object are not reordered,
+while tasks of different keys do not wait for each other.
+Submitting blocks once too many tasks are pending, which stops reading from the parent.
+*/
+type workerPool struct {
+	slots   chan struct{}
+	pending chan struct{}
+
+	lock sync.Mutex
+	// queues are the tasks waiting by ordering key, a key exists while its tasks are running.
+	queues map[string][]func()
+}
+
+// newWorkerPool returns a pool of workers, or nil if tasks are not run concurrently.
+func newWorkerPool(workers int) *workerPool {
+	if workers <= 1 {
+		return nil
+	}
+	return &workerPool{
+		slots:   make(chan struct{}, workers),
+		pending: make(chan struct{}, workers*maxPendingPerWorker),
+		queues:  make(map[string][]func()),
+	}
+}
+
+// submit runs fn by a worker after tasks of key submitted before.
+// Tasks of empty key are not ordered.
+func (w *workerPool) submit(key string, fn func()) {
+	w.pending <- struct{}{}
+	if key == "" {
+		go w.run(fn)
+		return
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if queue, ok := w.queues[key]; ok {
+		w.queues[key] = append(queue, fn)
+		return
+	}
+	w.queues[key] = nil
+	go w.runQueue(key, fn)
+}
+
+// runQueue runs fn and the tasks queued of key after it.
+func (w *workerPool) runQueue(key string, fn func()) {
+	for fn != nil {
+		w.run(fn)
+		w.lock.Lock()
+		fn = nil
+		if queue := w.queues[key]; len(queue) != 0 {
+			fn, w.queues[key] = queue[0], queue[1:]
+		} else {
+			delete(w.queues, key)
+		}
+		w.lock.Unlock()
+	}
+}
+
+func (w *workerPool) run(fn func()) {
+	w.slots <- struct{}{}
+	defer func() {
+		<-w.slots
+		<-w.pending
+	}()
+	fn()
+}
+
+// stats returns the number of tasks running and pending including running.
+func (w *workerPool) stats() (running, pending int) {
+	if w == nil {
+		return 0, 0
+	}
+	return len(w.slots), len(w.pending)
+}
+
+/*
+orderingKey returns the key that msg is ordered by in the worker pool,
+which is the destination and namespace of its task, so that tasks to objects of a namespace
+keep their order. Messages without a task are not ordered.
+*/
+func orderingKey(msg *clustermessage.ClusterMessage) string {
+	var dest, uri string
+	switch msg.Head.Command {
+	case clustermessage.CommandType_ControlReq:
+		task := handler.GetControllerTaskFromClusterMessage(msg)
+		if task == nil {
+			return ""
+		}
+		dest, uri = task.Destination, task.URI
+	case clustermessage.CommandType_ControlMultiReq:
+		task := handler.GetControlMultiTaskFromClusterMessage(msg)
+		if task == nil {
+			return ""
+		}
+		dest, uri = task.Destination, task.URI
+	default:
+		return ""
+	}
+	return dest + "/" + uriNamespace(uri)
+}
+
+// uriNamespace returns the namespace of a k8s api uri, empty if it is cluster scoped.
+func uriNamespace(uri string) string {
+	if u, err := url.Parse(uri); err == nil {
+		uri = u.Path
+	}
+	parts := strings.Split(strings.Trim(uri, "/"), "/")
+	for i := 0; i+1 < len(parts); i++ {
+		if parts[i] == "namespaces" {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
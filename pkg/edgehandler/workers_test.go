@@ -0,0 +1,266 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgehandler
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/clustershim"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+	"github.com/baidu/ote-stack/pkg/config"
+)
+
+func TestWorkerPoolOrder(t *testing.T) {
+	pool := newWorkerPool(4)
+	var lock sync.Mutex
+	done := map[string][]int{}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		for _, key := range []string{"a", "b"} {
+			i, key := i, key
+			wg.Add(1)
+			pool.submit(key, func() {
+				defer wg.Done()
+				time.Sleep(time.Millisecond)
+				lock.Lock()
+				done[key] = append(done[key], i)
+				lock.Unlock()
+			})
+		}
+	}
+	wg.Wait()
+	for _, key := range []string{"a", "b"} {
+		assert.Len(t, done[key], 20)
+		for i, n := range done[key] {
+			assert.Equal(t, i, n)
+		}
+	}
+	// tasks are done before workers release them, wait for the pool to be idle.
+	for i := 0; i < 100 && !idle(pool); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(t, idle(pool))
+}
+
+// idle returns whether pool has no tasks running, pending or queued.
+func idle(pool *workerPool) bool {
+	running, pending := pool.stats()
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	return running == 0 && pending == 0 && len(pool.queues) == 0
+}
+
+func TestWorkerPoolConcurrency(t *testing.T) {
+	assert.Nil(t, newWorkerPool(1))
+
+	pool := newWorkerPool(2)
+	release := make(chan struct{})
+	started := make(chan string, 3)
+	for _, key := range []string{"a", "b", "c"} {
+		key := key
+		pool.submit(key, func() {
+			started <- key
+			<-release
+		})
+	}
+	// tasks of different keys run concurrently, at most by 2 workers.
+	<-started
+	<-started
+	select {
+	case key := <-started:
+		t.Errorf("task %s started while workers are busy", key)
+	case <-time.After(100 * time.Millisecond):
+	}
+	running, pending := pool.stats()
+	assert.Equal(t, 2, running)
+	assert.Equal(t, 3, pending)
+	close(release)
+	<-started
+}
+
+func TestOrderingKey(t *testing.T) {
+	casetest := []struct {
+		Name    string
+		Command clustermessage.CommandType
+		Task    proto.Message
+		Expect  string
+	}{
+		{
+			Name:    "namespaced",
+			Command: clustermessage.CommandType_ControlReq,
+			Task: &clustermessage.ControllerTask{
+				Destination: otev1.ClusterControllerDestAPI,
+				URI:         "/api/v1/namespaces/default/pods/p1?timeout=1s",
+			},
+			Expect: "api/default",
+		},
+		{
+			Name:    "cluster scoped",
+			Command: clustermessage.CommandType_ControlReq,
+			Task: &clustermessage.ControllerTask{
+				Destination: otev1.ClusterControllerDestAPI,
+				URI:         "/api/v1/nodes",
+			},
+			Expect: "api/",
+		},
+		{
+			Name:    "multi",
+			Command: clustermessage.CommandType_ControlMultiReq,
+			Task: &clustermessage.ControlMultiTask{
+				Destination: otev1.ClusterControllerDestAPI,
+				URI:         "/apis/apps/v1/namespaces/ote/deployments",
+			},
+			Expect: "api/ote",
+		},
+		{
+			Name:    "redirect",
+			Command: clustermessage.CommandType_Redirect,
+			Task:    &clustermessage.RedirectTask{Address: "127.0.0.1:8288"},
+			Expect:  "",
+		},
+	}
+	for _, ct := range casetest {
+		body, err := proto.Marshal(ct.Task)
+		assert.Nil(t, err)
+		msg := &clustermessage.ClusterMessage{
+			Head: &clustermessage.MessageHead{Command: ct.Command},
+			Body: body,
+		}
+		assert.Equal(t, ct.Expect, orderingKey(msg), ct.Name)
+	}
+}
+
+// blockingShimHandler blocks tasks of namespace slow until released or their context is done.
+type blockingShimHandler struct {
+	release chan struct{}
+}
+
+func (b *blockingShimHandler) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	return b.DoContext(context.Background(), in)
+}
+
+func (b *blockingShimHandler) DoContext(ctx context.Context,
+	in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	task := handler.GetControllerTaskFromClusterMessage(in)
+	if uriNamespace(task.URI) == "slow" {
+		select {
+		case <-b.release:
+		case <-ctx.Done():
+			return handler.Response(handler.ControlTaskResponse(http.StatusGatewayTimeout, ""), in.Head), nil
+		}
+	}
+	return handler.Response(handler.ControlTaskResponse(http.StatusOK, ""), in.Head), nil
+}
+
+// recordingEdgeTunnel sends messages to parent by sent.
+type recordingEdgeTunnel struct {
+	fakeEdgeTunnel
+	sent chan *clustermessage.ClusterMessage
+}
+
+func (r *recordingEdgeTunnel) Send(data []byte) error {
+	msg := &clustermessage.ClusterMessage{}
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return err
+	}
+	r.sent <- msg
+	return nil
+}
+
+func workerTask(t *testing.T, id, namespace string) []byte {
+	task := &clustermessage.ControllerTask{
+		Destination: otev1.ClusterControllerDestAPI,
+		Method:      http.MethodGet,
+		URI:         "/api/v1/namespaces/" + namespace + "/pods",
+	}
+	msg, err := task.ToClusterMessage(&clustermessage.MessageHead{
+		MessageID:       id,
+		Command:         clustermessage.CommandType_ControlReq,
+		ClusterSelector: "child",
+	})
+	assert.Nil(t, err)
+	data, err := proto.Marshal(msg)
+	assert.Nil(t, err)
+	return data
+}
+
+func decodeWorkerTask(t *testing.T, data []byte) *clustermessage.ClusterMessage {
+	msg := &clustermessage.ClusterMessage{}
+	assert.Nil(t, proto.Unmarshal(data, msg))
+	return msg
+}
+
+func responseStatus(t *testing.T, msg *clustermessage.ClusterMessage) int32 {
+	resp := &clustermessage.ControllerTaskResponse{}
+	assert.Nil(t, proto.Unmarshal(msg.Body, resp))
+	return resp.StatusCode
+}
+
+func TestParallelTasksFromParent(t *testing.T) {
+	shim := &blockingShimHandler{release: make(chan struct{})}
+	tun := &recordingEdgeTunnel{sent: make(chan *clustermessage.ClusterMessage, 10)}
+	conf := &config.ClusterControllerConfig{
+		ClusterName:       "child",
+		EdgeToClusterChan: make(chan clustermessage.ClusterMessage, 10),
+		ShimWorkers:       2,
+	}
+	edge := NewEdgeHandlerWithShim(conf,
+		clustershim.NewlocalShimClientWithHandler(clustershim.ShimHandler{otev1.ClusterControllerDestAPI: shim}),
+		nil).(*edgeHandler)
+	edge.edgeTunnel = tun
+
+	// the slow task does not block the one of another namespace.
+	assert.Nil(t, edge.receiveMessageFromTunnel("parent", workerTask(t, "slow", "slow")))
+	assert.Nil(t, edge.receiveMessageFromTunnel("parent", workerTask(t, "fast", "fast")))
+	resp := <-tun.sent
+	assert.Equal(t, "fast", resp.Head.MessageID)
+	assert.Equal(t, int32(http.StatusOK), responseStatus(t, resp))
+
+	close(shim.release)
+	resp = <-tun.sent
+	assert.Equal(t, "slow", resp.Head.MessageID)
+	assert.Equal(t, int32(http.StatusOK), responseStatus(t, resp))
+}
+
+func TestShimTimeout(t *testing.T) {
+	shim := &blockingShimHandler{release: make(chan struct{})}
+	tun := &recordingEdgeTunnel{sent: make(chan *clustermessage.ClusterMessage, 10)}
+	conf := &config.ClusterControllerConfig{
+		ClusterName:       "child",
+		EdgeToClusterChan: make(chan clustermessage.ClusterMessage, 10),
+		ShimWorkers:       2,
+		ShimTimeout:       100 * time.Millisecond,
+	}
+	edge := NewEdgeHandlerWithShim(conf,
+		clustershim.NewlocalShimClientWithHandler(clustershim.ShimHandler{otev1.ClusterControllerDestAPI: shim}),
+		nil).(*edgeHandler)
+	edge.edgeTunnel = tun
+
+	assert.Nil(t, edge.receiveMessageFromTunnel("parent", workerTask(t, "slow", "slow")))
+	resp := <-tun.sent
+	assert.Equal(t, "slow", resp.Head.MessageID)
+	assert.Equal(t, int32(http.StatusGatewayTimeout), responseStatus(t, resp))
+}
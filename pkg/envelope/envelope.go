@@ -0,0 +1,202 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package envelope seals bodies of controller tasks by envelope encryption.
+/*
+A body, e.g. a Secret, is encrypted by a random data key with AES-256-GCM, and the data key
+is encrypted by a key manager, e.g. vault transit, with the key of each destination cluster.
+Clusters forward the envelope as is, so that the plaintext never traverses them, and only
+the cluster shim of a destination opens it with its own key.
+*/
+package envelope
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	// Prefix starts bodies which are envelopes, followed by the envelope in json.
+	Prefix = "ote-envelope:"
+
+	// ClusterPlaceholder in key templates is replaced by the name of the destination cluster.
+	ClusterPlaceholder = "{cluster}"
+	// DefaultKeyTemplate is the key of each destination cluster by default.
+	DefaultKeyTemplate = "ote-" + ClusterPlaceholder
+
+	// dataKeySize is the size of data keys for AES-256.
+	dataKeySize = 32
+)
+
+// KeyManager encrypts and decrypts data keys by named keys it keeps.
+type KeyManager interface {
+	Encrypt(key string, plaintext []byte) ([]byte, error)
+	Decrypt(key string, ciphertext []byte) ([]byte, error)
+}
+
+// Envelope is a body encrypted by a data key, with the data key encrypted by each key.
+type Envelope struct {
+	// Keys are the data key encrypted by name of keys.
+	Keys       map[string][]byte `json:"keys"`
+	Nonce      []byte            `json:"nonce"`
+	Ciphertext []byte            `json:"ciphertext"`
+}
+
+// NewKeyManager returns the key manager of rawURL, which is either a directory of key files
+// like file:///etc/ote/keys, or the url of a vault transit engine like
+// https://vault:8200/v1/transit, with the token in environment VAULT_TOKEN.
+func NewKeyManager(rawURL string) (KeyManager, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("kms url %s is invalid: %v", rawURL, err)
+	}
+	switch u.Scheme {
+	case "file":
+		return NewFileKeys(u.Path), nil
+	case "http", "https":
+		return NewVaultTransit(rawURL, os.Getenv("VAULT_TOKEN")), nil
+	default:
+		return nil, fmt.Errorf("kms scheme %s is not supported", u.Scheme)
+	}
+}
+
+// KeyName returns the key of cluster by template.
+func KeyName(template, cluster string) string {
+	return strings.Replace(template, ClusterPlaceholder, cluster, -1)
+}
+
+// Seal returns the body of plaintext sealed for keys by km.
+func Seal(plaintext []byte, keys []string, km KeyManager) ([]byte, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no key to seal for")
+	}
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, fmt.Errorf("generate data key failed: %v", err)
+	}
+	nonce, ciphertext, err := encrypt(dataKey, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	e := &Envelope{
+		Keys:       make(map[string][]byte, len(keys)),
+		Nonce:      nonce,
+		Ciphertext: ciphertext,
+	}
+	sorted := append([]string{}, keys...)
+	sort.Strings(sorted)
+	for _, key := range sorted {
+		if _, ok := e.Keys[key]; ok {
+			continue
+		}
+		wrapped, err := km.Encrypt(key, dataKey)
+		if err != nil {
+			return nil, fmt.Errorf("encrypt data key by %s failed: %v", key, err)
+		}
+		e.Keys[key] = wrapped
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(Prefix), data...), nil
+}
+
+// IsSealed returns true if body is an envelope.
+func IsSealed(body []byte) bool {
+	return bytes.HasPrefix(body, []byte(Prefix))
+}
+
+// Parse returns the envelope of body.
+func Parse(body []byte) (*Envelope, error) {
+	if !IsSealed(body) {
+		return nil, fmt.Errorf("body is not an envelope")
+	}
+	e := &Envelope{}
+	if err := json.Unmarshal(body[len(Prefix):], e); err != nil {
+		return nil, fmt.Errorf("unmarshal envelope failed: %v", err)
+	}
+	return e, nil
+}
+
+// ErrNotSealedFor is returned by opening an envelope not sealed for the key.
+type ErrNotSealedFor string
+
+func (e ErrNotSealedFor) Error() string {
+	return fmt.Sprintf("envelope is not sealed for key %s", string(e))
+}
+
+// Open returns the plaintext of envelope body by key of km.
+func Open(body []byte, key string, km KeyManager) ([]byte, error) {
+	e, err := Parse(body)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, ok := e.Keys[key]
+	if !ok {
+		return nil, ErrNotSealedFor(key)
+	}
+	dataKey, err := km.Decrypt(key, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt data key by %s failed: %v", key, err)
+	}
+	return decrypt(dataKey, e.Nonce, e.Ciphertext)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encrypt encrypts plaintext by key with a random nonce.
+func encrypt(key, plaintext []byte) ([]byte, []byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, fmt.Errorf("generate nonce failed: %v", err)
+	}
+	return nonce, gcm.Seal(nil, nonce, plaintext, nil), nil
+}
+
+func decrypt(key, nonce, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("nonce size %d is invalid", len(nonce))
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt failed: %v", err)
+	}
+	return plaintext, nil
+}
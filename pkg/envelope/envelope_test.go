@@ -0,0 +1,119 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envelope
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newFileKeys returns keys in a temporary directory, with a key of each name.
+func newFileKeys(t *testing.T, names ...string) (*FileKeys, func()) {
+	dir, err := ioutil.TempDir("", "envelope")
+	assert.Nil(t, err)
+	for i, name := range names {
+		key := make([]byte, dataKeySize)
+		key[0] = byte(i + 1)
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0600)
+		assert.Nil(t, err)
+	}
+	return NewFileKeys(dir), func() { os.RemoveAll(dir) }
+}
+
+func TestSealAndOpen(t *testing.T) {
+	km, cleanup := newFileKeys(t, "ote-c1", "ote-c2", "ote-c3")
+	defer cleanup()
+	plaintext := []byte(`{"kind":"Secret","data":{"password":"cGFzcw=="}}`)
+
+	body, err := Seal(plaintext, []string{KeyName(DefaultKeyTemplate, "c2"), "ote-c1", "ote-c1"}, km)
+	assert.Nil(t, err)
+	assert.True(t, IsSealed(body))
+	assert.NotContains(t, string(body), "cGFzcw==")
+	e, err := Parse(body)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(e.Keys))
+
+	for _, key := range []string{"ote-c1", "ote-c2"} {
+		opened, err := Open(body, key, km)
+		assert.Nil(t, err)
+		assert.Equal(t, plaintext, opened)
+	}
+	_, err = Open(body, "ote-c3", km)
+	assert.Equal(t, ErrNotSealedFor("ote-c3"), err)
+
+	// the data key encrypted for a key cannot be decrypted by another key.
+	e.Keys["ote-c3"] = e.Keys["ote-c1"]
+	_, err = km.Decrypt("ote-c3", e.Keys["ote-c3"])
+	assert.NotNil(t, err)
+
+	_, err = Seal(plaintext, nil, km)
+	assert.NotNil(t, err)
+	_, err = Seal(plaintext, []string{"ote-c4"}, km)
+	assert.NotNil(t, err)
+	_, err = Parse(plaintext)
+	assert.NotNil(t, err)
+	_, err = Parse([]byte(Prefix + "{"))
+	assert.NotNil(t, err)
+}
+
+func TestOpenTampered(t *testing.T) {
+	km, cleanup := newFileKeys(t, "ote-c1")
+	defer cleanup()
+	sealed, err := Seal([]byte("secret"), []string{"ote-c1"}, km)
+	assert.Nil(t, err)
+	e, err := Parse(sealed)
+	assert.Nil(t, err)
+	e.Ciphertext[0] ^= 1
+	_, err = decrypt(make([]byte, dataKeySize), e.Nonce, e.Ciphertext)
+	assert.NotNil(t, err)
+}
+
+func TestFileKeys(t *testing.T) {
+	km, cleanup := newFileKeys(t, "ote-c1")
+	defer cleanup()
+	ioutil.WriteFile(filepath.Join(km.dir, "short"), []byte(base64.StdEncoding.EncodeToString([]byte("short"))), 0600)
+
+	for _, key := range []string{"", "..", "../ote-c1", "short", "missing"} {
+		_, err := km.Encrypt(key, []byte("data"))
+		assert.NotNil(t, err, key)
+	}
+	_, err := km.Decrypt("ote-c1", []byte("short"))
+	assert.NotNil(t, err)
+}
+
+func TestNewKeyManager(t *testing.T) {
+	km, err := NewKeyManager("file:///etc/ote/keys")
+	assert.Nil(t, err)
+	assert.Equal(t, "/etc/ote/keys", km.(*FileKeys).dir)
+
+	os.Setenv("VAULT_TOKEN", "token")
+	defer os.Unsetenv("VAULT_TOKEN")
+	km, err = NewKeyManager("https://vault:8200/v1/transit/")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://vault:8200/v1/transit", km.(*VaultTransit).addr)
+	assert.Equal(t, "token", km.(*VaultTransit).token)
+
+	_, err = NewKeyManager("kms://key")
+	assert.NotNil(t, err)
+	_, err = NewKeyManager("://")
+	assert.NotNil(t, err)
+}
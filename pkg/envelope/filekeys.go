@@ -0,0 +1,80 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envelope
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// FileKeys keeps keys in files of a directory, each of a base64 encoded 32 bytes key
+// and named by the key, e.g. generated by `head -c 32 /dev/urandom | base64 > ote-c1`.
+type FileKeys struct {
+	dir string
+}
+
+// NewFileKeys returns the keys in dir.
+func NewFileKeys(dir string) *FileKeys {
+	return &FileKeys{dir: dir}
+}
+
+func (f *FileKeys) load(key string) ([]byte, error) {
+	if key == "" || strings.ContainsAny(key, `/\`) || key == "." || key == ".." {
+		return nil, fmt.Errorf("key name %q is invalid", key)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(f.dir, key))
+	if err != nil {
+		return nil, fmt.Errorf("read key %s failed: %v", key, err)
+	}
+	k, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(k) != dataKeySize {
+		return nil, fmt.Errorf("key %s is not a base64 encoded %d bytes key", key, dataKeySize)
+	}
+	return k, nil
+}
+
+// Encrypt encrypts plaintext by key, the nonce is prepended to the ciphertext.
+func (f *FileKeys) Encrypt(key string, plaintext []byte) ([]byte, error) {
+	k, err := f.load(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce, ciphertext, err := encrypt(k, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return append(nonce, ciphertext...), nil
+}
+
+// Decrypt decrypts ciphertext returned by Encrypt by key.
+func (f *FileKeys) Decrypt(key string, ciphertext []byte) ([]byte, error) {
+	k, err := f.load(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(k)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext is too short")
+	}
+	return decrypt(k, ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():])
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envelope
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// vaultTimeout is the time of a request to vault.
+var vaultTimeout = 10 * time.Second
+
+// VaultTransit encrypts and decrypts by keys of a vault transit secrets engine.
+type VaultTransit struct {
+	// addr is the url of the transit engine, e.g., https://vault:8200/v1/transit.
+	addr   string
+	token  string
+	client *http.Client
+}
+
+// NewVaultTransit returns the transit engine at addr, requested with token.
+func NewVaultTransit(addr, token string) *VaultTransit {
+	return &VaultTransit{
+		addr:   strings.TrimSuffix(addr, "/"),
+		token:  token,
+		client: &http.Client{Timeout: vaultTimeout},
+	}
+}
+
+type vaultResponse struct {
+	Data struct {
+		Ciphertext string `json:"ciphertext"`
+		Plaintext  string `json:"plaintext"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+// request posts body to op of key, e.g. encrypt/ote-c1.
+func (v *VaultTransit) request(op, key string, body map[string]string) (*vaultResponse, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, v.addr+"/"+op+"/"+url.PathEscape(key), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if v.token != "" {
+		req.Header.Set("X-Vault-Token", v.token)
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	ret := &vaultResponse{}
+	if err := json.Unmarshal(raw, ret); err != nil {
+		return nil, fmt.Errorf("vault responded %d: %s", resp.StatusCode, string(raw))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault responded %d: %s", resp.StatusCode, strings.Join(ret.Errors, ", "))
+	}
+	return ret, nil
+}
+
+// Encrypt encrypts plaintext by key of the transit engine.
+func (v *VaultTransit) Encrypt(key string, plaintext []byte) ([]byte, error) {
+	resp, err := v.request("encrypt", key, map[string]string{
+		"plaintext": base64.StdEncoding.EncodeToString(plaintext),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Data.Ciphertext == "" {
+		return nil, fmt.Errorf("vault responded no ciphertext")
+	}
+	return []byte(resp.Data.Ciphertext), nil
+}
+
+// Decrypt decrypts ciphertext by key of the transit engine.
+func (v *VaultTransit) Decrypt(key string, ciphertext []byte) ([]byte, error) {
+	resp, err := v.request("decrypt", key, map[string]string{
+		"ciphertext": string(ciphertext),
+	})
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("vault responded invalid plaintext: %v", err)
+	}
+	return plaintext, nil
+}
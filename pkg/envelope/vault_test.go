@@ -0,0 +1,93 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envelope
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newVault returns a fake transit engine of keys, which encrypts by prefixing the key name.
+func newVault(t *testing.T, token string, keys ...string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != token {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/transit/"), "/")
+		found := false
+		for _, key := range keys {
+			found = found || (len(parts) == 2 && key == parts[1])
+		}
+		if !found {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":["encryption key not found"]}`))
+			return
+		}
+		body := map[string]string{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&body))
+		prefix := "vault:v1:" + parts[1] + ":"
+		switch parts[0] {
+		case "encrypt":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]string{"ciphertext": prefix + body["plaintext"]},
+			})
+		case "decrypt":
+			if !strings.HasPrefix(body["ciphertext"], prefix) {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"errors":["cipher: message authentication failed"]}`))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]string{"plaintext": strings.TrimPrefix(body["ciphertext"], prefix)},
+			})
+		}
+	}))
+}
+
+func TestVaultTransit(t *testing.T) {
+	server := newVault(t, "token", "ote-c1", "ote-c2")
+	defer server.Close()
+	km := NewVaultTransit(server.URL+"/v1/transit", "token")
+
+	ciphertext, err := km.Encrypt("ote-c1", []byte("data key"))
+	assert.Nil(t, err)
+	assert.Equal(t, "vault:v1:ote-c1:"+base64.StdEncoding.EncodeToString([]byte("data key")), string(ciphertext))
+	plaintext, err := km.Decrypt("ote-c1", ciphertext)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("data key"), plaintext)
+
+	_, err = km.Decrypt("ote-c2", ciphertext)
+	assert.Contains(t, err.Error(), "message authentication failed")
+	_, err = km.Encrypt("ote-c3", []byte("data key"))
+	assert.Contains(t, err.Error(), "encryption key not found")
+	_, err = NewVaultTransit(server.URL+"/v1/transit", "invalid").Encrypt("ote-c1", []byte("data key"))
+	assert.Contains(t, err.Error(), "permission denied")
+
+	body, err := Seal([]byte("secret"), []string{"ote-c1", "ote-c2"}, km)
+	assert.Nil(t, err)
+	opened, err := Open(body, "ote-c2", km)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("secret"), opened)
+}
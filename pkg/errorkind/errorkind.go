@@ -0,0 +1,63 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package errorkind implements errors of kinds, e.g., ErrBadRequest of cluster shims,
+// so that callers and tests branch on the kind of an error instead of matching its message.
+package errorkind
+
+import (
+	"fmt"
+)
+
+// kindError is an error of a kind, with a message describing it.
+type kindError struct {
+	kind error
+	msg  string
+}
+
+func (e *kindError) Error() string {
+	return e.msg
+}
+
+// Unwrap returns the kind of the error, so that errors.Is(err, kind) holds with go 1.13 or later.
+func (e *kindError) Unwrap() error {
+	return e.kind
+}
+
+// Errorf returns an error of kind, with message formatted by format and a.
+func Errorf(kind error, format string, a ...interface{}) error {
+	return &kindError{kind: kind, msg: fmt.Sprintf(format, a...)}
+}
+
+// Of returns the kind of err returned by Errorf, nil if it is not of a kind.
+func Of(err error) error {
+	if e, ok := err.(*kindError); ok {
+		return e.kind
+	}
+	return nil
+}
+
+// Is returns true if err is kind, or an error of kind returned by Errorf, including errors
+// of kinds of kind. It works as errors.Is for errors of kinds, without go 1.13.
+func Is(err, kind error) bool {
+	for err != nil {
+		if err == kind {
+			return true
+		}
+		err = Of(err)
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package errorkind
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorf(t *testing.T) {
+	errBadRequest := errors.New("bad request")
+	err := Errorf(errBadRequest, "name of %s is required", "job")
+	assert.Equal(t, "name of job is required", err.Error())
+	assert.Equal(t, errBadRequest, Of(err))
+	assert.True(t, Is(err, errBadRequest))
+	assert.True(t, Is(errBadRequest, errBadRequest))
+
+	assert.Nil(t, Of(errBadRequest))
+	assert.Nil(t, Of(nil))
+	assert.False(t, Is(nil, errBadRequest))
+	assert.False(t, Is(errors.New("bad request"), errBadRequest))
+	assert.False(t, Is(err, errors.New("bad request")))
+
+	// kinds can be of kinds.
+	errNoName := Errorf(errBadRequest, "name is required")
+	err = Errorf(errNoName, "name of job is required")
+	assert.Equal(t, errNoName, Of(err))
+	assert.True(t, Is(err, errBadRequest))
+}
@@ -36,6 +36,22 @@ func (c *FakeOteV1) ClusterControllers(namespace string) v1.ClusterControllerInt
 	return &FakeClusterControllers{c, namespace}
 }
 
+func (c *FakeOteV1) ClusterInventories(namespace string) v1.ClusterInventoryInterface {
+	return &FakeClusterInventories{c, namespace}
+}
+
+func (c *FakeOteV1) ClusterQuotas(namespace string) v1.ClusterQuotaInterface {
+	return &FakeClusterQuotas{c, namespace}
+}
+
+func (c *FakeOteV1) CronClusterTasks(namespace string) v1.CronClusterTaskInterface {
+	return &FakeCronClusterTasks{c, namespace}
+}
+
+func (c *FakeOteV1) ResourceTransforms(namespace string) v1.ResourceTransformInterface {
+	return &FakeResourceTransforms{c, namespace}
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeOteV1) RESTClient() rest.Interface {
@@ -21,3 +21,11 @@ package v1
 type ClusterExpansion interface{}
 
 type ClusterControllerExpansion interface{}
+
+type ClusterInventoryExpansion interface{}
+
+type ClusterQuotaExpansion interface{}
+
+type CronClusterTaskExpansion interface{}
+
+type ResourceTransformExpansion interface{}
@@ -29,6 +29,10 @@ type OteV1Interface interface {
 	RESTClient() rest.Interface
 	ClustersGetter
 	ClusterControllersGetter
+	ClusterInventoriesGetter
+	ClusterQuotasGetter
+	CronClusterTasksGetter
+	ResourceTransformsGetter
 }
 
 // OteV1Client is used to interact with features provided by the ote.baidu.com group.
@@ -44,6 +48,22 @@ func (c *OteV1Client) ClusterControllers(namespace string) ClusterControllerInte
 	return newClusterControllers(c, namespace)
 }
 
+func (c *OteV1Client) ClusterInventories(namespace string) ClusterInventoryInterface {
+	return newClusterInventories(c, namespace)
+}
+
+func (c *OteV1Client) ClusterQuotas(namespace string) ClusterQuotaInterface {
+	return newClusterQuotas(c, namespace)
+}
+
+func (c *OteV1Client) CronClusterTasks(namespace string) CronClusterTaskInterface {
+	return newCronClusterTasks(c, namespace)
+}
+
+func (c *OteV1Client) ResourceTransforms(namespace string) ResourceTransformInterface {
+	return newResourceTransforms(c, namespace)
+}
+
 // NewForConfig creates a new OteV1Client for the given config.
 func NewForConfig(c *rest.Config) (*OteV1Client, error) {
 	config := *c
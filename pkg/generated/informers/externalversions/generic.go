@@ -57,6 +57,14 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Ote().V1().Clusters().Informer()}, nil
 	case v1.SchemeGroupVersion.WithResource("clustercontrollers"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Ote().V1().ClusterControllers().Informer()}, nil
+	case v1.SchemeGroupVersion.WithResource("clusterinventories"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Ote().V1().ClusterInventories().Informer()}, nil
+	case v1.SchemeGroupVersion.WithResource("clusterquotas"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Ote().V1().ClusterQuotas().Informer()}, nil
+	case v1.SchemeGroupVersion.WithResource("cronclustertasks"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Ote().V1().CronClusterTasks().Informer()}, nil
+	case v1.SchemeGroupVersion.WithResource("resourcetransforms"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Ote().V1().ResourceTransforms().Informer()}, nil
 
 	}
 
@@ -28,6 +28,14 @@ type Interface interface {
 	Clusters() ClusterInformer
 	// ClusterControllers returns a ClusterControllerInformer.
 	ClusterControllers() ClusterControllerInformer
+	// ClusterInventories returns a ClusterInventoryInformer.
+	ClusterInventories() ClusterInventoryInformer
+	// ClusterQuotas returns a ClusterQuotaInformer.
+	ClusterQuotas() ClusterQuotaInformer
+	// CronClusterTasks returns a CronClusterTaskInformer.
+	CronClusterTasks() CronClusterTaskInformer
+	// ResourceTransforms returns a ResourceTransformInformer.
+	ResourceTransforms() ResourceTransformInformer
 }
 
 type version struct {
@@ -50,3 +58,23 @@ func (v *version) Clusters() ClusterInformer {
 func (v *version) ClusterControllers() ClusterControllerInformer {
 	return &clusterControllerInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
+
+// ClusterInventories returns a ClusterInventoryInformer.
+func (v *version) ClusterInventories() ClusterInventoryInformer {
+	return &clusterInventoryInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// ClusterQuotas returns a ClusterQuotaInformer.
+func (v *version) ClusterQuotas() ClusterQuotaInformer {
+	return &clusterQuotaInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// CronClusterTasks returns a CronClusterTaskInformer.
+func (v *version) CronClusterTasks() CronClusterTaskInformer {
+	return &cronClusterTaskInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// ResourceTransforms returns a ResourceTransformInformer.
+func (v *version) ResourceTransforms() ResourceTransformInformer {
+	return &resourceTransformInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
@@ -33,3 +33,35 @@ type ClusterControllerListerExpansion interface{}
 // ClusterControllerNamespaceListerExpansion allows custom methods to be added to
 // ClusterControllerNamespaceLister.
 type ClusterControllerNamespaceListerExpansion interface{}
+
+// ClusterInventoryListerExpansion allows custom methods to be added to
+// ClusterInventoryLister.
+type ClusterInventoryListerExpansion interface{}
+
+// ClusterInventoryNamespaceListerExpansion allows custom methods to be added to
+// ClusterInventoryNamespaceLister.
+type ClusterInventoryNamespaceListerExpansion interface{}
+
+// ClusterQuotaListerExpansion allows custom methods to be added to
+// ClusterQuotaLister.
+type ClusterQuotaListerExpansion interface{}
+
+// ClusterQuotaNamespaceListerExpansion allows custom methods to be added to
+// ClusterQuotaNamespaceLister.
+type ClusterQuotaNamespaceListerExpansion interface{}
+
+// CronClusterTaskListerExpansion allows custom methods to be added to
+// CronClusterTaskLister.
+type CronClusterTaskListerExpansion interface{}
+
+// CronClusterTaskNamespaceListerExpansion allows custom methods to be added to
+// CronClusterTaskNamespaceLister.
+type CronClusterTaskNamespaceListerExpansion interface{}
+
+// ResourceTransformListerExpansion allows custom methods to be added to
+// ResourceTransformLister.
+type ResourceTransformListerExpansion interface{}
+
+// ResourceTransformNamespaceListerExpansion allows custom methods to be added to
+// ResourceTransformNamespaceLister.
+type ResourceTransformNamespaceListerExpansion interface{}
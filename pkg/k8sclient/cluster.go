@@ -33,11 +33,14 @@ import (
 // ClusterCRD manipulates Cluster crd.
 type ClusterCRD struct {
 	client oteclient.Interface
+	// AdjustClockSkew converts timestamps in status patched to the clock of root
+	// by clock skew of the cluster.
+	AdjustClockSkew bool
 }
 
 // NewClusterCRD new a ClusterCRD with k8s client.
 func NewClusterCRD(client oteclient.Interface) *ClusterCRD {
-	return &ClusterCRD{client}
+	return &ClusterCRD{client: client}
 }
 
 // Get get a Cluster by namespace and name.
@@ -103,12 +106,37 @@ func (c *ClusterCRD) PatchStatus(newcluster *otev1.Cluster) error {
 			newcluster.Namespace, newcluster.Name, err)
 	}
 
+	// timestamp reported is in the clock of the cluster.
+	if c.AdjustClockSkew && oldcluster.Status.ClockSkew != 0 {
+		newcluster = newcluster.DeepCopy()
+		newcluster.Status.Timestamp -= oldcluster.Status.ClockSkew / 1000
+	}
 	if !updateClusterIsValid(newcluster, oldcluster) {
 		return fmt.Errorf("check newcluster %s failed", newcluster.Name)
 	}
 
 	update := oldcluster.DeepCopy()
 	update.Status = newcluster.Status
+	// version, protocol and capabilities are set on regist, and not reported in status.
+	if update.Status.Version == "" {
+		update.Status.Version = oldcluster.Status.Version
+		update.Status.Protocol = oldcluster.Status.Protocol
+	}
+	if update.Status.Capabilities == nil {
+		update.Status.Capabilities = oldcluster.Status.Capabilities
+	}
+	// health is computed by ote controller manager, and not reported.
+	if update.Status.Health == nil {
+		update.Status.Health = oldcluster.Status.Health
+	}
+	// conditions not reported are kept, e.g., ClockSkewed set by root.
+	if len(newcluster.Status.Conditions) != 0 {
+		merged := otev1.ClusterStatus{Conditions: oldcluster.DeepCopy().Status.Conditions}
+		for _, cond := range newcluster.Status.Conditions {
+			merged.SetCondition(cond)
+		}
+		update.Status.Conditions = merged.Conditions
+	}
 	patchBytes, err := getPatchBytes(oldcluster, update)
 
 	if err != nil {
@@ -137,6 +165,12 @@ func getPatchBytes(oldcluster, newcluster *otev1.Cluster) ([]byte, error) {
 	return patchBytes, nil
 }
 
+// updateClusterIsValid checks if status of newcluster is after the one of oldcluster,
+// by hybrid logical clocks so that skew of clocks does not matter, or by timestamps
+// if either is not reported with hybrid time.
 func updateClusterIsValid(newcluster, oldcluster *otev1.Cluster) bool {
+	if newcluster.Status.HLC != nil && oldcluster.Status.HLC != nil {
+		return oldcluster.Status.HLC.Before(*newcluster.Status.HLC)
+	}
 	return newcluster.Status.Timestamp > oldcluster.Status.Timestamp
 }
@@ -17,6 +17,8 @@ limitations under the License.
 package k8sclient
 
 import (
+	"fmt"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/klog"
 
@@ -51,3 +53,12 @@ func (c *ClusterControllerCRD) Update(cc *otev1.ClusterController) {
 		klog.Errorf("update clustercontroller(%v) failed: %v", cc, err)
 	}
 }
+
+// Create create a ClusterController.
+func (c *ClusterControllerCRD) Create(cc *otev1.ClusterController) error {
+	_, err := c.client.OteV1().ClusterControllers(cc.ObjectMeta.Namespace).Create(cc)
+	if err != nil {
+		return fmt.Errorf("create clustercontroller(%s-%s) failed: %v", cc.ObjectMeta.Namespace, cc.ObjectMeta.Name, err)
+	}
+	return nil
+}
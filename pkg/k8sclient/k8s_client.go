@@ -82,6 +82,11 @@ func NewK8sClient(k8sOption K8sOption) (kubernetes.Interface, error) {
 	return clientset, nil
 }
 
+// GetRestConfig returns the rest config of k8s apiserver by k8s config file.
+func GetRestConfig(kubeConfig string) (*rest.Config, error) {
+	return getRestConfigFromKubeConfigFile(kubeConfig)
+}
+
 func getRestConfigFromKubeConfigFile(kubeConfig string) (*rest.Config, error) {
 	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
 	loadingRules.DefaultClientConfig = &clientcmd.DefaultClientConfig
@@ -120,6 +120,79 @@ func TestClusterCRD(t *testing.T) {
 	assert.Equal(t, set.Status.ParentName, o.Status.ParentName)
 	assert.Equal(t, set.Status.Capacity[corev1.ResourceCPU].Value(), o.Status.Capacity[corev1.ResourceCPU].Value())
 	assert.Equal(t, patchset.Status.Timestamp, o.Status.Timestamp)
+
+	// timestamp of a cluster 10s ahead of root is adjusted.
+	o.Status.ClockSkew = 10000
+	err = clusterCRD.UpdateStatus(o)
+	assert.NotNil(t, err)
+	o.Status.Timestamp++
+	err = clusterCRD.UpdateStatus(o)
+	assert.Nil(t, err)
+	patchset.Status.Timestamp = 11111120
+	err = clusterCRD.PatchStatus(patchset)
+	assert.Nil(t, err)
+	clusterCRD.AdjustClockSkew = true
+	err = clusterCRD.PatchStatus(patchset)
+	assert.NotNil(t, err)
+	patchset.Status.Timestamp = 11111131
+	err = clusterCRD.PatchStatus(patchset)
+	assert.Nil(t, err)
+	o = clusterCRD.Get(patchset.Namespace, patchset.Name)
+	assert.Equal(t, int64(11111121), o.Status.Timestamp)
+	assert.Equal(t, int64(10000), o.Status.ClockSkew)
+
+	// conditions not reported are kept.
+	o.Status.Timestamp++
+	o.Status.Conditions = []otev1.ClusterCondition{
+		{Type: otev1.ClusterConditionClockSkewed, Status: corev1.ConditionTrue},
+	}
+	err = clusterCRD.UpdateStatus(o)
+	assert.Nil(t, err)
+	patchset.Status.Timestamp = 11111142
+	patchset.Status.Conditions = []otev1.ClusterCondition{
+		{Type: otev1.ClusterConditionConnectivity, Status: corev1.ConditionFalse},
+	}
+	err = clusterCRD.PatchStatus(patchset)
+	assert.Nil(t, err)
+	o = clusterCRD.Get(patchset.Namespace, patchset.Name)
+	assert.Equal(t, 2, len(o.Status.Conditions))
+	assert.Equal(t, corev1.ConditionTrue, o.Status.GetCondition(otev1.ClusterConditionClockSkewed).Status)
+	assert.Equal(t, corev1.ConditionFalse, o.Status.GetCondition(otev1.ClusterConditionConnectivity).Status)
+
+	// health not reported is kept.
+	o.Status.Timestamp++
+	o.Status.Health = &otev1.ClusterHealth{Score: 80}
+	err = clusterCRD.UpdateStatus(o)
+	assert.Nil(t, err)
+	patchset.Status.Timestamp = 11111152
+	err = clusterCRD.PatchStatus(patchset)
+	assert.Nil(t, err)
+	o = clusterCRD.Get(patchset.Namespace, patchset.Name)
+	assert.Equal(t, 80, o.Status.Health.Score)
+
+	// statuses with hybrid times are ordered by them rather than timestamps.
+	clusterCRD.AdjustClockSkew = false
+	patchset.Status.HLC = &otev1.HybridTime{WallTime: 1000, Logical: 1}
+	patchset.Status.Timestamp = 1
+	err = clusterCRD.PatchStatus(patchset)
+	assert.NotNil(t, err)
+	o = clusterCRD.Get(patchset.Namespace, patchset.Name)
+	// a status without hybrid time is ordered by timestamp.
+	o.Status.HLC = &otev1.HybridTime{WallTime: 1000}
+	o.Status.Timestamp++
+	err = clusterCRD.UpdateStatus(o)
+	assert.Nil(t, err)
+	err = clusterCRD.PatchStatus(patchset)
+	assert.Nil(t, err)
+	err = clusterCRD.PatchStatus(patchset)
+	assert.NotNil(t, err)
+	patchset.Status.HLC = &otev1.HybridTime{WallTime: 999, Logical: 5}
+	patchset.Status.Timestamp = 11111199
+	err = clusterCRD.PatchStatus(patchset)
+	assert.NotNil(t, err)
+	o = clusterCRD.Get(patchset.Namespace, patchset.Name)
+	assert.Equal(t, otev1.HybridTime{WallTime: 1000, Logical: 1}, *o.Status.HLC)
+	assert.Equal(t, int64(1), o.Status.Timestamp)
 }
 
 func TestClusterControllerCRD(t *testing.T) {
@@ -140,6 +213,11 @@ func TestClusterControllerCRD(t *testing.T) {
 	clustercontrollerCRD.Update(o)
 	o = clustercontrollerCRD.Get("default", "cc1")
 	assert.Equal(t, "isset", o.Spec.Destination)
+
+	o.Name = "cc2"
+	assert.Nil(t, clustercontrollerCRD.Create(o))
+	assert.NotNil(t, clustercontrollerCRD.Get("default", "cc2"))
+	assert.NotNil(t, clustercontrollerCRD.Create(o))
 }
 
 func TestK8sClient(t *testing.T) {
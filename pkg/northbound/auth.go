@@ -0,0 +1,321 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package northbound
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
+
+	"github.com/baidu/ote-stack/pkg/oidc"
+)
+
+// Default claims of id tokens of users authenticated by oidc.
+const (
+	DefaultUsernameClaim = "sub"
+	DefaultGroupsClaim   = "groups"
+)
+
+// User is a user of northbound api, authenticated by bearer token in metadata authorization.
+type User struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	// Methods are the rpcs allowed, e.g., ListClusters, all if empty.
+	Methods []string `json:"methods,omitempty"`
+	// Destinations are the destinations of tasks allowed to submit, e.g., api, all if empty.
+	Destinations []string `json:"destinations,omitempty"`
+	// Tenants are the names of tenants of a user authenticated by oidc, empty for users of static tokens.
+	Tenants []string `json:"-"`
+
+	// tenants allow the user instead of methods and destinations if not empty.
+	tenants []Tenant
+}
+
+// OIDCConfig is the openid connect provider authenticating users by id tokens, e.g., corporate sso.
+type OIDCConfig struct {
+	IssuerURL string `json:"issuerURL"`
+	ClientID  string `json:"clientID"`
+	// CAFile is the ca to verify the provider by, the system roots if empty.
+	CAFile string `json:"caFile,omitempty"`
+	// UsernameClaim is the claim of the name of users, DefaultUsernameClaim if empty.
+	UsernameClaim string `json:"usernameClaim,omitempty"`
+	// GroupsClaim is the claim of groups of users, DefaultGroupsClaim if empty.
+	GroupsClaim string `json:"groupsClaim,omitempty"`
+}
+
+// Tenant is what users of groups authenticated by oidc are allowed.
+type Tenant struct {
+	Name string `json:"name"`
+	// Groups are the groups of users in the tenant.
+	Groups []string `json:"groups"`
+	// Methods are the rpcs allowed, all if empty.
+	Methods []string `json:"methods,omitempty"`
+	// Destinations are the destinations of tasks allowed to submit, all if empty.
+	Destinations []string `json:"destinations,omitempty"`
+}
+
+// AuthConfig is the users of northbound api.
+type AuthConfig struct {
+	Users []User `json:"users"`
+	// OIDC authenticates users by id tokens besides users of static tokens if set,
+	// who are allowed by the tenants of their groups.
+	OIDC    *OIDCConfig `json:"oidc,omitempty"`
+	Tenants []Tenant    `json:"tenants,omitempty"`
+}
+
+// LoadAuthConfig reads users of northbound api in yaml or json from file.
+func LoadAuthConfig(file string) (*AuthConfig, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read northbound auth config failed: %v", err)
+	}
+	conf := &AuthConfig{}
+	if err := yaml.Unmarshal(data, conf); err != nil {
+		return nil, fmt.Errorf("unmarshal northbound auth config failed: %v", err)
+	}
+	names := make(map[string]bool)
+	for _, u := range conf.Users {
+		if u.Name == "" || u.Token == "" {
+			return nil, fmt.Errorf("user of northbound api has no name or token")
+		}
+		if names[u.Name] {
+			return nil, fmt.Errorf("user %s of northbound api is duplicated", u.Name)
+		}
+		names[u.Name] = true
+	}
+	tenants := make(map[string]bool)
+	for _, tenant := range conf.Tenants {
+		if tenant.Name == "" || len(tenant.Groups) == 0 {
+			return nil, fmt.Errorf("tenant of northbound api has no name or groups")
+		}
+		if tenants[tenant.Name] {
+			return nil, fmt.Errorf("tenant %s of northbound api is duplicated", tenant.Name)
+		}
+		tenants[tenant.Name] = true
+	}
+	if conf.OIDC != nil && len(conf.Tenants) == 0 {
+		return nil, fmt.Errorf("tenants are required by oidc of northbound api")
+	}
+	return conf, nil
+}
+
+func allowed(list []string, s string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowMethod returns true if the user is allowed to call rpc method.
+func (u *User) AllowMethod(method string) bool {
+	if len(u.tenants) == 0 {
+		return allowed(u.Methods, method)
+	}
+	for _, tenant := range u.tenants {
+		if allowed(tenant.Methods, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowDestination returns true if the user is allowed to submit tasks to destination.
+// A user of tenants is allowed by a tenant allowing both SubmitTask and destination.
+func (u *User) AllowDestination(destination string) bool {
+	if len(u.tenants) == 0 {
+		return allowed(u.Destinations, destination)
+	}
+	for _, tenant := range u.tenants {
+		if allowed(tenant.Methods, "SubmitTask") && allowed(tenant.Destinations, destination) {
+			return true
+		}
+	}
+	return false
+}
+
+type userKey struct{}
+
+// userFromContext returns the user authenticated of an rpc.
+func userFromContext(ctx context.Context) *User {
+	u, _ := ctx.Value(userKey{}).(*User)
+	return u
+}
+
+// tokenVerifier verifies id tokens, e.g., oidc.Verifier.
+type tokenVerifier interface {
+	Verify(token string) (oidc.Claims, error)
+}
+
+// authenticator authenticates and authorizes rpcs by users.
+type authenticator struct {
+	users []User
+	// verifier verifies id tokens of users of tenants, nil if oidc is not set.
+	verifier      tokenVerifier
+	usernameClaim string
+	groupsClaim   string
+	tenants       []Tenant
+}
+
+func newAuthenticator(conf *Config) (*authenticator, error) {
+	if conf.Auth == nil || (len(conf.Auth.Users) == 0 && conf.Auth.OIDC == nil) {
+		return nil, fmt.Errorf("users or oidc of northbound api are required")
+	}
+	a := &authenticator{users: conf.Auth.Users, tenants: conf.Auth.Tenants}
+	if c := conf.Auth.OIDC; c != nil {
+		verifier, err := oidc.NewVerifier(oidc.Config{IssuerURL: c.IssuerURL, ClientID: c.ClientID, CAFile: c.CAFile})
+		if err != nil {
+			return nil, err
+		}
+		a.verifier = verifier
+		a.usernameClaim, a.groupsClaim = c.UsernameClaim, c.GroupsClaim
+	}
+	if a.usernameClaim == "" {
+		a.usernameClaim = DefaultUsernameClaim
+	}
+	if a.groupsClaim == "" {
+		a.groupsClaim = DefaultGroupsClaim
+	}
+	return a, nil
+}
+
+// oidcUser returns the user of id token, who is allowed by any tenant of its groups.
+func (a *authenticator) oidcUser(token string) (*User, error) {
+	claims, err := a.verifier.Verify(token)
+	if err != nil {
+		klog.V(1).Infof("bearer token of northbound api is neither static nor a valid id token: %v", err)
+		return nil, status.Error(codes.Unauthenticated, "invalid bearer token")
+	}
+	name := claims.String(a.usernameClaim)
+	if name == "" {
+		return nil, status.Errorf(codes.Unauthenticated, "id token has no claim %s", a.usernameClaim)
+	}
+	groups := claims.Strings(a.groupsClaim)
+	user := &User{Name: name}
+	for _, tenant := range a.tenants {
+		if inGroups(tenant.Groups, groups) {
+			user.Tenants = append(user.Tenants, tenant.Name)
+			user.tenants = append(user.tenants, tenant)
+		}
+	}
+	if len(user.tenants) == 0 {
+		klog.Warningf("user %s of groups %v is not in any tenant of northbound api", name, groups)
+		return nil, status.Errorf(codes.PermissionDenied, "user %s is not in any tenant", name)
+	}
+	return user, nil
+}
+
+func inGroups(tenantGroups, groups []string) bool {
+	for _, g := range groups {
+		for _, tg := range tenantGroups {
+			if g == tg {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// String returns the name of the user, with its tenants if any.
+func (u *User) String() string {
+	if len(u.Tenants) == 0 {
+		return u.Name
+	}
+	return fmt.Sprintf("%s(%s)", u.Name, strings.Join(u.Tenants, ","))
+}
+
+// authorize returns the context with the user of the bearer token in ctx if it is allowed to call method.
+func (a *authenticator) authorize(ctx context.Context, fullMethod string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var authorization string
+	if values := md.Get("authorization"); len(values) != 0 {
+		authorization = values[0]
+	}
+	return a.authorizeToken(ctx, authorization, path.Base(fullMethod))
+}
+
+// authorizeToken returns the context with the user of bearer token in authorization
+// if it is allowed to call method.
+func (a *authenticator) authorizeToken(ctx context.Context, authorization, method string) (context.Context, error) {
+	token := strings.TrimPrefix(authorization, "Bearer ")
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "bearer token is required")
+	}
+	var user *User
+	for i := range a.users {
+		if subtle.ConstantTimeCompare([]byte(a.users[i].Token), []byte(token)) == 1 {
+			user = &a.users[i]
+			break
+		}
+	}
+	if user == nil && a.verifier != nil {
+		var err error
+		if user, err = a.oidcUser(token); err != nil {
+			return nil, err
+		}
+	}
+	if user == nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid bearer token")
+	}
+	if !user.AllowMethod(method) {
+		klog.Warningf("user %s is not allowed to call %s", user, method)
+		return nil, status.Errorf(codes.PermissionDenied, "user %s is not allowed to call %s", user.Name, method)
+	}
+	return context.WithValue(ctx, userKey{}, user), nil
+}
+
+func (a *authenticator) unaryInterceptor(ctx context.Context, req interface{},
+	info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// authorizedStream is a stream with the context of the user authorized.
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authorizedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (a *authenticator) streamInterceptor(srv interface{}, ss grpc.ServerStream,
+	info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authorize(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authorizedStream{ServerStream: ss, ctx: ctx})
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package northbound
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/baidu/ote-stack/pkg/oidc"
+)
+
+// fakeTokenVerifier verifies id tokens by its claims of tokens.
+type fakeTokenVerifier map[string]oidc.Claims
+
+func (f fakeTokenVerifier) Verify(token string) (oidc.Claims, error) {
+	claims, ok := f[token]
+	if !ok {
+		return nil, fmt.Errorf("invalid id token")
+	}
+	return claims, nil
+}
+
+func newOIDCAuthenticator(t *testing.T) *authenticator {
+	a, err := newAuthenticator(&Config{Auth: &AuthConfig{
+		Users: []User{{Name: "admin", Token: "t1"}},
+		OIDC:  &OIDCConfig{IssuerURL: "https://sso.example.com", ClientID: "ote", UsernameClaim: "email"},
+		Tenants: []Tenant{
+			{Name: "viewers", Groups: []string{"dev", "qa"}, Methods: []string{"ListClusters"}},
+			{Name: "ops", Groups: []string{"sre"}, Destinations: []string{"api"}},
+			{Name: "admins", Groups: []string{"root"}},
+		},
+	}})
+	assert.Nil(t, err)
+	a.verifier = fakeTokenVerifier{
+		"dev":     {"email": "dev@example.com", "groups": []interface{}{"dev"}},
+		"sre":     {"email": "sre@example.com", "groups": []interface{}{"qa", "sre"}},
+		"root":    {"email": "root@example.com", "groups": []interface{}{"sre", "root"}},
+		"nogroup": {"email": "guest@example.com"},
+		"noname":  {"sub": "x", "groups": []interface{}{"dev"}},
+	}
+	return a
+}
+
+func TestOIDCAuth(t *testing.T) {
+	a := newOIDCAuthenticator(t)
+	ctx := context.Background()
+	code := func(token, method string) codes.Code {
+		_, err := a.authorizeToken(ctx, "Bearer "+token, method)
+		return status.Code(err)
+	}
+
+	// static tokens are still valid.
+	assert.Equal(t, codes.OK, code("t1", "SubmitTask"))
+	assert.Equal(t, codes.Unauthenticated, code("invalid", "ListClusters"))
+	assert.Equal(t, codes.Unauthenticated, code("noname", "ListClusters"))
+	assert.Equal(t, codes.PermissionDenied, code("nogroup", "ListClusters"))
+
+	assert.Equal(t, codes.OK, code("dev", "ListClusters"))
+	assert.Equal(t, codes.PermissionDenied, code("dev", "SubmitTask"))
+
+	// allowed by any tenant of groups, but methods and destinations of different tenants are not mixed.
+	userCtx, err := a.authorizeToken(ctx, "Bearer sre", "SubmitTask")
+	assert.Nil(t, err)
+	user := userFromContext(userCtx)
+	assert.Equal(t, "sre@example.com", user.Name)
+	assert.Equal(t, []string{"viewers", "ops"}, user.Tenants)
+	assert.Equal(t, "sre@example.com(viewers,ops)", user.String())
+	assert.True(t, user.AllowMethod("ListClusters"))
+	assert.True(t, user.AllowDestination("api"))
+	assert.False(t, user.AllowDestination("exec"))
+
+	userCtx, err = a.authorizeToken(ctx, "Bearer root", "SubmitTask")
+	assert.Nil(t, err)
+	user = userFromContext(userCtx)
+	assert.Equal(t, []string{"ops", "admins"}, user.Tenants)
+	assert.True(t, user.AllowDestination("exec"))
+}
+
+func TestNewOIDCAuthenticator(t *testing.T) {
+	a, err := newAuthenticator(&Config{Auth: &AuthConfig{
+		OIDC:    &OIDCConfig{IssuerURL: "https://sso.example.com", ClientID: "ote"},
+		Tenants: []Tenant{{Name: "admins", Groups: []string{"root"}}},
+	}})
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultUsernameClaim, a.usernameClaim)
+	assert.Equal(t, DefaultGroupsClaim, a.groupsClaim)
+
+	_, err = newAuthenticator(&Config{Auth: &AuthConfig{OIDC: &OIDCConfig{IssuerURL: "https://sso.example.com"}}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,268 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package northbound
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/client"
+	pb "github.com/baidu/ote-stack/pkg/northbound/v1"
+)
+
+const (
+	// GatewayPrefix is the path prefix of rest api of the gateway.
+	GatewayPrefix = "/v1/"
+	// OpenAPIPath is the path of the OpenAPI spec of the gateway, served without authentication.
+	OpenAPIPath = "/v1/openapi.json"
+)
+
+/*
+gateway serves the northbound api in rest, mirroring the gRPC service as grpc-gateway does:
+
+	POST /v1/tasks                   SubmitTask, body is Task
+	GET  /v1/tasks/{name}/results    WatchResults, query Clusters and TimeoutSeconds
+	GET  /v1/topology                GetTopology
+	GET  /v1/clusters                ListClusters, query Selector
+
+Messages are in proto3 json with field names of the proto. Rpcs are called in process
+with the same authentication and authorization of the gRPC service by header Authorization.
+*/
+type gateway struct {
+	server    *Server
+	auth      *authenticator
+	marshaler *jsonpb.Marshaler
+}
+
+func newGateway(conf *Config, cl *client.Client) (*gateway, error) {
+	auth, err := newAuthenticator(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &gateway{
+		server:    NewServer(cl, conf.RootName),
+		auth:      auth,
+		marshaler: &jsonpb.Marshaler{OrigName: true},
+	}, nil
+}
+
+// startGateway serves the gateway on conf.GatewayListenAddr in background.
+func startGateway(conf *Config, cl *client.Client) error {
+	g, err := newGateway(conf, cl)
+	if err != nil {
+		return err
+	}
+	lis, err := net.Listen("tcp", conf.GatewayListenAddr)
+	if err != nil {
+		return fmt.Errorf("listen northbound gateway on %s failed: %v", conf.GatewayListenAddr, err)
+	}
+	s := &http.Server{Handler: g}
+	go func() {
+		klog.Infof("serve northbound gateway on %s", conf.GatewayListenAddr)
+		var err error
+		if conf.TLSCertFile != "" || conf.TLSKeyFile != "" {
+			err = s.ServeTLS(lis, conf.TLSCertFile, conf.TLSKeyFile)
+		} else {
+			err = s.Serve(lis)
+		}
+		klog.Errorf("northbound gateway stopped: %v", err)
+	}()
+	return nil
+}
+
+func (g *gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && r.URL.Path == OpenAPIPath {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(openAPISpec))
+		return
+	}
+
+	route := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, GatewayPrefix), "/"), "/")
+	switch {
+	case r.Method == http.MethodPost && len(route) == 1 && route[0] == "tasks":
+		g.submitTask(w, r)
+	case r.Method == http.MethodGet && len(route) == 3 && route[0] == "tasks" && route[2] == "results":
+		g.watchResults(w, r, route[1])
+	case r.Method == http.MethodGet && len(route) == 1 && route[0] == "topology":
+		g.call(w, r, "GetTopology", func(ctx context.Context) (proto.Message, error) {
+			return g.server.GetTopology(ctx, &pb.GetTopologyRequest{})
+		})
+	case r.Method == http.MethodGet && len(route) == 1 && route[0] == "clusters":
+		req := &pb.ListClustersRequest{Selector: r.URL.Query().Get("Selector")}
+		g.call(w, r, "ListClusters", func(ctx context.Context) (proto.Message, error) {
+			return g.server.ListClusters(ctx, req)
+		})
+	default:
+		g.writeError(w, status.Errorf(codes.NotFound, "%s %s is not found", r.Method, r.URL.Path))
+	}
+}
+
+func (g *gateway) submitTask(w http.ResponseWriter, r *http.Request) {
+	task := &pb.Task{}
+	if err := jsonpb.Unmarshal(r.Body, task); err != nil {
+		g.writeError(w, status.Errorf(codes.InvalidArgument, "invalid task: %v", err))
+		return
+	}
+	g.call(w, r, "SubmitTask", func(ctx context.Context) (proto.Message, error) {
+		return g.server.SubmitTask(ctx, &pb.SubmitTaskRequest{Task: task})
+	})
+}
+
+// call calls the unary rpc by user authorized, and writes the response.
+func (g *gateway) call(w http.ResponseWriter, r *http.Request, method string,
+	rpc func(ctx context.Context) (proto.Message, error)) {
+	ctx, err := g.auth.authorizeToken(r.Context(), r.Header.Get("Authorization"), method)
+	if err != nil {
+		g.writeError(w, err)
+		return
+	}
+	resp, err := rpc(ctx)
+	if err != nil {
+		g.writeError(w, err)
+		return
+	}
+	body, err := g.marshaler.MarshalToString(resp)
+	if err != nil {
+		g.writeError(w, status.Error(codes.Internal, err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(body))
+}
+
+// watchResults streams results of task name in newline delimited json,
+// each line is {"result": TaskResult}, or {"error": Status} if the watch failed after started.
+func (g *gateway) watchResults(w http.ResponseWriter, r *http.Request, name string) {
+	ctx, err := g.auth.authorizeToken(r.Context(), r.Header.Get("Authorization"), "WatchResults")
+	if err != nil {
+		g.writeError(w, err)
+		return
+	}
+	query := r.URL.Query()
+	req := &pb.WatchResultsRequest{Name: name, Clusters: query["Clusters"]}
+	if timeout := query.Get("TimeoutSeconds"); timeout != "" {
+		if req.TimeoutSeconds, err = strconv.ParseInt(timeout, 10, 64); err != nil {
+			g.writeError(w, status.Errorf(codes.InvalidArgument, "invalid TimeoutSeconds %s", timeout))
+			return
+		}
+	}
+	stream := &resultStream{ctx: ctx, w: w, marshaler: g.marshaler}
+	err = g.server.WatchResults(req, stream)
+	if err == nil {
+		if !stream.started {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		return
+	}
+	if !stream.started {
+		g.writeError(w, err)
+		return
+	}
+	klog.Warningf("watch results of %s by gateway failed: %v", name, err)
+	w.Write([]byte(fmt.Sprintf("{\"error\":%s}\n", statusJSON(err))))
+}
+
+// writeError writes err as rpc status with the http status of its code.
+func (g *gateway) writeError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatusFromCode(status.Code(err)))
+	w.Write([]byte(statusJSON(err)))
+}
+
+// statusJSON returns the rpc status of err in json, as {"error": message, "code": code, "message": message}.
+func statusJSON(err error) string {
+	s := status.Convert(err)
+	message := strconv.Quote(s.Message())
+	return fmt.Sprintf("{\"error\":%s,\"code\":%d,\"message\":%s}", message, s.Code(), message)
+}
+
+// resultStream writes results streamed by WatchResults to a http response.
+type resultStream struct {
+	// ServerStream is not set, only Context and Send are called by WatchResults.
+	grpc.ServerStream
+	ctx       context.Context
+	w         http.ResponseWriter
+	marshaler *jsonpb.Marshaler
+	started   bool
+}
+
+func (s *resultStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *resultStream) Send(r *pb.TaskResult) error {
+	if !s.started {
+		s.w.Header().Set("Content-Type", "application/json")
+		s.started = true
+	}
+	buf := bytes.NewBufferString("{\"result\":")
+	if err := s.marshaler.Marshal(buf, r); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	buf.WriteString("}\n")
+	if _, err := s.w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// httpStatusFromCode returns the http status of rpc code as grpc-gateway does.
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return http.StatusRequestTimeout
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package northbound
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/client"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned"
+	otefake "github.com/baidu/ote-stack/pkg/k8sclient/fake"
+)
+
+func startGatewayServer(t *testing.T) (*httptest.Server, oteclient.Interface) {
+	oteClient := otefake.NewSimpleClientset(
+		newCluster("c1", "root", otev1.ClusterStatusOnline),
+		newCluster("c2", "c1", otev1.ClusterStatusOnline))
+	cl := client.NewForClientset(oteClient)
+	cl.PollInterval = 10 * time.Millisecond
+	g, err := newGateway(&Config{
+		Auth: &AuthConfig{Users: []User{
+			{Name: "admin", Token: "t1"},
+			{Name: "viewer", Token: "t2", Methods: []string{"ListClusters", "GetTopology"}},
+		}},
+		RootName: "root",
+	}, cl)
+	assert.Nil(t, err)
+	return httptest.NewServer(g), oteClient
+}
+
+func doRequest(t *testing.T, method, url, token, body string) (int, string) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	assert.Nil(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	return resp.StatusCode, string(data)
+}
+
+func TestGatewayAuth(t *testing.T) {
+	s, _ := startGatewayServer(t)
+	defer s.Close()
+
+	code, body := doRequest(t, http.MethodGet, s.URL+"/v1/clusters", "", "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Contains(t, body, "bearer token is required")
+	code, _ = doRequest(t, http.MethodGet, s.URL+"/v1/clusters", "invalid", "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = doRequest(t, http.MethodPost, s.URL+"/v1/tasks", "t2", `{"Selector": "c1", "Destination": "api"}`)
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = doRequest(t, http.MethodGet, s.URL+"/v1/tasks/t/results", "t2", "")
+	assert.Equal(t, http.StatusForbidden, code)
+
+	code, _ = doRequest(t, http.MethodGet, s.URL+"/v1/notexist", "t1", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, body = doRequest(t, http.MethodGet, s.URL+OpenAPIPath, "", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, json.Valid([]byte(body)))
+}
+
+func TestGatewaySubmitAndWatch(t *testing.T) {
+	s, oteClient := startGatewayServer(t)
+	defer s.Close()
+
+	code, _ := doRequest(t, http.MethodPost, s.URL+"/v1/tasks", "t1", "{")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = doRequest(t, http.MethodPost, s.URL+"/v1/tasks", "t1", `{"Selector": "c1"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, body := doRequest(t, http.MethodPost, s.URL+"/v1/tasks", "t1",
+		`{"Name": "task1", "Selector": "c.*", "Destination": "api", "Method": "GET", "URI": "/api/v1/pods"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"Name": "task1", "Clusters": ["c1", "c2"]}`, body)
+
+	ccClient := oteClient.OteV1().ClusterControllers(otev1.ClusterNamespace)
+	cc, err := ccClient.Get("task1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	cc.Status = map[string]otev1.ClusterControllerStatus{
+		"c1": {StatusCode: http.StatusOK, Body: "ok", Timestamp: 1},
+		"c2": {StatusCode: http.StatusNotFound, Body: "not found", Timestamp: 2},
+	}
+	_, err = ccClient.Update(cc)
+	assert.Nil(t, err)
+
+	code, _ = doRequest(t, http.MethodGet, s.URL+"/v1/tasks/task1/results?TimeoutSeconds=x", "t1", "")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, body = doRequest(t, http.MethodGet,
+		s.URL+"/v1/tasks/task1/results?Clusters=c1&Clusters=c2&TimeoutSeconds=5", "t1", "")
+	assert.Equal(t, http.StatusOK, code)
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	assert.Len(t, lines, 2)
+	assert.JSONEq(t, `{"result": {"Cluster": "c1", "StatusCode": 200, "Body": "ok", "Timestamp": "1"}}`, lines[0])
+	assert.JSONEq(t, `{"result": {"Cluster": "c2", "StatusCode": 404, "Body": "not found", "Timestamp": "2"}}`, lines[1])
+}
+
+func TestGatewayTopologyAndClusters(t *testing.T) {
+	s, _ := startGatewayServer(t)
+	defer s.Close()
+
+	code, body := doRequest(t, http.MethodGet, s.URL+"/v1/topology", "t2", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"Root": {"Name": "root", "Children": [
+		{"Name": "c1", "Status": "online", "Children": [{"Name": "c2", "Status": "online"}]}]}}`, body)
+
+	code, body = doRequest(t, http.MethodGet, s.URL+"/v1/clusters?Selector=c2", "t2", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"Clusters": [{"Name": "c2", "Parent": "c1", "Status": "online"}]}`, body)
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package northbound
+
+// openAPISpec is the OpenAPI spec of the rest api of the gateway, keep it in sync with v1/northbound.proto.
+const openAPISpec = `{
+  "swagger": "2.0",
+  "info": {
+    "title": "ote northbound api",
+    "version": "v1"
+  },
+  "basePath": "/v1",
+  "consumes": ["application/json"],
+  "produces": ["application/json"],
+  "securityDefinitions": {
+    "bearer": {
+      "type": "apiKey",
+      "name": "Authorization",
+      "in": "header",
+      "description": "Bearer token of a user of northbound api, e.g., Bearer 9f1b2c7e0d"
+    }
+  },
+  "security": [{"bearer": []}],
+  "paths": {
+    "/tasks": {
+      "post": {
+        "summary": "SubmitTask creates a task for clusters selected.",
+        "operationId": "SubmitTask",
+        "parameters": [
+          {"name": "body", "in": "body", "required": true, "schema": {"$ref": "#/definitions/Task"}}
+        ],
+        "responses": {
+          "200": {"description": "task submitted", "schema": {"$ref": "#/definitions/SubmitTaskResponse"}},
+          "default": {"description": "error", "schema": {"$ref": "#/definitions/Status"}}
+        }
+      }
+    },
+    "/tasks/{name}/results": {
+      "get": {
+        "summary": "WatchResults streams results of a task by cluster as they arrive, in newline delimited json.",
+        "operationId": "WatchResults",
+        "parameters": [
+          {"name": "name", "in": "path", "required": true, "type": "string"},
+          {"name": "Clusters", "in": "query", "type": "array", "items": {"type": "string"}, "collectionFormat": "multi",
+            "description": "Clusters to wait for, as returned by SubmitTask, results are streamed until timeout if empty."},
+          {"name": "TimeoutSeconds", "in": "query", "type": "string", "format": "int64",
+            "description": "Time to wait for results, 600 if not set."}
+        ],
+        "responses": {
+          "200": {
+            "description": "stream of results, each line is a result or an error.",
+            "schema": {
+              "type": "object",
+              "properties": {
+                "result": {"$ref": "#/definitions/TaskResult"},
+                "error": {"$ref": "#/definitions/Status"}
+              }
+            }
+          },
+          "default": {"description": "error", "schema": {"$ref": "#/definitions/Status"}}
+        }
+      }
+    },
+    "/topology": {
+      "get": {
+        "summary": "GetTopology returns the tree of clusters.",
+        "operationId": "GetTopology",
+        "responses": {
+          "200": {"description": "topology of clusters", "schema": {"$ref": "#/definitions/Topology"}},
+          "default": {"description": "error", "schema": {"$ref": "#/definitions/Status"}}
+        }
+      }
+    },
+    "/clusters": {
+      "get": {
+        "summary": "ListClusters returns clusters selected.",
+        "operationId": "ListClusters",
+        "parameters": [
+          {"name": "Selector", "in": "query", "type": "string",
+            "description": "Selects clusters in rules of cluster selector, all clusters if empty."}
+        ],
+        "responses": {
+          "200": {"description": "clusters selected", "schema": {"$ref": "#/definitions/ListClustersResponse"}},
+          "default": {"description": "error", "schema": {"$ref": "#/definitions/Status"}}
+        }
+      }
+    }
+  },
+  "definitions": {
+    "Task": {
+      "type": "object",
+      "properties": {
+        "Name": {"type": "string", "description": "Name of the task, generated if empty."},
+        "Selector": {"type": "string"},
+        "Destination": {"type": "string"},
+        "Method": {"type": "string"},
+        "URI": {"type": "string"},
+        "Body": {"type": "string"},
+        "IdempotencyKey": {"type": "string"}
+      }
+    },
+    "SubmitTaskResponse": {
+      "type": "object",
+      "properties": {
+        "Name": {"type": "string"},
+        "Clusters": {"type": "array", "items": {"type": "string"},
+          "description": "Online clusters selected, results are done when all of them responded."}
+      }
+    },
+    "TaskResult": {
+      "type": "object",
+      "properties": {
+        "Cluster": {"type": "string"},
+        "StatusCode": {"type": "integer", "format": "int32"},
+        "Body": {"type": "string"},
+        "Reason": {"type": "string", "description": "Set if the result is not responded by the cluster, e.g., TimedOut."},
+        "Timestamp": {"type": "string", "format": "int64"}
+      }
+    },
+    "ClusterNode": {
+      "type": "object",
+      "properties": {
+        "Name": {"type": "string"},
+        "Status": {"type": "string"},
+        "Children": {"type": "array", "items": {"$ref": "#/definitions/ClusterNode"}}
+      }
+    },
+    "Topology": {
+      "type": "object",
+      "properties": {
+        "Root": {"$ref": "#/definitions/ClusterNode"},
+        "Detached": {"type": "array", "items": {"$ref": "#/definitions/ClusterNode"},
+          "description": "Clusters whose parents are not registered."}
+      }
+    },
+    "Cluster": {
+      "type": "object",
+      "properties": {
+        "Name": {"type": "string"},
+        "Parent": {"type": "string"},
+        "Status": {"type": "string"},
+        "Version": {"type": "string"},
+        "Timestamp": {"type": "string", "format": "int64"}
+      }
+    },
+    "ListClustersResponse": {
+      "type": "object",
+      "properties": {
+        "Clusters": {"type": "array", "items": {"$ref": "#/definitions/Cluster"}}
+      }
+    },
+    "Status": {
+      "type": "object",
+      "properties": {
+        "error": {"type": "string"},
+        "code": {"type": "integer", "format": "int32", "description": "gRPC status code."},
+        "message": {"type": "string"}
+      }
+    }
+  }
+}
+`
@@ -0,0 +1,240 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package northbound serves the gRPC api of root for programs to submit tasks,
+// watch results of tasks and get the topology of clusters, as an alternative to ClusterController crds.
+package northbound
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/client"
+	"github.com/baidu/ote-stack/pkg/clusterselector"
+	pb "github.com/baidu/ote-stack/pkg/northbound/v1"
+)
+
+// DefaultWatchTimeout is the time to watch results of a task if not set in request.
+const DefaultWatchTimeout = 10 * time.Minute
+
+// Config is the config of northbound api server.
+type Config struct {
+	// ListenAddr is the address to serve northbound api in gRPC, not served if empty.
+	ListenAddr string
+	// GatewayListenAddr is the address to serve northbound api in rest, not served if empty.
+	GatewayListenAddr string
+	// Auth is the users allowed to call northbound api.
+	Auth *AuthConfig
+	// TLSCertFile and TLSKeyFile serve northbound api in tls if set.
+	TLSCertFile string
+	TLSKeyFile  string
+	// RootName is the name of root cluster.
+	RootName string
+}
+
+// Server implements the northbound api by client of ClusterController crds.
+type Server struct {
+	client   *client.Client
+	rootName string
+}
+
+// NewServer returns a northbound api server using cl.
+func NewServer(cl *client.Client, rootName string) *Server {
+	return &Server{client: cl, rootName: rootName}
+}
+
+// Start serves northbound api by conf in gRPC and rest in background.
+func Start(conf *Config, cl *client.Client) error {
+	if conf.ListenAddr != "" {
+		if err := startGRPCServer(conf, cl); err != nil {
+			return err
+		}
+	}
+	if conf.GatewayListenAddr != "" {
+		return startGateway(conf, cl)
+	}
+	return nil
+}
+
+func startGRPCServer(conf *Config, cl *client.Client) error {
+	s, err := newGRPCServer(conf, cl)
+	if err != nil {
+		return err
+	}
+	lis, err := net.Listen("tcp", conf.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("listen northbound api on %s failed: %v", conf.ListenAddr, err)
+	}
+	go func() {
+		klog.Infof("serve northbound api on %s", conf.ListenAddr)
+		if err := s.Serve(lis); err != nil {
+			klog.Errorf("northbound api server stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+func newGRPCServer(conf *Config, cl *client.Client) (*grpc.Server, error) {
+	auth, err := newAuthenticator(conf)
+	if err != nil {
+		return nil, err
+	}
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(auth.unaryInterceptor),
+		grpc.StreamInterceptor(auth.streamInterceptor),
+	}
+	if conf.TLSCertFile != "" || conf.TLSKeyFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(conf.TLSCertFile, conf.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load tls cert of northbound api failed: %v", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	s := grpc.NewServer(opts...)
+	pb.RegisterNorthboundServer(s, NewServer(cl, conf.RootName))
+	return s, nil
+}
+
+// SubmitTask creates a task for clusters selected.
+func (s *Server) SubmitTask(ctx context.Context, req *pb.SubmitTaskRequest) (*pb.SubmitTaskResponse, error) {
+	task := req.GetTask()
+	if task == nil {
+		return nil, status.Error(codes.InvalidArgument, "task is required")
+	}
+	if user := userFromContext(ctx); user != nil && !user.AllowDestination(task.Destination) {
+		return nil, status.Errorf(codes.PermissionDenied,
+			"user %s is not allowed to submit tasks to %s", user.Name, task.Destination)
+	}
+	if task.Selector == "" || task.Destination == "" {
+		return nil, status.Error(codes.InvalidArgument, "selector and destination of task are required")
+	}
+	ref, err := s.client.Submit(&client.Task{
+		Name:           task.Name,
+		Selector:       task.Selector,
+		Destination:    task.Destination,
+		Method:         task.Method,
+		URI:            task.URI,
+		Body:           task.Body,
+		IdempotencyKey: task.IdempotencyKey,
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if user := userFromContext(ctx); user != nil {
+		klog.Infof("user %s submitted task %s to %s", user, ref.Name, task.Selector)
+	}
+	return &pb.SubmitTaskResponse{Name: ref.Name, Clusters: ref.Clusters}, nil
+}
+
+// WatchResults streams results of a task until all clusters in request have results,
+// or timeout. It ends at once if the task does not exist.
+func (s *Server) WatchResults(req *pb.WatchResultsRequest, stream pb.Northbound_WatchResultsServer) error {
+	if req.Name == "" {
+		return status.Error(codes.InvalidArgument, "name of task is required")
+	}
+	timeout := DefaultWatchTimeout
+	if req.TimeoutSeconds > 0 {
+		timeout = time.Duration(req.TimeoutSeconds) * time.Second
+	}
+	ref := &client.TaskRef{Name: req.Name, Clusters: req.Clusters}
+	for r := range s.client.Results(ref, timeout, stream.Context().Done()) {
+		if err := stream.Send(&pb.TaskResult{
+			Cluster:    r.Cluster,
+			StatusCode: int32(r.StatusCode),
+			Body:       r.Body,
+			Reason:     r.Reason,
+			Timestamp:  r.Timestamp.Unix(),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetTopology returns the tree of clusters by their parents.
+func (s *Server) GetTopology(ctx context.Context, req *pb.GetTopologyRequest) (*pb.Topology, error) {
+	clusters, err := s.client.Clusters()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return buildTopology(s.rootName, clusters), nil
+}
+
+func buildTopology(rootName string, clusters []client.Cluster) *pb.Topology {
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].Name < clusters[j].Name
+	})
+	root := &pb.ClusterNode{Name: rootName}
+	nodes := map[string]*pb.ClusterNode{rootName: root}
+	for _, c := range clusters {
+		if c.Name == rootName {
+			root.Status = c.Status
+			continue
+		}
+		nodes[c.Name] = &pb.ClusterNode{Name: c.Name, Status: c.Status}
+	}
+	topology := &pb.Topology{Root: root}
+	for _, c := range clusters {
+		if c.Name == rootName {
+			continue
+		}
+		parent, ok := nodes[c.Parent]
+		if !ok || c.Parent == c.Name {
+			topology.Detached = append(topology.Detached, nodes[c.Name])
+			continue
+		}
+		parent.Children = append(parent.Children, nodes[c.Name])
+	}
+	return topology
+}
+
+// ListClusters returns clusters selected, sorted by name.
+func (s *Server) ListClusters(ctx context.Context, req *pb.ListClustersRequest) (*pb.ListClustersResponse, error) {
+	clusters, err := s.client.Clusters()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	var selector clusterselector.Selector
+	if req.Selector != "" {
+		selector = clusterselector.NewSelector(req.Selector)
+	}
+	resp := &pb.ListClustersResponse{}
+	for _, c := range clusters {
+		if selector != nil && !selector.Has(c.Name) {
+			continue
+		}
+		resp.Clusters = append(resp.Clusters, &pb.Cluster{
+			Name:      c.Name,
+			Parent:    c.Parent,
+			Status:    c.Status,
+			Version:   c.Version,
+			Timestamp: c.Timestamp.Unix(),
+		})
+	}
+	sort.Slice(resp.Clusters, func(i, j int) bool {
+		return resp.Clusters[i].Name < resp.Clusters[j].Name
+	})
+	return resp, nil
+}
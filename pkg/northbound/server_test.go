@@ -0,0 +1,224 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package northbound
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/client"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned"
+	otefake "github.com/baidu/ote-stack/pkg/k8sclient/fake"
+	pb "github.com/baidu/ote-stack/pkg/northbound/v1"
+)
+
+func newCluster(name, parent, status string) *otev1.Cluster {
+	return &otev1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: otev1.ClusterNamespace},
+		Status:     otev1.ClusterStatus{Status: status, ParentName: parent},
+	}
+}
+
+// startServer serves northbound api with fake clusters and returns a client of it.
+func startServer(t *testing.T) (pb.NorthboundClient, oteclient.Interface, func()) {
+	oteClient := otefake.NewSimpleClientset(
+		newCluster("c1", "root", otev1.ClusterStatusOnline),
+		newCluster("c2", "c1", otev1.ClusterStatusOnline),
+		newCluster("c3", "lost", otev1.ClusterStatusOffline))
+	cl := client.NewForClientset(oteClient)
+	cl.PollInterval = 10 * time.Millisecond
+	s, err := newGRPCServer(&Config{
+		Auth: &AuthConfig{Users: []User{
+			{Name: "admin", Token: "t1"},
+			{Name: "viewer", Token: "t2", Methods: []string{"ListClusters", "GetTopology"}},
+			{Name: "ops", Token: "t3", Destinations: []string{otev1.ClusterControllerDestAPI}},
+		}},
+		RootName: "root",
+	}, cl)
+	assert.Nil(t, err)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go s.Serve(lis)
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	assert.Nil(t, err)
+	return pb.NewNorthboundClient(conn), oteClient, func() {
+		conn.Close()
+		s.Stop()
+	}
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestAuth(t *testing.T) {
+	c, _, stop := startServer(t)
+	defer stop()
+
+	_, err := c.ListClusters(context.Background(), &pb.ListClustersRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = c.ListClusters(withToken("invalid"), &pb.ListClustersRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = c.ListClusters(withToken("t2"), &pb.ListClustersRequest{})
+	assert.Nil(t, err)
+	task := &pb.Task{Selector: "c1", Destination: otev1.ClusterControllerDestAPI}
+	_, err = c.SubmitTask(withToken("t2"), &pb.SubmitTaskRequest{Task: task})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	stream, err := c.WatchResults(withToken("t2"), &pb.WatchResultsRequest{Name: "t"})
+	assert.Nil(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// destinations allowed.
+	_, err = c.SubmitTask(withToken("t3"), &pb.SubmitTaskRequest{Task: task})
+	assert.Nil(t, err)
+	_, err = c.SubmitTask(withToken("t3"), &pb.SubmitTaskRequest{
+		Task: &pb.Task{Selector: "c1", Destination: otev1.ClusterControllerDestExec},
+	})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestSubmitAndWatch(t *testing.T) {
+	c, oteClient, stop := startServer(t)
+	defer stop()
+	ctx := withToken("t1")
+
+	_, err := c.SubmitTask(ctx, &pb.SubmitTaskRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	resp, err := c.SubmitTask(ctx, &pb.SubmitTaskRequest{Task: &pb.Task{
+		Name:        "task1",
+		Selector:    "c.*",
+		Destination: otev1.ClusterControllerDestAPI,
+		Method:      http.MethodGet,
+		URI:         "/api/v1/pods",
+	}})
+	assert.Nil(t, err)
+	assert.Equal(t, "task1", resp.Name)
+	assert.Equal(t, []string{"c1", "c2"}, resp.Clusters)
+
+	ccClient := oteClient.OteV1().ClusterControllers(otev1.ClusterNamespace)
+	cc, err := ccClient.Get("task1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "/api/v1/pods", cc.Spec.URL)
+	cc.Status = map[string]otev1.ClusterControllerStatus{
+		"c1": {StatusCode: http.StatusOK, Body: "ok", Timestamp: 1},
+		"c2": {StatusCode: http.StatusGatewayTimeout, Reason: otev1.ClusterControllerStatusTimedOut, Timestamp: 2},
+	}
+	_, err = ccClient.Update(cc)
+	assert.Nil(t, err)
+
+	stream, err := c.WatchResults(ctx, &pb.WatchResultsRequest{Name: resp.Name, Clusters: resp.Clusters, TimeoutSeconds: 5})
+	assert.Nil(t, err)
+	var results []*pb.TaskResult
+	for {
+		r, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		results = append(results, r)
+	}
+	assert.Len(t, results, 2)
+	assert.Equal(t, "c1", results[0].Cluster)
+	assert.Equal(t, int32(http.StatusOK), results[0].StatusCode)
+	assert.Equal(t, otev1.ClusterControllerStatusTimedOut, results[1].Reason)
+}
+
+func TestTopologyAndClusters(t *testing.T) {
+	c, _, stop := startServer(t)
+	defer stop()
+	ctx := withToken("t2")
+
+	topology, err := c.GetTopology(ctx, &pb.GetTopologyRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, "root", topology.Root.Name)
+	assert.Len(t, topology.Root.Children, 1)
+	assert.Equal(t, "c1", topology.Root.Children[0].Name)
+	assert.Equal(t, "c2", topology.Root.Children[0].Children[0].Name)
+	assert.Len(t, topology.Detached, 1)
+	assert.Equal(t, "c3", topology.Detached[0].Name)
+
+	resp, err := c.ListClusters(ctx, &pb.ListClustersRequest{})
+	assert.Nil(t, err)
+	assert.Len(t, resp.Clusters, 3)
+	resp, err = c.ListClusters(ctx, &pb.ListClustersRequest{Selector: "c1,c3"})
+	assert.Nil(t, err)
+	assert.Len(t, resp.Clusters, 2)
+	assert.Equal(t, "c3", resp.Clusters[1].Name)
+	assert.Equal(t, "lost", resp.Clusters[1].Parent)
+}
+
+func TestLoadAuthConfig(t *testing.T) {
+	write := func(content string) string {
+		f, err := ioutil.TempFile("", "northbound")
+		assert.Nil(t, err)
+		f.WriteString(content)
+		f.Close()
+		return f.Name()
+	}
+
+	file := write("users:\n- name: admin\n  token: t1\n- name: viewer\n  token: t2\n  methods: [ListClusters]\n")
+	defer os.Remove(file)
+	conf, err := LoadAuthConfig(file)
+	assert.Nil(t, err)
+	assert.Len(t, conf.Users, 2)
+	assert.True(t, conf.Users[0].AllowMethod("SubmitTask"))
+	assert.False(t, conf.Users[1].AllowMethod("SubmitTask"))
+
+	file = write("oidc:\n  issuerURL: https://sso.example.com\n  clientID: ote\n" +
+		"tenants:\n- name: ops\n  groups: [sre]\n  destinations: [api]\n")
+	defer os.Remove(file)
+	conf, err = LoadAuthConfig(file)
+	assert.Nil(t, err)
+	assert.Equal(t, "ote", conf.OIDC.ClientID)
+	assert.Equal(t, []string{"api"}, conf.Tenants[0].Destinations)
+
+	for _, content := range []string{
+		"users:\n- name: admin\n",
+		"users:\n- name: admin\n  token: t1\n- name: admin\n  token: t2\n",
+		"users: x",
+		"oidc:\n  issuerURL: https://sso.example.com\n  clientID: ote\n",
+		"tenants:\n- name: ops\n",
+		"tenants:\n- name: ops\n  groups: [sre]\n- name: ops\n  groups: [dev]\n",
+	} {
+		file := write(content)
+		defer os.Remove(file)
+		_, err := LoadAuthConfig(file)
+		assert.Error(t, err, content)
+	}
+	_, err = LoadAuthConfig("/not/exist")
+	assert.Error(t, err)
+
+	_, err = newGRPCServer(&Config{}, nil)
+	assert.Error(t, err)
+}
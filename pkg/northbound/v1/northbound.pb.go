@@ -0,0 +1,898 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: northbound.proto
+
+package v1
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+// Task is an operation sent to clusters selected by Selector.
+type Task struct {
+	// Name is the name of the task, generated if empty.
+	Name                 string   `protobuf:"bytes,1,opt,name=Name,proto3" json:"Name,omitempty"`
+	Selector             string   `protobuf:"bytes,2,opt,name=Selector,proto3" json:"Selector,omitempty"`
+	Destination          string   `protobuf:"bytes,3,opt,name=Destination,proto3" json:"Destination,omitempty"`
+	Method               string   `protobuf:"bytes,4,opt,name=Method,proto3" json:"Method,omitempty"`
+	URI                  string   `protobuf:"bytes,5,opt,name=URI,proto3" json:"URI,omitempty"`
+	Body                 string   `protobuf:"bytes,6,opt,name=Body,proto3" json:"Body,omitempty"`
+	IdempotencyKey       string   `protobuf:"bytes,7,opt,name=IdempotencyKey,proto3" json:"IdempotencyKey,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Task) Reset()         { *m = Task{} }
+func (m *Task) String() string { return proto.CompactTextString(m) }
+func (*Task) ProtoMessage()    {}
+func (*Task) Descriptor() ([]byte, []int) {
+	return fileDescriptor_90b042c70967f647, []int{0}
+}
+
+func (m *Task) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Task.Unmarshal(m, b)
+}
+func (m *Task) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Task.Marshal(b, m, deterministic)
+}
+func (m *Task) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Task.Merge(m, src)
+}
+func (m *Task) XXX_Size() int {
+	return xxx_messageInfo_Task.Size(m)
+}
+func (m *Task) XXX_DiscardUnknown() {
+	xxx_messageInfo_Task.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Task proto.InternalMessageInfo
+
+func (m *Task) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Task) GetSelector() string {
+	if m != nil {
+		return m.Selector
+	}
+	return ""
+}
+
+func (m *Task) GetDestination() string {
+	if m != nil {
+		return m.Destination
+	}
+	return ""
+}
+
+func (m *Task) GetMethod() string {
+	if m != nil {
+		return m.Method
+	}
+	return ""
+}
+
+func (m *Task) GetURI() string {
+	if m != nil {
+		return m.URI
+	}
+	return ""
+}
+
+func (m *Task) GetBody() string {
+	if m != nil {
+		return m.Body
+	}
+	return ""
+}
+
+func (m *Task) GetIdempotencyKey() string {
+	if m != nil {
+		return m.IdempotencyKey
+	}
+	return ""
+}
+
+type SubmitTaskRequest struct {
+	Task                 *Task    `protobuf:"bytes,1,opt,name=Task,proto3" json:"Task,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SubmitTaskRequest) Reset()         { *m = SubmitTaskRequest{} }
+func (m *SubmitTaskRequest) String() string { return proto.CompactTextString(m) }
+func (*SubmitTaskRequest) ProtoMessage()    {}
+func (*SubmitTaskRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_90b042c70967f647, []int{1}
+}
+
+func (m *SubmitTaskRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SubmitTaskRequest.Unmarshal(m, b)
+}
+func (m *SubmitTaskRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SubmitTaskRequest.Marshal(b, m, deterministic)
+}
+func (m *SubmitTaskRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SubmitTaskRequest.Merge(m, src)
+}
+func (m *SubmitTaskRequest) XXX_Size() int {
+	return xxx_messageInfo_SubmitTaskRequest.Size(m)
+}
+func (m *SubmitTaskRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SubmitTaskRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SubmitTaskRequest proto.InternalMessageInfo
+
+func (m *SubmitTaskRequest) GetTask() *Task {
+	if m != nil {
+		return m.Task
+	}
+	return nil
+}
+
+type SubmitTaskResponse struct {
+	Name string `protobuf:"bytes,1,opt,name=Name,proto3" json:"Name,omitempty"`
+	// Clusters are online clusters selected, results are done when all of them responded.
+	Clusters             []string `protobuf:"bytes,2,rep,name=Clusters,proto3" json:"Clusters,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SubmitTaskResponse) Reset()         { *m = SubmitTaskResponse{} }
+func (m *SubmitTaskResponse) String() string { return proto.CompactTextString(m) }
+func (*SubmitTaskResponse) ProtoMessage()    {}
+func (*SubmitTaskResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_90b042c70967f647, []int{2}
+}
+
+func (m *SubmitTaskResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SubmitTaskResponse.Unmarshal(m, b)
+}
+func (m *SubmitTaskResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SubmitTaskResponse.Marshal(b, m, deterministic)
+}
+func (m *SubmitTaskResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SubmitTaskResponse.Merge(m, src)
+}
+func (m *SubmitTaskResponse) XXX_Size() int {
+	return xxx_messageInfo_SubmitTaskResponse.Size(m)
+}
+func (m *SubmitTaskResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SubmitTaskResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SubmitTaskResponse proto.InternalMessageInfo
+
+func (m *SubmitTaskResponse) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *SubmitTaskResponse) GetClusters() []string {
+	if m != nil {
+		return m.Clusters
+	}
+	return nil
+}
+
+type WatchResultsRequest struct {
+	Name string `protobuf:"bytes,1,opt,name=Name,proto3" json:"Name,omitempty"`
+	// Clusters are the clusters to wait for, as returned by SubmitTask.
+	// results are streamed until timeout if empty.
+	Clusters []string `protobuf:"bytes,2,rep,name=Clusters,proto3" json:"Clusters,omitempty"`
+	// TimeoutSeconds is the time to wait for results, 600 if not set.
+	TimeoutSeconds       int64    `protobuf:"varint,3,opt,name=TimeoutSeconds,proto3" json:"TimeoutSeconds,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WatchResultsRequest) Reset()         { *m = WatchResultsRequest{} }
+func (m *WatchResultsRequest) String() string { return proto.CompactTextString(m) }
+func (*WatchResultsRequest) ProtoMessage()    {}
+func (*WatchResultsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_90b042c70967f647, []int{3}
+}
+
+func (m *WatchResultsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WatchResultsRequest.Unmarshal(m, b)
+}
+func (m *WatchResultsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WatchResultsRequest.Marshal(b, m, deterministic)
+}
+func (m *WatchResultsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WatchResultsRequest.Merge(m, src)
+}
+func (m *WatchResultsRequest) XXX_Size() int {
+	return xxx_messageInfo_WatchResultsRequest.Size(m)
+}
+func (m *WatchResultsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_WatchResultsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WatchResultsRequest proto.InternalMessageInfo
+
+func (m *WatchResultsRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *WatchResultsRequest) GetClusters() []string {
+	if m != nil {
+		return m.Clusters
+	}
+	return nil
+}
+
+func (m *WatchResultsRequest) GetTimeoutSeconds() int64 {
+	if m != nil {
+		return m.TimeoutSeconds
+	}
+	return 0
+}
+
+// TaskResult is the result of a task in a cluster.
+type TaskResult struct {
+	Cluster    string `protobuf:"bytes,1,opt,name=Cluster,proto3" json:"Cluster,omitempty"`
+	StatusCode int32  `protobuf:"varint,2,opt,name=StatusCode,proto3" json:"StatusCode,omitempty"`
+	Body       string `protobuf:"bytes,3,opt,name=Body,proto3" json:"Body,omitempty"`
+	// Reason is set if the result is not responded by the cluster, e.g., TimedOut.
+	Reason               string   `protobuf:"bytes,4,opt,name=Reason,proto3" json:"Reason,omitempty"`
+	Timestamp            int64    `protobuf:"varint,5,opt,name=Timestamp,proto3" json:"Timestamp,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TaskResult) Reset()         { *m = TaskResult{} }
+func (m *TaskResult) String() string { return proto.CompactTextString(m) }
+func (*TaskResult) ProtoMessage()    {}
+func (*TaskResult) Descriptor() ([]byte, []int) {
+	return fileDescriptor_90b042c70967f647, []int{4}
+}
+
+func (m *TaskResult) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TaskResult.Unmarshal(m, b)
+}
+func (m *TaskResult) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TaskResult.Marshal(b, m, deterministic)
+}
+func (m *TaskResult) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TaskResult.Merge(m, src)
+}
+func (m *TaskResult) XXX_Size() int {
+	return xxx_messageInfo_TaskResult.Size(m)
+}
+func (m *TaskResult) XXX_DiscardUnknown() {
+	xxx_messageInfo_TaskResult.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TaskResult proto.InternalMessageInfo
+
+func (m *TaskResult) GetCluster() string {
+	if m != nil {
+		return m.Cluster
+	}
+	return ""
+}
+
+func (m *TaskResult) GetStatusCode() int32 {
+	if m != nil {
+		return m.StatusCode
+	}
+	return 0
+}
+
+func (m *TaskResult) GetBody() string {
+	if m != nil {
+		return m.Body
+	}
+	return ""
+}
+
+func (m *TaskResult) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+func (m *TaskResult) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+type GetTopologyRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetTopologyRequest) Reset()         { *m = GetTopologyRequest{} }
+func (m *GetTopologyRequest) String() string { return proto.CompactTextString(m) }
+func (*GetTopologyRequest) ProtoMessage()    {}
+func (*GetTopologyRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_90b042c70967f647, []int{5}
+}
+
+func (m *GetTopologyRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetTopologyRequest.Unmarshal(m, b)
+}
+func (m *GetTopologyRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetTopologyRequest.Marshal(b, m, deterministic)
+}
+func (m *GetTopologyRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetTopologyRequest.Merge(m, src)
+}
+func (m *GetTopologyRequest) XXX_Size() int {
+	return xxx_messageInfo_GetTopologyRequest.Size(m)
+}
+func (m *GetTopologyRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetTopologyRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetTopologyRequest proto.InternalMessageInfo
+
+// ClusterNode is a cluster in the tree of clusters.
+type ClusterNode struct {
+	Name                 string         `protobuf:"bytes,1,opt,name=Name,proto3" json:"Name,omitempty"`
+	Status               string         `protobuf:"bytes,2,opt,name=Status,proto3" json:"Status,omitempty"`
+	Children             []*ClusterNode `protobuf:"bytes,3,rep,name=Children,proto3" json:"Children,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *ClusterNode) Reset()         { *m = ClusterNode{} }
+func (m *ClusterNode) String() string { return proto.CompactTextString(m) }
+func (*ClusterNode) ProtoMessage()    {}
+func (*ClusterNode) Descriptor() ([]byte, []int) {
+	return fileDescriptor_90b042c70967f647, []int{6}
+}
+
+func (m *ClusterNode) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ClusterNode.Unmarshal(m, b)
+}
+func (m *ClusterNode) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ClusterNode.Marshal(b, m, deterministic)
+}
+func (m *ClusterNode) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ClusterNode.Merge(m, src)
+}
+func (m *ClusterNode) XXX_Size() int {
+	return xxx_messageInfo_ClusterNode.Size(m)
+}
+func (m *ClusterNode) XXX_DiscardUnknown() {
+	xxx_messageInfo_ClusterNode.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ClusterNode proto.InternalMessageInfo
+
+func (m *ClusterNode) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *ClusterNode) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *ClusterNode) GetChildren() []*ClusterNode {
+	if m != nil {
+		return m.Children
+	}
+	return nil
+}
+
+type Topology struct {
+	Root *ClusterNode `protobuf:"bytes,1,opt,name=Root,proto3" json:"Root,omitempty"`
+	// Detached are the clusters whose parents are not registered.
+	Detached             []*ClusterNode `protobuf:"bytes,2,rep,name=Detached,proto3" json:"Detached,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *Topology) Reset()         { *m = Topology{} }
+func (m *Topology) String() string { return proto.CompactTextString(m) }
+func (*Topology) ProtoMessage()    {}
+func (*Topology) Descriptor() ([]byte, []int) {
+	return fileDescriptor_90b042c70967f647, []int{7}
+}
+
+func (m *Topology) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Topology.Unmarshal(m, b)
+}
+func (m *Topology) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Topology.Marshal(b, m, deterministic)
+}
+func (m *Topology) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Topology.Merge(m, src)
+}
+func (m *Topology) XXX_Size() int {
+	return xxx_messageInfo_Topology.Size(m)
+}
+func (m *Topology) XXX_DiscardUnknown() {
+	xxx_messageInfo_Topology.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Topology proto.InternalMessageInfo
+
+func (m *Topology) GetRoot() *ClusterNode {
+	if m != nil {
+		return m.Root
+	}
+	return nil
+}
+
+func (m *Topology) GetDetached() []*ClusterNode {
+	if m != nil {
+		return m.Detached
+	}
+	return nil
+}
+
+type ListClustersRequest struct {
+	// Selector selects clusters in rules of cluster selector, all clusters if empty.
+	Selector             string   `protobuf:"bytes,1,opt,name=Selector,proto3" json:"Selector,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListClustersRequest) Reset()         { *m = ListClustersRequest{} }
+func (m *ListClustersRequest) String() string { return proto.CompactTextString(m) }
+func (*ListClustersRequest) ProtoMessage()    {}
+func (*ListClustersRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_90b042c70967f647, []int{8}
+}
+
+func (m *ListClustersRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListClustersRequest.Unmarshal(m, b)
+}
+func (m *ListClustersRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListClustersRequest.Marshal(b, m, deterministic)
+}
+func (m *ListClustersRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListClustersRequest.Merge(m, src)
+}
+func (m *ListClustersRequest) XXX_Size() int {
+	return xxx_messageInfo_ListClustersRequest.Size(m)
+}
+func (m *ListClustersRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListClustersRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListClustersRequest proto.InternalMessageInfo
+
+func (m *ListClustersRequest) GetSelector() string {
+	if m != nil {
+		return m.Selector
+	}
+	return ""
+}
+
+type Cluster struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=Name,proto3" json:"Name,omitempty"`
+	Parent               string   `protobuf:"bytes,2,opt,name=Parent,proto3" json:"Parent,omitempty"`
+	Status               string   `protobuf:"bytes,3,opt,name=Status,proto3" json:"Status,omitempty"`
+	Version              string   `protobuf:"bytes,4,opt,name=Version,proto3" json:"Version,omitempty"`
+	Timestamp            int64    `protobuf:"varint,5,opt,name=Timestamp,proto3" json:"Timestamp,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Cluster) Reset()         { *m = Cluster{} }
+func (m *Cluster) String() string { return proto.CompactTextString(m) }
+func (*Cluster) ProtoMessage()    {}
+func (*Cluster) Descriptor() ([]byte, []int) {
+	return fileDescriptor_90b042c70967f647, []int{9}
+}
+
+func (m *Cluster) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Cluster.Unmarshal(m, b)
+}
+func (m *Cluster) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Cluster.Marshal(b, m, deterministic)
+}
+func (m *Cluster) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Cluster.Merge(m, src)
+}
+func (m *Cluster) XXX_Size() int {
+	return xxx_messageInfo_Cluster.Size(m)
+}
+func (m *Cluster) XXX_DiscardUnknown() {
+	xxx_messageInfo_Cluster.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Cluster proto.InternalMessageInfo
+
+func (m *Cluster) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Cluster) GetParent() string {
+	if m != nil {
+		return m.Parent
+	}
+	return ""
+}
+
+func (m *Cluster) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *Cluster) GetVersion() string {
+	if m != nil {
+		return m.Version
+	}
+	return ""
+}
+
+func (m *Cluster) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+type ListClustersResponse struct {
+	Clusters             []*Cluster `protobuf:"bytes,1,rep,name=Clusters,proto3" json:"Clusters,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}   `json:"-"`
+	XXX_unrecognized     []byte     `json:"-"`
+	XXX_sizecache        int32      `json:"-"`
+}
+
+func (m *ListClustersResponse) Reset()         { *m = ListClustersResponse{} }
+func (m *ListClustersResponse) String() string { return proto.CompactTextString(m) }
+func (*ListClustersResponse) ProtoMessage()    {}
+func (*ListClustersResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_90b042c70967f647, []int{10}
+}
+
+func (m *ListClustersResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListClustersResponse.Unmarshal(m, b)
+}
+func (m *ListClustersResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListClustersResponse.Marshal(b, m, deterministic)
+}
+func (m *ListClustersResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListClustersResponse.Merge(m, src)
+}
+func (m *ListClustersResponse) XXX_Size() int {
+	return xxx_messageInfo_ListClustersResponse.Size(m)
+}
+func (m *ListClustersResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListClustersResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListClustersResponse proto.InternalMessageInfo
+
+func (m *ListClustersResponse) GetClusters() []*Cluster {
+	if m != nil {
+		return m.Clusters
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*Task)(nil), "ote.northbound.v1.Task")
+	proto.RegisterType((*SubmitTaskRequest)(nil), "ote.northbound.v1.SubmitTaskRequest")
+	proto.RegisterType((*SubmitTaskResponse)(nil), "ote.northbound.v1.SubmitTaskResponse")
+	proto.RegisterType((*WatchResultsRequest)(nil), "ote.northbound.v1.WatchResultsRequest")
+	proto.RegisterType((*TaskResult)(nil), "ote.northbound.v1.TaskResult")
+	proto.RegisterType((*GetTopologyRequest)(nil), "ote.northbound.v1.GetTopologyRequest")
+	proto.RegisterType((*ClusterNode)(nil), "ote.northbound.v1.ClusterNode")
+	proto.RegisterType((*Topology)(nil), "ote.northbound.v1.Topology")
+	proto.RegisterType((*ListClustersRequest)(nil), "ote.northbound.v1.ListClustersRequest")
+	proto.RegisterType((*Cluster)(nil), "ote.northbound.v1.Cluster")
+	proto.RegisterType((*ListClustersResponse)(nil), "ote.northbound.v1.ListClustersResponse")
+}
+
+func init() { proto.RegisterFile("northbound.proto", fileDescriptor_90b042c70967f647) }
+
+var fileDescriptor_90b042c70967f647 = []byte{
+	// 591 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x54, 0x51, 0x8b, 0xd3, 0x40,
+	0x10, 0x26, 0x4d, 0xaf, 0xd7, 0x9b, 0x1e, 0x72, 0xb7, 0x77, 0xd4, 0x10, 0xf5, 0x28, 0x41, 0xcf,
+	0x03, 0xa1, 0xd8, 0x0a, 0x3e, 0xf8, 0x24, 0xd7, 0x82, 0x1c, 0x6a, 0xd1, 0xb4, 0x7a, 0xe8, 0x8b,
+	0xa4, 0xcd, 0x60, 0x83, 0x49, 0xb6, 0x66, 0x27, 0x07, 0xf5, 0xdd, 0x77, 0x7f, 0x91, 0xbf, 0xca,
+	0x1f, 0x20, 0xd9, 0x6c, 0xd2, 0xed, 0x35, 0xa5, 0xfa, 0xb6, 0x33, 0xfb, 0x65, 0xe6, 0xdb, 0x6f,
+	0xbe, 0x09, 0x1c, 0xc5, 0x3c, 0xa1, 0xf9, 0x94, 0xa7, 0xb1, 0xdf, 0x5d, 0x24, 0x9c, 0x38, 0x3b,
+	0xe6, 0x84, 0x5d, 0x2d, 0x7b, 0xd3, 0x73, 0x7e, 0x1b, 0x50, 0x9f, 0x78, 0xe2, 0x1b, 0x63, 0x50,
+	0x1f, 0x79, 0x11, 0x5a, 0x46, 0xc7, 0xb8, 0x38, 0x70, 0xe5, 0x99, 0xd9, 0xd0, 0x1c, 0x63, 0x88,
+	0x33, 0xe2, 0x89, 0x55, 0x93, 0xf9, 0x32, 0x66, 0x1d, 0x68, 0x0d, 0x51, 0x50, 0x10, 0x7b, 0x14,
+	0xf0, 0xd8, 0x32, 0xe5, 0xb5, 0x9e, 0x62, 0x6d, 0x68, 0xbc, 0x45, 0x9a, 0x73, 0xdf, 0xaa, 0xcb,
+	0x4b, 0x15, 0xb1, 0x23, 0x30, 0x3f, 0xb8, 0x57, 0xd6, 0x9e, 0x4c, 0x66, 0xc7, 0xac, 0xf7, 0x25,
+	0xf7, 0x97, 0x56, 0x23, 0xef, 0x9d, 0x9d, 0xd9, 0x39, 0xdc, 0xb9, 0xf2, 0x31, 0x5a, 0x70, 0xc2,
+	0x78, 0xb6, 0x7c, 0x8d, 0x4b, 0x6b, 0x5f, 0xde, 0xde, 0xca, 0x3a, 0x2f, 0xe1, 0x78, 0x9c, 0x4e,
+	0xa3, 0x80, 0xb2, 0x57, 0xb8, 0xf8, 0x3d, 0x45, 0x41, 0xec, 0x49, 0xfe, 0x28, 0xf9, 0x98, 0x56,
+	0xff, 0x6e, 0x77, 0xe3, 0xdd, 0x5d, 0x89, 0x96, 0x20, 0x67, 0x08, 0x4c, 0xaf, 0x20, 0x16, 0x3c,
+	0x16, 0xb8, 0x4d, 0x8f, 0x41, 0x98, 0x0a, 0xc2, 0x44, 0x58, 0xb5, 0x8e, 0x99, 0xe9, 0x51, 0xc4,
+	0x4e, 0x04, 0x27, 0xd7, 0x1e, 0xcd, 0xe6, 0x2e, 0x8a, 0x34, 0x24, 0x51, 0x30, 0xf9, 0xcf, 0x32,
+	0xd9, 0xb3, 0x27, 0x41, 0x84, 0x3c, 0xa5, 0x31, 0xce, 0x78, 0xec, 0x0b, 0xa9, 0xac, 0xe9, 0xde,
+	0xca, 0x3a, 0xbf, 0x0c, 0x00, 0xc5, 0x37, 0x0d, 0x89, 0x59, 0xb0, 0xaf, 0x4a, 0xa8, 0x4e, 0x45,
+	0xc8, 0xce, 0x00, 0xc6, 0xe4, 0x51, 0x2a, 0x06, 0xdc, 0x47, 0x39, 0xc5, 0x3d, 0x57, 0xcb, 0x94,
+	0xda, 0x9b, 0x9a, 0xf6, 0x6d, 0x68, 0xb8, 0xe8, 0x09, 0x1e, 0x17, 0x93, 0xcb, 0x23, 0x76, 0x1f,
+	0x0e, 0x32, 0x1a, 0x82, 0xbc, 0x68, 0x21, 0xe7, 0x67, 0xba, 0xab, 0x84, 0x73, 0x0a, 0xec, 0x15,
+	0xd2, 0x84, 0x2f, 0x78, 0xc8, 0xbf, 0x2e, 0x95, 0x00, 0x4e, 0x0a, 0x2d, 0x45, 0x65, 0xa4, 0xda,
+	0x6d, 0xe8, 0xd1, 0x86, 0x46, 0x4e, 0x48, 0x99, 0x4c, 0x45, 0xec, 0x05, 0x34, 0x07, 0xf3, 0x20,
+	0xf4, 0x13, 0xcc, 0xfc, 0x65, 0x5e, 0xb4, 0xfa, 0x67, 0x15, 0x93, 0xd4, 0xaa, 0xbb, 0x25, 0xde,
+	0xf9, 0x01, 0xcd, 0x82, 0x09, 0xeb, 0x43, 0xdd, 0xe5, 0x9c, 0x94, 0x1b, 0x76, 0xd5, 0x90, 0xd8,
+	0xac, 0xf7, 0x10, 0xc9, 0x9b, 0xcd, 0xd1, 0x97, 0x33, 0xfa, 0x87, 0xde, 0x05, 0xde, 0xe9, 0xc1,
+	0xc9, 0x9b, 0x40, 0x90, 0xba, 0x2c, 0xad, 0xa0, 0x6f, 0x93, 0xb1, 0xbe, 0x4d, 0xce, 0x4f, 0xa3,
+	0x1c, 0xe0, 0x36, 0x89, 0xde, 0x79, 0x09, 0xc6, 0x54, 0x48, 0x94, 0x47, 0x9a, 0x74, 0xe6, 0x9a,
+	0x74, 0x16, 0xec, 0x7f, 0xc4, 0x44, 0x04, 0xe5, 0x08, 0x8b, 0x70, 0xc7, 0x0c, 0x47, 0x70, 0xba,
+	0x4e, 0x5d, 0x6d, 0xc3, 0x73, 0xcd, 0xb2, 0x86, 0x94, 0xc3, 0xde, 0x2e, 0xc7, 0xca, 0xce, 0xfd,
+	0x3f, 0x35, 0x80, 0x51, 0x89, 0x61, 0x9f, 0x00, 0x56, 0xab, 0xc6, 0x1e, 0x56, 0x94, 0xd8, 0xd8,
+	0x65, 0xfb, 0xd1, 0x0e, 0x94, 0x62, 0x78, 0x0d, 0x87, 0xfa, 0xfe, 0xb1, 0xf3, 0x8a, 0xcf, 0x2a,
+	0x16, 0xd4, 0x7e, 0xb0, 0xed, 0xe7, 0x20, 0x61, 0x4f, 0x0d, 0xf6, 0x1e, 0x5a, 0x9a, 0xad, 0x59,
+	0x15, 0x9d, 0x4d, 0xdb, 0xdb, 0xf7, 0xaa, 0xca, 0x16, 0x35, 0xbe, 0xc0, 0xa1, 0xae, 0x72, 0x25,
+	0xd7, 0x0a, 0x07, 0xd9, 0x8f, 0x77, 0xe2, 0x72, 0x31, 0x2e, 0xeb, 0x9f, 0x6b, 0x37, 0xbd, 0x69,
+	0x43, 0xfe, 0xf5, 0x9f, 0xfd, 0x1d, 0x00, 0x65, 0x0d, 0x98, 0xb7, 0x09, 0x06, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// NorthboundClient is the client API for Northbound service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type NorthboundClient interface {
+	// SubmitTask creates a task for clusters selected.
+	SubmitTask(ctx context.Context, in *SubmitTaskRequest, opts ...grpc.CallOption) (*SubmitTaskResponse, error)
+	// WatchResults streams results of a task by cluster as they arrive.
+	WatchResults(ctx context.Context, in *WatchResultsRequest, opts ...grpc.CallOption) (Northbound_WatchResultsClient, error)
+	// GetTopology returns the tree of clusters.
+	GetTopology(ctx context.Context, in *GetTopologyRequest, opts ...grpc.CallOption) (*Topology, error)
+	// ListClusters returns clusters selected.
+	ListClusters(ctx context.Context, in *ListClustersRequest, opts ...grpc.CallOption) (*ListClustersResponse, error)
+}
+
+type northboundClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewNorthboundClient(cc *grpc.ClientConn) NorthboundClient {
+	return &northboundClient{cc}
+}
+
+func (c *northboundClient) SubmitTask(ctx context.Context, in *SubmitTaskRequest, opts ...grpc.CallOption) (*SubmitTaskResponse, error) {
+	out := new(SubmitTaskResponse)
+	err := c.cc.Invoke(ctx, "/ote.northbound.v1.Northbound/SubmitTask", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *northboundClient) WatchResults(ctx context.Context, in *WatchResultsRequest, opts ...grpc.CallOption) (Northbound_WatchResultsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Northbound_serviceDesc.Streams[0], "/ote.northbound.v1.Northbound/WatchResults", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &northboundWatchResultsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Northbound_WatchResultsClient interface {
+	Recv() (*TaskResult, error)
+	grpc.ClientStream
+}
+
+type northboundWatchResultsClient struct {
+	grpc.ClientStream
+}
+
+func (x *northboundWatchResultsClient) Recv() (*TaskResult, error) {
+	m := new(TaskResult)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *northboundClient) GetTopology(ctx context.Context, in *GetTopologyRequest, opts ...grpc.CallOption) (*Topology, error) {
+	out := new(Topology)
+	err := c.cc.Invoke(ctx, "/ote.northbound.v1.Northbound/GetTopology", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *northboundClient) ListClusters(ctx context.Context, in *ListClustersRequest, opts ...grpc.CallOption) (*ListClustersResponse, error) {
+	out := new(ListClustersResponse)
+	err := c.cc.Invoke(ctx, "/ote.northbound.v1.Northbound/ListClusters", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// NorthboundServer is the server API for Northbound service.
+type NorthboundServer interface {
+	// SubmitTask creates a task for clusters selected.
+	SubmitTask(context.Context, *SubmitTaskRequest) (*SubmitTaskResponse, error)
+	// WatchResults streams results of a task by cluster as they arrive.
+	WatchResults(*WatchResultsRequest, Northbound_WatchResultsServer) error
+	// GetTopology returns the tree of clusters.
+	GetTopology(context.Context, *GetTopologyRequest) (*Topology, error)
+	// ListClusters returns clusters selected.
+	ListClusters(context.Context, *ListClustersRequest) (*ListClustersResponse, error)
+}
+
+// UnimplementedNorthboundServer can be embedded to have forward compatible implementations.
+type UnimplementedNorthboundServer struct {
+}
+
+func (*UnimplementedNorthboundServer) SubmitTask(ctx context.Context, req *SubmitTaskRequest) (*SubmitTaskResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitTask not implemented")
+}
+func (*UnimplementedNorthboundServer) WatchResults(req *WatchResultsRequest, srv Northbound_WatchResultsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchResults not implemented")
+}
+func (*UnimplementedNorthboundServer) GetTopology(ctx context.Context, req *GetTopologyRequest) (*Topology, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTopology not implemented")
+}
+func (*UnimplementedNorthboundServer) ListClusters(ctx context.Context, req *ListClustersRequest) (*ListClustersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListClusters not implemented")
+}
+
+func RegisterNorthboundServer(s *grpc.Server, srv NorthboundServer) {
+	s.RegisterService(&_Northbound_serviceDesc, srv)
+}
+
+func _Northbound_SubmitTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitTaskRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NorthboundServer).SubmitTask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ote.northbound.v1.Northbound/SubmitTask",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NorthboundServer).SubmitTask(ctx, req.(*SubmitTaskRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Northbound_WatchResults_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchResultsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NorthboundServer).WatchResults(m, &northboundWatchResultsServer{stream})
+}
+
+type Northbound_WatchResultsServer interface {
+	Send(*TaskResult) error
+	grpc.ServerStream
+}
+
+type northboundWatchResultsServer struct {
+	grpc.ServerStream
+}
+
+func (x *northboundWatchResultsServer) Send(m *TaskResult) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Northbound_GetTopology_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTopologyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NorthboundServer).GetTopology(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ote.northbound.v1.Northbound/GetTopology",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NorthboundServer).GetTopology(ctx, req.(*GetTopologyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Northbound_ListClusters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListClustersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NorthboundServer).ListClusters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ote.northbound.v1.Northbound/ListClusters",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NorthboundServer).ListClusters(ctx, req.(*ListClustersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Northbound_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "ote.northbound.v1.Northbound",
+	HandlerType: (*NorthboundServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SubmitTask",
+			Handler:    _Northbound_SubmitTask_Handler,
+		},
+		{
+			MethodName: "GetTopology",
+			Handler:    _Northbound_GetTopology_Handler,
+		},
+		{
+			MethodName: "ListClusters",
+			Handler:    _Northbound_ListClusters_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchResults",
+			Handler:       _Northbound_WatchResults_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "northbound.proto",
+}
@@ -0,0 +1,323 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package objectref transfers large bodies of controller tasks out of band.
+/*
+Instead of the body, a task carries a reference to an object in object storage, which is
+a signed url or the sha256 of the content under a store url. Clusters forward the reference
+as is, and the destination clusters fetch the object, verify its size and sha256, and cache it
+in a directory by sha256, so that the object is fetched once by each cluster.
+*/
+package objectref
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	// Prefix starts bodies which are references, followed by the reference in json.
+	Prefix = "ote-object-ref:"
+
+	// DefaultFetchTimeout is the time to fetch an object by default.
+	DefaultFetchTimeout = 5 * time.Minute
+)
+
+// Ref is a reference to an object in object storage.
+type Ref struct {
+	// URL is the url to get the object, e.g., a signed url of the object storage.
+	// If empty, the object is got from the store url by its sha256.
+	URL    string `json:"url,omitempty"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// NewRef returns the reference to data stored at url.
+func NewRef(data []byte, url string) *Ref {
+	sum := sha256.Sum256(data)
+	return &Ref{
+		URL:    url,
+		SHA256: hex.EncodeToString(sum[:]),
+		Size:   int64(len(data)),
+	}
+}
+
+// Marshal returns the body carrying the reference.
+func (r *Ref) Marshal() []byte {
+	data, _ := json.Marshal(r)
+	return append([]byte(Prefix), data...)
+}
+
+func (r *Ref) valid() error {
+	if len(r.SHA256) != sha256.Size*2 {
+		return fmt.Errorf("sha256 %q of object is invalid", r.SHA256)
+	}
+	if _, err := hex.DecodeString(r.SHA256); err != nil {
+		return fmt.Errorf("sha256 %q of object is invalid", r.SHA256)
+	}
+	if r.Size < 0 {
+		return fmt.Errorf("size %d of object is invalid", r.Size)
+	}
+	return nil
+}
+
+// verify checks data is the content referenced.
+func (r *Ref) verify(data []byte) error {
+	if int64(len(data)) != r.Size {
+		return fmt.Errorf("size of object %s is %d, expected %d", r.SHA256, len(data), r.Size)
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != strings.ToLower(r.SHA256) {
+		return fmt.Errorf("sha256 of object %s mismatched", r.SHA256)
+	}
+	return nil
+}
+
+// IsRef returns true if body carries a reference.
+func IsRef(body []byte) bool {
+	return bytes.HasPrefix(body, []byte(Prefix))
+}
+
+// Parse returns the reference carried by body.
+func Parse(body []byte) (*Ref, error) {
+	if !IsRef(body) {
+		return nil, fmt.Errorf("body is not an object reference")
+	}
+	r := &Ref{}
+	if err := json.Unmarshal(body[len(Prefix):], r); err != nil {
+		return nil, fmt.Errorf("unmarshal object reference failed: %v", err)
+	}
+	if err := r.valid(); err != nil {
+		return nil, err
+	}
+	r.SHA256 = strings.ToLower(r.SHA256)
+	return r, nil
+}
+
+// Config is the config of fetcher.
+type Config struct {
+	// CacheDir is the directory to cache objects, objects are not cached if empty.
+	CacheDir string
+	// CacheSize is the bytes of objects cached, the least recently used ones are removed
+	// if exceeded, 0 means no limit.
+	CacheSize int64
+	// StoreURL is the url to get objects referenced without url, by StoreURL/sha256.
+	StoreURL string
+	// MaxSize is the max size of objects to fetch, 0 means no limit.
+	MaxSize int64
+	// Timeout is the time to fetch an object, DefaultFetchTimeout if 0.
+	Timeout time.Duration
+}
+
+// Fetcher fetches and caches objects referenced.
+type Fetcher struct {
+	conf   Config
+	client *http.Client
+	// lock serializes fetching, so that an object is fetched once.
+	lock sync.Mutex
+}
+
+var defaultFetcher = NewFetcher(Config{})
+
+// NewFetcher returns a Fetcher with conf.
+func NewFetcher(conf Config) *Fetcher {
+	if conf.Timeout == 0 {
+		conf.Timeout = DefaultFetchTimeout
+	}
+	conf.StoreURL = strings.TrimSuffix(conf.StoreURL, "/")
+	return &Fetcher{
+		conf:   conf,
+		client: &http.Client{Timeout: conf.Timeout},
+	}
+}
+
+// Setup replaces the default fetcher with conf, and creates the cache directory.
+func Setup(conf Config) error {
+	if conf.CacheSize < 0 || conf.MaxSize < 0 {
+		return fmt.Errorf("object cache size and max size must not be negative")
+	}
+	if conf.CacheDir != "" {
+		if err := os.MkdirAll(conf.CacheDir, 0700); err != nil {
+			return fmt.Errorf("create object cache dir %s failed: %v", conf.CacheDir, err)
+		}
+	}
+	defaultFetcher = NewFetcher(conf)
+	return nil
+}
+
+// Resolve returns the object if body is a reference by the default fetcher,
+// otherwise body itself.
+func Resolve(body []byte) ([]byte, error) {
+	return defaultFetcher.Resolve(body)
+}
+
+// Resolve returns the object if body is a reference, otherwise body itself.
+func (f *Fetcher) Resolve(body []byte) ([]byte, error) {
+	if !IsRef(body) {
+		return body, nil
+	}
+	r, err := Parse(body)
+	if err != nil {
+		return nil, err
+	}
+	return f.Fetch(r)
+}
+
+// Fetch returns the object referenced by r, from cache if cached.
+func (f *Fetcher) Fetch(r *Ref) ([]byte, error) {
+	if f.conf.MaxSize > 0 && r.Size > f.conf.MaxSize {
+		return nil, fmt.Errorf("size %d of object %s exceeds the max size %d", r.Size, r.SHA256, f.conf.MaxSize)
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if data, ok := f.cached(r); ok {
+		return data, nil
+	}
+
+	url := r.URL
+	if url == "" {
+		if f.conf.StoreURL == "" {
+			return nil, fmt.Errorf("object %s is not cached and has no url", r.SHA256)
+		}
+		url = f.conf.StoreURL + "/" + r.SHA256
+	}
+	data, err := f.get(url, r.Size)
+	if err != nil {
+		return nil, fmt.Errorf("fetch object %s failed: %v", r.SHA256, err)
+	}
+	if err := r.verify(data); err != nil {
+		return nil, err
+	}
+	klog.V(1).Infof("fetched object %s of %d bytes", r.SHA256, r.Size)
+	f.cache(r, data)
+	return data, nil
+}
+
+func (f *Fetcher) get(url string, size int64) ([]byte, error) {
+	resp, err := f.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %s", resp.Status)
+	}
+	// read one more byte to find objects larger than expected.
+	return ioutil.ReadAll(io.LimitReader(resp.Body, size+1))
+}
+
+func (f *Fetcher) path(r *Ref) string {
+	return filepath.Join(f.conf.CacheDir, r.SHA256)
+}
+
+// cached returns the object cached, objects corrupted are removed.
+func (f *Fetcher) cached(r *Ref) ([]byte, bool) {
+	if f.conf.CacheDir == "" {
+		return nil, false
+	}
+	path := f.path(r)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	if err := r.verify(data); err != nil {
+		klog.Warningf("remove corrupted object cache %s: %v", path, err)
+		os.Remove(path)
+		return nil, false
+	}
+	// modification time is the last used time for eviction.
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return data, true
+}
+
+func (f *Fetcher) cache(r *Ref, data []byte) {
+	if f.conf.CacheDir == "" {
+		return
+	}
+	if f.conf.CacheSize > 0 && r.Size > f.conf.CacheSize {
+		return
+	}
+	tmp, err := ioutil.TempFile(f.conf.CacheDir, ".tmp-")
+	if err != nil {
+		klog.Errorf("cache object %s failed: %v", r.SHA256, err)
+		return
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), f.path(r))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		klog.Errorf("cache object %s failed: %v", r.SHA256, err)
+		return
+	}
+	f.evict()
+}
+
+// evict removes the least recently used objects until the cache fits in cache size.
+func (f *Fetcher) evict() {
+	if f.conf.CacheSize == 0 {
+		return
+	}
+	files, err := ioutil.ReadDir(f.conf.CacheDir)
+	if err != nil {
+		klog.Errorf("read object cache dir failed: %v", err)
+		return
+	}
+	var total int64
+	objects := make([]os.FileInfo, 0, len(files))
+	for _, file := range files {
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+		total += file.Size()
+		objects = append(objects, file)
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].ModTime().Before(objects[j].ModTime())
+	})
+	for _, file := range objects {
+		if total <= f.conf.CacheSize {
+			return
+		}
+		if err := os.Remove(filepath.Join(f.conf.CacheDir, file.Name())); err != nil {
+			klog.Errorf("remove object cache %s failed: %v", file.Name(), err)
+			continue
+		}
+		total -= file.Size()
+		klog.V(1).Infof("evicted object cache %s", file.Name())
+	}
+}
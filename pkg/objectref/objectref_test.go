@@ -0,0 +1,182 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objectref
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newStore returns a server serving objects by path, and counts of requests by path.
+func newStore(objects map[string][]byte) (*httptest.Server, map[string]int) {
+	counts := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counts[r.URL.Path]++
+		data, ok := objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	}))
+	return server, counts
+}
+
+func TestParse(t *testing.T) {
+	data := []byte("large object")
+	ref := NewRef(data, "http://store/obj")
+	assert.Equal(t, int64(len(data)), ref.Size)
+	assert.Nil(t, ref.verify(data))
+	assert.NotNil(t, ref.verify([]byte("large objecT")))
+	assert.NotNil(t, ref.verify([]byte("large")))
+
+	body := ref.Marshal()
+	assert.True(t, IsRef(body))
+	parsed, err := Parse(body)
+	assert.Nil(t, err)
+	assert.Equal(t, ref, parsed)
+
+	assert.False(t, IsRef(data))
+	_, err = Parse(data)
+	assert.NotNil(t, err)
+	_, err = Parse([]byte(Prefix + "{"))
+	assert.NotNil(t, err)
+	_, err = Parse([]byte(Prefix + `{"sha256":"abc","size":1}`))
+	assert.NotNil(t, err)
+	_, err = Parse([]byte(Prefix + `{"sha256":"` + strings.Repeat("z", 64) + `","size":1}`))
+	assert.NotNil(t, err)
+	_, err = Parse([]byte(Prefix + `{"sha256":"` + ref.SHA256 + `","size":-1}`))
+	assert.NotNil(t, err)
+}
+
+func TestResolve(t *testing.T) {
+	data := []byte("large object")
+	server, counts := newStore(map[string][]byte{
+		"/signed":                     data,
+		"/" + NewRef(data, "").SHA256: data,
+	})
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "objectref")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	f := NewFetcher(Config{CacheDir: dir, StoreURL: server.URL + "/"})
+	body, err := f.Resolve([]byte("inline"))
+	assert.Nil(t, err)
+	assert.Equal(t, "inline", string(body))
+
+	ref := NewRef(data, server.URL+"/signed")
+	body, err = f.Resolve(ref.Marshal())
+	assert.Nil(t, err)
+	assert.Equal(t, data, body)
+	// fetched once, then from cache.
+	body, err = f.Resolve(ref.Marshal())
+	assert.Nil(t, err)
+	assert.Equal(t, data, body)
+	assert.Equal(t, 1, counts["/signed"])
+	_, err = os.Stat(filepath.Join(dir, ref.SHA256))
+	assert.Nil(t, err)
+
+	// cache corrupted is fetched again.
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, ref.SHA256), []byte("corrupted!!!"), 0600))
+	body, err = f.Resolve(ref.Marshal())
+	assert.Nil(t, err)
+	assert.Equal(t, data, body)
+	assert.Equal(t, 2, counts["/signed"])
+
+	// reference without url is got from store url.
+	f = NewFetcher(Config{StoreURL: server.URL})
+	body, err = f.Resolve(NewRef(data, "").Marshal())
+	assert.Nil(t, err)
+	assert.Equal(t, data, body)
+	assert.Equal(t, 1, counts["/"+ref.SHA256])
+
+	// content mismatched, not found, too large or no url.
+	_, err = f.Resolve(NewRef([]byte("another"), server.URL+"/signed").Marshal())
+	assert.NotNil(t, err)
+	_, err = f.Resolve(NewRef(data, server.URL+"/notfound").Marshal())
+	assert.NotNil(t, err)
+	f = NewFetcher(Config{MaxSize: 4})
+	_, err = f.Resolve(ref.Marshal())
+	assert.NotNil(t, err)
+	_, err = f.Resolve(NewRef([]byte("a"), "").Marshal())
+	assert.NotNil(t, err)
+}
+
+func TestEvict(t *testing.T) {
+	objects := map[string][]byte{
+		"/1": []byte("1111"),
+		"/2": []byte("2222"),
+		"/3": []byte("3333"),
+	}
+	server, _ := newStore(objects)
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "objectref")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	f := NewFetcher(Config{CacheDir: dir, CacheSize: 8})
+	refs := map[string]*Ref{}
+	for _, name := range []string{"1", "2"} {
+		refs[name] = NewRef(objects["/"+name], server.URL+"/"+name)
+		_, err := f.Fetch(refs[name])
+		assert.Nil(t, err)
+	}
+	// 1 is used more recently than 2.
+	old := time.Now().Add(-time.Hour)
+	assert.Nil(t, os.Chtimes(f.path(refs["2"]), old, old))
+	assert.Nil(t, os.Chtimes(f.path(refs["1"]), old.Add(-time.Minute), old.Add(-time.Minute)))
+	_, err = f.Fetch(refs["1"])
+	assert.Nil(t, err)
+
+	refs["3"] = NewRef(objects["/3"], server.URL+"/3")
+	_, err = f.Fetch(refs["3"])
+	assert.Nil(t, err)
+	for name, exist := range map[string]bool{"1": true, "2": false, "3": true} {
+		_, err := os.Stat(f.path(refs[name]))
+		assert.Equal(t, exist, err == nil, name)
+	}
+
+	// objects larger than cache size are not cached.
+	assert.Nil(t, os.Remove(f.path(refs["1"])))
+	f = NewFetcher(Config{CacheDir: dir, CacheSize: 2})
+	_, err = f.Fetch(refs["1"])
+	assert.Nil(t, err)
+	_, err = os.Stat(f.path(refs["1"]))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSetup(t *testing.T) {
+	defer Setup(Config{})
+	dir, err := ioutil.TempDir("", "objectref")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NotNil(t, Setup(Config{CacheSize: -1}))
+	assert.Nil(t, Setup(Config{CacheDir: filepath.Join(dir, "cache")}))
+	_, err = os.Stat(filepath.Join(dir, "cache"))
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultFetchTimeout, defaultFetcher.conf.Timeout)
+}
@@ -0,0 +1,399 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package oidc verifies id tokens issued by an openid connect provider, e.g., dex or keycloak,
+// by the keys published by the provider, so that users and clusters are authenticated
+// by an identity system of enterprises instead of static tokens.
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+var (
+	// requestTimeout is the time of a request to the provider.
+	requestTimeout = 10 * time.Second
+	// minRefreshInterval is the min time between fetching keys of the provider again,
+	// which is done once a token is signed by an unknown key, e.g., the keys are rotated.
+	minRefreshInterval = 10 * time.Second
+	// clockSkewLeeway is the clock skew tolerated checking expiry of tokens.
+	clockSkewLeeway = time.Minute
+)
+
+// Config is the openid connect provider to verify tokens by.
+type Config struct {
+	// IssuerURL is the url of the provider, which must be the issuer of tokens,
+	// e.g., https://dex.example.com, its keys are discovered from <url>/.well-known/openid-configuration.
+	IssuerURL string
+	// ClientID is the audience tokens must be issued for.
+	ClientID string
+	// CAFile is the ca to verify the provider by, the system roots if empty.
+	CAFile string
+}
+
+// Claims are the claims of a token verified.
+type Claims map[string]interface{}
+
+// String returns the claim name in string, empty if not a string.
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Strings returns the claim name in strings, e.g., groups, which is a string or an array of strings.
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		ret := make([]string, 0, len(v))
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				ret = append(ret, s)
+			}
+		}
+		return ret
+	default:
+		return nil
+	}
+}
+
+// Verifier verifies tokens issued by a provider.
+type Verifier struct {
+	conf   Config
+	client *http.Client
+	now    func() time.Time
+
+	lock sync.Mutex
+	// keys are the keys of the provider by key id, fetched once a token is verified.
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// NewVerifier returns a verifier of tokens of the provider of conf.
+// The provider is not reached until a token is verified.
+func NewVerifier(conf Config) (*Verifier, error) {
+	if conf.IssuerURL == "" || conf.ClientID == "" {
+		return nil, fmt.Errorf("issuer url and client id of oidc are required")
+	}
+	if !strings.HasPrefix(conf.IssuerURL, "https://") && !strings.HasPrefix(conf.IssuerURL, "http://") {
+		return nil, fmt.Errorf("issuer url of oidc %s is not http or https", conf.IssuerURL)
+	}
+	client := &http.Client{Timeout: requestTimeout}
+	if conf.CAFile != "" {
+		ca, err := ioutil.ReadFile(conf.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read oidc ca failed: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate is found in oidc ca %s", conf.CAFile)
+		}
+		client.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		}
+	}
+	return &Verifier{
+		conf:   conf,
+		client: client,
+		now:    time.Now,
+		keys:   make(map[string]crypto.PublicKey),
+	}, nil
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+/*
+Verify returns the claims of token if it is a jwt signed by a key of the provider,
+issued by the issuer for the client id, and not expired.
+*/
+func (v *Verifier) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("token is not a jwt")
+	}
+	h := header{}
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, fmt.Errorf("decode token header failed: %v", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("decode token signature failed: %v", err)
+	}
+	key, err := v.key(h.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(h.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	claims := Claims{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("decode token claims failed: %v", err)
+	}
+	if iss := claims.String("iss"); iss != v.conf.IssuerURL {
+		return nil, fmt.Errorf("token is issued by %s instead of %s", iss, v.conf.IssuerURL)
+	}
+	if !contains(claims.Strings("aud"), v.conf.ClientID) {
+		return nil, fmt.Errorf("token is not issued for %s", v.conf.ClientID)
+	}
+	now := v.now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("token has no expiry")
+	}
+	if now.Add(-clockSkewLeeway).After(time.Unix(int64(exp), 0)) {
+		return nil, fmt.Errorf("token is expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkewLeeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, fmt.Errorf("token is not valid yet")
+	}
+	return claims, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// key returns the key of kid, the keys are fetched again if kid is unknown.
+func (v *Verifier) key(kid string) (crypto.PublicKey, error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if key := v.lookup(kid); key != nil {
+		return key, nil
+	}
+	if !v.fetched.IsZero() && v.now().Sub(v.fetched) < minRefreshInterval {
+		return nil, fmt.Errorf("key %s of token is not found", kid)
+	}
+	keys, err := v.fetchKeys()
+	if err != nil {
+		return nil, err
+	}
+	v.keys, v.fetched = keys, v.now()
+	klog.V(3).Infof("fetched %d keys of oidc provider %s", len(keys), v.conf.IssuerURL)
+	if key := v.lookup(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("key %s of token is not found", kid)
+}
+
+// lookup returns the key of kid, or the only key if kid is empty.
+func (v *Verifier) lookup(kid string) crypto.PublicKey {
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key
+		}
+	}
+	return v.keys[kid]
+}
+
+type discovery struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// fetchKeys returns the signing keys of the provider by key id.
+func (v *Verifier) fetchKeys() (map[string]crypto.PublicKey, error) {
+	d := discovery{}
+	if err := v.get(strings.TrimSuffix(v.conf.IssuerURL, "/")+"/.well-known/openid-configuration", &d); err != nil {
+		return nil, err
+	}
+	if d.Issuer != v.conf.IssuerURL {
+		return nil, fmt.Errorf("issuer of oidc provider is %s instead of %s", d.Issuer, v.conf.IssuerURL)
+	}
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	if err := v.get(d.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			klog.Warningf("ignore key %s of oidc provider: %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func (v *Verifier) get(url string, out interface{}) error {
+	resp, err := v.client.Get(url)
+	if err != nil {
+		return fmt.Errorf("request oidc provider failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("request %s of oidc provider failed: %s", url, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode %s of oidc provider failed: %v", url, err)
+	}
+	return nil
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("curve %s is not supported", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point of key is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("key type %s is not supported", k.Kty)
+	}
+}
+
+// verifySignature verifies signature of signed by key of alg.
+func verifySignature(alg string, key crypto.PublicKey, signed, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("token algorithm %s is not supported", alg)
+	}
+	digest := digestOf(hash, signed)
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("token algorithm %s does not match rsa key", alg)
+		}
+		if err := rsa.VerifyPKCS1v15(key, hash, digest, signature); err != nil {
+			return fmt.Errorf("token signature is invalid")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(alg, "ES") || len(signature) != 2*size {
+			return fmt.Errorf("token algorithm %s does not match ecdsa key", alg)
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return fmt.Errorf("token signature is invalid")
+		}
+		return nil
+	default:
+		return fmt.Errorf("key of token is not supported")
+	}
+}
+
+func digestOf(hash crypto.Hash, data []byte) []byte {
+	switch hash {
+	case crypto.SHA384:
+		sum := sha512.Sum384(data)
+		return sum[:]
+	case crypto.SHA512:
+		sum := sha512.Sum512(data)
+		return sum[:]
+	default:
+		sum := sha256.Sum256(data)
+		return sum[:]
+	}
+}
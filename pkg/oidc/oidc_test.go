@@ -0,0 +1,186 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// provider is a fake oidc provider publishing an rsa key and an ecdsa key.
+type provider struct {
+	server   *httptest.Server
+	rsaKid   string
+	rsaKey   *rsa.PrivateKey
+	ecKey    *ecdsa.PrivateKey
+	requests int
+}
+
+func encodeInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func newProvider(t *testing.T) *provider {
+	p := &provider{rsaKid: "rsa"}
+	var err error
+	p.rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	p.ecKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(discovery{Issuer: p.server.URL, JWKSURI: p.server.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		p.requests++
+		json.NewEncoder(w).Encode(map[string][]jwk{"keys": {
+			{Kty: "RSA", Kid: p.rsaKid, Use: "sig", N: encodeInt(p.rsaKey.N), E: encodeInt(big.NewInt(int64(p.rsaKey.E)))},
+			{Kty: "EC", Kid: "ec", Crv: "P-256", X: encodeInt(p.ecKey.X), Y: encodeInt(p.ecKey.Y)},
+			{Kty: "oct", Kid: "hmac"},
+		}})
+	})
+	p.server = httptest.NewServer(mux)
+	return p
+}
+
+func (p *provider) sign(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	h, _ := json.Marshal(header{Alg: alg, Kid: kid})
+	c, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(signed))
+	var signature []byte
+	switch alg {
+	case "RS256":
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, p.rsaKey, crypto.SHA256, digest[:])
+		assert.Nil(t, err)
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, p.ecKey, digest[:])
+		assert.Nil(t, err)
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (p *provider) claims(exp time.Duration) map[string]interface{} {
+	return map[string]interface{}{
+		"iss":    p.server.URL,
+		"aud":    "ote",
+		"sub":    "c1",
+		"groups": []string{"edge", "ops"},
+		"exp":    time.Now().Add(exp).Unix(),
+	}
+}
+
+func TestNewVerifier(t *testing.T) {
+	_, err := NewVerifier(Config{IssuerURL: "https://dex"})
+	assert.NotNil(t, err)
+	_, err = NewVerifier(Config{IssuerURL: "dex", ClientID: "ote"})
+	assert.NotNil(t, err)
+	_, err = NewVerifier(Config{IssuerURL: "https://dex", ClientID: "ote", CAFile: "/not/exist"})
+	assert.NotNil(t, err)
+	_, err = NewVerifier(Config{IssuerURL: "https://dex", ClientID: "ote"})
+	assert.Nil(t, err)
+}
+
+func TestVerify(t *testing.T) {
+	p := newProvider(t)
+	defer p.server.Close()
+	v, err := NewVerifier(Config{IssuerURL: p.server.URL, ClientID: "ote"})
+	assert.Nil(t, err)
+
+	for _, alg := range []string{"RS256", "ES256"} {
+		kid := map[string]string{"RS256": "rsa", "ES256": "ec"}[alg]
+		claims, err := v.Verify(p.sign(t, alg, kid, p.claims(time.Hour)))
+		assert.Nil(t, err, alg)
+		assert.Equal(t, "c1", claims.String("sub"))
+		assert.Equal(t, []string{"edge", "ops"}, claims.Strings("groups"))
+		assert.Equal(t, []string{"ote"}, claims.Strings("aud"))
+	}
+	// keys are fetched once.
+	assert.Equal(t, 1, p.requests)
+
+	expired := p.claims(-time.Hour)
+	other := p.claims(time.Hour)
+	other["aud"] = []string{"other"}
+	issuer := p.claims(time.Hour)
+	issuer["iss"] = "https://other"
+	invalid := []string{
+		"not a jwt",
+		p.sign(t, "RS256", "rsa", expired),
+		p.sign(t, "RS256", "rsa", other),
+		p.sign(t, "RS256", "rsa", issuer),
+		// signed by a key of another type.
+		p.sign(t, "ES256", "rsa", p.claims(time.Hour)),
+		p.sign(t, "HS256", "rsa", p.claims(time.Hour)),
+		p.sign(t, "RS256", "unknown", p.claims(time.Hour)),
+	}
+	for i, token := range invalid {
+		_, err := v.Verify(token)
+		assert.NotNil(t, err, i)
+	}
+	// unknown keys are not fetched again in min refresh interval.
+	assert.Equal(t, 1, p.requests)
+
+	// a tampered token is refused.
+	token := p.sign(t, "RS256", "rsa", p.claims(time.Hour))
+	tampered := p.claims(time.Hour)
+	tampered["sub"] = "c2"
+	c, _ := json.Marshal(tampered)
+	_, err = v.Verify(token[:strings.Index(token, ".")+1] + base64.RawURLEncoding.EncodeToString(c) +
+		token[strings.LastIndex(token, "."):])
+	assert.NotNil(t, err)
+}
+
+func TestKeyRotation(t *testing.T) {
+	p := newProvider(t)
+	defer p.server.Close()
+	v, err := NewVerifier(Config{IssuerURL: p.server.URL, ClientID: "ote"})
+	assert.Nil(t, err)
+	now := time.Now()
+	v.now = func() time.Time { return now }
+	_, err = v.Verify(p.sign(t, "RS256", "rsa", p.claims(time.Hour)))
+	assert.Nil(t, err)
+
+	// the provider rotates its key.
+	p.rsaKid = "rsa2"
+	p.rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	_, err = v.Verify(p.sign(t, "RS256", "rsa2", p.claims(time.Hour)))
+	assert.NotNil(t, err)
+
+	now = now.Add(minRefreshInterval)
+	_, err = v.Verify(p.sign(t, "RS256", "rsa2", p.claims(time.Hour)))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, p.requests)
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package otectl
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/archive"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned"
+)
+
+// Capture asks the cluster to archive whole messages sampled at rate for duration,
+// or to stop capturing if duration is 0, and returns the response of the cluster.
+func Capture(client oteclient.Interface, cluster string,
+	rate float64, duration, timeout time.Duration) (string, error) {
+	task := &Task{
+		Destination: otev1.ClusterControllerDestCapture,
+		Method:      http.MethodDelete,
+	}
+	if duration != 0 {
+		body, err := json.Marshal(archive.Capture{Rate: rate, Duration: duration})
+		if err != nil {
+			return "", err
+		}
+		task.Method = http.MethodPost
+		task.Body = string(body)
+	}
+	status, err := runPodTask(client, cluster, task, timeout)
+	if err != nil {
+		return "", err
+	}
+	return status.Body, nil
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package otectl
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/archive"
+	otefake "github.com/baidu/ote-stack/pkg/k8sclient/fake"
+)
+
+func TestCapture(t *testing.T) {
+	client := otefake.NewSimpleClientset()
+	go fakeResponse(t, client, func(cc *otev1.ClusterController) {
+		assert.Equal(t, otev1.ClusterControllerDestCapture, cc.Spec.Destination)
+		assert.Equal(t, http.MethodPost, cc.Spec.Method)
+		c := archive.Capture{}
+		assert.Nil(t, json.Unmarshal([]byte(cc.Spec.Body), &c))
+		assert.Equal(t, archive.Capture{Rate: 0.1, Duration: time.Minute}, c)
+	}, map[string]otev1.ClusterControllerStatus{
+		"c1": {StatusCode: 200, Body: "capturing"},
+	})
+	ret, err := Capture(client, "c1", 0.1, time.Minute, time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, "capturing", ret)
+
+	go fakeResponse(t, client, func(cc *otev1.ClusterController) {
+		assert.Equal(t, http.MethodDelete, cc.Spec.Method)
+		assert.Equal(t, "", cc.Spec.Body)
+	}, map[string]otev1.ClusterControllerStatus{
+		"c1": {StatusCode: 400, Body: "message archive is disabled"},
+	})
+	_, err = Capture(client, "c1", 0, 0, time.Second)
+	assert.NotNil(t, err)
+}
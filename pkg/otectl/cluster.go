@@ -0,0 +1,215 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package otectl implements the operations behind the otectl command line tool.
+package otectl
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/config"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned"
+)
+
+const (
+	treeBranch     = "├── "
+	treeLastBranch = "└── "
+	treeIndent     = "│   "
+	treeLastIndent = "    "
+)
+
+// ClusterTreeNode is a node of the cluster tree built from Cluster crds.
+type ClusterTreeNode struct {
+	Name    string
+	Cluster *otev1.Cluster
+	Childs  []*ClusterTreeNode
+}
+
+// ListClusters lists all Cluster crds registered in the root cluster, sorted by name.
+func ListClusters(client oteclient.Interface) ([]otev1.Cluster, error) {
+	list, err := client.OteV1().Clusters(otev1.ClusterNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list clusters failed: %v", err)
+	}
+	clusters := list.Items
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].ObjectMeta.Name < clusters[j].ObjectMeta.Name
+	})
+	return clusters, nil
+}
+
+// GetCluster gets a Cluster crd by name.
+func GetCluster(client oteclient.Interface, name string) (*otev1.Cluster, error) {
+	cluster, err := client.OteV1().Clusters(otev1.ClusterNamespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("get cluster %s failed: %v", name, err)
+	}
+	return cluster, nil
+}
+
+// BuildClusterTree builds a tree rooted at the root cluster by parent name of clusters.
+// A cluster whose parent is not registered is hung under the root.
+func BuildClusterTree(clusters []otev1.Cluster) *ClusterTreeNode {
+	root := &ClusterTreeNode{Name: config.RootClusterName}
+	nodes := map[string]*ClusterTreeNode{
+		config.RootClusterName: root,
+	}
+	for i := range clusters {
+		name := clusters[i].ObjectMeta.Name
+		nodes[name] = &ClusterTreeNode{
+			Name:    name,
+			Cluster: &clusters[i],
+		}
+	}
+
+	for i := range clusters {
+		node := nodes[clusters[i].ObjectMeta.Name]
+		parent, ok := nodes[clusters[i].Status.ParentName]
+		if !ok || parent == node {
+			parent = root
+		}
+		parent.Childs = append(parent.Childs, node)
+	}
+
+	for _, node := range nodes {
+		sort.Slice(node.Childs, func(i, j int) bool {
+			return node.Childs[i].Name < node.Childs[j].Name
+		})
+	}
+	return root
+}
+
+// PrintClusterTree writes the cluster tree to w.
+func PrintClusterTree(w io.Writer, root *ClusterTreeNode) {
+	if root == nil {
+		return
+	}
+	fmt.Fprintln(w, root.label())
+	printChilds(w, root, "")
+}
+
+func printChilds(w io.Writer, node *ClusterTreeNode, prefix string) {
+	for i, child := range node.Childs {
+		branch, indent := treeBranch, treeIndent
+		if i == len(node.Childs)-1 {
+			branch, indent = treeLastBranch, treeLastIndent
+		}
+		fmt.Fprintf(w, "%s%s%s\n", prefix, branch, child.label())
+		printChilds(w, child, prefix+indent)
+	}
+}
+
+func (n *ClusterTreeNode) label() string {
+	if n.Cluster == nil {
+		return n.Name
+	}
+	return fmt.Sprintf("%s (%s)", n.Name, clusterStatus(n.Cluster))
+}
+
+// PrintClusters writes clusters to w as a table.
+func PrintClusters(w io.Writer, clusters []otev1.Cluster) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tPARENT\tLISTEN\tSTATUS\tLAST-UPDATE")
+	for i := range clusters {
+		c := &clusters[i]
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", c.ObjectMeta.Name, c.Status.ParentName,
+			c.Status.Listen, clusterStatus(c), since(c.Status.Timestamp))
+	}
+	return tw.Flush()
+}
+
+// DescribeCluster writes the detail of a cluster to w.
+// clusters are all registered clusters, used to find childs of the cluster.
+func DescribeCluster(w io.Writer, cluster *otev1.Cluster, clusters []otev1.Cluster) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Name:\t%s\n", cluster.ObjectMeta.Name)
+	fmt.Fprintf(tw, "UserDefineName:\t%s\n", cluster.Spec.Name)
+	fmt.Fprintf(tw, "Parent:\t%s\n", cluster.Status.ParentName)
+	fmt.Fprintf(tw, "Listen:\t%s\n", cluster.Status.Listen)
+	fmt.Fprintf(tw, "Status:\t%s\n", clusterStatus(cluster))
+	fmt.Fprintf(tw, "Version:\t%s\n", cluster.Status.Version)
+	fmt.Fprintf(tw, "Protocol:\t%d\n", cluster.Status.Protocol)
+	fmt.Fprintf(tw, "ClockSkew:\t%v\n", time.Duration(cluster.Status.ClockSkew)*time.Millisecond)
+	fmt.Fprintf(tw, "LastUpdate:\t%s\n", since(cluster.Status.Timestamp))
+
+	var childs []string
+	for i := range clusters {
+		if clusters[i].Status.ParentName == cluster.ObjectMeta.Name {
+			childs = append(childs, clusters[i].ObjectMeta.Name)
+		}
+	}
+	sort.Strings(childs)
+	fmt.Fprintf(tw, "Childs:\t%v\n", childs)
+
+	if c := cluster.Status.Capabilities; c != nil {
+		fmt.Fprintln(tw, "Capabilities:")
+		fmt.Fprintf(tw, "  Commands:\t%v\n", c.Commands)
+		fmt.Fprintf(tw, "  Destinations:\t%v\n", c.Destinations)
+		fmt.Fprintf(tw, "  Protocols:\t%d-%d\n", c.MinProtocol, c.Protocol)
+		fmt.Fprintf(tw, "  ReportEncodings:\t%v\n", c.ReportEncodings)
+		gates := make([]string, 0, len(c.FeatureGates))
+		for name, enabled := range c.FeatureGates {
+			gates = append(gates, fmt.Sprintf("%s=%t", name, enabled))
+		}
+		sort.Strings(gates)
+		fmt.Fprintf(tw, "  FeatureGates:\t%v\n", gates)
+	}
+	if len(cluster.Status.Conditions) > 0 {
+		fmt.Fprintln(tw, "Conditions:")
+		for _, cond := range cluster.Status.Conditions {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", cond.Type, cond.Status, cond.Reason, cond.Message)
+		}
+	}
+	fmt.Fprintln(tw, "Capacity:")
+	printResource(tw, cluster.Status.Capacity)
+	fmt.Fprintln(tw, "Allocatable:")
+	printResource(tw, cluster.Status.Allocatable)
+	return tw.Flush()
+}
+
+func printResource(w io.Writer, res map[corev1.ResourceName]*resource.Quantity) {
+	names := make([]string, 0, len(res))
+	for name := range res {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s:\t%s\n", name, res[corev1.ResourceName(name)].String())
+	}
+}
+
+func clusterStatus(c *otev1.Cluster) string {
+	if c.Status.Status == "" {
+		return "unknown"
+	}
+	return c.Status.Status
+}
+
+func since(timestamp int64) string {
+	if timestamp == 0 {
+		return "<unknown>"
+	}
+	return time.Since(time.Unix(timestamp, 0)).Truncate(time.Second).String()
+}
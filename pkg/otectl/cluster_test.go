@@ -0,0 +1,142 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otectl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	otefake "github.com/baidu/ote-stack/pkg/k8sclient/fake"
+)
+
+func newTestCluster(name, parent, status string) *otev1.Cluster {
+	return &otev1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: otev1.ClusterNamespace,
+		},
+		Spec: otev1.ClusterSpec{
+			Name: name,
+		},
+		Status: otev1.ClusterStatus{
+			ParentName: parent,
+			Status:     status,
+			Listen:     ":8287",
+		},
+	}
+}
+
+func TestListClusters(t *testing.T) {
+	client := otefake.NewSimpleClientset(
+		newTestCluster("c2", "Root", otev1.ClusterStatusOnline),
+		newTestCluster("c1", "Root", otev1.ClusterStatusOnline),
+	)
+	clusters, err := ListClusters(client)
+	assert.Nil(t, err)
+	assert.Len(t, clusters, 2)
+	assert.Equal(t, "c1", clusters[0].ObjectMeta.Name)
+
+	cluster, err := GetCluster(client, "c2")
+	assert.Nil(t, err)
+	assert.Equal(t, "c2", cluster.ObjectMeta.Name)
+
+	_, err = GetCluster(client, "c3")
+	assert.NotNil(t, err)
+}
+
+func TestBuildClusterTree(t *testing.T) {
+	clusters := []otev1.Cluster{
+		*newTestCluster("c1", "Root", otev1.ClusterStatusOnline),
+		*newTestCluster("c11", "c1", otev1.ClusterStatusOffline),
+		*newTestCluster("c2", "Root", ""),
+		*newTestCluster("c3", "unknown", otev1.ClusterStatusOnline),
+	}
+
+	root := BuildClusterTree(clusters)
+	assert.Equal(t, "Root", root.Name)
+	assert.Len(t, root.Childs, 3)
+	assert.Equal(t, "c1", root.Childs[0].Name)
+	assert.Len(t, root.Childs[0].Childs, 1)
+	assert.Equal(t, "c11", root.Childs[0].Childs[0].Name)
+	assert.Equal(t, "c3", root.Childs[2].Name)
+
+	buf := &bytes.Buffer{}
+	PrintClusterTree(buf, root)
+	expect := strings.Join([]string{
+		"Root",
+		"├── c1 (online)",
+		"│   └── c11 (offline)",
+		"├── c2 (unknown)",
+		"└── c3 (online)",
+		"",
+	}, "\n")
+	assert.Equal(t, expect, buf.String())
+}
+
+func TestPrintClusters(t *testing.T) {
+	clusters := []otev1.Cluster{
+		*newTestCluster("c1", "Root", otev1.ClusterStatusOnline),
+	}
+	buf := &bytes.Buffer{}
+	assert.Nil(t, PrintClusters(buf, clusters))
+	assert.Contains(t, buf.String(), "NAME")
+	assert.Contains(t, buf.String(), "c1")
+	assert.Contains(t, buf.String(), "online")
+}
+
+func TestDescribeCluster(t *testing.T) {
+	cpu := resource.MustParse("4")
+	c1 := newTestCluster("c1", "Root", otev1.ClusterStatusOnline)
+	c1.Status.Capacity = map[corev1.ResourceName]*resource.Quantity{
+		corev1.ResourceCPU: &cpu,
+	}
+	clusters := []otev1.Cluster{
+		*c1,
+		*newTestCluster("c11", "c1", otev1.ClusterStatusOnline),
+	}
+
+	buf := &bytes.Buffer{}
+	c1.Status.ClockSkew = -45000
+	c1.Status.Conditions = []otev1.ClusterCondition{{
+		Type:    otev1.ClusterConditionClockSkewed,
+		Status:  corev1.ConditionTrue,
+		Reason:  "ClockSkewed",
+		Message: "clock is 45s behind root",
+	}}
+	c1.Status.Capabilities = &otev1.ClusterCapabilities{
+		Destinations: []string{"api", "exec"},
+		Protocol:     2,
+		MinProtocol:  1,
+		FeatureGates: map[string]bool{"B": false, "A": true},
+	}
+	assert.Nil(t, DescribeCluster(buf, c1, clusters))
+	assert.Contains(t, buf.String(), "-45s")
+	assert.Contains(t, buf.String(), "[api exec]")
+	assert.Contains(t, buf.String(), "1-2")
+	assert.Contains(t, buf.String(), "[A=true B=false]")
+	assert.Contains(t, buf.String(), "clock is 45s behind root")
+	assert.Contains(t, buf.String(), "[c11]")
+	assert.Contains(t, buf.String(), "cpu:")
+	assert.Contains(t, buf.String(), "4")
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otectl
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned"
+)
+
+// Decommission marks cluster name to be decommissioned by the decommission controller at now.
+// Workloads of the cluster are hinted to migrate to clusters matched by migrateTo if it is set.
+func Decommission(client oteclient.Interface, name, migrateTo string, now time.Time) error {
+	cluster, err := GetCluster(client, name)
+	if err != nil {
+		return err
+	}
+	if _, ok := cluster.Annotations[otev1.ClusterDecommissionAnnotation]; ok {
+		return fmt.Errorf("cluster %s is already being decommissioned", name)
+	}
+	if cluster.Annotations == nil {
+		cluster.Annotations = make(map[string]string)
+	}
+	cluster.Annotations[otev1.ClusterDecommissionAnnotation] = now.UTC().Format(time.RFC3339)
+	if migrateTo != "" {
+		cluster.Annotations[otev1.ClusterMigrateToAnnotation] = migrateTo
+	}
+	if _, err := client.OteV1().Clusters(otev1.ClusterNamespace).Update(cluster); err != nil {
+		return fmt.Errorf("decommission cluster %s failed: %v", name, err)
+	}
+	return nil
+}
+
+// WaitDecommissioned writes phases of decommission of cluster name to w
+// until the cluster is unregistered, or timeout.
+func WaitDecommissioned(client oteclient.Interface, name string,
+	timeout, interval time.Duration, w io.Writer) error {
+	deadline := time.Now().Add(timeout)
+	phase := ""
+	for {
+		cluster, err := client.OteV1().Clusters(otev1.ClusterNamespace).Get(name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			fmt.Fprintf(w, "cluster %s is unregistered\n", name)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("get cluster %s failed: %v", name, err)
+		}
+		if p := cluster.Annotations[otev1.ClusterDecommissionPhaseAnnotation]; p != phase {
+			phase = p
+			fmt.Fprintf(w, "%s: %s\n", phase, cluster.Annotations[otev1.ClusterDecommissionMessageAnnotation])
+		}
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("cluster %s is not unregistered in %v, phase %q", name, timeout, phase)
+		}
+		time.Sleep(interval)
+	}
+}
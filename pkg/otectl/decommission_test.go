@@ -0,0 +1,60 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otectl
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	otefake "github.com/baidu/ote-stack/pkg/k8sclient/fake"
+)
+
+func TestDecommission(t *testing.T) {
+	client := otefake.NewSimpleClientset(newTestCluster("c1", "Root", otev1.ClusterStatusOnline))
+	now := time.Date(2019, 11, 1, 10, 0, 0, 0, time.UTC)
+
+	assert.NotNil(t, Decommission(client, "c2", "", now))
+	assert.Nil(t, Decommission(client, "c1", "c.*", now))
+	cluster, err := GetCluster(client, "c1")
+	assert.Nil(t, err)
+	assert.Equal(t, "2019-11-01T10:00:00Z", cluster.Annotations[otev1.ClusterDecommissionAnnotation])
+	assert.Equal(t, "c.*", cluster.Annotations[otev1.ClusterMigrateToAnnotation])
+	assert.NotNil(t, Decommission(client, "c1", "", now))
+}
+
+func TestWaitDecommissioned(t *testing.T) {
+	cluster := newTestCluster("c1", "Root", otev1.ClusterStatusOnline)
+	cluster.Annotations = map[string]string{
+		otev1.ClusterDecommissionPhaseAnnotation:   "Blocked",
+		otev1.ClusterDecommissionMessageAnnotation: "child clusters [c2] are online",
+	}
+	client := otefake.NewSimpleClientset(cluster)
+
+	w := &bytes.Buffer{}
+	assert.NotNil(t, WaitDecommissioned(client, "c1", 0, time.Millisecond, w))
+	assert.Equal(t, "Blocked: child clusters [c2] are online\n", w.String())
+
+	assert.Nil(t, client.OteV1().Clusters(otev1.ClusterNamespace).Delete("c1", &metav1.DeleteOptions{}))
+	w.Reset()
+	assert.Nil(t, WaitDecommissioned(client, "c1", 0, time.Millisecond, w))
+	assert.Equal(t, "cluster c1 is unregistered\n", w.String())
+}
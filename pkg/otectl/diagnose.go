@@ -0,0 +1,50 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otectl
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned"
+)
+
+// DiagnoseBundleName returns the default file name of the support bundle of cluster.
+func DiagnoseBundleName(cluster string, now time.Time) string {
+	return fmt.Sprintf("%s-diagnose-%s.tar.gz", cluster, now.Format("20060102150405"))
+}
+
+// Diagnose asks the cluster to collect a support bundle, and writes the tar.gz bundle to w.
+func Diagnose(client oteclient.Interface, cluster string, w io.Writer, timeout time.Duration) error {
+	status, err := runPodTask(client, cluster, &Task{
+		Destination: otev1.ClusterControllerDestDiagnose,
+		Method:      http.MethodGet,
+	}, timeout)
+	if err != nil {
+		return err
+	}
+	bundle, err := base64.StdEncoding.DecodeString(status.Body)
+	if err != nil {
+		return fmt.Errorf("decode support bundle failed: %v", err)
+	}
+	_, err = w.Write(bundle)
+	return err
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otectl
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	otefake "github.com/baidu/ote-stack/pkg/k8sclient/fake"
+)
+
+func TestDiagnose(t *testing.T) {
+	assert.Equal(t, "c1-diagnose-20191010080910.tar.gz",
+		DiagnoseBundleName("c1", time.Date(2019, 10, 10, 8, 9, 10, 0, time.UTC)))
+
+	client := otefake.NewSimpleClientset()
+	go fakeResponse(t, client, func(cc *otev1.ClusterController) {
+		assert.Equal(t, otev1.ClusterControllerDestDiagnose, cc.Spec.Destination)
+	}, map[string]otev1.ClusterControllerStatus{
+		"c1": {StatusCode: 200, Body: base64.StdEncoding.EncodeToString([]byte("bundle"))},
+	})
+	buf := &bytes.Buffer{}
+	assert.Nil(t, Diagnose(client, "c1", buf, time.Second))
+	assert.Equal(t, "bundle", buf.String())
+
+	go fakeResponse(t, client, nil, map[string]otev1.ClusterControllerStatus{
+		"c1": {StatusCode: 200, Body: "invalid base64"},
+	})
+	assert.NotNil(t, Diagnose(client, "c1", buf, time.Second))
+}
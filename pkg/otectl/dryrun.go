@@ -0,0 +1,113 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otectl
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/taskbuilder"
+)
+
+// Resolution is the clusters matched by a selector.
+type Resolution struct {
+	Selector string
+	// Online is the online clusters matched, which will receive the operation.
+	Online []string
+	// Offline is the offline clusters matched, which won't receive the operation.
+	Offline []string
+}
+
+// ResolveSelector resolves the selector against the registered clusters.
+func ResolveSelector(selector string, clusters []otev1.Cluster) *Resolution {
+	ret := &Resolution{
+		Selector: selector,
+		Online:   SelectClusters(selector, clusters, true),
+	}
+	online := make(map[string]bool, len(ret.Online))
+	for _, name := range ret.Online {
+		online[name] = true
+	}
+	for _, name := range SelectClusters(selector, clusters, false) {
+		if !online[name] {
+			ret.Offline = append(ret.Offline, name)
+		}
+	}
+	return ret
+}
+
+// PrintResolution writes the clusters matched by selector to w.
+func PrintResolution(w io.Writer, r *Resolution) {
+	fmt.Fprintf(w, "Target clusters (%d):\n", len(r.Online))
+	for _, name := range r.Online {
+		fmt.Fprintf(w, "  %s\n", name)
+	}
+	if len(r.Offline) != 0 {
+		fmt.Fprintf(w, "Offline clusters matched, would not receive (%d):\n", len(r.Offline))
+		for _, name := range r.Offline {
+			fmt.Fprintf(w, "  %s\n", name)
+		}
+	}
+}
+
+// LoadClusterController reads a ClusterController crd in yaml or json from r.
+func LoadClusterController(r io.Reader) (*otev1.ClusterController, error) {
+	cc := &otev1.ClusterController{}
+	if err := yaml.NewYAMLOrJSONDecoder(r, 4096).Decode(cc); err != nil {
+		return nil, fmt.Errorf("decode clustercontroller failed: %v", err)
+	}
+	return cc, nil
+}
+
+// TaskFromClusterController returns the task of a ClusterController crd, expanded from its typed task if set.
+func TaskFromClusterController(cc *otev1.ClusterController) (*Task, error) {
+	spec := cc.Spec.DeepCopy()
+	if err := taskbuilder.Expand(spec); err != nil {
+		return nil, err
+	}
+	return &Task{
+		Selector:    spec.ClusterSelector,
+		Destination: spec.Destination,
+		Method:      spec.Method,
+		URI:         spec.URL,
+		Body:        spec.Body,
+
+		IdempotencyKey: spec.IdempotencyKey,
+	}, nil
+}
+
+// PrintDryRun writes the operation of task and the clusters it would be sent to.
+func PrintDryRun(w io.Writer, task *Task, clusters []otev1.Cluster) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Selector:\t%s\n", task.Selector)
+	fmt.Fprintf(tw, "Destination:\t%s\n", task.Destination)
+	fmt.Fprintf(tw, "Method:\t%s\n", task.Method)
+	fmt.Fprintf(tw, "URI:\t%s\n", task.URI)
+	fmt.Fprintf(tw, "Body:\t%d bytes\n", len(task.Body))
+	if task.IdempotencyKey != "" {
+		fmt.Fprintf(tw, "IdempotencyKey:\t%s\n", task.IdempotencyKey)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	PrintResolution(w, ResolveSelector(task.Selector, clusters))
+	return nil
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otectl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+)
+
+var dryRunClusters = []otev1.Cluster{
+	*newTestCluster("c1", "Root", otev1.ClusterStatusOnline),
+	*newTestCluster("c2", "Root", otev1.ClusterStatusOffline),
+	*newTestCluster("d1", "Root", otev1.ClusterStatusOnline),
+}
+
+func TestResolveSelector(t *testing.T) {
+	r := ResolveSelector("c", dryRunClusters)
+	assert.Equal(t, []string{"c1"}, r.Online)
+	assert.Equal(t, []string{"c2"}, r.Offline)
+
+	buf := &bytes.Buffer{}
+	PrintResolution(buf, r)
+	expect := strings.Join([]string{
+		"Target clusters (1):",
+		"  c1",
+		"Offline clusters matched, would not receive (1):",
+		"  c2",
+		"",
+	}, "\n")
+	assert.Equal(t, expect, buf.String())
+}
+
+func TestDryRunClusterController(t *testing.T) {
+	file := `
+apiVersion: ote.baidu.com/v1
+kind: ClusterController
+metadata:
+  name: deploy-nginx
+  namespace: kube-system
+spec:
+  clusterSelector: c1,d1
+  destination: api
+  method: POST
+  url: /apis/apps/v1/namespaces/default/deployments
+  body: "{}"
+`
+	cc, err := LoadClusterController(strings.NewReader(file))
+	assert.Nil(t, err)
+	assert.Equal(t, "deploy-nginx", cc.ObjectMeta.Name)
+
+	buf := &bytes.Buffer{}
+	task, err := TaskFromClusterController(cc)
+	assert.Nil(t, err)
+	assert.Nil(t, PrintDryRun(buf, task, dryRunClusters))
+	assert.Contains(t, buf.String(), "Method:       POST")
+	assert.Contains(t, buf.String(), "Body:         2 bytes")
+	assert.Contains(t, buf.String(), "Target clusters (2):\n  c1\n  d1\n")
+
+	// typed task is expanded.
+	cc.Spec.Task = &otev1.ClusterControllerTask{Type: otev1.ClusterControllerTaskDelete,
+		Object: &otev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Namespace: "ns1", Name: "p1"}}
+	task, err = TaskFromClusterController(cc)
+	assert.Nil(t, err)
+	assert.Equal(t, "/api/v1/namespaces/ns1/pods/p1", task.URI)
+	cc.Spec.Task.Object = nil
+	_, err = TaskFromClusterController(cc)
+	assert.NotNil(t, err)
+
+	_, err = LoadClusterController(strings.NewReader("{invalid"))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otectl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned"
+)
+
+// PodOption locates a container of a pod in an edge cluster.
+type PodOption struct {
+	Cluster   string
+	Namespace string
+	Pod       string
+	Container string
+}
+
+// LogOption is the option to get logs of a pod.
+type LogOption struct {
+	TailLines int64
+	Previous  bool
+}
+
+// ExecURI makes the uri to exec command in the pod.
+func ExecURI(pod *PodOption, command []string) string {
+	query := url.Values{}
+	for _, c := range command {
+		query.Add("command", c)
+	}
+	query.Set("stdout", "true")
+	query.Set("stderr", "true")
+	if pod.Container != "" {
+		query.Set("container", pod.Container)
+	}
+	return fmt.Sprintf("/api/v1/namespaces/%s/pods/%s/exec?%s",
+		pod.Namespace, pod.Pod, query.Encode())
+}
+
+// LogURI makes the uri to get logs of the pod.
+func LogURI(pod *PodOption, option *LogOption) string {
+	query := url.Values{}
+	if pod.Container != "" {
+		query.Set("container", pod.Container)
+	}
+	if option.TailLines > 0 {
+		query.Set("tailLines", strconv.FormatInt(option.TailLines, 10))
+	}
+	if option.Previous {
+		query.Set("previous", "true")
+	}
+	uri := fmt.Sprintf("/api/v1/namespaces/%s/pods/%s/log", pod.Namespace, pod.Pod)
+	if len(query) != 0 {
+		uri += "?" + query.Encode()
+	}
+	return uri
+}
+
+// Exec runs command in the pod through the tunnel, writes output of the command
+// to stdout and stderr, and returns the exit code of the command.
+func Exec(client oteclient.Interface, pod *PodOption, command []string,
+	stdout, stderr io.Writer, timeout time.Duration) (int, error) {
+	status, err := runPodTask(client, pod.Cluster, &Task{
+		Destination: otev1.ClusterControllerDestExec,
+		Method:      http.MethodPost,
+		URI:         ExecURI(pod, command),
+	}, timeout)
+	if err != nil {
+		return -1, err
+	}
+	return DemuxStream([]byte(status.Body), stdout, stderr)
+}
+
+// Logs gets logs of the pod through the tunnel and writes them to w.
+func Logs(client oteclient.Interface, pod *PodOption, option *LogOption,
+	w io.Writer, timeout time.Duration) error {
+	status, err := runPodTask(client, pod.Cluster, &Task{
+		Destination: otev1.ClusterControllerDestLog,
+		Method:      http.MethodGet,
+		URI:         LogURI(pod, option),
+	}, timeout)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, status.Body)
+	return err
+}
+
+func runPodTask(client oteclient.Interface, cluster string,
+	task *Task, timeout time.Duration) (*otev1.ClusterControllerStatus, error) {
+	task.Selector = ClusterSelector(cluster)
+	ret, err := RunTask(client, task, []string{cluster}, timeout)
+	if err != nil {
+		return nil, err
+	}
+	status, ok := ret[cluster]
+	if !ok {
+		return nil, fmt.Errorf("no response from cluster %s in %v", cluster, timeout)
+	}
+	if status.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cluster %s responses %d: %s", cluster, status.StatusCode, status.Body)
+	}
+	return &status, nil
+}
+
+// DemuxStream writes the multiplexed stream returned by exec to stdout and stderr,
+// and returns the exit code from the error channel.
+func DemuxStream(body []byte, stdout, stderr io.Writer) (int, error) {
+	frames, err := handler.DecodeStream(body)
+	if err != nil {
+		return -1, err
+	}
+
+	exitCode := 0
+	for _, frame := range frames {
+		switch frame.Channel {
+		case handler.StreamStdout:
+			_, err = stdout.Write(frame.Data)
+		case handler.StreamStderr:
+			_, err = stderr.Write(frame.Data)
+		case handler.StreamError:
+			exitCode, err = exitCodeFromStatus(frame.Data)
+		}
+		if err != nil {
+			return -1, err
+		}
+	}
+	return exitCode, nil
+}
+
+// exitCodeFromStatus parses the metav1.Status sent by kube-apiserver in error channel.
+func exitCodeFromStatus(data []byte) (int, error) {
+	status := &metav1.Status{}
+	if err := json.Unmarshal(data, status); err != nil {
+		return -1, fmt.Errorf("unmarshal exec status failed: %v", err)
+	}
+	if status.Status == metav1.StatusSuccess {
+		return 0, nil
+	}
+	if status.Reason == "NonZeroExitCode" && status.Details != nil {
+		for _, cause := range status.Details.Causes {
+			if cause.Type == "ExitCode" {
+				code, err := strconv.Atoi(cause.Message)
+				if err != nil {
+					return -1, fmt.Errorf("invalid exit code %s", cause.Message)
+				}
+				return code, nil
+			}
+		}
+	}
+	return -1, fmt.Errorf("exec failed: %s", status.Message)
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otectl
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+	otefake "github.com/baidu/ote-stack/pkg/k8sclient/fake"
+)
+
+var testPod = &PodOption{
+	Cluster:   "c1",
+	Namespace: "default",
+	Pod:       "p1",
+	Container: "app",
+}
+
+func TestURI(t *testing.T) {
+	assert.Equal(t,
+		"/api/v1/namespaces/default/pods/p1/exec?command=ls&command=-l&container=app&stderr=true&stdout=true",
+		ExecURI(testPod, []string{"ls", "-l"}))
+	assert.Equal(t,
+		"/api/v1/namespaces/default/pods/p1/log?container=app&previous=true&tailLines=10",
+		LogURI(testPod, &LogOption{TailLines: 10, Previous: true}))
+	assert.Equal(t, "/api/v1/namespaces/default/pods/p2/log",
+		LogURI(&PodOption{Namespace: "default", Pod: "p2"}, &LogOption{}))
+}
+
+func TestDemuxStream(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Frames   []handler.StreamFrame
+		Stdout   string
+		Stderr   string
+		ExitCode int
+		Err      bool
+	}{
+		{
+			Name: "success",
+			Frames: []handler.StreamFrame{
+				{Channel: handler.StreamStdout, Data: []byte("a")},
+				{Channel: handler.StreamStderr, Data: []byte("b")},
+				{Channel: handler.StreamStdout, Data: []byte("c")},
+				{Channel: handler.StreamError, Data: []byte(`{"status":"Success"}`)},
+			},
+			Stdout: "ac",
+			Stderr: "b",
+		},
+		{
+			Name: "non zero exit code",
+			Frames: []handler.StreamFrame{
+				{Channel: handler.StreamError, Data: []byte(`{"status":"Failure","reason":"NonZeroExitCode",` +
+					`"details":{"causes":[{"reason":"ExitCode","message":"2"}]}}`)},
+			},
+			ExitCode: 2,
+		},
+		{
+			Name: "exec failed",
+			Frames: []handler.StreamFrame{
+				{Channel: handler.StreamError, Data: []byte(`{"status":"Failure","message":"no such file"}`)},
+			},
+			ExitCode: -1,
+			Err:      true,
+		},
+	}
+
+	for _, c := range cases {
+		body, err := handler.EncodeStream(c.Frames)
+		assert.Nil(t, err)
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		code, err := DemuxStream(body, stdout, stderr)
+		assert.Equal(t, c.Err, err != nil, c.Name)
+		assert.Equal(t, c.ExitCode, code, c.Name)
+		assert.Equal(t, c.Stdout, stdout.String(), c.Name)
+		assert.Equal(t, c.Stderr, stderr.String(), c.Name)
+	}
+
+	_, err := DemuxStream([]byte("invalid"), &bytes.Buffer{}, &bytes.Buffer{})
+	assert.NotNil(t, err)
+}
+
+func TestExec(t *testing.T) {
+	client := otefake.NewSimpleClientset()
+	body, _ := handler.EncodeStream([]handler.StreamFrame{
+		{Channel: handler.StreamStdout, Data: []byte("hello\n")},
+	})
+	go fakeResponse(t, client, func(cc *otev1.ClusterController) {
+		assert.Equal(t, otev1.ClusterControllerDestExec, cc.Spec.Destination)
+		assert.Equal(t, "^c1$", cc.Spec.ClusterSelector)
+	}, map[string]otev1.ClusterControllerStatus{
+		"c1": {StatusCode: 200, Body: string(body)},
+	})
+
+	stdout := &bytes.Buffer{}
+	code, err := Exec(client, testPod, []string{"echo", "hello"}, stdout, &bytes.Buffer{}, time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "hello\n", stdout.String())
+}
+
+func TestLogs(t *testing.T) {
+	client := otefake.NewSimpleClientset()
+	go fakeResponse(t, client, func(cc *otev1.ClusterController) {
+		assert.Equal(t, otev1.ClusterControllerDestLog, cc.Spec.Destination)
+	}, map[string]otev1.ClusterControllerStatus{
+		"c1": {StatusCode: 200, Body: "log line\n"},
+	})
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, Logs(client, testPod, &LogOption{}, buf, time.Second))
+	assert.Equal(t, "log line\n", buf.String())
+
+	// error response
+	go fakeResponse(t, client, nil, map[string]otev1.ClusterControllerStatus{
+		"c1": {StatusCode: 404, Body: "pod not found"},
+	})
+	assert.NotNil(t, Logs(client, testPod, &LogOption{}, buf, time.Second))
+
+	// no response
+	assert.NotNil(t, Logs(client, testPod, &LogOption{}, buf, 50*time.Millisecond))
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otectl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+)
+
+// LoadJob reads a Job in yaml or json from r.
+func LoadJob(r io.Reader) (*batchv1.Job, error) {
+	job := &batchv1.Job{}
+	if err := yaml.NewYAMLOrJSONDecoder(r, 4096).Decode(job); err != nil {
+		return nil, fmt.Errorf("decode job failed: %v", err)
+	}
+	if job.Kind != "" && job.Kind != "Job" {
+		return nil, fmt.Errorf("kind %s is not Job", job.Kind)
+	}
+	if job.Name == "" {
+		return nil, fmt.Errorf("name of job is required")
+	}
+	if job.Namespace == "" {
+		job.Namespace = "default"
+	}
+	return job, nil
+}
+
+// JobTask returns the task to run the job on clusters matched by selector.
+func JobTask(selector string, job *batchv1.Job) (*Task, error) {
+	body, err := json.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+	return &Task{
+		Selector:    selector,
+		Destination: otev1.ClusterControllerDestJob,
+		Method:      http.MethodPost,
+		Body:        string(body),
+	}, nil
+}
+
+// JobStatusTask returns the task to get the status and results of job on clusters.
+func JobStatusTask(selector, namespace, name string) *Task {
+	return &Task{
+		Selector:    selector,
+		Destination: otev1.ClusterControllerDestJob,
+		Method:      http.MethodGet,
+		URI:         namespace + "/" + name,
+	}
+}
+
+// JobDeleteTask returns the task to delete the job with its pods on clusters.
+func JobDeleteTask(selector, namespace, name string) *Task {
+	return &Task{
+		Selector:    selector,
+		Destination: otev1.ClusterControllerDestJob,
+		Method:      http.MethodDelete,
+		URI:         namespace + "/" + name,
+	}
+}
+
+// JobResult is the status of job of a cluster, Err is set if no status is got.
+type JobResult struct {
+	Status *handler.JobStatus
+	Err    string
+}
+
+// ParseJobResults returns the job status by cluster from responses of clusters.
+// Clusters in expect without response are included.
+func ParseJobResults(results map[string]otev1.ClusterControllerStatus, expect []string) map[string]*JobResult {
+	ret := make(map[string]*JobResult, len(expect))
+	for _, name := range expect {
+		ret[name] = &JobResult{Err: "no response"}
+	}
+	for name, result := range results {
+		if result.StatusCode != http.StatusOK {
+			ret[name] = &JobResult{Err: fmt.Sprintf("%d %s", result.StatusCode, result.Body)}
+			continue
+		}
+		status := &handler.JobStatus{}
+		if err := json.Unmarshal([]byte(result.Body), status); err != nil {
+			ret[name] = &JobResult{Err: fmt.Sprintf("invalid response: %v", err)}
+			continue
+		}
+		ret[name] = &JobResult{Status: status}
+	}
+	return ret
+}
+
+// PrintJobResults writes the job status of each cluster to w, and results of pods if detail is true.
+// It returns the number of clusters the job finished on, and the number of clusters it succeeded on.
+func PrintJobResults(w io.Writer, results map[string]*JobResult, detail bool) (int, int) {
+	names := sortedJobClusters(results)
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "CLUSTER\tCOMPLETIONS\tACTIVE\tFAILED\tSTATUS")
+	finished, succeeded := 0, 0
+	for _, name := range names {
+		r := results[name]
+		if r.Status == nil {
+			fmt.Fprintf(tw, "%s\t-\t-\t-\t%s\n", name, r.Err)
+			continue
+		}
+		state := "Running"
+		switch {
+		case r.Status.Complete:
+			state = "Complete"
+			finished++
+			succeeded++
+		case r.Status.JobFailed:
+			state = "Failed " + r.Status.Reason
+			finished++
+		}
+		fmt.Fprintf(tw, "%s\t%d/%d\t%d\t%d\t%s\n", name, r.Status.Succeeded, r.Status.Completions,
+			r.Status.Active, r.Status.Failed, state)
+	}
+	tw.Flush()
+
+	if detail {
+		for _, name := range names {
+			status := results[name].Status
+			if status == nil {
+				continue
+			}
+			for _, pod := range sortedJobPods(status) {
+				p := status.Pods[pod]
+				fmt.Fprintf(w, "--- %s/%s (%s, exit code %d)\n", name, pod, p.Phase, p.ExitCode)
+				if p.Result != "" {
+					fmt.Fprintln(w, p.Result)
+				}
+			}
+		}
+	}
+	fmt.Fprintf(w, "%d clusters: %d finished, %d succeeded\n", len(names), finished, succeeded)
+	return finished, succeeded
+}
+
+// SaveJobResults writes the result of each pod to dir/<cluster>/<pod>.
+func SaveJobResults(dir string, results map[string]*JobResult) error {
+	for name, r := range results {
+		if r.Status == nil || len(r.Status.Pods) == 0 {
+			continue
+		}
+		clusterDir := filepath.Join(dir, name)
+		if err := os.MkdirAll(clusterDir, 0755); err != nil {
+			return fmt.Errorf("create dir for results of %s failed: %v", name, err)
+		}
+		for pod, p := range r.Status.Pods {
+			if err := ioutil.WriteFile(filepath.Join(clusterDir, pod), []byte(p.Result), 0644); err != nil {
+				return fmt.Errorf("save result of %s/%s failed: %v", name, pod, err)
+			}
+		}
+	}
+	return nil
+}
+
+func sortedJobClusters(results map[string]*JobResult) []string {
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedJobPods(status *handler.JobStatus) []string {
+	pods := make([]string, 0, len(status.Pods))
+	for pod := range status.Pods {
+		pods = append(pods, pod)
+	}
+	sort.Strings(pods)
+	return pods
+}
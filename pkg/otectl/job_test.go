@@ -0,0 +1,124 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otectl
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+)
+
+func TestLoadJob(t *testing.T) {
+	job, err := LoadJob(strings.NewReader(`
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: infer
+spec:
+  template:
+    spec:
+      restartPolicy: Never
+      containers:
+      - name: infer
+        image: infer:v1
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, "infer", job.Name)
+	assert.Equal(t, "default", job.Namespace)
+	assert.Equal(t, "infer:v1", job.Spec.Template.Spec.Containers[0].Image)
+
+	_, err = LoadJob(strings.NewReader("kind: Pod\nmetadata:\n  name: p1\n"))
+	assert.NotNil(t, err)
+	_, err = LoadJob(strings.NewReader("kind: Job\n"))
+	assert.NotNil(t, err)
+	_, err = LoadJob(strings.NewReader("{"))
+	assert.NotNil(t, err)
+}
+
+func TestJobTask(t *testing.T) {
+	job, _ := LoadJob(strings.NewReader(`{"metadata": {"name": "infer", "namespace": "ai"}}`))
+	task, err := JobTask("c1", job)
+	assert.Nil(t, err)
+	assert.Equal(t, otev1.ClusterControllerDestJob, task.Destination)
+	assert.Equal(t, http.MethodPost, task.Method)
+	sent := &batchv1.Job{}
+	assert.Nil(t, json.Unmarshal([]byte(task.Body), sent))
+	assert.Equal(t, "ai", sent.Namespace)
+
+	task = JobStatusTask("c1", "ai", "infer")
+	assert.Equal(t, http.MethodGet, task.Method)
+	assert.Equal(t, "ai/infer", task.URI)
+	task = JobDeleteTask("c1", "ai", "infer")
+	assert.Equal(t, http.MethodDelete, task.Method)
+}
+
+func TestJobResults(t *testing.T) {
+	complete, _ := json.Marshal(&handler.JobStatus{
+		Name: "infer", Namespace: "default", Completions: 1, Succeeded: 1, Complete: true,
+		Pods: map[string]*handler.JobPodResult{"p1": {Phase: "Succeeded", Result: "score=0.9"}},
+	})
+	failed, _ := json.Marshal(&handler.JobStatus{
+		Name: "infer", Namespace: "default", Completions: 1, Failed: 2, JobFailed: true, Reason: "BackoffLimitExceeded",
+		Pods: map[string]*handler.JobPodResult{"p2": {Phase: "Failed", ExitCode: 1}},
+	})
+	running, _ := json.Marshal(&handler.JobStatus{Name: "infer", Namespace: "default", Completions: 1, Active: 1})
+	results := map[string]otev1.ClusterControllerStatus{
+		"c1": {StatusCode: 200, Body: string(complete)},
+		"c2": {StatusCode: 200, Body: string(failed)},
+		"c3": {StatusCode: 200, Body: string(running)},
+		"c4": {StatusCode: 404, Body: "not found"},
+		"c5": {StatusCode: 200, Body: "{"},
+	}
+	jobs := ParseJobResults(results, []string{"c1", "c6"})
+	assert.Len(t, jobs, 6)
+	assert.True(t, jobs["c1"].Status.Finished())
+	assert.Equal(t, "404 not found", jobs["c4"].Err)
+	assert.Contains(t, jobs["c5"].Err, "invalid response")
+	assert.Equal(t, "no response", jobs["c6"].Err)
+
+	buf := &bytes.Buffer{}
+	finished, succeeded := PrintJobResults(buf, jobs, true)
+	assert.Equal(t, 2, finished)
+	assert.Equal(t, 1, succeeded)
+	assert.Contains(t, buf.String(), "c1       1/1          0       0       Complete")
+	assert.Contains(t, buf.String(), "c2       0/1          0       2       Failed BackoffLimitExceeded")
+	assert.Contains(t, buf.String(), "c3       0/1          1       0       Running")
+	assert.Contains(t, buf.String(), "--- c1/p1 (Succeeded, exit code 0)\nscore=0.9\n")
+	assert.Contains(t, buf.String(), "--- c2/p2 (Failed, exit code 1)\n")
+	assert.Contains(t, buf.String(), "6 clusters: 2 finished, 1 succeeded")
+
+	dir, err := ioutil.TempDir("", "job")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, SaveJobResults(dir, jobs))
+	data, err := ioutil.ReadFile(filepath.Join(dir, "c1", "p1"))
+	assert.Nil(t, err)
+	assert.Equal(t, "score=0.9", string(data))
+	_, err = os.Stat(filepath.Join(dir, "c3"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otectl
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/config"
+)
+
+const (
+	// DefaultTunnelPort is the default port of cloud tunnel of clustercontroller.
+	DefaultTunnelPort = "8287"
+	// JoinTokenEnv is the env of clustercontroller container which holds the join token.
+	JoinTokenEnv = "OTE_JOIN_TOKEN"
+
+	joinName     = "ote-clustercontroller"
+	joinTokenKey = "token"
+)
+
+// DefaultClusterControllerImage is the default image of clustercontroller.
+var DefaultClusterControllerImage = "ote-stack/clustercontroller:" + config.Version
+
+// JoinOption is the option to generate manifests for a new edge cluster.
+type JoinOption struct {
+	ClusterName    string
+	Parent         string // cloud tunnel address of parent cluster
+	Token          string
+	Image          string
+	TunnelListen   string
+	RemoteShimAddr string
+	HelmTillerAddr string
+}
+
+// DefaultParent returns the address of root cloud tunnel on the host of root apiserver.
+func DefaultParent(apiserverHost string) (string, error) {
+	u, err := url.Parse(apiserverHost)
+	if err != nil || u.Hostname() == "" {
+		return "", fmt.Errorf("can not get host of root apiserver %s", apiserverHost)
+	}
+	return net.JoinHostPort(u.Hostname(), DefaultTunnelPort), nil
+}
+
+// CheckJoinOption checks the option against the registered clusters.
+func CheckJoinOption(option *JoinOption, clusters []otev1.Cluster) error {
+	if option.ClusterName == "" {
+		return fmt.Errorf("cluster name is empty")
+	}
+	if config.IsRoot(option.ClusterName) {
+		return fmt.Errorf("cluster name %s is reserved for root", option.ClusterName)
+	}
+	if option.Parent == "" {
+		return fmt.Errorf("parent is empty")
+	}
+	for i := range clusters {
+		if clusters[i].Spec.Name == option.ClusterName {
+			return fmt.Errorf("cluster %s has already registered", option.ClusterName)
+		}
+	}
+	return nil
+}
+
+// JoinManifest writes the manifests to deploy clustercontroller in a new edge cluster.
+func JoinManifest(w io.Writer, option *JoinOption) error {
+	objs := []runtime.Object{
+		joinServiceAccount(),
+		joinClusterRole(),
+		joinClusterRoleBinding(),
+		joinSecret(option),
+		joinDeployment(option),
+	}
+	for i, obj := range objs {
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			return fmt.Errorf("marshal manifest failed: %v", err)
+		}
+		if i != 0 {
+			fmt.Fprintln(w, "---")
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func joinObjectMeta() metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      joinName,
+		Namespace: otev1.ClusterNamespace,
+		Labels:    map[string]string{"app": joinName},
+	}
+}
+
+func joinServiceAccount() *corev1.ServiceAccount {
+	return &corev1.ServiceAccount{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+		ObjectMeta: joinObjectMeta(),
+	}
+}
+
+// joinClusterRole allows all operations, since clustercontroller
+// transmits requests of parent to apiserver of the cluster.
+func joinClusterRole() *rbacv1.ClusterRole {
+	meta := joinObjectMeta()
+	meta.Namespace = ""
+	return &rbacv1.ClusterRole{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+		ObjectMeta: meta,
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{"*"},
+				Resources: []string{"*"},
+				Verbs:     []string{"*"},
+			},
+			{
+				NonResourceURLs: []string{"*"},
+				Verbs:           []string{"*"},
+			},
+		},
+	}
+}
+
+func joinClusterRoleBinding() *rbacv1.ClusterRoleBinding {
+	meta := joinObjectMeta()
+	meta.Namespace = ""
+	return &rbacv1.ClusterRoleBinding{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding"},
+		ObjectMeta: meta,
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: "rbac.authorization.k8s.io",
+			Kind:     "ClusterRole",
+			Name:     joinName,
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      "ServiceAccount",
+				Name:      joinName,
+				Namespace: otev1.ClusterNamespace,
+			},
+		},
+	}
+}
+
+func joinSecret(option *JoinOption) *corev1.Secret {
+	return &corev1.Secret{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: joinObjectMeta(),
+		Type:       corev1.SecretTypeOpaque,
+		StringData: map[string]string{joinTokenKey: option.Token},
+	}
+}
+
+func joinDeployment(option *JoinOption) *appsv1.Deployment {
+	image := option.Image
+	if image == "" {
+		image = DefaultClusterControllerImage
+	}
+	listen := option.TunnelListen
+	if listen == "" {
+		listen = ":" + DefaultTunnelPort
+	}
+	// empty kube-config makes clustercontroller use in-cluster config.
+	args := []string{
+		"--cluster-name=" + option.ClusterName,
+		"--parent-cluster=" + option.Parent,
+		"--tunnel-listen=" + listen,
+		"--kube-config=",
+	}
+	if option.RemoteShimAddr != "" {
+		args = append(args, "--remote-shim-endpoint="+option.RemoteShimAddr)
+	}
+	if option.HelmTillerAddr != "" {
+		args = append(args, "--helm-tiller-addr="+option.HelmTillerAddr)
+	}
+
+	replicas := int32(1)
+	labels := map[string]string{"app": joinName}
+	return &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: joinObjectMeta(),
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Strategy: appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: joinName,
+					// childs connect to the tunnel listened on host.
+					HostNetwork: true,
+					Containers: []corev1.Container{
+						{
+							Name:    "clustercontroller",
+							Image:   image,
+							Command: []string{"clustercontroller"},
+							Args:    args,
+							Env: []corev1.EnvVar{
+								{
+									Name: JoinTokenEnv,
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{Name: joinName},
+											Key:                  joinTokenKey,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
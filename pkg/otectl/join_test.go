@@ -0,0 +1,81 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otectl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	"sigs.k8s.io/yaml"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+)
+
+func TestDefaultParent(t *testing.T) {
+	parent, err := DefaultParent("https://192.168.0.2:6443")
+	assert.Nil(t, err)
+	assert.Equal(t, "192.168.0.2:8287", parent)
+
+	_, err = DefaultParent("")
+	assert.NotNil(t, err)
+}
+
+func TestCheckJoinOption(t *testing.T) {
+	c1 := newTestCluster("uuid-1", "Root", otev1.ClusterStatusOnline)
+	c1.Spec.Name = "c1"
+	clusters := []otev1.Cluster{*c1}
+
+	assert.Nil(t, CheckJoinOption(&JoinOption{ClusterName: "c2", Parent: "p:8287"}, clusters))
+	assert.NotNil(t, CheckJoinOption(&JoinOption{ClusterName: "c1", Parent: "p:8287"}, clusters))
+	assert.NotNil(t, CheckJoinOption(&JoinOption{ClusterName: "Root", Parent: "p:8287"}, clusters))
+	assert.NotNil(t, CheckJoinOption(&JoinOption{ClusterName: "c2"}, clusters))
+	assert.NotNil(t, CheckJoinOption(&JoinOption{Parent: "p:8287"}, clusters))
+}
+
+func TestJoinManifest(t *testing.T) {
+	buf := &bytes.Buffer{}
+	assert.Nil(t, JoinManifest(buf, &JoinOption{
+		ClusterName:    "c1",
+		Parent:         "192.168.0.2:8287",
+		Token:          "abcdef",
+		RemoteShimAddr: ":8262",
+	}))
+
+	docs := strings.Split(buf.String(), "---\n")
+	assert.Len(t, docs, 5)
+	assert.Contains(t, docs[0], "kind: ServiceAccount")
+	assert.Contains(t, docs[1], "kind: ClusterRole")
+	assert.Contains(t, docs[2], "kind: ClusterRoleBinding")
+	assert.Contains(t, docs[3], "token: abcdef")
+
+	deploy := &appsv1.Deployment{}
+	assert.Nil(t, yaml.Unmarshal([]byte(docs[4]), deploy))
+	assert.Equal(t, otev1.ClusterNamespace, deploy.ObjectMeta.Namespace)
+	container := deploy.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, DefaultClusterControllerImage, container.Image)
+	assert.Equal(t, []string{
+		"--cluster-name=c1",
+		"--parent-cluster=192.168.0.2:8287",
+		"--tunnel-listen=:8287",
+		"--kube-config=",
+		"--remote-shim-endpoint=:8262",
+	}, container.Args)
+	assert.Equal(t, JoinTokenEnv, container.Env[0].Name)
+}
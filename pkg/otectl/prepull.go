@@ -0,0 +1,157 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otectl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"text/tabwriter"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+)
+
+// PrePullTask returns the task to start pre-pulling images on clusters matched by selector.
+func PrePullTask(selector string, spec *handler.PrePullSpec) (*Task, error) {
+	if spec.Name == "" || len(spec.Images) == 0 {
+		return nil, fmt.Errorf("name and images of pre-pull are required")
+	}
+	body, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &Task{
+		Selector:    selector,
+		Destination: otev1.ClusterControllerDestPrePull,
+		Method:      http.MethodPost,
+		Body:        string(body),
+	}, nil
+}
+
+// PrePullStatusTask returns the task to get the progress of pre-pull on clusters.
+func PrePullStatusTask(selector, name string) *Task {
+	return &Task{
+		Selector:    selector,
+		Destination: otev1.ClusterControllerDestPrePull,
+		Method:      http.MethodGet,
+		URI:         name,
+	}
+}
+
+// PrePullDeleteTask returns the task to stop pre-pulling on clusters.
+func PrePullDeleteTask(selector, name string) *Task {
+	return &Task{
+		Selector:    selector,
+		Destination: otev1.ClusterControllerDestPrePull,
+		Method:      http.MethodDelete,
+		URI:         name,
+	}
+}
+
+// PrePullProgress is the progress of pre-pull of a cluster, Err is set if no progress is got.
+type PrePullProgress struct {
+	Status *handler.PrePullStatus
+	Err    string
+}
+
+// ParsePrePullResults returns the progress of pre-pull by cluster from responses of clusters.
+// Clusters in expect without response are included.
+func ParsePrePullResults(results map[string]otev1.ClusterControllerStatus, expect []string) map[string]*PrePullProgress {
+	ret := make(map[string]*PrePullProgress, len(expect))
+	for _, name := range expect {
+		ret[name] = &PrePullProgress{Err: "no response"}
+	}
+	for name, result := range results {
+		if result.StatusCode != http.StatusOK {
+			ret[name] = &PrePullProgress{Err: fmt.Sprintf("%d %s", result.StatusCode, result.Body)}
+			continue
+		}
+		status := &handler.PrePullStatus{}
+		if err := json.Unmarshal([]byte(result.Body), status); err != nil {
+			ret[name] = &PrePullProgress{Err: fmt.Sprintf("invalid response: %v", err)}
+			continue
+		}
+		ret[name] = &PrePullProgress{Status: status}
+	}
+	return ret
+}
+
+// PrintPrePullProgress writes the progress of each cluster to w, and failed images of nodes
+// if detail is true. It returns true if images are pulled on all nodes of all clusters.
+func PrintPrePullProgress(w io.Writer, progress map[string]*PrePullProgress, detail bool) bool {
+	names := make([]string, 0, len(progress))
+	for name := range progress {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "CLUSTER\tNODES\tCOMPLETED\tFAILED\tSTATUS")
+	completed := 0
+	for _, name := range names {
+		p := progress[name]
+		if p.Status == nil {
+			fmt.Fprintf(tw, "%s\t-\t-\t-\t%s\n", name, p.Err)
+			continue
+		}
+		failed := 0
+		for _, node := range p.Status.Nodes {
+			if len(node.Failed) > 0 {
+				failed++
+			}
+		}
+		state := "Pulling"
+		if p.Status.Completed() {
+			state = "Completed"
+			completed++
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\n", name, p.Status.DesiredNodes, p.Status.CompletedNodes, failed, state)
+	}
+	tw.Flush()
+
+	if detail {
+		for _, name := range names {
+			printPrePullFailures(w, name, progress[name].Status)
+		}
+	}
+	fmt.Fprintf(w, "%d clusters: %d completed\n", len(names), completed)
+	return completed == len(names)
+}
+
+func printPrePullFailures(w io.Writer, cluster string, status *handler.PrePullStatus) {
+	if status == nil {
+		return
+	}
+	nodes := make([]string, 0, len(status.Nodes))
+	for node := range status.Nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	for _, node := range nodes {
+		images := make([]string, 0, len(status.Nodes[node].Failed))
+		for image := range status.Nodes[node].Failed {
+			images = append(images, image)
+		}
+		sort.Strings(images)
+		for _, image := range images {
+			fmt.Fprintf(w, "%s/%s: %s %s\n", cluster, node, image, status.Nodes[node].Failed[image])
+		}
+	}
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otectl
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+)
+
+func TestPrePullTask(t *testing.T) {
+	_, err := PrePullTask("c1", &handler.PrePullSpec{Name: "web"})
+	assert.NotNil(t, err)
+
+	task, err := PrePullTask("c1", &handler.PrePullSpec{Name: "web", Images: []string{"nginx"}})
+	assert.Nil(t, err)
+	assert.Equal(t, otev1.ClusterControllerDestPrePull, task.Destination)
+	assert.Equal(t, http.MethodPost, task.Method)
+	spec := &handler.PrePullSpec{}
+	assert.Nil(t, json.Unmarshal([]byte(task.Body), spec))
+	assert.Equal(t, []string{"nginx"}, spec.Images)
+
+	task = PrePullStatusTask("c1", "web")
+	assert.Equal(t, http.MethodGet, task.Method)
+	assert.Equal(t, "web", task.URI)
+	task = PrePullDeleteTask("c1", "web")
+	assert.Equal(t, http.MethodDelete, task.Method)
+}
+
+func TestPrePullProgress(t *testing.T) {
+	completed, _ := json.Marshal(&handler.PrePullStatus{
+		Name: "web", Images: []string{"nginx"}, DesiredNodes: 1, CompletedNodes: 1,
+		Nodes: map[string]*handler.PrePullNodeStatus{"n1": {Pulled: []string{"nginx"}}},
+	})
+	pulling, _ := json.Marshal(&handler.PrePullStatus{
+		Name: "web", Images: []string{"nginx"}, DesiredNodes: 2, CompletedNodes: 1,
+		Nodes: map[string]*handler.PrePullNodeStatus{
+			"n1": {Pulled: []string{"nginx"}},
+			"n2": {Failed: map[string]string{"nginx": "ErrImagePull: timeout"}},
+		},
+	})
+	results := map[string]otev1.ClusterControllerStatus{
+		"c1": {StatusCode: 200, Body: string(completed)},
+		"c2": {StatusCode: 200, Body: string(pulling)},
+		"c3": {StatusCode: 404, Body: "not found"},
+		"c4": {StatusCode: 200, Body: "{"},
+	}
+	progress := ParsePrePullResults(results, []string{"c1", "c5"})
+	assert.Len(t, progress, 5)
+	assert.True(t, progress["c1"].Status.Completed())
+	assert.Equal(t, "404 not found", progress["c3"].Err)
+	assert.Contains(t, progress["c4"].Err, "invalid response")
+	assert.Equal(t, "no response", progress["c5"].Err)
+
+	buf := &bytes.Buffer{}
+	assert.False(t, PrintPrePullProgress(buf, progress, true))
+	assert.Contains(t, buf.String(), "c1       1      1          0       Completed")
+	assert.Contains(t, buf.String(), "c2       2      1          1       Pulling")
+	assert.Contains(t, buf.String(), "c2/n2: nginx ErrImagePull: timeout")
+	assert.Contains(t, buf.String(), "5 clusters: 1 completed")
+
+	buf.Reset()
+	assert.True(t, PrintPrePullProgress(buf, ParsePrePullResults(
+		map[string]otev1.ClusterControllerStatus{"c1": results["c1"]}, nil), false))
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otectl
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clusterselector"
+)
+
+// SelectClusters returns names of clusters matched by the selector, sorted by name.
+// Only online clusters are selected if onlineOnly is true.
+func SelectClusters(selector string, clusters []otev1.Cluster, onlineOnly bool) []string {
+	s := clusterselector.NewSelector(selector)
+	var ret []string
+	for i := range clusters {
+		if onlineOnly && clusters[i].Status.Status != otev1.ClusterStatusOnline {
+			continue
+		}
+		if s.Has(clusters[i].ObjectMeta.Name) {
+			ret = append(ret, clusters[i].ObjectMeta.Name)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// PrintTaskResults writes the response of each cluster to w, followed by a summary.
+// Clusters in expect without response are reported too.
+// It returns true if all expected clusters responded with 2xx status code.
+func PrintTaskResults(w io.Writer, results map[string]otev1.ClusterControllerStatus, expect []string) bool {
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	for _, name := range expect {
+		if _, ok := results[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	succeeded, failed, missing := 0, 0, 0
+	for _, name := range names {
+		status, ok := results[name]
+		if !ok {
+			missing++
+			fmt.Fprintf(w, "==> %s (no response)\n", name)
+			continue
+		}
+		if status.StatusCode >= 200 && status.StatusCode < 300 {
+			succeeded++
+		} else {
+			failed++
+		}
+		fmt.Fprintf(w, "==> %s (%d)\n", name, status.StatusCode)
+		if status.Body != "" {
+			fmt.Fprint(w, status.Body)
+			if !strings.HasSuffix(status.Body, "\n") {
+				fmt.Fprintln(w)
+			}
+		}
+	}
+	fmt.Fprintf(w, "%d clusters: %d succeeded, %d failed, %d no response\n",
+		len(names), succeeded, failed, missing)
+	return failed == 0 && missing == 0
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otectl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+)
+
+func TestSelectClusters(t *testing.T) {
+	clusters := []otev1.Cluster{
+		*newTestCluster("c2", "Root", otev1.ClusterStatusOnline),
+		*newTestCluster("c1", "Root", otev1.ClusterStatusOnline),
+		*newTestCluster("c11", "c1", otev1.ClusterStatusOffline),
+		*newTestCluster("d1", "Root", otev1.ClusterStatusOnline),
+	}
+	assert.Equal(t, []string{"c1", "c11", "c2"}, SelectClusters("c", clusters, false))
+	assert.Equal(t, []string{"c1", "c2"}, SelectClusters("c", clusters, true))
+	assert.Equal(t, []string{"c1", "d1"}, SelectClusters("^c1$,d1", clusters, true))
+	assert.Nil(t, SelectClusters("e", clusters, false))
+}
+
+func TestPrintTaskResults(t *testing.T) {
+	results := map[string]otev1.ClusterControllerStatus{
+		"c2": {StatusCode: 200, Body: "ok"},
+		"c1": {StatusCode: 404, Body: "not found\n"},
+	}
+
+	buf := &bytes.Buffer{}
+	assert.False(t, PrintTaskResults(buf, results, []string{"c1", "c2", "c3"}))
+	expect := strings.Join([]string{
+		"==> c1 (404)",
+		"not found",
+		"==> c2 (200)",
+		"ok",
+		"==> c3 (no response)",
+		"3 clusters: 1 succeeded, 1 failed, 1 no response",
+		"",
+	}, "\n")
+	assert.Equal(t, expect, buf.String())
+
+	buf.Reset()
+	assert.True(t, PrintTaskResults(buf, map[string]otev1.ClusterControllerStatus{
+		"c2": {StatusCode: 201},
+	}, []string{"c2"}))
+}
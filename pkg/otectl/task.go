@@ -0,0 +1,124 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otectl
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/envelope"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned"
+)
+
+const (
+	// DefaultTaskTimeout is the default time to wait for responses of a task.
+	DefaultTaskTimeout = 90 * time.Second
+	taskNamePrefix     = "otectl-"
+)
+
+var taskPollInterval = time.Second
+
+// Task is an operation sent to clusters selected by Selector.
+type Task struct {
+	Selector    string
+	Destination string
+	Method      string
+	URI         string
+	Body        string
+	// IdempotencyKey is set to skip clusters which have done a task of the same key.
+	IdempotencyKey string
+}
+
+// SealTask seals the body of task for clusters by km, with the key of each cluster by template,
+// so that the body is only opened by cluster shims of clusters.
+func SealTask(task *Task, clusters []string, km envelope.KeyManager, template string) error {
+	keys := make([]string, len(clusters))
+	for i, cluster := range clusters {
+		keys[i] = envelope.KeyName(template, cluster)
+	}
+	body, err := envelope.Seal([]byte(task.Body), keys, km)
+	if err != nil {
+		return fmt.Errorf("seal body failed: %v", err)
+	}
+	task.Body = string(body)
+	return nil
+}
+
+// ClusterSelector returns the selector which only matches the given cluster.
+func ClusterSelector(cluster string) string {
+	return "^" + regexp.QuoteMeta(cluster) + "$"
+}
+
+// RunTask creates a ClusterController crd for the task and waits until
+// all expected clusters responded or timeout. The crd is deleted after that.
+// The responses received are returned by cluster name.
+func RunTask(client oteclient.Interface, task *Task,
+	expect []string, timeout time.Duration) (map[string]otev1.ClusterControllerStatus, error) {
+	cc := &otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s%d", taskNamePrefix, time.Now().UnixNano()),
+			Namespace: otev1.ClusterNamespace,
+		},
+		Spec: otev1.ClusterControllerSpec{
+			ClusterSelector: task.Selector,
+			Destination:     task.Destination,
+			Method:          task.Method,
+			URL:             task.URI,
+			Body:            task.Body,
+			IdempotencyKey:  task.IdempotencyKey,
+		},
+	}
+
+	ccClient := client.OteV1().ClusterControllers(otev1.ClusterNamespace)
+	cc, err := ccClient.Create(cc)
+	if err != nil {
+		return nil, fmt.Errorf("create clustercontroller failed: %v", err)
+	}
+	defer func() {
+		if err := ccClient.Delete(cc.ObjectMeta.Name, &metav1.DeleteOptions{}); err != nil {
+			klog.Errorf("delete clustercontroller %s failed: %v", cc.ObjectMeta.Name, err)
+		}
+	}()
+
+	ret := map[string]otev1.ClusterControllerStatus{}
+	deadline := time.Now().Add(timeout)
+	for {
+		cur, err := ccClient.Get(cc.ObjectMeta.Name, metav1.GetOptions{})
+		if err != nil {
+			return ret, fmt.Errorf("get clustercontroller %s failed: %v", cc.ObjectMeta.Name, err)
+		}
+		ret = cur.Status
+		if hasAllResponses(ret, expect) || time.Now().After(deadline) {
+			return ret, nil
+		}
+		time.Sleep(taskPollInterval)
+	}
+}
+
+func hasAllResponses(status map[string]otev1.ClusterControllerStatus, expect []string) bool {
+	for _, cluster := range expect {
+		if _, ok := status[cluster]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otectl
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/envelope"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned"
+	otefake "github.com/baidu/ote-stack/pkg/k8sclient/fake"
+)
+
+func init() {
+	taskPollInterval = 10 * time.Millisecond
+}
+
+// fakeResponse acts as the root clustercontroller, it fills the response
+// of each cluster to the first clustercontroller crd created.
+func fakeResponse(t *testing.T, client oteclient.Interface,
+	check func(*otev1.ClusterController), resp map[string]otev1.ClusterControllerStatus) {
+	ccClient := client.OteV1().ClusterControllers(otev1.ClusterNamespace)
+	for i := 0; i < 100; i++ {
+		list, err := ccClient.List(metav1.ListOptions{})
+		assert.Nil(t, err)
+		if len(list.Items) != 0 {
+			cc := list.Items[0].DeepCopy()
+			if check != nil {
+				check(cc)
+			}
+			cc.Status = resp
+			_, err := ccClient.Update(cc)
+			assert.Nil(t, err)
+			return
+		}
+		time.Sleep(taskPollInterval)
+	}
+	t.Errorf("no clustercontroller created")
+}
+
+func TestRunTask(t *testing.T) {
+	client := otefake.NewSimpleClientset()
+	task := &Task{
+		Selector:    "c1,c2",
+		Destination: otev1.ClusterControllerDestAPI,
+		Method:      "GET",
+		URI:         "/api/v1/pods",
+	}
+	resp := map[string]otev1.ClusterControllerStatus{
+		"c1": {StatusCode: 200, Body: "ok"},
+		"c2": {StatusCode: 404, Body: "not found"},
+	}
+	go fakeResponse(t, client, func(cc *otev1.ClusterController) {
+		assert.Equal(t, "c1,c2", cc.Spec.ClusterSelector)
+		assert.Equal(t, "/api/v1/pods", cc.Spec.URL)
+	}, resp)
+
+	ret, err := RunTask(client, task, []string{"c1", "c2"}, time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, resp, ret)
+
+	// crd is deleted after task done
+	list, err := client.OteV1().ClusterControllers(otev1.ClusterNamespace).List(metav1.ListOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, list.Items, 0)
+
+	// timeout with part of responses
+	go fakeResponse(t, client, nil, map[string]otev1.ClusterControllerStatus{
+		"c1": {StatusCode: 200},
+	})
+	ret, err = RunTask(client, task, []string{"c1", "c2"}, 100*time.Millisecond)
+	assert.Nil(t, err)
+	assert.Len(t, ret, 1)
+}
+
+func TestClusterSelector(t *testing.T) {
+	assert.Equal(t, "^c1$", ClusterSelector("c1"))
+	assert.Equal(t, `^c\.1$`, ClusterSelector("c.1"))
+}
+
+// prefixKeyManager encrypts by prefixing the key name.
+type prefixKeyManager struct{}
+
+func (prefixKeyManager) Encrypt(key string, plaintext []byte) ([]byte, error) {
+	return append([]byte(key+":"), plaintext...), nil
+}
+
+func (prefixKeyManager) Decrypt(key string, ciphertext []byte) ([]byte, error) {
+	if !bytes.HasPrefix(ciphertext, []byte(key+":")) {
+		return nil, fmt.Errorf("not encrypted by %s", key)
+	}
+	return ciphertext[len(key)+1:], nil
+}
+
+func TestSealTask(t *testing.T) {
+	task := &Task{Body: `{"kind":"Secret"}`}
+	assert.NotNil(t, SealTask(task, nil, prefixKeyManager{}, envelope.DefaultKeyTemplate))
+	assert.Equal(t, `{"kind":"Secret"}`, task.Body)
+
+	assert.Nil(t, SealTask(task, []string{"c1", "c2"}, prefixKeyManager{}, "edge-{cluster}-key"))
+	assert.True(t, envelope.IsSealed([]byte(task.Body)))
+	body, err := envelope.Open([]byte(task.Body), "edge-c2-key", prefixKeyManager{})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"kind":"Secret"}`, string(body))
+	_, err = envelope.Open([]byte(task.Body), "ote-c2", prefixKeyManager{})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otectl
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned"
+)
+
+const (
+	// clearScreen moves the cursor to top left and clears the terminal.
+	clearScreen = "\033[H\033[2J"
+	// maxTreeEvents is the number of recent changes shown under the tree.
+	maxTreeEvents = 10
+)
+
+// treeWatcher keeps the clusters and the recent changes of them.
+type treeWatcher struct {
+	clusters map[string]otev1.Cluster
+	events   []string
+	now      func() time.Time
+}
+
+func newTreeWatcher() *treeWatcher {
+	return &treeWatcher{
+		clusters: make(map[string]otev1.Cluster),
+		now:      time.Now,
+	}
+}
+
+// WatchClusterTree watches Cluster crds, and renders the cluster tree
+// with recent join/leave/status changes to w each time clusters change,
+// until stop is closed.
+func WatchClusterTree(client oteclient.Interface, w io.Writer, stop <-chan struct{}) error {
+	tw := newTreeWatcher()
+	clusterClient := client.OteV1().Clusters(otev1.ClusterNamespace)
+	first := true
+	for {
+		list, err := clusterClient.List(metav1.ListOptions{})
+		if err != nil {
+			return fmt.Errorf("list clusters failed: %v", err)
+		}
+		tw.sync(list.Items, !first)
+		first = false
+		tw.render(w)
+
+		watcher, err := clusterClient.Watch(metav1.ListOptions{ResourceVersion: list.ResourceVersion})
+		if err != nil {
+			return fmt.Errorf("watch clusters failed: %v", err)
+		}
+		if done := tw.watch(watcher, w, stop); done {
+			return nil
+		}
+		// watch is closed by apiserver, list and watch again.
+		klog.V(3).Infof("cluster watch closed, rewatch")
+	}
+}
+
+// watch applies the events of watcher until stop is closed or watcher is closed.
+// It returns true if stop is closed.
+func (t *treeWatcher) watch(watcher watch.Interface, w io.Writer, stop <-chan struct{}) bool {
+	defer watcher.Stop()
+	for {
+		select {
+		case <-stop:
+			return true
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return false
+			}
+			cluster, ok := event.Object.(*otev1.Cluster)
+			if !ok {
+				continue
+			}
+			if t.apply(event.Type, cluster) {
+				t.render(w)
+			}
+		}
+	}
+}
+
+// sync replaces the clusters with a new list. Changes are recorded if record is true.
+func (t *treeWatcher) sync(clusters []otev1.Cluster, record bool) {
+	current := make(map[string]bool, len(clusters))
+	for i := range clusters {
+		current[clusters[i].ObjectMeta.Name] = true
+		if record {
+			t.apply(watch.Modified, &clusters[i])
+		} else {
+			t.clusters[clusters[i].ObjectMeta.Name] = clusters[i]
+		}
+	}
+	for name, cluster := range t.clusters {
+		if !current[name] {
+			cluster := cluster
+			t.apply(watch.Deleted, &cluster)
+		}
+	}
+}
+
+// apply updates a cluster by event, and returns true if the tree changed.
+func (t *treeWatcher) apply(eventType watch.EventType, cluster *otev1.Cluster) bool {
+	name := cluster.ObjectMeta.Name
+	old, exist := t.clusters[name]
+	switch eventType {
+	case watch.Added, watch.Modified:
+		t.clusters[name] = *cluster
+		if !exist {
+			t.record("%s joined under %s", name, cluster.Status.ParentName)
+			return true
+		}
+		changed := false
+		if old.Status.ParentName != cluster.Status.ParentName {
+			t.record("%s moved from %s to %s", name, old.Status.ParentName, cluster.Status.ParentName)
+			changed = true
+		}
+		if clusterStatus(&old) != clusterStatus(cluster) {
+			t.record("%s %s -> %s", name, clusterStatus(&old), clusterStatus(cluster))
+			changed = true
+		}
+		return changed
+	case watch.Deleted:
+		if !exist {
+			return false
+		}
+		delete(t.clusters, name)
+		t.record("%s left", name)
+		return true
+	default:
+		return false
+	}
+}
+
+func (t *treeWatcher) record(format string, args ...interface{}) {
+	event := t.now().Format("15:04:05") + " " + fmt.Sprintf(format, args...)
+	t.events = append(t.events, event)
+	if len(t.events) > maxTreeEvents {
+		t.events = t.events[len(t.events)-maxTreeEvents:]
+	}
+}
+
+// render clears the screen and writes the tree and recent changes to w.
+func (t *treeWatcher) render(w io.Writer) {
+	names := make([]string, 0, len(t.clusters))
+	for name := range t.clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	clusters := make([]otev1.Cluster, 0, len(names))
+	for _, name := range names {
+		clusters = append(clusters, t.clusters[name])
+	}
+
+	fmt.Fprint(w, clearScreen)
+	fmt.Fprintf(w, "Cluster tree, updated at %s\n\n", t.now().Format("15:04:05"))
+	PrintClusterTree(w, BuildClusterTree(clusters))
+	if len(t.events) != 0 {
+		fmt.Fprintln(w, "\nRecent changes:")
+		for _, event := range t.events {
+			fmt.Fprintf(w, "  %s\n", event)
+		}
+	}
+}
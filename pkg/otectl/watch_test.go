@@ -0,0 +1,129 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otectl
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/watch"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	otefake "github.com/baidu/ote-stack/pkg/k8sclient/fake"
+)
+
+type syncBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.String()
+}
+
+func newTestTreeWatcher() *treeWatcher {
+	tw := newTreeWatcher()
+	tw.now = func() time.Time {
+		return time.Date(2019, 10, 10, 8, 9, 10, 0, time.UTC)
+	}
+	return tw
+}
+
+func TestTreeWatcherApply(t *testing.T) {
+	tw := newTestTreeWatcher()
+	tw.sync([]otev1.Cluster{*newTestCluster("c1", "Root", otev1.ClusterStatusOnline)}, false)
+	assert.Len(t, tw.events, 0)
+
+	assert.True(t, tw.apply(watch.Added, newTestCluster("c2", "c1", otev1.ClusterStatusOnline)))
+	assert.False(t, tw.apply(watch.Modified, newTestCluster("c2", "c1", otev1.ClusterStatusOnline)))
+	assert.True(t, tw.apply(watch.Modified, newTestCluster("c2", "c1", otev1.ClusterStatusOffline)))
+	assert.True(t, tw.apply(watch.Modified, newTestCluster("c2", "Root", otev1.ClusterStatusOffline)))
+	assert.True(t, tw.apply(watch.Deleted, newTestCluster("c1", "Root", otev1.ClusterStatusOnline)))
+	assert.False(t, tw.apply(watch.Deleted, newTestCluster("c3", "Root", otev1.ClusterStatusOnline)))
+	assert.Equal(t, []string{
+		"08:09:10 c2 joined under c1",
+		"08:09:10 c2 online -> offline",
+		"08:09:10 c2 moved from c1 to Root",
+		"08:09:10 c1 left",
+	}, tw.events)
+
+	// resync records the clusters missed
+	tw.sync([]otev1.Cluster{*newTestCluster("c3", "Root", otev1.ClusterStatusOnline)}, true)
+	assert.Equal(t, "08:09:10 c2 left", tw.events[len(tw.events)-1])
+	assert.Len(t, tw.clusters, 1)
+
+	for i := 0; i < maxTreeEvents; i++ {
+		tw.record("event")
+	}
+	assert.Len(t, tw.events, maxTreeEvents)
+}
+
+func TestTreeWatcherRender(t *testing.T) {
+	tw := newTestTreeWatcher()
+	tw.apply(watch.Added, newTestCluster("c1", "Root", otev1.ClusterStatusOnline))
+	buf := &bytes.Buffer{}
+	tw.render(buf)
+	expect := strings.Join([]string{
+		clearScreen + "Cluster tree, updated at 08:09:10",
+		"",
+		"Root",
+		"└── c1 (online)",
+		"",
+		"Recent changes:",
+		"  08:09:10 c1 joined under Root",
+		"",
+	}, "\n")
+	assert.Equal(t, expect, buf.String())
+}
+
+func TestWatchClusterTree(t *testing.T) {
+	client := otefake.NewSimpleClientset(newTestCluster("c1", "Root", otev1.ClusterStatusOnline))
+	buf := &syncBuffer{}
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- WatchClusterTree(client, buf, stop)
+	}()
+
+	// wait for the watch to start
+	for i := 0; i < 100 && !strings.Contains(buf.String(), "c1 (online)"); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	_, err := client.OteV1().Clusters(otev1.ClusterNamespace).Create(
+		newTestCluster("c2", "c1", otev1.ClusterStatusOnline))
+	assert.Nil(t, err)
+	for i := 0; i < 100 && !strings.Contains(buf.String(), "c2 joined under c1"); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(stop)
+	assert.Nil(t, <-done)
+	assert.Contains(t, buf.String(), "    └── c2 (online)")
+}
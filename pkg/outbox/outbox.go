@@ -0,0 +1,310 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package outbox queues reports and responses to the parent in a local directory while the tunnel
+// is disconnected, and forwards them once connected again, so that they are not lost.
+/*
+A message is kept in a file, e.g., <dir>/<unix nano of time queued>-<seq>.msg, in the form sent to
+the tunnel. The oldest messages are dropped if more than the size are queued, and messages queued
+longer than the ttl are dropped instead of forwarded. Queuing is disabled unless a directory is set.
+*/
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+const (
+	// DefaultSize is the max number of messages queued by default.
+	DefaultSize = 1000
+	// DefaultTTL is the time to keep messages queued by default.
+	DefaultTTL = time.Hour
+
+	fileSuffix = ".msg"
+)
+
+// Config is the config of outbox.
+type Config struct {
+	// Dir is the directory to queue messages, queuing is disabled if empty.
+	Dir string
+	// Size is the max number of messages queued, DefaultSize if 0.
+	Size int
+	// TTL is the time to keep messages queued, DefaultTTL if 0.
+	TTL time.Duration
+}
+
+// entry is a message queued.
+type entry struct {
+	name   string
+	queued time.Time
+}
+
+// Stats is the stats of an outbox.
+type Stats struct {
+	Messages  int       `json:"messages"`
+	Oldest    time.Time `json:"oldest,omitempty"`
+	Queued    uint64    `json:"queued"`
+	Forwarded uint64    `json:"forwarded"`
+	Dropped   uint64    `json:"dropped"`
+	Expired   uint64    `json:"expired"`
+}
+
+// Outbox queues messages in files in order of time queued.
+type Outbox struct {
+	conf Config
+	now  func() time.Time
+
+	lock    sync.Mutex
+	entries []entry
+	seq     uint64
+	stats   Stats
+	// forwarding serializes forwarding, so that messages are forwarded once in order.
+	forwarding sync.Mutex
+}
+
+var defaultOutbox *Outbox
+
+// New returns an Outbox with conf, and loads the messages queued in the directory.
+func New(conf Config) (*Outbox, error) {
+	if conf.Dir == "" {
+		return nil, fmt.Errorf("outbox directory is empty")
+	}
+	if conf.Size < 0 || conf.TTL < 0 {
+		return nil, fmt.Errorf("outbox size and ttl cannot be negative")
+	}
+	if conf.Size == 0 {
+		conf.Size = DefaultSize
+	}
+	if conf.TTL == 0 {
+		conf.TTL = DefaultTTL
+	}
+	if err := os.MkdirAll(conf.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("create outbox directory %s failed: %v", conf.Dir, err)
+	}
+	o := &Outbox{
+		conf: conf,
+		now:  time.Now,
+	}
+	if err := o.load(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Setup replaces the default outbox with conf, disables queuing if directory is empty.
+func Setup(conf Config) error {
+	if conf.Dir == "" {
+		defaultOutbox = nil
+		return nil
+	}
+	o, err := New(conf)
+	if err != nil {
+		return err
+	}
+	defaultOutbox = o
+	klog.Infof("queue messages to parent in %s while disconnected, %d messages loaded", conf.Dir, len(o.entries))
+	return nil
+}
+
+// Queued returns true if messages of command are queued by the default outbox, i.e., reports
+// and responses, while other messages, e.g., registering and routes, are sent again once connected.
+func Queued(command clustermessage.CommandType) bool {
+	if defaultOutbox == nil {
+		return false
+	}
+	switch command {
+	case clustermessage.CommandType_ControlResp, clustermessage.CommandType_ControlMultiResp,
+		clustermessage.CommandType_DeployResp, clustermessage.CommandType_EdgeReport:
+		return true
+	default:
+		return false
+	}
+}
+
+// Put queues data of a message by the default outbox.
+func Put(data []byte) {
+	if defaultOutbox == nil {
+		return
+	}
+	defaultOutbox.Put(data)
+}
+
+// Forward forwards messages queued by the default outbox by send.
+func Forward(send func([]byte) error) {
+	if defaultOutbox == nil {
+		return
+	}
+	defaultOutbox.Forward(send)
+}
+
+// Len returns the number of messages queued by the default outbox.
+func Len() int {
+	if defaultOutbox == nil {
+		return 0
+	}
+	return defaultOutbox.Stats().Messages
+}
+
+// Handler is the http handler to get stats of the default outbox in json.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	if defaultOutbox == nil {
+		http.Error(w, "outbox is disabled", http.StatusNotFound)
+		return
+	}
+	data, _ := json.Marshal(defaultOutbox.Stats())
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// Put queues data of a message, and drops the oldest messages if more than the size are queued.
+func (o *Outbox) Put(data []byte) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	now := o.now()
+	o.seq++
+	e := entry{
+		name:   fmt.Sprintf("%020d-%010d%s", now.UnixNano(), o.seq, fileSuffix),
+		queued: now,
+	}
+	file := filepath.Join(o.conf.Dir, e.name)
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		klog.Errorf("queue message to parent failed: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		klog.Errorf("queue message to parent failed: %v", err)
+		return
+	}
+	o.entries = append(o.entries, e)
+	o.stats.Queued++
+	for len(o.entries) > o.conf.Size {
+		klog.Warningf("outbox is full, drop message queued at %v", o.entries[0].queued)
+		o.removeOldest()
+		o.stats.Dropped++
+	}
+}
+
+/*
+Forward sends messages queued by send in order, and removes them once sent.
+Messages expired are dropped, and forwarding stops at the first message failed to send,
+which is forwarded again next time.
+*/
+func (o *Outbox) Forward(send func([]byte) error) {
+	o.forwarding.Lock()
+	defer o.forwarding.Unlock()
+	forwarded := 0
+	for {
+		o.lock.Lock()
+		if len(o.entries) == 0 {
+			o.lock.Unlock()
+			break
+		}
+		e := o.entries[0]
+		if o.now().Sub(e.queued) > o.conf.TTL {
+			o.removeOldest()
+			o.stats.Expired++
+			o.lock.Unlock()
+			continue
+		}
+		o.lock.Unlock()
+
+		data, err := ioutil.ReadFile(filepath.Join(o.conf.Dir, e.name))
+		if err == nil {
+			if err := send(data); err != nil {
+				klog.Warningf("forward messages queued stopped, %d forwarded: %v", forwarded, err)
+				return
+			}
+			forwarded++
+		} else {
+			klog.Errorf("read message queued %s failed, drop it: %v", e.name, err)
+		}
+
+		o.lock.Lock()
+		// the message may be dropped by Put if the outbox is full during sending.
+		if len(o.entries) != 0 && o.entries[0].name == e.name {
+			o.removeOldest()
+			if err == nil {
+				o.stats.Forwarded++
+			}
+		}
+		o.lock.Unlock()
+	}
+	if forwarded != 0 {
+		klog.Infof("%d messages queued are forwarded to parent", forwarded)
+	}
+}
+
+// Stats returns the stats of the outbox.
+func (o *Outbox) Stats() Stats {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	s := o.stats
+	s.Messages = len(o.entries)
+	if len(o.entries) != 0 {
+		s.Oldest = o.entries[0].queued
+	}
+	return s
+}
+
+// removeOldest removes the oldest message, with lock held.
+func (o *Outbox) removeOldest() {
+	name := o.entries[0].name
+	o.entries = o.entries[1:]
+	if err := os.Remove(filepath.Join(o.conf.Dir, name)); err != nil && !os.IsNotExist(err) {
+		klog.Errorf("remove message queued %s failed: %v", name, err)
+	}
+}
+
+// load loads the messages queued, files of other names are skipped.
+func (o *Outbox) load() error {
+	files, err := ioutil.ReadDir(o.conf.Dir)
+	if err != nil {
+		return fmt.Errorf("read outbox directory %s failed: %v", o.conf.Dir, err)
+	}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), fileSuffix) {
+			continue
+		}
+		nano, err := strconv.ParseInt(strings.SplitN(f.Name(), "-", 2)[0], 10, 64)
+		if err != nil {
+			klog.Errorf("message queued %s is of unknown name, skip it", f.Name())
+			continue
+		}
+		o.entries = append(o.entries, entry{name: f.Name(), queued: time.Unix(0, nano)})
+	}
+	// names are of the same length, sorted in order of time queued.
+	sort.Slice(o.entries, func(i, j int) bool {
+		return o.entries[i].name < o.entries[j].name
+	})
+	for len(o.entries) > o.conf.Size {
+		o.removeOldest()
+	}
+	return nil
+}
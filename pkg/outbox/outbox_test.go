@@ -0,0 +1,132 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package outbox
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+func newTestOutbox(t *testing.T, conf Config) *Outbox {
+	dir, err := ioutil.TempDir("", "outbox")
+	assert.Nil(t, err)
+	conf.Dir = dir
+	o, err := New(conf)
+	assert.Nil(t, err)
+	return o
+}
+
+// recorder records messages sent, and fails after limit messages if limit is not negative.
+type recorder struct {
+	sent  []string
+	limit int
+}
+
+func (r *recorder) send(data []byte) error {
+	if r.limit >= 0 && len(r.sent) >= r.limit {
+		return fmt.Errorf("disconnected")
+	}
+	r.sent = append(r.sent, string(data))
+	return nil
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{})
+	assert.NotNil(t, err)
+	_, err = New(Config{Dir: "/tmp/outbox", Size: -1})
+	assert.NotNil(t, err)
+
+	o := newTestOutbox(t, Config{})
+	defer os.RemoveAll(o.conf.Dir)
+	assert.Equal(t, DefaultSize, o.conf.Size)
+	assert.Equal(t, DefaultTTL, o.conf.TTL)
+}
+
+func TestForward(t *testing.T) {
+	o := newTestOutbox(t, Config{Size: 3, TTL: time.Minute})
+	defer os.RemoveAll(o.conf.Dir)
+	now := time.Now()
+	o.now = func() time.Time { return now }
+	for i := 0; i < 4; i++ {
+		o.Put([]byte(fmt.Sprintf("m%d", i)))
+		now = now.Add(time.Second)
+	}
+	// the oldest is dropped if full.
+	stats := o.Stats()
+	assert.Equal(t, 3, stats.Messages)
+	assert.Equal(t, uint64(1), stats.Dropped)
+
+	// forwarding stops at the message failed, which is forwarded next time.
+	r := &recorder{limit: 1}
+	o.Forward(r.send)
+	assert.Equal(t, []string{"m1"}, r.sent)
+	assert.Equal(t, 2, o.Stats().Messages)
+
+	// messages are kept after restarted.
+	loaded, err := New(o.conf)
+	assert.Nil(t, err)
+	loaded.now = o.now
+	r = &recorder{limit: -1}
+	loaded.Forward(r.send)
+	assert.Equal(t, []string{"m2", "m3"}, r.sent)
+	assert.Equal(t, 0, loaded.Stats().Messages)
+	files, _ := ioutil.ReadDir(o.conf.Dir)
+	assert.Empty(t, files)
+
+	// messages expired are dropped.
+	loaded.Put([]byte("m4"))
+	now = now.Add(time.Minute + time.Second)
+	loaded.Put([]byte("m5"))
+	r = &recorder{limit: -1}
+	loaded.Forward(r.send)
+	assert.Equal(t, []string{"m5"}, r.sent)
+	stats = loaded.Stats()
+	assert.Equal(t, uint64(1), stats.Expired)
+	assert.Equal(t, uint64(3), stats.Forwarded)
+}
+
+func TestDefaultOutbox(t *testing.T) {
+	assert.Nil(t, Setup(Config{}))
+	assert.False(t, Queued(clustermessage.CommandType_ControlResp))
+	assert.Equal(t, 0, Len())
+	w := httptest.NewRecorder()
+	Handler(w, httptest.NewRequest(http.MethodGet, "/outbox", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	dir, err := ioutil.TempDir("", "outbox")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, Setup(Config{Dir: dir}))
+	defer Setup(Config{})
+	assert.True(t, Queued(clustermessage.CommandType_ControlResp))
+	assert.True(t, Queued(clustermessage.CommandType_EdgeReport))
+	assert.False(t, Queued(clustermessage.CommandType_ClusterRegist))
+	Put([]byte("m"))
+	assert.Equal(t, 1, Len())
+	w = httptest.NewRecorder()
+	Handler(w, httptest.NewRequest(http.MethodGet, "/outbox", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"messages":1`)
+}
@@ -0,0 +1,277 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package quota computes resources requested by workloads and pods against ClusterQuotas,
+// shared by the distribution controllers in center and the shim enforcing quotas on edge.
+package quota
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clusterselector"
+)
+
+// Resources are the resources limited by ClusterQuotas.
+var Resources = []corev1.ResourceName{corev1.ResourcePods, corev1.ResourceCPU, corev1.ResourceMemory}
+
+// workload is the part of a workload object, or a list of them, to compute its requests.
+type workload struct {
+	Kind string `json:"kind"`
+	Spec struct {
+		Replicas    *int32                 `json:"replicas"`
+		Parallelism *int32                 `json:"parallelism"`
+		Template    corev1.PodTemplateSpec `json:"template"`
+	} `json:"spec"`
+	Items []json.RawMessage `json:"items"`
+}
+
+/*
+Requests returns resources requested by pods of the object in body, false if it is not a workload.
+Pods of Deployment, StatefulSet, ReplicaSet and ReplicationController are counted by replicas,
+those of Job by parallelism, and DaemonSet is counted as one pod since its nodes are unknown in center.
+Items of a list are summed.
+*/
+func Requests(body []byte) (corev1.ResourceList, bool, error) {
+	w := &workload{}
+	if err := json.Unmarshal(body, w); err != nil {
+		return nil, false, err
+	}
+	switch w.Kind {
+	case "Pod":
+		pod := &corev1.Pod{}
+		if err := json.Unmarshal(body, pod); err != nil {
+			return nil, false, err
+		}
+		return PodRequests(&pod.Spec), true, nil
+	case "Deployment", "StatefulSet", "ReplicaSet", "ReplicationController":
+		return scale(PodRequests(&w.Spec.Template.Spec), w.Spec.Replicas), true, nil
+	case "Job":
+		return scale(PodRequests(&w.Spec.Template.Spec), w.Spec.Parallelism), true, nil
+	case "DaemonSet":
+		return PodRequests(&w.Spec.Template.Spec), true, nil
+	}
+	// lists, e.g., List or DeploymentList.
+	total := corev1.ResourceList{}
+	isWorkload := false
+	for _, item := range w.Items {
+		requests, ok, err := Requests(item)
+		if err != nil {
+			return nil, false, err
+		}
+		if ok {
+			isWorkload = true
+			total = Add(total, requests)
+		}
+	}
+	if !isWorkload {
+		return nil, false, nil
+	}
+	return total, true, nil
+}
+
+// scale returns requests of pod multiplied by replicas, which is 1 if not set.
+func scale(pod corev1.ResourceList, replicas *int32) corev1.ResourceList {
+	n := int64(1)
+	if replicas != nil {
+		n = int64(*replicas)
+	}
+	total := corev1.ResourceList{}
+	for name, value := range pod {
+		if name == corev1.ResourceCPU {
+			total[name] = *resource.NewMilliQuantity(value.MilliValue()*n, value.Format)
+		} else {
+			total[name] = *resource.NewQuantity(value.Value()*n, value.Format)
+		}
+	}
+	return total
+}
+
+// PodRequests returns resources requested by a pod of spec, as the scheduler computes them:
+// the larger of the sum of containers and the max of init containers.
+// Limits of a container are taken as its requests if those are not set.
+func PodRequests(spec *corev1.PodSpec) corev1.ResourceList {
+	total := corev1.ResourceList{corev1.ResourcePods: *resource.NewQuantity(1, resource.DecimalSI)}
+	containers := corev1.ResourceList{}
+	for i := range spec.Containers {
+		containers = Add(containers, containerRequests(&spec.Containers[i]))
+	}
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		q, ok := containers[name]
+		for i := range spec.InitContainers {
+			if init, exist := containerRequests(&spec.InitContainers[i])[name]; exist && init.Cmp(q) > 0 {
+				q, ok = init, true
+			}
+		}
+		if ok {
+			total[name] = q.DeepCopy()
+		}
+	}
+	return total
+}
+
+func containerRequests(c *corev1.Container) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		if q, ok := c.Resources.Requests[name]; ok {
+			requests[name] = q
+		} else if q, ok := c.Resources.Limits[name]; ok {
+			requests[name] = q
+		}
+	}
+	return requests
+}
+
+// Usage returns resources requested by pods not terminated.
+func Usage(pods []*corev1.Pod) corev1.ResourceList {
+	total := corev1.ResourceList{corev1.ResourcePods: *resource.NewQuantity(0, resource.DecimalSI)}
+	for _, pod := range pods {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		total = Add(total, PodRequests(&pod.Spec))
+	}
+	return total
+}
+
+// Add returns the sum of a and b.
+func Add(a, b corev1.ResourceList) corev1.ResourceList {
+	total := make(corev1.ResourceList, len(a))
+	for name, value := range a {
+		total[name] = value.DeepCopy()
+	}
+	for name, value := range b {
+		q := total[name]
+		q.Add(value)
+		total[name] = q
+	}
+	return total
+}
+
+// Subtract returns a minus b, with resources below zero as zero.
+func Subtract(a, b corev1.ResourceList) corev1.ResourceList {
+	total := make(corev1.ResourceList, len(a))
+	for name, value := range a {
+		q := value.DeepCopy()
+		if sub, ok := b[name]; ok {
+			q.Sub(sub)
+		}
+		if q.Sign() < 0 {
+			q = *resource.NewQuantity(0, value.Format)
+		}
+		total[name] = q
+	}
+	return total
+}
+
+/*
+Exceeded returns reasons of resources in hard exceeded if requested is added to used,
+e.g., "pods: 11 > 10". Resources not requested are not checked, so that
+tasks not adding pods are admitted even though the cluster is over quota.
+*/
+func Exceeded(hard, used, requested corev1.ResourceList) []string {
+	var reasons []string
+	for _, name := range Resources {
+		limit, ok := hard[name]
+		if !ok {
+			continue
+		}
+		req, ok := requested[name]
+		if !ok || req.Sign() <= 0 {
+			continue
+		}
+		total := used[name].DeepCopy()
+		total.Add(req)
+		if total.Cmp(limit) > 0 {
+			reasons = append(reasons, fmt.Sprintf("%s: %s > %s", name, total.String(), limit.String()))
+		}
+	}
+	return reasons
+}
+
+// Min returns the smaller of each resource in a and b, so that a cluster selected
+// by several ClusterQuotas is limited by all of them.
+func Min(a, b corev1.ResourceList) corev1.ResourceList {
+	total := make(corev1.ResourceList, len(a))
+	for name, value := range a {
+		total[name] = value.DeepCopy()
+	}
+	for name, value := range b {
+		if q, ok := total[name]; !ok || value.Cmp(q) < 0 {
+			total[name] = value.DeepCopy()
+		}
+	}
+	return total
+}
+
+// Validate returns an error if hard of q limits resources not supported or below zero.
+func Validate(q *otev1.ClusterQuota) error {
+	names := make([]string, 0, len(q.Spec.Hard))
+	for name := range q.Spec.Hard {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !supported(corev1.ResourceName(name)) {
+			return fmt.Errorf("resource %s is not supported, only pods, cpu and memory are", name)
+		}
+		if value := q.Spec.Hard[corev1.ResourceName(name)]; value.Sign() < 0 {
+			return fmt.Errorf("hard of %s %s is negative", name, value.String())
+		}
+	}
+	return nil
+}
+
+func supported(name corev1.ResourceName) bool {
+	for _, r := range Resources {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Selects returns true if q limits cluster. Those with errors are not enforced.
+func Selects(q *otev1.ClusterQuota, cluster string) bool {
+	if q.Status.Error != "" {
+		return false
+	}
+	return clusterselector.NewSelector(q.Spec.ClusterSelector).Has(cluster)
+}
+
+// Hard returns limits of cluster by all quotas selecting it, nil if it is not limited.
+func Hard(quotas []*otev1.ClusterQuota, cluster string) corev1.ResourceList {
+	var hard corev1.ResourceList
+	for _, q := range quotas {
+		if !Selects(q, cluster) {
+			continue
+		}
+		if hard == nil {
+			hard = corev1.ResourceList{}
+			for name, value := range q.Spec.Hard {
+				hard[name] = value.DeepCopy()
+			}
+			continue
+		}
+		hard = Min(hard, q.Spec.Hard)
+	}
+	return hard
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+)
+
+func resources(pods, cpu, memory string) corev1.ResourceList {
+	list := corev1.ResourceList{}
+	if pods != "" {
+		list[corev1.ResourcePods] = resource.MustParse(pods)
+	}
+	if cpu != "" {
+		list[corev1.ResourceCPU] = resource.MustParse(cpu)
+	}
+	if memory != "" {
+		list[corev1.ResourceMemory] = resource.MustParse(memory)
+	}
+	return list
+}
+
+func assertResources(t *testing.T, expected, actual corev1.ResourceList) {
+	assert.Equal(t, len(expected), len(actual), "%v", actual)
+	for name, value := range expected {
+		q := actual[name]
+		assert.Zero(t, value.Cmp(q), "%s: expected %s, got %s", name, value.String(), q.String())
+	}
+}
+
+func TestRequests(t *testing.T) {
+	casetest := []struct {
+		name       string
+		body       string
+		isWorkload bool
+		expected   corev1.ResourceList
+	}{
+		{
+			name:       "pod with init container larger",
+			body:       `{"kind":"Pod","spec":{"containers":[{"resources":{"requests":{"cpu":"100m","memory":"64Mi"}}},{"resources":{"limits":{"cpu":"200m"}}}],"initContainers":[{"resources":{"requests":{"memory":"128Mi"}}}]}}`,
+			isWorkload: true,
+			expected:   resources("1", "300m", "128Mi"),
+		},
+		{
+			name:       "deployment by replicas",
+			body:       `{"kind":"Deployment","spec":{"replicas":3,"template":{"spec":{"containers":[{"resources":{"requests":{"cpu":"250m","memory":"1Gi"}}}]}}}}`,
+			isWorkload: true,
+			expected:   resources("3", "750m", "3Gi"),
+		},
+		{
+			name:       "statefulset without replicas",
+			body:       `{"kind":"StatefulSet","spec":{"template":{"spec":{"containers":[{}]}}}}`,
+			isWorkload: true,
+			expected:   resources("1", "", ""),
+		},
+		{
+			name:       "job by parallelism",
+			body:       `{"kind":"Job","spec":{"parallelism":2,"template":{"spec":{"containers":[{"resources":{"requests":{"cpu":"1"}}}]}}}}`,
+			isWorkload: true,
+			expected:   resources("2", "2", ""),
+		},
+		{
+			name:       "list of workloads",
+			body:       `{"kind":"DeploymentList","items":[{"kind":"Deployment","spec":{"replicas":2}},{"kind":"ConfigMap"},{"kind":"DaemonSet"}]}`,
+			isWorkload: true,
+			expected:   resources("3", "", ""),
+		},
+		{
+			name: "not workload",
+			body: `{"kind":"ConfigMap","data":{"a":"b"}}`,
+		},
+	}
+
+	for _, ct := range casetest {
+		t.Run(ct.name, func(t *testing.T) {
+			requests, ok, err := Requests([]byte(ct.body))
+			assert.Nil(t, err)
+			assert.Equal(t, ct.isWorkload, ok)
+			assertResources(t, ct.expected, requests)
+		})
+	}
+
+	_, _, err := Requests([]byte("{"))
+	assert.NotNil(t, err)
+}
+
+func TestUsage(t *testing.T) {
+	pod := func(phase corev1.PodPhase, cpu string) *corev1.Pod {
+		return &corev1.Pod{
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Resources: corev1.ResourceRequirements{Requests: resources("", cpu, "")},
+			}}},
+			Status: corev1.PodStatus{Phase: phase},
+		}
+	}
+	used := Usage([]*corev1.Pod{
+		pod(corev1.PodRunning, "1"),
+		pod(corev1.PodPending, "500m"),
+		pod(corev1.PodSucceeded, "2"),
+		pod(corev1.PodFailed, "2"),
+	})
+	assertResources(t, resources("2", "1500m", ""), used)
+
+	assertResources(t, resources("0", "", ""), Usage(nil))
+}
+
+func TestExceeded(t *testing.T) {
+	hard := resources("10", "4", "")
+	used := resources("9", "3", "8Gi")
+
+	assert.Empty(t, Exceeded(hard, used, resources("1", "1", "100Gi")))
+	assert.Equal(t, []string{"pods: 11 > 10", "cpu: 4500m > 4"},
+		Exceeded(hard, used, resources("2", "1500m", "")))
+	// tasks not requesting pods are admitted though the cluster is over quota.
+	assert.Empty(t, Exceeded(hard, resources("12", "", ""), resources("0", "", "")))
+	assert.Empty(t, Exceeded(nil, used, resources("100", "", "")))
+}
+
+func TestSubtract(t *testing.T) {
+	assertResources(t, resources("1", "0", ""),
+		Subtract(resources("3", "1", ""), resources("2", "2", "1Gi")))
+}
+
+func TestValidate(t *testing.T) {
+	q := &otev1.ClusterQuota{Spec: otev1.ClusterQuotaSpec{Hard: resources("10", "4", "8Gi")}}
+	assert.Nil(t, Validate(q))
+
+	q.Spec.Hard[corev1.ResourceStorage] = resource.MustParse("1Gi")
+	assert.NotNil(t, Validate(q))
+
+	q.Spec.Hard = resources("-1", "", "")
+	assert.NotNil(t, Validate(q))
+}
+
+func TestHard(t *testing.T) {
+	quota := func(name, selector string, hard corev1.ResourceList) *otev1.ClusterQuota {
+		return &otev1.ClusterQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       otev1.ClusterQuotaSpec{ClusterSelector: selector, Hard: hard},
+		}
+	}
+	invalid := quota("invalid", "", resources("1", "", ""))
+	invalid.Status.Error = "invalid"
+	quotas := []*otev1.ClusterQuota{
+		quota("all", "", resources("10", "4", "")),
+		quota("small", "^edge-", resources("20", "2", "4Gi")),
+		invalid,
+	}
+
+	assertResources(t, resources("10", "2", "4Gi"), Hard(quotas, "edge-1"))
+	assertResources(t, resources("10", "4", ""), Hard(quotas, "c1"))
+	assert.Nil(t, Hard(quotas[1:], "c1"))
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reporter
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// major types of cbor(RFC 7049) used by reports.
+const (
+	cborUint  = 0
+	cborNint  = 1
+	cborBytes = 2
+	cborText  = 3
+	cborArray = 4
+	cborMap   = 5
+)
+
+const (
+	cborKeyResourceType = "resourceType"
+	cborKeyBody         = "body"
+)
+
+// cborSerializer encodes reports as a cbor array of maps with keys resourceType and body,
+// the same as the json encoding but with body in byte string.
+type cborSerializer struct{}
+
+func (cborSerializer) Name() string {
+	return EncodingCBOR
+}
+
+func (cborSerializer) Marshal(r Reports) ([]byte, error) {
+	b := cborAppendHead(nil, cborArray, uint64(len(r)))
+	for _, rep := range r {
+		b = cborAppendHead(b, cborMap, 2)
+		b = cborAppendHead(b, cborText, uint64(len(cborKeyResourceType)))
+		b = append(b, cborKeyResourceType...)
+		if rep.ResourceType >= 0 {
+			b = cborAppendHead(b, cborUint, uint64(rep.ResourceType))
+		} else {
+			b = cborAppendHead(b, cborNint, uint64(-1-rep.ResourceType))
+		}
+		b = cborAppendHead(b, cborText, uint64(len(cborKeyBody)))
+		b = append(b, cborKeyBody...)
+		b = cborAppendHead(b, cborBytes, uint64(len(rep.Body)))
+		b = append(b, rep.Body...)
+	}
+	return b, nil
+}
+
+func (cborSerializer) Unmarshal(b []byte) (Reports, error) {
+	d := &cborDecoder{b: b}
+	n, err := d.head(cborArray)
+	if err != nil {
+		return nil, err
+	}
+	// each report takes more than 1 byte, n is not trusted to allocate.
+	if n > uint64(len(d.b)) {
+		return nil, fmt.Errorf("unexpected end of cbor")
+	}
+	r := make(Reports, 0, n)
+	for i := uint64(0); i < n; i++ {
+		rep, err := d.report()
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, rep)
+	}
+	if len(d.b) != 0 {
+		return nil, fmt.Errorf("%d bytes left after reports", len(d.b))
+	}
+	return r, nil
+}
+
+// cborAppendHead appends the head of a data item with major type and argument n.
+func cborAppendHead(b []byte, major byte, n uint64) []byte {
+	major <<= 5
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= 0xff:
+		return append(b, major|24, byte(n))
+	case n <= 0xffff:
+		b = append(b, major|25, 0, 0)
+		binary.BigEndian.PutUint16(b[len(b)-2:], uint16(n))
+		return b
+	case n <= 0xffffffff:
+		b = append(b, major|26, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(b[len(b)-4:], uint32(n))
+		return b
+	default:
+		b = append(b, major|27, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(b[len(b)-8:], n)
+		return b
+	}
+}
+
+type cborDecoder struct {
+	b []byte
+}
+
+// next decodes the head of next data item, and returns its major type and argument.
+// Indefinite lengths are not supported.
+func (d *cborDecoder) next() (byte, uint64, error) {
+	if len(d.b) == 0 {
+		return 0, 0, fmt.Errorf("unexpected end of cbor")
+	}
+	major, info := d.b[0]>>5, d.b[0]&0x1f
+	d.b = d.b[1:]
+	size := 0
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, 0, fmt.Errorf("cbor additional info %d is not supported", info)
+	}
+	if len(d.b) < size {
+		return 0, 0, fmt.Errorf("unexpected end of cbor")
+	}
+	var n uint64
+	for _, c := range d.b[:size] {
+		n = n<<8 | uint64(c)
+	}
+	d.b = d.b[size:]
+	return major, n, nil
+}
+
+// head decodes the head of next data item, which must be of major type.
+func (d *cborDecoder) head(major byte) (uint64, error) {
+	m, n, err := d.next()
+	if err != nil {
+		return 0, err
+	}
+	if m != major {
+		return 0, fmt.Errorf("cbor major type %d is not %d", m, major)
+	}
+	return n, nil
+}
+
+// bytes decodes the content of next byte or text string.
+func (d *cborDecoder) bytes(major byte) ([]byte, error) {
+	n, err := d.head(major)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.b)) {
+		return nil, fmt.Errorf("unexpected end of cbor")
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b, nil
+}
+
+func (d *cborDecoder) report() (Report, error) {
+	rep := Report{}
+	n, err := d.head(cborMap)
+	if err != nil {
+		return rep, err
+	}
+	for i := uint64(0); i < n; i++ {
+		key, err := d.bytes(cborText)
+		if err != nil {
+			return rep, err
+		}
+		switch string(key) {
+		case cborKeyResourceType:
+			major, v, err := d.next()
+			if err != nil {
+				return rep, err
+			}
+			switch major {
+			case cborUint:
+				rep.ResourceType = int(v)
+			case cborNint:
+				rep.ResourceType = -1 - int(v)
+			default:
+				return rep, fmt.Errorf("cbor major type %d of resource type is not integer", major)
+			}
+		case cborKeyBody:
+			body, err := d.bytes(cborBytes)
+			if err != nil {
+				return rep, err
+			}
+			rep.Body = append([]byte{}, body...)
+		default:
+			return rep, fmt.Errorf("unexpected key %s in report", key)
+		}
+	}
+	return rep, nil
+}
@@ -0,0 +1,262 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reporter
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+)
+
+const (
+	certExpiryCheckPeriod = time.Hour
+
+	// names of certificates checked.
+	certNameAPIServer = "apiserver"
+	certNameClient    = "kubeconfig-client"
+	certNameCA        = "kubeconfig-ca"
+
+	// reasons of condition CertificateExpiring, Expired comes first if any certificate has expired.
+	certExpiryReasonValid       = "Valid"
+	certExpiryReasonExpiring    = "Expiring"
+	certExpiryReasonExpired     = "Expired"
+	certExpiryReasonCheckFailed = "CheckFailed"
+)
+
+/*
+CertExpiryReporter checks expiries of certificates served by the k8s apiserver, and of the client
+certificate and ca of the kubeconfig of the cluster shim periodically, and keeps them with
+condition CertificateExpiring, which is true if any certificate expires in threshold,
+so that certificates of clusters are renewed before they expire.
+Credentials other than certificates, e.g., tokens, are not checked.
+*/
+type CertExpiryReporter struct {
+	restConfig *rest.Config
+	threshold  time.Duration
+	now        func() time.Time
+	// servingCerts returns certificates served by the apiserver, nil if it is not served by tls.
+	servingCerts func() ([]*x509.Certificate, error)
+	// onChange is called when the certificates or status of the condition change.
+	onChange func()
+
+	lock   sync.Mutex
+	status otev1.ClusterStatus
+}
+
+// newCertExpiryReporter returns a CertExpiryReporter, nil if no rest config or threshold is set.
+func newCertExpiryReporter(ctx *ReporterContext, onChange func()) *CertExpiryReporter {
+	if ctx.RestConfig == nil || ctx.CertExpiryThreshold <= 0 {
+		return nil
+	}
+	c := &CertExpiryReporter{
+		restConfig: ctx.RestConfig,
+		threshold:  ctx.CertExpiryThreshold,
+		now:        time.Now,
+		onChange:   onChange,
+	}
+	c.servingCerts = c.dialServingCerts
+	return c
+}
+
+// Certificates returns the latest expiries of certificates, nil if not checked yet.
+func (c *CertExpiryReporter) Certificates() []otev1.CertificateExpiry {
+	if c == nil {
+		return nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]otev1.CertificateExpiry(nil), c.status.Certificates...)
+}
+
+// Condition returns the latest condition CertificateExpiring, nil if not checked yet.
+func (c *CertExpiryReporter) Condition() *otev1.ClusterCondition {
+	if c == nil {
+		return nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	cond := c.status.GetCondition(otev1.ClusterConditionCertificateExpiring)
+	if cond == nil {
+		return nil
+	}
+	ret := *cond
+	return &ret
+}
+
+func (c *CertExpiryReporter) check() {
+	var certs []otev1.CertificateExpiry
+	var failures []string
+	served, err := c.servingCerts()
+	if err != nil {
+		failures = append(failures, fmt.Sprintf("get certificates of apiserver failed: %v", err))
+	}
+	certs = append(certs, certificateExpiries(certNameAPIServer, served)...)
+	tlsConfig := &c.restConfig.TLSClientConfig
+	for _, src := range []struct {
+		name string
+		data []byte
+		file string
+	}{
+		{certNameClient, tlsConfig.CertData, tlsConfig.CertFile},
+		{certNameCA, tlsConfig.CAData, tlsConfig.CAFile},
+	} {
+		loaded, err := loadCertificates(src.data, src.file)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("load certificates of %s failed: %v", src.name, err))
+		}
+		certs = append(certs, certificateExpiries(src.name, loaded)...)
+	}
+
+	now := c.now()
+	cond := otev1.ClusterCondition{
+		Type:               otev1.ClusterConditionCertificateExpiring,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: now.Unix(),
+		Reason:             certExpiryReasonValid,
+	}
+	var expiring []string
+	for _, cert := range certs {
+		notAfter := time.Unix(cert.NotAfter, 0)
+		if notAfter.Sub(now) >= c.threshold {
+			continue
+		}
+		if !notAfter.After(now) {
+			cond.Reason = certExpiryReasonExpired
+		} else if cond.Reason != certExpiryReasonExpired {
+			cond.Reason = certExpiryReasonExpiring
+		}
+		expiring = append(expiring, fmt.Sprintf("%s %s expires at %s",
+			cert.Name, cert.Subject, notAfter.UTC().Format(time.RFC3339)))
+	}
+	switch {
+	case len(expiring) != 0:
+		cond.Status = corev1.ConditionTrue
+		cond.Message = strings.Join(append(expiring, failures...), "; ")
+		klog.Warningf("certificates expire in %v: %s", c.threshold, cond.Message)
+	case len(failures) != 0 && len(certs) == 0:
+		cond.Status = corev1.ConditionUnknown
+		cond.Reason = certExpiryReasonCheckFailed
+		cond.Message = strings.Join(failures, "; ")
+		klog.Warningf("certificate expiry check failed: %s", cond.Message)
+	case len(failures) != 0:
+		cond.Message = strings.Join(failures, "; ")
+	}
+
+	c.lock.Lock()
+	old := c.status.GetCondition(otev1.ClusterConditionCertificateExpiring)
+	changed := old == nil || old.Status != cond.Status || old.Reason != cond.Reason ||
+		!reflect.DeepEqual(c.status.Certificates, certs)
+	c.status.SetCondition(cond)
+	c.status.Certificates = certs
+	c.lock.Unlock()
+	if changed && c.onChange != nil {
+		c.onChange()
+	}
+}
+
+// dialServingCerts returns certificates sent by the apiserver in tls handshake.
+// They are only read for expiries, so the chain is not verified.
+func (c *CertExpiryReporter) dialServingCerts() ([]*x509.Certificate, error) {
+	host := c.restConfig.Host
+	if !strings.Contains(host, "://") {
+		if !rest.IsConfigTransportTLS(*c.restConfig) {
+			return nil, nil
+		}
+		host = "https://" + host
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" {
+		return nil, nil
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "443")
+	}
+	serverName := c.restConfig.TLSClientConfig.ServerName
+	if serverName == "" {
+		serverName = u.Hostname()
+	}
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: connectivityCheckTimeout}, "tcp", addr,
+		&tls.Config{ServerName: serverName, InsecureSkipVerify: true})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates, nil
+}
+
+// loadCertificates parses pem certificates in data, or in file if data is empty.
+func loadCertificates(data []byte, file string) ([]*x509.Certificate, error) {
+	if len(data) == 0 {
+		if file == "" {
+			return nil, nil
+		}
+		var err error
+		if data, err = ioutil.ReadFile(file); err != nil {
+			return nil, err
+		}
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return certs, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate found")
+	}
+	return certs, nil
+}
+
+func certificateExpiries(name string, certs []*x509.Certificate) []otev1.CertificateExpiry {
+	var ret []otev1.CertificateExpiry
+	for _, cert := range certs {
+		ret = append(ret, otev1.CertificateExpiry{
+			Name:     name,
+			Subject:  cert.Subject.CommonName,
+			NotAfter: cert.NotAfter.Unix(),
+		})
+	}
+	return ret
+}
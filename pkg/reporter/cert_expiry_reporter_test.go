@@ -0,0 +1,173 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reporter
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+// newTestCertificate returns a self signed certificate of name expiring at notAfter, and its pem.
+func newTestCertificate(t *testing.T, name string, notAfter time.Time) (*x509.Certificate, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+	return cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestNewCertExpiryReporter(t *testing.T) {
+	assert.Nil(t, newCertExpiryReporter(&ReporterContext{CertExpiryThreshold: time.Hour}, nil))
+	assert.Nil(t, newCertExpiryReporter(&ReporterContext{RestConfig: &rest.Config{}}, nil))
+	assert.NotNil(t, newCertExpiryReporter(&ReporterContext{
+		RestConfig:          &rest.Config{},
+		CertExpiryThreshold: time.Hour,
+	}, nil))
+
+	var c *CertExpiryReporter
+	assert.Nil(t, c.Condition())
+	assert.Nil(t, c.Certificates())
+}
+
+func TestLoadCertificates(t *testing.T) {
+	_, ca1 := newTestCertificate(t, "ca1", time.Now().Add(time.Hour))
+	_, ca2 := newTestCertificate(t, "ca2", time.Now().Add(time.Hour))
+	certs, err := loadCertificates(append(ca1, ca2...), "")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(certs))
+	assert.Equal(t, "ca2", certs[1].Subject.CommonName)
+
+	certs, err = loadCertificates(nil, "")
+	assert.Nil(t, err)
+	assert.Nil(t, certs)
+	_, err = loadCertificates(nil, "/not/exist")
+	assert.NotNil(t, err)
+	_, err = loadCertificates([]byte("not a pem"), "")
+	assert.NotNil(t, err)
+}
+
+func TestCertExpiryCheck(t *testing.T) {
+	now := time.Unix(1000000000, 0)
+	serving, _ := newTestCertificate(t, "kube-apiserver", now.Add(60*24*time.Hour))
+	_, client := newTestCertificate(t, "admin", now.Add(10*24*time.Hour))
+	_, ca := newTestCertificate(t, "kubernetes", now.Add(3650*24*time.Hour))
+
+	changed := 0
+	c := newCertExpiryReporter(&ReporterContext{
+		RestConfig: &rest.Config{TLSClientConfig: rest.TLSClientConfig{
+			CertData: client,
+			CAData:   ca,
+		}},
+		CertExpiryThreshold: 30 * 24 * time.Hour,
+	}, func() { changed++ })
+	c.now = func() time.Time { return now }
+	c.servingCerts = func() ([]*x509.Certificate, error) {
+		return []*x509.Certificate{serving}, nil
+	}
+
+	// client certificate expires in threshold.
+	c.check()
+	cond := c.Condition()
+	assert.Equal(t, corev1.ConditionTrue, cond.Status)
+	assert.Equal(t, certExpiryReasonExpiring, cond.Reason)
+	assert.Equal(t, "kubeconfig-client admin expires at 2001-09-19T01:46:40Z", cond.Message)
+	assert.Equal(t, 3, len(c.Certificates()))
+	assert.Equal(t, certNameAPIServer, c.Certificates()[0].Name)
+	assert.Equal(t, serving.NotAfter.Unix(), c.Certificates()[0].NotAfter)
+	assert.Equal(t, 1, changed)
+
+	// not changed
+	c.check()
+	assert.Equal(t, 1, changed)
+
+	// apiserver certificate expired, and checking fails is noted.
+	now = now.Add(90 * 24 * time.Hour)
+	c.servingCerts = func() ([]*x509.Certificate, error) {
+		return nil, fmt.Errorf("connection refused")
+	}
+	c.restConfig.TLSClientConfig.CertData = nil
+	c.check()
+	cond = c.Condition()
+	assert.Equal(t, corev1.ConditionFalse, cond.Status)
+	assert.Equal(t, certExpiryReasonValid, cond.Reason)
+	assert.Equal(t, "get certificates of apiserver failed: connection refused", cond.Message)
+	assert.Equal(t, 1, len(c.Certificates()))
+	assert.Equal(t, 2, changed)
+
+	c.servingCerts = func() ([]*x509.Certificate, error) {
+		return []*x509.Certificate{serving}, nil
+	}
+	c.check()
+	cond = c.Condition()
+	assert.Equal(t, corev1.ConditionTrue, cond.Status)
+	assert.Equal(t, certExpiryReasonExpired, cond.Reason)
+	assert.Equal(t, 3, changed)
+
+	// nothing checked.
+	c.restConfig.TLSClientConfig.CAData = []byte("not a pem")
+	c.servingCerts = func() ([]*x509.Certificate, error) {
+		return nil, fmt.Errorf("connection refused")
+	}
+	c.check()
+	cond = c.Condition()
+	assert.Equal(t, corev1.ConditionUnknown, cond.Status)
+	assert.Equal(t, certExpiryReasonCheckFailed, cond.Reason)
+	assert.Nil(t, c.Certificates())
+}
+
+func TestDialServingCerts(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+
+	c := &CertExpiryReporter{restConfig: &rest.Config{Host: server.URL}}
+	certs, err := c.dialServingCerts()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(certs))
+	assert.Equal(t, server.Certificate().NotAfter, certs[0].NotAfter)
+
+	// apiserver of plain http.
+	c.restConfig.Host = "http://127.0.0.1:8080"
+	certs, err = c.dialServingCerts()
+	assert.Nil(t, err)
+	assert.Nil(t, certs)
+	c.restConfig.Host = "127.0.0.1:8080"
+	certs, err = c.dialServingCerts()
+	assert.Nil(t, err)
+	assert.Nil(t, certs)
+}
@@ -29,6 +29,7 @@ import (
 
 	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
 	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/config"
 )
 
 const (
@@ -37,9 +38,12 @@ const (
 
 // ClusterStatusReporter is responsible for synchronizing information about the status of a cluster.
 type ClusterStatusReporter struct {
-	syncChan    chan clustermessage.ClusterMessage
-	kubeClient  kubernetes.Interface
-	clusterName func() string
+	syncChan     chan clustermessage.ClusterMessage
+	kubeClient   kubernetes.Interface
+	clusterName  func() string
+	connectivity *ConnectivityReporter
+	// certExpiry is nil if certificates are not checked.
+	certExpiry *CertExpiryReporter
 }
 
 func startClusterStatusReporter(ctx *ReporterContext) error {
@@ -62,11 +66,16 @@ func newClusterStatusReporter(ctx *ReporterContext) (*ClusterStatusReporter, err
 		return nil, fmt.Errorf("kubeclient is nil")
 	}
 
-	return &ClusterStatusReporter{
+	reporter := &ClusterStatusReporter{
 		syncChan:    ctx.SyncChan,
 		kubeClient:  ctx.KubeClient,
 		clusterName: ctx.ClusterName,
-	}, nil
+	}
+	// report at once when connectivity changes, rather than in the next period.
+	reporter.connectivity = newConnectivityReporter(ctx, reporter.syncClusterStatus)
+	reporter.certExpiry = newCertExpiryReporter(ctx, reporter.syncClusterStatus)
+	ctx.registerResync(resyncPriorityClusterStatus, reporter.syncClusterStatus)
+	return reporter, nil
 }
 
 // Run starts a cron job that synchronizes information of the cluster.
@@ -74,15 +83,21 @@ func (c *ClusterStatusReporter) Run(stopCh <-chan struct{}) {
 	klog.Infof("Starting cluster status reporter")
 	defer klog.Infof("Shutting down cluster status reporter")
 
+	go wait.Until(c.connectivity.check, connectivityCheckPeriod, stopCh)
+	if c.certExpiry != nil {
+		go wait.Until(c.certExpiry.check, certExpiryCheckPeriod, stopCh)
+	}
 	go wait.Until(c.syncClusterStatus, clusterStatusSyncPeriod, stopCh)
 
 	<-stopCh
 }
 
 func (c *ClusterStatusReporter) syncClusterStatus() {
+	hlc := config.HybridNow()
 	status := &otev1.ClusterStatus{
 		Timestamp: time.Now().Unix(),
 		Status:    otev1.ClusterStatusOnline,
+		HLC:       &hlc,
 	}
 
 	list, err := c.kubeClient.CoreV1().Nodes().List(metav1.ListOptions{})
@@ -92,6 +107,13 @@ func (c *ClusterStatusReporter) syncClusterStatus() {
 	} else {
 		status.ClusterResource = *caculateClusterResource(list)
 	}
+	if cond := c.connectivity.Condition(); cond != nil {
+		status.SetCondition(*cond)
+	}
+	if cond := c.certExpiry.Condition(); cond != nil {
+		status.SetCondition(*cond)
+		status.Certificates = c.certExpiry.Certificates()
+	}
 
 	clusterStatusJSON, err := status.Serialize()
 	if err != nil {
@@ -266,3 +266,28 @@ func TestSyncClusterStatus(t *testing.T) {
 		}
 	}
 }
+
+func TestSyncClusterStatusConnectivity(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	ctx := &ReporterContext{
+		ClusterName:     func() string { return "test-cluster" },
+		SyncChan:        make(chan clustermessage.ClusterMessage, 1),
+		KubeClient:      client,
+		InformerFactory: informers.NewSharedInformerFactory(client, 1*time.Second),
+		StopChan:        make(chan struct{}, 1),
+	}
+	report, err := newClusterStatusReporter(ctx)
+	assert.NoError(t, err)
+
+	// status is reported once connectivity is checked the first time.
+	report.connectivity.check()
+	msg := <-ctx.SyncChan
+	result := []Report{}
+	assert.NoError(t, json.Unmarshal(msg.Body, &result))
+	status, err := otev1.ClusterStatusDeserialize(result[0].Body)
+	assert.NoError(t, err)
+	cond := status.GetCondition(otev1.ClusterConditionConnectivity)
+	if assert.NotNil(t, cond) {
+		assert.Equal(t, corev1.ConditionTrue, cond.Status)
+	}
+}
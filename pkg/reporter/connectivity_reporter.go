@@ -0,0 +1,137 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reporter
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	kubernetes "k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+)
+
+const (
+	connectivityCheckPeriod  = 30 * time.Second
+	connectivityCheckTimeout = 5 * time.Second
+
+	// reasons of condition Connectivity, failures of local dns and apiserver come first
+	// since the parent is unreachable if the edge itself is broken.
+	connectivityReasonConnected            = "Connected"
+	connectivityReasonDNSFailed            = "DNSFailed"
+	connectivityReasonAPIServerUnreachable = "APIServerUnreachable"
+	connectivityReasonParentUnreachable    = "ParentUnreachable"
+)
+
+/*
+ConnectivityReporter checks resolution of local dns, reachability of the k8s apiserver
+and of the parent endpoint periodically, and keeps the result as condition Connectivity,
+which is reported in the cluster status to tell failures of WAN from local ones.
+Checks of dns and parent are skipped if no names or endpoint are set.
+*/
+type ConnectivityReporter struct {
+	kubeClient     kubernetes.Interface
+	parentEndpoint string
+	dnsNames       []string
+	lookupHost     func(ctx context.Context, host string) ([]string, error)
+	dial           func(network, address string, timeout time.Duration) (net.Conn, error)
+	// onChange is called when status of the condition changes.
+	onChange func()
+
+	lock   sync.Mutex
+	status otev1.ClusterStatus
+}
+
+func newConnectivityReporter(ctx *ReporterContext, onChange func()) *ConnectivityReporter {
+	return &ConnectivityReporter{
+		kubeClient:     ctx.KubeClient,
+		parentEndpoint: ctx.ParentEndpoint,
+		dnsNames:       ctx.DNSNames,
+		lookupHost:     net.DefaultResolver.LookupHost,
+		dial:           net.DialTimeout,
+		onChange:       onChange,
+	}
+}
+
+// Condition returns the latest condition Connectivity, nil if not checked yet.
+func (c *ConnectivityReporter) Condition() *otev1.ClusterCondition {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	cond := c.status.GetCondition(otev1.ClusterConditionConnectivity)
+	if cond == nil {
+		return nil
+	}
+	ret := *cond
+	return &ret
+}
+
+func (c *ConnectivityReporter) check() {
+	reason := connectivityReasonConnected
+	var failures []string
+	fail := func(r string, err error) {
+		if reason == connectivityReasonConnected {
+			reason = r
+		}
+		failures = append(failures, err.Error())
+	}
+	for _, name := range c.dnsNames {
+		ctx, cancel := context.WithTimeout(context.Background(), connectivityCheckTimeout)
+		_, err := c.lookupHost(ctx, name)
+		cancel()
+		if err != nil {
+			fail(connectivityReasonDNSFailed, fmt.Errorf("resolve %s failed: %v", name, err))
+		}
+	}
+	if _, err := c.kubeClient.Discovery().ServerVersion(); err != nil {
+		fail(connectivityReasonAPIServerUnreachable, fmt.Errorf("apiserver is unreachable: %v", err))
+	}
+	if c.parentEndpoint != "" {
+		conn, err := c.dial("tcp", c.parentEndpoint, connectivityCheckTimeout)
+		if err != nil {
+			fail(connectivityReasonParentUnreachable, fmt.Errorf("parent %s is unreachable: %v", c.parentEndpoint, err))
+		} else {
+			conn.Close()
+		}
+	}
+
+	cond := otev1.ClusterCondition{
+		Type:               otev1.ClusterConditionConnectivity,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: time.Now().Unix(),
+		Reason:             reason,
+	}
+	if len(failures) != 0 {
+		cond.Status = corev1.ConditionFalse
+		cond.Message = strings.Join(failures, "; ")
+		klog.Warningf("connectivity check failed: %s", cond.Message)
+	}
+
+	c.lock.Lock()
+	old := c.status.GetCondition(otev1.ClusterConditionConnectivity)
+	changed := old == nil || old.Status != cond.Status || old.Reason != cond.Reason
+	c.status.SetCondition(cond)
+	c.lock.Unlock()
+	if changed && c.onChange != nil {
+		c.onChange()
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reporter
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestConnectivityReporter(dnsErr, dialErr error) (*ConnectivityReporter, *int) {
+	changed := 0
+	c := newConnectivityReporter(&ReporterContext{
+		KubeClient:     fake.NewSimpleClientset(),
+		ParentEndpoint: "parent:8287",
+		DNSNames:       []string{"kubernetes.default.svc.cluster.local"},
+	}, func() { changed++ })
+	c.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		return []string{"10.0.0.1"}, dnsErr
+	}
+	c.dial = func(network, address string, timeout time.Duration) (net.Conn, error) {
+		if dialErr != nil {
+			return nil, dialErr
+		}
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+	return c, &changed
+}
+
+func TestConnectivityCheck(t *testing.T) {
+	c, changed := newTestConnectivityReporter(nil, nil)
+	assert.Nil(t, c.Condition())
+
+	c.check()
+	cond := c.Condition()
+	assert.NotNil(t, cond)
+	assert.Equal(t, corev1.ConditionTrue, cond.Status)
+	assert.Equal(t, connectivityReasonConnected, cond.Reason)
+	assert.Equal(t, 1, *changed)
+
+	// not changed
+	c.check()
+	assert.Equal(t, 1, *changed)
+
+	// parent unreachable
+	c.dial = func(network, address string, timeout time.Duration) (net.Conn, error) {
+		return nil, fmt.Errorf("i/o timeout")
+	}
+	c.check()
+	cond = c.Condition()
+	assert.Equal(t, corev1.ConditionFalse, cond.Status)
+	assert.Equal(t, connectivityReasonParentUnreachable, cond.Reason)
+	assert.Equal(t, "parent parent:8287 is unreachable: i/o timeout", cond.Message)
+	assert.Equal(t, 2, *changed)
+
+	// local failure comes first
+	c.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		return nil, fmt.Errorf("no such host")
+	}
+	c.check()
+	cond = c.Condition()
+	assert.Equal(t, connectivityReasonDNSFailed, cond.Reason)
+	assert.Contains(t, cond.Message, "no such host")
+	assert.Contains(t, cond.Message, "i/o timeout")
+	assert.Equal(t, 3, *changed)
+}
+
+func TestConnectivityCheckSkipped(t *testing.T) {
+	c, _ := newTestConnectivityReporter(fmt.Errorf("no such host"), fmt.Errorf("i/o timeout"))
+	c.dnsNames = nil
+	c.parentEndpoint = ""
+	c.check()
+	assert.Equal(t, corev1.ConditionTrue, c.Condition().Status)
+}
@@ -60,6 +60,9 @@ func startDaemonsetReporter(ctx *ReporterContext) error {
 		},
 		DeleteFunc: daemonsetReporter.deleteDaemonset,
 	})
+	ctx.registerResync(resyncPriorityWorkload, func() {
+		resyncStore(ctx.InformerFactory.Apps().V1().DaemonSets().Informer().GetStore(), daemonsetReporter.handleDaemonset)
+	})
 
 	return nil
 }
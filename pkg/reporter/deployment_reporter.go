@@ -60,6 +60,9 @@ func startDeploymentReporter(ctx *ReporterContext) error {
 		},
 		DeleteFunc: deploymentReporter.deleteDeployment,
 	})
+	ctx.registerResync(resyncPriorityWorkload, func() {
+		resyncStore(ctx.InformerFactory.Apps().V1().Deployments().Informer().GetStore(), deploymentReporter.handleDeployment)
+	})
 
 	return nil
 }
@@ -0,0 +1,195 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reporter
+
+import (
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+// EndpointsReporter reports endpoints of exported services, so that they can be discovered
+// by center and other clusters.
+type EndpointsReporter struct {
+	SyncChan chan clustermessage.ClusterMessage
+	ctx      *ReporterContext
+
+	serviceLister   corelisters.ServiceLister
+	endpointsLister corelisters.EndpointsLister
+}
+
+// startEndpointsReporter inits endpoints reporter and starts to watch endpoints resource.
+func startEndpointsReporter(ctx *ReporterContext) error {
+	if !ctx.IsValid() {
+		return fmt.Errorf("ReporterContext validation failed")
+	}
+
+	endpointsReporter := &EndpointsReporter{
+		ctx:             ctx,
+		SyncChan:        ctx.SyncChan,
+		serviceLister:   ctx.InformerFactory.Core().V1().Services().Lister(),
+		endpointsLister: ctx.InformerFactory.Core().V1().Endpoints().Lister(),
+	}
+
+	ctx.InformerFactory.Core().V1().Endpoints().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: endpointsReporter.handleEndpoints,
+		UpdateFunc: func(old, new interface{}) {
+			newEndpoints := new.(*corev1.Endpoints)
+			oldEndpoints := old.(*corev1.Endpoints)
+			if newEndpoints.ResourceVersion == oldEndpoints.ResourceVersion {
+				// Periodic resync will send update events for all known Endpoints.
+				return
+			}
+			endpointsReporter.handleEndpoints(new)
+		},
+		DeleteFunc: endpointsReporter.deleteEndpoints,
+	})
+	ctx.registerResync(resyncPriorityService, func() {
+		resyncStore(ctx.InformerFactory.Core().V1().Endpoints().Informer().GetStore(), endpointsReporter.handleEndpoints)
+	})
+	// report or delete endpoints when a service is exported or unexported.
+	ctx.InformerFactory.Core().V1().Services().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: endpointsReporter.handleServiceExportChanged,
+	})
+
+	return nil
+}
+
+// isExported returns true if the service of endpoints is exported.
+func (er *EndpointsReporter) isExported(endpoints *corev1.Endpoints) bool {
+	service, err := er.serviceLister.Services(endpoints.Namespace).Get(endpoints.Name)
+	if err != nil {
+		return false
+	}
+	return IsServiceExported(service)
+}
+
+// handleEndpoints is used to handle the creation and update operations of the endpoints.
+func (er *EndpointsReporter) handleEndpoints(obj interface{}) {
+	endpoints, ok := obj.(*corev1.Endpoints)
+	if !ok {
+		klog.Errorf("Should be Endpoints object but encounter others in handleEndpoints.")
+		return
+	}
+	if !er.isExported(endpoints) {
+		return
+	}
+	klog.V(3).Infof("handle Endpoints: %s", endpoints.Name)
+
+	er.report(endpoints, false)
+}
+
+// deleteEndpoints is used to handle the removal of the endpoints.
+func (er *EndpointsReporter) deleteEndpoints(obj interface{}) {
+	endpoints, ok := obj.(*corev1.Endpoints)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			klog.Errorf("Should be Endpoints object but encounter others in deleteEndpoints")
+			return
+		}
+		if endpoints, ok = tombstone.Obj.(*corev1.Endpoints); !ok {
+			klog.Errorf("Should be Endpoints object but encounter others in deleteEndpoints")
+			return
+		}
+	}
+	// service may be deleted before endpoints, so deletion is always reported,
+	// center ignores endpoints not found.
+	klog.V(3).Infof("Endpoints: %s deleted.", endpoints.Name)
+
+	er.report(endpoints, true)
+}
+
+// handleServiceExportChanged reports endpoints of a service newly exported,
+// or deletes endpoints of a service not exported any more.
+func (er *EndpointsReporter) handleServiceExportChanged(old, new interface{}) {
+	oldService := old.(*corev1.Service)
+	newService := new.(*corev1.Service)
+	exported := IsServiceExported(newService)
+	if IsServiceExported(oldService) == exported {
+		return
+	}
+	endpoints, err := er.endpointsLister.Endpoints(newService.Namespace).Get(newService.Name)
+	if err != nil {
+		klog.V(3).Infof("get endpoints of service %s failed: %v", newService.Name, err)
+		return
+	}
+	klog.Infof("service %s/%s exported changes to %v", newService.Namespace, newService.Name, exported)
+	er.report(endpoints, !exported)
+}
+
+func (er *EndpointsReporter) report(endpoints *corev1.Endpoints, deleted bool) {
+	// do not modify the object in informer cache.
+	endpoints = endpoints.DeepCopy()
+	addLabelToResource(&endpoints.ObjectMeta, er.ctx)
+
+	// generates unique key for endpoints.
+	key, err := cache.MetaNamespaceKeyFunc(endpoints)
+	if err != nil {
+		klog.Errorf("Failed to get map key: %s", err)
+		return
+	}
+
+	endpointsMap := &EndpointsResourceStatus{}
+	if deleted {
+		endpointsMap.DelMap = map[string]*corev1.Endpoints{key: endpoints}
+	} else {
+		endpointsMap.UpdateMap = map[string]*corev1.Endpoints{key: endpoints}
+	}
+
+	go er.sendToSyncChan(endpointsMap)
+}
+
+// sendToSyncChan sends wrapped ClusterMessage data to SyncChan.
+func (er *EndpointsReporter) sendToSyncChan(endpointsMap *EndpointsResourceStatus) {
+	endpointsReports, err := endpointsMap.serializeMapToReporters()
+	if err != nil {
+		klog.Errorf("serialize map failed: %v", err)
+		return
+	}
+
+	msg, err := endpointsReports.ToClusterMessage(er.ctx.ClusterName())
+	if err != nil {
+		klog.Errorf("change endpoints Reports to clustermessage failed: %v", err)
+		return
+	}
+
+	er.SyncChan <- *msg
+}
+
+// serializeMapToReporters serializes EndpointsResourceStatus and converts to Reports.
+func (er *EndpointsResourceStatus) serializeMapToReporters() (Reports, error) {
+	endpointsJSON, err := json.Marshal(er)
+	if err != nil {
+		return nil, err
+	}
+
+	data := Reports{
+		{
+			ResourceType: ResourceTypeEndpoints,
+			Body:         endpointsJSON,
+		},
+	}
+
+	return data, nil
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reporter
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newExportedService(exported bool) *corev1.Service {
+	service := newService()
+	if exported {
+		service.Labels = map[string]string{ServiceExportLabel: "true"}
+	}
+	return service
+}
+
+func newEndpoints() *corev1.Endpoints {
+	return &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       namespace,
+			ResourceVersion: "10",
+		},
+		Subsets: []corev1.EndpointSubset{
+			{
+				Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}},
+				Ports:     []corev1.EndpointPort{{Port: 80}},
+			},
+		},
+	}
+}
+
+func (f *fixture) newEndpointsReporter(service *corev1.Service) *EndpointsReporter {
+	ctx := f.newReportContext()
+	if service != nil {
+		ctx.InformerFactory.Core().V1().Services().Informer().GetIndexer().Add(service)
+	}
+	ctx.InformerFactory.Core().V1().Endpoints().Informer().GetIndexer().Add(newEndpoints())
+
+	return &EndpointsReporter{
+		ctx:             ctx,
+		SyncChan:        ctx.SyncChan,
+		serviceLister:   ctx.InformerFactory.Core().V1().Services().Lister(),
+		endpointsLister: ctx.InformerFactory.Core().V1().Endpoints().Lister(),
+	}
+}
+
+func receiveEndpointsReport(t *testing.T, er *EndpointsReporter) *EndpointsResourceStatus {
+	select {
+	case data := <-er.SyncChan:
+		assert.Equal(t, clusterName, data.Head.ClusterName)
+		reports := []Report{}
+		assert.Nil(t, json.Unmarshal(data.Body, &reports))
+		assert.Equal(t, ResourceTypeEndpoints, reports[0].ResourceType)
+		ers := &EndpointsResourceStatus{}
+		assert.Nil(t, json.Unmarshal(reports[0].Body, ers))
+		return ers
+	case <-time.After(100 * time.Millisecond):
+		return nil
+	}
+}
+
+func TestStartEndpointsReporter(t *testing.T) {
+	f := newFixture(t)
+	assert.Nil(t, startEndpointsReporter(f.newReportContext()))
+	assert.NotNil(t, startEndpointsReporter(&ReporterContext{}))
+}
+
+func TestIsServiceExported(t *testing.T) {
+	assert.False(t, IsServiceExported(newExportedService(false)))
+	service := newExportedService(true)
+	assert.True(t, IsServiceExported(service))
+	service.Labels[ServiceImportLabel] = "c1"
+	assert.False(t, IsServiceExported(service))
+}
+
+func TestHandleEndpoints(t *testing.T) {
+	f := newFixture(t)
+
+	// endpoints of service not exported are not reported
+	er := f.newEndpointsReporter(newExportedService(false))
+	er.handleEndpoints(newEndpoints())
+	assert.Nil(t, receiveEndpointsReport(t, er))
+	er = f.newEndpointsReporter(nil)
+	er.handleEndpoints(newEndpoints())
+	assert.Nil(t, receiveEndpointsReport(t, er))
+
+	er = f.newEndpointsReporter(newExportedService(true))
+	endpoints := newEndpoints()
+	er.handleEndpoints(endpoints)
+	ers := receiveEndpointsReport(t, er)
+	assert.NotNil(t, ers)
+	reported := ers.UpdateMap[namespace+"/"+name]
+	assert.Equal(t, clusterName, reported.Labels[ClusterLabel])
+	assert.Equal(t, "10", reported.Labels[EdgeVersionLabel])
+	assert.Equal(t, "10.0.0.1", reported.Subsets[0].Addresses[0].IP)
+	// object in cache is not modified
+	assert.Nil(t, endpoints.Labels)
+}
+
+func TestDeleteEndpoints(t *testing.T) {
+	f := newFixture(t)
+	er := f.newEndpointsReporter(nil)
+	er.deleteEndpoints(newEndpoints())
+	ers := receiveEndpointsReport(t, er)
+	assert.NotNil(t, ers)
+	assert.NotNil(t, ers.DelMap[namespace+"/"+name])
+
+	er.deleteEndpoints(cache.DeletedFinalStateUnknown{Obj: newEndpoints()})
+	assert.NotNil(t, receiveEndpointsReport(t, er))
+}
+
+func TestHandleServiceExportChanged(t *testing.T) {
+	f := newFixture(t)
+	er := f.newEndpointsReporter(nil)
+
+	er.handleServiceExportChanged(newExportedService(false), newExportedService(false))
+	assert.Nil(t, receiveEndpointsReport(t, er))
+
+	er.handleServiceExportChanged(newExportedService(false), newExportedService(true))
+	ers := receiveEndpointsReport(t, er)
+	assert.NotNil(t, ers)
+	assert.Len(t, ers.UpdateMap, 1)
+
+	er.handleServiceExportChanged(newExportedService(true), newExportedService(false))
+	ers = receiveEndpointsReport(t, er)
+	assert.NotNil(t, ers)
+	assert.Len(t, ers.DelMap, 1)
+}
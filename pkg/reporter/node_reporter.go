@@ -19,6 +19,8 @@ package reporter
 import (
 	"encoding/json"
 	"fmt"
+	"sync"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/tools/cache"
@@ -32,6 +34,18 @@ type NodeReporter struct {
 	SyncChan chan clustermessage.ClusterMessage
 
 	ctx *ReporterContext
+
+	// masks holds nodes turning not ready in grace period, keyed by node key.
+	masks     map[string]*nodeMask
+	masksLock sync.Mutex
+	// reportedReady records whether the last reported status of a node is ready.
+	reportedReady map[string]bool
+}
+
+// nodeMask is a not ready node held in grace period.
+type nodeMask struct {
+	node  *corev1.Node
+	timer *time.Timer
 }
 
 // newNodeReporter creates a new NodeReporter.
@@ -41,8 +55,10 @@ func newNodeReporter(ctx *ReporterContext) (*NodeReporter, error) {
 	}
 
 	nodeReporter := &NodeReporter{
-		ctx:      ctx,
-		SyncChan: ctx.SyncChan,
+		ctx:           ctx,
+		SyncChan:      ctx.SyncChan,
+		masks:         make(map[string]*nodeMask),
+		reportedReady: make(map[string]bool),
 	}
 
 	ctx.InformerFactory.Core().V1().Nodes().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
@@ -59,6 +75,9 @@ func newNodeReporter(ctx *ReporterContext) (*NodeReporter, error) {
 		},
 		DeleteFunc: nodeReporter.deleteNode,
 	})
+	ctx.registerResync(resyncPriorityNode, func() {
+		resyncStore(ctx.InformerFactory.Core().V1().Nodes().Informer().GetStore(), nodeReporter.handleNode)
+	})
 
 	return nodeReporter, nil
 }
@@ -92,7 +111,15 @@ func (nr *NodeReporter) handleNode(obj interface{}) {
 
 	klog.V(3).Infof("find node (namespace/name): %s", key)
 
-	// adds node objects to UpdateMap.
+	if nr.maskNotReady(key, node) {
+		return
+	}
+
+	nr.reportNode(key, node)
+}
+
+// reportNode adds node objects to UpdateMap, and sends it to center.
+func (nr *NodeReporter) reportNode(key string, node *corev1.Node) {
 	nodeMap := &NodeResourceStatus{
 		UpdateMap: map[string]*corev1.Node{
 			key: node,
@@ -117,6 +144,8 @@ func (nr *NodeReporter) deleteNode(obj interface{}) {
 		return
 	}
 
+	nr.unmask(key)
+
 	// adds node objects to DelMap.
 	nodeMap := &NodeResourceStatus{
 		DelMap: map[string]*corev1.Node{
@@ -127,6 +156,80 @@ func (nr *NodeReporter) deleteNode(obj interface{}) {
 	go nr.sendToSyncChan(nodeMap)
 }
 
+// maskNotReady holds a ready node turning not ready in grace period, and returns true if it is held.
+// The node is reported when grace period expires, or drops if it is ready again in grace period.
+func (nr *NodeReporter) maskNotReady(key string, node *corev1.Node) bool {
+	if nr.ctx.NodeNotReadyGracePeriod <= 0 {
+		return false
+	}
+
+	nr.masksLock.Lock()
+	defer nr.masksLock.Unlock()
+	ready := hasReadyCondition(node)
+	if ready || !nr.reportedReady[key] {
+		if mask, ok := nr.masks[key]; ok {
+			mask.timer.Stop()
+			delete(nr.masks, key)
+			if ready {
+				klog.Infof("node %s is ready again in grace period", key)
+			}
+		}
+		nr.reportedReady[key] = ready
+		return false
+	}
+
+	if mask, ok := nr.masks[key]; ok {
+		// keep the latest status to report when grace period expires.
+		mask.node = node
+		return true
+	}
+	klog.Infof("node %s is not ready, mask it for %v", key, nr.ctx.NodeNotReadyGracePeriod)
+	nr.masks[key] = &nodeMask{
+		node: node,
+		timer: time.AfterFunc(nr.ctx.NodeNotReadyGracePeriod, func() {
+			nr.expireMask(key)
+		}),
+	}
+	return true
+}
+
+// expireMask reports the not ready node held when grace period expires.
+func (nr *NodeReporter) expireMask(key string) {
+	nr.masksLock.Lock()
+	mask, ok := nr.masks[key]
+	if ok {
+		delete(nr.masks, key)
+		nr.reportedReady[key] = false
+	}
+	nr.masksLock.Unlock()
+
+	if ok {
+		klog.Infof("node %s is not ready after grace period, report it", key)
+		nr.reportNode(key, mask.node)
+	}
+}
+
+func (nr *NodeReporter) unmask(key string) {
+	nr.masksLock.Lock()
+	defer nr.masksLock.Unlock()
+	if mask, ok := nr.masks[key]; ok {
+		mask.timer.Stop()
+		delete(nr.masks, key)
+	}
+	delete(nr.reportedReady, key)
+}
+
+// hasReadyCondition returns true if the ready condition of node is true,
+// unlike isNodeReady, an unschedulable node is not masked.
+func hasReadyCondition(node *corev1.Node) bool {
+	for _, c := range node.Status.Conditions {
+		if c.Type == corev1.NodeReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
 func (nr *NodeReporter) sendToSyncChan(nodeMap *NodeResourceStatus) {
 	nodeReports, err := nodeMap.serializeMapToReports()
 	if err != nil {
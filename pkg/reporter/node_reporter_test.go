@@ -19,6 +19,7 @@ package reporter
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
@@ -138,3 +139,85 @@ func TestDelNode(t *testing.T) {
 
 	close(nodeReporter.SyncChan)
 }
+
+func newFakeReadyNode(name string, ready corev1.ConditionStatus) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{ClusterLabel: clusterName},
+		},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: ready},
+			},
+		},
+	}
+}
+
+func receiveReportedNode(t *testing.T, nodeReporter *NodeReporter, timeout time.Duration) *corev1.Node {
+	select {
+	case data := <-nodeReporter.SyncChan:
+		ret := []Report{}
+		assert.Nil(t, json.Unmarshal(data.Body, &ret))
+		nrs := NodeResourceStatus{}
+		assert.Nil(t, json.Unmarshal(ret[0].Body, &nrs))
+		for _, node := range nrs.UpdateMap {
+			return node
+		}
+	case <-time.After(timeout):
+	}
+	return nil
+}
+
+func TestMaskNotReadyNode(t *testing.T) {
+	f := newFixtureNode(t)
+	nodeReporter := f.newNodeReporter()
+	nodeReporter.ctx.NodeNotReadyGracePeriod = 200 * time.Millisecond
+
+	// not ready node never reported ready is reported at once
+	nodeReporter.handleNode(newFakeReadyNode("n1", corev1.ConditionFalse))
+	assert.NotNil(t, receiveReportedNode(t, nodeReporter, time.Second))
+
+	nodeReporter.handleNode(newFakeReadyNode("n1", corev1.ConditionTrue))
+	assert.NotNil(t, receiveReportedNode(t, nodeReporter, time.Second))
+
+	// brief not ready is masked
+	nodeReporter.handleNode(newFakeReadyNode("n1", corev1.ConditionUnknown))
+	assert.Nil(t, receiveReportedNode(t, nodeReporter, 50*time.Millisecond))
+	nodeReporter.handleNode(newFakeReadyNode("n1", corev1.ConditionTrue))
+	node := receiveReportedNode(t, nodeReporter, time.Second)
+	assert.NotNil(t, node)
+	assert.True(t, hasReadyCondition(node))
+	assert.Nil(t, receiveReportedNode(t, nodeReporter, 300*time.Millisecond))
+
+	// not ready after grace period is reported with the latest status
+	nodeReporter.handleNode(newFakeReadyNode("n1", corev1.ConditionUnknown))
+	nodeReporter.handleNode(newFakeReadyNode("n1", corev1.ConditionFalse))
+	node = receiveReportedNode(t, nodeReporter, time.Second)
+	assert.NotNil(t, node)
+	assert.Equal(t, corev1.ConditionFalse, node.Status.Conditions[0].Status)
+	assert.False(t, nodeReporter.reportedReady["n1"])
+
+	// deleted node is not masked any more
+	nodeReporter.handleNode(newFakeReadyNode("n1", corev1.ConditionTrue))
+	assert.NotNil(t, receiveReportedNode(t, nodeReporter, time.Second))
+	nodeReporter.handleNode(newFakeReadyNode("n1", corev1.ConditionFalse))
+	nodeReporter.deleteNode(newFakeReadyNode("n1", corev1.ConditionFalse))
+	select {
+	case <-nodeReporter.SyncChan:
+	case <-time.After(time.Second):
+		t.Fatal("delete is not reported")
+	}
+	assert.Nil(t, receiveReportedNode(t, nodeReporter, 300*time.Millisecond))
+	assert.Len(t, nodeReporter.masks, 0)
+}
+
+func TestHasReadyCondition(t *testing.T) {
+	assert.True(t, hasReadyCondition(newFakeReadyNode("n1", corev1.ConditionTrue)))
+	assert.False(t, hasReadyCondition(newFakeReadyNode("n1", corev1.ConditionFalse)))
+	assert.False(t, hasReadyCondition(&corev1.Node{}))
+
+	node := newFakeReadyNode("n1", corev1.ConditionTrue)
+	node.Spec.Unschedulable = true
+	assert.True(t, hasReadyCondition(node))
+}
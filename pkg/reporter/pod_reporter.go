@@ -74,6 +74,11 @@ func newPodReporter(ctx *ReporterContext) (*PodReporter, error) {
 		},
 		DeleteFunc: podReporter.deletePod,
 	})
+	// pods are sent at once rather than in the next tick on resync.
+	ctx.registerResync(resyncPriorityWorkload, func() {
+		resyncStore(ctx.InformerFactory.Core().V1().Pods().Informer().GetStore(), podReporter.handlePod)
+		podReporter.sendClusterMessageToSyncChan()
+	})
 
 	return podReporter, nil
 }
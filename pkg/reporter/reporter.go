@@ -19,12 +19,15 @@ package reporter
 
 import (
 	"encoding/json"
+	"sync"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/informers"
 	kubernetes "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/klog"
 
 	"github.com/baidu/ote-stack/pkg/clustermessage"
@@ -39,10 +42,18 @@ const (
 	ResourceTypeStatefulset
 	ResourceTypeClusterStatus
 	ResourceTypeEvent
+	ResourceTypeEndpoints
 
 	ClusterLabel     = "ote-cluster"
 	EdgeVersionLabel = "edge-version"
 	EdgeNodeName     = "node-name"
+
+	// ServiceExportLabel is set to "true" on a service of edge cluster to export it,
+	// the endpoints of exported services are reported to center.
+	ServiceExportLabel = "ote-service-export"
+	// ServiceImportLabel is set to the cluster name of the service imported from,
+	// imported services are not reported to center.
+	ServiceImportLabel = "ote-service-import"
 )
 
 // Report defines edge report content.
@@ -117,6 +128,16 @@ type EventResourceStatus struct {
 	FullList []*corev1.Event `json:"fullList"`
 }
 
+//EndpointsResourceStatus defines endpoints resource status.
+type EndpointsResourceStatus struct {
+	// UpdateMap stores created/updated resource obj.
+	UpdateMap map[string]*corev1.Endpoints `json:"updateMap"`
+	// DelMap stores deleted resource obj.
+	DelMap map[string]*corev1.Endpoints `json:"delMap"`
+	// FullList stores full resource obj.
+	FullList []*corev1.Endpoints `json:"fullList"`
+}
+
 // ReporterContext defines the context object for reporter.
 type ReporterContext struct {
 	// InformerFactory gives access to informers for the reporter.
@@ -129,6 +150,22 @@ type ReporterContext struct {
 	StopChan <-chan struct{}
 	// KubeClient is the kubernetes client interface for the reporter to use.
 	KubeClient kubernetes.Interface
+	// NodeNotReadyGracePeriod is the time a ready node turning not ready is still reported as ready,
+	// so that a brief NotReady is not seen by center. 0 means NotReady is reported at once.
+	NodeNotReadyGracePeriod time.Duration
+	// ParentEndpoint is the address of the parent cluster to check reachability, not checked if empty.
+	ParentEndpoint string
+	// DNSNames are the names to resolve to check local dns, not checked if empty.
+	DNSNames []string
+	// RestConfig is the config of KubeClient, whose certificates and those of the apiserver
+	// are checked for expiry if CertExpiryThreshold is set.
+	RestConfig *rest.Config
+	// CertExpiryThreshold is the time before expiry of a certificate to raise condition
+	// CertificateExpiring, certificates are not checked if 0.
+	CertExpiryThreshold time.Duration
+
+	resyncLock sync.Mutex
+	resyncs    []resyncFunc
 }
 
 // InitFunc is used to launch a particular reporter.
@@ -145,6 +182,7 @@ func NewReporterInitializers() map[string]InitFunc {
 	reporters["deployment"] = startDeploymentReporter
 	reporters["daemonset"] = startDaemonsetReporter
 	reporters["service"] = startServiceReporter
+	reporters["endpoints"] = startEndpointsReporter
 	// Immediate reporting will cause timeliness problems and need to be transformed into regular reporting
 	//reporters["event"] = startEventReporter
 
@@ -201,3 +239,8 @@ func addLabelToResource(resource *metav1.ObjectMeta, ctx *ReporterContext) {
 	// support for CM sequential checking
 	resource.Labels[EdgeVersionLabel] = resource.ResourceVersion
 }
+
+// IsServiceExported returns true if the service is exported to other clusters.
+func IsServiceExported(service *corev1.Service) bool {
+	return service.Labels[ServiceExportLabel] == "true" && service.Labels[ServiceImportLabel] == ""
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reporter
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+)
+
+// Priorities of resyncs, resyncs of lower priority are reported first,
+// so that center knows clusters and nodes before the workloads on them.
+const (
+	resyncPriorityClusterStatus = iota
+	resyncPriorityNode
+	resyncPriorityWorkload
+	resyncPriorityService
+)
+
+// resyncFunc reports all resources of a reporter again.
+type resyncFunc struct {
+	priority int
+	fn       func()
+}
+
+// registerResync registers fn to be called by Resync in order of priority.
+func (ctx *ReporterContext) registerResync(priority int, fn func()) {
+	ctx.resyncLock.Lock()
+	defer ctx.resyncLock.Unlock()
+	ctx.resyncs = append(ctx.resyncs, resyncFunc{priority: priority, fn: fn})
+	sort.SliceStable(ctx.resyncs, func(i, j int) bool {
+		return ctx.resyncs[i].priority < ctx.resyncs[j].priority
+	})
+}
+
+// Resync reports all resources of reporters started with ctx again,
+// cluster status and nodes first, it is used when center lost the resources mirrored.
+func (ctx *ReporterContext) Resync() {
+	ctx.resyncLock.Lock()
+	resyncs := make([]resyncFunc, len(ctx.resyncs))
+	copy(resyncs, ctx.resyncs)
+	ctx.resyncLock.Unlock()
+
+	klog.Infof("resync all resources of %d reporters", len(resyncs))
+	for _, r := range resyncs {
+		r.fn()
+	}
+}
+
+// resyncStore calls handle with copies of all objects in store.
+func resyncStore(store cache.Store, handle func(obj interface{})) {
+	for _, obj := range store.List() {
+		if o, ok := obj.(runtime.Object); ok {
+			handle(o.DeepCopyObject())
+		}
+	}
+}
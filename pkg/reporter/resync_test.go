@@ -0,0 +1,78 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reporter
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeinformers "k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+func TestResyncOrder(t *testing.T) {
+	ctx := &ReporterContext{}
+	var order []string
+	ctx.registerResync(resyncPriorityService, func() { order = append(order, "service") })
+	ctx.registerResync(resyncPriorityWorkload, func() { order = append(order, "pod") })
+	ctx.registerResync(resyncPriorityClusterStatus, func() { order = append(order, "cluster") })
+	ctx.registerResync(resyncPriorityNode, func() { order = append(order, "node") })
+	ctx.registerResync(resyncPriorityWorkload, func() { order = append(order, "deployment") })
+
+	ctx.Resync()
+	assert.Equal(t, []string{"cluster", "node", "pod", "deployment", "service"}, order)
+}
+
+func TestResyncPods(t *testing.T) {
+	k8sI := kubeinformers.NewSharedInformerFactory(k8sfake.NewSimpleClientset(), noResyncPeriodFunc())
+	ctx := &ReporterContext{
+		InformerFactory: k8sI,
+		ClusterName: func() string {
+			return clusterName
+		},
+		SyncChan: make(chan clustermessage.ClusterMessage, 1),
+		StopChan: make(<-chan struct{}),
+	}
+	_, err := newPodReporter(ctx)
+	assert.Nil(t, err)
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       namespace,
+			ResourceVersion: "10",
+		},
+	}
+	k8sI.Core().V1().Pods().Informer().GetStore().Add(pod)
+
+	ctx.Resync()
+	msg := <-ctx.SyncChan
+	var reports []Report
+	assert.Nil(t, json.Unmarshal(msg.Body, &reports))
+	assert.Len(t, reports, 1)
+	status := PodResourceStatus{}
+	assert.Nil(t, json.Unmarshal(reports[0].Body, &status))
+	assert.Contains(t, status.UpdateMap, mapKey)
+	assert.Equal(t, clusterName, status.UpdateMap[mapKey].Labels[ClusterLabel])
+	// the object in informer store is not changed.
+	assert.Nil(t, pod.Labels)
+}
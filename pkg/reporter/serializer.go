@@ -0,0 +1,335 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+const (
+	EncodingJSON     = "json"
+	EncodingProtobuf = "protobuf"
+	EncodingCBOR     = "cbor"
+
+	// encodingMarker starts bodies not in json, followed by the id of the encoding.
+	// Bodies in json have no marker, so that they are compatible with older clusters.
+	encodingMarker = 0x00
+)
+
+// Serializer encodes and decodes bodies of edge reports.
+type Serializer interface {
+	// Name is the name of the encoding negotiated between clusters.
+	Name() string
+	Marshal(r Reports) ([]byte, error)
+	Unmarshal(b []byte) (Reports, error)
+}
+
+var (
+	serializersLock sync.RWMutex
+	serializerIDs   = map[string]byte{}
+	serializers     = map[byte]Serializer{}
+	// encodings are the encodings accepted from children, in preference order.
+	encodings = []string{EncodingJSON}
+	// upstreamEncoding is the encoding negotiated with the upstream, which is the parent,
+	// or controller managers of root.
+	upstreamEncoding atomic.Value
+)
+
+func init() {
+	RegisterSerializer(0, jsonSerializer{})
+	RegisterSerializer(1, protobufSerializer{})
+	RegisterSerializer(2, cborSerializer{})
+}
+
+// RegisterSerializer registers s with id, which is written before bodies encoded by s.
+// id 0 is json, whose bodies are written without id.
+func RegisterSerializer(id byte, s Serializer) {
+	serializersLock.Lock()
+	defer serializersLock.Unlock()
+	serializerIDs[s.Name()] = id
+	serializers[id] = s
+}
+
+// SetEncodings sets the encodings accepted from children in preference order,
+// which are also offered to parent. json is always accepted.
+func SetEncodings(names []string) error {
+	serializersLock.Lock()
+	defer serializersLock.Unlock()
+	accepted := make([]string, 0, len(names)+1)
+	hasJSON := false
+	for _, name := range names {
+		if _, ok := serializerIDs[name]; !ok {
+			return fmt.Errorf("report encoding %s is not supported", name)
+		}
+		hasJSON = hasJSON || name == EncodingJSON
+		accepted = append(accepted, name)
+	}
+	if !hasJSON {
+		accepted = append(accepted, EncodingJSON)
+	}
+	encodings = accepted
+	return nil
+}
+
+// EncodingSupported returns true if encoding name is registered.
+func EncodingSupported(name string) bool {
+	serializersLock.RLock()
+	defer serializersLock.RUnlock()
+	_, ok := serializerIDs[name]
+	return ok
+}
+
+// Encodings returns the encodings accepted in preference order, separated by comma.
+func Encodings() string {
+	serializersLock.RLock()
+	defer serializersLock.RUnlock()
+	return strings.Join(encodings, ",")
+}
+
+// NegotiateEncoding returns the encoding used by a peer offering encodings separated by comma
+// in preference order, which is the first one accepted. Peers offering none use json.
+func NegotiateEncoding(offered string) string {
+	serializersLock.RLock()
+	defer serializersLock.RUnlock()
+	for _, name := range strings.Split(offered, ",") {
+		name = strings.TrimSpace(name)
+		for _, accepted := range encodings {
+			if name == accepted {
+				return name
+			}
+		}
+	}
+	return EncodingJSON
+}
+
+// SetUpstreamEncoding sets the encoding negotiated with the upstream, json if name is empty.
+func SetUpstreamEncoding(name string) {
+	if name == "" {
+		name = EncodingJSON
+	}
+	upstreamEncoding.Store(name)
+}
+
+// UpstreamEncoding returns the encoding negotiated with the upstream,
+// edge reports are transcoded to it before sent to the upstream.
+func UpstreamEncoding() string {
+	if name, ok := upstreamEncoding.Load().(string); ok {
+		return name
+	}
+	return EncodingJSON
+}
+
+// EncodeReports encodes r by the encoding name.
+func EncodeReports(r Reports, name string) ([]byte, error) {
+	serializersLock.RLock()
+	id, ok := serializerIDs[name]
+	s := serializers[id]
+	serializersLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("report encoding %s is not supported", name)
+	}
+
+	b, err := s.Marshal(r)
+	if err != nil || id == 0 {
+		return b, err
+	}
+	return append([]byte{encodingMarker, id}, b...), nil
+}
+
+// DecodeReports decodes b in any encoding registered.
+func DecodeReports(b []byte) (Reports, error) {
+	s, payload, err := serializerOf(b)
+	if err != nil {
+		return nil, err
+	}
+	return s.Unmarshal(payload)
+}
+
+// ReportsEncoding returns the encoding of b.
+func ReportsEncoding(b []byte) string {
+	s, _, err := serializerOf(b)
+	if err != nil {
+		return ""
+	}
+	return s.Name()
+}
+
+func serializerOf(b []byte) (Serializer, []byte, error) {
+	serializersLock.RLock()
+	defer serializersLock.RUnlock()
+	if len(b) == 0 || b[0] != encodingMarker {
+		return serializers[0], b, nil
+	}
+	if len(b) < 2 {
+		return nil, nil, fmt.Errorf("report encoding is missing")
+	}
+	s, ok := serializers[b[1]]
+	if !ok {
+		return nil, nil, fmt.Errorf("report encoding %d is not supported", b[1])
+	}
+	return s, b[2:], nil
+}
+
+// Transcode encodes the body of edge report msg by the encoding name if it is in
+// another encoding, and returns true if the body is changed.
+func Transcode(msg *clustermessage.ClusterMessage, name string) (bool, error) {
+	if msg.Head == nil || msg.Head.Command != clustermessage.CommandType_EdgeReport {
+		return false, nil
+	}
+	if ReportsEncoding(msg.Body) == name {
+		return false, nil
+	}
+	r, err := DecodeReports(msg.Body)
+	if err != nil {
+		return false, fmt.Errorf("decode reports failed: %v", err)
+	}
+	body, err := EncodeReports(r, name)
+	if err != nil {
+		return false, err
+	}
+	msg.Body = body
+	return true, nil
+}
+
+type jsonSerializer struct{}
+
+func (jsonSerializer) Name() string {
+	return EncodingJSON
+}
+
+func (jsonSerializer) Marshal(r Reports) ([]byte, error) {
+	return json.Marshal(r)
+}
+
+func (jsonSerializer) Unmarshal(b []byte) (Reports, error) {
+	r := Reports{}
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// protobufSerializer encodes reports as protobuf message
+//
+//	message Reports { repeated Report reports = 1; }
+//	message Report { int64 resourceType = 1; bytes body = 2; }
+type protobufSerializer struct{}
+
+const (
+	protobufTagReports      = 1<<3 | 2
+	protobufTagResourceType = 1 << 3
+	protobufTagBody         = 2<<3 | 2
+)
+
+func (protobufSerializer) Name() string {
+	return EncodingProtobuf
+}
+
+func (protobufSerializer) Marshal(r Reports) ([]byte, error) {
+	buf := proto.NewBuffer(nil)
+	report := proto.NewBuffer(nil)
+	for _, rep := range r {
+		report.Reset()
+		report.EncodeVarint(protobufTagResourceType)
+		report.EncodeVarint(uint64(rep.ResourceType))
+		report.EncodeVarint(protobufTagBody)
+		report.EncodeRawBytes(rep.Body)
+
+		buf.EncodeVarint(protobufTagReports)
+		buf.EncodeRawBytes(report.Bytes())
+	}
+	return buf.Bytes(), nil
+}
+
+func (protobufSerializer) Unmarshal(b []byte) (Reports, error) {
+	r := Reports{}
+	for len(b) > 0 {
+		tag, data, rest, err := decodeProtobufField(b)
+		if err != nil {
+			return nil, err
+		}
+		if tag != protobufTagReports {
+			return nil, fmt.Errorf("unexpected tag %d in reports", tag)
+		}
+		rep, err := unmarshalProtobufReport(data)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, rep)
+		b = rest
+	}
+	return r, nil
+}
+
+func unmarshalProtobufReport(b []byte) (Report, error) {
+	rep := Report{}
+	for len(b) > 0 {
+		tag, data, rest, err := decodeProtobufField(b)
+		if err != nil {
+			return rep, err
+		}
+		switch tag {
+		case protobufTagResourceType:
+			v, n := proto.DecodeVarint(data)
+			if n == 0 {
+				return rep, fmt.Errorf("invalid resource type")
+			}
+			rep.ResourceType = int(v)
+		case protobufTagBody:
+			rep.Body = append([]byte{}, data...)
+		default:
+			return rep, fmt.Errorf("unexpected tag %d in report", tag)
+		}
+		b = rest
+	}
+	return rep, nil
+}
+
+// decodeProtobufField decodes a varint or length-delimited field from b,
+// and returns its tag, its value and the rest of b.
+func decodeProtobufField(b []byte) (uint64, []byte, []byte, error) {
+	tag, n := proto.DecodeVarint(b)
+	if n == 0 {
+		return 0, nil, nil, fmt.Errorf("invalid tag")
+	}
+	b = b[n:]
+	switch tag & 7 {
+	case 0:
+		_, n = proto.DecodeVarint(b)
+		if n == 0 {
+			return 0, nil, nil, fmt.Errorf("invalid varint of tag %d", tag)
+		}
+		return tag, b[:n], b[n:], nil
+	case 2:
+		size, n := proto.DecodeVarint(b)
+		if n == 0 || size > uint64(len(b)-n) {
+			return 0, nil, nil, fmt.Errorf("invalid length of tag %d", tag)
+		}
+		b = b[n:]
+		return tag, b[:size], b[size:], nil
+	default:
+		return 0, nil, nil, fmt.Errorf("unexpected wire type of tag %d", tag)
+	}
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+func newTestReports() Reports {
+	return Reports{
+		{ResourceType: ResourceTypePod, Body: []byte(`{"fullList":[]}`)},
+		{ResourceType: ResourceTypeEvent, Body: bytes.Repeat([]byte("e"), 300)},
+		{ResourceType: -1, Body: []byte{}},
+	}
+}
+
+func TestEncodeReports(t *testing.T) {
+	reports := newTestReports()
+	for _, name := range []string{EncodingJSON, EncodingProtobuf, EncodingCBOR} {
+		b, err := EncodeReports(reports, name)
+		assert.Nil(t, err, name)
+		assert.Equal(t, name, ReportsEncoding(b))
+		decoded, err := DecodeReports(b)
+		assert.Nil(t, err, name)
+		assert.Equal(t, len(reports), len(decoded), name)
+		for i := range reports {
+			assert.Equal(t, reports[i].ResourceType, decoded[i].ResourceType, name)
+			assert.Equal(t, string(reports[i].Body), string(decoded[i].Body), name)
+		}
+	}
+
+	_, err := EncodeReports(reports, "xml")
+	assert.NotNil(t, err)
+}
+
+func TestDecodeReports(t *testing.T) {
+	// json reports of older clusters.
+	b, err := json.Marshal(newTestReports())
+	assert.Nil(t, err)
+	r, err := DecodeReports(b)
+	assert.Nil(t, err)
+	assert.Len(t, r, 3)
+
+	for _, b := range [][]byte{
+		{encodingMarker},
+		{encodingMarker, 100},
+		{encodingMarker, 1, 0xff},
+		{encodingMarker, 2, 0x9f},
+		{encodingMarker, 2, 0x9a, 0xff, 0xff, 0xff, 0xff},
+		[]byte("invalid"),
+	} {
+		_, err := DecodeReports(b)
+		assert.NotNil(t, err, "%v", b)
+	}
+
+	// bodies in protobuf and cbor are shorter than base64 in json.
+	reports := Reports{{ResourceType: ResourceTypeNode, Body: bytes.Repeat([]byte("n"), 3000)}}
+	j, _ := EncodeReports(reports, EncodingJSON)
+	p, _ := EncodeReports(reports, EncodingProtobuf)
+	c, _ := EncodeReports(reports, EncodingCBOR)
+	assert.True(t, len(p) < len(j)*4/5)
+	assert.True(t, len(c) < len(j)*4/5)
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	defer SetEncodings(nil)
+
+	assert.Equal(t, EncodingJSON, Encodings())
+	assert.Equal(t, EncodingJSON, NegotiateEncoding(""))
+	assert.Equal(t, EncodingJSON, NegotiateEncoding("cbor,protobuf,json"))
+
+	assert.NotNil(t, SetEncodings([]string{"xml"}))
+	assert.Nil(t, SetEncodings([]string{EncodingProtobuf, EncodingCBOR}))
+	assert.Equal(t, "protobuf,cbor,json", Encodings())
+	assert.Equal(t, EncodingCBOR, NegotiateEncoding("cbor, protobuf"))
+	assert.Equal(t, EncodingProtobuf, NegotiateEncoding("xml,protobuf"))
+	assert.Equal(t, EncodingJSON, NegotiateEncoding("xml"))
+
+	assert.True(t, EncodingSupported(EncodingCBOR))
+	assert.False(t, EncodingSupported(""))
+}
+
+func TestTranscode(t *testing.T) {
+	defer SetUpstreamEncoding("")
+	assert.Equal(t, EncodingJSON, UpstreamEncoding())
+	SetUpstreamEncoding(EncodingCBOR)
+	assert.Equal(t, EncodingCBOR, UpstreamEncoding())
+
+	msg, err := newTestReports().ToClusterMessage("c1")
+	assert.Nil(t, err)
+	changed, err := Transcode(msg, EncodingJSON)
+	assert.Nil(t, err)
+	assert.False(t, changed)
+
+	changed, err = Transcode(msg, EncodingProtobuf)
+	assert.Nil(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, EncodingProtobuf, ReportsEncoding(msg.Body))
+	r, err := DecodeReports(msg.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, ResourceTypeEvent, r[1].ResourceType)
+
+	// messages other than edge reports are not changed.
+	ctrl := &clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{Command: clustermessage.CommandType_ControlReq},
+		Body: []byte("task"),
+	}
+	changed, err = Transcode(ctrl, EncodingProtobuf)
+	assert.Nil(t, err)
+	assert.False(t, changed)
+
+	msg.Body = []byte{encodingMarker, 100}
+	_, err = Transcode(msg, EncodingJSON)
+	assert.NotNil(t, err)
+}
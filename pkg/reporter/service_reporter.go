@@ -58,6 +58,9 @@ func startServiceReporter(ctx *ReporterContext) error {
 		},
 		DeleteFunc: serviceReporter.deleteService,
 	})
+	ctx.registerResync(resyncPriorityService, func() {
+		resyncStore(ctx.InformerFactory.Core().V1().Services().Informer().GetStore(), serviceReporter.handleService)
+	})
 
 	return nil
 }
@@ -70,6 +73,10 @@ func (sr *ServiceReporter) handleService(obj interface{}) {
 		return
 	}
 	klog.V(3).Infof("handle Service: %s", service.Name)
+	if service.Labels[ServiceImportLabel] != "" {
+		// imported from other cluster, no need to report back.
+		return
+	}
 
 	addLabelToResource(&service.ObjectMeta, sr.ctx)
 
@@ -97,6 +104,9 @@ func (sr *ServiceReporter) deleteService(obj interface{}) {
 		return
 	}
 	klog.V(3).Infof("Service: %s deleted.", service.Name)
+	if service.Labels[ServiceImportLabel] != "" {
+		return
+	}
 
 	addLabelToResource(&service.ObjectMeta, sr.ctx)
 
@@ -18,6 +18,7 @@ package reporter
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
@@ -104,3 +105,18 @@ func TestSvcSendToSyncChan(t *testing.T) {
 
 	close(serviceReporter.SyncChan)
 }
+
+func TestImportedServiceNotReported(t *testing.T) {
+	f := newFixture(t)
+	service := newService()
+	service.Labels = map[string]string{ServiceImportLabel: "c1"}
+	serviceReporter := f.newServiceReporter()
+	serviceReporter.handleService(service)
+	serviceReporter.deleteService(service)
+
+	select {
+	case <-serviceReporter.SyncChan:
+		t.Fatal("imported service should not be reported")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
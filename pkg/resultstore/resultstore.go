@@ -0,0 +1,437 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package resultstore keeps results of clusters of ClusterControllers done on root in a local directory,
+// with truncated outputs and durations, and queries them.
+/*
+Results are stored by task in files of each ClusterController created, e.g.,
+<dir>/kube-system/backup/<uid>.jsonl, with a result in json per line. The latest result of a cluster
+in a file is the one merged to the ClusterController. Files not written in the retention are removed.
+Since a ClusterController selecting thousands of clusters exceeds size limits of etcd with their outputs,
+bodies of status in ClusterControllers can be dropped, and outputs are queried from the store only.
+*/
+package resultstore
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+)
+
+const (
+	// DefaultRetention is the time to keep results by default.
+	DefaultRetention = 7 * 24 * time.Hour
+	// DefaultOutputSize is the max bytes of output stored by default.
+	DefaultOutputSize = 4096
+	// DefaultQueryLimit is the max number of results returned by a query by default.
+	DefaultQueryLimit = 1000
+
+	fileSuffix = ".jsonl"
+	// pruneInterval is the interval to remove files out of retention.
+	pruneInterval = time.Hour
+)
+
+// Config is the config of result store.
+type Config struct {
+	// Dir is the directory to store results, results are not stored if empty.
+	Dir string
+	// Retention is the time to keep results after their tasks last responded, DefaultRetention if 0.
+	Retention time.Duration
+	// OutputSize is the max bytes of output stored, outputs are not stored if 0.
+	OutputSize int
+	// CompactStatus drops bodies of status merged to ClusterControllers, which are only kept in the store.
+	CompactStatus bool
+}
+
+// Result is the result of a cluster of a task.
+type Result struct {
+	Namespace string `json:"namespace"`
+	Task      string `json:"task"`
+	// UID is the uid of the ClusterController, which tells tasks created again by the same name.
+	UID        string `json:"uid"`
+	Cluster    string `json:"cluster"`
+	StatusCode int    `json:"code"`
+	Reason     string `json:"reason,omitempty"`
+	// Created is the time the task is created, Responded is the time the result is merged on root.
+	Created   time.Time `json:"created"`
+	Responded time.Time `json:"responded"`
+	// DurationSeconds is the seconds from task created to responded.
+	DurationSeconds float64 `json:"durationSeconds"`
+	// OutputSize is the bytes of the whole output.
+	OutputSize int `json:"outputSize"`
+	// Output is the output truncated to OutputSize of config.
+	Output string `json:"output,omitempty"`
+}
+
+// Failed returns true if the status code of r is not 2xx.
+func (r *Result) Failed() bool {
+	return r.StatusCode < http.StatusOK || r.StatusCode >= http.StatusMultipleChoices
+}
+
+// Query selects results stored, empty fields match all.
+type Query struct {
+	// Namespace is the namespace of tasks, otev1.ClusterNamespace if empty.
+	Namespace string
+	Task      string
+	Cluster   string
+	// Failed selects failed results only.
+	Failed bool
+	Since  time.Time
+	Until  time.Time
+	// Limit is the max number of the latest results returned, DefaultQueryLimit if 0.
+	Limit int
+}
+
+func (q *Query) match(r *Result) bool {
+	if !q.Since.IsZero() && r.Responded.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && r.Responded.After(q.Until) {
+		return false
+	}
+	if q.Failed && !r.Failed() {
+		return false
+	}
+	return q.Cluster == "" || q.Cluster == r.Cluster
+}
+
+// Store stores results by task.
+type Store struct {
+	sync.Mutex
+	conf Config
+	// pruned is the time files are pruned last time.
+	pruned time.Time
+	now    func() time.Time
+}
+
+var defaultStore *Store
+
+// New returns a Store with conf.
+func New(conf Config) (*Store, error) {
+	if conf.Dir == "" {
+		return nil, fmt.Errorf("result store directory is empty")
+	}
+	if conf.Retention < 0 {
+		return nil, fmt.Errorf("result retention cannot be negative")
+	}
+	if conf.Retention == 0 {
+		conf.Retention = DefaultRetention
+	}
+	if conf.OutputSize < 0 {
+		return nil, fmt.Errorf("result output size cannot be negative")
+	}
+	if err := os.MkdirAll(conf.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("create result store directory %s failed: %v", conf.Dir, err)
+	}
+	return &Store{
+		conf: conf,
+		now:  time.Now,
+	}, nil
+}
+
+// Setup replaces the default store with conf, disables storing if directory is empty.
+func Setup(conf Config) error {
+	if conf.Dir == "" {
+		defaultStore = nil
+		return nil
+	}
+	s, err := New(conf)
+	if err != nil {
+		return err
+	}
+	defaultStore = s
+	klog.Infof("store results of tasks in %s for %v", conf.Dir, s.conf.Retention)
+	return nil
+}
+
+// Record stores status of cluster of cc by the default store,
+// and returns the status to merge to cc, whose body is dropped if status is compacted.
+func Record(cc *otev1.ClusterController, cluster string, status otev1.ClusterControllerStatus) otev1.ClusterControllerStatus {
+	if defaultStore == nil {
+		return status
+	}
+	return defaultStore.Record(cc, cluster, status)
+}
+
+// QueryHandler is the http handler to query the default store, by query parameters of namespace, task,
+// cluster, failed, since and until in RFC3339 and limit, and responds results in json.
+func QueryHandler(w http.ResponseWriter, r *http.Request) {
+	if defaultStore == nil {
+		http.Error(w, "result store is disabled", http.StatusNotFound)
+		return
+	}
+	q, err := parseQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	results, err := defaultStore.Query(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data, _ := json.Marshal(results)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func parseQuery(values url.Values) (Query, error) {
+	q := Query{
+		Namespace: values.Get("namespace"),
+		Task:      values.Get("task"),
+		Cluster:   values.Get("cluster"),
+	}
+	var err error
+	if s := values.Get("failed"); s != "" {
+		if q.Failed, err = strconv.ParseBool(s); err != nil {
+			return q, fmt.Errorf("failed %s is invalid", s)
+		}
+	}
+	if s := values.Get("since"); s != "" {
+		if q.Since, err = time.Parse(time.RFC3339, s); err != nil {
+			return q, fmt.Errorf("since %s is not in RFC3339: %v", s, err)
+		}
+	}
+	if s := values.Get("until"); s != "" {
+		if q.Until, err = time.Parse(time.RFC3339, s); err != nil {
+			return q, fmt.Errorf("until %s is not in RFC3339: %v", s, err)
+		}
+	}
+	if s := values.Get("limit"); s != "" {
+		if q.Limit, err = strconv.Atoi(s); err != nil || q.Limit < 0 {
+			return q, fmt.Errorf("limit %s is invalid", s)
+		}
+	}
+	return q, nil
+}
+
+// Record stores status of cluster of cc, and returns the status to merge to cc.
+func (s *Store) Record(cc *otev1.ClusterController, cluster string, status otev1.ClusterControllerStatus) otev1.ClusterControllerStatus {
+	if !validName(cc.Namespace) || !validName(cc.Name) {
+		return status
+	}
+	r := &Result{
+		Namespace:  cc.Namespace,
+		Task:       cc.Name,
+		UID:        string(cc.UID),
+		Cluster:    cluster,
+		StatusCode: status.StatusCode,
+		Reason:     status.Reason,
+		Created:    cc.CreationTimestamp.Time,
+		OutputSize: len(status.Body),
+	}
+	if s.conf.OutputSize > 0 {
+		size := len(status.Body)
+		if size > s.conf.OutputSize {
+			size = s.conf.OutputSize
+		}
+		r.Output = status.Body[:size]
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	r.Responded = s.now()
+	if !r.Created.IsZero() {
+		r.DurationSeconds = r.Responded.Sub(r.Created).Seconds()
+	}
+	if err := s.write(r); err != nil {
+		klog.Errorf("store result of %s/%s from %s failed: %v", cc.Namespace, cc.Name, cluster, err)
+		return status
+	}
+	if s.conf.CompactStatus {
+		status.Body = ""
+	}
+	return status
+}
+
+// write appends r to the file of its task, call with lock held.
+func (s *Store) write(r *Result) error {
+	if r.Responded.Sub(s.pruned) >= pruneInterval {
+		s.pruned = r.Responded
+		s.prune(r.Responded)
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	dir := s.taskDir(r.Namespace, r.Task)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	uid := r.UID
+	if uid == "" {
+		uid = "_"
+	}
+	f, err := os.OpenFile(filepath.Join(dir, url.PathEscape(uid)+fileSuffix), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// prune removes files not written in retention at now, and directories of tasks emptied, call with lock held.
+func (s *Store) prune(now time.Time) {
+	oldest := now.Add(-s.conf.Retention)
+	for _, namespace := range subdirs(s.conf.Dir) {
+		for _, task := range subdirs(filepath.Join(s.conf.Dir, namespace)) {
+			dir := filepath.Join(s.conf.Dir, namespace, task)
+			files, err := ioutil.ReadDir(dir)
+			if err != nil {
+				continue
+			}
+			kept := 0
+			for _, f := range files {
+				if f.IsDir() || !f.ModTime().Before(oldest) {
+					kept++
+					continue
+				}
+				if err := os.Remove(filepath.Join(dir, f.Name())); err != nil {
+					klog.Errorf("remove results %s failed: %v", f.Name(), err)
+					kept++
+				}
+			}
+			if kept == 0 {
+				os.Remove(dir)
+			}
+		}
+	}
+}
+
+// Query returns the latest results of clusters of tasks matched by q in time order.
+func (s *Store) Query(q Query) ([]Result, error) {
+	if q.Namespace == "" {
+		q.Namespace = otev1.ClusterNamespace
+	}
+	if q.Limit == 0 {
+		q.Limit = DefaultQueryLimit
+	}
+	if !validName(q.Namespace) || (q.Task != "" && !validName(q.Task)) {
+		return nil, fmt.Errorf("task %s/%s is invalid", q.Namespace, q.Task)
+	}
+	s.Lock()
+	defer s.Unlock()
+	tasks := []string{url.PathEscape(q.Task)}
+	if q.Task == "" {
+		tasks = subdirs(filepath.Join(s.conf.Dir, url.PathEscape(q.Namespace)))
+	}
+	results := []Result{}
+	for _, task := range tasks {
+		dir := filepath.Join(s.conf.Dir, url.PathEscape(q.Namespace), task)
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, f := range files {
+			if f.IsDir() || !strings.HasSuffix(f.Name(), fileSuffix) {
+				continue
+			}
+			matched, err := read(filepath.Join(dir, f.Name()), &q)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, matched...)
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Responded.Before(results[j].Responded)
+	})
+	if len(results) > q.Limit {
+		results = results[len(results)-q.Limit:]
+	}
+	return results, nil
+}
+
+// read returns the latest results of clusters in file matched by q.
+func read(file string, q *Query) ([]Result, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	latest := make(map[string]int)
+	var results []Result
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		r := Result{}
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			// skip result written partially.
+			continue
+		}
+		if i, ok := latest[r.Cluster]; ok {
+			results[i] = r
+			continue
+		}
+		latest[r.Cluster] = len(results)
+		results = append(results, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	matched := results[:0]
+	for _, r := range results {
+		if q.match(&r) {
+			matched = append(matched, r)
+		}
+	}
+	return matched, nil
+}
+
+// subdirs returns names of directories in dir.
+func subdirs(dir string) []string {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var ret []string
+	for _, f := range files {
+		if f.IsDir() {
+			ret = append(ret, f.Name())
+		}
+	}
+	return ret
+}
+
+// validName returns true if name can be a directory in the store.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".."
+}
+
+func (s *Store) taskDir(namespace, task string) string {
+	return filepath.Join(s.conf.Dir, url.PathEscape(namespace), url.PathEscape(task))
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resultstore
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+)
+
+var testCreated = time.Date(2019, 10, 31, 23, 0, 0, 0, time.UTC)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func newTestStore(t *testing.T, conf Config) (*Store, *fakeClock) {
+	dir, err := ioutil.TempDir("", "resultstore")
+	assert.Nil(t, err)
+	conf.Dir = dir
+	s, err := New(conf)
+	assert.Nil(t, err)
+	clock := &fakeClock{t: testCreated.Add(30 * time.Second)}
+	s.now = clock.now
+	return s, clock
+}
+
+func newClusterController(name, uid string) *otev1.ClusterController {
+	return &otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         otev1.ClusterNamespace,
+			UID:               types.UID(uid),
+			CreationTimestamp: metav1.NewTime(testCreated),
+		},
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{})
+	assert.NotNil(t, err)
+	_, err = New(Config{Dir: "/tmp", Retention: -1})
+	assert.NotNil(t, err)
+	_, err = New(Config{Dir: "/tmp", OutputSize: -1})
+	assert.NotNil(t, err)
+
+	s, _ := newTestStore(t, Config{})
+	defer os.RemoveAll(s.conf.Dir)
+	assert.Equal(t, DefaultRetention, s.conf.Retention)
+}
+
+func TestRecordAndQuery(t *testing.T) {
+	s, clock := newTestStore(t, Config{OutputSize: 4})
+	defer os.RemoveAll(s.conf.Dir)
+
+	cc := newClusterController("task1", "uid1")
+	status := s.Record(cc, "c1", otev1.ClusterControllerStatus{StatusCode: http.StatusGatewayTimeout,
+		Body: "no response", Reason: otev1.ClusterControllerStatusTimedOut})
+	assert.Equal(t, "no response", status.Body)
+	clock.t = clock.t.Add(time.Minute)
+	s.Record(cc, "c1", otev1.ClusterControllerStatus{StatusCode: http.StatusOK, Body: "succeeded"})
+	s.Record(cc, "c2", otev1.ClusterControllerStatus{StatusCode: http.StatusNotFound, Body: "nf"})
+	s.Record(newClusterController("task2", "uid2"), "c1", otev1.ClusterControllerStatus{StatusCode: http.StatusOK})
+
+	results, err := s.Query(Query{Task: "task1"})
+	assert.Nil(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, "c1", results[0].Cluster)
+	assert.Equal(t, http.StatusOK, results[0].StatusCode)
+	assert.Equal(t, "succ", results[0].Output)
+	assert.Equal(t, len("succeeded"), results[0].OutputSize)
+	assert.Equal(t, 90.0, results[0].DurationSeconds)
+	assert.Equal(t, "uid1", results[0].UID)
+	assert.Equal(t, "nf", results[1].Output)
+
+	results, err = s.Query(Query{Task: "task1", Failed: true})
+	assert.Nil(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, "c2", results[0].Cluster)
+
+	results, err = s.Query(Query{Cluster: "c1"})
+	assert.Nil(t, err)
+	assert.Len(t, results, 2)
+
+	results, err = s.Query(Query{Limit: 1})
+	assert.Nil(t, err)
+	assert.Len(t, results, 1)
+
+	results, err = s.Query(Query{Since: clock.t.Add(time.Second)})
+	assert.Nil(t, err)
+	assert.Empty(t, results)
+
+	results, err = s.Query(Query{Task: "none"})
+	assert.Nil(t, err)
+	assert.Empty(t, results)
+
+	_, err = s.Query(Query{Task: ".."})
+	assert.NotNil(t, err)
+}
+
+func TestRecordTaskCreatedAgain(t *testing.T) {
+	s, _ := newTestStore(t, Config{})
+	defer os.RemoveAll(s.conf.Dir)
+
+	s.Record(newClusterController("task1", "uid1"), "c1", otev1.ClusterControllerStatus{StatusCode: http.StatusOK})
+	s.Record(newClusterController("task1", "uid2"), "c1", otev1.ClusterControllerStatus{StatusCode: http.StatusOK})
+	results, err := s.Query(Query{Task: "task1"})
+	assert.Nil(t, err)
+	assert.Len(t, results, 2)
+	assert.Empty(t, results[0].Output)
+}
+
+func TestCompactStatus(t *testing.T) {
+	s, _ := newTestStore(t, Config{OutputSize: 100, CompactStatus: true})
+	defer os.RemoveAll(s.conf.Dir)
+
+	status := s.Record(newClusterController("task1", "uid1"), "c1",
+		otev1.ClusterControllerStatus{StatusCode: http.StatusOK, Body: "output", Timestamp: 1})
+	assert.Equal(t, otev1.ClusterControllerStatus{StatusCode: http.StatusOK, Timestamp: 1}, status)
+	results, err := s.Query(Query{Task: "task1"})
+	assert.Nil(t, err)
+	assert.Equal(t, "output", results[0].Output)
+}
+
+func TestPrune(t *testing.T) {
+	s, clock := newTestStore(t, Config{Retention: time.Hour})
+	defer os.RemoveAll(s.conf.Dir)
+
+	s.Record(newClusterController("task1", "uid1"), "c1", otev1.ClusterControllerStatus{StatusCode: http.StatusOK})
+	file := filepath.Join(s.conf.Dir, otev1.ClusterNamespace, "task1", "uid1"+fileSuffix)
+	old := clock.t.Add(-2 * time.Hour)
+	assert.Nil(t, os.Chtimes(file, old, old))
+
+	clock.t = clock.t.Add(pruneInterval)
+	s.Record(newClusterController("task2", "uid2"), "c1", otev1.ClusterControllerStatus{StatusCode: http.StatusOK})
+	_, err := os.Stat(filepath.Dir(file))
+	assert.True(t, os.IsNotExist(err))
+	results, err := s.Query(Query{})
+	assert.Nil(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, "task2", results[0].Task)
+}
+
+func TestQueryHandler(t *testing.T) {
+	defaultStore = nil
+	w := httptest.NewRecorder()
+	QueryHandler(w, httptest.NewRequest(http.MethodGet, "/results", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	status := otev1.ClusterControllerStatus{StatusCode: http.StatusOK, Body: "ok"}
+	assert.Equal(t, status, Record(newClusterController("task1", "uid1"), "c1", status))
+
+	s, _ := newTestStore(t, Config{})
+	defer os.RemoveAll(s.conf.Dir)
+	defaultStore = s
+	defer func() { defaultStore = nil }()
+	Record(newClusterController("task1", "uid1"), "c1", otev1.ClusterControllerStatus{StatusCode: http.StatusOK})
+
+	w = httptest.NewRecorder()
+	QueryHandler(w, httptest.NewRequest(http.MethodGet, "/results?failed=x", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	QueryHandler(w, httptest.NewRequest(http.MethodGet, "/results?task=task1&limit=10", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var results []Result
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &results))
+	assert.Len(t, results, 1)
+	assert.Equal(t, "c1", results[0].Cluster)
+}
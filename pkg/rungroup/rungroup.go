@@ -0,0 +1,123 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package rungroup runs long-running goroutines of a component as a group, which are stopped
+// together by canceling the context of the group, instead of stop channels of each goroutine.
+package rungroup
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// Group is a group of goroutines sharing a context, which is canceled once the group is stopped,
+// or a goroutine of the group fails, i.e., returns an error or panics.
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	lock sync.Mutex
+	// err is the error of the first goroutine failed.
+	err error
+}
+
+// New returns a group whose context is derived from parent, stopped if parent is done.
+func New(parent context.Context) *Group {
+	ctx, cancel := context.WithCancel(parent)
+	return &Group{ctx: ctx, cancel: cancel}
+}
+
+// Context returns the context of the group, done once the group is stopped.
+func (g *Group) Context() context.Context {
+	return g.ctx
+}
+
+/*
+Go runs fn named name in a goroutine of the group with the context of the group.
+fn should return once the context is done. The group is stopped if fn fails,
+while fn returning nil or the error of the context done leaves other goroutines running.
+*/
+func (g *Group) Go(name string, fn func(ctx context.Context) error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		err := g.run(name, fn)
+		if err == nil || err == g.ctx.Err() {
+			klog.V(3).Infof("%s stopped", name)
+			return
+		}
+		klog.Errorf("%s failed, stop all: %v", name, err)
+		g.lock.Lock()
+		if g.err == nil {
+			g.err = fmt.Errorf("%s failed: %v", name, err)
+		}
+		g.lock.Unlock()
+		g.cancel()
+	}()
+}
+
+// run runs fn, and returns the panic of fn as an error.
+func (g *Group) run(name string, fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			klog.Errorf("%s panicked: %v\n%s", name, r, debug.Stack())
+			err = fmt.Errorf("panicked: %v", r)
+		}
+	}()
+	return fn(g.ctx)
+}
+
+// Every runs fn named name in a goroutine of the group every interval, starting immediately,
+// until the group is stopped or fn fails.
+func (g *Group) Every(name string, interval time.Duration, fn func(ctx context.Context) error) {
+	g.Go(name, func(ctx context.Context) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := fn(ctx); err != nil {
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+// Stop cancels the context of the group, and returns without waiting for goroutines to return.
+func (g *Group) Stop() {
+	g.cancel()
+}
+
+// Wait waits for all goroutines of the group to return, and returns the error of the first one failed.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	return g.Err()
+}
+
+// Err returns the error of the first goroutine failed, nil if none failed.
+func (g *Group) Err() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.err
+}
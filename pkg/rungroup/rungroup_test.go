@@ -0,0 +1,82 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package rungroup
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// untilDone returns once ctx is done.
+func untilDone(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestStop(t *testing.T) {
+	g := New(context.Background())
+	g.Go("a", untilDone)
+	g.Go("b", func(ctx context.Context) error { return nil })
+	g.Stop()
+	assert.Nil(t, g.Wait())
+
+	// the group is stopped if the parent is done.
+	parent, cancel := context.WithCancel(context.Background())
+	g = New(parent)
+	g.Go("a", untilDone)
+	cancel()
+	assert.Nil(t, g.Wait())
+}
+
+func TestFailure(t *testing.T) {
+	g := New(context.Background())
+	g.Go("a", untilDone)
+	g.Go("b", func(ctx context.Context) error { return fmt.Errorf("broken") })
+	err := g.Wait()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "b failed: broken")
+	assert.NotNil(t, g.Context().Err())
+
+	g = New(context.Background())
+	g.Go("a", untilDone)
+	g.Go("c", func(ctx context.Context) error { panic("bug") })
+	err = g.Wait()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "c failed: panicked: bug")
+}
+
+func TestEvery(t *testing.T) {
+	g := New(context.Background())
+	runs := make(chan struct{}, 10)
+	g.Every("tick", time.Millisecond, func(ctx context.Context) error {
+		runs <- struct{}{}
+		return nil
+	})
+	<-runs
+	<-runs
+	g.Stop()
+	assert.Nil(t, g.Wait())
+
+	g = New(context.Background())
+	g.Every("tick", time.Millisecond, func(ctx context.Context) error {
+		return fmt.Errorf("broken")
+	})
+	assert.NotNil(t, g.Wait())
+}
@@ -0,0 +1,385 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package simulator runs a tree of cluster controllers in process for tests.
+// Each simulated cluster is a real clusterhandler and edgehandler connected by real tunnels
+// on local addresses, with a fake shim doing tasks, and root watches a fake k8s client,
+// so that routing, fan-out and aggregation of tasks are tested at many levels without
+// real clusters.
+package simulator
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clusterhandler"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/clusterrouter"
+	"github.com/baidu/ote-stack/pkg/clustershim"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+	"github.com/baidu/ote-stack/pkg/config"
+	"github.com/baidu/ote-stack/pkg/edgehandler"
+	oteclient "github.com/baidu/ote-stack/pkg/generated/clientset/versioned"
+	otefake "github.com/baidu/ote-stack/pkg/k8sclient/fake"
+)
+
+const (
+	clusterNamePrefix = "sim"
+	// DefaultTimeout is the time to wait for the tree routed by default.
+	DefaultTimeout = 10 * time.Second
+	// pollInterval is the interval to check routes and status of tasks.
+	pollInterval = 50 * time.Millisecond
+)
+
+// Config is the config of a simulated tree.
+type Config struct {
+	// Depth is the number of levels of clusters under root.
+	Depth int
+	// Fanout is the number of childs of root and each cluster above the bottom level.
+	Fanout int
+	// Handlers returns the shim handlers of a simulated cluster by its name.
+	// By default, a cluster responds to tasks of all destinations with 200 and its name.
+	// Destinations of the shim are process-wide capabilities,
+	// so all clusters should have handlers of the same destinations.
+	Handlers func(cluster string) clustershim.ShimHandler
+	// Timeout is the time to wait for the tree routed, DefaultTimeout if 0.
+	Timeout time.Duration
+}
+
+func (c *Config) valid() error {
+	if c.Depth <= 0 {
+		return fmt.Errorf("depth must be positive")
+	}
+	if c.Fanout <= 0 {
+		return fmt.Errorf("fanout must be positive")
+	}
+	return nil
+}
+
+// Cluster is a simulated cluster.
+type Cluster struct {
+	// Name is the path of cluster from root, e.g., sim-1-2 is the second child of sim-1.
+	Name string
+	// Parent is the name of the parent cluster, empty for root.
+	Parent string
+	// Level is the level of cluster, root is 0.
+	Level int
+	// Addr is the tunnel listen address of cluster.
+	Addr string
+	// Router is the route of subtree and neighbor of cluster.
+	Router *clusterrouter.ClusterRouter
+
+	edgeHandler edgehandler.EdgeHandler
+}
+
+// Simulator is a running simulated tree.
+type Simulator struct {
+	conf      *Config
+	k8sClient oteclient.Interface
+	// clusters are simulated clusters by name, including root.
+	clusters map[string]*Cluster
+}
+
+// Start starts a simulated tree by config, and returns it after all clusters are routed by root.
+func Start(c *Config) (*Simulator, error) {
+	if err := c.valid(); err != nil {
+		return nil, err
+	}
+	s := &Simulator{
+		conf:      c,
+		k8sClient: otefake.NewSimpleClientset(),
+		clusters:  make(map[string]*Cluster),
+	}
+	root, err := s.startRoot()
+	if err != nil {
+		return nil, err
+	}
+	parents := []*Cluster{root}
+	for level := 1; level <= c.Depth; level++ {
+		var childs []*Cluster
+		for _, parent := range parents {
+			for i := 1; i <= c.Fanout; i++ {
+				child, err := s.startCluster(parent, i)
+				if err != nil {
+					s.Stop()
+					return nil, err
+				}
+				childs = append(childs, child)
+			}
+		}
+		parents = childs
+	}
+	if err := s.waitRouted(); err != nil {
+		s.Stop()
+		return nil, err
+	}
+	klog.Infof("%d clusters simulated in %d levels", len(s.clusters)-1, c.Depth)
+	return s, nil
+}
+
+// startRoot starts root watching the fake k8s client.
+func (s *Simulator) startRoot() (*Cluster, error) {
+	addr, err := freeAddr()
+	if err != nil {
+		return nil, err
+	}
+	root := &Cluster{
+		Name:   config.RootClusterName,
+		Addr:   addr,
+		Router: clusterrouter.NewClusterRouter(),
+	}
+	ch, err := clusterhandler.NewClusterHandlerWithRouter(&config.ClusterControllerConfig{
+		TunnelListenAddr:      addr,
+		ClusterName:           config.RootClusterName,
+		ClusterUserDefineName: config.RootClusterName,
+		K8sClient:             s.k8sClient,
+		EdgeToClusterChan:     make(chan clustermessage.ClusterMessage),
+		ClusterToEdgeChan:     make(chan clustermessage.ClusterMessage),
+	}, root.Router)
+	if err != nil {
+		return nil, fmt.Errorf("create root clusterhandler failed: %v", err)
+	}
+	if err := ch.Start(); err != nil {
+		return nil, fmt.Errorf("start root clusterhandler failed: %v", err)
+	}
+	s.clusters[root.Name] = root
+	return root, nil
+}
+
+// startCluster starts the i-th child of parent, which connects to parent and listens to its childs.
+func (s *Simulator) startCluster(parent *Cluster, i int) (*Cluster, error) {
+	name := fmt.Sprintf("%s-%d", parent.Name, i)
+	if parent.Level == 0 {
+		name = fmt.Sprintf("%s-%d", clusterNamePrefix, i)
+	}
+	addr, err := freeAddr()
+	if err != nil {
+		return nil, err
+	}
+	cluster := &Cluster{
+		Name:   name,
+		Parent: parent.Name,
+		Level:  parent.Level + 1,
+		Addr:   addr,
+		Router: clusterrouter.NewClusterRouter(),
+	}
+	conf := &config.ClusterControllerConfig{
+		TunnelListenAddr:      addr,
+		ParentCluster:         []string{parent.Addr},
+		ClusterName:           name,
+		ClusterUserDefineName: name,
+		EdgeToClusterChan:     make(chan clustermessage.ClusterMessage),
+		ClusterToEdgeChan:     make(chan clustermessage.ClusterMessage),
+	}
+	shim := clustershim.NewlocalShimClientWithHandler(s.handlers(name))
+	cluster.edgeHandler = edgehandler.NewEdgeHandlerWithShim(conf, shim, cluster.Router)
+	if err := cluster.edgeHandler.Start(); err != nil {
+		return nil, fmt.Errorf("start edgehandler of %s failed: %v", name, err)
+	}
+	ch, err := clusterhandler.NewClusterHandlerWithRouter(conf, cluster.Router)
+	if err != nil {
+		return nil, fmt.Errorf("create clusterhandler of %s failed: %v", name, err)
+	}
+	if err := ch.Start(); err != nil {
+		return nil, fmt.Errorf("start clusterhandler of %s failed: %v", name, err)
+	}
+	s.clusters[name] = cluster
+	return cluster, nil
+}
+
+func (s *Simulator) handlers(cluster string) clustershim.ShimHandler {
+	if s.conf.Handlers != nil {
+		return s.conf.Handlers(cluster)
+	}
+	h := &echoHandler{cluster: cluster}
+	return clustershim.ShimHandler{
+		otev1.ClusterControllerDestAPI:   h,
+		otev1.ClusterControllerDestHelm:  h,
+		otev1.ClusterControllerDestProxy: h,
+		otev1.ClusterControllerDestApply: h,
+	}
+}
+
+// waitRouted waits until root routes to all simulated clusters.
+func (s *Simulator) waitRouted() error {
+	want := s.Names()
+	root := s.Root()
+	return poll(s.timeout(), func() bool {
+		routed := make(map[string]bool)
+		for _, name := range root.Router.SubTreeClusters() {
+			routed[name] = true
+		}
+		for _, name := range want {
+			if !routed[name] {
+				return false
+			}
+		}
+		return true
+	}, fmt.Sprintf("clusters are not routed by root in %v", s.timeout()))
+}
+
+func (s *Simulator) timeout() time.Duration {
+	if s.conf.Timeout > 0 {
+		return s.conf.Timeout
+	}
+	return DefaultTimeout
+}
+
+// Stop stops edgehandlers of all simulated clusters.
+// Clusterhandlers do not support graceful stop, and keep listening.
+func (s *Simulator) Stop() {
+	for _, cluster := range s.clusters {
+		if cluster.edgeHandler != nil {
+			cluster.edgeHandler.Stop()
+		}
+	}
+}
+
+// K8sClient returns the fake k8s client watched by root.
+func (s *Simulator) K8sClient() oteclient.Interface {
+	return s.k8sClient
+}
+
+// Root returns the root cluster.
+func (s *Simulator) Root() *Cluster {
+	return s.clusters[config.RootClusterName]
+}
+
+// Cluster returns the simulated cluster of name, nil if not found.
+func (s *Simulator) Cluster(name string) *Cluster {
+	return s.clusters[name]
+}
+
+// Names returns names of all simulated clusters except root in order.
+func (s *Simulator) Names() []string {
+	var names []string
+	for name := range s.clusters {
+		if name != config.RootClusterName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Level returns names of simulated clusters at level in order.
+func (s *Simulator) Level(level int) []string {
+	var names []string
+	for name, cluster := range s.clusters {
+		if cluster.Level == level {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Subtree returns names of simulated clusters in the subtree of cluster name in order.
+func (s *Simulator) Subtree(name string) []string {
+	if name == config.RootClusterName {
+		return s.Names()
+	}
+	var names []string
+	for _, n := range s.Names() {
+		if strings.HasPrefix(n, name+"-") {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+// Dispatch creates ClusterController name of spec in the fake k8s client,
+// which is dispatched by root to clusters selected.
+func (s *Simulator) Dispatch(name string, spec otev1.ClusterControllerSpec) error {
+	cc := &otev1.ClusterController{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: otev1.ClusterNamespace,
+			// not set by the fake client, and ClusterControllers created long ago are not dispatched.
+			CreationTimestamp: metav1.Now(),
+		},
+		Spec: spec,
+	}
+	_, err := s.k8sClient.OteV1().ClusterControllers(otev1.ClusterNamespace).Create(cc)
+	if err != nil {
+		return fmt.Errorf("create clustercontroller %s failed: %v", name, err)
+	}
+	return nil
+}
+
+// WaitForStatus waits until ClusterController name is responded by clusters,
+// and returns the status of all clusters responded.
+func (s *Simulator) WaitForStatus(name string, clusters []string,
+	timeout time.Duration) (map[string]otev1.ClusterControllerStatus, error) {
+	var status map[string]otev1.ClusterControllerStatus
+	err := poll(timeout, func() bool {
+		cc, err := s.k8sClient.OteV1().ClusterControllers(otev1.ClusterNamespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return false
+		}
+		status = cc.Status
+		for _, cluster := range clusters {
+			if _, ok := status[cluster]; !ok {
+				return false
+			}
+		}
+		return true
+	}, fmt.Sprintf("clustercontroller %s is not responded by all clusters in %v", name, timeout))
+	return status, err
+}
+
+// poll calls done until it returns true, and returns an error of msg if it is not done in timeout.
+func poll(timeout time.Duration, done func() bool, msg string) error {
+	deadline := time.Now().Add(timeout)
+	for !done() {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s", msg)
+		}
+		time.Sleep(pollInterval)
+	}
+	return nil
+}
+
+// echoHandler responds to tasks with 200 and the name of cluster.
+type echoHandler struct {
+	cluster string
+}
+
+func (e *echoHandler) Do(in *clustermessage.ClusterMessage) (*clustermessage.ClusterMessage, error) {
+	if in.Head.Command != clustermessage.CommandType_ControlReq {
+		return nil, handler.Errorf(handler.ErrUnsupportedCommand,
+			"command %s is not supported by echoHandler", in.Head.Command.String())
+	}
+	return handler.Response(handler.ControlTaskResponse(http.StatusOK, e.cluster), in.Head), nil
+}
+
+// freeAddr returns a free local address for a cloud tunnel.
+func freeAddr() (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("find free address failed: %v", err)
+	}
+	defer ln.Close()
+	return ln.Addr().String(), nil
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simulator
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+)
+
+func TestConfigValid(t *testing.T) {
+	assert.Nil(t, (&Config{Depth: 1, Fanout: 1}).valid())
+	assert.NotNil(t, (&Config{Depth: 0, Fanout: 1}).valid())
+	assert.NotNil(t, (&Config{Depth: 1, Fanout: 0}).valid())
+}
+
+func TestSimulator(t *testing.T) {
+	s, err := Start(&Config{Depth: 3, Fanout: 2})
+	assert.Nil(t, err)
+	defer s.Stop()
+
+	assert.Len(t, s.Names(), 14)
+	assert.Equal(t, []string{"sim-1", "sim-2"}, s.Level(1))
+	assert.Len(t, s.Level(3), 8)
+	assert.Equal(t, []string{"sim-1-1", "sim-1-1-1", "sim-1-1-2", "sim-1-2", "sim-1-2-1", "sim-1-2-2"},
+		s.Subtree("sim-1"))
+	assert.Equal(t, "sim-1-2", s.Cluster("sim-1-2-1").Parent)
+	assert.Equal(t, 3, s.Cluster("sim-1-2-1").Level)
+	assert.Nil(t, s.Cluster("sim-3"))
+	// each cluster routes to its own subtree only.
+	assert.ElementsMatch(t, s.Subtree("sim-1"), s.Cluster("sim-1").Router.SubTreeClusters())
+	assert.ElementsMatch(t, s.Subtree("sim-2-1"), s.Cluster("sim-2-1").Router.SubTreeClusters())
+
+	// fan out to all leaves through 2 levels of clusters, and aggregate responses in root.
+	assert.Nil(t, s.Dispatch("leaves", otev1.ClusterControllerSpec{
+		ClusterSelector: "^sim-[0-9]+-[0-9]+-[0-9]+$",
+		Destination:     otev1.ClusterControllerDestAPI,
+		Method:          http.MethodGet,
+		URL:             "/api/v1/namespaces",
+	}))
+	status, err := s.WaitForStatus("leaves", s.Level(3), 10*time.Second)
+	assert.Nil(t, err)
+	assert.Len(t, status, 8)
+	for _, cluster := range s.Level(3) {
+		assert.Equal(t, http.StatusOK, status[cluster].StatusCode)
+		assert.Equal(t, cluster, status[cluster].Body)
+	}
+
+	// route to a subtree only.
+	subtree := append([]string{"sim-2"}, s.Subtree("sim-2")...)
+	assert.Nil(t, s.Dispatch("subtree", otev1.ClusterControllerSpec{
+		ClusterSelector: "^sim-2(-|$)",
+		Destination:     otev1.ClusterControllerDestAPI,
+		Method:          http.MethodGet,
+		URL:             "/api/v1/namespaces",
+	}))
+	status, err = s.WaitForStatus("subtree", subtree, 10*time.Second)
+	assert.Nil(t, err)
+	assert.Len(t, status, len(subtree))
+	assert.NotContains(t, status, "sim-1")
+}
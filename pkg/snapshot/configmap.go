@@ -0,0 +1,101 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ConfigMapKey is the key of snapshot in the configmap.
+const ConfigMapKey = "snapshot.json"
+
+// splitConfigMap splits configmap "namespace/name" to namespace and name.
+func splitConfigMap(configMap string) (string, string, error) {
+	parts := strings.Split(configMap, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("configmap %q is not namespace/name", configMap)
+	}
+	return parts[0], parts[1], nil
+}
+
+// SaveConfigMap writes state to configMap "namespace/name", the configmap is created if not exists.
+func SaveConfigMap(client kubernetes.Interface, configMap string, state *State) error {
+	namespace, name, err := splitConfigMap(configMap)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("marshal snapshot failed: %v", err)
+	}
+	cms := client.CoreV1().ConfigMaps(namespace)
+	cm, err := cms.Get(name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Data:       map[string]string{ConfigMapKey: string(data)},
+		}
+		if _, err := cms.Create(cm); err != nil {
+			return fmt.Errorf("create snapshot configmap %s failed: %v", configMap, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("get snapshot configmap %s failed: %v", configMap, err)
+	}
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data[ConfigMapKey] = string(data)
+	if _, err := cms.Update(cm); err != nil {
+		return fmt.Errorf("update snapshot configmap %s failed: %v", configMap, err)
+	}
+	return nil
+}
+
+// LoadConfigMap reads state from configMap "namespace/name",
+// os.ErrNotExist is returned if there is no snapshot.
+func LoadConfigMap(client kubernetes.Interface, configMap string) (*State, error) {
+	namespace, name, err := splitConfigMap(configMap)
+	if err != nil {
+		return nil, err
+	}
+	cm, err := client.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, os.ErrNotExist
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get snapshot configmap %s failed: %v", configMap, err)
+	}
+	data, ok := cm.Data[ConfigMapKey]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	state := &State{}
+	if err := json.Unmarshal([]byte(data), state); err != nil {
+		return nil, fmt.Errorf("unmarshal snapshot in configmap %s failed: %v", configMap, err)
+	}
+	return state, nil
+}
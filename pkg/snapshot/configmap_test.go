@@ -0,0 +1,75 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/baidu/ote-stack/pkg/clusterrouter"
+)
+
+func TestSaveAndLoadConfigMap(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	configMap := "kube-system/ote-root-snapshot"
+
+	_, err := LoadConfigMap(client, configMap)
+	assert.True(t, os.IsNotExist(err))
+
+	state := &State{
+		Version:     "1.0",
+		ClusterName: "root",
+		Time:        100,
+		Router: &clusterrouter.Snapshot{
+			ParentNeighbor: map[string]string{"p1": "192.168.0.4:8287"},
+		},
+	}
+	// created at first, and updated then
+	assert.Nil(t, SaveConfigMap(client, configMap, state))
+	state.Time = 200
+	assert.Nil(t, SaveConfigMap(client, configMap, state))
+	loaded, err := LoadConfigMap(client, configMap)
+	assert.Nil(t, err)
+	assert.Equal(t, state, loaded)
+
+	assert.NotNil(t, SaveConfigMap(client, "invalid", state))
+	_, err = LoadConfigMap(client, "kube-system/")
+	assert.NotNil(t, err)
+}
+
+func TestRestoreConfigMap(t *testing.T) {
+	conf := Config{
+		ClusterName: "root",
+		ConfigMap:   "kube-system/ote-root-snapshot",
+		KubeClient:  fake.NewSimpleClientset(),
+	}
+	ok, err := Restore(conf)
+	assert.False(t, ok)
+	assert.Nil(t, err)
+
+	save(conf)
+	_, err = LoadConfigMap(conf.KubeClient, conf.ConfigMap)
+	assert.Nil(t, err)
+	conf.MaxAge = time.Hour
+	ok, err = Restore(conf)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package snapshot saves runtime state of clustercontroller to disk periodically,
+// and restores it on startup, so that a restarted clustercontroller can route
+// messages to its subtree and recover its connection without waiting for
+// the whole subtree to report again.
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/clusterrouter"
+	"github.com/baidu/ote-stack/pkg/config"
+)
+
+const (
+	// DefaultInterval is the default interval to save snapshot.
+	DefaultInterval = 10 * time.Second
+	// DefaultMaxAge is the default max age of a snapshot to restore.
+	DefaultMaxAge = 10 * time.Minute
+	// DefaultRouteGracePeriod is the default time to wait for childs to reconnect,
+	// after which routes restored from ports not reconnected are removed.
+	DefaultRouteGracePeriod = time.Minute
+)
+
+// State is the runtime state of clustercontroller saved to disk.
+type State struct {
+	Version     string                  `json:"version"`
+	ClusterName string                  `json:"clusterName"`
+	Time        int64                   `json:"time"`
+	Router      *clusterrouter.Snapshot `json:"router"`
+}
+
+// Config is the config to save and restore snapshot.
+type Config struct {
+	// Path is the file to save snapshot.
+	Path        string
+	ClusterName string
+	// Interval is the interval to save snapshot.
+	Interval time.Duration
+	// MaxAge is the max age of a snapshot to restore, older ones are ignored.
+	MaxAge time.Duration
+	// RouteGracePeriod is the time to wait for childs to reconnect after restore.
+	RouteGracePeriod time.Duration
+	// ConfigMap is the configmap "namespace/name" to save snapshot instead of Path,
+	// which is shared by active and standby roots.
+	ConfigMap string
+	// KubeClient is the client of k8s to save snapshot in ConfigMap.
+	KubeClient kubernetes.Interface
+}
+
+func (c *Config) setDefault() {
+	if c.Interval <= 0 {
+		c.Interval = DefaultInterval
+	}
+	if c.MaxAge <= 0 {
+		c.MaxAge = DefaultMaxAge
+	}
+	if c.RouteGracePeriod <= 0 {
+		c.RouteGracePeriod = DefaultRouteGracePeriod
+	}
+}
+
+// Current returns the current runtime state.
+func Current(clusterName string) *State {
+	return &State{
+		Version:     config.Version,
+		ClusterName: clusterName,
+		Time:        time.Now().Unix(),
+		Router:      clusterrouter.Router().Snapshot(),
+	}
+}
+
+// Save writes state to path atomically.
+func Save(path string, state *State) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("marshal snapshot failed: %v", err)
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("create snapshot file failed: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write snapshot file failed: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write snapshot file failed: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("rename snapshot file failed: %v", err)
+	}
+	return nil
+}
+
+// Load reads state from path.
+func Load(path string) (*State, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	state := &State{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("unmarshal snapshot %s failed: %v", path, err)
+	}
+	return state, nil
+}
+
+// location returns where the snapshot of conf is saved.
+func (c *Config) location() string {
+	if c.ConfigMap != "" {
+		return "configmap " + c.ConfigMap
+	}
+	return c.Path
+}
+
+// load reads state from ConfigMap if it is set, or from Path.
+func (c *Config) load() (*State, error) {
+	if c.ConfigMap != "" {
+		return LoadConfigMap(c.KubeClient, c.ConfigMap)
+	}
+	return Load(c.Path)
+}
+
+// save writes state to ConfigMap if it is set, or to Path.
+func (c *Config) save(state *State) error {
+	if c.ConfigMap != "" {
+		return SaveConfigMap(c.KubeClient, c.ConfigMap, state)
+	}
+	return Save(c.Path, state)
+}
+
+// Restore loads the snapshot of conf and restores it to cluster router.
+// It returns false if there is no snapshot or the snapshot is not restorable.
+func Restore(conf Config) (bool, error) {
+	conf.setDefault()
+	state, err := conf.load()
+	if os.IsNotExist(err) {
+		klog.Infof("no snapshot at %s to restore", conf.location())
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if state.ClusterName != conf.ClusterName {
+		return false, fmt.Errorf("snapshot is of cluster %s, not %s", state.ClusterName, conf.ClusterName)
+	}
+	age := time.Since(time.Unix(state.Time, 0))
+	if age > conf.MaxAge {
+		klog.Infof("snapshot at %s is %v old, older than %v, ignore it", conf.location(), age, conf.MaxAge)
+		return false, nil
+	}
+
+	clusterrouter.Router().Restore(state.Router)
+	time.AfterFunc(conf.RouteGracePeriod, clusterrouter.Router().ExpireRestoredRoutes)
+	klog.Infof("restored snapshot at %s of %v ago", conf.location(), age)
+	return true, nil
+}
+
+// Run saves snapshot every interval until stop is closed, and saves it again on stop.
+func Run(conf Config, stop <-chan struct{}) {
+	conf.setDefault()
+	ticker := time.NewTicker(conf.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			save(conf)
+			return
+		case <-ticker.C:
+			save(conf)
+		}
+	}
+}
+
+func save(conf Config) {
+	if err := conf.save(Current(conf.ClusterName)); err != nil {
+		klog.Errorf("save snapshot failed: %v", err)
+	}
+}
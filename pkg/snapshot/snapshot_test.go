@@ -0,0 +1,132 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/clusterrouter"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cc.snapshot")
+
+	_, err = Load(path)
+	assert.True(t, os.IsNotExist(err))
+
+	state := &State{
+		Version:     "1.0",
+		ClusterName: "c1",
+		Time:        100,
+		Router: &clusterrouter.Snapshot{
+			ParentNeighbor: map[string]string{"p1": "192.168.0.4:8287"},
+		},
+	}
+	assert.Nil(t, Save(path, state))
+	loaded, err := Load(path)
+	assert.Nil(t, err)
+	assert.Equal(t, state, loaded)
+
+	// no temp file left
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, files, 1)
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte("{"), 0644))
+	_, err = Load(path)
+	assert.NotNil(t, err)
+
+	assert.NotNil(t, Save(filepath.Join(dir, "notexist", "cc.snapshot"), state))
+}
+
+func TestRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	conf := Config{
+		Path:        filepath.Join(dir, "cc.snapshot"),
+		ClusterName: "c1",
+	}
+
+	// no snapshot
+	ok, err := Restore(conf)
+	assert.False(t, ok)
+	assert.Nil(t, err)
+
+	newState := func(clusterName string, age time.Duration) *State {
+		return &State{
+			ClusterName: clusterName,
+			Time:        time.Now().Add(-age).Unix(),
+			Router: &clusterrouter.Snapshot{
+				ParentNeighbor: map[string]string{"p1": "192.168.0.4:8287"},
+			},
+		}
+	}
+
+	// snapshot of other cluster
+	assert.Nil(t, Save(conf.Path, newState("c2", 0)))
+	ok, err = Restore(conf)
+	assert.False(t, ok)
+	assert.NotNil(t, err)
+
+	// too old
+	assert.Nil(t, Save(conf.Path, newState("c1", time.Hour)))
+	ok, err = Restore(conf)
+	assert.False(t, ok)
+	assert.Nil(t, err)
+
+	assert.Nil(t, Save(conf.Path, newState("c1", time.Second)))
+	ok, err = Restore(conf)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	assert.Equal(t, "192.168.0.4:8287", clusterrouter.Router().ParentNeighbors()["p1"])
+}
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	conf := Config{
+		Path:        filepath.Join(dir, "cc.snapshot"),
+		ClusterName: "c1",
+		Interval:    10 * time.Millisecond,
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		Run(conf, stop)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(stop)
+	<-done
+
+	state, err := Load(conf.Path)
+	assert.Nil(t, err)
+	assert.Equal(t, "c1", state.ClusterName)
+	assert.NotNil(t, state.Router)
+}
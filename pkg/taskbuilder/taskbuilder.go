@@ -0,0 +1,372 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package taskbuilder builds ClusterControllers of typed tasks, e.g., apply a manifest,
+// delete an object or run a job, so that callers do not encode destinations, methods,
+// uris and bodies of tasks by hand, and tasks are validated when built.
+package taskbuilder
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clusterselector"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+)
+
+// DefaultNamespace is the namespace of namespaced objects without namespace.
+const DefaultNamespace = "default"
+
+// Owner is the owner objects applied by typed tasks are labeled with, by handler.OwnerLabel,
+// so that those left in clusters are told from objects created by users of clusters.
+const Owner = "clustercontroller"
+
+// clusterScopedKinds are kinds of objects not in namespaces, other kinds are taken as namespaced.
+var clusterScopedKinds = map[string]bool{
+	"APIService":                     true,
+	"CSIDriver":                      true,
+	"CSINode":                        true,
+	"ClusterRole":                    true,
+	"ClusterRoleBinding":             true,
+	"CustomResourceDefinition":       true,
+	"MutatingWebhookConfiguration":   true,
+	"Namespace":                      true,
+	"Node":                           true,
+	"PersistentVolume":               true,
+	"PodSecurityPolicy":              true,
+	"PriorityClass":                  true,
+	"RuntimeClass":                   true,
+	"StorageClass":                   true,
+	"ValidatingWebhookConfiguration": true,
+	"VolumeAttachment":               true,
+}
+
+// Builder builds a ClusterController of a typed task.
+type Builder struct {
+	name          string
+	selector      string
+	task          *otev1.ClusterControllerTask
+	waveSize      int
+	failurePolicy string
+	maxFailures   int
+	rollback      *otev1.ClusterControllerTask
+	err           error
+}
+
+// New returns a builder of ClusterController name.
+func New(name string) *Builder {
+	return &Builder{name: name}
+}
+
+// Selector sets the clusters to send the task to, in rules of cluster selector.
+func (b *Builder) Selector(selector string) *Builder {
+	b.selector = selector
+	return b
+}
+
+// Clusters sets the clusters to send the task to by their names.
+func (b *Builder) Clusters(names ...string) *Builder {
+	rules := make([]string, len(names))
+	for i, name := range names {
+		rules[i] = "^" + regexp.QuoteMeta(name) + "$"
+	}
+	b.selector = clusterselector.ClustersToSelector(&rules)
+	return b
+}
+
+// Apply sets the task to create or update the object of manifest in json or yaml.
+func (b *Builder) Apply(manifest []byte) *Builder {
+	return b.setTask(&otev1.ClusterControllerTask{
+		Type:     otev1.ClusterControllerTaskApply,
+		Manifest: string(manifest),
+	})
+}
+
+// ApplyObject sets the task to create or update obj, e.g., a *corev1.ConfigMap with its TypeMeta.
+func (b *Builder) ApplyObject(obj interface{}) *Builder {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		b.err = fmt.Errorf("marshal object failed: %v", err)
+		return b
+	}
+	return b.Apply(data)
+}
+
+// Delete sets the task to delete the object of ref.
+func (b *Builder) Delete(ref otev1.ObjectReference) *Builder {
+	return b.setTask(&otev1.ClusterControllerTask{
+		Type:   otev1.ClusterControllerTaskDelete,
+		Object: &ref,
+	})
+}
+
+// RunJob sets the task to run job and collect its results.
+func (b *Builder) RunJob(job *batchv1.Job) *Builder {
+	data, err := json.Marshal(job)
+	if err != nil {
+		b.err = fmt.Errorf("marshal job failed: %v", err)
+		return b
+	}
+	return b.setTask(&otev1.ClusterControllerTask{
+		Type:     otev1.ClusterControllerTaskJob,
+		Manifest: string(data),
+	})
+}
+
+func (b *Builder) setTask(task *otev1.ClusterControllerTask) *Builder {
+	if b.task != nil && b.err == nil {
+		b.err = fmt.Errorf("task %s is already set", b.task.Type)
+	}
+	b.task = task
+	return b
+}
+
+// Waves dispatches the task to clusters in waves of size.
+func (b *Builder) Waves(size int) *Builder {
+	b.waveSize = size
+	return b
+}
+
+// OnFailure sets the failure policy applied when more than maxFailures clusters failed,
+// rollback is the task sent to clusters succeeded by policy Rollback.
+func (b *Builder) OnFailure(policy string, maxFailures int, rollback *otev1.ClusterControllerTask) *Builder {
+	b.failurePolicy = policy
+	b.maxFailures = maxFailures
+	b.rollback = rollback
+	return b
+}
+
+// Build validates the task and returns the ClusterController, with the spec expanded from the task.
+func (b *Builder) Build() (*otev1.ClusterController, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if errs := validation.IsDNS1123Subdomain(b.name); len(errs) != 0 {
+		return nil, fmt.Errorf("invalid name %q: %s", b.name, strings.Join(errs, ", "))
+	}
+	if b.selector == "" {
+		return nil, fmt.Errorf("cluster selector is required")
+	}
+	for _, rule := range strings.Split(b.selector, clusterselector.SelectorPatternDelimiter) {
+		if _, err := regexp.Compile(strings.TrimSpace(rule)); err != nil {
+			return nil, fmt.Errorf("invalid cluster selector %q: %v", rule, err)
+		}
+	}
+	if b.task == nil {
+		return nil, fmt.Errorf("task is required")
+	}
+	cc := &otev1.ClusterController{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: otev1.SchemeGroupVersion.String(),
+			Kind:       "ClusterController",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      b.name,
+			Namespace: otev1.ClusterNamespace,
+		},
+		Spec: otev1.ClusterControllerSpec{
+			ClusterSelector: b.selector,
+			Task:            b.task,
+			WaveSize:        b.waveSize,
+			FailurePolicy:   b.failurePolicy,
+			MaxFailures:     b.maxFailures,
+			Rollback:        b.rollback,
+		},
+	}
+	if err := ValidateFailurePolicy(&cc.Spec); err != nil {
+		return nil, err
+	}
+	if err := Expand(&cc.Spec); err != nil {
+		return nil, err
+	}
+	return cc, nil
+}
+
+// ValidateFailurePolicy validates waves and failure policy of spec, and the rollback task if set.
+func ValidateFailurePolicy(spec *otev1.ClusterControllerSpec) error {
+	if spec.WaveSize < 0 {
+		return fmt.Errorf("wave size cannot be negative")
+	}
+	if spec.MaxFailures < 0 {
+		return fmt.Errorf("max failures cannot be negative")
+	}
+	switch spec.FailurePolicy {
+	case "", otev1.ClusterControllerFailureContinue, otev1.ClusterControllerFailureFailFast:
+	case otev1.ClusterControllerFailureRollback:
+		if spec.Rollback == nil {
+			return fmt.Errorf("rollback task is required by failure policy %s", spec.FailurePolicy)
+		}
+	default:
+		return fmt.Errorf("failure policy %q is not supported", spec.FailurePolicy)
+	}
+	if spec.Rollback != nil {
+		if err := Expand(&otev1.ClusterControllerSpec{Task: spec.Rollback}); err != nil {
+			return fmt.Errorf("invalid rollback task: %v", err)
+		}
+	}
+	return nil
+}
+
+// Expand validates the typed task of spec, and sets Destination, Method, URL and Body from it.
+// spec is not changed if it has no typed task.
+func Expand(spec *otev1.ClusterControllerSpec) error {
+	task := spec.Task
+	if task == nil {
+		return nil
+	}
+	switch task.Type {
+	case otev1.ClusterControllerTaskApply:
+		obj, uri, err := DecodeManifest(task.Manifest)
+		if err != nil {
+			return err
+		}
+		labelOwner(obj)
+		body, err := json.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		spec.Destination = otev1.ClusterControllerDestApply
+		spec.Method = http.MethodPost
+		spec.URL = uri
+		spec.Body = string(body)
+	case otev1.ClusterControllerTaskDelete:
+		if task.Object == nil {
+			return fmt.Errorf("object to delete is required")
+		}
+		ref := task.Object
+		if ref.APIVersion == "" || ref.Kind == "" || ref.Name == "" {
+			return fmt.Errorf("apiVersion, kind and name of object to delete are required")
+		}
+		uri, err := collectionURI(ref.APIVersion, ref.Kind, ref.Namespace)
+		if err != nil {
+			return err
+		}
+		spec.Destination = otev1.ClusterControllerDestAPI
+		spec.Method = http.MethodDelete
+		spec.URL = uri + "/" + ref.Name
+		spec.Body = ""
+	case otev1.ClusterControllerTaskJob:
+		job := &batchv1.Job{}
+		if err := yaml.Unmarshal([]byte(task.Manifest), job); err != nil {
+			return fmt.Errorf("decode job failed: %v", err)
+		}
+		if job.Kind != "" && job.Kind != "Job" {
+			return fmt.Errorf("kind %s is not Job", job.Kind)
+		}
+		if job.Name == "" {
+			return fmt.Errorf("name of job is required")
+		}
+		if job.Namespace == "" {
+			job.Namespace = DefaultNamespace
+		}
+		body, err := json.Marshal(job)
+		if err != nil {
+			return err
+		}
+		spec.Destination = otev1.ClusterControllerDestJob
+		spec.Method = http.MethodPost
+		spec.URL = ""
+		spec.Body = string(body)
+	default:
+		return fmt.Errorf("task type %q is not supported", task.Type)
+	}
+	return nil
+}
+
+// DecodeManifest decodes an object in json or yaml, and returns it with the uri to create it.
+// Namespaced objects without namespace are in DefaultNamespace.
+func DecodeManifest(manifest string) (map[string]interface{}, string, error) {
+	obj := make(map[string]interface{})
+	if err := yaml.Unmarshal([]byte(manifest), &obj); err != nil {
+		return nil, "", fmt.Errorf("decode manifest failed: %v", err)
+	}
+	apiVersion, _ := obj["apiVersion"].(string)
+	kind, _ := obj["kind"].(string)
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	if apiVersion == "" || kind == "" || name == "" {
+		return nil, "", fmt.Errorf("apiVersion, kind and name of manifest are required")
+	}
+	namespace, _ := metadata["namespace"].(string)
+	if clusterScopedKinds[kind] {
+		if namespace != "" {
+			return nil, "", fmt.Errorf("%s %s is not namespaced", kind, name)
+		}
+	} else if namespace == "" {
+		namespace = DefaultNamespace
+		metadata["namespace"] = namespace
+	}
+	uri, err := collectionURI(apiVersion, kind, namespace)
+	if err != nil {
+		return nil, "", err
+	}
+	return obj, uri, nil
+}
+
+// labelOwner labels obj applied by typed tasks with Owner.
+func labelOwner(obj map[string]interface{}) {
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	labels, ok := metadata["labels"].(map[string]interface{})
+	if !ok {
+		labels = make(map[string]interface{})
+		metadata["labels"] = labels
+	}
+	labels[handler.OwnerLabel] = Owner
+}
+
+// collectionURI returns the uri of objects of kind in namespace.
+func collectionURI(apiVersion, kind, namespace string) (string, error) {
+	prefix := "/apis/" + apiVersion
+	if !strings.Contains(apiVersion, "/") {
+		if apiVersion != "v1" {
+			return "", fmt.Errorf("invalid apiVersion %s", apiVersion)
+		}
+		prefix = "/api/v1"
+	}
+	resource := pluralize(kind)
+	if clusterScopedKinds[kind] {
+		return prefix + "/" + resource, nil
+	}
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	return prefix + "/namespaces/" + namespace + "/" + resource, nil
+}
+
+// pluralize returns the resource name of kind, e.g., ingresses of Ingress.
+func pluralize(kind string) string {
+	resource := strings.ToLower(kind)
+	switch {
+	case resource == "endpoints":
+		return resource
+	case strings.HasSuffix(resource, "s"), strings.HasSuffix(resource, "x"),
+		strings.HasSuffix(resource, "ch"), strings.HasSuffix(resource, "sh"):
+		return resource + "es"
+	case strings.HasSuffix(resource, "y") && len(resource) > 1 &&
+		!strings.ContainsAny(resource[len(resource)-2:len(resource)-1], "aeiou"):
+		return resource[:len(resource)-1] + "ies"
+	}
+	return resource + "s"
+}
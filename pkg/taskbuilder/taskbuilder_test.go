@@ -0,0 +1,185 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskbuilder
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustershim/handler"
+)
+
+func TestApply(t *testing.T) {
+	cc, err := New("apply-cm").Clusters("c1", "c.2").Apply([]byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm1
+data:
+  a: b
+`)).Build()
+	assert.Nil(t, err)
+	assert.Equal(t, "apply-cm", cc.Name)
+	assert.Equal(t, otev1.ClusterNamespace, cc.Namespace)
+	assert.Equal(t, "^c1$,^c\\.2$", cc.Spec.ClusterSelector)
+	assert.Equal(t, otev1.ClusterControllerDestApply, cc.Spec.Destination)
+	assert.Equal(t, http.MethodPost, cc.Spec.Method)
+	assert.Equal(t, "/api/v1/namespaces/default/configmaps", cc.Spec.URL)
+	cm := &corev1.ConfigMap{}
+	assert.Nil(t, json.Unmarshal([]byte(cc.Spec.Body), cm))
+	assert.Equal(t, "default", cm.Namespace)
+	assert.Equal(t, "b", cm.Data["a"])
+	assert.Equal(t, Owner, cm.Labels[handler.OwnerLabel])
+	assert.Equal(t, otev1.ClusterControllerTaskApply, cc.Spec.Task.Type)
+
+	cc, err = New("apply-deploy").Selector("^beijing-").ApplyObject(map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "d1", "namespace": "ns1"},
+	}).Build()
+	assert.Nil(t, err)
+	assert.Equal(t, "/apis/apps/v1/namespaces/ns1/deployments", cc.Spec.URL)
+
+	cc, err = New("apply-role").Selector("c1").ApplyObject(map[string]interface{}{
+		"apiVersion": "rbac.authorization.k8s.io/v1",
+		"kind":       "ClusterRole",
+		"metadata":   map[string]interface{}{"name": "r1"},
+	}).Build()
+	assert.Nil(t, err)
+	assert.Equal(t, "/apis/rbac.authorization.k8s.io/v1/clusterroles", cc.Spec.URL)
+
+	for _, manifest := range []string{
+		"{",
+		`{"kind":"ConfigMap","metadata":{"name":"a"}}`,
+		`{"apiVersion":"v1","kind":"ConfigMap","metadata":{}}`,
+		`{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"a","namespace":"b"}}`,
+		`{"apiVersion":"v2","kind":"ConfigMap","metadata":{"name":"a"}}`,
+	} {
+		_, err := New("invalid").Selector("c1").Apply([]byte(manifest)).Build()
+		assert.NotNil(t, err, manifest)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	cc, err := New("delete-ingress").Selector("c1").Delete(otev1.ObjectReference{
+		APIVersion: "extensions/v1beta1",
+		Kind:       "Ingress",
+		Namespace:  "ns1",
+		Name:       "i1",
+	}).Build()
+	assert.Nil(t, err)
+	assert.Equal(t, otev1.ClusterControllerDestAPI, cc.Spec.Destination)
+	assert.Equal(t, http.MethodDelete, cc.Spec.Method)
+	assert.Equal(t, "/apis/extensions/v1beta1/namespaces/ns1/ingresses/i1", cc.Spec.URL)
+
+	_, err = New("delete").Selector("c1").Delete(otev1.ObjectReference{Kind: "Pod", Name: "p1"}).Build()
+	assert.NotNil(t, err)
+}
+
+func TestRunJob(t *testing.T) {
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "j1"}}
+	cc, err := New("run-job").Selector("c1").RunJob(job).Build()
+	assert.Nil(t, err)
+	assert.Equal(t, otev1.ClusterControllerDestJob, cc.Spec.Destination)
+	assert.Equal(t, http.MethodPost, cc.Spec.Method)
+	built := &batchv1.Job{}
+	assert.Nil(t, json.Unmarshal([]byte(cc.Spec.Body), built))
+	assert.Equal(t, "j1", built.Name)
+	assert.Equal(t, DefaultNamespace, built.Namespace)
+
+	_, err = New("run-job").Selector("c1").RunJob(&batchv1.Job{}).Build()
+	assert.NotNil(t, err)
+}
+
+func TestBuild(t *testing.T) {
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "j1"}}
+	for name, b := range map[string]*Builder{
+		"invalid name":     New("Invalid_Name").Selector("c1").RunJob(job),
+		"no selector":      New("a").RunJob(job),
+		"invalid selector": New("a").Selector("c1,(").RunJob(job),
+		"no task":          New("a").Selector("c1"),
+		"two tasks":        New("a").Selector("c1").RunJob(job).Delete(otev1.ObjectReference{}),
+	} {
+		_, err := b.Build()
+		assert.NotNil(t, err, name)
+	}
+}
+
+func TestFailurePolicy(t *testing.T) {
+	cm := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "cm1"},
+	}
+	rollback := &otev1.ClusterControllerTask{
+		Type:   otev1.ClusterControllerTaskDelete,
+		Object: &otev1.ObjectReference{APIVersion: "v1", Kind: "ConfigMap", Name: "cm1"},
+	}
+	cc, err := New("apply-cm").Selector("c1").ApplyObject(cm).Waves(10).
+		OnFailure(otev1.ClusterControllerFailureRollback, 2, rollback).Build()
+	assert.Nil(t, err)
+	assert.Equal(t, 10, cc.Spec.WaveSize)
+	assert.Equal(t, otev1.ClusterControllerFailureRollback, cc.Spec.FailurePolicy)
+	assert.Equal(t, 2, cc.Spec.MaxFailures)
+	assert.Equal(t, rollback, cc.Spec.Rollback)
+
+	for name, b := range map[string]*Builder{
+		"negative wave size": New("a").Selector("c1").ApplyObject(cm).Waves(-1),
+		"negative failures":  New("a").Selector("c1").ApplyObject(cm).OnFailure("", -1, nil),
+		"unknown policy":     New("a").Selector("c1").ApplyObject(cm).OnFailure("Retry", 0, nil),
+		"no rollback task":   New("a").Selector("c1").ApplyObject(cm).OnFailure(otev1.ClusterControllerFailureRollback, 0, nil),
+		"invalid rollback task": New("a").Selector("c1").ApplyObject(cm).OnFailure(otev1.ClusterControllerFailureRollback, 0,
+			&otev1.ClusterControllerTask{Type: otev1.ClusterControllerTaskDelete}),
+	} {
+		_, err := b.Build()
+		assert.NotNil(t, err, name)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	// spec without typed task is not changed.
+	spec := &otev1.ClusterControllerSpec{Destination: "api", Method: "GET", URL: "/api"}
+	assert.Nil(t, Expand(spec))
+	assert.Equal(t, "/api", spec.URL)
+
+	spec.Task = &otev1.ClusterControllerTask{Type: "unknown"}
+	assert.NotNil(t, Expand(spec))
+	spec.Task = &otev1.ClusterControllerTask{Type: otev1.ClusterControllerTaskDelete}
+	assert.NotNil(t, Expand(spec))
+	spec.Task = &otev1.ClusterControllerTask{Type: otev1.ClusterControllerTaskJob, Manifest: `{"kind":"Pod"}`}
+	assert.NotNil(t, Expand(spec))
+}
+
+func TestPluralize(t *testing.T) {
+	for kind, resource := range map[string]string{
+		"Pod":           "pods",
+		"Ingress":       "ingresses",
+		"NetworkPolicy": "networkpolicies",
+		"Gateway":       "gateways",
+		"Endpoints":     "endpoints",
+		"StorageClass":  "storageclasses",
+	} {
+		assert.Equal(t, resource, pluralize(kind), kind)
+	}
+}
@@ -0,0 +1,455 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
+
+	"github.com/baidu/ote-stack/pkg/oidc"
+)
+
+// Names of built-in auth providers of tunnel admission.
+const (
+	AuthProviderToken   = "token"
+	AuthProviderCert    = "cert"
+	AuthProviderOIDC    = "oidc"
+	AuthProviderWebhook = "webhook"
+
+	// DefaultOIDCClaim is the claim of id tokens that must be the name of the cluster.
+	DefaultOIDCClaim = "sub"
+)
+
+// webhookTimeout is the time of a request to the auth webhook.
+var webhookTimeout = 10 * time.Second
+
+// AuthRequest is a child connecting to a cloud tunnel to authenticate.
+type AuthRequest struct {
+	// Cluster is the name the child connects as.
+	Cluster    string
+	RemoteAddr string
+	// Header is the header of the websocket handshake, or the metadata of the grpc stream.
+	Header http.Header
+	// TLS is the state of the tls connection, nil if in plain text.
+	TLS *tls.ConnectionState
+}
+
+// Token returns the bearer token in header Authorization of the child, empty if none.
+func (r *AuthRequest) Token() string {
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(authorization, "Bearer "))
+}
+
+// AuthProvider authenticates childs connecting to cloud tunnels.
+type AuthProvider interface {
+	// Authenticate returns true if the child of req is authenticated as req.Cluster.
+	// It returns false without error if req has no credentials of the provider, e.g., no bearer token,
+	// or an error if the credentials are invalid or not of the cluster.
+	Authenticate(req *AuthRequest) (bool, error)
+}
+
+// AuthProviderFunc is a function authenticating childs as an AuthProvider.
+type AuthProviderFunc func(req *AuthRequest) (bool, error)
+
+func (f AuthProviderFunc) Authenticate(req *AuthRequest) (bool, error) {
+	return f(req)
+}
+
+// authChain tries providers in order.
+type authChain struct {
+	providers []AuthProvider
+}
+
+/*
+NewAuthChain returns a provider trying providers in order, which authenticates a child once one of them does.
+Errors of providers do not stop the chain, since credentials invalid to one may be valid to another,
+e.g., a bearer token unknown to the static tokens is an id token of oidc.
+The child is refused with the errors if none of them authenticates it.
+*/
+func NewAuthChain(providers ...AuthProvider) AuthProvider {
+	return &authChain{providers: providers}
+}
+
+func (c *authChain) Authenticate(req *AuthRequest) (bool, error) {
+	var errs []string
+	for _, p := range c.providers {
+		ok, err := p.Authenticate(req)
+		if ok {
+			return true, nil
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) != 0 {
+		return false, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return false, nil
+}
+
+// certProvider authenticates childs by client certificates.
+type certProvider struct{}
+
+/*
+NewCertAuthProvider returns a provider authenticating childs presenting a client certificate of mutual tls,
+which is verified by the tunnel ca, and whose name is the cluster.
+Childs in plain text, or without a certificate, have no credentials of it.
+*/
+func NewCertAuthProvider() AuthProvider {
+	return certProvider{}
+}
+
+func (certProvider) Authenticate(req *AuthRequest) (bool, error) {
+	if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+		return false, nil
+	}
+	if err := verifyPeerName(req.TLS, req.Cluster); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ClusterToken is a bearer token of childs.
+type ClusterToken struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	// Clusters are the names of clusters allowed to connect by the token, in shell patterns
+	// like edge-*, all if empty.
+	Clusters []string `json:"clusters,omitempty"`
+}
+
+// tokenProvider authenticates childs by static bearer tokens.
+type tokenProvider struct {
+	tokens []ClusterToken
+}
+
+// NewTokenAuthProvider returns a provider authenticating childs by bearer tokens in file,
+// in yaml or json like {"tokens": [{"name": "edge", "token": "...", "clusters": ["edge-*"]}]}.
+func NewTokenAuthProvider(file string) (AuthProvider, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read tunnel auth tokens failed: %v", err)
+	}
+	conf := struct {
+		Tokens []ClusterToken `json:"tokens"`
+	}{}
+	if err := yaml.Unmarshal(data, &conf); err != nil {
+		return nil, fmt.Errorf("unmarshal tunnel auth tokens failed: %v", err)
+	}
+	for _, t := range conf.Tokens {
+		if t.Name == "" || t.Token == "" {
+			return nil, fmt.Errorf("tunnel auth token has no name or token")
+		}
+		for _, pattern := range t.Clusters {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("clusters %s of tunnel auth token %s is invalid: %v", pattern, t.Name, err)
+			}
+		}
+	}
+	return &tokenProvider{tokens: conf.Tokens}, nil
+}
+
+func (p *tokenProvider) Authenticate(req *AuthRequest) (bool, error) {
+	token := req.Token()
+	if token == "" {
+		return false, nil
+	}
+	for _, t := range p.tokens {
+		if subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) != 1 {
+			continue
+		}
+		if !matchCluster(t.Clusters, req.Cluster) {
+			return false, fmt.Errorf("token %s is not allowed for cluster %s", t.Name, req.Cluster)
+		}
+		return true, nil
+	}
+	return false, fmt.Errorf("bearer token is invalid")
+}
+
+func matchCluster(patterns []string, cluster string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, cluster); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenVerifier verifies id tokens, e.g., oidc.Verifier.
+type TokenVerifier interface {
+	Verify(token string) (oidc.Claims, error)
+}
+
+// oidcProvider authenticates childs by id tokens.
+type oidcProvider struct {
+	verifier TokenVerifier
+	claim    string
+}
+
+// NewOIDCAuthProvider returns a provider authenticating childs by bearer id tokens verified by verifier,
+// whose claim, DefaultOIDCClaim if empty, is the name of the cluster.
+func NewOIDCAuthProvider(verifier TokenVerifier, claim string) AuthProvider {
+	if claim == "" {
+		claim = DefaultOIDCClaim
+	}
+	return &oidcProvider{verifier: verifier, claim: claim}
+}
+
+func (p *oidcProvider) Authenticate(req *AuthRequest) (bool, error) {
+	token := req.Token()
+	if token == "" {
+		return false, nil
+	}
+	claims, err := p.verifier.Verify(token)
+	if err != nil {
+		return false, fmt.Errorf("id token is invalid: %v", err)
+	}
+	if name := claims.String(p.claim); name != req.Cluster {
+		return false, fmt.Errorf("id token of %s %s is not of cluster %s", p.claim, name, req.Cluster)
+	}
+	return true, nil
+}
+
+// WebhookAuthRequest is posted in json to the auth webhook for a child connecting.
+type WebhookAuthRequest struct {
+	Cluster    string `json:"cluster"`
+	RemoteAddr string `json:"remoteAddr"`
+	// Token is the bearer token of the child, empty if none.
+	Token string `json:"token,omitempty"`
+	// CommonName and DNSNames are of the client certificate of the child, empty if none.
+	CommonName string   `json:"commonName,omitempty"`
+	DNSNames   []string `json:"dnsNames,omitempty"`
+}
+
+// WebhookAuthResponse is responded by the auth webhook.
+type WebhookAuthResponse struct {
+	Allowed bool `json:"allowed"`
+	// Reason is why the child is refused.
+	Reason string `json:"reason,omitempty"`
+}
+
+// webhookProvider authenticates childs by an external webhook.
+type webhookProvider struct {
+	url    string
+	client *http.Client
+}
+
+/*
+NewWebhookAuthProvider returns a provider authenticating childs by posting WebhookAuthRequest to url,
+which responds WebhookAuthResponse with status code 200, so that an identity system of enterprises
+decides which clusters are admitted. A child is refused if the webhook fails.
+*/
+func NewWebhookAuthProvider(url string) AuthProvider {
+	return &webhookProvider{url: url, client: &http.Client{Timeout: webhookTimeout}}
+}
+
+func (p *webhookProvider) Authenticate(req *AuthRequest) (bool, error) {
+	body := WebhookAuthRequest{
+		Cluster:    req.Cluster,
+		RemoteAddr: req.RemoteAddr,
+		Token:      req.Token(),
+	}
+	if req.TLS != nil && len(req.TLS.PeerCertificates) != 0 {
+		body.CommonName = req.TLS.PeerCertificates[0].Subject.CommonName
+		body.DNSNames = req.TLS.PeerCertificates[0].DNSNames
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return false, err
+	}
+	resp, err := p.client.Post(p.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return false, fmt.Errorf("request auth webhook failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("request auth webhook failed: %s", resp.Status)
+	}
+	result := WebhookAuthResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("decode response of auth webhook failed: %v", err)
+	}
+	if !result.Allowed {
+		return false, fmt.Errorf("refused by auth webhook: %s", result.Reason)
+	}
+	return true, nil
+}
+
+// AuthConfig is the authentication of childs connecting to cloud tunnels,
+// and of edge tunnels connecting to the parent.
+type AuthConfig struct {
+	// Providers are the names of providers authenticating childs in order,
+	// built-in ones or registered by RegisterAuthProvider. Childs are not authenticated if empty.
+	Providers []string
+	// TokenFile is the file of bearer tokens of provider token.
+	TokenFile string
+	// OIDC is the provider of id tokens of provider oidc, whose OIDCClaim is the name of the cluster.
+	OIDC      oidc.Config
+	OIDCClaim string
+	// WebhookURL is the url of provider webhook.
+	WebhookURL string
+	// ClientTokenFile is the file of the bearer token sent to the parent, read at each connection
+	// so that it can be rotated. No token is sent if empty.
+	ClientTokenFile string
+}
+
+var (
+	authLock sync.RWMutex
+	// authProvider authenticates childs, nil if childs are not authenticated.
+	authProvider AuthProvider
+	// clientTokenFile is the file of the bearer token sent to parents.
+	clientTokenFile string
+	// registeredAuthProviders are the providers registered by name.
+	registeredAuthProviders = map[string]AuthProvider{}
+)
+
+// RegisterAuthProvider registers p by name, which is used in providers of AuthConfig,
+// so that programs building cluster controller plug their identity systems into tunnel admission.
+func RegisterAuthProvider(name string, p AuthProvider) {
+	authLock.Lock()
+	defer authLock.Unlock()
+	registeredAuthProviders[name] = p
+}
+
+// SetupAuth authenticates childs connecting to cloud tunnels started after it by the chain
+// of providers of c, and sends the client token to parents.
+func SetupAuth(c AuthConfig) error {
+	var providers []AuthProvider
+	for _, name := range c.Providers {
+		p, err := newAuthProvider(name, c)
+		if err != nil {
+			return err
+		}
+		providers = append(providers, p)
+	}
+	if c.ClientTokenFile != "" {
+		if _, err := readClientToken(c.ClientTokenFile); err != nil {
+			return err
+		}
+	}
+	authLock.Lock()
+	defer authLock.Unlock()
+	authProvider = nil
+	if len(providers) != 0 {
+		authProvider = NewAuthChain(providers...)
+		klog.Infof("authenticate childs by %s", strings.Join(c.Providers, ","))
+	}
+	clientTokenFile = c.ClientTokenFile
+	return nil
+}
+
+// SetAuthProvider authenticates childs by p, or disables authentication if p is nil.
+func SetAuthProvider(p AuthProvider) {
+	authLock.Lock()
+	defer authLock.Unlock()
+	authProvider = p
+}
+
+func newAuthProvider(name string, c AuthConfig) (AuthProvider, error) {
+	switch name {
+	case AuthProviderToken:
+		if c.TokenFile == "" {
+			return nil, fmt.Errorf("token file is required by tunnel auth provider token")
+		}
+		return NewTokenAuthProvider(c.TokenFile)
+	case AuthProviderCert:
+		if getServerTLS() == nil {
+			return nil, fmt.Errorf("tunnel tls is required by tunnel auth provider cert")
+		}
+		return NewCertAuthProvider(), nil
+	case AuthProviderOIDC:
+		verifier, err := oidc.NewVerifier(c.OIDC)
+		if err != nil {
+			return nil, err
+		}
+		return NewOIDCAuthProvider(verifier, c.OIDCClaim), nil
+	case AuthProviderWebhook:
+		if c.WebhookURL == "" {
+			return nil, fmt.Errorf("url is required by tunnel auth provider webhook")
+		}
+		return NewWebhookAuthProvider(c.WebhookURL), nil
+	}
+	authLock.RLock()
+	defer authLock.RUnlock()
+	if p, ok := registeredAuthProviders[name]; ok {
+		return p, nil
+	}
+	return nil, fmt.Errorf("tunnel auth provider %s is unknown", name)
+}
+
+// authenticate returns error if the child of req is not authenticated.
+func authenticate(req *AuthRequest) error {
+	authLock.RLock()
+	p := authProvider
+	authLock.RUnlock()
+	if p == nil {
+		return nil
+	}
+	ok, err := p.Authenticate(req)
+	if ok {
+		return nil
+	}
+	if err == nil {
+		err = fmt.Errorf("credentials are required")
+	}
+	return err
+}
+
+func readClientToken(file string) (string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("read tunnel token failed: %v", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// clientAuthorization returns the header Authorization sent to parents, empty if no token.
+func clientAuthorization() string {
+	authLock.RLock()
+	file := clientTokenFile
+	authLock.RUnlock()
+	if file == "" {
+		return ""
+	}
+	token, err := readClientToken(file)
+	if err != nil {
+		klog.Errorf("%v", err)
+		return ""
+	}
+	if token == "" {
+		return ""
+	}
+	return "Bearer " + token
+}
@@ -0,0 +1,258 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/oidc"
+)
+
+func authRequest(cluster, token string) *AuthRequest {
+	req := &AuthRequest{Cluster: cluster, RemoteAddr: "192.168.0.2:43210", Header: http.Header{}}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func writeTokens(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "tunnel-auth")
+	assert.Nil(t, err)
+	file := filepath.Join(dir, "tokens.yaml")
+	assert.Nil(t, ioutil.WriteFile(file, []byte(content), 0600))
+	return file
+}
+
+func TestAuthChain(t *testing.T) {
+	noOpinion := AuthProviderFunc(func(*AuthRequest) (bool, error) { return false, nil })
+	refuse := AuthProviderFunc(func(*AuthRequest) (bool, error) { return false, fmt.Errorf("refused") })
+	admit := AuthProviderFunc(func(*AuthRequest) (bool, error) { return true, nil })
+
+	ok, err := NewAuthChain().Authenticate(authRequest("c1", ""))
+	assert.False(t, ok)
+	assert.Nil(t, err)
+
+	ok, err = NewAuthChain(noOpinion, refuse, admit).Authenticate(authRequest("c1", ""))
+	assert.True(t, ok)
+	assert.Nil(t, err)
+
+	ok, err = NewAuthChain(refuse, noOpinion, refuse).Authenticate(authRequest("c1", ""))
+	assert.False(t, ok)
+	assert.Equal(t, "refused; refused", err.Error())
+}
+
+func TestTokenAuthProvider(t *testing.T) {
+	file := writeTokens(t, `
+tokens:
+- name: edge
+  token: edge-token
+  clusters: ["edge-*"]
+- name: all
+  token: all-token
+`)
+	defer os.RemoveAll(filepath.Dir(file))
+	p, err := NewTokenAuthProvider(file)
+	assert.Nil(t, err)
+
+	ok, err := p.Authenticate(authRequest("edge-1", ""))
+	assert.False(t, ok)
+	assert.Nil(t, err)
+	ok, err = p.Authenticate(authRequest("edge-1", "edge-token"))
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	ok, err = p.Authenticate(authRequest("c1", "edge-token"))
+	assert.False(t, ok)
+	assert.NotNil(t, err)
+	ok, err = p.Authenticate(authRequest("c1", "all-token"))
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	ok, err = p.Authenticate(authRequest("c1", "unknown"))
+	assert.False(t, ok)
+	assert.NotNil(t, err)
+
+	for _, content := range []string{"tokens: [{name: edge}]", "tokens: [{name: edge, token: t, clusters: ['[']}]"} {
+		invalid := writeTokens(t, content)
+		_, err = NewTokenAuthProvider(invalid)
+		assert.NotNil(t, err, content)
+		os.RemoveAll(filepath.Dir(invalid))
+	}
+	_, err = NewTokenAuthProvider("/not/exist")
+	assert.NotNil(t, err)
+}
+
+func TestCertAuthProvider(t *testing.T) {
+	p := NewCertAuthProvider()
+	ok, err := p.Authenticate(authRequest("c1", ""))
+	assert.False(t, ok)
+	assert.Nil(t, err)
+
+	req := authRequest("c1", "")
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "c1"}}}}
+	ok, err = p.Authenticate(req)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+
+	req.Cluster = "c2"
+	ok, err = p.Authenticate(req)
+	assert.False(t, ok)
+	assert.NotNil(t, err)
+}
+
+type fakeTokenVerifier map[string]oidc.Claims
+
+func (f fakeTokenVerifier) Verify(token string) (oidc.Claims, error) {
+	claims, ok := f[token]
+	if !ok {
+		return nil, fmt.Errorf("token is not a jwt")
+	}
+	return claims, nil
+}
+
+func TestOIDCAuthProvider(t *testing.T) {
+	verifier := fakeTokenVerifier{
+		"id-c1": {"sub": "c1", "cluster": "edge-1"},
+	}
+	p := NewOIDCAuthProvider(verifier, "")
+	ok, err := p.Authenticate(authRequest("c1", ""))
+	assert.False(t, ok)
+	assert.Nil(t, err)
+	ok, err = p.Authenticate(authRequest("c1", "id-c1"))
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	ok, err = p.Authenticate(authRequest("c2", "id-c1"))
+	assert.False(t, ok)
+	assert.NotNil(t, err)
+	ok, err = p.Authenticate(authRequest("c1", "static"))
+	assert.False(t, ok)
+	assert.NotNil(t, err)
+
+	p = NewOIDCAuthProvider(verifier, "cluster")
+	ok, err = p.Authenticate(authRequest("edge-1", "id-c1"))
+	assert.True(t, ok)
+	assert.Nil(t, err)
+}
+
+func TestWebhookAuthProvider(t *testing.T) {
+	var received WebhookAuthRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = WebhookAuthRequest{}
+		json.NewDecoder(r.Body).Decode(&received)
+		switch received.Cluster {
+		case "c1":
+			json.NewEncoder(w).Encode(WebhookAuthResponse{Allowed: true})
+		case "c2":
+			json.NewEncoder(w).Encode(WebhookAuthResponse{Reason: "unknown cluster"})
+		default:
+			http.Error(w, "failed", http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	p := NewWebhookAuthProvider(server.URL)
+
+	req := authRequest("c1", "t1")
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{
+		Subject:  pkix.Name{CommonName: "c1"},
+		DNSNames: []string{"c1.edge"},
+	}}}
+	ok, err := p.Authenticate(req)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	assert.Equal(t, WebhookAuthRequest{
+		Cluster:    "c1",
+		RemoteAddr: "192.168.0.2:43210",
+		Token:      "t1",
+		CommonName: "c1",
+		DNSNames:   []string{"c1.edge"},
+	}, received)
+
+	ok, err = p.Authenticate(authRequest("c2", ""))
+	assert.False(t, ok)
+	assert.Contains(t, err.Error(), "unknown cluster")
+	ok, err = p.Authenticate(authRequest("c3", ""))
+	assert.False(t, ok)
+	assert.NotNil(t, err)
+}
+
+func TestSetupAuth(t *testing.T) {
+	defer SetupAuth(AuthConfig{})
+
+	assert.NotNil(t, SetupAuth(AuthConfig{Providers: []string{"unknown"}}))
+	assert.NotNil(t, SetupAuth(AuthConfig{Providers: []string{AuthProviderToken}}))
+	assert.NotNil(t, SetupAuth(AuthConfig{Providers: []string{AuthProviderCert}}))
+	assert.NotNil(t, SetupAuth(AuthConfig{Providers: []string{AuthProviderOIDC}}))
+	assert.NotNil(t, SetupAuth(AuthConfig{Providers: []string{AuthProviderWebhook}}))
+	assert.NotNil(t, SetupAuth(AuthConfig{ClientTokenFile: "/not/exist"}))
+
+	file := writeTokens(t, "tokens: [{name: edge, token: edge-token}]")
+	defer os.RemoveAll(filepath.Dir(file))
+	RegisterAuthProvider("ldap", AuthProviderFunc(func(req *AuthRequest) (bool, error) {
+		return req.Cluster == "ldap-user", nil
+	}))
+	assert.Nil(t, SetupAuth(AuthConfig{
+		Providers: []string{AuthProviderToken, "ldap"},
+		TokenFile: file,
+	}))
+	assert.Nil(t, authenticate(authRequest("c1", "edge-token")))
+	assert.Nil(t, authenticate(authRequest("ldap-user", "")))
+	assert.NotNil(t, authenticate(authRequest("c1", "")))
+	assert.NotNil(t, authenticate(authRequest("c1", "other-token")))
+
+	// childs are refused before admitted.
+	ct := cloudTunnel{
+		redirect: func() string { return "" },
+		assign:   func(string) string { return "" },
+	}
+	w := httptest.NewRecorder()
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "http://origin/access/c1", nil),
+		map[string]string{accessURIParam: "c1"})
+	ct.accessHandler(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// childs authenticated go on to be admitted, and fail of no listen address.
+	w = httptest.NewRecorder()
+	req.Header.Set("Authorization", "Bearer edge-token")
+	ct.accessHandler(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestClientAuthorization(t *testing.T) {
+	defer SetupAuth(AuthConfig{})
+	assert.Equal(t, "", clientAuthorization())
+
+	file := writeTokens(t, "token-1\n")
+	defer os.RemoveAll(filepath.Dir(file))
+	assert.Nil(t, SetupAuth(AuthConfig{ClientTokenFile: file}))
+	assert.Equal(t, "Bearer token-1", clientAuthorization())
+
+	// the token rotated is read at the next connection.
+	assert.Nil(t, ioutil.WriteFile(file, []byte("token-2"), 0600))
+	assert.Equal(t, "Bearer token-2", clientAuthorization())
+}
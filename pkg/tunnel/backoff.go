@@ -0,0 +1,132 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ReconnectPolicy defines the backoff of edge tunnel reconnecting to parent.
+type ReconnectPolicy struct {
+	// InitialInterval is the time to wait before the first retry.
+	InitialInterval time.Duration
+	// MaxInterval is the max time to wait between retries.
+	MaxInterval time.Duration
+	// Multiplier is the factor the interval grows by after each retry.
+	Multiplier float64
+	// Jitter is the fraction(0-1) of the interval randomized, so that edges disconnected
+	// at the same time, e.g. by a restart of parent, do not reconnect at the same time.
+	Jitter float64
+	// MaxRetries is the number of retries after which MaxRetriesHook is called,
+	// and edge tunnel goes back to the parent configured, 0 means no limit.
+	MaxRetries int
+}
+
+// DefaultReconnectPolicy is the reconnect policy of edge tunnels by default.
+var DefaultReconnectPolicy = ReconnectPolicy{
+	InitialInterval: time.Second,
+	MaxInterval:     time.Minute,
+	Multiplier:      2,
+	Jitter:          0.2,
+}
+
+var (
+	reconnectPolicyLock sync.RWMutex
+	reconnectPolicy     = DefaultReconnectPolicy
+)
+
+func (p *ReconnectPolicy) valid() error {
+	if p.InitialInterval <= 0 {
+		return fmt.Errorf("reconnect initial interval must be positive")
+	}
+	if p.MaxInterval < p.InitialInterval {
+		return fmt.Errorf("reconnect max interval cannot be less than initial interval")
+	}
+	if p.Multiplier < 1 {
+		return fmt.Errorf("reconnect multiplier cannot be less than 1")
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return fmt.Errorf("reconnect jitter %v is not in [0, 1]", p.Jitter)
+	}
+	if p.MaxRetries < 0 {
+		return fmt.Errorf("reconnect max retries cannot be negative")
+	}
+	return nil
+}
+
+// SetReconnectPolicy sets the reconnect policy of edge tunnels.
+func SetReconnectPolicy(p ReconnectPolicy) error {
+	if err := p.valid(); err != nil {
+		return err
+	}
+	reconnectPolicyLock.Lock()
+	defer reconnectPolicyLock.Unlock()
+	reconnectPolicy = p
+	return nil
+}
+
+func getReconnectPolicy() ReconnectPolicy {
+	reconnectPolicyLock.RLock()
+	defer reconnectPolicyLock.RUnlock()
+	return reconnectPolicy
+}
+
+// backoff is the exponential backoff with jitter of retries by a reconnect policy.
+type backoff struct {
+	policy   ReconnectPolicy
+	interval time.Duration
+	retries  int
+	rand     *rand.Rand
+}
+
+func newBackoff(policy ReconnectPolicy) *backoff {
+	b := &backoff{
+		policy: policy,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	b.reset()
+	return b
+}
+
+// next returns the time to wait before the next retry, and grows the interval.
+func (b *backoff) next() time.Duration {
+	b.retries++
+	wait := b.interval
+	if b.policy.Jitter > 0 {
+		// randomized in [interval*(1-jitter), interval*(1+jitter)].
+		wait = time.Duration(float64(wait) * (1 + b.policy.Jitter*(2*b.rand.Float64()-1)))
+	}
+	b.interval = time.Duration(float64(b.interval) * b.policy.Multiplier)
+	if b.interval > b.policy.MaxInterval {
+		b.interval = b.policy.MaxInterval
+	}
+	return wait
+}
+
+// exhausted returns true if the retries reach the max retries of the policy.
+func (b *backoff) exhausted() bool {
+	return b.policy.MaxRetries > 0 && b.retries >= b.policy.MaxRetries
+}
+
+// reset starts retries from the initial interval.
+func (b *backoff) reset() {
+	b.interval = b.policy.InitialInterval
+	b.retries = 0
+}
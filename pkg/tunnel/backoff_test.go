@@ -0,0 +1,98 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/config"
+)
+
+func TestSetReconnectPolicy(t *testing.T) {
+	defer SetReconnectPolicy(DefaultReconnectPolicy)
+	assert.Nil(t, SetReconnectPolicy(DefaultReconnectPolicy))
+	for _, p := range []ReconnectPolicy{
+		{},
+		{InitialInterval: time.Second, MaxInterval: time.Millisecond, Multiplier: 2},
+		{InitialInterval: time.Second, MaxInterval: time.Minute, Multiplier: 0.5},
+		{InitialInterval: time.Second, MaxInterval: time.Minute, Multiplier: 2, Jitter: 2},
+		{InitialInterval: time.Second, MaxInterval: time.Minute, Multiplier: 2, MaxRetries: -1},
+	} {
+		assert.NotNil(t, SetReconnectPolicy(p), "%+v", p)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	b := newBackoff(ReconnectPolicy{
+		InitialInterval: time.Second,
+		MaxInterval:     5 * time.Second,
+		Multiplier:      2,
+		MaxRetries:      4,
+	})
+	for _, expect := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		assert.Equal(t, expect, b.next())
+		assert.False(t, b.exhausted())
+	}
+	// the interval is at most max interval
+	assert.Equal(t, 5*time.Second, b.next())
+	assert.True(t, b.exhausted())
+	b.reset()
+	assert.False(t, b.exhausted())
+	assert.Equal(t, time.Second, b.next())
+
+	// the interval is randomized by jitter
+	policy := DefaultReconnectPolicy
+	b = newBackoff(policy)
+	waits := make(map[time.Duration]bool)
+	for i := 0; i < 10; i++ {
+		b.reset()
+		wait := b.next()
+		assert.InDelta(t, float64(time.Second), float64(wait), float64(time.Second)*policy.Jitter)
+		waits[wait] = true
+	}
+	assert.True(t, len(waits) > 1)
+	assert.False(t, b.exhausted())
+}
+
+func TestReconnectMaxRetries(t *testing.T) {
+	assert.Nil(t, SetReconnectPolicy(ReconnectPolicy{
+		InitialInterval: time.Millisecond,
+		MaxInterval:     time.Millisecond,
+		Multiplier:      1,
+		MaxRetries:      2,
+	}))
+	defer SetReconnectPolicy(DefaultReconnectPolicy)
+
+	retries := 0
+	tun := newTestEdgeTunnel()
+	tun.parentAddr = tun.cloudAddr
+	tun.cloudAddr = "127.0.0.1:1"
+	tun.conf = &config.ClusterControllerConfig{}
+	tun.RegistMaxRetriesHook(func(n int) {
+		retries = n
+	})
+
+	// goes back to the parent configured after max retries.
+	tun.reconnect()
+	assert.Equal(t, 2, retries)
+	assert.Equal(t, tun.parentAddr, tun.cloudAddr)
+	assert.NotNil(t, tun.wsclient)
+	tun.wsclient.Close()
+}
@@ -0,0 +1,368 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+// Channel is a logical channel of a tunnel connection, whose messages are flow controlled
+// independently of other channels, so that a channel lagging does not hold back the others.
+type Channel byte
+
+const (
+	// ChannelControl carries tasks, their responses, registries and routes of clusters.
+	ChannelControl Channel = iota
+	// ChannelReport carries edge reports and messages unknown.
+	ChannelReport
+	// ChannelProxy carries k8s api requests proxied from cloud and their responses.
+	ChannelProxy
+
+	// channels is the number of channels.
+	channels = int(ChannelProxy) + 1
+	// channelWindowUpdate starts frames granting bytes of a channel back to the peer,
+	// instead of a channel.
+	channelWindowUpdate byte = 0xff
+	// windowUpdateSize is the size of window update frames.
+	windowUpdateSize = 10
+)
+
+var channelNames = []string{"control", "report", "proxy"}
+
+func (c Channel) String() string {
+	if int(c) >= channels {
+		return "unknown"
+	}
+	return channelNames[c]
+}
+
+// ChannelOf returns the channel of ClusterMessage serialized in msg,
+// ChannelReport if msg is not a ClusterMessage.
+func ChannelOf(msg []byte) Channel {
+	head := clustermessage.HeadOf(msg)
+	if head == nil {
+		return ChannelReport
+	}
+	if strings.HasPrefix(head.MessageID, otev1.ClusterProxyMessagePrefix) {
+		return ChannelProxy
+	}
+	if head.Command.Priority() == clustermessage.PriorityReport {
+		return ChannelReport
+	}
+	return ChannelControl
+}
+
+// ErrChannelBlocked is returned by sending a message whose channel has no window left
+// in WriteTimeout, e.g., the peer stops handling messages of the channel.
+var ErrChannelBlocked = fmt.Errorf("tunnel channel is blocked")
+
+// channelWindow is the window of channels in bytes, 0 if channels are not used.
+var channelWindow int64
+
+// SetChannelWindow sets the window of tunnel channels in bytes accepted from childs and
+// offered to parent, which is the max bytes of messages of a channel sent to the peer
+// and not handled yet. Connections are not multiplexed by channels if window is 0.
+func SetChannelWindow(window int64) error {
+	if window < 0 {
+		return fmt.Errorf("tunnel channel window must not be negative")
+	}
+	atomic.StoreInt64(&channelWindow, window)
+	return nil
+}
+
+// ChannelWindow returns the window of tunnel channels in bytes, 0 if channels are not used.
+func ChannelWindow() int64 {
+	return atomic.LoadInt64(&channelWindow)
+}
+
+// NegotiateChannelWindow returns the window of channels used with a peer offering window,
+// which is the smaller of both, or empty if either side has none.
+func NegotiateChannelWindow(offered string) string {
+	local := ChannelWindow()
+	window, err := strconv.ParseInt(offered, 10, 64)
+	if local <= 0 || err != nil || window <= 0 {
+		return ""
+	}
+	if window > local {
+		window = local
+	}
+	return strconv.FormatInt(window, 10)
+}
+
+/*
+muxer multiplexes messages of a connection by channels, each message is framed by its channel.
+Each direction of a channel has a window of bytes: the sender sends at most window bytes
+not granted back, and the receiver queues messages of each channel separately,
+and grants bytes of messages back by window update frames once they are handled.
+A message larger than the window is sent once all bytes of its channel are granted back.
+*/
+type muxer struct {
+	window int64
+
+	lock   sync.Mutex
+	cond   *sync.Cond
+	closed bool
+	// credits are bytes of each channel allowed to send until the peer grants more.
+	credits [channels]int64
+	// inboxes are messages of each channel received and not handled.
+	inboxes [channels]*inbox
+}
+
+func newMuxer(window int64) *muxer {
+	m := &muxer{window: window}
+	m.cond = sync.NewCond(&m.lock)
+	for i := range m.credits {
+		m.credits[i] = window
+		m.inboxes[i] = newInbox()
+	}
+	return m
+}
+
+// reserve waits until n bytes of ch are allowed to send, for at most timeout.
+func (m *muxer) reserve(ch Channel, n int, timeout time.Duration) error {
+	size := int64(n)
+	timer := time.AfterFunc(timeout, func() {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		m.cond.Broadcast()
+	})
+	defer timer.Stop()
+	deadline := time.Now().Add(timeout)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for m.credits[ch] < size && m.credits[ch] < m.window {
+		if m.closed {
+			return fmt.Errorf("connection is closed")
+		}
+		if !time.Now().Before(deadline) {
+			return ErrChannelBlocked
+		}
+		m.cond.Wait()
+	}
+	if m.closed {
+		return fmt.Errorf("connection is closed")
+	}
+	m.credits[ch] -= size
+	return nil
+}
+
+// grant allows n more bytes of ch to send.
+func (m *muxer) grant(ch Channel, n int64) {
+	if m == nil {
+		return
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.credits[ch] += n
+	m.cond.Broadcast()
+}
+
+// close fails messages waiting to send, and stops handling messages once those received are handled.
+func (m *muxer) close() {
+	if m == nil {
+		return
+	}
+	m.lock.Lock()
+	m.closed = true
+	m.cond.Broadcast()
+	m.lock.Unlock()
+	for _, in := range m.inboxes {
+		in.close()
+	}
+}
+
+// frame returns msg framed by ch.
+func (m *muxer) frame(ch Channel, msg []byte) []byte {
+	if m == nil {
+		return msg
+	}
+	return append([]byte{byte(ch)}, msg...)
+}
+
+// demux returns the channel and message of frame, or false if frame is a window update.
+func (m *muxer) demux(frame []byte) (Channel, []byte, bool, error) {
+	if m == nil {
+		return ChannelControl, frame, true, nil
+	}
+	if len(frame) == 0 {
+		return 0, nil, false, fmt.Errorf("empty frame")
+	}
+	if frame[0] == channelWindowUpdate {
+		if len(frame) != windowUpdateSize || int(frame[1]) >= channels {
+			return 0, nil, false, fmt.Errorf("invalid window update")
+		}
+		m.grant(Channel(frame[1]), int64(binary.BigEndian.Uint64(frame[2:])))
+		return 0, nil, false, nil
+	}
+	if int(frame[0]) >= channels {
+		return 0, nil, false, fmt.Errorf("unknown channel %d", frame[0])
+	}
+	return Channel(frame[0]), frame[1:], true, nil
+}
+
+// windowUpdate returns the frame granting n bytes of ch back to the peer.
+func windowUpdate(ch Channel, n int64) []byte {
+	frame := make([]byte, windowUpdateSize)
+	frame[0] = channelWindowUpdate
+	frame[1] = byte(ch)
+	binary.BigEndian.PutUint64(frame[2:], uint64(n))
+	return frame
+}
+
+// inbox queues messages of a channel received until handled.
+type inbox struct {
+	lock     sync.Mutex
+	cond     *sync.Cond
+	closed   bool
+	messages [][]byte
+	// held is bytes received and not granted back, pending is bytes of them handled.
+	held    int64
+	pending int64
+}
+
+func newInbox() *inbox {
+	in := &inbox{}
+	in.cond = sync.NewCond(&in.lock)
+	return in
+}
+
+// push queues msg, it fails if the peer sends more than window.
+func (in *inbox) push(msg []byte, window int64) error {
+	in.lock.Lock()
+	defer in.lock.Unlock()
+	size := int64(len(msg))
+	if in.held > 0 && in.held+size > window {
+		return fmt.Errorf("window of %d bytes exceeded", window)
+	}
+	in.held += size
+	in.messages = append(in.messages, msg)
+	in.cond.Signal()
+	return nil
+}
+
+// pop returns the next message to handle, or false once closed and all messages are handled.
+func (in *inbox) pop() ([]byte, bool) {
+	in.lock.Lock()
+	defer in.lock.Unlock()
+	for len(in.messages) == 0 {
+		if in.closed {
+			return nil, false
+		}
+		in.cond.Wait()
+	}
+	msg := in.messages[0]
+	in.messages[0] = nil
+	in.messages = in.messages[1:]
+	return msg, true
+}
+
+// handled records a message of n bytes handled, and returns bytes to grant back to the peer,
+// which are granted in batches of half window, or once all messages are handled.
+func (in *inbox) handled(n int, window int64) int64 {
+	in.lock.Lock()
+	defer in.lock.Unlock()
+	in.pending += int64(n)
+	if in.pending < window/2 && len(in.messages) != 0 {
+		return 0
+	}
+	granted := in.pending
+	in.held -= granted
+	in.pending = 0
+	return granted
+}
+
+func (in *inbox) close() {
+	in.lock.Lock()
+	defer in.lock.Unlock()
+	in.closed = true
+	in.cond.Broadcast()
+}
+
+// setChannels multiplexes connection of c by channels of window negotiated,
+// before messages are sent or read.
+func (c *WSClient) setChannels(window string) {
+	c.mux = nil
+	if n, err := strconv.ParseInt(window, 10, 64); err == nil && n > 0 {
+		c.mux = newMuxer(n)
+		klog.Infof("wsclient %s multiplexes channels of window %d", c.Name, n)
+	}
+}
+
+/*
+serveChannels reads messages of c until reading fails, and hands them over to handle
+in order of each channel by a goroutine of the channel, so that a channel handled slowly
+does not delay others. received is called after each message is read, if not nil.
+c must be multiplexed by channels.
+*/
+func (c *WSClient) serveChannels(received func(msg []byte), handle func(msg []byte)) {
+	for ch := range c.mux.inboxes {
+		go c.handleChannel(Channel(ch), handle)
+	}
+	defer c.mux.close()
+	for {
+		ch, msg, err := c.readChannelMessage()
+		if err != nil {
+			klog.Errorf("wsclient %s read msg error, err:%s", c.Name, err.Error())
+			return
+		}
+		if err := c.mux.inboxes[ch].push(msg, c.mux.window); err != nil {
+			klog.Errorf("wsclient %s channel %s: %s", c.Name, ch, err.Error())
+			return
+		}
+		if received != nil {
+			received(msg)
+		}
+	}
+}
+
+// handleChannel handles messages of ch one by one, and grants them back to the peer.
+func (c *WSClient) handleChannel(ch Channel, handle func(msg []byte)) {
+	in := c.mux.inboxes[ch]
+	for {
+		msg, ok := in.pop()
+		if !ok {
+			return
+		}
+		handle(msg)
+		if n := in.handled(len(msg), c.mux.window); n > 0 {
+			c.writeFrame(windowUpdate(ch, n))
+		}
+	}
+}
+
+// writeFrame writes frame to connection directly, bypassing the send queue.
+func (c *WSClient) writeFrame(frame []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.conn.writeMessage(frame); err != nil {
+		klog.V(3).Infof("wsclient %s write frame failed: %s", c.Name, err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/config"
+)
+
+func newChannelMessage(t *testing.T, id string, command clustermessage.CommandType, size int) []byte {
+	data, err := proto.Marshal(&clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{MessageID: id, Command: command},
+		Body: bytes.Repeat([]byte("x"), size),
+	})
+	assert.Nil(t, err)
+	return data
+}
+
+func TestChannelOf(t *testing.T) {
+	assert.Equal(t, ChannelControl, ChannelOf(newChannelMessage(t, "m1", clustermessage.CommandType_ControlReq, 1)))
+	assert.Equal(t, ChannelControl, ChannelOf(newChannelMessage(t, "m1", clustermessage.CommandType_SubTreeRoute, 1)))
+	assert.Equal(t, ChannelReport, ChannelOf(newChannelMessage(t, "m1", clustermessage.CommandType_EdgeReport, 1)))
+	assert.Equal(t, ChannelProxy, ChannelOf(newChannelMessage(t,
+		otev1.ClusterProxyMessagePrefix+"1", clustermessage.CommandType_ControlResp, 1)))
+	assert.Equal(t, ChannelReport, ChannelOf([]byte("invalid")))
+
+	assert.Equal(t, "control", ChannelControl.String())
+	assert.Equal(t, "proxy", ChannelProxy.String())
+	assert.Equal(t, "unknown", Channel(channels).String())
+}
+
+func TestSetChannelWindow(t *testing.T) {
+	defer SetChannelWindow(0)
+	assert.NotNil(t, SetChannelWindow(-1))
+	assert.Equal(t, "", NegotiateChannelWindow("1024"))
+
+	assert.Nil(t, SetChannelWindow(2048))
+	assert.Equal(t, int64(2048), ChannelWindow())
+	assert.Equal(t, "1024", NegotiateChannelWindow("1024"))
+	assert.Equal(t, "2048", NegotiateChannelWindow("4096"))
+	assert.Equal(t, "", NegotiateChannelWindow(""))
+	assert.Equal(t, "", NegotiateChannelWindow("0"))
+	assert.Equal(t, "", NegotiateChannelWindow("abc"))
+}
+
+func TestMuxerWindow(t *testing.T) {
+	m := newMuxer(100)
+	assert.Nil(t, m.reserve(ChannelReport, 60, time.Second))
+	// other channels have their own windows.
+	assert.Nil(t, m.reserve(ChannelControl, 100, time.Second))
+	assert.Equal(t, ErrChannelBlocked, m.reserve(ChannelReport, 60, 20*time.Millisecond))
+
+	done := make(chan error)
+	go func() {
+		done <- m.reserve(ChannelReport, 60, time.Second)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	m.grant(ChannelReport, 60)
+	assert.Nil(t, <-done)
+
+	// a message larger than window waits for all bytes granted back.
+	go func() {
+		done <- m.reserve(ChannelReport, 200, time.Second)
+	}()
+	select {
+	case <-done:
+		t.Errorf("large message reserved with bytes outstanding")
+	case <-time.After(20 * time.Millisecond):
+	}
+	m.grant(ChannelReport, 60)
+	assert.Nil(t, <-done)
+
+	go func() {
+		done <- m.reserve(ChannelReport, 1, time.Second)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	m.close()
+	assert.NotNil(t, <-done)
+	assert.NotNil(t, m.reserve(ChannelControl, 0, time.Second))
+}
+
+func TestMuxerFrame(t *testing.T) {
+	var nilMux *muxer
+	assert.Equal(t, []byte("msg"), nilMux.frame(ChannelProxy, []byte("msg")))
+	ch, msg, ok, err := nilMux.demux([]byte("msg"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, ChannelControl, ch)
+	assert.Equal(t, []byte("msg"), msg)
+
+	m := newMuxer(100)
+	ch, msg, ok, err = m.demux(m.frame(ChannelProxy, []byte("msg")))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, ChannelProxy, ch)
+	assert.Equal(t, []byte("msg"), msg)
+
+	assert.Nil(t, m.reserve(ChannelProxy, 100, time.Second))
+	_, _, ok, err = m.demux(windowUpdate(ChannelProxy, 40))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, int64(40), m.credits[ChannelProxy])
+
+	for _, frame := range [][]byte{nil, {byte(channels)}, {channelWindowUpdate, 0}, {channelWindowUpdate, 9, 0, 0, 0, 0, 0, 0, 0, 1}} {
+		_, _, _, err = m.demux(frame)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestInbox(t *testing.T) {
+	in := newInbox()
+	assert.Nil(t, in.push(make([]byte, 30), 100))
+	assert.Nil(t, in.push(make([]byte, 30), 100))
+	assert.NotNil(t, in.push(make([]byte, 50), 100))
+
+	msg, ok := in.pop()
+	assert.True(t, ok)
+	assert.Len(t, msg, 30)
+	// bytes are granted in batches of half window.
+	assert.Equal(t, int64(0), in.handled(len(msg), 100))
+	msg, ok = in.pop()
+	assert.True(t, ok)
+	assert.Equal(t, int64(60), in.handled(len(msg), 100))
+
+	// a message larger than window is accepted once all bytes are granted back.
+	assert.Nil(t, in.push(make([]byte, 200), 100))
+	msg, _ = in.pop()
+	// or all messages are handled.
+	assert.Equal(t, int64(200), in.handled(len(msg), 100))
+
+	assert.Nil(t, in.push([]byte("last"), 100))
+	in.close()
+	msg, ok = in.pop()
+	assert.True(t, ok)
+	assert.Equal(t, []byte("last"), msg)
+	_, ok = in.pop()
+	assert.False(t, ok)
+}
+
+func TestChannelsNegotiated(t *testing.T) {
+	assert.Nil(t, SetChannelWindow(1024))
+	defer SetChannelWindow(0)
+
+	received := make(chan []byte, 10)
+	release := make(chan struct{})
+	ct := NewCloudTunnel("127.0.0.1:0").(*cloudTunnel)
+	ct.RegistReturnMessageFunc(func(client string, msg []byte) error {
+		if ChannelOf(msg) == ChannelReport {
+			<-release
+		}
+		received <- msg
+		return nil
+	})
+	assert.Nil(t, ct.Start())
+
+	e := &edgeTunnel{
+		name:                  "c-channels",
+		cloudAddr:             ct.server.Addr,
+		listenAddr:            ":8287",
+		conf:                  &config.ClusterControllerConfig{},
+		afterConnectToHook:    func() {},
+		afterDisconnectHook:   func() {},
+		receiveMessageHandler: func(string, []byte) error { return nil },
+	}
+	assert.Nil(t, e.connect())
+	defer e.wsclient.Close()
+	assert.NotNil(t, e.wsclient.mux)
+	assert.Equal(t, int64(1024), e.wsclient.mux.window)
+	go e.handleReceiveMessage()
+
+	// the second report waits for the first one handled.
+	report := newChannelMessage(t, "r1", clustermessage.CommandType_EdgeReport, 600)
+	assert.Nil(t, e.Send(report))
+	sent := make(chan error)
+	go func() {
+		sent <- e.Send(report)
+	}()
+	select {
+	case <-sent:
+		t.Fatal("report sent beyond window")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// reports held back do not hold back control messages.
+	control := newChannelMessage(t, "c1", clustermessage.CommandType_ControlResp, 600)
+	assert.Nil(t, e.Send(control))
+	select {
+	case msg := <-received:
+		assert.Equal(t, control, msg)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no control message received by cloudtunnel")
+	}
+
+	close(release)
+	assert.Nil(t, <-sent)
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-received:
+			assert.Equal(t, report, msg)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no report received by cloudtunnel")
+		}
+	}
+}
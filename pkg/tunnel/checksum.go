@@ -0,0 +1,255 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	// ChecksumCRC32C checksums frames by crc32 of Castagnoli polynomial.
+	ChecksumCRC32C = "crc32c"
+
+	// frameChecked starts frames of messages, followed by sequence, checksum and the message.
+	frameChecked byte = 0
+	// frameRetransmit starts frames requesting the peer to send frames again from the sequence.
+	frameRetransmit byte = 1
+	// checksumHeaderSize is the size of type, sequence and checksum of checked frames.
+	checksumHeaderSize = 9
+)
+
+var (
+	crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+	// checksumEnabled is 1 if checksums are offered to parent.
+	checksumEnabled int32
+
+	// maxRetainedFrames is the max number of frames sent kept for retransmission.
+	maxRetainedFrames = 256
+	// maxRetainedBytes is the max bytes of frames sent kept for retransmission,
+	// the latest frame is kept even if it is larger.
+	maxRetainedBytes = 4 << 20
+	// retransmitInterval is the min interval to request retransmission again
+	// while frames out of order are received.
+	retransmitInterval = time.Second
+)
+
+// SetChecksum sets whether frames sent to and received from parent are checksummed,
+// if the parent supports checksums. Checksums of childs are accepted if they offer.
+func SetChecksum(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&checksumEnabled, v)
+}
+
+// Checksum returns the checksum offered to parent, empty if not enabled.
+func Checksum() string {
+	if atomic.LoadInt32(&checksumEnabled) == 0 {
+		return ""
+	}
+	return ChecksumCRC32C
+}
+
+// NegotiateChecksum returns the checksum used with a child offering checksum, empty if not supported.
+func NegotiateChecksum(offered string) string {
+	if offered == ChecksumCRC32C {
+		return offered
+	}
+	return ""
+}
+
+// setChecksum checksums frames of c by checksum negotiated, before messages are sent or read.
+func (c *WSClient) setChecksum(checksum string) {
+	if checksum != ChecksumCRC32C {
+		return
+	}
+	c.conn = newChecksumConn(c.Name, c.conn)
+	klog.Infof("wsclient %s checksums frames by %s", c.Name, checksum)
+}
+
+/*
+checksumConn checksums frames of a connection, so that messages corrupted in transit,
+e.g., by flaky cellular links, are found instead of failing to be decoded.
+Frames are numbered in order, and the receiver drops a corrupted frame and all frames after it,
+and requests the sender to send frames again from the one corrupted, so that messages are
+received in order without loss. The sender keeps the latest frames sent for retransmission,
+the connection fails if a frame requested is not kept any more.
+*/
+type checksumConn struct {
+	conn messageConn
+	name string
+
+	writeLock sync.Mutex
+	// next is the sequence of the next frame to send.
+	next uint32
+	// retained are the latest frames sent in order, the last of which is of sequence next-1.
+	retained      [][]byte
+	retainedBytes int
+
+	// fields below are only used by reading.
+	// expected is the sequence of the next frame to receive.
+	expected uint32
+	// waiting is true if frames are dropped until the one expected is sent again.
+	waiting   bool
+	requested time.Time
+}
+
+func newChecksumConn(name string, conn messageConn) *checksumConn {
+	return &checksumConn{conn: conn, name: name}
+}
+
+// seal sets the checksum of frame, which is of all bytes except itself.
+func seal(frame []byte) {
+	binary.BigEndian.PutUint32(frame[5:checksumHeaderSize], frameChecksum(frame))
+}
+
+func frameChecksum(frame []byte) uint32 {
+	sum := crc32.Checksum(frame[:5], crc32cTable)
+	return crc32.Update(sum, crc32cTable, frame[checksumHeaderSize:])
+}
+
+// validFrame returns true if frame is of a known type and not corrupted.
+func validFrame(frame []byte) bool {
+	if len(frame) < checksumHeaderSize {
+		return false
+	}
+	if frame[0] != frameChecked && frame[0] != frameRetransmit {
+		return false
+	}
+	return binary.BigEndian.Uint32(frame[5:checksumHeaderSize]) == frameChecksum(frame)
+}
+
+func (c *checksumConn) writeMessage(msg []byte) error {
+	frame := make([]byte, checksumHeaderSize+len(msg))
+	frame[0] = frameChecked
+	copy(frame[checksumHeaderSize:], msg)
+
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	binary.BigEndian.PutUint32(frame[1:5], c.next)
+	seal(frame)
+	c.next++
+	c.retain(frame)
+	return c.conn.writeMessage(frame)
+}
+
+// retain keeps frame for retransmission and drops the oldest ones exceeding limits.
+func (c *checksumConn) retain(frame []byte) {
+	c.retained = append(c.retained, frame)
+	c.retainedBytes += len(frame)
+	drop := 0
+	for len(c.retained)-drop > 1 &&
+		(len(c.retained)-drop > maxRetainedFrames || c.retainedBytes > maxRetainedBytes) {
+		c.retainedBytes -= len(c.retained[drop])
+		c.retained[drop] = nil
+		drop++
+	}
+	c.retained = c.retained[drop:]
+}
+
+// retransmit sends frames again from seq requested by the peer.
+func (c *checksumConn) retransmit(seq uint32) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	behind := int(c.next - seq)
+	if behind < 0 || behind > len(c.retained) {
+		return fmt.Errorf("frame %d to retransmit is not kept, next frame is %d", seq, c.next)
+	}
+	klog.Warningf("wsclient %s retransmits %d frames from %d", c.name, behind, seq)
+	for _, frame := range c.retained[len(c.retained)-behind:] {
+		if err := c.conn.writeMessage(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// requestRetransmit requests the peer to send frames again from the one expected,
+// at most once in retransmitInterval unless force is true.
+func (c *checksumConn) requestRetransmit(force bool) error {
+	now := time.Now()
+	if !force && now.Sub(c.requested) < retransmitInterval {
+		return nil
+	}
+	c.waiting = true
+	c.requested = now
+	frame := make([]byte, checksumHeaderSize)
+	frame[0] = frameRetransmit
+	binary.BigEndian.PutUint32(frame[1:5], c.expected)
+	seal(frame)
+
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	return c.conn.writeMessage(frame)
+}
+
+func (c *checksumConn) readMessage(buf *bytes.Buffer) error {
+	for {
+		buf.Reset()
+		if err := c.conn.readMessage(buf); err != nil {
+			return err
+		}
+		frame := buf.Bytes()
+		if !validFrame(frame) {
+			klog.Warningf("wsclient %s receives a corrupted frame, request frames from %d again",
+				c.name, c.expected)
+			if err := c.requestRetransmit(true); err != nil {
+				return err
+			}
+			continue
+		}
+		seq := binary.BigEndian.Uint32(frame[1:5])
+		if frame[0] == frameRetransmit {
+			if err := c.retransmit(seq); err != nil {
+				return err
+			}
+			continue
+		}
+		if seq != c.expected {
+			// frames after a corrupted one are dropped since they are sent again with it,
+			// and frames before are already received. Request again in case the request is lost.
+			if c.waiting && int32(seq-c.expected) > 0 {
+				if err := c.requestRetransmit(false); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		c.expected++
+		c.waiting = false
+		buf.Next(checksumHeaderSize)
+		return nil
+	}
+}
+
+func (c *checksumConn) closeNormally(reason string) {
+	c.conn.closeNormally(reason)
+}
+
+func (c *checksumConn) close() error {
+	return c.conn.close()
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/config"
+)
+
+// testMessageConn is a connection of messages written to out and read from in, for tests only.
+type testMessageConn struct {
+	in  chan []byte
+	out chan []byte
+	// corrupt modifies frames written, if not nil.
+	corrupt func(frame []byte)
+	once    sync.Once
+}
+
+// newTestMessageConns returns a pair of connections connected to each other.
+func newTestMessageConns() (*testMessageConn, *testMessageConn) {
+	a, b := make(chan []byte, 100), make(chan []byte, 100)
+	return &testMessageConn{in: a, out: b}, &testMessageConn{in: b, out: a}
+}
+
+func (c *testMessageConn) writeMessage(msg []byte) error {
+	frame := append([]byte{}, msg...)
+	if c.corrupt != nil {
+		c.corrupt(frame)
+	}
+	c.out <- frame
+	return nil
+}
+
+func (c *testMessageConn) readMessage(buf *bytes.Buffer) error {
+	msg, ok := <-c.in
+	if !ok {
+		return io.EOF
+	}
+	buf.Write(msg)
+	return nil
+}
+
+func (c *testMessageConn) closeNormally(reason string) {}
+
+func (c *testMessageConn) close() error {
+	c.once.Do(func() { close(c.in) })
+	return nil
+}
+
+func TestNegotiateChecksum(t *testing.T) {
+	assert.Equal(t, "", Checksum())
+	SetChecksum(true)
+	assert.Equal(t, ChecksumCRC32C, Checksum())
+	SetChecksum(false)
+	assert.Equal(t, "", Checksum())
+
+	assert.Equal(t, ChecksumCRC32C, NegotiateChecksum(ChecksumCRC32C))
+	assert.Equal(t, "", NegotiateChecksum(""))
+	assert.Equal(t, "", NegotiateChecksum("xxhash"))
+}
+
+func TestValidFrame(t *testing.T) {
+	frame := make([]byte, checksumHeaderSize+4)
+	copy(frame[checksumHeaderSize:], "test")
+	seal(frame)
+	assert.True(t, validFrame(frame))
+	assert.False(t, validFrame(frame[:checksumHeaderSize-1]))
+
+	frame[checksumHeaderSize] ^= 1
+	assert.False(t, validFrame(frame))
+	frame[checksumHeaderSize] ^= 1
+	frame[0] = 2
+	seal(frame)
+	assert.False(t, validFrame(frame))
+}
+
+func TestChecksumConnRetransmit(t *testing.T) {
+	a, b := newTestMessageConns()
+	sender, receiver := newChecksumConn("a", a), newChecksumConn("b", b)
+	// the second frame is corrupted once.
+	frames := 0
+	a.corrupt = func(frame []byte) {
+		frames++
+		if frames == 2 {
+			frame[len(frame)-1] ^= 1
+		}
+	}
+	// retransmission is done by reading of sender.
+	go sender.readMessage(&bytes.Buffer{})
+
+	for _, msg := range []string{"m1", "m2", "m3"} {
+		assert.Nil(t, sender.writeMessage([]byte(msg)))
+	}
+	buf := &bytes.Buffer{}
+	for _, msg := range []string{"m1", "m2", "m3"} {
+		assert.Nil(t, receiver.readMessage(buf))
+		assert.Equal(t, msg, buf.String())
+	}
+	assert.False(t, receiver.waiting)
+	assert.Equal(t, uint32(3), receiver.expected)
+	assert.Equal(t, uint32(3), sender.next)
+	a.close()
+	b.close()
+}
+
+func TestChecksumConnRetain(t *testing.T) {
+	defer func(frames, bytes int) {
+		maxRetainedFrames, maxRetainedBytes = frames, bytes
+	}(maxRetainedFrames, maxRetainedBytes)
+	maxRetainedFrames = 2
+	maxRetainedBytes = 100
+
+	a, b := newTestMessageConns()
+	c := newChecksumConn("a", a)
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, c.writeMessage([]byte("test")))
+	}
+	assert.Equal(t, 2, len(c.retained))
+	assert.Equal(t, 2*(checksumHeaderSize+4), c.retainedBytes)
+
+	// the latest frame is kept even if larger than bytes limited.
+	assert.Nil(t, c.writeMessage(make([]byte, 200)))
+	assert.Equal(t, 1, len(c.retained))
+	assert.Equal(t, checksumHeaderSize+200, c.retainedBytes)
+
+	for len(b.in) > 0 {
+		<-b.in
+	}
+	assert.Nil(t, c.retransmit(3))
+	assert.Equal(t, 1, len(b.in))
+	assert.Nil(t, c.retransmit(4))
+	assert.NotNil(t, c.retransmit(2))
+	assert.NotNil(t, c.retransmit(5))
+}
+
+func TestChecksumNegotiated(t *testing.T) {
+	SetChecksum(true)
+	defer SetChecksum(false)
+
+	received := make(chan []byte, 10)
+	ct := NewCloudTunnel("127.0.0.1:0").(*cloudTunnel)
+	ct.RegistReturnMessageFunc(func(client string, msg []byte) error {
+		received <- msg
+		return nil
+	})
+	assert.Nil(t, ct.Start())
+
+	e := &edgeTunnel{
+		name:                  "c-checksum",
+		cloudAddr:             ct.server.Addr,
+		listenAddr:            ":8287",
+		conf:                  &config.ClusterControllerConfig{},
+		afterConnectToHook:    func() {},
+		afterDisconnectHook:   func() {},
+		receiveMessageHandler: func(string, []byte) error { return nil },
+	}
+	assert.Nil(t, e.connect())
+	defer e.wsclient.Close()
+	_, ok := e.wsclient.conn.(*checksumConn)
+	assert.True(t, ok)
+
+	assert.Nil(t, e.Send([]byte("test")))
+	select {
+	case msg := <-received:
+		assert.Equal(t, []byte("test"), msg)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received by cloudtunnel")
+	}
+}
@@ -18,18 +18,24 @@ package tunnel
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"math/rand"
 	"net"
 	"net/http"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
+	"google.golang.org/grpc"
 	"k8s.io/klog"
 
+	"github.com/baidu/ote-stack/pkg/capability"
 	"github.com/baidu/ote-stack/pkg/config"
+	"github.com/baidu/ote-stack/pkg/reporter"
 )
 
 const (
@@ -41,7 +47,14 @@ const (
 	controllerURI = "/controller"
 )
 
-var upgrader = websocket.Upgrader{}
+var (
+	upgrader = websocket.Upgrader{}
+
+	// CloudReceiveWorkers is the max number of messages from childs handled concurrently.
+	CloudReceiveWorkers = 64
+	// CloudReceiveQueueSize is the number of messages from a child queued before handled.
+	CloudReceiveQueueSize = 100
+)
 
 // ControllerManagerMsgHandleFunc is a function handle msg from controller manager,
 // string is remote address of the controller manager,
@@ -60,8 +73,12 @@ type CloudTunnel interface {
 	Broadcast(msg []byte)
 	// SendToControllerManager sends msg to anyone of controller manager.
 	SendToControllerManager([]byte) error
+	// Drain moves childs to the alternate parent and stops cloudtunnel in timeout.
+	Drain(alternate string, timeout time.Duration) error
 	// RegistRedirectFunc registers a func which calls before CheckNameValidFunc.
 	RegistRedirectFunc(fn RedirectFunc)
+	// RegistAssignFunc registers a func which calls after RedirectFunc for childs.
+	RegistAssignFunc(fn AssignFunc)
 	// RegistCheckNameValidFunc registers ClusterNameChecker.
 	RegistCheckNameValidFunc(fn ClusterNameChecker)
 	// RegistAfterConnectHook registers AfterConnectHook.
@@ -72,6 +89,8 @@ type CloudTunnel interface {
 	RegistClientCloseHandler(fn ClientCloseHandleFunc)
 	// RegistControllerManagerMsgHandler regists ControllerManagerMsgHandleFunc.
 	RegistControllerManagerMsgHandler(fn ControllerManagerMsgHandleFunc)
+	// StatsProvider provides stats of connections of childs and controller managers.
+	StatsProvider
 }
 
 // cloudTunnel handles all communications with edgetunnel.
@@ -79,14 +98,25 @@ type cloudTunnel struct {
 	clients               sync.Map
 	address               string
 	redirect              RedirectFunc
+	assign                AssignFunc
 	clusterNameCheck      ClusterNameChecker
 	receiveMessageHandler TunnelReadMessageFunc
 	notifyClientClosed    ClientCloseHandleFunc
 	afterConnectHook      AfterConnectHook
 	server                *http.Server
+	grpcServer            *grpc.Server
 	controllers           sync.Map // remoteAddr -> wsclient
 	controllersKey        []string
+	controllerEncodings   sync.Map // remoteAddr -> report encoding
 	controlMsgHandler     ControllerManagerMsgHandleFunc
+	// receiveWorkers bounds the number of messages handled concurrently.
+	receiveWorkers chan struct{}
+	// unhandled is the number of messages read from childs and not handled yet.
+	unhandled int64
+
+	drainLock      sync.RWMutex
+	draining       bool
+	drainAlternate string
 }
 
 // NewCloudTunnel returns a new cloudTunnel object.
@@ -94,11 +124,13 @@ func NewCloudTunnel(address string) CloudTunnel {
 	tunnel := &cloudTunnel{
 		address:            address,
 		redirect:           func() string { return "" },
+		assign:             func(string) string { return "" },
 		clusterNameCheck:   defaultClusterNameChecker,
 		notifyClientClosed: func(*config.ClusterRegistry) { return },
 		afterConnectHook:   defaultAfterConnectHook,
 		controlMsgHandler:  defaultControlMsgHandler,
 		controllersKey:     make([]string, 0),
+		receiveWorkers:     make(chan struct{}, CloudReceiveWorkers),
 	}
 
 	tunnel.receiveMessageHandler = func(client string, msg []byte) error {
@@ -129,17 +161,32 @@ func (t *cloudTunnel) Send(clusterName string, msg []byte) error {
 
 func (t *cloudTunnel) SendToControllerManager(msg []byte) error {
 	// select a controller and send the msg
-	client := findAController(t.controllers, t.controllersKey)
+	client := findAController(&t.controllers, t.controllersKey)
 	if client == nil {
 		return fmt.Errorf("cannot find a controller to send msg to")
 	}
 	return client.WriteMessage(msg)
 }
 
+func (t *cloudTunnel) Stats() []ConnectionStats {
+	var clients []*WSClient
+	for _, m := range []*sync.Map{&t.clients, &t.controllers} {
+		m.Range(func(k, v interface{}) bool {
+			clients = append(clients, v.(*WSClient))
+			return true
+		})
+	}
+	return clientStats(clients...)
+}
+
 func (t *cloudTunnel) RegistRedirectFunc(fn RedirectFunc) {
 	t.redirect = fn
 }
 
+func (t *cloudTunnel) RegistAssignFunc(fn AssignFunc) {
+	t.assign = fn
+}
+
 func (t *cloudTunnel) RegistCheckNameValidFunc(fn ClusterNameChecker) {
 	t.clusterNameCheck = fn
 }
@@ -166,18 +213,54 @@ func (t *cloudTunnel) handleReceiveMessage(client *WSClient) {
 	}
 
 	klog.Infof("wsclient %s start read message", client.Name)
+	// messages are handled off the read goroutine in order,
+	// reading blocks only if the queue of this client is full.
+	queue := make(chan []byte, CloudReceiveQueueSize)
+	go t.dispatchReceivedMessage(client.Name, queue)
+	defer close(queue)
+	// reading is delayed by the rate limit of the child, which slows the child down.
+	limiter := newChildLimiter(client.Name)
+	defer removeChildLimiter(client.Name, limiter)
+	if client.mux != nil {
+		// messages of each channel are handled in order by itself instead.
+		client.serveChannels(func(msg []byte) {
+			atomic.AddInt64(&t.unhandled, 1)
+			limiter.wait(client.Name, len(msg))
+		}, func(msg []byte) {
+			t.handleMessage(client.Name, msg)
+		})
+		return
+	}
 	for {
 		msg, err := client.ReadMessage()
 		if err != nil {
 			klog.Errorf("wsclient %s read msg error, err:%s", client.Name, err.Error())
 			break
 		}
-		go t.receiveMessageHandler(client.Name, msg)
+		atomic.AddInt64(&t.unhandled, 1)
+		queue <- msg
+		limiter.wait(client.Name, len(msg))
 	}
 }
 
-func (t *cloudTunnel) connect(cr *config.ClusterRegistry, conn *websocket.Conn) {
-	wsclient := NewWSClient(cr.Name, conn)
+// dispatchReceivedMessage handles messages of a client one by one until queue is closed.
+func (t *cloudTunnel) dispatchReceivedMessage(client string, queue <-chan []byte) {
+	for msg := range queue {
+		t.handleMessage(client, msg)
+	}
+}
+
+// handleMessage handles msg of client, which takes a worker while being handled,
+// so that a burst from one client takes at most one worker of each channel
+// and does not stall the others.
+func (t *cloudTunnel) handleMessage(client string, msg []byte) {
+	t.receiveWorkers <- struct{}{}
+	t.receiveMessageHandler(client, msg)
+	<-t.receiveWorkers
+	atomic.AddInt64(&t.unhandled, -1)
+}
+
+func (t *cloudTunnel) connect(cr *config.ClusterRegistry, wsclient *WSClient) {
 	_, ok := t.clients.LoadOrStore(cr.Name, wsclient)
 	if ok {
 		klog.Infof("cluster %s is already connected", cr.Name)
@@ -188,12 +271,15 @@ func (t *cloudTunnel) connect(cr *config.ClusterRegistry, conn *websocket.Conn)
 	}
 
 	klog.Infof("cluster %s is connected", cr.Name)
+	wsclient.track(RoleChild, cr.Name, RoleChild+"/"+cr.Name)
 	t.afterConnectHook(cr)
 	t.handleReceiveMessage(wsclient)
 
 	// notify client closed.
 	klog.Infof("cluster %s is disconnected", cr.Name)
 	cr.Time = time.Now().Unix()
+	hlc := config.HybridNow()
+	cr.HLC = &hlc
 	go t.notifyClientClosed(cr)
 
 	// close websocket.
@@ -201,63 +287,166 @@ func (t *cloudTunnel) connect(cr *config.ClusterRegistry, conn *websocket.Conn)
 	t.clients.Delete(cr.Name)
 }
 
-// handler for child cluster controller
-func (t *cloudTunnel) accessHandler(w http.ResponseWriter, r *http.Request) {
+// admitError is the error refusing a child to connect, with the http status code.
+type admitError struct {
+	code int
+	msg  string
+}
+
+func (e *admitError) Error() string {
+	return e.msg
+}
+
+/*
+admit checks the child of cluster connecting from remote address with header and tls state, and returns the registry
+of the child and the header to respond if it is accepted. If the child should connect to another
+server, the address of it is returned. Otherwise, an admitError tells why the child is refused.
+*/
+func (t *cloudTunnel) admit(cluster, remoteAddr string, header http.Header,
+	state *tls.ConnectionState) (*config.ClusterRegistry, http.Header, string, error) {
 	// redirect to another server if it is specified
-	redirectAddr := t.redirect()
-	if redirectAddr != "" {
-		redirectUrl := r.URL
-		redirectUrl.Host = redirectAddr
-		http.Redirect(w, r, redirectUrl.String(), http.StatusFound)
-		return
+	if redirectAddr := t.redirect(); redirectAddr != "" {
+		return nil, nil, redirectAddr, nil
 	}
 
-	cluster := mux.Vars(r)[accessURIParam]
+	// childs connecting while draining go to the alternate parent, or are refused without one
+	if draining, alternate := t.drainingTo(); draining {
+		if alternate != "" {
+			klog.V(1).Infof("cloud tunnel is draining, redirect cluster %s to %s", cluster, alternate)
+			return nil, nil, alternate, nil
+		}
+		klog.V(1).Infof("cloud tunnel is draining, cluster %s is refused", cluster)
+		return nil, nil, "", &admitError{http.StatusServiceUnavailable, "cloud tunnel is draining"}
+	}
+
+	// with mutual tls, the child can only register with the name of its certificate
+	if err := verifyPeerName(state, cluster); err != nil {
+		klog.V(1).Infof("cluster %s is refused: %v", cluster, err)
+		return nil, nil, "", &admitError{http.StatusForbidden, err.Error()}
+	}
+
+	// authenticate the child by the auth providers, if any
+	if err := authenticate(&AuthRequest{Cluster: cluster, RemoteAddr: remoteAddr, Header: header, TLS: state}); err != nil {
+		klog.V(1).Infof("cluster %s is not authenticated: %v", cluster, err)
+		return nil, nil, "", &admitError{http.StatusUnauthorized, err.Error()}
+	}
+
+	// redirect to the server the child is assigned to, unless it is redirected here by a Redirect message
+	if assignedAddr := t.assign(cluster); assignedAddr != "" &&
+		header.Get(config.ClusterConnectHeaderRedirected) == "" {
+		klog.V(1).Infof("cluster %s is assigned to %s, redirect it", cluster, assignedAddr)
+		return nil, nil, assignedAddr, nil
+	}
 
 	// get cluster listen addr from header.
 	// TODO if listen addr is duplicated, refuse to connect.
-	listenAddr := r.Header.Get(config.ClusterConnectHeaderListenAddr)
+	listenAddr := header.Get(config.ClusterConnectHeaderListenAddr)
 	if listenAddr == "" {
 		klog.V(1).Infof("cluster %s listenAddr is not specified, should set in header", cluster)
-		http.Error(w, "listenAddr is not specified, should set in header", http.StatusBadRequest)
-		return
+		return nil, nil, "", &admitError{http.StatusBadRequest, "listenAddr is not specified, should set in header"}
 	}
+	// childs listening on all addresses are reached by the address connecting from.
+	listenAddr = config.AdvertiseAddr(listenAddr, remoteAddr)
 	// get name of the child
-	name := r.Header.Get(config.ClusterConnectHeaderUserDefineName)
+	name := header.Get(config.ClusterConnectHeaderUserDefineName)
 	if name == "" {
 		klog.V(1).Infof("cluster %s user-define name is not specified, should set in header", cluster)
-		http.Error(w, "user-define name is not specified, should set in header", http.StatusBadRequest)
-		return
+		return nil, nil, "", &admitError{http.StatusBadRequest, "user-define name is not specified, should set in header"}
 	}
 
 	_, ok := t.clients.Load(cluster)
 	if ok {
 		klog.V(1).Infof("cluster %s is already connected", cluster)
-		http.Error(w, "already build connection", http.StatusForbidden)
-		return
+		return nil, nil, "", &admitError{http.StatusForbidden, "already build connection"}
+	}
+
+	// negotiate protocol version with the child
+	protocol, err := config.NegotiateProtocol(header.Get(config.ClusterConnectHeaderProtocol))
+	if err != nil {
+		klog.V(1).Infof("cluster %s is refused: %v", cluster, err)
+		return nil, nil, "", &admitError{http.StatusBadRequest, err.Error()}
+	}
+
+	// measure clock skew of the child
+	now := time.Now()
+	skew, err := config.ClockSkew(header.Get(config.ClusterConnectHeaderTime), now, now)
+	if err != nil {
+		klog.V(1).Infof("cannot measure clock skew of cluster %s: %v", cluster, err)
 	}
 
-	cr := config.ClusterRegistry{
+	hlc := config.HybridUpdateHeader(header.Get(config.ClusterConnectHeaderHLC))
+	cr := &config.ClusterRegistry{
 		Name:           cluster,
 		UserDefineName: name,
 		Listen:         listenAddr,
-		Time:           time.Now().Unix(),
+		Time:           now.Unix(),
+		Version:        header.Get(config.ClusterConnectHeaderVersion),
+		Protocol:       protocol,
+		ClockSkew:      skew,
+		Capabilities:   capability.Parse(header.Get(config.ClusterConnectHeaderCapabilities)),
+		HLC:            &hlc,
+		PreviousName:   header.Get(config.ClusterConnectHeaderPreviousName),
 	}
 
-	if !t.clusterNameCheck(&cr) {
+	if !t.clusterNameCheck(cr) {
 		klog.V(1).Infof("cluster %s has been registered", cluster)
-		http.Error(w, "cluster name has been registered", http.StatusForbidden)
+		return nil, nil, "", &admitError{http.StatusForbidden, "cluster name has been registered"}
+	}
+
+	respHeader := http.Header{}
+	respHeader.Set(config.ClusterConnectHeaderProtocol, strconv.Itoa(protocol))
+	respHeader.Set(config.ClusterConnectHeaderTime, strconv.FormatInt(config.UnixMilli(time.Now()), 10))
+	respHeader.Set(config.ClusterConnectHeaderHLC, hlc.String())
+	respHeader.Set(config.ClusterConnectHeaderEncoding,
+		reporter.NegotiateEncoding(header.Get(config.ClusterConnectHeaderEncodings)))
+	respHeader.Set(config.ClusterConnectHeaderCompression,
+		NegotiateCompression(header.Get(config.ClusterConnectHeaderCompressions)))
+	if window := NegotiateChannelWindow(header.Get(config.ClusterConnectHeaderChannelWindow)); window != "" {
+		respHeader.Set(config.ClusterConnectHeaderChannelWindow, window)
+	}
+	if checksum := NegotiateChecksum(header.Get(config.ClusterConnectHeaderChecksum)); checksum != "" {
+		respHeader.Set(config.ClusterConnectHeaderChecksum, checksum)
+	}
+	return cr, respHeader, "", nil
+}
+
+// setupChildClient sets the compression, channels and checksum negotiated in respHeader
+// with the child of wsclient.
+func setupChildClient(wsclient *WSClient, respHeader http.Header) {
+	compression := respHeader.Get(config.ClusterConnectHeaderCompression)
+	wsclient.setCompression(compression)
+	// the parent trains dictionaries from messages of the child
+	wsclient.trainDictionaries()
+	wsclient.setChannels(respHeader.Get(config.ClusterConnectHeaderChannelWindow))
+	wsclient.setChecksum(respHeader.Get(config.ClusterConnectHeaderChecksum))
+	klog.Infof("cluster %s connects with tunnel compression %s", wsclient.Name, compression)
+}
+
+// handler for child cluster controller
+func (t *cloudTunnel) accessHandler(w http.ResponseWriter, r *http.Request) {
+	cluster := mux.Vars(r)[accessURIParam]
+	cr, respHeader, redirectAddr, err := t.admit(cluster, r.RemoteAddr, r.Header, r.TLS)
+	if redirectAddr != "" {
+		redirectUrl := r.URL
+		redirectUrl.Host = redirectAddr
+		http.Redirect(w, r, redirectUrl.String(), http.StatusFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), err.(*admitError).code)
 		return
 	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := upgrader.Upgrade(w, r, respHeader)
 	if err != nil {
 		klog.Errorf("connect to cluster %s failed: %s", cluster, err.Error())
 		http.Error(w, "fail to upgrade to websocket", http.StatusInternalServerError)
 		return
 	}
 
-	go t.connect(&cr, conn)
+	wsclient := NewWSClient(cr.Name, conn)
+	setupChildClient(wsclient, respHeader)
+	go t.connect(cr, wsclient)
 }
 
 func (t *cloudTunnel) controllerHandler(w http.ResponseWriter, r *http.Request) {
@@ -270,7 +459,16 @@ func (t *cloudTunnel) controllerHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	if err := verifyPeerName(r.TLS, ControllerManagerCertName); err != nil {
+		klog.V(1).Infof("controller %s is refused: %v", r.RemoteAddr, err)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	encoding := reporter.NegotiateEncoding(r.Header.Get(config.ClusterConnectHeaderEncodings))
+	respHeader := http.Header{}
+	respHeader.Set(config.ClusterConnectHeaderEncoding, encoding)
+	conn, err := upgrader.Upgrade(w, r, respHeader)
 	if err != nil {
 		klog.Errorf("connect to controller %s failed: %s", r.RemoteAddr, err.Error())
 		http.Error(w, "fail to upgrade to websocket", http.StatusInternalServerError)
@@ -285,8 +483,12 @@ func (t *cloudTunnel) controllerHandler(w http.ResponseWriter, r *http.Request)
 		}
 		return
 	}
-	klog.Infof("controller %s is connected", r.RemoteAddr)
+	klog.Infof("controller %s is connected with report encoding %s", r.RemoteAddr, encoding)
+	host, _, _ := net.SplitHostPort(r.RemoteAddr)
+	wsclient.track(RoleControllerManager, r.RemoteAddr, RoleControllerManager+"/"+host)
 	t.controllersKey = append(t.controllersKey, r.RemoteAddr)
+	t.controllerEncodings.Store(r.RemoteAddr, encoding)
+	t.updateUpstreamEncoding()
 	// root cluster controller get msg from controllers and publish to clusters
 	go t.handleControlMsg(wsclient)
 }
@@ -311,14 +513,33 @@ func (t *cloudTunnel) handleControlMsg(client *WSClient) {
 	// close websocket.
 	t.controllers.Delete(client.Name)
 	t.controllersKey = removeFromSliceByValue(t.controllersKey, client.Name)
+	t.controllerEncodings.Delete(client.Name)
+	t.updateUpstreamEncoding()
 	client.Close()
 }
 
+// updateUpstreamEncoding sets the report encoding to controller managers, which is the one
+// negotiated with all of them, or json if they are different, since any of them may be sent to.
+func (t *cloudTunnel) updateUpstreamEncoding() {
+	encoding := ""
+	t.controllerEncodings.Range(func(k, v interface{}) bool {
+		if encoding != "" && encoding != v.(string) {
+			encoding = reporter.EncodingJSON
+			return false
+		}
+		encoding = v.(string)
+		return true
+	})
+	reporter.SetUpstreamEncoding(encoding)
+}
+
 func (t *cloudTunnel) Stop() error {
 	// gradeful stop cloudtunnel.
 	ctx, cancel := context.WithTimeout(context.Background(), StopTimeout)
 	defer cancel()
-	return t.server.Shutdown(ctx)
+	err := t.server.Shutdown(ctx)
+	t.grpcServer.Stop()
+	return err
 }
 
 func (t *cloudTunnel) Start() error {
@@ -329,13 +550,21 @@ func (t *cloudTunnel) Start() error {
 	// add handler for ote controller manager
 	router.HandleFunc(controllerURI, t.controllerHandler)
 
-	ln, err := net.Listen("tcp", t.address)
+	ln, err := Listen(t.address)
 	if err != nil {
 		return err
 	}
+	if getServerTLS() != nil {
+		klog.Infof("cloud tunnel requires mutual tls")
+		ln = tls.NewListener(ln, &tls.Config{GetConfigForClient: serverTLSForClient})
+	}
+	// childs connecting by grpc are served by grpc server on the same address
+	grpcLn, httpLn := splitGRPC(ln)
+	t.grpcServer = newGRPCServer(t)
+	go t.grpcServer.Serve(grpcLn)
 
 	t.server = &http.Server{
-		Addr:         ln.Addr().String(),
+		Addr:         httpLn.Addr().String(),
 		Handler:      router,
 		WriteTimeout: WriteTimeout,
 		ReadTimeout:  ReadTimeout,
@@ -343,7 +572,7 @@ func (t *cloudTunnel) Start() error {
 	}
 
 	go func() {
-		if err := t.server.Serve(ln); err != nil {
+		if err := t.server.Serve(httpLn); err != nil && err != http.ErrServerClosed {
 			klog.Fatalf("fail to start cloudtunnel: %s", err.Error())
 		}
 	}()
@@ -376,7 +605,7 @@ func removeFromSliceByValue(slice []string, s string) []string {
 	return append(slice[:n], slice[n+1:]...)
 }
 
-func findAController(clientMap sync.Map, clientKey []string) *WSClient {
+func findAController(clientMap *sync.Map, clientKey []string) *WSClient {
 	if clientKey == nil || len(clientKey) == 0 {
 		klog.Errorf("client map and key are empty to find a controller")
 		return nil
@@ -388,6 +617,6 @@ func findAController(clientMap sync.Map, clientKey []string) *WSClient {
 		return client.(*WSClient)
 	}
 
-	klog.Warningf("client %s is in client key but has no client(%v)", clientName, clientMap)
+	klog.Warningf("client %s is in client key but has no client", clientName)
 	return findAController(clientMap, removeFromSliceByValue(clientKey, clientName))
 }
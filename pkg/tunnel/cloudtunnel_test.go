@@ -22,18 +22,22 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 
+	otev1 "github.com/baidu/ote-stack/pkg/apis/ote/v1"
 	"github.com/baidu/ote-stack/pkg/config"
+	"github.com/baidu/ote-stack/pkg/reporter"
 )
 
 var (
-	ipAddrForTest = &net.IPAddr{[]byte{}, ""}
+	ipAddrForTest = &net.IPAddr{IP: []byte{}, Zone: ""}
 )
 
 func TestRemoveFromSliceByValue(t *testing.T) {
@@ -50,15 +54,15 @@ func TestFindAController(t *testing.T) {
 	clientMap := sync.Map{}
 	var clientKey []string
 	// clientKey is empty
-	ws := findAController(clientMap, clientKey)
+	ws := findAController(&clientMap, clientKey)
 	assert.Nil(t, ws)
 	// find one in clientKey, but not in clientMap, finally find nothing
 	clientKey = append(clientKey, "a")
-	ws = findAController(clientMap, clientKey)
+	ws = findAController(&clientMap, clientKey)
 	assert.Nil(t, ws)
 	oWs := &WSClient{}
 	clientMap.Store("a", oWs)
-	ws = findAController(clientMap, clientKey)
+	ws = findAController(&clientMap, clientKey)
 	assert.NotNil(t, ws)
 	assert.Equal(t, oWs, ws)
 }
@@ -77,10 +81,20 @@ func TestListenedCloudTunnel(t *testing.T) {
 	// wait listen
 	time.Sleep(1 * time.Second)
 	assert.Nil(t, err)
+	// connect a client with an old protocol version
+	oldHeader := http.Header{}
+	oldHeader.Add(config.ClusterConnectHeaderListenAddr, "fake")
+	oldHeader.Add(config.ClusterConnectHeaderUserDefineName, clientName)
+	oldHeader.Add(config.ClusterConnectHeaderProtocol, "0")
+	_, resp, err := websocket.DefaultDialer.Dial(u.String(), oldHeader)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
 	// connect a client
-	conn, _, err := websocket.DefaultDialer.Dial(u.String(), header)
+	header.Add(config.ClusterConnectHeaderProtocol, strconv.Itoa(config.ProtocolVersion+1))
+	conn, resp, err := websocket.DefaultDialer.Dial(u.String(), header)
 	assert.NotNil(t, conn)
 	assert.Nil(t, err)
+	assert.Equal(t, strconv.Itoa(config.ProtocolVersion), resp.Header.Get(config.ClusterConnectHeaderProtocol))
 	//TODO assert wsclient exits
 	//	client1, ok := ct.clients.Load("c1")
 	//	assert.True(t, ok)
@@ -123,6 +137,40 @@ func TestAccessHandler(t *testing.T) {
 	assert.Equal(t, "http://"+redirectAddr, l[0])
 }
 
+func TestAccessHandlerAssign(t *testing.T) {
+	// redirect to the frontend assigned
+	ct := cloudTunnel{
+		redirect: func() string { return "" },
+		assign: func(cluster string) string {
+			if cluster == "c1" {
+				return "frontend2"
+			}
+			return ""
+		},
+	}
+	w := httptest.NewRecorder()
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "http://origin/access/c1", nil),
+		map[string]string{accessURIParam: "c1"})
+	ct.accessHandler(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "http://frontend2/access/c1", w.Header().Get("Location"))
+
+	// cluster assigned to this frontend is not redirected
+	w = httptest.NewRecorder()
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodGet, "http://origin/access/c2", nil),
+		map[string]string{accessURIParam: "c2"})
+	ct.accessHandler(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// cluster redirected here by Redirect message is not assigned to another
+	w = httptest.NewRecorder()
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodGet, "http://origin/access/c1", nil),
+		map[string]string{accessURIParam: "c1"})
+	req.Header.Set(config.ClusterConnectHeaderRedirected, "true")
+	ct.accessHandler(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
 func TestControllerHandler(t *testing.T) {
 	// redirect to leader
 	redirectAddr := "redirect"
@@ -142,6 +190,10 @@ func TestControllerHandler(t *testing.T) {
 func TestHandleReceieveMsg(t *testing.T) {
 	ctInter := NewCloudTunnel("")
 	ct := ctInter.(*cloudTunnel)
+	registries := make(chan *config.ClusterRegistry, 1)
+	ct.RegistAfterConnectHook(func(cr *config.ClusterRegistry) {
+		registries <- cr
+	})
 	err := ct.Start()
 	addr := ct.server.Addr
 	clientName := "c1"
@@ -153,10 +205,35 @@ func TestHandleReceieveMsg(t *testing.T) {
 	// wait listen
 	time.Sleep(1 * time.Second)
 	assert.Nil(t, err)
-	// connect a client
-	conn, _, err := websocket.DefaultDialer.Dial(u.String(), header)
+	// connect a client with an old protocol version
+	oldHeader := http.Header{}
+	oldHeader.Add(config.ClusterConnectHeaderListenAddr, "fake")
+	oldHeader.Add(config.ClusterConnectHeaderUserDefineName, clientName)
+	oldHeader.Add(config.ClusterConnectHeaderProtocol, "0")
+	_, resp, err := websocket.DefaultDialer.Dial(u.String(), oldHeader)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	// connect a client with clock ahead of 1 minute
+	header.Add(config.ClusterConnectHeaderProtocol, strconv.Itoa(config.ProtocolVersion+1))
+	header.Add(config.ClusterConnectHeaderTime, strconv.FormatInt(config.UnixMilli(time.Now().Add(time.Minute)), 10))
+	childHLC := otev1.HybridTime{WallTime: config.UnixMilli(time.Now().Add(time.Minute)), Logical: 3}
+	header.Add(config.ClusterConnectHeaderHLC, childHLC.String())
+	conn, resp, err := websocket.DefaultDialer.Dial(u.String(), header)
 	assert.NotNil(t, conn)
 	assert.Nil(t, err)
+	assert.Equal(t, strconv.Itoa(config.ProtocolVersion), resp.Header.Get(config.ClusterConnectHeaderProtocol))
+	skew, err := config.ClockSkew(resp.Header.Get(config.ClusterConnectHeaderTime), time.Now(), time.Now())
+	assert.Nil(t, err)
+	assert.InDelta(t, 0, skew, 1000)
+	cr := <-registries
+	assert.InDelta(t, 60000, cr.ClockSkew, 1000)
+	// the registry and the parent responded are after the hybrid time of the child.
+	assert.True(t, childHLC.Before(*cr.HLC))
+	parentHLC, err := otev1.ParseHybridTime(resp.Header.Get(config.ClusterConnectHeaderHLC))
+	assert.Nil(t, err)
+	assert.Equal(t, *cr.HLC, parentHLC)
+	// children offering no encodings use json.
+	assert.Equal(t, reporter.EncodingJSON, resp.Header.Get(config.ClusterConnectHeaderEncoding))
 
 	time.Sleep(time.Second * time.Duration(1))
 
@@ -175,3 +252,137 @@ func TestHandleReceieveMsg(t *testing.T) {
 
 	ct.handleReceiveMessage(ws)
 }
+
+func TestDispatchReceivedMessage(t *testing.T) {
+	ct := NewCloudTunnel("").(*cloudTunnel)
+	ct.receiveWorkers = make(chan struct{}, 2)
+
+	block := make(chan struct{})
+	mutex := sync.Mutex{}
+	received := map[string][]string{}
+	ct.receiveMessageHandler = func(client string, msg []byte) error {
+		if client == "slow" {
+			<-block
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		received[client] = append(received[client], string(msg))
+		return nil
+	}
+
+	slow := make(chan []byte, 10)
+	fast := make(chan []byte, 10)
+	go ct.dispatchReceivedMessage("slow", slow)
+	go ct.dispatchReceivedMessage("fast", fast)
+	for i := 0; i < 5; i++ {
+		slow <- []byte(fmt.Sprintf("%d", i))
+		fast <- []byte(fmt.Sprintf("%d", i))
+	}
+
+	// the slow client takes only one worker, messages of the other are handled.
+	for i := 0; i < 100; i++ {
+		mutex.Lock()
+		n := len(received["fast"])
+		mutex.Unlock()
+		if n == 5 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(block)
+	close(slow)
+	close(fast)
+	for i := 0; i < 100; i++ {
+		mutex.Lock()
+		n := len(received["slow"])
+		mutex.Unlock()
+		if n == 5 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	expect := []string{"0", "1", "2", "3", "4"}
+	assert.Equal(t, expect, received["fast"])
+	assert.Equal(t, expect, received["slow"])
+}
+
+func TestUpdateUpstreamEncoding(t *testing.T) {
+	defer reporter.SetUpstreamEncoding("")
+	ct := NewCloudTunnel("").(*cloudTunnel)
+	ct.updateUpstreamEncoding()
+	assert.Equal(t, reporter.EncodingJSON, reporter.UpstreamEncoding())
+
+	ct.controllerEncodings.Store("cm1", reporter.EncodingCBOR)
+	ct.updateUpstreamEncoding()
+	assert.Equal(t, reporter.EncodingCBOR, reporter.UpstreamEncoding())
+	ct.controllerEncodings.Store("cm2", reporter.EncodingCBOR)
+	ct.updateUpstreamEncoding()
+	assert.Equal(t, reporter.EncodingCBOR, reporter.UpstreamEncoding())
+
+	// json is used if controller managers negotiated different encodings.
+	ct.controllerEncodings.Store("cm3", reporter.EncodingProtobuf)
+	ct.updateUpstreamEncoding()
+	assert.Equal(t, reporter.EncodingJSON, reporter.UpstreamEncoding())
+}
+
+func TestAccessHandlerCapabilities(t *testing.T) {
+	registered := make(chan *config.ClusterRegistry, 1)
+	ct := NewCloudTunnel("127.0.0.1:0").(*cloudTunnel)
+	ct.RegistAfterConnectHook(func(cr *config.ClusterRegistry) {
+		registered <- cr
+	})
+	assert.Nil(t, ct.Start())
+
+	u := fmt.Sprintf("ws://%s%s%s", ct.server.Addr, accessURI, "c1")
+	header := http.Header{}
+	header.Add(config.ClusterConnectHeaderListenAddr, "fake")
+	header.Add(config.ClusterConnectHeaderUserDefineName, "c1")
+	header.Add(config.ClusterConnectHeaderCapabilities, `{"destinations":["api","exec"]}`)
+	header.Add(config.ClusterConnectHeaderPreviousName, "c0")
+	conn, _, err := websocket.DefaultDialer.Dial(u, header)
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	select {
+	case cr := <-registered:
+		assert.Equal(t, []string{"api", "exec"}, cr.Capabilities.Destinations)
+		assert.Equal(t, "c0", cr.PreviousName)
+	case <-time.After(5 * time.Second):
+		t.Fatal("cluster is not connected")
+	}
+}
+
+func TestTunnelIPv6(t *testing.T) {
+	if ln, err := net.Listen("tcp", "[::1]:0"); err != nil {
+		t.Skipf("ipv6 is unavailable: %v", err)
+	} else {
+		ln.Close()
+	}
+	registered := make(chan *config.ClusterRegistry, 2)
+	ct := NewCloudTunnel("[::1]:0").(*cloudTunnel)
+	ct.RegistAfterConnectHook(func(cr *config.ClusterRegistry) {
+		registered <- cr
+	})
+	assert.Nil(t, ct.Start())
+	assert.Nil(t, config.ValidateAddress(ct.server.Addr))
+
+	for _, e := range []*edgeTunnel{
+		newTestEdgeTunnel(),
+		newTestGRPCEdgeTunnel("c6", ct.server.Addr),
+	} {
+		e.cloudAddr = ct.server.Addr
+		e.listenAddr = "[::]:8287"
+		assert.Nil(t, e.connect(), e.protocol)
+		select {
+		case cr := <-registered:
+			// childs listening on all addresses are reached by the address connecting from.
+			assert.Equal(t, "[::1]:8287", cr.Listen)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("cluster is not connected by %s", e.protocol)
+		}
+		e.wsclient.Close()
+	}
+}
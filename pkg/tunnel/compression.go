@@ -0,0 +1,177 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+const (
+	// CompressionNone sends messages as they are, used with peers not offering compressions.
+	CompressionNone = "none"
+	// CompressionGzip compresses messages by gzip.
+	CompressionGzip = "gzip"
+	// CompressionDeflateDict compresses messages by deflate with dictionaries trained by the parent.
+	CompressionDeflateDict = "deflate-dict"
+)
+
+// Flags of frames on connections with a compression negotiated, which prefix each message.
+const (
+	frameRaw        byte = 0
+	frameCompressed byte = 1
+	// frameDictionary carries a dictionary from the parent, which is not handed over to handlers.
+	frameDictionary byte = 2
+)
+
+// CompressMinSize is the min size of messages to compress,
+// smaller ones are sent raw since compression hardly saves anything.
+var CompressMinSize = 1024
+
+// compressor compresses and decompresses messages.
+type compressor interface {
+	compress(msg []byte) ([]byte, error)
+	decompress(data []byte) ([]byte, error)
+	// minSize returns the min size of messages to compress.
+	minSize() int
+}
+
+var (
+	gzipShared = &gzipCompressor{}
+	// compressors return the compressor of a connection by compression name.
+	compressors = map[string]func() compressor{
+		CompressionGzip:        func() compressor { return gzipShared },
+		CompressionDeflateDict: func() compressor { return newDictCompressor() },
+	}
+
+	compressionsLock sync.RWMutex
+	// compressions are the compressions accepted from childs and offered to parent in preference order.
+	compressions []string
+)
+
+// SetCompressions sets the compressions of tunnel messages accepted from childs and
+// offered to parent in preference order, messages are not compressed if names is empty.
+func SetCompressions(names []string) error {
+	accepted := make([]string, 0, len(names))
+	for _, name := range names {
+		if name == CompressionNone {
+			continue
+		}
+		if _, ok := compressors[name]; !ok {
+			return fmt.Errorf("tunnel compression %s is not supported", name)
+		}
+		accepted = append(accepted, name)
+	}
+	compressionsLock.Lock()
+	defer compressionsLock.Unlock()
+	compressions = accepted
+	return nil
+}
+
+// Compressions returns the compressions accepted separated by comma.
+func Compressions() string {
+	compressionsLock.RLock()
+	defer compressionsLock.RUnlock()
+	return strings.Join(compressions, ",")
+}
+
+// NegotiateCompression returns the compression used with a peer offering compressions
+// separated by comma in preference order, which is the first one accepted.
+// Peers offering none send messages uncompressed.
+func NegotiateCompression(offered string) string {
+	compressionsLock.RLock()
+	defer compressionsLock.RUnlock()
+	for _, name := range strings.Split(offered, ",") {
+		name = strings.TrimSpace(name)
+		for _, accepted := range compressions {
+			if name == accepted {
+				return name
+			}
+		}
+	}
+	return CompressionNone
+}
+
+// encodeFrame returns msg framed by flag, compressed by c if it is large enough and gets smaller.
+func encodeFrame(c compressor, msg []byte) ([]byte, error) {
+	if len(msg) >= c.minSize() {
+		compressed, err := c.compress(msg)
+		if err != nil {
+			return nil, err
+		}
+		if len(compressed) < len(msg) {
+			return append([]byte{frameCompressed}, compressed...), nil
+		}
+	}
+	return append([]byte{frameRaw}, msg...), nil
+}
+
+// decodeFrame returns the message of frame, decompressed by c if it is compressed.
+// The message returned does not share memory with frame.
+func decodeFrame(c compressor, frame []byte) ([]byte, error) {
+	if len(frame) == 0 {
+		return nil, fmt.Errorf("empty frame")
+	}
+	switch frame[0] {
+	case frameRaw:
+		return append([]byte(nil), frame[1:]...), nil
+	case frameCompressed:
+		return c.decompress(frame[1:])
+	default:
+		return nil, fmt.Errorf("unknown frame flag %d", frame[0])
+	}
+}
+
+// gzipCompressor compresses messages by gzip with writers pooled.
+type gzipCompressor struct {
+	writers sync.Pool
+}
+
+func (g *gzipCompressor) compress(msg []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w, ok := g.writers.Get().(*gzip.Writer)
+	if ok {
+		w.Reset(buf)
+	} else {
+		w = gzip.NewWriter(buf)
+	}
+	defer g.writers.Put(w)
+	if _, err := w.Write(msg); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (g *gzipCompressor) minSize() int {
+	return CompressMinSize
+}
+
+func (g *gzipCompressor) decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
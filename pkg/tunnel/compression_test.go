@@ -0,0 +1,127 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/config"
+)
+
+func TestSetCompressions(t *testing.T) {
+	defer SetCompressions(nil)
+	assert.NotNil(t, SetCompressions([]string{"lz4"}))
+	assert.Equal(t, "", Compressions())
+	assert.Equal(t, CompressionNone, NegotiateCompression(CompressionGzip))
+
+	assert.Nil(t, SetCompressions([]string{CompressionNone, CompressionGzip}))
+	assert.Equal(t, CompressionGzip, Compressions())
+	assert.Equal(t, CompressionGzip, NegotiateCompression("lz4, gzip"))
+	assert.Equal(t, CompressionNone, NegotiateCompression(""))
+}
+
+func TestFrame(t *testing.T) {
+	c := compressors[CompressionGzip]()
+	large := bytes.Repeat([]byte("pod"), CompressMinSize)
+	for _, msg := range [][]byte{[]byte("small"), large} {
+		frame, err := encodeFrame(c, msg)
+		assert.Nil(t, err)
+		decoded, err := decodeFrame(c, frame)
+		assert.Nil(t, err)
+		assert.Equal(t, msg, decoded)
+	}
+
+	frame, err := encodeFrame(c, large)
+	assert.Nil(t, err)
+	assert.Equal(t, frameCompressed, frame[0])
+	assert.True(t, len(frame) < len(large)/10)
+	frame, err = encodeFrame(c, []byte("small"))
+	assert.Nil(t, err)
+	assert.Equal(t, append([]byte{frameRaw}, "small"...), frame)
+
+	_, err = decodeFrame(c, nil)
+	assert.NotNil(t, err)
+	_, err = decodeFrame(c, []byte{9, 1})
+	assert.NotNil(t, err)
+	_, err = decodeFrame(c, []byte{frameCompressed, 1})
+	assert.NotNil(t, err)
+}
+
+func TestCompressedWSClient(t *testing.T) {
+	client := newTestWSClient()
+	assert.NotNil(t, client)
+	defer client.Close()
+	client.setCompression(CompressionGzip)
+
+	// the echo server sends frames back as they are.
+	large := bytes.Repeat([]byte("deployment"), CompressMinSize)
+	for _, msg := range [][]byte{[]byte("small"), large} {
+		assert.Nil(t, client.WriteMessage(msg))
+		got, err := client.ReadMessage()
+		assert.Nil(t, err)
+		assert.Equal(t, msg, got)
+	}
+}
+
+func TestCompressionNegotiated(t *testing.T) {
+	assert.Nil(t, SetCompressions([]string{CompressionGzip}))
+	defer SetCompressions(nil)
+
+	received := make(chan []byte, 1)
+	ct := NewCloudTunnel("127.0.0.1:0").(*cloudTunnel)
+	ct.RegistReturnMessageFunc(func(client string, msg []byte) error {
+		received <- msg
+		return nil
+	})
+	assert.Nil(t, ct.Start())
+
+	e := &edgeTunnel{
+		name:               "c1",
+		cloudAddr:          ct.server.Addr,
+		listenAddr:         ":8287",
+		conf:               &config.ClusterControllerConfig{},
+		afterConnectToHook: func() {},
+	}
+	assert.Nil(t, e.connect())
+	defer e.wsclient.Close()
+	assert.NotNil(t, e.wsclient.compressor)
+
+	large := bytes.Repeat([]byte("node"), CompressMinSize)
+	assert.Nil(t, e.Send(large))
+	select {
+	case msg := <-received:
+		assert.Equal(t, large, msg)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received by cloudtunnel")
+	}
+
+	// the child is stored after connected.
+	for i := 0; i < 50; i++ {
+		if _, ok := ct.clients.Load("c1"); ok {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Nil(t, ct.Send("c1", large))
+	msg, err := e.wsclient.ReadMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, large, msg)
+}
@@ -23,11 +23,11 @@ import (
 	"sync"
 	"time"
 
-	proto "github.com/golang/protobuf/proto"
-	"github.com/gorilla/websocket"
 	"k8s.io/klog"
 
 	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/config"
+	"github.com/baidu/ote-stack/pkg/reporter"
 )
 
 var (
@@ -81,12 +81,13 @@ func NewControllerTunnel(remoteAddr string) ControllerTunnel {
 }
 
 func (e *controllerTunnel) connect() error {
-	u := url.URL{Scheme: "ws", Host: e.cloudAddr, Path: controllerURI}
+	scheme, dialer := tunnelDialer()
+	u := url.URL{Scheme: scheme, Host: dialHost(e.cloudAddr, dialer), Path: controllerURI}
 	header := http.Header{}
+	header.Add(config.ClusterConnectHeaderEncodings, reporter.Encodings())
 
 	klog.Infof("connecting to cloudtunnel %s", u.String())
-	// TODO https connection.
-	conn, resp, err := websocket.DefaultDialer.Dial(u.String(), header)
+	conn, resp, err := dialer.Dial(u.String(), header)
 	if err != nil {
 		if resp != nil {
 			if resp.StatusCode == http.StatusFound {
@@ -107,6 +108,7 @@ func (e *controllerTunnel) connect() error {
 
 	// TODO gradeful new wsclient.
 	e.wsclient = NewWSClient(e.cloudAddr, conn)
+	e.wsclient.track(RoleRoot, e.cloudAddr, RoleRoot+"/"+e.cloudAddr)
 
 	go e.afterConnectToHook()
 
@@ -199,18 +201,16 @@ func (e *controllerTunnel) sendFromChan() {
 	var msg clustermessage.ClusterMessage
 	for {
 		msg = <-e.sendChan
-		data, err := proto.Marshal(&msg)
-		if err != nil {
-			klog.Errorf("serialize cluster message(%v) failed: %v", msg, err)
-			continue
-		}
-		err = e.Send(data)
-		if err == nil {
-			continue
-		}
-		klog.Errorf("send msg failed: %v", err)
-		e.connectionHealth = false
-		err = e.recoverAndReSend(data)
+		// data is sent synchronously, so it is safe to be in a pooled buffer.
+		err := msg.SerializeTo(func(data []byte) error {
+			err := e.Send(data)
+			if err == nil {
+				return nil
+			}
+			klog.Errorf("send msg failed: %v", err)
+			e.connectionHealth = false
+			return e.recoverAndReSend(data)
+		})
 		if err != nil {
 			klog.Errorf("%v", err)
 		}
@@ -0,0 +1,262 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"k8s.io/klog"
+)
+
+const (
+	// maxDictionarySize is the max size of dictionaries, which is the window of deflate.
+	maxDictionarySize = 32 << 10
+	// maxDictionaries is the number of latest dictionaries kept to decompress messages,
+	// since messages compressed by an older one may be in flight while a new one is distributed.
+	maxDictionaries = 4
+)
+
+var (
+	// DictCompressMinSize is the min size of messages compressed with dictionaries,
+	// which is smaller than CompressMinSize since small deltas get smaller by dictionaries.
+	DictCompressMinSize = 64
+	// DictTrainSamples is the number of recent messages a dictionary is trained from.
+	DictTrainSamples = 128
+	// DictSampleMaxSize is the max size of messages sampled, larger ones compress well without dictionaries.
+	DictSampleMaxSize = 16 << 10
+	// DictRetrainMessages is the number of messages sampled after which the dictionary is trained again.
+	DictRetrainMessages = 4096
+)
+
+// dictionary is a preset dictionary of deflate with writers pooled.
+type dictionary struct {
+	id      uint32
+	data    []byte
+	writers sync.Pool
+}
+
+/*
+dictCompressor compresses messages of a connection by deflate with preset dictionaries.
+The parent trains dictionaries from messages received from the child, and sends them by
+dictionary frames. Both sides compress messages with the latest dictionary after it is
+sent or received, and each compressed message tells the id of its dictionary.
+*/
+type dictCompressor struct {
+	lock sync.RWMutex
+	// dicts are the latest dictionaries by id, current is the one to compress with, of id 0 if none yet.
+	dicts   map[uint32]*dictionary
+	current *dictionary
+	// trainer trains dictionaries, nil on the child.
+	trainer *dictTrainer
+}
+
+func newDictCompressor() *dictCompressor {
+	return &dictCompressor{
+		dicts:   make(map[uint32]*dictionary),
+		current: &dictionary{},
+	}
+}
+
+func (d *dictCompressor) minSize() int {
+	return DictCompressMinSize
+}
+
+// compress returns the id of the dictionary used followed by msg compressed, id 0 means no dictionary.
+func (d *dictCompressor) compress(msg []byte) ([]byte, error) {
+	d.lock.RLock()
+	dict := d.current
+	d.lock.RUnlock()
+
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, dict.id)
+	w, ok := dict.writers.Get().(*flate.Writer)
+	if ok {
+		w.Reset(buf)
+	} else {
+		var err error
+		if w, err = flate.NewWriterDict(buf, flate.DefaultCompression, dict.data); err != nil {
+			return nil, err
+		}
+	}
+	defer dict.writers.Put(w)
+	if _, err := w.Write(msg); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (d *dictCompressor) decompress(data []byte) ([]byte, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("dictionary id is missing")
+	}
+	id := binary.BigEndian.Uint32(data)
+	var dict []byte
+	if id != 0 {
+		d.lock.RLock()
+		found, ok := d.dicts[id]
+		d.lock.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("dictionary %d is unknown", id)
+		}
+		dict = found.data
+	}
+	r := flate.NewReaderDict(bytes.NewReader(data[4:]), dict)
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// use adds dictionary data of id and compresses messages with it from now on.
+func (d *dictCompressor) use(id uint32, data []byte) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	dict := &dictionary{id: id, data: data}
+	d.dicts[id] = dict
+	d.current = dict
+	for old := range d.dicts {
+		if len(d.dicts) <= maxDictionaries {
+			break
+		}
+		// ids are increasing, so those not in the latest ones are dropped.
+		if old+maxDictionaries <= id {
+			delete(d.dicts, old)
+		}
+	}
+}
+
+// receive uses the dictionary in payload of a dictionary frame.
+func (d *dictCompressor) receive(payload []byte) error {
+	if len(payload) < 4 {
+		return fmt.Errorf("dictionary id is missing")
+	}
+	id := binary.BigEndian.Uint32(payload)
+	if id == 0 || len(payload)-4 > maxDictionarySize {
+		return fmt.Errorf("invalid dictionary %d of %d bytes", id, len(payload)-4)
+	}
+	d.use(id, append([]byte(nil), payload[4:]...))
+	klog.Infof("use tunnel compression dictionary %d of %d bytes", id, len(payload)-4)
+	return nil
+}
+
+// dictTrainer samples messages received and trains dictionaries from them.
+type dictTrainer struct {
+	lock    sync.Mutex
+	samples [][]byte
+	next    int
+	// sampled is the number of messages sampled since the last dictionary trained.
+	sampled int
+	trained uint32
+}
+
+/*
+sample adds msg to samples, and returns the id and data of a new dictionary if it is time to train.
+The first dictionary is trained once DictTrainSamples messages are sampled,
+and a new one every DictRetrainMessages messages after that, so it follows changes of reports.
+*/
+func (t *dictTrainer) sample(msg []byte) (uint32, []byte) {
+	if len(msg) < DictCompressMinSize || len(msg) > DictSampleMaxSize {
+		return 0, nil
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	sample := append([]byte(nil), msg...)
+	if len(t.samples) < DictTrainSamples {
+		t.samples = append(t.samples, sample)
+	} else {
+		t.samples[t.next] = sample
+	}
+	t.next = (t.next + 1) % DictTrainSamples
+	t.sampled++
+	if len(t.samples) < DictTrainSamples {
+		return 0, nil
+	}
+	if t.trained != 0 && t.sampled < DictRetrainMessages {
+		return 0, nil
+	}
+	t.sampled = 0
+	t.trained++
+	return t.trained, t.train()
+}
+
+/*
+train returns a dictionary of recent samples. Deflate prefers matches at the end of the
+dictionary, so samples are appended from the oldest to the latest, and duplicates are kept
+once, until the dictionary is full. Call with lock held.
+*/
+func (t *dictTrainer) train() []byte {
+	seen := make(map[string]bool, len(t.samples))
+	var picked [][]byte
+	size := 0
+	// from the latest to the oldest.
+	for i := 0; i < len(t.samples) && size < maxDictionarySize; i++ {
+		sample := t.samples[(t.next-1-i+2*len(t.samples))%len(t.samples)]
+		if seen[string(sample)] {
+			continue
+		}
+		seen[string(sample)] = true
+		picked = append(picked, sample)
+		size += len(sample)
+	}
+	dict := make([]byte, 0, size)
+	for i := len(picked) - 1; i >= 0; i-- {
+		dict = append(dict, picked[i]...)
+	}
+	if len(dict) > maxDictionarySize {
+		dict = dict[len(dict)-maxDictionarySize:]
+	}
+	return dict
+}
+
+// trainDictionaries makes c train dictionaries from messages read, if compressed with dictionaries.
+func (c *WSClient) trainDictionaries() {
+	if dict, ok := c.compressor.(*dictCompressor); ok {
+		dict.trainer = &dictTrainer{}
+	}
+}
+
+// trainDictionary samples msg read, and sends a new dictionary to the peer if one is trained.
+func (c *WSClient) trainDictionary(msg []byte) {
+	dict, ok := c.compressor.(*dictCompressor)
+	if !ok || dict.trainer == nil {
+		return
+	}
+	id, data := dict.trainer.sample(msg)
+	if data == nil {
+		return
+	}
+	frame := make([]byte, 5, 5+len(data))
+	frame[0] = frameDictionary
+	binary.BigEndian.PutUint32(frame[1:], id)
+	frame = append(frame, data...)
+
+	// the dictionary is used after sent, so that the peer gets it before messages compressed by it.
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.conn.writeMessage(c.mux.frame(ChannelControl, frame)); err != nil {
+		klog.Errorf("wsclient %s send dictionary failed: %s", c.Name, err.Error())
+		return
+	}
+	dict.use(id, data)
+	klog.Infof("wsclient %s trained tunnel compression dictionary %d of %d bytes", c.Name, id, len(data))
+}
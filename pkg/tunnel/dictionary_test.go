@@ -0,0 +1,159 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/config"
+)
+
+func testReport(i int) []byte {
+	return []byte(fmt.Sprintf(`{"kind":"Pod","apiVersion":"v1","metadata":{"name":"nginx-%d",`+
+		`"namespace":"default","labels":{"app":"nginx"}},"status":{"phase":"Running","podIP":"10.0.0.%d"}}`, i, i))
+}
+
+func TestDictCompressor(t *testing.T) {
+	parent := newDictCompressor()
+	child := newDictCompressor()
+	msg := testReport(1)
+
+	// compressed without dictionary before one is received.
+	compressed, err := child.compress(msg)
+	assert.Nil(t, err)
+	withoutDict := len(compressed)
+	got, err := parent.decompress(compressed)
+	assert.Nil(t, err)
+	assert.Equal(t, msg, got)
+
+	dict := bytes.Join([][]byte{testReport(2), testReport(3)}, nil)
+	parent.use(1, dict)
+	payload := append([]byte{0, 0, 0, 1}, dict...)
+	assert.Nil(t, child.receive(payload))
+	compressed, err = child.compress(msg)
+	assert.Nil(t, err)
+	assert.True(t, len(compressed) < withoutDict/2)
+	got, err = parent.decompress(compressed)
+	assert.Nil(t, err)
+	assert.Equal(t, msg, got)
+
+	// messages of dictionaries unknown or dropped are not decompressed.
+	_, err = newDictCompressor().decompress(compressed)
+	assert.NotNil(t, err)
+	for id := uint32(2); id < 2+maxDictionaries; id++ {
+		parent.use(id, dict)
+	}
+	assert.Len(t, parent.dicts, maxDictionaries)
+	_, err = parent.decompress(compressed)
+	assert.NotNil(t, err)
+
+	assert.NotNil(t, child.receive([]byte{0, 0}))
+	assert.NotNil(t, child.receive([]byte{0, 0, 0, 0}))
+	_, err = parent.decompress([]byte{0})
+	assert.NotNil(t, err)
+}
+
+func TestDictTrainer(t *testing.T) {
+	defer func(samples, retrain int) {
+		DictTrainSamples, DictRetrainMessages = samples, retrain
+	}(DictTrainSamples, DictRetrainMessages)
+	DictTrainSamples, DictRetrainMessages = 3, 4
+
+	trainer := &dictTrainer{}
+	// too small to sample.
+	id, dict := trainer.sample([]byte("small"))
+	assert.Nil(t, dict)
+	for i := 0; i < 2; i++ {
+		id, dict = trainer.sample(testReport(i))
+		assert.Nil(t, dict)
+	}
+	id, dict = trainer.sample(testReport(0))
+	assert.Equal(t, uint32(1), id)
+	// duplicates are kept once, the latest sample at the end.
+	assert.Equal(t, bytes.Join([][]byte{testReport(1), testReport(0)}, nil), dict)
+
+	for i := 0; i < 3; i++ {
+		_, dict = trainer.sample(testReport(i))
+		assert.Nil(t, dict)
+	}
+	id, dict = trainer.sample(testReport(9))
+	assert.Equal(t, uint32(2), id)
+	assert.Equal(t, bytes.Join([][]byte{testReport(1), testReport(2), testReport(9)}, nil), dict)
+}
+
+func TestDictionaryNegotiated(t *testing.T) {
+	defer func(samples int) { DictTrainSamples = samples }(DictTrainSamples)
+	DictTrainSamples = 2
+	assert.Nil(t, SetCompressions([]string{CompressionDeflateDict}))
+	defer SetCompressions(nil)
+
+	received := make(chan []byte, 10)
+	ct := NewCloudTunnel("127.0.0.1:0").(*cloudTunnel)
+	ct.RegistReturnMessageFunc(func(client string, msg []byte) error {
+		received <- msg
+		return nil
+	})
+	assert.Nil(t, ct.Start())
+
+	e := &edgeTunnel{
+		name:               "c1",
+		cloudAddr:          ct.server.Addr,
+		listenAddr:         ":8287",
+		conf:               &config.ClusterControllerConfig{},
+		afterConnectToHook: func() {},
+	}
+	assert.Nil(t, e.connect())
+	defer e.wsclient.Close()
+
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, e.Send(testReport(i)))
+		select {
+		case msg := <-received:
+			assert.Equal(t, testReport(i), msg)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no message received by cloudtunnel")
+		}
+	}
+
+	// the dictionary trained is sent before messages of the parent.
+	for i := 0; i < 50; i++ {
+		if _, ok := ct.clients.Load("c1"); ok {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Nil(t, ct.Send("c1", testReport(3)))
+	msg, err := e.wsclient.ReadMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, testReport(3), msg)
+	dict := e.wsclient.compressor.(*dictCompressor)
+	assert.Equal(t, uint32(1), dict.current.id)
+
+	// the child compresses with the dictionary.
+	assert.Nil(t, e.Send(testReport(4)))
+	select {
+	case msg := <-received:
+		assert.Equal(t, testReport(4), msg)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received by cloudtunnel")
+	}
+}
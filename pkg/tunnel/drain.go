@@ -0,0 +1,133 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tunnel
+
+import (
+	"fmt"
+	"regexp"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+// DrainMessagePrefix is the prefix of id of Redirect messages sent to childs by draining.
+const DrainMessagePrefix = "drain-"
+
+// drainPollInterval is the interval to check whether draining is done.
+var drainPollInterval = 100 * time.Millisecond
+
+/*
+Drain stops cloudtunnel gracefully, e.g. for a rolling upgrade of the cluster controller.
+Childs connecting after that are redirected to alternate, or refused if it is empty.
+Each child connected is moved once messages being sent to it are written:
+it is sent a Redirect message to alternate, and holds responses of tasks in flight
+until connected to alternate; or its connection is closed normally if alternate is empty,
+and it reconnects to a parent neighbor by its reconnect policy.
+cloudtunnel is stopped once all childs are disconnected and messages read from them are handled,
+or timeout passed, when childs still connected are closed.
+*/
+func (t *cloudTunnel) Drain(alternate string, timeout time.Duration) error {
+	t.drainLock.Lock()
+	if t.draining {
+		t.drainLock.Unlock()
+		return fmt.Errorf("cloud tunnel is already draining")
+	}
+	t.draining = true
+	t.drainAlternate = alternate
+	t.drainLock.Unlock()
+	if t.server == nil {
+		// cloudtunnel is not started, e.g. by a standby root.
+		return nil
+	}
+
+	klog.Infof("drain cloud tunnel in %v, alternate parent is %q", timeout, alternate)
+	deadline := time.Now().Add(timeout)
+	t.clients.Range(func(k, v interface{}) bool {
+		go t.drainChild(k.(string), v.(*WSClient), alternate, deadline)
+		return true
+	})
+	for !t.drained() && time.Now().Before(deadline) {
+		time.Sleep(drainPollInterval)
+	}
+	t.clients.Range(func(k, v interface{}) bool {
+		klog.Warningf("cluster %s is still connected after draining, close it", k.(string))
+		v.(*WSClient).Close()
+		return true
+	})
+	if n := atomic.LoadInt64(&t.unhandled); n > 0 {
+		klog.Warningf("%d messages from childs are not handled after draining", n)
+	}
+	klog.Infof("cloud tunnel is drained")
+	return t.Stop()
+}
+
+// drainingTo returns whether cloudtunnel is draining, and the alternate parent of childs.
+func (t *cloudTunnel) drainingTo() (bool, string) {
+	t.drainLock.RLock()
+	defer t.drainLock.RUnlock()
+	return t.draining, t.drainAlternate
+}
+
+// drained returns true if no child is connected and messages read from childs are handled.
+func (t *cloudTunnel) drained() bool {
+	connected := false
+	t.clients.Range(func(k, v interface{}) bool {
+		connected = true
+		return false
+	})
+	return !connected && atomic.LoadInt64(&t.unhandled) <= 0
+}
+
+// drainChild moves the child of cluster connected by wsclient to alternate before deadline.
+func (t *cloudTunnel) drainChild(cluster string, wsclient *WSClient, alternate string, deadline time.Time) {
+	// messages being sent are written before the child moves.
+	for !wsclient.queue.idle() && time.Now().Before(deadline) {
+		time.Sleep(drainPollInterval)
+	}
+	if alternate != "" {
+		msg, err := drainMessage(cluster, alternate)
+		if err == nil {
+			err = wsclient.WriteMessage(msg)
+		}
+		if err == nil {
+			klog.Infof("redirect cluster %s to %s for draining", cluster, alternate)
+			return
+		}
+		klog.Errorf("redirect cluster %s to %s for draining failed: %v", cluster, alternate, err)
+	}
+	klog.Infof("close cluster %s for draining", cluster)
+	wsclient.conn.closeNormally("draining")
+	wsclient.Close()
+}
+
+// drainMessage returns the Redirect message asking the child of cluster to reconnect to alternate.
+func drainMessage(cluster, alternate string) ([]byte, error) {
+	task := &clustermessage.RedirectTask{Address: alternate}
+	msg, err := task.ToClusterMessage(&clustermessage.MessageHead{
+		MessageID:       DrainMessagePrefix + string(uuid.NewUUID()),
+		Command:         clustermessage.CommandType_Redirect,
+		ClusterSelector: "^" + regexp.QuoteMeta(cluster) + "$",
+	})
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(msg)
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tunnel
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/clusterselector"
+	"github.com/baidu/ote-stack/pkg/config"
+)
+
+// startDrainTest starts a cloud tunnel and connects child c1 to it.
+func startDrainTest(t *testing.T) (*cloudTunnel, *websocket.Conn) {
+	connected := make(chan struct{}, 1)
+	ct := NewCloudTunnel("127.0.0.1:0").(*cloudTunnel)
+	ct.RegistAfterConnectHook(func(cr *config.ClusterRegistry) {
+		connected <- struct{}{}
+	})
+	assert.Nil(t, ct.Start())
+	conn, resp, err := dialDrainTest(ct, "c1")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	select {
+	case <-connected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("cluster is not connected")
+	}
+	return ct, conn
+}
+
+func dialDrainTest(ct *cloudTunnel, cluster string) (*websocket.Conn, *http.Response, error) {
+	u := fmt.Sprintf("ws://%s%s%s", ct.server.Addr, accessURI, cluster)
+	header := http.Header{}
+	header.Add(config.ClusterConnectHeaderListenAddr, "fake")
+	header.Add(config.ClusterConnectHeaderUserDefineName, cluster)
+	dialer := *websocket.DefaultDialer
+	return dialer.Dial(u, header)
+}
+
+func drainAsync(ct *cloudTunnel, alternate string, timeout time.Duration) chan error {
+	done := make(chan error, 1)
+	go func() { done <- ct.Drain(alternate, timeout) }()
+	return done
+}
+
+func TestDrainRedirect(t *testing.T) {
+	drainPollInterval = 10 * time.Millisecond
+	ct, conn := startDrainTest(t)
+	defer conn.Close()
+	done := drainAsync(ct, "127.0.0.1:9999", 5*time.Second)
+
+	// the child is asked to move to the alternate parent.
+	_, data, err := conn.ReadMessage()
+	assert.Nil(t, err)
+	msg := &clustermessage.ClusterMessage{}
+	assert.Nil(t, proto.Unmarshal(data, msg))
+	assert.Equal(t, clustermessage.CommandType_Redirect, msg.Head.Command)
+	assert.Contains(t, msg.Head.MessageID, DrainMessagePrefix)
+	selector := clusterselector.NewSelector(msg.Head.ClusterSelector)
+	assert.True(t, selector.Has("c1"))
+	assert.False(t, selector.Has("c10"))
+	task := &clustermessage.RedirectTask{}
+	assert.Nil(t, proto.Unmarshal(msg.Body, task))
+	assert.Equal(t, "127.0.0.1:9999", task.Address)
+
+	// childs connecting while draining are redirected to the alternate parent.
+	_, resp, err := dialDrainTest(ct, "c2")
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Location"), "127.0.0.1:9999")
+
+	// draining is done once the child is moved.
+	conn.Close()
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("draining is not done after childs moved")
+	}
+	assert.NotNil(t, ct.Drain("", time.Second))
+}
+
+func TestDrainWithoutAlternate(t *testing.T) {
+	drainPollInterval = 10 * time.Millisecond
+	ct, conn := startDrainTest(t)
+	defer conn.Close()
+	done := drainAsync(ct, "", 5*time.Second)
+
+	// the connection of the child is closed normally.
+	_, _, err := conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure))
+
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("draining is not done after childs closed")
+	}
+}
+
+func TestDrainRefused(t *testing.T) {
+	ct := NewCloudTunnel("127.0.0.1:0").(*cloudTunnel)
+	ct.draining = true
+	_, _, redirectAddr, err := ct.admit("c1", "127.0.0.1:1234", http.Header{}, nil)
+	assert.Equal(t, "", redirectAddr)
+	assert.Equal(t, http.StatusServiceUnavailable, err.(*admitError).code)
+}
+
+func TestDrainTimeout(t *testing.T) {
+	drainPollInterval = 10 * time.Millisecond
+	ct, conn := startDrainTest(t)
+	defer conn.Close()
+
+	// the child not moving is closed after timeout.
+	start := time.Now()
+	assert.Nil(t, ct.Drain("127.0.0.1:9999", 200*time.Millisecond))
+	assert.True(t, time.Since(start) >= 200*time.Millisecond)
+	_, _, err := conn.ReadMessage()
+	assert.Nil(t, err)
+	_, _, err = conn.ReadMessage()
+	assert.NotNil(t, err)
+}
+
+func TestDrainNotStarted(t *testing.T) {
+	ct := NewCloudTunnel("127.0.0.1:0").(*cloudTunnel)
+	assert.Nil(t, ct.Drain("", time.Second))
+}
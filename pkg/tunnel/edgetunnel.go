@@ -21,116 +21,382 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"k8s.io/klog"
 
+	"github.com/baidu/ote-stack/pkg/capability"
 	clusterrouter "github.com/baidu/ote-stack/pkg/clusterrouter"
 	"github.com/baidu/ote-stack/pkg/config"
+	"github.com/baidu/ote-stack/pkg/reporter"
 )
 
 var (
 	waitConnection   = 1
 	blacklistSeconds = 10
+	// maxRedirectPending is the max number of messages held while redirecting to another parent.
+	maxRedirectPending = 1000
 )
 
 // EdgeTunnel is a iterface for edgeTunnel.
 type EdgeTunnel interface {
 	// Start will start edgeTunnel.
 	Start() error
+	// StartConnecting starts edgeTunnel as Start, but keeps connecting in background
+	// instead of failing if no parent is reachable.
+	StartConnecting()
 	// Stop will close the connection of edgeTunnel.
 	Stop() error
 	// Send sends binary message to websocket connection.
 	Send(msg []byte) error
+	// Redirect reconnects to the parent at addr,
+	// messages sent before connected are held and sent after that.
+	Redirect(addr string) error
 	// Regist registers receive message handler.
 	RegistReceiveMessageHandler(TunnelReadMessageFunc)
 	RegistAfterConnectToHook(fn AfterConnectToHook)
 	RegistAfterDisconnectHook(fn AfterDisconnectHook)
+	RegistMaxRetriesHook(fn MaxRetriesHook)
+	// StatsProvider provides stats of the connection to parent.
+	StatsProvider
 }
 
 // edgeTunnel is responsible for communication with cloudTunnel.
 type edgeTunnel struct {
 	conf            *config.ClusterControllerConfig
+	parentAddr      string   // the parent configured, gone back to after max retries
+	parents         []string // the parents configured, failed over in order
+	parentIndex     int      // the index of the current parent in parents
 	cloudAddr       string
 	originCloudAddr string // set to setting cloud addr when redirect to another
+	redirectAddr    string // set to the cloud addr redirected to by Redirect
 	name            string
 	uuid            string
 	listenAddr      string
-	wsclient        *WSClient
+	// previousName is the name registed by before renamed, sent until connected once.
+	previousName string
+	// protocol is the tunnel protocol to connect to parent by.
+	protocol string
+	// shadow is true if the parent is a shadow parent, see NewShadowEdgeTunnel.
+	shadow bool
+	// heartbeatInterval and heartbeatTimeout are of pings to the parent, no ping if interval is 0.
+	heartbeatInterval time.Duration
+	heartbeatTimeout  time.Duration
+
+	lock     sync.Mutex
+	wsclient *WSClient
+	// redirecting is true from Redirect called to connected to a parent again,
+	// messages sent in it are held in pending.
+	redirecting bool
+	pending     [][]byte
+	// router is the route of neighbors to fail over to, the default router if nil.
+	router *clusterrouter.ClusterRouter
 
 	receiveMessageHandler TunnelReadMessageFunc
 	afterConnectToHook    AfterConnectToHook
 	afterDisconnectHook   AfterDisconnectHook
+	maxRetriesHook        MaxRetriesHook
 }
 
 // NewEdgeTunnel returns a new edgeTunnel object.
 func NewEdgeTunnel(conf *config.ClusterControllerConfig) EdgeTunnel {
+	var parent string
+	if len(conf.ParentCluster) != 0 {
+		parent = conf.ParentCluster[0]
+	}
 	return &edgeTunnel{
 		conf:       conf,
 		name:       conf.ClusterUserDefineName,
-		cloudAddr:  conf.ParentCluster,
+		parentAddr: parent,
+		cloudAddr:  parent,
+		parents:    conf.ParentCluster,
 		listenAddr: conf.TunnelListenAddr,
+		protocol:   conf.TunnelProtocol,
+
+		previousName:      conf.PreviousClusterName,
+		heartbeatInterval: conf.HeartbeatInterval,
+		heartbeatTimeout:  conf.HeartbeatTimeout,
 		receiveMessageHandler: func(client string, msg []byte) error {
 			klog.Info(string(msg))
 			return nil
 		},
 		afterConnectToHook:  func() {},
 		afterDisconnectHook: func() {},
+		maxRetriesHook:      func(int) {},
 	}
 
 }
 
+// NewEdgeTunnelWithRouter returns a new edgeTunnel object, which fails over to the parent
+// neighbors in router instead of the default one.
+func NewEdgeTunnelWithRouter(conf *config.ClusterControllerConfig, router *clusterrouter.ClusterRouter) EdgeTunnel {
+	e := NewEdgeTunnel(conf).(*edgeTunnel)
+	e.router = router
+	return e
+}
+
+/*
+NewShadowEdgeTunnel returns a new edgeTunnel object connecting to the shadow parent at addr,
+which is used to test a new parent with the same messages reported to the parent.
+It does not change the state negotiated with the parent, e.g., the report encoding and
+the clock skew, reports are sent to it in json. It is not failed over to parent neighbors,
+and does not fail Start if the shadow parent is unavailable, but keeps connecting to it.
+*/
+func NewShadowEdgeTunnel(conf *config.ClusterControllerConfig, addr string) EdgeTunnel {
+	e := NewEdgeTunnel(conf).(*edgeTunnel)
+	e.parentAddr = addr
+	e.cloudAddr = addr
+	e.parents = []string{addr}
+	// shadow parents do not route to this cluster, so nothing is tombstoned.
+	e.previousName = ""
+	e.shadow = true
+	return e
+}
+
 func (e *edgeTunnel) connect() error {
 	e.uuid = e.name
-	u := url.URL{Scheme: "ws", Host: e.cloudAddr, Path: accessURI + e.uuid}
 	header := http.Header{}
 	header.Add(config.ClusterConnectHeaderListenAddr, e.listenAddr)
 	header.Add(config.ClusterConnectHeaderUserDefineName, e.name)
+	header.Add(config.ClusterConnectHeaderVersion, config.Version)
+	header.Add(config.ClusterConnectHeaderProtocol, strconv.Itoa(config.ProtocolVersion))
+	if e.shadow {
+		header.Add(config.ClusterConnectHeaderEncodings, reporter.EncodingJSON)
+	} else {
+		header.Add(config.ClusterConnectHeaderEncodings, reporter.Encodings())
+	}
+	if compressions := Compressions(); compressions != "" {
+		header.Add(config.ClusterConnectHeaderCompressions, compressions)
+	}
+	if window := ChannelWindow(); window > 0 {
+		header.Add(config.ClusterConnectHeaderChannelWindow, strconv.FormatInt(window, 10))
+	}
+	if checksum := Checksum(); checksum != "" {
+		header.Add(config.ClusterConnectHeaderChecksum, checksum)
+	}
+	if capabilities := capability.Header(); capabilities != "" {
+		header.Add(config.ClusterConnectHeaderCapabilities, capabilities)
+	}
+	if e.redirectAddr != "" && e.redirectAddr == e.cloudAddr {
+		header.Add(config.ClusterConnectHeaderRedirected, "true")
+	}
+	if e.previousName != "" {
+		header.Add(config.ClusterConnectHeaderPreviousName, e.previousName)
+	}
+	if authorization := clientAuthorization(); authorization != "" {
+		header.Set("Authorization", authorization)
+	}
+
+	sent := time.Now()
+	header.Set(config.ClusterConnectHeaderTime, strconv.FormatInt(config.UnixMilli(sent), 10))
+	header.Set(config.ClusterConnectHeaderHLC, config.HybridNow().String())
+	wsclient, respHeader, location, err := e.dial(header)
+	if location != "" {
+		klog.Infof("redirect to %s", location)
+		e.originCloudAddr = e.cloudAddr
+		e.cloudAddr = location
+		return e.connect()
+	}
+	if err != nil {
+		return err
+	}
+
+	klog.Infof("connected to cloudtunnel with protocol version %s",
+		respHeader.Get(config.ClusterConnectHeaderProtocol))
+	if !e.shadow {
+		e.negotiate(respHeader, sent)
+	}
+
+	// parents without the header send messages uncompressed.
+	compression := respHeader.Get(config.ClusterConnectHeaderCompression)
+	if _, ok := compressors[compression]; !ok {
+		compression = CompressionNone
+	}
+	klog.Infof("tunnel compression with cloudtunnel is %s", compression)
+
+	wsclient.setCompression(compression)
+	// parents without the header do not multiplex channels.
+	wsclient.setChannels(respHeader.Get(config.ClusterConnectHeaderChannelWindow))
+	// parents without the header do not checksum frames.
+	wsclient.setChecksum(respHeader.Get(config.ClusterConnectHeaderChecksum))
+	role := RoleParent
+	if e.shadow {
+		role = RoleShadowParent
+	}
+	wsclient.track(role, e.cloudAddr, role)
+	e.setWSClient(wsclient)
+	// the previous name is tombstoned once re-registered.
+	e.previousName = ""
+
+	go e.afterConnectToHook()
+
+	return nil
+}
+
+// negotiate sets the state negotiated with the parent by header responded to connection sent.
+func (e *edgeTunnel) negotiate(respHeader http.Header, sent time.Time) {
+	// the parent is ahead of this cluster by skew.
+	skew, err := config.ClockSkew(respHeader.Get(config.ClusterConnectHeaderTime), sent, time.Now())
+	if err != nil {
+		klog.Warningf("cannot measure clock skew with cloudtunnel: %v", err)
+	} else {
+		klog.Infof("clock skew with cloudtunnel is %dms", -skew)
+	}
+	config.SetParentClockSkew(-skew)
+	// reports after connected are ordered after the registry of this cluster by the parent.
+	config.HybridUpdateHeader(respHeader.Get(config.ClusterConnectHeaderHLC))
+	// parents without the header only accept json.
+	encoding := respHeader.Get(config.ClusterConnectHeaderEncoding)
+	if reporter.EncodingSupported(encoding) {
+		reporter.SetUpstreamEncoding(encoding)
+	} else {
+		reporter.SetUpstreamEncoding(reporter.EncodingJSON)
+	}
+	klog.Infof("report encoding with cloudtunnel is %s", reporter.UpstreamEncoding())
+	e.conf.ClusterName = e.uuid
+}
+
+// dial connects to cloud tunnel with header by the tunnel protocol, and returns the client of
+// the connection and the header responded, or the address redirected to.
+func (e *edgeTunnel) dial(header http.Header) (*WSClient, http.Header, string, error) {
+	if e.protocol == TunnelProtocolGRPC {
+		conn, respHeader, location, err := dialGRPC(e.cloudAddr, e.uuid, header,
+			e.heartbeatInterval, e.heartbeatTimeout)
+		if err != nil || location != "" {
+			return nil, nil, location, err
+		}
+		return newClient(e.uuid, conn), respHeader, "", nil
+	}
+	conn, respHeader, location, err := dialWebsocket(e.cloudAddr, accessURI+e.uuid, header)
+	if err != nil || location != "" {
+		return nil, nil, location, err
+	}
+	// TODO gradeful new wsclient.
+	wsclient := NewWSClient(e.uuid, conn)
+	wsclient.startHeartbeat(e.heartbeatInterval, e.heartbeatTimeout)
+	return wsclient, respHeader, "", nil
+}
 
+// dialWebsocket connects to cloud tunnel at addr by websocket, and returns the connection and
+// the header responded, or the address redirected to.
+func dialWebsocket(addr, path string, header http.Header) (*websocket.Conn, http.Header, string, error) {
+	scheme, dialer := tunnelDialer()
+	u := url.URL{Scheme: scheme, Host: dialHost(addr, dialer), Path: path}
 	klog.Infof("connecting to cloudtunnel %s", u.String())
-	// TODO https connection.
-	conn, resp, err := websocket.DefaultDialer.Dial(u.String(), header)
+	conn, resp, err := dialer.Dial(u.String(), header)
 	if err != nil {
 		if resp != nil {
 			if resp.StatusCode == http.StatusFound {
 				redirectLocation, err := resp.Location()
 				if err != nil {
 					klog.Errorf("failed to redirect, err=%v", err)
-					return err
+					return nil, nil, "", err
 				}
-				klog.Infof("redirect to %s", redirectLocation.String())
-				e.originCloudAddr = e.cloudAddr
-				e.cloudAddr = redirectLocation.Host
-				return e.connect()
+				return nil, nil, redirectLocation.Host, nil
 			}
 			klog.Errorf("failed to connect to cloudtunnel, code=%v", resp.StatusCode)
 		}
-		return err
+		return nil, nil, "", err
 	}
-
-	e.conf.ClusterName = e.uuid
-
-	// TODO gradeful new wsclient.
-	e.wsclient = NewWSClient(e.uuid, conn)
-
-	go e.afterConnectToHook()
-
-	return nil
+	return conn, resp.Header, "", nil
 }
 
 func (e *edgeTunnel) Send(msg []byte) error {
-	if e.wsclient == nil {
+	e.lock.Lock()
+	wsclient := e.wsclient
+	if e.redirecting {
+		e.hold(msg)
+		e.lock.Unlock()
+		return nil
+	}
+	e.lock.Unlock()
+
+	if wsclient == nil {
 		return fmt.Errorf("edge tunnel is not ready")
 	}
-	err := e.wsclient.WriteMessage(msg)
+	err := wsclient.WriteMessage(msg)
 	if err != nil {
+		// the connection may be closed by Redirect during writing.
+		e.lock.Lock()
+		redirecting := e.redirecting
+		if redirecting {
+			e.hold(msg)
+		}
+		e.lock.Unlock()
+		if redirecting {
+			return nil
+		}
 		klog.Errorf("wsclient write msg failed: %s", err.Error())
 		return err
 	}
 	return nil
 }
 
+// hold keeps msg sent while redirecting, it is dropped if too many are held.
+// call with lock held.
+func (e *edgeTunnel) hold(msg []byte) {
+	if len(e.pending) >= maxRedirectPending {
+		klog.Errorf("drop message sent while redirecting, %d messages are held", len(e.pending))
+		return
+	}
+	e.pending = append(e.pending, msg)
+}
+
+// setWSClient sets the connection to parent, and sends messages held while redirecting by it.
+func (e *edgeTunnel) setWSClient(wsclient *WSClient) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.wsclient = wsclient
+	if !e.redirecting {
+		return
+	}
+	e.redirecting = false
+	klog.Infof("send %d messages held while redirecting", len(e.pending))
+	for _, msg := range e.pending {
+		if err := wsclient.WriteMessage(msg); err != nil {
+			klog.Errorf("wsclient write msg held failed: %s", err.Error())
+		}
+	}
+	e.pending = nil
+}
+
+/*
+Redirect closes the connection to current parent and reconnects to the parent at addr.
+Messages sent before connected, e.g. responses of tasks in flight, are held and sent
+to the new parent once connected, so that they are not lost by moving.
+If addr is unavailable, it goes back to current parent.
+*/
+func (e *edgeTunnel) Redirect(addr string) error {
+	if addr == "" {
+		return fmt.Errorf("redirect address is empty")
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.wsclient == nil {
+		return fmt.Errorf("edge tunnel is not ready")
+	}
+	klog.Infof("redirect from %s to %s", e.cloudAddr, addr)
+	if e.originCloudAddr == "" {
+		e.originCloudAddr = e.cloudAddr
+	}
+	e.cloudAddr = addr
+	e.redirectAddr = addr
+	e.redirecting = true
+	// close gracefully, the parent knows the child is moved rather than lost.
+	e.wsclient.conn.closeNormally("redirected")
+	return e.wsclient.Close()
+}
+
+func (e *edgeTunnel) Stats() []ConnectionStats {
+	e.lock.Lock()
+	wsclient := e.wsclient
+	e.lock.Unlock()
+	return clientStats(wsclient)
+}
+
 func (e *edgeTunnel) RegistReceiveMessageHandler(fn TunnelReadMessageFunc) {
 	e.receiveMessageHandler = fn
 }
@@ -143,57 +409,127 @@ func (e *edgeTunnel) RegistAfterDisconnectHook(fn AfterDisconnectHook) {
 	e.afterDisconnectHook = fn
 }
 
+func (e *edgeTunnel) RegistMaxRetriesHook(fn MaxRetriesHook) {
+	e.maxRetriesHook = fn
+}
+
 func (e *edgeTunnel) Stop() error {
 	//TODO: graceful stop.
 	return nil
 }
 
+/*
+reconnect connects to parent again by the reconnect policy, until connected.
+Each retry waits for an exponential backoff with jitter, so that childs disconnected at
+the same time, e.g. by a restart of parent, spread out their reconnections.
+A child redirected tries the origin parent first, then a parent neighbor on failure.
+A child of more than one parent configured retries the current one instead of neighbors,
+and fails over to the next one once disconnected for ParentFailoverThreshold.
+After max retries, it calls MaxRetriesHook and starts over from the parent configured.
+The shadow parent is retried without choosing others.
+*/
 func (e *edgeTunnel) reconnect() {
+	b := newBackoff(getReconnectPolicy())
+	disconnected := time.Now()
 	for {
-		if err := e.connect(); err != nil {
-			// if it has be redirected, try the origin parent first
-			if e.originCloudAddr != "" {
-				// wait for leader elect
-				time.Sleep(1 * time.Second)
-
-				klog.Infof("reconnect to origin parent %s", e.originCloudAddr)
-				e.cloudAddr = e.originCloudAddr
+		wait := b.next()
+		klog.Infof("connect to %s after %v", e.cloudAddr, wait)
+		time.Sleep(wait)
+		err := e.connect()
+		if err == nil {
+			break
+		}
+		klog.Errorf("connect to %s failed: %s", e.cloudAddr, err.Error())
+
+		if b.exhausted() {
+			klog.Errorf("reconnect failed after %d retries", b.retries)
+			e.maxRetriesHook(b.retries)
+			b.reset()
+			if !e.shadow && e.parentAddr != "" {
+				klog.Infof("go back to parent %s", e.parentAddr)
+				e.cloudAddr = e.parentAddr
+				e.parentIndex = 0
 				e.originCloudAddr = ""
-				continue
+				defaultCloudBlackList.Clear()
+				disconnected = time.Now()
 			}
-			// if disconnect to parent, choose a parent neighbor to connect.
-			if !e.chooseParentNeighbor() {
-				// wait and connect to current parent.
-				klog.Errorf("connect to %s failed, try again after %ds: %s",
-					e.cloudAddr, waitConnection, err.Error())
-				time.Sleep(time.Duration(waitConnection) * time.Second)
+			continue
+		}
+		if e.shadow {
+			continue
+		}
+		// if it has be redirected, try the origin parent first
+		if e.originCloudAddr != "" {
+			klog.Infof("reconnect to origin parent %s", e.originCloudAddr)
+			e.cloudAddr = e.originCloudAddr
+			e.originCloudAddr = ""
+			continue
+		}
+		if len(e.parents) > 1 {
+			if time.Since(disconnected) >= e.conf.ParentFailoverThreshold {
+				e.failover()
+				disconnected = time.Now()
 			}
-
-			// connect to new parent immediately.
-			klog.Errorf("connect to new parent %s", e.cloudAddr)
 			continue
 		}
-		break
+		// if disconnect to parent, choose a parent neighbor to connect.
+		if e.chooseParentNeighbor() {
+			klog.Infof("connect to new parent %s", e.cloudAddr)
+		}
 	}
 
 	// cloud address is not needed in black list after connecting to a parent.
-	defaultCloudBlackList.Clear()
+	if !e.shadow {
+		defaultCloudBlackList.Clear()
+	}
 }
+
 func (e *edgeTunnel) Start() error {
-	if err := e.connect(); err != nil {
+	if e.shadow {
+		go func() {
+			e.reconnect()
+			e.serve()
+		}()
+		return nil
+	}
+	// connect to the first reachable parent.
+	var err error
+	for i, addr := range e.parents {
+		e.parentIndex, e.cloudAddr = i, addr
+		if err = e.connect(); err == nil {
+			break
+		}
+		klog.Errorf("connect to parent %s failed: %v", addr, err)
+	}
+	if err != nil {
 		return err
 	}
 
 	// TODO exit if name is duplicate.
-	go func() {
-		for {
-			e.handleReceiveMessage()
+	go e.serve()
+	return nil
+}
 
-			e.wsclient.Close()
-			e.reconnect()
-		}
+func (e *edgeTunnel) StartConnecting() {
+	err := e.Start()
+	if err == nil {
+		return
+	}
+	klog.Errorf("no parent is reachable, keep connecting in background: %v", err)
+	go func() {
+		e.reconnect()
+		e.serve()
 	}()
-	return nil
+}
+
+// serve handles messages from the parent connected, and reconnects once disconnected.
+func (e *edgeTunnel) serve() {
+	for {
+		e.handleReceiveMessage()
+
+		e.wsclient.Close()
+		e.reconnect()
+	}
 }
 
 // handleReceiveMessage reads message from the connection and process it one by one.
@@ -201,19 +537,41 @@ func (e *edgeTunnel) Start() error {
 // and once error happened, call afterDisconnectHook immediately
 func (e *edgeTunnel) handleReceiveMessage() {
 	klog.V(1).Infof("start handle receive message")
-	for {
-		msg, err := e.wsclient.ReadMessage()
-		if err != nil {
-			klog.Errorf("read msg failed: %s", err.Error())
-			break
-		}
+	if client := e.wsclient; client.mux != nil {
+		// messages of each channel are handled in order by itself.
+		client.serveChannels(nil, func(msg []byte) {
+			e.receiveMessageHandler(client.Name, msg)
+		})
+	} else {
+		for {
+			msg, err := e.wsclient.ReadMessage()
+			if err != nil {
+				klog.Errorf("read msg failed: %s", err.Error())
+				break
+			}
 
-		e.receiveMessageHandler(e.wsclient.Name, msg)
+			e.receiveMessageHandler(e.wsclient.Name, msg)
+		}
 	}
 	klog.Warningf("disconnect from %s", e.cloudAddr)
 	e.afterDisconnectHook()
 }
 
+// route returns the router of edge tunnel.
+func (e *edgeTunnel) route() *clusterrouter.ClusterRouter {
+	if e.router == nil {
+		return clusterrouter.Router()
+	}
+	return e.router
+}
+
+// failover changes cloud address of edge tunnel to the next parent configured.
+func (e *edgeTunnel) failover() {
+	e.parentIndex = (e.parentIndex + 1) % len(e.parents)
+	klog.Warningf("parent %s is unreachable, fail over to parent %s", e.cloudAddr, e.parents[e.parentIndex])
+	e.cloudAddr = e.parents[e.parentIndex]
+}
+
 // chooseParentNeighbor change cloud addrrss of edge tunnel
 // if a parent or neighbor node found.
 func (e *edgeTunnel) chooseParentNeighbor() bool {
@@ -222,7 +580,7 @@ func (e *edgeTunnel) chooseParentNeighbor() bool {
 	// find first parent neighbor not in blacklist.
 	var choose string
 	// TODO choose from parent neighbor or parent's parent.
-	for _, addr := range clusterrouter.Router().ParentNeighbors() {
+	for _, addr := range e.route().ParentNeighbors() {
 		if !defaultCloudBlackList.Find(addr) {
 			choose = addr
 			break
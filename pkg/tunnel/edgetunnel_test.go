@@ -18,14 +18,18 @@ package tunnel
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 
 	clusterrouter "github.com/baidu/ote-stack/pkg/clusterrouter"
 	"github.com/baidu/ote-stack/pkg/config"
+	"github.com/baidu/ote-stack/pkg/reporter"
 )
 
 func newTestEdgeTunnel() *edgeTunnel {
@@ -62,6 +66,61 @@ func TestConnect(t *testing.T) {
 	assert.Equal(t, originAddr, e.originCloudAddr)
 }
 
+func TestConnectShadow(t *testing.T) {
+	offered := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offered <- r.Header.Get(config.ClusterConnectHeaderEncodings)
+		header := http.Header{}
+		header.Set(config.ClusterConnectHeaderEncoding, reporter.EncodingProtobuf)
+		c, err := (&websocket.Upgrader{}).Upgrade(w, r, header)
+		if err != nil {
+			return
+		}
+		c.Close()
+	}))
+	defer server.Close()
+
+	reporter.SetUpstreamEncoding(reporter.EncodingJSON)
+	conf := &config.ClusterControllerConfig{ClusterName: "origin", ClusterUserDefineName: "child"}
+	tun := NewShadowEdgeTunnel(conf, server.Listener.Addr().String()).(*edgeTunnel)
+	assert.True(t, tun.shadow)
+	assert.Nil(t, tun.connect())
+	// the shadow parent is offered json only, and does not change state negotiated with parent.
+	assert.Equal(t, reporter.EncodingJSON, <-offered)
+	assert.Equal(t, reporter.EncodingJSON, reporter.UpstreamEncoding())
+	assert.Equal(t, "origin", conf.ClusterName)
+}
+
+func TestConnectRenamed(t *testing.T) {
+	previous := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		previous <- r.Header.Get(config.ClusterConnectHeaderPreviousName)
+		c, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		c.Close()
+	}))
+	defer server.Close()
+
+	conf := &config.ClusterControllerConfig{
+		ClusterName:           "new",
+		ClusterUserDefineName: "new",
+		PreviousClusterName:   "old",
+		ParentCluster:         []string{server.Listener.Addr().String()},
+	}
+	tun := NewEdgeTunnel(conf).(*edgeTunnel)
+	assert.Nil(t, tun.connect())
+	assert.Equal(t, "old", <-previous)
+	// the previous name is sent until connected once.
+	assert.Nil(t, tun.connect())
+	assert.Equal(t, "", <-previous)
+
+	shadow := NewShadowEdgeTunnel(conf, server.Listener.Addr().String()).(*edgeTunnel)
+	assert.Nil(t, shadow.connect())
+	assert.Equal(t, "", <-previous)
+}
+
 func TestSend(t *testing.T) {
 	tun := newTestEdgeTunnel()
 	if err := tun.Send([]byte("test")); err == nil {
@@ -77,6 +136,29 @@ func TestSend(t *testing.T) {
 	}
 }
 
+func TestRedirect(t *testing.T) {
+	tun := newTestEdgeTunnel()
+	assert.NotNil(t, tun.Redirect("127.0.0.1:8288"))
+
+	assert.Nil(t, tun.connect())
+	originAddr := tun.cloudAddr
+	assert.NotNil(t, tun.Redirect(""))
+	assert.Nil(t, tun.Redirect("127.0.0.1:8288"))
+	assert.Equal(t, "127.0.0.1:8288", tun.cloudAddr)
+	assert.Equal(t, originAddr, tun.originCloudAddr)
+
+	// messages sent while redirecting are held
+	assert.Nil(t, tun.Send([]byte("in flight")))
+	assert.Equal(t, [][]byte{[]byte("in flight")}, tun.pending)
+
+	// and sent after connected again
+	tun.cloudAddr = originAddr
+	assert.Nil(t, tun.connect())
+	assert.False(t, tun.redirecting)
+	assert.Nil(t, tun.pending)
+	assert.Nil(t, tun.Send([]byte("test")))
+}
+
 func TestHandleReceiveMessage(t *testing.T) {
 	tun := newTestEdgeTunnel()
 
@@ -163,6 +245,88 @@ func TestChooseParentNeighbor(t *testing.T) {
 	}
 }
 
+func TestStartWithParents(t *testing.T) {
+	addr := testServer.Listener.Addr().String()
+	conf := &config.ClusterControllerConfig{
+		ClusterUserDefineName: "child",
+		ParentCluster:         []string{"127.0.0.1:1", addr},
+	}
+	tun := NewEdgeTunnel(conf).(*edgeTunnel)
+	assert.Equal(t, "127.0.0.1:1", tun.parentAddr)
+
+	// the first reachable parent is connected.
+	assert.Nil(t, tun.Start())
+	assert.Equal(t, addr, tun.cloudAddr)
+	assert.Equal(t, 1, tun.parentIndex)
+
+	conf.ParentCluster = []string{"127.0.0.1:1", "127.0.0.1:2"}
+	assert.NotNil(t, NewEdgeTunnel(conf).Start())
+}
+
+func TestStartConnecting(t *testing.T) {
+	assert.Nil(t, SetReconnectPolicy(ReconnectPolicy{
+		InitialInterval: 5 * time.Millisecond,
+		MaxInterval:     5 * time.Millisecond,
+		Multiplier:      1,
+	}))
+	defer SetReconnectPolicy(DefaultReconnectPolicy)
+	neighbors := clusterrouter.Router().ParentNeighbor
+	clusterrouter.Router().ParentNeighbor = map[string]string{"c2": testServer.Listener.Addr().String()}
+	defer func() {
+		clusterrouter.Router().ParentNeighbor = neighbors
+	}()
+	defaultCloudBlackList.Clear()
+
+	// parents unreachable are not failed, but connected in background.
+	tun := NewEdgeTunnel(&config.ClusterControllerConfig{
+		ClusterUserDefineName: "child",
+		ParentCluster:         []string{"127.0.0.1:1"},
+	})
+	connected := make(chan struct{}, 1)
+	tun.RegistAfterConnectToHook(func() {
+		connected <- struct{}{}
+	})
+	tun.StartConnecting()
+	select {
+	case <-connected:
+	case <-time.After(5 * time.Second):
+		t.Errorf("not connected to parent neighbor")
+	}
+}
+
+func TestFailoverToNextParent(t *testing.T) {
+	assert.Nil(t, SetReconnectPolicy(ReconnectPolicy{
+		InitialInterval: 5 * time.Millisecond,
+		MaxInterval:     5 * time.Millisecond,
+		Multiplier:      1,
+	}))
+	defer SetReconnectPolicy(DefaultReconnectPolicy)
+
+	addr := testServer.Listener.Addr().String()
+	tun := newTestEdgeTunnel()
+	tun.conf = &config.ClusterControllerConfig{ParentFailoverThreshold: 50 * time.Millisecond}
+	tun.parents = []string{"127.0.0.1:1", addr}
+	tun.parentAddr = tun.parents[0]
+	tun.cloudAddr = tun.parents[0]
+	neighbors := clusterrouter.Router().ParentNeighbor
+	clusterrouter.Router().ParentNeighbor = map[string]string{"c2": "127.0.0.1:1234"}
+	defer func() {
+		clusterrouter.Router().ParentNeighbor = neighbors
+	}()
+
+	// the parent disconnected is retried until the threshold, instead of parent neighbors.
+	start := time.Now()
+	tun.reconnect()
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+	assert.Equal(t, addr, tun.cloudAddr)
+	assert.Equal(t, 1, tun.parentIndex)
+
+	// and fails over in order, back to the first one after the last one.
+	tun.failover()
+	assert.Equal(t, "127.0.0.1:1", tun.cloudAddr)
+	assert.Equal(t, 0, tun.parentIndex)
+}
+
 func TestCloudBlackList_Clear(t *testing.T) {
 	defaultCloudBlackList.Push("c1")
 	defaultCloudBlackList.Clear()
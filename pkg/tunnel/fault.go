@@ -0,0 +1,211 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// FaultConfig defines the faults injected to websocket clients, for resilience testing only.
+// Faults are disabled by default.
+type FaultConfig struct {
+	// Target is a regex of client names to inject faults, empty means all clients.
+	// Name of client is the cluster name on both sides of cloud and edge tunnel.
+	Target string `json:"target,omitempty"`
+	// DropPercent is the percent(0-100) of messages dropped on write.
+	DropPercent float64 `json:"dropPercent,omitempty"`
+	// LatencyMs is the latency added to each message on write.
+	LatencyMs int64 `json:"latencyMs,omitempty"`
+	// KillIntervalSeconds is the interval to close connections of all targets,
+	// 0 means connections are not killed.
+	KillIntervalSeconds int64 `json:"killIntervalSeconds,omitempty"`
+}
+
+// Enabled returns true if any fault is set.
+func (f *FaultConfig) Enabled() bool {
+	return f.DropPercent > 0 || f.LatencyMs > 0 || f.KillIntervalSeconds > 0
+}
+
+func (f *FaultConfig) valid() error {
+	if f.DropPercent < 0 || f.DropPercent > 100 {
+		return fmt.Errorf("drop percent %v is not in [0, 100]", f.DropPercent)
+	}
+	if f.LatencyMs < 0 {
+		return fmt.Errorf("latency cannot be negative")
+	}
+	if f.KillIntervalSeconds < 0 {
+		return fmt.Errorf("kill interval cannot be negative")
+	}
+	return nil
+}
+
+// faultInjector holds the fault config and the clients alive.
+type faultInjector struct {
+	sync.RWMutex
+	conf     FaultConfig
+	target   *regexp.Regexp
+	stopKill chan struct{}
+	clients  sync.Map // *WSClient -> struct{}
+}
+
+var faults = &faultInjector{}
+
+// SetFaultConfig replaces the faults injected to websocket clients.
+// An empty config disables fault injection.
+func SetFaultConfig(conf FaultConfig) error {
+	return faults.set(conf)
+}
+
+// GetFaultConfig returns the faults injected currently.
+func GetFaultConfig() FaultConfig {
+	faults.RLock()
+	defer faults.RUnlock()
+	return faults.conf
+}
+
+func (f *faultInjector) set(conf FaultConfig) error {
+	if err := conf.valid(); err != nil {
+		return err
+	}
+	var target *regexp.Regexp
+	if conf.Target != "" {
+		var err error
+		if target, err = regexp.Compile(conf.Target); err != nil {
+			return fmt.Errorf("target %s is not a valid regex: %v", conf.Target, err)
+		}
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	if f.stopKill != nil {
+		close(f.stopKill)
+		f.stopKill = nil
+	}
+	f.conf = conf
+	f.target = target
+	if conf.KillIntervalSeconds > 0 {
+		f.stopKill = make(chan struct{})
+		go f.killLoop(time.Duration(conf.KillIntervalSeconds)*time.Second, f.stopKill)
+	}
+	if conf.Enabled() {
+		klog.Warningf("fault injection enabled: %+v", conf)
+	} else {
+		klog.Infof("fault injection disabled")
+	}
+	return nil
+}
+
+func (f *faultInjector) isTarget(name string) bool {
+	return f.target == nil || f.target.MatchString(name)
+}
+
+// beforeWrite sleeps for the latency, and returns true if the message of client should be dropped.
+func (f *faultInjector) beforeWrite(name string) bool {
+	f.RLock()
+	if !f.conf.Enabled() || !f.isTarget(name) {
+		f.RUnlock()
+		return false
+	}
+	latency := time.Duration(f.conf.LatencyMs) * time.Millisecond
+	drop := f.conf.DropPercent > 0 && rand.Float64()*100 < f.conf.DropPercent
+	f.RUnlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	if drop {
+		klog.V(3).Infof("fault injection: drop message of %s", name)
+	}
+	return drop
+}
+
+func (f *faultInjector) add(c *WSClient) {
+	f.clients.Store(c, struct{}{})
+}
+
+func (f *faultInjector) remove(c *WSClient) {
+	f.clients.Delete(c)
+}
+
+func (f *faultInjector) killLoop(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			f.kill()
+		}
+	}
+}
+
+// kill closes connections of all target clients, which will be reconnected by tunnels.
+func (f *faultInjector) kill() {
+	f.RLock()
+	defer f.RUnlock()
+	f.clients.Range(func(key, value interface{}) bool {
+		c := key.(*WSClient)
+		if f.isTarget(c.Name) {
+			klog.Warningf("fault injection: kill connection of %s", c.Name)
+			c.Close()
+		}
+		return true
+	})
+}
+
+// FaultHandler is the http handler to get(GET), set(PUT/POST) or disable(DELETE)
+// fault injection with FaultConfig in json.
+func FaultHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		conf := FaultConfig{}
+		if err := json.Unmarshal(body, &conf); err != nil {
+			http.Error(w, fmt.Sprintf("unmarshal fault config failed: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := SetFaultConfig(conf); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case http.MethodDelete:
+		SetFaultConfig(FaultConfig{})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	conf := GetFaultConfig()
+	data, _ := json.Marshal(&conf)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetFaultConfig(t *testing.T) {
+	defer SetFaultConfig(FaultConfig{})
+
+	assert.NotNil(t, SetFaultConfig(FaultConfig{DropPercent: 101}))
+	assert.NotNil(t, SetFaultConfig(FaultConfig{LatencyMs: -1}))
+	assert.NotNil(t, SetFaultConfig(FaultConfig{KillIntervalSeconds: -1}))
+	assert.NotNil(t, SetFaultConfig(FaultConfig{Target: "("}))
+
+	conf := FaultConfig{Target: "^c1$", DropPercent: 50}
+	assert.Nil(t, SetFaultConfig(conf))
+	assert.Equal(t, conf, GetFaultConfig())
+	assert.True(t, conf.Enabled())
+	assert.False(t, (&FaultConfig{Target: "c1"}).Enabled())
+}
+
+func TestFaultBeforeWrite(t *testing.T) {
+	defer SetFaultConfig(FaultConfig{})
+
+	assert.False(t, faults.beforeWrite("c1"))
+
+	assert.Nil(t, SetFaultConfig(FaultConfig{Target: "^c1$", DropPercent: 100}))
+	assert.True(t, faults.beforeWrite("c1"))
+	assert.False(t, faults.beforeWrite("c2"))
+
+	assert.Nil(t, SetFaultConfig(FaultConfig{LatencyMs: 50}))
+	start := time.Now()
+	assert.False(t, faults.beforeWrite("c1"))
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+}
+
+func TestFaultDropMessage(t *testing.T) {
+	defer SetFaultConfig(FaultConfig{})
+
+	client := newTestWSClient()
+	assert.NotNil(t, client)
+	defer client.Close()
+
+	assert.Nil(t, SetFaultConfig(FaultConfig{Target: client.Name, DropPercent: 100}))
+	assert.Nil(t, client.WriteMessage([]byte("dropped")))
+	assert.Nil(t, SetFaultConfig(FaultConfig{}))
+	assert.Nil(t, client.WriteMessage([]byte("sent")))
+
+	// the echo server returns the message not dropped only.
+	msg, err := client.ReadMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, "sent", string(msg))
+}
+
+func TestFaultKill(t *testing.T) {
+	defer SetFaultConfig(FaultConfig{})
+
+	target := newTestWSClient()
+	assert.NotNil(t, target)
+	other := newTestWSClient()
+	assert.NotNil(t, other)
+	other.Name = "other"
+	defer other.Close()
+
+	assert.Nil(t, SetFaultConfig(FaultConfig{Target: "^test$"}))
+	faults.kill()
+	_, err := target.ReadMessage()
+	assert.NotNil(t, err)
+
+	assert.Nil(t, other.WriteMessage([]byte("alive")))
+	msg, err := other.ReadMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, "alive", string(msg))
+}
+
+func TestFaultHandler(t *testing.T) {
+	defer SetFaultConfig(FaultConfig{})
+
+	cases := []struct {
+		method string
+		body   string
+		code   int
+		expect string
+	}{
+		{http.MethodPut, `{"target":"c1","dropPercent":10,"latencyMs":100}`, http.StatusOK,
+			`{"target":"c1","dropPercent":10,"latencyMs":100}`},
+		{http.MethodGet, "", http.StatusOK, `{"target":"c1","dropPercent":10,"latencyMs":100}`},
+		{http.MethodPost, `{"dropPercent":200}`, http.StatusBadRequest, ""},
+		{http.MethodPost, `not json`, http.StatusBadRequest, ""},
+		{http.MethodDelete, "", http.StatusOK, `{}`},
+		{http.MethodPatch, "", http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, "/faults", strings.NewReader(c.body))
+		w := httptest.NewRecorder()
+		FaultHandler(w, req)
+		assert.Equal(t, c.code, w.Code, c.method+" "+c.body)
+		if c.expect != "" {
+			assert.Equal(t, c.expect, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,331 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/config"
+)
+
+// Tunnel protocols to connect to parent by.
+const (
+	TunnelProtocolWebsocket = "websocket"
+	TunnelProtocolGRPC      = "grpc"
+)
+
+const (
+	// grpcConnectMethod is the bidirectional streaming method childs connect by.
+	grpcConnectMethod = "/tunnel.Tunnel/Connect"
+	// grpcClusterKey is the metadata of the cluster connecting, which is in the uri of websocket.
+	grpcClusterKey = "cluster-id"
+	// grpcLocationKey is the trailer of the address to redirect to, which is the location of websocket.
+	grpcLocationKey = "location"
+)
+
+// grpcMinPingInterval is the min interval of pings of childs allowed by the grpc server.
+const grpcMinPingInterval = 5 * time.Second
+
+// GRPCMaxMessageSize is the max size of messages sent and received by grpc.
+var GRPCMaxMessageSize = 64 << 20
+
+// ValidateTunnelProtocol returns error if protocol is not supported, empty means websocket.
+func ValidateTunnelProtocol(protocol string) error {
+	switch protocol {
+	case "", TunnelProtocolWebsocket, TunnelProtocolGRPC:
+		return nil
+	default:
+		return fmt.Errorf("tunnel protocol %s is not supported", protocol)
+	}
+}
+
+// tunnelServer is the grpc server accepting childs by bidirectional streams.
+type tunnelServer interface {
+	connectStream(stream grpc.ServerStream) error
+}
+
+// tunnelServiceDesc is the grpc service of cloud tunnel, whose messages are wrappers.BytesValue.
+var tunnelServiceDesc = grpc.ServiceDesc{
+	ServiceName: "tunnel.Tunnel",
+	HandlerType: (*tunnelServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "Connect",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				return srv.(tunnelServer).connectStream(stream)
+			},
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "tunnel",
+}
+
+// grpcClientConn is the grpc stream of a child to its parent.
+type grpcClientConn struct {
+	conn   *grpc.ClientConn
+	stream grpc.ClientStream
+	cancel context.CancelFunc
+}
+
+func (g *grpcClientConn) writeMessage(msg []byte) error {
+	return g.stream.SendMsg(&wrappers.BytesValue{Value: msg})
+}
+
+func (g *grpcClientConn) readMessage(buf *bytes.Buffer) error {
+	return readStreamMessage(g.stream, buf)
+}
+
+func (g *grpcClientConn) closeNormally(reason string) {
+	g.stream.CloseSend()
+}
+
+func (g *grpcClientConn) close() error {
+	g.cancel()
+	return g.conn.Close()
+}
+
+// grpcServerConn is the grpc stream of a child connected, which ends after closed.
+type grpcServerConn struct {
+	stream grpc.ServerStream
+	done   chan struct{}
+	once   sync.Once
+}
+
+func (g *grpcServerConn) writeMessage(msg []byte) error {
+	return g.stream.SendMsg(&wrappers.BytesValue{Value: msg})
+}
+
+func (g *grpcServerConn) readMessage(buf *bytes.Buffer) error {
+	return readStreamMessage(g.stream, buf)
+}
+
+func (g *grpcServerConn) closeNormally(reason string) {}
+
+func (g *grpcServerConn) close() error {
+	g.once.Do(func() { close(g.done) })
+	return nil
+}
+
+func readStreamMessage(stream grpc.Stream, buf *bytes.Buffer) error {
+	msg := &wrappers.BytesValue{}
+	if err := stream.RecvMsg(msg); err != nil {
+		return err
+	}
+	buf.Write(msg.Value)
+	return nil
+}
+
+// dialGRPC connects to cloud tunnel at addr by grpc as cluster, and returns the stream and
+// the header responded, or the address redirected to.
+// The connection is pinged by keepalive of heartbeat interval and timeout,
+// or of IdleTimeout and ReadTimeout if no heartbeat.
+func dialGRPC(addr, cluster string, header http.Header,
+	interval, timeout time.Duration) (*grpcClientConn, http.Header, string, error) {
+	if interval <= 0 {
+		interval, timeout = IdleTimeout, ReadTimeout
+	}
+	opts := []grpc.DialOption{
+		grpc.WithBlock(),
+		grpc.FailOnNonTempDialError(true),
+		grpc.WithDefaultCallOptions(
+			grpc.MaxCallRecvMsgSize(GRPCMaxMessageSize),
+			grpc.MaxCallSendMsgSize(GRPCMaxMessageSize)),
+		// pings keep the stream through proxies and load balancers closing idle connections.
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                interval,
+			Timeout:             timeout,
+			PermitWithoutStream: true,
+		}),
+	}
+	target := addr
+	if dial := unixDialContext(addr); dial != nil {
+		target = unixHost
+		opts = append(opts, grpc.WithContextDialer(dial))
+	} else if d := getProxyDialer(); d != nil {
+		opts = append(opts, grpc.WithContextDialer(dialProxy(d)))
+	}
+	if tlsConfig := getClientTLS(); tlsConfig != nil {
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+	klog.Infof("connecting to cloudtunnel %s by grpc", addr)
+	dialCtx, dialCancel := context.WithTimeout(context.Background(), ReadTimeout)
+	defer dialCancel()
+	conn, err := grpc.DialContext(dialCtx, target, opts...)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	md := metadata.MD{}
+	for k, v := range header {
+		md.Append(k, v...)
+	}
+	md.Set(grpcClusterKey, cluster)
+	ctx, cancel := context.WithCancel(metadata.NewOutgoingContext(context.Background(), md))
+	stream, err := conn.NewStream(ctx, &tunnelServiceDesc.Streams[0], grpcConnectMethod)
+	if err != nil {
+		cancel()
+		conn.Close()
+		return nil, nil, "", err
+	}
+	respMD, err := stream.Header()
+	if err == nil && len(respMD.Get(config.ClusterConnectHeaderProtocol)) == 0 {
+		// refused by the parent, the status follows the empty header.
+		if err = stream.RecvMsg(&wrappers.BytesValue{}); err == nil {
+			err = fmt.Errorf("no header is responded by cloudtunnel")
+		}
+	}
+	if err != nil {
+		location := stream.Trailer().Get(grpcLocationKey)
+		cancel()
+		conn.Close()
+		if len(location) != 0 {
+			return nil, nil, location[0], nil
+		}
+		klog.Errorf("failed to connect to cloudtunnel: %v", err)
+		return nil, nil, "", err
+	}
+
+	respHeader := http.Header{}
+	for k, v := range respMD {
+		for _, value := range v {
+			respHeader.Add(k, value)
+		}
+	}
+	return &grpcClientConn{conn: conn, stream: stream, cancel: cancel}, respHeader, "", nil
+}
+
+// newGRPCServer returns the grpc server of cloud tunnel t.
+func newGRPCServer(t *cloudTunnel) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.Creds(tlsInfoCredentials{}),
+		grpc.MaxRecvMsgSize(GRPCMaxMessageSize),
+		grpc.MaxSendMsgSize(GRPCMaxMessageSize),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			// below the min keepalive time of grpc clients, which is 10s.
+			MinTime:             grpcMinPingInterval,
+			PermitWithoutStream: true,
+		}))
+	server.RegisterService(&tunnelServiceDesc, t)
+	return server
+}
+
+// connectStream admits the child connecting by stream, and serves it until the stream ends.
+func (t *cloudTunnel) connectStream(stream grpc.ServerStream) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	header := http.Header{}
+	for k, v := range md {
+		for _, value := range v {
+			header.Add(k, value)
+		}
+	}
+	var state *tls.ConnectionState
+	var remoteAddr string
+	if p, ok := peer.FromContext(stream.Context()); ok {
+		remoteAddr = p.Addr.String()
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			state = &info.State
+		}
+	}
+
+	cluster := header.Get(grpcClusterKey)
+	cr, respHeader, redirectAddr, err := t.admit(cluster, remoteAddr, header, state)
+	if redirectAddr != "" {
+		stream.SetTrailer(metadata.Pairs(grpcLocationKey, redirectAddr))
+		return status.Errorf(codes.Unavailable, "redirect to %s", redirectAddr)
+	}
+	if err != nil {
+		switch err.(*admitError).code {
+		case http.StatusBadRequest:
+			return status.Error(codes.InvalidArgument, err.Error())
+		case http.StatusForbidden:
+			return status.Error(codes.PermissionDenied, err.Error())
+		case http.StatusUnauthorized:
+			return status.Error(codes.Unauthenticated, err.Error())
+		case http.StatusServiceUnavailable:
+			return status.Error(codes.Unavailable, err.Error())
+		default:
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	respMD := metadata.MD{}
+	for k, v := range respHeader {
+		respMD.Append(k, v...)
+	}
+	if err := stream.SendHeader(respMD); err != nil {
+		klog.Errorf("connect to cluster %s failed: %s", cluster, err.Error())
+		return err
+	}
+	conn := &grpcServerConn{stream: stream, done: make(chan struct{})}
+	wsclient := newClient(cr.Name, conn)
+	setupChildClient(wsclient, respHeader)
+	go t.connect(cr, wsclient)
+	// the stream ends once returned, which stops reading of the child.
+	select {
+	case <-conn.done:
+	case <-stream.Context().Done():
+	}
+	return nil
+}
+
+// tlsInfoCredentials tells grpc the tls state of connections, whose handshake is done by cloud tunnel.
+type tlsInfoCredentials struct{}
+
+func (tlsInfoCredentials) ClientHandshake(ctx context.Context, authority string,
+	conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return nil, nil, fmt.Errorf("client handshake is not supported")
+}
+
+func (tlsInfoCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		return conn, credentials.TLSInfo{State: tlsConn.ConnectionState()}, nil
+	}
+	return conn, nil, nil
+}
+
+func (tlsInfoCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: "tls"}
+}
+
+func (c tlsInfoCredentials) Clone() credentials.TransportCredentials {
+	return c
+}
+
+func (tlsInfoCredentials) OverrideServerName(string) error {
+	return nil
+}
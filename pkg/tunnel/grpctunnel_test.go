@@ -0,0 +1,146 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/config"
+)
+
+func newTestGRPCEdgeTunnel(name, cloudAddr string) *edgeTunnel {
+	return &edgeTunnel{
+		name:               name,
+		cloudAddr:          cloudAddr,
+		listenAddr:         ":8287",
+		protocol:           TunnelProtocolGRPC,
+		conf:               &config.ClusterControllerConfig{},
+		afterConnectToHook: func() {},
+	}
+}
+
+func TestValidateTunnelProtocol(t *testing.T) {
+	assert.Nil(t, ValidateTunnelProtocol(""))
+	assert.Nil(t, ValidateTunnelProtocol(TunnelProtocolWebsocket))
+	assert.Nil(t, ValidateTunnelProtocol(TunnelProtocolGRPC))
+	assert.NotNil(t, ValidateTunnelProtocol("quic"))
+}
+
+func TestGRPCTunnel(t *testing.T) {
+	received := make(chan []byte, 1)
+	registered := make(chan *config.ClusterRegistry, 1)
+	ct := NewCloudTunnel("127.0.0.1:0").(*cloudTunnel)
+	ct.RegistReturnMessageFunc(func(client string, msg []byte) error {
+		received <- msg
+		return nil
+	})
+	ct.RegistAfterConnectHook(func(cr *config.ClusterRegistry) {
+		registered <- cr
+	})
+	assert.Nil(t, ct.Start())
+
+	e := newTestGRPCEdgeTunnel("c1", ct.server.Addr)
+	assert.Nil(t, e.connect())
+	defer e.wsclient.Close()
+	assert.Nil(t, e.wsclient.Conn)
+	select {
+	case cr := <-registered:
+		assert.Equal(t, "c1", cr.Name)
+		assert.Equal(t, config.ProtocolVersion, cr.Protocol)
+	case <-time.After(5 * time.Second):
+		t.Fatal("cluster is not connected")
+	}
+
+	assert.Nil(t, e.Send([]byte("report")))
+	select {
+	case msg := <-received:
+		assert.Equal(t, []byte("report"), msg)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received by cloudtunnel")
+	}
+	assert.Nil(t, ct.Send("c1", []byte("task")))
+	msg, err := e.wsclient.ReadMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("task"), msg)
+
+	// the child connected is refused.
+	_, _, _, err = dialGRPC(ct.server.Addr, "c1", http.Header{
+		http.CanonicalHeaderKey(config.ClusterConnectHeaderListenAddr):     {"fake"},
+		http.CanonicalHeaderKey(config.ClusterConnectHeaderUserDefineName): {"c1"},
+	}, 0, 0)
+	assert.NotNil(t, err)
+
+	// websocket childs are served on the same address.
+	ws := &edgeTunnel{
+		name:               "c2",
+		cloudAddr:          ct.server.Addr,
+		listenAddr:         ":8288",
+		conf:               &config.ClusterControllerConfig{},
+		afterConnectToHook: func() {},
+	}
+	assert.Nil(t, ws.connect())
+	defer ws.wsclient.Close()
+	assert.NotNil(t, ws.wsclient.Conn)
+}
+
+func TestGRPCTunnelRedirect(t *testing.T) {
+	ct := NewCloudTunnel("127.0.0.1:0").(*cloudTunnel)
+	ct.RegistRedirectFunc(func() string {
+		return "redirect"
+	})
+	assert.Nil(t, ct.Start())
+
+	originAddr := ct.server.Addr
+	e := newTestGRPCEdgeTunnel("c1", originAddr)
+	assert.NotNil(t, e.connect())
+	assert.Equal(t, "redirect", e.cloudAddr)
+	assert.Equal(t, originAddr, e.originCloudAddr)
+}
+
+func TestGRPCTunnelMutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	defer os.RemoveAll(ca.dir)
+	defer SetupTLS(TLSConfig{})
+	assert.Nil(t, SetupTLS(ca.issue(t, "root")))
+
+	registered := make(chan *config.ClusterRegistry, 1)
+	ct := NewCloudTunnel("127.0.0.1:0").(*cloudTunnel)
+	ct.RegistAfterConnectHook(func(cr *config.ClusterRegistry) {
+		registered <- cr
+	})
+	assert.Nil(t, ct.Start())
+
+	assert.Nil(t, SetupTLS(ca.issue(t, "c1")))
+	e := newTestGRPCEdgeTunnel("c1", ct.server.Addr)
+	assert.Nil(t, e.connect())
+	defer e.wsclient.Close()
+	select {
+	case cr := <-registered:
+		assert.Equal(t, "c1", cr.Name)
+	case <-time.After(5 * time.Second):
+		t.Fatal("cluster is not connected")
+	}
+
+	// c1 can not register as c2.
+	spoofed := newTestGRPCEdgeTunnel("c2", ct.server.Addr)
+	assert.NotNil(t, spoofed.connect())
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// DefaultHashRingReplicas is the number of virtual nodes of each member in hash ring by default.
+const DefaultHashRingReplicas = 100
+
+// HashRing assigns keys to members by consistent hashing, so that only keys
+// of a member are moved to others when the member is removed, and only a share of
+// keys are moved to a member added.
+type HashRing struct {
+	hashes  []uint32
+	members map[uint32]string
+}
+
+// NewHashRing returns a hash ring of members with replicas virtual nodes of each member.
+func NewHashRing(members []string, replicas int) *HashRing {
+	if replicas <= 0 {
+		replicas = DefaultHashRingReplicas
+	}
+	r := &HashRing{members: make(map[uint32]string, len(members)*replicas)}
+	for _, m := range members {
+		for i := 0; i < replicas; i++ {
+			h := crc32.ChecksumIEEE([]byte(m + "#" + strconv.Itoa(i)))
+			if _, ok := r.members[h]; ok {
+				continue
+			}
+			r.members[h] = m
+			r.hashes = append(r.hashes, h)
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+	return r
+}
+
+// Get returns the member key is assigned to, empty if there is no member.
+func (r *HashRing) Get(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+	h := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.members[r.hashes[i]]
+}
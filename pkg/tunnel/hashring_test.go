@@ -0,0 +1,54 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashRing(t *testing.T) {
+	assert.Equal(t, "", NewHashRing(nil, 0).Get("c1"))
+
+	members := []string{"f1:8287", "f2:8287", "f3:8287"}
+	ring := NewHashRing(members, 0)
+	counts := make(map[string]int)
+	assigned := make(map[string]string)
+	for i := 0; i < 3000; i++ {
+		key := "cluster" + strconv.Itoa(i)
+		m := ring.Get(key)
+		assert.Equal(t, m, ring.Get(key))
+		counts[m]++
+		assigned[key] = m
+	}
+	// keys are spread to all members
+	for _, m := range members {
+		assert.True(t, counts[m] > 500, "%s is assigned %d keys", m, counts[m])
+	}
+
+	// only keys of the removed member are moved
+	ring = NewHashRing(members[:2], 0)
+	for key, m := range assigned {
+		if m != members[2] {
+			assert.Equal(t, m, ring.Get(key))
+		} else {
+			assert.NotEqual(t, members[2], ring.Get(key))
+		}
+	}
+}
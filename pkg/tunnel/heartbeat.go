@@ -0,0 +1,77 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"k8s.io/klog"
+)
+
+const (
+	// DefaultHeartbeatInterval is the interval of edge tunnel to ping its parent by default.
+	DefaultHeartbeatInterval = 15 * time.Second
+	// DefaultHeartbeatTimeout is the time to wait for pongs of parent by default.
+	DefaultHeartbeatTimeout = 15 * time.Second
+)
+
+// ValidateHeartbeat returns error if the heartbeat of interval and timeout is invalid,
+// interval 0 means no heartbeat.
+func ValidateHeartbeat(interval, timeout time.Duration) error {
+	if interval < 0 {
+		return fmt.Errorf("heartbeat interval cannot be negative")
+	}
+	if interval > 0 && timeout <= 0 {
+		return fmt.Errorf("heartbeat timeout must be positive")
+	}
+	return nil
+}
+
+/*
+startHeartbeat pings the peer of websocket client c every interval, and fails reading of c
+if neither pong nor message is received in interval and timeout after the last one,
+so that a connection dropped silently is found dead promptly.
+Clients of grpc streams are kept alive by keepalive of grpc instead.
+*/
+func (c *WSClient) startHeartbeat(interval, timeout time.Duration) {
+	ws, ok := c.conn.(*wsConn)
+	if interval <= 0 || !ok {
+		return
+	}
+	ws.alive = interval + timeout
+	ws.conn.SetReadDeadline(time.Now().Add(ws.alive))
+	ws.conn.SetPongHandler(func(data string) error {
+		c.stats.pong(data, time.Now())
+		return ws.conn.SetReadDeadline(time.Now().Add(ws.alive))
+	})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			// fails once the connection is closed.
+			// the pong echoes the time sent, which measures the round trip time.
+			now := time.Now()
+			err := ws.conn.WriteControl(websocket.PingMessage, pingData(now), now.Add(timeout))
+			if err != nil {
+				klog.V(3).Infof("stop heartbeat of wsclient %s: %v", c.Name, err)
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/config"
+)
+
+func TestValidateHeartbeat(t *testing.T) {
+	assert.Nil(t, ValidateHeartbeat(0, 0))
+	assert.Nil(t, ValidateHeartbeat(time.Second, time.Second))
+	assert.NotNil(t, ValidateHeartbeat(-time.Second, time.Second))
+	assert.NotNil(t, ValidateHeartbeat(time.Second, 0))
+}
+
+func TestHeartbeat(t *testing.T) {
+	// a parent not reading answers no pings, like a connection dropped silently.
+	stop := make(chan struct{})
+	defer close(stop)
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		<-stop
+		c.Close()
+	}))
+	defer dead.Close()
+
+	newTunnel := func(addr string, disconnected chan struct{}) *edgeTunnel {
+		return &edgeTunnel{
+			name:                "child",
+			cloudAddr:           addr,
+			conf:                &config.ClusterControllerConfig{},
+			heartbeatInterval:   100 * time.Millisecond,
+			heartbeatTimeout:    400 * time.Millisecond,
+			afterConnectToHook:  func() {},
+			afterDisconnectHook: func() { disconnected <- struct{}{} },
+			receiveMessageHandler: func(string, []byte) error {
+				return nil
+			},
+		}
+	}
+
+	disconnected := make(chan struct{}, 1)
+	tun := newTunnel(dead.Listener.Addr().String(), disconnected)
+	assert.Nil(t, tun.connect())
+	go tun.handleReceiveMessage()
+	select {
+	case <-disconnected:
+	case <-time.After(3 * time.Second):
+		assert.Fail(t, "dead connection is not found")
+	}
+
+	// a parent answering pings is kept connected.
+	aliveDisconnected := make(chan struct{}, 1)
+	alive := newTunnel(testServer.Listener.Addr().String(), aliveDisconnected)
+	assert.Nil(t, alive.connect())
+	go alive.handleReceiveMessage()
+	select {
+	case <-aliveDisconnected:
+		assert.Fail(t, "alive connection is disconnected")
+	case <-time.After(time.Second):
+	}
+	alive.wsclient.Close()
+}
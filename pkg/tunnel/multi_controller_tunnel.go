@@ -0,0 +1,117 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+/*
+multiControllerTunnel connects to all root frontends sharing childs.
+Messages are sent to all frontends, each of them routes messages to its childs only,
+and messages received from any frontend are handled.
+*/
+type multiControllerTunnel struct {
+	addrs    []string
+	tunnels  []ControllerTunnel
+	sendChan chan clustermessage.ClusterMessage
+}
+
+// NewMultiControllerTunnel returns a ControllerTunnel connected to each of remoteAddrs.
+func NewMultiControllerTunnel(remoteAddrs []string) ControllerTunnel {
+	m := &multiControllerTunnel{
+		addrs:    remoteAddrs,
+		tunnels:  make([]ControllerTunnel, len(remoteAddrs)),
+		sendChan: make(chan clustermessage.ClusterMessage, ControllerSendChanBufferSize),
+	}
+	for i, addr := range remoteAddrs {
+		m.tunnels[i] = NewControllerTunnel(addr)
+	}
+	return m
+}
+
+func (m *multiControllerTunnel) Start() error {
+	for _, t := range m.tunnels {
+		if err := t.Start(); err != nil {
+			return err
+		}
+	}
+	go m.sendFromChan()
+	return nil
+}
+
+func (m *multiControllerTunnel) Stop() error {
+	var lastErr error
+	for _, t := range m.tunnels {
+		if err := t.Stop(); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// Send sends msg to all frontends, it fails only if sending to all of them failed.
+func (m *multiControllerTunnel) Send(msg []byte) error {
+	var lastErr error
+	sent := false
+	for _, t := range m.tunnels {
+		if err := t.Send(msg); err != nil {
+			lastErr = err
+			continue
+		}
+		sent = true
+	}
+	if !sent && lastErr != nil {
+		return fmt.Errorf("send to all root frontends failed: %v", lastErr)
+	}
+	return nil
+}
+
+func (m *multiControllerTunnel) SendChan() chan clustermessage.ClusterMessage {
+	return m.sendChan
+}
+
+func (m *multiControllerTunnel) RegistReceiveMessageHandler(fn TunnelReadMessageFunc) {
+	for _, t := range m.tunnels {
+		t.RegistReceiveMessageHandler(fn)
+	}
+}
+
+func (m *multiControllerTunnel) RegistAfterConnectToHook(fn AfterConnectToHook) {
+	for _, t := range m.tunnels {
+		t.RegistAfterConnectToHook(fn)
+	}
+}
+
+// sendFromChan copies messages to send channels of all frontends,
+// messages are dropped for a frontend whose channel is full, e.g., disconnected for long.
+func (m *multiControllerTunnel) sendFromChan() {
+	for msg := range m.sendChan {
+		for i, t := range m.tunnels {
+			select {
+			case t.SendChan() <- *proto.Clone(&msg).(*clustermessage.ClusterMessage):
+			default:
+				klog.Errorf("send channel of root frontend %s is full, throw the msg", m.addrs[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/klog"
+)
+
+// RateLimitConfig defines the rate limit of bytes received from each child of cloud tunnel,
+// so that a chatty child cannot monopolize the uplink of the parent.
+type RateLimitConfig struct {
+	// Limit is the bytes per second received from each child, 0 means no limit.
+	Limit int64
+	// Burst is the max bytes received from a child at once, Limit if 0.
+	Burst int64
+}
+
+// RateLimitStats is the throttle state of a child.
+type RateLimitStats struct {
+	Limit int64 `json:"limit"`
+	Burst int64 `json:"burst"`
+	// Throttled is true if reading from the child is waiting for the rate limit now.
+	Throttled bool `json:"throttled"`
+	// Received is the bytes received from the child.
+	Received uint64 `json:"received"`
+	// ThrottledMessages is the number of messages delayed by the rate limit.
+	ThrottledMessages uint64 `json:"throttledMessages"`
+	// ThrottledSeconds is the total time reading from the child waited for the rate limit.
+	ThrottledSeconds float64 `json:"throttledSeconds"`
+}
+
+var (
+	rateLimitLock sync.RWMutex
+	rateLimitConf RateLimitConfig
+	// childLimiters are the limiters of children connected by name.
+	childLimiters sync.Map // string -> *childLimiter
+)
+
+// SetRateLimit sets the rate limit of children connected after that.
+func SetRateLimit(conf RateLimitConfig) error {
+	if conf.Limit < 0 || conf.Burst < 0 {
+		return fmt.Errorf("rate limit and burst cannot be negative")
+	}
+	if conf.Burst == 0 {
+		conf.Burst = conf.Limit
+	}
+	rateLimitLock.Lock()
+	defer rateLimitLock.Unlock()
+	rateLimitConf = conf
+	return nil
+}
+
+// GetRateLimitStats returns the throttle state of children connected by name.
+func GetRateLimitStats() map[string]RateLimitStats {
+	ret := make(map[string]RateLimitStats)
+	childLimiters.Range(func(key, value interface{}) bool {
+		ret[key.(string)] = value.(*childLimiter).stats()
+		return true
+	})
+	return ret
+}
+
+// RateLimitHandler is the http handler to get throttle state of children in json.
+func RateLimitHandler(w http.ResponseWriter, r *http.Request) {
+	data, _ := json.Marshal(GetRateLimitStats())
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// childLimiter delays reading from a child by a token bucket of bytes.
+type childLimiter struct {
+	conf    RateLimitConfig
+	limiter *rate.Limiter
+
+	throttled         int32
+	received          uint64
+	throttledMessages uint64
+	throttledNanos    int64
+}
+
+// newChildLimiter returns the limiter of child by the rate limit set, nil if no limit.
+// It is kept for stats until removed.
+func newChildLimiter(child string) *childLimiter {
+	rateLimitLock.RLock()
+	conf := rateLimitConf
+	rateLimitLock.RUnlock()
+	if conf.Limit == 0 {
+		return nil
+	}
+	l := &childLimiter{
+		conf:    conf,
+		limiter: rate.NewLimiter(rate.Limit(conf.Limit), int(conf.Burst)),
+	}
+	childLimiters.Store(child, l)
+	return l
+}
+
+// removeChildLimiter removes the limiter of child if it is l.
+func removeChildLimiter(child string, l *childLimiter) {
+	if l == nil {
+		return
+	}
+	if current, ok := childLimiters.Load(child); ok && current == l {
+		childLimiters.Delete(child)
+	}
+}
+
+// wait waits until n bytes received are allowed by the rate limit.
+// Messages larger than burst take tokens of several bursts.
+func (l *childLimiter) wait(child string, n int) {
+	if l == nil {
+		return
+	}
+	atomic.AddUint64(&l.received, uint64(n))
+	now := time.Now()
+	var delay time.Duration
+	for burst := int(l.conf.Burst); n > 0; n -= burst {
+		tokens := n
+		if tokens > burst {
+			tokens = burst
+		}
+		delay = l.limiter.ReserveN(now, tokens).DelayFrom(now)
+	}
+	if delay <= 0 {
+		return
+	}
+	klog.V(3).Infof("reading from %s is throttled for %v", child, delay)
+	atomic.StoreInt32(&l.throttled, 1)
+	atomic.AddUint64(&l.throttledMessages, 1)
+	time.Sleep(delay)
+	atomic.AddInt64(&l.throttledNanos, int64(delay))
+	atomic.StoreInt32(&l.throttled, 0)
+}
+
+func (l *childLimiter) stats() RateLimitStats {
+	return RateLimitStats{
+		Limit:             l.conf.Limit,
+		Burst:             l.conf.Burst,
+		Throttled:         atomic.LoadInt32(&l.throttled) == 1,
+		Received:          atomic.LoadUint64(&l.received),
+		ThrottledMessages: atomic.LoadUint64(&l.throttledMessages),
+		ThrottledSeconds:  time.Duration(atomic.LoadInt64(&l.throttledNanos)).Seconds(),
+	}
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestChildLimiter(t *testing.T, child string, conf RateLimitConfig) *childLimiter {
+	assert.Nil(t, SetRateLimit(conf))
+	defer SetRateLimit(RateLimitConfig{})
+	return newChildLimiter(child)
+}
+
+func TestSetRateLimit(t *testing.T) {
+	assert.NotNil(t, SetRateLimit(RateLimitConfig{Limit: -1}))
+	assert.NotNil(t, SetRateLimit(RateLimitConfig{Limit: 1, Burst: -1}))
+	assert.Nil(t, SetRateLimit(RateLimitConfig{Limit: 100}))
+	assert.Equal(t, int64(100), rateLimitConf.Burst)
+	assert.Nil(t, SetRateLimit(RateLimitConfig{}))
+
+	// no limiter without limit.
+	l := newChildLimiter("rate-none")
+	assert.Nil(t, l)
+	l.wait("rate-none", 1024)
+	removeChildLimiter("rate-none", l)
+	assert.NotContains(t, GetRateLimitStats(), "rate-none")
+}
+
+func TestChildLimiter(t *testing.T) {
+	l := newTestChildLimiter(t, "rate-child", RateLimitConfig{Limit: 10000, Burst: 1000})
+	defer removeChildLimiter("rate-child", l)
+
+	// a burst is not delayed.
+	start := time.Now()
+	l.wait("rate-child", 1000)
+	assert.True(t, time.Since(start) < 50*time.Millisecond)
+	stats := GetRateLimitStats()["rate-child"]
+	assert.Equal(t, uint64(1000), stats.Received)
+	assert.Equal(t, uint64(0), stats.ThrottledMessages)
+
+	// a message larger than burst waits for tokens of several bursts.
+	done := make(chan struct{})
+	go func() {
+		l.wait("rate-child", 2000)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, GetRateLimitStats()["rate-child"].Throttled)
+	<-done
+	assert.True(t, time.Since(start) >= 150*time.Millisecond)
+	stats = GetRateLimitStats()["rate-child"]
+	assert.False(t, stats.Throttled)
+	assert.Equal(t, uint64(3000), stats.Received)
+	assert.Equal(t, uint64(1), stats.ThrottledMessages)
+	assert.True(t, stats.ThrottledSeconds > 0.1)
+	assert.Equal(t, int64(10000), stats.Limit)
+	assert.Equal(t, int64(1000), stats.Burst)
+
+	// only the current limiter of a child is removed.
+	removeChildLimiter("rate-child", &childLimiter{})
+	assert.Contains(t, GetRateLimitStats(), "rate-child")
+}
+
+func TestRateLimitHandler(t *testing.T) {
+	l := newTestChildLimiter(t, "rate-handler", RateLimitConfig{Limit: 100})
+	defer removeChildLimiter("rate-handler", l)
+
+	w := httptest.NewRecorder()
+	RateLimitHandler(w, httptest.NewRequest("GET", "/rate-limits", nil))
+	stats := make(map[string]RateLimitStats)
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, int64(100), stats["rate-handler"].Limit)
+}
@@ -0,0 +1,206 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+const (
+	// SendQueuePolicyDrop drops a message sent to a full send queue.
+	SendQueuePolicyDrop = "drop"
+	// SendQueuePolicyOldest drops the oldest message in a full send queue for the one sent.
+	SendQueuePolicyOldest = "oldest"
+	// SendQueuePolicyBlock blocks the sender until the send queue is not full.
+	SendQueuePolicyBlock = "block"
+)
+
+// ErrSendQueueFull is returned by sending a message dropped since the send queue is full.
+var ErrSendQueueFull = fmt.Errorf("send queue is full")
+
+// SendQueueConfig defines the send queue of each connection of tunnels.
+type SendQueueConfig struct {
+	// Limit is the max number of messages waiting to be written to a connection,
+	// 0 means no limit.
+	Limit int
+	// Policy is the policy of sending a message to a full send queue, drop if empty.
+	Policy string
+}
+
+// SendQueueStats is the stats of send queues of connections of a peer.
+type SendQueueStats struct {
+	// Queued is the number of messages waiting to be written.
+	Queued int64 `json:"queued"`
+	// Dropped is the number of messages dropped since the send queue is full.
+	Dropped uint64 `json:"dropped"`
+}
+
+var (
+	sendQueueLock sync.RWMutex
+	sendQueueConf SendQueueConfig
+	// sendQueueStats are the stats of send queues by peer name, kept across reconnections.
+	sendQueueStats sync.Map // string -> *SendQueueStats
+)
+
+// SetSendQueue sets the send queue of connections established after that.
+func SetSendQueue(conf SendQueueConfig) error {
+	if conf.Limit < 0 {
+		return fmt.Errorf("send queue limit cannot be negative")
+	}
+	switch conf.Policy {
+	case "":
+		conf.Policy = SendQueuePolicyDrop
+	case SendQueuePolicyDrop, SendQueuePolicyOldest, SendQueuePolicyBlock:
+	default:
+		return fmt.Errorf("send queue policy %s is not supported", conf.Policy)
+	}
+	sendQueueLock.Lock()
+	defer sendQueueLock.Unlock()
+	sendQueueConf = conf
+	return nil
+}
+
+// GetSendQueueStats returns the stats of send queues by peer name.
+func GetSendQueueStats() map[string]SendQueueStats {
+	ret := make(map[string]SendQueueStats)
+	sendQueueStats.Range(func(key, value interface{}) bool {
+		stats := value.(*SendQueueStats)
+		ret[key.(string)] = SendQueueStats{
+			Queued:  atomic.LoadInt64(&stats.Queued),
+			Dropped: atomic.LoadUint64(&stats.Dropped),
+		}
+		return true
+	})
+	return ret
+}
+
+// SendQueueHandler is the http handler to get stats of send queues in json.
+func SendQueueHandler(w http.ResponseWriter, r *http.Request) {
+	data, _ := json.Marshal(GetSendQueueStats())
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// sendQueue orders writers of a connection, at most one of them writes at a time,
+// and the others wait in lanes by priority of their messages.
+// The turn to write is passed to writers of the most urgent lane first,
+// so that urgent messages are not delayed by bursts of reports.
+type sendQueue struct {
+	limit  int
+	policy string
+	stats  *SendQueueStats
+
+	lock    sync.Mutex
+	notFull *sync.Cond
+	writing bool
+	// waiting are turns of writers waiting by priority.
+	waiting [clustermessage.Priorities]*list.List // chan error
+}
+
+func newSendQueue(name string) *sendQueue {
+	sendQueueLock.RLock()
+	conf := sendQueueConf
+	sendQueueLock.RUnlock()
+	stats, _ := sendQueueStats.LoadOrStore(name, &SendQueueStats{})
+	q := &sendQueue{
+		limit:  conf.Limit,
+		policy: conf.Policy,
+		stats:  stats.(*SendQueueStats),
+	}
+	for i := range q.waiting {
+		q.waiting[i] = list.New()
+	}
+	q.notFull = sync.NewCond(&q.lock)
+	return q
+}
+
+// acquire waits for the turn to write a message of priority,
+// and returns ErrSendQueueFull if the message is dropped.
+// The limit applies to each lane, a full lane of reports does not drop urgent messages.
+// release must be called after written if acquired.
+func (q *sendQueue) acquire(priority clustermessage.Priority) error {
+	if q == nil {
+		return nil
+	}
+	if priority < 0 || int(priority) >= len(q.waiting) {
+		priority = clustermessage.PriorityReport
+	}
+	lane := q.waiting[priority]
+	q.lock.Lock()
+	for q.writing && q.limit > 0 && lane.Len() >= q.limit {
+		switch q.policy {
+		case SendQueuePolicyBlock:
+			q.notFull.Wait()
+		case SendQueuePolicyOldest:
+			oldest := lane.Remove(lane.Front()).(chan error)
+			atomic.AddInt64(&q.stats.Queued, -1)
+			atomic.AddUint64(&q.stats.Dropped, 1)
+			oldest <- ErrSendQueueFull
+		default:
+			atomic.AddUint64(&q.stats.Dropped, 1)
+			q.lock.Unlock()
+			return ErrSendQueueFull
+		}
+	}
+	if !q.writing {
+		q.writing = true
+		q.lock.Unlock()
+		return nil
+	}
+	turn := make(chan error, 1)
+	lane.PushBack(turn)
+	atomic.AddInt64(&q.stats.Queued, 1)
+	q.lock.Unlock()
+	return <-turn
+}
+
+// release passes the turn to write to the next writer waiting in the most urgent lane.
+func (q *sendQueue) release() {
+	if q == nil {
+		return
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	// writers blocked may wait for different lanes.
+	defer q.notFull.Broadcast()
+	for _, lane := range q.waiting {
+		if lane.Len() != 0 {
+			next := lane.Remove(lane.Front()).(chan error)
+			atomic.AddInt64(&q.stats.Queued, -1)
+			next <- nil
+			return
+		}
+	}
+	q.writing = false
+}
+
+// idle returns true if no message is being written or waiting to be written.
+func (q *sendQueue) idle() bool {
+	if q == nil {
+		return true
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return !q.writing
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+)
+
+func newTestSendQueue(t *testing.T, name string, conf SendQueueConfig) *sendQueue {
+	assert.Nil(t, SetSendQueue(conf))
+	defer SetSendQueue(SendQueueConfig{})
+	sendQueueStats.Delete(name)
+	return newSendQueue(name)
+}
+
+// waitQueued waits until n writers are waiting in q.
+func waitQueued(q *sendQueue, n int) {
+	for i := 0; i < 100; i++ {
+		l := 0
+		q.lock.Lock()
+		for _, lane := range q.waiting {
+			l += lane.Len()
+		}
+		q.lock.Unlock()
+		if l == n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSetSendQueue(t *testing.T) {
+	assert.NotNil(t, SetSendQueue(SendQueueConfig{Limit: -1}))
+	assert.NotNil(t, SetSendQueue(SendQueueConfig{Policy: "unknown"}))
+	assert.Nil(t, SetSendQueue(SendQueueConfig{Limit: 1}))
+	assert.Equal(t, SendQueuePolicyDrop, sendQueueConf.Policy)
+	assert.Nil(t, SetSendQueue(SendQueueConfig{}))
+}
+
+func TestSendQueueDrop(t *testing.T) {
+	q := newTestSendQueue(t, "queue-drop", SendQueueConfig{Limit: 1, Policy: SendQueuePolicyDrop})
+	assert.Nil(t, q.acquire(clustermessage.PriorityReport))
+	second := make(chan error, 1)
+	go func() { second <- q.acquire(clustermessage.PriorityReport) }()
+	waitQueued(q, 1)
+
+	// the queue is full, messages sent are dropped.
+	assert.Equal(t, ErrSendQueueFull, q.acquire(clustermessage.PriorityReport))
+	q.release()
+	assert.Nil(t, <-second)
+	q.release()
+	assert.Nil(t, q.acquire(clustermessage.PriorityReport))
+	q.release()
+
+	stats := GetSendQueueStats()["queue-drop"]
+	assert.Equal(t, SendQueueStats{Queued: 0, Dropped: 1}, stats)
+}
+
+func TestSendQueueOldest(t *testing.T) {
+	q := newTestSendQueue(t, "queue-oldest", SendQueueConfig{Limit: 1, Policy: SendQueuePolicyOldest})
+	assert.Nil(t, q.acquire(clustermessage.PriorityReport))
+	oldest := make(chan error, 1)
+	go func() { oldest <- q.acquire(clustermessage.PriorityReport) }()
+	waitQueued(q, 1)
+
+	// the oldest one waiting is dropped for the new one.
+	newest := make(chan error, 1)
+	go func() { newest <- q.acquire(clustermessage.PriorityReport) }()
+	assert.Equal(t, ErrSendQueueFull, <-oldest)
+	waitQueued(q, 1)
+	q.release()
+	assert.Nil(t, <-newest)
+	q.release()
+
+	assert.Equal(t, uint64(1), GetSendQueueStats()["queue-oldest"].Dropped)
+}
+
+func TestSendQueueBlock(t *testing.T) {
+	q := newTestSendQueue(t, "queue-block", SendQueueConfig{Limit: 1, Policy: SendQueuePolicyBlock})
+	assert.Nil(t, q.acquire(clustermessage.PriorityReport))
+	second := make(chan error, 1)
+	go func() { second <- q.acquire(clustermessage.PriorityReport) }()
+	waitQueued(q, 1)
+
+	// the sender is blocked until the queue is not full.
+	third := make(chan error, 1)
+	go func() { third <- q.acquire(clustermessage.PriorityReport) }()
+	select {
+	case <-third:
+		assert.Fail(t, "sender is not blocked by full queue")
+	case <-time.After(100 * time.Millisecond):
+	}
+	q.release()
+	assert.Nil(t, <-second)
+	q.release()
+	assert.Nil(t, <-third)
+	q.release()
+
+	assert.Equal(t, uint64(0), GetSendQueueStats()["queue-block"].Dropped)
+}
+
+func TestSendQueuePriority(t *testing.T) {
+	q := newTestSendQueue(t, "queue-priority", SendQueueConfig{Limit: 1, Policy: SendQueuePolicyDrop})
+	assert.Nil(t, q.acquire(clustermessage.PriorityReport))
+	report := make(chan error, 1)
+	go func() { report <- q.acquire(clustermessage.PriorityReport) }()
+	waitQueued(q, 1)
+	// the lane of reports is full, but urgent messages are still queued.
+	assert.Equal(t, ErrSendQueueFull, q.acquire(clustermessage.PriorityReport))
+	route := make(chan error, 1)
+	go func() { route <- q.acquire(clustermessage.PriorityRoute) }()
+	waitQueued(q, 2)
+	control := make(chan error, 1)
+	go func() { control <- q.acquire(clustermessage.PriorityControl) }()
+	waitQueued(q, 3)
+
+	// turns are passed by priority rather than the order queued.
+	q.release()
+	assert.Nil(t, <-control)
+	q.release()
+	assert.Nil(t, <-route)
+	q.release()
+	assert.Nil(t, <-report)
+	q.release()
+
+	assert.Equal(t, uint64(1), GetSendQueueStats()["queue-priority"].Dropped)
+	assert.Equal(t, int64(0), GetSendQueueStats()["queue-priority"].Queued)
+}
+
+func TestSendQueueHandler(t *testing.T) {
+	q := newTestSendQueue(t, "queue-handler", SendQueueConfig{})
+	assert.Nil(t, q.acquire(clustermessage.PriorityReport))
+	q.release()
+
+	w := httptest.NewRecorder()
+	SendQueueHandler(w, httptest.NewRequest("GET", "/send-queues", nil))
+	stats := make(map[string]SendQueueStats)
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Contains(t, stats, "queue-handler")
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// http2Preface is the client preface of http2, grpc connections in plain text start with it.
+const http2Preface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+/*
+splitListener accepts connections of a listener, and hands over them to the grpc listener
+or the http listener, so that cloud tunnel serves childs by grpc and websocket on the same address.
+A connection is of grpc if h2 is negotiated by tls, or it starts with the http2 preface in plain text.
+*/
+type splitListener struct {
+	ln   net.Listener
+	grpc *subListener
+	http *subListener
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// subListener is the listener of connections of a protocol.
+type subListener struct {
+	parent *splitListener
+	conns  chan net.Conn
+}
+
+// splitGRPC returns the listeners of grpc and http connections accepted by ln.
+func splitGRPC(ln net.Listener) (net.Listener, net.Listener) {
+	s := &splitListener{ln: ln, done: make(chan struct{})}
+	s.grpc = &subListener{parent: s, conns: make(chan net.Conn)}
+	s.http = &subListener{parent: s, conns: make(chan net.Conn)}
+	go s.serve()
+	return s.grpc, s.http
+}
+
+func (s *splitListener) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			s.close()
+			return
+		}
+		go s.route(conn)
+	}
+}
+
+// route hands over conn to the listener of its protocol.
+func (s *splitListener) route(conn net.Conn) {
+	isGRPC, conn, err := sniffGRPC(conn)
+	if err != nil {
+		klog.V(3).Infof("drop connection from %s: %v", conn.RemoteAddr(), err)
+		conn.Close()
+		return
+	}
+	sub := s.http
+	if isGRPC {
+		sub = s.grpc
+	}
+	select {
+	case sub.conns <- conn:
+	case <-s.done:
+		conn.Close()
+	}
+}
+
+// sniffGRPC returns whether conn is of grpc, and the conn to read from the beginning.
+func sniffGRPC(conn net.Conn) (bool, net.Conn, error) {
+	conn.SetDeadline(time.Now().Add(ReadTimeout))
+	defer conn.SetDeadline(time.Time{})
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if err := tlsConn.Handshake(); err != nil {
+			return false, conn, err
+		}
+		return tlsConn.ConnectionState().NegotiatedProtocol == "h2", conn, nil
+	}
+
+	r := bufio.NewReader(conn)
+	buffered := &bufferedConn{Conn: conn, r: r}
+	for i := 1; i <= len(http2Preface); i++ {
+		peeked, err := r.Peek(i)
+		if err != nil {
+			return false, buffered, err
+		}
+		if peeked[i-1] != http2Preface[i-1] {
+			return false, buffered, nil
+		}
+	}
+	return true, buffered, nil
+}
+
+func (s *splitListener) close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+		err = s.ln.Close()
+	})
+	return err
+}
+
+func (l *subListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.parent.done:
+		return nil, fmt.Errorf("listener is closed")
+	}
+}
+
+// Close closes the listener split, so that both listeners are closed.
+func (l *subListener) Close() error {
+	return l.parent.close()
+}
+
+func (l *subListener) Addr() net.Addr {
+	return l.parent.ln.Addr()
+}
+
+// bufferedConn is a connection whose bytes sniffed are read first.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (b *bufferedConn) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
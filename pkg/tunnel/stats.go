@@ -0,0 +1,214 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tunnel
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Roles of peers of tunnel connections.
+const (
+	// RoleParent is the role of the parent connected by an edge tunnel.
+	RoleParent = "parent"
+	// RoleShadowParent is the role of the shadow parent connected by an edge tunnel.
+	RoleShadowParent = "shadow-parent"
+	// RoleChild is the role of a child connected to a cloud tunnel.
+	RoleChild = "child"
+	// RoleControllerManager is the role of a controller manager connected to a cloud tunnel.
+	RoleControllerManager = "controller-manager"
+	// RoleRoot is the role of the root connected by a controller tunnel.
+	RoleRoot = "root"
+)
+
+// Protocols of tunnel connections.
+const (
+	ProtocolWebsocket = "websocket"
+	ProtocolGRPC      = "grpc"
+)
+
+// ConnectionStats is the stats of a tunnel connection.
+type ConnectionStats struct {
+	// Peer is the name of a child, or the address of others.
+	Peer     string `json:"peer"`
+	Role     string `json:"role"`
+	Protocol string `json:"protocol"`
+	// ConnectedAt is the time the connection is established.
+	ConnectedAt      time.Time `json:"connectedAt"`
+	BytesSent        uint64    `json:"bytesSent"`
+	BytesReceived    uint64    `json:"bytesReceived"`
+	MessagesSent     uint64    `json:"messagesSent"`
+	MessagesReceived uint64    `json:"messagesReceived"`
+	// RTT is the round trip time of the last heartbeat, 0 if not measured,
+	// e.g., by cloud tunnels and grpc connections, which do not ping.
+	RTT time.Duration `json:"rtt"`
+	// Reconnects is the times connected again since the first connection of the process,
+	// to any parent for edge tunnels, or by the child for cloud tunnels.
+	Reconnects uint64 `json:"reconnects"`
+}
+
+// StatsProvider provides stats of tunnel connections.
+type StatsProvider interface {
+	// Stats returns stats of connections established.
+	Stats() []ConnectionStats
+}
+
+// connStats are counters of a connection, updated atomically.
+type connStats struct {
+	connectedAt      time.Time
+	bytesSent        uint64
+	bytesReceived    uint64
+	messagesSent     uint64
+	messagesReceived uint64
+	// rtt is the nanoseconds of the last heartbeat.
+	rtt int64
+
+	// role, peer and connects are set by track.
+	role     string
+	peer     string
+	connects *uint64
+}
+
+var (
+	// trackedClients are clients of connections established, whose stats are provided.
+	trackedClients sync.Map // *WSClient -> struct{}
+	// connectCounts are times connected by key of track, kept across reconnections.
+	connectCounts sync.Map // string -> *uint64
+)
+
+// Stats returns stats of all tunnel connections established by the process, ordered by role and peer.
+func Stats() []ConnectionStats {
+	var clients []*WSClient
+	trackedClients.Range(func(k, v interface{}) bool {
+		clients = append(clients, k.(*WSClient))
+		return true
+	})
+	return clientStats(clients...)
+}
+
+// StatsHandler is the http handler to get stats of all tunnel connections in json.
+func StatsHandler(w http.ResponseWriter, r *http.Request) {
+	data, _ := json.Marshal(Stats())
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// clientStats returns stats of clients tracked, ordered by role and peer.
+func clientStats(clients ...*WSClient) []ConnectionStats {
+	ret := []ConnectionStats{}
+	for _, c := range clients {
+		if c == nil || c.stats == nil || c.stats.connects == nil {
+			continue
+		}
+		s := c.stats
+		protocol := ProtocolGRPC
+		if c.Conn != nil {
+			protocol = ProtocolWebsocket
+		}
+		stats := ConnectionStats{
+			Peer:             s.peer,
+			Role:             s.role,
+			Protocol:         protocol,
+			ConnectedAt:      s.connectedAt,
+			BytesSent:        atomic.LoadUint64(&s.bytesSent),
+			BytesReceived:    atomic.LoadUint64(&s.bytesReceived),
+			MessagesSent:     atomic.LoadUint64(&s.messagesSent),
+			MessagesReceived: atomic.LoadUint64(&s.messagesReceived),
+			RTT:              time.Duration(atomic.LoadInt64(&s.rtt)),
+		}
+		if n := atomic.LoadUint64(s.connects); n > 0 {
+			stats.Reconnects = n - 1
+		}
+		ret = append(ret, stats)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Role != ret[j].Role {
+			return ret[i].Role < ret[j].Role
+		}
+		return ret[i].Peer < ret[j].Peer
+	})
+	return ret
+}
+
+/*
+track provides stats of c connected with peer of role, after the connection is established.
+Connections of the same key are counted, so that the times reconnected are kept,
+e.g., by the name of a child.
+*/
+func (c *WSClient) track(role, peer, key string) {
+	count, _ := connectCounts.LoadOrStore(key, new(uint64))
+	atomic.AddUint64(count.(*uint64), 1)
+	c.stats.role = role
+	c.stats.peer = peer
+	c.stats.connects = count.(*uint64)
+	trackedClients.Store(c, struct{}{})
+}
+
+// untrack stops providing stats of c once its connection is closed.
+func (c *WSClient) untrack() {
+	trackedClients.Delete(c)
+}
+
+// sent counts a message of n bytes written, counting by nil s is a noop.
+func (s *connStats) sent(n int) {
+	if s == nil {
+		return
+	}
+	atomic.AddUint64(&s.bytesSent, uint64(n))
+	atomic.AddUint64(&s.messagesSent, 1)
+}
+
+// received counts a frame of n bytes read.
+func (s *connStats) received(n int) {
+	if s == nil {
+		return
+	}
+	atomic.AddUint64(&s.bytesReceived, uint64(n))
+}
+
+// receivedMessage counts a message read.
+func (s *connStats) receivedMessage() {
+	if s == nil {
+		return
+	}
+	atomic.AddUint64(&s.messagesReceived, 1)
+}
+
+// pingData returns the payload of a ping sent at now, which is echoed by the pong.
+func pingData(now time.Time) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, uint64(now.UnixNano()))
+	return data
+}
+
+// pong records the round trip time of the ping echoed in data at now.
+func (s *connStats) pong(data string, now time.Time) {
+	if s == nil {
+		return
+	}
+	if len(data) != 8 {
+		return
+	}
+	sent := time.Unix(0, int64(binary.BigEndian.Uint64([]byte(data))))
+	if rtt := now.Sub(sent); rtt >= 0 {
+		atomic.StoreInt64(&s.rtt, int64(rtt))
+	}
+}
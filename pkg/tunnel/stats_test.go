@@ -0,0 +1,138 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tunnel
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/config"
+)
+
+func TestPong(t *testing.T) {
+	s := &connStats{}
+	now := time.Now()
+	s.pong(string(pingData(now)), now.Add(30*time.Millisecond))
+	assert.Equal(t, 30*time.Millisecond, time.Duration(s.rtt))
+	// pongs of others are ignored.
+	s.pong("", now)
+	s.pong(string(pingData(now)), now.Add(-time.Second))
+	assert.Equal(t, 30*time.Millisecond, time.Duration(s.rtt))
+
+	// counting by clients without stats is a noop.
+	var empty *connStats
+	empty.sent(1)
+	empty.received(1)
+	empty.pong("", now)
+	assert.Len(t, clientStats(&WSClient{}), 0)
+}
+
+// findStats returns stats of peer of role in stats.
+func findStats(stats []ConnectionStats, role, peer string) *ConnectionStats {
+	for i := range stats {
+		if stats[i].Role == role && stats[i].Peer == peer {
+			return &stats[i]
+		}
+	}
+	return nil
+}
+
+func TestConnectionStats(t *testing.T) {
+	connected := make(chan *config.ClusterRegistry, 2)
+	received := make(chan []byte, 2)
+	ct := NewCloudTunnel("127.0.0.1:0").(*cloudTunnel)
+	ct.RegistAfterConnectHook(func(cr *config.ClusterRegistry) {
+		connected <- cr
+	})
+	ct.RegistReturnMessageFunc(func(client string, msg []byte) error {
+		received <- msg
+		return nil
+	})
+	assert.Nil(t, ct.Start())
+	defer ct.Stop()
+
+	connectCounts.Delete(RoleChild + "/stats-child")
+	e := newTestEdgeTunnel()
+	e.name = "stats-child"
+	e.cloudAddr = ct.server.Addr
+	e.heartbeatInterval = 10 * time.Millisecond
+	e.heartbeatTimeout = time.Second
+	for i := 0; i < 2; i++ {
+		if e.wsclient != nil {
+			// reconnect once the parent finds the connection closed.
+			e.wsclient.Close()
+			for j := 0; j < 100; j++ {
+				if _, ok := ct.clients.Load("stats-child"); !ok {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+		assert.Nil(t, e.connect())
+		select {
+		case <-connected:
+		case <-time.After(5 * time.Second):
+			t.Fatal("cluster is not connected")
+		}
+	}
+	defer e.wsclient.Close()
+	// pongs are handled while reading.
+	go func(c *WSClient) {
+		for {
+			if _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}(e.wsclient)
+
+	msg := []byte("hello")
+	assert.Nil(t, e.Send(msg))
+	<-received
+	time.Sleep(100 * time.Millisecond)
+
+	edgeStats := e.Stats()
+	assert.Len(t, edgeStats, 1)
+	s := edgeStats[0]
+	assert.Equal(t, RoleParent, s.Role)
+	assert.Equal(t, ct.server.Addr, s.Peer)
+	assert.Equal(t, ProtocolWebsocket, s.Protocol)
+	assert.Equal(t, uint64(1), s.MessagesSent)
+	assert.Equal(t, uint64(len(msg)), s.BytesSent)
+	assert.True(t, s.RTT > 0)
+	assert.False(t, s.ConnectedAt.IsZero())
+
+	cloudStats := ct.Stats()
+	assert.Len(t, cloudStats, 1)
+	s = cloudStats[0]
+	assert.Equal(t, RoleChild, s.Role)
+	assert.Equal(t, "stats-child", s.Peer)
+	assert.Equal(t, uint64(1), s.MessagesReceived)
+	assert.Equal(t, uint64(len(msg)), s.BytesReceived)
+	assert.Equal(t, uint64(1), s.Reconnects)
+
+	// stats of all connections of the process.
+	w := httptest.NewRecorder()
+	StatsHandler(w, httptest.NewRequest(http.MethodGet, "/tunnel-stats", nil))
+	var all []ConnectionStats
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &all))
+	assert.NotNil(t, findStats(all, RoleChild, "stats-child"))
+	assert.NotNil(t, findStats(all, RoleParent, ct.server.Addr))
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"k8s.io/klog"
+)
+
+// ControllerManagerCertName is the common name of the client certificate of ote controller manager,
+// required to connect to a cloud tunnel with mutual tls.
+const ControllerManagerCertName = "ote-controller-manager"
+
+// TLSConfig is the config of mutual tls between tunnels.
+type TLSConfig struct {
+	// CAFile is the ca to verify certificates of parents and childs.
+	CAFile string
+	// CertFile and KeyFile are the certificate presented to childs as a server,
+	// and to the parent as a client.
+	CertFile string
+	KeyFile  string
+}
+
+var (
+	tlsLock sync.RWMutex
+	// serverTLS is the tls config of cloud tunnel, nil if tunnels are in plain text.
+	serverTLS *tls.Config
+	// clientTLS is the tls config to connect to cloud tunnels, nil if tunnels are in plain text.
+	clientTLS *tls.Config
+	// tlsFiles are the files of tls set up, and tlsContent is the content loaded from them.
+	tlsFiles   TLSConfig
+	tlsContent [][]byte
+)
+
+/*
+SetupTLS enables mutual tls of tunnels by c, or disables it if c is empty.
+Cloud tunnels started after it serves childs with tls, and requires client certificates
+signed by the ca, while edge tunnels and controller tunnels connect to parents with
+the certificate and verify parents by the ca.
+*/
+func SetupTLS(c TLSConfig) error {
+	if c.CAFile == "" && c.CertFile == "" && c.KeyFile == "" {
+		tlsLock.Lock()
+		defer tlsLock.Unlock()
+		serverTLS, clientTLS = nil, nil
+		tlsFiles, tlsContent = TLSConfig{}, nil
+		return nil
+	}
+	if c.CAFile == "" || c.CertFile == "" || c.KeyFile == "" {
+		return fmt.Errorf("ca, cert and key are all required by tunnel tls")
+	}
+	content, err := readTLSFiles(c)
+	if err != nil {
+		return err
+	}
+	return loadTLS(c, content)
+}
+
+// readTLSFiles returns the content of ca, cert and key files of c.
+func readTLSFiles(c TLSConfig) ([][]byte, error) {
+	ca, err := ioutil.ReadFile(c.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("read tunnel tls ca failed: %v", err)
+	}
+	cert, err := ioutil.ReadFile(c.CertFile)
+	if err != nil {
+		return nil, fmt.Errorf("read tunnel tls cert failed: %v", err)
+	}
+	key, err := ioutil.ReadFile(c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("read tunnel tls key failed: %v", err)
+	}
+	return [][]byte{ca, cert, key}, nil
+}
+
+// loadTLS replaces tls configs with content of ca, cert and key read from files of c.
+func loadTLS(c TLSConfig, content [][]byte) error {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(content[0]) {
+		return fmt.Errorf("no certificate is found in tunnel tls ca %s", c.CAFile)
+	}
+	cert, err := tls.X509KeyPair(content[1], content[2])
+	if err != nil {
+		return fmt.Errorf("load tunnel tls cert failed: %v", err)
+	}
+
+	tlsLock.Lock()
+	defer tlsLock.Unlock()
+	tlsFiles, tlsContent = c, content
+	serverTLS = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+		// childs connecting by grpc negotiate h2.
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+	clientTLS = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		MinVersion:   tls.VersionTLS12,
+	}
+	return nil
+}
+
+/*
+ReloadTLS loads the files of tls set up again if any of them changed, e.g., rotated by a renewer
+or a Kubernetes Secret mounted, and returns true if reloaded. Cloud tunnels serve handshakes
+after it with the new certificate and ca, and tunnels dial with them, while connections
+established are kept. The tls in use is kept if the files fail to load.
+*/
+func ReloadTLS() (bool, error) {
+	tlsLock.RLock()
+	c, loaded := tlsFiles, tlsContent
+	tlsLock.RUnlock()
+	if loaded == nil {
+		return false, nil
+	}
+	content, err := readTLSFiles(c)
+	if err != nil {
+		return false, err
+	}
+	changed := false
+	for i := range content {
+		if !bytes.Equal(content[i], loaded[i]) {
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+	if err := loadTLS(c, content); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// WatchTLS reloads tls every interval until stop is closed, see ReloadTLS.
+func WatchTLS(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		reloaded, err := ReloadTLS()
+		if err != nil {
+			klog.Errorf("reload tunnel tls failed, keep the one in use: %v", err)
+		} else if reloaded {
+			klog.Infof("tunnel tls is reloaded")
+		}
+	}
+}
+
+// serverTLSForClient returns the tls config of cloud tunnel in use to serve a handshake,
+// so that certificates reloaded are served without restarting the listener.
+func serverTLSForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	return getServerTLS(), nil
+}
+
+func getServerTLS() *tls.Config {
+	tlsLock.RLock()
+	defer tlsLock.RUnlock()
+	return serverTLS
+}
+
+func getClientTLS() *tls.Config {
+	tlsLock.RLock()
+	defer tlsLock.RUnlock()
+	if clientTLS == nil {
+		return nil
+	}
+	return clientTLS.Clone()
+}
+
+// tunnelDialer returns the scheme and dialer to connect to cloud tunnels, by the proxy if set.
+func tunnelDialer() (string, *websocket.Dialer) {
+	dialer := *websocket.DefaultDialer
+	scheme := "ws"
+	if tlsConfig := getClientTLS(); tlsConfig != nil {
+		dialer.TLSClientConfig = tlsConfig
+		scheme = "wss"
+	}
+	if d := getProxyDialer(); d != nil {
+		dialer.Proxy = nil
+		dialer.NetDial = d.Dial
+	}
+	return scheme, &dialer
+}
+
+/*
+verifyPeerName returns error if the connection of tls state is not of name,
+i.e., neither the common name nor dns names of the subject is name,
+so that a cluster can not register with the name of another one.
+*/
+func verifyPeerName(state *tls.ConnectionState, name string) error {
+	if state == nil {
+		if getServerTLS() != nil {
+			return fmt.Errorf("client certificate is required")
+		}
+		return nil
+	}
+	if len(state.PeerCertificates) == 0 {
+		return fmt.Errorf("client certificate is required")
+	}
+	cert := state.PeerCertificates[0]
+	if cert.Subject.CommonName == name {
+		return nil
+	}
+	for _, dns := range cert.DNSNames {
+		if dns == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("client certificate of %s is not of %s", cert.Subject.CommonName, name)
+}
@@ -0,0 +1,238 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tunnel
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/config"
+)
+
+// testCA signs certificates of tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	dir  string
+}
+
+func newTestCA(t *testing.T) *testCA {
+	dir, err := ioutil.TempDir("", "tunneltls")
+	assert.Nil(t, err)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ote-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+	ca := &testCA{cert: cert, key: key, dir: dir}
+	writePEM(t, ca.file("ca.crt"), "CERTIFICATE", der)
+	return ca
+}
+
+func (ca *testCA) file(name string) string {
+	return filepath.Join(ca.dir, name)
+}
+
+// issue writes cert and key files of name, valid for 127.0.0.1, and returns the tls config of them.
+func (ca *testCA) issue(t *testing.T, name string) TLSConfig {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	assert.Nil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	writePEM(t, ca.file(name+".crt"), "CERTIFICATE", der)
+	writePEM(t, ca.file(name+".key"), "EC PRIVATE KEY", keyDER)
+	return TLSConfig{CAFile: ca.file("ca.crt"), CertFile: ca.file(name + ".crt"), KeyFile: ca.file(name + ".key")}
+}
+
+func writePEM(t *testing.T, file, typ string, der []byte) {
+	err := ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600)
+	assert.Nil(t, err)
+}
+
+func TestSetupTLS(t *testing.T) {
+	ca := newTestCA(t)
+	defer os.RemoveAll(ca.dir)
+	defer SetupTLS(TLSConfig{})
+
+	assert.Nil(t, SetupTLS(TLSConfig{}))
+	scheme, _ := tunnelDialer()
+	assert.Equal(t, "ws", scheme)
+	assert.Nil(t, getServerTLS())
+
+	assert.NotNil(t, SetupTLS(TLSConfig{CAFile: ca.file("ca.crt")}))
+	assert.NotNil(t, SetupTLS(TLSConfig{CAFile: "notexist", CertFile: "notexist", KeyFile: "notexist"}))
+
+	conf := ca.issue(t, "c1")
+	assert.Nil(t, SetupTLS(conf))
+	scheme, dialer := tunnelDialer()
+	assert.Equal(t, "wss", scheme)
+	assert.Len(t, dialer.TLSClientConfig.Certificates, 1)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, getServerTLS().ClientAuth)
+}
+
+func TestVerifyPeerName(t *testing.T) {
+	assert.Nil(t, verifyPeerName(nil, "c1"))
+
+	state := &tls.ConnectionState{}
+	assert.NotNil(t, verifyPeerName(state, "c1"))
+
+	state.PeerCertificates = []*x509.Certificate{{
+		Subject:  pkix.Name{CommonName: "c1"},
+		DNSNames: []string{"c1.edge"},
+	}}
+	assert.Nil(t, verifyPeerName(state, "c1"))
+	assert.Nil(t, verifyPeerName(state, "c1.edge"))
+	assert.NotNil(t, verifyPeerName(state, "c2"))
+}
+
+func TestReloadTLS(t *testing.T) {
+	ca := newTestCA(t)
+	defer os.RemoveAll(ca.dir)
+	defer SetupTLS(TLSConfig{})
+
+	reloaded, err := ReloadTLS()
+	assert.Nil(t, err)
+	assert.False(t, reloaded)
+
+	conf := ca.issue(t, "root")
+	assert.Nil(t, SetupTLS(conf))
+	reloaded, err = ReloadTLS()
+	assert.Nil(t, err)
+	assert.False(t, reloaded)
+
+	ct := NewCloudTunnel("127.0.0.1:0").(*cloudTunnel)
+	assert.Nil(t, ct.Start())
+	defer ct.Stop()
+	handshake := func() (*tls.Conn, *big.Int) {
+		conn, err := tls.Dial("tcp", ct.server.Addr, getClientTLS())
+		assert.Nil(t, err)
+		return conn, conn.ConnectionState().PeerCertificates[0].SerialNumber
+	}
+	established, serial := handshake()
+	defer established.Close()
+
+	// the cert rotated is served to new handshakes.
+	ca.issue(t, "root")
+	reloaded, err = ReloadTLS()
+	assert.Nil(t, err)
+	assert.True(t, reloaded)
+	conn, rotated := handshake()
+	conn.Close()
+	assert.NotEqual(t, serial, rotated)
+
+	// connections established are kept.
+	_, err = established.Write([]byte("GET / HTTP/1.1\r\nHost: root\r\n\r\n"))
+	assert.Nil(t, err)
+	resp, err := http.ReadResponse(bufio.NewReader(established), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// the tls in use is kept if files are broken.
+	assert.Nil(t, ioutil.WriteFile(conf.KeyFile, []byte("broken"), 0600))
+	reloaded, err = ReloadTLS()
+	assert.NotNil(t, err)
+	assert.False(t, reloaded)
+	conn, kept := handshake()
+	conn.Close()
+	assert.Equal(t, rotated, kept)
+}
+
+func TestMutualTLSTunnel(t *testing.T) {
+	ca := newTestCA(t)
+	defer os.RemoveAll(ca.dir)
+	defer SetupTLS(TLSConfig{})
+	assert.Nil(t, SetupTLS(ca.issue(t, "root")))
+
+	registered := make(chan *config.ClusterRegistry, 1)
+	ct := NewCloudTunnel("127.0.0.1:0").(*cloudTunnel)
+	ct.RegistAfterConnectHook(func(cr *config.ClusterRegistry) {
+		registered <- cr
+	})
+	assert.Nil(t, ct.Start())
+
+	dial := func(cluster string) (*http.Response, error) {
+		scheme, dialer := tunnelDialer()
+		header := http.Header{}
+		header.Add(config.ClusterConnectHeaderListenAddr, "fake")
+		header.Add(config.ClusterConnectHeaderUserDefineName, cluster)
+		conn, resp, err := dialer.Dial(fmt.Sprintf("%s://%s%s%s", scheme, ct.server.Addr, accessURI, cluster), header)
+		if err == nil {
+			conn.Close()
+		}
+		return resp, err
+	}
+
+	// the certificate is of c1.
+	assert.Nil(t, SetupTLS(ca.issue(t, "c1")))
+	_, err := dial("c1")
+	assert.Nil(t, err)
+	select {
+	case cr := <-registered:
+		assert.Equal(t, "c1", cr.Name)
+	case <-time.After(5 * time.Second):
+		t.Fatal("cluster is not connected")
+	}
+
+	// c1 can not register as c2.
+	resp, err := dial("c2")
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// clients without certificates are refused by tls handshake.
+	_, dialer := tunnelDialer()
+	dialer.TLSClientConfig.Certificates = nil
+	_, _, err = dialer.Dial(fmt.Sprintf("wss://%s%s%s", ct.server.Addr, accessURI, "c3"), nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tunnel
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/baidu/ote-stack/pkg/config"
+)
+
+// unixHost is the host in urls of requests over unix domain sockets.
+const unixHost = "localhost"
+
+/*
+Listen listens on addr of tcp, or of a unix domain socket like unix:///var/run/ote/tunnel.sock,
+so that components on the same host talk without ports.
+The socket file left by a previous run is removed before listening.
+*/
+func Listen(addr string) (net.Listener, error) {
+	path, ok := config.UnixSocketPath(addr)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a unix socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove stale unix socket %s failed: %v", path, err)
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// UnixDial returns the host in urls to addr, and the function to dial addr
+// if it is of a unix domain socket, nil otherwise.
+func UnixDial(addr string) (string, func(network, address string) (net.Conn, error)) {
+	path, ok := config.UnixSocketPath(addr)
+	if !ok {
+		return addr, nil
+	}
+	return unixHost, func(string, string) (net.Conn, error) {
+		return net.DialTimeout("unix", path, ReadTimeout)
+	}
+}
+
+// unixDialContext returns the function to dial addr with context
+// if it is of a unix domain socket, nil otherwise.
+func unixDialContext(addr string) func(context.Context, string) (net.Conn, error) {
+	path, ok := config.UnixSocketPath(addr)
+	if !ok {
+		return nil
+	}
+	return func(ctx context.Context, _ string) (net.Conn, error) {
+		d := &net.Dialer{}
+		return d.DialContext(ctx, "unix", path)
+	}
+}
+
+// dialHost sets dialer to dial addr over the unix domain socket if it is of one,
+// instead of the proxy, and returns the host in urls to addr.
+func dialHost(addr string, dialer *websocket.Dialer) string {
+	host, dial := UnixDial(addr)
+	if dial != nil {
+		dialer.Proxy = nil
+		dialer.NetDial = dial
+	}
+	return host
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tunnel
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/config"
+)
+
+func TestListen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tunnel")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	ln, err := Listen("127.0.0.1:0")
+	assert.Nil(t, err)
+	assert.Equal(t, "tcp", ln.Addr().Network())
+	ln.Close()
+
+	// a stale socket is removed.
+	path := filepath.Join(dir, "tunnel.sock")
+	ln, err = Listen("unix://" + path)
+	assert.Nil(t, err)
+	assert.Equal(t, "unix", ln.Addr().Network())
+	ln.(interface{ SetUnlinkOnClose(bool) }).SetUnlinkOnClose(false)
+	ln.Close()
+	ln, err = Listen("unix://" + path)
+	assert.Nil(t, err)
+	ln.Close()
+
+	// other files are kept.
+	path = filepath.Join(dir, "file")
+	assert.Nil(t, ioutil.WriteFile(path, nil, 0644))
+	_, err = Listen("unix://" + path)
+	assert.NotNil(t, err)
+	_, err = os.Stat(path)
+	assert.Nil(t, err)
+}
+
+func TestUnixDial(t *testing.T) {
+	host, dial := UnixDial("127.0.0.1:8287")
+	assert.Equal(t, "127.0.0.1:8287", host)
+	assert.Nil(t, dial)
+	host, dial = UnixDial("unix:///var/run/ote/tunnel.sock")
+	assert.Equal(t, unixHost, host)
+	assert.NotNil(t, dial)
+	assert.Nil(t, unixDialContext("127.0.0.1:8287"))
+}
+
+func TestTunnelOverUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tunnel")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	addr := "unix://" + filepath.Join(dir, "tunnel.sock")
+
+	registered := make(chan *config.ClusterRegistry, 2)
+	ct := NewCloudTunnel(addr).(*cloudTunnel)
+	ct.RegistReturnMessageFunc(func(client string, msg []byte) error {
+		return nil
+	})
+	ct.RegistAfterConnectHook(func(cr *config.ClusterRegistry) {
+		registered <- cr
+	})
+	assert.Nil(t, ct.Start())
+	defer ct.Stop()
+
+	ws := newTestEdgeTunnel()
+	ws.name, ws.cloudAddr = "c1", addr
+	grpc := newTestGRPCEdgeTunnel("c2", addr)
+	for _, e := range []*edgeTunnel{ws, grpc} {
+		assert.Nil(t, e.connect())
+		defer e.wsclient.Close()
+		select {
+		case cr := <-registered:
+			assert.Equal(t, e.name, cr.Name)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("cluster %s is not connected", e.name)
+		}
+	}
+}
@@ -18,12 +18,14 @@ limitations under the License.
 package tunnel
 
 import (
+	"bytes"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"k8s.io/klog"
 
+	"github.com/baidu/ote-stack/pkg/clustermessage"
 	"github.com/baidu/ote-stack/pkg/config"
 )
 
@@ -32,21 +34,54 @@ const (
 	ReadTimeout  = time.Second * 15
 	IdleTimeout  = time.Second * 60
 	StopTimeout  = time.Second * 15
+
+	// maxPooledReadBufferSize is the max capacity of read buffers put back to pool.
+	maxPooledReadBufferSize = 1 << 20
 )
 
-// WSClient is a websocket client.
+var readBufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// messageConn is a connection sending and receiving binary messages.
+type messageConn interface {
+	// writeMessage writes msg, it is not called concurrently.
+	writeMessage(msg []byte) error
+	// readMessage reads the next message into buf.
+	readMessage(buf *bytes.Buffer) error
+	// closeNormally tells the peer the connection is closed on purpose for reason.
+	closeNormally(reason string)
+	close() error
+}
+
+// WSClient is a websocket client, or a client of a grpc stream.
 type WSClient struct {
 	// Name defines uuid of the client.
 	Name string
-	// Conn defines websocket connection.
+	// Conn defines websocket connection, nil if the client is of a grpc stream.
 	Conn  *websocket.Conn
+	conn  messageConn
 	mutex sync.Mutex
+	// queue orders messages written, which are limited by the send queue set.
+	queue *sendQueue
+	// compressor compresses messages by the compression negotiated, nil if not compressed.
+	compressor compressor
+	// mux multiplexes messages by channels negotiated, nil if channels are not used.
+	mux *muxer
+	// stats are counters of the connection.
+	stats *connStats
 }
 
 // RedirectFunc is a function called before ClusterNameChecker,
 // which returns a redirect server address.
 type RedirectFunc func() string
 
+// AssignFunc is a function called before ClusterNameChecker, which returns
+// the server address a cluster is assigned to, empty if it is assigned to this server.
+type AssignFunc func(cluster string) string
+
 // ClusterNameChecker is a function to check cluster name.
 type ClusterNameChecker func(*config.ClusterRegistry) bool
 
@@ -67,40 +102,181 @@ type AfterConnectToHook func()
 // AfterDisconnectHook is a function of edge tunnel to call after disconnect from parent.
 type AfterDisconnectHook func()
 
+// MaxRetriesHook is a function of edge tunnel to call after reconnection failed max retries.
+type MaxRetriesHook func(retries int)
+
 // NewWSClient returns a websocket client.
 func NewWSClient(name string, conn *websocket.Conn) *WSClient {
+	wsclient := newClient(name, &wsConn{conn: conn})
+	wsclient.Conn = conn
+	return wsclient
+}
+
+// newClient returns a client of conn.
+func newClient(name string, conn messageConn) *WSClient {
 	wsclient := &WSClient{
-		Name: name,
-		Conn: conn,
+		Name:  name,
+		conn:  conn,
+		queue: newSendQueue(name),
+		stats: &connStats{connectedAt: time.Now()},
 	}
+	faults.add(wsclient)
 	return wsclient
 }
 
+// setCompression sets the compression negotiated with the peer, before messages are sent or read.
+func (c *WSClient) setCompression(name string) {
+	c.compressor = nil
+	if newCompressor, ok := compressors[name]; ok {
+		c.compressor = newCompressor()
+	}
+}
+
 // Close closes websocket connection.
 func (c *WSClient) Close() error {
-	return c.Conn.Close()
+	faults.remove(c)
+	c.untrack()
+	c.mux.close()
+	return c.conn.close()
 }
 
-// WriteMessage writes binary message to connection.
+// WriteMessage writes binary message to connection in the lane of priority of the message,
+// it returns ErrSendQueueFull if the message is dropped by the send queue.
+// With channels, it waits for the window of the channel of the message first,
+// and returns ErrChannelBlocked if the window is not granted in WriteTimeout.
 func (c *WSClient) WriteMessage(msg []byte) error {
+	if faults.beforeWrite(c.Name) {
+		return nil
+	}
+	var ch Channel
+	if c.mux != nil {
+		ch = ChannelOf(msg)
+		if err := c.mux.reserve(ch, len(msg), WriteTimeout); err != nil {
+			klog.V(3).Infof("wsclient %s drop msg of channel %s: %s", c.Name, ch, err.Error())
+			return err
+		}
+	}
+	if err := c.queue.acquire(clustermessage.PriorityOf(msg)); err != nil {
+		klog.V(3).Infof("wsclient %s drop msg: %s", c.Name, err.Error())
+		c.mux.grant(ch, int64(len(msg)))
+		return err
+	}
+	defer c.queue.release()
+	if c.compressor != nil {
+		frame, err := encodeFrame(c.compressor, msg)
+		if err != nil {
+			klog.Errorf("wsclient %s compress msg failed: %s", c.Name, err.Error())
+			return err
+		}
+		msg = frame
+	}
+	msg = c.mux.frame(ch, msg)
+
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	c.Conn.SetWriteDeadline(time.Now().Add(WriteTimeout))
-	if err := c.Conn.WriteMessage(websocket.BinaryMessage, msg); err != nil {
+	if err := c.conn.writeMessage(msg); err != nil {
 		klog.Errorf("wsclient %s write msg failed: %s", c.Name, err.Error())
 		return err
 	}
+	c.stats.sent(len(msg))
 
 	return nil
 }
 
 // ReadMessage reads binary message from connection.
 func (c *WSClient) ReadMessage() ([]byte, error) {
-	_, message, err := c.Conn.ReadMessage()
+	_, message, err := c.readChannelMessage()
+	return message, err
+}
+
+// readChannelMessage reads binary message from connection with its channel,
+// which is ChannelControl if channels are not used.
+func (c *WSClient) readChannelMessage() (Channel, []byte, error) {
+	// read into a pooled buffer to avoid growing a new one for each message,
+	// then copy out the message by exact size since it is handed over to handlers.
+	buf := readBufferPool.Get().(*bytes.Buffer)
+	defer putReadBuffer(buf)
+	for {
+		buf.Reset()
+		if err := c.conn.readMessage(buf); err != nil {
+			klog.Errorf("wsclient %s read msg failed: %s", c.Name, err.Error())
+			return 0, nil, err
+		}
+		c.stats.received(buf.Len())
+		ch, frame, ok, err := c.mux.demux(buf.Bytes())
+		if err != nil {
+			klog.Errorf("wsclient %s demultiplex msg failed: %s", c.Name, err.Error())
+			return 0, nil, err
+		}
+		if !ok {
+			// window update.
+			continue
+		}
+		if c.compressor != nil {
+			if dict, ok := c.compressor.(*dictCompressor); ok && len(frame) > 0 && frame[0] == frameDictionary {
+				if err := dict.receive(frame[1:]); err != nil {
+					klog.Errorf("wsclient %s receive dictionary failed: %s", c.Name, err.Error())
+					return 0, nil, err
+				}
+				continue
+			}
+			message, err := decodeFrame(c.compressor, frame)
+			if err != nil {
+				klog.Errorf("wsclient %s decompress msg failed: %s", c.Name, err.Error())
+				return 0, nil, err
+			}
+			c.trainDictionary(message)
+			c.stats.receivedMessage()
+			return ch, message, nil
+		}
+		message := make([]byte, len(frame))
+		copy(message, frame)
+		c.stats.receivedMessage()
+		return ch, message, nil
+	}
+}
+
+func putReadBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledReadBufferSize {
+		return
+	}
+	readBufferPool.Put(buf)
+}
+
+// wsConn is a websocket connection.
+type wsConn struct {
+	conn *websocket.Conn
+	// alive is the time to wait for the next message or pong since the last one,
+	// 0 if no heartbeat.
+	alive time.Duration
+}
+
+func (w *wsConn) writeMessage(msg []byte) error {
+	w.conn.SetWriteDeadline(time.Now().Add(WriteTimeout))
+	return w.conn.WriteMessage(websocket.BinaryMessage, msg)
+}
+
+func (w *wsConn) readMessage(buf *bytes.Buffer) error {
+	_, r, err := w.conn.NextReader()
 	if err != nil {
-		klog.Errorf("wsclient %s read msg failed: %s", c.Name, err.Error())
-		return nil, err
+		return err
+	}
+	if _, err = buf.ReadFrom(r); err != nil {
+		return err
+	}
+	if w.alive > 0 {
+		w.conn.SetReadDeadline(time.Now().Add(w.alive))
 	}
-	return message, nil
+	return nil
+}
+
+func (w *wsConn) closeNormally(reason string) {
+	w.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, reason),
+		time.Now().Add(WriteTimeout))
+}
+
+func (w *wsConn) close() error {
+	return w.conn.Close()
 }
@@ -32,8 +32,8 @@ var (
 
 func initTestServer() {
 	testServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		upgrader = websocket.Upgrader{}
-		c, err := upgrader.Upgrade(w, r, nil)
+		testUpgrader := websocket.Upgrader{}
+		c, err := testUpgrader.Upgrade(w, r, nil)
 		if err != nil {
 			return
 		}
@@ -0,0 +1,275 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package watchdog monitors memory of clustercontroller, and sheds low-priority
+// messages under memory pressure to keep it from being OOM killed on small edge nodes.
+/*
+There are 3 levels of memory:
+
+1. normal: memory is under soft limit, nothing is shed.
+2. pressure: memory is over soft limit, bulk reports(e.g., events) are shed.
+3. critical: memory is over hard limit, all edge reports are shed.
+
+Control messages(e.g., ControlReq, ControlResp, ClusterRegist, route) are never shed.
+A level is left after memory falls below 90% of its limit, to avoid flapping.
+*/
+package watchdog
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/reporter"
+)
+
+// Level is the level of memory pressure.
+type Level int32
+
+const (
+	LevelNormal Level = iota
+	LevelPressure
+	LevelCritical
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelPressure:
+		return "pressure"
+	case LevelCritical:
+		return "critical"
+	default:
+		return "normal"
+	}
+}
+
+// Priority is the priority of a message to be kept under memory pressure.
+type Priority int
+
+const (
+	// PriorityControl messages are never shed.
+	PriorityControl Priority = iota
+	// PriorityNormal messages are shed at critical level.
+	PriorityNormal
+	// PriorityBulk messages are shed at pressure level.
+	PriorityBulk
+)
+
+const (
+	// DefaultInterval is the default interval to check memory.
+	DefaultInterval = 5 * time.Second
+	// recoverRatio is the ratio of limit to leave a level.
+	recoverRatio = 0.9
+)
+
+// bulkResourceTypes are the resource types of bulk reports.
+var bulkResourceTypes = map[int]bool{
+	reporter.ResourceTypeEvent: true,
+}
+
+// Config is the config of memory watchdog, limits are in bytes and 0 means no limit.
+type Config struct {
+	SoftLimit uint64
+	HardLimit uint64
+	Interval  time.Duration
+}
+
+// Stats is the stats of memory watchdog.
+type Stats struct {
+	Level      string `json:"level"`
+	Memory     uint64 `json:"memory"`
+	SoftLimit  uint64 `json:"softLimit"`
+	HardLimit  uint64 `json:"hardLimit"`
+	ShedBulk   int64  `json:"shedBulk"`
+	ShedNormal int64  `json:"shedNormal"`
+}
+
+// MemoryWatchdog checks memory periodically and decides which messages should be shed.
+type MemoryWatchdog struct {
+	conf       Config
+	level      int32
+	memory     uint64
+	shedBulk   int64
+	shedNormal int64
+	readMemory func() uint64
+}
+
+var defaultWatchdog = NewMemoryWatchdog(Config{})
+
+// NewMemoryWatchdog returns a MemoryWatchdog, which is at normal level until Run.
+func NewMemoryWatchdog(conf Config) *MemoryWatchdog {
+	if conf.Interval <= 0 {
+		conf.Interval = DefaultInterval
+	}
+	return &MemoryWatchdog{
+		conf:       conf,
+		readMemory: readMemory,
+	}
+}
+
+// Start starts the default watchdog with conf until stop is closed.
+// It does nothing if no limit is set.
+func Start(conf Config, stop <-chan struct{}) {
+	if conf.SoftLimit == 0 && conf.HardLimit == 0 {
+		return
+	}
+	defaultWatchdog = NewMemoryWatchdog(conf)
+	klog.Infof("start memory watchdog, soft limit %d bytes, hard limit %d bytes",
+		conf.SoftLimit, conf.HardLimit)
+	go defaultWatchdog.Run(stop)
+}
+
+// ShouldShed returns true if msg should be shed by the default watchdog.
+func ShouldShed(msg *clustermessage.ClusterMessage) bool {
+	return defaultWatchdog.ShouldShed(msg)
+}
+
+// GetStats returns the stats of the default watchdog.
+func GetStats() Stats {
+	return defaultWatchdog.Stats()
+}
+
+// StatsHandler is the http handler to get stats of the default watchdog in json.
+func StatsHandler(w http.ResponseWriter, r *http.Request) {
+	data, _ := json.Marshal(GetStats())
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// Run checks memory every interval until stop is closed.
+func (m *MemoryWatchdog) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(m.conf.Interval)
+	defer ticker.Stop()
+	m.check()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			m.check()
+		}
+	}
+}
+
+// Level returns the current level of memory.
+func (m *MemoryWatchdog) Level() Level {
+	return Level(atomic.LoadInt32(&m.level))
+}
+
+// ShouldShed returns true if msg should be shed at current level.
+func (m *MemoryWatchdog) ShouldShed(msg *clustermessage.ClusterMessage) bool {
+	level := m.Level()
+	if level == LevelNormal || msg == nil {
+		return false
+	}
+	switch MessagePriority(msg) {
+	case PriorityBulk:
+		atomic.AddInt64(&m.shedBulk, 1)
+		return true
+	case PriorityNormal:
+		if level == LevelCritical {
+			atomic.AddInt64(&m.shedNormal, 1)
+			return true
+		}
+	}
+	return false
+}
+
+// Stats returns the stats of the watchdog.
+func (m *MemoryWatchdog) Stats() Stats {
+	return Stats{
+		Level:      m.Level().String(),
+		Memory:     atomic.LoadUint64(&m.memory),
+		SoftLimit:  m.conf.SoftLimit,
+		HardLimit:  m.conf.HardLimit,
+		ShedBulk:   atomic.LoadInt64(&m.shedBulk),
+		ShedNormal: atomic.LoadInt64(&m.shedNormal),
+	}
+}
+
+func (m *MemoryWatchdog) check() {
+	memory := m.readMemory()
+	atomic.StoreUint64(&m.memory, memory)
+	old := m.Level()
+	level := m.nextLevel(old, memory)
+	if level == old {
+		return
+	}
+	atomic.StoreInt32(&m.level, int32(level))
+	if level > old {
+		klog.Warningf("memory %d bytes, level %s -> %s, start shedding reports", memory, old, level)
+	} else {
+		klog.Infof("memory %d bytes, level %s -> %s", memory, old, level)
+	}
+	if level == LevelCritical {
+		// return memory to OS as soon as possible.
+		debug.FreeOSMemory()
+	}
+}
+
+// nextLevel returns the level of memory, a level is left only if memory
+// falls below recoverRatio of its limit.
+func (m *MemoryWatchdog) nextLevel(current Level, memory uint64) Level {
+	over := func(limit uint64, l Level) bool {
+		if limit == 0 {
+			return false
+		}
+		if current >= l {
+			return float64(memory) >= float64(limit)*recoverRatio
+		}
+		return memory >= limit
+	}
+	switch {
+	case over(m.conf.HardLimit, LevelCritical):
+		return LevelCritical
+	case over(m.conf.SoftLimit, LevelPressure):
+		return LevelPressure
+	default:
+		return LevelNormal
+	}
+}
+
+// readMemory returns the memory obtained from OS and not released by go runtime,
+// which approximates the resident memory of the process.
+func readMemory() uint64 {
+	stats := runtime.MemStats{}
+	runtime.ReadMemStats(&stats)
+	return stats.Sys - stats.HeapReleased
+}
+
+// MessagePriority returns the priority of msg.
+// Edge reports are bulk if all the resources reported are bulk, otherwise they are normal.
+func MessagePriority(msg *clustermessage.ClusterMessage) Priority {
+	if msg.Head == nil || msg.Head.Command != clustermessage.CommandType_EdgeReport {
+		return PriorityControl
+	}
+	reports, err := reporter.DecodeReports(msg.Body)
+	if err != nil || len(reports) == 0 {
+		return PriorityNormal
+	}
+	for _, r := range reports {
+		if !bulkResourceTypes[r.ResourceType] {
+			return PriorityNormal
+		}
+	}
+	return PriorityBulk
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2019 Baidu, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watchdog
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/ote-stack/pkg/clustermessage"
+	"github.com/baidu/ote-stack/pkg/reporter"
+)
+
+func newReportMessage(t *testing.T, resourceTypes ...int) *clustermessage.ClusterMessage {
+	reports := reporter.Reports{}
+	for _, rt := range resourceTypes {
+		reports = append(reports, reporter.Report{ResourceType: rt, Body: []byte("{}")})
+	}
+	msg, err := reports.ToClusterMessage("c1")
+	assert.Nil(t, err)
+	return msg
+}
+
+func newTestWatchdog(memory *uint64) *MemoryWatchdog {
+	m := NewMemoryWatchdog(Config{SoftLimit: 100, HardLimit: 200})
+	m.readMemory = func() uint64 {
+		return *memory
+	}
+	return m
+}
+
+func TestMessagePriority(t *testing.T) {
+	control := &clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{Command: clustermessage.CommandType_ControlResp},
+	}
+	assert.Equal(t, PriorityControl, MessagePriority(control))
+	assert.Equal(t, PriorityControl, MessagePriority(&clustermessage.ClusterMessage{}))
+	assert.Equal(t, PriorityBulk, MessagePriority(newReportMessage(t, reporter.ResourceTypeEvent)))
+	assert.Equal(t, PriorityNormal, MessagePriority(
+		newReportMessage(t, reporter.ResourceTypeEvent, reporter.ResourceTypePod)))
+	assert.Equal(t, PriorityNormal, MessagePriority(newReportMessage(t)))
+
+	bad := newReportMessage(t)
+	bad.Body = []byte("{")
+	assert.Equal(t, PriorityNormal, MessagePriority(bad))
+}
+
+func TestCheckLevel(t *testing.T) {
+	memory := uint64(50)
+	m := newTestWatchdog(&memory)
+
+	cases := []struct {
+		memory uint64
+		level  Level
+	}{
+		{50, LevelNormal},
+		{100, LevelPressure},
+		{95, LevelPressure}, // not below 90% of soft limit
+		{89, LevelNormal},
+		{250, LevelCritical},
+		{185, LevelCritical}, // not below 90% of hard limit
+		{150, LevelPressure},
+		{10, LevelNormal},
+	}
+	for _, c := range cases {
+		memory = c.memory
+		m.check()
+		assert.Equal(t, c.level, m.Level(), "memory %d", c.memory)
+	}
+
+	// no limit
+	m = NewMemoryWatchdog(Config{})
+	m.readMemory = func() uint64 { return 1 << 40 }
+	m.check()
+	assert.Equal(t, LevelNormal, m.Level())
+}
+
+func TestShouldShed(t *testing.T) {
+	memory := uint64(50)
+	m := newTestWatchdog(&memory)
+	control := &clustermessage.ClusterMessage{
+		Head: &clustermessage.MessageHead{Command: clustermessage.CommandType_ControlReq},
+	}
+	bulk := newReportMessage(t, reporter.ResourceTypeEvent)
+	normal := newReportMessage(t, reporter.ResourceTypePod)
+
+	assert.False(t, m.ShouldShed(bulk))
+	assert.False(t, m.ShouldShed(nil))
+
+	memory = 150
+	m.check()
+	assert.False(t, m.ShouldShed(control))
+	assert.False(t, m.ShouldShed(normal))
+	assert.True(t, m.ShouldShed(bulk))
+
+	memory = 300
+	m.check()
+	assert.False(t, m.ShouldShed(control))
+	assert.True(t, m.ShouldShed(normal))
+	assert.True(t, m.ShouldShed(bulk))
+
+	stats := m.Stats()
+	assert.Equal(t, "critical", stats.Level)
+	assert.Equal(t, uint64(300), stats.Memory)
+	assert.Equal(t, int64(2), stats.ShedBulk)
+	assert.Equal(t, int64(1), stats.ShedNormal)
+}
+
+func TestStatsHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	StatsHandler(w, httptest.NewRequest("GET", "/memory", nil))
+	stats := Stats{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, "normal", stats.Level)
+}